@@ -0,0 +1,182 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package analytics exports the sequenced entries of Trillian logs, and the
+// mutations of Trillian maps, into an analytics store, so that operators can
+// answer usage questions without querying the databases serving the trees.
+//
+// Entries and mutations are exported as metadata: sizes, hashes and
+// timestamps. Leaf values are only exported if requested. Exports read the
+// trees through their public APIs, resume after the last row exported to the
+// store, and can backfill from any position.
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ColumnType is the type of the values of a Column.
+type ColumnType int
+
+// Column types, and the Go types of their values in a Row.
+const (
+	// Int64 columns hold int64 values.
+	Int64 ColumnType = iota
+	// String columns hold string values.
+	String
+	// Bytes columns hold []byte values.
+	Bytes
+	// Timestamp columns hold time.Time values.
+	Timestamp
+)
+
+// holds returns whether v has the Go type of the values of columns of type t.
+func (t ColumnType) holds(v interface{}) bool {
+	switch v.(type) {
+	case int64:
+		return t == Int64
+	case string:
+		return t == String
+	case []byte:
+		return t == Bytes
+	case time.Time:
+		return t == Timestamp
+	}
+	return false
+}
+
+// Column is a column of a Table.
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Table describes a table of exported rows. Each row belongs to a tree, whose
+// ID is in TreeColumn, and has a position in it, in PositionColumn, up to
+// which the tree has been exported.
+type Table struct {
+	Name    string
+	Columns []Column
+	// Key holds the names of the columns which identify a row. Rows exported
+	// again, e.g. by a backfill, have the same key.
+	Key            []string
+	TreeColumn     string
+	PositionColumn string
+}
+
+// Row holds the values of a row of a Table, in the order of its columns.
+type Row []interface{}
+
+// Sink stores exported rows in an analytics store.
+type Sink interface {
+	// EnsureTable creates table if it doesn't exist, or adds the columns it
+	// is missing. Columns are never removed.
+	EnsureTable(ctx context.Context, table *Table) error
+	// Insert adds rows to table, which EnsureTable was called for.
+	Insert(ctx context.Context, table *Table, rows []Row) error
+	// LastPosition returns the greatest position of the rows of table which
+	// belong to treeID, and false if there are none.
+	LastPosition(ctx context.Context, table *Table, treeID int64) (int64, bool, error)
+}
+
+// Tables exported, by name.
+const (
+	LogEntriesTableName   = "log_entries"
+	MapMutationsTableName = "map_mutations"
+	MapRevisionsTableName = "map_revisions"
+)
+
+// LogEntriesTable returns the table of the sequenced entries of logs, with
+// their values and extra data if includeValues is set.
+func LogEntriesTable(includeValues bool) *Table {
+	t := &Table{
+		Name: LogEntriesTableName,
+		Columns: []Column{
+			{Name: "log_id", Type: Int64},
+			{Name: "leaf_index", Type: Int64},
+			{Name: "merkle_leaf_hash", Type: String},
+			{Name: "leaf_identity_hash", Type: String},
+			{Name: "queue_timestamp", Type: Timestamp},
+			{Name: "integrate_timestamp", Type: Timestamp},
+			{Name: "leaf_value_size", Type: Int64},
+			{Name: "extra_data_size", Type: Int64},
+		},
+		Key:            []string{"log_id", "leaf_index"},
+		TreeColumn:     "log_id",
+		PositionColumn: "leaf_index",
+	}
+	if includeValues {
+		t.Columns = append(t.Columns, Column{Name: "leaf_value", Type: Bytes}, Column{Name: "extra_data", Type: Bytes})
+	}
+	return t
+}
+
+// MapMutationsTable returns the table of the leaves written to maps at each
+// revision, with their values and extra data if includeValues is set.
+func MapMutationsTable(includeValues bool) *Table {
+	t := &Table{
+		Name: MapMutationsTableName,
+		Columns: []Column{
+			{Name: "map_id", Type: Int64},
+			{Name: "revision", Type: Int64},
+			{Name: "revision_timestamp", Type: Timestamp},
+			{Name: "leaf_index", Type: String},
+			{Name: "leaf_hash", Type: String},
+			{Name: "leaf_value_size", Type: Int64},
+			{Name: "extra_data_size", Type: Int64},
+		},
+		Key:            []string{"map_id", "revision", "leaf_index"},
+		TreeColumn:     "map_id",
+		PositionColumn: "revision",
+	}
+	if includeValues {
+		t.Columns = append(t.Columns, Column{Name: "leaf_value", Type: Bytes}, Column{Name: "extra_data", Type: Bytes})
+	}
+	return t
+}
+
+// MapRevisionsTable returns the table of the revisions of maps. A revision is
+// only added once all its mutations have been exported.
+func MapRevisionsTable() *Table {
+	return &Table{
+		Name: MapRevisionsTableName,
+		Columns: []Column{
+			{Name: "map_id", Type: Int64},
+			{Name: "revision", Type: Int64},
+			{Name: "timestamp", Type: Timestamp},
+			{Name: "root_hash", Type: String},
+			{Name: "metadata_size", Type: Int64},
+			{Name: "mutation_count", Type: Int64},
+		},
+		Key:            []string{"map_id", "revision"},
+		TreeColumn:     "map_id",
+		PositionColumn: "revision",
+	}
+}
+
+// checkRow returns an error if row doesn't hold a value of the right type for
+// each column of table.
+func checkRow(table *Table, row Row) error {
+	if len(row) != len(table.Columns) {
+		return fmt.Errorf("analytics: %d values for the %d columns of %s", len(row), len(table.Columns), table.Name)
+	}
+	for i, col := range table.Columns {
+		if !col.Type.holds(row[i]) {
+			return fmt.Errorf("analytics: value %T of column %s.%s has the wrong type", row[i], table.Name, col.Name)
+		}
+	}
+	return nil
+}
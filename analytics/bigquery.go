@@ -0,0 +1,211 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// bigQueryTimeFormat is the format of TIMESTAMP values in JSON loads, which
+// have microsecond precision.
+const bigQueryTimeFormat = "2006-01-02T15:04:05.000000Z"
+
+// bigQueryField is a field of a BigQuery JSON schema file.
+type bigQueryField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode"`
+}
+
+// BigQueryFileSink stores rows in files which can be loaded into BigQuery
+// tables. For each table, it writes:
+//   - <table>.schema.json, its schema;
+//   - <table>.json, its rows, as newline-delimited JSON; and
+//   - <table>.positions.json, the last position exported for each tree.
+//
+// The rows are loaded with e.g.
+//
+//	bq load --source_format=NEWLINE_DELIMITED_JSON \
+//	  --schema_update_option=ALLOW_FIELD_ADDITION \
+//	  dataset.log_entries log_entries.json log_entries.schema.json
+//
+// after which the rows file can be removed. Rows exported again by a backfill
+// are duplicated in BigQuery, and can be told apart by the key of the table.
+type BigQueryFileSink struct {
+	dir string
+	mu  sync.Mutex
+}
+
+var _ Sink = &BigQueryFileSink{}
+
+// NewBigQueryFileSink returns a sink which writes files in dir.
+func NewBigQueryFileSink(dir string) *BigQueryFileSink {
+	return &BigQueryFileSink{dir: dir}
+}
+
+// EnsureTable implements Sink.
+func (s *BigQueryFileSink) EnsureTable(ctx context.Context, table *Table) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := s.path(table, ".schema.json")
+	var fields []bigQueryField
+	if err := readJSON(path, &fields); err != nil {
+		return err
+	}
+	have := make(map[string]bool)
+	for _, f := range fields {
+		have[f.Name] = true
+	}
+	n := len(fields)
+	for _, col := range table.Columns {
+		if !have[col.Name] {
+			fields = append(fields, bigQueryField{Name: col.Name, Type: bigQueryType(col.Type), Mode: "NULLABLE"})
+		}
+	}
+	if len(fields) == n {
+		return nil
+	}
+	return writeJSON(path, fields)
+}
+
+// Insert implements Sink.
+func (s *BigQueryFileSink) Insert(ctx context.Context, table *Table, rows []Row) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	positions, err := s.positions(table)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path(table, ".json"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, row := range rows {
+		if err := checkRow(table, row); err != nil {
+			f.Close()
+			return err
+		}
+		obj := make(map[string]interface{}, len(row))
+		var tree, pos int64
+		for i, col := range table.Columns {
+			switch v := row[i].(type) {
+			case []byte:
+				obj[col.Name] = base64.StdEncoding.EncodeToString(v)
+			case time.Time:
+				obj[col.Name] = v.UTC().Format(bigQueryTimeFormat)
+			case int64:
+				switch col.Name {
+				case table.TreeColumn:
+					tree = v
+				case table.PositionColumn:
+					pos = v
+				}
+				obj[col.Name] = v
+			default:
+				obj[col.Name] = v
+			}
+		}
+		if err := enc.Encode(obj); err != nil {
+			f.Close()
+			return err
+		}
+		key := strconv.FormatInt(tree, 10)
+		if last, ok := positions[key]; !ok || pos > last {
+			positions[key] = pos
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// The positions are written after the rows, so that rows are exported
+	// again rather than lost if writing them fails.
+	return writeJSON(s.path(table, ".positions.json"), positions)
+}
+
+// LastPosition implements Sink.
+func (s *BigQueryFileSink) LastPosition(ctx context.Context, table *Table, treeID int64) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	positions, err := s.positions(table)
+	if err != nil {
+		return 0, false, err
+	}
+	last, ok := positions[strconv.FormatInt(treeID, 10)]
+	return last, ok, nil
+}
+
+func (s *BigQueryFileSink) positions(table *Table) (map[string]int64, error) {
+	positions := make(map[string]int64)
+	if err := readJSON(s.path(table, ".positions.json"), &positions); err != nil {
+		return nil, err
+	}
+	return positions, nil
+}
+
+func (s *BigQueryFileSink) path(table *Table, suffix string) string {
+	return filepath.Join(s.dir, table.Name+suffix)
+}
+
+// readJSON decodes the JSON file at path into v, leaving v unchanged if the
+// file doesn't exist.
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return nil
+}
+
+// writeJSON replaces the file at path with v encoded as JSON.
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func bigQueryType(t ColumnType) string {
+	switch t {
+	case Int64:
+		return "INTEGER"
+	case Bytes:
+		return "BYTES"
+	case Timestamp:
+		return "TIMESTAMP"
+	default:
+		return "STRING"
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBigQueryFileSink(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "bigquery")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	sink := NewBigQueryFileSink(dir)
+
+	table := &Table{
+		Name:           "entries",
+		Columns:        []Column{{Name: "tree", Type: Int64}, {Name: "pos", Type: Int64}, {Name: "time", Type: Timestamp}},
+		TreeColumn:     "tree",
+		PositionColumn: "pos",
+	}
+	if err := sink.EnsureTable(ctx, table); err != nil {
+		t.Fatalf("EnsureTable(): %v", err)
+	}
+	// A later version of the table has a new column.
+	table.Columns = append(table.Columns, Column{Name: "value", Type: Bytes})
+	if err := sink.EnsureTable(ctx, table); err != nil {
+		t.Fatalf("EnsureTable() again: %v", err)
+	}
+	var fields []bigQueryField
+	if err := readJSON(filepath.Join(dir, "entries.schema.json"), &fields); err != nil {
+		t.Fatalf("reading schema: %v", err)
+	}
+	wantFields := []bigQueryField{
+		{Name: "tree", Type: "INTEGER", Mode: "NULLABLE"},
+		{Name: "pos", Type: "INTEGER", Mode: "NULLABLE"},
+		{Name: "time", Type: "TIMESTAMP", Mode: "NULLABLE"},
+		{Name: "value", Type: "BYTES", Mode: "NULLABLE"},
+	}
+	if !reflect.DeepEqual(fields, wantFields) {
+		t.Errorf("schema %+v, want %+v", fields, wantFields)
+	}
+
+	if _, ok, err := sink.LastPosition(ctx, table, 1); err != nil || ok {
+		t.Errorf("LastPosition() before Insert(): %t, %v, want false", ok, err)
+	}
+	for _, rows := range [][]Row{
+		{{int64(1), int64(5), time.Unix(1, 1500).UTC(), []byte("a")}, {int64(2), int64(3), time.Unix(2, 0).UTC(), []byte(nil)}},
+		{{int64(1), int64(6), time.Unix(3, 0).UTC(), []byte("b")}},
+	} {
+		if err := sink.Insert(ctx, table, rows); err != nil {
+			t.Fatalf("Insert(): %v", err)
+		}
+	}
+	for tree, want := range map[int64]int64{1: 6, 2: 3} {
+		if last, ok, err := sink.LastPosition(ctx, table, tree); err != nil || !ok || last != want {
+			t.Errorf("LastPosition(%d): %d, %t, %v, want %d, true", tree, last, ok, err, want)
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "entries.json"))
+	if err != nil {
+		t.Fatalf("reading rows: %v", err)
+	}
+	want := `{"pos":5,"time":"1970-01-01T00:00:01.000001Z","tree":1,"value":"YQ=="}` + "\n" +
+		`{"pos":3,"time":"1970-01-01T00:00:02.000000Z","tree":2,"value":""}` + "\n" +
+		`{"pos":6,"time":"1970-01-01T00:00:03.000000Z","tree":1,"value":"Yg=="}` + "\n"
+	if got := string(data); got != want {
+		t.Errorf("rows:\n%s\nwant:\n%s", got, want)
+	}
+}
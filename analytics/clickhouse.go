@@ -0,0 +1,168 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clickHouseTimeFormat is the format of DateTime64 values in JSONEachRow
+// inserts.
+const clickHouseTimeFormat = "2006-01-02 15:04:05.000000000"
+
+// ClickHouseSink stores rows in a ClickHouse database, through its HTTP
+// interface.
+//
+// Tables use the ReplacingMergeTree engine, ordered by their key, so that rows
+// exported again by a backfill eventually replace the earlier ones. Bytes
+// values are stored base64-encoded in String columns.
+type ClickHouseSink struct {
+	url      string
+	database string
+	client   *http.Client
+}
+
+var _ Sink = &ClickHouseSink{}
+
+// NewClickHouseSink returns a sink which stores rows in database, served by
+// the ClickHouse HTTP interface at serverURL, e.g. "http://localhost:8123",
+// with client. A nil client uses http.DefaultClient.
+func NewClickHouseSink(serverURL, database string, client *http.Client) *ClickHouseSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &ClickHouseSink{url: strings.TrimSuffix(serverURL, "/"), database: database, client: client}
+}
+
+// EnsureTable implements Sink.
+func (s *ClickHouseSink) EnsureTable(ctx context.Context, table *Table) error {
+	cols := make([]string, 0, len(table.Columns))
+	for _, col := range table.Columns {
+		cols = append(cols, fmt.Sprintf("%s %s", col.Name, clickHouseType(col.Type)))
+	}
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = ReplacingMergeTree ORDER BY (%s)",
+		table.Name, strings.Join(cols, ", "), strings.Join(table.Key, ", "))
+	if _, err := s.query(ctx, create, nil); err != nil {
+		return err
+	}
+	// Add the columns which tables created by earlier versions are missing.
+	for _, col := range table.Columns {
+		alter := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", table.Name, col.Name, clickHouseType(col.Type))
+		if _, err := s.query(ctx, alter, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert implements Sink.
+func (s *ClickHouseSink) Insert(ctx context.Context, table *Table, rows []Row) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := checkRow(table, row); err != nil {
+			return err
+		}
+		obj := make(map[string]interface{}, len(row))
+		for i, col := range table.Columns {
+			switch v := row[i].(type) {
+			case []byte:
+				obj[col.Name] = base64.StdEncoding.EncodeToString(v)
+			case time.Time:
+				obj[col.Name] = v.UTC().Format(clickHouseTimeFormat)
+			default:
+				obj[col.Name] = v
+			}
+		}
+		if err := enc.Encode(obj); err != nil {
+			return err
+		}
+	}
+	_, err := s.query(ctx, fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", table.Name), &body)
+	return err
+}
+
+// LastPosition implements Sink.
+func (s *ClickHouseSink) LastPosition(ctx context.Context, table *Table, treeID int64) (int64, bool, error) {
+	q := fmt.Sprintf("SELECT max(%s), count() FROM %s WHERE %s = %d FORMAT TabSeparated",
+		table.PositionColumn, table.Name, table.TreeColumn, treeID)
+	out, err := s.query(ctx, q, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return 0, false, fmt.Errorf("clickhouse: unexpected result %q", out)
+	}
+	count, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || count == 0 {
+		return 0, false, err
+	}
+	last, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return last, true, nil
+}
+
+// query runs q, with body as its data if set, and returns its output.
+func (s *ClickHouseSink) query(ctx context.Context, q string, body io.Reader) ([]byte, error) {
+	params := url.Values{"query": {q}}
+	if s.database != "" {
+		params.Set("database", s.database)
+	}
+	if body == nil {
+		body = http.NoBody
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url+"/?"+params.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	out, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("clickhouse: %s: %s", resp.Status, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+func clickHouseType(t ColumnType) string {
+	switch t {
+	case Int64:
+		return "Int64"
+	case Timestamp:
+		return "DateTime64(9, 'UTC')"
+	default:
+		return "String"
+	}
+}
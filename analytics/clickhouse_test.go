@@ -0,0 +1,118 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClickHouse records the queries it receives, and answers them with the
+// output set for the first matching prefix.
+type fakeClickHouse struct {
+	queries []string
+	bodies  []string
+	outputs map[string]string
+}
+
+func (f *fakeClickHouse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("query")
+	if got, want := r.URL.Query().Get("database"), "trillian"; got != want {
+		http.Error(w, "wrong database "+got, http.StatusBadRequest)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	f.queries = append(f.queries, q)
+	f.bodies = append(f.bodies, string(body))
+	for prefix, out := range f.outputs {
+		if strings.HasPrefix(q, prefix) {
+			if strings.HasPrefix(out, "error") {
+				http.Error(w, out, http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(out))
+		}
+	}
+}
+
+func TestClickHouseSink(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeClickHouse{outputs: map[string]string{"SELECT": "41\t7\n"}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	sink := NewClickHouseSink(server.URL+"/", "trillian", nil)
+
+	table := &Table{
+		Name:           "entries",
+		Columns:        []Column{{Name: "tree", Type: Int64}, {Name: "pos", Type: Int64}, {Name: "value", Type: Bytes}, {Name: "time", Type: Timestamp}},
+		Key:            []string{"tree", "pos"},
+		TreeColumn:     "tree",
+		PositionColumn: "pos",
+	}
+	if err := sink.EnsureTable(ctx, table); err != nil {
+		t.Fatalf("EnsureTable(): %v", err)
+	}
+	wantQueries := []string{
+		"CREATE TABLE IF NOT EXISTS entries (tree Int64, pos Int64, value String, time DateTime64(9, 'UTC')) ENGINE = ReplacingMergeTree ORDER BY (tree, pos)",
+		"ALTER TABLE entries ADD COLUMN IF NOT EXISTS tree Int64",
+		"ALTER TABLE entries ADD COLUMN IF NOT EXISTS pos Int64",
+		"ALTER TABLE entries ADD COLUMN IF NOT EXISTS value String",
+		"ALTER TABLE entries ADD COLUMN IF NOT EXISTS time DateTime64(9, 'UTC')",
+	}
+	if got := strings.Join(fake.queries, "\n"); got != strings.Join(wantQueries, "\n") {
+		t.Errorf("EnsureTable() queries:\n%s\nwant:\n%s", got, strings.Join(wantQueries, "\n"))
+	}
+
+	fake.queries, fake.bodies = nil, nil
+	rows := []Row{
+		{int64(1), int64(40), []byte("a"), time.Unix(1, 5).UTC()},
+		{int64(1), int64(41), []byte(nil), time.Unix(2, 0).UTC()},
+	}
+	if err := sink.Insert(ctx, table, rows); err != nil {
+		t.Fatalf("Insert(): %v", err)
+	}
+	if got, want := fake.queries[0], "INSERT INTO entries FORMAT JSONEachRow"; got != want {
+		t.Errorf("Insert() query %q, want %q", got, want)
+	}
+	wantBody := `{"pos":40,"time":"1970-01-01 00:00:01.000000005","tree":1,"value":"YQ=="}` + "\n" +
+		`{"pos":41,"time":"1970-01-01 00:00:02.000000000","tree":1,"value":""}` + "\n"
+	if got := fake.bodies[0]; got != wantBody {
+		t.Errorf("Insert() body:\n%s\nwant:\n%s", got, wantBody)
+	}
+	if err := sink.Insert(ctx, table, []Row{{int64(1)}}); err == nil {
+		t.Error("Insert(short row): nil, want err")
+	}
+
+	fake.queries = nil
+	if last, ok, err := sink.LastPosition(ctx, table, 1); err != nil || !ok || last != 41 {
+		t.Errorf("LastPosition(): %d, %t, %v, want 41, true", last, ok, err)
+	}
+	if got, want := fake.queries[0], "SELECT max(pos), count() FROM entries WHERE tree = 1 FORMAT TabSeparated"; got != want {
+		t.Errorf("LastPosition() query %q, want %q", got, want)
+	}
+	fake.outputs["SELECT"] = "0\t0\n"
+	if _, ok, err := sink.LastPosition(ctx, table, 2); err != nil || ok {
+		t.Errorf("LastPosition() of empty tree: %t, %v, want false", ok, err)
+	}
+	fake.outputs["SELECT"] = "error: table is broken"
+	if _, _, err := sink.LastPosition(ctx, table, 2); err == nil || !strings.Contains(err.Error(), "table is broken") {
+		t.Errorf("LastPosition() of failed query: %v, want error with server message", err)
+	}
+}
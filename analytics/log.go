@@ -0,0 +1,218 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/ptypes"
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/util/clock"
+)
+
+// LogConfig holds the dependencies and parameters of a LogExporter.
+type LogConfig struct {
+	// Client serves the log to export, with ID LogID.
+	Client trillian.TrillianLogClient
+	LogID  int64
+	Sink   Sink
+
+	// IncludeValues exports the values and extra data of the entries.
+	IncludeValues bool
+	// ChunkSize is the number of entries in each response streamed by the
+	// log, and in each insert into the sink. Zero uses the default of the
+	// server.
+	ChunkSize int32
+	// Backfill exports the entries from BackfillFrom, rather than from the
+	// entry following the last one in the sink. Entries exported again have
+	// the same key as before.
+	Backfill     bool
+	BackfillFrom int64
+
+	// Interval is the delay between exports, or before retrying after an
+	// error, when running continuously.
+	Interval      time.Duration
+	TimeSource    clock.TimeSource
+	MetricFactory monitoring.MetricFactory
+}
+
+// LogExporter exports the sequenced entries of a log into a Sink, in order.
+type LogExporter struct {
+	cfg     LogConfig
+	table   *Table
+	label   string
+	rows    monitoring.Counter
+	next    int64
+	started bool
+}
+
+// NewLogExporter returns a new LogExporter.
+func NewLogExporter(cfg LogConfig) (*LogExporter, error) {
+	if cfg.Client == nil || cfg.Sink == nil {
+		return nil, errors.New("analytics: log client and sink are required")
+	}
+	if cfg.ChunkSize < 0 {
+		return nil, fmt.Errorf("analytics: chunk size must not be negative, got %d", cfg.ChunkSize)
+	}
+	if cfg.Backfill && cfg.BackfillFrom < 0 {
+		return nil, fmt.Errorf("analytics: backfill must start from a leaf index, got %d", cfg.BackfillFrom)
+	}
+	if cfg.TimeSource == nil {
+		cfg.TimeSource = clock.System
+	}
+	return &LogExporter{
+		cfg:   cfg,
+		table: LogEntriesTable(cfg.IncludeValues),
+		label: strconv.FormatInt(cfg.LogID, 10),
+		rows:  newRowsCounter(cfg.MetricFactory),
+	}, nil
+}
+
+// Run exports the entries of the log as they are sequenced, every Interval,
+// until ctx is cancelled. Errors are logged, and retried.
+func (e *LogExporter) Run(ctx context.Context) error {
+	return run(ctx, e.cfg.Interval, e.cfg.TimeSource, func(ctx context.Context) error {
+		n, err := e.Export(ctx)
+		if err != nil {
+			return fmt.Errorf("log %d: %v", e.cfg.LogID, err)
+		}
+		glog.V(1).Infof("Exported %d entries of log %d", n, e.cfg.LogID)
+		return nil
+	})
+}
+
+// Export exports the entries of the log which haven't been yet, up to the
+// size of its latest root, and returns how many it exported.
+func (e *LogExporter) Export(ctx context.Context) (int, error) {
+	if err := e.start(ctx); err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := e.cfg.Client.GetLeavesByRangeStream(ctx, &trillian.GetLeavesByRangeStreamRequest{
+		LogId:      e.cfg.LogID,
+		StartIndex: e.next,
+		ChunkSize:  e.cfg.ChunkSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("GetLeavesByRangeStream(%d, %d): %v", e.cfg.LogID, e.next, err)
+	}
+	exported := 0
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return exported, nil
+		} else if err != nil {
+			return exported, fmt.Errorf("GetLeavesByRangeStream(%d, %d): %v", e.cfg.LogID, e.next, err)
+		}
+		if len(resp.Leaves) == 0 {
+			continue
+		}
+		rows := make([]Row, 0, len(resp.Leaves))
+		for _, leaf := range resp.Leaves {
+			rows = append(rows, e.row(leaf))
+		}
+		if err := e.cfg.Sink.Insert(ctx, e.table, rows); err != nil {
+			return exported, fmt.Errorf("failed to insert %d entries from index %d: %v", len(rows), e.next, err)
+		}
+		e.rows.Add(float64(len(rows)), e.table.Name, e.label)
+		exported += len(rows)
+		e.next = resp.Leaves[len(resp.Leaves)-1].LeafIndex + 1
+	}
+}
+
+// start creates the table of entries, if needed, and finds the first entry
+// to export, the first time it's called.
+func (e *LogExporter) start(ctx context.Context) error {
+	if e.started {
+		return nil
+	}
+	if err := e.cfg.Sink.EnsureTable(ctx, e.table); err != nil {
+		return fmt.Errorf("failed to create table %s: %v", e.table.Name, err)
+	}
+	if e.cfg.Backfill {
+		e.next = e.cfg.BackfillFrom
+	} else {
+		last, ok, err := e.cfg.Sink.LastPosition(ctx, e.table, e.cfg.LogID)
+		if err != nil {
+			return fmt.Errorf("failed to read the last exported entry: %v", err)
+		}
+		if ok {
+			e.next = last + 1
+		}
+	}
+	e.started = true
+	return nil
+}
+
+func (e *LogExporter) row(leaf *trillian.LogLeaf) Row {
+	row := Row{
+		e.cfg.LogID,
+		leaf.LeafIndex,
+		hex.EncodeToString(leaf.MerkleLeafHash),
+		hex.EncodeToString(leaf.LeafIdentityHash),
+		timestamp(leaf.QueueTimestamp),
+		timestamp(leaf.IntegrateTimestamp),
+		int64(len(leaf.LeafValue)),
+		int64(len(leaf.ExtraData)),
+	}
+	if e.cfg.IncludeValues {
+		row = append(row, leaf.LeafValue, leaf.ExtraData)
+	}
+	return row
+}
+
+// timestamp returns ts as a time, or the Unix epoch if it's unset or invalid.
+func timestamp(ts *tspb.Timestamp) time.Time {
+	t, err := ptypes.Timestamp(ts)
+	if err != nil {
+		return time.Unix(0, 0).UTC()
+	}
+	return t
+}
+
+func newRowsCounter(mf monitoring.MetricFactory) monitoring.Counter {
+	if mf == nil {
+		mf = monitoring.InertMetricFactory{}
+	}
+	return mf.NewCounter("analytics_exported_rows", "Number of rows exported to the analytics store", "table", monitoring.TreeIDLabel)
+}
+
+// run calls export every interval until ctx is cancelled, logging errors.
+func run(ctx context.Context, interval time.Duration, ts clock.TimeSource, export func(context.Context) error) error {
+	if interval <= 0 {
+		return fmt.Errorf("analytics: interval must be positive, got %v", interval)
+	}
+	for {
+		if err := export(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			glog.Errorf("analytics export: %v", err)
+		}
+		if err := clock.SleepSource(ctx, interval, ts); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/testonly"
+)
+
+// memSink holds rows in memory.
+type memSink struct {
+	tables map[string]*Table
+	rows   map[string][]Row
+}
+
+func newMemSink() *memSink {
+	return &memSink{tables: make(map[string]*Table), rows: make(map[string][]Row)}
+}
+
+func (s *memSink) EnsureTable(_ context.Context, table *Table) error {
+	s.tables[table.Name] = table
+	return nil
+}
+
+func (s *memSink) Insert(_ context.Context, table *Table, rows []Row) error {
+	if s.tables[table.Name] == nil {
+		return fmt.Errorf("no table %s", table.Name)
+	}
+	for _, row := range rows {
+		if err := checkRow(table, row); err != nil {
+			return err
+		}
+	}
+	s.rows[table.Name] = append(s.rows[table.Name], rows...)
+	return nil
+}
+
+func (s *memSink) LastPosition(_ context.Context, table *Table, treeID int64) (int64, bool, error) {
+	var tree, pos int
+	for i, col := range table.Columns {
+		switch col.Name {
+		case table.TreeColumn:
+			tree = i
+		case table.PositionColumn:
+			pos = i
+		}
+	}
+	var last int64
+	ok := false
+	for _, row := range s.rows[table.Name] {
+		if row[tree].(int64) == treeID && (!ok || row[pos].(int64) > last) {
+			last, ok = row[pos].(int64), true
+		}
+	}
+	return last, ok, nil
+}
+
+// column returns the values of the column named name of the rows of table.
+func (s *memSink) column(table, name string) []interface{} {
+	var values []interface{}
+	for i, col := range s.tables[table].Columns {
+		if col.Name == name {
+			for _, row := range s.rows[table] {
+				values = append(values, row[i])
+			}
+		}
+	}
+	return values
+}
+
+func TestLogExporter(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, stop, err := testonly.NewMockServer(ctrl)
+	if err != nil {
+		t.Fatalf("NewMockServer(): %v", err)
+	}
+	defer stop()
+
+	queued := time.Unix(1500000000, 0).UTC()
+	ts, err := ptypes.TimestampProto(queued)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaves := func(start, end int64) []*trillian.LogLeaf {
+		var ret []*trillian.LogLeaf
+		for i := start; i < end; i++ {
+			ret = append(ret, &trillian.LogLeaf{LeafIndex: i, LeafValue: []byte(fmt.Sprintf("value-%d", i)), MerkleLeafHash: []byte{byte(i)}, QueueTimestamp: ts})
+		}
+		return ret
+	}
+	// expect makes the log stream leaves from start, in chunks of two.
+	expect := func(start, end int64) {
+		s.Log.EXPECT().GetLeavesByRangeStream(&trillian.GetLeavesByRangeStreamRequest{LogId: 1, StartIndex: start, ChunkSize: 2}, gomock.Any()).DoAndReturn(
+			func(req *trillian.GetLeavesByRangeStreamRequest, stream trillian.TrillianLog_GetLeavesByRangeStreamServer) error {
+				for i := start; i < end; i += 2 {
+					j := i + 2
+					if j > end {
+						j = end
+					}
+					if err := stream.Send(&trillian.GetLeavesByRangeStreamResponse{Leaves: leaves(i, j)}); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+	}
+
+	sink := newMemSink()
+	e, err := NewLogExporter(LogConfig{Client: s.LogClient, LogID: 1, Sink: sink, ChunkSize: 2, IncludeValues: true})
+	if err != nil {
+		t.Fatalf("NewLogExporter(): %v", err)
+	}
+	expect(0, 5)
+	if n, err := e.Export(ctx); err != nil || n != 5 {
+		t.Fatalf("Export(): %d, %v, want 5", n, err)
+	}
+	expect(5, 6)
+	if n, err := e.Export(ctx); err != nil || n != 1 {
+		t.Fatalf("Export() again: %d, %v, want 1", n, err)
+	}
+	want := []interface{}{int64(0), int64(1), int64(2), int64(3), int64(4), int64(5)}
+	if got := sink.column(LogEntriesTableName, "leaf_index"); !reflect.DeepEqual(got, want) {
+		t.Errorf("exported leaf indices %v, want %v", got, want)
+	}
+	if got, want := sink.column(LogEntriesTableName, "leaf_value")[1], []byte("value-1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("exported leaf value %q, want %q", got, want)
+	}
+	if got := sink.column(LogEntriesTableName, "queue_timestamp")[0]; got != queued {
+		t.Errorf("exported queue timestamp %v, want %v", got, queued)
+	}
+
+	// A new exporter resumes after the last entry in the sink, unless it
+	// backfills.
+	e, err = NewLogExporter(LogConfig{Client: s.LogClient, LogID: 1, Sink: sink, ChunkSize: 2})
+	if err != nil {
+		t.Fatalf("NewLogExporter(): %v", err)
+	}
+	expect(6, 6)
+	if n, err := e.Export(ctx); err != nil || n != 0 {
+		t.Errorf("resumed Export(): %d, %v, want 0", n, err)
+	}
+	e, err = NewLogExporter(LogConfig{Client: s.LogClient, LogID: 1, Sink: newMemSink(), ChunkSize: 2, Backfill: true, BackfillFrom: 4})
+	if err != nil {
+		t.Fatalf("NewLogExporter(): %v", err)
+	}
+	expect(4, 6)
+	if n, err := e.Export(ctx); err != nil || n != 2 {
+		t.Errorf("backfill Export(): %d, %v, want 2", n, err)
+	}
+}
+
+func TestNewLogExporter_Errors(t *testing.T) {
+	client := trillian.NewTrillianLogClient(nil)
+	for _, cfg := range []LogConfig{
+		{Sink: newMemSink()},
+		{Client: client},
+		{Client: client, Sink: newMemSink(), ChunkSize: -1},
+		{Client: client, Sink: newMemSink(), Backfill: true, BackfillFrom: -1},
+	} {
+		if _, err := NewLogExporter(cfg); err == nil {
+			t.Errorf("NewLogExporter(%+v): nil, want err", cfg)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+)
+
+// MapConfig holds the dependencies and parameters of a MapExporter.
+type MapConfig struct {
+	// Client serves the map to export, with ID MapID.
+	Client trillian.TrillianMapClient
+	MapID  int64
+	Sink   Sink
+
+	// IncludeValues exports the values and extra data of the leaves written.
+	IncludeValues bool
+	// PageSize is the number of roots, or of leaves with all their versions,
+	// in each response streamed by the map. Zero uses the default of the
+	// server.
+	PageSize int32
+	// Backfill exports the revisions from BackfillFrom, rather than from the
+	// revision following the last one in the sink. Mutations exported again
+	// have the same key as before.
+	Backfill     bool
+	BackfillFrom int64
+
+	// Interval is the delay between exports, or before retrying after an
+	// error, when running continuously.
+	Interval      time.Duration
+	TimeSource    clock.TimeSource
+	MetricFactory monitoring.MetricFactory
+}
+
+// MapExporter exports the leaves written to a map at each revision, and the
+// revisions themselves, into a Sink.
+//
+// The leaves written at new revisions are found by exporting the map in full,
+// which costs a scan of all the versions of its leaves per export, so exports
+// are best run at a modest interval.
+type MapExporter struct {
+	cfg       MapConfig
+	mutations *Table
+	revisions *Table
+	label     string
+	rows      monitoring.Counter
+	next      int64
+	started   bool
+}
+
+// NewMapExporter returns a new MapExporter.
+func NewMapExporter(cfg MapConfig) (*MapExporter, error) {
+	if cfg.Client == nil || cfg.Sink == nil {
+		return nil, errors.New("analytics: map client and sink are required")
+	}
+	if cfg.PageSize < 0 {
+		return nil, fmt.Errorf("analytics: page size must not be negative, got %d", cfg.PageSize)
+	}
+	if cfg.Backfill && cfg.BackfillFrom < 0 {
+		return nil, fmt.Errorf("analytics: backfill must start from a revision, got %d", cfg.BackfillFrom)
+	}
+	if cfg.TimeSource == nil {
+		cfg.TimeSource = clock.System
+	}
+	return &MapExporter{
+		cfg:       cfg,
+		mutations: MapMutationsTable(cfg.IncludeValues),
+		revisions: MapRevisionsTable(),
+		label:     strconv.FormatInt(cfg.MapID, 10),
+		rows:      newRowsCounter(cfg.MetricFactory),
+	}, nil
+}
+
+// Run exports the revisions of the map as they are published, every
+// Interval, until ctx is cancelled. Errors are logged, and retried.
+func (e *MapExporter) Run(ctx context.Context) error {
+	return run(ctx, e.cfg.Interval, e.cfg.TimeSource, func(ctx context.Context) error {
+		n, err := e.Export(ctx)
+		if err != nil {
+			return fmt.Errorf("map %d: %v", e.cfg.MapID, err)
+		}
+		glog.V(1).Infof("Exported %d revisions of map %d", n, e.cfg.MapID)
+		return nil
+	})
+}
+
+// Export exports the revisions of the map which haven't been yet, up to its
+// latest one, and returns how many it exported. The mutations of all the
+// revisions are exported before the revisions, so that an interrupted export
+// is resumed from its first revision.
+func (e *MapExporter) Export(ctx context.Context) (int, error) {
+	if err := e.start(ctx); err != nil {
+		return 0, err
+	}
+	resp, err := e.cfg.Client.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{MapId: e.cfg.MapID})
+	if err != nil {
+		return 0, fmt.Errorf("GetSignedMapRoot(%d): %v", e.cfg.MapID, err)
+	}
+	var latest types.MapRootV1
+	if err := latest.UnmarshalBinary(resp.GetMapRoot().GetMapRoot()); err != nil {
+		return 0, err
+	}
+	last := int64(latest.Revision)
+	if last < e.next {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := e.cfg.Client.ExportMap(ctx, &trillian.ExportMapRequest{MapId: e.cfg.MapID, Revision: last, PageSize: e.cfg.PageSize})
+	if err != nil {
+		return 0, fmt.Errorf("ExportMap(%d, %d): %v", e.cfg.MapID, last, err)
+	}
+	roots := make(map[int64]*types.MapRootV1)
+	counts := make(map[int64]int64)
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, fmt.Errorf("ExportMap(%d, %d): %v", e.cfg.MapID, last, err)
+		}
+		for _, smr := range resp.MapRoots {
+			var root types.MapRootV1
+			if err := root.UnmarshalBinary(smr.GetMapRoot()); err != nil {
+				return 0, err
+			}
+			if rev := int64(root.Revision); rev >= e.next {
+				roots[rev] = &root
+			}
+		}
+		var rows []Row
+		for _, v := range resp.LeafVersions {
+			if v.Revision < e.next {
+				continue
+			}
+			root, ok := roots[v.Revision]
+			if !ok {
+				return 0, fmt.Errorf("ExportMap(%d, %d): no root for revision %d", e.cfg.MapID, last, v.Revision)
+			}
+			rows = append(rows, e.mutationRow(v, root))
+			counts[v.Revision]++
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		if err := e.cfg.Sink.Insert(ctx, e.mutations, rows); err != nil {
+			return 0, fmt.Errorf("failed to insert %d mutations: %v", len(rows), err)
+		}
+		e.rows.Add(float64(len(rows)), e.mutations.Name, e.label)
+	}
+
+	rows := make([]Row, 0, last-e.next+1)
+	for rev := e.next; rev <= last; rev++ {
+		root, ok := roots[rev]
+		if !ok {
+			return 0, fmt.Errorf("ExportMap(%d, %d): no root for revision %d", e.cfg.MapID, last, rev)
+		}
+		rows = append(rows, Row{
+			e.cfg.MapID,
+			rev,
+			time.Unix(0, int64(root.TimestampNanos)).UTC(),
+			hex.EncodeToString(root.RootHash),
+			int64(len(root.Metadata)),
+			counts[rev],
+		})
+	}
+	if err := e.cfg.Sink.Insert(ctx, e.revisions, rows); err != nil {
+		return 0, fmt.Errorf("failed to insert revisions %d to %d: %v", e.next, last, err)
+	}
+	e.rows.Add(float64(len(rows)), e.revisions.Name, e.label)
+	e.next = last + 1
+	return len(rows), nil
+}
+
+// start creates the tables of mutations and revisions, if needed, and finds
+// the first revision to export, the first time it's called.
+func (e *MapExporter) start(ctx context.Context) error {
+	if e.started {
+		return nil
+	}
+	for _, t := range []*Table{e.mutations, e.revisions} {
+		if err := e.cfg.Sink.EnsureTable(ctx, t); err != nil {
+			return fmt.Errorf("failed to create table %s: %v", t.Name, err)
+		}
+	}
+	if e.cfg.Backfill {
+		e.next = e.cfg.BackfillFrom
+	} else {
+		last, ok, err := e.cfg.Sink.LastPosition(ctx, e.revisions, e.cfg.MapID)
+		if err != nil {
+			return fmt.Errorf("failed to read the last exported revision: %v", err)
+		}
+		if ok {
+			e.next = last + 1
+		}
+	}
+	e.started = true
+	return nil
+}
+
+func (e *MapExporter) mutationRow(v *trillian.MapLeafVersion, root *types.MapRootV1) Row {
+	leaf := v.GetLeaf()
+	row := Row{
+		e.cfg.MapID,
+		v.Revision,
+		time.Unix(0, int64(root.TimestampNanos)).UTC(),
+		hex.EncodeToString(leaf.GetIndex()),
+		hex.EncodeToString(leaf.GetLeafHash()),
+		int64(len(leaf.GetLeafValue())),
+		int64(len(leaf.GetExtraData())),
+	}
+	if e.cfg.IncludeValues {
+		row = append(row, leaf.GetLeafValue(), leaf.GetExtraData())
+	}
+	return row
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/types"
+)
+
+func TestMapExporter(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, stop, err := testonly.NewMockServer(ctrl)
+	if err != nil {
+		t.Fatalf("NewMockServer(): %v", err)
+	}
+	defer stop()
+
+	root := func(rev uint64) *trillian.SignedMapRoot {
+		data, err := (&types.MapRootV1{Revision: rev, RootHash: []byte{byte(rev)}, TimestampNanos: rev * 1000}).MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary(): %v", err)
+		}
+		return &trillian.SignedMapRoot{MapRoot: data}
+	}
+	leaf := func(b byte) *trillian.MapLeaf { return &trillian.MapLeaf{Index: []byte{b}, LeafValue: []byte{b}} }
+	// expect makes the map export revisions up to last, writing leaf 1 at
+	// each of them, and leaf 2 at revision 2.
+	expect := func(last int64) {
+		s.Map.EXPECT().GetSignedMapRoot(gomock.Any(), gomock.Any()).Return(&trillian.GetSignedMapRootResponse{MapRoot: root(uint64(last))}, nil)
+		s.Map.EXPECT().ExportMap(&trillian.ExportMapRequest{MapId: 1, Revision: last}, gomock.Any()).DoAndReturn(
+			func(req *trillian.ExportMapRequest, stream trillian.TrillianMap_ExportMapServer) error {
+				resp := &trillian.ExportMapResponse{}
+				for rev := int64(0); rev <= last; rev++ {
+					resp.MapRoots = append(resp.MapRoots, root(uint64(rev)))
+				}
+				if err := stream.Send(resp); err != nil {
+					return err
+				}
+				resp = &trillian.ExportMapResponse{}
+				for rev := int64(1); rev <= last; rev++ {
+					resp.LeafVersions = append(resp.LeafVersions, &trillian.MapLeafVersion{Revision: rev, Leaf: leaf(1)})
+				}
+				if last >= 2 {
+					resp.LeafVersions = append(resp.LeafVersions, &trillian.MapLeafVersion{Revision: 2, Leaf: leaf(2)})
+				}
+				return stream.Send(resp)
+			})
+	}
+
+	sink := newMemSink()
+	e, err := NewMapExporter(MapConfig{Client: s.MapClient, MapID: 1, Sink: sink})
+	if err != nil {
+		t.Fatalf("NewMapExporter(): %v", err)
+	}
+	expect(2)
+	if n, err := e.Export(ctx); err != nil || n != 3 {
+		t.Fatalf("Export(): %d, %v, want 3", n, err)
+	}
+	expect(3)
+	if n, err := e.Export(ctx); err != nil || n != 1 {
+		t.Fatalf("Export() again: %d, %v, want 1", n, err)
+	}
+	// No new revision.
+	s.Map.EXPECT().GetSignedMapRoot(gomock.Any(), gomock.Any()).Return(&trillian.GetSignedMapRootResponse{MapRoot: root(3)}, nil)
+	if n, err := e.Export(ctx); err != nil || n != 0 {
+		t.Fatalf("Export() without new revision: %d, %v, want 0", n, err)
+	}
+
+	for _, c := range []struct {
+		table, column string
+		want          []interface{}
+	}{
+		{table: MapMutationsTableName, column: "revision", want: []interface{}{int64(1), int64(2), int64(2), int64(3)}},
+		{table: MapMutationsTableName, column: "leaf_index", want: []interface{}{"01", "01", "02", "01"}},
+		{table: MapRevisionsTableName, column: "revision", want: []interface{}{int64(0), int64(1), int64(2), int64(3)}},
+		{table: MapRevisionsTableName, column: "mutation_count", want: []interface{}{int64(0), int64(1), int64(2), int64(1)}},
+		{table: MapRevisionsTableName, column: "root_hash", want: []interface{}{"00", "01", "02", "03"}},
+	} {
+		if got := sink.column(c.table, c.column); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("exported %s.%s %v, want %v", c.table, c.column, got, c.want)
+		}
+	}
+
+	// A new exporter resumes after the last revision in the sink.
+	e, err = NewMapExporter(MapConfig{Client: s.MapClient, MapID: 1, Sink: sink})
+	if err != nil {
+		t.Fatalf("NewMapExporter(): %v", err)
+	}
+	s.Map.EXPECT().GetSignedMapRoot(gomock.Any(), gomock.Any()).Return(&trillian.GetSignedMapRootResponse{MapRoot: root(3)}, nil)
+	if n, err := e.Export(ctx); err != nil || n != 0 {
+		t.Errorf("resumed Export(): %d, %v, want 0", n, err)
+	}
+
+	// A backfill exports the revisions again.
+	backfill := newMemSink()
+	e, err = NewMapExporter(MapConfig{Client: s.MapClient, MapID: 1, Sink: backfill, Backfill: true, BackfillFrom: 2, IncludeValues: true})
+	if err != nil {
+		t.Fatalf("NewMapExporter(): %v", err)
+	}
+	expect(3)
+	if n, err := e.Export(ctx); err != nil || n != 2 {
+		t.Errorf("backfill Export(): %d, %v, want 2", n, err)
+	}
+	want := []interface{}{[]byte{1}, []byte{1}, []byte{2}}
+	if got := backfill.column(MapMutationsTableName, "leaf_value"); !reflect.DeepEqual(got, want) {
+		t.Errorf("backfilled leaf values %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/client/backoff"
+)
+
+// DefaultLeafPageSize is the number of leaves a LeafIterator requests at a
+// time if its PageSize is zero.
+const DefaultLeafPageSize = 1000
+
+// LeafIterator reads a range of the leaves of a log, one page at a time. All
+// pages are read at the same tree size, so leaves sequenced meanwhile never
+// tear the range. It is not safe for concurrent use.
+type LeafIterator struct {
+	// PageSize is the number of leaves requested at a time. If zero,
+	// DefaultLeafPageSize is used.
+	PageSize int64
+
+	c        *LogClient
+	treeSize int64
+	next     int64
+	end      int64
+	page     []*trillian.LogLeaf
+	backoff  backoff.Backoff
+}
+
+// ListLeaves returns an iterator over up to count leaves from index start,
+// read at the size of the trusted root. Leaves beyond the trusted root are not
+// returned, so call UpdateRoot first to read the latest leaves.
+func (c *LogClient) ListLeaves(start, count int64) *LeafIterator {
+	treeSize := int64(c.GetRoot().TreeSize)
+	end := start + count
+	if end > treeSize || end < start {
+		end = treeSize
+	}
+	return &LeafIterator{
+		c:        c,
+		treeSize: treeSize,
+		next:     start,
+		end:      end,
+		backoff: backoff.Backoff{
+			Min:    100 * time.Millisecond,
+			Max:    10 * time.Second,
+			Factor: 2,
+			Jitter: true,
+		},
+	}
+}
+
+// TreeSize returns the tree size the leaves are read at.
+func (it *LeafIterator) TreeSize() int64 {
+	return it.treeSize
+}
+
+// Next returns the next leaf of the range, or io.EOF once there are no more.
+// Pages cut short by the server are continued, and pages which the server
+// cannot serve yet, e.g. because it lags behind the trusted root, are retried
+// until ctx is done.
+func (it *LeafIterator) Next(ctx context.Context) (*trillian.LogLeaf, error) {
+	if len(it.page) == 0 {
+		if it.next >= it.end {
+			return nil, io.EOF
+		}
+		if err := it.backoff.Retry(ctx, func() error { return it.fetch(ctx) }); err != nil {
+			return nil, err
+		}
+		it.backoff.Reset()
+	}
+	leaf := it.page[0]
+	it.page = it.page[1:]
+	return leaf, nil
+}
+
+// fetch reads the next page of leaves.
+func (it *LeafIterator) fetch(ctx context.Context) error {
+	count := it.PageSize
+	if count <= 0 {
+		count = DefaultLeafPageSize
+	}
+	if count > it.end-it.next {
+		count = it.end - it.next
+	}
+	resp, err := it.c.client.GetLeavesByRange(ctx, &trillian.GetLeavesByRangeRequest{
+		LogId:      it.c.LogID,
+		StartIndex: it.next,
+		Count:      count,
+		TreeSize:   it.treeSize,
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Leaves) == 0 {
+		return backoff.RetriableErrorf("no leaves from index %d at tree size %d yet", it.next, it.treeSize)
+	}
+	if resp.TreeSize != 0 && resp.TreeSize != it.treeSize {
+		return fmt.Errorf("leaves read at tree size %d, want %d", resp.TreeSize, it.treeSize)
+	}
+	if int64(len(resp.Leaves)) > count {
+		return fmt.Errorf("len(Leaves)=%d, want <= %d", len(resp.Leaves), count)
+	}
+	for i, l := range resp.Leaves {
+		if want := it.next + int64(i); l.LeafIndex != want {
+			return fmt.Errorf("Leaves[%d].LeafIndex=%d, want %d", i, l.LeafIndex, want)
+		}
+	}
+	it.page = resp.Leaves
+	it.next += int64(len(resp.Leaves))
+	return nil
+}
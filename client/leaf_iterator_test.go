@@ -0,0 +1,130 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc"
+)
+
+// fakeRangeLogClient serves GetLeavesByRange from a log which grows by a leaf
+// on every request, returning at most maxPage leaves at a time.
+type fakeRangeLogClient struct {
+	trillian.TrillianLogClient
+	size    int64
+	maxPage int64
+	// lagging is the number of requests answered with no leaves, as by a
+	// server behind the pinned tree size.
+	lagging int
+	// indexOffset corrupts the returned leaf indices.
+	indexOffset int64
+	reqs        []*trillian.GetLeavesByRangeRequest
+}
+
+func (f *fakeRangeLogClient) GetLeavesByRange(ctx context.Context, req *trillian.GetLeavesByRangeRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByRangeResponse, error) {
+	f.reqs = append(f.reqs, req)
+	f.size++
+	if f.lagging > 0 {
+		f.lagging--
+		return &trillian.GetLeavesByRangeResponse{}, nil
+	}
+	end := req.TreeSize
+	if end == 0 {
+		end = f.size
+	}
+	end = min64(end, req.StartIndex+min64(req.Count, f.maxPage))
+	resp := &trillian.GetLeavesByRangeResponse{TreeSize: req.TreeSize}
+	for i := req.StartIndex; i < end; i++ {
+		resp.Leaves = append(resp.Leaves, &trillian.LogLeaf{LeafIndex: i + f.indexOffset})
+	}
+	return resp, nil
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func TestLeafIterator(t *testing.T) {
+	for _, tc := range []struct {
+		desc         string
+		start, count int64
+		pageSize     int64
+		lagging      int
+		indexOffset  int64
+		wantIndices  []int64
+		wantReqs     int
+		wantErr      string
+	}{
+		{desc: "all", start: 0, count: 10, pageSize: 2, wantIndices: []int64{0, 1, 2, 3, 4, 5}, wantReqs: 3},
+		{desc: "short-pages", start: 1, count: 4, pageSize: 4, wantIndices: []int64{1, 2, 3, 4}, wantReqs: 2},
+		{desc: "lagging", start: 4, count: 10, lagging: 2, wantIndices: []int64{4, 5}, wantReqs: 3},
+		{desc: "beyond-root", start: 6, count: 1, wantReqs: 0},
+		{desc: "bad-index", start: 0, count: 1, indexOffset: 1, wantErr: "LeafIndex=1, want 0"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			f := &fakeRangeLogClient{size: 6, maxPage: 3, lagging: tc.lagging, indexOffset: tc.indexOffset}
+			c := New(1, f, nil, types.LogRootV1{TreeSize: 6})
+			it := c.ListLeaves(tc.start, tc.count)
+			it.PageSize = tc.pageSize
+			it.backoff.Min, it.backoff.Max = time.Millisecond, time.Millisecond
+
+			var got []int64
+			for {
+				leaf, err := it.Next(ctx)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if tc.wantErr == "" || !strings.Contains(err.Error(), tc.wantErr) {
+						t.Fatalf("Next(): %v, want err containing %q", err, tc.wantErr)
+					}
+					return
+				}
+				got = append(got, leaf.LeafIndex)
+			}
+			if tc.wantErr != "" {
+				t.Fatalf("Next(): EOF, want err containing %q", tc.wantErr)
+			}
+			if len(got) != len(tc.wantIndices) {
+				t.Fatalf("leaf indices: %v, want %v", got, tc.wantIndices)
+			}
+			for i := range got {
+				if got[i] != tc.wantIndices[i] {
+					t.Fatalf("leaf indices: %v, want %v", got, tc.wantIndices)
+				}
+			}
+			if got, want := len(f.reqs), tc.wantReqs; got != want {
+				t.Errorf("GetLeavesByRange() calls: %d, want %d", got, want)
+			}
+			for _, req := range f.reqs {
+				if got, want := req.TreeSize, it.TreeSize(); got != want {
+					t.Errorf("GetLeavesByRange().TreeSize: %d, want %d", got, want)
+				}
+			}
+		})
+	}
+}
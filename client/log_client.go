@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -39,6 +40,11 @@ type LogClient struct {
 	root          types.LogRootV1
 	rootLock      sync.Mutex
 	updateLock    sync.Mutex
+
+	// Cache, if set, holds the inclusion proofs verified against roots of
+	// the log, and serves repeated verifications without an RPC. Proofs
+	// against roots smaller than the trusted root are evicted when it grows.
+	Cache *ProofCache
 }
 
 // New returns a new LogClient.
@@ -242,6 +248,9 @@ func (c *LogClient) UpdateRoot(ctx context.Context) (*types.LogRootV1, error) {
 
 		// Take a copy of the new trusted root in order to prevent clients from modifying it.
 		c.root = *newTrusted
+		if newTrusted.TreeSize > currentlyTrusted.TreeSize {
+			c.Cache.Invalidate(c.LogID, int64(newTrusted.TreeSize))
+		}
 
 		return newTrusted, nil
 	}
@@ -309,6 +318,15 @@ func (c *LogClient) VerifyInclusion(ctx context.Context, data []byte) error {
 
 // GetAndVerifyInclusionAtIndex ensures that the given leaf data has been included in the log at a particular index.
 func (c *LogClient) GetAndVerifyInclusionAtIndex(ctx context.Context, data []byte, index int64, sth *types.LogRootV1) error {
+	key := proofCacheKey{
+		treeID:   c.LogID,
+		revision: int64(sth.TreeSize),
+		kind:     logInclusionAtKind,
+		index:    strconv.FormatInt(index, 10) + "/" + string(c.BuildLeaf(data).MerkleLeafHash),
+	}
+	if cached, ok := c.Cache.get(key); ok && bytes.Equal(cached.([]byte), sth.RootHash) {
+		return nil
+	}
 	resp, err := c.client.GetInclusionProof(ctx,
 		&trillian.GetInclusionProofRequest{
 			LogId:     c.LogID,
@@ -329,10 +347,18 @@ func (c *LogClient) GetAndVerifyInclusionAtIndex(ctx context.Context, data []byt
 		return fmt.Errorf("response for InclusionProof has a smaller (%d) root than requested (%d)", proofRoot.TreeSize, sth.TreeSize)
 	}
 
-	return c.VerifyInclusionAtIndex(sth, data, index, resp.Proof.Hashes)
+	if err := c.VerifyInclusionAtIndex(sth, data, index, resp.Proof.Hashes); err != nil {
+		return err
+	}
+	c.Cache.put(key, append([]byte(nil), sth.RootHash...))
+	return nil
 }
 
 func (c *LogClient) getAndVerifyInclusionProof(ctx context.Context, leafHash []byte, sth *types.LogRootV1) (bool, error) {
+	key := proofCacheKey{treeID: c.LogID, revision: int64(sth.TreeSize), kind: logInclusionKind, index: string(leafHash)}
+	if cached, ok := c.Cache.get(key); ok && bytes.Equal(cached.([]byte), sth.RootHash) {
+		return true, nil
+	}
 	resp, err := c.client.GetInclusionProofByHash(ctx,
 		&trillian.GetInclusionProofByHashRequest{
 			LogId:    c.LogID,
@@ -350,6 +376,7 @@ func (c *LogClient) getAndVerifyInclusionProof(ctx context.Context, leafHash []b
 			return false, fmt.Errorf("VerifyInclusionByHash(): %v", err)
 		}
 	}
+	c.Cache.put(key, append([]byte(nil), sth.RootHash...))
 	return true, nil
 }
 
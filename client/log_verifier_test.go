@@ -16,10 +16,14 @@ package client
 
 import (
 	"crypto"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto/keys/pem"
+	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/merkle/rfc6962"
 	"github.com/google/trillian/testonly"
 	"github.com/google/trillian/types"
@@ -90,3 +94,162 @@ func TestVerifyInclusionByHashErrors(t *testing.T) {
 		}
 	}
 }
+
+// rootChain returns a trusted root of size sizes[0], and a chain of signed
+// roots of the remaining sizes, with consistency proofs.
+func rootChain(t testing.TB, sizes ...int64) (*types.LogRootV1, []ChainedRoot) {
+	t.Helper()
+	key, err := pem.UnmarshalPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("Failed to open test key, err=%v", err)
+	}
+	signer := tcrypto.NewSigner(0, key, crypto.SHA256)
+
+	mt := merkle.NewInMemoryMerkleTree(rfc6962.DefaultHasher)
+	for i := int64(0); i < sizes[len(sizes)-1]; i++ {
+		mt.AddLeaf([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+	rootAt := func(size int64) *types.LogRootV1 {
+		return &types.LogRootV1{TreeSize: uint64(size), RootHash: mt.RootAtSnapshot(size).Hash()}
+	}
+
+	trusted := rootAt(sizes[0])
+	var chain []ChainedRoot
+	for i, size := range sizes[1:] {
+		slr, err := signer.SignLogRoot(rootAt(size))
+		if err != nil {
+			t.Fatalf("SignLogRoot(): %v", err)
+		}
+		var proof [][]byte
+		if prev := sizes[i]; prev != 0 && prev != size {
+			for _, node := range mt.SnapshotConsistency(prev, size) {
+				proof = append(proof, node.Value.Hash())
+			}
+		}
+		chain = append(chain, ChainedRoot{Root: slr, Consistency: proof})
+	}
+	return trusted, chain
+}
+
+func TestVerifyRootChain(t *testing.T) {
+	pk, err := pem.UnmarshalPublicKey(testonly.DemoPublicKey)
+	if err != nil {
+		t.Fatalf("Failed to load public key, err=%v", err)
+	}
+	logVerifier := NewLogVerifier(rfc6962.DefaultHasher, pk, crypto.SHA256)
+
+	for _, test := range []struct {
+		desc    string
+		sizes   []int64
+		modify  func([]ChainedRoot)
+		wantErr string
+	}{
+		{desc: "empty", sizes: []int64{5}},
+		{desc: "fromZero", sizes: []int64{0, 1, 3, 3, 7, 12, 12, 20}},
+		{desc: "fromTrusted", sizes: []int64{4, 4, 9, 16, 17}},
+		{
+			desc:    "nilRoot",
+			sizes:   []int64{0, 1, 3},
+			modify:  func(c []ChainedRoot) { c[1].Root = nil },
+			wantErr: "root 1 is nil",
+		},
+		{
+			desc:  "badSignature",
+			sizes: []int64{0, 1, 3, 7, 12},
+			modify: func(c []ChainedRoot) {
+				sig := append([]byte(nil), c[2].Root.LogRootSignature...)
+				sig[len(sig)-1] ^= 1
+				c[2].Root.LogRootSignature = sig
+			},
+			wantErr: "root 2",
+		},
+		{
+			desc:  "badRepeatedSignature",
+			sizes: []int64{0, 1, 3, 3, 3},
+			modify: func(c []ChainedRoot) {
+				sig := append([]byte(nil), c[1].Root.LogRootSignature...)
+				sig[len(sig)-1] ^= 1
+				c[1].Root.LogRootSignature = sig
+				c[2].Root = c[1].Root
+				c[3].Root = c[1].Root
+			},
+			wantErr: "root 1",
+		},
+		{
+			desc:    "badConsistency",
+			sizes:   []int64{2, 5, 9, 14},
+			modify:  func(c []ChainedRoot) { c[2].Consistency = c[1].Consistency },
+			wantErr: "root 2: failed to verify consistency proof",
+		},
+		{
+			desc:    "shrinking",
+			sizes:   []int64{0, 5, 9, 14},
+			modify:  func(c []ChainedRoot) { c[1], c[2] = c[2], c[1] },
+			wantErr: "root 1",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			trusted, chain := rootChain(t, test.sizes...)
+			if test.modify != nil {
+				test.modify(chain)
+			}
+			for _, parallelism := range []int{0, 1, 3} {
+				roots, err := logVerifier.VerifyRootChain(trusted, chain, parallelism)
+				if test.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+						t.Errorf("VerifyRootChain(%d): %v, want error containing %q", parallelism, err, test.wantErr)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("VerifyRootChain(%d): %v", parallelism, err)
+				}
+
+				// The result must match verifying each root in turn.
+				want := make([]*types.LogRootV1, 0, len(chain))
+				prev := trusted
+				for i, cr := range chain {
+					r, err := logVerifier.VerifyRoot(prev, cr.Root, cr.Consistency)
+					if err != nil {
+						t.Fatalf("VerifyRoot(%d): %v", i, err)
+					}
+					want = append(want, r)
+					prev = r
+				}
+				if !reflect.DeepEqual(roots, want) {
+					t.Errorf("VerifyRootChain(%d)=%v, want %v", parallelism, roots, want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyRootChainTrustedNil(t *testing.T) {
+	logVerifier := NewLogVerifier(rfc6962.DefaultHasher, nil, crypto.SHA256)
+	if _, err := logVerifier.VerifyRootChain(nil, nil, 0); err == nil {
+		t.Error("VerifyRootChain() error expected, but got nil")
+	}
+}
+
+func BenchmarkVerifyRootChain(b *testing.B) {
+	pk, err := pem.UnmarshalPublicKey(testonly.DemoPublicKey)
+	if err != nil {
+		b.Fatalf("Failed to load public key, err=%v", err)
+	}
+	logVerifier := NewLogVerifier(rfc6962.DefaultHasher, pk, crypto.SHA256)
+	sizes := make([]int64, 257)
+	for i := range sizes {
+		sizes[i] = int64(i) * 3
+	}
+	trusted, chain := rootChain(b, sizes...)
+
+	for _, parallelism := range []int{1, 0} {
+		b.Run(fmt.Sprintf("parallelism=%d", parallelism), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := logVerifier.VerifyRootChain(trusted, chain, parallelism); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
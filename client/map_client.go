@@ -15,9 +15,12 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"math/rand"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
 	"github.com/google/trillian/types"
 	"google.golang.org/grpc/status"
@@ -28,6 +31,12 @@ type MapClient struct {
 	*MapVerifier
 	MapID int64
 	Conn  trillian.TrillianMapClient
+	// MaxResponseBytes, if positive, bounds the size of each GetLeaves
+	// response, so that large batches are fetched in several pages.
+	MaxResponseBytes int32
+	// Cache, if set, holds the roots and leaves verified at given revisions,
+	// and serves repeated requests for them without an RPC.
+	Cache *ProofCache
 }
 
 // NewMapClientFromTree returns a verifying Map client for the specified tree.
@@ -55,6 +64,11 @@ func (c *MapClient) GetAndVerifyLatestMapRoot(ctx context.Context) (*types.MapRo
 
 // GetAndVerifyMapRootByRevision verifies and returns the map root with the given revision.
 func (c *MapClient) GetAndVerifyMapRootByRevision(ctx context.Context, revision int64) (*types.MapRootV1, error) {
+	key := proofCacheKey{treeID: c.MapID, revision: revision, kind: mapRootKind}
+	if cached, ok := c.Cache.get(key); ok {
+		root := *cached.(*types.MapRootV1)
+		return &root, nil
+	}
 	rootResp, err := c.Conn.GetSignedMapRootByRevision(ctx, &trillian.GetSignedMapRootByRevisionRequest{MapId: c.MapID, Revision: revision})
 	if err != nil {
 		s := status.Convert(err)
@@ -67,26 +81,118 @@ func (c *MapClient) GetAndVerifyMapRootByRevision(ctx context.Context, revision
 	if int64(root.Revision) != revision {
 		return nil, fmt.Errorf("GetAndVerifyMapRootByRevision(%v, %d): got revision %d", c.MapID, revision, root.Revision)
 	}
-	return root, err
+	cached := *root
+	c.Cache.put(key, &cached)
+	return root, nil
 }
 
 // GetAndVerifyMapLeaves verifies and returns the requested map leaves.
-// indexes may not contain duplicates.
+// indexes may not contain duplicates. If the server splits the response into
+// pages, all of them are fetched, and verified to be at the same revision.
 func (c *MapClient) GetAndVerifyMapLeaves(ctx context.Context, indexes [][]byte) ([]*trillian.MapLeaf, error) {
-	getResp, err := c.Conn.GetLeaves(ctx, &trillian.GetMapLeavesRequest{
-		MapId: c.MapID,
-		Index: indexes,
+	req := &trillian.GetMapLeavesRequest{
+		MapId:            c.MapID,
+		Index:            indexes,
+		MaxResponseBytes: c.MaxResponseBytes,
+	}
+	revision := int64(-1)
+	var leaves []*trillian.MapLeaf
+	for {
+		getResp, err := c.Conn.GetLeaves(ctx, req)
+		if err != nil {
+			s := status.Convert(err)
+			return nil, status.Errorf(s.Code(), "map.GetLeaves(): %v", s.Message())
+		}
+		pageIndexes := indexes[len(leaves):]
+		if getResp.NextPageToken != "" {
+			n := len(getResp.MapLeafInclusion)
+			if n == 0 || n >= len(pageIndexes) {
+				return nil, fmt.Errorf("map.GetLeaves(): got %d leaves with a next page, want 1 to %d", n, len(pageIndexes)-1)
+			}
+			pageIndexes = pageIndexes[:n]
+		}
+		if revision == -1 && getResp.NextPageToken != "" {
+			root, err := c.VerifySignedMapRoot(getResp.GetMapRoot())
+			if err != nil {
+				return nil, err
+			}
+			revision = int64(root.Revision)
+		}
+		page, err := c.VerifyMapLeavesResponse(pageIndexes, revision, getResp)
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, page...)
+		if getResp.NextPageToken == "" {
+			return leaves, nil
+		}
+		req.PageToken = getResp.NextPageToken
+	}
+}
+
+// GetAndVerifyMapLeavesByRevision verifies and returns the requested map leaves at a specific revision.
+// indexes may not contain duplicates. Leaves held by c.Cache are not fetched again.
+func (c *MapClient) GetAndVerifyMapLeavesByRevision(ctx context.Context, revision int64, indexes [][]byte) ([]*trillian.MapLeaf, error) {
+	leaves := make([]*trillian.MapLeaf, len(indexes))
+	var missing [][]byte
+	for i, index := range indexes {
+		if cached, ok := c.Cache.get(proofCacheKey{treeID: c.MapID, revision: revision, kind: mapLeafKind, index: string(index)}); ok {
+			leaves[i] = proto.Clone(cached.(*trillian.MapLeaf)).(*trillian.MapLeaf)
+			continue
+		}
+		missing = append(missing, index)
+	}
+	if len(missing) == 0 {
+		return leaves, nil
+	}
+
+	getResp, err := c.Conn.GetLeavesByRevision(ctx, &trillian.GetMapLeavesByRevisionRequest{
+		MapId:    c.MapID,
+		Index:    missing,
+		Revision: revision,
 	})
 	if err != nil {
 		s := status.Convert(err)
 		return nil, status.Errorf(s.Code(), "map.GetLeaves(): %v", s.Message())
 	}
-	return c.VerifyMapLeavesResponse(indexes, -1, getResp)
+	fetched, err := c.VerifyMapLeavesResponse(missing, revision, getResp)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range fetched {
+		c.Cache.put(proofCacheKey{treeID: c.MapID, revision: revision, kind: mapLeafKind, index: string(l.GetIndex())}, proto.Clone(l))
+	}
+	// Fill in the leaves which were not cached, in order.
+	for i := range leaves {
+		if leaves[i] == nil {
+			leaves[i], fetched = fetched[0], fetched[1:]
+		}
+	}
+	return leaves, nil
 }
 
-// GetAndVerifyMapLeavesByRevision verifies and returns the requested map leaves at a specific revision.
-// indexes may not contain duplicates.
-func (c *MapClient) GetAndVerifyMapLeavesByRevision(ctx context.Context, revision int64, indexes [][]byte) ([]*trillian.MapLeaf, error) {
+// SpotCheckMapLeaves verifies that a random sample of up to n of leaves, as
+// returned by the GetLeavesByRevision method of the map write API without
+// proofs, are included in root, which was returned alongside them.
+func (c *MapClient) SpotCheckMapLeaves(ctx context.Context, root *trillian.SignedMapRoot, leaves []*trillian.MapLeaf, n int) error {
+	mapRoot, err := c.VerifySignedMapRoot(root)
+	if err != nil {
+		return err
+	}
+	if n > len(leaves) {
+		n = len(leaves)
+	}
+	sample := make([]*trillian.MapLeaf, 0, n)
+	indexes := make([][]byte, 0, n)
+	for _, i := range rand.Perm(len(leaves))[:n] {
+		sample = append(sample, leaves[i])
+		indexes = append(indexes, leaves[i].Index)
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	revision := int64(mapRoot.Revision)
 	getResp, err := c.Conn.GetLeavesByRevision(ctx, &trillian.GetMapLeavesByRevisionRequest{
 		MapId:    c.MapID,
 		Index:    indexes,
@@ -94,9 +200,109 @@ func (c *MapClient) GetAndVerifyMapLeavesByRevision(ctx context.Context, revisio
 	})
 	if err != nil {
 		s := status.Convert(err)
-		return nil, status.Errorf(s.Code(), "map.GetLeaves(): %v", s.Message())
+		return status.Errorf(s.Code(), "map.GetLeavesByRevision(): %v", s.Message())
+	}
+	// The proofs must lead to the root of the export, not just to a root of
+	// the same revision.
+	if got, want := getResp.GetMapRoot().GetMapRoot(), root.MapRoot; !bytes.Equal(got, want) {
+		return fmt.Errorf("revision %d: got map root %x, want %x", revision, got, want)
+	}
+	verified, err := c.VerifyMapLeavesResponse(indexes, revision, getResp)
+	if err != nil {
+		return err
+	}
+	for i, l := range sample {
+		if got := verified[i].GetIndex(); !bytes.Equal(got, l.Index) {
+			return fmt.Errorf("revision %d: got leaf index %x, want %x", revision, got, l.Index)
+		}
+		if got, want := verified[i].LeafValue, l.LeafValue; !bytes.Equal(got, want) {
+			return fmt.Errorf("revision %d: got value %x at index %x, want %x", revision, got, l.Index, want)
+		}
+	}
+	return nil
+}
+
+// GetAndVerifyMapLeafHistory verifies and returns the values of the leaf at
+// index at up to count consecutive revisions, starting at start. The i-th
+// returned leaf is the value at revision start+i.
+func (c *MapClient) GetAndVerifyMapLeafHistory(ctx context.Context, index []byte, start int64, count int32) ([]*trillian.MapLeaf, error) {
+	getResp, err := c.Conn.GetLeafHistory(ctx, &trillian.GetMapLeafHistoryRequest{
+		MapId:         c.MapID,
+		Index:         index,
+		StartRevision: start,
+		Count:         count,
+	})
+	if err != nil {
+		s := status.Convert(err)
+		return nil, status.Errorf(s.Code(), "map.GetLeafHistory(): %v", s.Message())
+	}
+	if got, want := len(getResp.Leaves), int(count); count > 0 && got > want {
+		return nil, fmt.Errorf("map.GetLeafHistory(): got %d revisions, want at most %d", got, want)
+	}
+
+	leaves := make([]*trillian.MapLeaf, 0, len(getResp.Leaves))
+	for i, l := range getResp.Leaves {
+		rev := start + int64(i)
+		verified, err := c.VerifyMapLeavesResponse([][]byte{index}, rev, &trillian.GetMapLeavesResponse{
+			MapLeafInclusion: []*trillian.MapLeafInclusion{l.MapLeafInclusion},
+			MapRoot:          l.MapRoot,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("revision %d: %v", rev, err)
+		}
+		if got := verified[0].GetIndex(); !bytes.Equal(got, index) {
+			return nil, fmt.Errorf("revision %d: got leaf index %x, want %x", rev, got, index)
+		}
+		leaves = append(leaves, verified[0])
+	}
+	return leaves, nil
+}
+
+// GetAndVerifyConsistencyProof verifies that revision second of the map was
+// derived from revision first by setting the leaves in delta, and no others.
+// It returns the leaves at the indexes of delta at revision first.
+func (c *MapClient) GetAndVerifyConsistencyProof(ctx context.Context, first, second int64, delta []*trillian.MapLeaf) ([]*trillian.MapLeaf, error) {
+	indexes := make([][]byte, 0, len(delta))
+	for _, l := range delta {
+		indexes = append(indexes, l.Index)
+	}
+	getResp, err := c.Conn.GetConsistencyProof(ctx, &trillian.GetMapConsistencyProofRequest{
+		MapId:          c.MapID,
+		FirstRevision:  first,
+		SecondRevision: second,
+		Index:          indexes,
+	})
+	if err != nil {
+		s := status.Convert(err)
+		return nil, status.Errorf(s.Code(), "map.GetConsistencyProof(): %v", s.Message())
+	}
+
+	before, after, err := c.VerifyConsistencyProofResponse(indexes, first, second, getResp)
+	if err != nil {
+		return nil, err
+	}
+	for i, l := range delta {
+		if got, want := after[i].LeafValue, l.LeafValue; !bytes.Equal(got, want) {
+			return nil, fmt.Errorf("revision %d: got value %x at index %x, want %x", second, got, l.Index, want)
+		}
+	}
+	return before, nil
+}
+
+// GetAndVerifyMapLeavesByIndexPrefix returns all the leaves with values whose
+// indexes start with prefix at revision, in ascending index order, and
+// verifies that there are no others.
+func (c *MapClient) GetAndVerifyMapLeavesByIndexPrefix(ctx context.Context, prefix []byte, revision int64) ([]*trillian.MapLeaf, error) {
+	getResp, err := c.Conn.GetLeavesByIndexPrefix(ctx, &trillian.GetMapLeavesByIndexPrefixRequest{
+		MapId:       c.MapID,
+		IndexPrefix: prefix,
+		Revision:    revision,
+	})
+	if err != nil {
+		s := status.Convert(err)
+		return nil, status.Errorf(s.Code(), "map.GetLeavesByIndexPrefix(): %v", s.Message())
 	}
-	return c.VerifyMapLeavesResponse(indexes, revision, getResp)
+	return c.VerifyIndexPrefixResponse(prefix, revision, getResp)
 }
 
 // SetAndVerifyMapLeaves calls SetLeaves and verifies the signature of the returned map root.
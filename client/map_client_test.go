@@ -17,6 +17,7 @@ package client
 import (
 	"bytes"
 	"context"
+	"reflect"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
@@ -24,6 +25,7 @@ import (
 	"github.com/google/trillian/storage/testdb"
 	"github.com/google/trillian/storage/testonly"
 	"github.com/google/trillian/testonly/integration"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -178,3 +180,406 @@ func TestGetLeavesAtRevision(t *testing.T) {
 		})
 	}
 }
+
+// countingMapClient counts the indexes requested from GetLeavesByRevision,
+// and the calls to GetSignedMapRootByRevision.
+type countingMapClient struct {
+	trillian.TrillianMapClient
+	indexes int
+	roots   int
+}
+
+func (c *countingMapClient) GetLeavesByRevision(ctx context.Context, req *trillian.GetMapLeavesByRevisionRequest, opts ...grpc.CallOption) (*trillian.GetMapLeavesResponse, error) {
+	c.indexes += len(req.Index)
+	return c.TrillianMapClient.GetLeavesByRevision(ctx, req, opts...)
+}
+
+func (c *countingMapClient) GetSignedMapRootByRevision(ctx context.Context, req *trillian.GetSignedMapRootByRevisionRequest, opts ...grpc.CallOption) (*trillian.GetSignedMapRootResponse, error) {
+	c.roots++
+	return c.TrillianMapClient.GetSignedMapRootByRevision(ctx, req, opts...)
+}
+
+func TestGetLeavesByRevisionCached(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+	ctx := context.Background()
+	env, err := integration.NewMapEnv(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	tree, err := CreateAndInitTree(ctx,
+		&trillian.CreateTreeRequest{Tree: testonly.MapTree},
+		env.Admin, env.Map, nil)
+	if err != nil {
+		t.Fatalf("Failed to create map: %v", err)
+	}
+
+	conn := &countingMapClient{TrillianMapClient: env.Map}
+	client, err := NewMapClientFromTree(conn, tree)
+	if err != nil {
+		t.Fatalf("NewMapClientFromTree(): %v", err)
+	}
+	client.Cache = NewProofCache(10, nil)
+
+	indexA := []byte("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+	indexB := []byte("BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB")
+	if _, err := env.Write.WriteLeaves(ctx, &trillian.WriteMapLeavesRequest{
+		MapId:  client.MapID,
+		Leaves: []*trillian.MapLeaf{{Index: indexA, LeafValue: []byte("A")}},
+	}); err != nil {
+		t.Fatalf("WriteLeaves(): %v", err)
+	}
+
+	for _, tc := range []struct {
+		desc        string
+		indexes     [][]byte
+		wantIndexes int
+	}{
+		{desc: "miss", indexes: [][]byte{indexA}, wantIndexes: 1},
+		{desc: "hit", indexes: [][]byte{indexA}, wantIndexes: 1},
+		{desc: "partial", indexes: [][]byte{indexB, indexA}, wantIndexes: 2},
+		{desc: "absent", indexes: [][]byte{indexB}, wantIndexes: 2},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			leaves, err := client.GetAndVerifyMapLeavesByRevision(ctx, 1, tc.indexes)
+			if err != nil {
+				t.Fatalf("GetAndVerifyMapLeavesByRevision(): %v", err)
+			}
+			if got, want := conn.indexes, tc.wantIndexes; got != want {
+				t.Errorf("%d indexes requested, want %d", got, want)
+			}
+			for i, l := range leaves {
+				if got, want := l.Index, tc.indexes[i]; !bytes.Equal(got, want) {
+					t.Errorf("leaves[%d].Index: %x, want %x", i, got, want)
+				}
+			}
+		})
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetAndVerifyMapRootByRevision(ctx, 1); err != nil {
+			t.Fatalf("GetAndVerifyMapRootByRevision(): %v", err)
+		}
+	}
+	if got, want := conn.roots, 1; got != want {
+		t.Errorf("GetSignedMapRootByRevision() called %d times, want %d", got, want)
+	}
+}
+
+func TestGetLeavesPaged(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+	ctx := context.Background()
+	env, err := integration.NewMapEnv(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	tree, err := CreateAndInitTree(ctx,
+		&trillian.CreateTreeRequest{Tree: testonly.MapTree},
+		env.Admin, env.Map, nil)
+	if err != nil {
+		t.Fatalf("Failed to create map: %v", err)
+	}
+
+	client, err := NewMapClientFromTree(env.Map, tree)
+	if err != nil {
+		t.Fatalf("NewMapClientFromTree(): %v", err)
+	}
+
+	var indexes [][]byte
+	var leaves []*trillian.MapLeaf
+	for i := byte(0); i < 10; i++ {
+		index := bytes.Repeat([]byte{i}, 32)
+		indexes = append(indexes, index)
+		leaves = append(leaves, &trillian.MapLeaf{Index: index, LeafValue: []byte{i}})
+	}
+	if _, err := env.Write.WriteLeaves(ctx, &trillian.WriteMapLeavesRequest{MapId: client.MapID, Leaves: leaves}); err != nil {
+		t.Fatalf("WriteLeaves(): %v", err)
+	}
+
+	// Each page holds a single inclusion proof.
+	rsp, err := env.Map.GetLeaves(ctx, &trillian.GetMapLeavesRequest{MapId: client.MapID, Index: indexes, MaxResponseBytes: 1})
+	if err != nil {
+		t.Fatalf("GetLeaves(): %v", err)
+	}
+	if got := len(rsp.MapLeafInclusion); got != 1 || rsp.NextPageToken == "" {
+		t.Errorf("GetLeaves(): got %d leaves and token %q, want 1 leaf and a token", got, rsp.NextPageToken)
+	}
+
+	client.MaxResponseBytes = 1
+	got, err := client.GetAndVerifyMapLeaves(ctx, indexes)
+	if err != nil {
+		t.Fatalf("GetAndVerifyMapLeaves(): %v", err)
+	}
+	if len(got) != len(leaves) {
+		t.Fatalf("GetAndVerifyMapLeaves(): got %d leaves, want %d", len(got), len(leaves))
+	}
+	for i, l := range got {
+		if !bytes.Equal(l.Index, leaves[i].Index) || !bytes.Equal(l.LeafValue, leaves[i].LeafValue) {
+			t.Errorf("GetAndVerifyMapLeaves()[%d]=%x:%x, want %x:%x", i, l.Index, l.LeafValue, leaves[i].Index, leaves[i].LeafValue)
+		}
+	}
+}
+
+func TestSpotCheckMapLeaves(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+	ctx := context.Background()
+	env, err := integration.NewMapEnv(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	tree, err := CreateAndInitTree(ctx,
+		&trillian.CreateTreeRequest{Tree: testonly.MapTree},
+		env.Admin, env.Map, nil)
+	if err != nil {
+		t.Fatalf("Failed to create map: %v", err)
+	}
+
+	client, err := NewMapClientFromTree(env.Map, tree)
+	if err != nil {
+		t.Fatalf("NewMapClientFromTree(): %v", err)
+	}
+
+	var indexes [][]byte
+	var leaves []*trillian.MapLeaf
+	for i := byte(0); i < 10; i++ {
+		index := bytes.Repeat([]byte{i}, 32)
+		indexes = append(indexes, index)
+		leaves = append(leaves, &trillian.MapLeaf{Index: index, LeafValue: []byte{i}})
+	}
+	writeResp, err := env.Write.WriteLeaves(ctx, &trillian.WriteMapLeavesRequest{MapId: client.MapID, Leaves: leaves})
+	if err != nil {
+		t.Fatalf("WriteLeaves(): %v", err)
+	}
+
+	export, err := env.Write.GetLeavesByRevision(ctx, &trillian.GetMapLeavesByRevisionRequest{
+		MapId:          client.MapID,
+		Index:          indexes,
+		Revision:       writeResp.Revision,
+		IncludeMapRoot: true,
+	})
+	if err != nil {
+		t.Fatalf("GetLeavesByRevision(): %v", err)
+	}
+	if export.MapRoot == nil {
+		t.Fatal("GetLeavesByRevision(): no map root")
+	}
+	if err := client.SpotCheckMapLeaves(ctx, export.MapRoot, export.Leaves, 3); err != nil {
+		t.Errorf("SpotCheckMapLeaves(): %v", err)
+	}
+
+	// Any exported leaf which does not match the root is detected.
+	export.Leaves[0].LeafValue = []byte("tampered")
+	if err := client.SpotCheckMapLeaves(ctx, export.MapRoot, export.Leaves[:1], 3); err == nil {
+		t.Error("SpotCheckMapLeaves(tampered): nil, want error")
+	}
+}
+
+func TestGetLeafHistory(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+	ctx := context.Background()
+	env, err := integration.NewMapEnv(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	tree, err := CreateAndInitTree(ctx,
+		&trillian.CreateTreeRequest{Tree: testonly.MapTree},
+		env.Admin, env.Map, nil)
+	if err != nil {
+		t.Fatalf("Failed to create map: %v", err)
+	}
+
+	client, err := NewMapClientFromTree(env.Map, tree)
+	if err != nil {
+		t.Fatalf("NewMapClientFromTree(): %v", err)
+	}
+
+	index := []byte("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+	for _, value := range []string{"A", "B"} {
+		if _, err := env.Write.WriteLeaves(ctx, &trillian.WriteMapLeavesRequest{
+			MapId:  client.MapID,
+			Leaves: []*trillian.MapLeaf{{Index: index, LeafValue: []byte(value)}},
+		}); err != nil {
+			t.Fatalf("WriteLeaves(): %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		desc  string
+		start int64
+		count int32
+		want  []string
+	}{
+		{desc: "all", start: 0, count: 10, want: []string{"", "A", "B"}},
+		{desc: "middle", start: 1, count: 1, want: []string{"A"}},
+		{desc: "future", start: 3, count: 10},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			leaves, err := client.GetAndVerifyMapLeafHistory(ctx, index, tc.start, tc.count)
+			if err != nil {
+				t.Fatalf("GetAndVerifyMapLeafHistory(): %v", err)
+			}
+			var got []string
+			for _, l := range leaves {
+				got = append(got, string(l.LeafValue))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("GetAndVerifyMapLeafHistory(): got values %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGetConsistencyProof(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+	ctx := context.Background()
+	env, err := integration.NewMapEnv(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	tree, err := CreateAndInitTree(ctx,
+		&trillian.CreateTreeRequest{Tree: testonly.MapTree},
+		env.Admin, env.Map, nil)
+	if err != nil {
+		t.Fatalf("Failed to create map: %v", err)
+	}
+
+	client, err := NewMapClientFromTree(env.Map, tree)
+	if err != nil {
+		t.Fatalf("NewMapClientFromTree(): %v", err)
+	}
+
+	indexA := []byte("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+	indexB := []byte("BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB")
+	// Revision 1 sets A, 2 sets B, and 3 sets A again.
+	for _, l := range []*trillian.MapLeaf{
+		{Index: indexA, LeafValue: []byte("A1")},
+		{Index: indexB, LeafValue: []byte("B2")},
+		{Index: indexA, LeafValue: []byte("A3")},
+	} {
+		if _, err := env.Write.WriteLeaves(ctx, &trillian.WriteMapLeavesRequest{
+			MapId:  client.MapID,
+			Leaves: []*trillian.MapLeaf{l},
+		}); err != nil {
+			t.Fatalf("WriteLeaves(): %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		desc          string
+		first, second int64
+		delta         []*trillian.MapLeaf
+		want          []string
+		wantErr       bool
+	}{
+		{desc: "unchanged", first: 2, second: 2},
+		{
+			desc:  "one revision",
+			first: 2, second: 3,
+			delta: []*trillian.MapLeaf{{Index: indexA, LeafValue: []byte("A3")}},
+			want:  []string{"A1"},
+		},
+		{
+			desc:  "all revisions",
+			first: 0, second: 3,
+			delta: []*trillian.MapLeaf{{Index: indexB, LeafValue: []byte("B2")}, {Index: indexA, LeafValue: []byte("A3")}},
+			want:  []string{"", ""},
+		},
+		{
+			desc:  "missing change",
+			first: 1, second: 3,
+			delta:   []*trillian.MapLeaf{{Index: indexA, LeafValue: []byte("A3")}},
+			wantErr: true,
+		},
+		{
+			desc:  "wrong value",
+			first: 2, second: 3,
+			delta:   []*trillian.MapLeaf{{Index: indexA, LeafValue: []byte("A2")}},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			before, err := client.GetAndVerifyConsistencyProof(ctx, tc.first, tc.second, tc.delta)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("GetAndVerifyConsistencyProof(): %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var got []string
+			for _, l := range before {
+				got = append(got, string(l.LeafValue))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("GetAndVerifyConsistencyProof(): got values %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGetLeavesByIndexPrefix(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+	ctx := context.Background()
+	env, err := integration.NewMapEnv(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	tree, err := CreateAndInitTree(ctx,
+		&trillian.CreateTreeRequest{Tree: testonly.MapTree},
+		env.Admin, env.Map, nil)
+	if err != nil {
+		t.Fatalf("Failed to create map: %v", err)
+	}
+
+	client, err := NewMapClientFromTree(env.Map, tree)
+	if err != nil {
+		t.Fatalf("NewMapClientFromTree(): %v", err)
+	}
+
+	indexA1 := []byte("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA1")
+	indexA2 := []byte("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA2")
+	indexB := []byte("BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB")
+	// Revision 1 sets A1, A2 and B, and 2 clears A2.
+	for _, leaves := range [][]*trillian.MapLeaf{
+		{{Index: indexA1, LeafValue: []byte("A1")}, {Index: indexA2, LeafValue: []byte("A2")}, {Index: indexB, LeafValue: []byte("B")}},
+		{{Index: indexA2}},
+	} {
+		if _, err := env.Write.WriteLeaves(ctx, &trillian.WriteMapLeavesRequest{
+			MapId:  client.MapID,
+			Leaves: leaves,
+		}); err != nil {
+			t.Fatalf("WriteLeaves(): %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		desc     string
+		prefix   []byte
+		revision int64
+		want     []string
+	}{
+		{desc: "empty map", prefix: []byte("A"), revision: 0},
+		{desc: "all", prefix: []byte("A"), revision: 1, want: []string{"A1", "A2"}},
+		{desc: "cleared", prefix: []byte("A"), revision: 2, want: []string{"A1"}},
+		{desc: "longer prefix", prefix: indexA2[:31], revision: 1, want: []string{"A1", "A2"}},
+		{desc: "no leaves", prefix: []byte("C"), revision: 2},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			leaves, err := client.GetAndVerifyMapLeavesByIndexPrefix(ctx, tc.prefix, tc.revision)
+			if err != nil {
+				t.Fatalf("GetAndVerifyMapLeavesByIndexPrefix(): %v", err)
+			}
+			var got []string
+			for _, l := range leaves {
+				got = append(got, string(l.LeafValue))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("GetAndVerifyMapLeavesByIndexPrefix(): got values %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
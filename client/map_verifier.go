@@ -15,6 +15,7 @@
 package client
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 
@@ -57,7 +58,7 @@ func NewMapVerifier(config *trillian.Tree, rootVerifier *maps.RootVerifier) (*Ma
 		return nil, fmt.Errorf("client: NewMapVerifierFromTree(): TreeType: %v, want %v", got, want)
 	}
 
-	mapHasher, err := hashers.NewMapHasher(config.HashStrategy)
+	mapHasher, err := hashers.NewMapHasherForTree(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed creating MapHasher: %v", err)
 	}
@@ -114,3 +115,63 @@ func (m *MapVerifier) VerifyMapLeavesResponse(indexes [][]byte, revision int64,
 	}
 	return leaves, nil
 }
+
+// VerifyConsistencyProofResponse verifies the response of GetConsistencyProof
+// for indexes between revisions first and second, and returns the leaves at
+// indexes at both revisions. It proves that the map did not change between
+// the two revisions at any other index.
+func (m *MapVerifier) VerifyConsistencyProofResponse(indexes [][]byte, first, second int64, resp *trillian.GetMapConsistencyProofResponse) ([]*trillian.MapLeaf, []*trillian.MapLeaf, error) {
+	if got, want := len(resp.FirstLeaves), len(indexes); got != want {
+		return nil, nil, status.Errorf(codes.Internal, "got %v leaves at first revision, want %v", got, want)
+	}
+	if got, want := len(resp.SecondLeaves), len(indexes); got != want {
+		return nil, nil, status.Errorf(codes.Internal, "got %v leaves at second revision, want %v", got, want)
+	}
+	for i, index := range indexes {
+		if got := resp.FirstLeaves[i].GetIndex(); !bytes.Equal(got, index) {
+			return nil, nil, status.Errorf(codes.Internal, "got leaf index %x at first revision, want %x", got, index)
+		}
+	}
+	firstRoot, err := m.VerifySignedMapRoot(resp.GetFirstMapRoot())
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "VerifySignedMapRoot(%v): %v", m.MapID, err)
+	}
+	secondRoot, err := m.VerifySignedMapRoot(resp.GetSecondMapRoot())
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "VerifySignedMapRoot(%v): %v", m.MapID, err)
+	}
+	if got, want := int64(firstRoot.Revision), first; got != want {
+		return nil, nil, status.Errorf(codes.Internal, "got first map revision %v, want %v", got, want)
+	}
+	if got, want := int64(secondRoot.Revision), second; got != want {
+		return nil, nil, status.Errorf(codes.Internal, "got second map revision %v, want %v", got, want)
+	}
+
+	if err := merkle.VerifyMapConsistencyProof(m.MapID, resp.FirstLeaves, resp.SecondLeaves, firstRoot.RootHash, secondRoot.RootHash, resp.Proof, m.Hasher); err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "map: VerifyMapConsistencyProof(): %v", err)
+	}
+	return resp.FirstLeaves, resp.SecondLeaves, nil
+}
+
+// VerifyIndexPrefixResponse verifies the response of GetLeavesByIndexPrefix
+// for prefix at revision, and returns the leaves with non-empty values. It
+// proves that no other leaf under prefix has a value at revision.
+func (m *MapVerifier) VerifyIndexPrefixResponse(prefix []byte, revision int64, resp *trillian.GetMapLeavesByIndexPrefixResponse) ([]*trillian.MapLeaf, error) {
+	mapRoot, err := m.VerifySignedMapRoot(resp.GetMapRoot())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "VerifySignedMapRoot(%v): %v", m.MapID, err)
+	}
+	if got, want := int64(mapRoot.Revision), revision; got != want {
+		return nil, status.Errorf(codes.Internal, "got map revision %v, want %v", got, want)
+	}
+	if err := merkle.VerifyMapPrefixProof(m.MapID, prefix, resp.Leaves, mapRoot.RootHash, resp.Proof, m.Hasher); err != nil {
+		return nil, status.Errorf(codes.Internal, "map: VerifyMapPrefixProof(): %v", err)
+	}
+	var leaves []*trillian.MapLeaf
+	for _, l := range resp.Leaves {
+		if len(l.LeafValue) > 0 {
+			leaves = append(leaves, l)
+		}
+	}
+	return leaves, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+
+	"github.com/google/trillian/monitoring"
+)
+
+// proofKind is the kind of verified result held by a ProofCache entry.
+type proofKind int
+
+const (
+	mapRootKind        proofKind = iota // *types.MapRootV1 at a revision.
+	mapLeafKind                         // *trillian.MapLeaf at a revision.
+	logInclusionKind                    // Root hash a leaf hash was proven under.
+	logInclusionAtKind                  // Root hash a leaf was proven under at an index.
+)
+
+// Values of the reason label of the eviction metric.
+const (
+	sizeEvictionReason        = "size"
+	invalidatedEvictionReason = "invalidated"
+)
+
+// proofCacheKey identifies a verified result. revision is the revision of a
+// map, or the size of a log.
+type proofCacheKey struct {
+	treeID   int64
+	revision int64
+	kind     proofKind
+	index    string
+}
+
+type proofCacheEntry struct {
+	key   proofCacheKey
+	value interface{}
+}
+
+// ProofCache is an LRU cache of the roots and proofs verified by MapClient
+// and LogClient, which lets applications that repeatedly verify the same
+// entries skip the RPCs. Entries are keyed by tree, revision or tree size,
+// and index, as what was verified at a revision never changes. A ProofCache
+// may be shared by several clients, and is safe for concurrent use. A nil
+// *ProofCache caches nothing.
+type ProofCache struct {
+	size            int
+	hitCounter      monitoring.Counter
+	missCounter     monitoring.Counter
+	evictionCounter monitoring.Counter
+
+	mu      sync.Mutex
+	lru     *list.List // Of *proofCacheEntry, most recently used first.
+	entries map[proofCacheKey]*list.Element
+}
+
+// NewProofCache returns a cache of up to size verified roots and proofs. mf
+// may be nil.
+func NewProofCache(size int, mf monitoring.MetricFactory) *ProofCache {
+	if mf == nil {
+		mf = monitoring.InertMetricFactory{}
+	}
+	return &ProofCache{
+		size: size,
+		hitCounter: mf.NewCounter(
+			"client_proof_cache_hits",
+			"Number of verified roots and proofs served from the client proof cache",
+			"tree_id",
+		),
+		missCounter: mf.NewCounter(
+			"client_proof_cache_misses",
+			"Number of roots and proofs not found in the client proof cache",
+			"tree_id",
+		),
+		evictionCounter: mf.NewCounter(
+			"client_proof_cache_evictions",
+			"Number of entries evicted from the client proof cache",
+			"tree_id", "reason",
+		),
+		lru:     list.New(),
+		entries: make(map[proofCacheKey]*list.Element),
+	}
+}
+
+// get returns the value cached for key, if any.
+func (c *ProofCache) get(key proofCacheKey) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	label := strconv.FormatInt(key.treeID, 10)
+	elem, ok := c.entries[key]
+	if !ok {
+		c.missCounter.Inc(label)
+		return nil, false
+	}
+	c.hitCounter.Inc(label)
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*proofCacheEntry).value, true
+}
+
+// put caches value for key, evicting the least recently used entries if the
+// cache is full. The value must not be modified afterwards.
+func (c *ProofCache) put(key proofCacheKey, value interface{}) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*proofCacheEntry).value = value
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&proofCacheEntry{key: key, value: value})
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back(), sizeEvictionReason)
+	}
+}
+
+// Invalidate evicts the entries of treeID at revisions, or log sizes, below
+// before. LogClient calls it whenever its trusted root grows, as it only
+// fetches proofs against its trusted root; map applications call it once they
+// no longer read old revisions.
+func (c *ProofCache) Invalidate(treeID, before int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if key := elem.Value.(*proofCacheEntry).key; key.treeID == treeID && key.revision < before {
+			c.remove(elem, invalidatedEvictionReason)
+		}
+		elem = next
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *ProofCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// remove evicts elem. The caller must hold c.mu.
+func (c *ProofCache) remove(elem *list.Element, reason string) {
+	key := elem.Value.(*proofCacheEntry).key
+	c.lru.Remove(elem)
+	delete(c.entries, key)
+	c.evictionCounter.Inc(strconv.FormatInt(key.treeID, 10), reason)
+}
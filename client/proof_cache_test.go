@@ -0,0 +1,80 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"testing"
+
+	"github.com/google/trillian/monitoring"
+)
+
+func leafKey(treeID, revision int64, index string) proofCacheKey {
+	return proofCacheKey{treeID: treeID, revision: revision, kind: mapLeafKind, index: index}
+}
+
+func TestProofCache(t *testing.T) {
+	c := NewProofCache(3, monitoring.InertMetricFactory{})
+	c.put(leafKey(1, 1, "a"), "1a")
+	c.put(leafKey(1, 1, "b"), "1b")
+	c.put(leafKey(1, 2, "a"), "2a")
+	if _, ok := c.get(leafKey(1, 1, "a")); !ok {
+		t.Errorf("get(1a) not found")
+	}
+	// Evicts 1b, the least recently used entry.
+	c.put(leafKey(2, 1, "a"), "other tree")
+
+	for _, tc := range []struct {
+		key       proofCacheKey
+		want      interface{}
+		wantFound bool
+	}{
+		{key: leafKey(1, 1, "a"), want: "1a", wantFound: true},
+		{key: leafKey(1, 1, "b")},
+		{key: leafKey(1, 2, "a"), want: "2a", wantFound: true},
+		{key: proofCacheKey{treeID: 1, revision: 2, kind: mapRootKind, index: "a"}},
+		{key: leafKey(2, 1, "a"), want: "other tree", wantFound: true},
+	} {
+		got, ok := c.get(tc.key)
+		if ok != tc.wantFound || got != tc.want {
+			t.Errorf("get(%+v): %v, %v, want %v, %v", tc.key, got, ok, tc.want, tc.wantFound)
+		}
+	}
+
+	// Only the older revisions of tree 1 are invalidated.
+	c.Invalidate(1, 2)
+	if got, want := c.Len(), 2; got != want {
+		t.Errorf("Len(): %v, want %v", got, want)
+	}
+	if _, ok := c.get(leafKey(1, 1, "a")); ok {
+		t.Errorf("get(1a) found after Invalidate()")
+	}
+	for _, key := range []proofCacheKey{leafKey(1, 2, "a"), leafKey(2, 1, "a")} {
+		if _, ok := c.get(key); !ok {
+			t.Errorf("get(%+v) not found after Invalidate()", key)
+		}
+	}
+}
+
+func TestProofCache_Nil(t *testing.T) {
+	var c *ProofCache
+	c.put(leafKey(1, 1, "a"), "1a")
+	if got, ok := c.get(leafKey(1, 1, "a")); ok {
+		t.Errorf("get(): %v, want not found", got)
+	}
+	c.Invalidate(1, 2)
+	if got := c.Len(); got != 0 {
+		t.Errorf("Len(): %v, want 0", got)
+	}
+}
@@ -16,17 +16,37 @@ package rpcflags
 
 import (
 	"flag"
+	"strings"
+	"time"
 
 	"github.com/golang/glog"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 )
 
 var (
 	// tlsCertFile is the flag-assigned value for the path to the Trillian server's TLS certificate.
 	tlsCertFile = flag.String("tls_cert_file", "", "Path to the file containing the Trillian server's PEM-encoded public TLS certificate. If unset, unsecured connections will be used")
+
+	keepaliveTime                = flag.Duration("grpc_keepalive_time", 0, "If positive, interval after which the client pings the server if the connection is idle, to detect connections which were silently dropped (e.g. by a load balancer). Must not be lower than the minimum ping interval enforced by the server (5m by default). Zero, the default, disables keepalive pings")
+	keepaliveTimeout             = flag.Duration("grpc_keepalive_timeout", 20*time.Second, "Time the client waits for a response to a keepalive ping before closing the connection")
+	keepalivePermitWithoutStream = flag.Bool("grpc_keepalive_permit_without_stream", false, "If true, keepalive pings are sent even when there are no active RPCs. The server must permit this too")
+	maxReconnectDelay            = flag.Duration("grpc_max_reconnect_delay", 0, "Maximum delay between attempts to re-establish a broken connection. Zero uses the gRPC default (120s)")
+	waitForReady                 = flag.Bool("grpc_wait_for_ready", false, "If true, RPCs issued while the connection is being re-established (e.g. after the server closes it on reaching its maximum age) wait for it to be ready instead of failing immediately")
+	dnsResolver                  = flag.Bool("grpc_dns_resolver", false, "If true, addresses passed through DialTarget are resolved by the gRPC DNS resolver, which re-resolves them whenever a connection breaks")
 )
 
+// DialTarget returns the target to pass to grpc.Dial for addr. If the
+// --grpc_dns_resolver flag is set and addr does not specify a resolver scheme,
+// the gRPC DNS resolver is used.
+func DialTarget(addr string) string {
+	if !*dnsResolver || strings.Contains(addr, "://") {
+		return addr
+	}
+	return "dns:///" + addr
+}
+
 // NewClientDialOptionsFromFlags returns a list of grpc.DialOption values to be
 // passed as DialOption arguments to grpc.Dial
 func NewClientDialOptionsFromFlags() ([]grpc.DialOption, error) {
@@ -43,5 +63,19 @@ func NewClientDialOptionsFromFlags() ([]grpc.DialOption, error) {
 		dialOpts = append(dialOpts, grpc.WithTransportCredentials(creds))
 	}
 
+	if *keepaliveTime > 0 {
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                *keepaliveTime,
+			Timeout:             *keepaliveTimeout,
+			PermitWithoutStream: *keepalivePermitWithoutStream,
+		}))
+	}
+	if *maxReconnectDelay > 0 {
+		dialOpts = append(dialOpts, grpc.WithBackoffMaxDelay(*maxReconnectDelay))
+	}
+	if *waitForReady {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.WaitForReady(true)))
+	}
+
 	return dialOpts, nil
 }
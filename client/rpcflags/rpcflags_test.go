@@ -19,14 +19,20 @@ import (
 	"encoding/pem"
 	"flag"
 	"os"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/storage/memory"
 	"github.com/google/trillian/testonly/flagsaver"
 	"github.com/google/trillian/testonly/integration"
 	"github.com/google/trillian/testonly/setup"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 )
 
 func TestNewClientDialOptionsFromFlagsWithTLSCertFileNotSet(t *testing.T) {
@@ -59,6 +65,19 @@ func TestNewClientDialOptionsFromFlagsWithTLSCertFileNotSet(t *testing.T) {
 	}
 }
 
+func TestNewClientDialOptionsFromFlagsDefaults(t *testing.T) {
+	defer flagsaver.Save().MustRestore()
+	dialOpts, err := NewClientDialOptionsFromFlags()
+	if err != nil {
+		t.Fatalf("Got an unexpected error: %v", err)
+	}
+	// Only the insecure transport is set: keepalive pings and the other
+	// connection options are opt-in.
+	if got, want := len(dialOpts), 1; got != want {
+		t.Errorf("Got %d dial options, want %d", got, want)
+	}
+}
+
 func TestNewClientDialOptionsFromFlagsWithTLSCertFileMissing(t *testing.T) {
 	defer flagsaver.Save().MustRestore()
 	if err := flag.Set("tls_cert_file", "/a/missing/file"); err != nil {
@@ -133,3 +152,78 @@ func TestNewClientDialOptionsFromFlagsWithTLSCertFileSet(t *testing.T) {
 		t.Errorf("failed to request trees from the Admin Server: %v", err)
 	}
 }
+
+func TestNewClientDialOptionsFromFlagsWithConnectionCycling(t *testing.T) {
+	defer flagsaver.Save().MustRestore()
+	for name, value := range map[string]string{
+		"grpc_keepalive_time":      "10s",
+		"grpc_max_reconnect_delay": "100ms",
+		"grpc_wait_for_ready":      "true",
+		"grpc_dns_resolver":        "true",
+	} {
+		if err := flag.Set(name, value); err != nil {
+			t.Fatalf("Failed to set -%s flag: %v", name, err)
+		}
+	}
+
+	// Set up a Trillian server which closes each connection shortly after it
+	// is opened, as servers with a maximum connection age and load balancers
+	// resetting connections do.
+	ts := memory.NewTreeStorage()
+	registry := extension.Registry{
+		AdminStorage: memory.NewAdminStorage(ts),
+		LogStorage:   memory.NewLogStorage(ts, nil),
+		QuotaManager: quota.Noop(),
+	}
+	serverOpts := []grpc.ServerOption{grpc.KeepaliveParams(keepalive.ServerParameters{
+		MaxConnectionAge:      50 * time.Millisecond,
+		MaxConnectionAgeGrace: 50 * time.Millisecond,
+	})}
+	logEnv, err := integration.NewLogEnvWithRegistryAndGRPCOptions(context.Background(), 0, registry, serverOpts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logEnv.Close()
+
+	dialOpts, err := NewClientDialOptionsFromFlags()
+	if err != nil {
+		t.Fatalf("Got an unexpected error: %v", err)
+	}
+	conn, err := grpc.Dial(DialTarget(logEnv.Address), dialOpts...)
+	if err != nil {
+		t.Fatalf("failed to dial %v: %v", logEnv.Address, err)
+	}
+	defer conn.Close()
+
+	// Requests must keep succeeding while the server cycles the connection.
+	adminClient := trillian.NewTrillianAdminClient(conn)
+	for i, end := 0, time.Now().Add(time.Second); time.Now().Before(end); i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := adminClient.ListTrees(ctx, &trillian.ListTreesRequest{})
+		cancel()
+		if err != nil {
+			t.Fatalf("ListTrees() request %d failed: %v", i, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDialTarget(t *testing.T) {
+	defer flagsaver.Save().MustRestore()
+	for _, test := range []struct {
+		addr        string
+		dnsResolver bool
+		want        string
+	}{
+		{addr: "localhost:8090", want: "localhost:8090"},
+		{addr: "localhost:8090", dnsResolver: true, want: "dns:///localhost:8090"},
+		{addr: "passthrough:///localhost:8090", dnsResolver: true, want: "passthrough:///localhost:8090"},
+	} {
+		if err := flag.Set("grpc_dns_resolver", strconv.FormatBool(test.dnsResolver)); err != nil {
+			t.Fatalf("Failed to set -grpc_dns_resolver flag: %v", err)
+		}
+		if got := DialTarget(test.addr); got != test.want {
+			t.Errorf("DialTarget(%q) with dns resolver %v = %q, want %q", test.addr, test.dnsResolver, got, test.want)
+		}
+	}
+}
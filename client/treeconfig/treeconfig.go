@@ -0,0 +1,322 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package treeconfig builds valid requests to create Trillian trees.
+//
+// A Builder starts from the defaults for a type of tree, and applies options
+// on top of them. Build checks the resulting tree against the rules the
+// Trillian admin server applies to new trees, so that mistakes are reported
+// before calling CreateTree, with the name of the offending option:
+//
+//	req, err := treeconfig.NewMapTree().
+//		WithHasher(trillian.HashStrategy_CONIKS_SHA256).
+//		WithSigner(sigpb.DigitallySigned_ECDSA).
+//		WithDisplayName("my map").
+//		Build()
+package treeconfig
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
+	"github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/merkle/hashers"
+
+	// Register the hashers of the supported hash strategies.
+	_ "github.com/google/trillian/merkle/coniks"
+	_ "github.com/google/trillian/merkle/maphasher"
+	_ "github.com/google/trillian/merkle/rfc6962"
+)
+
+// Limits on the sizes of tree labels, as enforced by the server.
+const (
+	maxLabelKeySize   = 63
+	maxLabelValueSize = 255
+)
+
+// Builder builds a CreateTreeRequest. The zero Builder is not valid; use
+// NewLogTree, NewPreorderedLogTree or NewMapTree.
+type Builder struct {
+	tree    *trillian.Tree
+	keySpec *keyspb.Specification
+	// err is the first error of an option, returned by Build.
+	err error
+}
+
+func newBuilder(treeType trillian.TreeType, hashStrategy trillian.HashStrategy) *Builder {
+	b := &Builder{tree: &trillian.Tree{
+		TreeState:       trillian.TreeState_ACTIVE,
+		TreeType:        treeType,
+		HashStrategy:    hashStrategy,
+		HashAlgorithm:   sigpb.DigitallySigned_SHA256,
+		MaxRootDuration: ptypes.DurationProto(0),
+	}}
+	return b.WithSigner(sigpb.DigitallySigned_ECDSA)
+}
+
+// NewLogTree returns a Builder for a LOG tree, which by default uses the
+// RFC6962_SHA256 hash strategy and an ECDSA key generated by the server.
+func NewLogTree() *Builder {
+	return newBuilder(trillian.TreeType_LOG, trillian.HashStrategy_RFC6962_SHA256)
+}
+
+// NewPreorderedLogTree returns a Builder for a PREORDERED_LOG tree, with the
+// same defaults as NewLogTree.
+func NewPreorderedLogTree() *Builder {
+	return newBuilder(trillian.TreeType_PREORDERED_LOG, trillian.HashStrategy_RFC6962_SHA256)
+}
+
+// NewMapTree returns a Builder for a MAP tree, which by default uses the
+// CONIKS_SHA256 hash strategy and an ECDSA key generated by the server.
+func NewMapTree() *Builder {
+	return newBuilder(trillian.TreeType_MAP, trillian.HashStrategy_CONIKS_SHA256)
+}
+
+// WithHasher sets the hash strategy of the tree, which must be supported for
+// the type of the tree.
+func (b *Builder) WithHasher(strategy trillian.HashStrategy) *Builder {
+	b.tree.HashStrategy = strategy
+	return b
+}
+
+// WithHashAlgorithm sets the hash algorithm used to sign the roots of the
+// tree.
+func (b *Builder) WithHashAlgorithm(alg sigpb.DigitallySigned_HashAlgorithm) *Builder {
+	b.tree.HashAlgorithm = alg
+	return b
+}
+
+// WithSigner sets the signature algorithm of the tree, and has the server
+// generate a private key for it. It replaces any key set by WithPrivateKey.
+func (b *Builder) WithSigner(alg sigpb.DigitallySigned_SignatureAlgorithm) *Builder {
+	spec := &keyspb.Specification{}
+	switch alg {
+	case sigpb.DigitallySigned_ECDSA:
+		spec.Params = &keyspb.Specification_EcdsaParams{EcdsaParams: &keyspb.Specification_ECDSA{}}
+	case sigpb.DigitallySigned_RSA:
+		spec.Params = &keyspb.Specification_RsaParams{RsaParams: &keyspb.Specification_RSA{}}
+	case sigpb.DigitallySigned_ED25519:
+		spec.Params = &keyspb.Specification_Ed25519Params{Ed25519Params: &keyspb.Specification_Ed25519{}}
+	default:
+		b.setErr(fmt.Errorf("WithSigner: unsupported signature algorithm: %v", alg))
+	}
+	b.tree.SignatureAlgorithm = alg
+	b.tree.PrivateKey = nil
+	b.keySpec = spec
+	return b
+}
+
+// WithPrivateKey sets the signature algorithm of the tree, and the private
+// key the server uses to sign its roots, e.g. a keyspb.PEMKeyFile, or an Any
+// holding it. It replaces any key generation requested by WithSigner.
+func (b *Builder) WithPrivateKey(alg sigpb.DigitallySigned_SignatureAlgorithm, key proto.Message) *Builder {
+	anyKey, ok := key.(*any.Any)
+	if !ok {
+		var err error
+		if anyKey, err = ptypes.MarshalAny(key); err != nil {
+			b.setErr(fmt.Errorf("WithPrivateKey: %v", err))
+		}
+	}
+	b.tree.SignatureAlgorithm = alg
+	b.tree.PrivateKey = anyKey
+	b.keySpec = nil
+	return b
+}
+
+// WithDisplayName sets the display name of the tree.
+func (b *Builder) WithDisplayName(name string) *Builder {
+	b.tree.DisplayName = name
+	return b
+}
+
+// WithDescription sets the description of the tree.
+func (b *Builder) WithDescription(description string) *Builder {
+	b.tree.Description = description
+	return b
+}
+
+// WithMaxRootDuration sets the interval after which a new signed root is
+// produced even if the tree has not changed. Zero means never.
+func (b *Builder) WithMaxRootDuration(d time.Duration) *Builder {
+	b.tree.MaxRootDuration = ptypes.DurationProto(d)
+	return b
+}
+
+// WithRevisionRetention sets the revision retention policy of a map, which
+// keeps the keepRevisions most recent revisions, and the revisions superseded
+// within keepDuration. Zero values disable the corresponding rule.
+func (b *Builder) WithRevisionRetention(keepRevisions int64, keepDuration time.Duration) *Builder {
+	b.tree.RevisionRetentionPolicy = &trillian.RevisionRetentionPolicy{KeepRevisions: keepRevisions}
+	if keepDuration != 0 {
+		b.tree.RevisionRetentionPolicy.KeepDuration = ptypes.DurationProto(keepDuration)
+	}
+	return b
+}
+
+// WithMapIndexBits sets the number of bits of the indices of a map, a
+// multiple of 8 of at most the bit length of its hash strategy, which also
+// bounds the length of its proofs.
+func (b *Builder) WithMapIndexBits(bits int) *Builder {
+	b.tree.MapIndexBits = int32(bits)
+	return b
+}
+
+// WithDuplicateLeafPolicy sets how a log handles leaves with the same
+// identity hash as a leaf already queued or sequenced.
+func (b *Builder) WithDuplicateLeafPolicy(policy trillian.DuplicateLeafPolicy) *Builder {
+	b.tree.DuplicateLeafPolicy = policy
+	return b
+}
+
+// WithLabel sets a label of the tree, which tags it for operators' tooling.
+func (b *Builder) WithLabel(key, value string) *Builder {
+	if b.tree.Labels == nil {
+		b.tree.Labels = make(map[string]string)
+	}
+	b.tree.Labels[key] = value
+	return b
+}
+
+// WithDeleteRetention sets the period the tree remains soft-deleted, and may
+// be undeleted, before it's hard-deleted. Zero means the server's default.
+func (b *Builder) WithDeleteRetention(d time.Duration) *Builder {
+	b.tree.DeleteRetention = nil
+	if d != 0 {
+		b.tree.DeleteRetention = ptypes.DurationProto(d)
+	}
+	return b
+}
+
+// WithQuota sets the rates at which requests to the tree may spend read and
+// write quota tokens. Zero rates are unlimited.
+func (b *Builder) WithQuota(readTokensPerSecond, writeTokensPerSecond int64) *Builder {
+	b.tree.Quota = nil
+	if readTokensPerSecond != 0 || writeTokensPerSecond != 0 {
+		b.tree.Quota = &trillian.TreeQuota{ReadTokensPerSecond: readTokensPerSecond, WriteTokensPerSecond: writeTokensPerSecond}
+	}
+	return b
+}
+
+// WithOwner sets the principal which owns the tree, which is the only one
+// allowed to access it on servers enforcing tree ownership.
+func (b *Builder) WithOwner(owner string) *Builder {
+	b.tree.Owner = owner
+	return b
+}
+
+func (b *Builder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Build returns the request which creates the configured tree, or an error
+// if an option is invalid, or the server would reject the tree.
+func (b *Builder) Build() (*trillian.CreateTreeRequest, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if err := validate(b.tree); err != nil {
+		return nil, err
+	}
+	return &trillian.CreateTreeRequest{
+		Tree:    proto.Clone(b.tree).(*trillian.Tree),
+		KeySpec: proto.Clone(b.keySpec).(*keyspb.Specification),
+	}, nil
+}
+
+// validate mirrors the validation of new trees by the admin server and
+// storage.ValidateTreeForCreation, except for keys, which are checked by
+// the server.
+func validate(tree *trillian.Tree) error {
+	switch tree.TreeType {
+	case trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG:
+		if _, err := hashers.NewLogHasher(tree.HashStrategy); err != nil {
+			return fmt.Errorf("WithHasher: %v is not a %v hash strategy", tree.HashStrategy, tree.TreeType)
+		}
+	case trillian.TreeType_MAP:
+		h, err := hashers.NewMapHasher(tree.HashStrategy)
+		if err != nil {
+			return fmt.Errorf("WithHasher: %v is not a %v hash strategy", tree.HashStrategy, tree.TreeType)
+		}
+		if tree.MapIndexBits != 0 {
+			if _, err := hashers.WithIndexBits(h, int(tree.MapIndexBits)); err != nil {
+				return fmt.Errorf("WithMapIndexBits: %v", err)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid tree type: %v", tree.TreeType)
+	}
+	if tree.MapIndexBits != 0 && tree.TreeType != trillian.TreeType_MAP {
+		return fmt.Errorf("WithMapIndexBits: not supported for %v trees", tree.TreeType)
+	}
+	if tree.DuplicateLeafPolicy != trillian.DuplicateLeafPolicy_DUPLICATE_LEAF_POLICY_UNSPECIFIED {
+		if tree.TreeType != trillian.TreeType_LOG {
+			return fmt.Errorf("WithDuplicateLeafPolicy: not supported for %v trees", tree.TreeType)
+		}
+		if _, ok := trillian.DuplicateLeafPolicy_name[int32(tree.DuplicateLeafPolicy)]; !ok {
+			return fmt.Errorf("WithDuplicateLeafPolicy: invalid policy: %v", tree.DuplicateLeafPolicy)
+		}
+	}
+	for k, v := range tree.Labels {
+		if k == "" || len(k) > maxLabelKeySize {
+			return fmt.Errorf("WithLabel: key %q must have 1 to %d bytes", k, maxLabelKeySize)
+		}
+		if len(v) > maxLabelValueSize {
+			return fmt.Errorf("WithLabel: value of %q longer than %d bytes", k, maxLabelValueSize)
+		}
+	}
+	if tree.HashAlgorithm == sigpb.DigitallySigned_NONE {
+		return fmt.Errorf("WithHashAlgorithm: invalid hash algorithm: %v", tree.HashAlgorithm)
+	}
+	if tree.SignatureAlgorithm == sigpb.DigitallySigned_ANONYMOUS {
+		return fmt.Errorf("WithPrivateKey: invalid signature algorithm: %v", tree.SignatureAlgorithm)
+	}
+	if d, err := ptypes.Duration(tree.MaxRootDuration); err != nil || d < 0 {
+		return fmt.Errorf("WithMaxRootDuration: invalid duration: %v", tree.MaxRootDuration)
+	}
+	if tree.DeleteRetention != nil {
+		if d, err := ptypes.Duration(tree.DeleteRetention); err != nil || d < 0 {
+			return fmt.Errorf("WithDeleteRetention: invalid duration: %v", tree.DeleteRetention)
+		}
+	}
+	if q := tree.Quota; q.GetReadTokensPerSecond() < 0 || q.GetWriteTokensPerSecond() < 0 {
+		return fmt.Errorf("WithQuota: negative rate: %v", q)
+	}
+	if policy := tree.RevisionRetentionPolicy; policy != nil {
+		if tree.TreeType != trillian.TreeType_MAP {
+			return fmt.Errorf("WithRevisionRetention: not supported for %v trees", tree.TreeType)
+		}
+		if policy.KeepRevisions < 0 {
+			return fmt.Errorf("WithRevisionRetention: negative revision count: %v", policy.KeepRevisions)
+		}
+		var keepDuration time.Duration
+		if policy.KeepDuration != nil {
+			keepDuration, _ = ptypes.Duration(policy.KeepDuration)
+		}
+		if keepDuration < 0 {
+			return fmt.Errorf("WithRevisionRetention: negative duration: %v", keepDuration)
+		}
+		if policy.KeepRevisions == 0 && keepDuration == 0 {
+			return errors.New("WithRevisionRetention: requires a revision count or a duration")
+		}
+	}
+	return nil
+}
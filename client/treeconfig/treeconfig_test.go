@@ -0,0 +1,204 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package treeconfig
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
+	"github.com/google/trillian/crypto/sigpb"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestBuild(t *testing.T) {
+	ecdsaSpec := &keyspb.Specification{Params: &keyspb.Specification_EcdsaParams{EcdsaParams: &keyspb.Specification_ECDSA{}}}
+	key, err := ptypes.MarshalAny(&empty.Empty{})
+	if err != nil {
+		t.Fatalf("MarshalAny(): %v", err)
+	}
+
+	for _, test := range []struct {
+		desc    string
+		builder *Builder
+		want    *trillian.CreateTreeRequest
+	}{
+		{
+			desc:    "log",
+			builder: NewLogTree(),
+			want: &trillian.CreateTreeRequest{
+				Tree: &trillian.Tree{
+					TreeState:          trillian.TreeState_ACTIVE,
+					TreeType:           trillian.TreeType_LOG,
+					HashStrategy:       trillian.HashStrategy_RFC6962_SHA256,
+					HashAlgorithm:      sigpb.DigitallySigned_SHA256,
+					SignatureAlgorithm: sigpb.DigitallySigned_ECDSA,
+					MaxRootDuration:    ptypes.DurationProto(0),
+				},
+				KeySpec: ecdsaSpec,
+			},
+		},
+		{
+			desc: "map",
+			builder: NewMapTree().
+				WithHasher(trillian.HashStrategy_TEST_MAP_HASHER).
+				WithPrivateKey(sigpb.DigitallySigned_RSA, key).
+				WithDisplayName("name").
+				WithDescription("description").
+				WithMaxRootDuration(time.Hour).
+				WithRevisionRetention(10, 0).
+				WithMapIndexBits(160).
+				WithLabel("env", "prod").
+				WithDeleteRetention(24*time.Hour).
+				WithQuota(100, 10).
+				WithOwner("tenant-1"),
+			want: &trillian.CreateTreeRequest{
+				Tree: &trillian.Tree{
+					TreeState:               trillian.TreeState_ACTIVE,
+					TreeType:                trillian.TreeType_MAP,
+					HashStrategy:            trillian.HashStrategy_TEST_MAP_HASHER,
+					HashAlgorithm:           sigpb.DigitallySigned_SHA256,
+					SignatureAlgorithm:      sigpb.DigitallySigned_RSA,
+					PrivateKey:              key,
+					DisplayName:             "name",
+					Description:             "description",
+					MaxRootDuration:         ptypes.DurationProto(time.Hour),
+					RevisionRetentionPolicy: &trillian.RevisionRetentionPolicy{KeepRevisions: 10},
+					MapIndexBits:            160,
+					Labels:                  map[string]string{"env": "prod"},
+					DeleteRetention:         ptypes.DurationProto(24 * time.Hour),
+					Quota:                   &trillian.TreeQuota{ReadTokensPerSecond: 100, WriteTokensPerSecond: 10},
+					Owner:                   "tenant-1",
+				},
+			},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := test.builder.Build()
+			if err != nil {
+				t.Fatalf("Build(): %v", err)
+			}
+			if !proto.Equal(got, test.want) {
+				t.Errorf("Build() diff (-got +want):\n%s", pretty.Compare(got, test.want))
+			}
+		})
+	}
+}
+
+func TestBuild_Invalid(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		builder *Builder
+		wantErr string
+	}{
+		{
+			desc:    "map-with-log-hasher",
+			builder: NewMapTree().WithHasher(trillian.HashStrategy_RFC6962_SHA256),
+			wantErr: "WithHasher",
+		},
+		{
+			desc:    "log-with-map-hasher",
+			builder: NewPreorderedLogTree().WithHasher(trillian.HashStrategy_CONIKS_SHA256),
+			wantErr: "WithHasher",
+		},
+		{
+			desc:    "no-hash-algorithm",
+			builder: NewLogTree().WithHashAlgorithm(sigpb.DigitallySigned_NONE),
+			wantErr: "WithHashAlgorithm",
+		},
+		{
+			desc:    "anonymous-signer",
+			builder: NewLogTree().WithSigner(sigpb.DigitallySigned_ANONYMOUS),
+			wantErr: "WithSigner",
+		},
+		{
+			desc:    "anonymous-key",
+			builder: NewLogTree().WithPrivateKey(sigpb.DigitallySigned_ANONYMOUS, &empty.Empty{}),
+			wantErr: "WithPrivateKey",
+		},
+		{
+			desc:    "negative-max-root-duration",
+			builder: NewLogTree().WithMaxRootDuration(-time.Second),
+			wantErr: "WithMaxRootDuration",
+		},
+		{
+			desc:    "empty-label-key",
+			builder: NewLogTree().WithLabel("", "value"),
+			wantErr: "WithLabel",
+		},
+		{
+			desc:    "long-label-value",
+			builder: NewLogTree().WithLabel("key", strings.Repeat("x", 256)),
+			wantErr: "WithLabel",
+		},
+		{
+			desc:    "negative-delete-retention",
+			builder: NewLogTree().WithDeleteRetention(-time.Hour),
+			wantErr: "WithDeleteRetention",
+		},
+		{
+			desc:    "negative-quota",
+			builder: NewLogTree().WithQuota(0, -1),
+			wantErr: "WithQuota",
+		},
+		{
+			desc:    "map-duplicate-leaf-policy",
+			builder: NewMapTree().WithDuplicateLeafPolicy(trillian.DuplicateLeafPolicy_DEDUPLICATE),
+			wantErr: "WithDuplicateLeafPolicy",
+		},
+		{
+			desc:    "log-retention",
+			builder: NewLogTree().WithRevisionRetention(10, 0),
+			wantErr: "WithRevisionRetention",
+		},
+		{
+			desc:    "negative-retention",
+			builder: NewMapTree().WithRevisionRetention(-1, time.Hour),
+			wantErr: "WithRevisionRetention",
+		},
+		{
+			desc:    "empty-retention",
+			builder: NewMapTree().WithRevisionRetention(0, 0),
+			wantErr: "WithRevisionRetention",
+		},
+		{
+			desc:    "log-index-bits",
+			builder: NewLogTree().WithMapIndexBits(160),
+			wantErr: "WithMapIndexBits",
+		},
+		{
+			desc:    "index-bits-not-bytes",
+			builder: NewMapTree().WithMapIndexBits(100),
+			wantErr: "WithMapIndexBits",
+		},
+		{
+			desc:    "index-bits-too-long",
+			builder: NewMapTree().WithMapIndexBits(264),
+			wantErr: "WithMapIndexBits",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := test.builder.Build()
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Build(): %v, want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
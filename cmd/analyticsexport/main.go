@@ -0,0 +1,169 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the
+// analyticsexport command, which exports the entries of a Trillian log, or
+// the mutations of a Trillian map, into an analytics store.
+//
+// Example usage:
+// $ ./analyticsexport --rpc_server=host:port --log_id=1 --sink=clickhouse --clickhouse_url=http://localhost:8123
+// $ ./analyticsexport --rpc_server=host:port --map_id=2 --sink=bigquery_files --output_dir=/tmp/export --once
+//
+// Exports resume after the last row in the store, unless --backfill_from is
+// set.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/analytics"
+	"github.com/google/trillian/client/rpcflags"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/prometheus"
+	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/clock"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+)
+
+var (
+	rpcServer          = flag.String("rpc_server", "", "Address of the gRPC Trillian Log or Map Server serving the tree to export (host:port)")
+	logID              = flag.Int64("log_id", 0, "ID of the log to export; exactly one of --log_id and --map_id must be set")
+	mapID              = flag.Int64("map_id", 0, "ID of the map to export; exactly one of --log_id and --map_id must be set")
+	sinkName           = flag.String("sink", "clickhouse", "Store to export to: clickhouse, or bigquery_files for files to load into BigQuery")
+	clickHouseURL      = flag.String("clickhouse_url", "http://localhost:8123", "URL of the ClickHouse HTTP interface, for --sink=clickhouse")
+	clickHouseDatabase = flag.String("clickhouse_database", "", "ClickHouse database to create tables in, for --sink=clickhouse. Empty uses the default database")
+	outputDir          = flag.String("output_dir", "", "Directory to write files to, for --sink=bigquery_files")
+	includeValues      = flag.Bool("include_values", false, "Export leaf values and extra data, as well as metadata")
+	chunkSize          = flag.Int("chunk_size", 0, "Number of log entries, or map roots and leaves, in each streamed response. Zero uses the default of the server")
+	backfillFrom       = flag.Int64("backfill_from", -1, "If non-negative, export from this log entry or map revision, rather than after the last one exported")
+	interval           = flag.Duration("interval", time.Minute, "Delay between exports")
+	once               = flag.Bool("once", false, "Export once, rather than continuously")
+	metricsEndpoint    = flag.String("metrics_endpoint", "", "Endpoint for serving metrics; if left empty, metrics will not be exposed")
+)
+
+// exporter is implemented by analytics.LogExporter and analytics.MapExporter.
+type exporter interface {
+	Export(ctx context.Context) (int, error)
+	Run(ctx context.Context) error
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go util.AwaitSignal(ctx, cancel)
+	if err := run(ctx); err != nil && err != context.Canceled {
+		glog.Exit(err)
+	}
+}
+
+func run(ctx context.Context) error {
+	if *rpcServer == "" {
+		return errors.New("--rpc_server is required")
+	}
+	if (*logID == 0) == (*mapID == 0) {
+		return errors.New("exactly one of --log_id and --map_id is required")
+	}
+	var sink analytics.Sink
+	switch *sinkName {
+	case "clickhouse":
+		sink = analytics.NewClickHouseSink(*clickHouseURL, *clickHouseDatabase, nil)
+	case "bigquery_files":
+		if *outputDir == "" {
+			return errors.New("--output_dir is required for --sink=bigquery_files")
+		}
+		sink = analytics.NewBigQueryFileSink(*outputDir)
+	default:
+		return fmt.Errorf("unknown --sink %q", *sinkName)
+	}
+
+	var mf monitoring.MetricFactory
+	if *metricsEndpoint != "" {
+		mf = prometheus.MetricFactory{}
+		http.Handle("/metrics", promhttp.Handler())
+		server := http.Server{Addr: *metricsEndpoint, Handler: nil}
+		glog.Infof("Serving metrics at %v", *metricsEndpoint)
+		go func() {
+			err := server.ListenAndServe()
+			glog.Warningf("Metrics server exited: %v", err)
+		}()
+	}
+
+	dialOpts, err := rpcflags.NewClientDialOptionsFromFlags()
+	if err != nil {
+		return fmt.Errorf("failed to determine dial options: %v", err)
+	}
+	conn, err := grpc.Dial(rpcflags.DialTarget(*rpcServer), dialOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to dial %v: %v", *rpcServer, err)
+	}
+	defer conn.Close()
+
+	var e exporter
+	if *logID != 0 {
+		e, err = analytics.NewLogExporter(analytics.LogConfig{
+			Client:        trillian.NewTrillianLogClient(conn),
+			LogID:         *logID,
+			Sink:          sink,
+			IncludeValues: *includeValues,
+			ChunkSize:     int32(*chunkSize),
+			Backfill:      *backfillFrom >= 0,
+			BackfillFrom:  *backfillFrom,
+			Interval:      *interval,
+			TimeSource:    clock.System,
+			MetricFactory: mf,
+		})
+	} else {
+		e, err = analytics.NewMapExporter(analytics.MapConfig{
+			Client:        trillian.NewTrillianMapClient(conn),
+			MapID:         *mapID,
+			Sink:          sink,
+			IncludeValues: *includeValues,
+			PageSize:      int32(*chunkSize),
+			Backfill:      *backfillFrom >= 0,
+			BackfillFrom:  *backfillFrom,
+			Interval:      *interval,
+			TimeSource:    clock.System,
+			MetricFactory: mf,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if *once {
+		n, err := e.Export(ctx)
+		if err != nil {
+			return err
+		}
+		unit := "log entries"
+		if *mapID != 0 {
+			unit = "map revisions"
+		}
+		glog.Infof("Exported %d %s", n, unit)
+		return nil
+	}
+	glog.Infof("Exporting tree %d at %v every %v", *logID+*mapID, *rpcServer, *interval)
+	return e.Run(ctx)
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the
+// copystorage command, which copies the logs and maps of one storage system to
+// another, e.g. to move a deployment from MySQL to CloudSpanner or Postgres,
+// and verifies the root hash of each tree copied.
+//
+// Example usage:
+// $ ./copystorage --src_storage_system=mysql --mysql_uri=... --dst_storage_system=postgres --pg_conn_str=...
+// $ ./copystorage --src_storage_system=mysql --dst_storage_system=cloud_spanner --cloudspanner_uri=... --tree_ids=1,2
+//
+// The storage systems are configured with the same flags as the servers, so
+// the source and destination must be different storage systems. The trees and
+// their signed roots are copied unchanged, and the leaves and Merkle nodes or
+// subtrees of each root are checked against its root hash. Copying resumes
+// from the latest root in the destination, so the source can keep serving
+// until a final copy with its log signers and map writers stopped. Maps whose
+// older revisions have been garbage collected can't be copied.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/server"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/copier"
+
+	// Load hashers
+	_ "github.com/google/trillian/merkle/coniks"
+	_ "github.com/google/trillian/merkle/maphasher"
+	_ "github.com/google/trillian/merkle/rfc6962"
+)
+
+var (
+	srcStorageSystem = flag.String("src_storage_system", "mysql", "Storage system to copy the trees from")
+	dstStorageSystem = flag.String("dst_storage_system", "", "Storage system to copy the trees to")
+	treeIDs          = flag.String("tree_ids", "", "Comma-separated IDs of the trees to copy. If empty, all trees which are not deleted are copied")
+	batchSize        = flag.Int("batch_size", copier.DefaultBatchSize, "Number of leaves read from the source storage at a time")
+	verifyOnly       = flag.Bool("verify_only", false, "If true, the trees are only verified against the source storage, not copied")
+)
+
+// parseTreeIDs parses a comma-separated list of tree IDs.
+func parseTreeIDs(s string) ([]int64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var ids []int64
+	for _, f := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(f), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --tree_ids: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// listTrees returns the trees in admin with ids, or all the trees which are
+// not deleted if ids is empty.
+func listTrees(ctx context.Context, admin storage.AdminStorage, ids []int64) ([]*trillian.Tree, error) {
+	if len(ids) == 0 {
+		return storage.ListTrees(ctx, admin, false /* includeDeleted */)
+	}
+	trees := make([]*trillian.Tree, 0, len(ids))
+	for _, id := range ids {
+		tree, err := storage.GetTree(ctx, admin, id)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, tree)
+	}
+	return trees, nil
+}
+
+// copyTree copies tree with c, unless verifyOnly is set, and verifies the
+// copy.
+func copyTree(ctx context.Context, c *copier.Copier, tree *trillian.Tree, verifyOnly bool) error {
+	id := tree.TreeId
+	switch tree.TreeType {
+	case trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG:
+		if !verifyOnly {
+			stats, err := c.CopyLog(ctx, id)
+			if err != nil {
+				return fmt.Errorf("copy failed: %v", err)
+			}
+			glog.Infof("%d: copied %d roots and %d leaves", id, stats.Roots, stats.Leaves)
+		}
+		if err := c.VerifyLog(ctx, id); err != nil {
+			return fmt.Errorf("verification failed: %v", err)
+		}
+		return nil
+	case trillian.TreeType_MAP:
+		if !verifyOnly {
+			stats, err := c.CopyMap(ctx, id)
+			if err != nil {
+				return fmt.Errorf("copy failed: %v", err)
+			}
+			glog.Infof("%d: copied %d map roots and %d leaf values", id, stats.Roots, stats.Leaves)
+		}
+		if err := c.VerifyMap(ctx, id); err != nil {
+			return fmt.Errorf("verification failed: %v", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("can't copy a %s", tree.TreeType)
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+	ctx := context.Background()
+
+	if *srcStorageSystem == *dstStorageSystem {
+		glog.Exitf("--src_storage_system and --dst_storage_system must be different storage systems")
+	}
+	ids, err := parseTreeIDs(*treeIDs)
+	if err != nil {
+		glog.Exit(err)
+	}
+
+	src, err := server.NewStorageProvider(*srcStorageSystem, monitoring.InertMetricFactory{})
+	if err != nil {
+		glog.Exitf("Failed to get source storage provider: %v", err)
+	}
+	defer src.Close()
+	dst, err := server.NewStorageProvider(*dstStorageSystem, monitoring.InertMetricFactory{})
+	if err != nil {
+		glog.Exitf("Failed to get destination storage provider: %v", err)
+	}
+	defer dst.Close()
+
+	trees, err := listTrees(ctx, src.AdminStorage(), ids)
+	if err != nil {
+		glog.Exitf("Failed to list trees: %v", err)
+	}
+
+	c := &copier.Copier{Src: src, Dst: dst, BatchSize: *batchSize}
+	var failed int
+	for _, tree := range trees {
+		if err := copyTree(ctx, c, tree, *verifyOnly); err != nil {
+			glog.Errorf("%d: %v", tree.TreeId, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%d: OK\n", tree.TreeId)
+	}
+	if failed > 0 {
+		glog.Exitf("%d of %d trees failed", failed, len(trees))
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+
+	storageto "github.com/google/trillian/storage/testonly"
+)
+
+func TestListTrees(t *testing.T) {
+	ctx := context.Background()
+	admin := memory.NewAdminStorage(memory.NewTreeStorage())
+	var all []int64
+	for _, tree := range []*trillian.Tree{storageto.LogTree, storageto.PreorderedLogTree, storageto.MapTree} {
+		tree, err := storage.CreateTree(ctx, admin, tree)
+		if err != nil {
+			t.Fatalf("CreateTree(): %v", err)
+		}
+		all = append(all, tree.TreeId)
+	}
+	sortIDs(all)
+
+	for _, test := range []struct {
+		desc    string
+		ids     []int64
+		want    []int64
+		wantErr bool
+	}{
+		{desc: "all", want: all},
+		{desc: "some", ids: all[1:], want: all[1:]},
+		{desc: "unknown", ids: []int64{all[0], 12345}, wantErr: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			trees, err := listTrees(ctx, admin, test.ids)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("listTrees()=%v, want err? %v", err, test.wantErr)
+			}
+			var ids []int64
+			for _, tree := range trees {
+				ids = append(ids, tree.TreeId)
+			}
+			sortIDs(ids)
+			if !reflect.DeepEqual(ids, test.want) {
+				t.Errorf("listTrees()=%v, want %v", ids, test.want)
+			}
+		})
+	}
+}
+
+func sortIDs(ids []int64) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+}
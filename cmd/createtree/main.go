@@ -36,13 +36,12 @@ import (
 	"time"
 
 	"github.com/golang/glog"
-	"github.com/golang/protobuf/ptypes"
 	"github.com/google/trillian"
 	"github.com/google/trillian/client"
 	"github.com/google/trillian/client/rpcflags"
+	"github.com/google/trillian/client/treeconfig"
 	"github.com/google/trillian/cmd"
 	"github.com/google/trillian/cmd/createtree/keys"
-	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/crypto/sigpb"
 	"google.golang.org/grpc"
 )
@@ -53,7 +52,7 @@ var (
 
 	treeState          = flag.String("tree_state", trillian.TreeState_ACTIVE.String(), "State of the new tree")
 	treeType           = flag.String("tree_type", trillian.TreeType_LOG.String(), "Type of the new tree")
-	hashStrategy       = flag.String("hash_strategy", trillian.HashStrategy_RFC6962_SHA256.String(), "Hash strategy (aka preimage protection) of the new tree")
+	hashStrategy       = flag.String("hash_strategy", "", "Hash strategy (aka preimage protection) of the new tree. If empty, RFC6962_SHA256 is used for logs and CONIKS_SHA256 for maps")
 	hashAlgorithm      = flag.String("hash_algorithm", sigpb.DigitallySigned_SHA256.String(), "Hash algorithm of the new tree")
 	signatureAlgorithm = flag.String("signature_algorithm", sigpb.DigitallySigned_ECDSA.String(), "Signature algorithm of the new tree")
 	displayName        = flag.String("display_name", "", "Display name of the new tree")
@@ -82,7 +81,7 @@ func createTree(ctx context.Context) (*trillian.Tree, error) {
 		return nil, fmt.Errorf("failed to determine dial options: %v", err)
 	}
 
-	conn, err := grpc.Dial(*adminServerAddr, dialOpts...)
+	conn, err := grpc.Dial(rpcflags.DialTarget(*adminServerAddr), dialOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to dial %v: %v", *adminServerAddr, err)
 	}
@@ -100,17 +99,15 @@ func newRequest() (*trillian.CreateTreeRequest, error) {
 	if !ok {
 		return nil, fmt.Errorf("unknown TreeState: %v", *treeState)
 	}
+	if trillian.TreeState(ts) != trillian.TreeState_ACTIVE {
+		return nil, fmt.Errorf("invalid TreeState: %v, trees are created %v", *treeState, trillian.TreeState_ACTIVE)
+	}
 
 	tt, ok := trillian.TreeType_value[*treeType]
 	if !ok {
 		return nil, fmt.Errorf("unknown TreeType: %v", *treeType)
 	}
 
-	hs, ok := trillian.HashStrategy_value[*hashStrategy]
-	if !ok {
-		return nil, fmt.Errorf("unknown HashStrategy: %v", *hashStrategy)
-	}
-
 	ha, ok := sigpb.DigitallySigned_HashAlgorithm_value[*hashAlgorithm]
 	if !ok {
 		return nil, fmt.Errorf("unknown HashAlgorithm: %v", *hashAlgorithm)
@@ -121,41 +118,46 @@ func newRequest() (*trillian.CreateTreeRequest, error) {
 		return nil, fmt.Errorf("unknown SignatureAlgorithm: %v", *signatureAlgorithm)
 	}
 
-	ctr := &trillian.CreateTreeRequest{Tree: &trillian.Tree{
-		TreeState:          trillian.TreeState(ts),
-		TreeType:           trillian.TreeType(tt),
-		HashStrategy:       trillian.HashStrategy(hs),
-		HashAlgorithm:      sigpb.DigitallySigned_HashAlgorithm(ha),
-		SignatureAlgorithm: sigpb.DigitallySigned_SignatureAlgorithm(sa),
-		DisplayName:        *displayName,
-		Description:        *description,
-		MaxRootDuration:    ptypes.DurationProto(*maxRootDuration),
-	}}
-	glog.Infof("Creating tree %+v", ctr.Tree)
+	var b *treeconfig.Builder
+	switch trillian.TreeType(tt) {
+	case trillian.TreeType_LOG:
+		b = treeconfig.NewLogTree()
+	case trillian.TreeType_PREORDERED_LOG:
+		b = treeconfig.NewPreorderedLogTree()
+	case trillian.TreeType_MAP:
+		b = treeconfig.NewMapTree()
+	default:
+		return nil, fmt.Errorf("unsupported TreeType: %v", *treeType)
+	}
+
+	if *hashStrategy != "" {
+		hs, ok := trillian.HashStrategy_value[*hashStrategy]
+		if !ok {
+			return nil, fmt.Errorf("unknown HashStrategy: %v", *hashStrategy)
+		}
+		b.WithHasher(trillian.HashStrategy(hs))
+	}
+
+	b.WithHashAlgorithm(sigpb.DigitallySigned_HashAlgorithm(ha)).
+		WithDisplayName(*displayName).
+		WithDescription(*description).
+		WithMaxRootDuration(*maxRootDuration)
 
 	if *privateKeyFormat != "" {
 		pk, err := keys.New(*privateKeyFormat)
 		if err != nil {
 			return nil, err
 		}
-		ctr.Tree.PrivateKey = pk
+		b.WithPrivateKey(sigpb.DigitallySigned_SignatureAlgorithm(sa), pk)
 	} else {
-		ctr.KeySpec = &keyspb.Specification{}
-
-		switch sigpb.DigitallySigned_SignatureAlgorithm(sa) {
-		case sigpb.DigitallySigned_ECDSA:
-			ctr.KeySpec.Params = &keyspb.Specification_EcdsaParams{
-				EcdsaParams: &keyspb.Specification_ECDSA{},
-			}
-		case sigpb.DigitallySigned_RSA:
-			ctr.KeySpec.Params = &keyspb.Specification_RsaParams{
-				RsaParams: &keyspb.Specification_RSA{},
-			}
-		default:
-			return nil, fmt.Errorf("unsupported signature algorithm: %v", sa)
-		}
+		b.WithSigner(sigpb.DigitallySigned_SignatureAlgorithm(sa))
 	}
 
+	ctr, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("Creating tree %+v", ctr.Tree)
 	return ctr, nil
 }
 
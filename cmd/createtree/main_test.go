@@ -105,6 +105,15 @@ func TestCreateTree(t *testing.T) {
 			validateErr: errors.New("unknown TreeType"),
 			wantErr:     true,
 		},
+		{
+			desc: "invalidHashStrategyForType",
+			setFlags: func() {
+				*treeType = trillian.TreeType_MAP.String()
+				*hashStrategy = trillian.HashStrategy_RFC6962_SHA256.String()
+			},
+			validateErr: errors.New("not a MAP hash strategy"),
+			wantErr:     true,
+		},
 		{
 			desc:        "invalidKeyTypeOpts",
 			setFlags:    func() { *privateKeyFormat = "LLAMA!!" },
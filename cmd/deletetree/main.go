@@ -43,7 +43,7 @@ func main() {
 		glog.Exitf("Failed to determine dial options: %v", err)
 	}
 
-	conn, err := grpc.Dial(*adminServerAddr, dialOpts...)
+	conn, err := grpc.Dial(rpcflags.DialTarget(*adminServerAddr), dialOpts...)
 	if err != nil {
 		glog.Exitf("Failed to dial %v: %v", *adminServerAddr, err)
 	}
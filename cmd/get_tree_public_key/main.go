@@ -45,7 +45,7 @@ func getPublicKeyPEM() (string, error) {
 		return "", fmt.Errorf("failed to determine dial options: %v", err)
 	}
 
-	conn, err := grpc.Dial(*adminServerAddr, dialOpts...)
+	conn, err := grpc.Dial(rpcflags.DialTarget(*adminServerAddr), dialOpts...)
 	if err != nil {
 		return "", fmt.Errorf("failed to dial %v: %v", *adminServerAddr, err)
 	}
@@ -0,0 +1,128 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the mapmirror
+// command, which replicates a Trillian map into another one, and checks that
+// both have the same root hash at each revision.
+//
+// Example usage:
+// $ ./mapmirror --source_server=host:port --source_map_id=1 --dest_server=other:port --dest_map_id=2
+//
+// The destination map must have been created with the same hash strategy as
+// the source map, initialised, and not be written to by anything else. The
+// command exits if the destination map diverges from the source map.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client/rpcflags"
+	"github.com/google/trillian/maps/mirror"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/prometheus"
+	"github.com/google/trillian/util"
+	"github.com/google/trillian/util/clock"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+)
+
+var (
+	sourceServer    = flag.String("source_server", "", "Address of the gRPC Trillian Map Server serving the source map (host:port)")
+	sourceMapID     = flag.Int64("source_map_id", 0, "ID of the map to mirror")
+	destServer      = flag.String("dest_server", "", "Address of the gRPC Trillian Map Server serving the destination map (host:port)")
+	destWriteServer = flag.String("dest_write_server", "", "Address of the gRPC Trillian Map Write Server serving the destination map (host:port). Defaults to --dest_server. It must not queue writes")
+	destMapID       = flag.Int64("dest_map_id", 0, "ID of the map which the revisions of the source map are applied to")
+	pageSize        = flag.Int("page_size", 0, "Number of leaves in each page of the listings of both maps. Zero uses the default of the servers")
+	retryInterval   = flag.Duration("retry_interval", 10*time.Second, "Delay before following the source map again after an error")
+	metricsEndpoint = flag.String("metrics_endpoint", "", "Endpoint for serving metrics; if left empty, metrics will not be exposed")
+)
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go util.AwaitSignal(ctx, cancel)
+	if err := run(ctx); err != nil && err != context.Canceled {
+		glog.Exit(err)
+	}
+}
+
+func run(ctx context.Context) error {
+	if *sourceServer == "" || *destServer == "" {
+		return errors.New("--source_server and --dest_server are required")
+	}
+	if *sourceMapID == 0 || *destMapID == 0 {
+		return errors.New("--source_map_id and --dest_map_id are required")
+	}
+
+	var mf monitoring.MetricFactory
+	if *metricsEndpoint != "" {
+		mf = prometheus.MetricFactory{}
+		http.Handle("/metrics", promhttp.Handler())
+		server := http.Server{Addr: *metricsEndpoint, Handler: nil}
+		glog.Infof("Serving metrics at %v", *metricsEndpoint)
+		go func() {
+			err := server.ListenAndServe()
+			glog.Warningf("Metrics server exited: %v", err)
+		}()
+	}
+
+	dialOpts, err := rpcflags.NewClientDialOptionsFromFlags()
+	if err != nil {
+		return fmt.Errorf("failed to determine dial options: %v", err)
+	}
+	source, err := grpc.Dial(rpcflags.DialTarget(*sourceServer), dialOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to dial %v: %v", *sourceServer, err)
+	}
+	defer source.Close()
+	dest, err := grpc.Dial(rpcflags.DialTarget(*destServer), dialOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to dial %v: %v", *destServer, err)
+	}
+	defer dest.Close()
+	destWrite := dest
+	if *destWriteServer != "" {
+		if destWrite, err = grpc.Dial(rpcflags.DialTarget(*destWriteServer), dialOpts...); err != nil {
+			return fmt.Errorf("failed to dial %v: %v", *destWriteServer, err)
+		}
+		defer destWrite.Close()
+	}
+
+	m, err := mirror.New(mirror.Config{
+		Source:        trillian.NewTrillianMapClient(source),
+		SourceID:      *sourceMapID,
+		Dest:          trillian.NewTrillianMapClient(dest),
+		DestWrite:     trillian.NewTrillianMapWriteClient(destWrite),
+		DestID:        *destMapID,
+		PageSize:      int32(*pageSize),
+		RetryInterval: *retryInterval,
+		TimeSource:    clock.System,
+		MetricFactory: mf,
+	})
+	if err != nil {
+		return err
+	}
+	glog.Infof("Mirroring map %d at %v to map %d at %v", *sourceMapID, *sourceServer, *destMapID, *destServer)
+	return m.Run(ctx)
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the treebackup
+// command, which backs up individual logs to files and restores them, on the
+// same or any other storage system, with identical root hashes.
+//
+// Example usage:
+// $ ./treebackup --storage_system=mysql --mysql_uri=... --tree_id=123 --file=123.backup backup
+// $ ./treebackup --storage_system=mysql --tree_id=123 --at=2020-01-02T15:04:05Z --file=123.backup backup
+// $ ./treebackup --storage_system=cloud_spanner --cloudspanner_uri=... --file=123.backup restore
+//
+// Backups are logical: they hold the tree and its signed roots with their
+// witness signatures and leaves, in a format independent of the storage
+// system. A backup holds the roots up to --revision, or up to the latest root
+// published at --at, and by default all of them. The backed up tree is
+// restored under its own ID, which must not exist in the storage system, and
+// the restored log is verified against the last root of the backup. Backups
+// hold the private key of the tree as stored, so they must be protected like
+// the database. Maps are not backed up: use trillctl maps export instead.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/server"
+	"github.com/google/trillian/storage/copier"
+	"github.com/google/trillian/types"
+
+	// Load hashers
+	_ "github.com/google/trillian/merkle/rfc6962"
+)
+
+var (
+	storageSystem = flag.String("storage_system", "mysql", "Storage system to back up the log from, or to restore it to")
+	treeID        = flag.Int64("tree_id", 0, "ID of the log to back up")
+	file          = flag.String("file", "", "File the backup is written to, or restored from")
+	revision      = flag.Int64("revision", -1, "Revision of the last root backed up. If negative, the latest root is")
+	at            = flag.String("at", "", "If set, the roots published after this time, in RFC 3339 format, are not backed up")
+)
+
+// include returns the function selecting the roots backed up, or nil if all
+// of them are.
+func include() (func(*types.LogRootV1) bool, error) {
+	var before time.Time
+	if *at != "" {
+		var err error
+		if before, err = time.Parse(time.RFC3339, *at); err != nil {
+			return nil, fmt.Errorf("invalid --at: %v", err)
+		}
+	}
+	if *revision < 0 && before.IsZero() {
+		return nil, nil
+	}
+	return func(root *types.LogRootV1) bool {
+		if *revision >= 0 && int64(root.Revision) > *revision {
+			return false
+		}
+		return before.IsZero() || root.TimestampNanos <= uint64(before.UnixNano())
+	}, nil
+}
+
+func backup(ctx context.Context, f *os.File) error {
+	if *treeID == 0 {
+		return fmt.Errorf("--tree_id is required")
+	}
+	inc, err := include()
+	if err != nil {
+		return err
+	}
+	sp, err := server.NewStorageProvider(*storageSystem, monitoring.InertMetricFactory{})
+	if err != nil {
+		return fmt.Errorf("failed to get storage provider: %v", err)
+	}
+	defer sp.Close()
+
+	stats, err := copier.BackupLog(ctx, sp, *treeID, inc, f)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	fmt.Printf("%d: backed up %d roots and %d leaves to %s\n", *treeID, stats.Roots, stats.Leaves, *file)
+	return nil
+}
+
+func restore(ctx context.Context, f *os.File) error {
+	sp, err := server.NewStorageProvider(*storageSystem, monitoring.InertMetricFactory{})
+	if err != nil {
+		return fmt.Errorf("failed to get storage provider: %v", err)
+	}
+	defer sp.Close()
+
+	tree, stats, err := copier.RestoreLog(ctx, sp, f)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d: restored %d roots and %d leaves from %s\n", tree.TreeId, stats.Roots, stats.Leaves, *file)
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+	ctx := context.Background()
+
+	if flag.NArg() != 1 {
+		glog.Exitf("Usage: treebackup [flags] backup|restore")
+	}
+	if *file == "" {
+		glog.Exitf("--file is required")
+	}
+	switch cmd := flag.Arg(0); cmd {
+	case "backup":
+		f, err := os.OpenFile(*file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			glog.Exitf("Failed to create backup: %v", err)
+		}
+		err = backup(ctx, f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(*file)
+			glog.Exitf("Backup failed: %v", err)
+		}
+	case "restore":
+		f, err := os.Open(*file)
+		if err != nil {
+			glog.Exitf("Failed to open backup: %v", err)
+		}
+		defer f.Close()
+		if err := restore(ctx, f); err != nil {
+			glog.Exitf("Restore failed: %v", err)
+		}
+	default:
+		glog.Exitf("Unknown command %q, want backup or restore", cmd)
+	}
+}
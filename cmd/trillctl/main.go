@@ -0,0 +1,328 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the trillctl
+// command, which administers the trees of a Trillian instance.
+//
+// Example usage:
+// $ ./trillctl --admin_server=host:port trees export > trees.yaml
+// $ ./trillctl --admin_server=other:port trees import < trees.yaml
+//
+// "trees export" writes the definitions of trees to stdout as YAML, without
+// their private key material: keys held by Trillian are dropped, and
+// references to keys held elsewhere, such as PEM files, lose their password or
+// PIN, which may be added back to the file before importing it.
+//
+// "trees import" creates and initialises the trees defined by such a file, in
+// another environment, and prints the ID of each created tree. Trees without a
+// private key get a newly generated one.
+//
+// "maps export" writes the roots and leaves of a map up to a revision to
+// stdout, and "maps import" replays them into a map, verifying that each
+// revision reproduces the exported root hash:
+// $ ./trillctl --admin_server=host:port --tree_ids=1 trees export > map.yaml
+// $ ./trillctl --admin_server=host:port --map_id=1 maps export > map.bin
+// $ ./trillctl --admin_server=other:port --keep_tree_ids --init=false trees import < map.yaml
+// $ ./trillctl --admin_server=other:port --map_id=1 maps import < map.bin
+//
+// As map hashes depend on the tree ID, the map must be imported under its
+// exported ID, and left uninitialised, as importing revision 0 initialises it.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/client/rpcflags"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc"
+	"gopkg.in/yaml.v3"
+
+	// Register the key and storage settings protos found in trees.
+	_ "github.com/google/trillian/crypto/keyspb"
+	_ "github.com/google/trillian/storage/cloudspanner/spannerpb"
+	_ "github.com/google/trillian/storage/storagepb"
+)
+
+var (
+	adminServerAddr = flag.String("admin_server", "", "Address of the gRPC Trillian Admin Server (host:port)")
+	rpcDeadline     = flag.Duration("rpc_deadline", time.Minute, "Deadline for the RPC requests of a command")
+	treeIDs         = flag.String("tree_ids", "", "Comma-separated IDs of the trees to export. If empty, all trees which are not deleted are exported")
+	initTrees       = flag.Bool("init", true, "Initialise imported trees, as createtree does")
+	keepTreeIDs     = flag.Bool("keep_tree_ids", false, "Import trees under their exported IDs, as maps must be to import their contents")
+	mapID           = flag.Int64("map_id", 0, "ID of the map to export or import")
+	revision        = flag.Int64("revision", -1, "Revision of the map to export. If negative, the latest revision is exported")
+	pageSize        = flag.Int("page_size", 0, "Number of roots or leaves in each page of a map export. Zero uses the default of the server")
+
+	errUsage = errors.New("usage: trillctl [flags] trees|maps export|import")
+)
+
+// clients holds the clients of the Trillian servers used by commands.
+type clients struct {
+	admin trillian.TrillianAdminClient
+	log   trillian.TrillianLogClient
+	tmap  trillian.TrillianMapClient
+}
+
+// run runs the command in args, reading from in and writing to out.
+func run(ctx context.Context, args []string, in io.Reader, out io.Writer) error {
+	if len(args) != 2 || (args[0] != "trees" && args[0] != "maps") || (args[1] != "export" && args[1] != "import") {
+		return errUsage
+	}
+	if args[0] == "maps" && *mapID == 0 {
+		return errors.New("empty --map_id, please provide the ID of the map")
+	}
+	if *adminServerAddr == "" {
+		return errors.New("empty --admin_server, please provide the Admin server host:port")
+	}
+
+	dialOpts, err := rpcflags.NewClientDialOptionsFromFlags()
+	if err != nil {
+		return fmt.Errorf("failed to determine dial options: %v", err)
+	}
+	conn, err := grpc.Dial(rpcflags.DialTarget(*adminServerAddr), dialOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to dial %v: %v", *adminServerAddr, err)
+	}
+	defer conn.Close()
+	c := clients{
+		admin: trillian.NewTrillianAdminClient(conn),
+		log:   trillian.NewTrillianLogClient(conn),
+		tmap:  trillian.NewTrillianMapClient(conn),
+	}
+
+	switch {
+	case args[0] == "maps" && args[1] == "export":
+		return exportMap(ctx, c, *mapID, *revision, out)
+	case args[0] == "maps":
+		return importMap(ctx, c, *mapID, in, out)
+	case args[1] == "export":
+		ids, err := parseTreeIDs(*treeIDs)
+		if err != nil {
+			return err
+		}
+		return exportTrees(ctx, c, ids, out)
+	}
+	return importTrees(ctx, c, in, out)
+}
+
+// parseTreeIDs parses a comma-separated list of tree IDs.
+func parseTreeIDs(s string) ([]int64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var ids []int64
+	for _, f := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(f), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --tree_ids: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// exportTrees writes the definitions of the trees with ids, or of all trees if
+// ids is empty, to out as YAML.
+func exportTrees(ctx context.Context, c clients, ids []int64, out io.Writer) error {
+	resp, err := c.admin.ExportTrees(ctx, &trillian.ExportTreesRequest{TreeId: ids})
+	if err != nil {
+		return fmt.Errorf("failed to export trees: %v", err)
+	}
+	data, err := marshalYAML(resp)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+// importTrees creates the trees defined by the YAML read from in, initialises
+// them if --init is set, and writes their IDs to out.
+func importTrees(ctx context.Context, c clients, in io.Reader, out io.Writer) error {
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	var trees trillian.ExportTreesResponse
+	if err := unmarshalYAML(data, &trees); err != nil {
+		return err
+	}
+	resp, err := c.admin.ImportTrees(ctx, &trillian.ImportTreesRequest{Tree: trees.Tree, KeepTreeIds: *keepTreeIDs})
+	if err != nil {
+		return fmt.Errorf("failed to import trees: %v", err)
+	}
+	for _, tree := range resp.Tree {
+		if *initTrees {
+			if err := initTree(ctx, c, tree); err != nil {
+				return fmt.Errorf("failed to initialise tree %d: %v", tree.TreeId, err)
+			}
+		}
+		fmt.Fprintln(out, tree.TreeId)
+	}
+	return nil
+}
+
+func initTree(ctx context.Context, c clients, tree *trillian.Tree) error {
+	switch tree.TreeType {
+	case trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG:
+		return client.InitLog(ctx, tree, c.log)
+	case trillian.TreeType_MAP:
+		return client.InitMap(ctx, tree, c.tmap)
+	}
+	return fmt.Errorf("don't know how to initialise tree type %v", tree.TreeType)
+}
+
+// exportMap writes the roots and leaves of map mapID up to rev, or its latest
+// revision if rev is negative, to out, as a sequence of length-prefixed
+// ExportMapResponse protos.
+func exportMap(ctx context.Context, c clients, mapID, rev int64, out io.Writer) error {
+	if rev < 0 {
+		resp, err := c.tmap.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{MapId: mapID})
+		if err != nil {
+			return fmt.Errorf("failed to get the latest root of map %d: %v", mapID, err)
+		}
+		var root types.MapRootV1
+		if err := root.UnmarshalBinary(resp.GetMapRoot().GetMapRoot()); err != nil {
+			return err
+		}
+		rev = int64(root.Revision)
+	}
+	stream, err := c.tmap.ExportMap(ctx, &trillian.ExportMapRequest{MapId: mapID, Revision: rev, PageSize: int32(*pageSize)})
+	if err != nil {
+		return fmt.Errorf("failed to export map %d: %v", mapID, err)
+	}
+	w := bufio.NewWriter(out)
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to export map %d: %v", mapID, err)
+		}
+		data, err := proto.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		var size [binary.MaxVarintLen64]byte
+		if _, err := w.Write(size[:binary.PutUvarint(size[:], uint64(len(data)))]); err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	glog.Infof("Exported map %d at revision %d", mapID, rev)
+	return w.Flush()
+}
+
+// importMap replays the export read from in into map mapID, one revision at a
+// time, and writes the revisions imported to out.
+func importMap(ctx context.Context, c clients, mapID int64, in io.Reader, out io.Writer) error {
+	var roots []*trillian.SignedMapRoot
+	leaves := make(map[int64][]*trillian.MapLeaf)
+	r := bufio.NewReader(in)
+	for {
+		size, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read the map export: %v", err)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return fmt.Errorf("failed to read the map export: %v", err)
+		}
+		var resp trillian.ExportMapResponse
+		if err := proto.Unmarshal(data, &resp); err != nil {
+			return fmt.Errorf("failed to parse the map export: %v", err)
+		}
+		roots = append(roots, resp.MapRoots...)
+		for _, v := range resp.LeafVersions {
+			leaves[v.Revision] = append(leaves[v.Revision], v.Leaf)
+		}
+	}
+
+	for _, root := range roots {
+		var mapRoot types.MapRootV1
+		if err := mapRoot.UnmarshalBinary(root.MapRoot); err != nil {
+			return err
+		}
+		rev := int64(mapRoot.Revision)
+		if _, err := c.tmap.ImportMapRevision(ctx, &trillian.ImportMapRevisionRequest{MapId: mapID, MapRoot: root, Leaves: leaves[rev]}); err != nil {
+			return fmt.Errorf("failed to import revision %d of map %d: %v", rev, mapID, err)
+		}
+		fmt.Fprintln(out, rev)
+	}
+	return nil
+}
+
+// marshalYAML returns the YAML form of the JSON encoding of resp, so that
+// trees are written with the field names of the API.
+func marshalYAML(resp *trillian.ExportTreesResponse) ([]byte, error) {
+	var buf bytes.Buffer
+	m := jsonpb.Marshaler{OrigName: true}
+	if err := m.Marshal(&buf, resp); err != nil {
+		return nil, fmt.Errorf("failed to marshal trees: %v", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
+
+// unmarshalYAML parses data, written by marshalYAML, into resp.
+func unmarshalYAML(data []byte, resp *trillian.ExportTreesResponse) error {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse YAML: %v", err)
+	}
+	j, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to convert YAML to JSON: %v", err)
+	}
+	if err := jsonpb.Unmarshal(bytes.NewReader(j), resp); err != nil {
+		return fmt.Errorf("failed to unmarshal trees: %v", err)
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *rpcDeadline)
+	defer cancel()
+	if err := run(ctx, flag.Args(), os.Stdin, os.Stdout); err != nil {
+		glog.Exitf("trillctl: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
+	"github.com/google/trillian/storage/storagepb"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/testonly/flagsaver"
+	"github.com/google/trillian/types"
+)
+
+func TestExportImportTrees(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	defer flagsaver.Save().MustRestore()
+
+	s, stopFakeServer, err := testonly.NewMockServer(ctrl)
+	if err != nil {
+		t.Fatalf("Error starting fake server: %v", err)
+	}
+	defer stopFakeServer()
+	*adminServerAddr = s.Addr
+	*treeIDs = "1, 2"
+	*initTrees = false
+
+	key, err := ptypes.MarshalAny(&keyspb.PEMKeyFile{Path: "key.pem"})
+	if err != nil {
+		t.Fatalf("MarshalAny(): %v", err)
+	}
+	settings, err := ptypes.MarshalAny(&storagepb.MapStorageSettings{LeafCompression: storagepb.LeafCompression_SNAPPY})
+	if err != nil {
+		t.Fatalf("MarshalAny(): %v", err)
+	}
+	trees := []*trillian.Tree{
+		{
+			TreeId:      1,
+			TreeState:   trillian.TreeState_FROZEN,
+			TreeType:    trillian.TreeType_LOG,
+			DisplayName: "log",
+			PrivateKey:  key,
+			PublicKey:   &keyspb.PublicKey{Der: []byte("public")},
+			CreateTime:  ptypes.TimestampNow(),
+		},
+		{
+			TreeId:          2,
+			TreeState:       trillian.TreeState_ACTIVE,
+			TreeType:        trillian.TreeType_MAP,
+			Description:     "map\nwith a description",
+			StorageSettings: settings,
+		},
+	}
+	s.Admin.EXPECT().ExportTrees(gomock.Any(), &trillian.ExportTreesRequest{TreeId: []int64{1, 2}}).Return(&trillian.ExportTreesResponse{Tree: trees}, nil)
+
+	var exported bytes.Buffer
+	if err := run(ctx, []string{"trees", "export"}, nil, &exported); err != nil {
+		t.Fatalf("trees export: %v", err)
+	}
+	if !strings.Contains(exported.String(), "display_name: log") {
+		t.Errorf("trees export wrote:\n%s\nwant display_name: log", exported.String())
+	}
+
+	s.Admin.EXPECT().ImportTrees(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, req *trillian.ImportTreesRequest) (*trillian.ImportTreesResponse, error) {
+		if len(req.Tree) != len(trees) {
+			t.Fatalf("ImportTrees() got %d trees, want %d", len(req.Tree), len(trees))
+		}
+		for i := range trees {
+			if !proto.Equal(req.Tree[i], trees[i]) {
+				t.Errorf("ImportTrees() tree %d = %v, want %v", i, req.Tree[i], trees[i])
+			}
+		}
+		return &trillian.ImportTreesResponse{Tree: []*trillian.Tree{{TreeId: 10}, {TreeId: 11}}}, nil
+	})
+	var out bytes.Buffer
+	if err := run(ctx, []string{"trees", "import"}, &exported, &out); err != nil {
+		t.Fatalf("trees import: %v", err)
+	}
+	if got, want := out.String(), "10\n11\n"; got != want {
+		t.Errorf("trees import wrote %q, want %q", got, want)
+	}
+}
+
+func TestExportImportMap(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	defer flagsaver.Save().MustRestore()
+
+	s, stopFakeServer, err := testonly.NewMockServer(ctrl)
+	if err != nil {
+		t.Fatalf("Error starting fake server: %v", err)
+	}
+	defer stopFakeServer()
+	*adminServerAddr = s.Addr
+	*mapID = 1
+
+	root := func(rev uint64) *trillian.SignedMapRoot {
+		data, err := (&types.MapRootV1{Revision: rev, RootHash: []byte{byte(rev)}}).MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary(): %v", err)
+		}
+		return &trillian.SignedMapRoot{MapRoot: data}
+	}
+	leaf := func(b byte) *trillian.MapLeaf { return &trillian.MapLeaf{Index: []byte{b}, LeafValue: []byte{b}} }
+	resps := []*trillian.ExportMapResponse{
+		{MapRoots: []*trillian.SignedMapRoot{root(0), root(1), root(2)}},
+		{LeafVersions: []*trillian.MapLeafVersion{{Revision: 1, Leaf: leaf(1)}, {Revision: 2, Leaf: leaf(1)}}},
+		{LeafVersions: []*trillian.MapLeafVersion{{Revision: 2, Leaf: leaf(2)}}},
+	}
+	s.Map.EXPECT().GetSignedMapRoot(gomock.Any(), gomock.Any()).Return(&trillian.GetSignedMapRootResponse{MapRoot: root(2)}, nil)
+	s.Map.EXPECT().ExportMap(&trillian.ExportMapRequest{MapId: 1, Revision: 2}, gomock.Any()).DoAndReturn(
+		func(req *trillian.ExportMapRequest, stream trillian.TrillianMap_ExportMapServer) error {
+			for _, resp := range resps {
+				if err := stream.Send(resp); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	var exported bytes.Buffer
+	if err := run(ctx, []string{"maps", "export"}, nil, &exported); err != nil {
+		t.Fatalf("maps export: %v", err)
+	}
+
+	wantLeaves := [][]*trillian.MapLeaf{nil, {leaf(1)}, {leaf(1), leaf(2)}}
+	for rev, want := range wantLeaves {
+		want := &trillian.ImportMapRevisionRequest{MapId: 1, MapRoot: root(uint64(rev)), Leaves: want}
+		s.Map.EXPECT().ImportMapRevision(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, req *trillian.ImportMapRevisionRequest) (*trillian.ImportMapRevisionResponse, error) {
+				if !proto.Equal(req, want) {
+					t.Errorf("ImportMapRevision(%v), want %v", req, want)
+				}
+				return &trillian.ImportMapRevisionResponse{MapRoot: req.MapRoot}, nil
+			})
+	}
+	var out bytes.Buffer
+	if err := run(ctx, []string{"maps", "import"}, &exported, &out); err != nil {
+		t.Fatalf("maps import: %v", err)
+	}
+	if got, want := out.String(), "0\n1\n2\n"; got != want {
+		t.Errorf("maps import wrote %q, want %q", got, want)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	ctx := context.Background()
+	defer flagsaver.Save().MustRestore()
+
+	for _, test := range []struct {
+		desc  string
+		args  []string
+		addr  string
+		trees string
+	}{
+		{desc: "noCommand", addr: "localhost:1"},
+		{desc: "unknownCommand", args: []string{"trees", "list"}, addr: "localhost:1"},
+		{desc: "noAdminServer", args: []string{"trees", "export"}},
+		{desc: "badTreeIDs", args: []string{"trees", "export"}, addr: "localhost:1", trees: "1,x"},
+		{desc: "noMapID", args: []string{"maps", "export"}, addr: "localhost:1"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			*adminServerAddr = test.addr
+			*treeIDs = test.trees
+			if err := run(ctx, test.args, nil, &bytes.Buffer{}); err == nil {
+				t.Error("run() returned nil error")
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/client/treeconfig"
+	"github.com/google/trillian/crypto/sigpb"
+	"google.golang.org/grpc"
+)
+
+// bootstrapTimeout bounds waiting for the server to start, and creating the
+// demo trees.
+const bootstrapTimeout = time.Minute
+
+// bootstrapTrees waits for the server at endpoint to start and, if it has no
+// trees, creates a demo log and, if withMap is set, a demo map.
+func bootstrapTrees(ctx context.Context, endpoint string, withMap bool) error {
+	ctx, cancel := context.WithTimeout(ctx, bootstrapTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, endpoint, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return fmt.Errorf("failed to dial %v: %v", endpoint, err)
+	}
+	defer conn.Close()
+
+	trees, err := createDemoTrees(ctx, trillian.NewTrillianAdminClient(conn), trillian.NewTrillianLogClient(conn), trillian.NewTrillianMapClient(conn), withMap)
+	if err != nil {
+		return err
+	}
+	printDemoTrees(os.Stdout, endpoint, trees)
+	return nil
+}
+
+// createDemoTrees creates and initializes the demo trees, unless there are
+// trees already, and returns the trees it created.
+func createDemoTrees(ctx context.Context, admin trillian.TrillianAdminClient, logClient trillian.TrillianLogClient, mapClient trillian.TrillianMapClient, withMap bool) ([]*trillian.Tree, error) {
+	resp, err := admin.ListTrees(ctx, &trillian.ListTreesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("ListTrees(): %v", err)
+	}
+	if n := len(resp.Tree); n > 0 {
+		glog.Infof("Not creating demo trees: %d trees exist", n)
+		return nil, nil
+	}
+
+	builders := []*treeconfig.Builder{
+		treeconfig.NewLogTree().WithDisplayName("demo log"),
+	}
+	if withMap {
+		builders = append(builders, treeconfig.NewMapTree().WithDisplayName("demo map"))
+	}
+	var created []*trillian.Tree
+	for _, b := range builders {
+		req, err := b.WithSigner(sigpb.DigitallySigned_ECDSA).Build()
+		if err != nil {
+			return created, err
+		}
+		tree, err := client.CreateAndInitTree(ctx, req, admin, mapClient, logClient)
+		if err != nil {
+			return created, fmt.Errorf("failed to create %s: %v", req.Tree.DisplayName, err)
+		}
+		created = append(created, tree)
+	}
+	return created, nil
+}
+
+// printDemoTrees tells the user how to reach the demo trees.
+func printDemoTrees(w io.Writer, endpoint string, trees []*trillian.Tree) {
+	for _, tree := range trees {
+		fmt.Fprintf(w, "Created %s %d, served at %s\n", tree.DisplayName, tree.TreeId, endpoint)
+	}
+	if len(trees) > 0 {
+		fmt.Fprintf(w, "List all trees with: trillctl --admin_server=%s trees export\n", endpoint)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keys/der"
+	"github.com/google/trillian/crypto/keyspb"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/server"
+	"github.com/google/trillian/server/admin"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/testonly/integration"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc"
+)
+
+// newKeyProto creates the private keys of the trees created by tests.
+func newKeyProto(ctx context.Context, spec *keyspb.Specification) (proto.Message, error) {
+	return der.NewProtoFromSpec(spec)
+}
+
+// serveForTests serves the admin and log APIs, and the map API if withMap is
+// set, of registry on a local port, and returns a connection to them. The
+// returned function stops the server.
+func serveForTests(t *testing.T, registry extension.Registry, withMap bool) (*grpc.ClientConn, func()) {
+	t.Helper()
+	s := grpc.NewServer()
+	trillian.RegisterTrillianAdminServer(s, admin.New(registry, nil))
+	trillian.RegisterTrillianLogServer(s, server.NewTrillianLogRPCServer(registry, clock.System))
+	if withMap {
+		trillian.RegisterTrillianMapServer(s, server.NewTrillianMapServer(registry, server.TrillianMapServerOptions{UseSingleTransaction: true}))
+	}
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen(): %v", err)
+	}
+	go s.Serve(lis)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		s.Stop()
+		t.Fatalf("Dial(): %v", err)
+	}
+	return conn, func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func TestCreateDemoTrees(t *testing.T) {
+	ctx := context.Background()
+	ts := memory.NewTreeStorage()
+	registry := extension.Registry{
+		AdminStorage:  memory.NewAdminStorage(ts),
+		LogStorage:    memory.NewLogStorage(ts, nil),
+		MetricFactory: monitoring.InertMetricFactory{},
+		NewKeyProto:   newKeyProto,
+	}
+	conn, stop := serveForTests(t, registry, false)
+	defer stop()
+	adminClient := trillian.NewTrillianAdminClient(conn)
+	logClient := trillian.NewTrillianLogClient(conn)
+
+	trees, err := createDemoTrees(ctx, adminClient, logClient, trillian.NewTrillianMapClient(conn), false)
+	if err != nil {
+		t.Fatalf("createDemoTrees(): %v", err)
+	}
+	if len(trees) != 1 || trees[0].TreeType != trillian.TreeType_LOG {
+		t.Fatalf("createDemoTrees(): %v, want one log", trees)
+	}
+	if _, err := logClient.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: trees[0].TreeId}); err != nil {
+		t.Errorf("GetLatestSignedLogRoot() of demo log: %v", err)
+	}
+	var out bytes.Buffer
+	printDemoTrees(&out, "localhost:8090", trees)
+	if got, want := out.String(), "Created demo log"; !strings.HasPrefix(got, want) {
+		t.Errorf("printDemoTrees(): %q, want prefix %q", got, want)
+	}
+
+	// Later runs keep the existing trees.
+	trees, err = createDemoTrees(ctx, adminClient, logClient, trillian.NewTrillianMapClient(conn), false)
+	if err != nil || len(trees) != 0 {
+		t.Errorf("createDemoTrees() again: %v, %v, want no trees", trees, err)
+	}
+}
+
+func TestCreateDemoTreesSQLite(t *testing.T) {
+	ctx := context.Background()
+	registry, done, err := integration.NewSQLiteRegistryForTests(ctx)
+	if err != nil {
+		t.Fatalf("NewSQLiteRegistryForTests(): %v", err)
+	}
+	defer done(ctx)
+	registry.MetricFactory = monitoring.InertMetricFactory{}
+	registry.NewKeyProto = newKeyProto
+	conn, stop := serveForTests(t, registry, true)
+	defer stop()
+	adminClient := trillian.NewTrillianAdminClient(conn)
+	logClient := trillian.NewTrillianLogClient(conn)
+	mapClient := trillian.NewTrillianMapClient(conn)
+
+	trees, err := createDemoTrees(ctx, adminClient, logClient, mapClient, true)
+	if err != nil {
+		t.Fatalf("createDemoTrees(): %v", err)
+	}
+	if len(trees) != 2 || trees[0].TreeType != trillian.TreeType_LOG || trees[1].TreeType != trillian.TreeType_MAP {
+		t.Fatalf("createDemoTrees(): %v, want a log and a map", trees)
+	}
+	if _, err := logClient.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: trees[0].TreeId}); err != nil {
+		t.Errorf("GetLatestSignedLogRoot() of demo log: %v", err)
+	}
+	if _, err := mapClient.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{MapId: trees[1].TreeId}); err != nil {
+		t.Errorf("GetSignedMapRoot() of demo map: %v", err)
+	}
+
+	// Later runs keep the existing trees.
+	trees, err = createDemoTrees(ctx, adminClient, logClient, mapClient, true)
+	if err != nil || len(trees) != 0 {
+		t.Errorf("createDemoTrees() again: %v, %v, want no trees", trees, err)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The trillian_lite binary runs the Trillian admin and log servers, the log
+// signer and, optionally, the map server in one process behind one port, for
+// demos and embedded use.
+//
+// Example usage:
+// $ ./trillian_lite --serve_maps
+// $ ./trillian_lite --sqlite_file=/var/lib/trillian/trillian.db
+// $ ./trillian_lite --storage_system=memory
+// $ ./trillian_lite --storage_system=mysql --mysql_uri=... --serve_maps
+//
+// By default, trees are stored in the SQLite file trillian_lite.db in the
+// working directory, which needs no database server, and the first run creates
+// a demo log whose ID is printed to stdout. Trees stored in memory are lost
+// when the process exits, and memory storage has no maps.
+package main
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/cmd"
+	"github.com/google/trillian/crypto/keys/der"
+	"github.com/google/trillian/crypto/keyspb"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/monitoring/prometheus"
+	"github.com/google/trillian/server"
+	"github.com/google/trillian/util/clock"
+	"github.com/google/trillian/util/election"
+	"github.com/google/trillian/util/election2"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/grpc"
+
+	// Register key ProtoHandlers
+	_ "github.com/google/trillian/crypto/keys/der/proto"
+	_ "github.com/google/trillian/crypto/keys/pem/proto"
+
+	// Load hashers
+	_ "github.com/google/trillian/merkle/coniks"
+	_ "github.com/google/trillian/merkle/maphasher"
+	_ "github.com/google/trillian/merkle/rfc6962"
+)
+
+var (
+	rpcEndpoint       = flag.String("rpc_endpoint", "localhost:8090", "Endpoint for RPC requests (host:port)")
+	httpEndpoint      = flag.String("http_endpoint", "", "Endpoint for HTTP metrics and REST requests on (host:port, empty means disabled)")
+	healthzTimeout    = flag.Duration("healthz_timeout", time.Second*5, "Timeout used during healthz checks")
+	serveMaps         = flag.Bool("serve_maps", false, "If true, the map APIs are also served. Requires a storage system with maps, e.g. sqlite or mysql")
+	bootstrap         = flag.Bool("bootstrap", true, "If true and there are no trees, a demo log, and a demo map with --serve_maps, are created at startup and their IDs printed to stdout")
+	sequencerInterval = flag.Duration("sequencer_interval", 100*time.Millisecond, "Time between each sequencing pass through all logs")
+	batchSize         = flag.Int("batch_size", 1000, "Max number of leaves to process per batch")
+	numSequencers     = flag.Int("num_sequencers", 2, "Number of sequencer workers to run in parallel")
+
+	configFile = flag.String("config", "", "Config file containing flags, file contents can be overridden by command line flags")
+)
+
+// liteDefaults are the defaults of flags registered by other packages which
+// let trillian_lite run without a database server.
+var liteDefaults = map[string]string{
+	"storage_system": "sqlite",
+	"sqlite_file":    "trillian_lite.db",
+	"quota_system":   server.QuotaNoop,
+}
+
+func main() {
+	for name, value := range liteDefaults {
+		f := flag.Lookup(name)
+		if err := f.Value.Set(value); err != nil {
+			glog.Exitf("Failed to default --%s to %q: %v", name, value, err)
+		}
+		f.DefValue = value
+	}
+	flag.Parse()
+	defer glog.Flush()
+
+	if *configFile != "" {
+		if err := cmd.ParseFlagFile(*configFile); err != nil {
+			glog.Exitf("Failed to load flags from config file %q: %s", *configFile, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mf := prometheus.MetricFactory{}
+
+	sp, err := server.NewStorageProviderFromFlags(mf)
+	if err != nil {
+		glog.Exitf("Failed to get storage provider: %v", err)
+	}
+	defer sp.Close()
+
+	qm, err := server.NewQuotaManagerFromFlags()
+	if err != nil {
+		glog.Exitf("Error creating quota manager: %v", err)
+	}
+
+	// All the components share one registry, so that in-memory trees are
+	// visible to each of them.
+	registry := extension.Registry{
+		AdminStorage:    sp.AdminStorage(),
+		LogStorage:      sp.LogStorage(),
+		ElectionFactory: election2.NoopFactory{},
+		QuotaManager:    qm,
+		MetricFactory:   mf,
+		NewKeyProto: func(ctx context.Context, spec *keyspb.Specification) (proto.Message, error) {
+			return der.NewProtoFromSpec(spec)
+		},
+	}
+	treeTypes := []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG}
+	var mapServer *server.TrillianMapServer
+	if *serveMaps {
+		if sp.MapStorage() == nil {
+			glog.Exitf("--serve_maps requires a storage system with map storage, not %v", flag.Lookup("storage_system").Value)
+		}
+		registry.MapStorage = sp.MapStorage()
+		treeTypes = append(treeTypes, trillian.TreeType_MAP)
+		mapServer = server.NewTrillianMapServer(registry, server.TrillianMapServerOptions{UseSingleTransaction: true})
+	}
+
+	// This process is the only signer, so it is master for all logs.
+	sequencerTask := log.NewOperationManager(log.OperationInfo{
+		Registry:    registry,
+		BatchSize:   *batchSize,
+		NumWorkers:  *numSequencers,
+		RunInterval: *sequencerInterval,
+		TimeSource:  clock.System,
+		ElectionConfig: election.RunnerConfig{
+			TimeSource: clock.System,
+		},
+	}, log.NewSequencerManager(registry, 0))
+	go sequencerTask.OperationLoop(ctx)
+
+	if *bootstrap {
+		go func() {
+			if err := bootstrapTrees(ctx, *rpcEndpoint, mapServer != nil); err != nil {
+				glog.Errorf("Failed to create demo trees: %v", err)
+			}
+		}()
+	}
+
+	m := server.Main{
+		RPCEndpoint:  *rpcEndpoint,
+		HTTPEndpoint: *httpEndpoint,
+		StatsPrefix:  "lite",
+		DBClose:      sp.Close,
+		Registry:     registry,
+		RegisterHandlerFn: func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error {
+			if err := trillian.RegisterTrillianLogHandlerFromEndpoint(ctx, mux, endpoint, opts); err != nil {
+				return err
+			}
+			if mapServer != nil {
+				return trillian.RegisterTrillianMapHandlerFromEndpoint(ctx, mux, endpoint, opts)
+			}
+			return nil
+		},
+		RegisterServerFn: func(s *grpc.Server, registry extension.Registry) error {
+			logServer := server.NewTrillianLogRPCServer(registry, clock.System)
+			trillian.RegisterTrillianLogServer(s, logServer)
+			go func() {
+				if err := logServer.LoadFrozenLogs(context.Background()); err != nil {
+					glog.Errorf("Failed to load frozen logs: %v", err)
+				}
+			}()
+			if mapServer != nil {
+				trillian.RegisterTrillianMapServer(s, mapServer)
+				trillian.RegisterTrillianMapWriteServer(s, server.NewTrillianMapWriteServer(registry, mapServer))
+			}
+			return nil
+		},
+		IsHealthy:        sp.AdminStorage().CheckDatabaseAccessible,
+		HealthyDeadline:  *healthzTimeout,
+		AllowedTreeTypes: treeTypes,
+	}
+
+	if err := m.Run(ctx); err != nil {
+		glog.Exitf("Server exited with error: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The trillian_migrate binary upgrades and downgrades the schema of the
+// database of a SQL storage system in place, so that schema changes don't have
+// to be applied by hand.
+//
+// Example usage:
+// $ ./trillian_migrate --storage_system=mysql --mysql_uri=... status
+// $ ./trillian_migrate --storage_system=mysql --mysql_uri=... baseline
+// $ ./trillian_migrate --storage_system=mysql --mysql_uri=... up
+// $ ./trillian_migrate --storage_system=postgres --pg_conn_str=... --target=1 down
+//
+// status prints the schema version of the database, and the latest version
+// known to this binary. up and down migrate the schema to --target, which
+// defaults to the latest version. baseline records --target as the version of
+// a database created before schemas were versioned, whose schema must already
+// be at that version; it defaults to 1, the schema of releases up to v1.3.2.
+// Databases created from the storage.sql of a later, unversioned build are at
+// version 2.
+//
+// The servers check the schema version at startup, so they should be stopped
+// while the schema is migrated, and upgraded before it is downgraded.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage/migrate"
+	"github.com/google/trillian/storage/mysql"
+	"github.com/google/trillian/storage/postgres"
+	"github.com/google/trillian/storage/sqlite"
+)
+
+var (
+	storageSystem = flag.String("storage_system", "mysql", "Storage system whose schema is migrated. One of: mysql, postgres, crdb, sqlite")
+	mySQLURI      = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test", "Connection URI for MySQL database")
+	pgConnStr     = flag.String("pg_conn_str", "user=postgres dbname=test port=5432 sslmode=disable", "Connection string for Postgres database")
+	crdbConnStr   = flag.String("crdb_conn_str", "postgresql://root@localhost:26257/test?sslmode=disable", "Connection string for CockroachDB database")
+	sqliteFile    = flag.String("sqlite_file", "trillian.db", "File holding the SQLite database")
+	target        = flag.Int("target", 0, "Schema version to migrate to, or to baseline at. If zero, the latest version for up, and 1 for baseline")
+)
+
+var commands = map[string]bool{"status": true, "up": true, "down": true, "baseline": true}
+
+// openDB opens the database of the storage system, and returns it with the
+// history of its schema.
+func openDB() (*sql.DB, migrate.Schema, error) {
+	var db *sql.DB
+	var schema migrate.Schema
+	var err error
+	switch *storageSystem {
+	case "mysql":
+		db, err = mysql.OpenDB(*mySQLURI)
+		schema = mysql.Schema
+	case "postgres":
+		db, err = postgres.OpenDB(*pgConnStr)
+		schema = postgres.Schema
+	case "crdb":
+		db, err = postgres.OpenDB(*crdbConnStr)
+		schema = postgres.Schema
+	case "sqlite":
+		// Unlike sqlite.OpenDB, this doesn't create missing tables, which
+		// would baseline unversioned databases behind the operator's back.
+		// Foreign keys aren't enforced, as they can't be turned off within
+		// the transaction of a step, and rebuilding the Trees table would
+		// otherwise delete the rows of every table referencing it.
+		db, err = sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=rw&_foreign_keys=off", *sqliteFile))
+		schema = sqlite.Schema
+	default:
+		return nil, migrate.Schema{}, fmt.Errorf("unsupported storage system %q", *storageSystem)
+	}
+	return db, schema, err
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+	if flag.NArg() != 1 || !commands[flag.Arg(0)] {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] status|up|down|baseline\n", os.Args[0])
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	db, schema, err := openDB()
+	if err != nil {
+		glog.Exitf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	switch flag.Arg(0) {
+	case "status":
+		version, err := schema.Version(ctx, db)
+		switch err {
+		case nil:
+			fmt.Printf("Schema version: %d\n", version)
+		case migrate.ErrUnversioned:
+			fmt.Println("Schema version: unversioned")
+		default:
+			glog.Exitf("Failed to read schema version: %v", err)
+		}
+		fmt.Printf("Latest version: %d\n", schema.Latest())
+	case "up":
+		if *target == 0 {
+			*target = schema.Latest()
+		}
+		if err := schema.Up(ctx, db, *target); err != nil {
+			glog.Exitf("Failed to upgrade schema: %v", err)
+		}
+	case "down":
+		if err := schema.Down(ctx, db, *target); err != nil {
+			glog.Exitf("Failed to downgrade schema: %v", err)
+		}
+	case "baseline":
+		if *target == 0 {
+			*target = 1
+		}
+		if err := schema.Baseline(ctx, db, *target); err != nil {
+			glog.Exitf("Failed to baseline schema: %v", err)
+		}
+	}
+}
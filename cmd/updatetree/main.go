@@ -98,7 +98,7 @@ func updateTree(ctx context.Context) (*trillian.Tree, error) {
 		return nil, fmt.Errorf("failed to determine dial options: %v", err)
 	}
 
-	conn, err := grpc.Dial(*adminServerAddr, dialOpts...)
+	conn, err := grpc.Dial(rpcflags.DialTarget(*adminServerAddr), dialOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to dial %v: %v", *adminServerAddr, err)
 	}
@@ -18,6 +18,7 @@ package crypto
 import (
 	"crypto"
 	"crypto/rand"
+	"fmt"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian"
@@ -34,6 +35,16 @@ type Signer struct {
 	// If Hash is noHash (zero), the signer expects to be given the full message not a hashed digest.
 	Hash   crypto.Hash
 	Signer crypto.Signer
+	// Cosigners sign roots along with Signer, and their signatures go into
+	// the additional signatures of the roots. They use the same Hash.
+	Cosigners []crypto.Signer
+	// Threshold is the number of keys, among Signer and Cosigners, which
+	// must sign roots. Zero means all of them. Signer must always sign.
+	Threshold int
+	// NextSigner, if set, is the key which replaces Signer at the end of a
+	// key rotation. It must sign roots too, and its signature is appended to
+	// the additional signatures.
+	NextSigner crypto.Signer
 }
 
 // NewSigner returns a new signer. The signer will set the KeyHint field, when available, with KeyID.
@@ -76,22 +87,76 @@ func (s *Signer) Sign(data []byte) ([]byte, error) {
 	return s.Signer.Sign(rand.Reader, digest, s.Hash)
 }
 
+// signRoot signs a root with Signer and the Cosigners, and returns the
+// signature of Signer and the additional signatures of the Cosigners. The
+// signatures of the Cosigners which fail are empty, as long as Signer and at
+// least Threshold keys in all succeed. The signature of NextSigner, if any,
+// ends the additional signatures.
+func (s *Signer) signRoot(root []byte) ([]byte, [][]byte, error) {
+	signature, additional, err := s.cosignRoot(root)
+	if err != nil || s.NextSigner == nil {
+		return signature, additional, err
+	}
+	next := &Signer{Hash: s.Hash, Signer: s.NextSigner}
+	nextSignature, err := next.Sign(root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("next key failed to sign the root: %v", err)
+	}
+	return signature, append(additional, nextSignature), nil
+}
+
+// cosignRoot signs a root with Signer and the Cosigners, as signRoot.
+func (s *Signer) cosignRoot(root []byte) ([]byte, [][]byte, error) {
+	if len(s.Cosigners) == 0 {
+		signature, err := s.Sign(root)
+		return signature, nil, err
+	}
+
+	threshold := s.Threshold
+	if threshold <= 0 {
+		threshold = len(s.Cosigners) + 1
+	}
+	// Roots are identified by the signature of Signer, so it can't be left
+	// empty whatever the threshold.
+	signature, err := s.Sign(root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signer failed to sign the root: %v", err)
+	}
+	signed := 1
+	var lastErr error
+	additional := make([][]byte, len(s.Cosigners))
+	for i, cosigner := range s.Cosigners {
+		c := &Signer{Hash: s.Hash, Signer: cosigner}
+		if additional[i], err = c.Sign(root); err != nil {
+			glog.Warningf("%v: cosigner %d failed to sign root: %v", s.KeyHint, i, err)
+			lastErr = err
+			continue
+		}
+		signed++
+	}
+	if signed < threshold {
+		return nil, nil, fmt.Errorf("%d of %d keys signed the root, need %d: %v", signed, len(s.Cosigners)+1, threshold, lastErr)
+	}
+	return signature, additional, nil
+}
+
 // SignLogRoot returns a complete SignedLogRoot (including signature).
 func (s *Signer) SignLogRoot(r *types.LogRootV1) (*trillian.SignedLogRoot, error) {
 	logRoot, err := r.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
-	signature, err := s.Sign(logRoot)
+	signature, additional, err := s.signRoot(logRoot)
 	if err != nil {
 		glog.Warningf("%v: signer failed to sign log root: %v", s.KeyHint, err)
 		return nil, err
 	}
 
 	return &trillian.SignedLogRoot{
-		KeyHint:          s.KeyHint,
-		LogRoot:          logRoot,
-		LogRootSignature: signature,
+		KeyHint:              s.KeyHint,
+		LogRoot:              logRoot,
+		LogRootSignature:     signature,
+		AdditionalSignatures: additional,
 	}, nil
 }
 
@@ -102,14 +167,15 @@ func (s *Signer) SignMapRoot(r *types.MapRootV1) (*trillian.SignedMapRoot, error
 		return nil, err
 	}
 
-	signature, err := s.Sign(rootBytes)
+	signature, additional, err := s.signRoot(rootBytes)
 	if err != nil {
 		glog.Warningf("%v: signer failed to sign map root: %v", s.KeyHint, err)
 		return nil, err
 	}
 
 	return &trillian.SignedMapRoot{
-		MapRoot:   rootBytes,
-		Signature: signature,
+		MapRoot:              rootBytes,
+		Signature:            signature,
+		AdditionalSignatures: additional,
 	}, nil
 }
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
 	"github.com/google/trillian/crypto/keys/pem"
 	"github.com/google/trillian/testonly"
 	"github.com/google/trillian/types"
@@ -113,6 +114,149 @@ func TestSignLogRoot(t *testing.T) {
 	}
 }
 
+func TestSignLogRoot_Cosigners(t *testing.T) {
+	key, err := pem.UnmarshalPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("Failed to open test key, err=%v", err)
+	}
+	key2, err := pem.UnmarshalPrivateKey(privPEM, "")
+	if err != nil {
+		t.Fatalf("Failed to open test key, err=%v", err)
+	}
+	failing := testonly.NewSignerWithErr(key2, errors.New("signfail"))
+	pubs := []crypto.PublicKey{key.Public(), key2.Public(), key2.Public()}
+	root := &types.LogRootV1{TimestampNanos: 2267709, RootHash: []byte("Islington"), TreeSize: 2}
+
+	for _, test := range []struct {
+		desc      string
+		primary   crypto.Signer
+		cosigners []crypto.Signer
+		threshold int
+		wantErr   bool
+		wantEmpty []bool
+	}{
+		{desc: "all", cosigners: []crypto.Signer{key2, key2}, wantEmpty: []bool{false, false}},
+		{desc: "one-fails", cosigners: []crypto.Signer{key2, failing}, threshold: 2, wantEmpty: []bool{false, true}},
+		{desc: "one-fails-all-needed", cosigners: []crypto.Signer{key2, failing}, wantErr: true},
+		{desc: "too-many-fail", cosigners: []crypto.Signer{failing, failing}, threshold: 2, wantErr: true},
+		{desc: "primary-fails", primary: testonly.NewSignerWithErr(key, errors.New("signfail")), cosigners: []crypto.Signer{key2, key2}, threshold: 2, wantErr: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			signer := NewSigner(0, key, crypto.SHA256)
+			if test.primary != nil {
+				signer.Signer = test.primary
+			}
+			signer.Cosigners = test.cosigners
+			signer.Threshold = test.threshold
+
+			slr, err := signer.SignLogRoot(root)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("SignLogRoot()=%v, want err? %t", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got, want := len(slr.AdditionalSignatures), len(test.wantEmpty); got != want {
+				t.Fatalf("got %d additional signatures, want %d", got, want)
+			}
+			for i, wantEmpty := range test.wantEmpty {
+				if gotEmpty := len(slr.AdditionalSignatures[i]) == 0; gotEmpty != wantEmpty {
+					t.Errorf("AdditionalSignatures[%d] empty: %t, want %t", i, gotEmpty, wantEmpty)
+				}
+			}
+			if _, err := VerifySignedLogRootThreshold(pubs, test.threshold, crypto.SHA256, slr); err != nil {
+				t.Errorf("VerifySignedLogRootThreshold(%d)=%v", test.threshold, err)
+			}
+			if test.threshold != 0 {
+				if _, err := VerifySignedLogRootThreshold(pubs, 0, crypto.SHA256, slr); err == nil {
+					t.Error("VerifySignedLogRootThreshold(0)=nil, want err")
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyRootThreshold_Primary(t *testing.T) {
+	key, err := pem.UnmarshalPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("Failed to open test key, err=%v", err)
+	}
+	key2, err := pem.UnmarshalPrivateKey(privPEM, "")
+	if err != nil {
+		t.Fatalf("Failed to open test key, err=%v", err)
+	}
+	signer := NewSigner(0, key, crypto.SHA256)
+	signer.Cosigners = []crypto.Signer{key2, key2}
+	pubs := []crypto.PublicKey{key.Public(), key2.Public(), key2.Public()}
+	slr, err := signer.SignLogRoot(&types.LogRootV1{TimestampNanos: 2267709, RootHash: []byte("Islington"), TreeSize: 2})
+	if err != nil {
+		t.Fatalf("SignLogRoot()=%v", err)
+	}
+	smr, err := signer.SignMapRoot(&types.MapRootV1{TimestampNanos: 2267709, RootHash: []byte("Islington"), Revision: 2})
+	if err != nil {
+		t.Fatalf("SignMapRoot()=%v", err)
+	}
+
+	for _, test := range []struct {
+		desc string
+		// primary returns the primary signature to verify in place of sig.
+		primary func(sig []byte) []byte
+		wantErr bool
+	}{
+		{desc: "valid", primary: func(sig []byte) []byte { return sig }},
+		{desc: "empty", primary: func([]byte) []byte { return nil }, wantErr: true},
+		{desc: "invalid", primary: func(sig []byte) []byte {
+			bad := append([]byte(nil), sig...)
+			bad[len(bad)-1] ^= 1
+			return bad
+		}, wantErr: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			// The cosignatures alone meet the threshold.
+			badSLR := &trillian.SignedLogRoot{LogRoot: slr.LogRoot, LogRootSignature: test.primary(slr.LogRootSignature), AdditionalSignatures: slr.AdditionalSignatures}
+			if _, err := VerifySignedLogRootThreshold(pubs, 2, crypto.SHA256, badSLR); (err != nil) != test.wantErr {
+				t.Errorf("VerifySignedLogRootThreshold(2)=%v, want err? %t", err, test.wantErr)
+			}
+			badSMR := &trillian.SignedMapRoot{MapRoot: smr.MapRoot, Signature: test.primary(smr.Signature), AdditionalSignatures: smr.AdditionalSignatures}
+			if _, err := VerifySignedMapRootThreshold(pubs, 2, crypto.SHA256, badSMR); (err != nil) != test.wantErr {
+				t.Errorf("VerifySignedMapRootThreshold(2)=%v, want err? %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignLogRoot_NextSigner(t *testing.T) {
+	key, err := pem.UnmarshalPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("Failed to open test key, err=%v", err)
+	}
+	next, err := pem.UnmarshalPrivateKey(privPEM, "")
+	if err != nil {
+		t.Fatalf("Failed to open test key, err=%v", err)
+	}
+	root := &types.LogRootV1{TimestampNanos: 2267709, RootHash: []byte("Islington"), TreeSize: 2}
+
+	signer := NewSigner(0, key, crypto.SHA256)
+	signer.Cosigners = []crypto.Signer{key}
+	signer.NextSigner = next
+	slr, err := signer.SignLogRoot(root)
+	if err != nil {
+		t.Fatalf("SignLogRoot()=%v", err)
+	}
+	if got, want := len(slr.AdditionalSignatures), 2; got != want {
+		t.Fatalf("got %d additional signatures, want %d", got, want)
+	}
+	pubs := []crypto.PublicKey{key.Public(), key.Public(), next.Public()}
+	if _, err := VerifySignedLogRootThreshold(pubs, 0, crypto.SHA256, slr); err != nil {
+		t.Errorf("VerifySignedLogRootThreshold()=%v", err)
+	}
+
+	// Roots aren't signed unless the next key signs them too.
+	signer.NextSigner = testonly.NewSignerWithErr(next, errors.New("signfail"))
+	_, err = signer.SignLogRoot(root)
+	testonly.EnsureErrorContains(t, err, "signfail")
+}
+
 func TestSignMapRoot(t *testing.T) {
 	key, err := pem.UnmarshalPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
 	if err != nil {
@@ -60,6 +60,69 @@ func VerifySignedMapRoot(pub crypto.PublicKey, hash crypto.Hash, smr *trillian.S
 	return &root, nil
 }
 
+// VerifySignedLogRootThreshold verifies that at least threshold of the
+// signatures of the SignedLogRoot are valid, and returns its contents. The
+// first of pubs verifies its log_root_signature, which is always required,
+// and the others its additional_signatures, in order. The threshold counts the
+// log_root_signature, and a threshold of zero requires all of them.
+func VerifySignedLogRootThreshold(pubs []crypto.PublicKey, threshold int, hash crypto.Hash, r *trillian.SignedLogRoot) (*types.LogRootV1, error) {
+	if err := verifyThreshold(pubs, threshold, hash, r.LogRoot, r.LogRootSignature, r.AdditionalSignatures); err != nil {
+		return nil, err
+	}
+
+	var logRoot types.LogRootV1
+	if err := logRoot.UnmarshalBinary(r.LogRoot); err != nil {
+		return nil, err
+	}
+	return &logRoot, nil
+}
+
+// VerifySignedMapRootThreshold verifies that at least threshold of the
+// signatures of the SignedMapRoot are valid, and returns its contents, like
+// VerifySignedLogRootThreshold.
+func VerifySignedMapRootThreshold(pubs []crypto.PublicKey, threshold int, hash crypto.Hash, smr *trillian.SignedMapRoot) (*types.MapRootV1, error) {
+	if smr == nil {
+		return nil, errors.New("SignedMapRoot is nil")
+	}
+	if err := verifyThreshold(pubs, threshold, hash, smr.MapRoot, smr.Signature, smr.AdditionalSignatures); err != nil {
+		return nil, err
+	}
+	var root types.MapRootV1
+	if err := root.UnmarshalBinary(smr.MapRoot); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// verifyThreshold verifies that sig is a valid signature over data by the
+// first of pubs, and that at least threshold of sig and additional are valid
+// signatures over data by the corresponding pubs.
+func verifyThreshold(pubs []crypto.PublicKey, threshold int, hash crypto.Hash, data, sig []byte, additional [][]byte) error {
+	if len(pubs) == 0 {
+		return errors.New("no public keys")
+	}
+	if threshold <= 0 {
+		threshold = len(pubs)
+	}
+	if threshold > len(pubs) {
+		return fmt.Errorf("threshold %d exceeds the %d public keys", threshold, len(pubs))
+	}
+	if err := Verify(pubs[0], hash, data, sig); err != nil {
+		return fmt.Errorf("primary signature: %v", err)
+	}
+	sigs := append([][]byte{sig}, additional...)
+	valid := 1
+	for i := 1; i < len(pubs); i++ {
+		if i < len(sigs) && len(sigs[i]) != 0 && Verify(pubs[i], hash, data, sigs[i]) == nil {
+			valid++
+		}
+	}
+	if valid < threshold {
+		return fmt.Errorf("%d of %d signatures verified, need %d: %v", valid, len(pubs), threshold, errVerify)
+	}
+	return nil
+}
+
 // Verify cryptographically verifies the output of Signer.
 func Verify(pub crypto.PublicKey, hasher crypto.Hash, data, sig []byte) error {
 	if sig == nil {
@@ -0,0 +1,278 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main contains the implementation and entry point for the deploy
+// command, which brings up an example Trillian deployment with
+// docker-compose, creates a demo log and a demo map in it, exercises them, and
+// checks their roots. It doubles as a release acceptance test, and exits with
+// an error if any step fails.
+//
+// Example usage, from the root Trillian directory:
+// $ go run ./examples/deploy --alsologtostderr
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/crypto/keyspb"
+	"github.com/google/trillian/crypto/sigpb"
+	"github.com/google/trillian/integration"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/testonly/hammer"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc"
+)
+
+var (
+	composeFile    = flag.String("compose_file", "examples/deploy/docker-compose.yml", "docker-compose file describing the deployment")
+	project        = flag.String("project", "trillian-deploy", "docker-compose project name of the deployment")
+	up             = flag.Bool("up", true, "If true, bring the deployment up before testing it, and down afterwards. If false, test an already running deployment")
+	keep           = flag.Bool("keep", false, "If true, leave the deployment running after testing it, e.g. to explore the demo trees")
+	logServer      = flag.String("log_server", "localhost:8090", "Address of the gRPC Trillian Log Server (host:port)")
+	mapServer      = flag.String("map_server", "localhost:8093", "Address of the gRPC Trillian Map Server (host:port)")
+	mapWriteServer = flag.String("map_write_server", "localhost:8095", "Address of the gRPC Trillian Map Write Server (host:port)")
+	healthzAddrs   = flag.String("healthz_addrs", "localhost:8091,localhost:8092,localhost:8094,localhost:8096", "Comma-separated HTTP addresses of the servers, whose /healthz must report ok before testing starts")
+	mapOperations  = flag.Uint64("map_operations", 200, "Number of operations of the map hammer")
+	seed           = flag.Int64("seed", time.Now().UnixNano(), "Seed of the map hammer")
+	timeout        = flag.Duration("timeout", 15*time.Minute, "Deadline of the whole run, including bringing the deployment up")
+)
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := run(ctx); err != nil {
+		glog.Exit(err)
+	}
+	glog.Info("Deployment verified")
+}
+
+func run(ctx context.Context) (err error) {
+	if *up {
+		if err := compose(ctx, "up", "--build", "-d"); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				glog.Errorf("Deployment failed: %v; server logs follow", err)
+				if err := compose(context.Background(), "logs"); err != nil {
+					glog.Errorf("Failed to get server logs: %v", err)
+				}
+			}
+			if *keep {
+				glog.Infof("Leaving docker-compose project %q running", *project)
+				return
+			}
+			if err := compose(context.Background(), "down"); err != nil {
+				glog.Errorf("Failed to bring the deployment down: %v", err)
+			}
+		}()
+	}
+
+	for _, addr := range strings.Split(*healthzAddrs, ",") {
+		if err := awaitHealthy(ctx, addr); err != nil {
+			return err
+		}
+	}
+
+	logConn, err := grpc.Dial(*logServer, grpc.WithInsecure())
+	if err != nil {
+		return fmt.Errorf("failed to dial %v: %v", *logServer, err)
+	}
+	defer logConn.Close()
+	mapConn, err := grpc.Dial(*mapServer, grpc.WithInsecure())
+	if err != nil {
+		return fmt.Errorf("failed to dial %v: %v", *mapServer, err)
+	}
+	defer mapConn.Close()
+	mapWriteConn, err := grpc.Dial(*mapWriteServer, grpc.WithInsecure())
+	if err != nil {
+		return fmt.Errorf("failed to dial %v: %v", *mapWriteServer, err)
+	}
+	defer mapWriteConn.Close()
+
+	logClient := trillian.NewTrillianLogClient(logConn)
+	logTree, err := client.CreateAndInitTree(ctx, demoTree(trillian.TreeType_LOG, trillian.HashStrategy_RFC6962_SHA256),
+		trillian.NewTrillianAdminClient(logConn), nil, logClient)
+	if err != nil {
+		return fmt.Errorf("failed to create the demo log: %v", err)
+	}
+	glog.Infof("Created demo log %d", logTree.TreeId)
+	mapClient := trillian.NewTrillianMapClient(mapConn)
+	mapTree, err := client.CreateAndInitTree(ctx, demoTree(trillian.TreeType_MAP, trillian.HashStrategy_TEST_MAP_HASHER),
+		trillian.NewTrillianAdminClient(mapConn), mapClient, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create the demo map: %v", err)
+	}
+	glog.Infof("Created demo map %d", mapTree.TreeId)
+
+	params := integration.DefaultTestParameters(logTree.TreeId)
+	if err := integration.RunLogIntegration(logClient, params); err != nil {
+		return fmt.Errorf("log integration test failed: %v", err)
+	}
+	if err := hammer.HitMap(ctx, hammerConfig(mapTree.TreeId, mapClient, trillian.NewTrillianMapWriteClient(mapWriteConn), trillian.NewTrillianAdminClient(mapConn))); err != nil {
+		return fmt.Errorf("map hammer failed: %v", err)
+	}
+
+	return verifyRoots(ctx, logClient, logTree, params.LeafCount, mapClient, mapTree)
+}
+
+// compose runs docker-compose on the deployment with the given arguments.
+func compose(ctx context.Context, args ...string) error {
+	args = append([]string{"-f", *composeFile, "-p", *project}, args...)
+	glog.Infof("Running docker-compose %s", strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, "docker-compose", args...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker-compose %s: %v", strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// awaitHealthy polls the /healthz page of the server serving HTTP at addr
+// until it reports ok, or ctx is done.
+func awaitHealthy(ctx context.Context, addr string) error {
+	url := fmt.Sprintf("http://%s/healthz", addr)
+	for {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err == nil {
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK && strings.TrimSpace(string(body)) == "ok" {
+				glog.Infof("%v is healthy", url)
+				return nil
+			}
+			err = fmt.Errorf("%v: %s", resp.Status, body)
+		}
+		glog.V(1).Infof("%v is not healthy yet: %v", url, err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%v never became healthy: %v", url, err)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// demoTree returns a request to create a demo tree of the given type, whose
+// key is generated by the server.
+func demoTree(treeType trillian.TreeType, hashStrategy trillian.HashStrategy) *trillian.CreateTreeRequest {
+	return &trillian.CreateTreeRequest{
+		Tree: &trillian.Tree{
+			TreeState:          trillian.TreeState_ACTIVE,
+			TreeType:           treeType,
+			HashStrategy:       hashStrategy,
+			HashAlgorithm:      sigpb.DigitallySigned_SHA256,
+			SignatureAlgorithm: sigpb.DigitallySigned_ECDSA,
+			DisplayName:        fmt.Sprintf("deploy-%s", strings.ToLower(treeType.String())),
+			Description:        "Demo tree of the example deployment",
+			MaxRootDuration:    ptypes.DurationProto(time.Hour),
+		},
+		KeySpec: &keyspb.Specification{
+			Params: &keyspb.Specification_EcdsaParams{
+				EcdsaParams: &keyspb.Specification_ECDSA{
+					Curve: keyspb.Specification_ECDSA_P256,
+				},
+			},
+		},
+	}
+}
+
+// hammerConfig returns the configuration of a short map hammer run, which
+// makes valid and invalid requests to all map entrypoints.
+func hammerConfig(mapID int64, mapClient trillian.TrillianMapClient, write trillian.TrillianMapWriteClient, admin trillian.TrillianAdminClient) hammer.MapConfig {
+	return hammer.MapConfig{
+		MapID:         mapID,
+		MetricFactory: monitoring.InertMetricFactory{},
+		Client:        mapClient,
+		Write:         write,
+		Admin:         admin,
+		RandSource:    rand.NewSource(*seed),
+		EPBias: hammer.MapBias{
+			Bias: map[hammer.MapEntrypointName]int{
+				hammer.GetLeavesName:    10,
+				hammer.GetLeavesRevName: 10,
+				hammer.SetLeavesName:    10,
+				hammer.GetSMRName:       10,
+				hammer.GetSMRRevName:    10,
+			},
+			InvalidChance: map[hammer.MapEntrypointName]int{
+				hammer.GetLeavesName:    10,
+				hammer.GetLeavesRevName: 10,
+				hammer.SetLeavesName:    10,
+				hammer.GetSMRName:       0,
+				hammer.GetSMRRevName:    10,
+			},
+		},
+		LeafSize:          1000,
+		ExtraSize:         100,
+		MinLeaves:         100,
+		MaxLeaves:         150,
+		Operations:        *mapOperations,
+		OperationDeadline: time.Minute,
+		NumCheckers:       1,
+	}
+}
+
+// verifyRoots checks that the demo log holds at least logLeaves leaves, and
+// that the demo map has been written to, verifying the signatures of their
+// latest roots.
+func verifyRoots(ctx context.Context, logClient trillian.TrillianLogClient, logTree *trillian.Tree, logLeaves int64, mapClient trillian.TrillianMapClient, mapTree *trillian.Tree) error {
+	lc, err := client.NewFromTree(logClient, logTree, types.LogRootV1{})
+	if err != nil {
+		return err
+	}
+	logRoot, err := lc.UpdateRoot(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to verify the root of log %d: %v", logTree.TreeId, err)
+	}
+	if int64(logRoot.TreeSize) < logLeaves {
+		return fmt.Errorf("log %d has %d leaves, want at least %d", logTree.TreeId, logRoot.TreeSize, logLeaves)
+	}
+	glog.Infof("Log %d: size %d, root hash %x", logTree.TreeId, logRoot.TreeSize, logRoot.RootHash)
+
+	mc, err := client.NewMapClientFromTree(mapClient, mapTree)
+	if err != nil {
+		return err
+	}
+	mapRoot, err := mc.GetAndVerifyLatestMapRoot(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to verify the root of map %d: %v", mapTree.TreeId, err)
+	}
+	if mapRoot.Revision == 0 {
+		return errors.New("the map hammer wrote no revisions")
+	}
+	glog.Infof("Map %d: revision %d, root hash %x", mapTree.TreeId, mapRoot.Revision, mapRoot.RootHash)
+	return nil
+}
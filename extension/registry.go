@@ -18,8 +18,11 @@ package extension
 
 import (
 	"github.com/google/trillian/crypto/keys"
+	"github.com/google/trillian/merkle/hashers"
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/quota"
+	"github.com/google/trillian/server/attestation"
+	"github.com/google/trillian/server/notify"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/util/election2"
 )
@@ -45,4 +48,17 @@ type Registry struct {
 	NewKeyProto keys.ProtoGenerator
 	// SetProcessStatus sets the current process status for diagnostic purposes.
 	SetProcessStatus func(string)
+	// MapHashers provides the hashers of map hash strategies, in addition to
+	// the ones registered with hashers.RegisterMapHasher. Map storage must
+	// be given the same hashers.
+	MapHashers hashers.MapHasherRegistry
+	// Scrubber, if set, provides the results of integrity checks of the
+	// trees, for attestations and quarantines.
+	Scrubber attestation.ScrubReporter
+	// Tracer, if set, starts the tracing spans of the server in place of the
+	// default OpenCensus tracing, e.g. to plug in OpenTelemetry.
+	Tracer monitoring.Tracer
+	// TreeNotifier, if set, is notified of the changes made to the state of
+	// trees through the admin API, e.g. to call a webhook.
+	TreeNotifier notify.Notifier
 }
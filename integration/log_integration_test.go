@@ -24,6 +24,7 @@ import (
 
 	"github.com/google/trillian"
 	"github.com/google/trillian/client"
+	"github.com/google/trillian/client/rpcflags"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/quota"
 	"github.com/google/trillian/storage/memory"
@@ -31,6 +32,7 @@ import (
 	"github.com/google/trillian/testonly/integration"
 
 	_ "github.com/google/trillian/crypto/keys/der/proto" // Register PrivateKey ProtoHandler
+	pgtestdb "github.com/google/trillian/storage/postgres/testdb"
 	stestonly "github.com/google/trillian/storage/testonly"
 )
 
@@ -79,7 +81,7 @@ func TestLiveLogIntegration(t *testing.T) {
 	defer cancel()
 
 	// TODO: Other options apart from insecure connections
-	conn, err := grpc.DialContext(ctx, *serverFlag, grpc.WithInsecure())
+	conn, err := grpc.DialContext(ctx, rpcflags.DialTarget(*serverFlag), grpc.WithInsecure())
 	if err != nil {
 		t.Fatalf("Failed to connect to log server: %v", err)
 	}
@@ -114,6 +116,93 @@ func TestInProcessLogIntegration(t *testing.T) {
 	}
 }
 
+func TestInProcessLogIntegrationPostgres(t *testing.T) {
+	if !pgtestdb.PGAvailable() {
+		t.Skip("Skipping test as PostgreSQL not available")
+	}
+	ctx := context.Background()
+	registry, done, err := integration.NewPostgresRegistryForTests(ctx)
+	if err != nil {
+		t.Fatalf("NewPostgresRegistryForTests() returned err = %v", err)
+	}
+	defer done(ctx)
+
+	const numSequencers = 2
+	env, err := integration.NewLogEnvWithRegistry(ctx, numSequencers, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{
+		Tree: stestonly.LogTree,
+	}, env.Admin, nil, env.Log)
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	params := DefaultTestParameters(tree.TreeId)
+	if err := RunLogIntegration(env.Log, params); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+}
+
+func TestInProcessLogIntegrationSQLite(t *testing.T) {
+	ctx := context.Background()
+	registry, done, err := integration.NewSQLiteRegistryForTests(ctx)
+	if err != nil {
+		t.Fatalf("NewSQLiteRegistryForTests() returned err = %v", err)
+	}
+	defer done(ctx)
+
+	const numSequencers = 2
+	env, err := integration.NewLogEnvWithRegistry(ctx, numSequencers, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{
+		Tree: stestonly.LogTree,
+	}, env.Admin, nil, env.Log)
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	params := DefaultTestParameters(tree.TreeId)
+	if err := RunLogIntegration(env.Log, params); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+}
+
+func TestInProcessLogIntegrationBigtable(t *testing.T) {
+	ctx := context.Background()
+	registry, done, err := integration.NewBigtableRegistryForTests(ctx)
+	if err != nil {
+		t.Fatalf("NewBigtableRegistryForTests() returned err = %v", err)
+	}
+	defer done(ctx)
+
+	const numSequencers = 2
+	env, err := integration.NewLogEnvWithRegistry(ctx, numSequencers, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	tree, err := client.CreateAndInitTree(ctx, &trillian.CreateTreeRequest{
+		Tree: stestonly.LogTree,
+	}, env.Admin, nil, env.Log)
+	if err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	params := DefaultTestParameters(tree.TreeId)
+	if err := RunLogIntegration(env.Log, params); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+}
+
 func TestInProcessLogIntegrationDuplicateLeaves(t *testing.T) {
 	ctx := context.Background()
 	const numSequencers = 2
@@ -30,6 +30,8 @@ import (
 	"github.com/google/trillian/testonly"
 	"github.com/google/trillian/types"
 	"github.com/kylelemons/godebug/pretty"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	stestonly "github.com/google/trillian/storage/testonly"
 )
@@ -51,6 +53,8 @@ var AllTests = TestTable{
 	{"MapRevisionZero", RunMapRevisionZero},
 	{"MapRevisionInvalid", RunMapRevisionInvalid},
 	{"WriteLeavesRevision", RunWriteLeavesRevision},
+	{"SetMultiMapLeaves", RunSetMultiMapLeaves},
+	{"IdempotentWrite", RunIdempotentWrite},
 	{"LeafHistory", RunLeafHistory},
 	{"Inclusion", RunInclusion},
 	{"InclusionBatch", RunInclusionBatch},
@@ -414,6 +418,96 @@ func RunWriteLeavesRevision(ctx context.Context, t *testing.T, tadmin trillian.T
 	}
 }
 
+// RunSetMultiMapLeaves checks that SetMultiMapLeaves updates several maps
+// atomically.
+func RunSetMultiMapLeaves(ctx context.Context, t *testing.T, tadmin trillian.TrillianAdminClient, tmap trillian.TrillianMapClient, _ trillian.TrillianMapWriteClient) {
+	var mapIDs []int64
+	for i := 0; i < 2; i++ {
+		tree, err := newTreeWithHasher(ctx, tadmin, tmap, trillian.HashStrategy_CONIKS_SHA256)
+		if err != nil {
+			t.Fatalf("newTreeWithHasher: %v", err)
+		}
+		mapIDs = append(mapIDs, tree.TreeId)
+	}
+	set := func(revs ...int64) error {
+		req := &trillian.SetMultiMapLeavesRequest{}
+		for i, id := range mapIDs {
+			req.Requests = append(req.Requests, &trillian.SetMapLeavesRequest{
+				MapId:    id,
+				Leaves:   []*trillian.MapLeaf{{Index: index1, LeafValue: []byte(fmt.Sprintf("rev-%d", revs[i]))}},
+				Revision: revs[i],
+			})
+		}
+		rsp, err := tmap.SetMultiMapLeaves(ctx, req)
+		if err != nil {
+			return err
+		}
+		if got, want := len(rsp.MapRoots), len(mapIDs); got != want {
+			t.Fatalf("SetMultiMapLeaves() returned %d roots, want %d", got, want)
+		}
+		return nil
+	}
+
+	if err := set(1, 1); err != nil {
+		t.Fatalf("SetMultiMapLeaves(1, 1): %v", err)
+	}
+	// The second map can't be written at revision 3, so neither map must be
+	// updated.
+	if err := set(2, 3); err == nil {
+		t.Fatal("SetMultiMapLeaves(2, 3) succeeded, want error")
+	}
+
+	for _, id := range mapIDs {
+		rsp, err := tmap.GetLeaves(ctx, &trillian.GetMapLeavesRequest{MapId: id, Index: [][]byte{index1}})
+		if err != nil {
+			t.Fatalf("GetLeaves(%d): %v", id, err)
+		}
+		var root types.MapRootV1
+		if err := root.UnmarshalBinary(rsp.GetMapRoot().GetMapRoot()); err != nil {
+			t.Fatalf("UnmarshalBinary(): %v", err)
+		}
+		if got, want := root.Revision, uint64(1); got != want {
+			t.Errorf("map %d: got revision %d, want %d", id, got, want)
+		}
+		if got, want := rsp.MapLeafInclusion[0].Leaf.LeafValue, []byte("rev-1"); !bytes.Equal(got, want) {
+			t.Errorf("map %d: got leaf value %q, want %q", id, got, want)
+		}
+	}
+}
+
+// RunIdempotentWrite checks that retrying a write with the same idempotency
+// token returns the original root instead of failing or writing again.
+func RunIdempotentWrite(ctx context.Context, t *testing.T, tadmin trillian.TrillianAdminClient, tmap trillian.TrillianMapClient, _ trillian.TrillianMapWriteClient) {
+	tree, err := newTreeWithHasher(ctx, tadmin, tmap, trillian.HashStrategy_CONIKS_SHA256)
+	if err != nil {
+		t.Fatalf("newTreeWithHasher: %v", err)
+	}
+	req := &trillian.SetMapLeavesRequest{
+		MapId:            tree.TreeId,
+		Leaves:           []*trillian.MapLeaf{{Index: index1, LeafValue: []byte("value")}},
+		Revision:         1,
+		IdempotencyToken: []byte("write-1"),
+	}
+	first, err := tmap.SetLeaves(ctx, req)
+	if err != nil {
+		t.Fatalf("SetLeaves(): %v", err)
+	}
+	retry, err := tmap.SetLeaves(ctx, req)
+	if err != nil {
+		t.Fatalf("SetLeaves() retry: %v", err)
+	}
+	if !proto.Equal(retry.MapRoot, first.MapRoot) {
+		t.Errorf("SetLeaves() retry returned root %v, want %v", retry.MapRoot, first.MapRoot)
+	}
+
+	// A different token must be written as usual, and so fail the revision
+	// check.
+	req.IdempotencyToken = []byte("write-2")
+	if _, err := tmap.SetLeaves(ctx, req); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("SetLeaves() with new token: %v, want code %v", err, codes.FailedPrecondition)
+	}
+}
+
 // RunLeafHistory performs checks on Trillian Map leaf updates under a variety of Hash Strategies.
 func RunLeafHistory(ctx context.Context, t *testing.T, tadmin trillian.TrillianAdminClient, tmap trillian.TrillianMapClient, twrite trillian.TrillianMapWriteClient) {
 	for _, tc := range []struct {
@@ -20,11 +20,13 @@ import (
 	"log"
 	"testing"
 
+	"github.com/google/trillian/client/rpcflags"
 	"github.com/google/trillian/storage/testdb"
 	"github.com/google/trillian/testonly/integration"
 
 	_ "github.com/google/trillian/merkle/coniks"
 	_ "github.com/google/trillian/merkle/maphasher"
+	pgtestdb "github.com/google/trillian/storage/postgres/testdb"
 )
 
 var (
@@ -42,7 +44,7 @@ func TestMapIntegration(t *testing.T) {
 		}
 		env, err = integration.NewMapEnv(ctx, *singleTX)
 	} else {
-		env, err = integration.NewMapEnvFromConn(*server)
+		env, err = integration.NewMapEnvFromConn(rpcflags.DialTarget(*server))
 	}
 	if err != nil {
 		log.Fatalf("Could not create MapEnv: %v", err)
@@ -55,3 +57,69 @@ func TestMapIntegration(t *testing.T) {
 		})
 	}
 }
+
+func TestMapIntegrationPostgres(t *testing.T) {
+	if !pgtestdb.PGAvailable() {
+		t.Skip("Skipping map integration test, PostgreSQL not available")
+	}
+	ctx := context.Background()
+	registry, done, err := integration.NewPostgresRegistryForTests(ctx)
+	if err != nil {
+		t.Fatalf("NewPostgresRegistryForTests() returned err = %v", err)
+	}
+	defer done(ctx)
+
+	env, err := integration.NewMapEnvWithRegistry(registry, *singleTX)
+	if err != nil {
+		t.Fatalf("NewMapEnvWithRegistry() returned err = %v", err)
+	}
+	defer env.Close()
+
+	for _, test := range AllTests {
+		t.Run(test.Name, func(t *testing.T) {
+			test.Fn(ctx, t, env.Admin, env.Map, env.Write)
+		})
+	}
+}
+
+func TestMapIntegrationSQLite(t *testing.T) {
+	ctx := context.Background()
+	registry, done, err := integration.NewSQLiteRegistryForTests(ctx)
+	if err != nil {
+		t.Fatalf("NewSQLiteRegistryForTests() returned err = %v", err)
+	}
+	defer done(ctx)
+
+	env, err := integration.NewMapEnvWithRegistry(registry, *singleTX)
+	if err != nil {
+		t.Fatalf("NewMapEnvWithRegistry() returned err = %v", err)
+	}
+	defer env.Close()
+
+	for _, test := range AllTests {
+		t.Run(test.Name, func(t *testing.T) {
+			test.Fn(ctx, t, env.Admin, env.Map, env.Write)
+		})
+	}
+}
+
+func TestMapIntegrationBigtable(t *testing.T) {
+	ctx := context.Background()
+	registry, done, err := integration.NewBigtableRegistryForTests(ctx)
+	if err != nil {
+		t.Fatalf("NewBigtableRegistryForTests() returned err = %v", err)
+	}
+	defer done(ctx)
+
+	env, err := integration.NewMapEnvWithRegistry(registry, *singleTX)
+	if err != nil {
+		t.Fatalf("NewMapEnvWithRegistry() returned err = %v", err)
+	}
+	defer env.Close()
+
+	for _, test := range AllTests {
+		t.Run(test.Name, func(t *testing.T) {
+			test.Fn(ctx, t, env.Admin, env.Map, env.Write)
+		})
+	}
+}
@@ -29,10 +29,12 @@ import (
 	"github.com/google/trillian/quota/etcd/quotaapi"
 	"github.com/google/trillian/quota/etcd/quotapb"
 	"github.com/google/trillian/quota/mysqlqm"
+	"github.com/google/trillian/quota/postgresqm"
 	"github.com/google/trillian/server"
 	"github.com/google/trillian/server/admin"
 	"github.com/google/trillian/server/interceptor"
 	"github.com/google/trillian/storage/mysql"
+	"github.com/google/trillian/storage/postgres"
 	"github.com/google/trillian/storage/testdb"
 	"github.com/google/trillian/storage/testonly"
 	"github.com/google/trillian/testonly/integration"
@@ -43,6 +45,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	pgtestdb "github.com/google/trillian/storage/postgres/testdb"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 )
 
@@ -126,13 +129,44 @@ func TestMySQLRateLimiting(t *testing.T) {
 	}
 }
 
+func TestPostgresRateLimiting(t *testing.T) {
+	if !pgtestdb.PGAvailable() {
+		t.Skip("Skipping test as PostgreSQL not available")
+	}
+	ctx := context.Background()
+	db, done, err := pgtestdb.NewTrillianDB(ctx)
+	if err != nil {
+		t.Fatalf("NewTrillianDB() returned err = %v", err)
+	}
+	defer done(ctx)
+
+	const maxUnsequenced = 20
+	qm := &postgresqm.QuotaManager{DB: db, MaxUnsequencedRows: maxUnsequenced, UseSelectCount: true}
+	registry := extension.Registry{
+		AdminStorage: postgres.NewAdminStorage(db),
+		LogStorage:   postgres.NewLogStorage(db, nil),
+		MapStorage:   postgres.NewMapStorage(db),
+		QuotaManager: qm,
+	}
+
+	s, err := newTestServer(registry)
+	if err != nil {
+		t.Fatalf("newTestServer() returned err = %v", err)
+	}
+	defer s.close()
+	go s.serve()
+
+	if err := runRateLimitingTest(ctx, s, maxUnsequenced); err != nil {
+		t.Error(err)
+	}
+}
+
 func runRateLimitingTest(ctx context.Context, s *testServer, numTokens int) error {
 	tree, err := s.admin.CreateTree(ctx, &trillian.CreateTreeRequest{Tree: testonly.LogTree})
 	if err != nil {
 		return fmt.Errorf("CreateTree() returned err = %v", err)
 	}
-	// InitLog costs 1 token
-	numTokens--
+	// InitLog takes the priority lane, and costs no tokens
 	_, err = s.log.InitLog(ctx, &trillian.InitLogRequest{LogId: tree.TreeId})
 	if err != nil {
 		return fmt.Errorf("InitLog() returned err = %v", err)
@@ -18,6 +18,7 @@ import (
 	"context"
 	"crypto"
 	"testing"
+	"time"
 
 	"github.com/google/trillian"
 	"github.com/google/trillian/storage"
@@ -51,6 +52,42 @@ func createInitializedMapForTests(ctx context.Context, t *testing.T, s storage.M
 	return tree
 }
 
+// writeMapRevision sets leaves in a new revision of tree, and stores a root for
+// that revision. The root of revision N has a timestamp of N seconds.
+func writeMapRevision(ctx context.Context, t *testing.T, s storage.MapStorage, tree *trillian.Tree, leaves ...*trillian.MapLeaf) {
+	t.Helper()
+	err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		return writeMapRevisionInTX(ctx, tree, tx, leaves...)
+	})
+	if err != nil {
+		t.Fatalf("ReadWriteTransaction() = %v", err)
+	}
+}
+
+// writeMapRevisionInTX is the equivalent of writeMapRevision for an existing
+// transaction.
+func writeMapRevisionInTX(ctx context.Context, tree *trillian.Tree, tx storage.MapTreeTX, leaves ...*trillian.MapLeaf) error {
+	signer := tcrypto.NewSigner(tree.TreeId, testonly.NewSignerWithFixedSig(nil, []byte("sig")), crypto.SHA256)
+	rev, err := tx.WriteRevision(ctx)
+	if err != nil {
+		return err
+	}
+	for _, l := range leaves {
+		if err := tx.Set(ctx, l.Index, l); err != nil {
+			return err
+		}
+	}
+	root, err := signer.SignMapRoot(&types.MapRootV1{
+		RootHash:       []byte("rootHash"),
+		TimestampNanos: uint64(time.Unix(rev, 0).UnixNano()),
+		Revision:       uint64(rev),
+	})
+	if err != nil {
+		return err
+	}
+	return tx.StoreSignedMapRoot(ctx, root)
+}
+
 func mapTree(mapID int64) *trillian.Tree {
 	return &trillian.Tree{
 		TreeId:       mapID,
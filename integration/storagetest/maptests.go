@@ -16,11 +16,22 @@
 package storagetest
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
+	"reflect"
+	"sort"
 	"testing"
+	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	storageto "github.com/google/trillian/storage/testonly"
 )
@@ -84,3 +95,462 @@ func (*MapTests) TestMapSnapshot(ctx context.Context, t *testing.T, s storage.Ma
 		})
 	}
 }
+
+func (*MapTests) TestMapList(ctx context.Context, t *testing.T, s storage.MapStorage, as storage.AdminStorage) {
+	tree := createInitializedMapForTests(ctx, t, s, as)
+	index := func(i byte) []byte { return append(bytes.Repeat([]byte{0}, 31), i) }
+	leaf := func(i, rev byte) *trillian.MapLeaf {
+		return &trillian.MapLeaf{Index: index(i), LeafHash: []byte{i, rev}, LeafValue: []byte{rev}, ExtraData: []byte{i}}
+	}
+	writeMapRevision(ctx, t, s, tree, leaf(3, 1), leaf(1, 1), leaf(2, 1))
+	writeMapRevision(ctx, t, s, tree, leaf(2, 2), leaf(0, 2))
+
+	tests := []struct {
+		desc     string
+		revision int64
+		after    []byte
+		limit    int
+		want     []*trillian.MapLeaf
+	}{
+		{desc: "rev0", revision: 0, limit: 10},
+		{desc: "rev1", revision: 1, limit: 10, want: []*trillian.MapLeaf{leaf(1, 1), leaf(2, 1), leaf(3, 1)}},
+		{desc: "rev2", revision: 2, limit: 10, want: []*trillian.MapLeaf{leaf(0, 2), leaf(1, 1), leaf(2, 2), leaf(3, 1)}},
+		{desc: "rev2-limit", revision: 2, limit: 2, want: []*trillian.MapLeaf{leaf(0, 2), leaf(1, 1)}},
+		{desc: "rev2-after", revision: 2, after: index(1), limit: 2, want: []*trillian.MapLeaf{leaf(2, 2), leaf(3, 1)}},
+		{desc: "rev2-after-last", revision: 2, after: index(3), limit: 2},
+		{desc: "future-rev", revision: 10, after: index(2), limit: 10, want: []*trillian.MapLeaf{leaf(3, 1)}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			tx, err := s.SnapshotForTree(ctx, tree)
+			if err != nil {
+				t.Fatalf("SnapshotForTree(): %v", err)
+			}
+			defer tx.Close()
+
+			got, err := tx.List(ctx, test.revision, test.after, test.limit)
+			if err != nil {
+				t.Fatalf("List(%d, %x, %d): %v", test.revision, test.after, test.limit, err)
+			}
+			if err := tx.Commit(ctx); err != nil {
+				t.Errorf("Commit()=_,%v; want _,nil", err)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("List(%d, %x, %d) returned %d leaves, want %d", test.revision, test.after, test.limit, len(got), len(test.want))
+			}
+			for i := range got {
+				if !proto.Equal(got[i], test.want[i]) {
+					t.Errorf("List(%d, %x, %d)[%d]=%v, want %v", test.revision, test.after, test.limit, i, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func (*MapTests) TestMapDeleteRevisionsBefore(ctx context.Context, t *testing.T, s storage.MapStorage, as storage.AdminStorage) {
+	tree := createInitializedMapForTests(ctx, t, s, as)
+	index := func(i byte) []byte { return append(bytes.Repeat([]byte{0}, 31), i) }
+	leaf := func(i, rev byte) *trillian.MapLeaf {
+		return &trillian.MapLeaf{Index: index(i), LeafHash: []byte{i, rev}, LeafValue: []byte{rev}, ExtraData: []byte{i}}
+	}
+	writeMapRevision(ctx, t, s, tree, leaf(0, 1), leaf(1, 1))
+	writeMapRevision(ctx, t, s, tree, leaf(0, 2))
+	writeMapRevision(ctx, t, s, tree, leaf(2, 3))
+
+	for _, test := range []struct {
+		ts   time.Time
+		want int64
+	}{
+		{ts: time.Unix(0, 0), want: 0},
+		{ts: time.Unix(0, 1), want: 1},
+		{ts: time.Unix(2, 0), want: 2},
+		{ts: time.Unix(10, 0), want: 4},
+	} {
+		tx, err := s.SnapshotForTree(ctx, tree)
+		if err != nil {
+			t.Fatalf("SnapshotForTree(): %v", err)
+		}
+		got, err := tx.EarliestRevisionSince(ctx, test.ts)
+		if err != nil {
+			t.Errorf("EarliestRevisionSince(%v): %v", test.ts, err)
+		} else if got != test.want {
+			t.Errorf("EarliestRevisionSince(%v)=%d, want %d", test.ts, got, test.want)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			t.Errorf("Commit()=_,%v; want _,nil", err)
+		}
+		tx.Close()
+	}
+
+	err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		return tx.DeleteRevisionsBefore(ctx, 2)
+	})
+	if err != nil {
+		t.Fatalf("DeleteRevisionsBefore(2): %v", err)
+	}
+
+	tx, err := s.SnapshotForTree(ctx, tree)
+	if err != nil {
+		t.Fatalf("SnapshotForTree(): %v", err)
+	}
+	defer tx.Close()
+	for rev := int64(0); rev <= 3; rev++ {
+		_, err := tx.GetSignedMapRoot(ctx, rev)
+		if gotErr, wantErr := err != nil, rev < 2; gotErr != wantErr {
+			t.Errorf("GetSignedMapRoot(%d): %v, want err: %v", rev, err, wantErr)
+		}
+	}
+	// The leaf values of the remaining revisions must not be affected.
+	for _, test := range []struct {
+		revision int64
+		want     []*trillian.MapLeaf
+	}{
+		{revision: 2, want: []*trillian.MapLeaf{leaf(0, 2), leaf(1, 1)}},
+		{revision: 3, want: []*trillian.MapLeaf{leaf(0, 2), leaf(1, 1), leaf(2, 3)}},
+	} {
+		got, err := tx.List(ctx, test.revision, nil, 10)
+		if err != nil {
+			t.Fatalf("List(%d): %v", test.revision, err)
+		}
+		if len(got) != len(test.want) {
+			t.Fatalf("List(%d) returned %d leaves, want %d", test.revision, len(got), len(test.want))
+		}
+		for i := range got {
+			if !proto.Equal(got[i], test.want[i]) {
+				t.Errorf("List(%d)[%d]=%v, want %v", test.revision, i, got[i], test.want[i])
+			}
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Errorf("Commit()=_,%v; want _,nil", err)
+	}
+}
+
+func (*MapTests) TestMapListLeafVersions(ctx context.Context, t *testing.T, s storage.MapStorage, as storage.AdminStorage) {
+	tree := createInitializedMapForTests(ctx, t, s, as)
+	index := func(i byte) []byte { return append(bytes.Repeat([]byte{0}, 31), i) }
+	leaf := func(i, rev byte) *trillian.MapLeaf {
+		return &trillian.MapLeaf{Index: index(i), LeafHash: []byte{i, rev}, LeafValue: []byte{rev}, ExtraData: []byte{i}}
+	}
+	version := func(i, rev byte) storage.MapLeafVersion {
+		return storage.MapLeafVersion{Revision: int64(rev), Leaf: leaf(i, rev)}
+	}
+	writeMapRevision(ctx, t, s, tree, leaf(2, 1), leaf(1, 1))
+	writeMapRevision(ctx, t, s, tree, leaf(1, 2), leaf(0, 2))
+
+	list := func(after []byte, limit int) []storage.MapLeafVersion {
+		t.Helper()
+		tx, err := s.SnapshotForTree(ctx, tree)
+		if err != nil {
+			t.Fatalf("SnapshotForTree(): %v", err)
+		}
+		defer tx.Close()
+		got, err := tx.ListLeafVersions(ctx, after, limit)
+		if err != nil {
+			t.Fatalf("ListLeafVersions(%x, %d): %v", after, limit, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			t.Errorf("Commit()=_,%v; want _,nil", err)
+		}
+		return got
+	}
+	check := func(got, want []storage.MapLeafVersion) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("ListLeafVersions() returned %d versions, want %d", len(got), len(want))
+		}
+		for i := range got {
+			if got[i].Revision != want[i].Revision || !proto.Equal(got[i].Leaf, want[i].Leaf) {
+				t.Errorf("ListLeafVersions()[%d]=%d:%v, want %d:%v", i, got[i].Revision, got[i].Leaf, want[i].Revision, want[i].Leaf)
+			}
+		}
+	}
+
+	check(list(nil, 10), []storage.MapLeafVersion{version(0, 2), version(1, 1), version(1, 2), version(2, 1)})
+	check(list(nil, 2), []storage.MapLeafVersion{version(0, 2), version(1, 1), version(1, 2)})
+	check(list(index(0), 1), []storage.MapLeafVersion{version(1, 1), version(1, 2)})
+	check(list(index(2), 10), nil)
+
+	err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		return tx.SetLeafHash(ctx, 1, index(1), []byte("fixed"))
+	})
+	if err != nil {
+		t.Fatalf("SetLeafHash(): %v", err)
+	}
+	fixed := version(1, 1)
+	fixed.Leaf.LeafHash = []byte("fixed")
+	check(list(index(0), 1), []storage.MapLeafVersion{fixed, version(1, 2)})
+}
+
+func (*MapTests) TestMapReadWriteMultiTransaction(ctx context.Context, t *testing.T, s storage.MapStorage, as storage.AdminStorage) {
+	trees := []*trillian.Tree{
+		createInitializedMapForTests(ctx, t, s, as),
+		createInitializedMapForTests(ctx, t, s, as),
+	}
+	index := append(bytes.Repeat([]byte{0}, 31), 1)
+	leaf := func(rev byte) *trillian.MapLeaf {
+		return &trillian.MapLeaf{Index: index, LeafHash: []byte{rev}, LeafValue: []byte{rev}}
+	}
+	write := func(rev byte, fail error) error {
+		return s.ReadWriteMultiTransaction(ctx, trees, func(ctx context.Context, txs []storage.MapTreeTX) error {
+			if got, want := len(txs), len(trees); got != want {
+				t.Fatalf("ReadWriteMultiTransaction() passed %d transactions, want %d", got, want)
+			}
+			for i, tx := range txs {
+				if err := writeMapRevisionInTX(ctx, trees[i], tx, leaf(rev)); err != nil {
+					return err
+				}
+			}
+			return fail
+		})
+	}
+	if err := write(1, nil); err != nil {
+		t.Fatalf("ReadWriteMultiTransaction(): %v", err)
+	}
+	if err := write(2, errors.New("abort")); err == nil {
+		t.Fatal("ReadWriteMultiTransaction() succeeded, want error")
+	}
+
+	// Only the first write must have been applied, to all the maps.
+	for _, tree := range trees {
+		tx, err := s.SnapshotForTree(ctx, tree)
+		if err != nil {
+			t.Fatalf("SnapshotForTree(): %v", err)
+		}
+		root, err := tx.LatestSignedMapRoot(ctx)
+		if err != nil {
+			t.Fatalf("LatestSignedMapRoot(): %v", err)
+		}
+		var mapRoot types.MapRootV1
+		if err := mapRoot.UnmarshalBinary(root.MapRoot); err != nil {
+			t.Fatalf("UnmarshalBinary(): %v", err)
+		}
+		if got, want := mapRoot.Revision, uint64(1); got != want {
+			t.Errorf("map %d: LatestSignedMapRoot() revision=%d, want %d", tree.TreeId, got, want)
+		}
+		leaves, err := tx.Get(ctx, 2, [][]byte{index})
+		if err != nil {
+			t.Fatalf("Get(): %v", err)
+		}
+		if len(leaves) != 1 || !proto.Equal(leaves[0], leaf(1)) {
+			t.Errorf("map %d: Get()=%v, want %v", tree.TreeId, leaves, leaf(1))
+		}
+		if err := tx.Commit(ctx); err != nil {
+			t.Errorf("Commit()=_,%v; want _,nil", err)
+		}
+		tx.Close()
+	}
+}
+
+func (*MapTests) TestMapIdempotencyTokens(ctx context.Context, t *testing.T, s storage.MapStorage, as storage.AdminStorage) {
+	tree := createInitializedMapForTests(ctx, t, s, as)
+	index := append(bytes.Repeat([]byte{0}, 31), 1)
+	for _, token := range []string{"token1", "token2"} {
+		err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+			if err := tx.StoreIdempotencyToken(ctx, []byte(token)); err != nil {
+				return err
+			}
+			return writeMapRevisionInTX(ctx, tree, tx, &trillian.MapLeaf{Index: index, LeafHash: []byte(token), LeafValue: []byte(token)})
+		})
+		if err != nil {
+			t.Fatalf("ReadWriteTransaction(%s): %v", token, err)
+		}
+	}
+
+	check := func(want map[string]int64) {
+		t.Helper()
+		tx, err := s.SnapshotForTree(ctx, tree)
+		if err != nil {
+			t.Fatalf("SnapshotForTree(): %v", err)
+		}
+		defer tx.Close()
+		for _, token := range []string{"token1", "token2", "token3"} {
+			wantRev, wantFound := want[token]
+			rev, found, err := tx.GetIdempotencyToken(ctx, []byte(token))
+			if err != nil {
+				t.Errorf("GetIdempotencyToken(%s): %v", token, err)
+			} else if found != wantFound || rev != wantRev {
+				t.Errorf("GetIdempotencyToken(%s)=%d,%v, want %d,%v", token, rev, found, wantRev, wantFound)
+			}
+		}
+		if err := tx.Commit(ctx); err != nil {
+			t.Errorf("Commit()=_,%v; want _,nil", err)
+		}
+	}
+	check(map[string]int64{"token1": 1, "token2": 2})
+
+	// Tokens must be deleted along with the revisions they created.
+	err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		return tx.DeleteRevisionsBefore(ctx, 2)
+	})
+	if err != nil {
+		t.Fatalf("DeleteRevisionsBefore(2): %v", err)
+	}
+	check(map[string]int64{"token2": 2})
+}
+
+func (*MapTests) TestMapRecoveryMarkers(ctx context.Context, t *testing.T, s storage.MapStorage, as storage.AdminStorage) {
+	tree := createInitializedMapForTests(ctx, t, s, as)
+	index := append(bytes.Repeat([]byte{0}, 31), 1)
+	for rev := byte(1); rev <= 2; rev++ {
+		writeMapRevision(ctx, t, s, tree, &trillian.MapLeaf{Index: index, LeafHash: []byte{rev}, LeafValue: []byte{rev}})
+	}
+
+	tx, err := s.SnapshotForTree(ctx, tree)
+	if err != nil {
+		t.Fatalf("SnapshotForTree(): %v", err)
+	}
+	defer tx.Close()
+	// Each root, including the initial one, must have a marker.
+	for rev := int64(0); rev <= 2; rev++ {
+		marker, err := tx.GetRecoveryMarker(ctx, rev)
+		if err != nil {
+			t.Errorf("GetRecoveryMarker(%d): %v", rev, err)
+		} else if marker.TreeID != tree.TreeId || marker.Revision != rev {
+			t.Errorf("GetRecoveryMarker(%d)=%+v, want tree %d revision %d", rev, marker, tree.TreeId, rev)
+		}
+	}
+	if _, err := tx.GetRecoveryMarker(ctx, 3); status.Code(err) != codes.NotFound {
+		t.Errorf("GetRecoveryMarker(3): %v, want code %v", err, codes.NotFound)
+	}
+	latest, err := tx.LatestRecoveryMarker(ctx)
+	if err != nil {
+		t.Fatalf("LatestRecoveryMarker(): %v", err)
+	}
+	if got, want := latest.Revision, int64(2); got != want {
+		t.Errorf("LatestRecoveryMarker().Revision=%d, want %d", got, want)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Errorf("Commit()=_,%v; want _,nil", err)
+	}
+}
+
+func (*MapTests) TestMapRootSignatures(ctx context.Context, t *testing.T, s storage.MapStorage, as storage.AdminStorage) {
+	tree := createInitializedMapForTests(ctx, t, s, as)
+	index := append(bytes.Repeat([]byte{0}, 31), 1)
+	for rev := byte(1); rev <= 2; rev++ {
+		writeMapRevision(ctx, t, s, tree, &trillian.MapLeaf{Index: index, LeafHash: []byte{rev}, LeafValue: []byte{rev}})
+	}
+
+	sig := func(key, sig string) *trillian.MapRootSignature {
+		return &trillian.MapRootSignature{PublicKey: &keyspb.PublicKey{Der: []byte(key)}, Signature: []byte(sig)}
+	}
+	for _, w := range []struct {
+		revision int64
+		sig      *trillian.MapRootSignature
+	}{
+		{1, sig("key1", "old")},
+		{1, sig("key2", "sig2")},
+		{1, sig("key1", "sig1")}, // Replaces the first signature.
+		{2, sig("key1", "sig3")},
+	} {
+		err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+			return tx.StoreMapRootSignature(ctx, w.revision, w.sig)
+		})
+		if err != nil {
+			t.Fatalf("StoreMapRootSignature(%d, %v): %v", w.revision, w.sig, err)
+		}
+	}
+
+	check := func(want map[int64][]*trillian.MapRootSignature) {
+		t.Helper()
+		tx, err := s.SnapshotForTree(ctx, tree)
+		if err != nil {
+			t.Fatalf("SnapshotForTree(): %v", err)
+		}
+		defer tx.Close()
+		for rev := int64(1); rev <= 2; rev++ {
+			// Signatures are ordered by the hash of their key.
+			wantSigs := want[rev]
+			sort.Slice(wantSigs, func(i, j int) bool {
+				hi, hj := sha256.Sum256(wantSigs[i].PublicKey.Der), sha256.Sum256(wantSigs[j].PublicKey.Der)
+				return bytes.Compare(hi[:], hj[:]) < 0
+			})
+			sigs, err := tx.GetMapRootSignatures(ctx, rev)
+			if err != nil {
+				t.Errorf("GetMapRootSignatures(%d): %v", rev, err)
+				continue
+			}
+			if got, want := len(sigs), len(wantSigs); got != want {
+				t.Errorf("GetMapRootSignatures(%d): %d signatures, want %d", rev, got, want)
+				continue
+			}
+			for i := range sigs {
+				if !proto.Equal(sigs[i], wantSigs[i]) {
+					t.Errorf("GetMapRootSignatures(%d)[%d]=%v, want %v", rev, i, sigs[i], wantSigs[i])
+				}
+			}
+		}
+		if err := tx.Commit(ctx); err != nil {
+			t.Errorf("Commit()=_,%v; want _,nil", err)
+		}
+	}
+	check(map[int64][]*trillian.MapRootSignature{
+		1: {sig("key1", "sig1"), sig("key2", "sig2")},
+		2: {sig("key1", "sig3")},
+	})
+
+	// Signatures must be deleted along with the revisions they endorse.
+	err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		return tx.DeleteRevisionsBefore(ctx, 2)
+	})
+	if err != nil {
+		t.Fatalf("DeleteRevisionsBefore(2): %v", err)
+	}
+	check(map[int64][]*trillian.MapRootSignature{2: {sig("key1", "sig3")}})
+}
+
+func (*MapTests) TestMapListSignedMapRoots(ctx context.Context, t *testing.T, s storage.MapStorage, as storage.AdminStorage) {
+	tree := createInitializedMapForTests(ctx, t, s, as)
+	index := append(bytes.Repeat([]byte{0}, 31), 1)
+	for rev := byte(1); rev <= 4; rev++ {
+		writeMapRevision(ctx, t, s, tree, &trillian.MapLeaf{Index: index, LeafHash: []byte{rev}, LeafValue: []byte{rev}})
+	}
+
+	for _, test := range []struct {
+		desc   string
+		filter storage.MapRootFilter
+		limit  int
+		want   []uint64
+	}{
+		{desc: "all", limit: 10, want: []uint64{0, 1, 2, 3, 4}},
+		{desc: "limit", limit: 2, want: []uint64{0, 1}},
+		{desc: "zero-limit", limit: 0, want: []uint64{}},
+		{desc: "descending", filter: storage.MapRootFilter{Descending: true}, limit: 2, want: []uint64{4, 3}},
+		{desc: "revisions", filter: storage.MapRootFilter{StartRevision: 1, EndRevision: 3}, limit: 10, want: []uint64{1, 2}},
+		{desc: "times", filter: storage.MapRootFilter{StartTime: time.Unix(2, 0), EndTime: time.Unix(4, 0)}, limit: 10, want: []uint64{2, 3}},
+		{
+			desc:   "closest-before",
+			filter: storage.MapRootFilter{EndTime: time.Unix(3, 1), Descending: true},
+			limit:  1,
+			want:   []uint64{3},
+		},
+		{desc: "empty", filter: storage.MapRootFilter{StartRevision: 5}, limit: 10, want: []uint64{}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			tx, err := s.SnapshotForTree(ctx, tree)
+			if err != nil {
+				t.Fatalf("SnapshotForTree(): %v", err)
+			}
+			defer tx.Close()
+			roots, err := tx.ListSignedMapRoots(ctx, test.filter, test.limit)
+			if err != nil {
+				t.Fatalf("ListSignedMapRoots(): %v", err)
+			}
+			got := make([]uint64, 0, len(roots))
+			for _, root := range roots {
+				var mapRoot types.MapRootV1
+				if err := mapRoot.UnmarshalBinary(root.MapRoot); err != nil {
+					t.Fatalf("UnmarshalBinary(): %v", err)
+				}
+				got = append(got, mapRoot.Revision)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ListSignedMapRoots() returned revisions %v, want %v", got, test.want)
+			}
+			if err := tx.Commit(ctx); err != nil {
+				t.Errorf("Commit()=_,%v; want _,nil", err)
+			}
+		})
+	}
+}
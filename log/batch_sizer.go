@@ -0,0 +1,120 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/trillian/monitoring"
+)
+
+var (
+	batchSizerOnce sync.Once
+	seqBatchSize   monitoring.Gauge
+	seqBatchResize monitoring.Counter
+)
+
+func createBatchSizerMetrics(mf monitoring.MetricFactory) {
+	if mf == nil {
+		mf = monitoring.InertMetricFactory{}
+	}
+	seqBatchSize = mf.NewGauge("sequencer_batch_size", "Maximum number of leaves in the next sequencer batch", logIDLabel)
+	seqBatchResize = mf.NewCounter("sequencer_batch_resizes", "Number of times the sequencer batch size changed", logIDLabel, "direction")
+}
+
+// BatchSizerOptions configures a BatchSizer.
+type BatchSizerOptions struct {
+	// Initial is the batch size of the first pass over each log.
+	Initial int
+	// Min and Max bound the batch size of every log.
+	Min, Max int
+	// TargetLatency is the longest a batch should take to integrate. Batches
+	// which take longer halve the batch size of their log, and full batches
+	// which take less than half of it grow it by a quarter.
+	TargetLatency time.Duration
+}
+
+// BatchSizer chooses the number of leaves sequenced in each pass over a log,
+// so that operators don't need to tune a fixed batch size. A log whose queue
+// has more leaves than its batch size gets bigger batches, until they take too
+// long to integrate.
+type BatchSizer struct {
+	opts  BatchSizerOptions
+	mu    sync.Mutex
+	sizes map[int64]int
+}
+
+// NewBatchSizer returns a BatchSizer for opts.
+func NewBatchSizer(opts BatchSizerOptions, mf monitoring.MetricFactory) (*BatchSizer, error) {
+	if opts.Min <= 0 || opts.Max < opts.Min {
+		return nil, fmt.Errorf("batch size bounds must satisfy 0 < min <= max, got [%d, %d]", opts.Min, opts.Max)
+	}
+	if opts.Initial < opts.Min || opts.Initial > opts.Max {
+		return nil, fmt.Errorf("initial batch size %d not in [%d, %d]", opts.Initial, opts.Min, opts.Max)
+	}
+	if opts.TargetLatency <= 0 {
+		return nil, fmt.Errorf("target batch latency must be positive, got %v", opts.TargetLatency)
+	}
+	batchSizerOnce.Do(func() { createBatchSizerMetrics(mf) })
+	return &BatchSizer{opts: opts, sizes: make(map[int64]int)}, nil
+}
+
+// Size returns the batch size of the next pass over the log.
+func (b *BatchSizer) Size(logID int64) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size(logID)
+}
+
+func (b *BatchSizer) size(logID int64) int {
+	if size, ok := b.sizes[logID]; ok {
+		return size
+	}
+	return b.opts.Initial
+}
+
+// Observe adjusts the batch size of the log after a pass which sequenced the
+// given number of leaves, and took latency to do so.
+func (b *BatchSizer) Observe(logID int64, sequenced int, latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	size := b.size(logID)
+	next := size
+	switch {
+	case latency > b.opts.TargetLatency:
+		next = size / 2
+	case sequenced >= size && latency < b.opts.TargetLatency/2:
+		// A full batch means more leaves were probably queued.
+		next = size + (size+3)/4
+	}
+	if next < b.opts.Min {
+		next = b.opts.Min
+	}
+	if next > b.opts.Max {
+		next = b.opts.Max
+	}
+	b.sizes[logID] = next
+
+	label := strconv.FormatInt(logID, 10)
+	seqBatchSize.Set(float64(next), label)
+	if next > size {
+		seqBatchResize.Inc(label, "grow")
+	} else if next < size {
+		seqBatchResize.Inc(label, "shrink")
+	}
+}
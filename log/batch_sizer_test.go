@@ -0,0 +1,68 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewBatchSizerErrors(t *testing.T) {
+	for _, opts := range []BatchSizerOptions{
+		{Initial: 10, Min: 0, Max: 100, TargetLatency: time.Second},
+		{Initial: 10, Min: 20, Max: 10, TargetLatency: time.Second},
+		{Initial: 5, Min: 10, Max: 100, TargetLatency: time.Second},
+		{Initial: 500, Min: 10, Max: 100, TargetLatency: time.Second},
+		{Initial: 10, Min: 10, Max: 100},
+	} {
+		if _, err := NewBatchSizer(opts, nil); err == nil {
+			t.Errorf("NewBatchSizer(%+v): nil, want err", opts)
+		}
+	}
+}
+
+func TestBatchSizer(t *testing.T) {
+	b, err := NewBatchSizer(BatchSizerOptions{Initial: 100, Min: 10, Max: 200, TargetLatency: time.Second}, nil)
+	if err != nil {
+		t.Fatalf("NewBatchSizer(): %v", err)
+	}
+	for _, step := range []struct {
+		desc      string
+		sequenced int
+		latency   time.Duration
+		want      int
+	}{
+		{desc: "full and fast", sequenced: 100, latency: 100 * time.Millisecond, want: 125},
+		{desc: "full and fast again", sequenced: 125, latency: 100 * time.Millisecond, want: 157},
+		{desc: "full but near target", sequenced: 157, latency: 800 * time.Millisecond, want: 157},
+		{desc: "not full", sequenced: 20, latency: 10 * time.Millisecond, want: 157},
+		{desc: "full up to max", sequenced: 157, latency: 0, want: 197},
+		{desc: "capped at max", sequenced: 197, latency: 0, want: 200},
+		{desc: "slow", sequenced: 200, latency: 2 * time.Second, want: 100},
+		{desc: "slow and empty", sequenced: 0, latency: 2 * time.Second, want: 50},
+		{desc: "slow again", sequenced: 50, latency: 3 * time.Second, want: 25},
+		{desc: "slow down to min", sequenced: 25, latency: 3 * time.Second, want: 12},
+		{desc: "capped at min", sequenced: 12, latency: 3 * time.Second, want: 10},
+	} {
+		b.Observe(1, step.sequenced, step.latency)
+		if got := b.Size(1); got != step.want {
+			t.Errorf("%s: Size() = %d, want %d", step.desc, got, step.want)
+		}
+	}
+	// Each log has its own size.
+	if got, want := b.Size(2), 100; got != want {
+		t.Errorf("Size() of another log = %d, want %d", got, want)
+	}
+}
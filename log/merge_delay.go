@@ -0,0 +1,79 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// MergeDelaySLO is the longest leaves should wait between being queued and
+// being integrated. Batches with leaves which waited longer are logged as
+// errors, and counted by the sequencer_merge_delay_slo_violations metric.
+// Zero disables the check.
+var MergeDelaySLO time.Duration
+
+// mergeDelayPercentiles are the percentiles of the merge delay of each batch
+// exported by the sequencer_merge_delay_percentile metric.
+var mergeDelayPercentiles = []int{50, 90, 99, 100}
+
+// mergeDelayPercentile returns the p-th percentile of sorted, using the
+// nearest-rank method. sorted must not be empty.
+func mergeDelayPercentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// mergeDelaySLOViolations returns how many of delays exceed MergeDelaySLO.
+func mergeDelaySLOViolations(delays []time.Duration) int {
+	if MergeDelaySLO <= 0 {
+		return 0
+	}
+	n := 0
+	for _, d := range delays {
+		if d > MergeDelaySLO {
+			n++
+		}
+	}
+	return n
+}
+
+// trackMergeDelays exports the percentiles of the merge delays of the leaves
+// of a batch, and raises the alarm if any of them exceed MergeDelaySLO. The
+// leaves which have no queue timestamp are not included in delays.
+func trackMergeDelays(delays []time.Duration, label string) {
+	if len(delays) == 0 {
+		return
+	}
+	sort.Slice(delays, func(i, j int) bool { return delays[i] < delays[j] })
+	for _, p := range mergeDelayPercentiles {
+		seqMergeDelayPercentile.Set(mergeDelayPercentile(delays, p).Seconds(), label, strconv.Itoa(p))
+	}
+
+	violations := mergeDelaySLOViolations(delays)
+	if violations == 0 {
+		seqMergeDelaySLOExceeded.Set(0, label)
+		return
+	}
+	seqMergeDelaySLOExceeded.Set(1, label)
+	seqMergeDelaySLOViolations.Add(float64(violations), label)
+	glog.Errorf("%s: %d of %d leaves integrated later than the merge delay SLO of %v, the longest after %v", label, violations, len(delays), MergeDelaySLO, delays[len(delays)-1])
+}
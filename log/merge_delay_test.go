@@ -0,0 +1,65 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMergeDelayPercentile(t *testing.T) {
+	var delays []time.Duration
+	for i := 1; i <= 10; i++ {
+		delays = append(delays, time.Duration(i)*time.Second)
+	}
+	for _, test := range []struct {
+		delays []time.Duration
+		p      int
+		want   time.Duration
+	}{
+		{delays: delays, p: 0, want: time.Second},
+		{delays: delays, p: 50, want: 5 * time.Second},
+		{delays: delays, p: 90, want: 9 * time.Second},
+		{delays: delays, p: 99, want: 10 * time.Second},
+		{delays: delays, p: 100, want: 10 * time.Second},
+		{delays: delays[:1], p: 50, want: time.Second},
+	} {
+		t.Run(fmt.Sprintf("%d-of-%d", test.p, len(test.delays)), func(t *testing.T) {
+			if got := mergeDelayPercentile(test.delays, test.p); got != test.want {
+				t.Errorf("mergeDelayPercentile()=%v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeDelaySLOViolations(t *testing.T) {
+	defer func(slo time.Duration) { MergeDelaySLO = slo }(MergeDelaySLO)
+	delays := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}
+	for _, test := range []struct {
+		slo  time.Duration
+		want int
+	}{
+		{slo: 0, want: 0},
+		{slo: time.Second, want: 2},
+		{slo: 3 * time.Second, want: 0},
+		{slo: time.Millisecond, want: 3},
+	} {
+		MergeDelaySLO = test.slo
+		if got := mergeDelaySLOViolations(delays); got != test.want {
+			t.Errorf("mergeDelaySLOViolations() with SLO %v: %d, want %d", test.slo, got, test.want)
+		}
+	}
+}
@@ -87,8 +87,14 @@ type OperationInfo struct {
 
 	// BatchSize is the processing batch size to be passed to tasks run by this manager
 	BatchSize int
+	// BatchSizer, if set, chooses the batch size of each pass over each log
+	// instead of BatchSize.
+	BatchSizer *BatchSizer
 	// TimeSource should be used by the Operation to allow mocking for tests.
 	TimeSource clock.TimeSource
+	// DryRun makes sequencing passes do all the work of integrating batches,
+	// but roll it back instead of committing it. See Sequencer.DryRun.
+	DryRun bool
 
 	// The following parameters govern the overall scheduling of Operations
 	// by a OperationManager.
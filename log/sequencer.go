@@ -17,6 +17,7 @@ package log
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"sync"
@@ -55,6 +56,10 @@ var (
 	seqMergeDelay          monitoring.Histogram
 	seqTimestamp           monitoring.Gauge
 
+	seqMergeDelayPercentile    monitoring.Gauge
+	seqMergeDelaySLOExceeded   monitoring.Gauge
+	seqMergeDelaySLOViolations monitoring.Counter
+
 	// QuotaIncreaseFactor is the multiplier used for the number of tokens added back to
 	// sequencing-based quotas. The resulting PutTokens call is equivalent to
 	// "PutTokens(_, numLeaves * QuotaIncreaseFactor, _)".
@@ -64,6 +69,9 @@ var (
 	// configuration should be changed instead.
 	// A factor <1 WILL lead to token shortages, therefore it'll be normalized to 1.
 	QuotaIncreaseFactor = 1.1
+
+	// errDryRun rolls back the transactions of dry runs.
+	errDryRun = errors.New("dry run")
 )
 
 func quotaIncreaseFactor() float64 {
@@ -93,6 +101,9 @@ func createSequencerMetrics(mf monitoring.MetricFactory) {
 	seqStoreRootLatency = mf.NewHistogram("sequencer_latency_store_root", "Latency of store-root part of sequencer batch operation in seconds", logIDLabel)
 	seqCounter = mf.NewCounter("sequencer_sequenced", "Number of leaves sequenced", logIDLabel)
 	seqMergeDelay = mf.NewHistogram("sequencer_merge_delay", "Delay between queuing and integration of leaves", logIDLabel)
+	seqMergeDelayPercentile = mf.NewGauge("sequencer_merge_delay_percentile", "Percentiles of the delay between queuing and integration of the leaves of the last batch, in seconds", logIDLabel, "percentile")
+	seqMergeDelaySLOExceeded = mf.NewGauge("sequencer_merge_delay_slo_exceeded", "Set to 1 if leaves of the last batch were integrated later than the merge delay SLO, 0 otherwise", logIDLabel)
+	seqMergeDelaySLOViolations = mf.NewCounter("sequencer_merge_delay_slo_violations", "Number of leaves integrated later than the merge delay SLO", logIDLabel)
 }
 
 // Sequencer instances are responsible for integrating new leaves into a single log.
@@ -105,6 +116,12 @@ type Sequencer struct {
 	logStorage storage.LogStorage
 	signer     *tcrypto.Signer
 	qm         quota.Manager
+
+	// DryRun makes the sequencer dequeue batches, and compute and sign the
+	// roots they would produce, but only log those roots: nothing is written
+	// to storage, the transaction is rolled back, so the queued leaves stay
+	// queued, and quota is not replenished.
+	DryRun bool
 }
 
 // maxTreeDepth sets an upper limit on the size of Log trees.
@@ -196,31 +213,36 @@ func (s Sequencer) buildNodesFromNodeMap(nodeMap map[compact.NodeID][]byte, newV
 	return nodes, nil
 }
 
-func (s Sequencer) prepareLeaves(leaves []*trillian.LogLeaf, begin uint64, label string) error {
+// prepareLeaves sets the integration timestamp of leaves, and returns their
+// merge delays, to be tracked once the batch is committed.
+func (s Sequencer) prepareLeaves(leaves []*trillian.LogLeaf, begin uint64, label string) ([]time.Duration, error) {
 	now := s.timeSource.Now()
 	integrateAt, err := ptypes.TimestampProto(now)
 	if err != nil {
-		return fmt.Errorf("got invalid integrate timestamp: %v", err)
+		return nil, fmt.Errorf("got invalid integrate timestamp: %v", err)
 	}
+	delays := make([]time.Duration, 0, len(leaves))
 	for i, leaf := range leaves {
 		// The leaf should already have the correct index before it's integrated.
 		if got, want := leaf.LeafIndex, begin+uint64(i); got < 0 || got != int64(want) {
-			return fmt.Errorf("got invalid leaf index: %v, want: %v", got, want)
+			return nil, fmt.Errorf("got invalid leaf index: %v, want: %v", got, want)
 		}
 		leaf.IntegrateTimestamp = integrateAt
 
 		// Old leaves might not have a QueueTimestamp, only calculate the merge
-		// delay if this one does.
-		if leaf.QueueTimestamp != nil && leaf.QueueTimestamp.Seconds != 0 {
+		// delay if this one does. Dry runs don't integrate leaves, so the delays
+		// would only keep growing.
+		if !s.DryRun && leaf.QueueTimestamp != nil && leaf.QueueTimestamp.Seconds != 0 {
 			queueTS, err := ptypes.Timestamp(leaf.QueueTimestamp)
 			if err != nil {
-				return fmt.Errorf("got invalid queue timestamp: %v", queueTS)
+				return nil, fmt.Errorf("got invalid queue timestamp: %v", queueTS)
 			}
 			mergeDelay := now.Sub(queueTS)
 			seqMergeDelay.Observe(mergeDelay.Seconds(), label)
+			delays = append(delays, mergeDelay)
 		}
 	}
-	return nil
+	return delays, nil
 }
 
 // updateCompactRange adds the passed in leaves to the compact range. Returns a
@@ -330,9 +352,14 @@ func (s Sequencer) IntegrateBatch(ctx context.Context, tree *trillian.Tree, limi
 	label := strconv.FormatInt(tree.TreeId, 10)
 
 	numLeaves := 0
+	dryRun := false
 	var newLogRoot *types.LogRootV1
 	var newSLR *trillian.SignedLogRoot
+	// mergeDelays are only tracked once the transaction is committed, as it
+	// may be retried or fail after the leaves are prepared.
+	var mergeDelays []time.Duration
 	err := s.logStorage.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		mergeDelays = nil
 		stageStart := s.timeSource.Now()
 		defer seqBatches.Inc(label)
 		defer func() { seqLatency.Observe(clock.SecondsSince(s.timeSource, start), label) }()
@@ -413,7 +440,7 @@ func (s Sequencer) IntegrateBatch(ctx context.Context, tree *trillian.Tree, limi
 		}
 
 		// Collate node updates.
-		if err := s.prepareLeaves(sequencedLeaves, cr.End(), label); err != nil {
+		if mergeDelays, err = s.prepareLeaves(sequencedLeaves, cr.End(), label); err != nil {
 			return err
 		}
 		nodeMap, newRoot, err := s.updateCompactRange(cr, sequencedLeaves, label)
@@ -422,9 +449,11 @@ func (s Sequencer) IntegrateBatch(ctx context.Context, tree *trillian.Tree, limi
 		}
 		seqWriteTreeLatency.Observe(clock.SecondsSince(s.timeSource, stageStart), label)
 
-		// Store the sequenced batch.
-		if err := st.update(ctx, sequencedLeaves); err != nil {
-			return err
+		// Store the sequenced batch. Dry runs write nothing.
+		if !s.DryRun {
+			if err := st.update(ctx, sequencedLeaves); err != nil {
+				return err
+			}
 		}
 		stageStart = s.timeSource.Now()
 
@@ -439,8 +468,10 @@ func (s Sequencer) IntegrateBatch(ctx context.Context, tree *trillian.Tree, limi
 
 		// Now insert or update the nodes affected by the above, at the new tree
 		// version.
-		if err := tx.SetMerkleNodes(ctx, targetNodes); err != nil {
-			return fmt.Errorf("%v: Sequencer failed to set Merkle nodes: %v", tree.TreeId, err)
+		if !s.DryRun {
+			if err := tx.SetMerkleNodes(ctx, targetNodes); err != nil {
+				return fmt.Errorf("%v: Sequencer failed to set Merkle nodes: %v", tree.TreeId, err)
+			}
 		}
 		seqSetNodesLatency.Observe(clock.SecondsSince(s.timeSource, stageStart), label)
 		stageStart = s.timeSource.Now()
@@ -468,6 +499,10 @@ func (s Sequencer) IntegrateBatch(ctx context.Context, tree *trillian.Tree, limi
 		if err != nil {
 			return fmt.Errorf("%v: signer failed to sign root: %v", tree.TreeId, err)
 		}
+		if s.DryRun {
+			dryRun = true
+			return errDryRun
+		}
 
 		if err := tx.StoreSignedLogRoot(ctx, newSLR); err != nil {
 			return fmt.Errorf("%v: failed to write updated tree root: %v", tree.TreeId, err)
@@ -475,6 +510,10 @@ func (s Sequencer) IntegrateBatch(ctx context.Context, tree *trillian.Tree, limi
 		seqStoreRootLatency.Observe(clock.SecondsSince(s.timeSource, stageStart), label)
 		return nil
 	})
+	if dryRun {
+		glog.Infof("%v: dry run: would have sequenced %v leaves, size %v, tree-revision %v, root hash %x", tree.TreeId, numLeaves, newLogRoot.TreeSize, newLogRoot.Revision, newLogRoot.RootHash)
+		return 0, nil
+	}
 	if err != nil {
 		return 0, err
 	}
@@ -483,6 +522,7 @@ func (s Sequencer) IntegrateBatch(ctx context.Context, tree *trillian.Tree, limi
 	s.replenishQuota(ctx, numLeaves, tree.TreeId)
 
 	seqCounter.Add(float64(numLeaves), label)
+	trackMergeDelays(mergeDelays, label)
 	if newSLR != nil {
 		glog.Infof("%v: sequenced %v leaves, size %v, tree-revision %v", tree.TreeId, numLeaves, newLogRoot.TreeSize, newLogRoot.Revision)
 	}
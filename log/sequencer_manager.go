@@ -72,13 +72,22 @@ func (s *SequencerManager) ExecutePass(ctx context.Context, logID int64, info *O
 	}
 
 	sequencer := NewSequencer(hasher, info.TimeSource, s.registry.LogStorage, signer, s.registry.MetricFactory, s.registry.QuotaManager)
+	sequencer.DryRun = info.DryRun
 
 	maxRootDuration, err := ptypes.Duration(tree.MaxRootDuration)
 	if err != nil {
 		glog.Warning("failed to parse tree.MaxRootDuration, using zero")
 		maxRootDuration = 0
 	}
-	leaves, err := sequencer.IntegrateBatch(ctx, tree, info.BatchSize, s.guardWindow, maxRootDuration)
+	batchSize := info.BatchSize
+	if info.BatchSizer != nil {
+		batchSize = info.BatchSizer.Size(logID)
+	}
+	start := info.TimeSource.Now()
+	leaves, err := sequencer.IntegrateBatch(ctx, tree, batchSize, s.guardWindow, maxRootDuration)
+	if info.BatchSizer != nil {
+		info.BatchSizer.Observe(logID, leaves, info.TimeSource.Now().Sub(start))
+	}
 	if err != nil {
 		return 0, fmt.Errorf("failed to integrate batch for %v: %v", logID, err)
 	}
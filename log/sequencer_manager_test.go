@@ -258,6 +258,61 @@ func TestSequencerManagerSingleLogOneLeaf(t *testing.T) {
 	sm.ExecutePass(ctx, logID, createTestInfo(registry))
 }
 
+func TestSequencerManagerBatchSizer(t *testing.T) {
+	ctx := context.Background()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	logID := stestonly.LogTree.GetTreeId()
+	mockAdminTx := storage.NewMockReadOnlyAdminTX(mockCtrl)
+	mockAdmin := &stestonly.FakeAdminStorage{ReadOnlyTX: []storage.ReadOnlyAdminTX{mockAdminTx}}
+	mockTx := storage.NewMockLogTreeTX(mockCtrl)
+	fakeStorage := &stestonly.FakeLogStorage{TX: mockTx}
+
+	var keyProto ptypes.DynamicAny
+	if err := ptypes.UnmarshalAny(stestonly.LogTree.PrivateKey, &keyProto); err != nil {
+		t.Fatalf("Failed to unmarshal stestonly.LogTree.PrivateKey: %v", err)
+	}
+
+	keys.RegisterHandler(fakeKeyProtoHandler(keyProto.Message, fixedGoSigner, nil))
+	defer keys.UnregisterHandler(keyProto.Message)
+
+	// The batch holds a single leaf, as chosen by the sizer.
+	mockTx.EXPECT().Commit(gomock.Any()).Return(nil)
+	mockTx.EXPECT().Close().Return(nil)
+	mockTx.EXPECT().WriteRevision(gomock.Any()).AnyTimes().Return(int64(testRoot0.Revision+1), nil)
+	mockTx.EXPECT().DequeueLeaves(gomock.Any(), 1, fakeTime).Return([]*trillian.LogLeaf{testLeaf0}, nil)
+	mockTx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(testSignedRoot0, nil)
+	mockTx.EXPECT().UpdateSequencedLeaves(gomock.Any(), []*trillian.LogLeaf{testLeaf0Updated}).Return(nil)
+	mockTx.EXPECT().SetMerkleNodes(gomock.Any(), updatedNodes0).Return(nil)
+	mockTx.EXPECT().StoreSignedLogRoot(gomock.Any(), updatedSignedRoot).Return(nil)
+
+	mockAdminTx.EXPECT().GetTree(gomock.Any(), logID).Return(stestonly.LogTree, nil)
+	mockAdminTx.EXPECT().Commit().Return(nil)
+	mockAdminTx.EXPECT().Close().Return(nil)
+
+	registry := extension.Registry{
+		AdminStorage: mockAdmin,
+		LogStorage:   fakeStorage,
+		QuotaManager: quota.Noop(),
+	}
+	sizer, err := NewBatchSizer(BatchSizerOptions{Initial: 1, Min: 1, Max: 10, TargetLatency: time.Second}, nil)
+	if err != nil {
+		t.Fatalf("NewBatchSizer(): %v", err)
+	}
+	info := createTestInfo(registry)
+	info.BatchSizer = sizer
+
+	sm := NewSequencerManager(registry, zeroDuration)
+	if _, err := sm.ExecutePass(ctx, logID, info); err != nil {
+		t.Fatalf("ExecutePass(): %v", err)
+	}
+	// The batch was full, so the next one is bigger.
+	if got, want := sizer.Size(logID), 2; got != want {
+		t.Errorf("Size() after a full batch: %d, want %d", got, want)
+	}
+}
+
 func TestSequencerManagerGuardWindow(t *testing.T) {
 	ctx := context.Background()
 	mockCtrl := gomock.NewController(t)
@@ -19,6 +19,7 @@ import (
 	"crypto"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -34,6 +35,7 @@ import (
 	"github.com/google/trillian/util/clock"
 
 	tcrypto "github.com/google/trillian/crypto"
+	mtestonly "github.com/google/trillian/monitoring/testonly"
 	stestonly "github.com/google/trillian/storage/testonly"
 	"github.com/google/trillian/storage/tree"
 )
@@ -802,3 +804,88 @@ func TestIntegrateBatch_PutTokens(t *testing.T) {
 		}()
 	}
 }
+
+func TestIntegrateBatch_MergeDelay(t *testing.T) {
+	defer func(slo time.Duration) { MergeDelaySLO = slo }(MergeDelaySLO)
+	MergeDelaySLO = time.Minute
+
+	queuedAt := testonly.MustToTimestampProto(fakeTime.Add(-time.Hour))
+	for _, test := range []struct {
+		desc           string
+		commitFails    bool
+		wantViolations float64
+	}{
+		{desc: "committed", wantViolations: 1},
+		// Leaves which were not integrated don't count towards the SLO.
+		{desc: "commit-fails", commitFails: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			leaf := getLeaf42()
+			leaf.QueueTimestamp = queuedAt
+			updatedLeaves := []*trillian.LogLeaf{{
+				MerkleLeafHash:     testLeaf16Hash,
+				LeafValue:          testLeaf16Data,
+				LeafIndex:          16,
+				QueueTimestamp:     queuedAt,
+				IntegrateTimestamp: testonly.MustToTimestampProto(fakeTime),
+			}}
+			params := testParameters{
+				logID:            154035,
+				writeRevision:    int64(testRoot16.Revision + 1),
+				dequeueLimit:     1,
+				shouldCommit:     true,
+				commitFails:      test.commitFails,
+				commitError:      errors.New("commit"),
+				dequeuedLeaves:   []*trillian.LogLeaf{leaf},
+				latestSignedRoot: testSignedRoot16,
+				merkleNodesGet:   &compactTree16,
+				updatedLeaves:    &updatedLeaves,
+				merkleNodesSet:   &updatedNodes,
+				signer:           fixedGoSigner,
+			}
+			c, ctx := createTestContext(ctrl, params)
+			label := strconv.FormatInt(params.logID, 10)
+			violations := mtestonly.NewCounterSnapshot(seqMergeDelaySLOViolations, label)
+
+			tree := &trillian.Tree{TreeId: params.logID, TreeType: trillian.TreeType_LOG}
+			if _, err := c.sequencer.IntegrateBatch(ctx, tree, 1, 0, 0); (err != nil) != test.commitFails {
+				t.Errorf("IntegrateBatch(): %v, want err: %v", err, test.commitFails)
+			}
+			if got, want := violations.Delta(), test.wantViolations; got != want {
+				t.Errorf("SLO violations delta = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestIntegrateBatch_DryRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The batch is dequeued and its root computed and signed as usual, but
+	// nothing is written, the transaction is not committed, and no quota is
+	// replenished: the mocks fail the test on any call to Commit,
+	// UpdateSequencedLeaves, SetMerkleNodes, StoreSignedLogRoot or PutTokens.
+	params := testParameters{
+		logID:               154035,
+		writeRevision:       int64(testRoot16.Revision + 1),
+		dequeueLimit:        1,
+		dequeuedLeaves:      []*trillian.LogLeaf{getLeaf42()},
+		latestSignedRoot:    testSignedRoot16,
+		merkleNodesGet:      &compactTree16,
+		skipStoreSignedRoot: true,
+		signer:              fixedGoSigner,
+		qm:                  quota.NewMockManager(ctrl),
+	}
+	c, ctx := createTestContext(ctrl, params)
+	c.sequencer.DryRun = true
+	tree := &trillian.Tree{TreeId: params.logID, TreeType: trillian.TreeType_LOG}
+
+	got, err := c.sequencer.IntegrateBatch(ctx, tree, 1, 0, 0)
+	if err != nil || got != 0 {
+		t.Errorf("IntegrateBatch()=%v,%v; want 0,nil", got, err)
+	}
+}
@@ -0,0 +1,356 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mirror replicates a Trillian map into another one, e.g. served by
+// another instance in another region, revision by revision, and checks that
+// both maps have the same root hash at each revision.
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+)
+
+// Results recorded by the mirror_revisions metric.
+const (
+	resultMatch    = "match"
+	resultDiverged = "diverged"
+)
+
+// ErrDiverged is returned when a revision of the destination map does not
+// have the root hash of the same revision of the source map. Mirroring can't
+// resume until the destination map is rebuilt.
+var ErrDiverged = errors.New("mirror: destination map diverged from source map")
+
+// Config holds the dependencies and parameters of a Mirror.
+type Config struct {
+	// Source serves the map to follow, with ID SourceID.
+	Source   trillian.TrillianMapClient
+	SourceID int64
+	// Dest serves the map which the revisions of the source map are applied
+	// to, with ID DestID, and DestWrite its write API. It must have been
+	// created with the same hash strategy as the source map, initialised, and
+	// not be written to by anything else. Its writes must not be queued.
+	Dest      trillian.TrillianMapClient
+	DestWrite trillian.TrillianMapWriteClient
+	DestID    int64
+
+	// PageSize is the number of leaves in each page of the listings of both
+	// maps. Zero uses the default of the servers.
+	PageSize int32
+	// RetryInterval is the delay before following the source map again after
+	// an error.
+	RetryInterval time.Duration
+	TimeSource    clock.TimeSource
+	MetricFactory monitoring.MetricFactory
+}
+
+// Mirror follows the revisions of a source map, and applies each of them to a
+// destination map, as a single write of the leaves which changed, with the
+// metadata of the source revision.
+//
+// The leaves which changed are found by listing both maps in full, which
+// costs a scan of each map per revision, so mirroring is best suited to maps
+// which publish revisions at a modest rate.
+type Mirror struct {
+	cfg      Config
+	label    string
+	counter  monitoring.Counter
+	revision monitoring.Gauge
+}
+
+// New returns a new Mirror.
+func New(cfg Config) (*Mirror, error) {
+	if cfg.Source == nil || cfg.Dest == nil || cfg.DestWrite == nil {
+		return nil, errors.New("mirror: source and destination clients are required")
+	}
+	if cfg.PageSize < 0 {
+		return nil, fmt.Errorf("mirror: page size must not be negative, got %d", cfg.PageSize)
+	}
+	if cfg.RetryInterval <= 0 {
+		return nil, fmt.Errorf("mirror: retry interval must be positive, got %v", cfg.RetryInterval)
+	}
+	if cfg.TimeSource == nil {
+		cfg.TimeSource = clock.System
+	}
+	if cfg.MetricFactory == nil {
+		cfg.MetricFactory = monitoring.InertMetricFactory{}
+	}
+	return &Mirror{
+		cfg:   cfg,
+		label: strconv.FormatInt(cfg.SourceID, 10),
+		counter: cfg.MetricFactory.NewCounter(
+			"mirror_revisions",
+			"Number of source map revisions applied to the destination map, by the result of comparing their root hashes",
+			"map_id", "result"),
+		revision: cfg.MetricFactory.NewGauge(
+			"mirror_revision",
+			"Latest source map revision whose root hash the destination map matches",
+			"map_id"),
+	}, nil
+}
+
+// Run mirrors the revisions of the source map as they are published, until
+// ctx is cancelled or the destination map diverges, in which case it returns
+// ErrDiverged. Other errors are logged, and retried.
+func (m *Mirror) Run(ctx context.Context) error {
+	for {
+		err := m.follow(ctx)
+		if err == ErrDiverged || ctx.Err() != nil {
+			return err
+		}
+		glog.Errorf("Mirror.Run: map %d: %v", m.cfg.SourceID, err)
+		if err := clock.SleepSource(ctx, m.cfg.RetryInterval, m.cfg.TimeSource); err != nil {
+			return err
+		}
+	}
+}
+
+// follow mirrors each revision of the source map published while it watches
+// its roots.
+func (m *Mirror) follow(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := m.cfg.Source.WatchSignedMapRoots(ctx, &trillian.WatchSignedMapRootsRequest{MapId: m.cfg.SourceID})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		var root types.MapRootV1
+		if err := root.UnmarshalBinary(resp.GetMapRoot().GetMapRoot()); err != nil {
+			return err
+		}
+		if err := m.MirrorTo(ctx, int64(root.Revision)); err != nil {
+			return err
+		}
+	}
+}
+
+// MirrorTo applies the revisions of the source map up to revision which the
+// destination map does not have yet, after checking that the latest revision
+// of the destination map matches the source map.
+func (m *Mirror) MirrorTo(ctx context.Context, revision int64) error {
+	resp, err := m.cfg.Dest.GetSignedMapRoot(ctx, &trillian.GetSignedMapRootRequest{MapId: m.cfg.DestID})
+	if err != nil {
+		return fmt.Errorf("GetSignedMapRoot(%d): %v", m.cfg.DestID, err)
+	}
+	var destRoot types.MapRootV1
+	if err := destRoot.UnmarshalBinary(resp.GetMapRoot().GetMapRoot()); err != nil {
+		return err
+	}
+	rev := int64(destRoot.Revision)
+	if rev >= revision {
+		return nil
+	}
+
+	sourceResp, err := m.cfg.Source.GetSignedMapRootByRevision(ctx, &trillian.GetSignedMapRootByRevisionRequest{MapId: m.cfg.SourceID, Revision: rev})
+	if err != nil {
+		return fmt.Errorf("GetSignedMapRootByRevision(%d, %d): %v", m.cfg.SourceID, rev, err)
+	}
+	var sourceRoot types.MapRootV1
+	if err := sourceRoot.UnmarshalBinary(sourceResp.GetMapRoot().GetMapRoot()); err != nil {
+		return err
+	}
+	if err := m.check(rev, sourceRoot.RootHash, destRoot.RootHash); err != nil {
+		return err
+	}
+
+	for rev++; rev <= revision; rev++ {
+		if err := m.mirrorRevision(ctx, rev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mirrorRevision applies revision of the source map to the destination map,
+// whose latest revision must be the previous one.
+func (m *Mirror) mirrorRevision(ctx context.Context, revision int64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	source, err := m.list(ctx, m.cfg.Source, m.cfg.SourceID, revision)
+	if err != nil {
+		return err
+	}
+	dest, err := m.list(ctx, m.cfg.Dest, m.cfg.DestID, revision-1)
+	if err != nil {
+		return err
+	}
+	leaves, err := changedLeaves(source, dest)
+	if err != nil {
+		return err
+	}
+
+	var sourceRoot types.MapRootV1
+	if err := sourceRoot.UnmarshalBinary(source.root.GetMapRoot()); err != nil {
+		return err
+	}
+	// The write fails if another one created the revision in the meantime.
+	resp, err := m.cfg.DestWrite.WriteLeaves(ctx, &trillian.WriteMapLeavesRequest{
+		MapId:          m.cfg.DestID,
+		Leaves:         leaves,
+		Metadata:       sourceRoot.Metadata,
+		ExpectRevision: revision,
+	})
+	if err != nil {
+		return fmt.Errorf("WriteLeaves(%d, revision %d): %v", m.cfg.DestID, revision, err)
+	}
+	if resp.Revision != revision {
+		return fmt.Errorf("WriteLeaves(%d, revision %d) wrote revision %d, the destination server must not queue writes", m.cfg.DestID, revision, resp.Revision)
+	}
+	glog.V(1).Infof("Mirrored revision %d of map %d to map %d: %d leaves changed", revision, m.cfg.SourceID, m.cfg.DestID, len(leaves))
+	return m.check(revision, sourceRoot.RootHash, resp.RootHash)
+}
+
+// check compares the root hashes of revision of both maps.
+func (m *Mirror) check(revision int64, sourceHash, destHash []byte) error {
+	if !bytes.Equal(sourceHash, destHash) {
+		glog.Errorf("Revision %d of map %d has root hash %x, but map %d has %x", revision, m.cfg.DestID, destHash, m.cfg.SourceID, sourceHash)
+		m.counter.Inc(m.label, resultDiverged)
+		return ErrDiverged
+	}
+	m.counter.Inc(m.label, resultMatch)
+	m.revision.Set(float64(revision), m.label)
+	return nil
+}
+
+// leafLister iterates over the leaves of a map at a revision, in ascending
+// index order.
+type leafLister struct {
+	stream trillian.TrillianMap_ListLeavesByRevisionClient
+	root   *trillian.SignedMapRoot
+	page   []*trillian.MapLeaf
+	done   bool
+}
+
+// list starts listing the leaves of map mapID at revision.
+func (m *Mirror) list(ctx context.Context, c trillian.TrillianMapClient, mapID, revision int64) (*leafLister, error) {
+	stream, err := c.ListLeavesByRevision(ctx, &trillian.ListMapLeavesByRevisionRequest{
+		MapId:    mapID,
+		Revision: revision,
+		PageSize: m.cfg.PageSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ListLeavesByRevision(%d, %d): %v", mapID, revision, err)
+	}
+	l := &leafLister{stream: stream}
+	// The first response carries the root of the revision.
+	if err := l.fill(); err != nil {
+		return nil, fmt.Errorf("ListLeavesByRevision(%d, %d): %v", mapID, revision, err)
+	}
+	return l, nil
+}
+
+// fill receives pages until one has leaves, or the listing ends.
+func (l *leafLister) fill() error {
+	for len(l.page) == 0 && !l.done {
+		resp, err := l.stream.Recv()
+		if err == io.EOF {
+			l.done = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if resp.MapRoot != nil {
+			l.root = resp.MapRoot
+		}
+		l.page = resp.Leaves
+	}
+	return nil
+}
+
+// peek returns the next leaf, or nil if there are no more leaves.
+func (l *leafLister) peek() (*trillian.MapLeaf, error) {
+	if err := l.fill(); err != nil {
+		return nil, err
+	}
+	if len(l.page) == 0 {
+		return nil, nil
+	}
+	return l.page[0], nil
+}
+
+func (l *leafLister) pop() {
+	l.page = l.page[1:]
+}
+
+// changedLeaves returns the leaves to set in the map listed by dest to give
+// it the leaves listed by source: those which differ, and empty leaves for
+// the indices which only have a value in dest.
+func changedLeaves(source, dest *leafLister) ([]*trillian.MapLeaf, error) {
+	var changed []*trillian.MapLeaf
+	for {
+		s, err := source.peek()
+		if err != nil {
+			return nil, err
+		}
+		d, err := dest.peek()
+		if err != nil {
+			return nil, err
+		}
+		var cmp int
+		switch {
+		case s == nil && d == nil:
+			return changed, nil
+		case s == nil:
+			cmp = 1
+		case d == nil:
+			cmp = -1
+		default:
+			cmp = bytes.Compare(s.Index, d.Index)
+		}
+
+		switch {
+		case cmp < 0:
+			// Empty leaves are the same as missing ones.
+			if len(s.LeafValue) > 0 {
+				changed = append(changed, mirroredLeaf(s))
+			}
+			source.pop()
+		case cmp > 0:
+			if len(d.LeafValue) > 0 {
+				changed = append(changed, &trillian.MapLeaf{Index: d.Index})
+			}
+			dest.pop()
+		default:
+			if !bytes.Equal(s.LeafValue, d.LeafValue) || !bytes.Equal(s.ExtraData, d.ExtraData) {
+				changed = append(changed, mirroredLeaf(s))
+			}
+			source.pop()
+			dest.pop()
+		}
+	}
+}
+
+// mirroredLeaf returns the leaf to write for l, whose hash the destination
+// map computes itself.
+func mirroredLeaf(l *trillian.MapLeaf) *trillian.MapLeaf {
+	return &trillian.MapLeaf{Index: l.Index, LeafValue: l.LeafValue, ExtraData: l.ExtraData}
+}
@@ -0,0 +1,206 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirror
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc"
+
+	mtestonly "github.com/google/trillian/monitoring/testonly"
+)
+
+const (
+	sourceID = 1
+	destID   = 2
+)
+
+func mapRoot(t *testing.T, revision int64, hash string, metadata string) *trillian.SignedMapRoot {
+	t.Helper()
+	b, err := (&types.MapRootV1{RootHash: []byte(hash), Revision: uint64(revision), Metadata: []byte(metadata)}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary(): %v", err)
+	}
+	return &trillian.SignedMapRoot{MapRoot: b}
+}
+
+func leaf(index, value string) *trillian.MapLeaf {
+	return &trillian.MapLeaf{Index: []byte(index), LeafValue: []byte(value)}
+}
+
+// fakeWriteClient checks the write made by a Mirror, as the TrillianMapWrite
+// service has no mock server.
+type fakeWriteClient struct {
+	trillian.TrillianMapWriteClient
+	t     *testing.T
+	want  *trillian.WriteMapLeavesRequest
+	resp  *trillian.WriteMapLeavesResponse
+	calls int
+}
+
+func (f *fakeWriteClient) WriteLeaves(_ context.Context, req *trillian.WriteMapLeavesRequest, _ ...grpc.CallOption) (*trillian.WriteMapLeavesResponse, error) {
+	f.calls++
+	if !proto.Equal(req, f.want) {
+		f.t.Errorf("WriteLeaves(%v), want %v", req, f.want)
+	}
+	return f.resp, nil
+}
+
+// listLeaves serves the leaves of a revision in pages of the requested size,
+// with its root in the first page.
+func listLeaves(root *trillian.SignedMapRoot, leaves []*trillian.MapLeaf) func(*trillian.ListMapLeavesByRevisionRequest, trillian.TrillianMap_ListLeavesByRevisionServer) error {
+	return func(req *trillian.ListMapLeavesByRevisionRequest, stream trillian.TrillianMap_ListLeavesByRevisionServer) error {
+		resp := &trillian.ListMapLeavesByRevisionResponse{MapRoot: root}
+		for rest := leaves; ; {
+			n := int(req.PageSize)
+			if n > len(rest) {
+				n = len(rest)
+			}
+			resp.Leaves, rest = rest[:n], rest[n:]
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+			if len(rest) == 0 {
+				return nil
+			}
+			resp = &trillian.ListMapLeavesByRevisionResponse{}
+		}
+	}
+}
+
+// setUp returns a Mirror between two fake servers, whose maps are at
+// revision 1, and the source map has a revision 2. The destination map has
+// destHash at revision 1, and gets setHash at revision 2.
+func setUp(t *testing.T, ctrl *gomock.Controller, destHash, setHash string, wantWrite bool) (*testonly.MockServer, *Mirror, func()) {
+	t.Helper()
+	src, stopSrc, err := testonly.NewMockServer(ctrl)
+	if err != nil {
+		t.Fatalf("NewMockServer(): %v", err)
+	}
+	dst, stopDst, err := testonly.NewMockServer(ctrl)
+	if err != nil {
+		stopSrc()
+		t.Fatalf("NewMockServer(): %v", err)
+	}
+
+	write := &fakeWriteClient{t: t}
+	src.Map.EXPECT().GetSignedMapRootByRevision(gomock.Any(), &trillian.GetSignedMapRootByRevisionRequest{MapId: sourceID, Revision: 1}).
+		Return(&trillian.GetSignedMapRootResponse{MapRoot: mapRoot(t, 1, "hash1", "meta1")}, nil)
+	dst.Map.EXPECT().GetSignedMapRoot(gomock.Any(), &trillian.GetSignedMapRootRequest{MapId: destID}).
+		Return(&trillian.GetSignedMapRootResponse{MapRoot: mapRoot(t, 1, destHash, "meta1")}, nil)
+
+	if wantWrite {
+		src.Map.EXPECT().ListLeavesByRevision(&trillian.ListMapLeavesByRevisionRequest{MapId: sourceID, Revision: 2, PageSize: 2}, gomock.Any()).
+			DoAndReturn(listLeaves(mapRoot(t, 2, "hash2", "meta2"), []*trillian.MapLeaf{
+				leaf("a", "1"), leaf("b", "3"), leaf("c", "4"), leaf("e", ""),
+			}))
+		dst.Map.EXPECT().ListLeavesByRevision(&trillian.ListMapLeavesByRevisionRequest{MapId: destID, Revision: 1, PageSize: 2}, gomock.Any()).
+			DoAndReturn(listLeaves(mapRoot(t, 1, destHash, "meta1"), []*trillian.MapLeaf{
+				leaf("a", "1"), leaf("b", "2"), leaf("d", "5"),
+			}))
+		write.want = &trillian.WriteMapLeavesRequest{
+			MapId:          destID,
+			Leaves:         []*trillian.MapLeaf{leaf("b", "3"), leaf("c", "4"), {Index: []byte("d")}},
+			Metadata:       []byte("meta2"),
+			ExpectRevision: 2,
+		}
+		write.resp = &trillian.WriteMapLeavesResponse{Revision: 2, RootHash: []byte(setHash)}
+	}
+
+	m, err := New(Config{
+		Source:        src.MapClient,
+		SourceID:      sourceID,
+		Dest:          dst.MapClient,
+		DestWrite:     write,
+		DestID:        destID,
+		PageSize:      2,
+		RetryInterval: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("New(): %v", err)
+	}
+	return src, m, func() {
+		stopDst()
+		stopSrc()
+		want := 0
+		if wantWrite {
+			want = 1
+		}
+		if write.calls != want {
+			t.Errorf("WriteLeaves() called %d times, want %d", write.calls, want)
+		}
+	}
+}
+
+func TestMirrorTo(t *testing.T) {
+	for _, test := range []struct {
+		desc       string
+		destHash   string
+		setHash    string
+		wantWrite  bool
+		wantErr    error
+		wantResult string
+		wantCount  float64
+	}{
+		{desc: "match", destHash: "hash1", setHash: "hash2", wantWrite: true, wantResult: resultMatch, wantCount: 2},
+		{desc: "diverged", destHash: "hash1", setHash: "other", wantWrite: true, wantErr: ErrDiverged, wantResult: resultDiverged, wantCount: 1},
+		{desc: "alreadyDiverged", destHash: "other", wantErr: ErrDiverged, wantResult: resultDiverged, wantCount: 1},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			_, m, stop := setUp(t, ctrl, test.destHash, test.setHash, test.wantWrite)
+			defer stop()
+			results := mtestonly.NewCounterSnapshot(m.counter, "1", test.wantResult)
+
+			if err := m.MirrorTo(ctx, 2); err != test.wantErr {
+				t.Errorf("MirrorTo(): %v, want %v", err, test.wantErr)
+			}
+			if got := results.Delta(); got != test.wantCount {
+				t.Errorf("%v revisions: %v, want %v", test.wantResult, got, test.wantCount)
+			}
+		})
+	}
+}
+
+func TestRun_Diverged(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	src, m, stop := setUp(t, ctrl, "hash1", "other", true)
+	defer stop()
+
+	src.Map.EXPECT().WatchSignedMapRoots(&trillian.WatchSignedMapRootsRequest{MapId: sourceID}, gomock.Any()).
+		DoAndReturn(func(_ *trillian.WatchSignedMapRootsRequest, stream trillian.TrillianMap_WatchSignedMapRootsServer) error {
+			if err := stream.Send(&trillian.WatchSignedMapRootsResponse{MapRoot: mapRoot(t, 2, "hash2", "meta2")}); err != nil {
+				return err
+			}
+			<-stream.Context().Done()
+			return nil
+		})
+
+	if err := m.Run(ctx); err != ErrDiverged {
+		t.Errorf("Run(): %v, want %v", err, ErrDiverged)
+	}
+}
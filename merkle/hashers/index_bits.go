@@ -0,0 +1,92 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hashers
+
+import (
+	"fmt"
+
+	"github.com/google/trillian"
+)
+
+// IndexSize returns the number of bytes of the indices of the leaves of a map
+// hashed by h, which may be fewer than the number of bytes of its hashes.
+func IndexSize(h MapHasher) int {
+	return h.BitLen() / 8
+}
+
+// WithIndexBits returns a MapHasher for maps whose indices are bits long,
+// which must be a multiple of 8 of at most h.BitLen(). Such a map hashes like
+// the top bits levels of a map hashed by h: its leaves are hashed as subtrees
+// of h at height h.BitLen()-bits, and its indices are padded with zeros for h.
+func WithIndexBits(h MapHasher, bits int) (MapHasher, error) {
+	if bits <= 0 || bits%8 != 0 || bits > h.BitLen() {
+		return nil, fmt.Errorf("index bits: %d, want a multiple of 8 from 8 to %d", bits, h.BitLen())
+	}
+	if bits == h.BitLen() {
+		return h, nil
+	}
+	return &indexBitsHasher{MapHasher: h, bits: bits}, nil
+}
+
+// NewMapHasherForTree returns the MapHasher for the hash strategy and index
+// size of tree, a map.
+func NewMapHasherForTree(tree *trillian.Tree) (MapHasher, error) {
+	return MapHasherRegistry(nil).NewMapHasherForTree(tree)
+}
+
+// NewMapHasherForTree returns the MapHasher for the hash strategy and index
+// size of tree, a map, using the hashers in r.
+func (r MapHasherRegistry) NewMapHasherForTree(tree *trillian.Tree) (MapHasher, error) {
+	h, err := r.NewMapHasher(tree.HashStrategy)
+	if err != nil || tree.MapIndexBits == 0 {
+		return h, err
+	}
+	return WithIndexBits(h, int(tree.MapIndexBits))
+}
+
+// indexBitsHasher hashes maps with indices shorter than the hashes of the
+// MapHasher it embeds.
+type indexBitsHasher struct {
+	MapHasher
+	bits int
+}
+
+func (h *indexBitsHasher) String() string {
+	return fmt.Sprintf("%v with %d-bit indices", h.MapHasher, h.bits)
+}
+
+// HashEmpty returns the hash of an empty branch at a given height.
+func (h *indexBitsHasher) HashEmpty(treeID int64, index []byte, height int) []byte {
+	return h.MapHasher.HashEmpty(treeID, h.pad(index), height+h.MapHasher.BitLen()-h.bits)
+}
+
+// HashLeaf computes the hash of a leaf that exists.
+func (h *indexBitsHasher) HashLeaf(treeID int64, index []byte, leaf []byte) []byte {
+	return h.MapHasher.HashLeaf(treeID, h.pad(index), leaf)
+}
+
+// BitLen returns the number of bits of the indices, which is also the height
+// of the map.
+func (h *indexBitsHasher) BitLen() int {
+	return h.bits
+}
+
+// pad returns index padded with zeros to the index size of the embedded
+// MapHasher.
+func (h *indexBitsHasher) pad(index []byte) []byte {
+	padded := make([]byte, IndexSize(h.MapHasher))
+	copy(padded, index)
+	return padded
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hashers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/trillian"
+)
+
+// recordingMapHasher is a 256-bit MapHasher which records the arguments of
+// HashEmpty and HashLeaf.
+type recordingMapHasher struct {
+	MapHasher
+	index  []byte
+	height int
+}
+
+func (h *recordingMapHasher) HashEmpty(treeID int64, index []byte, height int) []byte {
+	h.index, h.height = index, height
+	return nil
+}
+
+func (h *recordingMapHasher) HashLeaf(treeID int64, index []byte, leaf []byte) []byte {
+	h.index = index
+	return nil
+}
+
+func (h *recordingMapHasher) BitLen() int { return 256 }
+
+func TestWithIndexBits(t *testing.T) {
+	base := &recordingMapHasher{}
+	for _, bits := range []int{-8, 0, 100, 264} {
+		if _, err := WithIndexBits(base, bits); err == nil {
+			t.Errorf("WithIndexBits(%d): nil, want err", bits)
+		}
+	}
+	if h, err := WithIndexBits(base, 256); err != nil || h != base {
+		t.Errorf("WithIndexBits(256): %v, %v, want the hasher itself", h, err)
+	}
+
+	h, err := WithIndexBits(base, 160)
+	if err != nil {
+		t.Fatalf("WithIndexBits(160): %v", err)
+	}
+	if got, want := h.BitLen(), 160; got != want {
+		t.Errorf("BitLen(): %d, want %d", got, want)
+	}
+	if got, want := IndexSize(h), 20; got != want {
+		t.Errorf("IndexSize(): %d, want %d", got, want)
+	}
+	index := bytes.Repeat([]byte{0xff}, 20)
+	wantIndex := append(append([]byte{}, index...), make([]byte, 12)...)
+	h.HashEmpty(1, index, 10)
+	if !bytes.Equal(base.index, wantIndex) || base.height != 106 {
+		t.Errorf("HashEmpty(%x, 10) hashed %x at height %d, want %x at height 106", index, base.index, base.height, wantIndex)
+	}
+	h.HashLeaf(1, index, []byte("leaf"))
+	if !bytes.Equal(base.index, wantIndex) {
+		t.Errorf("HashLeaf(%x) hashed index %x, want %x", index, base.index, wantIndex)
+	}
+}
+
+func TestNewMapHasherForTree(t *testing.T) {
+	const strategy = trillian.HashStrategy(1000)
+	base := &recordingMapHasher{}
+	r := MapHasherRegistry{strategy: base}
+	for _, tc := range []struct {
+		bits    int32
+		want    int
+		wantErr bool
+	}{
+		{bits: 0, want: 256},
+		{bits: 160, want: 160},
+		{bits: 12, wantErr: true},
+	} {
+		h, err := r.NewMapHasherForTree(&trillian.Tree{HashStrategy: strategy, MapIndexBits: tc.bits})
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("NewMapHasherForTree(%d bits): %v, want err? %t", tc.bits, err, tc.wantErr)
+			continue
+		}
+		if err == nil && h.BitLen() != tc.want {
+			t.Errorf("NewMapHasherForTree(%d bits).BitLen(): %d, want %d", tc.bits, h.BitLen(), tc.want)
+		}
+	}
+}
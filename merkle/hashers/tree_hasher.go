@@ -36,7 +36,7 @@ type LogHasher interface {
 // MapHasher provides the hash functions needed to compute sparse merkle trees.
 type MapHasher interface {
 	// HashEmpty returns the hash of an empty branch at a given depth.
-	// A height of 0 indicates an empty leaf. The maximum height is Size*8.
+	// A height of 0 indicates an empty leaf. The maximum height is BitLen.
 	// TODO(gbelvin) fully define index.
 	HashEmpty(treeID int64, index []byte, height int) []byte
 	// HashLeaf computes the hash of a leaf that exists.  This method
@@ -52,8 +52,9 @@ type MapHasher interface {
 	// Size is the number of bytes in the underlying hash function.
 	// TODO(gbelvin): Replace Size() with BitLength().
 	Size() int
-	// BitLen returns the number of bits in the underlying hash function.
-	// It is also the height of the merkle tree.
+	// BitLen returns the number of bits of the indices of the map, which is
+	// also the height of the merkle tree. It is the number of bits in the
+	// underlying hash function, unless the hasher is from WithIndexBits.
 	BitLen() int
 }
 
@@ -101,3 +102,19 @@ func NewMapHasher(h trillian.HashStrategy) (MapHasher, error) {
 	}
 	return nil, fmt.Errorf("MapHasher(%s) is an unknown hasher", h)
 }
+
+// MapHasherRegistry holds MapHashers which are used instead of, or as well
+// as, the ones registered with RegisterMapHasher. It allows a deployment to
+// use its own hashers, possibly for hash strategies which are not defined in
+// the API, without changing global state. A nil MapHasherRegistry only holds
+// the registered hashers.
+type MapHasherRegistry map[trillian.HashStrategy]MapHasher
+
+// NewMapHasher returns the MapHasher for h in r, or the one registered with
+// RegisterMapHasher if r has none.
+func (r MapHasherRegistry) NewMapHasher(h trillian.HashStrategy) (MapHasher, error) {
+	if f := r[h]; f != nil {
+		return f, nil
+	}
+	return NewMapHasher(h)
+}
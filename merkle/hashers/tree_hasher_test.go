@@ -0,0 +1,67 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hashers
+
+import (
+	"testing"
+
+	"github.com/google/trillian"
+)
+
+// fakeMapHasher is a MapHasher which is only compared by identity.
+type fakeMapHasher struct {
+	MapHasher
+	name string
+}
+
+func TestMapHasherRegistry(t *testing.T) {
+	const (
+		registered = trillian.HashStrategy_TEST_MAP_HASHER
+		custom     = trillian.HashStrategy(1000)
+	)
+	global := &fakeMapHasher{name: "global"}
+	mapHashers[registered] = global
+	defer delete(mapHashers, registered)
+
+	override := &fakeMapHasher{name: "override"}
+	poseidon := &fakeMapHasher{name: "poseidon"}
+	r := MapHasherRegistry{custom: poseidon}
+
+	for _, test := range []struct {
+		desc     string
+		r        MapHasherRegistry
+		strategy trillian.HashStrategy
+		want     MapHasher
+	}{
+		{desc: "nil registry", strategy: registered, want: global},
+		{desc: "nil registry custom", strategy: custom},
+		{desc: "custom", r: r, strategy: custom, want: poseidon},
+		{desc: "fallback", r: r, strategy: registered, want: global},
+		{desc: "override", r: MapHasherRegistry{registered: override}, strategy: registered, want: override},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := test.r.NewMapHasher(test.strategy)
+			if test.want == nil {
+				if err == nil {
+					t.Errorf("NewMapHasher(%v)=%v, want error", test.strategy, got)
+				}
+				return
+			}
+			if err != nil || got != test.want {
+				t.Errorf("NewMapHasher(%v)=%v, %v, want %v", test.strategy, got, err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/hashers"
+)
+
+// MapConsistencyProof builds a proof that a map revision differs from an
+// earlier one only at indices, from the inclusion proofs of indices at the
+// later revision, keyed by index. The proof consists of the hashes of the
+// largest subtrees which contain none of indices, in ascending index order.
+// It can be verified with VerifyMapConsistencyProof.
+func MapConsistencyProof(indices [][]byte, inclusions map[string][][]byte) ([][]byte, error) {
+	sorted := sortedIndices(indices)
+	for _, index := range sorted {
+		if got, want := len(inclusions[string(index)]), len(index)*8; got != want {
+			return nil, fmt.Errorf("inclusion proof for %x has %d hashes, want %d", index, got, want)
+		}
+	}
+	var proof [][]byte
+	var walk func(depth int, indices [][]byte)
+	walk = func(depth int, indices [][]byte) {
+		bitLen := len(indices[0]) * 8
+		if depth == bitLen {
+			return
+		}
+		split := sort.Search(len(indices), func(i int) bool { return bit(indices[i], depth) })
+		left, right := indices[:split], indices[split:]
+		// A child without any of indices is a sibling of the path from the
+		// other child to its leaves, so its hash is in their inclusion proofs.
+		height := bitLen - depth - 1
+		if len(left) == 0 {
+			proof = append(proof, inclusions[string(right[0])][height])
+		} else {
+			walk(depth+1, left)
+		}
+		if len(right) == 0 {
+			proof = append(proof, inclusions[string(left[0])][height])
+		} else {
+			walk(depth+1, right)
+		}
+	}
+	if len(sorted) > 0 {
+		walk(0, sorted)
+	}
+	return proof, nil
+}
+
+// VerifyMapConsistencyProof verifies that the maps with roots rootBefore and
+// rootAfter differ at most at the indices of the leaves in before and after,
+// which hold the leaves at each index in the earlier and later map
+// respectively. The two slices must list the same indices in the same order.
+//
+// Returns nil on a successful verification, and an error otherwise.
+func VerifyMapConsistencyProof(treeID int64, before, after []*trillian.MapLeaf, rootBefore, rootAfter []byte, proof [][]byte, h hashers.MapHasher) error {
+	if got, want := len(after), len(before); got != want {
+		return fmt.Errorf("got %d leaves after, want %d", got, want)
+	}
+	if len(before) == 0 {
+		if len(proof) != 0 {
+			return errors.New("no leaves, but proof is non-empty")
+		}
+		if !bytes.Equal(rootBefore, rootAfter) {
+			return fmt.Errorf("roots differ with no changed leaves: %x, %x", rootBefore, rootAfter)
+		}
+		return nil
+	}
+
+	changes := make([]leafChange, len(before))
+	for i := range before {
+		index := before[i].Index
+		if got, want := len(index)*8, h.BitLen(); got != want {
+			return fmt.Errorf("leaf %d: index len: %d, want %d", i, got, want)
+		}
+		if !bytes.Equal(after[i].Index, index) {
+			return fmt.Errorf("leaf %d: index after: %x, want %x", i, after[i].Index, index)
+		}
+		changes[i] = leafChange{
+			index:  index,
+			before: mapLeafHash(treeID, before[i], h),
+			after:  mapLeafHash(treeID, after[i], h),
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return bytes.Compare(changes[i].index, changes[j].index) < 0 })
+	for i := 1; i < len(changes); i++ {
+		if bytes.Equal(changes[i-1].index, changes[i].index) {
+			return fmt.Errorf("duplicate index %x", changes[i].index)
+		}
+	}
+	for i, element := range proof {
+		if got, want := len(element), h.Size(); got != 0 && got != want {
+			return fmt.Errorf("proof[%d] len: %d, want 0 or %d", i, got, want)
+		}
+	}
+
+	v := consistencyVerifier{treeID: treeID, h: h, proof: proof}
+	gotBefore, gotAfter, err := v.subtree(0, make([]byte, hashers.IndexSize(h)), changes)
+	if err != nil {
+		return err
+	}
+	if len(v.proof) != 0 {
+		return fmt.Errorf("%d unused proof hashes", len(v.proof))
+	}
+	gotBefore = v.orEmpty(gotBefore, make([]byte, hashers.IndexSize(h)), 0)
+	gotAfter = v.orEmpty(gotAfter, make([]byte, hashers.IndexSize(h)), 0)
+	if !bytes.Equal(gotBefore, rootBefore) {
+		return fmt.Errorf("calculated root before: %x, want: %x", gotBefore, rootBefore)
+	}
+	if !bytes.Equal(gotAfter, rootAfter) {
+		return fmt.Errorf("calculated root after: %x, want: %x", gotAfter, rootAfter)
+	}
+	return nil
+}
+
+// leafChange holds the leaf hashes at an index before and after a change. A
+// nil hash means there is no leaf.
+type leafChange struct {
+	index, before, after []byte
+}
+
+// consistencyVerifier computes the roots of a map before and after a set of
+// leaf changes, consuming the hashes of the unchanged subtrees from proof.
+type consistencyVerifier struct {
+	treeID int64
+	h      hashers.MapHasher
+	proof  [][]byte
+	// prefixBits, if positive, is the depth of an index prefix which all the
+	// changes are under. The subtrees below it taken from proof must then be
+	// empty.
+	prefixBits int
+}
+
+// subtree returns the hashes of the subtree at depth whose leftmost index is
+// path, before and after the changes, which all belong to it. As in HStar2, a
+// nil hash means the subtree is empty.
+func (v *consistencyVerifier) subtree(depth int, path []byte, changes []leafChange) ([]byte, []byte, error) {
+	if depth == v.h.BitLen() {
+		return changes[0].before, changes[0].after, nil
+	}
+	split := sort.Search(len(changes), func(i int) bool { return bit(changes[i].index, depth) })
+	rightPath := append([]byte(nil), path...)
+	rightPath[depth/8] |= 0x80 >> uint(depth%8)
+
+	lb, la, err := v.child(depth+1, path, changes[:split])
+	if err != nil {
+		return nil, nil, err
+	}
+	rb, ra, err := v.child(depth+1, rightPath, changes[split:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return v.combine(depth+1, path, rightPath, lb, rb), v.combine(depth+1, path, rightPath, la, ra), nil
+}
+
+// child returns the hashes of a child subtree before and after the changes,
+// which are taken from the proof if there are none in it.
+func (v *consistencyVerifier) child(depth int, path []byte, changes []leafChange) ([]byte, []byte, error) {
+	if len(changes) > 0 {
+		return v.subtree(depth, path, changes)
+	}
+	if len(v.proof) == 0 {
+		return nil, nil, errors.New("proof too short")
+	}
+	hash := v.proof[0]
+	v.proof = v.proof[1:]
+	if len(hash) == 0 {
+		return nil, nil, nil
+	}
+	if v.prefixBits > 0 && depth > v.prefixBits {
+		return nil, nil, fmt.Errorf("non-empty subtree under the prefix at depth %d", depth)
+	}
+	return hash, hash, nil
+}
+
+// combine returns the hash of the parent of the children at depth, or nil if
+// both are empty.
+func (v *consistencyVerifier) combine(depth int, leftPath, rightPath, left, right []byte) []byte {
+	if left == nil && right == nil {
+		return nil
+	}
+	return v.h.HashChildren(v.orEmpty(left, leftPath, depth), v.orEmpty(right, rightPath, depth))
+}
+
+// orEmpty returns hash, or the hash of the empty subtree at depth whose
+// leftmost index is path if hash is nil.
+func (v *consistencyVerifier) orEmpty(hash, path []byte, depth int) []byte {
+	if hash != nil {
+		return hash
+	}
+	return v.h.HashEmpty(v.treeID, path, v.h.BitLen()-depth)
+}
+
+// mapLeafHash returns the hash of leaf, or nil if it is an empty value that
+// has never been set.
+func mapLeafHash(treeID int64, leaf *trillian.MapLeaf, h hashers.MapHasher) []byte {
+	if len(leaf.LeafValue) == 0 && len(leaf.LeafHash) == 0 {
+		return nil
+	}
+	return h.HashLeaf(treeID, leaf.Index, leaf.LeafValue)
+}
+
+// sortedIndices returns a sorted copy of indices.
+func sortedIndices(indices [][]byte) [][]byte {
+	sorted := append([][]byte(nil), indices...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+	return sorted
+}
+
+// bit returns whether the bit of index at depth, counted from the most
+// significant bit, is set.
+func bit(index []byte, depth int) bool {
+	return index[depth/8]&(0x80>>uint(depth%8)) != 0
+}
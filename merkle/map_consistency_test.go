@@ -0,0 +1,228 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/coniks"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/merkle/maphasher"
+)
+
+const consistencyTreeID = 42
+
+// testMap is an in-memory sparse Merkle tree, which serves inclusion proofs.
+type testMap struct {
+	h      hashers.MapHasher
+	root   []byte
+	values map[string]string
+	leaves map[string][]byte
+	nodes  map[string][]byte
+}
+
+func newTestMap(t *testing.T, h hashers.MapHasher, values map[string]string) *testMap {
+	t.Helper()
+	m := &testMap{h: h, values: values, leaves: make(map[string][]byte), nodes: make(map[string][]byte)}
+	var hashes []*HStar2LeafHash
+	for index, value := range values {
+		hash := h.HashLeaf(consistencyTreeID, []byte(index), []byte(value))
+		m.leaves[index] = hash
+		hashes = append(hashes, &HStar2LeafHash{Index: new(big.Int).SetBytes([]byte(index)), LeafHash: hash})
+	}
+	hs2 := NewHStar2(consistencyTreeID, h)
+	set := func(depth int, index *big.Int, hash []byte) error {
+		m.nodes[m.nodeKey(depth, index.Bytes())] = hash
+		return nil
+	}
+	root, err := hs2.HStar2Nodes(nil, h.BitLen(), hashes, nil, set)
+	if err != nil {
+		t.Fatalf("HStar2Nodes(): %v", err)
+	}
+	m.root = root
+	return m
+}
+
+func (m *testMap) nodeKey(depth int, index []byte) string {
+	padded := make([]byte, m.h.Size())
+	copy(padded[len(padded)-len(index):], index)
+	return fmt.Sprintf("%d:%x", depth, padded)
+}
+
+func (m *testMap) leaf(index []byte) *trillian.MapLeaf {
+	leaf := &trillian.MapLeaf{Index: index}
+	if hash, ok := m.leaves[string(index)]; ok {
+		leaf.LeafHash = hash
+		leaf.LeafValue = []byte(m.values[string(index)])
+	}
+	return leaf
+}
+
+// inclusion returns the inclusion proof of index, with nil for empty subtrees.
+func (m *testMap) inclusion(index []byte) [][]byte {
+	bitLen := m.h.BitLen()
+	proof := make([][]byte, bitLen)
+	for height := range proof {
+		depth := bitLen - height
+		sib := append([]byte(nil), index...)
+		sib[(depth-1)/8] ^= 0x80 >> uint((depth-1)%8)
+		for d := depth; d < bitLen; d++ {
+			sib[d/8] &^= 0x80 >> uint(d%8)
+		}
+		if depth == bitLen {
+			proof[height] = m.leaves[string(sib)]
+		} else {
+			proof[height] = m.nodes[m.nodeKey(depth, sib)]
+		}
+	}
+	return proof
+}
+
+func testIndex(name string) []byte {
+	index := sha256.Sum256([]byte(name))
+	return index[:]
+}
+
+// neighbour returns the index which differs from index in its last bit.
+func neighbour(index []byte) []byte {
+	n := append([]byte(nil), index...)
+	n[len(n)-1] ^= 1
+	return n
+}
+
+func TestMapConsistencyProof(t *testing.T) {
+	var (
+		a, b, c, d = testIndex("a"), testIndex("b"), testIndex("c"), testIndex("d")
+		nA         = neighbour(a)
+		unset      = testIndex("unset")
+	)
+	before := map[string]string{string(a): "a", string(b): "b", string(c): "c"}
+	// The later map changes b, and adds the neighbour of a and d.
+	after := map[string]string{string(a): "a", string(b): "b2", string(c): "c", string(nA): "nA", string(d): "d"}
+
+	for _, test := range []struct {
+		desc    string
+		before  map[string]string
+		after   map[string]string
+		indices [][]byte
+		// claim, if set, replaces the value of the leaf at indices[0] after.
+		claim   []byte
+		tamper  func([][]byte) [][]byte
+		wantErr bool
+	}{
+		{desc: "noChange", before: before, after: before},
+		{desc: "noChangeUnsetIndex", before: before, after: before, indices: [][]byte{unset}},
+		{desc: "fromEmpty", before: map[string]string{}, after: after, indices: [][]byte{a, b, c, nA, d}},
+		{desc: "changes", before: before, after: after, indices: [][]byte{d, b, nA}},
+		{desc: "extraIndices", before: before, after: after, indices: [][]byte{b, unset, nA, a, d}},
+		{desc: "missingChange", before: before, after: after, indices: [][]byte{b, nA}, wantErr: true},
+		{desc: "missingAll", before: before, after: after, wantErr: true},
+		{desc: "wrongClaim", before: before, after: after, indices: [][]byte{b, nA, d}, claim: []byte("b3"), wantErr: true},
+		{
+			desc: "truncatedProof", before: before, after: after, indices: [][]byte{b, nA, d},
+			tamper:  func(p [][]byte) [][]byte { return p[:len(p)-1] },
+			wantErr: true,
+		},
+		{
+			desc: "extendedProof", before: before, after: after, indices: [][]byte{b, nA, d},
+			tamper:  func(p [][]byte) [][]byte { return append(p, nil) },
+			wantErr: true,
+		},
+		{
+			desc: "emptiedProof", before: before, after: after, indices: [][]byte{b, nA, d},
+			tamper: func(p [][]byte) [][]byte {
+				for i := range p {
+					if len(p[i]) != 0 {
+						p[i] = nil
+						break
+					}
+				}
+				return p
+			},
+			wantErr: true,
+		},
+	} {
+		for _, h := range []hashers.MapHasher{maphasher.Default, coniks.Default} {
+			t.Run(fmt.Sprintf("%s/%T", test.desc, h), func(t *testing.T) {
+				first, second := newTestMap(t, h, test.before), newTestMap(t, h, test.after)
+
+				inclusions := make(map[string][][]byte)
+				var leavesBefore, leavesAfter []*trillian.MapLeaf
+				for _, index := range test.indices {
+					inclusions[string(index)] = second.inclusion(index)
+					leaf := second.leaf(index)
+					if err := VerifyMapInclusionProof(consistencyTreeID, leaf, second.root, inclusions[string(index)], h); err != nil {
+						t.Fatalf("VerifyMapInclusionProof(%x): %v", index, err)
+					}
+					leavesBefore = append(leavesBefore, first.leaf(index))
+					leavesAfter = append(leavesAfter, leaf)
+				}
+				if test.claim != nil {
+					leavesAfter[0] = &trillian.MapLeaf{Index: test.indices[0], LeafValue: test.claim}
+				}
+
+				proof, err := MapConsistencyProof(test.indices, inclusions)
+				if err != nil {
+					t.Fatalf("MapConsistencyProof(): %v", err)
+				}
+				if test.tamper != nil {
+					proof = test.tamper(proof)
+				}
+				err = VerifyMapConsistencyProof(consistencyTreeID, leavesBefore, leavesAfter, first.root, second.root, proof, h)
+				if gotErr := err != nil; gotErr != test.wantErr {
+					t.Errorf("VerifyMapConsistencyProof(): %v, want error: %v", err, test.wantErr)
+				}
+			})
+		}
+	}
+}
+
+func TestVerifyMapConsistencyProofErrors(t *testing.T) {
+	h := maphasher.Default
+	a, b := testIndex("a"), testIndex("b")
+	m := newTestMap(t, h, map[string]string{string(a): "a"})
+	for _, test := range []struct {
+		desc          string
+		before, after []*trillian.MapLeaf
+		proof         [][]byte
+	}{
+		{desc: "leafCount", before: []*trillian.MapLeaf{{Index: a}}},
+		{desc: "indexMismatch", before: []*trillian.MapLeaf{{Index: a}}, after: []*trillian.MapLeaf{{Index: b}}},
+		{desc: "indexSize", before: []*trillian.MapLeaf{{Index: a[1:]}}, after: []*trillian.MapLeaf{{Index: a[1:]}}},
+		{
+			desc:   "duplicateIndex",
+			before: []*trillian.MapLeaf{{Index: a}, {Index: a}},
+			after:  []*trillian.MapLeaf{{Index: a}, {Index: a}},
+			proof:  m.inclusion(a),
+		},
+		{
+			desc:   "proofElementSize",
+			before: []*trillian.MapLeaf{{Index: a}},
+			after:  []*trillian.MapLeaf{{Index: a}},
+			proof:  [][]byte{{1, 2, 3}},
+		},
+		{desc: "noLeavesWithProof", proof: [][]byte{m.root}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if err := VerifyMapConsistencyProof(consistencyTreeID, test.before, test.after, m.root, m.root, test.proof, h); err == nil {
+				t.Error("VerifyMapConsistencyProof() succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/hashers"
+)
+
+// MapPrefixProofIndices returns the indices which the proof of the leaves
+// under prefix is built for with MapConsistencyProof: those of the leaves, or
+// the first index under prefix if there are none. size is the size of an
+// index in bytes.
+func MapPrefixProofIndices(prefix []byte, size int, leaves []*trillian.MapLeaf) [][]byte {
+	if len(leaves) == 0 {
+		first := make([]byte, size)
+		copy(first, prefix)
+		return [][]byte{first}
+	}
+	indices := make([][]byte, 0, len(leaves))
+	for _, l := range leaves {
+		indices = append(indices, l.Index)
+	}
+	return indices
+}
+
+// VerifyMapPrefixProof verifies that leaves, in ascending index order, are all
+// the leaves stored under the index prefix of the map with root expectedRoot.
+// The proof is built by MapConsistencyProof for MapPrefixProofIndices, and
+// may only have empty hashes for the subtrees under prefix.
+//
+// Returns nil on a successful verification, and an error otherwise.
+func VerifyMapPrefixProof(treeID int64, prefix []byte, leaves []*trillian.MapLeaf, expectedRoot []byte, proof [][]byte, h hashers.MapHasher) error {
+	if len(prefix) == 0 || len(prefix) >= hashers.IndexSize(h) {
+		return fmt.Errorf("prefix len: %d, want 1 to %d", len(prefix), hashers.IndexSize(h)-1)
+	}
+	changes := make([]leafChange, 0, len(leaves))
+	for i, l := range leaves {
+		if got, want := len(l.Index)*8, h.BitLen(); got != want {
+			return fmt.Errorf("leaf %d: index len: %d, want %d", i, got, want)
+		}
+		if !bytes.HasPrefix(l.Index, prefix) {
+			return fmt.Errorf("leaf %d: index %x is not under prefix %x", i, l.Index, prefix)
+		}
+		if i > 0 && bytes.Compare(leaves[i-1].Index, l.Index) >= 0 {
+			return fmt.Errorf("leaf %d: index %x out of order", i, l.Index)
+		}
+		// All the leaves were stored, so even cleared ones have a leaf hash.
+		hash := h.HashLeaf(treeID, l.Index, l.LeafValue)
+		changes = append(changes, leafChange{index: l.Index, before: hash, after: hash})
+	}
+	if len(changes) == 0 {
+		changes = append(changes, leafChange{index: MapPrefixProofIndices(prefix, hashers.IndexSize(h), nil)[0]})
+	}
+	for i, element := range proof {
+		if got, want := len(element), h.Size(); got != 0 && got != want {
+			return fmt.Errorf("proof[%d] len: %d, want 0 or %d", i, got, want)
+		}
+	}
+
+	v := consistencyVerifier{treeID: treeID, h: h, proof: proof, prefixBits: len(prefix) * 8}
+	root, _, err := v.subtree(0, make([]byte, hashers.IndexSize(h)), changes)
+	if err != nil {
+		return err
+	}
+	if len(v.proof) != 0 {
+		return fmt.Errorf("%d unused proof hashes", len(v.proof))
+	}
+	root = v.orEmpty(root, make([]byte, hashers.IndexSize(h)), 0)
+	if !bytes.Equal(root, expectedRoot) {
+		return fmt.Errorf("calculated root: %x, want: %x", root, expectedRoot)
+	}
+	return nil
+}
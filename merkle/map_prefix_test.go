@@ -0,0 +1,87 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/coniks"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/merkle/maphasher"
+)
+
+// prefixedIndex returns a test index which starts with prefix.
+func prefixedIndex(prefix byte, name string) []byte {
+	index := testIndex(name)
+	index[0] = prefix
+	return index
+}
+
+func TestVerifyMapPrefixProof(t *testing.T) {
+	var (
+		a, b, cleared = prefixedIndex(1, "a"), prefixedIndex(1, "b"), prefixedIndex(1, "cleared")
+		other         = prefixedIndex(2, "other")
+	)
+	values := map[string]string{string(a): "a", string(b): "b", string(cleared): "", string(other): "other"}
+	sorted := sortedIndices([][]byte{a, b, cleared})
+
+	for _, test := range []struct {
+		desc    string
+		prefix  []byte
+		indices [][]byte
+		// claim, if set, replaces the value of the first leaf.
+		claim   []byte
+		wantErr bool
+	}{
+		{desc: "all", prefix: []byte{1}, indices: sorted},
+		{desc: "empty", prefix: []byte{3}},
+		{desc: "missingLeaf", prefix: []byte{1}, indices: sortedIndices([][]byte{a, cleared}), wantErr: true},
+		{desc: "missingClearedLeaf", prefix: []byte{1}, indices: sortedIndices([][]byte{a, b}), wantErr: true},
+		{desc: "missingAll", prefix: []byte{1}, wantErr: true},
+		{desc: "outsidePrefix", prefix: []byte{2}, indices: [][]byte{a}, wantErr: true},
+		{desc: "outOfOrder", prefix: []byte{1}, indices: [][]byte{sorted[1], sorted[0], sorted[2]}, wantErr: true},
+		{desc: "wrongClaim", prefix: []byte{1}, indices: sorted, claim: []byte("x"), wantErr: true},
+		{desc: "emptyPrefix", indices: sorted, wantErr: true},
+	} {
+		for _, h := range []hashers.MapHasher{maphasher.Default, coniks.Default} {
+			t.Run(fmt.Sprintf("%s/%T", test.desc, h), func(t *testing.T) {
+				m := newTestMap(t, h, values)
+				var leaves []*trillian.MapLeaf
+				for _, index := range test.indices {
+					leaves = append(leaves, m.leaf(index))
+				}
+				indices := MapPrefixProofIndices(test.prefix, h.Size(), leaves)
+				inclusions := make(map[string][][]byte)
+				for _, index := range indices {
+					inclusions[string(index)] = m.inclusion(index)
+				}
+				proof, err := MapConsistencyProof(indices, inclusions)
+				if err != nil {
+					t.Fatalf("MapConsistencyProof(): %v", err)
+				}
+				if test.claim != nil {
+					leaves[0] = &trillian.MapLeaf{Index: leaves[0].Index, LeafValue: test.claim}
+				}
+
+				err = VerifyMapPrefixProof(consistencyTreeID, test.prefix, leaves, m.root, proof, h)
+				if gotErr := err != nil; gotErr != test.wantErr {
+					t.Errorf("VerifyMapPrefixProof(): %v, want error: %v", err, test.wantErr)
+				}
+			})
+		}
+	}
+}
@@ -31,7 +31,17 @@ import (
 //
 // Returns nil on a successful verification, and an error otherwise.
 func VerifyMapInclusionProof(treeID int64, leaf *trillian.MapLeaf, expectedRoot []byte, proof [][]byte, h hashers.MapHasher) error {
-	if got, want := len(leaf.Index)*8, h.BitLen(); got != want {
+	// An empty value that has never been set has a LeafHash of nil (indicating
+	// that the effective hash value is h.HashEmpty(index, 0)).
+	return VerifyMapInclusionProofForHash(treeID, leaf.Index, mapLeafHash(treeID, leaf, h), expectedRoot, proof, h)
+}
+
+// VerifyMapInclusionProofForHash verifies that the passed in expectedRoot can
+// be reconstructed from the hash of the leaf at index, which is nil for leaves
+// that have never been set, and proof. It is used where only the leaf hash is
+// known, e.g. by servers checking the leaves they have just written.
+func VerifyMapInclusionProofForHash(treeID int64, index, leafHash, expectedRoot []byte, proof [][]byte, h hashers.MapHasher) error {
+	if got, want := len(index)*8, h.BitLen(); got != want {
 		return fmt.Errorf("index len: %d, want %d", got, want)
 	}
 	if got, want := len(proof), h.BitLen(); got != want {
@@ -43,15 +53,8 @@ func VerifyMapInclusionProof(treeID int64, leaf *trillian.MapLeaf, expectedRoot
 		}
 	}
 
-	leafHash := h.HashLeaf(treeID, leaf.Index, leaf.LeafValue)
-	if len(leaf.LeafValue) == 0 && len(leaf.LeafHash) == 0 {
-		// This is an empty value that has never been set, and so has a LeafHash of nil
-		// (indicating that the effective hash value is h.HashEmpty(index, 0)).
-		leafHash = nil
-	}
-
 	runningHash := leafHash
-	nID := tree.NewNodeIDFromHash(leaf.Index)
+	nID := tree.NewNodeIDFromHash(index)
 	for height, sib := range nID.Siblings() {
 		pElement := proof[height]
 
@@ -15,10 +15,12 @@
 package merkle
 
 import (
+	"math/big"
 	"testing"
 
 	"github.com/google/trillian"
 	"github.com/google/trillian/merkle/coniks"
+	"github.com/google/trillian/merkle/hashers"
 	"github.com/google/trillian/merkle/maphasher"
 	"github.com/google/trillian/testonly"
 )
@@ -152,3 +154,54 @@ func TestMapHasherTestVectors(t *testing.T) {
 		}
 	}
 }
+
+func TestVerifyMapInclusionProofIndexBits(t *testing.T) {
+	const treeID = 42
+	for _, base := range []hashers.MapHasher{maphasher.Default, coniks.Default} {
+		h, err := hashers.WithIndexBits(base, 160)
+		if err != nil {
+			t.Fatalf("WithIndexBits(): %v", err)
+		}
+		// Two leaves in either half of the map, so that each is the only
+		// non-empty sibling in the proof of the other.
+		leaves := []*trillian.MapLeaf{
+			{Index: append([]byte{0x12}, make([]byte, 19)...), LeafValue: []byte("left")},
+			{Index: append([]byte{0x87}, make([]byte, 19)...), LeafValue: []byte("right")},
+		}
+		var values []*HStar2LeafHash
+		for _, l := range leaves {
+			values = append(values, &HStar2LeafHash{Index: new(big.Int).SetBytes(l.Index), LeafHash: h.HashLeaf(treeID, l.Index, l.LeafValue)})
+		}
+		// nodes holds the hashes of the nodes on the top 8 levels.
+		nodes := make(map[[2]int64][]byte)
+		hs2 := NewHStar2(treeID, h)
+		root, err := hs2.HStar2Nodes(nil, h.BitLen(), values, nil, func(depth int, index *big.Int, hash []byte) error {
+			if depth <= 8 {
+				index = new(big.Int).Rsh(index, uint(h.BitLen()-depth))
+				nodes[[2]int64{int64(depth), index.Int64()}] = hash
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("HStar2Nodes(): %v", err)
+		}
+
+		for i, l := range leaves {
+			proof := make([][]byte, h.BitLen())
+			proof[h.BitLen()-1] = nodes[[2]int64{1, int64(1 - i)}]
+			if err := VerifyMapInclusionProof(treeID, l, root, proof, h); err != nil {
+				t.Errorf("%v: VerifyMapInclusionProof(%x): %v", h, l.Index, err)
+			}
+			if err := VerifyMapInclusionProof(treeID, l, root, make([][]byte, base.BitLen()), h); err == nil {
+				t.Errorf("%v: VerifyMapInclusionProof(%x) with %d-bit proof: nil, want err", h, l.Index, base.BitLen())
+			}
+		}
+		absent := &trillian.MapLeaf{Index: append([]byte{0x13}, make([]byte, 19)...)}
+		proof := make([][]byte, h.BitLen())
+		proof[h.BitLen()-1] = nodes[[2]int64{1, 1}]
+		proof[h.BitLen()-8] = nodes[[2]int64{8, 0x12}]
+		if err := VerifyMapInclusionProof(treeID, absent, root, proof, h); err != nil {
+			t.Errorf("%v: VerifyMapInclusionProof(%x) of absent leaf: %v", h, absent.Index, err)
+		}
+	}
+}
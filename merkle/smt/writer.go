@@ -165,7 +165,7 @@ func (s *shardAccessor) Set(id tree.NodeID2, hash []byte) {
 
 // TODO(pavelkalinnikov): Make MapHasher.HashEmpty method take the id directly.
 func hashEmpty(hasher hashers.MapHasher, treeID int64, id tree.NodeID2) []byte {
-	index := make([]byte, hasher.Size())
+	index := make([]byte, hashers.IndexSize(hasher))
 	copy(index, id.FullBytes())
 	if last, bits := id.LastByte(); bits != 0 {
 		index[len(id.FullBytes())] = last
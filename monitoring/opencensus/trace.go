@@ -17,9 +17,11 @@ package opencensus
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"contrib.go.opencensus.io/exporter/stackdriver"
+	"github.com/google/trillian/monitoring"
 	"go.opencensus.io/plugin/ocgrpc"
 	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/stats/view"
@@ -104,10 +106,32 @@ func applyConfig(percent int) error {
 	return nil
 }
 
-// StartSpan starts a new tracing span.
+// StartSpan starts a new tracing span, annotated with the given attributes.
 // The returned context should be used for all child calls within the span, and
 // the returned func should be called to close the span.
-func StartSpan(ctx context.Context, name string) (context.Context, func()) {
+func StartSpan(ctx context.Context, name string, attrs ...monitoring.Attribute) (context.Context, func()) {
 	ctx, span := trace.StartSpan(ctx, name)
+	if len(attrs) > 0 {
+		span.AddAttributes(spanAttributes(attrs)...)
+	}
 	return ctx, span.End
 }
+
+// spanAttributes converts attributes to OpenCensus ones. Attributes with values
+// of other types are formatted as strings.
+func spanAttributes(attrs []monitoring.Attribute) []trace.Attribute {
+	ret := make([]trace.Attribute, 0, len(attrs))
+	for _, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			ret = append(ret, trace.StringAttribute(a.Key, v))
+		case int64:
+			ret = append(ret, trace.Int64Attribute(a.Key, v))
+		case bool:
+			ret = append(ret, trace.BoolAttribute(a.Key, v))
+		default:
+			ret = append(ret, trace.StringAttribute(a.Key, fmt.Sprint(v)))
+		}
+	}
+	return ret
+}
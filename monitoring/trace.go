@@ -18,29 +18,79 @@ import (
 	"context"
 )
 
-// startSpanFunc is the signature of a function which can start tracing spans.
-type startSpanFunc func(context.Context, string) (context.Context, func())
+// Keys of the attributes which the spans of RPCs are annotated with.
+const (
+	// TreeIDKey is the key of the ID of the tree of the request.
+	TreeIDKey = "trillian.tree_id"
+	// RPCKey is the key of the name of the RPC method, or of its step.
+	RPCKey = "trillian.rpc"
+	// RevisionKey is the key of the revision or tree size requested.
+	RevisionKey = "trillian.revision"
+	// BatchSizeKey is the key of the number of leaves or indices requested.
+	BatchSizeKey = "trillian.batch_size"
+)
+
+// Attribute is a key and value annotating a tracing span. Value is a string,
+// an int64 or a bool.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// StringAttribute returns an Attribute with a string value.
+func StringAttribute(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int64Attribute returns an Attribute with an int64 value.
+func Int64Attribute(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
 
-var startSpan startSpanFunc = noopStartSpan
+// Tracer starts tracing spans. It can be implemented to plug in a tracing
+// library, e.g. OpenTelemetry, in place of the OpenCensus support in the
+// opencensus package.
+type Tracer interface {
+	// StartSpan starts a new span with the given name and attributes. The
+	// returned context should be used for all child calls within the span,
+	// and the returned func should be called to close the span.
+	StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, func())
+}
+
+// TracerFunc is a function implementing Tracer.
+type TracerFunc func(ctx context.Context, name string, attrs ...Attribute) (context.Context, func())
+
+// StartSpan calls f.
+func (f TracerFunc) StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, func()) {
+	return f(ctx, name, attrs...)
+}
+
+var tracer Tracer = TracerFunc(noopStartSpan)
 
 // noopStartSpan is a span starting function which does nothing, and is used as
 // the default implementation.
-func noopStartSpan(ctx context.Context, _ string) (context.Context, func()) {
+func noopStartSpan(ctx context.Context, _ string, _ ...Attribute) (context.Context, func()) {
 	return ctx, func() {}
 }
 
-// StartSpan starts a new tracing span using the given message.
+// StartSpan starts a new tracing span using the given message, annotated with
+// the given attributes.
 // The returned context should be used for all child calls within the span, and
 // the returned func should be called to close the span.
 //
 // The default implementation of this method is a no-op; insert a real tracing span
-// implementation by setting this global variable to the relevant function at start of day.
-func StartSpan(ctx context.Context, name string) (context.Context, func()) {
-	return startSpan(ctx, name)
+// implementation by calling SetTracer or SetStartSpan at start of day.
+func StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, func()) {
+	return tracer.StartSpan(ctx, name, attrs...)
 }
 
 // SetStartSpan sets the function used to start tracing spans.
 // This may be used to add runtime support for different tracing implementation.
-func SetStartSpan(f startSpanFunc) {
-	startSpan = f
+func SetStartSpan(f func(context.Context, string, ...Attribute) (context.Context, func())) {
+	tracer = TracerFunc(f)
+}
+
+// SetTracer sets the Tracer used to start tracing spans.
+func SetTracer(t Tracer) {
+	tracer = t
 }
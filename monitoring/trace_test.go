@@ -0,0 +1,60 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/trillian/monitoring"
+)
+
+// recordingTracer records the spans it starts, and whether they are ended.
+type recordingTracer struct {
+	names []string
+	attrs [][]monitoring.Attribute
+	ended int
+}
+
+func (r *recordingTracer) StartSpan(ctx context.Context, name string, attrs ...monitoring.Attribute) (context.Context, func()) {
+	r.names = append(r.names, name)
+	r.attrs = append(r.attrs, attrs)
+	return ctx, func() { r.ended++ }
+}
+
+func TestSetTracer(t *testing.T) {
+	r := &recordingTracer{}
+	monitoring.SetTracer(r)
+	defer monitoring.SetStartSpan(func(ctx context.Context, _ string, _ ...monitoring.Attribute) (context.Context, func()) {
+		return ctx, func() {}
+	})
+
+	attrs := []monitoring.Attribute{
+		monitoring.Int64Attribute(monitoring.TreeIDKey, 123),
+		monitoring.StringAttribute(monitoring.RPCKey, "GetLeaves"),
+	}
+	_, end := monitoring.StartSpan(context.Background(), "span", attrs...)
+	end()
+	if got, want := r.names, []string{"span"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("StartSpan() names: %v, want %v", got, want)
+	}
+	if got, want := r.attrs, [][]monitoring.Attribute{attrs}; !reflect.DeepEqual(got, want) {
+		t.Errorf("StartSpan() attributes: %v, want %v", got, want)
+	}
+	if got, want := r.ended, 1; got != want {
+		t.Errorf("spans ended: %d, want %d", got, want)
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 const (
 	collectionTrees = "trees"
+	collectionMaps  = "maps"
 	wildcard        = "-"
 )
 
@@ -39,6 +40,10 @@ var (
 	//    be allowed, but since the ID being queried is already known, so is the collection, so it
 	//    doesn't make much sense.
 	treesUsersRE *regexp.Regexp
+
+	// mapsRE is a regex for quotas/maps/ name filters, which have their own
+	// kinds. Like treesUsersRE, it doesn't check that IDs are int64.
+	mapsRE *regexp.Regexp
 )
 
 func init() {
@@ -51,6 +56,10 @@ func init() {
 	if err != nil {
 		panic(fmt.Sprintf("treesUsersRE: %v", err))
 	}
+	mapsRE, err = regexp.Compile("^quotas/maps/[^/]+/(-|read_leaves|write_leaves|create_revision)/config$")
+	if err != nil {
+		panic(fmt.Sprintf("mapsRE: %v", err))
+	}
 }
 
 // nameFilter represents a config name filter, as used by ListConfigs.
@@ -64,7 +73,7 @@ func init() {
 type nameFilter []string
 
 func newNameFilter(name string) (nameFilter, error) {
-	if !globalRE.MatchString(name) && !treesUsersRE.MatchString(name) {
+	if !globalRE.MatchString(name) && !treesUsersRE.MatchString(name) && !mapsRE.MatchString(name) {
 		return nil, fmt.Errorf("invalid name filter: %q", name)
 	}
 
@@ -72,7 +81,7 @@ func newNameFilter(name string) (nameFilter, error) {
 
 	// Guard against some ambiguous / incorrect wildcards that the regexes won't protect against
 	switch collection := nf[1]; collection {
-	case collectionTrees:
+	case collectionTrees, collectionMaps:
 		id := nf[2]
 		if id == wildcard {
 			break
@@ -43,6 +43,15 @@ func TestNewNameFilter(t *testing.T) {
 		{name: "quotas/users/llama/-/config"}, // all quotas for user "llama"
 		{name: "quotas/users/-/-/config"},     // all users
 
+		{name: "quotas/maps/nan/write_leaves/config", wantErr: true}, // ID must be a number
+		{name: "quotas/maps/12345/read_leaves/config"},
+		{name: "quotas/maps/12345/write_leaves/config"},
+		{name: "quotas/maps/12345/create_revision/config"},
+		{name: "quotas/maps/-/write_leaves/config"}, // all maps/write_leaves
+		{name: "quotas/maps/12345/-/config"},        // all quotas for map 12345
+		{name: "quotas/maps/12345/read/config", wantErr: true},
+		{name: "quotas/trees/12345/write_leaves/config", wantErr: true},
+
 		{name: "quotas/-/1/read/config", wantErr: true}, // not allowed, use either trees/1 or users/1
 		{name: "quotas/-/1/write/config", wantErr: true},
 		{name: "quotas/-/1/-/config", wantErr: true},
@@ -44,6 +44,7 @@ var (
 	globalPattern *regexp.Regexp
 	treesPattern  *regexp.Regexp
 	usersPattern  *regexp.Regexp
+	mapsPattern   *regexp.Regexp
 )
 
 func init() {
@@ -60,6 +61,10 @@ func init() {
 	if err != nil {
 		glog.Fatalf("bad users pattern: %v", err)
 	}
+	mapsPattern, err = regexp.Compile(`^quotas/maps/\d+/(read_leaves|write_leaves|create_revision)/config$`)
+	if err != nil {
+		glog.Fatalf("bad maps pattern: %v", err)
+	}
 }
 
 // IsNameValid returns true if name is a valid quota name.
@@ -69,7 +74,7 @@ func IsNameValid(name string) bool {
 		return true
 	case usersPattern.MatchString(name):
 		return true
-	case treesPattern.MatchString(name):
+	case treesPattern.MatchString(name), mapsPattern.MatchString(name):
 		// Tree ID must fit on an int64
 		id := strings.Split(name, "/")[2]
 		_, err := strconv.ParseInt(id, 10, 64)
@@ -188,6 +193,9 @@ func validate(cfgs *storagepb.Configs) error {
 			if usersPattern.MatchString(cfg.Name) {
 				return status.Errorf(codes.InvalidArgument, "user quotas cannot use sequencing-based replenishment (Configs[%v].ReplenishmentStrategy)", i)
 			}
+			if mapsPattern.MatchString(cfg.Name) {
+				return status.Errorf(codes.InvalidArgument, "map quotas cannot use sequencing-based replenishment (Configs[%v].ReplenishmentStrategy)", i)
+			}
 			if strings.HasSuffix(cfg.Name, "/read/config") {
 				return status.Errorf(codes.InvalidArgument, "read quotas cannot use sequencing-based replenishment (Configs[%v].ReplenishmentStrategy)", i)
 			}
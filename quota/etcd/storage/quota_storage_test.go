@@ -101,6 +101,9 @@ func TestIsNameValid(t *testing.T) {
 		{name: "quotas/global/write/config", want: true},
 		{name: "quotas/trees/12356/read/config", want: true},
 		{name: "quotas/users/llama/write/config", want: true},
+		{name: "quotas/maps/12356/read_leaves/config", want: true},
+		{name: "quotas/maps/12356/write_leaves/config", want: true},
+		{name: "quotas/maps/12356/create_revision/config", want: true},
 
 		{name: "bad/quota/name"},
 		{name: "badprefix/quotas/global/read/config"},
@@ -109,6 +112,9 @@ func TestIsNameValid(t *testing.T) {
 		{name: "quotas/global/bad/config"},
 		{name: "quotas/trees/bad/read/config"},
 		{name: "quotas/trees/11111111111111111111/read/config"}, // ID > MaxInt64
+		{name: "quotas/maps/12356/read/config"},
+		{name: "quotas/trees/12356/write_leaves/config"},
+		{name: "quotas/maps/11111111111111111111/write_leaves/config"}, // ID > MaxInt64
 	}
 	for _, test := range tests {
 		if got := IsNameValid(test.name); got != test.want {
@@ -305,6 +311,10 @@ func TestQuotaStorage_UpdateConfigsErrors(t *testing.T) {
 	sequencingBasedReadQuota2.Configs[0].Name = "quotas/trees/1234/read/config"
 	sequencingBasedReadQuota2.Configs[0].ReplenishmentStrategy = sequencingBasedStrategy
 
+	sequencingBasedMapQuota := deepCopy(globalWriteCfgs)
+	sequencingBasedMapQuota.Configs[0].Name = "quotas/maps/1234/write_leaves/config"
+	sequencingBasedMapQuota.Configs[0].ReplenishmentStrategy = sequencingBasedStrategy
+
 	tests := []struct {
 		desc    string
 		update  func(*storagepb.Configs)
@@ -386,6 +396,11 @@ func TestQuotaStorage_UpdateConfigsErrors(t *testing.T) {
 			update:  updater(sequencingBasedReadQuota2),
 			wantErr: "cannot use sequencing-based replenishment",
 		},
+		{
+			desc:    "sequencingBasedMapQuota",
+			update:  updater(sequencingBasedMapQuota),
+			wantErr: "map quotas cannot use sequencing-based replenishment",
+		},
 	}
 
 	ctx := context.Background()
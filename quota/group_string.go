@@ -11,11 +11,12 @@ func _() {
 	_ = x[Global-0]
 	_ = x[Tree-1]
 	_ = x[User-2]
+	_ = x[Map-3]
 }
 
-const _Group_name = "GlobalTreeUser"
+const _Group_name = "GlobalTreeUserMap"
 
-var _Group_index = [...]uint8{0, 6, 10, 14}
+var _Group_index = [...]uint8{0, 6, 10, 14, 17}
 
 func (i Group) String() string {
 	if i < 0 || i >= Group(len(_Group_index)-1) {
@@ -10,11 +10,14 @@ func _() {
 	var x [1]struct{}
 	_ = x[Read-0]
 	_ = x[Write-1]
+	_ = x[ReadLeaves-2]
+	_ = x[WriteLeaves-3]
+	_ = x[CreateRevision-4]
 }
 
-const _Kind_name = "ReadWrite"
+const _Kind_name = "ReadWriteReadLeavesWriteLeavesCreateRevision"
 
-var _Kind_index = [...]uint8{0, 4, 9}
+var _Kind_index = [...]uint8{0, 4, 9, 19, 30, 44}
 
 func (i Kind) String() string {
 	if i < 0 || i >= Kind(len(_Kind_index)-1) {
@@ -0,0 +1,285 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresqm_test
+
+import (
+	"context"
+	"crypto"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/quota/postgresqm"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/postgres"
+	"github.com/google/trillian/storage/postgres/testdb"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/trees"
+	"github.com/google/trillian/types"
+	"github.com/kylelemons/godebug/pretty"
+
+	tcrypto "github.com/google/trillian/crypto"
+	stestonly "github.com/google/trillian/storage/testonly"
+)
+
+func TestQuotaManager_GetTokens(t *testing.T) {
+	db, done := newTestDB(t)
+	defer done()
+	ctx := context.Background()
+
+	tree, err := createTree(ctx, db)
+	if err != nil {
+		t.Fatalf("createTree() returned err = %v", err)
+	}
+
+	tests := []struct {
+		desc                                           string
+		unsequencedRows, maxUnsequencedRows, numTokens int
+		specs                                          []quota.Spec
+		wantErr                                        bool
+	}{
+		{
+			desc:               "globalWriteSingleToken",
+			unsequencedRows:    10,
+			maxUnsequencedRows: 20,
+			numTokens:          1,
+			specs:              []quota.Spec{{Group: quota.Global, Kind: quota.Write}},
+		},
+		{
+			desc:               "globalWriteOverQuota",
+			unsequencedRows:    15,
+			maxUnsequencedRows: 20,
+			numTokens:          10,
+			specs:              []quota.Spec{{Group: quota.Global, Kind: quota.Write}},
+			wantErr:            true,
+		},
+		{
+			desc:      "unlimitedQuotas",
+			numTokens: 10,
+			specs: []quota.Spec{
+				{Group: quota.User, Kind: quota.Read, User: "dylan"},
+				{Group: quota.Tree, Kind: quota.Read, TreeID: tree.TreeId},
+				{Group: quota.Global, Kind: quota.Read},
+				{Group: quota.User, Kind: quota.Write, User: "dylan"},
+				{Group: quota.Tree, Kind: quota.Write, TreeID: tree.TreeId},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if err := setUnsequencedRows(ctx, db, tree, test.unsequencedRows); err != nil {
+			t.Errorf("setUnsequencedRows() returned err = %v", err)
+			continue
+		}
+
+		// Statistics are only updated when the table is analyzed, so use select count(*) for
+		// precise assertions.
+		qm := &postgresqm.QuotaManager{DB: db, MaxUnsequencedRows: test.maxUnsequencedRows, UseSelectCount: true}
+		err := qm.GetTokens(ctx, test.numTokens, test.specs)
+		if hasErr := err == postgresqm.ErrTooManyUnsequencedRows; hasErr != test.wantErr {
+			t.Errorf("%v: GetTokens() returned err = %q, wantErr = %v", test.desc, err, test.wantErr)
+		}
+	}
+}
+
+func TestQuotaManager_GetTokens_Statistics(t *testing.T) {
+	db, done := newTestDB(t)
+	defer done()
+	ctx := context.Background()
+
+	tree, err := createTree(ctx, db)
+	if err != nil {
+		t.Fatalf("createTree() returned err = %v", err)
+	}
+	if err := setUnsequencedRows(ctx, db, tree, 20); err != nil {
+		t.Fatalf("setUnsequencedRows() returned err = %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "ANALYZE unsequenced"); err != nil {
+		t.Fatalf("ANALYZE returned err = %v", err)
+	}
+
+	qm := &postgresqm.QuotaManager{DB: db, MaxUnsequencedRows: 20}
+	if err := qm.GetTokens(ctx, 1 /* numTokens */, []quota.Spec{{Group: quota.Global, Kind: quota.Write}}); err != postgresqm.ErrTooManyUnsequencedRows {
+		t.Errorf("GetTokens() returned err = %v, want %v", err, postgresqm.ErrTooManyUnsequencedRows)
+	}
+}
+
+func TestQuotaManager_PeekTokens(t *testing.T) {
+	db, done := newTestDB(t)
+	defer done()
+	ctx := context.Background()
+
+	tree, err := createTree(ctx, db)
+	if err != nil {
+		t.Fatalf("createTree() returned err = %v", err)
+	}
+
+	unsequencedRows := 10
+	maxUnsequencedRows := 1000
+	if err := setUnsequencedRows(ctx, db, tree, unsequencedRows); err != nil {
+		t.Fatalf("setUnsequencedRows() returned err = %v", err)
+	}
+
+	qm := &postgresqm.QuotaManager{DB: db, MaxUnsequencedRows: maxUnsequencedRows, UseSelectCount: true}
+	specs := allSpecs(tree.TreeId)
+	tokens, err := qm.PeekTokens(ctx, specs)
+	if err != nil {
+		t.Fatalf("PeekTokens() returned err = %v", err)
+	}
+
+	// All specs but Global/Write are infinite
+	wantTokens := make(map[quota.Spec]int)
+	for _, spec := range specs {
+		wantTokens[spec] = quota.MaxTokens
+	}
+	wantTokens[quota.Spec{Group: quota.Global, Kind: quota.Write}] = maxUnsequencedRows - unsequencedRows
+
+	if diff := pretty.Compare(tokens, wantTokens); diff != "" {
+		t.Errorf("post-PeekTokens() diff:\n%v", diff)
+	}
+}
+
+func TestQuotaManager_Noops(t *testing.T) {
+	db, done := newTestDB(t)
+	defer done()
+	ctx := context.Background()
+
+	qm := &postgresqm.QuotaManager{DB: db, MaxUnsequencedRows: 1000}
+	specs := allSpecs(10 /* treeID */)
+	if err := qm.PutTokens(ctx, 10 /* numTokens */, specs); err != nil {
+		t.Errorf("PutTokens() returned err = %v", err)
+	}
+	if err := qm.ResetQuota(ctx, specs); err != nil {
+		t.Errorf("ResetQuota() returned err = %v", err)
+	}
+}
+
+// newTestDB returns a database with the Trillian schema, skipping the test if
+// PostgreSQL isn't available.
+func newTestDB(t *testing.T) (*sql.DB, func()) {
+	t.Helper()
+	if !testdb.PGAvailable() {
+		t.Skip("Skipping test as PostgreSQL not available")
+	}
+	ctx := context.Background()
+	db, done, err := testdb.NewTrillianDB(ctx)
+	if err != nil {
+		t.Fatalf("NewTrillianDB() returned err = %v", err)
+	}
+	return db, func() { done(ctx) }
+}
+
+func allSpecs(treeID int64) []quota.Spec {
+	return []quota.Spec{
+		{Group: quota.User, Kind: quota.Read, User: "florence"},
+		{Group: quota.Tree, Kind: quota.Read, TreeID: treeID},
+		{Group: quota.Global, Kind: quota.Read},
+		{Group: quota.User, Kind: quota.Write, User: "florence"},
+		{Group: quota.Tree, Kind: quota.Write, TreeID: treeID},
+		{Group: quota.Global, Kind: quota.Write},
+	}
+}
+
+func countUnsequenced(ctx context.Context, db *sql.DB) (int, error) {
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM unsequenced").Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func createTree(ctx context.Context, db *sql.DB) (*trillian.Tree, error) {
+	tree, err := storage.CreateTree(ctx, postgres.NewAdminStorage(db), stestonly.LogTree)
+	if err != nil {
+		return nil, err
+	}
+
+	ls := postgres.NewLogStorage(db, nil)
+	err = ls.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		signer := tcrypto.NewSigner(0, testonly.NewSignerWithFixedSig(nil, []byte("notempty")), crypto.SHA256)
+		slr, err := signer.SignLogRoot(&types.LogRootV1{RootHash: []byte{0}})
+		if err != nil {
+			return err
+		}
+		return tx.StoreSignedLogRoot(ctx, slr)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+func queueLeaves(ctx context.Context, db *sql.DB, tree *trillian.Tree, firstID, num int) error {
+	hasherFn, err := trees.Hash(tree)
+	if err != nil {
+		return err
+	}
+	hasher := hasherFn.New()
+
+	leaves := []*trillian.LogLeaf{}
+	for i := 0; i < num; i++ {
+		value := []byte(fmt.Sprintf("leaf-%v", firstID+i))
+		hasher.Reset()
+		if _, err := hasher.Write(value); err != nil {
+			return err
+		}
+		hash := hasher.Sum(nil)
+		leaves = append(leaves, &trillian.LogLeaf{
+			MerkleLeafHash:   hash,
+			LeafValue:        value,
+			ExtraData:        []byte("extra data"),
+			LeafIdentityHash: hash,
+		})
+	}
+
+	ls := postgres.NewLogStorage(db, nil)
+	return ls.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		_, err := tx.QueueLeaves(ctx, leaves, time.Now())
+		return err
+	})
+}
+
+func setUnsequencedRows(ctx context.Context, db *sql.DB, tree *trillian.Tree, wantRows int) error {
+	count, err := countUnsequenced(ctx, db)
+	if err != nil {
+		return err
+	}
+	if count == wantRows {
+		return nil
+	}
+
+	// Clear the tables and re-create leaves from scratch.
+	if _, err := db.ExecContext(ctx, "DELETE FROM unsequenced"); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM leaf_data"); err != nil {
+		return err
+	}
+	if err := queueLeaves(ctx, db, tree, 0 /* firstID */, wantRows); err != nil {
+		return err
+	}
+
+	count, err = countUnsequenced(ctx, db)
+	if err != nil {
+		return err
+	}
+	if count != wantRows {
+		return fmt.Errorf("got %v unsequenced rows, want = %v", count, wantRows)
+	}
+	return nil
+}
@@ -38,6 +38,12 @@ const (
 	// User is the per-user token scope.
 	// Users are defined according to each implementation.
 	User
+
+	// Map is the map-wide token scope of a map server entrypoint, charged
+	// with one of the map kinds, e.g. WriteLeaves. Unlike Tree tokens, which
+	// are charged per request, Map tokens are charged by the map server per
+	// leaf read or written and per revision created.
+	Map
 )
 
 // Kind represents the purpose of each token (Read or Write).
@@ -49,8 +55,25 @@ const (
 
 	// Write represents tokens used by modifying RPCs.
 	Write
+
+	// ReadLeaves represents a token per map leaf read.
+	ReadLeaves
+
+	// WriteLeaves represents a token per map leaf written.
+	WriteLeaves
+
+	// CreateRevision represents a token per map revision created.
+	CreateRevision
 )
 
+// kindNames holds the names of the kinds which are not the lower case form of
+// their String.
+var kindNames = map[Kind]string{
+	ReadLeaves:     "read_leaves",
+	WriteLeaves:    "write_leaves",
+	CreateRevision: "create_revision",
+}
+
 // Spec represents a combination of Group and Kind, with all additional data required to get / put
 // tokens.
 type Spec struct {
@@ -60,7 +83,7 @@ type Spec struct {
 	// Kind of the spec.
 	Kind
 
-	// TreeID identifies the tree for specs of the Tree group.
+	// TreeID identifies the tree for specs of the Tree and Map groups.
 	// Not used for other specs.
 	TreeID int64
 
@@ -76,15 +99,19 @@ type Spec struct {
 // * Global quotas are mapped to "global/read" or "global/write"
 // * Tree quotas are mapped to "trees/$TreeID/$Kind". E.g., "trees/10/read".
 // * User quotas are mapped to "users/$User/$Kind". E.g., "trees/10/read".
+// * Map quotas are mapped to "maps/$TreeID/$Kind". E.g., "maps/10/write_leaves".
 func (s Spec) Name() string {
 	group := strings.ToLower(fmt.Sprint(s.Group))
-	kind := strings.ToLower(fmt.Sprint(s.Kind))
+	kind, ok := kindNames[s.Kind]
+	if !ok {
+		kind = strings.ToLower(fmt.Sprint(s.Kind))
+	}
 	if s.Group == Global {
 		return fmt.Sprintf("%v/%v", group, kind)
 	}
 	var user string
 	switch s.Group {
-	case Tree:
+	case Tree, Map:
 		user = fmt.Sprint(s.TreeID)
 	case User:
 		user = s.User
@@ -27,6 +27,9 @@ func TestSpec_Name(t *testing.T) {
 		{spec: Spec{Group: Tree, Kind: Write, TreeID: 10}, want: "trees/10/write"},
 		{spec: Spec{Group: User, Kind: Read, User: "alpaca"}, want: "users/alpaca/read"},
 		{spec: Spec{Group: User, Kind: Write, User: "llama"}, want: "users/llama/write"},
+		{spec: Spec{Group: Map, Kind: ReadLeaves, TreeID: 12345}, want: "maps/12345/read_leaves"},
+		{spec: Spec{Group: Map, Kind: WriteLeaves, TreeID: 12345}, want: "maps/12345/write_leaves"},
+		{spec: Spec{Group: Map, Kind: CreateRevision, TreeID: 12345}, want: "maps/12345/create_revision"},
 	}
 	for _, test := range tests {
 		if got := test.spec.Name(); got != test.want {
@@ -18,14 +18,21 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto/keys/der"
+	"github.com/google/trillian/crypto/keyspb"
+	"github.com/google/trillian/crypto/sigpb"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/server/errmsg"
+	"github.com/google/trillian/server/notify"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/compression"
 	"github.com/google/trillian/trees"
 	"google.golang.org/genproto/protobuf/field_mask"
 	"google.golang.org/grpc/codes"
@@ -34,6 +41,15 @@ import (
 	_ "github.com/google/trillian/merkle/rfc6962" // Make hashers available
 )
 
+const (
+	// maxTemplateTrees is the largest number of trees created by a
+	// CreateTreesFromTemplate call.
+	maxTemplateTrees = 1000
+
+	// notifyTimeout bounds the delivery of each event to the TreeNotifier.
+	notifyTimeout = time.Minute
+)
+
 // Server is an implementation of trillian.TrillianAdminServer.
 type Server struct {
 	registry         extension.Registry
@@ -51,23 +67,22 @@ func New(registry extension.Registry, allowedTreeTypes []trillian.TreeType) *Ser
 	}
 }
 
-// IsHealthy returns nil if the server is healthy, error otherwise.
-// TODO(Martin2112): This method (and the one in the log server) should probably have ctx as a param
-func (s *Server) IsHealthy() error {
-	return s.registry.AdminStorage.CheckDatabaseAccessible(context.Background())
-}
-
 // ListTrees implements trillian.TrillianAdminServer.ListTrees.
 func (s *Server) ListTrees(ctx context.Context, req *trillian.ListTreesRequest) (*trillian.ListTreesResponse, error) {
 	// TODO(codingllama): This needs access control
-	resp, err := storage.ListTrees(ctx, s.registry.AdminStorage, req.GetShowDeleted())
+	filter, err := newTreeFilter(req)
 	if err != nil {
 		return nil, err
 	}
+	all, err := storage.ListTrees(ctx, s.registry.AdminStorage, req.GetShowDeleted())
+	if err != nil {
+		return nil, err
+	}
+	resp, next := filter.page(all)
 	for _, tree := range resp {
 		redact(tree)
 	}
-	return &trillian.ListTreesResponse{Tree: resp}, nil
+	return &trillian.ListTreesResponse{Tree: resp, NextPageToken: next}, nil
 }
 
 // GetTree implements trillian.TrillianAdminServer.GetTree.
@@ -81,6 +96,63 @@ func (s *Server) GetTree(ctx context.Context, req *trillian.GetTreeRequest) (*tr
 
 // CreateTree implements trillian.TrillianAdminServer.CreateTree.
 func (s *Server) CreateTree(ctx context.Context, req *trillian.CreateTreeRequest) (*trillian.Tree, error) {
+	return s.createTree(ctx, req, 0)
+}
+
+// createTree creates the tree described by req with treeID, or with a new ID
+// if treeID is zero.
+func (s *Server) createTree(ctx context.Context, req *trillian.CreateTreeRequest, treeID int64) (*trillian.Tree, error) {
+	tree, err := s.newTree(ctx, req, treeID)
+	if err != nil {
+		return nil, err
+	}
+	createdTree, err := storage.CreateTree(ctx, s.registry.AdminStorage, tree)
+	if err != nil {
+		return nil, err
+	}
+	return redact(createdTree), nil
+}
+
+// CreateTreesFromTemplate implements trillian.TrillianAdminServer.CreateTreesFromTemplate.
+func (s *Server) CreateTreesFromTemplate(ctx context.Context, req *trillian.CreateTreesFromTemplateRequest) (*trillian.CreateTreesFromTemplateResponse, error) {
+	if req.GetTemplate() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "a template is required")
+	}
+	if req.Count <= 0 {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.FieldNotPositive, errmsg.Params{"field": "CreateTreesFromTemplateRequest.Count", "value": req.Count})
+	}
+	if req.Count > maxTemplateTrees {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.TooManyTrees, errmsg.Params{"got": req.Count, "max": maxTemplateTrees})
+	}
+
+	// Keys are generated before the transaction, which only writes the trees.
+	trees := make([]*trillian.Tree, 0, req.Count)
+	for i := int32(0); i < req.Count; i++ {
+		createReq := &trillian.CreateTreeRequest{
+			Tree:    proto.Clone(req.Template).(*trillian.Tree),
+			KeySpec: req.KeySpec,
+		}
+		tree, err := s.newTree(ctx, createReq, 0)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, tree)
+	}
+	created, err := storage.CreateTrees(ctx, s.registry.AdminStorage, trees)
+	if err != nil {
+		return nil, err
+	}
+	for _, tree := range created {
+		redact(tree)
+	}
+	glog.Infof("Created %d trees from template", len(created))
+	return &trillian.CreateTreesFromTemplateResponse{Tree: created}, nil
+}
+
+// newTree returns the tree described by req, validated and ready to be
+// stored with treeID, or with a new ID if treeID is zero. If req has a key
+// specification, the private key of the tree is generated.
+func (s *Server) newTree(ctx context.Context, req *trillian.CreateTreeRequest, treeID int64) (*trillian.Tree, error) {
 	tree := req.GetTree()
 	if tree == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "a tree is required")
@@ -94,9 +166,12 @@ func (s *Server) CreateTree(ctx context.Context, req *trillian.CreateTreeRequest
 			return nil, status.Errorf(codes.InvalidArgument, "failed to create hasher for tree: %v", err.Error())
 		}
 	case trillian.TreeType_MAP:
-		if _, err := hashers.NewMapHasher(tree.HashStrategy); err != nil {
+		if _, err := s.registry.MapHashers.NewMapHasherForTree(tree); err != nil {
 			return nil, status.Errorf(codes.InvalidArgument, "failed to create hasher for tree: %v", err.Error())
 		}
+		if _, err := compression.ForTree(tree); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "failed to create leaf compression codec for tree: %v", err.Error())
+		}
 	default:
 		return nil, status.Errorf(codes.InvalidArgument, "invalid tree type: %v", tree.TreeType)
 	}
@@ -151,18 +226,30 @@ func (s *Server) CreateTree(ctx context.Context, req *trillian.CreateTreeRequest
 		tree.PublicKey = publicKey
 	}
 
+	// Likewise for the public keys of the additional private keys, if any.
+	if len(tree.AdditionalPublicKeys) != 0 && len(tree.AdditionalPublicKeys) != len(signer.Cosigners) {
+		return nil, status.Errorf(codes.InvalidArgument, "got %d additional public keys for %d additional private keys", len(tree.AdditionalPublicKeys), len(signer.Cosigners))
+	}
+	var additionalPublicKeys []*keyspb.PublicKey
+	for i, cosigner := range signer.Cosigners {
+		publicKey, err := der.ToPublicProto(cosigner.Public())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "failed to marshal additional public key: %v", err.Error())
+		}
+		if len(tree.AdditionalPublicKeys) != 0 && !bytes.Equal(tree.AdditionalPublicKeys[i].GetDer(), publicKey.Der) {
+			return nil, status.Errorf(codes.InvalidArgument, "additional public and private keys %d are not a pair", i)
+		}
+		additionalPublicKeys = append(additionalPublicKeys, publicKey)
+	}
+	tree.AdditionalPublicKeys = additionalPublicKeys
+
 	// Clear generated fields, storage must set those
-	tree.TreeId = 0
+	tree.TreeId = treeID
 	tree.CreateTime = nil
 	tree.UpdateTime = nil
 	tree.Deleted = false
 	tree.DeleteTime = nil
-
-	createdTree, err := storage.CreateTree(ctx, s.registry.AdminStorage, tree)
-	if err != nil {
-		return nil, err
-	}
-	return redact(createdTree), nil
+	return tree, nil
 }
 
 func (s *Server) validateAllowedTreeType(tt trillian.TreeType) error {
@@ -189,18 +276,44 @@ func (s *Server) UpdateTree(ctx context.Context, req *trillian.UpdateTreeRequest
 		return nil, err
 	}
 
-	updatedTree, err := storage.UpdateTree(ctx, s.registry.AdminStorage, tree.TreeId, func(other *trillian.Tree) {
-		if err := applyUpdateMask(tree, other, mask); err != nil {
-			// Should never happen (famous last words).
-			glog.Errorf("Error applying mask on tree update: %v", err)
+	var updatedTree *trillian.Tree
+	var previousState trillian.TreeState
+	err := s.registry.AdminStorage.ReadWriteTransaction(ctx, func(ctx context.Context, tx storage.AdminTX) error {
+		stored, err := tx.GetTree(ctx, tree.TreeId)
+		if err != nil {
+			return err
+		}
+		previousState = stored.TreeState
+		if stored.TreeState == trillian.TreeState_QUARANTINED && hasPath(mask, "tree_state") && tree.TreeState != stored.TreeState {
+			return errmsg.New(codes.FailedPrecondition, errmsg.TreeQuarantined, errmsg.Params{"tree_id": tree.TreeId})
 		}
+		updatedTree, err = tx.UpdateTree(ctx, tree.TreeId, func(other *trillian.Tree) {
+			if err := applyUpdateMask(tree, other, mask); err != nil {
+				// Should never happen (famous last words).
+				glog.Errorf("Error applying mask on tree update: %v", err)
+			}
+		})
+		return err
 	})
 	if err != nil {
 		return nil, err
 	}
+	if updatedTree.TreeState != previousState {
+		s.notifyStateChanged(updatedTree, previousState)
+	}
 	return redact(updatedTree), nil
 }
 
+// hasPath returns whether mask contains path.
+func hasPath(mask *field_mask.FieldMask, path string) bool {
+	for _, p := range mask.GetPaths() {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
 func applyUpdateMask(from, to *trillian.Tree, mask *field_mask.FieldMask) error {
 	if mask == nil || len(mask.Paths) == 0 {
 		return status.Errorf(codes.InvalidArgument, "an update_mask is required")
@@ -221,6 +334,18 @@ func applyUpdateMask(from, to *trillian.Tree, mask *field_mask.FieldMask) error
 			to.MaxRootDuration = from.MaxRootDuration
 		case "private_key":
 			to.PrivateKey = from.PrivateKey
+		case "revision_retention_policy":
+			to.RevisionRetentionPolicy = from.RevisionRetentionPolicy
+		case "duplicate_leaf_policy":
+			to.DuplicateLeafPolicy = from.DuplicateLeafPolicy
+		case "labels":
+			to.Labels = from.Labels
+		case "delete_retention":
+			to.DeleteRetention = from.DeleteRetention
+		case "quota":
+			to.Quota = from.Quota
+		case "owner":
+			to.Owner = from.Owner
 		default:
 			return status.Errorf(codes.InvalidArgument, "invalid update_mask path: %q", path)
 		}
@@ -234,6 +359,7 @@ func (s *Server) DeleteTree(ctx context.Context, req *trillian.DeleteTreeRequest
 	if err != nil {
 		return nil, err
 	}
+	s.notifyTreeEvent(notify.NewEvent(notify.Deleted, tree, timeNow()))
 	return redact(tree), nil
 }
 
@@ -243,11 +369,262 @@ func (s *Server) UndeleteTree(ctx context.Context, req *trillian.UndeleteTreeReq
 	if err != nil {
 		return nil, err
 	}
+	s.notifyTreeEvent(notify.NewEvent(notify.Undeleted, tree, timeNow()))
 	return redact(tree), nil
 }
 
+// PurgeTree implements trillian.TrillianAdminServer.PurgeTree.
+func (s *Server) PurgeTree(ctx context.Context, req *trillian.PurgeTreeRequest) (*trillian.Tree, error) {
+	var tree *trillian.Tree
+	err := s.registry.AdminStorage.ReadWriteTransaction(ctx, func(ctx context.Context, tx storage.AdminTX) error {
+		var err error
+		if tree, err = tx.GetTree(ctx, req.GetTreeId()); err != nil {
+			return err
+		}
+		if !tree.Deleted {
+			return errmsg.New(codes.FailedPrecondition, errmsg.TreeNotDeleted, errmsg.Params{"tree_id": tree.TreeId})
+		}
+		return tx.HardDeleteTree(ctx, tree.TreeId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	glog.Warningf("Purged tree %d", tree.TreeId)
+	s.notifyTreeEvent(notify.NewEvent(notify.Purged, tree, timeNow()))
+	return redact(tree), nil
+}
+
+// LiftQuarantine implements trillian.TrillianAdminServer.LiftQuarantine.
+func (s *Server) LiftQuarantine(ctx context.Context, req *trillian.LiftQuarantineRequest) (*trillian.Tree, error) {
+	state := req.GetTreeState()
+	switch state {
+	case trillian.TreeState_UNKNOWN_TREE_STATE:
+		state = trillian.TreeState_ACTIVE
+	case trillian.TreeState_ACTIVE, trillian.TreeState_DRAINING, trillian.TreeState_FROZEN:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid tree_state: %s", state)
+	}
+
+	var tree *trillian.Tree
+	err := s.registry.AdminStorage.ReadWriteTransaction(ctx, func(ctx context.Context, tx storage.AdminTX) error {
+		stored, err := tx.GetTree(ctx, req.GetTreeId())
+		if err != nil {
+			return err
+		}
+		if stored.TreeState != trillian.TreeState_QUARANTINED {
+			return errmsg.New(codes.FailedPrecondition, errmsg.TreeNotQuarantined, errmsg.Params{"tree_id": stored.TreeId, "tree_state": stored.TreeState})
+		}
+		tree, err = tx.UpdateTree(ctx, stored.TreeId, func(t *trillian.Tree) {
+			t.TreeState = state
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	glog.Warningf("Lifted quarantine of tree %d, now %s", tree.TreeId, tree.TreeState)
+	s.notifyStateChanged(tree, trillian.TreeState_QUARANTINED)
+	return redact(tree), nil
+}
+
+// PauseSequencing implements trillian.TrillianAdminServer.PauseSequencing.
+func (s *Server) PauseSequencing(ctx context.Context, req *trillian.PauseSequencingRequest) (*trillian.Tree, error) {
+	tree, err := s.setSequencingState(ctx, req.GetTreeId(), trillian.TreeState_PAUSED, func(stored *trillian.Tree) error {
+		isLog := stored.TreeType == trillian.TreeType_LOG || stored.TreeType == trillian.TreeType_PREORDERED_LOG
+		if !isLog || stored.TreeState != trillian.TreeState_ACTIVE {
+			return errmsg.New(codes.FailedPrecondition, errmsg.TreeNotPausable, errmsg.Params{"tree_id": stored.TreeId, "tree_type": stored.TreeType, "tree_state": stored.TreeState})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	glog.Warningf("Paused sequencing of tree %d", tree.TreeId)
+	return tree, nil
+}
+
+// ResumeSequencing implements trillian.TrillianAdminServer.ResumeSequencing.
+func (s *Server) ResumeSequencing(ctx context.Context, req *trillian.ResumeSequencingRequest) (*trillian.Tree, error) {
+	tree, err := s.setSequencingState(ctx, req.GetTreeId(), trillian.TreeState_ACTIVE, func(stored *trillian.Tree) error {
+		if stored.TreeState != trillian.TreeState_PAUSED {
+			return errmsg.New(codes.FailedPrecondition, errmsg.TreeNotPaused, errmsg.Params{"tree_id": stored.TreeId, "tree_state": stored.TreeState})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	glog.Warningf("Resumed sequencing of tree %d", tree.TreeId)
+	return tree, nil
+}
+
+// setSequencingState moves a tree to state, if check accepts it. Trees which
+// are already in state are returned unchanged, so that pausing and resuming
+// can be retried.
+func (s *Server) setSequencingState(ctx context.Context, treeID int64, state trillian.TreeState, check func(*trillian.Tree) error) (*trillian.Tree, error) {
+	var tree *trillian.Tree
+	var previousState trillian.TreeState
+	err := s.registry.AdminStorage.ReadWriteTransaction(ctx, func(ctx context.Context, tx storage.AdminTX) error {
+		stored, err := tx.GetTree(ctx, treeID)
+		if err != nil {
+			return err
+		}
+		previousState = stored.TreeState
+		if stored.TreeState == state {
+			tree = stored
+			return nil
+		}
+		if err := check(stored); err != nil {
+			return err
+		}
+		tree, err = tx.UpdateTree(ctx, stored.TreeId, func(t *trillian.Tree) {
+			t.TreeState = state
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if previousState != state {
+		s.notifyStateChanged(tree, previousState)
+	}
+	return redact(tree), nil
+}
+
+// ExportTrees implements trillian.TrillianAdminServer.ExportTrees.
+func (s *Server) ExportTrees(ctx context.Context, req *trillian.ExportTreesRequest) (*trillian.ExportTreesResponse, error) {
+	var trees []*trillian.Tree
+	if len(req.GetTreeId()) == 0 {
+		all, err := storage.ListTrees(ctx, s.registry.AdminStorage, false /* includeDeleted */)
+		if err != nil {
+			return nil, err
+		}
+		trees = all
+	}
+	for _, id := range req.GetTreeId() {
+		tree, err := storage.GetTree(ctx, s.registry.AdminStorage, id)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, tree)
+	}
+	for _, tree := range trees {
+		// A rotation in progress isn't exported, so imported trees keep the
+		// old key.
+		tree.KeyRotation = nil
+		if err := exportKey(tree); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to export private key of tree %d: %v", tree.TreeId, err)
+		}
+	}
+	return &trillian.ExportTreesResponse{Tree: trees}, nil
+}
+
+// exportKey replaces the private key of t by a reference to it which holds
+// no secrets, or removes it if the key is held by Trillian.
+func exportKey(t *trillian.Tree) error {
+	var ref proto.Message
+	switch {
+	case t.PrivateKey == nil:
+		return nil
+	case ptypes.Is(t.PrivateKey, &keyspb.PEMKeyFile{}):
+		var key keyspb.PEMKeyFile
+		if err := ptypes.UnmarshalAny(t.PrivateKey, &key); err != nil {
+			return err
+		}
+		ref = &keyspb.PEMKeyFile{Path: key.Path}
+	case ptypes.Is(t.PrivateKey, &keyspb.PKCS11Config{}):
+		var key keyspb.PKCS11Config
+		if err := ptypes.UnmarshalAny(t.PrivateKey, &key); err != nil {
+			return err
+		}
+		ref = &keyspb.PKCS11Config{TokenLabel: key.TokenLabel, PublicKey: key.PublicKey}
+	default:
+		t.PrivateKey = nil
+		return nil
+	}
+	any, err := ptypes.MarshalAny(ref)
+	if err != nil {
+		return err
+	}
+	t.PrivateKey = any
+	return nil
+}
+
+// ImportTrees implements trillian.TrillianAdminServer.ImportTrees.
+func (s *Server) ImportTrees(ctx context.Context, req *trillian.ImportTreesRequest) (*trillian.ImportTreesResponse, error) {
+	resp := &trillian.ImportTreesResponse{}
+	for i, tree := range req.GetTree() {
+		tree = proto.Clone(tree).(*trillian.Tree)
+		tree.TreeState = trillian.TreeState_ACTIVE
+		createReq := &trillian.CreateTreeRequest{Tree: tree}
+		if tree.PrivateKey == nil {
+			// The exported public key belongs to a key which wasn't exported.
+			tree.PublicKey = nil
+			createReq.KeySpec = req.GetKeySpec()
+			if createReq.KeySpec == nil {
+				createReq.KeySpec = defaultKeySpec(tree.SignatureAlgorithm)
+			}
+		}
+		var treeID int64
+		if req.KeepTreeIds {
+			treeID = req.GetTree()[i].TreeId
+		}
+		created, err := s.createTree(ctx, createReq, treeID)
+		if err != nil {
+			st := status.Convert(err)
+			return nil, status.Errorf(st.Code(), "failed to import tree %d (%d trees imported): %v", i, len(resp.Tree), st.Message())
+		}
+		glog.Infof("Imported tree %d as tree %d", req.GetTree()[i].TreeId, created.TreeId)
+		resp.Tree = append(resp.Tree, created)
+	}
+	return resp, nil
+}
+
+// defaultKeySpec returns the specification of a key with default parameters
+// for alg, or nil if there is none.
+func defaultKeySpec(alg sigpb.DigitallySigned_SignatureAlgorithm) *keyspb.Specification {
+	switch alg {
+	case sigpb.DigitallySigned_ECDSA:
+		return &keyspb.Specification{Params: &keyspb.Specification_EcdsaParams{EcdsaParams: &keyspb.Specification_ECDSA{}}}
+	case sigpb.DigitallySigned_RSA:
+		return &keyspb.Specification{Params: &keyspb.Specification_RsaParams{RsaParams: &keyspb.Specification_RSA{}}}
+	case sigpb.DigitallySigned_ED25519:
+		return &keyspb.Specification{Params: &keyspb.Specification_Ed25519Params{Ed25519Params: &keyspb.Specification_Ed25519{}}}
+	}
+	return nil
+}
+
+// notifyStateChanged notifies the TreeNotifier that the state of tree changed
+// from previousState.
+func (s *Server) notifyStateChanged(tree *trillian.Tree, previousState trillian.TreeState) {
+	event := notify.NewEvent(notify.StateChanged, tree, timeNow())
+	event.PreviousTreeState = previousState.String()
+	s.notifyTreeEvent(event)
+}
+
+// notifyTreeEvent delivers event to the TreeNotifier, if any. Events are
+// delivered in the background, so that RPCs neither wait for the notifier nor
+// fail with it.
+func (s *Server) notifyTreeEvent(event *notify.Event) {
+	notifier := s.registry.TreeNotifier
+	if notifier == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := notifier.Notify(ctx, event); err != nil {
+			glog.Warningf("Failed to notify %s of tree %d: %v", event.Kind, event.TreeID, err)
+		}
+	}()
+}
+
 // redact removes sensitive information from t. Returns t for convenience.
 func redact(t *trillian.Tree) *trillian.Tree {
 	t.PrivateKey = nil
+	t.AdditionalPrivateKeys = nil
+	if t.KeyRotation != nil {
+		t.KeyRotation.PrivateKey = nil
+	}
 	return t
 }
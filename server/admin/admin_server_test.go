@@ -30,6 +30,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/google/go-cmp/cmp"
@@ -39,7 +40,11 @@ import (
 	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/crypto/sigpb"
 	"github.com/google/trillian/extension"
+	"github.com/google/trillian/server/errmsg"
+	"github.com/google/trillian/server/notify"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/storage/storagepb"
 	"github.com/google/trillian/storage/testonly"
 	"github.com/kylelemons/godebug/pretty"
 	"google.golang.org/genproto/protobuf/field_mask"
@@ -338,6 +343,24 @@ func TestServer_CreateTree(t *testing.T) {
 	keySignatureMismatch := proto.Clone(validTree).(*trillian.Tree)
 	keySignatureMismatch.SignatureAlgorithm = sigpb.DigitallySigned_RSA
 
+	additionalKeys := proto.Clone(validTree).(*trillian.Tree)
+	additionalKeys.AdditionalPrivateKeys = []*any.Any{validTree.PrivateKey}
+	additionalKeys.SignatureThreshold = 1
+
+	mismatchedAdditionalPublicKey := proto.Clone(additionalKeys).(*trillian.Tree)
+	mismatchedAdditionalPublicKey.AdditionalPublicKeys = []*keyspb.PublicKey{testonly.LogTree.GetPublicKey()}
+
+	zstdCompression := proto.Clone(testonly.MapTree).(*trillian.Tree)
+	zstdCompression.StorageSettings = ttestonly.MustMarshalAny(t, &storagepb.MapStorageSettings{
+		LeafCompression: storagepb.LeafCompression_ZSTD,
+	})
+
+	// No codec is registered for unknown compressions.
+	unsupportedCompression := proto.Clone(testonly.MapTree).(*trillian.Tree)
+	unsupportedCompression.StorageSettings = ttestonly.MustMarshalAny(t, &storagepb.MapStorageSettings{
+		LeafCompression: storagepb.LeafCompression(99),
+	})
+
 	tests := []struct {
 		desc                  string
 		req                   *trillian.CreateTreeRequest
@@ -356,6 +379,16 @@ func TestServer_CreateTree(t *testing.T) {
 			req:     &trillian.CreateTreeRequest{},
 			wantErr: "tree is required",
 		},
+		{
+			desc:    "unsupportedCompression",
+			req:     &trillian.CreateTreeRequest{Tree: unsupportedCompression},
+			wantErr: "no codec registered for leaf compression 99",
+		},
+		{
+			desc:       "zstdCompression",
+			req:        &trillian.CreateTreeRequest{Tree: zstdCompression},
+			wantCommit: true,
+		},
 		{
 			desc:    "mismatchedPublicKey",
 			req:     &trillian.CreateTreeRequest{Tree: mismatchedPublicKey},
@@ -446,6 +479,16 @@ func TestServer_CreateTree(t *testing.T) {
 			req:     &trillian.CreateTreeRequest{Tree: keySignatureMismatch},
 			wantErr: "signature not supported by signer",
 		},
+		{
+			desc:       "additionalKeys",
+			req:        &trillian.CreateTreeRequest{Tree: additionalKeys},
+			wantCommit: true,
+		},
+		{
+			desc:    "mismatchedAdditionalPublicKey",
+			req:     &trillian.CreateTreeRequest{Tree: mismatchedAdditionalPublicKey},
+			wantErr: "additional public and private keys 0 are not a pair",
+		},
 		{
 			desc:      "createErr",
 			req:       &trillian.CreateTreeRequest{Tree: invalidTree},
@@ -501,7 +544,13 @@ func TestServer_CreateTree(t *testing.T) {
 				newTree.CreateTime = nowPB
 				newTree.UpdateTime = nowPB
 				newTree.PublicKey, err = der.ToPublicProto(privateKey.Public())
-				tx.EXPECT().CreateTree(gomock.Any(), gomock.Any()).MaxTimes(1).Return(newTree, test.createErr)
+				tx.EXPECT().CreateTree(gomock.Any(), gomock.Any()).MaxTimes(1).DoAndReturn(func(_ context.Context, tree *trillian.Tree) (*trillian.Tree, error) {
+					// The public keys of additional private keys are derived.
+					if got, want := len(tree.AdditionalPublicKeys), len(tree.AdditionalPrivateKeys); got != want {
+						t.Errorf("CreateTree() called with %d additional public keys, want %d", got, want)
+					}
+					return newTree, test.createErr
+				})
 			}
 
 			// Copy test.req so that any changes CreateTree makes don't affect the original, which may be shared between tests.
@@ -521,6 +570,7 @@ func TestServer_CreateTree(t *testing.T) {
 			wantTree.CreateTime = nowPB
 			wantTree.UpdateTime = nowPB
 			wantTree.PrivateKey = nil // redacted
+			wantTree.AdditionalPrivateKeys = nil
 			wantTree.PublicKey, err = der.ToPublicProto(privateKey.Public())
 			if err != nil {
 				t.Fatalf("failed to marshal test public key as protobuf: %v", err)
@@ -640,9 +690,16 @@ func TestServer_UpdateTree(t *testing.T) {
 		StorageSettings: settings,
 		MaxRootDuration: ptypes.DurationProto(2 * time.Nanosecond),
 		PrivateKey:      ttestonly.MustMarshalAny(t, &empty.Empty{}),
+		RevisionRetentionPolicy: &trillian.RevisionRetentionPolicy{
+			KeepRevisions: 10,
+		},
+		Labels:          map[string]string{"env": "prod"},
+		DeleteRetention: ptypes.DurationProto(24 * time.Hour),
+		Quota:           &trillian.TreeQuota{ReadTokensPerSecond: 100, WriteTokensPerSecond: 10},
+		Owner:           "tenant-1",
 	}
 	successMask := &field_mask.FieldMask{
-		Paths: []string{"tree_state", "display_name", "description", "storage_settings", "max_root_duration", "private_key"},
+		Paths: []string{"tree_state", "display_name", "description", "storage_settings", "max_root_duration", "private_key", "revision_retention_policy", "labels", "delete_retention", "quota", "owner"},
 	}
 
 	successWant := proto.Clone(existingTree).(*trillian.Tree)
@@ -652,6 +709,17 @@ func TestServer_UpdateTree(t *testing.T) {
 	successWant.StorageSettings = successTree.StorageSettings
 	successWant.PrivateKey = nil // redacted on responses
 	successWant.MaxRootDuration = successTree.MaxRootDuration
+	successWant.RevisionRetentionPolicy = successTree.RevisionRetentionPolicy
+	successWant.Labels = successTree.Labels
+	successWant.DeleteRetention = successTree.DeleteRetention
+	successWant.Quota = successTree.Quota
+	successWant.Owner = successTree.Owner
+
+	quarantinedTree := proto.Clone(existingTree).(*trillian.Tree)
+	quarantinedTree.TreeState = trillian.TreeState_QUARANTINED
+	renamedWant := proto.Clone(quarantinedTree).(*trillian.Tree)
+	renamedWant.DisplayName = successTree.DisplayName
+	renamedWant.PrivateKey = nil // redacted on responses
 
 	tests := []struct {
 		desc                           string
@@ -659,6 +727,7 @@ func TestServer_UpdateTree(t *testing.T) {
 		currentTree, wantTree          *trillian.Tree
 		updateErr                      error
 		commitErr, wantErr, wantCommit bool
+		wantReason                     errmsg.Reason
 	}{
 		{
 			desc:        "success",
@@ -708,6 +777,23 @@ func TestServer_UpdateTree(t *testing.T) {
 			wantErr:     true,
 			wantCommit:  true,
 		},
+		{
+			desc:        "quarantinedStateChange",
+			req:         &trillian.UpdateTreeRequest{Tree: successTree, UpdateMask: successMask},
+			currentTree: quarantinedTree,
+			wantErr:     true,
+			wantReason:  errmsg.TreeQuarantined,
+		},
+		{
+			desc: "quarantinedRename",
+			req: &trillian.UpdateTreeRequest{
+				Tree:       successTree,
+				UpdateMask: &field_mask.FieldMask{Paths: []string{"display_name"}},
+			},
+			currentTree: quarantinedTree,
+			wantTree:    renamedWant,
+			wantCommit:  true,
+		},
 	}
 
 	ctx := context.Background()
@@ -723,6 +809,7 @@ func TestServer_UpdateTree(t *testing.T) {
 		s := setup.server
 
 		if test.req.Tree != nil {
+			tx.EXPECT().GetTree(gomock.Any(), test.req.Tree.TreeId).MaxTimes(1).Return(proto.Clone(test.currentTree), nil)
 			tx.EXPECT().UpdateTree(gomock.Any(), test.req.Tree.TreeId, gomock.Any()).MaxTimes(1).Do(func(ctx context.Context, treeID int64, updateFn func(*trillian.Tree)) {
 				// This step should be done by the storage layer, but since we're mocking it we have to trigger it ourselves.
 				updateFn(test.currentTree)
@@ -734,6 +821,9 @@ func TestServer_UpdateTree(t *testing.T) {
 			t.Errorf("%v: UpdateTree() returned err = %q, wantErr = %v", test.desc, err, test.wantErr)
 			continue
 		} else if hasErr {
+			if got := errmsg.Reason(errmsg.Info(err).GetReason()); test.wantReason != "" && got != test.wantReason {
+				t.Errorf("%v: UpdateTree() reason = %v, want %v", test.desc, got, test.wantReason)
+			}
 			continue
 		}
 
@@ -913,8 +1003,592 @@ func TestServer_UndeleteTreeErrors(t *testing.T) {
 	}
 }
 
+func TestServer_LiftQuarantine(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	quarantinedLog := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	quarantinedLog.TreeId = 10
+	quarantinedLog.TreeState = trillian.TreeState_QUARANTINED
+	activeLog := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	activeLog.TreeId = 11
+
+	tests := []struct {
+		desc        string
+		req         *trillian.LiftQuarantineRequest
+		storedTree  *trillian.Tree
+		wantState   trillian.TreeState
+		wantCode    codes.Code
+		wantReason  errmsg.Reason
+		wantStorage bool
+	}{
+		{
+			desc:        "default",
+			req:         &trillian.LiftQuarantineRequest{TreeId: quarantinedLog.TreeId},
+			storedTree:  quarantinedLog,
+			wantState:   trillian.TreeState_ACTIVE,
+			wantStorage: true,
+		},
+		{
+			desc:        "frozen",
+			req:         &trillian.LiftQuarantineRequest{TreeId: quarantinedLog.TreeId, TreeState: trillian.TreeState_FROZEN},
+			storedTree:  quarantinedLog,
+			wantState:   trillian.TreeState_FROZEN,
+			wantStorage: true,
+		},
+		{
+			desc:     "stayQuarantined",
+			req:      &trillian.LiftQuarantineRequest{TreeId: quarantinedLog.TreeId, TreeState: trillian.TreeState_QUARANTINED},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			desc:        "notQuarantined",
+			req:         &trillian.LiftQuarantineRequest{TreeId: activeLog.TreeId},
+			storedTree:  activeLog,
+			wantCode:    codes.FailedPrecondition,
+			wantReason:  errmsg.TreeNotQuarantined,
+			wantStorage: true,
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			setup := setupAdminServer(
+				ctrl,
+				nil,   /* keygen */
+				false, /* snapshot */
+				test.wantCode == codes.OK,
+				false)
+			tx := setup.tx
+			if test.wantStorage {
+				stored := proto.Clone(test.storedTree).(*trillian.Tree)
+				tx.EXPECT().GetTree(gomock.Any(), test.req.TreeId).Return(stored, nil)
+				tx.EXPECT().UpdateTree(gomock.Any(), test.req.TreeId, gomock.Any()).MaxTimes(1).DoAndReturn(func(ctx context.Context, treeID int64, updateFn func(*trillian.Tree)) (*trillian.Tree, error) {
+					updateFn(stored)
+					return stored, nil
+				})
+			}
+
+			got, err := setup.server.LiftQuarantine(ctx, test.req)
+			if status.Code(err) != test.wantCode {
+				t.Fatalf("LiftQuarantine() returned err = %v, want code %v", err, test.wantCode)
+			}
+			if got := errmsg.Reason(errmsg.Info(err).GetReason()); got != test.wantReason {
+				t.Errorf("LiftQuarantine() reason = %v, want %v", got, test.wantReason)
+			}
+			if err != nil {
+				return
+			}
+			if got.TreeState != test.wantState {
+				t.Errorf("LiftQuarantine() tree_state = %v, want %v", got.TreeState, test.wantState)
+			}
+			if got.PrivateKey != nil {
+				t.Error("LiftQuarantine() returned the private key")
+			}
+		})
+	}
+}
+
+func TestServer_PurgeTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	deletedLog := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	deletedLog.TreeId = 10
+	deletedLog.Deleted = true
+	deletedLog.DeleteTime = ptypes.TimestampNow()
+	activeLog := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	activeLog.TreeId = 11
+
+	tests := []struct {
+		desc       string
+		storedTree *trillian.Tree
+		deleteErr  error
+		wantCode   codes.Code
+		wantReason errmsg.Reason
+	}{
+		{desc: "deleted", storedTree: deletedLog},
+		{desc: "notDeleted", storedTree: activeLog, wantCode: codes.FailedPrecondition, wantReason: errmsg.TreeNotDeleted},
+		{desc: "deleteErr", storedTree: deletedLog, deleteErr: status.Error(codes.Internal, "delete err"), wantCode: codes.Internal},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			setup := setupAdminServer(ctrl, nil /* keygen */, false /* snapshot */, test.wantCode == codes.OK, false /* commitErr */)
+			setup.tx.EXPECT().GetTree(gomock.Any(), test.storedTree.TreeId).Return(proto.Clone(test.storedTree).(*trillian.Tree), nil)
+			if test.storedTree.Deleted {
+				setup.tx.EXPECT().HardDeleteTree(gomock.Any(), test.storedTree.TreeId).Return(test.deleteErr)
+			}
+
+			got, err := setup.server.PurgeTree(ctx, &trillian.PurgeTreeRequest{TreeId: test.storedTree.TreeId})
+			if status.Code(err) != test.wantCode {
+				t.Fatalf("PurgeTree() returned err = %v, want code %v", err, test.wantCode)
+			}
+			if got := errmsg.Reason(errmsg.Info(err).GetReason()); got != test.wantReason {
+				t.Errorf("PurgeTree() reason = %v, want %v", got, test.wantReason)
+			}
+			if err != nil {
+				return
+			}
+			if got.TreeId != test.storedTree.TreeId {
+				t.Errorf("PurgeTree() tree_id = %v, want %v", got.TreeId, test.storedTree.TreeId)
+			}
+			if got.PrivateKey != nil {
+				t.Error("PurgeTree() returned the private key")
+			}
+		})
+	}
+}
+
+// fakeNotifier records the events it is notified of.
+type fakeNotifier struct {
+	events chan *notify.Event
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, event *notify.Event) error {
+	n.events <- event
+	return nil
+}
+
+func TestServer_TreeNotifier(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return now }
+
+	activeLog := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	activeLog.TreeId = 10
+	deletedLog := proto.Clone(activeLog).(*trillian.Tree)
+	deletedLog.Deleted = true
+
+	tests := []struct {
+		desc      string
+		call      func(s *Server, tx *storage.MockAdminTX) error
+		wantEvent *notify.Event
+	}{
+		{
+			desc: "renamed",
+			call: func(s *Server, tx *storage.MockAdminTX) error {
+				stored := proto.Clone(activeLog).(*trillian.Tree)
+				tx.EXPECT().GetTree(gomock.Any(), stored.TreeId).Return(stored, nil)
+				tx.EXPECT().UpdateTree(gomock.Any(), stored.TreeId, gomock.Any()).DoAndReturn(func(ctx context.Context, treeID int64, updateFn func(*trillian.Tree)) (*trillian.Tree, error) {
+					updateFn(stored)
+					return stored, nil
+				})
+				_, err := s.UpdateTree(context.Background(), &trillian.UpdateTreeRequest{
+					Tree:       &trillian.Tree{TreeId: stored.TreeId, DisplayName: "renamed"},
+					UpdateMask: &field_mask.FieldMask{Paths: []string{"display_name"}},
+				})
+				return err
+			},
+		},
+		{
+			desc: "frozen",
+			call: func(s *Server, tx *storage.MockAdminTX) error {
+				stored := proto.Clone(activeLog).(*trillian.Tree)
+				tx.EXPECT().GetTree(gomock.Any(), stored.TreeId).Return(stored, nil)
+				tx.EXPECT().UpdateTree(gomock.Any(), stored.TreeId, gomock.Any()).DoAndReturn(func(ctx context.Context, treeID int64, updateFn func(*trillian.Tree)) (*trillian.Tree, error) {
+					updateFn(stored)
+					return stored, nil
+				})
+				_, err := s.UpdateTree(context.Background(), &trillian.UpdateTreeRequest{
+					Tree:       &trillian.Tree{TreeId: stored.TreeId, TreeState: trillian.TreeState_FROZEN},
+					UpdateMask: &field_mask.FieldMask{Paths: []string{"tree_state"}},
+				})
+				return err
+			},
+			wantEvent: &notify.Event{Kind: notify.StateChanged, Time: now, TreeID: 10, TreeType: "LOG", TreeState: "FROZEN", PreviousTreeState: "ACTIVE"},
+		},
+		{
+			desc: "deleted",
+			call: func(s *Server, tx *storage.MockAdminTX) error {
+				tx.EXPECT().SoftDeleteTree(gomock.Any(), deletedLog.TreeId).Return(proto.Clone(deletedLog).(*trillian.Tree), nil)
+				_, err := s.DeleteTree(context.Background(), &trillian.DeleteTreeRequest{TreeId: deletedLog.TreeId})
+				return err
+			},
+			wantEvent: &notify.Event{Kind: notify.Deleted, Time: now, TreeID: 10, TreeType: "LOG", TreeState: "ACTIVE"},
+		},
+		{
+			desc: "paused",
+			call: func(s *Server, tx *storage.MockAdminTX) error {
+				stored := proto.Clone(activeLog).(*trillian.Tree)
+				tx.EXPECT().GetTree(gomock.Any(), stored.TreeId).Return(stored, nil)
+				tx.EXPECT().UpdateTree(gomock.Any(), stored.TreeId, gomock.Any()).DoAndReturn(func(ctx context.Context, treeID int64, updateFn func(*trillian.Tree)) (*trillian.Tree, error) {
+					updateFn(stored)
+					return stored, nil
+				})
+				_, err := s.PauseSequencing(context.Background(), &trillian.PauseSequencingRequest{TreeId: stored.TreeId})
+				return err
+			},
+			wantEvent: &notify.Event{Kind: notify.StateChanged, Time: now, TreeID: 10, TreeType: "LOG", TreeState: "PAUSED", PreviousTreeState: "ACTIVE"},
+		},
+	}
+
+	notifier := &fakeNotifier{events: make(chan *notify.Event, len(tests))}
+	for _, test := range tests {
+		setup := setupAdminServer(ctrl, nil /* keygen */, false /* snapshot */, true /* shouldCommit */, false /* commitErr */)
+		setup.server.registry.TreeNotifier = notifier
+		if err := test.call(setup.server, setup.tx); err != nil {
+			t.Fatalf("%v: %v", test.desc, err)
+		}
+		// Calls which notify nothing are followed by one which does, which
+		// checks that the earlier call didn't.
+		if test.wantEvent == nil {
+			continue
+		}
+		select {
+		case got := <-notifier.events:
+			if diff := cmp.Diff(got, test.wantEvent); diff != "" {
+				t.Errorf("%v: event diff (-got +want):\n%s", test.desc, diff)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%v: no event notified", test.desc)
+		}
+	}
+}
+
+func TestServer_PauseResumeSequencing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	treeIn := func(tree *trillian.Tree, state trillian.TreeState) *trillian.Tree {
+		tree = proto.Clone(tree).(*trillian.Tree)
+		tree.TreeId = 10
+		tree.TreeState = state
+		return tree
+	}
+	pause := func(s *Server, treeID int64) (*trillian.Tree, error) {
+		return s.PauseSequencing(context.Background(), &trillian.PauseSequencingRequest{TreeId: treeID})
+	}
+	resume := func(s *Server, treeID int64) (*trillian.Tree, error) {
+		return s.ResumeSequencing(context.Background(), &trillian.ResumeSequencingRequest{TreeId: treeID})
+	}
+
+	tests := []struct {
+		desc       string
+		rpc        func(*Server, int64) (*trillian.Tree, error)
+		storedTree *trillian.Tree
+		wantState  trillian.TreeState
+		wantUpdate bool
+		wantCode   codes.Code
+		wantReason errmsg.Reason
+	}{
+		{
+			desc:       "pauseActive",
+			rpc:        pause,
+			storedTree: treeIn(testonly.LogTree, trillian.TreeState_ACTIVE),
+			wantState:  trillian.TreeState_PAUSED,
+			wantUpdate: true,
+		},
+		{
+			desc:       "pausePreordered",
+			rpc:        pause,
+			storedTree: treeIn(testonly.PreorderedLogTree, trillian.TreeState_ACTIVE),
+			wantState:  trillian.TreeState_PAUSED,
+			wantUpdate: true,
+		},
+		{
+			desc:       "pausePaused",
+			rpc:        pause,
+			storedTree: treeIn(testonly.LogTree, trillian.TreeState_PAUSED),
+			wantState:  trillian.TreeState_PAUSED,
+		},
+		{
+			desc:       "pauseFrozen",
+			rpc:        pause,
+			storedTree: treeIn(testonly.LogTree, trillian.TreeState_FROZEN),
+			wantCode:   codes.FailedPrecondition,
+			wantReason: errmsg.TreeNotPausable,
+		},
+		{
+			desc:       "pauseMap",
+			rpc:        pause,
+			storedTree: treeIn(testonly.MapTree, trillian.TreeState_ACTIVE),
+			wantCode:   codes.FailedPrecondition,
+			wantReason: errmsg.TreeNotPausable,
+		},
+		{
+			desc:       "resumePaused",
+			rpc:        resume,
+			storedTree: treeIn(testonly.LogTree, trillian.TreeState_PAUSED),
+			wantState:  trillian.TreeState_ACTIVE,
+			wantUpdate: true,
+		},
+		{
+			desc:       "resumeActive",
+			rpc:        resume,
+			storedTree: treeIn(testonly.LogTree, trillian.TreeState_ACTIVE),
+			wantState:  trillian.TreeState_ACTIVE,
+		},
+		{
+			desc:       "resumeQuarantined",
+			rpc:        resume,
+			storedTree: treeIn(testonly.LogTree, trillian.TreeState_QUARANTINED),
+			wantCode:   codes.FailedPrecondition,
+			wantReason: errmsg.TreeNotPaused,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			setup := setupAdminServer(
+				ctrl,
+				nil,   /* keygen */
+				false, /* snapshot */
+				test.wantCode == codes.OK,
+				false)
+			stored := proto.Clone(test.storedTree).(*trillian.Tree)
+			setup.tx.EXPECT().GetTree(gomock.Any(), stored.TreeId).Return(stored, nil)
+			if test.wantUpdate {
+				setup.tx.EXPECT().UpdateTree(gomock.Any(), stored.TreeId, gomock.Any()).DoAndReturn(func(ctx context.Context, treeID int64, updateFn func(*trillian.Tree)) (*trillian.Tree, error) {
+					updateFn(stored)
+					return stored, nil
+				})
+			}
+
+			got, err := test.rpc(setup.server, stored.TreeId)
+			if status.Code(err) != test.wantCode {
+				t.Fatalf("%s() returned err = %v, want code %v", test.desc, err, test.wantCode)
+			}
+			if got := errmsg.Reason(errmsg.Info(err).GetReason()); got != test.wantReason {
+				t.Errorf("%s() reason = %v, want %v", test.desc, got, test.wantReason)
+			}
+			if err != nil {
+				return
+			}
+			if got.TreeState != test.wantState {
+				t.Errorf("%s() tree_state = %v, want %v", test.desc, got.TreeState, test.wantState)
+			}
+			if got.PrivateKey != nil {
+				t.Errorf("%s() returned the private key", test.desc)
+			}
+		})
+	}
+}
+
 // adminTestSetup contains an operational Server and required dependencies.
 // It's created via setupAdminServer.
+func TestServer_ExportImportTrees(t *testing.T) {
+	ctx := context.Background()
+	keys.RegisterHandler(&keyspb.PrivateKey{}, func(ctx context.Context, pb proto.Message) (crypto.Signer, error) {
+		return der.FromProto(pb.(*keyspb.PrivateKey))
+	})
+
+	src := memory.NewAdminStorage(memory.NewTreeStorage())
+	mustCreate := func(tree *trillian.Tree) *trillian.Tree {
+		t.Helper()
+		created, err := storage.CreateTree(ctx, src, proto.Clone(tree).(*trillian.Tree))
+		if err != nil {
+			t.Fatalf("CreateTree(): %v", err)
+		}
+		return created
+	}
+	const pemPath = "../../testdata/log-rpc-server.privkey.pem"
+	pemKey, err := ptypes.MarshalAny(&keyspb.PEMKeyFile{Path: pemPath, Password: ttestonly.DemoPrivateKeyPass})
+	if err != nil {
+		t.Fatalf("MarshalAny(): %v", err)
+	}
+	derTree := mustCreate(testonly.LogTree)
+	pemTree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	pemTree.PrivateKey = pemKey
+	pemTree = mustCreate(pemTree)
+
+	exporter := New(extension.Registry{AdminStorage: src}, nil)
+	resp, err := exporter.ExportTrees(ctx, &trillian.ExportTreesRequest{})
+	if err != nil {
+		t.Fatalf("ExportTrees(): %v", err)
+	}
+	exported := make(map[int64]*trillian.Tree)
+	for _, tree := range resp.Tree {
+		exported[tree.TreeId] = tree
+	}
+	if got, want := len(exported), 2; got != want {
+		t.Fatalf("ExportTrees() returned %d trees, want %d", got, want)
+	}
+	if key := exported[derTree.TreeId].GetPrivateKey(); key != nil {
+		t.Errorf("ExportTrees() returned private key %v, want none", key)
+	}
+	var ref keyspb.PEMKeyFile
+	if err := ptypes.UnmarshalAny(exported[pemTree.TreeId].GetPrivateKey(), &ref); err != nil {
+		t.Fatalf("UnmarshalAny(): %v", err)
+	}
+	if want := (&keyspb.PEMKeyFile{Path: pemPath}); !proto.Equal(&ref, want) {
+		t.Errorf("ExportTrees() returned key reference %v, want %v", &ref, want)
+	}
+
+	resp, err = exporter.ExportTrees(ctx, &trillian.ExportTreesRequest{TreeId: []int64{pemTree.TreeId}})
+	if err != nil {
+		t.Fatalf("ExportTrees(%d): %v", pemTree.TreeId, err)
+	}
+	if got, want := len(resp.Tree), 1; got != want {
+		t.Errorf("ExportTrees(%d) returned %d trees, want %d", pemTree.TreeId, got, want)
+	}
+	if _, err := exporter.ExportTrees(ctx, &trillian.ExportTreesRequest{TreeId: []int64{12345}}); err == nil {
+		t.Error("ExportTrees(12345) returned nil error for an unknown tree")
+	}
+
+	dst := memory.NewAdminStorage(memory.NewTreeStorage())
+	importer := New(extension.Registry{
+		AdminStorage: dst,
+		NewKeyProto: func(ctx context.Context, spec *keyspb.Specification) (proto.Message, error) {
+			return der.NewProtoFromSpec(spec)
+		},
+	}, nil)
+	// Without a key_spec, the key is generated for the signature algorithm.
+	frozen := proto.Clone(exported[derTree.TreeId]).(*trillian.Tree)
+	frozen.TreeState = trillian.TreeState_FROZEN
+	imported, err := importer.ImportTrees(ctx, &trillian.ImportTreesRequest{Tree: []*trillian.Tree{frozen}})
+	if err != nil {
+		t.Fatalf("ImportTrees(): %v", err)
+	}
+	if got, want := len(imported.Tree), 1; got != want {
+		t.Fatalf("ImportTrees() returned %d trees, want %d", got, want)
+	}
+	got := imported.Tree[0]
+	if got.TreeState != trillian.TreeState_ACTIVE || got.DisplayName != derTree.DisplayName {
+		t.Errorf("ImportTrees() = %+v, want an ACTIVE tree named %q", got, derTree.DisplayName)
+	}
+	if proto.Equal(got.PublicKey, derTree.PublicKey) {
+		t.Error("ImportTrees() kept the public key of the unexported private key")
+	}
+	if got.PrivateKey != nil {
+		t.Error("ImportTrees() returned the private key")
+	}
+
+	// Trees are created in order, and a failure keeps the trees before it.
+	withKey := proto.Clone(exported[derTree.TreeId]).(*trillian.Tree)
+	withKey.PrivateKey = testonly.LogTree.PrivateKey
+	invalid := proto.Clone(exported[derTree.TreeId]).(*trillian.Tree)
+	invalid.TreeType = trillian.TreeType_UNKNOWN_TREE_TYPE
+	_, err = importer.ImportTrees(ctx, &trillian.ImportTreesRequest{Tree: []*trillian.Tree{withKey, invalid}})
+	if got, want := status.Code(err), codes.InvalidArgument; got != want {
+		t.Errorf("ImportTrees() with an invalid tree returned err = %v, want code %v", err, want)
+	}
+	trees, err := storage.ListTrees(ctx, dst, false)
+	if err != nil {
+		t.Fatalf("ListTrees(): %v", err)
+	}
+	if got, want := len(trees), 2; got != want {
+		t.Errorf("ListTrees() returned %d trees after imports, want %d", got, want)
+	}
+
+	// With keep_tree_ids, trees are created with their exported ID, once.
+	keepReq := &trillian.ImportTreesRequest{Tree: []*trillian.Tree{exported[derTree.TreeId]}, KeepTreeIds: true}
+	imported, err = importer.ImportTrees(ctx, keepReq)
+	if err != nil {
+		t.Fatalf("ImportTrees(keep_tree_ids): %v", err)
+	}
+	if got, want := imported.Tree[0].TreeId, derTree.TreeId; got != want {
+		t.Errorf("ImportTrees(keep_tree_ids) created tree %d, want %d", got, want)
+	}
+	_, err = importer.ImportTrees(ctx, keepReq)
+	if got, want := status.Code(err), codes.AlreadyExists; got != want {
+		t.Errorf("ImportTrees(keep_tree_ids) of an existing tree returned err = %v, want code %v", err, want)
+	}
+}
+
+func TestServer_CreateTreesFromTemplate(t *testing.T) {
+	ctx := context.Background()
+	keys.RegisterHandler(&keyspb.PrivateKey{}, func(ctx context.Context, pb proto.Message) (crypto.Signer, error) {
+		return der.FromProto(pb.(*keyspb.PrivateKey))
+	})
+	as := memory.NewAdminStorage(memory.NewTreeStorage())
+	s := New(extension.Registry{
+		AdminStorage: as,
+		NewKeyProto: func(ctx context.Context, spec *keyspb.Specification) (proto.Message, error) {
+			return der.NewProtoFromSpec(spec)
+		},
+	}, nil)
+
+	template := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	template.PrivateKey = nil
+	template.PublicKey = nil
+	template.Labels = map[string]string{"tenant": "acme"}
+	keySpec := &keyspb.Specification{Params: &keyspb.Specification_EcdsaParams{EcdsaParams: &keyspb.Specification_ECDSA{}}}
+	resp, err := s.CreateTreesFromTemplate(ctx, &trillian.CreateTreesFromTemplateRequest{Template: template, KeySpec: keySpec, Count: 3})
+	if err != nil {
+		t.Fatalf("CreateTreesFromTemplate(): %v", err)
+	}
+	if got, want := len(resp.Tree), 3; got != want {
+		t.Fatalf("CreateTreesFromTemplate() returned %d trees, want %d", got, want)
+	}
+	ids := make(map[int64]bool)
+	publicKeys := make(map[string]bool)
+	for _, tree := range resp.Tree {
+		ids[tree.TreeId] = true
+		publicKeys[string(tree.PublicKey.GetDer())] = true
+		if tree.PrivateKey != nil {
+			t.Error("CreateTreesFromTemplate() returned the private key")
+		}
+		if tree.HashStrategy != template.HashStrategy || tree.Labels["tenant"] != "acme" {
+			t.Errorf("CreateTreesFromTemplate() = %+v, want a copy of the template", tree)
+		}
+	}
+	if len(ids) != 3 || len(publicKeys) != 3 {
+		t.Errorf("CreateTreesFromTemplate() returned %d tree IDs and %d public keys, want 3 of each", len(ids), len(publicKeys))
+	}
+
+	// A template private key is shared by all of the trees.
+	resp, err = s.CreateTreesFromTemplate(ctx, &trillian.CreateTreesFromTemplateRequest{Template: testonly.LogTree, Count: 2})
+	if err != nil {
+		t.Fatalf("CreateTreesFromTemplate(private_key): %v", err)
+	}
+	for _, tree := range resp.Tree {
+		if !proto.Equal(tree.PublicKey, testonly.LogTree.PublicKey) {
+			t.Errorf("CreateTreesFromTemplate(private_key) returned public key %v, want %v", tree.PublicKey, testonly.LogTree.PublicKey)
+		}
+	}
+
+	invalid := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	invalid.TreeType = trillian.TreeType_UNKNOWN_TREE_TYPE
+	for _, test := range []struct {
+		desc       string
+		req        *trillian.CreateTreesFromTemplateRequest
+		wantReason errmsg.Reason
+	}{
+		{desc: "noTemplate", req: &trillian.CreateTreesFromTemplateRequest{Count: 1}},
+		{desc: "zeroCount", req: &trillian.CreateTreesFromTemplateRequest{Template: testonly.LogTree}, wantReason: errmsg.FieldNotPositive},
+		{desc: "tooMany", req: &trillian.CreateTreesFromTemplateRequest{Template: testonly.LogTree, Count: maxTemplateTrees + 1}, wantReason: errmsg.TooManyTrees},
+		{desc: "invalidTemplate", req: &trillian.CreateTreesFromTemplateRequest{Template: invalid, Count: 1}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := s.CreateTreesFromTemplate(ctx, test.req)
+			if got, want := status.Code(err), codes.InvalidArgument; got != want {
+				t.Errorf("CreateTreesFromTemplate() returned err = %v, want code %v", err, want)
+			}
+			if got := errmsg.Reason(errmsg.Info(err).GetReason()); got != test.wantReason {
+				t.Errorf("CreateTreesFromTemplate() reason = %v, want %v", got, test.wantReason)
+			}
+		})
+	}
+	trees, err := storage.ListTrees(ctx, as, false)
+	if err != nil {
+		t.Fatalf("ListTrees(): %v", err)
+	}
+	if got, want := len(trees), 5; got != want {
+		t.Errorf("ListTrees() returned %d trees, want %d", got, want)
+	}
+}
+
+func TestServer_CreateTreesFromTemplateRollback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The transaction isn't committed if any of the trees fails.
+	setup := setupAdminServer(ctrl, nil /* keygen */, false /* snapshot */, false /* shouldCommit */, false /* commitErr */)
+	setup.tx.EXPECT().CreateTree(gomock.Any(), gomock.Any()).Return(proto.Clone(testonly.LogTree).(*trillian.Tree), nil)
+	setup.tx.EXPECT().CreateTree(gomock.Any(), gomock.Any()).Return(nil, status.Error(codes.Internal, "create err"))
+
+	req := &trillian.CreateTreesFromTemplateRequest{Template: testonly.LogTree, Count: 2}
+	if _, err := setup.server.CreateTreesFromTemplate(context.Background(), req); status.Code(err) != codes.Internal {
+		t.Errorf("CreateTreesFromTemplate() returned err = %v, want code %v", err, codes.Internal)
+	}
+}
+
 type adminTestSetup struct {
 	registry   extension.Registry
 	as         storage.AdminStorage
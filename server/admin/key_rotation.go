@@ -0,0 +1,248 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keys/der"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/server/errmsg"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/trees"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultKeyRotationOverlap is the time a tree signs with both its old and
+// new keys if RotateTreeKeyRequest.overlap is not set.
+const defaultKeyRotationOverlap = 24 * time.Hour
+
+var (
+	keyRetirementCounter  monitoring.Counter
+	keyRotatorMetricsOnce sync.Once
+)
+
+// RotateTreeKey implements trillian.TrillianAdminServer.RotateTreeKey.
+func (s *Server) RotateTreeKey(ctx context.Context, req *trillian.RotateTreeKeyRequest) (*trillian.Tree, error) {
+	if req.GetPrivateKey() != nil && req.GetKeySpec() != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "the private_key and key_spec fields are mutually exclusive")
+	}
+	overlap := defaultKeyRotationOverlap
+	if req.GetOverlap() != nil {
+		var err error
+		if overlap, err = ptypes.Duration(req.Overlap); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid overlap: %v", err)
+		}
+		if overlap < 0 {
+			return nil, errmsg.New(codes.InvalidArgument, errmsg.FieldNegative, errmsg.Params{"field": "RotateTreeKeyRequest.Overlap", "value": overlap})
+		}
+	}
+
+	var tree *trillian.Tree
+	retireTime := timeNow().Add(overlap)
+	err := s.registry.AdminStorage.ReadWriteTransaction(ctx, func(ctx context.Context, tx storage.AdminTX) error {
+		stored, err := tx.GetTree(ctx, req.GetTreeId())
+		if err != nil {
+			return err
+		}
+		if err := checkNoKeyRotation(stored); err != nil {
+			return err
+		}
+		rotation, err := s.newKeyRotation(ctx, stored, req)
+		if err != nil {
+			return err
+		}
+		if rotation.RetireTime, err = ptypes.TimestampProto(retireTime); err != nil {
+			return status.Errorf(codes.Internal, "failed to marshal retire_time: %v", err)
+		}
+		tree, err = tx.UpdateTree(ctx, stored.TreeId, func(t *trillian.Tree) {
+			t.KeyRotation = rotation
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("Rotating key of tree %d until %v", tree.TreeId, retireTime)
+	return redact(tree), nil
+}
+
+// checkNoKeyRotation returns an error if tree is already rotating its key.
+func checkNoKeyRotation(tree *trillian.Tree) error {
+	if tree.KeyRotation == nil {
+		return nil
+	}
+	retireTime, err := ptypes.Timestamp(tree.KeyRotation.RetireTime)
+	if err != nil {
+		return status.Errorf(codes.Internal, "invalid key_rotation.retire_time: %v", err)
+	}
+	return errmsg.New(codes.FailedPrecondition, errmsg.KeyRotationInProgress, errmsg.Params{"tree_id": tree.TreeId, "retire_time": retireTime})
+}
+
+// newKeyRotation returns a KeyRotation to the key imported or generated by
+// req, without a retire_time.
+func (s *Server) newKeyRotation(ctx context.Context, tree *trillian.Tree, req *trillian.RotateTreeKeyRequest) (*trillian.KeyRotation, error) {
+	rotation := &trillian.KeyRotation{PrivateKey: req.GetPrivateKey()}
+	if rotation.PrivateKey == nil {
+		if s.registry.NewKeyProto == nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "key generation is not enabled")
+		}
+		keySpec := req.GetKeySpec()
+		if keySpec == nil {
+			keySpec = defaultKeySpec(tree.SignatureAlgorithm)
+		}
+		keyProto, err := s.registry.NewKeyProto(ctx, keySpec)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "failed to generate private key: %v", err.Error())
+		}
+		if rotation.PrivateKey, err = ptypes.MarshalAny(keyProto); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to marshal private key: %v", err.Error())
+		}
+	}
+
+	// Check that the new key is valid for the tree by trying to get a signer.
+	rotating := proto.Clone(tree).(*trillian.Tree)
+	rotating.KeyRotation = rotation
+	signer, err := trees.Signer(ctx, rotating)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to create signer for new key: %v", err.Error())
+	}
+	if rotation.PublicKey, err = der.ToPublicProto(signer.NextSigner.Public()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to marshal public key: %v", err.Error())
+	}
+	return rotation, nil
+}
+
+// KeyRotator retires the old keys of trees once their key rotations are due.
+//
+// Key rotation goes through two separate stages:
+// * RotateTreeKey sets Tree.KeyRotation, after which roots are signed by both keys
+// * Retirement replaces the tree's keys by the new ones and clears Tree.KeyRotation
+//
+// KeyRotator performs retirement for trees whose key_rotation.retire_time has passed.
+type KeyRotator struct {
+	// admin is the storage.AdminStorage interface.
+	admin storage.AdminStorage
+
+	// minRunInterval defines how frequently sweeps for due rotations are performed.
+	// Actual runs happen randomly between [minInterval,2*minInterval).
+	minRunInterval time.Duration
+}
+
+// NewKeyRotator returns a new KeyRotator.
+func NewKeyRotator(admin storage.AdminStorage, minRunInterval time.Duration, mf monitoring.MetricFactory) *KeyRotator {
+	keyRotatorMetricsOnce.Do(func() {
+		if mf == nil {
+			mf = monitoring.InertMetricFactory{}
+		}
+		keyRetirementCounter = mf.NewCounter("tree_key_retirements", "Counter of retired tree keys", monitoring.TreeIDLabel, "success")
+	})
+	return &KeyRotator{admin: admin, minRunInterval: minRunInterval}
+}
+
+// Run starts the key retirement process. It runs until ctx is cancelled.
+func (r *KeyRotator) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		count, err := r.RunOnce(ctx)
+		if err != nil {
+			glog.Errorf("KeyRotator.Run: %v", err)
+		}
+		if count > 0 {
+			glog.Infof("KeyRotator.Run: successfully retired keys of %v trees", count)
+		}
+
+		d := r.minRunInterval + time.Duration(rand.Int63n(r.minRunInterval.Nanoseconds()))
+		timeSleep(d)
+	}
+}
+
+// RunOnce performs a single key retirement sweep. Returns the number of trees
+// whose old keys were retired.
+//
+// It attempts to retire as many keys as possible, regardless of failures. If it
+// encounters any failures the resulting error is non-nil.
+func (r *KeyRotator) RunOnce(ctx context.Context) (int, error) {
+	now := timeNow()
+
+	trees, err := storage.ListTrees(ctx, r.admin, false /* includeDeleted */)
+	if err != nil {
+		return 0, fmt.Errorf("error listing trees: %v", err)
+	}
+
+	count := 0
+	var errs []error
+	for _, tree := range trees {
+		if tree.KeyRotation == nil {
+			continue
+		}
+		retireTime, err := ptypes.Timestamp(tree.KeyRotation.RetireTime)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error parsing key_rotation.retire_time of tree %v: %v", tree.TreeId, err))
+			keyRetirementCounter.Inc(fmt.Sprint(tree.TreeId), "false")
+			continue
+		}
+		if now.Before(retireTime) {
+			continue
+		}
+
+		glog.Infof("KeyRotator.RunOnce: Retiring old key of tree %v", tree.TreeId)
+		// The rotation may have been retired since the trees were listed.
+		_, err = storage.UpdateTree(ctx, r.admin, tree.TreeId, func(t *trillian.Tree) {
+			if t.KeyRotation == nil {
+				return
+			}
+			t.PrivateKey = t.KeyRotation.PrivateKey
+			t.PublicKey = t.KeyRotation.PublicKey
+			t.KeyRotation = nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error retiring key of tree %v: %v", tree.TreeId, err))
+			keyRetirementCounter.Inc(fmt.Sprint(tree.TreeId), "false")
+			continue
+		}
+
+		count++
+		keyRetirementCounter.Inc(fmt.Sprint(tree.TreeId), "true")
+	}
+
+	if len(errs) == 0 {
+		return count, nil
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("encountered errors retiring tree keys:")
+	for _, err := range errs {
+		buf.WriteString("\n\t")
+		buf.WriteString(err.Error())
+	}
+	return count, errors.New(buf.String())
+}
@@ -0,0 +1,223 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keys/der"
+	"github.com/google/trillian/crypto/keyspb"
+	"github.com/google/trillian/server/errmsg"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/storage/testonly"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServer_RotateTreeKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return now }
+
+	keygen := func(ctx context.Context, spec *keyspb.Specification) (proto.Message, error) {
+		return der.NewProtoFromSpec(spec)
+	}
+	rotating := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	rotating.KeyRotation = &trillian.KeyRotation{
+		PrivateKey: testonly.MapTree.PrivateKey,
+		PublicKey:  testonly.MapTree.PublicKey,
+		RetireTime: ptypes.TimestampNow(),
+	}
+
+	tests := []struct {
+		desc          string
+		storedTree    *trillian.Tree
+		noKeygen      bool
+		req           *trillian.RotateTreeKeyRequest
+		wantPublicKey *keyspb.PublicKey
+		wantRetire    time.Time
+		wantCode      codes.Code
+		wantReason    errmsg.Reason
+	}{
+		{
+			desc:       "generate",
+			storedTree: testonly.LogTree,
+			req:        &trillian.RotateTreeKeyRequest{},
+			wantRetire: now.Add(defaultKeyRotationOverlap),
+		},
+		{
+			desc:          "import",
+			storedTree:    testonly.LogTree,
+			noKeygen:      true,
+			req:           &trillian.RotateTreeKeyRequest{PrivateKey: testonly.MapTree.PrivateKey, Overlap: ptypes.DurationProto(time.Hour)},
+			wantPublicKey: testonly.MapTree.PublicKey,
+			wantRetire:    now.Add(time.Hour),
+		},
+		{
+			desc:       "keySpec",
+			storedTree: testonly.LogTree,
+			req: &trillian.RotateTreeKeyRequest{KeySpec: &keyspb.Specification{
+				Params: &keyspb.Specification_EcdsaParams{EcdsaParams: &keyspb.Specification_ECDSA{}},
+			}},
+			wantRetire: now.Add(defaultKeyRotationOverlap),
+		},
+		{
+			desc: "privateKeyAndKeySpec",
+			req: &trillian.RotateTreeKeyRequest{
+				PrivateKey: testonly.MapTree.PrivateKey,
+				KeySpec:    &keyspb.Specification{},
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			desc:       "negativeOverlap",
+			req:        &trillian.RotateTreeKeyRequest{Overlap: ptypes.DurationProto(-time.Hour)},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.FieldNegative,
+		},
+		{
+			desc:       "inProgress",
+			storedTree: rotating,
+			req:        &trillian.RotateTreeKeyRequest{},
+			wantCode:   codes.FailedPrecondition,
+			wantReason: errmsg.KeyRotationInProgress,
+		},
+		{
+			desc:       "noKeygen",
+			storedTree: testonly.LogTree,
+			noKeygen:   true,
+			req:        &trillian.RotateTreeKeyRequest{},
+			wantCode:   codes.FailedPrecondition,
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var gen func(context.Context, *keyspb.Specification) (proto.Message, error)
+			if !test.noKeygen {
+				gen = keygen
+			}
+			setup := setupAdminServer(ctrl, gen, false /* snapshot */, test.wantCode == codes.OK, false /* commitErr */)
+			if test.storedTree != nil {
+				stored := proto.Clone(test.storedTree).(*trillian.Tree)
+				test.req.TreeId = stored.TreeId
+				setup.tx.EXPECT().GetTree(gomock.Any(), stored.TreeId).Return(stored, nil)
+				if test.wantCode == codes.OK {
+					setup.tx.EXPECT().UpdateTree(gomock.Any(), stored.TreeId, gomock.Any()).DoAndReturn(func(ctx context.Context, treeID int64, updateFn func(*trillian.Tree)) (*trillian.Tree, error) {
+						updateFn(stored)
+						return stored, nil
+					})
+				}
+			}
+
+			got, err := setup.server.RotateTreeKey(ctx, test.req)
+			if status.Code(err) != test.wantCode {
+				t.Fatalf("RotateTreeKey() returned err = %v, want code %v", err, test.wantCode)
+			}
+			if got := errmsg.Reason(errmsg.Info(err).GetReason()); got != test.wantReason {
+				t.Errorf("RotateTreeKey() reason = %v, want %v", got, test.wantReason)
+			}
+			if err != nil {
+				return
+			}
+			rotation := got.KeyRotation
+			if rotation == nil {
+				t.Fatal("RotateTreeKey() returned no key_rotation")
+			}
+			if got.PrivateKey != nil || rotation.PrivateKey != nil {
+				t.Error("RotateTreeKey() returned a private key")
+			}
+			if rotation.PublicKey == nil || proto.Equal(rotation.PublicKey, got.PublicKey) {
+				t.Errorf("RotateTreeKey().KeyRotation.PublicKey = %v, want a new key", rotation.PublicKey)
+			}
+			if test.wantPublicKey != nil && !proto.Equal(rotation.PublicKey, test.wantPublicKey) {
+				t.Errorf("RotateTreeKey().KeyRotation.PublicKey = %v, want %v", rotation.PublicKey, test.wantPublicKey)
+			}
+			if retire, err := ptypes.Timestamp(rotation.RetireTime); err != nil || !retire.Equal(test.wantRetire) {
+				t.Errorf("RotateTreeKey().KeyRotation.RetireTime = %v (err = %v), want %v", retire, err, test.wantRetire)
+			}
+		})
+	}
+}
+
+func TestKeyRotator_RunOnce(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return now }
+
+	as := memory.NewAdminStorage(memory.NewTreeStorage())
+	rotate := func(retireTime time.Time) *trillian.Tree {
+		tree, err := storage.CreateTree(ctx, as, testonly.LogTree)
+		if err != nil {
+			t.Fatalf("CreateTree(): %v", err)
+		}
+		ts, err := ptypes.TimestampProto(retireTime)
+		if err != nil {
+			t.Fatalf("TimestampProto(): %v", err)
+		}
+		tree, err = storage.UpdateTree(ctx, as, tree.TreeId, func(tree *trillian.Tree) {
+			tree.KeyRotation = &trillian.KeyRotation{
+				PrivateKey: testonly.MapTree.PrivateKey,
+				PublicKey:  testonly.MapTree.PublicKey,
+				RetireTime: ts,
+			}
+		})
+		if err != nil {
+			t.Fatalf("UpdateTree(): %v", err)
+		}
+		return tree
+	}
+	due := rotate(now.Add(-time.Minute))
+	pending := rotate(now.Add(time.Minute))
+
+	count, err := NewKeyRotator(as, time.Minute, nil).RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce(): %v", err)
+	}
+	if got, want := count, 1; got != want {
+		t.Errorf("RunOnce() = %v, want %v", got, want)
+	}
+
+	got, err := storage.GetTree(ctx, as, due.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree(): %v", err)
+	}
+	if got.KeyRotation != nil {
+		t.Errorf("retired tree has key_rotation %v, want nil", got.KeyRotation)
+	}
+	if !proto.Equal(got.PublicKey, testonly.MapTree.PublicKey) || !proto.Equal(got.PrivateKey, testonly.MapTree.PrivateKey) {
+		t.Errorf("retired tree has public key %v, want %v", got.PublicKey, testonly.MapTree.PublicKey)
+	}
+
+	got, err = storage.GetTree(ctx, as, pending.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree(): %v", err)
+	}
+	if got.KeyRotation == nil || !proto.Equal(got.PublicKey, testonly.LogTree.PublicKey) {
+		t.Errorf("pending tree has public key %v and key_rotation %v, want unchanged", got.PublicKey, got.KeyRotation)
+	}
+}
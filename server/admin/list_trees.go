@@ -0,0 +1,162 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/server/errmsg"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxListTreesPageSize is the largest number of trees returned by a ListTrees
+// call with a page size.
+const maxListTreesPageSize = 1000
+
+// treeFilter holds the filters of a ListTrees request.
+type treeFilter struct {
+	types         map[trillian.TreeType]bool
+	states        map[trillian.TreeState]bool
+	namePrefix    string
+	labels        map[string]string
+	createdAfter  time.Time // Inclusive, if not zero.
+	createdBefore time.Time // Exclusive, if not zero.
+	afterID       int64     // From the page token, if set.
+	hasPageToken  bool
+	pageSize      int // Zero means no limit.
+}
+
+// newTreeFilter returns the filter of req, or an InvalidArgument error if it
+// is malformed.
+func newTreeFilter(req *trillian.ListTreesRequest) (*treeFilter, error) {
+	f := &treeFilter{
+		namePrefix: req.DisplayNamePrefix,
+		labels:     req.Labels,
+		pageSize:   int(req.PageSize),
+	}
+	if f.pageSize < 0 {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.FieldNegative, errmsg.Params{"field": "ListTreesRequest.PageSize", "value": req.PageSize})
+	}
+	if f.pageSize > maxListTreesPageSize {
+		f.pageSize = maxListTreesPageSize
+	}
+	if len(req.TreeTypes) > 0 {
+		f.types = make(map[trillian.TreeType]bool)
+		for _, tt := range req.TreeTypes {
+			f.types[tt] = true
+		}
+	}
+	if len(req.TreeStates) > 0 {
+		f.states = make(map[trillian.TreeState]bool)
+		for _, ts := range req.TreeStates {
+			f.states[ts] = true
+		}
+	}
+	var err error
+	if req.CreateTimeStart != nil {
+		if f.createdAfter, err = ptypes.Timestamp(req.CreateTimeStart); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid create_time_start: %v", err)
+		}
+	}
+	if req.CreateTimeEnd != nil {
+		if f.createdBefore, err = ptypes.Timestamp(req.CreateTimeEnd); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid create_time_end: %v", err)
+		}
+	}
+	if req.PageToken != "" {
+		if f.afterID, err = parseTreePageToken(req.PageToken); err != nil {
+			return nil, err
+		}
+		f.hasPageToken = true
+	}
+	return f, nil
+}
+
+// matches returns whether tree passes the filters, other than the page token.
+func (f *treeFilter) matches(tree *trillian.Tree) bool {
+	if f.types != nil && !f.types[tree.TreeType] {
+		return false
+	}
+	if f.states != nil && !f.states[tree.TreeState] {
+		return false
+	}
+	if !strings.HasPrefix(tree.DisplayName, f.namePrefix) {
+		return false
+	}
+	for k, v := range f.labels {
+		if got, ok := tree.Labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	if !f.createdAfter.IsZero() || !f.createdBefore.IsZero() {
+		created, err := ptypes.Timestamp(tree.CreateTime)
+		if err != nil {
+			return false
+		}
+		if !f.createdAfter.IsZero() && created.Before(f.createdAfter) {
+			return false
+		}
+		if !f.createdBefore.IsZero() && !created.Before(f.createdBefore) {
+			return false
+		}
+	}
+	return true
+}
+
+// page returns the trees which pass the filter, in ascending tree ID order
+// and limited to the page size, along with the token of the next page.
+func (f *treeFilter) page(trees []*trillian.Tree) ([]*trillian.Tree, string) {
+	sort.Slice(trees, func(i, j int) bool { return trees[i].TreeId < trees[j].TreeId })
+	ret := make([]*trillian.Tree, 0, len(trees))
+	for _, tree := range trees {
+		if f.hasPageToken && tree.TreeId <= f.afterID {
+			continue
+		}
+		if !f.matches(tree) {
+			continue
+		}
+		if f.pageSize > 0 && len(ret) == f.pageSize {
+			return ret, treePageToken(ret[len(ret)-1].TreeId)
+		}
+		ret = append(ret, tree)
+	}
+	return ret, ""
+}
+
+// treePageToken returns the page token which resumes a listing after the tree
+// with treeID.
+func treePageToken(treeID int64) string {
+	return strconv.FormatInt(treeID, 10)
+}
+
+func parseTreePageToken(token string) (int64, error) {
+	id, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		return 0, errmsg.New(codes.InvalidArgument, errmsg.InvalidPageToken, errmsg.Params{"detail": err})
+	}
+	if id < 0 {
+		return 0, errmsg.New(codes.InvalidArgument, errmsg.InvalidPageToken, errmsg.Params{
+			"detail": fmt.Sprintf("negative tree ID %d", id),
+		})
+	}
+	return id, nil
+}
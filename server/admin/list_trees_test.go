@@ -0,0 +1,123 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage/testonly"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServer_ListTreesFilters(t *testing.T) {
+	created := time.Unix(1000, 0)
+	newTree := func(id int64, base *trillian.Tree, state trillian.TreeState, name string, age time.Duration) *trillian.Tree {
+		tree := proto.Clone(base).(*trillian.Tree)
+		tree.TreeId = id
+		tree.TreeState = state
+		tree.DisplayName = name
+		createTime, err := ptypes.TimestampProto(created.Add(age))
+		if err != nil {
+			t.Fatalf("TimestampProto(): %v", err)
+		}
+		tree.CreateTime = createTime
+		return tree
+	}
+	// Storage returns the trees out of order.
+	trees := []*trillian.Tree{
+		newTree(4, testonly.MapTree, trillian.TreeState_ACTIVE, "ct-map", 3*time.Hour),
+		newTree(1, testonly.LogTree, trillian.TreeState_ACTIVE, "ct-2019", 0),
+		newTree(3, testonly.LogTree, trillian.TreeState_FROZEN, "other", 2*time.Hour),
+		newTree(2, testonly.LogTree, trillian.TreeState_ACTIVE, "ct-2020", time.Hour),
+	}
+	trees[1].Labels = map[string]string{"env": "prod", "owner": "ct"}
+	trees[3].Labels = map[string]string{"env": "test", "owner": "ct"}
+	timestamp := func(age time.Duration) *trillian.ListTreesRequest {
+		start, _ := ptypes.TimestampProto(created.Add(age))
+		end, _ := ptypes.TimestampProto(created.Add(age + 2*time.Hour))
+		return &trillian.ListTreesRequest{CreateTimeStart: start, CreateTimeEnd: end}
+	}
+
+	for _, test := range []struct {
+		desc     string
+		req      *trillian.ListTreesRequest
+		wantIDs  []int64
+		wantNext string
+	}{
+		{desc: "all", req: &trillian.ListTreesRequest{}, wantIDs: []int64{1, 2, 3, 4}},
+		{desc: "types", req: &trillian.ListTreesRequest{TreeTypes: []trillian.TreeType{trillian.TreeType_MAP}}, wantIDs: []int64{4}},
+		{desc: "states", req: &trillian.ListTreesRequest{TreeStates: []trillian.TreeState{trillian.TreeState_FROZEN}}, wantIDs: []int64{3}},
+		{desc: "prefix", req: &trillian.ListTreesRequest{DisplayNamePrefix: "ct-20"}, wantIDs: []int64{1, 2}},
+		{desc: "labels", req: &trillian.ListTreesRequest{Labels: map[string]string{"owner": "ct"}}, wantIDs: []int64{1, 2}},
+		{desc: "labelValue", req: &trillian.ListTreesRequest{Labels: map[string]string{"owner": "ct", "env": "test"}}, wantIDs: []int64{2}},
+		{desc: "createTime", req: timestamp(time.Hour), wantIDs: []int64{2, 3}},
+		{
+			desc:    "combined",
+			req:     &trillian.ListTreesRequest{TreeTypes: []trillian.TreeType{trillian.TreeType_LOG}, TreeStates: []trillian.TreeState{trillian.TreeState_ACTIVE}, DisplayNamePrefix: "ct-"},
+			wantIDs: []int64{1, 2},
+		},
+		{desc: "firstPage", req: &trillian.ListTreesRequest{PageSize: 2}, wantIDs: []int64{1, 2}, wantNext: "2"},
+		{desc: "lastPage", req: &trillian.ListTreesRequest{PageSize: 2, PageToken: "2"}, wantIDs: []int64{3, 4}},
+		{desc: "filteredPage", req: &trillian.ListTreesRequest{PageSize: 1, PageToken: "1", DisplayNamePrefix: "ct-"}, wantIDs: []int64{2}, wantNext: "2"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			setup := setupAdminServer(ctrl, nil /* keygen */, true /* snapshot */, true /* shouldCommit */, false /* commitErr */)
+			setup.snapshotTX.EXPECT().ListTrees(gomock.Any(), false).Return(trees, nil)
+
+			resp, err := setup.server.ListTrees(context.Background(), test.req)
+			if err != nil {
+				t.Fatalf("ListTrees() returned err = %v", err)
+			}
+			var gotIDs []int64
+			for _, tree := range resp.Tree {
+				gotIDs = append(gotIDs, tree.TreeId)
+			}
+			if len(gotIDs) != len(test.wantIDs) {
+				t.Fatalf("ListTrees() returned trees %v, want %v", gotIDs, test.wantIDs)
+			}
+			for i := range gotIDs {
+				if gotIDs[i] != test.wantIDs[i] {
+					t.Fatalf("ListTrees() returned trees %v, want %v", gotIDs, test.wantIDs)
+				}
+			}
+			if resp.NextPageToken != test.wantNext {
+				t.Errorf("ListTrees().NextPageToken = %q, want %q", resp.NextPageToken, test.wantNext)
+			}
+		})
+	}
+}
+
+func TestServer_ListTreesBadRequest(t *testing.T) {
+	s := &Server{}
+	for _, req := range []*trillian.ListTreesRequest{
+		{PageSize: -1},
+		{PageToken: "x"},
+		{PageToken: "-1"},
+	} {
+		if _, err := s.ListTrees(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListTrees(%v) returned err = %v, wantCode = %s", req, err, codes.InvalidArgument)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/server/errmsg"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/trees"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	tcrypto "github.com/google/trillian/crypto"
+)
+
+// ResignLatestRoot implements trillian.TrillianAdminServer.ResignLatestRoot.
+func (s *Server) ResignLatestRoot(ctx context.Context, req *trillian.ResignLatestRootRequest) (*trillian.ResignLatestRootResponse, error) {
+	tree, err := trees.GetTree(ctx, s.registry.AdminStorage, req.GetTreeId(), trees.NewGetOpts(trees.Admin))
+	if err != nil {
+		return nil, err
+	}
+	// A quarantined tree failed an integrity check, so its root must not be
+	// vouched for again.
+	if tree.TreeState == trillian.TreeState_QUARANTINED {
+		return nil, errmsg.New(codes.FailedPrecondition, errmsg.TreeQuarantined, errmsg.Params{"tree_id": tree.TreeId})
+	}
+	signer, err := trees.Signer(ctx, tree)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to create signer for tree %d: %v", tree.TreeId, err)
+	}
+
+	resp := &trillian.ResignLatestRootResponse{}
+	switch tree.TreeType {
+	case trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG:
+		resp.SignedLogRoot, err = s.resignLogRoot(ctx, tree, signer)
+	case trillian.TreeType_MAP:
+		resp.SignedMapRoot, err = s.resignMapRoot(ctx, tree, signer)
+	default:
+		err = status.Errorf(codes.InvalidArgument, "can't re-sign the root of a tree of type %v", tree.TreeType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("Re-signed latest root of tree %d", tree.TreeId)
+	return resp, nil
+}
+
+// resignLogRoot stores and returns a copy of the latest root of log tree,
+// signed by signer at a new revision with the current time.
+func (s *Server) resignLogRoot(ctx context.Context, tree *trillian.Tree, signer *tcrypto.Signer) (*trillian.SignedLogRoot, error) {
+	if s.registry.LogStorage == nil {
+		return nil, status.Errorf(codes.Unimplemented, "this server does not serve logs")
+	}
+	var newRoot *trillian.SignedLogRoot
+	err := s.registry.LogStorage.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		latest, err := tx.LatestSignedLogRoot(ctx)
+		if err != nil {
+			return err
+		}
+		var root types.LogRootV1
+		if err := root.UnmarshalBinary(latest.LogRoot); err != nil {
+			return status.Errorf(codes.Internal, "failed to unmarshal latest root: %v", err)
+		}
+		if root.Revision, err = nextRootRevision(ctx, tx, root.Revision); err != nil {
+			return err
+		}
+		if root.TimestampNanos, err = nextRootTimestamp(tree, root.TimestampNanos); err != nil {
+			return err
+		}
+		if newRoot, err = signer.SignLogRoot(&root); err != nil {
+			return fmt.Errorf("SignLogRoot(): %v", err)
+		}
+		return tx.StoreSignedLogRoot(ctx, newRoot)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newRoot, nil
+}
+
+// resignMapRoot stores and returns a copy of the latest root of map tree,
+// signed by signer at a new revision with the current time.
+func (s *Server) resignMapRoot(ctx context.Context, tree *trillian.Tree, signer *tcrypto.Signer) (*trillian.SignedMapRoot, error) {
+	if s.registry.MapStorage == nil {
+		return nil, status.Errorf(codes.Unimplemented, "this server does not serve maps")
+	}
+	var newRoot *trillian.SignedMapRoot
+	err := s.registry.MapStorage.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		latest, err := tx.LatestSignedMapRoot(ctx)
+		if err != nil {
+			return err
+		}
+		var root types.MapRootV1
+		if err := root.UnmarshalBinary(latest.MapRoot); err != nil {
+			return status.Errorf(codes.Internal, "failed to unmarshal latest root: %v", err)
+		}
+		if root.Revision, err = nextRootRevision(ctx, tx, root.Revision); err != nil {
+			return err
+		}
+		if root.TimestampNanos, err = nextRootTimestamp(tree, root.TimestampNanos); err != nil {
+			return err
+		}
+		if newRoot, err = signer.SignMapRoot(&root); err != nil {
+			return fmt.Errorf("SignMapRoot(): %v", err)
+		}
+		return tx.StoreSignedMapRoot(ctx, newRoot)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newRoot, nil
+}
+
+// nextRootRevision returns the write revision of tx, which must follow the
+// revision of the latest root.
+func nextRootRevision(ctx context.Context, tx storage.TreeWriter, latest uint64) (uint64, error) {
+	rev, err := tx.WriteRevision(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if got, want := rev, int64(latest)+1; got != want {
+		return 0, fmt.Errorf("got write revision %d, want %d", got, want)
+	}
+	return uint64(rev), nil
+}
+
+// nextRootTimestamp returns the current time, which must be later than the
+// timestamp of the latest root.
+func nextRootTimestamp(tree *trillian.Tree, latest uint64) (uint64, error) {
+	now := uint64(timeNow().UnixNano())
+	if now <= latest {
+		return 0, status.Errorf(codes.FailedPrecondition, "latest root of tree %d has timestamp %v, not before the current time", tree.TreeId, time.Unix(0, int64(latest)))
+	}
+	return now, nil
+}
@@ -0,0 +1,212 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"crypto"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keys/der"
+	"github.com/google/trillian/server/errmsg"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/testonly"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	tcrypto "github.com/google/trillian/crypto"
+)
+
+func TestServer_ResignLatestRoot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return now }
+
+	newTree := func(template *trillian.Tree, update func(*trillian.Tree)) *trillian.Tree {
+		tree := proto.Clone(template).(*trillian.Tree)
+		tree.TreeId = 12345
+		if update != nil {
+			update(tree)
+		}
+		return tree
+	}
+	rootHash := []byte("01234567890123456789012345678901")
+	rootTime := uint64(now.Add(-time.Hour).UnixNano())
+
+	tests := []struct {
+		desc          string
+		tree          *trillian.Tree
+		rootTime      uint64
+		writeRevision int64
+		noLogStorage  bool
+		wantCode      codes.Code
+		wantReason    errmsg.Reason
+	}{
+		{desc: "log", tree: newTree(testonly.LogTree, nil)},
+		{desc: "preorderedLog", tree: newTree(testonly.PreorderedLogTree, nil)},
+		{desc: "map", tree: newTree(testonly.MapTree, nil)},
+		{
+			desc: "frozenLog",
+			tree: newTree(testonly.LogTree, func(t *trillian.Tree) { t.TreeState = trillian.TreeState_FROZEN }),
+		},
+		{
+			desc:       "quarantined",
+			tree:       newTree(testonly.LogTree, func(t *trillian.Tree) { t.TreeState = trillian.TreeState_QUARANTINED }),
+			wantCode:   codes.FailedPrecondition,
+			wantReason: errmsg.TreeQuarantined,
+		},
+		{
+			desc:     "deleted",
+			tree:     newTree(testonly.LogTree, func(t *trillian.Tree) { t.Deleted = true }),
+			wantCode: codes.NotFound,
+		},
+		{
+			desc:     "rootFromFuture",
+			tree:     newTree(testonly.LogTree, nil),
+			rootTime: uint64(now.Add(time.Minute).UnixNano()),
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			desc:          "revisionMismatch",
+			tree:          newTree(testonly.MapTree, nil),
+			writeRevision: 7,
+			wantCode:      codes.Unknown,
+		},
+		{
+			desc:         "noLogStorage",
+			tree:         newTree(testonly.LogTree, nil),
+			noLogStorage: true,
+			wantCode:     codes.Unimplemented,
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			setup := setupAdminServer(ctrl, nil /* keygen */, true /* snapshot */, true /* shouldCommit */, false /* commitErr */)
+			setup.snapshotTX.EXPECT().GetTree(gomock.Any(), test.tree.TreeId).Return(test.tree, nil)
+
+			latestTime := rootTime
+			if test.rootTime != 0 {
+				latestTime = test.rootTime
+			}
+			writeRevision := int64(6)
+			if test.writeRevision != 0 {
+				writeRevision = test.writeRevision
+			}
+			reachesStorage := test.wantReason == "" && test.wantCode != codes.NotFound && !test.noLogStorage
+			stores := test.wantCode == codes.OK
+
+			var stored proto.Message
+			if test.tree.TreeType == trillian.TreeType_MAP {
+				ms := storage.NewMockMapStorage(ctrl)
+				setup.server.registry.MapStorage = ms
+				if reachesStorage {
+					tx := storage.NewMockMapTreeTX(ctrl)
+					ms.EXPECT().ReadWriteTransaction(gomock.Any(), test.tree, gomock.Any()).DoAndReturn(
+						func(ctx context.Context, tree *trillian.Tree, f storage.MapTXFunc) error {
+							return f(ctx, tx)
+						})
+					latest, err := (&types.MapRootV1{RootHash: rootHash, TimestampNanos: latestTime, Revision: 5, Metadata: []byte("meta")}).MarshalBinary()
+					if err != nil {
+						t.Fatalf("MarshalBinary(): %v", err)
+					}
+					tx.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(&trillian.SignedMapRoot{MapRoot: latest}, nil)
+					tx.EXPECT().WriteRevision(gomock.Any()).Return(writeRevision, nil)
+					if stores {
+						tx.EXPECT().StoreSignedMapRoot(gomock.Any(), gomock.Any()).DoAndReturn(
+							func(ctx context.Context, root *trillian.SignedMapRoot) error {
+								stored = root
+								return nil
+							})
+					}
+				}
+			} else if !test.noLogStorage {
+				ls := storage.NewMockLogStorage(ctrl)
+				setup.server.registry.LogStorage = ls
+				if reachesStorage {
+					tx := storage.NewMockLogTreeTX(ctrl)
+					ls.EXPECT().ReadWriteTransaction(gomock.Any(), test.tree, gomock.Any()).DoAndReturn(
+						func(ctx context.Context, tree *trillian.Tree, f storage.LogTXFunc) error {
+							return f(ctx, tx)
+						})
+					latest, err := (&types.LogRootV1{RootHash: rootHash, TimestampNanos: latestTime, TreeSize: 42, Revision: 5}).MarshalBinary()
+					if err != nil {
+						t.Fatalf("MarshalBinary(): %v", err)
+					}
+					tx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(&trillian.SignedLogRoot{LogRoot: latest}, nil)
+					tx.EXPECT().WriteRevision(gomock.Any()).Return(writeRevision, nil)
+					if stores {
+						tx.EXPECT().StoreSignedLogRoot(gomock.Any(), gomock.Any()).DoAndReturn(
+							func(ctx context.Context, root *trillian.SignedLogRoot) error {
+								stored = root
+								return nil
+							})
+					}
+				}
+			}
+
+			resp, err := setup.server.ResignLatestRoot(ctx, &trillian.ResignLatestRootRequest{TreeId: test.tree.TreeId})
+			if status.Code(err) != test.wantCode {
+				t.Fatalf("ResignLatestRoot() returned err = %v, want code %v", err, test.wantCode)
+			}
+			if got := errmsg.Reason(errmsg.Info(err).GetReason()); got != test.wantReason {
+				t.Errorf("ResignLatestRoot() reason = %v, want %v", got, test.wantReason)
+			}
+			if err != nil {
+				return
+			}
+
+			pub, err := der.FromPublicProto(test.tree.PublicKey)
+			if err != nil {
+				t.Fatalf("FromPublicProto(): %v", err)
+			}
+			if test.tree.TreeType == trillian.TreeType_MAP {
+				if resp.SignedLogRoot != nil || !proto.Equal(resp.SignedMapRoot, stored) {
+					t.Fatalf("ResignLatestRoot() = %v, want the stored map root %v", resp, stored)
+				}
+				root, err := tcrypto.VerifySignedMapRoot(pub, crypto.SHA256, resp.SignedMapRoot)
+				if err != nil {
+					t.Fatalf("VerifySignedMapRoot(): %v", err)
+				}
+				want := &types.MapRootV1{RootHash: rootHash, TimestampNanos: uint64(now.UnixNano()), Revision: 6, Metadata: []byte("meta")}
+				if !reflect.DeepEqual(root, want) {
+					t.Errorf("ResignLatestRoot() signed %+v, want %+v", root, want)
+				}
+				return
+			}
+			if resp.SignedMapRoot != nil || !proto.Equal(resp.SignedLogRoot, stored) {
+				t.Fatalf("ResignLatestRoot() = %v, want the stored log root %v", resp, stored)
+			}
+			root, err := tcrypto.VerifySignedLogRoot(pub, crypto.SHA256, resp.SignedLogRoot)
+			if err != nil {
+				t.Fatalf("VerifySignedLogRoot(): %v", err)
+			}
+			want := &types.LogRootV1{RootHash: rootHash, TimestampNanos: uint64(now.UnixNano()), TreeSize: 42, Revision: 6, Metadata: []byte{}}
+			if !reflect.DeepEqual(root, want) {
+				t.Errorf("ResignLatestRoot() signed %+v, want %+v", root, want)
+			}
+		})
+	}
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
 )
@@ -32,6 +33,7 @@ import (
 const (
 	deleteErrReason        = "delete_error"
 	timestampParseErrReson = "timestamp_parse_error"
+	durationParseErrReason = "duration_parse_error"
 )
 
 var (
@@ -39,6 +41,7 @@ var (
 	timeSleep = time.Sleep
 
 	hardDeleteCounter monitoring.Counter
+	pendingPurgeGauge monitoring.Gauge
 	metricsOnce       sync.Once
 )
 
@@ -53,13 +56,14 @@ func incHardDeleteCounter(treeID int64, success bool, reason string) {
 // * Hard deletion, which effectively removes all tree data
 //
 // DeletedTreeGC performs the transition from soft to hard deletion. Trees that have been deleted
-// for at least DeletedThreshold are eligible for garbage collection.
+// for at least their delete_retention, or deleteThreshold if they don't set one, are eligible for
+// garbage collection.
 type DeletedTreeGC struct {
 	// admin is the storage.AdminStorage interface.
 	admin storage.AdminStorage
 
 	// deleteThreshold defines the minimum time a tree has to remain in the soft-deleted state
-	// before it's eligible for garbage collection.
+	// before it's eligible for garbage collection, unless the tree sets its delete_retention.
 	deleteThreshold time.Duration
 
 	// minRunInterval defines how frequently sweeps for deleted trees are performed.
@@ -79,6 +83,7 @@ func NewDeletedTreeGC(admin storage.AdminStorage, threshold, minRunInterval time
 			mf = monitoring.InertMetricFactory{}
 		}
 		hardDeleteCounter = mf.NewCounter("tree_hard_delete_counter", "Counter of hard-deleted trees", monitoring.TreeIDLabel, "success", "reason")
+		pendingPurgeGauge = mf.NewGauge("tree_pending_purges", "Number of soft-deleted trees not yet hard-deleted after the last sweep")
 	})
 	return gc
 }
@@ -122,20 +127,27 @@ func (gc *DeletedTreeGC) RunOnce(ctx context.Context) (int, error) {
 		return 0, fmt.Errorf("error listing trees: %v", err)
 	}
 
-	count := 0
+	count, pending := 0, 0
 	var errs []error
 	for _, tree := range trees {
 		if !tree.Deleted {
 			continue
 		}
+		pending++
 		deleteTime, err := ptypes.Timestamp(tree.DeleteTime)
 		if err != nil {
 			errs = append(errs, fmt.Errorf("error parsing delete_time of tree %v: %v", tree.TreeId, err))
 			incHardDeleteCounter(tree.TreeId, false, timestampParseErrReson)
 			continue
 		}
+		retention, err := gc.retention(tree)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error parsing delete_retention of tree %v: %v", tree.TreeId, err))
+			incHardDeleteCounter(tree.TreeId, false, durationParseErrReason)
+			continue
+		}
 		durationSinceDelete := now.Sub(deleteTime)
-		if durationSinceDelete <= gc.deleteThreshold {
+		if durationSinceDelete <= retention {
 			continue
 		}
 
@@ -147,8 +159,10 @@ func (gc *DeletedTreeGC) RunOnce(ctx context.Context) (int, error) {
 		}
 
 		count++
+		pending--
 		incHardDeleteCounter(tree.TreeId, true, "")
 	}
+	pendingPurgeGauge.Set(float64(pending))
 
 	if len(errs) == 0 {
 		return count, nil
@@ -162,3 +176,12 @@ func (gc *DeletedTreeGC) RunOnce(ctx context.Context) (int, error) {
 	}
 	return count, errors.New(buf.String())
 }
+
+// retention returns the minimum time tree has to remain in the soft-deleted
+// state before it's eligible for garbage collection.
+func (gc *DeletedTreeGC) retention(tree *trillian.Tree) (time.Duration, error) {
+	if tree.DeleteRetention == nil {
+		return gc.deleteThreshold, nil
+	}
+	return ptypes.Duration(tree.DeleteRetention)
+}
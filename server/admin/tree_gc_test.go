@@ -24,6 +24,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/google/trillian"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/testonly"
@@ -184,6 +185,58 @@ func TestDeletedTreeGC_RunOnce(t *testing.T) {
 	}
 }
 
+func TestDeletedTreeGC_RunOnceRetention(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	deleteTime := time.Date(2017, 9, 21, 10, 0, 0, 0, time.UTC)
+	newDeletedTree := func(id int64, retention time.Duration) *trillian.Tree {
+		tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+		tree.TreeId = id
+		tree.Deleted = true
+		tree.DeleteTime, _ = ptypes.TimestampProto(deleteTime)
+		if retention != 0 {
+			tree.DeleteRetention = ptypes.DurationProto(retention)
+		}
+		return tree
+	}
+	// The default threshold is 1 day, and the sweep runs 2 days after deletion.
+	defaultTree := newDeletedTree(1, 0)
+	shortTree := newDeletedTree(2, time.Hour)
+	longTree := newDeletedTree(3, 7*24*time.Hour)
+	badTree := newDeletedTree(4, 0)
+	badTree.DeleteRetention = &duration.Duration{Seconds: -1, Nanos: 1}
+
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return deleteTime.Add(48 * time.Hour) }
+
+	listTX := storage.NewMockReadOnlyAdminTX(ctrl)
+	listTX.EXPECT().ListTrees(gomock.Any(), true /* includeDeleted */).Return([]*trillian.Tree{defaultTree, shortTree, longTree, badTree}, nil)
+	listTX.EXPECT().Close().Return(nil)
+	listTX.EXPECT().Commit().Return(nil)
+	as := &testonly.FakeAdminStorage{ReadOnlyTX: []storage.ReadOnlyAdminTX{listTX}}
+	for _, id := range []int64{defaultTree.TreeId, shortTree.TreeId} {
+		deleteTX := storage.NewMockAdminTX(ctrl)
+		deleteTX.EXPECT().HardDeleteTree(gomock.Any(), id).Return(nil)
+		deleteTX.EXPECT().Close().Return(nil)
+		deleteTX.EXPECT().Commit().Return(nil)
+		as.TX = append(as.TX, deleteTX)
+	}
+
+	gc := NewDeletedTreeGC(as, 24*time.Hour, 1*time.Second /* minRunInterval */, nil /* mf */)
+	count, err := gc.RunOnce(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "error parsing delete_retention") {
+		t.Errorf("RunOnce() returned err = %v, want delete_retention parse error", err)
+	}
+	if count != 2 {
+		t.Errorf("RunOnce() = %v, want 2", count)
+	}
+	// longTree and badTree remain soft-deleted.
+	if got, want := pendingPurgeGauge.Value(), 2.0; got != want {
+		t.Errorf("pendingPurgeGauge = %v, want %v", got, want)
+	}
+}
+
 // listTreesSpec specifies all parameters required to mock a ListTrees TX call.
 type listTreesSpec struct {
 	snapshotErr, listErr, commitErr error
@@ -0,0 +1,95 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto"
+	"errors"
+	"flag"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keys/pem"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/server/attestation"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	tcrypto "github.com/google/trillian/crypto"
+)
+
+// DefaultAttestationInterval is the suggested min interval between
+// attestations.
+const DefaultAttestationInterval = time.Hour
+
+var (
+	attestationKeyFile     = flag.String("attestation_private_key_pem_file", "", "PEM file containing the private key used to sign integrity attestations. If unset, no attestations are produced")
+	attestationKeyPassword = flag.String("attestation_private_key_password", "", "Password of the attestation private key, if encrypted")
+	attestationInterval    = flag.Duration("attestation_interval", DefaultAttestationInterval, "Minimum interval between integrity attestations. Actual runs happen randomly between [minInterval,2*minInterval).")
+	attestationDir         = flag.String("attestation_dir", "", "If set, attestations are written as JSON files to this directory")
+	attestationLogServer   = flag.String("attestation_log_server", "", "If set, attestations are submitted to a Trillian log served at this address")
+	attestationLogID       = flag.Int64("attestation_log_id", 0, "ID of the log to submit attestations to")
+	attestationLogTLSCert  = flag.String("attestation_log_tls_cert_file", "", "Path to the PEM-encoded TLS certificate of the attestation log server. If unset, unsecured connections are used")
+)
+
+// NewAttesterFromFlags returns an Attester covering the trees of registry,
+// configured by flags. It returns nil if attestations are not enabled.
+func NewAttesterFromFlags(registry extension.Registry) (*attestation.Attester, error) {
+	if *attestationKeyFile == "" {
+		return nil, nil
+	}
+	key, err := pem.ReadPrivateKeyFile(*attestationKeyFile, *attestationKeyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	var publisher attestation.Publisher
+	switch {
+	case *attestationLogServer != "":
+		if *attestationLogID == 0 {
+			return nil, errors.New("--attestation_log_id must be set with --attestation_log_server")
+		}
+		opts := []grpc.DialOption{grpc.WithInsecure()}
+		if *attestationLogTLSCert != "" {
+			creds, err := credentials.NewClientTLSFromFile(*attestationLogTLSCert, "")
+			if err != nil {
+				return nil, err
+			}
+			opts = []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+		}
+		conn, err := grpc.Dial(*attestationLogServer, opts...)
+		if err != nil {
+			return nil, err
+		}
+		publisher = &attestation.LogPublisher{Client: trillian.NewTrillianLogClient(conn), LogID: *attestationLogID}
+	case *attestationDir != "":
+		publisher = &attestation.FilePublisher{Dir: *attestationDir}
+	default:
+		return nil, errors.New("one of --attestation_dir or --attestation_log_server must be set with --attestation_private_key_pem_file")
+	}
+
+	return attestation.NewAttester(attestation.Config{
+		Admin:          registry.AdminStorage,
+		LogStorage:     registry.LogStorage,
+		MapStorage:     registry.MapStorage,
+		Scrubber:       registry.Scrubber,
+		Signer:         tcrypto.NewSigner(0, key, crypto.SHA256),
+		Publisher:      publisher,
+		MinRunInterval: *attestationInterval,
+		TimeSource:     clock.System,
+		MetricFactory:  registry.MetricFactory,
+	})
+}
@@ -0,0 +1,308 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package attestation produces signed documents describing the trees served by
+// a Trillian server and their latest roots, for publication to relying parties.
+package attestation
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util/clock"
+
+	tcrypto "github.com/google/trillian/crypto"
+)
+
+// Document is the content of an attestation.
+type Document struct {
+	// Timestamp is the time at which the document was produced.
+	Timestamp time.Time `json:"timestamp"`
+	// SoftwareVersion identifies the build of the server which produced the
+	// document.
+	SoftwareVersion string `json:"software_version"`
+	// GoVersion is the version of Go the server was built with.
+	GoVersion string `json:"go_version"`
+	// Trees holds the state of each tree covered by the attestation.
+	Trees []TreeState `json:"trees"`
+	// ScrubResults holds the latest integrity check results, if a
+	// ScrubReporter is configured.
+	ScrubResults []ScrubResult `json:"scrub_results,omitempty"`
+}
+
+// TreeState describes a tree and its latest root.
+type TreeState struct {
+	TreeID    int64  `json:"tree_id"`
+	TreeType  string `json:"tree_type"`
+	TreeState string `json:"tree_state"`
+	// Root and RootSignature are the serialized LogRoot or MapRoot of the
+	// latest revision of the tree, and the tree's signature over it. Both are
+	// empty if the tree has not been initialized.
+	Root          []byte `json:"root,omitempty"`
+	RootSignature []byte `json:"root_signature,omitempty"`
+}
+
+// ScrubResult is the outcome of an integrity check of a tree.
+type ScrubResult struct {
+	TreeID int64     `json:"tree_id"`
+	Time   time.Time `json:"time"`
+	// OK is true if no integrity problems were found.
+	OK bool `json:"ok"`
+	// Details describes the problems found, if any.
+	Details string `json:"details,omitempty"`
+}
+
+// SignedDocument is a serialized Document, together with a signature over it.
+type SignedDocument struct {
+	Document  []byte `json:"document"`
+	KeyHint   []byte `json:"key_hint,omitempty"`
+	Signature []byte `json:"signature"`
+}
+
+// ScrubReporter provides the results of the most recent integrity checks of the
+// trees served.
+type ScrubReporter interface {
+	ScrubResults(ctx context.Context) ([]ScrubResult, error)
+}
+
+// Publisher makes signed attestations available to relying parties.
+type Publisher interface {
+	Publish(ctx context.Context, doc *SignedDocument) error
+}
+
+// Config holds the dependencies and parameters of an Attester.
+type Config struct {
+	Admin storage.AdminStorage
+	// LogStorage and MapStorage are used to read the latest roots of log and
+	// map trees respectively. Trees of a type whose storage is nil are not
+	// covered.
+	LogStorage storage.LogStorage
+	MapStorage storage.MapStorage
+	// Scrubber is optional.
+	Scrubber ScrubReporter
+
+	Signer    *tcrypto.Signer
+	Publisher Publisher
+
+	// MinRunInterval defines how frequently attestations are produced. Actual
+	// runs happen randomly between [minInterval,2*minInterval).
+	MinRunInterval time.Duration
+	TimeSource     clock.TimeSource
+	MetricFactory  monitoring.MetricFactory
+}
+
+// Attester periodically produces and publishes signed attestations.
+type Attester struct {
+	cfg     Config
+	counter monitoring.Counter
+}
+
+// NewAttester returns a new Attester.
+func NewAttester(cfg Config) (*Attester, error) {
+	if cfg.Admin == nil {
+		return nil, fmt.Errorf("attestation: admin storage is required")
+	}
+	if cfg.Signer == nil {
+		return nil, fmt.Errorf("attestation: signer is required")
+	}
+	if cfg.Publisher == nil {
+		return nil, fmt.Errorf("attestation: publisher is required")
+	}
+	if cfg.MinRunInterval <= 0 {
+		return nil, fmt.Errorf("attestation: run interval must be positive, got %v", cfg.MinRunInterval)
+	}
+	if cfg.TimeSource == nil {
+		cfg.TimeSource = clock.System
+	}
+	if cfg.MetricFactory == nil {
+		cfg.MetricFactory = monitoring.InertMetricFactory{}
+	}
+	return &Attester{
+		cfg: cfg,
+		counter: cfg.MetricFactory.NewCounter(
+			"attestation_runs",
+			"Number of attestations produced and published",
+			"success"),
+	}, nil
+}
+
+// Run produces and publishes attestations until ctx is cancelled.
+func (a *Attester) Run(ctx context.Context) {
+	for {
+		if _, err := a.RunOnce(ctx); err != nil {
+			glog.Errorf("Attester.Run: %v", err)
+		}
+
+		d := a.cfg.MinRunInterval + time.Duration(rand.Int63n(a.cfg.MinRunInterval.Nanoseconds()))
+		if err := clock.SleepSource(ctx, d, a.cfg.TimeSource); err != nil {
+			return
+		}
+	}
+}
+
+// RunOnce produces, signs and publishes a single attestation, and returns it.
+func (a *Attester) RunOnce(ctx context.Context) (*SignedDocument, error) {
+	signed, err := a.runOnce(ctx)
+	a.counter.Inc(fmt.Sprint(err == nil))
+	return signed, err
+}
+
+func (a *Attester) runOnce(ctx context.Context) (*SignedDocument, error) {
+	doc, err := a.Build(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error building attestation: %v", err)
+	}
+	signed, err := Sign(a.cfg.Signer, doc)
+	if err != nil {
+		return nil, fmt.Errorf("error signing attestation: %v", err)
+	}
+	if err := a.cfg.Publisher.Publish(ctx, signed); err != nil {
+		return nil, fmt.Errorf("error publishing attestation: %v", err)
+	}
+	glog.V(1).Infof("Attester: published attestation covering %d trees", len(doc.Trees))
+	return signed, nil
+}
+
+// Build returns an unsigned attestation of the current state of the trees.
+func (a *Attester) Build(ctx context.Context) (*Document, error) {
+	trees, err := storage.ListTrees(ctx, a.cfg.Admin, false /* includeDeleted */)
+	if err != nil {
+		return nil, fmt.Errorf("error listing trees: %v", err)
+	}
+
+	doc := &Document{
+		Timestamp:       a.cfg.TimeSource.Now().UTC(),
+		SoftwareVersion: softwareVersion(),
+		GoVersion:       runtime.Version(),
+	}
+	for _, tree := range trees {
+		var state *TreeState
+		var err error
+		switch tree.TreeType {
+		case trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG:
+			if a.cfg.LogStorage == nil {
+				continue
+			}
+			state, err = a.logState(ctx, tree)
+		case trillian.TreeType_MAP:
+			if a.cfg.MapStorage == nil {
+				continue
+			}
+			state, err = a.mapState(ctx, tree)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading root of tree %d: %v", tree.TreeId, err)
+		}
+		doc.Trees = append(doc.Trees, *state)
+	}
+
+	if a.cfg.Scrubber != nil {
+		if doc.ScrubResults, err = a.cfg.Scrubber.ScrubResults(ctx); err != nil {
+			return nil, fmt.Errorf("error reading scrub results: %v", err)
+		}
+	}
+	return doc, nil
+}
+
+func newTreeState(tree *trillian.Tree) *TreeState {
+	return &TreeState{
+		TreeID:    tree.TreeId,
+		TreeType:  tree.TreeType.String(),
+		TreeState: tree.TreeState.String(),
+	}
+}
+
+func (a *Attester) logState(ctx context.Context, tree *trillian.Tree) (*TreeState, error) {
+	state := newTreeState(tree)
+	tx, err := a.cfg.LogStorage.SnapshotForTree(ctx, tree)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	root, err := tx.LatestSignedLogRoot(ctx)
+	switch {
+	case err == storage.ErrTreeNeedsInit:
+	case err != nil:
+		return nil, err
+	default:
+		state.Root, state.RootSignature = root.LogRoot, root.LogRootSignature
+	}
+	return state, tx.Commit(ctx)
+}
+
+func (a *Attester) mapState(ctx context.Context, tree *trillian.Tree) (*TreeState, error) {
+	state := newTreeState(tree)
+	tx, err := a.cfg.MapStorage.SnapshotForTree(ctx, tree)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	root, err := tx.LatestSignedMapRoot(ctx)
+	switch {
+	case err == storage.ErrTreeNeedsInit:
+	case err != nil:
+		return nil, err
+	default:
+		state.Root, state.RootSignature = root.MapRoot, root.Signature
+	}
+	return state, tx.Commit(ctx)
+}
+
+// softwareVersion returns the module version of the running binary, or
+// "unknown" if it was not built with module support.
+func softwareVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s@%s", info.Main.Path, info.Main.Version)
+}
+
+// Sign serializes doc and signs it with signer.
+func Sign(signer *tcrypto.Signer, doc *Document) (*SignedDocument, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signer.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedDocument{Document: data, KeyHint: signer.KeyHint, Signature: sig}, nil
+}
+
+// Verify checks the signature of signed against pub, and returns the attested
+// Document.
+func Verify(pub crypto.PublicKey, hash crypto.Hash, signed *SignedDocument) (*Document, error) {
+	if err := tcrypto.Verify(pub, hash, signed.Document, signed.Signature); err != nil {
+		return nil, err
+	}
+	var doc Document
+	if err := json.Unmarshal(signed.Document, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing attestation: %v", err)
+	}
+	return &doc, nil
+}
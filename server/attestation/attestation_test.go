@@ -0,0 +1,206 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keys/pem"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/util/clock"
+	"github.com/kylelemons/godebug/pretty"
+
+	tcrypto "github.com/google/trillian/crypto"
+	stestonly "github.com/google/trillian/storage/testonly"
+)
+
+type fakePublisher struct {
+	docs []*SignedDocument
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, doc *SignedDocument) error {
+	p.docs = append(p.docs, doc)
+	return nil
+}
+
+type fakeScrubber []ScrubResult
+
+func (s fakeScrubber) ScrubResults(ctx context.Context) ([]ScrubResult, error) {
+	return s, nil
+}
+
+func TestAttester_RunOnce(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	logTree := proto.Clone(stestonly.LogTree).(*trillian.Tree)
+	logTree.TreeId = 1
+	mapTree := proto.Clone(stestonly.MapTree).(*trillian.Tree)
+	mapTree.TreeId = 2
+	newMapTree := proto.Clone(stestonly.MapTree).(*trillian.Tree)
+	newMapTree.TreeId = 3
+
+	listTX := storage.NewMockReadOnlyAdminTX(ctrl)
+	listTX.EXPECT().ListTrees(gomock.Any(), false).Return([]*trillian.Tree{logTree, mapTree, newMapTree}, nil)
+	listTX.EXPECT().Close().Return(nil)
+	listTX.EXPECT().Commit().Return(nil)
+	as := &stestonly.FakeAdminStorage{ReadOnlyTX: []storage.ReadOnlyAdminTX{listTX}}
+
+	logTX := storage.NewMockReadOnlyLogTreeTX(ctrl)
+	logTX.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(&trillian.SignedLogRoot{LogRoot: []byte("logroot"), LogRootSignature: []byte("logsig")}, nil)
+	logTX.EXPECT().Commit(gomock.Any()).Return(nil)
+	logTX.EXPECT().Close().Return(nil)
+	ls := storage.NewMockLogStorage(ctrl)
+	ls.EXPECT().SnapshotForTree(gomock.Any(), logTree).Return(logTX, nil)
+
+	mapTX := storage.NewMockReadOnlyMapTreeTX(ctrl)
+	gomock.InOrder(
+		mapTX.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(&trillian.SignedMapRoot{MapRoot: []byte("maproot"), Signature: []byte("mapsig")}, nil),
+		mapTX.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(nil, storage.ErrTreeNeedsInit),
+	)
+	mapTX.EXPECT().Commit(gomock.Any()).Return(nil).Times(2)
+	mapTX.EXPECT().Close().Return(nil).Times(2)
+
+	key, err := pem.UnmarshalPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("UnmarshalPrivateKey(): %v", err)
+	}
+	scrub := fakeScrubber{{TreeID: 2, Time: now.Add(-time.Hour), OK: true}}
+	publisher := &fakePublisher{}
+	a, err := NewAttester(Config{
+		Admin:          as,
+		LogStorage:     ls,
+		MapStorage:     &stestonly.FakeMapStorage{ReadOnlyTX: mapTX},
+		Scrubber:       scrub,
+		Signer:         tcrypto.NewSigner(0, key, crypto.SHA256),
+		Publisher:      publisher,
+		MinRunInterval: time.Hour,
+		TimeSource:     clock.NewFake(now),
+	})
+	if err != nil {
+		t.Fatalf("NewAttester(): %v", err)
+	}
+
+	signed, err := a.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce(): %v", err)
+	}
+	if len(publisher.docs) != 1 || publisher.docs[0] != signed {
+		t.Errorf("RunOnce() published %v, want [%v]", publisher.docs, signed)
+	}
+
+	doc, err := Verify(key.Public(), crypto.SHA256, signed)
+	if err != nil {
+		t.Fatalf("Verify(): %v", err)
+	}
+	want := &Document{
+		Timestamp:       now,
+		SoftwareVersion: doc.SoftwareVersion,
+		GoVersion:       doc.GoVersion,
+		Trees: []TreeState{
+			{TreeID: 1, TreeType: "LOG", TreeState: "ACTIVE", Root: []byte("logroot"), RootSignature: []byte("logsig")},
+			{TreeID: 2, TreeType: "MAP", TreeState: "ACTIVE", Root: []byte("maproot"), RootSignature: []byte("mapsig")},
+			{TreeID: 3, TreeType: "MAP", TreeState: "ACTIVE"},
+		},
+		ScrubResults: scrub,
+	}
+	if diff := pretty.Compare(doc, want); diff != "" {
+		t.Errorf("Verify() diff (-got +want):\n%s", diff)
+	}
+	if doc.SoftwareVersion == "" || doc.GoVersion == "" {
+		t.Errorf("Verify() returned versions %q, %q, want non-empty", doc.SoftwareVersion, doc.GoVersion)
+	}
+
+	signed.Document = bytes.Replace(signed.Document, []byte("ACTIVE"), []byte("FROZEN"), 1)
+	if _, err := Verify(key.Public(), crypto.SHA256, signed); err == nil {
+		t.Error("Verify() of modified attestation succeeded, want error")
+	}
+}
+
+func TestNewAttester_Invalid(t *testing.T) {
+	key, err := pem.UnmarshalPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass)
+	if err != nil {
+		t.Fatalf("UnmarshalPrivateKey(): %v", err)
+	}
+	valid := Config{
+		Admin:          &stestonly.FakeAdminStorage{},
+		Signer:         tcrypto.NewSigner(0, key, crypto.SHA256),
+		Publisher:      &fakePublisher{},
+		MinRunInterval: time.Hour,
+	}
+	if _, err := NewAttester(valid); err != nil {
+		t.Fatalf("NewAttester(valid): %v", err)
+	}
+	for _, test := range []struct {
+		desc   string
+		modify func(*Config)
+	}{
+		{desc: "no admin", modify: func(c *Config) { c.Admin = nil }},
+		{desc: "no signer", modify: func(c *Config) { c.Signer = nil }},
+		{desc: "no publisher", modify: func(c *Config) { c.Publisher = nil }},
+		{desc: "no interval", modify: func(c *Config) { c.MinRunInterval = 0 }},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := valid
+			test.modify(&cfg)
+			if _, err := NewAttester(cfg); err == nil {
+				t.Error("NewAttester() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestFilePublisher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "attestation")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	data, err := json.Marshal(&Document{Timestamp: time.Date(2019, 10, 1, 12, 0, 0, 5, time.UTC)})
+	if err != nil {
+		t.Fatalf("Marshal(): %v", err)
+	}
+	signed := &SignedDocument{Document: data, Signature: []byte("sig")}
+	if err := (&FilePublisher{Dir: dir}).Publish(context.Background(), signed); err != nil {
+		t.Fatalf("Publish(): %v", err)
+	}
+
+	got, err := ioutil.ReadFile(filepath.Join(dir, "attestation-20191001T120000.000000005Z.json"))
+	if err != nil {
+		t.Fatalf("ReadFile(): %v", err)
+	}
+	var gotSigned SignedDocument
+	if err := json.Unmarshal(got, &gotSigned); err != nil {
+		t.Fatalf("Unmarshal(): %v", err)
+	}
+	if diff := pretty.Compare(&gotSigned, signed); diff != "" {
+		t.Errorf("Published attestation diff (-got +want):\n%s", diff)
+	}
+}
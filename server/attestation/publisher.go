@@ -0,0 +1,84 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attestation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/trillian"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fileTimeFormat is used to name the files written by FilePublisher, so that
+// they sort in chronological order.
+const fileTimeFormat = "20060102T150405.000000000Z"
+
+// FilePublisher writes each attestation as a JSON file in a directory.
+type FilePublisher struct {
+	Dir string
+}
+
+// Publish implements Publisher.
+func (p *FilePublisher) Publish(ctx context.Context, doc *SignedDocument) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var d Document
+	if err := json.Unmarshal(doc.Document, &d); err != nil {
+		return err
+	}
+	name := filepath.Join(p.Dir, fmt.Sprintf("attestation-%s.json", d.Timestamp.UTC().Format(fileTimeFormat)))
+	// Write to a temporary file first, so that readers never see partial
+	// attestations.
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// LogPublisher submits each attestation as a leaf of a Trillian log, which
+// provides relying parties with a verifiable history of attestations.
+type LogPublisher struct {
+	Client trillian.TrillianLogClient
+	LogID  int64
+}
+
+// Publish implements Publisher.
+func (p *LogPublisher) Publish(ctx context.Context, doc *SignedDocument) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	rsp, err := p.Client.QueueLeaf(ctx, &trillian.QueueLeafRequest{
+		LogId: p.LogID,
+		Leaf:  &trillian.LogLeaf{LeafValue: data},
+	})
+	if err != nil {
+		return err
+	}
+	// A duplicate means this exact attestation was already submitted.
+	if s := rsp.GetQueuedLeaf().GetStatus(); s != nil && codes.Code(s.Code) != codes.OK && codes.Code(s.Code) != codes.AlreadyExists {
+		return status.ErrorProto(s)
+	}
+	return nil
+}
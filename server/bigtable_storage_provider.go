@@ -0,0 +1,113 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"flag"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/bigtable"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	bt "cloud.google.com/go/bigtable"
+)
+
+var (
+	btProject     = flag.String("bigtable_project", "", "Cloud project of the Bigtable instance")
+	btInstance    = flag.String("bigtable_instance", "", "Bigtable instance holding the table")
+	btTable       = flag.String("bigtable_table", "trillian", "Bigtable table holding the trees")
+	btCreateTable = flag.Bool("bigtable_create_table", false, "Create the Bigtable table if it doesn't exist")
+	btCommitLease = flag.Duration("bigtable_commit_lease", bigtable.DefaultCommitLease, "How long a writer may hold a tree before its commit is abandoned")
+
+	btOnce            sync.Once
+	btOnceErr         error
+	btStorageInstance *bigtableProvider
+)
+
+func init() {
+	if err := storage.RegisterProvider("bigtable", newBigtableStorageProvider); err != nil {
+		glog.Fatalf("Failed to register storage provider bigtable: %v", err)
+	}
+}
+
+type bigtableProvider struct {
+	client *bt.Client
+	tbl    *bt.Table
+	opts   bigtable.TreeStorageOptions
+}
+
+func newBigtableStorageProvider(_ monitoring.MetricFactory) (StorageProvider, error) {
+	btOnce.Do(func() {
+		opts := bigtable.TreeStorageOptions{CommitLease: *btCommitLease}
+		opts.SubtreeCodec, btOnceErr = subtreeCodecFromFlags(bigtable.SubtreeCodecs)
+		if btOnceErr != nil {
+			return
+		}
+		ctx := context.Background()
+		if *btCreateTable {
+			if btOnceErr = createBigtableTable(ctx); btOnceErr != nil {
+				return
+			}
+		}
+		var client *bt.Client
+		client, btOnceErr = bt.NewClient(ctx, *btProject, *btInstance)
+		if btOnceErr != nil {
+			return
+		}
+		btStorageInstance = &bigtableProvider{
+			client: client,
+			tbl:    client.Open(*btTable),
+			opts:   opts,
+		}
+	})
+	if btOnceErr != nil {
+		return nil, btOnceErr
+	}
+	return btStorageInstance, nil
+}
+
+// createBigtableTable creates the table named by the flags, unless it exists.
+func createBigtableTable(ctx context.Context) error {
+	admin, err := bt.NewAdminClient(ctx, *btProject, *btInstance)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+	if err := bigtable.CreateTable(ctx, admin, *btTable); status.Code(err) != codes.AlreadyExists {
+		return err
+	}
+	return nil
+}
+
+func (s *bigtableProvider) LogStorage() storage.LogStorage {
+	return bigtable.NewLogStorage(s.tbl, s.opts)
+}
+
+func (s *bigtableProvider) MapStorage() storage.MapStorage {
+	return bigtable.NewMapStorage(s.tbl, s.opts)
+}
+
+func (s *bigtableProvider) AdminStorage() storage.AdminStorage {
+	return bigtable.NewAdminStorage(s.tbl)
+}
+
+func (s *bigtableProvider) Close() error {
+	return s.client.Close()
+}
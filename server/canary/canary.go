@@ -0,0 +1,212 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package canary exercises designated canary trees through the RPC API of a
+// Trillian server, with write, read, prove and verify round trips, so that
+// misconfigured deployments are detected before they receive real traffic.
+package canary
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/client"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+)
+
+// errNotRun is returned by Ready until the first run completes.
+var errNotRun = errors.New("canary has not completed a run yet")
+
+// Config holds the dependencies and parameters of a Canary.
+type Config struct {
+	Admin trillian.TrillianAdminClient
+	// Log is required if any canary tree is a log, Map and MapWrite if any is
+	// a map.
+	Log      trillian.TrillianLogClient
+	Map      trillian.TrillianMapClient
+	MapWrite trillian.TrillianMapWriteClient
+
+	// TreeIDs are the IDs of the canary trees. They must be existing, active
+	// LOG or MAP trees which are not used for anything else, as the canary
+	// adds a leaf to each of them on every run.
+	TreeIDs []int64
+
+	// Timeout bounds the duration of each run. For logs, it must leave time
+	// for a signer to integrate the canary leaf.
+	Timeout time.Duration
+	// MinRunInterval defines how frequently the canary runs. Actual runs
+	// happen randomly between [minInterval,2*minInterval).
+	MinRunInterval time.Duration
+	TimeSource     clock.TimeSource
+	MetricFactory  monitoring.MetricFactory
+}
+
+// Canary periodically exercises the canary trees, and records whether the
+// latest run succeeded.
+type Canary struct {
+	cfg     Config
+	counter monitoring.Counter
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// New returns a new Canary.
+func New(cfg Config) (*Canary, error) {
+	if cfg.Admin == nil {
+		return nil, errors.New("canary: admin client is required")
+	}
+	if len(cfg.TreeIDs) == 0 {
+		return nil, errors.New("canary: no canary trees")
+	}
+	if cfg.Timeout <= 0 {
+		return nil, fmt.Errorf("canary: timeout must be positive, got %v", cfg.Timeout)
+	}
+	if cfg.MinRunInterval <= 0 {
+		return nil, fmt.Errorf("canary: run interval must be positive, got %v", cfg.MinRunInterval)
+	}
+	if cfg.TimeSource == nil {
+		cfg.TimeSource = clock.System
+	}
+	if cfg.MetricFactory == nil {
+		cfg.MetricFactory = monitoring.InertMetricFactory{}
+	}
+	return &Canary{
+		cfg: cfg,
+		counter: cfg.MetricFactory.NewCounter(
+			"canary_runs",
+			"Number of canary tree round trips",
+			"tree_id", "success"),
+		lastErr: errNotRun,
+	}, nil
+}
+
+// Ready returns nil if the latest run of the canary succeeded, and an error
+// describing the failure otherwise, including if no run has completed yet.
+func (c *Canary) Ready() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastErr
+}
+
+// Run exercises the canary trees immediately, then periodically until ctx is
+// cancelled.
+func (c *Canary) Run(ctx context.Context) {
+	for {
+		if err := c.RunOnce(ctx); err != nil {
+			glog.Errorf("Canary.Run: %v", err)
+		}
+
+		d := c.cfg.MinRunInterval + time.Duration(rand.Int63n(c.cfg.MinRunInterval.Nanoseconds()))
+		if err := clock.SleepSource(ctx, d, c.cfg.TimeSource); err != nil {
+			return
+		}
+	}
+}
+
+// RunOnce exercises each canary tree once, and returns the first failure.
+func (c *Canary) RunOnce(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	var firstErr error
+	for _, id := range c.cfg.TreeIDs {
+		err := c.exercise(ctx, id)
+		c.counter.Inc(strconv.FormatInt(id, 10), fmt.Sprint(err == nil))
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("canary tree %d: %v", id, err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastErr = firstErr
+	return firstErr
+}
+
+func (c *Canary) exercise(ctx context.Context, treeID int64) error {
+	tree, err := c.cfg.Admin.GetTree(ctx, &trillian.GetTreeRequest{TreeId: treeID})
+	if err != nil {
+		return fmt.Errorf("GetTree(): %v", err)
+	}
+	// The value is unique to each run, so that each write is a new leaf.
+	value := []byte(fmt.Sprintf("trillian canary %d", c.cfg.TimeSource.Now().UnixNano()))
+	switch tree.TreeType {
+	case trillian.TreeType_LOG:
+		if c.cfg.Log == nil {
+			return errors.New("no log client")
+		}
+		return c.exerciseLog(ctx, tree, value)
+	case trillian.TreeType_MAP:
+		if c.cfg.Map == nil || c.cfg.MapWrite == nil {
+			return errors.New("no map client")
+		}
+		return c.exerciseMap(ctx, tree, value)
+	default:
+		return fmt.Errorf("unsupported tree type %v", tree.TreeType)
+	}
+}
+
+// exerciseLog adds value to the log, and waits until it is included in a
+// verified root with a verified inclusion proof.
+func (c *Canary) exerciseLog(ctx context.Context, tree *trillian.Tree, value []byte) error {
+	lc, err := client.NewFromTree(c.cfg.Log, tree, types.LogRootV1{})
+	if err != nil {
+		return err
+	}
+	return lc.AddLeaf(ctx, value)
+}
+
+// exerciseMap writes value to the canary index of the map, and reads it back
+// with a verified inclusion proof at the written revision.
+func (c *Canary) exerciseMap(ctx context.Context, tree *trillian.Tree, value []byte) error {
+	mc, err := client.NewMapClientFromTree(c.cfg.Map, tree)
+	if err != nil {
+		return err
+	}
+	index := canaryIndex(hashers.IndexSize(mc.Hasher))
+	rsp, err := c.cfg.MapWrite.WriteLeaves(ctx, &trillian.WriteMapLeavesRequest{
+		MapId:  tree.TreeId,
+		Leaves: []*trillian.MapLeaf{{Index: index, LeafValue: value}},
+	})
+	if err != nil {
+		return fmt.Errorf("WriteLeaves(): %v", err)
+	}
+	leaves, err := mc.GetAndVerifyMapLeavesByRevision(ctx, rsp.Revision, [][]byte{index})
+	if err != nil {
+		return err
+	}
+	if got := leaves[0].LeafValue; !bytes.Equal(got, value) {
+		return fmt.Errorf("read back value %q at revision %d, want %q", got, rsp.Revision, value)
+	}
+	return nil
+}
+
+// canaryIndex returns the map index written by the canary.
+func canaryIndex(size int) []byte {
+	index := make([]byte, size)
+	copy(index, "trillian canary")
+	return index
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canary
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	stestonly "github.com/google/trillian/storage/testonly"
+)
+
+// fakeAdmin serves GetTree from a fixed set of trees.
+type fakeAdmin struct {
+	trillian.TrillianAdminClient
+	trees map[int64]*trillian.Tree
+}
+
+func (a *fakeAdmin) GetTree(ctx context.Context, req *trillian.GetTreeRequest, opts ...grpc.CallOption) (*trillian.Tree, error) {
+	tree, ok := a.trees[req.TreeId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "tree %d not found", req.TreeId)
+	}
+	return tree, nil
+}
+
+// fakeMapWrite fails every write.
+type fakeMapWrite struct {
+	trillian.TrillianMapWriteClient
+}
+
+func (fakeMapWrite) WriteLeaves(ctx context.Context, req *trillian.WriteMapLeavesRequest, opts ...grpc.CallOption) (*trillian.WriteMapLeavesResponse, error) {
+	return nil, status.Error(codes.PermissionDenied, "no writes")
+}
+
+func TestNew_Invalid(t *testing.T) {
+	valid := Config{
+		Admin:          &fakeAdmin{},
+		TreeIDs:        []int64{1},
+		Timeout:        time.Second,
+		MinRunInterval: time.Minute,
+	}
+	if _, err := New(valid); err != nil {
+		t.Fatalf("New(valid): %v", err)
+	}
+	for _, test := range []struct {
+		desc   string
+		modify func(*Config)
+	}{
+		{desc: "no admin", modify: func(c *Config) { c.Admin = nil }},
+		{desc: "no trees", modify: func(c *Config) { c.TreeIDs = nil }},
+		{desc: "no timeout", modify: func(c *Config) { c.Timeout = 0 }},
+		{desc: "no interval", modify: func(c *Config) { c.MinRunInterval = 0 }},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := valid
+			test.modify(&cfg)
+			if _, err := New(cfg); err == nil {
+				t.Error("New() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestRunOnce_Failures(t *testing.T) {
+	mapTree := proto.Clone(stestonly.MapTree).(*trillian.Tree)
+	mapTree.TreeId = 1
+	logTree := proto.Clone(stestonly.LogTree).(*trillian.Tree)
+	logTree.TreeId = 2
+	preorderedTree := proto.Clone(stestonly.PreorderedLogTree).(*trillian.Tree)
+	preorderedTree.TreeId = 3
+	admin := &fakeAdmin{trees: map[int64]*trillian.Tree{1: mapTree, 2: logTree, 3: preorderedTree}}
+
+	for _, test := range []struct {
+		desc string
+		cfg  Config
+	}{
+		{desc: "missing tree", cfg: Config{TreeIDs: []int64{4}}},
+		{desc: "no log client", cfg: Config{TreeIDs: []int64{2}}},
+		{desc: "no map client", cfg: Config{TreeIDs: []int64{1}}},
+		{desc: "unsupported type", cfg: Config{TreeIDs: []int64{3}}},
+		{
+			desc: "write fails",
+			cfg:  Config{TreeIDs: []int64{1}, Map: trillian.NewTrillianMapClient(nil), MapWrite: fakeMapWrite{}},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			cfg := test.cfg
+			cfg.Admin = admin
+			cfg.Timeout = time.Second
+			cfg.MinRunInterval = time.Minute
+			c, err := New(cfg)
+			if err != nil {
+				t.Fatalf("New(): %v", err)
+			}
+			if err := c.Ready(); err != errNotRun {
+				t.Errorf("Ready() before run: %v, want %v", err, errNotRun)
+			}
+			runErr := c.RunOnce(context.Background())
+			if runErr == nil {
+				t.Fatal("RunOnce() succeeded, want error")
+			}
+			if err := c.Ready(); err != runErr {
+				t.Errorf("Ready()=%v, want %v", err, runErr)
+			}
+		})
+	}
+}
+
+func TestReady_RecoversAfterSuccess(t *testing.T) {
+	c, err := New(Config{Admin: &fakeAdmin{}, TreeIDs: []int64{1}, Timeout: time.Second, MinRunInterval: time.Minute})
+	if err != nil {
+		t.Fatalf("New(): %v", err)
+	}
+	c.lastErr = errors.New("failed")
+	// A run with no failures clears the error.
+	c.cfg.TreeIDs = nil
+	if err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce(): %v", err)
+	}
+	if err := c.Ready(); err != nil {
+		t.Errorf("Ready()=%v, want nil", err)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canary
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// DefaultInterval is the suggested min interval between canary runs.
+	DefaultInterval = time.Minute
+	// DefaultTimeout is the suggested timeout of each canary run.
+	DefaultTimeout = 30 * time.Second
+)
+
+var (
+	canaryTreeIDs  = flag.String("canary_tree_ids", "", "Comma-separated IDs of trees exercised as canaries on startup and periodically. The server only reports ready on /readyz while the canaries succeed. Canary trees must not be used for anything else")
+	canaryInterval = flag.Duration("canary_interval", DefaultInterval, "Minimum interval between canary runs. Actual runs happen randomly between [minInterval,2*minInterval).")
+	canaryTimeout  = flag.Duration("canary_timeout", DefaultTimeout, "Timeout of each canary run. For logs, it must leave time for a signer to integrate the canary leaf")
+)
+
+// NewFromFlags returns a Canary which exercises the canary trees
+// configured by flags through the RPC server at endpoint. tlsCertFile is the
+// certificate of that server, or empty if it uses unsecured connections. It
+// returns nil if no canary trees are configured.
+func NewFromFlags(endpoint, tlsCertFile string, mf monitoring.MetricFactory) (*Canary, error) {
+	if *canaryTreeIDs == "" {
+		return nil, nil
+	}
+	var ids []int64
+	for _, s := range strings.Split(*canaryTreeIDs, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --canary_tree_ids: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Calls wait for the connection, so that canary runs started before the
+	// server is serving do not fail.
+	opts := []grpc.DialOption{grpc.WithInsecure(), grpc.WithDefaultCallOptions(grpc.WaitForReady(true))}
+	if tlsCertFile != "" {
+		creds, err := credentials.NewClientTLSFromFile(tlsCertFile, "")
+		if err != nil {
+			return nil, err
+		}
+		opts[0] = grpc.WithTransportCredentials(creds)
+	}
+	conn, err := grpc.Dial(endpoint, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(Config{
+		Admin:          trillian.NewTrillianAdminClient(conn),
+		Log:            trillian.NewTrillianLogClient(conn),
+		Map:            trillian.NewTrillianMapClient(conn),
+		MapWrite:       trillian.NewTrillianMapWriteClient(conn),
+		TreeIDs:        ids,
+		Timeout:        *canaryTimeout,
+		MinRunInterval: *canaryInterval,
+		TimeSource:     clock.System,
+		MetricFactory:  mf,
+	})
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/trees"
+	"golang.org/x/crypto/ed25519"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var checkpointOriginPrefix = flag.String("checkpoint_origin_prefix", "", "If set, GetCheckpoint returns the roots of each tree as signed checkpoints, whose origin is this prefix followed by \"/\" and the tree ID, e.g. \"example.com/trillian\"")
+
+// Signature algorithm identifiers of the keys signing notes, as in the
+// signed note format.
+const (
+	noteAlgEd25519 = 1
+	noteAlgECDSA   = 2
+)
+
+// checkpointOrigin returns the origin line of the checkpoints of the tree, or
+// an Unimplemented error if checkpoints are disabled.
+func checkpointOrigin(treeID int64) (string, error) {
+	prefix := strings.TrimRight(*checkpointOriginPrefix, "/")
+	if prefix == "" {
+		return "", status.Error(codes.Unimplemented, "checkpoints are disabled, see --checkpoint_origin_prefix")
+	}
+	if strings.IndexFunc(prefix, func(r rune) bool { return unicode.IsSpace(r) || r == '+' }) >= 0 {
+		return "", status.Errorf(codes.FailedPrecondition, "invalid checkpoint origin prefix %q: contains spaces or '+'", prefix)
+	}
+	return fmt.Sprintf("%s/%d", prefix, treeID), nil
+}
+
+// signedCheckpoint returns the checkpoint of tree at the given size and root
+// hash, as a note signed by the tree's key under the name of its origin:
+//
+//	<origin>
+//	<size>
+//	<base64 root hash>
+//
+//	— <origin> <base64 key ID and signature>
+//
+// For maps, the size is the revision.
+func signedCheckpoint(ctx context.Context, tree *trillian.Tree, size uint64, rootHash []byte) ([]byte, error) {
+	origin, err := checkpointOrigin(tree.TreeId)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := trees.Signer(ctx, tree)
+	if err != nil {
+		return nil, fmt.Errorf("trees.Signer(): %v", err)
+	}
+	keyID, err := noteKeyID(origin, signer.Public())
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "tree %d: %v", tree.TreeId, err)
+	}
+
+	body := fmt.Sprintf("%s\n%d\n%s\n", origin, size, base64.StdEncoding.EncodeToString(rootHash))
+	sig, err := signer.Sign([]byte(body))
+	if err != nil {
+		return nil, fmt.Errorf("Sign(): %v", err)
+	}
+	var note bytes.Buffer
+	note.WriteString(body)
+	note.WriteString("\n")
+	fmt.Fprintf(&note, "— %s %s\n", origin, base64.StdEncoding.EncodeToString(append(keyID, sig...)))
+	return note.Bytes(), nil
+}
+
+// noteKeyID returns the ID of the key named name in note signatures: the first
+// 4 bytes of the SHA-256 hash of the name, a newline, the algorithm identifier
+// and the key. Ed25519 keys are encoded as raw public keys, and ECDSA ones in
+// PKIX, ASN.1 DER form.
+func noteKeyID(name string, pub gocrypto.PublicKey) ([]byte, error) {
+	var alg byte
+	var key []byte
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		alg, key = noteAlgEd25519, pub
+	case *ed25519.PublicKey:
+		alg, key = noteAlgEd25519, *pub
+	case *ecdsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		alg, key = noteAlgECDSA, der
+	default:
+		return nil, fmt.Errorf("checkpoints can't be signed by keys of type %T", pub)
+	}
+	h := sha256.New()
+	h.Write([]byte(name + "\n"))
+	h.Write([]byte{alg})
+	h.Write(key)
+	return h.Sum(nil)[:4], nil
+}
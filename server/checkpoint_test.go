@@ -0,0 +1,124 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian/crypto/keys/der"
+	"golang.org/x/crypto/ed25519"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	tcrypto "github.com/google/trillian/crypto"
+	stestonly "github.com/google/trillian/storage/testonly"
+)
+
+// setCheckpointOriginPrefix sets the --checkpoint_origin_prefix flag, and
+// returns a function which restores it.
+func setCheckpointOriginPrefix(prefix string) func() {
+	old := *checkpointOriginPrefix
+	*checkpointOriginPrefix = prefix
+	return func() { *checkpointOriginPrefix = old }
+}
+
+// verifyCheckpoint checks that cp is a note signed under the name origin by the
+// key whose public key is pubDER, and returns its body.
+func verifyCheckpoint(t *testing.T, cp []byte, origin string, pubDER []byte) string {
+	t.Helper()
+	parts := strings.SplitN(string(cp), "\n\n", 2)
+	if len(parts) != 2 {
+		t.Fatalf("checkpoint %q has no signatures", cp)
+	}
+	body := parts[0] + "\n"
+	fields := strings.Fields(strings.TrimPrefix(parts[1], "— "))
+	if len(fields) != 2 || fields[0] != origin {
+		t.Fatalf("checkpoint signature line %q, want one by %q", parts[1], origin)
+	}
+	sig, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil || len(sig) < 4 {
+		t.Fatalf("checkpoint signature %q: %v", fields[1], err)
+	}
+	pub, err := der.UnmarshalPublicKey(pubDER)
+	if err != nil {
+		t.Fatalf("UnmarshalPublicKey(): %v", err)
+	}
+	keyID, err := noteKeyID(origin, pub)
+	if err != nil {
+		t.Fatalf("noteKeyID(): %v", err)
+	}
+	if !bytes.Equal(sig[:4], keyID) {
+		t.Errorf("checkpoint key ID %x, want %x", sig[:4], keyID)
+	}
+	if err := tcrypto.Verify(pub, crypto.SHA256, []byte(body), sig[4:]); err != nil {
+		t.Errorf("checkpoint signature does not verify: %v", err)
+	}
+	return body
+}
+
+func TestNoteKeyID(t *testing.T) {
+	// The verifier key "PeterNeumann+c74f20a3+ARpc2QcUPDhMQegwxbzhKqiBfsVkmqq/LDE4izWy10TW"
+	// from the signed note format documentation.
+	key, err := base64.StdEncoding.DecodeString("ARpc2QcUPDhMQegwxbzhKqiBfsVkmqq/LDE4izWy10TW")
+	if err != nil {
+		t.Fatalf("DecodeString(): %v", err)
+	}
+	if key[0] != noteAlgEd25519 {
+		t.Fatalf("algorithm %d, want Ed25519", key[0])
+	}
+	id, err := noteKeyID("PeterNeumann", ed25519.PublicKey(key[1:]))
+	if err != nil {
+		t.Fatalf("noteKeyID(): %v", err)
+	}
+	if got, want := hex.EncodeToString(id), "c74f20a3"; got != want {
+		t.Errorf("noteKeyID()=%s, want %s", got, want)
+	}
+}
+
+func TestSignedCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	tree := *stestonly.LogTree
+	tree.TreeId = 12345
+
+	t.Run("disabled", func(t *testing.T) {
+		defer setCheckpointOriginPrefix("")()
+		if _, err := signedCheckpoint(ctx, &tree, 7, []byte("root")); status.Code(err) != codes.Unimplemented {
+			t.Errorf("signedCheckpoint(): %v, want code %v", err, codes.Unimplemented)
+		}
+	})
+	t.Run("invalidOrigin", func(t *testing.T) {
+		defer setCheckpointOriginPrefix("example.com/my logs")()
+		if _, err := signedCheckpoint(ctx, &tree, 7, []byte("root")); status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("signedCheckpoint(): %v, want code %v", err, codes.FailedPrecondition)
+		}
+	})
+	t.Run("enabled", func(t *testing.T) {
+		defer setCheckpointOriginPrefix("example.com/trillian/")()
+		cp, err := signedCheckpoint(ctx, &tree, 7, []byte("root"))
+		if err != nil {
+			t.Fatalf("signedCheckpoint(): %v", err)
+		}
+		body := verifyCheckpoint(t, cp, "example.com/trillian/12345", tree.PublicKey.Der)
+		if want := "example.com/trillian/12345\n7\ncm9vdA==\n"; body != want {
+			t.Errorf("checkpoint body %q, want %q", body, want)
+		}
+	})
+}
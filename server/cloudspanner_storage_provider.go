@@ -29,6 +29,7 @@ import (
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cloudspanner"
+	"github.com/google/trillian/storage/subtreecodec"
 )
 
 var (
@@ -50,7 +51,7 @@ var (
 )
 
 func init() {
-	if err := RegisterStorageProvider("cloud_spanner", newCloudSpannerStorageProvider); err != nil {
+	if err := storage.RegisterProvider("cloud_spanner", newCloudSpannerStorageProvider); err != nil {
 		panic(err)
 	}
 }
@@ -73,6 +74,7 @@ func warn() {
 
 type cloudSpannerProvider struct {
 	client *spanner.Client
+	codec  subtreecodec.Codec
 }
 
 func configFromFlags() spanner.ClientConfig {
@@ -88,7 +90,7 @@ func configFromFlags() spanner.ClientConfig {
 	return r
 }
 
-func newCloudSpannerStorageProvider(_ monitoring.MetricFactory) (StorageProvider, error) {
+func newCloudSpannerStorageProvider(mf monitoring.MetricFactory) (StorageProvider, error) {
 	csMu.Lock()
 	defer csMu.Unlock()
 
@@ -96,12 +98,21 @@ func newCloudSpannerStorageProvider(_ monitoring.MetricFactory) (StorageProvider
 		return csStorageInstance, nil
 	}
 
+	codec, err := subtreeCodecFromFlags(cloudspanner.SubtreeCodecs)
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := spanner.NewClientWithConfig(context.TODO(), *csURI, configFromFlags())
 	if err != nil {
 		return nil, err
 	}
+	if mf != nil {
+		cloudspanner.InitMapMetrics(mf)
+	}
 	csStorageInstance = &cloudSpannerProvider{
 		client: client,
+		codec:  codec,
 	}
 	return csStorageInstance, nil
 }
@@ -121,6 +132,8 @@ func (s *cloudSpannerProvider) LogStorage() storage.LogStorage {
 	if *csReadOnlyStaleness > 0 {
 		opts.ReadOnlyStaleness = *csReadOnlyStaleness
 	}
+	opts.SubtreeCodec = s.codec
+	opts.QueryTags = *queryTags
 	return cloudspanner.NewLogStorageWithOpts(s.client, opts)
 }
 
@@ -131,6 +144,8 @@ func (s *cloudSpannerProvider) MapStorage() storage.MapStorage {
 	if *csReadOnlyStaleness > 0 {
 		opts.ReadOnlyStaleness = *csReadOnlyStaleness
 	}
+	opts.SubtreeCodec = s.codec
+	opts.QueryTags = *queryTags
 	return cloudspanner.NewMapStorageWithOpts(s.client, opts)
 }
 
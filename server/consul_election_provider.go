@@ -0,0 +1,54 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"flag"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/util/election2"
+	"github.com/google/trillian/util/election2/consul"
+)
+
+// ElectionConsul represents the Consul election implementation.
+const ElectionConsul = "consul"
+
+var (
+	consulAddress     = flag.String("consul_address", "localhost:8500", "Address of the Consul agent, for --election_system=consul")
+	consulToken       = flag.String("consul_token", "", "ACL token for the Consul agent, for --election_system=consul")
+	consulLockPrefix  = flag.String("consul_lock_prefix", "trillian/log-signer", "Key prefix of the Consul locks, followed by the log ID, for --election_system=consul")
+	consulSessionTTL  = flag.Duration("consul_session_ttl", 15*time.Second, "TTL of the Consul sessions holding the locks, between 10s and 24h, for --election_system=consul")
+	consulRetryPeriod = flag.Duration("consul_retry_period", 2*time.Second, "Interval between attempts to acquire a lock, and between checks of a held lock, for --election_system=consul")
+)
+
+func init() {
+	if err := RegisterElectionFactory(ElectionConsul, newConsulElectionFactory); err != nil {
+		glog.Fatalf("Failed to register election provider %v: %v", ElectionConsul, err)
+	}
+}
+
+func newConsulElectionFactory(instanceID string) (election2.Factory, error) {
+	client := consul.NewClient(*consulAddress, *consulToken, nil)
+	f, err := consul.NewFactory(instanceID, client, *consulLockPrefix, consul.Options{
+		SessionTTL:  *consulSessionTTL,
+		RetryPeriod: *consulRetryPeriod,
+	})
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("Using Consul election at %v", *consulAddress)
+	return f, nil
+}
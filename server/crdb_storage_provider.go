@@ -0,0 +1,109 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"database/sql"
+	"flag"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/postgres"
+
+	// Load PG driver, which CockroachDB is compatible with.
+	_ "github.com/lib/pq"
+)
+
+var (
+	crdbConnStr           = flag.String("crdb_conn_str", "postgresql://root@localhost:26257/test?sslmode=disable", "Connection string for CockroachDB database")
+	crdbSnapshotStaleness = flag.Duration("crdb_snapshot_staleness", 0, "If positive, how long ago read-only transactions read CockroachDB, with AS OF SYSTEM TIME, so that they don't contend with writes. Reads miss the writes of this last period")
+
+	crdbMaxConns           = flag.Int("crdb_max_conns", 0, "Maximum connections to the database")
+	crdbMaxIdle            = flag.Int("crdb_max_idle_conns", -1, "Maximum idle database connections in the connection pool")
+	crdbConnMaxLifetime    = flag.Duration("crdb_conn_max_lifetime", 0, "If positive, how long connections to the database are reused for")
+	crdbStatementCacheSize = flag.Int("crdb_statement_cache_size", 0, "If positive, the maximum number of prepared statements cached. Unlimited otherwise")
+
+	crdbOnce            sync.Once
+	crdbOnceErr         error
+	crdbStorageInstance *crdbProvider
+)
+
+func init() {
+	if err := storage.RegisterProvider("crdb", newCockroachDBProvider); err != nil {
+		glog.Fatalf("Failed to register storage provider crdb: %v", err)
+	}
+}
+
+// crdbProvider provides the PostgreSQL storage, in the CockroachDB dialect.
+type crdbProvider struct {
+	db          *sql.DB
+	mf          monitoring.MetricFactory
+	opts        postgres.TreeStorageOptions
+	stopMetrics func()
+}
+
+func newCockroachDBProvider(mf monitoring.MetricFactory) (StorageProvider, error) {
+	crdbOnce.Do(func() {
+		opts := postgres.TreeStorageOptions{
+			Dialect:            postgres.CockroachDB,
+			SnapshotStaleness:  *crdbSnapshotStaleness,
+			StatementCacheSize: *crdbStatementCacheSize,
+		}
+		opts.SubtreeCodec, crdbOnceErr = subtreeCodecFromFlags(postgres.SubtreeCodecs)
+		if crdbOnceErr != nil {
+			return
+		}
+		var db *sql.DB
+		db, crdbOnceErr = postgres.OpenDB(*crdbConnStr)
+		if crdbOnceErr != nil {
+			return
+		}
+		if crdbOnceErr = checkSchema("crdb", db, postgres.Schema); crdbOnceErr != nil {
+			db.Close()
+			return
+		}
+		poolOptions(*crdbMaxConns, *crdbMaxIdle, *crdbConnMaxLifetime).Apply(db)
+
+		crdbStorageInstance = &crdbProvider{
+			db:          db,
+			mf:          mf,
+			opts:        opts,
+			stopMetrics: exportPoolMetrics(mf, "crdb", db),
+		}
+	})
+	if crdbOnceErr != nil {
+		return nil, crdbOnceErr
+	}
+	return crdbStorageInstance, nil
+}
+
+func (s *crdbProvider) LogStorage() storage.LogStorage {
+	return postgres.NewLogStorageWithOpts(s.db, s.mf, s.opts)
+}
+
+func (s *crdbProvider) MapStorage() storage.MapStorage {
+	return postgres.NewMapStorageWithOpts(s.db, s.opts)
+}
+
+func (s *crdbProvider) AdminStorage() storage.AdminStorage {
+	return postgres.NewAdminStorageWithOpts(s.db, s.opts)
+}
+
+func (s *crdbProvider) Close() error {
+	s.stopMetrics()
+	return s.db.Close()
+}
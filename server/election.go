@@ -0,0 +1,75 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google/trillian/util/election2"
+)
+
+// NewElectionFactoryFunc is the signature of a function which can be
+// registered to provide master election for the instance with the given ID.
+type NewElectionFactoryFunc func(instanceID string) (election2.Factory, error)
+
+var (
+	efMu     sync.RWMutex
+	efByName map[string]NewElectionFactoryFunc
+)
+
+// RegisterElectionFactory registers the provided election factory provider.
+func RegisterElectionFactory(name string, ef NewElectionFactoryFunc) error {
+	efMu.Lock()
+	defer efMu.Unlock()
+
+	if efByName == nil {
+		efByName = make(map[string]NewElectionFactoryFunc)
+	}
+
+	_, exists := efByName[name]
+	if exists {
+		return fmt.Errorf("election provider %v already registered", name)
+	}
+	efByName[name] = ef
+	return nil
+}
+
+// ElectionSystems returns the sorted names of the registered election
+// providers.
+func ElectionSystems() []string {
+	efMu.RLock()
+	defer efMu.RUnlock()
+
+	r := []string{}
+	for k := range efByName {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// NewElectionFactory returns an election2.Factory from the named provider.
+func NewElectionFactory(name, instanceID string) (election2.Factory, error) {
+	efMu.RLock()
+	defer efMu.RUnlock()
+
+	f, exists := efByName[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown election system: %v", name)
+	}
+	return f(instanceID)
+}
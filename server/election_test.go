@@ -0,0 +1,52 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/google/trillian/util/election2"
+)
+
+func TestElectionProviderRegistration(t *testing.T) {
+	var gotID string
+	if err := RegisterElectionFactory("test-election", func(instanceID string) (election2.Factory, error) {
+		gotID = instanceID
+		return election2.NoopFactory{}, nil
+	}); err != nil {
+		t.Fatalf("RegisterElectionFactory()=%v", err)
+	}
+	if err := RegisterElectionFactory("test-election", nil); err == nil {
+		t.Error("RegisterElectionFactory() twice: no error, want error")
+	}
+
+	if _, err := NewElectionFactory("test-election", "instance"); err != nil {
+		t.Fatalf("NewElectionFactory()=%v", err)
+	}
+	if want := "instance"; gotID != want {
+		t.Errorf("provider called with instance %q, want %q", gotID, want)
+	}
+	if _, err := NewElectionFactory("unknown", "instance"); err == nil {
+		t.Error("NewElectionFactory(unknown): no error, want error")
+	}
+
+	var found bool
+	for _, n := range ElectionSystems() {
+		found = found || n == ElectionConsul
+	}
+	if !found {
+		t.Errorf("ElectionSystems()=%v, want %v included", ElectionSystems(), ElectionConsul)
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package errmsg separates the messages of the errors returned by Trillian
+// servers from the conditions which caused them.
+//
+// Each condition has a stable Reason. Errors created with New carry their
+// Reason and the parameters of their message in an errmsgpb.ErrorInfo detail
+// of their gRPC status, and their message is rendered from the English
+// Catalog. The gRPC code, reason and parameters of an error are stable, but
+// its message may change, so personalities which show Trillian errors to
+// their users can localize or rewrite them with their own Catalog.
+package errmsg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/trillian/server/errmsg/errmsgpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Reason identifies an error condition. Reasons are stable, and are
+// documented with the names of the parameters of their messages.
+type Reason string
+
+// Reasons of the errors returned by Trillian servers.
+const (
+	// FieldEmpty means a required request field is empty. Params: field.
+	FieldEmpty Reason = "FIELD_EMPTY"
+	// FieldNegative means a request field is negative. Params: field, value.
+	FieldNegative Reason = "FIELD_NEGATIVE"
+	// FieldNotPositive means a request field is zero or negative. Params:
+	// field, value.
+	FieldNotPositive Reason = "FIELD_NOT_POSITIVE"
+	// IndexBeyondTreeSize means a leaf index is not less than the tree size
+	// it refers to. Params: field, value, size.
+	IndexBeyondTreeSize Reason = "INDEX_BEYOND_TREE_SIZE"
+	// TreeSizesOutOfOrder means the second tree size of a consistency proof
+	// request is less than the first. Params: first, second.
+	TreeSizesOutOfOrder Reason = "TREE_SIZES_OUT_OF_ORDER"
+	// LeafHashWrongSize means a leaf hash does not have the size of the
+	// hashes of the tree. Params: field, got, want.
+	LeafHashWrongSize Reason = "LEAF_HASH_WRONG_SIZE"
+	// LeafHashNotFound means no leaf with a hash is included in a tree size.
+	// Params: hash, size.
+	LeafHashNotFound Reason = "LEAF_HASH_NOT_FOUND"
+	// LeafIndexNotSequential means the leaves of a request do not have
+	// consecutive indices. Params: field, value, want.
+	LeafIndexNotSequential Reason = "LEAF_INDEX_NOT_SEQUENTIAL"
+	// MapIndexWrongSize means a map index does not have the size of the
+	// hashes of the map. Params: position, got, want.
+	MapIndexWrongSize Reason = "MAP_INDEX_WRONG_SIZE"
+	// DuplicateMapIndex means a map index is repeated in a request. Params:
+	// position.
+	DuplicateMapIndex Reason = "DUPLICATE_MAP_INDEX"
+	// DuplicateMapRequest means a map is updated more than once in a request.
+	// Params: map_id.
+	DuplicateMapRequest Reason = "DUPLICATE_MAP_REQUEST"
+	// InvalidPageToken means a page token was not returned by the server.
+	// Params: detail.
+	InvalidPageToken Reason = "INVALID_PAGE_TOKEN"
+	// IdempotencyTokenTooLong means an idempotency token exceeds the maximum
+	// size. Params: got, max.
+	IdempotencyTokenTooLong Reason = "IDEMPOTENCY_TOKEN_TOO_LONG"
+	// RevisionMismatch means a write asserted a revision other than the one
+	// it would create. Params: revision.
+	RevisionMismatch Reason = "REVISION_MISMATCH"
+	// TreeAlreadyInitialized means a tree which has a root was initialized.
+	// Params: tree_type.
+	TreeAlreadyInitialized Reason = "TREE_ALREADY_INITIALIZED"
+	// TooManyIndices means a request reads more map indices than the server
+	// allows. Params: got, max.
+	TooManyIndices Reason = "TOO_MANY_INDICES"
+	// TooManyLeaves means a request writes more map leaves than the server
+	// allows. Params: got, max.
+	TooManyLeaves Reason = "TOO_MANY_LEAVES"
+	// RequestTooLarge means the size of a request exceeds the server limit.
+	// Params: got, max.
+	RequestTooLarge Reason = "REQUEST_TOO_LARGE"
+	// RequestTimedOut means a request did not complete within the server
+	// limit on its duration. Params: timeout.
+	RequestTimedOut Reason = "REQUEST_TIMED_OUT"
+	// RevisionsOutOfOrder means the second revision of a map consistency
+	// proof request is less than the first. Params: first, second.
+	RevisionsOutOfOrder Reason = "REVISIONS_OUT_OF_ORDER"
+	// ServerReadOnly means a write was sent to a server which only serves
+	// reads. Params: method.
+	ServerReadOnly Reason = "SERVER_READ_ONLY"
+	// QueuedWriteUnsupported means a write which is queued to be merged later
+	// set a field which needs the write to be applied immediately. Params:
+	// field.
+	QueuedWriteUnsupported Reason = "QUEUED_WRITE_UNSUPPORTED"
+	// MapIndexPrefixTooLong means a map index prefix is not shorter than the
+	// indexes of the map. Params: got, max.
+	MapIndexPrefixTooLong Reason = "MAP_INDEX_PREFIX_TOO_LONG"
+	// TreeQuarantined means an update would move a tree out of the
+	// QUARANTINED state, which only LiftQuarantine may do. Params: tree_id.
+	TreeQuarantined Reason = "TREE_QUARANTINED"
+	// TreeNotQuarantined means the quarantine of a tree which is not in the
+	// QUARANTINED state was lifted. Params: tree_id, tree_state.
+	TreeNotQuarantined Reason = "TREE_NOT_QUARANTINED"
+	// TreeNotPausable means the sequencing of a tree which is not an ACTIVE
+	// log was paused. Params: tree_id, tree_type, tree_state.
+	TreeNotPausable Reason = "TREE_NOT_PAUSABLE"
+	// TreeNotPaused means the sequencing of a tree which is not in the PAUSED
+	// state was resumed. Params: tree_id, tree_state.
+	TreeNotPaused Reason = "TREE_NOT_PAUSED"
+	// LeafHashIndexDisabled means a map without a leaf hash index was asked
+	// to look up a leaf by its hash. Params: map_id.
+	LeafHashIndexDisabled Reason = "LEAF_HASH_INDEX_DISABLED"
+	// DryRunUnsupported means a method which can't be run without writing was
+	// asked for a dry run. Params: method.
+	DryRunUnsupported Reason = "DRY_RUN_UNSUPPORTED"
+	// RevisionReserved means a write was made at a map revision reserved by
+	// another writer's lease. Params: revision.
+	RevisionReserved Reason = "REVISION_RESERVED"
+	// RevisionLeaseTooLong means a revision reservation asked for a lease
+	// longer than the server allows. Params: got, max.
+	RevisionLeaseTooLong Reason = "REVISION_LEASE_TOO_LONG"
+	// StageTimedOut means a stage of a map write did not complete within the
+	// server limit on its duration. Params: stage, timeout.
+	StageTimedOut Reason = "STAGE_TIMED_OUT"
+	// TreeOverloaded means requests for a tree are shed because it has too
+	// many requests in flight, or too many of its recent requests timed out.
+	// Params: tree_id.
+	TreeOverloaded Reason = "TREE_OVERLOADED"
+	// MapRootSignatureInvalid means a witness signature does not verify over
+	// the map root at the revision it was submitted for. Params: revision.
+	MapRootSignatureInvalid Reason = "MAP_ROOT_SIGNATURE_INVALID"
+	// LogRootSignatureInvalid means a witness signature does not verify over
+	// the log root at the revision it was submitted for. Params: revision.
+	LogRootSignatureInvalid Reason = "LOG_ROOT_SIGNATURE_INVALID"
+	// ImportedRootMismatch means the leaves of an imported map revision don't
+	// produce its exported root hash. Params: revision.
+	ImportedRootMismatch Reason = "IMPORTED_ROOT_MISMATCH"
+	// RootHashNotPublished means a root hash is not that of any root
+	// published by a log. Params: field, hash.
+	RootHashNotPublished Reason = "ROOT_HASH_NOT_PUBLISHED"
+	// RootsOutOfOrder means the second root of a consistency proof request
+	// has a smaller tree size than the first. Params: first, second.
+	RootsOutOfOrder Reason = "ROOTS_OUT_OF_ORDER"
+	// TreeNotDeleted means a tree which is not soft-deleted was purged.
+	// Params: tree_id.
+	TreeNotDeleted Reason = "TREE_NOT_DELETED"
+	// TooManyTrees means a request would create more trees than allowed.
+	// Params: got, max.
+	TooManyTrees Reason = "TOO_MANY_TREES"
+	// KeyRotationInProgress means the key of a tree was rotated while a
+	// previous rotation is still in progress. Params: tree_id, retire_time.
+	KeyRotationInProgress Reason = "KEY_ROTATION_IN_PROGRESS"
+)
+
+// Catalog maps reasons to message templates, in which each "{name}" is
+// replaced by the value of the parameter with that name.
+type Catalog map[Reason]string
+
+// English is the catalog of the messages returned by Trillian servers.
+var English = Catalog{
+	FieldEmpty:              "{field} empty",
+	FieldNegative:           "{field}: {value}, want >= 0",
+	FieldNotPositive:        "{field}: {value}, want > 0",
+	IndexBeyondTreeSize:     "{field}: {value}, want < TreeSize: {size}",
+	TreeSizesOutOfOrder:     "SecondTreeSize: {second}, want >= FirstTreeSize: {first}",
+	LeafHashWrongSize:       "{field}: {got} bytes, want {want}",
+	LeafHashNotFound:        "No leaf found for hash: {hash} in tree size {size}",
+	LeafIndexNotSequential:  "{field}={value}, want {want}",
+	MapIndexWrongSize:       "index at position {position} has wrong length: got={got},want={want}",
+	DuplicateMapIndex:       "duplicate index detected at position {position}",
+	DuplicateMapRequest:     "duplicate requests for map {map_id}",
+	InvalidPageToken:        "invalid page token: {detail}",
+	IdempotencyTokenTooLong: "idempotency token too long: got {got} bytes, max {max}",
+	RevisionMismatch:        "can't write to revision {revision}",
+	TreeAlreadyInitialized:  "{tree_type} is already initialised",
+	TooManyIndices:          "too many indices: got {got}, max {max}",
+	TooManyLeaves:           "too many leaves: got {got}, max {max}",
+	RequestTooLarge:         "request too large: got {got} bytes, max {max}",
+	RequestTimedOut:         "request did not complete within {timeout}",
+	RevisionsOutOfOrder:     "SecondRevision: {second}, want >= FirstRevision: {first}",
+	ServerReadOnly:          "{method} is not served by read-only servers",
+	QueuedWriteUnsupported:  "{field} is not supported by queued writes",
+	MapIndexPrefixTooLong:   "index prefix too long: got {got} bytes, max {max}",
+	TreeQuarantined:         "tree {tree_id} is quarantined, use LiftQuarantine to change its state",
+	TreeNotQuarantined:      "tree {tree_id} is not quarantined: tree_state {tree_state}",
+	TreeNotPausable:         "sequencing of tree {tree_id} can't be paused: tree_type {tree_type}, tree_state {tree_state}",
+	TreeNotPaused:           "sequencing of tree {tree_id} is not paused: tree_state {tree_state}",
+	LeafHashIndexDisabled:   "map {map_id} has no leaf hash index",
+	DryRunUnsupported:       "{method} does not support dry runs",
+	RevisionReserved:        "revision {revision} is reserved by another writer",
+	RevisionLeaseTooLong:    "revision lease too long: got {got}, max {max}",
+	StageTimedOut:           "{stage} stage of the write did not complete within {timeout}",
+	TreeOverloaded:          "tree {tree_id} is overloaded, retry later",
+	MapRootSignatureInvalid: "signature does not verify over the map root at revision {revision}",
+	LogRootSignatureInvalid: "signature does not verify over the log root at revision {revision}",
+	ImportedRootMismatch:    "imported leaves don't produce the exported root hash at revision {revision}",
+	RootHashNotPublished:    "{field}: {hash} is not the hash of a published root",
+	RootsOutOfOrder:         "second root has tree size {second}, want >= tree size of first root: {first}",
+	TreeNotDeleted:          "tree {tree_id} is not deleted, use DeleteTree before purging it",
+	TooManyTrees:            "too many trees: got {got}, max {max}",
+	KeyRotationInProgress:   "tree {tree_id} is rotating its key until {retire_time}",
+}
+
+// Params holds the values of the parameters of a message, keyed by name.
+// Values are formatted with fmt.Sprint.
+type Params map[string]interface{}
+
+// New returns an error with the given code and reason, and a message
+// rendered from the English catalog.
+func New(code codes.Code, reason Reason, params Params) error {
+	info := newInfo(reason, params)
+	s, err := status.New(code, English.Format(info)).WithDetails(info)
+	if err != nil {
+		// ErrorInfo always marshals, but the error must not be lost.
+		return status.Error(code, English.Format(info))
+	}
+	return s.Err()
+}
+
+// FieldViolation is a problem with one field of a request.
+type FieldViolation struct {
+	// Field is the path of the field, e.g. "leaves[3].index".
+	Field  string
+	Reason Reason
+	Params Params
+}
+
+// NewBadRequest returns an InvalidArgument error for violations, which must
+// not be empty, so that clients can fix all the problems with a request at
+// once. Its message and ErrorInfo are those of the first violation, and it
+// also carries an errdetails.BadRequest detail listing all of them, each
+// described by its reason followed by its English message.
+func NewBadRequest(violations []FieldViolation) error {
+	first := violations[0]
+	err := New(codes.InvalidArgument, first.Reason, first.Params)
+	br := &errdetails.BadRequest{FieldViolations: make([]*errdetails.BadRequest_FieldViolation, 0, len(violations))}
+	for _, v := range violations {
+		info := newInfo(v.Reason, v.Params)
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: fmt.Sprintf("%s: %s", v.Reason, English.Format(info)),
+		})
+	}
+	s, detailErr := status.Convert(err).WithDetails(br)
+	if detailErr != nil {
+		return err
+	}
+	return s.Err()
+}
+
+// BadRequest returns the BadRequest detail attached to err, or nil if err was
+// not created by NewBadRequest.
+func BadRequest(err error) *errdetails.BadRequest {
+	s, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	for _, d := range s.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			return br
+		}
+	}
+	return nil
+}
+
+// Info returns the ErrorInfo attached to err, or nil if err was not created
+// by New.
+func Info(err error) *errmsgpb.ErrorInfo {
+	s, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	for _, d := range s.Details() {
+		if info, ok := d.(*errmsgpb.ErrorInfo); ok {
+			return info
+		}
+	}
+	return nil
+}
+
+// Format renders the message of info. Reasons missing from c are rendered
+// from the English catalog, and unknown reasons as the reason followed by the
+// parameters.
+func (c Catalog) Format(info *errmsgpb.ErrorInfo) string {
+	reason := Reason(info.GetReason())
+	tmpl, ok := c[reason]
+	if !ok {
+		tmpl, ok = English[reason]
+	}
+	if !ok {
+		return fallbackMessage(info)
+	}
+	oldnew := make([]string, 0, 2*len(info.GetParams()))
+	for name, value := range info.GetParams() {
+		oldnew = append(oldnew, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(oldnew...).Replace(tmpl)
+}
+
+// Message returns the message of err rendered from c if err carries an
+// ErrorInfo, and the message of its gRPC status otherwise.
+func (c Catalog) Message(err error) string {
+	if info := Info(err); info != nil {
+		return c.Format(info)
+	}
+	return status.Convert(err).Message()
+}
+
+func newInfo(reason Reason, params Params) *errmsgpb.ErrorInfo {
+	info := &errmsgpb.ErrorInfo{Reason: string(reason), Params: make(map[string]string, len(params))}
+	for name, value := range params {
+		info.Params[name] = fmt.Sprint(value)
+	}
+	return info
+}
+
+func fallbackMessage(info *errmsgpb.ErrorInfo) string {
+	names := make([]string, 0, len(info.GetParams()))
+	for name := range info.GetParams() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(info.GetReason())
+	for _, name := range names {
+		fmt.Fprintf(&b, " %s=%s", name, info.GetParams()[name])
+	}
+	return b.String()
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errmsg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian/server/errmsg/errmsgpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNew(t *testing.T) {
+	err := New(codes.InvalidArgument, FieldNegative, Params{"field": "Req.Count", "value": int64(-1)})
+	s := status.Convert(err)
+	if got, want := s.Code(), codes.InvalidArgument; got != want {
+		t.Errorf("Code()=%v, want %v", got, want)
+	}
+	if got, want := s.Message(), "Req.Count: -1, want >= 0"; got != want {
+		t.Errorf("Message()=%q, want %q", got, want)
+	}
+	info := Info(err)
+	if info == nil {
+		t.Fatal("Info()=nil, want ErrorInfo")
+	}
+	if got, want := info.Reason, string(FieldNegative); got != want {
+		t.Errorf("Info().Reason=%q, want %q", got, want)
+	}
+	if got, want := info.Params["value"], "-1"; got != want {
+		t.Errorf("Info().Params[value]=%q, want %q", got, want)
+	}
+}
+
+func TestNewBadRequest(t *testing.T) {
+	err := NewBadRequest([]FieldViolation{
+		{Field: "index[1]", Reason: DuplicateMapIndex, Params: Params{"position": 1}},
+		{Field: "index[2]", Reason: MapIndexWrongSize, Params: Params{"position": 2, "got": 3, "want": 32}},
+	})
+	s := status.Convert(err)
+	if got, want := s.Code(), codes.InvalidArgument; got != want {
+		t.Errorf("Code()=%v, want %v", got, want)
+	}
+	if got, want := s.Message(), "duplicate index detected at position 1"; got != want {
+		t.Errorf("Message()=%q, want %q", got, want)
+	}
+	if got, want := Info(err).GetReason(), string(DuplicateMapIndex); got != want {
+		t.Errorf("Info().Reason=%q, want %q", got, want)
+	}
+	br := BadRequest(err)
+	if br == nil {
+		t.Fatal("BadRequest()=nil, want BadRequest")
+	}
+	want := []*errdetails.BadRequest_FieldViolation{
+		{Field: "index[1]", Description: "DUPLICATE_MAP_INDEX: duplicate index detected at position 1"},
+		{Field: "index[2]", Description: "MAP_INDEX_WRONG_SIZE: index at position 2 has wrong length: got=3,want=32"},
+	}
+	if got := br.FieldViolations; len(got) != len(want) {
+		t.Fatalf("BadRequest().FieldViolations=%v, want %v", got, want)
+	}
+	for i, got := range br.FieldViolations {
+		if !proto.Equal(got, want[i]) {
+			t.Errorf("BadRequest().FieldViolations[%d]=%v, want %v", i, got, want[i])
+		}
+	}
+	if br := BadRequest(New(codes.InvalidArgument, FieldEmpty, Params{"field": "x"})); br != nil {
+		t.Errorf("BadRequest(New())=%v, want nil", br)
+	}
+}
+
+func TestInfo_NoDetails(t *testing.T) {
+	for _, err := range []error{nil, errors.New("plain"), status.Error(codes.Internal, "no details")} {
+		if info := Info(err); info != nil {
+			t.Errorf("Info(%v)=%v, want nil", err, info)
+		}
+	}
+}
+
+func TestCatalog_Message(t *testing.T) {
+	french := Catalog{FieldEmpty: "{field} est vide"}
+	for _, test := range []struct {
+		desc string
+		err  error
+		want string
+	}{
+		{
+			desc: "localized",
+			err:  New(codes.InvalidArgument, FieldEmpty, Params{"field": "Req.Leaves"}),
+			want: "Req.Leaves est vide",
+		},
+		{
+			desc: "english-fallback",
+			err:  New(codes.InvalidArgument, DuplicateMapIndex, Params{"position": 3}),
+			want: "duplicate index detected at position 3",
+		},
+		{
+			desc: "unknown-reason",
+			err: func() error {
+				s, _ := status.New(codes.Unknown, "newer server").WithDetails(&errmsgpb.ErrorInfo{
+					Reason: "SOMETHING_NEW",
+					Params: map[string]string{"b": "2", "a": "1"},
+				})
+				return s.Err()
+			}(),
+			want: "SOMETHING_NEW a=1 b=2",
+		},
+		{
+			desc: "no-info",
+			err:  status.Error(codes.Internal, "storage failure"),
+			want: "storage failure",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := french.Message(test.err); got != test.want {
+				t.Errorf("Message()=%q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEnglish_Complete(t *testing.T) {
+	for _, reason := range []Reason{
+		FieldEmpty, FieldNegative, FieldNotPositive, IndexBeyondTreeSize,
+		TreeSizesOutOfOrder, LeafHashWrongSize, LeafHashNotFound,
+		LeafIndexNotSequential, MapIndexWrongSize, DuplicateMapIndex,
+		DuplicateMapRequest, InvalidPageToken, IdempotencyTokenTooLong,
+		RevisionMismatch, TreeAlreadyInitialized, TooManyIndices,
+		TooManyLeaves, RequestTooLarge, RequestTimedOut, RevisionsOutOfOrder,
+		ServerReadOnly, QueuedWriteUnsupported, MapIndexPrefixTooLong,
+		TreeQuarantined, TreeNotQuarantined, LeafHashIndexDisabled,
+		DryRunUnsupported, RevisionReserved, RevisionLeaseTooLong,
+		StageTimedOut, TreeOverloaded, MapRootSignatureInvalid,
+		ImportedRootMismatch, LogRootSignatureInvalid, TreeNotPausable,
+		TreeNotPaused, RootHashNotPublished, RootsOutOfOrder, TreeNotDeleted,
+		TooManyTrees, KeyRotationInProgress,
+	} {
+		if _, ok := English[reason]; !ok {
+			t.Errorf("English has no message for %v", reason)
+		}
+	}
+}
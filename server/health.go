@@ -0,0 +1,291 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/trees"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultHealthCheckInterval is the interval between checks of the server
+	// status if HealthOptions.CheckInterval is zero.
+	DefaultHealthCheckInterval = 10 * time.Second
+	// DefaultHealthCheckTimeout bounds each check of the server status if
+	// HealthOptions.CheckTimeout is zero.
+	DefaultHealthCheckTimeout = 5 * time.Second
+)
+
+// serviceTreeTypes holds the types of tree served by each gRPC service which
+// serves trees. The status of a tree can be checked through these services.
+var serviceTreeTypes = map[string][]trillian.TreeType{
+	"trillian.TrillianLog":      {trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG},
+	"trillian.TrillianMap":      {trillian.TreeType_MAP},
+	"trillian.TrillianMapWrite": {trillian.TreeType_MAP},
+}
+
+// HealthOptions configures a HealthServer.
+type HealthOptions struct {
+	// Readiness, if set, makes the server and its writable trees serving only
+	// if the signer keys of the trees can be loaded, and not only if the
+	// databases are accessible.
+	Readiness bool
+	// CheckInterval is the interval between checks of the server status. If
+	// zero, DefaultHealthCheckInterval is used.
+	CheckInterval time.Duration
+	// CheckTimeout bounds each check. If zero, DefaultHealthCheckTimeout is
+	// used.
+	CheckTimeout time.Duration
+}
+
+// HealthServer implements the standard grpc.health.v1.Health service. The
+// status of the server, under the empty service name, and of the services
+// added to it, is updated by CheckHealth. The status of a tree is checked on
+// each request for "<service>/<tree ID>", e.g. "trillian.TrillianLog/123",
+// and is only SERVING if the service is, and serves trees of its type.
+type HealthServer struct {
+	*health.Server
+	registry extension.Registry
+	opts     HealthOptions
+
+	mu       sync.Mutex
+	services []string
+	lastErr  error
+}
+
+// NewHealthServer returns a HealthServer checking the storage of registry.
+// It reports NOT_SERVING until CheckHealth is first called.
+func NewHealthServer(registry extension.Registry, opts HealthOptions) *HealthServer {
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = DefaultHealthCheckInterval
+	}
+	if opts.CheckTimeout <= 0 {
+		opts.CheckTimeout = DefaultHealthCheckTimeout
+	}
+	s := &HealthServer{
+		Server:   health.NewServer(),
+		registry: registry,
+		opts:     opts,
+		lastErr:  fmt.Errorf("health not checked yet"),
+	}
+	s.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	return s
+}
+
+// AddService adds a gRPC service, whose status follows that of the server.
+func (s *HealthServer) AddService(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.services = append(s.services, name)
+	status := grpc_health_v1.HealthCheckResponse_SERVING
+	if s.lastErr != nil {
+		status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	s.SetServingStatus(name, status)
+}
+
+// CheckHealth checks that the databases are accessible, and in readiness mode
+// that the signer keys of the writable trees can be loaded, and updates the
+// status of the server and its services. It returns the error which made the
+// server not serving, if any.
+func (s *HealthServer) CheckHealth(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.CheckTimeout)
+	defer cancel()
+	err := s.checkDatabases(ctx)
+	if err == nil && s.opts.Readiness {
+		err = s.checkSigners(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := grpc_health_v1.HealthCheckResponse_SERVING
+	if err != nil {
+		status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	s.SetServingStatus("", status)
+	for _, name := range s.services {
+		s.SetServingStatus(name, status)
+	}
+	s.lastErr = err
+	return err
+}
+
+// Ready returns the error which made the last CheckHealth report the server
+// not serving, if any.
+func (s *HealthServer) Ready() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// Run calls CheckHealth every CheckInterval until ctx is done.
+func (s *HealthServer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.CheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.CheckHealth(ctx); err != nil {
+			glog.Warningf("Server not serving: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkDatabases returns an error if any storage of the server is not
+// accessible.
+func (s *HealthServer) checkDatabases(ctx context.Context) error {
+	if err := s.registry.AdminStorage.CheckDatabaseAccessible(ctx); err != nil {
+		return fmt.Errorf("admin storage not accessible: %v", err)
+	}
+	if ls := s.registry.LogStorage; ls != nil {
+		if err := ls.CheckDatabaseAccessible(ctx); err != nil {
+			return fmt.Errorf("log storage not accessible: %v", err)
+		}
+	}
+	if ms := s.registry.MapStorage; ms != nil {
+		if err := ms.CheckDatabaseAccessible(ctx); err != nil {
+			return fmt.Errorf("map storage not accessible: %v", err)
+		}
+	}
+	return nil
+}
+
+// checkSigners returns an error if the signer key of any writable tree of the
+// server cannot be loaded.
+func (s *HealthServer) checkSigners(ctx context.Context) error {
+	allTrees, err := storage.ListTrees(ctx, s.registry.AdminStorage, false /* includeDeleted */)
+	if err != nil {
+		return fmt.Errorf("failed to list trees: %v", err)
+	}
+	for _, tree := range allTrees {
+		if !s.servesType(tree.TreeType) {
+			continue
+		}
+		if err := checkSigner(ctx, tree); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSigner returns an error if the tree is writable and its signer key
+// cannot be loaded.
+func checkSigner(ctx context.Context, tree *trillian.Tree) error {
+	switch tree.TreeState {
+	case trillian.TreeState_ACTIVE, trillian.TreeState_DRAINING:
+	default:
+		return nil
+	}
+	if _, err := trees.Signer(ctx, tree); err != nil {
+		return fmt.Errorf("signer key of tree %d not available: %v", tree.TreeId, err)
+	}
+	return nil
+}
+
+// servesType returns whether a service added to the server serves trees of
+// type treeType.
+func (s *HealthServer) servesType(treeType trillian.TreeType) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range s.services {
+		for _, t := range serviceTreeTypes[name] {
+			if t == treeType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check implements grpc_health_v1.HealthServer.Check.
+func (s *HealthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	service, treeID, ok := splitTreeService(req.Service)
+	if !ok {
+		return s.Server.Check(ctx, req)
+	}
+	resp, err := s.Server.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+	if err != nil || resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		return resp, err
+	}
+	return s.checkTree(ctx, service, treeID)
+}
+
+// Watch implements grpc_health_v1.HealthServer.Watch. The status of trees
+// cannot be watched.
+func (s *HealthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	if _, _, ok := splitTreeService(req.Service); ok {
+		return status.Errorf(codes.Unimplemented, "the status of tree %q cannot be watched, only checked", req.Service)
+	}
+	return s.Server.Watch(req, stream)
+}
+
+// checkTree returns the status of tree treeID, served by service.
+func (s *HealthServer) checkTree(ctx context.Context, service string, treeID int64) (*grpc_health_v1.HealthCheckResponse, error) {
+	tree, err := storage.GetTree(ctx, s.registry.AdminStorage, treeID)
+	if status.Code(err) == codes.NotFound {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	} else if err != nil {
+		return nil, err
+	}
+	served := false
+	for _, t := range serviceTreeTypes[service] {
+		served = served || t == tree.TreeType
+	}
+	if tree.Deleted || !served {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+
+	resp := &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}
+	if tree.TreeState == trillian.TreeState_QUARANTINED {
+		resp.Status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	} else if s.opts.Readiness {
+		if err := checkSigner(ctx, tree); err != nil {
+			glog.Warningf("Tree %d not serving: %v", treeID, err)
+			resp.Status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	return resp, nil
+}
+
+// splitTreeService splits the name of the status of a tree into the service
+// serving it and the tree ID.
+func splitTreeService(name string) (string, int64, bool) {
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return "", 0, false
+	}
+	treeID, err := strconv.ParseInt(name[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return name[:i], treeID, true
+}
@@ -0,0 +1,210 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/testonly"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	_ "github.com/google/trillian/crypto/keys/pem/proto" // PEMKeyFile proto handler
+	stestonly "github.com/google/trillian/storage/testonly"
+)
+
+const mapService = "trillian.TrillianMap"
+
+// healthTrees creates a map, a map whose signer key file has been removed, a
+// quarantined map and a log in as, and returns their IDs.
+func healthTrees(ctx context.Context, t *testing.T, as storage.AdminStorage) (mapID, badKeyID, quarantinedID, logID int64) {
+	t.Helper()
+	keyFile, err := ioutil.TempFile("", "health_test")
+	if err != nil {
+		t.Fatalf("TempFile(): %v", err)
+	}
+	defer os.Remove(keyFile.Name())
+	if _, err := keyFile.WriteString(testonly.DemoPrivateKey); err != nil {
+		t.Fatalf("WriteString(): %v", err)
+	}
+	keyFile.Close()
+	badKey := proto.Clone(stestonly.MapTree).(*trillian.Tree)
+	if badKey.PrivateKey, err = ptypes.MarshalAny(&keyspb.PEMKeyFile{Path: keyFile.Name(), Password: testonly.DemoPrivateKeyPass}); err != nil {
+		t.Fatalf("MarshalAny(): %v", err)
+	}
+	var ids []int64
+	for _, tree := range []*trillian.Tree{stestonly.MapTree, badKey, stestonly.MapTree, stestonly.LogTree} {
+		created, err := storage.CreateTree(ctx, as, proto.Clone(tree).(*trillian.Tree))
+		if err != nil {
+			t.Fatalf("CreateTree(): %v", err)
+		}
+		ids = append(ids, created.TreeId)
+	}
+	if _, err := storage.UpdateTree(ctx, as, ids[2], func(tree *trillian.Tree) {
+		tree.TreeState = trillian.TreeState_QUARANTINED
+	}); err != nil {
+		t.Fatalf("UpdateTree(): %v", err)
+	}
+	return ids[0], ids[1], ids[2], ids[3]
+}
+
+func checkHealth(ctx context.Context, s *HealthServer, service string) (grpc_health_v1.HealthCheckResponse_ServingStatus, codes.Code) {
+	resp, err := s.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+	if err != nil {
+		return grpc_health_v1.HealthCheckResponse_UNKNOWN, status.Code(err)
+	}
+	return resp.Status, codes.OK
+}
+
+func TestHealthServer_CheckHealth(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, test := range []struct {
+		desc          string
+		accessibleErr error
+		badKey        bool
+		readiness     bool
+		wantErr       bool
+	}{
+		{desc: "healthy"},
+		{desc: "unhealthy", accessibleErr: errors.New("DB not happy"), wantErr: true},
+		{desc: "bad-key", badKey: true},
+		{desc: "ready", readiness: true},
+		{desc: "not-ready", badKey: true, readiness: true, wantErr: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			as := memory.NewAdminStorage(memory.NewTreeStorage())
+			if test.badKey {
+				healthTrees(ctx, t, as)
+			}
+			fakeStorage := storage.NewMockMapStorage(ctrl)
+			fakeStorage.EXPECT().CheckDatabaseAccessible(gomock.Any()).Return(test.accessibleErr)
+
+			s := NewHealthServer(extension.Registry{AdminStorage: as, MapStorage: fakeStorage}, HealthOptions{Readiness: test.readiness})
+			s.AddService(mapService)
+			if got, _ := checkHealth(ctx, s, ""); got != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+				t.Errorf("Check() before CheckHealth(): %v, want NOT_SERVING", got)
+			}
+
+			err := s.CheckHealth(ctx)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("CheckHealth(): %v, want err? %t", err, test.wantErr)
+			}
+			if got, want := s.Ready(), err; got != want {
+				t.Errorf("Ready(): %v, want %v", got, want)
+			}
+			want := grpc_health_v1.HealthCheckResponse_SERVING
+			if test.wantErr {
+				want = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+			}
+			for _, service := range []string{"", mapService} {
+				if got, code := checkHealth(ctx, s, service); got != want {
+					t.Errorf("Check(%q): %v, %v, want %v", service, got, code, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthServer_CheckTree(t *testing.T) {
+	ctx := context.Background()
+	as := memory.NewAdminStorage(memory.NewTreeStorage())
+	mapID, badKeyID, quarantinedID, logID := healthTrees(ctx, t, as)
+
+	for _, test := range []struct {
+		desc      string
+		readiness bool
+		service   string
+		want      grpc_health_v1.HealthCheckResponse_ServingStatus
+		wantCode  codes.Code
+	}{
+		{desc: "map", service: fmt.Sprintf("%s/%d", mapService, mapID), want: grpc_health_v1.HealthCheckResponse_SERVING},
+		{desc: "map-ready", readiness: true, service: fmt.Sprintf("%s/%d", mapService, mapID), want: grpc_health_v1.HealthCheckResponse_SERVING},
+		{desc: "bad-key", service: fmt.Sprintf("%s/%d", mapService, badKeyID), want: grpc_health_v1.HealthCheckResponse_SERVING},
+		{desc: "bad-key-ready", readiness: true, service: fmt.Sprintf("%s/%d", mapService, badKeyID), want: grpc_health_v1.HealthCheckResponse_NOT_SERVING},
+		{desc: "quarantined", service: fmt.Sprintf("%s/%d", mapService, quarantinedID), want: grpc_health_v1.HealthCheckResponse_NOT_SERVING},
+		{desc: "wrong-type", service: fmt.Sprintf("%s/%d", mapService, logID), wantCode: codes.NotFound},
+		{desc: "service-not-added", service: fmt.Sprintf("trillian.TrillianLog/%d", logID), wantCode: codes.NotFound},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			// Check the server without readiness, which the bad key would
+			// fail, so that only the trees are checked for it.
+			s := NewHealthServer(extension.Registry{AdminStorage: as}, HealthOptions{})
+			s.AddService(mapService)
+			if err := s.CheckHealth(ctx); err != nil {
+				t.Fatalf("CheckHealth(): %v", err)
+			}
+			s.opts.Readiness = test.readiness
+
+			got, code := checkHealth(ctx, s, test.service)
+			if got != test.want || code != test.wantCode {
+				t.Errorf("Check(%q): %v, %v, want %v, %v", test.service, got, code, test.want, test.wantCode)
+			}
+		})
+	}
+}
+
+func TestHealthServer_CheckTreeNotServing(t *testing.T) {
+	ctx := context.Background()
+	as := memory.NewAdminStorage(memory.NewTreeStorage())
+	mapID, _, _, _ := healthTrees(ctx, t, as)
+	s := NewHealthServer(extension.Registry{AdminStorage: as}, HealthOptions{})
+	s.AddService(mapService)
+
+	// The server has not been checked yet, so neither are its trees.
+	service := fmt.Sprintf("%s/%d", mapService, mapID)
+	if got, code := checkHealth(ctx, s, service); got != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Check(%q): %v, %v, want NOT_SERVING", service, got, code)
+	}
+	err := s.Watch(&grpc_health_v1.HealthCheckRequest{Service: service}, nil)
+	if got, want := status.Code(err), codes.Unimplemented; got != want {
+		t.Errorf("Watch(%q): %v, want code %v", service, err, want)
+	}
+}
+
+func TestSplitTreeService(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		wantService string
+		wantTreeID  int64
+		wantOK      bool
+	}{
+		{name: "trillian.TrillianLog/123", wantService: "trillian.TrillianLog", wantTreeID: 123, wantOK: true},
+		{name: "trillian.TrillianLog"},
+		{name: ""},
+		{name: "trillian.TrillianLog/abc"},
+	} {
+		service, treeID, ok := splitTreeService(test.name)
+		if service != test.wantService || treeID != test.wantTreeID || ok != test.wantOK {
+			t.Errorf("splitTreeService(%q): %q, %d, %t, want %q, %d, %t", test.name, service, treeID, ok, test.wantService, test.wantTreeID, test.wantOK)
+		}
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/google/trillian/quota/etcd/quotapb"
 	"github.com/google/trillian/server/errors"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/querytag"
 	"github.com/google/trillian/trees"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -39,9 +40,15 @@ const (
 	badInfoReason            = "bad_info"
 	badTreeReason            = "bad_tree"
 	insufficientTokensReason = "insufficient_tokens"
+	treeQuotaReason          = "tree_quota"
 	getTreeStage             = "get_tree"
 	getTokensStage           = "get_tokens"
 	traceSpanRoot            = "/trillian/server/int"
+
+	// maxLeafHistoryCount mirrors the cap the map server applies to
+	// GetMapLeafHistoryRequest.Count, so callers aren't charged for revisions
+	// that will never be read.
+	maxLeafHistoryCount = 1000
 )
 
 var (
@@ -83,7 +90,8 @@ type RequestProcessor interface {
 
 // TrillianInterceptor checks that:
 // * Requests addressing a tree have the correct tree type and tree state;
-// * TODO(codingllama): Requests are properly authenticated / authorized ; and
+// * Requests are properly authenticated / authorized, if a TreeOwnership runs
+//   before it; and
 // * Requests are rate limited appropriately.
 type TrillianInterceptor struct {
 	admin storage.AdminStorage
@@ -92,6 +100,9 @@ type TrillianInterceptor struct {
 	// quotaDryRun controls whether lack of tokens actually blocks requests (if set to true, no
 	// requests are blocked by lack of tokens).
 	quotaDryRun bool
+
+	// treeLimiters enforces the quotas set on trees.
+	treeLimiters treeLimiters
 }
 
 // New returns a new TrillianInterceptor instance.
@@ -155,13 +166,16 @@ type trillianProcessor struct {
 }
 
 func (tp *trillianProcessor) Before(ctx context.Context, req interface{}, method string) (context.Context, error) {
+	// Attribute the storage queries of the request to it.
+	ctx = querytag.WithRPC(ctx, method)
+
 	// Skip if the interceptor is not enabled for this service.
 	if !enabledServices[serviceName(method)] {
 		return ctx, nil
 	}
 
 	// Don't want the Before to contain the action, so don't overwrite the ctx.
-	innerCtx, spanEnd := spanFor(ctx, "Before")
+	innerCtx, spanEnd := spanFor(ctx, "Before", monitoring.StringAttribute(monitoring.RPCKey, method))
 	defer spanEnd()
 	info, err := newRPCInfo(req)
 	if err != nil {
@@ -171,8 +185,11 @@ func (tp *trillianProcessor) Before(ctx context.Context, req interface{}, method
 	}
 	tp.info = info
 	requestCounter.Inc(fmt.Sprint(info.treeID))
+	if info.treeID != 0 {
+		ctx = querytag.WithTreeID(ctx, info.treeID)
+	}
 
-	// TODO(codingllama): Add auth interception
+	// Requests are authorized by the TreeOwnership interceptor, if any.
 
 	if info.getTree {
 		tree, err := trees.GetTree(
@@ -186,6 +203,14 @@ func (tp *trillianProcessor) Before(ctx context.Context, req interface{}, method
 			return ctx, err
 		}
 		ctx = trees.NewContext(ctx, tree)
+
+		if !tp.parent.treeLimiters.allow(tree, info.readonly, info.tokens, time.Now()) {
+			if !tp.parent.quotaDryRun {
+				incRequestDeniedCounter(treeQuotaReason, info.treeID, info.quotaUsers)
+				return ctx, status.Errorf(codes.ResourceExhausted, "quota of tree %d exhausted", info.treeID)
+			}
+			glog.Warningf("(quotaDryRun) Request %+v not denied due to dry run mode: quota of tree %d exhausted", req, info.treeID)
+		}
 	}
 
 	if info.tokens > 0 && len(info.specs) > 0 {
@@ -204,6 +229,30 @@ func (tp *trillianProcessor) Before(ctx context.Context, req interface{}, method
 		}
 	}
 
+	for i, c := range info.mapCharges {
+		specs := []quota.Spec{c.spec}
+		err := tp.parent.qm.GetTokens(innerCtx, c.tokens, specs)
+		quota.Metrics.IncAcquired(c.tokens, specs, err == nil)
+		if err != nil {
+			if !tp.parent.quotaDryRun {
+				// Return the tokens acquired so far, as the request won't be served.
+				if info.tokens > 0 {
+					tp.putTokens(info.tokens, info.specs)
+				}
+				for _, c := range info.mapCharges[:i] {
+					tp.putTokens(c.tokens, []quota.Spec{c.spec})
+				}
+				incRequestDeniedCounter(insufficientTokensReason, c.spec.TreeID, info.quotaUsers)
+				return ctx, status.Errorf(codes.ResourceExhausted, "quota exhausted: %v", err)
+			}
+			glog.Warningf("(quotaDryRun) Request %+v not denied due to dry run mode: %v", req, err)
+		}
+		if err = innerCtx.Err(); err != nil {
+			contextErrCounter.Inc(getTokensStage)
+			return ctx, err
+		}
+	}
+
 	return ctx, nil
 }
 
@@ -211,13 +260,13 @@ func (tp *trillianProcessor) After(ctx context.Context, resp interface{}, method
 	if !enabledServices[serviceName(method)] {
 		return
 	}
-	_, spanEnd := spanFor(ctx, "After")
+	_, spanEnd := spanFor(ctx, "After", monitoring.StringAttribute(monitoring.RPCKey, method))
 	defer spanEnd()
 	switch {
 	case tp.info == nil:
 		glog.Warningf("After called with nil rpcInfo, resp = [%+v], handlerErr = [%v]", resp, handlerErr)
 		return
-	case tp.info.tokens == 0:
+	case tp.info.tokens == 0 && len(tp.info.mapCharges) == 0:
 		// After() currently only does quota processing
 		return
 	}
@@ -253,27 +302,39 @@ func (tp *trillianProcessor) After(ctx context.Context, resp interface{}, method
 		}
 	}
 	if len(tp.info.specs) > 0 && tokens > 0 {
-		// Run PutTokens in a separate goroutine and with a separate context.
-		// It shouldn't block RPC completion, nor should it share the RPC's context deadline.
-		go func() {
-			ctx, spanEnd := spanFor(context.Background(), "After.PutTokens")
-			defer spanEnd()
-			ctx, cancel := context.WithTimeout(ctx, PutTokensTimeout)
-			defer cancel()
-
-			// TODO(codingllama): If PutTokens turns out to be unreliable we can still leak tokens. In
-			// this case, we may want to keep tabs on how many tokens we failed to replenish and bundle
-			// them up in the next PutTokens call (possibly as a QuotaManager decorator, or internally
-			// in its impl).
-			err := tp.parent.qm.PutTokens(ctx, tokens, tp.info.specs)
-			if err != nil {
-				glog.Warningf("Failed to replenish %v tokens: %v", tokens, err)
-			}
-			quota.Metrics.IncReturned(tokens, tp.info.specs, err == nil)
-		}()
+		tp.putTokens(tokens, tp.info.specs)
+	}
+	if handlerErr != nil {
+		// Map-wide quotas are spent by the leaves read or written, so are only
+		// returned for requests which failed.
+		for _, c := range tp.info.mapCharges {
+			tp.putTokens(c.tokens, []quota.Spec{c.spec})
+		}
 	}
 }
 
+// putTokens returns tokens to specs.
+func (tp *trillianProcessor) putTokens(tokens int, specs []quota.Spec) {
+	// Run PutTokens in a separate goroutine and with a separate context.
+	// It shouldn't block RPC completion, nor should it share the RPC's context deadline.
+	go func() {
+		ctx, spanEnd := spanFor(context.Background(), "After.PutTokens")
+		defer spanEnd()
+		ctx, cancel := context.WithTimeout(ctx, PutTokensTimeout)
+		defer cancel()
+
+		// TODO(codingllama): If PutTokens turns out to be unreliable we can still leak tokens. In
+		// this case, we may want to keep tabs on how many tokens we failed to replenish and bundle
+		// them up in the next PutTokens call (possibly as a QuotaManager decorator, or internally
+		// in its impl).
+		err := tp.parent.qm.PutTokens(ctx, tokens, specs)
+		if err != nil {
+			glog.Warningf("Failed to replenish %v tokens: %v", tokens, err)
+		}
+		quota.Metrics.IncReturned(tokens, specs, err == nil)
+	}()
+}
+
 func isLeafOK(leaf *trillian.QueuedLogLeaf) bool {
 	// Be biased in favor of OK, as that matches TrillianLogRPCServer's behavior.
 	return leaf == nil || leaf.Status == nil || leaf.Status.Code == int32(codes.OK)
@@ -303,12 +364,28 @@ type rpcInfo struct {
 	treeID    int64
 	treeTypes []trillian.TreeType
 
+	// priority indicates that the request takes the priority lane: it spends
+	// no quota and is never shed by an OverloadBreaker, so that trees can be
+	// created and initialised while the server is saturated with bulk writes.
+	priority bool
+
 	specs  []quota.Spec
 	tokens int
+	// mapCharges are the map-wide quotas charged by map requests, in addition
+	// to tokens for each of specs.
+	mapCharges []mapCharge
 	// Single string describing all of the users against which quota is requested.
 	quotaUsers string
 }
 
+// mapCharge is a number of tokens charged against a map-wide quota. Quota
+// managers charge the same number of tokens for every spec, so each map-wide
+// quota is acquired separately.
+type mapCharge struct {
+	spec   quota.Spec
+	tokens int
+}
+
 // chargable is satisfied by request proto messages which contain a GetChargeTo
 // accessor.
 type chargable interface {
@@ -352,12 +429,16 @@ func newRPCInfoForRequest(req interface{}) (*rpcInfo, error) {
 		info.readonly = false // Doesn't really matter as all interceptors are turned off
 
 	// Admin create
-	case *trillian.CreateTreeRequest:
+	case *trillian.CreateTreeRequest,
+		*trillian.CreateTreesFromTemplateRequest,
+		*trillian.ImportTreesRequest:
 		info.getTree = false // Tree doesn't exist
 		info.readonly = false
+		info.priority = true
 
 	// Admin list
-	case *trillian.ListTreesRequest:
+	case *trillian.ExportTreesRequest,
+		*trillian.ListTreesRequest:
 		info.getTree = false // Zero to many trees
 
 	// Admin / readonly
@@ -366,13 +447,21 @@ func newRPCInfoForRequest(req interface{}) (*rpcInfo, error) {
 
 	// Admin / readwrite
 	case *trillian.DeleteTreeRequest,
+		*trillian.LiftQuarantineRequest,
+		*trillian.PauseSequencingRequest,
+		*trillian.PurgeTreeRequest,
+		*trillian.ResignLatestRootRequest,
+		*trillian.ResumeSequencingRequest,
+		*trillian.RotateTreeKeyRequest,
 		*trillian.UndeleteTreeRequest,
 		*trillian.UpdateTreeRequest:
 		info.getTree = false // Read-modify-write done within RPC handler
 		info.readonly = false
+		info.priority = true
 
 	// (Log + Pre-ordered Log) / readonly
 	case *trillian.GetConsistencyProofRequest,
+		*trillian.GetConsistencyProofByRootHashRequest,
 		*trillian.GetEntryAndProofRequest,
 		*trillian.GetInclusionProofByHashRequest,
 		*trillian.GetInclusionProofRequest,
@@ -382,6 +471,9 @@ func newRPCInfoForRequest(req interface{}) (*rpcInfo, error) {
 	case *trillian.GetLeavesByHashRequest:
 		info.treeTypes = []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG}
 		info.tokens = len(req.GetLeafHash())
+	case *trillian.GetInclusionProofByHashBatchRequest:
+		info.treeTypes = []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG}
+		info.tokens = len(req.GetLeafHash())
 	case *trillian.GetLeavesByIndexRequest:
 		info.treeTypes = []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG}
 		info.tokens = len(req.GetLeafIndex())
@@ -393,6 +485,14 @@ func newRPCInfoForRequest(req interface{}) (*rpcInfo, error) {
 		}
 	case *trillian.GetSequencedLeafCountRequest:
 		info.treeTypes = []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG}
+	case *trillian.GetCheckpointRequest,
+		*trillian.GetLogRootSignaturesRequest:
+		info.treeTypes = []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG}
+		info.tokens = 1
+	case *trillian.AddLogRootSignatureRequest:
+		// Witness signatures don't change the log, so frozen logs accept them.
+		info.treeTypes = []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG}
+		info.tokens = 1
 
 	// Log / readwrite
 	case *trillian.QueueLeafRequest:
@@ -418,7 +518,7 @@ func newRPCInfoForRequest(req interface{}) (*rpcInfo, error) {
 	case *trillian.InitLogRequest:
 		info.readonly = false
 		info.treeTypes = []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG}
-		info.tokens = 1
+		info.priority = true
 
 	// Map / readonly
 	case *trillian.GetMapLeafByRevisionRequest:
@@ -433,8 +533,24 @@ func newRPCInfoForRequest(req interface{}) (*rpcInfo, error) {
 	case *trillian.GetMapLeavesRequest:
 		info.treeTypes = []trillian.TreeType{trillian.TreeType_MAP}
 		info.tokens = len(req.GetIndex())
-	case *trillian.GetSignedMapRootByRevisionRequest,
-		*trillian.GetSignedMapRootRequest:
+	case *trillian.GetMapLeafHistoryRequest:
+		info.treeTypes = []trillian.TreeType{trillian.TreeType_MAP}
+		info.tokens = leafHistoryTokens(req)
+	case *trillian.GetMapConsistencyProofRequest:
+		info.treeTypes = []trillian.TreeType{trillian.TreeType_MAP}
+		info.tokens = len(req.GetIndex())
+	case *trillian.GetMapCheckpointRequest,
+		*trillian.GetMapHotKeysRequest,
+		*trillian.GetMapLeafByHashRequest,
+		*trillian.GetMapLeavesByIndexPrefixRequest,
+		*trillian.GetMapRootSignaturesRequest,
+		*trillian.GetSignedMapRootByRevisionRequest,
+		*trillian.GetSignedMapRootRequest,
+		*trillian.ListSignedMapRootsRequest:
+		info.treeTypes = []trillian.TreeType{trillian.TreeType_MAP}
+		info.tokens = 1
+	case *trillian.AddMapRootSignatureRequest:
+		// Witness signatures don't change the map, so frozen maps accept them.
 		info.treeTypes = []trillian.TreeType{trillian.TreeType_MAP}
 		info.tokens = 1
 
@@ -447,10 +563,25 @@ func newRPCInfoForRequest(req interface{}) (*rpcInfo, error) {
 		info.readonly = false
 		info.treeTypes = []trillian.TreeType{trillian.TreeType_MAP}
 		info.tokens = len(req.GetLeaves())
+	case *trillian.ImportMapRevisionRequest:
+		info.readonly = false
+		info.treeTypes = []trillian.TreeType{trillian.TreeType_MAP}
+		info.tokens = len(req.GetLeaves())
 	case *trillian.InitMapRequest:
+		info.readonly = false
+		info.treeTypes = []trillian.TreeType{trillian.TreeType_MAP}
+		info.priority = true
+	case *trillian.DeleteMapLeafRangeRequest,
+		*trillian.ReserveMapRevisionRequest:
 		info.readonly = false
 		info.treeTypes = []trillian.TreeType{trillian.TreeType_MAP}
 		info.tokens = 1
+	case *trillian.SetMultiMapLeavesRequest:
+		info.getTree = false // Several trees, read within RPC handler
+		info.readonly = false
+		for _, r := range req.GetRequests() {
+			info.tokens += len(r.GetLeaves())
+		}
 
 	default:
 		return nil, status.Errorf(codes.Internal, "newRPCInfo: unmapped request type: %T", req)
@@ -459,6 +590,21 @@ func newRPCInfoForRequest(req interface{}) (*rpcInfo, error) {
 	return info, nil
 }
 
+// requestTreeID returns the ID of the tree addressed by req.
+func requestTreeID(req interface{}) (int64, error) {
+	switch req := req.(type) {
+	case logIDRequest:
+		return req.GetLogId(), nil
+	case mapIDRequest:
+		return req.GetMapId(), nil
+	case treeIDRequest:
+		return req.GetTreeId(), nil
+	case treeRequest:
+		return req.GetTree().GetTreeId(), nil
+	}
+	return 0, status.Errorf(codes.Internal, "cannot retrieve treeID from request: %T", req)
+}
+
 func newRPCInfo(req interface{}) (*rpcInfo, error) {
 	info, err := newRPCInfoForRequest(req)
 	if err != nil {
@@ -466,17 +612,11 @@ func newRPCInfo(req interface{}) (*rpcInfo, error) {
 	}
 
 	if info.getTree || info.tokens > 0 {
-		switch req := req.(type) {
-		case logIDRequest:
-			info.treeID = req.GetLogId()
-		case mapIDRequest:
-			info.treeID = req.GetMapId()
-		case treeIDRequest:
-			info.treeID = req.GetTreeId()
-		case treeRequest:
-			info.treeID = req.GetTree().GetTreeId()
-		default:
-			return nil, status.Errorf(codes.Internal, "cannot retrieve treeID from request: %T", req)
+		if _, ok := req.(*trillian.SetMultiMapLeavesRequest); !ok {
+			// SetMultiMapLeaves is charged against each of the maps, see below.
+			if info.treeID, err = requestTreeID(req); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -493,15 +633,74 @@ func newRPCInfo(req interface{}) (*rpcInfo, error) {
 			}
 			info.quotaUsers += user
 		}
-		info.specs = append(info.specs, []quota.Spec{
-			{Group: quota.Tree, Kind: kind, TreeID: info.treeID},
-			{Group: quota.Global, Kind: kind},
-		}...)
+		if req, ok := req.(*trillian.SetMultiMapLeavesRequest); ok {
+			// Quota managers charge the same number of tokens for every spec, so
+			// each map is conservatively charged for all the leaves in the request.
+			for _, r := range req.GetRequests() {
+				info.specs = append(info.specs, quota.Spec{Group: quota.Tree, Kind: kind, TreeID: r.GetMapId()})
+			}
+		} else {
+			info.specs = append(info.specs, quota.Spec{Group: quota.Tree, Kind: kind, TreeID: info.treeID})
+		}
+		info.specs = append(info.specs, quota.Spec{Group: quota.Global, Kind: kind})
 	}
+	info.mapCharges = mapChargesFor(req, info.treeID)
 
 	return info, nil
 }
 
+// mapChargesFor returns the map-wide quotas charged by req, addressed to
+// treeID: a read_leaves token per leaf read, a write_leaves token per leaf
+// written and a create_revision token per revision created. Requests which
+// are not for maps, and dry runs, are not charged.
+func mapChargesFor(req interface{}, treeID int64) []mapCharge {
+	charge := func(treeID int64, kind quota.Kind, tokens int) mapCharge {
+		return mapCharge{spec: quota.Spec{Group: quota.Map, Kind: kind, TreeID: treeID}, tokens: tokens}
+	}
+	writeCharges := func(treeID int64, leaves int) []mapCharge {
+		charges := []mapCharge{charge(treeID, quota.CreateRevision, 1)}
+		if leaves > 0 {
+			charges = append([]mapCharge{charge(treeID, quota.WriteLeaves, leaves)}, charges...)
+		}
+		return charges
+	}
+
+	switch req := req.(type) {
+	case *trillian.GetMapLeafRequest,
+		*trillian.GetMapLeafByRevisionRequest:
+		return []mapCharge{charge(treeID, quota.ReadLeaves, 1)}
+	case *trillian.GetMapLeavesRequest:
+		if n := len(req.GetIndex()); n > 0 {
+			return []mapCharge{charge(treeID, quota.ReadLeaves, n)}
+		}
+	case *trillian.GetMapLeavesByRevisionRequest:
+		if n := len(req.GetIndex()); n > 0 {
+			return []mapCharge{charge(treeID, quota.ReadLeaves, n)}
+		}
+	case *trillian.GetMapLeafHistoryRequest:
+		return []mapCharge{charge(treeID, quota.ReadLeaves, leafHistoryTokens(req))}
+	case *trillian.SetMapLeavesRequest:
+		if !req.GetDryRun() {
+			return writeCharges(treeID, len(req.GetLeaves()))
+		}
+	case *trillian.WriteMapLeavesRequest:
+		if !req.GetDryRun() {
+			return writeCharges(treeID, len(req.GetLeaves()))
+		}
+	case *trillian.ImportMapRevisionRequest:
+		return writeCharges(treeID, len(req.GetLeaves()))
+	case *trillian.DeleteMapLeafRangeRequest:
+		return writeCharges(treeID, 0)
+	case *trillian.SetMultiMapLeavesRequest:
+		var charges []mapCharge
+		for _, r := range req.GetRequests() {
+			charges = append(charges, writeCharges(r.GetMapId(), len(r.GetLeaves()))...)
+		}
+		return charges
+	}
+	return nil
+}
+
 type logIDRequest interface {
 	GetLogId() int64
 }
@@ -526,6 +725,19 @@ func ErrorWrapper(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
 	return rsp, errors.WrapError(err)
 }
 
-func spanFor(ctx context.Context, name string) (context.Context, func()) {
-	return monitoring.StartSpan(ctx, fmt.Sprintf("%s.%s", traceSpanRoot, name))
+func spanFor(ctx context.Context, name string, attrs ...monitoring.Attribute) (context.Context, func()) {
+	return monitoring.StartSpan(ctx, fmt.Sprintf("%s.%s", traceSpanRoot, name), attrs...)
+}
+
+// leafHistoryTokens returns the number of tokens charged for req: one per
+// revision read, capped at the maximum the map server will return.
+func leafHistoryTokens(req *trillian.GetMapLeafHistoryRequest) int {
+	n := int(req.GetCount())
+	if n <= 0 {
+		return 1
+	}
+	if n > maxLeafHistoryCount {
+		return maxLeafHistoryCount
+	}
+	return n
 }
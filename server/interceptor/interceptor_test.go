@@ -17,6 +17,7 @@ package interceptor
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
@@ -27,6 +28,7 @@ import (
 	"github.com/google/trillian/quota"
 	"github.com/google/trillian/quota/etcd/quotapb"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/querytag"
 	"github.com/google/trillian/storage/testonly"
 	"github.com/google/trillian/trees"
 	"github.com/kylelemons/godebug/pretty"
@@ -95,6 +97,31 @@ func TestTrillianInterceptor_TreeInterception(t *testing.T) {
 			method: "/trillian.TrillianAdmin/UpdateTree",
 			req:    &trillian.UpdateTreeRequest{Tree: &trillian.Tree{TreeId: logTree.TreeId}},
 		},
+		{
+			desc:   "adminLiftQuarantine",
+			method: "/trillian.TrillianAdmin/LiftQuarantine",
+			req:    &trillian.LiftQuarantineRequest{TreeId: logTree.TreeId},
+		},
+		{
+			desc:   "adminPauseSequencing",
+			method: "/trillian.TrillianAdmin/PauseSequencing",
+			req:    &trillian.PauseSequencingRequest{TreeId: logTree.TreeId},
+		},
+		{
+			desc:   "adminPurgeTree",
+			method: "/trillian.TrillianAdmin/PurgeTree",
+			req:    &trillian.PurgeTreeRequest{TreeId: logTree.TreeId},
+		},
+		{
+			desc:   "adminRotateTreeKey",
+			method: "/trillian.TrillianAdmin/RotateTreeKey",
+			req:    &trillian.RotateTreeKeyRequest{TreeId: logTree.TreeId},
+		},
+		{
+			desc:   "adminResignLatestRoot",
+			method: "/trillian.TrillianAdmin/ResignLatestRoot",
+			req:    &trillian.ResignLatestRootRequest{TreeId: logTree.TreeId},
+		},
 		{
 			desc:     "logRPC",
 			method:   "/trillian.TrillianLog/GetLatestSignedLogRoot",
@@ -185,6 +212,10 @@ func TestTrillianInterceptor_TreeInterception(t *testing.T) {
 					diff := pretty.Compare(tree, test.wantTree)
 					t.Errorf("post-FromContext diff:\n%v", diff)
 				}
+				want := querytag.Tags{TreeID: test.wantTree.TreeId, RPC: test.method, Revision: -1}
+				if got := querytag.FromContext(handler.ctx); got != want {
+					t.Errorf("querytag.FromContext()=%+v, want %+v", got, want)
+				}
 			}
 		})
 	}
@@ -210,6 +241,7 @@ func TestTrillianInterceptor_QuotaInterception(t *testing.T) {
 		method       string
 		req          interface{}
 		specs        []quota.Spec
+		mapCharges   []mapCharge
 		getTokensErr error
 		wantCode     codes.Code
 		wantTokens   int
@@ -234,6 +266,16 @@ func TestTrillianInterceptor_QuotaInterception(t *testing.T) {
 			},
 			wantTokens: 3,
 		},
+		{
+			desc:   "logReadProofBatch",
+			method: "/trillian.TrillianLog/GetInclusionProofByHashBatch",
+			req:    &trillian.GetInclusionProofByHashBatchRequest{LogId: logTree.TreeId, LeafHash: [][]byte{{1}, {2}}},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Read, TreeID: logTree.TreeId},
+				{Group: quota.Global, Kind: quota.Read},
+			},
+			wantTokens: 2,
+		},
 		{
 			desc:   "logReadRange",
 			method: "/trillian.TrillianLog/GetLeavesByRange",
@@ -306,8 +348,127 @@ func TestTrillianInterceptor_QuotaInterception(t *testing.T) {
 				{Group: quota.Tree, Kind: quota.Read, TreeID: mapTree.TreeId},
 				{Group: quota.Global, Kind: quota.Read},
 			},
+			mapCharges: []mapCharge{
+				{spec: quota.Spec{Group: quota.Map, Kind: quota.ReadLeaves, TreeID: mapTree.TreeId}, tokens: 2},
+			},
 			wantTokens: 2,
 		},
+		{
+			desc:   "mapLeafHistory",
+			method: "/trillian.TrillianMap/GetLeafHistory",
+			req:    &trillian.GetMapLeafHistoryRequest{MapId: mapTree.TreeId, Index: []byte{0x01}, Count: 10},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Read, TreeID: mapTree.TreeId},
+				{Group: quota.Global, Kind: quota.Read},
+			},
+			mapCharges: []mapCharge{
+				{spec: quota.Spec{Group: quota.Map, Kind: quota.ReadLeaves, TreeID: mapTree.TreeId}, tokens: 10},
+			},
+			wantTokens: 10,
+		},
+		{
+			desc:   "mapLeafHistoryCapped",
+			method: "/trillian.TrillianMap/GetLeafHistory",
+			req:    &trillian.GetMapLeafHistoryRequest{MapId: mapTree.TreeId, Index: []byte{0x01}, Count: 1 << 30},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Read, TreeID: mapTree.TreeId},
+				{Group: quota.Global, Kind: quota.Read},
+			},
+			mapCharges: []mapCharge{
+				{spec: quota.Spec{Group: quota.Map, Kind: quota.ReadLeaves, TreeID: mapTree.TreeId}, tokens: maxLeafHistoryCount},
+			},
+			wantTokens: maxLeafHistoryCount,
+		},
+		{
+			desc:   "mapLeafByHash",
+			method: "/trillian.TrillianMap/GetLeafByHash",
+			req:    &trillian.GetMapLeafByHashRequest{MapId: mapTree.TreeId, LeafHash: []byte{0x01}},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Read, TreeID: mapTree.TreeId},
+				{Group: quota.Global, Kind: quota.Read},
+			},
+			wantTokens: 1,
+		},
+		{
+			desc:   "mapHotKeys",
+			method: "/trillian.TrillianMapWrite/GetHotKeys",
+			req:    &trillian.GetMapHotKeysRequest{MapId: mapTree.TreeId, Limit: 5},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Read, TreeID: mapTree.TreeId},
+				{Group: quota.Global, Kind: quota.Read},
+			},
+			wantTokens: 1,
+		},
+		{
+			desc:   "mapLeavesByIndexPrefix",
+			method: "/trillian.TrillianMap/GetLeavesByIndexPrefix",
+			req:    &trillian.GetMapLeavesByIndexPrefixRequest{MapId: mapTree.TreeId, IndexPrefix: []byte{0x01}, Revision: 2},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Read, TreeID: mapTree.TreeId},
+				{Group: quota.Global, Kind: quota.Read},
+			},
+			wantTokens: 1,
+		},
+		{
+			desc:   "mapConsistencyProof",
+			method: "/trillian.TrillianMap/GetConsistencyProof",
+			req:    &trillian.GetMapConsistencyProofRequest{MapId: mapTree.TreeId, SecondRevision: 2, Index: [][]byte{{0x01}, {0x02}, {0x03}}},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Read, TreeID: mapTree.TreeId},
+				{Group: quota.Global, Kind: quota.Read},
+			},
+			wantTokens: 3,
+		},
+		{
+			desc:   "mapListRoots",
+			method: "/trillian.TrillianMap/ListSignedMapRoots",
+			req:    &trillian.ListSignedMapRootsRequest{MapId: mapTree.TreeId, PageSize: 10},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Read, TreeID: mapTree.TreeId},
+				{Group: quota.Global, Kind: quota.Read},
+			},
+			wantTokens: 1,
+		},
+		{
+			desc:   "logCheckpoint",
+			method: "/trillian.TrillianLog/GetCheckpoint",
+			req:    &trillian.GetCheckpointRequest{LogId: logTree.TreeId},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Read, TreeID: logTree.TreeId},
+				{Group: quota.Global, Kind: quota.Read},
+			},
+			wantTokens: 1,
+		},
+		{
+			desc:   "logConsistencyProofByRootHash",
+			method: "/trillian.TrillianLog/GetConsistencyProofByRootHash",
+			req:    &trillian.GetConsistencyProofByRootHashRequest{LogId: logTree.TreeId},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Read, TreeID: logTree.TreeId},
+				{Group: quota.Global, Kind: quota.Read},
+			},
+			wantTokens: 1,
+		},
+		{
+			desc:   "logAddRootSignature",
+			method: "/trillian.TrillianLog/AddLogRootSignature",
+			req:    &trillian.AddLogRootSignatureRequest{LogId: logTree.TreeId, Revision: 1},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Read, TreeID: logTree.TreeId},
+				{Group: quota.Global, Kind: quota.Read},
+			},
+			wantTokens: 1,
+		},
+		{
+			desc:   "mapAddRootSignature",
+			method: "/trillian.TrillianMap/AddMapRootSignature",
+			req:    &trillian.AddMapRootSignatureRequest{MapId: mapTree.TreeId, Revision: 1},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Read, TreeID: mapTree.TreeId},
+				{Group: quota.Global, Kind: quota.Read},
+			},
+			wantTokens: 1,
+		},
 		{
 			desc:   "emptyBatchRequest",
 			method: "/trillian.TrillianLog/QueueLeaves",
@@ -369,6 +530,10 @@ func TestTrillianInterceptor_QuotaInterception(t *testing.T) {
 				{Group: quota.Tree, Kind: quota.Write, TreeID: mapTree.TreeId},
 				{Group: quota.Global, Kind: quota.Write},
 			},
+			mapCharges: []mapCharge{
+				{spec: quota.Spec{Group: quota.Map, Kind: quota.WriteLeaves, TreeID: mapTree.TreeId}, tokens: 5},
+				{spec: quota.Spec{Group: quota.Map, Kind: quota.CreateRevision, TreeID: mapTree.TreeId}, tokens: 1},
+			},
 			wantTokens: 5,
 		},
 		{
@@ -382,8 +547,84 @@ func TestTrillianInterceptor_QuotaInterception(t *testing.T) {
 				{Group: quota.Tree, Kind: quota.Write, TreeID: mapTree.TreeId},
 				{Group: quota.Global, Kind: quota.Write},
 			},
+			mapCharges: []mapCharge{
+				{spec: quota.Spec{Group: quota.Map, Kind: quota.WriteLeaves, TreeID: mapTree.TreeId}, tokens: 5},
+				{spec: quota.Spec{Group: quota.Map, Kind: quota.CreateRevision, TreeID: mapTree.TreeId}, tokens: 1},
+			},
 			wantTokens: 5,
 		},
+		{
+			desc:   "importMapRevisionRequest",
+			method: "/trillian.TrillianMap/ImportMapRevision",
+			req: &trillian.ImportMapRevisionRequest{
+				MapId:  mapTree.TreeId,
+				Leaves: []*trillian.MapLeaf{{}, {}},
+			},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Write, TreeID: mapTree.TreeId},
+				{Group: quota.Global, Kind: quota.Write},
+			},
+			mapCharges: []mapCharge{
+				{spec: quota.Spec{Group: quota.Map, Kind: quota.WriteLeaves, TreeID: mapTree.TreeId}, tokens: 2},
+				{spec: quota.Spec{Group: quota.Map, Kind: quota.CreateRevision, TreeID: mapTree.TreeId}, tokens: 1},
+			},
+			wantTokens: 2,
+		},
+		{
+			desc:   "deleteMapLeafRangeRequest",
+			method: "/trillian.TrillianMapWrite/DeleteLeafRange",
+			req:    &trillian.DeleteMapLeafRangeRequest{MapId: mapTree.TreeId, IndexPrefix: []byte{1}},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Write, TreeID: mapTree.TreeId},
+				{Group: quota.Global, Kind: quota.Write},
+			},
+			mapCharges: []mapCharge{
+				{spec: quota.Spec{Group: quota.Map, Kind: quota.CreateRevision, TreeID: mapTree.TreeId}, tokens: 1},
+			},
+			wantTokens: 1,
+		},
+		{
+			desc:   "reserveMapRevisionRequest",
+			method: "/trillian.TrillianMapWrite/ReserveRevision",
+			req:    &trillian.ReserveMapRevisionRequest{MapId: mapTree.TreeId},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Write, TreeID: mapTree.TreeId},
+				{Group: quota.Global, Kind: quota.Write},
+			},
+			wantTokens: 1,
+		},
+		{
+			desc:   "initLogRequest",
+			method: "/trillian.TrillianLog/InitLog",
+			req:    &trillian.InitLogRequest{LogId: logTree.TreeId},
+		},
+		{
+			desc:   "initMapRequest",
+			method: "/trillian.TrillianMap/InitMap",
+			req:    &trillian.InitMapRequest{MapId: mapTree.TreeId},
+		},
+		{
+			desc:   "multiMapLeavesRequest",
+			method: "/trillian.TrillianMap/SetMultiMapLeaves",
+			req: &trillian.SetMultiMapLeavesRequest{
+				Requests: []*trillian.SetMapLeavesRequest{
+					{MapId: mapTree.TreeId, Leaves: []*trillian.MapLeaf{{}, {}}},
+					{MapId: mapTree.TreeId + 100, Leaves: []*trillian.MapLeaf{{}}},
+				},
+			},
+			specs: []quota.Spec{
+				{Group: quota.Tree, Kind: quota.Write, TreeID: mapTree.TreeId},
+				{Group: quota.Tree, Kind: quota.Write, TreeID: mapTree.TreeId + 100},
+				{Group: quota.Global, Kind: quota.Write},
+			},
+			mapCharges: []mapCharge{
+				{spec: quota.Spec{Group: quota.Map, Kind: quota.WriteLeaves, TreeID: mapTree.TreeId}, tokens: 2},
+				{spec: quota.Spec{Group: quota.Map, Kind: quota.CreateRevision, TreeID: mapTree.TreeId}, tokens: 1},
+				{spec: quota.Spec{Group: quota.Map, Kind: quota.WriteLeaves, TreeID: mapTree.TreeId + 100}, tokens: 1},
+				{spec: quota.Spec{Group: quota.Map, Kind: quota.CreateRevision, TreeID: mapTree.TreeId + 100}, tokens: 1},
+			},
+			wantTokens: 3,
+		},
 		{
 			desc:   "quotaError",
 			method: "/trillian.TrillianLog/GetLatestSignedLogRoot",
@@ -428,6 +669,9 @@ func TestTrillianInterceptor_QuotaInterception(t *testing.T) {
 			if test.wantTokens > 0 {
 				qm.EXPECT().GetTokens(gomock.Any(), test.wantTokens, test.specs).Return(test.getTokensErr)
 			}
+			for _, c := range test.mapCharges {
+				qm.EXPECT().GetTokens(gomock.Any(), c.tokens, []quota.Spec{c.spec}).Return(nil)
+			}
 
 			handler := &fakeHandler{resp: "ok"}
 			intercept := New(admin, qm, test.dryRun, nil /* mf */)
@@ -608,6 +852,138 @@ func TestTrillianInterceptor_QuotaInterception_ReturnsTokens(t *testing.T) {
 	}
 }
 
+func TestTrillianInterceptor_MapQuota(t *testing.T) {
+	mapTree := proto.Clone(testonly.MapTree).(*trillian.Tree)
+	mapTree.TreeId = 11
+
+	req := &trillian.SetMapLeavesRequest{MapId: mapTree.TreeId, Leaves: []*trillian.MapLeaf{{}, {}, {}}}
+	specs := []quota.Spec{
+		{Group: quota.Tree, Kind: quota.Write, TreeID: mapTree.TreeId},
+		{Group: quota.Global, Kind: quota.Write},
+	}
+	writeLeaves := []quota.Spec{{Group: quota.Map, Kind: quota.WriteLeaves, TreeID: mapTree.TreeId}}
+	createRevision := []quota.Spec{{Group: quota.Map, Kind: quota.CreateRevision, TreeID: mapTree.TreeId}}
+
+	tests := []struct {
+		desc          string
+		dryRun        bool
+		revisionErr   error
+		handlerErr    error
+		wantCode      codes.Code
+		wantPutTokens bool
+	}{
+		{desc: "ok"},
+		{desc: "revisionsExhausted", revisionErr: errors.New("not enough tokens"), wantCode: codes.ResourceExhausted, wantPutTokens: true},
+		{desc: "revisionsExhaustedDryRun", dryRun: true, revisionErr: errors.New("not enough tokens")},
+		{desc: "badRequest", handlerErr: status.Error(codes.InvalidArgument, "bad request"), wantCode: codes.InvalidArgument, wantPutTokens: true},
+	}
+
+	defer func(timeout time.Duration) {
+		PutTokensTimeout = timeout
+	}(PutTokensTimeout)
+	PutTokensTimeout = 5 * time.Second
+
+	ctx := context.Background()
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			admin := storage.NewMockAdminStorage(ctrl)
+			adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+			admin.EXPECT().Snapshot(gomock.Any()).AnyTimes().Return(adminTX, nil)
+			adminTX.EXPECT().GetTree(gomock.Any(), mapTree.TreeId).AnyTimes().Return(mapTree, nil)
+			adminTX.EXPECT().Close().AnyTimes().Return(nil)
+			adminTX.EXPECT().Commit().AnyTimes().Return(nil)
+
+			qm := quota.NewMockManager(ctrl)
+			qm.EXPECT().GetTokens(gomock.Any(), 3, specs).Return(nil)
+			qm.EXPECT().GetTokens(gomock.Any(), 3, writeLeaves).Return(nil)
+			qm.EXPECT().GetTokens(gomock.Any(), 1, createRevision).Return(test.revisionErr)
+			var wg sync.WaitGroup
+			if test.wantPutTokens {
+				wg.Add(2)
+				put := func(context.Context, int, []quota.Spec) { wg.Done() }
+				qm.EXPECT().PutTokens(gomock.Any(), 3, specs).Do(put).Return(nil)
+				qm.EXPECT().PutTokens(gomock.Any(), 3, writeLeaves).Do(put).Return(nil)
+				if test.handlerErr != nil {
+					wg.Add(1)
+					qm.EXPECT().PutTokens(gomock.Any(), 1, createRevision).Do(put).Return(nil)
+				}
+			}
+
+			handler := &fakeHandler{resp: &trillian.SetMapLeavesResponse{}, err: test.handlerErr}
+			intercept := New(admin, qm, test.dryRun, nil /* mf */)
+			_, err := intercept.UnaryInterceptor(ctx, req,
+				&grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianMap/SetLeaves"},
+				handler.run)
+			if got := status.Code(err); got != test.wantCode {
+				t.Errorf("UnaryInterceptor() returned err = %v, wantCode = %v", err, test.wantCode)
+			}
+			// PutTokens is delegated to separate goroutines.
+			wg.Wait()
+		})
+	}
+}
+
+func TestTrillianInterceptor_TreeQuota(t *testing.T) {
+	logTree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	logTree.TreeId = 12
+	logTree.Quota = &trillian.TreeQuota{ReadTokensPerSecond: 2, WriteTokensPerSecond: 1}
+
+	read := &trillian.GetLatestSignedLogRootRequest{LogId: logTree.TreeId}
+	write := &trillian.QueueLeafRequest{LogId: logTree.TreeId}
+	tests := []struct {
+		desc     string
+		dryRun   bool
+		reqs     []interface{}
+		wantCode []codes.Code
+	}{
+		{
+			desc:     "read",
+			reqs:     []interface{}{read, read, read},
+			wantCode: []codes.Code{codes.OK, codes.OK, codes.ResourceExhausted},
+		},
+		{
+			desc:     "write",
+			reqs:     []interface{}{write, read, write},
+			wantCode: []codes.Code{codes.OK, codes.OK, codes.ResourceExhausted},
+		},
+		{
+			desc:     "dryRun",
+			dryRun:   true,
+			reqs:     []interface{}{write, write},
+			wantCode: []codes.Code{codes.OK, codes.OK},
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			admin := storage.NewMockAdminStorage(ctrl)
+			adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+			admin.EXPECT().Snapshot(gomock.Any()).AnyTimes().Return(adminTX, nil)
+			adminTX.EXPECT().GetTree(gomock.Any(), logTree.TreeId).AnyTimes().Return(logTree, nil)
+			adminTX.EXPECT().Close().AnyTimes().Return(nil)
+			adminTX.EXPECT().Commit().AnyTimes().Return(nil)
+
+			intercept := New(admin, quota.Noop(), test.dryRun, nil /* mf */)
+			for i, req := range test.reqs {
+				method := "/trillian.TrillianLog/GetLatestSignedLogRoot"
+				if req == write {
+					method = "/trillian.TrillianLog/QueueLeaf"
+				}
+				handler := &fakeHandler{}
+				_, err := intercept.UnaryInterceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, handler.run)
+				if got, want := status.Code(err), test.wantCode[i]; got != want {
+					t.Errorf("UnaryInterceptor(%v) returned err = %v, wantCode = %v", method, err, want)
+				}
+			}
+		})
+	}
+}
+
 func TestTrillianInterceptor_NotIntercepted(t *testing.T) {
 	tests := []struct {
 		method string
@@ -615,6 +991,9 @@ func TestTrillianInterceptor_NotIntercepted(t *testing.T) {
 	}{
 		// Admin
 		{method: "/trillian.TrillianAdmin/CreateTree", req: &trillian.CreateTreeRequest{}},
+		{method: "/trillian.TrillianAdmin/CreateTreesFromTemplate", req: &trillian.CreateTreesFromTemplateRequest{}},
+		{method: "/trillian.TrillianAdmin/ExportTrees", req: &trillian.ExportTreesRequest{}},
+		{method: "/trillian.TrillianAdmin/ImportTrees", req: &trillian.ImportTreesRequest{}},
 		{method: "/trillian.TrillianAdmin/ListTrees", req: &trillian.ListTreesRequest{}},
 		// Quota
 		{method: "/quotapb.Quota/CreateConfig", req: &quotapb.CreateConfigRequest{}},
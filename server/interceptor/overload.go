@@ -0,0 +1,216 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/server/errmsg"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Reasons for shedding requests, recorded by the interceptor_overload_shed_count
+// metric.
+const (
+	inFlightReason    = "in_flight"
+	circuitOpenReason = "circuit_open"
+)
+
+// OverloadLimits configures the detection of overloaded trees by an
+// OverloadBreaker. Zero values disable the corresponding limit.
+type OverloadLimits struct {
+	// MaxInFlight is the maximum number of requests for a single tree which
+	// are handled concurrently.
+	MaxInFlight int
+	// TimeoutRate is the fraction of the requests for a tree in Window which
+	// may time out before the breaker of the tree opens, e.g. 0.5.
+	TimeoutRate float64
+	// MinRequests is the number of requests for a tree in Window below which
+	// its timeout rate is not considered.
+	MinRequests int
+	// Window is the period over which timeout rates are measured.
+	Window time.Duration
+	// Cooldown is the period for which the requests for a tree are shed once
+	// its breaker opens. A single probe request is then let through, which
+	// closes the breaker if it does not time out, or opens it again if it does.
+	Cooldown time.Duration
+}
+
+// Enabled returns whether any limit is set.
+func (l OverloadLimits) Enabled() bool {
+	return l.MaxInFlight > 0 || l.TimeoutRate > 0
+}
+
+// OverloadBreaker sheds the requests for trees which are overloaded, with
+// ResourceExhausted errors, so that a tree whose requests cause storage
+// timeouts does not take down every tree served by the same process. A tree is
+// overloaded when it has too many requests in flight, or when too many of its
+// recent requests timed out, in which case its circuit breaker opens for a
+// cooldown period.
+//
+// Only requests for a single tree are considered: admin requests and
+// SetMultiMapLeaves are never shed.
+type OverloadBreaker struct {
+	limits OverloadLimits
+	ts     clock.TimeSource
+
+	mu    sync.Mutex
+	trees map[int64]*treeLoad
+
+	shedCounter monitoring.Counter
+	tripCounter monitoring.Counter
+}
+
+// treeLoad is the load of a tree, as seen by an OverloadBreaker.
+type treeLoad struct {
+	inFlight int
+
+	// Requests completed, and those which timed out, since windowStart.
+	windowStart time.Time
+	requests    int
+	timeouts    int
+
+	// openUntil is the end of the cooldown period of an open breaker, or zero
+	// if the breaker is closed.
+	openUntil time.Time
+	// probing is set while the probe request of an open breaker is in flight.
+	probing bool
+}
+
+// NewOverloadBreaker returns an OverloadBreaker enforcing limits.
+func NewOverloadBreaker(limits OverloadLimits, ts clock.TimeSource, mf monitoring.MetricFactory) *OverloadBreaker {
+	if mf == nil {
+		mf = monitoring.InertMetricFactory{}
+	}
+	return &OverloadBreaker{
+		limits: limits,
+		ts:     ts,
+		trees:  make(map[int64]*treeLoad),
+		shedCounter: mf.NewCounter(
+			"interceptor_overload_shed_count",
+			"Number of requests shed because their tree is overloaded, by reason",
+			"reason", monitoring.TreeIDLabel),
+		tripCounter: mf.NewCounter(
+			"interceptor_overload_trip_count",
+			"Number of times the circuit breaker of a tree opened",
+			monitoring.TreeIDLabel),
+	}
+}
+
+// UnaryInterceptor executes the OverloadBreaker logic for unary RPCs.
+func (b *OverloadBreaker) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !enabledServices[serviceName(info.FullMethod)] {
+		return handler(ctx, req)
+	}
+	// Requests which can't be mapped to a tree are rejected by the
+	// TrillianInterceptor. Priority requests are never shed.
+	rpc, err := newRPCInfo(req)
+	if err != nil || rpc.treeID == 0 || rpc.priority {
+		return handler(ctx, req)
+	}
+
+	probe, err := b.acquire(rpc.treeID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := handler(ctx, req)
+	b.release(rpc.treeID, probe, err)
+	return resp, err
+}
+
+// acquire admits a request for treeID, or returns an error if the tree is
+// overloaded. It returns whether the request is the probe of an open breaker.
+func (b *OverloadBreaker) acquire(treeID int64) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l := b.trees[treeID]
+	if l == nil {
+		l = &treeLoad{windowStart: b.ts.Now()}
+		b.trees[treeID] = l
+	}
+
+	probe := false
+	if !l.openUntil.IsZero() {
+		if b.ts.Now().Before(l.openUntil) || l.probing {
+			return false, b.shed(treeID, circuitOpenReason)
+		}
+		l.probing = true
+		probe = true
+	} else if b.limits.MaxInFlight > 0 && l.inFlight >= b.limits.MaxInFlight {
+		return false, b.shed(treeID, inFlightReason)
+	}
+	l.inFlight++
+	return probe, nil
+}
+
+// release records the completion of a request for treeID admitted by
+// acquire, which returned err.
+func (b *OverloadBreaker) release(treeID int64, probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l := b.trees[treeID]
+	l.inFlight--
+	now := b.ts.Now()
+	timedOut := isTimeout(err)
+
+	switch {
+	case probe:
+		l.probing = false
+		if timedOut {
+			l.openUntil = now.Add(b.limits.Cooldown)
+			return
+		}
+		glog.Infof("%v: circuit breaker closed", treeID)
+		l.openUntil = time.Time{}
+		l.windowStart, l.requests, l.timeouts = now, 0, 0
+		return
+	case !l.openUntil.IsZero():
+		// Requests admitted before the breaker opened don't count.
+		return
+	}
+
+	if now.Sub(l.windowStart) >= b.limits.Window {
+		l.windowStart, l.requests, l.timeouts = now, 0, 0
+	}
+	l.requests++
+	if timedOut {
+		l.timeouts++
+	}
+	if b.limits.TimeoutRate > 0 && l.requests >= b.limits.MinRequests &&
+		float64(l.timeouts) >= b.limits.TimeoutRate*float64(l.requests) {
+		glog.Warningf("%v: circuit breaker opened for %v: %d of %d requests timed out", treeID, b.limits.Cooldown, l.timeouts, l.requests)
+		b.tripCounter.Inc(fmt.Sprint(treeID))
+		l.openUntil = now.Add(b.limits.Cooldown)
+	}
+}
+
+func (b *OverloadBreaker) shed(treeID int64, reason string) error {
+	b.shedCounter.Inc(reason, fmt.Sprint(treeID))
+	return errmsg.New(codes.ResourceExhausted, errmsg.TreeOverloaded, errmsg.Params{"tree_id": treeID})
+}
+
+// isTimeout returns whether err is caused by an expired deadline, e.g. of a
+// storage query.
+func isTimeout(err error) bool {
+	return err == context.DeadlineExceeded || status.Code(err) == codes.DeadlineExceeded
+}
@@ -0,0 +1,134 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/server/errmsg"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var getRootInfo = &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianMap/GetSignedMapRoot"}
+
+func getRootRequest(mapID int64) *trillian.GetSignedMapRootRequest {
+	return &trillian.GetSignedMapRootRequest{MapId: mapID}
+}
+
+// checkShed checks whether err is the error of a shed request.
+func checkShed(t *testing.T, err error, wantShed bool) {
+	t.Helper()
+	info := errmsg.Info(err)
+	shed := status.Code(err) == codes.ResourceExhausted && info != nil && info.Reason == string(errmsg.TreeOverloaded)
+	if shed != wantShed {
+		t.Errorf("UnaryInterceptor(): %v, want shed %v", err, wantShed)
+	}
+}
+
+func TestOverloadBreaker_InFlight(t *testing.T) {
+	ctx := context.Background()
+	b := NewOverloadBreaker(OverloadLimits{MaxInFlight: 2}, clock.System, nil /* mf */)
+
+	// Hold two requests for map 1 in flight.
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := b.UnaryInterceptor(ctx, getRootRequest(1), getRootInfo, func(context.Context, interface{}) (interface{}, error) {
+				started <- struct{}{}
+				<-release
+				return nil, nil
+			})
+			done <- err
+		}()
+		<-started
+	}
+
+	noop := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+	_, err := b.UnaryInterceptor(ctx, getRootRequest(1), getRootInfo, noop)
+	checkShed(t, err, true)
+	// Other trees, and requests which are not for a single tree, are not
+	// affected.
+	_, err = b.UnaryInterceptor(ctx, getRootRequest(2), getRootInfo, noop)
+	checkShed(t, err, false)
+	_, err = b.UnaryInterceptor(ctx, &trillian.GetTreeRequest{TreeId: 1}, &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianAdmin/GetTree"}, noop)
+	checkShed(t, err, false)
+	// Neither are priority requests for the tree.
+	_, err = b.UnaryInterceptor(ctx, &trillian.InitMapRequest{MapId: 1}, &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianMap/InitMap"}, noop)
+	checkShed(t, err, false)
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("UnaryInterceptor(): %v", err)
+		}
+	}
+	_, err = b.UnaryInterceptor(ctx, getRootRequest(1), getRootInfo, noop)
+	checkShed(t, err, false)
+}
+
+func TestOverloadBreaker_Timeouts(t *testing.T) {
+	ctx := context.Background()
+	ts := clock.NewFake(time.Unix(1000, 0))
+	b := NewOverloadBreaker(OverloadLimits{
+		TimeoutRate: 0.5,
+		MinRequests: 4,
+		Window:      10 * time.Second,
+		Cooldown:    5 * time.Second,
+	}, ts, nil /* mf */)
+
+	ok := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+	timeout := func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.DeadlineExceeded, "storage timeout")
+	}
+	call := func(mapID int64, handler grpc.UnaryHandler) error {
+		_, err := b.UnaryInterceptor(ctx, getRootRequest(mapID), getRootInfo, handler)
+		return err
+	}
+
+	// Timeouts in a previous window don't count.
+	for i := 0; i < 3; i++ {
+		call(1, timeout)
+	}
+	ts.Set(ts.Now().Add(10 * time.Second))
+	call(1, timeout)
+	call(1, ok)
+	checkShed(t, call(1, ok), false)
+
+	// Two of the four requests of the window have timed out, so the breaker
+	// opens, for map 1 only.
+	checkShed(t, call(1, timeout), false)
+	checkShed(t, call(1, ok), true)
+	checkShed(t, call(2, ok), false)
+
+	// After the cooldown, a failing probe opens the breaker again.
+	ts.Set(ts.Now().Add(5 * time.Second))
+	checkShed(t, call(1, timeout), false)
+	checkShed(t, call(1, ok), true)
+
+	// A successful probe closes it.
+	ts.Set(ts.Now().Add(5 * time.Second))
+	checkShed(t, call(1, ok), false)
+	for i := 0; i < 3; i++ {
+		checkShed(t, call(1, ok), false)
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"context"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/trees"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Reasons for denying requests to trees, recorded by the
+// interceptor_request_denied_count metric.
+const (
+	unauthenticatedReason = "unauthenticated"
+	notOwnerReason        = "not_owner"
+)
+
+// PrincipalFunc returns the authenticated principal which sent the request
+// of ctx.
+type PrincipalFunc func(ctx context.Context) (string, error)
+
+// TLSPrincipal is a PrincipalFunc which returns the subject common name of the
+// verified client certificate of the request, for servers which require client
+// certificates.
+func TLSPrincipal(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "no peer in context")
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", status.Error(codes.Unauthenticated, "no verified client certificate")
+	}
+	name := info.State.VerifiedChains[0][0].Subject.CommonName
+	if name == "" {
+		return "", status.Error(codes.Unauthenticated, "client certificate has no common name")
+	}
+	return name, nil
+}
+
+// TreeOwnership restricts requests to the trees owned by the principal which
+// sends them, so that a single Trillian server can isolate several tenants:
+// * Requests addressing trees fail with PermissionDenied unless the principal
+//   owns all of them;
+// * Trees created without an owner are owned by the principal which creates
+//   them, and only their owner can create trees owned by a principal; and
+// * ListTrees and ExportTrees only return the trees of the principal.
+//
+// Superusers, e.g. operators, may access all trees and change their owners.
+type TreeOwnership struct {
+	admin      storage.AdminStorage
+	principal  PrincipalFunc
+	superusers map[string]bool
+}
+
+// NewTreeOwnership returns a TreeOwnership which reads trees from admin, and
+// authenticates principals with principal.
+func NewTreeOwnership(admin storage.AdminStorage, principal PrincipalFunc, superusers []string, mf monitoring.MetricFactory) *TreeOwnership {
+	metricsOnce.Do(func() { initMetrics(mf) })
+	o := &TreeOwnership{admin: admin, principal: principal, superusers: make(map[string]bool)}
+	for _, s := range superusers {
+		o.superusers[s] = true
+	}
+	return o
+}
+
+// UnaryInterceptor enforces tree ownership for unary RPCs.
+func (o *TreeOwnership) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !enabledServices[serviceName(info.FullMethod)] {
+		return handler(ctx, req)
+	}
+	principal, err := o.principal(ctx)
+	if err != nil {
+		incRequestDeniedCounter(unauthenticatedReason, 0, "")
+		return nil, err
+	}
+	if o.superusers[principal] {
+		return handler(ctx, req)
+	}
+
+	switch req := req.(type) {
+	case *trillian.CreateTreeRequest:
+		if err := claimTree(principal, req.GetTree()); err != nil {
+			return nil, err
+		}
+	case *trillian.CreateTreesFromTemplateRequest:
+		if err := claimTree(principal, req.GetTemplate()); err != nil {
+			return nil, err
+		}
+	case *trillian.ImportTreesRequest:
+		for _, tree := range req.GetTree() {
+			if err := claimTree(principal, tree); err != nil {
+				return nil, err
+			}
+		}
+
+	case *trillian.ListTreesRequest:
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		r := resp.(*trillian.ListTreesResponse)
+		r.Tree = ownedTrees(principal, r.Tree)
+		return r, nil
+	case *trillian.ExportTreesRequest:
+		if len(req.GetTreeId()) == 0 {
+			// All trees are exported, so only the owned ones are returned.
+			resp, err := handler(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			r := resp.(*trillian.ExportTreesResponse)
+			r.Tree = ownedTrees(principal, r.Tree)
+			return r, nil
+		}
+		for _, id := range req.GetTreeId() {
+			if _, err := o.checkOwner(ctx, principal, id); err != nil {
+				return nil, err
+			}
+		}
+	case *trillian.SetMultiMapLeavesRequest:
+		for _, r := range req.GetRequests() {
+			if _, err := o.checkOwner(ctx, principal, r.GetMapId()); err != nil {
+				return nil, err
+			}
+		}
+
+	default:
+		treeID, err := requestTreeID(req)
+		if err != nil {
+			return nil, err
+		}
+		if u, ok := req.(*trillian.UpdateTreeRequest); ok {
+			for _, path := range u.GetUpdateMask().GetPaths() {
+				if path == "owner" {
+					incRequestDeniedCounter(notOwnerReason, treeID, "")
+					return nil, status.Errorf(codes.PermissionDenied, "only superusers may change the owner of tree %d", treeID)
+				}
+			}
+		}
+		tree, err := o.checkOwner(ctx, principal, treeID)
+		if err != nil {
+			return nil, err
+		}
+		// Save the TrillianInterceptor a second read of the tree.
+		if !tree.Deleted {
+			ctx = trees.NewContext(ctx, tree)
+		}
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor enforces tree ownership for streaming RPCs. Each streaming
+// RPC addresses a single tree, so the owner is checked when the first request
+// of the stream is received, and again if a later request addresses another
+// tree.
+func (o *TreeOwnership) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !enabledServices[serviceName(info.FullMethod)] {
+		return handler(srv, ss)
+	}
+	principal, err := o.principal(ss.Context())
+	if err != nil {
+		incRequestDeniedCounter(unauthenticatedReason, 0, "")
+		return err
+	}
+	if o.superusers[principal] {
+		return handler(srv, ss)
+	}
+	return handler(srv, &ownedStream{ServerStream: ss, o: o, principal: principal})
+}
+
+// ownedStream is a grpc.ServerStream which checks that the trees addressed by
+// the requests it receives are owned by principal.
+type ownedStream struct {
+	grpc.ServerStream
+	o         *TreeOwnership
+	principal string
+	// checked is set once the owner of treeID has been checked.
+	checked bool
+	treeID  int64
+}
+
+// RecvMsg receives the next request of the stream, and fails if it addresses
+// a tree not owned by the principal.
+func (s *ownedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	treeID, err := requestTreeID(m)
+	if err != nil {
+		return err
+	}
+	if s.checked && treeID == s.treeID {
+		return nil
+	}
+	if _, err := s.o.checkOwner(s.Context(), s.principal, treeID); err != nil {
+		return err
+	}
+	s.checked, s.treeID = true, treeID
+	return nil
+}
+
+// checkOwner returns the tree with treeID, which may be deleted, if it's owned
+// by principal.
+func (o *TreeOwnership) checkOwner(ctx context.Context, principal string, treeID int64) (*trillian.Tree, error) {
+	tree, err := storage.GetTree(ctx, o.admin, treeID)
+	if err != nil {
+		incRequestDeniedCounter(badTreeReason, treeID, "")
+		return nil, err
+	}
+	if tree.Owner != principal {
+		incRequestDeniedCounter(notOwnerReason, treeID, "")
+		glog.V(1).Infof("%q denied access to tree %d of %q", principal, treeID, tree.Owner)
+		return nil, status.Errorf(codes.PermissionDenied, "tree %d is not owned by %q", treeID, principal)
+	}
+	return tree, nil
+}
+
+// claimTree makes principal the owner of tree if it has none. It fails if tree
+// is owned by another principal.
+func claimTree(principal string, tree *trillian.Tree) error {
+	if tree == nil {
+		// Rejected by the RPC.
+		return nil
+	}
+	switch tree.Owner {
+	case "":
+		tree.Owner = principal
+	case principal:
+	default:
+		incRequestDeniedCounter(notOwnerReason, 0, "")
+		return status.Errorf(codes.PermissionDenied, "%q may not create trees owned by %q", principal, tree.Owner)
+	}
+	return nil
+}
+
+// ownedTrees returns the trees owned by principal.
+func ownedTrees(principal string, all []*trillian.Tree) []*trillian.Tree {
+	owned := make([]*trillian.Tree, 0, len(all))
+	for _, tree := range all {
+		if tree.Owner == principal {
+			owned = append(owned, tree)
+		}
+	}
+	return owned
+}
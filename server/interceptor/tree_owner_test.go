@@ -0,0 +1,362 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/storage/testonly"
+	"github.com/google/trillian/trees"
+	"google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type principalKey struct{}
+
+func testPrincipal(ctx context.Context) (string, error) {
+	principal, ok := ctx.Value(principalKey{}).(string)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "no principal")
+	}
+	return principal, nil
+}
+
+func TestTreeOwnership(t *testing.T) {
+	ctx := context.Background()
+	as := memory.NewAdminStorage(memory.NewTreeStorage())
+	newTree := func(owner string) *trillian.Tree {
+		tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+		tree.Owner = owner
+		tree, err := storage.CreateTree(ctx, as, tree)
+		if err != nil {
+			t.Fatalf("CreateTree(): %v", err)
+		}
+		return tree
+	}
+	alice := newTree("alice")
+	bob := newTree("bob")
+	unowned := newTree("")
+
+	o := NewTreeOwnership(as, testPrincipal, []string{"root"}, nil /* mf */)
+
+	tests := []struct {
+		desc      string
+		principal string
+		req       interface{}
+		wantCode  codes.Code
+		// wantTree is the tree the handler finds in its context, if any.
+		wantTree *trillian.Tree
+	}{
+		{desc: "unauthenticated", req: &trillian.GetTreeRequest{TreeId: alice.TreeId}, wantCode: codes.Unauthenticated},
+		{
+			desc:      "owner",
+			principal: "alice",
+			req:       &trillian.GetLatestSignedLogRootRequest{LogId: alice.TreeId},
+			wantTree:  alice,
+		},
+		{
+			desc:      "notOwner",
+			principal: "alice",
+			req:       &trillian.GetLatestSignedLogRootRequest{LogId: bob.TreeId},
+			wantCode:  codes.PermissionDenied,
+		},
+		{
+			desc:      "unowned",
+			principal: "alice",
+			req:       &trillian.DeleteTreeRequest{TreeId: unowned.TreeId},
+			wantCode:  codes.PermissionDenied,
+		},
+		{desc: "superuser", principal: "root", req: &trillian.DeleteTreeRequest{TreeId: bob.TreeId}},
+		{desc: "missingTree", principal: "alice", req: &trillian.GetTreeRequest{TreeId: 12345}, wantCode: codes.Unknown},
+		{
+			desc:      "updateTree",
+			principal: "alice",
+			req: &trillian.UpdateTreeRequest{
+				Tree:       &trillian.Tree{TreeId: alice.TreeId, DisplayName: "new name"},
+				UpdateMask: &field_mask.FieldMask{Paths: []string{"display_name"}},
+			},
+			wantTree: alice,
+		},
+		{
+			desc:      "updateOwner",
+			principal: "alice",
+			req: &trillian.UpdateTreeRequest{
+				Tree:       &trillian.Tree{TreeId: alice.TreeId, Owner: "bob"},
+				UpdateMask: &field_mask.FieldMask{Paths: []string{"owner"}},
+			},
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			desc:      "exportTrees",
+			principal: "alice",
+			req:       &trillian.ExportTreesRequest{TreeId: []int64{alice.TreeId, bob.TreeId}},
+			wantCode:  codes.PermissionDenied,
+		},
+		{
+			desc:      "setMultiMapLeaves",
+			principal: "bob",
+			req: &trillian.SetMultiMapLeavesRequest{Requests: []*trillian.SetMapLeavesRequest{
+				{MapId: bob.TreeId},
+				{MapId: alice.TreeId},
+			}},
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			desc:      "createOwnTree",
+			principal: "alice",
+			req:       &trillian.CreateTreeRequest{Tree: &trillian.Tree{Owner: "alice"}},
+		},
+		{
+			desc:      "createOthersTree",
+			principal: "alice",
+			req:       &trillian.CreateTreeRequest{Tree: &trillian.Tree{Owner: "bob"}},
+			wantCode:  codes.PermissionDenied,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := ctx
+			if test.principal != "" {
+				ctx = context.WithValue(ctx, principalKey{}, test.principal)
+			}
+			var gotTree *trillian.Tree
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				gotTree, _ = trees.FromContext(ctx)
+				return nil, nil
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianAdmin/Method"}
+			if _, err := o.UnaryInterceptor(ctx, test.req, info, handler); status.Code(err) != test.wantCode {
+				t.Fatalf("UnaryInterceptor() returned err = %v, want code %v", err, test.wantCode)
+			}
+			if !proto.Equal(gotTree, test.wantTree) {
+				t.Errorf("handler got tree %v in context, want %v", gotTree, test.wantTree)
+			}
+		})
+	}
+
+	// Trees created without an owner are owned by their creator.
+	req := &trillian.CreateTreeRequest{Tree: &trillian.Tree{}}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	info := &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianAdmin/CreateTree"}
+	if _, err := o.UnaryInterceptor(context.WithValue(ctx, principalKey{}, "alice"), req, info, handler); err != nil {
+		t.Fatalf("UnaryInterceptor(CreateTree) returned err = %v", err)
+	}
+	if got, want := req.Tree.Owner, "alice"; got != want {
+		t.Errorf("CreateTree owner = %q, want %q", got, want)
+	}
+
+	// ListTrees only returns the trees of the principal.
+	listHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &trillian.ListTreesResponse{Tree: []*trillian.Tree{alice, bob, unowned}}, nil
+	}
+	info = &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianAdmin/ListTrees"}
+	resp, err := o.UnaryInterceptor(context.WithValue(ctx, principalKey{}, "bob"), &trillian.ListTreesRequest{}, info, listHandler)
+	if err != nil {
+		t.Fatalf("UnaryInterceptor(ListTrees) returned err = %v", err)
+	}
+	if got := resp.(*trillian.ListTreesResponse).Tree; len(got) != 1 || !proto.Equal(got[0], bob) {
+		t.Errorf("ListTrees returned %v, want only %v", got, bob)
+	}
+}
+
+// fakeServerStream is a grpc.ServerStream which receives reqs in order.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	reqs []proto.Message
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServerStream) RecvMsg(m interface{}) error {
+	if len(s.reqs) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), s.reqs[0])
+	s.reqs = s.reqs[1:]
+	return nil
+}
+
+func TestTreeOwnershipStream(t *testing.T) {
+	ctx := context.Background()
+	as := memory.NewAdminStorage(memory.NewTreeStorage())
+	newTree := func(template *trillian.Tree, owner string) *trillian.Tree {
+		tree := proto.Clone(template).(*trillian.Tree)
+		tree.Owner = owner
+		tree, err := storage.CreateTree(ctx, as, tree)
+		if err != nil {
+			t.Fatalf("CreateTree(): %v", err)
+		}
+		return tree
+	}
+	aliceLog := newTree(testonly.PreorderedLogTree, "alice")
+	bobLog := newTree(testonly.PreorderedLogTree, "bob")
+	bobMap := newTree(testonly.MapTree, "bob")
+
+	o := NewTreeOwnership(as, testPrincipal, []string{"root"}, nil /* mf */)
+
+	addLeaves := func(logID int64) *trillian.AddSequencedLeavesStreamRequest {
+		return &trillian.AddSequencedLeavesStreamRequest{LogId: logID}
+	}
+	for _, test := range []struct {
+		desc      string
+		principal string
+		method    string
+		reqs      []proto.Message
+		wantCode  codes.Code
+	}{
+		{
+			desc:     "unauthenticated",
+			method:   "/trillian.TrillianLog/GetLeavesByRangeStream",
+			reqs:     []proto.Message{&trillian.GetLeavesByRangeStreamRequest{LogId: aliceLog.TreeId}},
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			desc:      "logOwner",
+			principal: "alice",
+			method:    "/trillian.TrillianLog/GetLeavesByRangeStream",
+			reqs:      []proto.Message{&trillian.GetLeavesByRangeStreamRequest{LogId: aliceLog.TreeId}},
+		},
+		{
+			desc:      "logNotOwner",
+			principal: "alice",
+			method:    "/trillian.TrillianLog/GetLeavesByRangeStream",
+			reqs:      []proto.Message{&trillian.GetLeavesByRangeStreamRequest{LogId: bobLog.TreeId}},
+			wantCode:  codes.PermissionDenied,
+		},
+		{
+			desc:      "logClientStream",
+			principal: "alice",
+			method:    "/trillian.TrillianLog/AddSequencedLeavesStream",
+			reqs:      []proto.Message{addLeaves(aliceLog.TreeId), addLeaves(aliceLog.TreeId)},
+		},
+		{
+			desc:      "logClientStreamNotOwner",
+			principal: "alice",
+			method:    "/trillian.TrillianLog/AddSequencedLeavesStream",
+			reqs:      []proto.Message{addLeaves(bobLog.TreeId)},
+			wantCode:  codes.PermissionDenied,
+		},
+		{
+			desc:      "logClientStreamSwitchesTree",
+			principal: "alice",
+			method:    "/trillian.TrillianLog/AddSequencedLeavesStream",
+			reqs:      []proto.Message{addLeaves(aliceLog.TreeId), addLeaves(bobLog.TreeId)},
+			wantCode:  codes.PermissionDenied,
+		},
+		{
+			desc:      "mapOwner",
+			principal: "bob",
+			method:    "/trillian.TrillianMap/WatchSignedMapRoots",
+			reqs:      []proto.Message{&trillian.WatchSignedMapRootsRequest{MapId: bobMap.TreeId}},
+		},
+		{
+			desc:      "mapNotOwner",
+			principal: "alice",
+			method:    "/trillian.TrillianMap/ListLeavesByRevision",
+			reqs:      []proto.Message{&trillian.ListMapLeavesByRevisionRequest{MapId: bobMap.TreeId}},
+			wantCode:  codes.PermissionDenied,
+		},
+		{
+			desc:      "mapExportNotOwner",
+			principal: "alice",
+			method:    "/trillian.TrillianMap/ExportMap",
+			reqs:      []proto.Message{&trillian.ExportMapRequest{MapId: bobMap.TreeId}},
+			wantCode:  codes.PermissionDenied,
+		},
+		{
+			desc:      "superuser",
+			principal: "root",
+			method:    "/trillian.TrillianMap/ExportMap",
+			reqs:      []proto.Message{&trillian.ExportMapRequest{MapId: bobMap.TreeId}},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := ctx
+			if test.principal != "" {
+				ctx = context.WithValue(ctx, principalKey{}, test.principal)
+			}
+			// The handler receives all the requests, as the RPC servers do.
+			handler := func(srv interface{}, ss grpc.ServerStream) error {
+				for {
+					req := proto.Clone(test.reqs[0])
+					req.Reset()
+					if err := ss.RecvMsg(req); err == io.EOF {
+						return nil
+					} else if err != nil {
+						return err
+					}
+				}
+			}
+			ss := &fakeServerStream{ctx: ctx, reqs: test.reqs}
+			info := &grpc.StreamServerInfo{FullMethod: test.method}
+			if err := o.StreamInterceptor(nil, ss, info, handler); status.Code(err) != test.wantCode {
+				t.Errorf("StreamInterceptor() returned err = %v, want code %v", err, test.wantCode)
+			}
+		})
+	}
+}
+
+func TestTLSPrincipal(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}}
+	for _, test := range []struct {
+		desc     string
+		peer     *peer.Peer
+		want     string
+		wantCode codes.Code
+	}{
+		{desc: "noPeer", wantCode: codes.Unauthenticated},
+		{desc: "noTLS", peer: &peer.Peer{}, wantCode: codes.Unauthenticated},
+		{
+			desc:     "unverified",
+			peer:     &peer.Peer{AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}},
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			desc: "verified",
+			peer: &peer.Peer{AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}}},
+			want: "alice",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := context.Background()
+			if test.peer != nil {
+				ctx = peer.NewContext(ctx, test.peer)
+			}
+			got, err := TLSPrincipal(ctx)
+			if status.Code(err) != test.wantCode {
+				t.Fatalf("TLSPrincipal() returned err = %v, want code %v", err, test.wantCode)
+			}
+			if got != test.want {
+				t.Errorf("TLSPrincipal() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
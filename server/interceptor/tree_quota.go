@@ -0,0 +1,99 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/trillian"
+	"golang.org/x/time/rate"
+)
+
+// treeLimiterIdleTimeout is how long the limiter of a tree may go unused
+// before it's dropped, e.g. because the tree was deleted or lost its quota.
+const treeLimiterIdleTimeout = 10 * time.Minute
+
+// treeLimiterKey identifies the rate limiter of the read or write tokens of a
+// tree.
+type treeLimiterKey struct {
+	treeID   int64
+	readonly bool
+}
+
+// treeLimiter is a rate limiter and the last time it was used.
+type treeLimiter struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// treeLimiters enforces the quota of each tree, i.e. the rates at which its
+// requests may spend read and write tokens. A tree may spend a second's worth
+// of tokens at once.
+type treeLimiters struct {
+	mu        sync.Mutex
+	limiters  map[treeLimiterKey]*treeLimiter
+	lastPrune time.Time
+}
+
+// allow spends tokens from the read or write quota of tree, and returns
+// whether it had enough of them. Trees without a quota always have enough.
+// Requests which are charged more tokens than a tree may spend at once are
+// charged that maximum instead.
+func (l *treeLimiters) allow(tree *trillian.Tree, readonly bool, tokens int, now time.Time) bool {
+	perSecond := tree.Quota.GetWriteTokensPerSecond()
+	if readonly {
+		perSecond = tree.Quota.GetReadTokensPerSecond()
+	}
+	if perSecond <= 0 || tokens <= 0 {
+		return true
+	}
+	burst := int(perSecond)
+	if tokens > burst {
+		tokens = burst
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limiters == nil {
+		l.limiters = make(map[treeLimiterKey]*treeLimiter)
+		l.lastPrune = now
+	}
+	if now.Sub(l.lastPrune) >= treeLimiterIdleTimeout {
+		l.prune(now)
+	}
+	key := treeLimiterKey{treeID: tree.TreeId, readonly: readonly}
+	tl, ok := l.limiters[key]
+	// Limiters are replaced when the quota of their tree is updated, so the
+	// new rate applies straight away.
+	if !ok || tl.limiter.Limit() != rate.Limit(perSecond) || tl.limiter.Burst() != burst {
+		tl = &treeLimiter{limiter: rate.NewLimiter(rate.Limit(perSecond), burst)}
+		l.limiters[key] = tl
+	}
+	tl.lastUsed = now
+	return tl.limiter.AllowN(now, tokens)
+}
+
+// prune drops the limiters which haven't been used for treeLimiterIdleTimeout.
+// An idle limiter has replenished all its tokens, so dropping it doesn't let
+// its tree spend more than its quota. l.mu must be held.
+func (l *treeLimiters) prune(now time.Time) {
+	for key, tl := range l.limiters {
+		if now.Sub(tl.lastUsed) >= treeLimiterIdleTimeout {
+			delete(l.limiters, key)
+		}
+	}
+	l.lastPrune = now
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+)
+
+func TestTreeLimiters_Allow(t *testing.T) {
+	now := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	tree := &trillian.Tree{TreeId: 1, Quota: &trillian.TreeQuota{WriteTokensPerSecond: 10}}
+	unlimited := &trillian.Tree{TreeId: 2}
+
+	var l treeLimiters
+	for _, step := range []struct {
+		desc     string
+		tree     *trillian.Tree
+		readonly bool
+		tokens   int
+		after    time.Duration
+		want     bool
+	}{
+		{desc: "burst", tree: tree, tokens: 6, want: true},
+		{desc: "exhausted", tree: tree, tokens: 6, want: false},
+		{desc: "remaining", tree: tree, tokens: 4, want: true},
+		{desc: "replenished", tree: tree, tokens: 5, after: 500 * time.Millisecond, want: true},
+		{desc: "unlimitedRead", tree: tree, readonly: true, tokens: 100, want: true},
+		{desc: "unlimitedTree", tree: unlimited, tokens: 100, want: true},
+		// Requests charged more than a second's worth of tokens spend all of them.
+		{desc: "clamped", tree: tree, tokens: 100, after: time.Second, want: true},
+		{desc: "clampedExhausted", tree: tree, tokens: 1, want: false},
+	} {
+		now = now.Add(step.after)
+		if got := l.allow(step.tree, step.readonly, step.tokens, now); got != step.want {
+			t.Errorf("%v: allow(%v tokens) = %v, want %v", step.desc, step.tokens, got, step.want)
+		}
+	}
+
+	// Updating the quota of the tree replaces its limiter.
+	tree.Quota.WriteTokensPerSecond = 20
+	if !l.allow(tree, false, 20, now) {
+		t.Error("allow() after quota update = false, want true")
+	}
+}
+
+func TestTreeLimiters_Prune(t *testing.T) {
+	now := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	busy := &trillian.Tree{TreeId: 1, Quota: &trillian.TreeQuota{WriteTokensPerSecond: 10}}
+	idle := &trillian.Tree{TreeId: 2, Quota: &trillian.TreeQuota{ReadTokensPerSecond: 10}}
+
+	var l treeLimiters
+	l.allow(busy, false, 1, now)
+	l.allow(idle, true, 1, now)
+	if got, want := len(l.limiters), 2; got != want {
+		t.Fatalf("len(limiters) = %v, want %v", got, want)
+	}
+
+	now = now.Add(treeLimiterIdleTimeout / 2)
+	l.allow(busy, false, 1, now)
+	now = now.Add(treeLimiterIdleTimeout / 2)
+	l.allow(busy, false, 1, now)
+
+	if _, ok := l.limiters[treeLimiterKey{treeID: idle.TreeId, readonly: true}]; ok {
+		t.Error("limiter of idle tree not pruned")
+	}
+	if _, ok := l.limiters[treeLimiterKey{treeID: busy.TreeId}]; !ok {
+		t.Error("limiter of busy tree pruned")
+	}
+}
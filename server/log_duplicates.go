@@ -0,0 +1,103 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/hashers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// duplicateNonceSize is the number of random bytes mixed into the identity
+// hashes of leaves queued to logs which allow duplicates.
+const duplicateNonceSize = 16
+
+// uniqueIdentityHashes replaces the identity hashes of leaves with unique
+// ones, so that the storage queues them even if they duplicate other leaves.
+func uniqueIdentityHashes(leaves []*trillian.LogLeaf, hasher hashers.LogHasher) error {
+	for _, leaf := range leaves {
+		nonce := make([]byte, duplicateNonceSize)
+		if _, err := rand.Read(nonce); err != nil {
+			return status.Errorf(codes.Internal, "failed to generate leaf identity: %v", err)
+		}
+		data := append(append([]byte{}, leaf.LeafIdentityHash...), nonce...)
+		leaf.LeafIdentityHash = hasher.HashLeaf(data)
+	}
+	return nil
+}
+
+// deduplicateLeaves turns the AlreadyExists results of queuing leaves into OK
+// ones, with the sequence information of the original leaves if they have
+// been integrated. Some storage implementations return the original leaves
+// without their Merkle leaf hashes, so these are computed with hasher.
+func (t *TrillianLogRPCServer) deduplicateLeaves(ctx context.Context, tree *trillian.Tree, hasher hashers.LogHasher, queued []*trillian.QueuedLogLeaf) error {
+	var dups []*trillian.QueuedLogLeaf
+	var hashes [][]byte
+	for _, q := range queued {
+		if q.Status == nil || q.Status.Code != int32(codes.AlreadyExists) || q.Leaf == nil {
+			continue
+		}
+		dups = append(dups, q)
+		if len(q.Leaf.MerkleLeafHash) == 0 {
+			q.Leaf.MerkleLeafHash = hasher.HashLeaf(q.Leaf.LeafValue)
+		}
+		if q.Leaf.IntegrateTimestamp == nil {
+			hashes = append(hashes, q.Leaf.MerkleLeafHash)
+		}
+	}
+	if len(dups) == 0 {
+		return nil
+	}
+
+	if len(hashes) > 0 {
+		tx, err := t.snapshotForTree(ctx, tree, "QueueLeaves")
+		if err != nil {
+			return err
+		}
+		defer t.closeAndLog(ctx, tree.TreeId, tx, "QueueLeaves")
+		leaves, err := tx.GetLeavesByHash(ctx, hashes, false)
+		if err != nil {
+			return err
+		}
+		if err := t.commitAndLog(ctx, tree.TreeId, tx, "QueueLeaves"); err != nil {
+			return err
+		}
+		for _, q := range dups {
+			if q.Leaf.IntegrateTimestamp != nil {
+				continue
+			}
+			for _, leaf := range leaves {
+				// Leaves with the same value may have different identities.
+				if bytes.Equal(leaf.LeafIdentityHash, q.Leaf.LeafIdentityHash) {
+					q.Leaf.LeafIndex = leaf.LeafIndex
+					q.Leaf.IntegrateTimestamp = leaf.IntegrateTimestamp
+					break
+				}
+			}
+		}
+	}
+
+	for _, q := range dups {
+		msg := fmt.Sprintf("duplicate of existing leaf with identity hash %x", q.Leaf.LeafIdentityHash)
+		q.Status = status.New(codes.OK, msg).Proto()
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/merkle/rfc6962"
+	"github.com/google/trillian/storage"
+	"google.golang.org/grpc/codes"
+
+	stestonly "github.com/google/trillian/storage/testonly"
+)
+
+// duplicatesAdminStorage returns an admin storage with a log which has the
+// given duplicate leaf policy.
+func duplicatesAdminStorage(ctrl *gomock.Controller, policy trillian.DuplicateLeafPolicy) storage.AdminStorage {
+	tree := addTreeID(stestonly.LogTree, logID1)
+	tree.DuplicateLeafPolicy = policy
+
+	adminStorage := storage.NewMockAdminStorage(ctrl)
+	adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+	adminStorage.EXPECT().Snapshot(gomock.Any()).Return(adminTX, nil)
+	adminTX.EXPECT().GetTree(gomock.Any(), logID1).Return(tree, nil)
+	adminTX.EXPECT().Close().Return(nil)
+	adminTX.EXPECT().Commit().Return(nil)
+	return adminStorage
+}
+
+func TestQueueLeavesDeduplicate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hasher := rfc6962.DefaultHasher
+	integrated, err := ptypes.TimestampProto(time.Unix(1000, 0))
+	if err != nil {
+		t.Fatalf("TimestampProto(): %v", err)
+	}
+	newLeaf := &trillian.LogLeaf{LeafValue: []byte("new")}
+	seqLeaf := &trillian.LogLeaf{LeafValue: []byte("sequenced")}
+	unseqLeaf := &trillian.LogLeaf{LeafValue: []byte("unsequenced")}
+	req := &trillian.QueueLeavesRequest{LogId: logID1, Leaves: []*trillian.LogLeaf{newLeaf, seqLeaf, unseqLeaf}}
+
+	// The storage returns the original leaves without sequence information.
+	seqOrig := &trillian.LogLeaf{LeafValue: []byte("sequenced"), LeafIdentityHash: hasher.HashLeaf([]byte("sequenced"))}
+	unseqOrig := &trillian.LogLeaf{LeafValue: []byte("unsequenced"), LeafIdentityHash: hasher.HashLeaf([]byte("unsequenced"))}
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().QueueLeaves(gomock.Any(), gomock.Any(), req.Leaves, fakeTime).Return([]*trillian.QueuedLogLeaf{
+		okQueuedLeaf(newLeaf), dupeQueuedLeaf(seqOrig), dupeQueuedLeaf(unseqOrig),
+	}, nil)
+	tx := storage.NewMockLogTreeTX(ctrl)
+	mockStorage.EXPECT().SnapshotForTree(gomock.Any(), gomock.Any()).Return(tx, nil)
+	tx.EXPECT().GetLeavesByHash(gomock.Any(), [][]byte{hasher.HashLeaf([]byte("sequenced")), hasher.HashLeaf([]byte("unsequenced"))}, false).Return([]*trillian.LogLeaf{
+		{LeafIdentityHash: seqOrig.LeafIdentityHash, LeafIndex: 7, IntegrateTimestamp: integrated},
+	}, nil)
+	tx.EXPECT().Commit(gomock.Any()).Return(nil)
+	tx.EXPECT().Close().Return(nil)
+
+	registry := extension.Registry{
+		AdminStorage: duplicatesAdminStorage(ctrl, trillian.DuplicateLeafPolicy_DEDUPLICATE),
+		LogStorage:   mockStorage,
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	rsp, err := server.QueueLeaves(context.Background(), req)
+	if err != nil {
+		t.Fatalf("QueueLeaves(): %v", err)
+	}
+	if got, want := len(rsp.QueuedLeaves), 3; got != want {
+		t.Fatalf("QueueLeaves() returned %d leaves, want %d", got, want)
+	}
+	for i, q := range rsp.QueuedLeaves {
+		if got := codes.Code(q.Status.GetCode()); got != codes.OK {
+			t.Errorf("QueuedLeaves[%d].Status=%v, want OK", i, got)
+		}
+	}
+	if got := rsp.QueuedLeaves[1].Leaf; got.LeafIndex != 7 || !proto.Equal(got.IntegrateTimestamp, integrated) {
+		t.Errorf("QueuedLeaves[1].Leaf=%v, want index 7 integrated at %v", got, integrated)
+	}
+	if got := rsp.QueuedLeaves[2].Leaf; got.IntegrateTimestamp != nil {
+		t.Errorf("QueuedLeaves[2].Leaf=%v, want unsequenced", got)
+	}
+}
+
+func TestQueueLeavesAllowDuplicates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var queued [][]byte
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().QueueLeaves(gomock.Any(), gomock.Any(), gomock.Any(), fakeTime).Times(2).DoAndReturn(
+		func(_ context.Context, _ *trillian.Tree, leaves []*trillian.LogLeaf, _ time.Time) ([]*trillian.QueuedLogLeaf, error) {
+			ret := make([]*trillian.QueuedLogLeaf, 0, len(leaves))
+			for _, leaf := range leaves {
+				queued = append(queued, leaf.LeafIdentityHash)
+				ret = append(ret, okQueuedLeaf(leaf))
+			}
+			return ret, nil
+		})
+
+	adminStorage := storage.NewMockAdminStorage(ctrl)
+	adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+	tree := addTreeID(stestonly.LogTree, logID1)
+	tree.DuplicateLeafPolicy = trillian.DuplicateLeafPolicy_ALLOW_DUPLICATES
+	adminStorage.EXPECT().Snapshot(gomock.Any()).Times(2).Return(adminTX, nil)
+	adminTX.EXPECT().GetTree(gomock.Any(), logID1).Times(2).Return(tree, nil)
+	adminTX.EXPECT().Close().Times(2).Return(nil)
+	adminTX.EXPECT().Commit().Times(2).Return(nil)
+
+	registry := extension.Registry{AdminStorage: adminStorage, LogStorage: mockStorage}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	merkleHash := rfc6962.DefaultHasher.HashLeaf([]byte("value"))
+	for i := 0; i < 2; i++ {
+		req := &trillian.QueueLeavesRequest{LogId: logID1, Leaves: []*trillian.LogLeaf{{LeafValue: []byte("value")}}}
+		if _, err := server.QueueLeaves(context.Background(), req); err != nil {
+			t.Fatalf("QueueLeaves(): %v", err)
+		}
+		if got := req.Leaves[0].MerkleLeafHash; !bytes.Equal(got, merkleHash) {
+			t.Errorf("MerkleLeafHash=%x, want %x", got, merkleHash)
+		}
+	}
+
+	if len(queued) != 2 {
+		t.Fatalf("queued %d leaves, want 2", len(queued))
+	}
+	for _, id := range queued {
+		if len(id) != len(merkleHash) || bytes.Equal(id, merkleHash) {
+			t.Errorf("LeafIdentityHash=%x, want a unique hash", id)
+		}
+	}
+	if bytes.Equal(queued[0], queued[1]) {
+		t.Errorf("duplicate leaves queued with the same identity hash %x", queued[0])
+	}
+}
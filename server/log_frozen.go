@@ -0,0 +1,283 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/tree"
+	"github.com/google/trillian/trees"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	frozenLogCache         = flag.Bool("frozen_log_cache", false, "Serve the latest root of FROZEN logs from memory once it's been read, rather than from storage for each request. The root is read again if the tree is updated")
+	frozenLogInMemoryLimit = flag.Int64("frozen_log_in_memory_max_size", 0, "If positive, FROZEN logs with up to this many leaves are loaded into memory at startup, about 64 bytes per leaf, so that their inclusion and consistency proofs are served without reading storage. Requires --frozen_log_cache")
+)
+
+// frozenLog is the state of a FROZEN log kept in memory, which does not change
+// for as long as the log stays frozen.
+type frozenLog struct {
+	// tree is the definition of the log the state was read for. The state is
+	// read again once the log is updated.
+	tree *trillian.Tree
+	slr  *trillian.SignedLogRoot
+	root types.LogRootV1
+	// nodes is nil unless the Merkle tree of the log was loaded into memory.
+	nodes *frozenLogNodes
+}
+
+// frozenLogs caches the state of FROZEN logs. It is safe for concurrent use.
+type frozenLogs struct {
+	mu   sync.Mutex
+	logs map[int64]*frozenLog
+}
+
+// get returns the cached state of tree, or nil if tree isn't FROZEN or its
+// state isn't cached.
+func (f *frozenLogs) get(tree *trillian.Tree) *frozenLog {
+	if tree.TreeState != trillian.TreeState_FROZEN {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	log, ok := f.logs[tree.TreeId]
+	if !ok || !proto.Equal(log.tree.UpdateTime, tree.UpdateTime) {
+		return nil
+	}
+	return log
+}
+
+// put caches log, unless the same version of its tree is already cached with
+// its nodes.
+func (f *frozenLogs) put(log *frozenLog) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.logs == nil {
+		f.logs = make(map[int64]*frozenLog)
+	}
+	if old, ok := f.logs[log.tree.TreeId]; ok && old.nodes != nil && proto.Equal(old.tree.UpdateTime, log.tree.UpdateTime) {
+		return
+	}
+	f.logs[log.tree.TreeId] = log
+}
+
+// frozenLog returns the state of tree if it's FROZEN and FROZEN logs are
+// cached, reading it from storage on first use, or nil otherwise.
+func (t *TrillianLogRPCServer) frozenLog(ctx context.Context, tree *trillian.Tree, method string) (*frozenLog, error) {
+	if t.frozenLogs == nil || tree.TreeState != trillian.TreeState_FROZEN {
+		return nil, nil
+	}
+	if log := t.frozenLogs.get(tree); log != nil {
+		return log, nil
+	}
+	slr, err := t.readLatestRoot(ctx, tree, method)
+	if err != nil {
+		return nil, err
+	}
+	log := &frozenLog{tree: tree, slr: slr}
+	if err := log.root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not read current log root: %v", err)
+	}
+	t.frozenLogs.put(log)
+	return log, nil
+}
+
+// LoadFrozenLogs loads the Merkle trees of the FROZEN logs with up to
+// --frozen_log_in_memory_max_size leaves into memory, so that their proofs are
+// served without reading storage. Logs which fail to load are served from
+// storage. It does nothing unless FROZEN logs are cached.
+func (t *TrillianLogRPCServer) LoadFrozenLogs(ctx context.Context) error {
+	if t.frozenLogs == nil || t.frozenLogLimit <= 0 {
+		return nil
+	}
+	all, err := storage.ListTrees(ctx, t.registry.AdminStorage, false /* includeDeleted */)
+	if err != nil {
+		return err
+	}
+	for _, tree := range all {
+		if tree.TreeState != trillian.TreeState_FROZEN || (tree.TreeType != trillian.TreeType_LOG && tree.TreeType != trillian.TreeType_PREORDERED_LOG) {
+			continue
+		}
+		if err := t.loadFrozenLog(ctx, tree); err != nil {
+			glog.Warningf("%v: failed to load frozen log into memory: %v", tree.TreeId, err)
+		}
+	}
+	return nil
+}
+
+// loadFrozenLog loads the Merkle tree of the FROZEN log tree into memory, if
+// it's not too large.
+func (t *TrillianLogRPCServer) loadFrozenLog(ctx context.Context, tree *trillian.Tree) error {
+	hasher, err := hashers.NewLogHasher(tree.HashStrategy)
+	if err != nil {
+		return err
+	}
+	ctx = trees.NewContext(ctx, tree)
+	tx, err := t.snapshotForTree(ctx, tree, "LoadFrozenLogs")
+	if err != nil {
+		return err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "LoadFrozenLogs")
+
+	slr, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return err
+	}
+	log := &frozenLog{tree: tree, slr: slr}
+	if err := log.root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return fmt.Errorf("could not read current log root: %v", err)
+	}
+	if int64(log.root.TreeSize) > t.frozenLogLimit {
+		glog.Infof("%v: not loading frozen log of %d leaves into memory", tree.TreeId, log.root.TreeSize)
+		if err := t.commitAndLog(ctx, tree.TreeId, tx, "LoadFrozenLogs"); err != nil {
+			return err
+		}
+		t.frozenLogs.put(log)
+		return nil
+	}
+
+	leafHashes := make([]byte, 0, int(log.root.TreeSize)*hasher.Size())
+	for start := int64(0); start < int64(log.root.TreeSize); start += maxLeafChunkSize {
+		count := int64(log.root.TreeSize) - start
+		if count > maxLeafChunkSize {
+			count = maxLeafChunkSize
+		}
+		leaves, err := tx.GetLeavesByRange(ctx, start, count)
+		if err != nil {
+			return err
+		}
+		if int64(len(leaves)) != count {
+			return fmt.Errorf("got %d leaves from index %d, want %d", len(leaves), start, count)
+		}
+		for _, leaf := range leaves {
+			if len(leaf.MerkleLeafHash) != hasher.Size() {
+				return fmt.Errorf("leaf %d has a hash of %d bytes, want %d", leaf.LeafIndex, len(leaf.MerkleLeafHash), hasher.Size())
+			}
+			leafHashes = append(leafHashes, leaf.MerkleLeafHash...)
+		}
+	}
+	if err := t.commitAndLog(ctx, tree.TreeId, tx, "LoadFrozenLogs"); err != nil {
+		return err
+	}
+
+	nodes := newFrozenLogNodes(hasher, leafHashes)
+	// Proofs are only served from memory if they match the signed root.
+	if got := nodes.rootHash(hasher); !bytes.Equal(got, log.root.RootHash) {
+		return fmt.Errorf("leaves have root hash %x, want %x", got, log.root.RootHash)
+	}
+	log.nodes = nodes
+	t.frozenLogs.put(log)
+	glog.Infof("%v: loaded frozen log of %d leaves into memory", tree.TreeId, log.root.TreeSize)
+	return nil
+}
+
+// frozenLogNodes holds all the nodes of a log's Merkle tree, which are all the
+// nodes proofs are built from. Like in log storage, the nodes on the right
+// border of the tree cover the leaves there are, rather than a perfect
+// subtree.
+type frozenLogNodes struct {
+	hashSize int
+	// levels[l] holds the concatenated hashes of the nodes at level l, leaves
+	// first.
+	levels [][]byte
+}
+
+// newFrozenLogNodes computes the nodes of the Merkle tree with the given
+// concatenated leaf hashes.
+func newFrozenLogNodes(hasher hashers.LogHasher, leafHashes []byte) *frozenLogNodes {
+	size := hasher.Size()
+	n := &frozenLogNodes{hashSize: size, levels: [][]byte{leafHashes}}
+	for level := leafHashes; len(level) > size; {
+		next := make([]byte, 0, (len(level)/size+1)/2*size)
+		for i := 0; i < len(level); i += 2 * size {
+			if i+size == len(level) {
+				// A node without a right child has the hash of its left child.
+				next = append(next, level[i:]...)
+				break
+			}
+			next = append(next, hasher.HashChildren(level[i:i+size], level[i+size:i+2*size])...)
+		}
+		n.levels = append(n.levels, next)
+		level = next
+	}
+	return n
+}
+
+// node returns the hash of the node with the given coordinates, if the tree
+// has it.
+func (n *frozenLogNodes) node(level uint, index uint64) ([]byte, bool) {
+	if level >= uint(len(n.levels)) {
+		return nil, false
+	}
+	hashes := n.levels[level]
+	if index >= uint64(len(hashes)/n.hashSize) {
+		return nil, false
+	}
+	return hashes[index*uint64(n.hashSize) : (index+1)*uint64(n.hashSize)], true
+}
+
+// rootHash returns the root hash of the whole tree.
+func (n *frozenLogNodes) rootHash(hasher hashers.LogHasher) []byte {
+	if len(n.levels[0]) == 0 {
+		return hasher.EmptyRoot()
+	}
+	return n.levels[len(n.levels)-1]
+}
+
+// proof builds the proof made of the nodes in fetches.
+func (n *frozenLogNodes) proof(hasher hashers.LogHasher, leafIndex int64, fetches []merkle.NodeFetch) (*trillian.Proof, error) {
+	nodes := make([]tree.Node, 0, len(fetches))
+	for _, fetch := range fetches {
+		hash, ok := n.node(fetch.ID.Level, fetch.ID.Index)
+		if !ok {
+			return nil, fmt.Errorf("node %+v is not in the frozen log", fetch.ID)
+		}
+		nodes = append(nodes, tree.Node{Hash: hash})
+	}
+	return buildProof(hasher, leafIndex, nodes, fetches)
+}
+
+// inclusionProof returns the inclusion proof of leafIndex in the tree of
+// treeSize leaves.
+func (n *frozenLogNodes) inclusionProof(hasher hashers.LogHasher, treeSize, leafIndex, rootTreeSize int64) (*trillian.Proof, error) {
+	fetches, err := merkle.CalcInclusionProofNodeAddresses(treeSize, leafIndex, rootTreeSize)
+	if err != nil {
+		return nil, err
+	}
+	return n.proof(hasher, leafIndex, fetches)
+}
+
+// consistencyProof returns the consistency proof between the trees of
+// firstTreeSize and secondTreeSize leaves.
+func (n *frozenLogNodes) consistencyProof(hasher hashers.LogHasher, firstTreeSize, secondTreeSize, rootTreeSize int64) (*trillian.Proof, error) {
+	fetches, err := merkle.CalcConsistencyProofNodeAddresses(firstTreeSize, secondTreeSize, rootTreeSize)
+	if err != nil {
+		return nil, err
+	}
+	return n.proof(hasher, 0, fetches)
+}
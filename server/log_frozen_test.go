@@ -0,0 +1,175 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	"github.com/google/trillian/types"
+
+	stestonly "github.com/google/trillian/storage/testonly"
+)
+
+func TestFrozenLogNodes(t *testing.T) {
+	verifier := merkle.NewLogVerifier(th)
+	for size := int64(0); size <= 17; size++ {
+		t.Run(fmt.Sprintf("size:%d", size), func(t *testing.T) {
+			mt := merkle.NewInMemoryMerkleTree(th)
+			var leafHashes []byte
+			for i := int64(0); i < size; i++ {
+				_, entry := mt.AddLeaf([]byte(fmt.Sprintf("leaf %d", i)))
+				leafHashes = append(leafHashes, entry.Hash()...)
+			}
+			nodes := newFrozenLogNodes(th, leafHashes)
+			if got, want := nodes.rootHash(th), mt.CurrentRoot().Hash(); !bytes.Equal(got, want) {
+				t.Fatalf("rootHash()=%x, want %x", got, want)
+			}
+
+			for treeSize := int64(1); treeSize <= size; treeSize++ {
+				root := mt.RootAtSnapshot(treeSize).Hash()
+				for index := int64(0); index < treeSize; index++ {
+					proof, err := nodes.inclusionProof(th, treeSize, index, size)
+					if err != nil {
+						t.Fatalf("inclusionProof(%d, %d): %v", treeSize, index, err)
+					}
+					if err := verifier.VerifyInclusionProof(index, treeSize, proof.Hashes, root, mt.LeafHash(index+1)); err != nil {
+						t.Errorf("inclusionProof(%d, %d) does not verify: %v", treeSize, index, err)
+					}
+				}
+				for first := int64(1); first <= treeSize; first++ {
+					proof, err := nodes.consistencyProof(th, first, treeSize, size)
+					if err != nil {
+						t.Fatalf("consistencyProof(%d, %d): %v", first, treeSize, err)
+					}
+					if err := verifier.VerifyConsistencyProof(first, treeSize, mt.RootAtSnapshot(first).Hash(), root, proof.Hashes); err != nil {
+						t.Errorf("consistencyProof(%d, %d) does not verify: %v", first, treeSize, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFrozenLogs(t *testing.T) {
+	defer func(cache bool, limit int64) {
+		*frozenLogCache = cache
+		*frozenLogInMemoryLimit = limit
+	}(*frozenLogCache, *frozenLogInMemoryLimit)
+	*frozenLogCache = true
+
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	as := memory.NewAdminStorage(memory.NewTreeStorage())
+	tree, err := storage.CreateTree(ctx, as, stestonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	tree, err = storage.UpdateTree(ctx, as, tree.TreeId, func(tree *trillian.Tree) {
+		tree.TreeState = trillian.TreeState_FROZEN
+	})
+	if err != nil {
+		t.Fatalf("UpdateTree(): %v", err)
+	}
+
+	const size = 5
+	mt := merkle.NewInMemoryMerkleTree(th)
+	leaves := make([]*trillian.LogLeaf, 0, size)
+	for i := int64(0); i < size; i++ {
+		_, entry := mt.AddLeaf([]byte(fmt.Sprintf("leaf %d", i)))
+		leaves = append(leaves, &trillian.LogLeaf{LeafIndex: i, MerkleLeafHash: entry.Hash()})
+	}
+	root := &types.LogRootV1{TreeSize: size, RootHash: mt.CurrentRoot().Hash(), TimestampNanos: 1000, Revision: 3}
+	slr, err := fixedSigner.SignLogRoot(root)
+	if err != nil {
+		t.Fatalf("SignLogRoot(): %v", err)
+	}
+
+	for _, test := range []struct {
+		desc     string
+		limit    int64
+		inMemory bool
+	}{
+		{desc: "rootOnly"},
+		{desc: "tooLarge", limit: size - 1},
+		{desc: "inMemory", limit: size, inMemory: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			*frozenLogInMemoryLimit = test.limit
+			ls := storage.NewMockLogStorage(ctrl)
+			tx := storage.NewMockLogTreeTX(ctrl)
+			// The root is read once, either by LoadFrozenLogs or by the first
+			// request, and the leaves are only read to be loaded.
+			ls.EXPECT().SnapshotForTree(gomock.Any(), gomock.Any()).Return(tx, nil)
+			tx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(slr, nil)
+			if test.inMemory {
+				tx.EXPECT().GetLeavesByRange(gomock.Any(), int64(0), int64(size)).Return(leaves, nil)
+			}
+			tx.EXPECT().Commit(gomock.Any()).Return(nil)
+			tx.EXPECT().Close().Return(nil)
+
+			server := NewTrillianLogRPCServer(extension.Registry{AdminStorage: as, LogStorage: ls}, fakeTimeSource)
+			if err := server.LoadFrozenLogs(ctx); err != nil {
+				t.Fatalf("LoadFrozenLogs(): %v", err)
+			}
+			for i := 0; i < 2; i++ {
+				resp, err := server.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: tree.TreeId})
+				if err != nil {
+					t.Fatalf("GetLatestSignedLogRoot() #%d: %v", i, err)
+				}
+				if !proto.Equal(resp.SignedLogRoot, slr) {
+					t.Errorf("GetLatestSignedLogRoot() #%d=%v, want %v", i, resp.SignedLogRoot, slr)
+				}
+			}
+			if !test.inMemory {
+				return
+			}
+
+			verifier := merkle.NewLogVerifier(th)
+			inclusion, err := server.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{LogId: tree.TreeId, LeafIndex: 1, TreeSize: 3})
+			if err != nil {
+				t.Fatalf("GetInclusionProof(): %v", err)
+			}
+			if err := verifier.VerifyInclusionProof(1, 3, inclusion.Proof.Hashes, mt.RootAtSnapshot(3).Hash(), mt.LeafHash(2)); err != nil {
+				t.Errorf("GetInclusionProof() returned a bad proof: %v", err)
+			}
+			consistency, err := server.GetConsistencyProof(ctx, &trillian.GetConsistencyProofRequest{LogId: tree.TreeId, FirstTreeSize: 2, SecondTreeSize: size})
+			if err != nil {
+				t.Fatalf("GetConsistencyProof(): %v", err)
+			}
+			if err := verifier.VerifyConsistencyProof(2, size, mt.RootAtSnapshot(2).Hash(), root.RootHash, consistency.Proof.Hashes); err != nil {
+				t.Errorf("GetConsistencyProof() returned a bad proof: %v", err)
+			}
+			latest, err := server.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: tree.TreeId, FirstTreeSize: 4})
+			if err != nil {
+				t.Fatalf("GetLatestSignedLogRoot(): %v", err)
+			}
+			if err := verifier.VerifyConsistencyProof(4, size, mt.RootAtSnapshot(4).Hash(), root.RootHash, latest.Proof.Hashes); err != nil {
+				t.Errorf("GetLatestSignedLogRoot() returned a bad proof: %v", err)
+			}
+		})
+	}
+}
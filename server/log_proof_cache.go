@@ -0,0 +1,123 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"container/list"
+	"flag"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/util/clock"
+)
+
+var (
+	proofCacheSize = flag.Int("consistency_proof_cache_size", 0, "Number of consistency proofs cached by the log server, so that monitors asking for the same proofs don't each read their nodes from storage. If zero, proofs are not cached")
+	proofCacheTTL  = flag.Duration("consistency_proof_cache_ttl", time.Minute, "How long a cached consistency proof is served for, as long as the log root it was computed with is still the latest one")
+)
+
+// proofCacheKey identifies a consistency proof between two sizes of a log,
+// computed while the log had a given root. A new root changes the key, so
+// proofs are only served from the cache while their root is the latest one.
+type proofCacheKey struct {
+	treeID        int64
+	first, second int64
+	rootHash      string
+}
+
+type proofCacheEntry struct {
+	key     proofCacheKey
+	proof   *trillian.Proof
+	expires time.Time
+}
+
+// proofCache is an LRU cache of log consistency proofs, whose entries expire
+// after a TTL. It is safe for concurrent use.
+type proofCache struct {
+	size        int
+	ttl         time.Duration
+	timeSource  clock.TimeSource
+	hitCounter  monitoring.Counter
+	missCounter monitoring.Counter
+
+	mu      sync.Mutex
+	lru     *list.List // Of *proofCacheEntry, most recently used first.
+	entries map[proofCacheKey]*list.Element
+}
+
+// newProofCache returns a cache of up to size proofs, each served for up to
+// ttl after it's computed.
+func newProofCache(size int, ttl time.Duration, timeSource clock.TimeSource, mf monitoring.MetricFactory) *proofCache {
+	return &proofCache{
+		size:       size,
+		ttl:        ttl,
+		timeSource: timeSource,
+		hitCounter: mf.NewCounter(
+			"consistency_proof_cache_hits",
+			"Number of consistency proofs served from the proof cache",
+			"log_id",
+		),
+		missCounter: mf.NewCounter(
+			"consistency_proof_cache_misses",
+			"Number of consistency proofs not in the proof cache, and read from storage",
+			"log_id",
+		),
+		lru:     list.New(),
+		entries: make(map[proofCacheKey]*list.Element),
+	}
+}
+
+// get returns the cached proof for key, if there is one which hasn't expired.
+// The proof must not be modified.
+func (c *proofCache) get(key proofCacheKey) (*trillian.Proof, bool) {
+	label := strconv.FormatInt(key.treeID, 10)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if ok && c.timeSource.Now().After(elem.Value.(*proofCacheEntry).expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok {
+		c.missCounter.Inc(label)
+		return nil, false
+	}
+	c.hitCounter.Inc(label)
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*proofCacheEntry).proof, true
+}
+
+// put caches proof for key, evicting the least recently used proofs if the
+// cache is full.
+func (c *proofCache) put(key proofCacheKey, proof *trillian.Proof) {
+	e := &proofCacheEntry{key: key, proof: proof, expires: c.timeSource.Now().Add(c.ttl)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = e
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*proofCacheEntry).key)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/tree"
+	"github.com/google/trillian/util/clock"
+
+	stestonly "github.com/google/trillian/storage/testonly"
+)
+
+func TestProofCache(t *testing.T) {
+	ts := clock.NewFake(time.Unix(1000, 0))
+	c := newProofCache(2, time.Minute, ts, monitoring.InertMetricFactory{})
+	k1 := proofCacheKey{treeID: 1, first: 4, second: 7, rootHash: "root"}
+	k2 := proofCacheKey{treeID: 1, first: 5, second: 7, rootHash: "root"}
+	k3 := proofCacheKey{treeID: 2, first: 4, second: 7, rootHash: "root"}
+	p1 := &trillian.Proof{Hashes: [][]byte{[]byte("one")}}
+
+	if _, ok := c.get(k1); ok {
+		t.Fatal("get() on empty cache found a proof")
+	}
+	c.put(k1, p1)
+	if got, ok := c.get(k1); !ok || got != p1 {
+		t.Errorf("get()=%v, %v, want %v, true", got, ok, p1)
+	}
+	if _, ok := c.get(proofCacheKey{treeID: 1, first: 4, second: 7, rootHash: "new root"}); ok {
+		t.Error("get() with a different root hash found a proof")
+	}
+
+	// Using k1 makes k2 the least recently used.
+	c.put(k2, &trillian.Proof{})
+	c.get(k1)
+	c.put(k3, &trillian.Proof{})
+	if _, ok := c.get(k2); ok {
+		t.Error("get() found the least recently used proof, want it evicted")
+	}
+	if _, ok := c.get(k1); !ok {
+		t.Error("get() evicted a recently used proof")
+	}
+
+	ts.Set(ts.Now().Add(time.Minute + time.Second))
+	if _, ok := c.get(k1); ok {
+		t.Error("get() found an expired proof")
+	}
+}
+
+func TestGetConsistencyProofCached(t *testing.T) {
+	defer func(size int) { *proofCacheSize = size }(*proofCacheSize)
+	*proofCacheSize = 10
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fakeStorage := storage.NewMockLogStorage(ctrl)
+	mockTX := storage.NewMockLogTreeTX(ctrl)
+	fakeStorage.EXPECT().SnapshotForTree(gomock.Any(), tree1).Return(mockTX, nil).Times(2)
+	mockTX.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(signedRoot1, nil).Times(2)
+	// The nodes are only read for the first request.
+	mockTX.EXPECT().ReadRevision(gomock.Any()).Return(int64(root1.Revision), nil)
+	mockTX.EXPECT().GetMerkleNodes(gomock.Any(), revision1, nodeIdsConsistencySize4ToSize7).Return(
+		[]tree.Node{{NodeID: stestonly.MustCreateNodeIDForTreeCoords(2, 1, 64), NodeRevision: 3, Hash: []byte("nodehash")}}, nil)
+	mockTX.EXPECT().Commit(gomock.Any()).Return(nil).Times(2)
+	mockTX.EXPECT().Close().Return(nil).Times(2)
+
+	registry := extension.Registry{
+		AdminStorage: fakeAdminStorage(ctrl, storageParams{treeID: logID1, numSnapshots: 2}),
+		LogStorage:   fakeStorage,
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+	want := &trillian.Proof{Hashes: [][]byte{[]byte("nodehash")}}
+	for i := 0; i < 2; i++ {
+		resp, err := server.GetConsistencyProof(context.Background(), &getConsistencyProofRequest7)
+		if err != nil {
+			t.Fatalf("GetConsistencyProof() #%d: %v", i, err)
+		}
+		if !proto.Equal(resp.Proof, want) {
+			t.Errorf("GetConsistencyProof() #%d=%v, want %v", i, resp.Proof, want)
+		}
+	}
+}
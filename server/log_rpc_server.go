@@ -16,14 +16,21 @@ package server
 
 import (
 	"context"
+	"crypto"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian"
+	tcrypto "github.com/google/trillian/crypto"
+	"github.com/google/trillian/crypto/keys/der"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/merkle/hashers"
 	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/server/errmsg"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/trees"
 	"github.com/google/trillian/types"
@@ -35,7 +42,21 @@ import (
 // TODO: There is no access control in the server yet and clients could easily modify
 // any tree.
 
-const traceSpanRoot = "/trillian"
+const (
+	traceSpanRoot = "/trillian"
+
+	// defaultLeafChunkSize is the number of leaves in each
+	// GetLeavesByRangeStream response, if the request doesn't set one.
+	defaultLeafChunkSize = 256
+	// maxLeafChunkSize is the maximum number of leaves in each
+	// GetLeavesByRangeStream response, which keeps responses well within gRPC
+	// message size limits.
+	maxLeafChunkSize = 1024
+)
+
+// integrationPollInterval is the interval at which AddSequencedLeavesStream
+// checks whether the imported leaves have been integrated. Tests change it.
+var integrationPollInterval = time.Second
 
 var (
 	optsLogInit            = trees.NewGetOpts(trees.Admin, trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG)
@@ -50,6 +71,13 @@ type TrillianLogRPCServer struct {
 	timeSource            clock.TimeSource
 	leafCounter           monitoring.Counter
 	proofIndexPercentiles monitoring.Histogram
+	// proofCache is nil if consistency proofs are not cached.
+	proofCache *proofCache
+	// frozenLogs is nil if the state of FROZEN logs is not cached.
+	frozenLogs *frozenLogs
+	// frozenLogLimit is the largest number of leaves of a FROZEN log loaded
+	// into memory by LoadFrozenLogs.
+	frozenLogLimit int64
 }
 
 // NewTrillianLogRPCServer creates a new RPC server backed by a LogStorageProvider.
@@ -58,9 +86,20 @@ func NewTrillianLogRPCServer(registry extension.Registry, timeSource clock.TimeS
 	if mf == nil {
 		mf = monitoring.InertMetricFactory{}
 	}
+	var pc *proofCache
+	if *proofCacheSize > 0 {
+		pc = newProofCache(*proofCacheSize, *proofCacheTTL, timeSource, mf)
+	}
+	var fl *frozenLogs
+	if *frozenLogCache {
+		fl = &frozenLogs{}
+	}
 	return &TrillianLogRPCServer{
-		registry:   registry,
-		timeSource: timeSource,
+		registry:       registry,
+		timeSource:     timeSource,
+		proofCache:     pc,
+		frozenLogs:     fl,
+		frozenLogLimit: *frozenLogInMemoryLimit,
 		leafCounter: mf.NewCounter(
 			"queued_leaves",
 			"Number of leaves requested to be queued",
@@ -74,16 +113,9 @@ func NewTrillianLogRPCServer(registry extension.Registry, timeSource clock.TimeS
 	}
 }
 
-// IsHealthy returns nil if the server is healthy, error otherwise.
-func (t *TrillianLogRPCServer) IsHealthy() error {
-	ctx, spanEnd := spanFor(context.Background(), "IsHealthy")
-	defer spanEnd()
-	return t.registry.LogStorage.CheckDatabaseAccessible(ctx)
-}
-
 // QueueLeaf submits one leaf to the queue.
 func (t *TrillianLogRPCServer) QueueLeaf(ctx context.Context, req *trillian.QueueLeafRequest) (*trillian.QueueLeafResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "QueueLeaf")
+	ctx, spanEnd := spanFor(ctx, "QueueLeaf", treeAttr(req.LogId), batchAttr(1))
 	defer spanEnd()
 	if err := validateLogLeaf(req.Leaf, "QueueLeafRequest.Leaf"); err != nil {
 		return nil, err
@@ -117,7 +149,7 @@ func hashLeaves(leaves []*trillian.LogLeaf, hasher hashers.LogHasher) {
 
 // QueueLeaves submits a batch of leaves to the log for later integration into the underlying tree.
 func (t *TrillianLogRPCServer) QueueLeaves(ctx context.Context, req *trillian.QueueLeavesRequest) (*trillian.QueueLeavesResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "QueueLeaves")
+	ctx, spanEnd := spanFor(ctx, "QueueLeaves", treeAttr(req.LogId), batchAttr(len(req.Leaves)))
 	defer spanEnd()
 	if err := validateLogLeaves(req.Leaves, "QueueLeavesRequest"); err != nil {
 		return nil, err
@@ -132,6 +164,11 @@ func (t *TrillianLogRPCServer) QueueLeaves(ctx context.Context, req *trillian.Qu
 	ctx = trees.NewContext(ctx, tree)
 
 	hashLeaves(req.Leaves, hasher)
+	if tree.DuplicateLeafPolicy == trillian.DuplicateLeafPolicy_ALLOW_DUPLICATES {
+		if err := uniqueIdentityHashes(req.Leaves, hasher); err != nil {
+			return nil, err
+		}
+	}
 
 	ret, err := t.registry.LogStorage.QueueLeaves(ctx, tree, req.Leaves, t.timeSource.Now())
 	if err != nil {
@@ -145,12 +182,17 @@ func (t *TrillianLogRPCServer) QueueLeaves(ctx context.Context, req *trillian.Qu
 			t.leafCounter.Inc("existing")
 		}
 	}
-	return &trillian.QueueLeavesResponse{QueuedLeaves: ret}, nil
+	if tree.DuplicateLeafPolicy == trillian.DuplicateLeafPolicy_DEDUPLICATE {
+		if err := t.deduplicateLeaves(ctx, tree, hasher, ret); err != nil {
+			return nil, err
+		}
+	}
+	return &trillian.QueueLeavesResponse{QueuedLeaves: ret, Backpressure: t.queueBackpressure(ctx, logID)}, nil
 }
 
 // AddSequencedLeaf submits one sequenced leaf to the storage.
 func (t *TrillianLogRPCServer) AddSequencedLeaf(ctx context.Context, req *trillian.AddSequencedLeafRequest) (*trillian.AddSequencedLeafResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "AddSequencedLeaf")
+	ctx, spanEnd := spanFor(ctx, "AddSequencedLeaf", treeAttr(req.LogId), batchAttr(1))
 	defer spanEnd()
 	if err := validateLogLeaf(req.Leaf, "AddSequencedLeafRequest.Leaf"); err != nil {
 		return nil, err
@@ -176,7 +218,7 @@ func (t *TrillianLogRPCServer) AddSequencedLeaf(ctx context.Context, req *trilli
 // AddSequencedLeaves submits a batch of sequenced leaves to a pre-ordered log
 // for later integration into its underlying tree.
 func (t *TrillianLogRPCServer) AddSequencedLeaves(ctx context.Context, req *trillian.AddSequencedLeavesRequest) (*trillian.AddSequencedLeavesResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "AddSequencedLeaves")
+	ctx, spanEnd := spanFor(ctx, "AddSequencedLeaves", treeAttr(req.LogId), batchAttr(len(req.Leaves)))
 	defer spanEnd()
 	if err := validateAddSequencedLeavesRequest(req); err != nil {
 		return nil, err
@@ -201,10 +243,103 @@ func (t *TrillianLogRPCServer) AddSequencedLeaves(ctx context.Context, req *tril
 	return &trillian.AddSequencedLeavesResponse{Results: leaves}, nil
 }
 
+// AddSequencedLeavesStream adds the leaves of each request of the stream to a
+// pre-ordered log, as AddSequencedLeaves does, checking that their indices
+// continue from the previous request. Once the client closes the stream, it
+// waits for the leaves to be integrated if the first request asks for it.
+func (t *TrillianLogRPCServer) AddSequencedLeavesStream(stream trillian.TrillianLog_AddSequencedLeavesStreamServer) error {
+	ctx, spanEnd := spanFor(stream.Context(), "AddSequencedLeavesStream")
+	defer spanEnd()
+
+	var first *trillian.AddSequencedLeavesStreamRequest
+	var tree *trillian.Tree
+	var hasher hashers.LogHasher
+	var nextIndex int64
+	resp := &trillian.AddSequencedLeavesStreamResponse{}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := validateAddSequencedLeavesStreamRequest(req, first == nil, nextIndex); err != nil {
+			return err
+		}
+		if first == nil {
+			first = req
+			if tree, hasher, err = t.getTreeAndHasher(ctx, req.LogId, optsPreorderedLogWrite); err != nil {
+				return err
+			}
+			ctx = trees.NewContext(ctx, tree)
+		} else if req.LogId != first.LogId {
+			return status.Errorf(codes.InvalidArgument, "AddSequencedLeavesStreamRequest.LogId: got %d, want %d as in the first request", req.LogId, first.LogId)
+		}
+
+		hashLeaves(req.Leaves, hasher)
+		results, err := t.registry.LogStorage.AddSequencedLeaves(ctx, tree, req.Leaves, t.timeSource.Now())
+		if err != nil {
+			return err
+		}
+		if got, want := len(results), len(req.Leaves); got != want {
+			return status.Errorf(codes.Internal, "AddSequencedLeaves returned %d leaves, want: %d", got, want)
+		}
+		for _, r := range results {
+			switch c := codes.Code(r.GetStatus().GetCode()); c {
+			case codes.OK:
+			case codes.AlreadyExists:
+				resp.DuplicateCount++
+			default:
+				return status.Errorf(c, "AddSequencedLeaves(): %s", r.GetStatus().GetMessage())
+			}
+		}
+		resp.LeafCount += int64(len(req.Leaves))
+		nextIndex = req.Leaves[len(req.Leaves)-1].LeafIndex + 1
+	}
+	if first == nil {
+		return errEmpty("AddSequencedLeavesStreamRequest.Leaves")
+	}
+
+	if first.WaitForIntegration {
+		slr, err := t.awaitTreeSize(ctx, tree, nextIndex)
+		if err != nil {
+			return err
+		}
+		resp.SignedLogRoot = slr
+	}
+	return stream.SendAndClose(resp)
+}
+
+// awaitTreeSize polls the latest root of tree until it has at least size
+// leaves, and returns it.
+func (t *TrillianLogRPCServer) awaitTreeSize(ctx context.Context, tree *trillian.Tree, size int64) (*trillian.SignedLogRoot, error) {
+	ticker := time.NewTicker(integrationPollInterval)
+	defer ticker.Stop()
+	for {
+		slr, err := t.latestRoot(ctx, tree, "AddSequencedLeavesStream")
+		if err != nil {
+			return nil, err
+		}
+		var root types.LogRootV1
+		if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+			return nil, status.Errorf(codes.Internal, "Could not read current log root: %v", err)
+		}
+		if int64(root.TreeSize) >= size {
+			return slr, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
 // GetInclusionProof obtains the proof of inclusion in the tree for a leaf that has been sequenced.
 // Similar to the get proof by hash handler but one less step as we don't need to look up the index
 func (t *TrillianLogRPCServer) GetInclusionProof(ctx context.Context, req *trillian.GetInclusionProofRequest) (*trillian.GetInclusionProofResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetInclusionProof")
+	ctx, spanEnd := spanFor(ctx, "GetInclusionProof", treeAttr(req.LogId), revisionAttr(req.TreeSize))
 	defer spanEnd()
 	if err := validateGetInclusionProofRequest(req); err != nil {
 		return nil, err
@@ -216,6 +351,21 @@ func (t *TrillianLogRPCServer) GetInclusionProof(ctx context.Context, req *trill
 		return nil, err
 	}
 	ctx = trees.NewContext(ctx, tree)
+	frozen, err := t.frozenLog(ctx, tree, "GetInclusionProof")
+	if err != nil {
+		return nil, err
+	}
+	if frozen != nil && frozen.nodes != nil {
+		r := &trillian.GetInclusionProofResponse{SignedLogRoot: frozen.slr}
+		if uint64(req.TreeSize) > frozen.root.TreeSize {
+			return r, nil
+		}
+		if r.Proof, err = frozen.nodes.inclusionProof(hasher, req.TreeSize, req.LeafIndex, int64(frozen.root.TreeSize)); err != nil {
+			return nil, err
+		}
+		t.recordIndexPercent(req.LeafIndex, frozen.root.TreeSize)
+		return r, nil
+	}
 
 	// Next we need to make sure the requested tree size corresponds to an STH, so that we
 	// have a usable tree revision
@@ -258,7 +408,7 @@ func (t *TrillianLogRPCServer) GetInclusionProof(ctx context.Context, req *trill
 // GetInclusionProofByHash obtains proofs of inclusion by leaf hash. Because some logs can
 // contain duplicate hashes it is possible for multiple proofs to be returned.
 func (t *TrillianLogRPCServer) GetInclusionProofByHash(ctx context.Context, req *trillian.GetInclusionProofByHashRequest) (*trillian.GetInclusionProofByHashResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetInclusionProofByHash")
+	ctx, spanEnd := spanFor(ctx, "GetInclusionProofByHash", treeAttr(req.LogId), revisionAttr(req.TreeSize))
 	defer spanEnd()
 
 	tree, hasher, err := t.getTreeAndHasher(ctx, req.LogId, optsLogRead)
@@ -314,8 +464,10 @@ func (t *TrillianLogRPCServer) GetInclusionProofByHash(ctx context.Context, req
 		return nil, err
 	}
 	if len(proofs) < 1 {
-		return nil, status.Errorf(codes.NotFound,
-			"No leaf found for hash: %x in tree size %v", req.LeafHash, req.TreeSize)
+		return nil, errmsg.New(codes.NotFound, errmsg.LeafHashNotFound, errmsg.Params{
+			"hash": hex.EncodeToString(req.LeafHash),
+			"size": req.TreeSize,
+		})
 	}
 
 	// TODO(gbelvin): Rename "Proof" -> "Proofs"
@@ -325,11 +477,97 @@ func (t *TrillianLogRPCServer) GetInclusionProofByHash(ctx context.Context, req
 	}, nil
 }
 
+// GetInclusionProofByHashBatch obtains proofs of inclusion for many leaf hashes, all
+// read from one snapshot of the tree. Hashes which aren't in the requested tree size
+// have no proofs, rather than failing the whole batch.
+func (t *TrillianLogRPCServer) GetInclusionProofByHashBatch(ctx context.Context, req *trillian.GetInclusionProofByHashBatchRequest) (*trillian.GetInclusionProofByHashBatchResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "GetInclusionProofByHashBatch", treeAttr(req.LogId), revisionAttr(req.TreeSize), batchAttr(len(req.LeafHash)))
+	defer spanEnd()
+
+	tree, hasher, err := t.getTreeAndHasher(ctx, req.LogId, optsLogRead)
+	if err != nil {
+		return nil, err
+	}
+	ctx = trees.NewContext(ctx, tree)
+
+	if err := validateGetInclusionProofByHashBatchRequest(req, hasher); err != nil {
+		return nil, err
+	}
+
+	tx, err := t.snapshotForTree(ctx, tree, "GetInclusionProofByHashBatch")
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetInclusionProofByHashBatch")
+
+	// A hash requested more than once is read, and proven, once.
+	seen := make(map[string]bool)
+	leafHashes := make([][]byte, 0, len(req.LeafHash))
+	for _, hash := range req.LeafHash {
+		if !seen[string(hash)] {
+			seen[string(hash)] = true
+			leafHashes = append(leafHashes, hash)
+		}
+	}
+	leaves, err := tx.GetLeavesByHash(ctx, leafHashes, req.OrderBySequence)
+	if err != nil {
+		return nil, err
+	}
+
+	slr, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not read current log root: %v", err)
+	}
+	rev, err := tx.ReadRevision(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build one proof per leaf within the requested size, and group them by hash.
+	proofs := make(map[string][]*trillian.Proof)
+	for _, leaf := range leaves {
+		if leaf.LeafIndex >= req.TreeSize {
+			continue
+		}
+		proofNodeIDs, err := merkle.CalcInclusionProofNodeAddresses(req.TreeSize, leaf.LeafIndex, int64(root.TreeSize))
+		if err != nil {
+			return nil, err
+		}
+		proof, err := fetchNodesAndBuildProof(ctx, tx, hasher, rev, leaf.LeafIndex, proofNodeIDs)
+		if err != nil {
+			return nil, err
+		}
+		key := string(leaf.MerkleLeafHash)
+		proofs[key] = append(proofs[key], proof)
+		t.recordIndexPercent(leaf.LeafIndex, root.TreeSize)
+	}
+
+	if err := t.commitAndLog(ctx, req.LogId, tx, "GetInclusionProofByHashBatch"); err != nil {
+		return nil, err
+	}
+
+	resp := &trillian.GetInclusionProofByHashBatchResponse{
+		Proofs:        make([]*trillian.GetInclusionProofByHashBatchResponse_LeafHashProofs, 0, len(req.LeafHash)),
+		SignedLogRoot: slr,
+	}
+	for _, hash := range req.LeafHash {
+		resp.Proofs = append(resp.Proofs, &trillian.GetInclusionProofByHashBatchResponse_LeafHashProofs{
+			LeafHash: hash,
+			Proof:    proofs[string(hash)],
+		})
+	}
+	return resp, nil
+}
+
 // GetConsistencyProof obtains a proof that two versions of the tree are consistent with each
 // other and that the later tree includes all the entries of the prior one. For more details
 // see the example trees in RFC 6962.
 func (t *TrillianLogRPCServer) GetConsistencyProof(ctx context.Context, req *trillian.GetConsistencyProofRequest) (*trillian.GetConsistencyProofResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetConsistencyProof")
+	ctx, spanEnd := spanFor(ctx, "GetConsistencyProof", treeAttr(req.LogId), revisionAttr(req.SecondTreeSize))
 	defer spanEnd()
 	if err := validateGetConsistencyProofRequest(req); err != nil {
 		return nil, err
@@ -341,6 +579,20 @@ func (t *TrillianLogRPCServer) GetConsistencyProof(ctx context.Context, req *tri
 		return nil, err
 	}
 	ctx = trees.NewContext(ctx, tree)
+	frozen, err := t.frozenLog(ctx, tree, "GetConsistencyProof")
+	if err != nil {
+		return nil, err
+	}
+	if frozen != nil && frozen.nodes != nil {
+		r := &trillian.GetConsistencyProofResponse{SignedLogRoot: frozen.slr}
+		if uint64(req.SecondTreeSize) > frozen.root.TreeSize {
+			return r, nil
+		}
+		if r.Proof, err = frozen.nodes.consistencyProof(hasher, req.FirstTreeSize, req.SecondTreeSize, int64(frozen.root.TreeSize)); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
 
 	tx, err := t.snapshotForTree(ctx, tree, "GetConsistencyProof")
 	if err != nil {
@@ -362,7 +614,7 @@ func (t *TrillianLogRPCServer) GetConsistencyProof(ctx context.Context, req *tri
 		return r, nil
 	}
 	// Try to get consistency proof
-	proof, err := tryGetConsistencyProof(ctx, req.FirstTreeSize, req.SecondTreeSize, int64(root.TreeSize), tx, hasher)
+	proof, err := t.consistencyProof(ctx, tree.TreeId, req.FirstTreeSize, req.SecondTreeSize, &root, tx, hasher)
 	if err != nil {
 		return nil, err
 	}
@@ -376,16 +628,110 @@ func (t *TrillianLogRPCServer) GetConsistencyProof(ctx context.Context, req *tri
 	return r, nil
 }
 
+// GetConsistencyProofByRootHash obtains a consistency proof between two roots
+// of the log identified by their root hashes, which must be of roots the log
+// has published.
+func (t *TrillianLogRPCServer) GetConsistencyProofByRootHash(ctx context.Context, req *trillian.GetConsistencyProofByRootHashRequest) (*trillian.GetConsistencyProofByRootHashResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "GetConsistencyProofByRootHash", treeAttr(req.LogId))
+	defer spanEnd()
+	if err := validateGetConsistencyProofByRootHashRequest(req); err != nil {
+		return nil, err
+	}
+
+	tree, hasher, err := t.getTreeAndHasher(ctx, req.LogId, optsLogRead)
+	if err != nil {
+		return nil, err
+	}
+	ctx = trees.NewContext(ctx, tree)
+
+	tx, err := t.snapshotForTree(ctx, tree, "GetConsistencyProofByRootHash")
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetConsistencyProofByRootHash")
+
+	first, firstRoot, err := publishedLogRoot(ctx, tx, req.FirstRootHash, "GetConsistencyProofByRootHashRequest.FirstRootHash")
+	if err != nil {
+		return nil, err
+	}
+	second, secondRoot, err := publishedLogRoot(ctx, tx, req.SecondRootHash, "GetConsistencyProofByRootHashRequest.SecondRootHash")
+	if err != nil {
+		return nil, err
+	}
+	if firstRoot.TreeSize > secondRoot.TreeSize {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.RootsOutOfOrder, errmsg.Params{"first": firstRoot.TreeSize, "second": secondRoot.TreeSize})
+	}
+	r := &trillian.GetConsistencyProofByRootHashResponse{FirstSignedLogRoot: first, SecondSignedLogRoot: second}
+
+	// Every tree is consistent with the empty one.
+	if firstRoot.TreeSize == 0 {
+		r.Proof = &trillian.Proof{}
+	} else {
+		slr, err := tx.LatestSignedLogRoot(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var root types.LogRootV1
+		if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+			return nil, status.Errorf(codes.Internal, "Could not read current log root: %v", err)
+		}
+		r.Proof, err = t.consistencyProof(ctx, tree.TreeId, int64(firstRoot.TreeSize), int64(secondRoot.TreeSize), &root, tx, hasher)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// publishedLogRoot returns the earliest root of the log with rootHash, or a
+// NotFound error naming field if the log hasn't published one.
+func publishedLogRoot(ctx context.Context, tx storage.ReadOnlyLogTreeTX, rootHash []byte, field string) (*trillian.SignedLogRoot, *types.LogRootV1, error) {
+	slr, err := tx.GetSignedLogRootByHash(ctx, rootHash)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil, errmsg.New(codes.NotFound, errmsg.RootHashNotPublished, errmsg.Params{"field": field, "hash": fmt.Sprintf("%x", rootHash)})
+	} else if err != nil {
+		return nil, nil, err
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "Could not read log root: %v", err)
+	}
+	return slr, &root, nil
+}
+
 // GetLatestSignedLogRoot obtains the latest published tree root for the Merkle Tree that
 // underlies the log.
 func (t *TrillianLogRPCServer) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest) (*trillian.GetLatestSignedLogRootResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetLatestSignedLogRoot")
+	ctx, spanEnd := spanFor(ctx, "GetLatestSignedLogRoot", treeAttr(req.LogId))
 	defer spanEnd()
 	tree, hasher, err := t.getTreeAndHasher(ctx, req.LogId, optsLogRead)
 	if err != nil {
 		return nil, err
 	}
 	ctx = trees.NewContext(ctx, tree)
+	frozen, err := t.frozenLog(ctx, tree, "GetLatestSignedLogRoot")
+	if err != nil {
+		return nil, err
+	}
+	if frozen != nil && (req.FirstTreeSize == 0 || frozen.nodes != nil) {
+		r := &trillian.GetLatestSignedLogRootResponse{SignedLogRoot: frozen.slr}
+		if req.FirstTreeSize == 0 {
+			return r, nil
+		}
+		second := int64(frozen.root.TreeSize)
+		if err := validateGetConsistencyProofRequest(&trillian.GetConsistencyProofRequest{LogId: req.LogId, FirstTreeSize: int64(req.FirstTreeSize), SecondTreeSize: second}); err != nil {
+			return nil, err
+		}
+		if r.Proof, err = frozen.nodes.consistencyProof(hasher, int64(req.FirstTreeSize), second, second); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+
 	tx, err := t.registry.LogStorage.SnapshotForTree(ctx, tree)
 	if err != nil {
 		return nil, err
@@ -421,7 +767,7 @@ func (t *TrillianLogRPCServer) GetLatestSignedLogRoot(ctx context.Context, req *
 		return nil, err
 	}
 	// Try to get consistency proof
-	proof, err := tryGetConsistencyProof(ctx, reqProof.FirstTreeSize, reqProof.SecondTreeSize, int64(root.TreeSize), tx, hasher)
+	proof, err := t.consistencyProof(ctx, tree.TreeId, reqProof.FirstTreeSize, reqProof.SecondTreeSize, &root, tx, hasher)
 	if err != nil {
 		return nil, err
 	}
@@ -433,6 +779,24 @@ func (t *TrillianLogRPCServer) GetLatestSignedLogRoot(ctx context.Context, req *
 	return r, nil
 }
 
+// consistencyProof returns the consistency proof between two sizes of a log
+// whose latest root is root, from the proof cache if it has it.
+func (t *TrillianLogRPCServer) consistencyProof(ctx context.Context, treeID, firstTreeSize, secondTreeSize int64, root *types.LogRootV1, tx storage.ReadOnlyLogTreeTX, hasher hashers.LogHasher) (*trillian.Proof, error) {
+	if t.proofCache == nil {
+		return tryGetConsistencyProof(ctx, firstTreeSize, secondTreeSize, int64(root.TreeSize), tx, hasher)
+	}
+	key := proofCacheKey{treeID: treeID, first: firstTreeSize, second: secondTreeSize, rootHash: string(root.RootHash)}
+	if proof, ok := t.proofCache.get(key); ok {
+		return proof, nil
+	}
+	proof, err := tryGetConsistencyProof(ctx, firstTreeSize, secondTreeSize, int64(root.TreeSize), tx, hasher)
+	if err != nil {
+		return nil, err
+	}
+	t.proofCache.put(key, proof)
+	return proof, nil
+}
+
 func tryGetConsistencyProof(ctx context.Context, firstTreeSize, secondTreeSize, rootTreeSize int64, tx storage.ReadOnlyLogTreeTX, hasher hashers.LogHasher) (*trillian.Proof, error) {
 	nodeFetches, err := merkle.CalcConsistencyProofNodeAddresses(firstTreeSize, secondTreeSize, rootTreeSize)
 	if err != nil {
@@ -455,7 +819,7 @@ func tryGetConsistencyProof(ctx context.Context, firstTreeSize, secondTreeSize,
 // GetSequencedLeafCount returns the number of leaves that have been integrated into the Merkle
 // Tree. This can be zero for a log containing no entries.
 func (t *TrillianLogRPCServer) GetSequencedLeafCount(ctx context.Context, req *trillian.GetSequencedLeafCountRequest) (*trillian.GetSequencedLeafCountResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetSequencedLeafCount")
+	ctx, spanEnd := spanFor(ctx, "GetSequencedLeafCount", treeAttr(req.LogId))
 	defer spanEnd()
 	tree, ctx, err := t.getTreeAndContext(ctx, req.LogId, optsLogRead)
 	if err != nil {
@@ -484,7 +848,7 @@ func (t *TrillianLogRPCServer) GetSequencedLeafCount(ctx context.Context, req *t
 // TODO: Validate indices against published tree size in case we implement write sharding that
 // can get ahead of this point. Not currently clear what component should own this state.
 func (t *TrillianLogRPCServer) GetLeavesByIndex(ctx context.Context, req *trillian.GetLeavesByIndexRequest) (*trillian.GetLeavesByIndexResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetLeavesByIndex")
+	ctx, spanEnd := spanFor(ctx, "GetLeavesByIndex", treeAttr(req.LogId), batchAttr(len(req.LeafIndex)))
 	defer spanEnd()
 	if err := validateGetLeavesByIndexRequest(req); err != nil {
 		return nil, err
@@ -525,7 +889,7 @@ func (t *TrillianLogRPCServer) GetLeavesByIndex(ctx context.Context, req *trilli
 // This only fetches sequenced leaves; leaves that have been queued but not yet integrated
 // are not visible.
 func (t *TrillianLogRPCServer) GetLeavesByRange(ctx context.Context, req *trillian.GetLeavesByRangeRequest) (*trillian.GetLeavesByRangeResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetLeavesByRange")
+	ctx, spanEnd := spanFor(ctx, "GetLeavesByRange", treeAttr(req.LogId), revisionAttr(req.TreeSize), batchAttr(int(req.Count)))
 	defer spanEnd()
 	if err := validateGetLeavesByRangeRequest(req); err != nil {
 		return nil, err
@@ -552,8 +916,23 @@ func (t *TrillianLogRPCServer) GetLeavesByRange(ctx context.Context, req *trilli
 
 	r := &trillian.GetLeavesByRangeResponse{SignedLogRoot: slr}
 
-	if req.StartIndex < int64(root.TreeSize) {
-		leaves, err := tx.GetLeavesByRange(ctx, req.StartIndex, req.Count)
+	// Read the range at a single tree size, so that leaves sequenced
+	// concurrently, e.g. into a pre-ordered log, are never returned.
+	size := int64(root.TreeSize)
+	if req.TreeSize > 0 {
+		if req.TreeSize > size {
+			return r, nil
+		}
+		size = req.TreeSize
+	}
+	r.TreeSize = size
+
+	if req.StartIndex < size {
+		count := req.Count
+		if count > size-req.StartIndex {
+			count = size - req.StartIndex
+		}
+		leaves, err := tx.GetLeavesByRange(ctx, req.StartIndex, count)
 		if err != nil {
 			return nil, err
 		}
@@ -567,11 +946,131 @@ func (t *TrillianLogRPCServer) GetLeavesByRange(ctx context.Context, req *trilli
 	return r, nil
 }
 
+// GetLeavesByRangeStream streams the leaves in a sequential range, in chunks.
+// The tree size is read once, from the latest root, and each chunk is then
+// read in its own snapshot, so that the stream doesn't hold a transaction open
+// for as long as the client takes to read it. Sending blocks while the client
+// is behind, as gRPC flow control applies.
+func (t *TrillianLogRPCServer) GetLeavesByRangeStream(req *trillian.GetLeavesByRangeStreamRequest, stream trillian.TrillianLog_GetLeavesByRangeStreamServer) error {
+	ctx, spanEnd := spanFor(stream.Context(), "GetLeavesByRangeStream", treeAttr(req.LogId), revisionAttr(req.TreeSize), batchAttr(int(req.ChunkSize)))
+	defer spanEnd()
+	if err := validateGetLeavesByRangeStreamRequest(req); err != nil {
+		return err
+	}
+	chunkSize := int64(req.ChunkSize)
+	switch {
+	case chunkSize == 0:
+		chunkSize = defaultLeafChunkSize
+	case chunkSize > maxLeafChunkSize:
+		chunkSize = maxLeafChunkSize
+	}
+
+	tree, ctx, err := t.getTreeAndContext(ctx, req.LogId, optsLogRead)
+	if err != nil {
+		return err
+	}
+	slr, err := t.latestRoot(ctx, tree, "GetLeavesByRangeStream")
+	if err != nil {
+		return err
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return status.Errorf(codes.Internal, "Could not read current log root: %v", err)
+	}
+
+	resp := &trillian.GetLeavesByRangeStreamResponse{SignedLogRoot: slr}
+	// As in GetLeavesByRange, all the leaves are read at a single tree size.
+	size := int64(root.TreeSize)
+	if req.TreeSize > 0 {
+		if req.TreeSize > size {
+			size = 0
+		} else {
+			size = req.TreeSize
+		}
+	}
+	resp.TreeSize = size
+	end := size
+	if req.Count > 0 && req.Count < end-req.StartIndex {
+		end = req.StartIndex + req.Count
+	}
+
+	for start := req.StartIndex; ; {
+		if start < end {
+			count := end - start
+			if count > chunkSize {
+				count = chunkSize
+			}
+			leaves, err := t.leafChunk(ctx, tree, start, count)
+			if err != nil {
+				return err
+			}
+			if len(leaves) == 0 {
+				return status.Errorf(codes.Internal, "no leaves at index %d, below tree size %d", start, size)
+			}
+			resp.Leaves = leaves
+			start += int64(len(leaves))
+		}
+		// Always send the first response, so that the client gets the log root
+		// even if the range is empty.
+		if len(resp.Leaves) > 0 || resp.SignedLogRoot != nil {
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+		if start >= end {
+			return nil
+		}
+		resp = &trillian.GetLeavesByRangeStreamResponse{}
+	}
+}
+
+// latestRoot returns the latest root of tree, read in its own snapshot unless
+// tree is a cached FROZEN log.
+func (t *TrillianLogRPCServer) latestRoot(ctx context.Context, tree *trillian.Tree, method string) (*trillian.SignedLogRoot, error) {
+	frozen, err := t.frozenLog(ctx, tree, method)
+	if err != nil {
+		return nil, err
+	}
+	if frozen != nil {
+		return frozen.slr, nil
+	}
+	return t.readLatestRoot(ctx, tree, method)
+}
+
+// readLatestRoot returns the latest root of tree, read in its own snapshot.
+func (t *TrillianLogRPCServer) readLatestRoot(ctx context.Context, tree *trillian.Tree, method string) (*trillian.SignedLogRoot, error) {
+	tx, err := t.snapshotForTree(ctx, tree, method)
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, method)
+	slr, err := tx.LatestSignedLogRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return slr, t.commitAndLog(ctx, tree.TreeId, tx, method)
+}
+
+// leafChunk returns up to count leaves of tree from index start, read in their
+// own snapshot.
+func (t *TrillianLogRPCServer) leafChunk(ctx context.Context, tree *trillian.Tree, start, count int64) ([]*trillian.LogLeaf, error) {
+	tx, err := t.snapshotForTree(ctx, tree, "GetLeavesByRangeStream")
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetLeavesByRangeStream")
+	leaves, err := tx.GetLeavesByRange(ctx, start, count)
+	if err != nil {
+		return nil, err
+	}
+	return leaves, t.commitAndLog(ctx, tree.TreeId, tx, "GetLeavesByRangeStream")
+}
+
 // GetLeavesByHash obtains one or more leaves based on their tree hash. It is not possible
 // to fetch leaves that have been queued but not yet integrated. Logs may accept duplicate
 // entries so this may return more results than the number of hashes in the request.
 func (t *TrillianLogRPCServer) GetLeavesByHash(ctx context.Context, req *trillian.GetLeavesByHashRequest) (*trillian.GetLeavesByHashResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetLeavesByHash")
+	ctx, spanEnd := spanFor(ctx, "GetLeavesByHash", treeAttr(req.LogId), batchAttr(len(req.LeafHash)))
 	defer spanEnd()
 
 	tree, hasher, err := t.getTreeAndHasher(ctx, req.LogId, optsLogRead)
@@ -616,7 +1115,7 @@ func (t *TrillianLogRPCServer) GetLeavesByHash(ctx context.Context, req *trillia
 // GetEntryAndProof returns both a Merkle Leaf entry and an inclusion proof for a given index
 // and tree size.
 func (t *TrillianLogRPCServer) GetEntryAndProof(ctx context.Context, req *trillian.GetEntryAndProofRequest) (*trillian.GetEntryAndProofResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetEntryAndProof")
+	ctx, spanEnd := spanFor(ctx, "GetEntryAndProof", treeAttr(req.LogId), revisionAttr(req.TreeSize))
 	defer spanEnd()
 	if err := validateGetEntryAndProofRequest(req); err != nil {
 		return nil, err
@@ -683,6 +1182,98 @@ func (t *TrillianLogRPCServer) GetEntryAndProof(ctx context.Context, req *trilli
 	return r, nil
 }
 
+// AddLogRootSignature implements the AddLogRootSignature RPC method.
+func (t *TrillianLogRPCServer) AddLogRootSignature(ctx context.Context, req *trillian.AddLogRootSignatureRequest) (*trillian.AddLogRootSignatureResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "AddLogRootSignature", treeAttr(req.LogId), revisionAttr(req.Revision))
+	defer spanEnd()
+	if req.Revision < 0 {
+		return nil, errNegative("AddLogRootSignatureRequest.Revision", req.Revision)
+	}
+	pub, err := der.FromPublicProto(req.GetSignature().GetPublicKey())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "AddLogRootSignatureRequest.Signature.PublicKey: %v", err)
+	}
+	tree, ctx, err := t.getTreeAndContext(ctx, req.LogId, optsLogRead)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.registry.LogStorage.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		root, err := tx.GetSignedLogRoot(ctx, req.Revision)
+		if err != nil {
+			return err
+		}
+		if err := tcrypto.Verify(pub, crypto.SHA256, root.LogRoot, req.Signature.Signature); err != nil {
+			glog.V(1).Infof("%v: witness signature over revision %d does not verify: %v", req.LogId, req.Revision, err)
+			return errmsg.New(codes.InvalidArgument, errmsg.LogRootSignatureInvalid, errmsg.Params{"revision": req.Revision})
+		}
+		return tx.StoreLogRootSignature(ctx, req.Revision, req.Signature)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.AddLogRootSignatureResponse{}, nil
+}
+
+// GetLogRootSignatures implements the GetLogRootSignatures RPC method.
+func (t *TrillianLogRPCServer) GetLogRootSignatures(ctx context.Context, req *trillian.GetLogRootSignaturesRequest) (*trillian.GetLogRootSignaturesResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "GetLogRootSignatures", treeAttr(req.LogId), revisionAttr(req.Revision))
+	defer spanEnd()
+	if req.Revision < 0 {
+		return nil, errNegative("GetLogRootSignaturesRequest.Revision", req.Revision)
+	}
+	tree, ctx, err := t.getTreeAndContext(ctx, req.LogId, optsLogRead)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := t.snapshotForTree(ctx, tree, "GetLogRootSignatures")
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetLogRootSignatures")
+
+	root, err := tx.GetSignedLogRoot(ctx, req.Revision)
+	if err != nil {
+		return nil, err
+	}
+	sigs, err := tx.GetLogRootSignatures(ctx, req.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.commitAndLog(ctx, req.LogId, tx, "GetLogRootSignatures"); err != nil {
+		return nil, err
+	}
+	return &trillian.GetLogRootSignaturesResponse{SignedLogRoot: root, Signatures: sigs}, nil
+}
+
+// GetCheckpoint returns the latest root of the log as a signed checkpoint.
+func (t *TrillianLogRPCServer) GetCheckpoint(ctx context.Context, req *trillian.GetCheckpointRequest) (*trillian.GetCheckpointResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "GetCheckpoint", treeAttr(req.LogId))
+	defer spanEnd()
+	if _, err := checkpointOrigin(req.LogId); err != nil {
+		return nil, err
+	}
+	tree, ctx, err := t.getTreeAndContext(ctx, req.LogId, optsLogRead)
+	if err != nil {
+		return nil, err
+	}
+	slr, err := t.latestRoot(ctx, tree, "GetCheckpoint")
+	if err != nil {
+		return nil, err
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not read current log root: %v", err)
+	}
+
+	cp, err := signedCheckpoint(ctx, tree, root.TreeSize, root.RootHash)
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.GetCheckpointResponse{Checkpoint: cp, SignedLogRoot: slr}, nil
+}
+
 func (t *TrillianLogRPCServer) commitAndLog(ctx context.Context, logID int64, tx storage.ReadOnlyLogTreeTX, op string) error {
 	err := tx.Commit(ctx)
 	if err != nil {
@@ -738,7 +1329,7 @@ func (t *TrillianLogRPCServer) getTreeAndContext(ctx context.Context, treeID int
 // InitLog initialises a freshly created Log by creating the first STH with
 // size 0.
 func (t *TrillianLogRPCServer) InitLog(ctx context.Context, req *trillian.InitLogRequest) (*trillian.InitLogResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "InitLog")
+	ctx, spanEnd := spanFor(ctx, "InitLog", treeAttr(req.LogId))
 	defer spanEnd()
 	logID := req.LogId
 	tree, hasher, err := t.getTreeAndHasher(ctx, logID, optsLogInit)
@@ -757,7 +1348,7 @@ func (t *TrillianLogRPCServer) InitLog(ctx context.Context, req *trillian.InitLo
 
 		// Belt and braces check.
 		if latestRoot.GetLogRoot() != nil {
-			return status.Errorf(codes.AlreadyExists, "log is already initialised")
+			return errmsg.New(codes.AlreadyExists, errmsg.TreeAlreadyInitialized, errmsg.Params{"tree_type": "log"})
 		}
 
 		signer, err := trees.Signer(ctx, tree)
@@ -797,8 +1388,28 @@ func (t *TrillianLogRPCServer) recordIndexPercent(leafIndex int64, treeSize uint
 	}
 }
 
-func spanFor(ctx context.Context, name string) (context.Context, func()) {
-	return monitoring.StartSpan(ctx, fmt.Sprintf("%s.%s", traceSpanRoot, name))
+// spanFor starts a span for the RPC method, or step of one, name, annotated
+// with the name and attrs.
+func spanFor(ctx context.Context, name string, attrs ...monitoring.Attribute) (context.Context, func()) {
+	attrs = append(attrs, monitoring.StringAttribute(monitoring.RPCKey, name))
+	return monitoring.StartSpan(ctx, fmt.Sprintf("%s.%s", traceSpanRoot, name), attrs...)
+}
+
+// treeAttr returns the span attribute of the tree ID of a request.
+func treeAttr(treeID int64) monitoring.Attribute {
+	return monitoring.Int64Attribute(monitoring.TreeIDKey, treeID)
+}
+
+// revisionAttr returns the span attribute of the revision, or tree size, of a
+// request.
+func revisionAttr(rev int64) monitoring.Attribute {
+	return monitoring.Int64Attribute(monitoring.RevisionKey, rev)
+}
+
+// batchAttr returns the span attribute of the number of leaves or indices of
+// a request.
+func batchAttr(n int) monitoring.Attribute {
+	return monitoring.Int64Attribute(monitoring.BatchSizeKey, int64(n))
 }
 
 func (t *TrillianLogRPCServer) snapshotForTree(ctx context.Context, tree *trillian.Tree, method string) (storage.ReadOnlyLogTreeTX, error) {
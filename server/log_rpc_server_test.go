@@ -17,8 +17,12 @@ package server
 import (
 	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"testing"
 	"time"
@@ -27,16 +31,21 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keys/der"
 	"github.com/google/trillian/crypto/keys/pem"
+	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/crypto/sigpb"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/merkle/rfc6962"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/server/errmsg"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/testonly"
 	"github.com/google/trillian/types"
 	"github.com/google/trillian/util/clock"
 	"github.com/kylelemons/godebug/pretty"
 	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
@@ -280,6 +289,10 @@ func TestGetLeavesByRange(t *testing.T) {
 
 	var tests = []struct {
 		start, count int64
+		treeSize     int64
+		// getCount is the count read from storage, if not count.
+		getCount     int64
+		skipGet      bool
 		skipTX       bool
 		adminErr     error
 		txErr        error
@@ -287,6 +300,7 @@ func TestGetLeavesByRange(t *testing.T) {
 		slrErr       error
 		root         *trillian.SignedLogRoot
 		want         []*trillian.LogLeaf
+		wantTreeSize int64
 		wantErr      string
 	}{
 		{
@@ -296,9 +310,10 @@ func TestGetLeavesByRange(t *testing.T) {
 			wantErr:  "admin_err",
 		},
 		{
-			start: 1,
-			count: 1,
-			want:  []*trillian.LogLeaf{leaf1},
+			start:        1,
+			count:        1,
+			want:         []*trillian.LogLeaf{leaf1},
+			wantTreeSize: 7,
 		},
 		{
 			start:   1,
@@ -326,14 +341,52 @@ func TestGetLeavesByRange(t *testing.T) {
 			wantErr: "test error plugh",
 		},
 		{
-			start: 1,
-			count: 3,
-			want:  []*trillian.LogLeaf{leaf1, leaf2, leaf3},
+			start:        1,
+			count:        3,
+			want:         []*trillian.LogLeaf{leaf1, leaf2, leaf3},
+			wantTreeSize: 7,
 		},
 		{
-			start: 1,
-			count: 30,
-			want:  []*trillian.LogLeaf{leaf1, leaf2, leaf3},
+			start:        1,
+			count:        30,
+			getCount:     6,
+			want:         []*trillian.LogLeaf{leaf1, leaf2, leaf3},
+			wantTreeSize: 7,
+		},
+		{
+			start:        1,
+			count:        30,
+			treeSize:     4,
+			getCount:     3,
+			want:         []*trillian.LogLeaf{leaf1, leaf2, leaf3},
+			wantTreeSize: 4,
+		},
+		{
+			start:        1,
+			count:        3,
+			treeSize:     7,
+			want:         []*trillian.LogLeaf{leaf1, leaf2, leaf3},
+			wantTreeSize: 7,
+		},
+		{
+			start:        5,
+			count:        1,
+			treeSize:     4,
+			skipGet:      true,
+			wantTreeSize: 4,
+		},
+		{
+			start:    1,
+			count:    1,
+			treeSize: 8,
+			skipGet:  true,
+		},
+		{
+			start:    1,
+			count:    1,
+			treeSize: -1,
+			skipTX:   true,
+			wantErr:  "want >= 0",
 		},
 		{
 			start:   -1,
@@ -374,12 +427,20 @@ func TestGetLeavesByRange(t *testing.T) {
 					fakeStorage.EXPECT().SnapshotForTree(gomock.Any(), tree).Return(mockTX, nil)
 					mockTX.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(root, test.slrErr)
 
+					getCount := test.count
+					if test.getCount != 0 {
+						getCount = test.getCount
+					}
 					if test.root == nil {
 						if test.getErr != nil {
-							mockTX.EXPECT().GetLeavesByRange(gomock.Any(), test.start, test.count).Return(nil, test.getErr)
+							mockTX.EXPECT().GetLeavesByRange(gomock.Any(), test.start, getCount).Return(nil, test.getErr)
 						} else {
-							mockTX.EXPECT().GetLeavesByRange(gomock.Any(), test.start, test.count).Return(test.want, nil)
-							mockTX.EXPECT().Commit(gomock.Any()).Return(nil)
+							if !test.skipGet {
+								mockTX.EXPECT().GetLeavesByRange(gomock.Any(), test.start, getCount).Return(test.want, nil)
+							}
+							if test.treeSize <= 7 {
+								mockTX.EXPECT().Commit(gomock.Any()).Return(nil)
+							}
 						}
 					}
 					mockTX.EXPECT().Close().Return(nil)
@@ -397,6 +458,7 @@ func TestGetLeavesByRange(t *testing.T) {
 			LogId:      tree.TreeId,
 			StartIndex: test.start,
 			Count:      test.count,
+			TreeSize:   test.treeSize,
 		}
 		rsp, err := server.GetLeavesByRange(ctx, &req)
 		if err != nil {
@@ -414,9 +476,179 @@ func TestGetLeavesByRange(t *testing.T) {
 		if got := rsp.Leaves; !cmp.Equal(got, test.want, cmp.Comparer(proto.Equal)) {
 			t.Errorf("GetLeavesByRange(%d, %+d)=%+v; want %+v", req.StartIndex, req.Count, got, test.want)
 		}
+		if got := rsp.TreeSize; got != test.wantTreeSize {
+			t.Errorf("GetLeavesByRange(%d, %+d, %d).TreeSize=%d; want %d", req.StartIndex, req.Count, req.TreeSize, got, test.wantTreeSize)
+		}
 	}
 }
 
+// fakeLeavesStream records the responses sent by GetLeavesByRangeStream.
+type fakeLeavesStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	resps []*trillian.GetLeavesByRangeStreamResponse
+}
+
+func (s *fakeLeavesStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeLeavesStream) Send(resp *trillian.GetLeavesByRangeStreamResponse) error {
+	s.resps = append(s.resps, resp)
+	return nil
+}
+
+func TestGetLeavesByRangeStream(t *testing.T) {
+	leaves := func(start, end int64) []*trillian.LogLeaf {
+		var ret []*trillian.LogLeaf
+		for i := start; i < end; i++ {
+			ret = append(ret, newTestLeaf([]byte(fmt.Sprintf("value%d", i)), nil, i))
+		}
+		return ret
+	}
+	getErr := errors.New("get failed")
+
+	type getCall struct {
+		start, count int64
+		leaves       []*trillian.LogLeaf
+		err          error
+	}
+	tests := []struct {
+		desc      string
+		req       *trillian.GetLeavesByRangeStreamRequest
+		calls     []getCall
+		want      []*trillian.GetLeavesByRangeStreamResponse
+		wantCode  codes.Code
+		noStorage bool
+	}{
+		{
+			desc:      "negative count",
+			req:       &trillian.GetLeavesByRangeStreamRequest{StartIndex: 1, Count: -1},
+			wantCode:  codes.InvalidArgument,
+			noStorage: true,
+		},
+		{
+			desc:      "negative chunk size",
+			req:       &trillian.GetLeavesByRangeStreamRequest{StartIndex: 1, ChunkSize: -1},
+			wantCode:  codes.InvalidArgument,
+			noStorage: true,
+		},
+		{
+			desc: "whole tree in chunks",
+			req:  &trillian.GetLeavesByRangeStreamRequest{StartIndex: 1, ChunkSize: 3},
+			calls: []getCall{
+				{start: 1, count: 3, leaves: leaves(1, 4)},
+				{start: 4, count: 3, leaves: leaves(4, 7)},
+			},
+			want: []*trillian.GetLeavesByRangeStreamResponse{
+				{SignedLogRoot: signedRoot1, TreeSize: 7, Leaves: leaves(1, 4)},
+				{Leaves: leaves(4, 7)},
+			},
+		},
+		{
+			desc: "count",
+			req:  &trillian.GetLeavesByRangeStreamRequest{StartIndex: 2, Count: 2},
+			calls: []getCall{
+				{start: 2, count: 2, leaves: leaves(2, 4)},
+			},
+			want: []*trillian.GetLeavesByRangeStreamResponse{
+				{SignedLogRoot: signedRoot1, TreeSize: 7, Leaves: leaves(2, 4)},
+			},
+		},
+		{
+			desc: "pinned tree size",
+			req:  &trillian.GetLeavesByRangeStreamRequest{Count: 10, TreeSize: 5, ChunkSize: 4},
+			calls: []getCall{
+				{start: 0, count: 4, leaves: leaves(0, 4)},
+				{start: 4, count: 1, leaves: leaves(4, 5)},
+			},
+			want: []*trillian.GetLeavesByRangeStreamResponse{
+				{SignedLogRoot: signedRoot1, TreeSize: 5, Leaves: leaves(0, 4)},
+				{Leaves: leaves(4, 5)},
+			},
+		},
+		{
+			desc: "short chunk",
+			req:  &trillian.GetLeavesByRangeStreamRequest{StartIndex: 3},
+			calls: []getCall{
+				{start: 3, count: 4, leaves: leaves(3, 5)},
+				{start: 5, count: 2, leaves: leaves(5, 7)},
+			},
+			want: []*trillian.GetLeavesByRangeStreamResponse{
+				{SignedLogRoot: signedRoot1, TreeSize: 7, Leaves: leaves(3, 5)},
+				{Leaves: leaves(5, 7)},
+			},
+		},
+		{
+			desc: "start beyond tree size",
+			req:  &trillian.GetLeavesByRangeStreamRequest{StartIndex: 9},
+			want: []*trillian.GetLeavesByRangeStreamResponse{
+				{SignedLogRoot: signedRoot1, TreeSize: 7},
+			},
+		},
+		{
+			desc: "tree size beyond latest root",
+			req:  &trillian.GetLeavesByRangeStreamRequest{TreeSize: 8},
+			want: []*trillian.GetLeavesByRangeStreamResponse{
+				{SignedLogRoot: signedRoot1},
+			},
+		},
+		{
+			desc:     "missing leaves",
+			req:      &trillian.GetLeavesByRangeStreamRequest{StartIndex: 6},
+			calls:    []getCall{{start: 6, count: 1}},
+			wantCode: codes.Internal,
+		},
+		{
+			desc:     "get error",
+			req:      &trillian.GetLeavesByRangeStreamRequest{StartIndex: 6},
+			calls:    []getCall{{start: 6, count: 1, err: getErr}},
+			wantCode: codes.Unknown,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			test.req.LogId = logID1
+			fakeStorage := storage.NewMockLogStorage(ctrl)
+			mockTX := storage.NewMockLogTreeTX(ctrl)
+			if !test.noStorage {
+				snapshots := 1 + len(test.calls)
+				fakeStorage.EXPECT().SnapshotForTree(gomock.Any(), gomock.Any()).Times(snapshots).Return(mockTX, nil)
+				mockTX.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(signedRoot1, nil)
+				commits := snapshots
+				for _, c := range test.calls {
+					mockTX.EXPECT().GetLeavesByRange(gomock.Any(), c.start, c.count).Return(c.leaves, c.err)
+					if c.err != nil {
+						commits--
+					}
+				}
+				mockTX.EXPECT().Commit(gomock.Any()).Times(commits).Return(nil)
+				mockTX.EXPECT().Close().Times(snapshots).Return(nil)
+			}
+
+			registry := extension.Registry{
+				AdminStorage: fakeAdminStorage(ctrl, storageParams{treeID: logID1, numSnapshots: 1}),
+				LogStorage:   fakeStorage,
+			}
+			server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+			stream := &fakeLeavesStream{ctx: context.Background()}
+			err := server.GetLeavesByRangeStream(test.req, stream)
+			if got := status.Code(err); got != test.wantCode {
+				t.Fatalf("GetLeavesByRangeStream(): %v, want code %v", err, test.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(stream.resps, test.want, cmp.Comparer(proto.Equal)); diff != "" {
+				t.Errorf("GetLeavesByRangeStream() responses diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
 func TestQueueLeavesStorageError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -576,6 +808,165 @@ func TestAddSequencedLeaves(t *testing.T) {
 	}
 }
 
+// fakeSequencedLeavesStream sends requests to AddSequencedLeavesStream, and
+// records its response.
+type fakeSequencedLeavesStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	reqs []*trillian.AddSequencedLeavesStreamRequest
+	resp *trillian.AddSequencedLeavesStreamResponse
+}
+
+func (s *fakeSequencedLeavesStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeSequencedLeavesStream) Recv() (*trillian.AddSequencedLeavesStreamRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+
+func (s *fakeSequencedLeavesStream) SendAndClose(resp *trillian.AddSequencedLeavesStreamResponse) error {
+	s.resp = resp
+	return nil
+}
+
+func TestAddSequencedLeavesStream(t *testing.T) {
+	defer func(d time.Duration) { integrationPollInterval = d }(integrationPollInterval)
+	integrationPollInterval = time.Millisecond
+
+	leaves := func(start, end int64) []*trillian.LogLeaf {
+		var ret []*trillian.LogLeaf
+		for i := start; i < end; i++ {
+			ret = append(ret, newTestLeaf([]byte(fmt.Sprintf("value%d", i)), nil, i))
+		}
+		return ret
+	}
+	results := func(cs ...codes.Code) []*trillian.QueuedLogLeaf {
+		var ret []*trillian.QueuedLogLeaf
+		for _, c := range cs {
+			ret = append(ret, &trillian.QueuedLogLeaf{Status: status.New(c, "").Proto()})
+		}
+		return ret
+	}
+	root := func(size uint64) *trillian.SignedLogRoot {
+		b, err := (&types.LogRootV1{TreeSize: size, RootHash: []byte("hash")}).MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary(): %v", err)
+		}
+		return &trillian.SignedLogRoot{LogRoot: b}
+	}
+
+	for _, tc := range []struct {
+		desc     string
+		reqs     []*trillian.AddSequencedLeavesStreamRequest
+		added    [][]*trillian.QueuedLogLeaf
+		roots    []*trillian.SignedLogRoot
+		want     *trillian.AddSequencedLeavesStreamResponse
+		wantCode codes.Code
+	}{
+		{
+			desc: "chunks",
+			reqs: []*trillian.AddSequencedLeavesStreamRequest{
+				{LogId: logID1, Leaves: leaves(3, 6)},
+				{LogId: logID1, Leaves: leaves(6, 8)},
+			},
+			added: [][]*trillian.QueuedLogLeaf{
+				results(codes.OK, codes.OK, codes.OK),
+				results(codes.AlreadyExists, codes.OK),
+			},
+			want: &trillian.AddSequencedLeavesStreamResponse{LeafCount: 5, DuplicateCount: 1},
+		},
+		{
+			desc: "waitForIntegration",
+			reqs: []*trillian.AddSequencedLeavesStreamRequest{
+				{LogId: logID1, Leaves: leaves(0, 2), WaitForIntegration: true},
+				{LogId: logID1, Leaves: leaves(2, 3)},
+			},
+			added: [][]*trillian.QueuedLogLeaf{
+				results(codes.OK, codes.OK),
+				results(codes.OK),
+			},
+			roots: []*trillian.SignedLogRoot{root(1), root(2), root(3)},
+			want:  &trillian.AddSequencedLeavesStreamResponse{LeafCount: 3, SignedLogRoot: root(3)},
+		},
+		{
+			desc: "gapBetweenChunks",
+			reqs: []*trillian.AddSequencedLeavesStreamRequest{
+				{LogId: logID1, Leaves: leaves(0, 2)},
+				{LogId: logID1, Leaves: leaves(3, 4)},
+			},
+			added:    [][]*trillian.QueuedLogLeaf{results(codes.OK, codes.OK)},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			desc: "unorderedChunk",
+			reqs: []*trillian.AddSequencedLeavesStreamRequest{
+				{LogId: logID1, Leaves: append(leaves(1, 2), leaves(0, 1)...)},
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			desc: "otherLog",
+			reqs: []*trillian.AddSequencedLeavesStreamRequest{
+				{LogId: logID1, Leaves: leaves(0, 2)},
+				{LogId: logID2, Leaves: leaves(2, 4)},
+			},
+			added:    [][]*trillian.QueuedLogLeaf{results(codes.OK, codes.OK)},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			desc: "storageFailure",
+			reqs: []*trillian.AddSequencedLeavesStreamRequest{
+				{LogId: logID1, Leaves: leaves(0, 1)},
+			},
+			added:    [][]*trillian.QueuedLogLeaf{results(codes.Internal)},
+			wantCode: codes.Internal,
+		},
+		{
+			desc:     "emptyStream",
+			wantCode: codes.InvalidArgument,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tree := addTreeID(stestonly.PreorderedLogTree, logID1)
+			mockStorage := storage.NewMockLogStorage(ctrl)
+			for i, res := range tc.added {
+				mockStorage.EXPECT().AddSequencedLeaves(gomock.Any(), tree, tc.reqs[i].Leaves, gomock.Any()).Return(res, nil)
+			}
+			if len(tc.roots) > 0 {
+				mockTX := storage.NewMockLogTreeTX(ctrl)
+				mockStorage.EXPECT().SnapshotForTree(gomock.Any(), tree).Times(len(tc.roots)).Return(mockTX, nil)
+				for _, r := range tc.roots {
+					mockTX.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(r, nil)
+				}
+				mockTX.EXPECT().Commit(gomock.Any()).Times(len(tc.roots)).Return(nil)
+				mockTX.EXPECT().Close().Times(len(tc.roots)).Return(nil)
+			}
+			server := NewTrillianLogRPCServer(extension.Registry{
+				AdminStorage: fakeAdminStorage(ctrl, storageParams{treeID: logID1, preordered: true, numSnapshots: 1}),
+				LogStorage:   mockStorage,
+			}, fakeTimeSource)
+
+			stream := &fakeSequencedLeavesStream{ctx: context.Background(), reqs: tc.reqs}
+			err := server.AddSequencedLeavesStream(stream)
+			if got := status.Code(err); got != tc.wantCode {
+				t.Fatalf("AddSequencedLeavesStream(): %v, want code %v", err, tc.wantCode)
+			}
+			if !proto.Equal(stream.resp, tc.want) {
+				t.Errorf("AddSequencedLeavesStream() response: %v, want %v", stream.resp, tc.want)
+			}
+		})
+	}
+}
+
 type latestRootTest struct {
 	desc        string
 	req         *trillian.GetLatestSignedLogRootRequest
@@ -1064,6 +1455,65 @@ func TestGetProofByHash(t *testing.T) {
 	}
 }
 
+func TestGetProofByHashBatch(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fakeStorage := storage.NewMockLogStorage(ctrl)
+	mockTX := storage.NewMockLogTreeTX(ctrl)
+	fakeStorage.EXPECT().SnapshotForTree(gomock.Any(), tree1).Return(mockTX, nil)
+
+	// Each hash is read once, though leafHash1 is requested twice. The leaf with
+	// leafHash2 is beyond the requested tree size.
+	mockTX.EXPECT().GetLeavesByHash(gomock.Any(), [][]byte{leafHash1, leafHash2}, false).Return([]*trillian.LogLeaf{
+		{LeafIndex: 2, MerkleLeafHash: leafHash1},
+		{LeafIndex: 7, MerkleLeafHash: leafHash2},
+	}, nil)
+	mockTX.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(signedRoot1, nil)
+	mockTX.EXPECT().ReadRevision(gomock.Any()).Return(int64(root1.Revision), nil)
+	mockTX.EXPECT().GetMerkleNodes(gomock.Any(), revision1, nodeIdsInclusionSize7Index2).Return([]tree.Node{
+		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
+		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
+		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+	mockTX.EXPECT().Commit(gomock.Any()).Return(nil)
+	mockTX.EXPECT().Close().Return(nil)
+
+	registry := extension.Registry{
+		AdminStorage: fakeAdminStorage(ctrl, storageParams{
+			treeID:       logID1,
+			numSnapshots: 1,
+		}),
+		LogStorage: fakeStorage,
+	}
+	server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+
+	resp, err := server.GetInclusionProofByHashBatch(ctx, &trillian.GetInclusionProofByHashBatchRequest{
+		LogId:    logID1,
+		TreeSize: 7,
+		LeafHash: [][]byte{leafHash1, leafHash2, leafHash1},
+	})
+	if err != nil {
+		t.Fatalf("GetInclusionProofByHashBatch(): %v", err)
+	}
+
+	proof := &trillian.Proof{
+		LeafIndex: 2,
+		Hashes:    [][]byte{[]byte("nodehash0"), []byte("nodehash1"), []byte("nodehash2")},
+	}
+	want := &trillian.GetInclusionProofByHashBatchResponse{
+		Proofs: []*trillian.GetInclusionProofByHashBatchResponse_LeafHashProofs{
+			{LeafHash: leafHash1, Proof: []*trillian.Proof{proof}},
+			{LeafHash: leafHash2},
+			{LeafHash: leafHash1, Proof: []*trillian.Proof{proof}},
+		},
+		SignedLogRoot: signedRoot1,
+	}
+	if !proto.Equal(resp, want) {
+		t.Errorf("GetInclusionProofByHashBatch(): %v, want %v", proto.CompactTextString(resp), proto.CompactTextString(want))
+	}
+}
+
 func TestGetProofByIndex(t *testing.T) {
 	for _, tc := range []struct {
 		name         string
@@ -1758,6 +2208,97 @@ func TestTrillianLogRPCServer_GetConsistencyProofErrors(t *testing.T) {
 	}
 }
 
+func TestGetConsistencyProofByRootHash(t *testing.T) {
+	mustSign := func(root *types.LogRootV1) *trillian.SignedLogRoot {
+		t.Helper()
+		slr, err := fixedSigner.SignLogRoot(root)
+		if err != nil {
+			t.Fatalf("SignLogRoot(): %v", err)
+		}
+		return slr
+	}
+	emptyRoot := mustSign(&types.LogRootV1{RootHash: []byte("EMPTY"), TreeSize: 0, Revision: 1})
+	root4 := mustSign(&types.LogRootV1{RootHash: []byte("FOUR"), TreeSize: 4, Revision: 3})
+	published := map[string]*trillian.SignedLogRoot{
+		"EMPTY":       emptyRoot,
+		"FOUR":        root4,
+		"A NICE HASH": signedRoot1,
+	}
+	node := tree.Node{NodeID: stestonly.MustCreateNodeIDForTreeCoords(2, 1, 64), NodeRevision: 3, Hash: []byte("nodehash")}
+
+	for _, test := range []struct {
+		desc       string
+		first      string
+		second     string
+		wantCode   codes.Code
+		wantFirst  *trillian.SignedLogRoot
+		wantHashes [][]byte
+	}{
+		{desc: "ok", first: "FOUR", second: "A NICE HASH", wantFirst: root4, wantHashes: [][]byte{[]byte("nodehash")}},
+		{desc: "emptyFirst", first: "EMPTY", second: "A NICE HASH", wantFirst: emptyRoot},
+		{desc: "firstNotPublished", first: "FAKE", second: "A NICE HASH", wantCode: codes.NotFound},
+		{desc: "secondNotPublished", first: "FOUR", second: "FAKE", wantCode: codes.NotFound},
+		{desc: "outOfOrder", first: "A NICE HASH", second: "FOUR", wantCode: codes.InvalidArgument},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			fakeStorage := storage.NewMockLogStorage(ctrl)
+			mockTX := storage.NewMockLogTreeTX(ctrl)
+			fakeStorage.EXPECT().SnapshotForTree(gomock.Any(), tree1).Return(mockTX, nil)
+			mockTX.EXPECT().GetSignedLogRootByHash(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+				func(_ context.Context, hash []byte) (*trillian.SignedLogRoot, error) {
+					if slr, ok := published[string(hash)]; ok {
+						return slr, nil
+					}
+					return nil, status.Error(codes.NotFound, "not found")
+				})
+			if test.wantCode == codes.OK {
+				if len(test.wantHashes) > 0 {
+					mockTX.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(signedRoot1, nil)
+					mockTX.EXPECT().ReadRevision(gomock.Any()).Return(revision1, nil)
+					mockTX.EXPECT().GetMerkleNodes(gomock.Any(), revision1, nodeIdsConsistencySize4ToSize7).Return([]tree.Node{node}, nil)
+				}
+				mockTX.EXPECT().Commit(gomock.Any()).Return(nil)
+			}
+			mockTX.EXPECT().Close().Return(nil)
+
+			registry := extension.Registry{
+				AdminStorage: fakeAdminStorage(ctrl, storageParams{treeID: logID1, numSnapshots: 1}),
+				LogStorage:   fakeStorage,
+			}
+			server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+			req := &trillian.GetConsistencyProofByRootHashRequest{LogId: logID1, FirstRootHash: []byte(test.first), SecondRootHash: []byte(test.second)}
+			rsp, err := server.GetConsistencyProofByRootHash(context.Background(), req)
+			if got := status.Code(err); got != test.wantCode {
+				t.Fatalf("GetConsistencyProofByRootHash()=_, %v, want code %v", err, test.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if !proto.Equal(rsp.FirstSignedLogRoot, test.wantFirst) || !proto.Equal(rsp.SecondSignedLogRoot, signedRoot1) {
+				t.Errorf("GetConsistencyProofByRootHash() roots %v, %v, want %v, %v", rsp.FirstSignedLogRoot, rsp.SecondSignedLogRoot, test.wantFirst, signedRoot1)
+			}
+			if want := (&trillian.Proof{Hashes: test.wantHashes}); !proto.Equal(rsp.Proof, want) {
+				t.Errorf("GetConsistencyProofByRootHash().Proof=%v, want %v", rsp.Proof, want)
+			}
+		})
+	}
+}
+
+func TestTrillianLogRPCServer_GetConsistencyProofByRootHashErrors(t *testing.T) {
+	logServer := NewTrillianLogRPCServer(extension.Registry{}, fakeTimeSource)
+	for _, req := range []*trillian.GetConsistencyProofByRootHashRequest{
+		{LogId: 1, SecondRootHash: []byte("second")},
+		{LogId: 1, FirstRootHash: []byte("first")},
+	} {
+		if _, err := logServer.GetConsistencyProofByRootHash(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("GetConsistencyProofByRootHash(%v) returned err = %v, wantCode = %s", req, err, codes.InvalidArgument)
+		}
+	}
+}
+
 func TestTrillianLogRPCServer_GetEntryAndProofErrors(t *testing.T) {
 	tests := []struct {
 		desc string
@@ -1881,6 +2422,55 @@ func TestTrillianLogRPCServer_GetInclusionProofByHashErrors(t *testing.T) {
 	}
 }
 
+func TestTrillianLogRPCServer_GetInclusionProofByHashBatchErrors(t *testing.T) {
+	tests := []struct {
+		desc string
+		req  *trillian.GetInclusionProofByHashBatchRequest
+	}{
+		{
+			desc: "nilLeafHashes",
+			req: &trillian.GetInclusionProofByHashBatchRequest{
+				LogId:    1,
+				TreeSize: 20,
+			},
+		},
+		{
+			desc: "nilLeafHash",
+			req: &trillian.GetInclusionProofByHashBatchRequest{
+				LogId:    1,
+				LeafHash: [][]byte{[]byte("32.bytes.hash..................."), nil},
+				TreeSize: 20,
+			},
+		},
+		{
+			desc: "badTreeSize",
+			req: &trillian.GetInclusionProofByHashBatchRequest{
+				LogId:    1,
+				LeafHash: [][]byte{[]byte("32.bytes.hash...................")},
+				TreeSize: -20,
+			},
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			registry := extension.Registry{
+				AdminStorage: fakeAdminStorage(ctrl, storageParams{treeID: test.req.LogId, numSnapshots: 1}),
+			}
+			logServer := NewTrillianLogRPCServer(registry, fakeTimeSource)
+
+			_, err := logServer.GetInclusionProofByHashBatch(ctx, test.req)
+			if s, ok := status.FromError(err); !ok || s.Code() != codes.InvalidArgument {
+				t.Errorf("%v: GetInclusionProofByHashBatch() returned err = %v, wantCode = %s", test.desc, err, codes.InvalidArgument)
+			}
+		})
+	}
+}
+
 func TestTrillianLogRPCServer_GetLeavesByHashErrors(t *testing.T) {
 	tests := []struct {
 		desc string
@@ -2136,6 +2726,163 @@ func TestInitLog(t *testing.T) {
 	}
 }
 
+func TestAddLogRootSignature(t *testing.T) {
+	ctx := context.Background()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey(): %v", err)
+	}
+	pub, err := der.ToPublicProto(key.Public())
+	if err != nil {
+		t.Fatalf("ToPublicProto(): %v", err)
+	}
+	witness := tcrypto.NewSHA256Signer(key)
+	root := &trillian.SignedLogRoot{LogRoot: []byte("root at revision 1")}
+	sign := func(data string) []byte {
+		sig, err := witness.Sign([]byte(data))
+		if err != nil {
+			t.Fatalf("Sign(): %v", err)
+		}
+		return sig
+	}
+
+	for _, tc := range []struct {
+		desc       string
+		revision   int64
+		sig        *trillian.LogRootSignature
+		wantRead   bool
+		wantStore  bool
+		wantCode   codes.Code
+		wantReason errmsg.Reason
+	}{
+		{
+			desc:      "valid",
+			revision:  1,
+			sig:       &trillian.LogRootSignature{PublicKey: pub, Signature: sign("root at revision 1")},
+			wantRead:  true,
+			wantStore: true,
+		},
+		{
+			desc:       "otherRoot",
+			revision:   1,
+			sig:        &trillian.LogRootSignature{PublicKey: pub, Signature: sign("other root")},
+			wantRead:   true,
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.LogRootSignatureInvalid,
+		},
+		{
+			desc:     "badKey",
+			revision: 1,
+			sig:      &trillian.LogRootSignature{PublicKey: &keyspb.PublicKey{Der: []byte("not a key")}, Signature: sign("root at revision 1")},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			desc:       "negativeRevision",
+			revision:   -1,
+			sig:        &trillian.LogRootSignature{PublicKey: pub, Signature: sign("root at revision 1")},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.FieldNegative,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			tx := storage.NewMockLogTreeTX(ctrl)
+			if tc.wantRead {
+				tx.EXPECT().GetSignedLogRoot(gomock.Any(), tc.revision).Return(root, nil)
+				tx.EXPECT().Close().Return(nil)
+			}
+			if tc.wantStore {
+				tx.EXPECT().StoreLogRootSignature(gomock.Any(), tc.revision, tc.sig).Return(nil)
+				tx.EXPECT().Commit(gomock.Any()).Return(nil)
+			}
+			server := NewTrillianLogRPCServer(extension.Registry{
+				AdminStorage: fakeAdminStorage(ctrl, storageParams{treeID: logID1, numSnapshots: 1}),
+				LogStorage:   &stestonly.FakeLogStorage{TX: tx},
+			}, fakeTimeSource)
+
+			_, err := server.AddLogRootSignature(ctx, &trillian.AddLogRootSignatureRequest{LogId: logID1, Revision: tc.revision, Signature: tc.sig})
+			if got := status.Code(err); got != tc.wantCode {
+				t.Fatalf("AddLogRootSignature(): %v, want code %v", err, tc.wantCode)
+			}
+			if tc.wantReason != "" {
+				if info := errmsg.Info(err); info == nil || info.Reason != string(tc.wantReason) {
+					t.Errorf("AddLogRootSignature(): %v, want reason %v", err, tc.wantReason)
+				}
+			}
+		})
+	}
+}
+
+func TestGetLogRootSignatures(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	root := &trillian.SignedLogRoot{LogRoot: []byte("root at revision 2")}
+	sigs := []*trillian.LogRootSignature{
+		{PublicKey: &keyspb.PublicKey{Der: []byte("key1")}, Signature: []byte("sig1")},
+		{PublicKey: &keyspb.PublicKey{Der: []byte("key2")}, Signature: []byte("sig2")},
+	}
+	tx := storage.NewMockLogTreeTX(ctrl)
+	tx.EXPECT().GetSignedLogRoot(gomock.Any(), int64(2)).Return(root, nil)
+	tx.EXPECT().GetLogRootSignatures(gomock.Any(), int64(2)).Return(sigs, nil)
+	tx.EXPECT().Commit(gomock.Any()).Return(nil)
+	tx.EXPECT().Close().Return(nil)
+	server := NewTrillianLogRPCServer(extension.Registry{
+		AdminStorage: fakeAdminStorage(ctrl, storageParams{treeID: logID1, numSnapshots: 1}),
+		LogStorage:   &stestonly.FakeLogStorage{ReadOnlyTX: tx},
+	}, fakeTimeSource)
+
+	resp, err := server.GetLogRootSignatures(ctx, &trillian.GetLogRootSignaturesRequest{LogId: logID1, Revision: 2})
+	if err != nil {
+		t.Fatalf("GetLogRootSignatures(): %v", err)
+	}
+	want := &trillian.GetLogRootSignaturesResponse{SignedLogRoot: root, Signatures: sigs}
+	if !proto.Equal(resp, want) {
+		t.Errorf("GetLogRootSignatures(): %v, want %v", resp, want)
+	}
+}
+
+func TestGetCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	defer setCheckpointOriginPrefix("example.com/logs")()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	b, err := (&types.LogRootV1{TreeSize: 7, RootHash: []byte("root")}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary(): %v", err)
+	}
+	slr := &trillian.SignedLogRoot{LogRoot: b}
+	tx := storage.NewMockLogTreeTX(ctrl)
+	tx.EXPECT().LatestSignedLogRoot(gomock.Any()).Return(slr, nil)
+	tx.EXPECT().Commit(gomock.Any()).Return(nil)
+	tx.EXPECT().Close().Return(nil)
+	server := NewTrillianLogRPCServer(extension.Registry{
+		AdminStorage: fakeAdminStorage(ctrl, storageParams{treeID: logID1, numSnapshots: 1}),
+		LogStorage:   &stestonly.FakeLogStorage{ReadOnlyTX: tx},
+	}, fakeTimeSource)
+
+	resp, err := server.GetCheckpoint(ctx, &trillian.GetCheckpointRequest{LogId: logID1})
+	if err != nil {
+		t.Fatalf("GetCheckpoint(): %v", err)
+	}
+	if !proto.Equal(resp.SignedLogRoot, slr) {
+		t.Errorf("GetCheckpoint().SignedLogRoot=%v, want %v", resp.SignedLogRoot, slr)
+	}
+	body := verifyCheckpoint(t, resp.Checkpoint, "example.com/logs/1", stestonly.LogTree.PublicKey.Der)
+	if want := "example.com/logs/1\n7\ncm9vdA==\n"; body != want {
+		t.Errorf("GetCheckpoint() body %q, want %q", body, want)
+	}
+
+	// Checkpoints are disabled without an origin prefix.
+	defer setCheckpointOriginPrefix("")()
+	if _, err := server.GetCheckpoint(ctx, &trillian.GetCheckpointRequest{LogId: logID1}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("GetCheckpoint() without origin prefix: %v, want code %v", err, codes.Unimplemented)
+	}
+}
+
 type prepareFakeStorageFunc func(*stestonly.FakeLogStorage)
 type prepareMockTXFunc func(*storage.MockLogTreeTX)
 type makeRPCFunc func(*TrillianLogRPCServer) error
@@ -2323,3 +3070,29 @@ func newSignerWithFixedSig(sig []byte) crypto.Signer {
 	}
 	return testonly.NewSignerWithFixedSig(key, sig)
 }
+
+func TestSpanFor(t *testing.T) {
+	var got []monitoring.Attribute
+	monitoring.SetStartSpan(func(ctx context.Context, name string, attrs ...monitoring.Attribute) (context.Context, func()) {
+		if want := "/trillian.GetLeavesByRange"; name != want {
+			t.Errorf("StartSpan(): name %q, want %q", name, want)
+		}
+		got = attrs
+		return ctx, func() {}
+	})
+	defer monitoring.SetStartSpan(func(ctx context.Context, _ string, _ ...monitoring.Attribute) (context.Context, func()) {
+		return ctx, func() {}
+	})
+
+	_, spanEnd := spanFor(context.Background(), "GetLeavesByRange", treeAttr(1), revisionAttr(2), batchAttr(3))
+	spanEnd()
+	want := []monitoring.Attribute{
+		{Key: monitoring.TreeIDKey, Value: int64(1)},
+		{Key: monitoring.RevisionKey, Value: int64(2)},
+		{Key: monitoring.BatchSizeKey, Value: int64(3)},
+		{Key: monitoring.RPCKey, Value: "GetLeavesByRange"},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("StartSpan(): attributes diff (-got +want):\n%s", diff)
+	}
+}
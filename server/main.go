@@ -17,6 +17,11 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"time"
@@ -27,13 +32,16 @@ import (
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/server/admin"
+	"github.com/google/trillian/server/attestation"
 	"github.com/google/trillian/server/interceptor"
+	"github.com/google/trillian/server/quarantine"
 	"github.com/google/trillian/util"
 	"github.com/google/trillian/util/clock"
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/naming"
 	"google.golang.org/grpc/reflection"
 
@@ -51,8 +59,36 @@ const (
 	// hard-deleting them.
 	// Actual runs happen randomly between [minInterval,2*minInterval).
 	DefaultTreeDeleteMinInterval = 4 * time.Hour
+
+	// DefaultKeyRotationMinInterval is the suggested min interval between
+	// sweeps for tree key rotations whose old keys are due to be retired.
+	// Actual runs happen randomly between [minInterval,2*minInterval).
+	DefaultKeyRotationMinInterval = 10 * time.Minute
+
+	// DefaultRevisionGCMinInterval is the suggested min interval between map
+	// revision GC sweeps.
+	// Actual runs happen randomly between [minInterval,2*minInterval).
+	DefaultRevisionGCMinInterval = 10 * time.Minute
+
+	// DefaultMergeMinInterval is the suggested min interval between merges of
+	// queued map writes.
+	// Actual runs happen randomly between [minInterval,2*minInterval).
+	DefaultMergeMinInterval = time.Second
+
+	// DefaultMergeBatchSize is the suggested maximum number of queued writes
+	// merged into each map revision.
+	DefaultMergeBatchSize = 100
 )
 
+// ReadinessCheck is run in the background by Main, and reports whether the
+// server is ready to receive traffic, e.g. a canary.Canary.
+type ReadinessCheck interface {
+	// Run runs the check until ctx is cancelled.
+	Run(ctx context.Context)
+	// Ready returns nil if the server is ready.
+	Ready() error
+}
+
 // Main encapsulates the data and logic to start a Trillian server (Log or Map).
 type Main struct {
 	// Endpoints for RPC and HTTP/REST servers.
@@ -61,6 +97,10 @@ type Main struct {
 
 	// TLS Certificate and Key files for the server.
 	TLSCertFile, TLSKeyFile string
+	// TLSClientCAFile, if set, is a file of PEM certificates, and only RPC
+	// clients with a certificate signed by one of them are accepted. It
+	// requires TLSCertFile and TLSKeyFile.
+	TLSClientCAFile string
 
 	DBClose func() error
 
@@ -81,17 +121,59 @@ type Main struct {
 	// HealthyDeadline is the maximum duration to wait wait for a successful
 	// IsHealthy() call.
 	HealthyDeadline time.Duration
+	// Health configures the grpc.health.v1.Health service registered by Main,
+	// which also gates the "/readyz" endpoint.
+	Health HealthOptions
 
 	// AllowedTreeTypes determines which types of trees may be created through the Admin Server
 	// bound by Main. nil means unrestricted.
 	AllowedTreeTypes []trillian.TreeType
+	// AdminDisabled stops Main from serving the Admin API, e.g. on a server
+	// whose clients must not be able to manage trees.
+	AdminDisabled bool
 
 	TreeGCEnabled         bool
 	TreeDeleteThreshold   time.Duration
 	TreeDeleteMinInterval time.Duration
 
+	// KeyRotationEnabled enables retirement of the old keys of trees whose
+	// key rotations are due.
+	KeyRotationEnabled     bool
+	KeyRotationMinInterval time.Duration
+
+	// RevisionGCEnabled enables garbage collection of map revisions according
+	// to the retention policy of each map. Requires Registry.MapStorage.
+	RevisionGCEnabled     bool
+	RevisionGCMinInterval time.Duration
+
+	// MapMerger, if set, periodically merges queued map writes into new
+	// revisions.
+	MapMerger *MapMerger
+
+	// Attester, if set, periodically publishes signed attestations of the
+	// trees served.
+	Attester *attestation.Attester
+
+	// Quarantiner, if set, periodically quarantines the trees which failed
+	// integrity checks.
+	Quarantiner *quarantine.Quarantiner
+
+	// Canary, if set, is run in the background, and gates the "/readyz"
+	// endpoint on its success.
+	Canary ReadinessCheck
+
+	// OverloadBreaker, if set, sheds the requests for overloaded trees.
+	OverloadBreaker *interceptor.OverloadBreaker
+
+	// TreeOwnership, if set, restricts the requests of each principal to the
+	// trees it owns. It requires TLSClientCAFile, so that principals are
+	// authenticated by their client certificates.
+	TreeOwnership *interceptor.TreeOwnership
+
 	// These will be added to the GRPC server options.
 	ExtraOptions []grpc.ServerOption
+
+	health *HealthServer
 }
 
 func (m *Main) healthz(rw http.ResponseWriter, req *http.Request) {
@@ -107,6 +189,20 @@ func (m *Main) healthz(rw http.ResponseWriter, req *http.Request) {
 	rw.Write([]byte("ok"))
 }
 
+func (m *Main) readyz(rw http.ResponseWriter, req *http.Request) {
+	for _, check := range []ReadinessCheck{m.Canary, m.health} {
+		if check == nil {
+			continue
+		}
+		if err := check.Ready(); err != nil {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+	}
+	m.healthz(rw, req)
+}
+
 // Run starts the configured server. Blocks until the server exits.
 func (m *Main) Run(ctx context.Context) error {
 	glog.CopyStandardLogTo("WARNING")
@@ -114,6 +210,9 @@ func (m *Main) Run(ctx context.Context) error {
 	if m.HealthyDeadline == 0 {
 		m.HealthyDeadline = 5 * time.Second
 	}
+	if m.Registry.Tracer != nil {
+		monitoring.SetTracer(m.Registry.Tracer)
+	}
 
 	srv, err := m.newGRPCServer()
 	if err != nil {
@@ -123,10 +222,22 @@ func (m *Main) Run(ctx context.Context) error {
 
 	defer m.DBClose()
 
+	// Fail fast if the databases are not accessible.
+	m.health = NewHealthServer(m.Registry, m.Health)
+	if err := m.health.checkDatabases(ctx); err != nil {
+		return err
+	}
+
 	if err := m.RegisterServerFn(srv, m.Registry); err != nil {
 		return err
 	}
-	trillian.RegisterTrillianAdminServer(srv, admin.New(m.Registry, m.AllowedTreeTypes))
+	if !m.AdminDisabled {
+		trillian.RegisterTrillianAdminServer(srv, admin.New(m.Registry, m.AllowedTreeTypes))
+	}
+	for name := range srv.GetServiceInfo() {
+		m.health.AddService(name)
+	}
+	grpc_health_v1.RegisterHealthServer(srv, m.health)
 	reflection.Register(srv)
 
 	if endpoint := m.HTTPEndpoint; endpoint != "" {
@@ -135,13 +246,16 @@ func (m *Main) Run(ctx context.Context) error {
 		if err := m.RegisterHandlerFn(ctx, gatewayMux, m.RPCEndpoint, opts); err != nil {
 			return err
 		}
-		if err := trillian.RegisterTrillianAdminHandlerFromEndpoint(ctx, gatewayMux, m.RPCEndpoint, opts); err != nil {
-			return err
+		if !m.AdminDisabled {
+			if err := trillian.RegisterTrillianAdminHandlerFromEndpoint(ctx, gatewayMux, m.RPCEndpoint, opts); err != nil {
+				return err
+			}
 		}
 
 		http.Handle("/", gatewayMux)
 		http.Handle("/metrics", promhttp.Handler())
 		http.HandleFunc("/healthz", m.healthz)
+		http.HandleFunc("/readyz", m.readyz)
 
 		go func() {
 			glog.Infof("HTTP server starting on %v", endpoint)
@@ -179,6 +293,60 @@ func (m *Main) Run(ctx context.Context) error {
 		}()
 	}
 
+	if m.KeyRotationEnabled {
+		go func() {
+			glog.Info("Tree key rotator started")
+			r := admin.NewKeyRotator(
+				m.Registry.AdminStorage,
+				m.KeyRotationMinInterval,
+				m.Registry.MetricFactory)
+			r.Run(ctx)
+		}()
+	}
+
+	if m.RevisionGCEnabled && m.Registry.MapStorage != nil {
+		go func() {
+			glog.Info("Map revision GC started")
+			gc := NewMapRevisionGC(
+				m.Registry.AdminStorage,
+				m.Registry.MapStorage,
+				m.RevisionGCMinInterval,
+				clock.System,
+				m.Registry.MetricFactory)
+			gc.Run(ctx)
+		}()
+	}
+
+	if m.MapMerger != nil {
+		go func() {
+			glog.Info("Map merger started")
+			m.MapMerger.Run(ctx)
+		}()
+	}
+
+	if m.Attester != nil {
+		go func() {
+			glog.Info("Attester started")
+			m.Attester.Run(ctx)
+		}()
+	}
+
+	if m.Quarantiner != nil {
+		go func() {
+			glog.Info("Quarantiner started")
+			m.Quarantiner.Run(ctx)
+		}()
+	}
+
+	if m.Canary != nil {
+		go func() {
+			glog.Info("Canary started")
+			m.Canary.Run(ctx)
+		}()
+	}
+
+	go m.health.Run(ctx)
+
 	if err := srv.Serve(lis); err != nil {
 		glog.Errorf("RPC server terminated: %v", err)
 	}
@@ -197,18 +365,36 @@ func (m *Main) newGRPCServer() (*grpc.Server, error) {
 	stats := monitoring.NewRPCStatsInterceptor(clock.System, m.StatsPrefix, m.Registry.MetricFactory)
 	ti := interceptor.New(m.Registry.AdminStorage, m.Registry.QuotaManager, m.QuotaDryRun, m.Registry.MetricFactory)
 
+	interceptors := []grpc.UnaryServerInterceptor{
+		stats.Interceptor(),
+		interceptor.ErrorWrapper,
+	}
+	var streamInterceptors []grpc.StreamServerInterceptor
+	// Shed the requests for overloaded trees before they read the tree or
+	// spend quota.
+	if m.OverloadBreaker != nil {
+		interceptors = append(interceptors, m.OverloadBreaker.UnaryInterceptor)
+	}
+	if m.TreeOwnership != nil {
+		if m.TLSClientCAFile == "" {
+			return nil, errors.New("enforcing tree owners requires client certificates")
+		}
+		interceptors = append(interceptors, m.TreeOwnership.UnaryInterceptor)
+		streamInterceptors = append(streamInterceptors, m.TreeOwnership.StreamInterceptor)
+	}
+	interceptors = append(interceptors, ti.UnaryInterceptor)
+
 	serverOpts := []grpc.ServerOption{
-		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
-			stats.Interceptor(),
-			interceptor.ErrorWrapper,
-			ti.UnaryInterceptor,
-		)),
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(interceptors...)),
+	}
+	if len(streamInterceptors) > 0 {
+		serverOpts = append(serverOpts, grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(streamInterceptors...)))
 	}
 	serverOpts = append(serverOpts, m.ExtraOptions...)
 
-	// Let credentials.NewServerTLSFromFile handle the error case when only one of the flags is set.
-	if m.TLSCertFile != "" || m.TLSKeyFile != "" {
-		serverCreds, err := credentials.NewServerTLSFromFile(m.TLSCertFile, m.TLSKeyFile)
+	// Let the TLS functions handle the error case when only one of the flags is set.
+	if m.TLSCertFile != "" || m.TLSKeyFile != "" || m.TLSClientCAFile != "" {
+		serverCreds, err := m.serverCredentials()
 		if err != nil {
 			return nil, err
 		}
@@ -220,6 +406,31 @@ func (m *Main) newGRPCServer() (*grpc.Server, error) {
 	return s, nil
 }
 
+// serverCredentials returns the TLS credentials of the gRPC server, which
+// also verify the certificates of clients if TLSClientCAFile is set.
+func (m *Main) serverCredentials() (credentials.TransportCredentials, error) {
+	if m.TLSClientCAFile == "" {
+		return credentials.NewServerTLSFromFile(m.TLSCertFile, m.TLSKeyFile)
+	}
+	cert, err := tls.LoadX509KeyPair(m.TLSCertFile, m.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	caPEM, err := ioutil.ReadFile(m.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", m.TLSClientCAFile)
+	}
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}), nil
+}
+
 // AnnounceSelf announces this binary's presence to etcd.  Returns a function that
 // should be called on process exit.
 // AnnounceSelf does nothing if client is nil.
@@ -0,0 +1,99 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate and its key to dir, and
+// returns the names of the files.
+func writeTestCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey(): %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "trillian test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate(): %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey(): %v", err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestServerCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trillian-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir)
+	emptyFile := filepath.Join(dir, "empty.pem")
+	if err := ioutil.WriteFile(emptyFile, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		desc    string
+		m       Main
+		wantErr bool
+	}{
+		{desc: "tls", m: Main{TLSCertFile: certFile, TLSKeyFile: keyFile}},
+		{desc: "client ca", m: Main{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: certFile}},
+		{desc: "client ca without key", m: Main{TLSClientCAFile: certFile}, wantErr: true},
+		{desc: "missing client ca", m: Main{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: filepath.Join(dir, "missing.pem")}, wantErr: true},
+		{desc: "empty client ca", m: Main{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: emptyFile}, wantErr: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			creds, err := test.m.serverCredentials()
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("serverCredentials(): %v, want error: %v", err, test.wantErr)
+			}
+			if err == nil && creds.Info().SecurityProtocol != "tls" {
+				t.Errorf("serverCredentials(): protocol %q, want tls", creds.Info().SecurityProtocol)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+)
+
+// Kinds of hedged reads, used as metric labels.
+const (
+	hedgeLeaves = "leaves"
+	hedgeProofs = "proofs"
+)
+
+// hedgeResult is the outcome of one of the reads of a hedgedRead.
+type hedgeResult struct {
+	val    interface{}
+	err    error
+	hedged bool
+}
+
+// hedgedRead returns the result of read on tx. If HedgeDelay is set, and
+// read has not returned after it, read is issued again on a new snapshot of
+// tree, and the first successful result of the two is returned. The other
+// read is cancelled, and hedgedRead waits for the read on tx to return, so
+// that the caller can commit or close tx as soon as it returns. read must only
+// read at a fixed revision, so that both snapshots return the same data.
+func (t *TrillianMapServer) hedgedRead(ctx context.Context, tree *trillian.Tree, tx storage.ReadOnlyMapTreeTX, kind string, read func(context.Context, storage.ReadOnlyMapTreeTX) (interface{}, error)) (interface{}, error) {
+	if t.opts.HedgeDelay <= 0 {
+		return read(ctx, tx)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered, so that the read which loses does not block.
+	results := make(chan hedgeResult, 2)
+	go func() {
+		val, err := read(ctx, tx)
+		results <- hedgeResult{val: val, err: err}
+	}()
+
+	timer := time.NewTimer(t.opts.HedgeDelay)
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		return r.val, r.err
+	case <-timer.C:
+	}
+
+	label := strconv.FormatInt(tree.TreeId, 10)
+	pending := 1
+	t.hedgeCounter.Inc(label, kind)
+	if hedgeTX, err := t.snapshotForTree(ctx, tree, "HedgedRead"); err != nil {
+		glog.Warningf("%v: could not create snapshot for hedged %s read: %v", tree.TreeId, kind, err)
+	} else {
+		pending++
+		go func() {
+			val, err := read(ctx, hedgeTX)
+			if err == nil {
+				err = hedgeTX.Commit(ctx)
+			}
+			t.closeAndLog(ctx, tree.TreeId, hedgeTX, "HedgedRead")
+			results <- hedgeResult{val: val, err: err, hedged: true}
+		}()
+	}
+
+	// Transactions may be read from concurrently, but not once they are
+	// committed or closed, which callers do as soon as hedgedRead returns. So
+	// the read on tx must not outlive hedgedRead, and if the hedge wins its
+	// result is held until the cancelled read on tx returns.
+	var val interface{}
+	var firstErr error
+	won, txDone := false, false
+	for ; pending > 0 && !(won && txDone); pending-- {
+		r := <-results
+		if !r.hedged {
+			txDone = true
+		}
+		switch {
+		case won:
+		case r.err == nil:
+			if r.hedged {
+				t.hedgeWinCounter.Inc(label, kind)
+			}
+			won, val = true, r.val
+			cancel()
+		case firstErr == nil:
+			firstErr = r.err
+		}
+	}
+	if won {
+		return val, nil
+	}
+	return nil, firstErr
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/testonly"
+
+	mtestonly "github.com/google/trillian/monitoring/testonly"
+)
+
+func TestHedgedRead(t *testing.T) {
+	ctx := context.Background()
+	tree := &trillian.Tree{TreeId: 1, TreeType: trillian.TreeType_MAP}
+	errRead := errors.New("read failed")
+
+	for _, test := range []struct {
+		desc       string
+		hedgeDelay time.Duration
+		// slow and fail make the read on the original or the hedge snapshot
+		// block until cancelled, or fail.
+		slowFirst, failFirst, failHedge bool
+		wantVal                         string
+		wantErr                         bool
+		wantHedges, wantWins            float64
+	}{
+		{desc: "disabled", wantVal: "first"},
+		{desc: "fast", hedgeDelay: time.Millisecond, wantVal: "first"},
+		{desc: "disabledFailed", failFirst: true, wantErr: true},
+		{desc: "hedgeWins", hedgeDelay: time.Millisecond, slowFirst: true, wantVal: "hedge", wantHedges: 1, wantWins: 1},
+		{desc: "hedgeAfterFailure", hedgeDelay: time.Millisecond, slowFirst: true, failHedge: true, wantErr: true, wantHedges: 1},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			firstTX := storage.NewMockReadOnlyMapTreeTX(ctrl)
+			hedgeTX := storage.NewMockReadOnlyMapTreeTX(ctrl)
+			if test.wantHedges > 0 {
+				if !test.failHedge {
+					hedgeTX.EXPECT().Commit(gomock.Any()).Return(nil)
+				}
+				hedgeTX.EXPECT().Close().Return(nil)
+			}
+			s := NewTrillianMapServer(extension.Registry{
+				MapStorage:    &testonly.FakeMapStorage{ReadOnlyTX: hedgeTX},
+				MetricFactory: monitoring.InertMetricFactory{},
+			}, TrillianMapServerOptions{HedgeDelay: test.hedgeDelay})
+			hedges := mtestonly.NewCounterSnapshot(s.hedgeCounter, "1", hedgeLeaves)
+			wins := mtestonly.NewCounterSnapshot(s.hedgeWinCounter, "1", hedgeLeaves)
+
+			read := func(ctx context.Context, tx storage.ReadOnlyMapTreeTX) (interface{}, error) {
+				if tx == firstTX {
+					if test.slowFirst {
+						<-ctx.Done()
+						return nil, ctx.Err()
+					}
+					if test.failFirst {
+						return nil, errRead
+					}
+					return "first", nil
+				}
+				if test.failHedge {
+					return nil, errRead
+				}
+				return "hedge", nil
+			}
+			// Ends the slow original read if the hedge fails too.
+			cctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+			defer cancel()
+			got, err := s.hedgedRead(cctx, tree, firstTX, hedgeLeaves, read)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("hedgedRead() = (_, %v), want err %v", err, test.wantErr)
+			}
+			if err == nil && got != test.wantVal {
+				t.Errorf("hedgedRead() = %v, want %v", got, test.wantVal)
+			}
+			if got := hedges.Delta(); got != test.wantHedges {
+				t.Errorf("hedged reads: %v, want %v", got, test.wantHedges)
+			}
+			if got := wins.Delta(); got != test.wantWins {
+				t.Errorf("hedged read wins: %v, want %v", got, test.wantWins)
+			}
+		})
+	}
+}
+
+// closeCheckingTX is a ReadOnlyMapTreeTX which records whether it is used
+// after being closed.
+type closeCheckingTX struct {
+	storage.ReadOnlyMapTreeTX
+	mu             sync.Mutex
+	closed         bool
+	usedAfterClose bool
+}
+
+func (tx *closeCheckingTX) use() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.closed {
+		tx.usedAfterClose = true
+	}
+}
+
+func (tx *closeCheckingTX) Close() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.closed = true
+	return nil
+}
+
+func TestHedgedReadDoesNotOutliveTX(t *testing.T) {
+	ctx := context.Background()
+	tree := &trillian.Tree{TreeId: 1, TreeType: trillian.TreeType_MAP}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hedgeTX := storage.NewMockReadOnlyMapTreeTX(ctrl)
+	hedgeTX.EXPECT().Commit(gomock.Any()).Return(nil)
+	hedgeTX.EXPECT().Close().Return(nil)
+	s := NewTrillianMapServer(extension.Registry{
+		MapStorage:    &testonly.FakeMapStorage{ReadOnlyTX: hedgeTX},
+		MetricFactory: monitoring.InertMetricFactory{},
+	}, TrillianMapServerOptions{HedgeDelay: time.Millisecond})
+
+	firstTX := &closeCheckingTX{}
+	firstDone := make(chan struct{})
+	read := func(ctx context.Context, tx storage.ReadOnlyMapTreeTX) (interface{}, error) {
+		if tx != firstTX {
+			return "hedge", nil
+		}
+		defer close(firstDone)
+		// The original read takes a while to notice it's cancelled, and
+		// uses tx once more.
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		firstTX.use()
+		return nil, ctx.Err()
+	}
+	got, err := s.hedgedRead(ctx, tree, firstTX, hedgeLeaves, read)
+	if err != nil || got != "hedge" {
+		t.Fatalf("hedgedRead() = (%v, %v), want (hedge, nil)", got, err)
+	}
+	// The caller closes its transaction as soon as hedgedRead returns.
+	firstTX.Close()
+
+	select {
+	case <-firstDone:
+	case <-time.After(time.Second):
+		t.Fatal("original read did not return")
+	}
+	if firstTX.usedAfterClose {
+		t.Error("original read used its transaction after hedgedRead returned")
+	}
+}
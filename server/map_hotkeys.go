@@ -0,0 +1,169 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+)
+
+const (
+	// DefaultHotKeyMaxPrefixes is the number of index prefixes counted per
+	// map if MapHotKeyOptions.MaxPrefixes is zero.
+	DefaultHotKeyMaxPrefixes = 1000
+	// defaultHotKeysLimit is the number of prefixes returned by GetHotKeys
+	// requests without a limit.
+	defaultHotKeysLimit = 10
+)
+
+// MapHotKeyOptions configures the counting of the leaves written to each map
+// under each index prefix, which GetHotKeys reports.
+type MapHotKeyOptions struct {
+	// PrefixBytes is the number of leading bytes of the indices which writes
+	// are counted by. If zero, writes are not counted.
+	PrefixBytes int
+	// MaxPrefixes is the number of prefixes counted for each map. Once it is
+	// reached, a new prefix replaces the least written one, which bounds
+	// memory while keeping the most written prefixes, as in the Space-Saving
+	// algorithm. If zero, DefaultHotKeyMaxPrefixes is used.
+	MaxPrefixes int
+}
+
+// prefixCount is the number of leaves written under a prefix, which may be
+// overcounted by up to overcount.
+type prefixCount struct {
+	writes    int64
+	overcount int64
+}
+
+// mapPrefixCounts holds the prefix counts of a map.
+type mapPrefixCounts struct {
+	start  time.Time
+	total  int64
+	counts map[string]*prefixCount
+	// hottest is the highest count, which never decreases, as only the least
+	// written prefix is replaced, by a prefix with a higher count.
+	hottest int64
+}
+
+// hotKeys counts the leaves written to each map under each index prefix. It
+// is safe for concurrent use.
+type hotKeys struct {
+	opts       MapHotKeyOptions
+	shareGauge monitoring.Gauge
+
+	mu   sync.Mutex
+	maps map[int64]*mapPrefixCounts
+}
+
+func newHotKeys(opts MapHotKeyOptions, mf monitoring.MetricFactory) *hotKeys {
+	if opts.MaxPrefixes <= 0 {
+		opts.MaxPrefixes = DefaultHotKeyMaxPrefixes
+	}
+	return &hotKeys{
+		opts: opts,
+		shareGauge: mf.NewGauge(
+			"hottest_prefix_write_share",
+			"Share of the leaves written to each map under its most written index prefix",
+			"map_id",
+		),
+		maps: make(map[int64]*mapPrefixCounts),
+	}
+}
+
+// record counts the leaves written to map mapID.
+func (h *hotKeys) record(mapID int64, leaves []*trillian.MapLeaf) {
+	if h.opts.PrefixBytes <= 0 || len(leaves) == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	m, ok := h.maps[mapID]
+	if !ok {
+		m = &mapPrefixCounts{start: time.Now(), counts: make(map[string]*prefixCount)}
+		h.maps[mapID] = m
+	}
+	for _, l := range leaves {
+		prefix := l.Index
+		if len(prefix) > h.opts.PrefixBytes {
+			prefix = prefix[:h.opts.PrefixBytes]
+		}
+		c := m.counts[string(prefix)]
+		if c == nil {
+			c = &prefixCount{}
+			if len(m.counts) >= h.opts.MaxPrefixes {
+				min := m.leastWritten()
+				c.writes, c.overcount = m.counts[min].writes, m.counts[min].writes
+				delete(m.counts, min)
+			}
+			m.counts[string(prefix)] = c
+		}
+		c.writes++
+		m.total++
+		if c.writes > m.hottest {
+			m.hottest = c.writes
+		}
+	}
+	h.shareGauge.Set(float64(m.hottest)/float64(m.total), strconv.FormatInt(mapID, 10))
+}
+
+// leastWritten returns the prefix with the lowest count.
+func (m *mapPrefixCounts) leastWritten() string {
+	var min string
+	var minWrites int64 = -1
+	for prefix, c := range m.counts {
+		if minWrites < 0 || c.writes < minWrites {
+			min, minWrites = prefix, c.writes
+		}
+	}
+	return min
+}
+
+// get returns up to limit of the most written prefixes of map mapID.
+func (h *hotKeys) get(mapID int64, limit int) *trillian.GetMapHotKeysResponse {
+	resp := &trillian.GetMapHotKeysResponse{PrefixBytes: int32(h.opts.PrefixBytes)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	m, ok := h.maps[mapID]
+	if !ok {
+		return resp
+	}
+	resp.TotalWrites = m.total
+	resp.CountStartNanos = m.start.UnixNano()
+	for prefix, c := range m.counts {
+		resp.HotKeys = append(resp.HotKeys, &trillian.MapPrefixWrites{
+			IndexPrefix:  []byte(prefix),
+			Writes:       c.writes,
+			MaxOvercount: c.overcount,
+		})
+	}
+	sort.Slice(resp.HotKeys, func(i, j int) bool {
+		a, b := resp.HotKeys[i], resp.HotKeys[j]
+		if a.Writes != b.Writes {
+			return a.Writes > b.Writes
+		}
+		return bytes.Compare(a.IndexPrefix, b.IndexPrefix) < 0
+	})
+	if len(resp.HotKeys) > limit {
+		resp.HotKeys = resp.HotKeys[:limit]
+	}
+	return resp
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+// leavesUnder returns n leaves whose indices start with prefix.
+func leavesUnder(prefix string, n int) []*trillian.MapLeaf {
+	leaves := make([]*trillian.MapLeaf, n)
+	for i := range leaves {
+		leaves[i] = &trillian.MapLeaf{Index: []byte(prefix + "-rest-of-the-index")}
+	}
+	return leaves
+}
+
+func TestHotKeys(t *testing.T) {
+	h := newHotKeys(MapHotKeyOptions{PrefixBytes: 2, MaxPrefixes: 3}, monitoring.InertMetricFactory{})
+	h.record(1, leavesUnder("aa", 5))
+	h.record(1, leavesUnder("bb", 2))
+	h.record(1, leavesUnder("cc", 1))
+	h.record(2, leavesUnder("aa", 1))
+	// dd replaces cc, the least written prefix, and inherits its count.
+	h.record(1, leavesUnder("dd", 2))
+
+	got := h.get(1, 10)
+	want := &trillian.GetMapHotKeysResponse{
+		PrefixBytes:     2,
+		TotalWrites:     10,
+		CountStartNanos: got.CountStartNanos,
+		HotKeys: []*trillian.MapPrefixWrites{
+			{IndexPrefix: []byte("aa"), Writes: 5},
+			{IndexPrefix: []byte("dd"), Writes: 3, MaxOvercount: 1},
+			{IndexPrefix: []byte("bb"), Writes: 2},
+		},
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("get(1) diff:\n%v", pretty.Compare(got, want))
+	}
+	if got.CountStartNanos == 0 {
+		t.Error("get(1).CountStartNanos not set")
+	}
+
+	if got := h.get(1, 1).HotKeys; len(got) != 1 || string(got[0].IndexPrefix) != "aa" {
+		t.Errorf("get(1, 1): %v, want only aa", got)
+	}
+	if got := h.get(3, 10); got.TotalWrites != 0 || len(got.HotKeys) != 0 {
+		t.Errorf("get(3): %v, want no writes", got)
+	}
+}
+
+func TestHotKeys_ShareMetric(t *testing.T) {
+	h := newHotKeys(MapHotKeyOptions{PrefixBytes: 1}, monitoring.InertMetricFactory{})
+	h.record(1, leavesUnder("a", 3))
+	h.record(1, leavesUnder("b", 1))
+	if got, want := h.shareGauge.Value("1"), 0.75; got != want {
+		t.Errorf("hottest_prefix_write_share: %v, want %v", got, want)
+	}
+}
+
+func TestHotKeys_Disabled(t *testing.T) {
+	h := newHotKeys(MapHotKeyOptions{}, monitoring.InertMetricFactory{})
+	h.record(1, leavesUnder("a", 3))
+	if got := h.get(1, 10); got.TotalWrites != 0 {
+		t.Errorf("get(1): %v, want no writes", got)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian/server/errmsg"
+	"google.golang.org/grpc/codes"
+)
+
+// Reasons recorded by the rejected_requests metric.
+const (
+	rejectTooManyIndices = "too_many_indices"
+	rejectTooManyLeaves  = "too_many_leaves"
+	rejectTooLarge       = "too_large"
+	rejectTimeout        = "timeout"
+	rejectStageTimeout   = "stage_timeout"
+)
+
+// MapLimits bounds the work done by each request to a TrillianMapServer, so
+// that a single client cannot start long transactions which starve the other
+// maps of the server. Zero values disable the corresponding limit.
+type MapLimits struct {
+	// MaxGetIndices is the maximum number of indices read by each GetLeaves,
+	// GetLeavesByRevision or GetLeavesByRevisionNoProof request, and of leaves
+	// returned by each GetLeavesByIndexPrefix request.
+	MaxGetIndices int
+	// MaxSetLeaves is the maximum number of leaves written by each SetLeaves
+	// or WriteLeaves request, in total by each SetMultiMapLeaves request, and
+	// cleared by each DeleteLeafRange request, which leaves the rest of larger
+	// ranges to later requests.
+	MaxSetLeaves int
+	// MaxRequestBytes is the maximum serialized size of each write request.
+	MaxRequestBytes int
+	// RequestTimeout bounds the duration of each unary read or write request,
+	// in addition to the deadline set by the client. Streaming requests are
+	// not bounded.
+	RequestTimeout time.Duration
+	// StageTimeouts bounds the duration of each stage of a write request.
+	StageTimeouts MapStageTimeouts
+}
+
+// checkIndexCount returns an InvalidArgument error if n indices exceed the
+// limit of a read request.
+func (t *TrillianMapServer) checkIndexCount(mapID int64, n int) error {
+	if max := t.opts.Limits.MaxGetIndices; max > 0 && n > max {
+		t.rejectCounter.Inc(strconv.FormatInt(mapID, 10), rejectTooManyIndices)
+		return errmsg.New(codes.InvalidArgument, errmsg.TooManyIndices, errmsg.Params{"got": n, "max": max})
+	}
+	return nil
+}
+
+// checkWriteSize returns an InvalidArgument error if n leaves exceed the limit
+// of a write request, or a ResourceExhausted error if req is too large.
+func (t *TrillianMapServer) checkWriteSize(mapID int64, n int, req proto.Message) error {
+	label := strconv.FormatInt(mapID, 10)
+	if max := t.opts.Limits.MaxSetLeaves; max > 0 && n > max {
+		t.rejectCounter.Inc(label, rejectTooManyLeaves)
+		return errmsg.New(codes.InvalidArgument, errmsg.TooManyLeaves, errmsg.Params{"got": n, "max": max})
+	}
+	if max := t.opts.Limits.MaxRequestBytes; max > 0 {
+		if size := proto.Size(req); size > max {
+			t.rejectCounter.Inc(label, rejectTooLarge)
+			return errmsg.New(codes.ResourceExhausted, errmsg.RequestTooLarge, errmsg.Params{"got": size, "max": max})
+		}
+	}
+	return nil
+}
+
+// withRequestTimeout returns a context which expires after the request
+// timeout, and a function which must be called with the error returned by the
+// request. The function releases the context, and turns errors caused by the
+// expiry of the request timeout into DeadlineExceeded errors.
+func (t *TrillianMapServer) withRequestTimeout(ctx context.Context, mapID int64) (context.Context, func(error) error) {
+	timeout := t.opts.Limits.RequestTimeout
+	if timeout <= 0 {
+		return ctx, func(err error) error { return err }
+	}
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func(err error) error {
+		defer cancel()
+		// Only errors after the server deadline, rather than the client's, are
+		// rewritten.
+		if err == nil || ctx.Err() != context.DeadlineExceeded || parent.Err() != nil {
+			return err
+		}
+		t.rejectCounter.Inc(strconv.FormatInt(mapID, 10), rejectTimeout)
+		return errmsg.New(codes.DeadlineExceeded, errmsg.RequestTimedOut, errmsg.Params{"timeout": timeout})
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/server/errmsg"
+	"github.com/google/trillian/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	mtestonly "github.com/google/trillian/monitoring/testonly"
+)
+
+func TestMapLimits_Rejections(t *testing.T) {
+	ctx := context.Background()
+	leaves := func(n int) []*trillian.MapLeaf {
+		ret := make([]*trillian.MapLeaf, n)
+		for i := range ret {
+			ret[i] = &trillian.MapLeaf{Index: []byte{byte(i)}, LeafValue: make([]byte, 100)}
+		}
+		return ret
+	}
+	limits := MapLimits{MaxGetIndices: 2, MaxSetLeaves: 2, MaxRequestBytes: 150}
+
+	for _, test := range []struct {
+		desc       string
+		call       func(s *TrillianMapServer) error
+		wantCode   codes.Code
+		wantReason errmsg.Reason
+		wantLabel  string
+	}{
+		{
+			desc: "GetLeaves",
+			call: func(s *TrillianMapServer) error {
+				_, err := s.GetLeaves(ctx, &trillian.GetMapLeavesRequest{MapId: mapID1, Index: [][]byte{{1}, {2}, {3}}})
+				return err
+			},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.TooManyIndices,
+			wantLabel:  rejectTooManyIndices,
+		},
+		{
+			desc: "GetLeavesByRevisionNoProof",
+			call: func(s *TrillianMapServer) error {
+				_, err := s.GetLeavesByRevisionNoProof(ctx, &trillian.GetMapLeavesByRevisionRequest{MapId: mapID1, Index: [][]byte{{1}, {2}, {3}}})
+				return err
+			},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.TooManyIndices,
+			wantLabel:  rejectTooManyIndices,
+		},
+		{
+			desc: "SetLeaves count",
+			call: func(s *TrillianMapServer) error {
+				_, err := s.SetLeaves(ctx, &trillian.SetMapLeavesRequest{MapId: mapID1, Leaves: leaves(3)})
+				return err
+			},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.TooManyLeaves,
+			wantLabel:  rejectTooManyLeaves,
+		},
+		{
+			desc: "SetLeaves bytes",
+			call: func(s *TrillianMapServer) error {
+				_, err := s.SetLeaves(ctx, &trillian.SetMapLeavesRequest{MapId: mapID1, Leaves: leaves(2)})
+				return err
+			},
+			wantCode:   codes.ResourceExhausted,
+			wantReason: errmsg.RequestTooLarge,
+			wantLabel:  rejectTooLarge,
+		},
+		{
+			desc: "SetMultiMapLeaves total",
+			call: func(s *TrillianMapServer) error {
+				_, err := s.SetMultiMapLeaves(ctx, &trillian.SetMultiMapLeavesRequest{Requests: []*trillian.SetMapLeavesRequest{
+					{MapId: mapID1, Leaves: leaves(1)},
+					{MapId: mapID1 + 1, Leaves: leaves(2)},
+				}})
+				return err
+			},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.TooManyLeaves,
+			wantLabel:  rejectTooManyLeaves,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			// No storage is expected to be used by rejected requests.
+			s := NewTrillianMapServer(extension.Registry{}, TrillianMapServerOptions{Limits: limits})
+			rejected := mtestonly.NewCounterSnapshot(s.rejectCounter, "1", test.wantLabel)
+			err := test.call(s)
+			if got := status.Code(err); got != test.wantCode {
+				t.Errorf("got error %v, want code %v", err, test.wantCode)
+			}
+			if info := errmsg.Info(err); info == nil || info.Reason != string(test.wantReason) {
+				t.Errorf("got error info %v, want reason %v", info, test.wantReason)
+			}
+			if got := rejected.Delta(); got != 1 {
+				t.Errorf("rejected_requests delta=%v, want 1", got)
+			}
+		})
+	}
+}
+
+func TestMapLimits_RequestTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mapStorage := storage.NewMockMapStorage(ctrl)
+	// The snapshot blocks until the request times out.
+	mapStorage.EXPECT().SnapshotForTree(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _ *trillian.Tree) (storage.ReadOnlyMapTreeTX, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	s := NewTrillianMapServer(extension.Registry{
+		AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+		MapStorage:   mapStorage,
+	}, TrillianMapServerOptions{Limits: MapLimits{RequestTimeout: 10 * time.Millisecond}})
+	rejected := mtestonly.NewCounterSnapshot(s.rejectCounter, "1", rejectTimeout)
+
+	_, err := s.GetLeaves(context.Background(), &trillian.GetMapLeavesRequest{MapId: mapID1, Index: [][]byte{make([]byte, 32)}})
+	if got, want := status.Code(err), codes.DeadlineExceeded; got != want {
+		t.Errorf("GetLeaves()=%v, want code %v", err, want)
+	}
+	if info := errmsg.Info(err); info == nil || info.Reason != string(errmsg.RequestTimedOut) {
+		t.Errorf("GetLeaves() error info %v, want reason %v", info, errmsg.RequestTimedOut)
+	}
+	if got := rejected.Delta(); got != 1 {
+		t.Errorf("rejected_requests delta=%v, want 1", got)
+	}
+}
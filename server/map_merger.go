@@ -0,0 +1,203 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/compression"
+	"github.com/google/trillian/storage/querytag"
+	"github.com/google/trillian/trees"
+	"github.com/google/trillian/util/clock"
+)
+
+// MapMerger merges the writes queued by a map write server with QueueWrites
+// set into new revisions of their maps, in the way the log sequencer
+// integrates queued log entries.
+//
+// Each run merges up to batchSize of the oldest queued writes of each map into
+// a single revision. Where several writes set the same leaf, the latest one
+// wins, and the new map root takes the metadata of the latest write.
+type MapMerger struct {
+	server    *TrillianMapServer
+	batchSize int
+
+	// minRunInterval defines how frequently queued writes are merged. Actual
+	// runs happen randomly between [minInterval,2*minInterval).
+	minRunInterval time.Duration
+	timeSource     clock.TimeSource
+
+	mergedCounter monitoring.Counter
+}
+
+// NewMapMerger returns a new MapMerger, which merges the queued writes of the
+// maps served by server.
+func NewMapMerger(server *TrillianMapServer, batchSize int, minRunInterval time.Duration, timeSource clock.TimeSource, mf monitoring.MetricFactory) *MapMerger {
+	if mf == nil {
+		mf = monitoring.InertMetricFactory{}
+	}
+	return &MapMerger{
+		server:         server,
+		batchSize:      batchSize,
+		minRunInterval: minRunInterval,
+		timeSource:     timeSource,
+		mergedCounter: mf.NewCounter(
+			"map_merged_writes",
+			"Number of queued writes merged into new map revisions",
+			monitoring.TreeIDLabel),
+	}
+}
+
+// Run starts merging queued writes. It runs until ctx is cancelled.
+func (m *MapMerger) Run(ctx context.Context) {
+	for {
+		count, err := m.RunOnce(ctx)
+		if err != nil {
+			glog.Errorf("MapMerger.Run: %v", err)
+		}
+		if count > 0 {
+			glog.V(1).Infof("MapMerger.Run: merged %v queued writes", count)
+		}
+
+		d := m.minRunInterval + time.Duration(rand.Int63n(m.minRunInterval.Nanoseconds()))
+		if err := clock.SleepSource(ctx, d, m.timeSource); err != nil {
+			return
+		}
+	}
+}
+
+// RunOnce merges the queued writes of each active map into a new revision.
+// Returns the number of writes which were merged.
+//
+// It attempts to process as many maps as possible, regardless of failures. If
+// it encounters any failures the resulting error is non-nil.
+func (m *MapMerger) RunOnce(ctx context.Context) (int, error) {
+	trees, err := storage.ListTrees(ctx, m.server.registry.AdminStorage, false /* includeDeleted */)
+	if err != nil {
+		return 0, fmt.Errorf("error listing trees: %v", err)
+	}
+
+	count := 0
+	var errs []error
+	for _, tree := range trees {
+		if tree.TreeType != trillian.TreeType_MAP || tree.TreeState != trillian.TreeState_ACTIVE {
+			continue
+		}
+		merged, err := m.merge(ctx, tree)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error merging writes to map %v: %v", tree.TreeId, err))
+			continue
+		}
+		if merged > 0 {
+			m.mergedCounter.Add(float64(merged), fmt.Sprint(tree.TreeId))
+			m.server.notifier.notify(tree.TreeId)
+		}
+		count += merged
+	}
+
+	if len(errs) == 0 {
+		return count, nil
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("encountered errors merging map writes:")
+	for _, err := range errs {
+		buf.WriteString("\n\t")
+		buf.WriteString(err.Error())
+	}
+	return count, errors.New(buf.String())
+}
+
+// merge merges the oldest queued writes of tree into a new revision, and
+// returns the number of writes merged.
+func (m *MapMerger) merge(ctx context.Context, tree *trillian.Tree) (int, error) {
+	hasher, err := m.server.registry.MapHashers.NewMapHasherForTree(tree)
+	if err != nil {
+		return 0, err
+	}
+	ctx = trees.NewContext(ctx, tree)
+
+	codec, err := compression.ForTree(tree)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = m.server.registry.MapStorage.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		dequeuer, ok := tx.(storage.MapWriteDequeuer)
+		if !ok {
+			return errors.New("map storage does not support queued writes")
+		}
+		writes, err := dequeuer.DequeueMapWrites(ctx, m.batchSize)
+		if err != nil || len(writes) == 0 {
+			return err
+		}
+		// Queued writes hold uncompressed leaves, which are compressed as
+		// they are merged.
+		tx = compression.WrapTX(tx, codec)
+
+		// Later writes replace the leaves set by earlier ones.
+		leaves := make([]*trillian.MapLeaf, 0, len(writes[0].Leaves))
+		pos := make(map[string]int)
+		for _, w := range writes {
+			for _, l := range w.Leaves {
+				if i, ok := pos[string(l.Index)]; ok {
+					leaves[i] = l
+					continue
+				}
+				pos[string(l.Index)] = len(leaves)
+				leaves = append(leaves, l)
+			}
+		}
+		hkv := make([]merkle.HashKeyValue, 0, len(leaves))
+		for _, l := range leaves {
+			l.LeafHash = hasher.HashLeaf(tree.TreeId, l.Index, l.LeafValue)
+			hkv = append(hkv, merkle.HashKeyValue{HashedKey: l.Index, HashedValue: l.LeafHash})
+		}
+
+		rev, err := tx.WriteRevision(ctx)
+		if err != nil {
+			return err
+		}
+		glog.V(2).Infof("%v: Merging %d queued writes at revision %v", tree.TreeId, len(writes), rev)
+		ctx = querytag.WithRevision(ctx, rev)
+		if err := m.server.writeLeaves(ctx, tree.TreeId, tx, leaves); err != nil {
+			return err
+		}
+		if _, err := m.server.updateTree(ctx, tree, hasher, tx, hkv, writes[len(writes)-1].Metadata, rev, true /* singleTX */); err != nil {
+			return err
+		}
+		count = len(writes)
+		return nil
+	})
+	if err == storage.ErrTreeNeedsInit {
+		// Writes to the map stay queued until it is initialized.
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
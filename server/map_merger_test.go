@@ -0,0 +1,211 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/server/errmsg"
+	"github.com/google/trillian/storage"
+	stestonly "github.com/google/trillian/storage/testonly"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// queueTX is a MapTreeTX which supports queued writes.
+type queueTX struct {
+	*storage.MockMapTreeTX
+	writes []*storage.QueuedMapWrite
+	limit  int
+}
+
+func (tx *queueTX) DequeueMapWrites(ctx context.Context, limit int) ([]*storage.QueuedMapWrite, error) {
+	tx.limit = limit
+	return tx.writes, nil
+}
+
+// queueStorage is a MapStorage which supports queued writes.
+type queueStorage struct {
+	stestonly.FakeMapStorage
+	queued []*storage.QueuedMapWrite
+}
+
+func (s *queueStorage) QueueMapWrite(ctx context.Context, tree *trillian.Tree, w *storage.QueuedMapWrite) error {
+	s.queued = append(s.queued, w)
+	return nil
+}
+
+func TestMapMerger_RunOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	index := func(b byte) []byte { return bytes.Repeat([]byte{b}, 32) }
+	writes := []*storage.QueuedMapWrite{
+		{
+			Leaves:   []*trillian.MapLeaf{{Index: index(1), LeafValue: []byte("a")}, {Index: index(2), LeafValue: []byte("b")}},
+			Metadata: []byte("first"),
+		},
+		{
+			Leaves:   []*trillian.MapLeaf{{Index: index(1), LeafValue: []byte("c")}},
+			Metadata: []byte("second"),
+		},
+	}
+
+	listTX := storage.NewMockReadOnlyAdminTX(ctrl)
+	listTX.EXPECT().ListTrees(gomock.Any(), false).Return([]*trillian.Tree{stestonly.LogTree, stestonly.MapTree}, nil)
+	listTX.EXPECT().Close().Return(nil)
+	listTX.EXPECT().Commit().Return(nil)
+
+	tx := &queueTX{MockMapTreeTX: storage.NewMockMapTreeTX(ctrl), writes: writes}
+	tx.EXPECT().WriteRevision(gomock.Any()).Return(int64(5), nil)
+	// The second write to index 1 replaces the first.
+	var set []string
+	tx.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Times(2).DoAndReturn(
+		func(_ context.Context, index []byte, l *trillian.MapLeaf) error {
+			set = append(set, string(l.LeafValue))
+			return nil
+		})
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil, nil)
+	tx.EXPECT().SetMerkleNodes(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	var root types.MapRootV1
+	tx.EXPECT().StoreSignedMapRoot(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, smr *trillian.SignedMapRoot) error {
+			return root.UnmarshalBinary(smr.MapRoot)
+		})
+	tx.EXPECT().Commit(gomock.Any()).Return(nil)
+	tx.EXPECT().Close().Return(nil)
+
+	mapServer := NewTrillianMapServer(extension.Registry{
+		AdminStorage: &stestonly.FakeAdminStorage{ReadOnlyTX: []storage.ReadOnlyAdminTX{listTX}},
+		MapStorage:   &stestonly.FakeMapStorage{TX: tx},
+	}, TrillianMapServerOptions{})
+	merger := NewMapMerger(mapServer, 10, time.Second, clock.System, nil)
+
+	count, err := merger.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce(): %v", err)
+	}
+	if got, want := count, len(writes); got != want {
+		t.Errorf("RunOnce()=%v, want %v", got, want)
+	}
+	if got, want := tx.limit, 10; got != want {
+		t.Errorf("DequeueMapWrites() limit=%v, want %v", got, want)
+	}
+	if got, want := set, []string{"c", "b"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Set() values=%v, want %v", got, want)
+	}
+	if got, want := root.Revision, uint64(5); got != want {
+		t.Errorf("Revision=%v, want %v", got, want)
+	}
+	if got, want := string(root.Metadata), "second"; got != want {
+		t.Errorf("Metadata=%q, want %q", got, want)
+	}
+}
+
+func TestWriteLeaves_Queued(t *testing.T) {
+	ctx := context.Background()
+	leaves := []*trillian.MapLeaf{{Index: make([]byte, 32), LeafValue: []byte("value")}}
+
+	for _, test := range []struct {
+		desc       string
+		req        *trillian.WriteMapLeavesRequest
+		noQueue    bool
+		wantCode   codes.Code
+		wantReason errmsg.Reason
+	}{
+		{
+			desc: "queued",
+			req:  &trillian.WriteMapLeavesRequest{MapId: mapID1, Leaves: leaves, Metadata: []byte("meta")},
+		},
+		{
+			desc:       "expect revision",
+			req:        &trillian.WriteMapLeavesRequest{MapId: mapID1, Leaves: leaves, ExpectRevision: 2},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.QueuedWriteUnsupported,
+		},
+		{
+			desc:       "idempotency token",
+			req:        &trillian.WriteMapLeavesRequest{MapId: mapID1, Leaves: leaves, IdempotencyToken: []byte("token")},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.QueuedWriteUnsupported,
+		},
+		{
+			desc:       "dry run",
+			req:        &trillian.WriteMapLeavesRequest{MapId: mapID1, Leaves: leaves, DryRun: true},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.QueuedWriteUnsupported,
+		},
+		{
+			desc:       "revision lease",
+			req:        &trillian.WriteMapLeavesRequest{MapId: mapID1, Leaves: leaves, RevisionLease: []byte("token")},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.QueuedWriteUnsupported,
+		},
+		{
+			desc:     "storage without queue",
+			req:      &trillian.WriteMapLeavesRequest{MapId: mapID1, Leaves: leaves},
+			noQueue:  true,
+			wantCode: codes.Unimplemented,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			qs := &queueStorage{}
+			registry := extension.Registry{
+				AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+				MapStorage:   qs,
+			}
+			if test.noQueue {
+				registry.MapStorage = &stestonly.FakeMapStorage{}
+			}
+			mapServer := NewTrillianMapServer(registry, TrillianMapServerOptions{QueueWrites: true})
+			writeServer := NewTrillianMapWriteServer(registry, mapServer)
+
+			rsp, err := writeServer.WriteLeaves(ctx, test.req)
+			if got, want := status.Code(err), test.wantCode; got != want {
+				t.Fatalf("WriteLeaves(): %v, want code %v", err, want)
+			}
+			if test.wantReason != "" {
+				if info := errmsg.Info(err); info == nil || info.Reason != string(test.wantReason) {
+					t.Errorf("WriteLeaves(): %v, want reason %v", err, test.wantReason)
+				}
+			}
+			if err != nil {
+				return
+			}
+			if got, want := rsp.Revision, int64(0); got != want {
+				t.Errorf("WriteLeaves().Revision=%v, want %v", got, want)
+			}
+			if got, want := len(qs.queued), 1; got != want {
+				t.Fatalf("queued %d writes, want %d", got, want)
+			}
+			w := qs.queued[0]
+			if !proto.Equal(w.Leaves[0], leaves[0]) || len(w.Leaves[0].LeafHash) == 0 || string(w.Metadata) != "meta" {
+				t.Errorf("queued %+v, want leaves %v with hashes and metadata %q", w, leaves, "meta")
+			}
+		})
+	}
+}
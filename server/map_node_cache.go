@@ -0,0 +1,153 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/tree"
+)
+
+// nodeCacheKey identifies a Merkle node of a map as read at a revision. The
+// node read for a published revision never changes, so it can be cached.
+type nodeCacheKey struct {
+	treeID   int64
+	revision int64
+	nodeID   string
+}
+
+// nodeCacheEntry is a cached node, or the absence of a node, which means the
+// node has the default hash.
+type nodeCacheEntry struct {
+	key   nodeCacheKey
+	node  tree.Node
+	found bool
+}
+
+// nodeCache is an LRU cache of the Merkle nodes read by map inclusion
+// proofs. It is safe for concurrent use.
+type nodeCache struct {
+	size        int
+	hitCounter  monitoring.Counter
+	missCounter monitoring.Counter
+
+	mu      sync.Mutex
+	lru     *list.List // Of *nodeCacheEntry, most recently used first.
+	entries map[nodeCacheKey]*list.Element
+}
+
+// newNodeCache returns a cache of up to size nodes.
+func newNodeCache(size int, mf monitoring.MetricFactory) *nodeCache {
+	return &nodeCache{
+		size: size,
+		hitCounter: mf.NewCounter(
+			"node_cache_hits",
+			"Number of Merkle nodes of map inclusion proofs found in the node cache",
+			"map_id",
+		),
+		missCounter: mf.NewCounter(
+			"node_cache_misses",
+			"Number of Merkle nodes of map inclusion proofs read from storage",
+			"map_id",
+		),
+		lru:     list.New(),
+		entries: make(map[nodeCacheKey]*list.Element),
+	}
+}
+
+// get returns the cached nodes among ids, keyed by node ID, and the IDs which
+// are not cached. IDs cached as absent are in neither.
+func (c *nodeCache) get(treeID, revision int64, ids []tree.NodeID) (map[string]tree.Node, []tree.NodeID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	nodes := make(map[string]tree.Node)
+	var missing []tree.NodeID
+	for _, id := range ids {
+		elem, ok := c.entries[nodeCacheKey{treeID, revision, id.AsKey()}]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		c.lru.MoveToFront(elem)
+		if e := elem.Value.(*nodeCacheEntry); e.found {
+			nodes[e.key.nodeID] = e.node
+		}
+	}
+	label := strconv.FormatInt(treeID, 10)
+	c.hitCounter.Add(float64(len(ids)-len(missing)), label)
+	c.missCounter.Add(float64(len(missing)), label)
+	return nodes, missing
+}
+
+// put caches the nodes read for ids, and the absence of the other ids.
+func (c *nodeCache) put(treeID, revision int64, ids []tree.NodeID, nodes []tree.Node) {
+	found := make(map[string]tree.Node, len(nodes))
+	for _, n := range nodes {
+		found[n.NodeID.AsKey()] = n
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range ids {
+		key := nodeCacheKey{treeID, revision, id.AsKey()}
+		if _, ok := c.entries[key]; ok {
+			continue
+		}
+		n, ok := found[key.nodeID]
+		c.entries[key] = c.lru.PushFront(&nodeCacheEntry{key: key, node: n, found: ok})
+	}
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*nodeCacheEntry).key)
+	}
+}
+
+// cachingMapTX serves the Merkle nodes of published revisions of a map from a
+// nodeCache, and reads the others from the wrapped transaction.
+type cachingMapTX struct {
+	storage.ReadOnlyMapTreeTX
+	treeID int64
+	cache  *nodeCache
+}
+
+// GetMerkleNodes implements storage.NodeReader.
+func (c *cachingMapTX) GetMerkleNodes(ctx context.Context, revision int64, ids []tree.NodeID) ([]tree.Node, error) {
+	byID, missing := c.cache.get(c.treeID, revision, ids)
+	if len(missing) > 0 {
+		read, err := c.ReadOnlyMapTreeTX.GetMerkleNodes(ctx, revision, missing)
+		if err != nil {
+			return nil, err
+		}
+		c.cache.put(c.treeID, revision, missing, read)
+		for _, n := range read {
+			byID[n.NodeID.AsKey()] = n
+		}
+	}
+
+	// Return the nodes in the order of ids, like storage does.
+	nodes := make([]tree.Node, 0, len(byID))
+	for _, id := range ids {
+		if n, ok := byID[id.AsKey()]; ok {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
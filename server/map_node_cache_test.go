@@ -0,0 +1,103 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/tree"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func cacheTestNodes(depths ...int) []tree.Node {
+	path := []byte{0x80, 0x40}
+	nodes := make([]tree.Node, len(depths))
+	for i, d := range depths {
+		nodes[i] = tree.Node{
+			NodeID:       tree.NewNodeIDFromHash(path).MaskLeft(d),
+			Hash:         []byte{byte(d)},
+			NodeRevision: 1,
+		}
+	}
+	return nodes
+}
+
+func nodeIDs(nodes []tree.Node) []tree.NodeID {
+	ids := make([]tree.NodeID, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.NodeID
+	}
+	return ids
+}
+
+func TestCachingMapTX_GetMerkleNodes(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	all := cacheTestNodes(1, 2, 3, 4)
+	// Node 2 is absent from storage.
+	stored := []tree.Node{all[0], all[1], all[3]}
+	mockTX := storage.NewMockReadOnlyMapTreeTX(ctrl)
+	gomock.InOrder(
+		mockTX.EXPECT().GetMerkleNodes(gomock.Any(), int64(5), nodeIDs(all[:3])).Return(stored[:2], nil),
+		mockTX.EXPECT().GetMerkleNodes(gomock.Any(), int64(5), nodeIDs(all[3:])).Return(stored[2:], nil),
+		// Nodes are cached per revision.
+		mockTX.EXPECT().GetMerkleNodes(gomock.Any(), int64(6), nodeIDs(all[:1])).Return(stored[:1], nil),
+	)
+	tx := &cachingMapTX{ReadOnlyMapTreeTX: mockTX, treeID: 1, cache: newNodeCache(10, monitoring.InertMetricFactory{})}
+
+	for _, test := range []struct {
+		desc string
+		rev  int64
+		ids  []tree.NodeID
+		want []tree.Node
+	}{
+		{desc: "cold", rev: 5, ids: nodeIDs(all[:3]), want: stored[:2]},
+		{desc: "cached", rev: 5, ids: nodeIDs(all[:3]), want: stored[:2]},
+		{desc: "partly-cached", rev: 5, ids: nodeIDs(all), want: stored},
+		{desc: "other-revision", rev: 6, ids: nodeIDs(all[:1]), want: stored[:1]},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := tx.GetMerkleNodes(ctx, test.rev, test.ids)
+			if err != nil {
+				t.Fatalf("GetMerkleNodes(): %v", err)
+			}
+			if diff := pretty.Compare(got, test.want); diff != "" {
+				t.Errorf("GetMerkleNodes() diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNodeCache_Evicts(t *testing.T) {
+	nodes := cacheTestNodes(1, 2, 3)
+	c := newNodeCache(2, monitoring.InertMetricFactory{})
+	c.put(1, 5, nodeIDs(nodes[:2]), nodes[:2])
+	// Using node 1 makes node 2 the least recently used.
+	if _, missing := c.get(1, 5, nodeIDs(nodes[:1])); len(missing) != 0 {
+		t.Fatalf("get() missing %v, want none", missing)
+	}
+	c.put(1, 5, nodeIDs(nodes[2:]), nodes[2:])
+
+	got, missing := c.get(1, 5, nodeIDs(nodes))
+	if len(got) != 2 || len(missing) != 1 || missing[0].AsKey() != nodes[1].NodeID.AsKey() {
+		t.Errorf("get() returned %d nodes and missing %v, want node 2 evicted", len(got), missing)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/bits"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/tree"
+)
+
+// Names of the preload strategies returned by NewPreloadStrategy.
+const (
+	PreloadNone        = "none"
+	PreloadFullSibling = "full_sibling"
+	PreloadAdaptive    = "adaptive"
+)
+
+// siblingChunkSize is the number of leaves whose siblings are computed by a
+// goroutine of calcAllSiblingsParallel at a time.
+const siblingChunkSize = 256
+
+// DefaultAdaptivePreloadMinBatchSize is the default minimum number of leaves
+// in an update for the adaptive preload strategy to preload their siblings.
+const DefaultAdaptivePreloadMinBatchSize = 64
+
+// PreloadStrategy selects the Merkle nodes loaded into the subtree cache of a
+// transaction before a map update is applied in single-transaction mode.
+type PreloadStrategy interface {
+	// Name identifies the strategy in metrics.
+	Name() string
+	// NodesToPreload returns the IDs of the nodes to load before updating the
+	// leaves in hkv, in a tree with the given depth. It returns nil if nothing
+	// should be preloaded.
+	NodesToPreload(ctx context.Context, treeDepth int, hkv []merkle.HashKeyValue) []tree.NodeID
+}
+
+// NewPreloadStrategy returns the PreloadStrategy with the given name.
+// minBatchSize is only used by the adaptive strategy. parallelism bounds the
+// number of goroutines computing the nodes to preload, or is GOMAXPROCS if
+// not positive.
+func NewPreloadStrategy(name string, minBatchSize, parallelism int) (PreloadStrategy, error) {
+	switch name {
+	case PreloadNone:
+		return NoPreload{}, nil
+	case PreloadFullSibling:
+		return FullSiblingPreload{Parallelism: parallelism}, nil
+	case PreloadAdaptive:
+		if minBatchSize <= 0 {
+			return nil, fmt.Errorf("adaptive preload min batch size must be positive, got %d", minBatchSize)
+		}
+		return AdaptivePreload{MinBatchSize: minBatchSize, Parallelism: parallelism}, nil
+	default:
+		return nil, fmt.Errorf("unknown preload strategy %q", name)
+	}
+}
+
+// NoPreload never preloads nodes, so that the sparse Merkle writer reads them
+// as it needs them.
+type NoPreload struct{}
+
+// Name implements PreloadStrategy.
+func (NoPreload) Name() string { return PreloadNone }
+
+// NodesToPreload implements PreloadStrategy.
+func (NoPreload) NodesToPreload(context.Context, int, []merkle.HashKeyValue) []tree.NodeID {
+	return nil
+}
+
+// FullSiblingPreload preloads all the siblings on the Merkle paths of the
+// updated leaves, which are all the nodes the sparse Merkle writer reads.
+type FullSiblingPreload struct {
+	// Parallelism bounds the number of goroutines computing the siblings. If
+	// not positive, GOMAXPROCS is used.
+	Parallelism int
+}
+
+// Name implements PreloadStrategy.
+func (FullSiblingPreload) Name() string { return PreloadFullSibling }
+
+// NodesToPreload implements PreloadStrategy.
+func (f FullSiblingPreload) NodesToPreload(ctx context.Context, treeDepth int, hkv []merkle.HashKeyValue) []tree.NodeID {
+	return calcAllSiblingsParallel(ctx, hkv, f.Parallelism)
+}
+
+// AdaptivePreload preloads all the siblings on the Merkle paths of the updated
+// leaves only for updates of at least MinBatchSize leaves. Small updates read
+// few nodes, for which a single large read over-fetches.
+type AdaptivePreload struct {
+	MinBatchSize int
+	// Parallelism bounds the number of goroutines computing the siblings. If
+	// not positive, GOMAXPROCS is used.
+	Parallelism int
+}
+
+// Name implements PreloadStrategy.
+func (AdaptivePreload) Name() string { return PreloadAdaptive }
+
+// NodesToPreload implements PreloadStrategy.
+func (a AdaptivePreload) NodesToPreload(ctx context.Context, treeDepth int, hkv []merkle.HashKeyValue) []tree.NodeID {
+	if len(hkv) < a.MinBatchSize {
+		return nil
+	}
+	return calcAllSiblingsParallel(ctx, hkv, a.Parallelism)
+}
+
+// doPreload causes the subtreeCache in tx to become populated with the
+// subtrees containing the nodes selected by the preload strategy for the
+// indices specified in hkv.
+// This is a performance workaround for locking issues which occur when the
+// sparse Merkle tree code is used with a single transaction (and therefore
+// a single subtreeCache too).
+func (t *TrillianMapServer) doPreload(ctx context.Context, mapID int64, tx storage.MapTreeTX, treeDepth int, hkv []merkle.HashKeyValue) error {
+	ctx, spanEnd := spanFor(ctx, "doPreload", treeAttr(mapID), batchAttr(len(hkv)))
+	defer spanEnd()
+
+	nids := t.opts.Preload.NodesToPreload(ctx, treeDepth, hkv)
+	if len(nids) == 0 {
+		return nil
+	}
+
+	readRev, err := tx.ReadRevision(ctx)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := tx.GetMerkleNodes(ctx, readRev, nids)
+	if err != nil {
+		return err
+	}
+	label, strategy := strconv.FormatInt(mapID, 10), t.opts.Preload.Name()
+	t.preloadNodeCounter.Add(float64(len(nids)), label, strategy)
+	t.preloadHitCounter.Add(float64(len(nodes)), label, strategy)
+	return nil
+}
+
+// calcAllSiblingsParallel returns the IDs of all the siblings on the Merkle
+// paths of the leaves in hkv, without duplicates.
+//
+// The siblings at depth d of two leaves are the same node iff the leaves share
+// their first d bits, so once the leaves are sorted, each leaf only adds the
+// siblings below its longest common prefix with the previous leaf. This
+// avoids a set of all the node IDs. The sorted leaves are split into chunks of
+// siblingChunkSize, which are processed by up to parallelism goroutines, or
+// GOMAXPROCS goroutines if parallelism is not positive.
+func calcAllSiblingsParallel(_ context.Context, hkv []merkle.HashKeyValue, parallelism int) []tree.NodeID {
+	keys := make([][]byte, len(hkv))
+	for i, kv := range hkv {
+		keys[i] = kv.HashedKey
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+
+	numChunks := (len(keys) + siblingChunkSize - 1) / siblingChunkSize
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	if parallelism > numChunks {
+		parallelism = numChunks
+	}
+
+	// Each goroutine takes the next chunk, and stores its siblings at the
+	// chunk's index, so that the result does not depend on scheduling.
+	chunkSibs := make([][]tree.NodeID, numChunks)
+	next := int64(-1)
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				c := int(atomic.AddInt64(&next, 1))
+				if c >= numChunks {
+					return
+				}
+				end := (c + 1) * siblingChunkSize
+				if end > len(keys) {
+					end = len(keys)
+				}
+				chunkSibs[c] = chunkSiblings(keys, c*siblingChunkSize, end)
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for _, sibs := range chunkSibs {
+		total += len(sibs)
+	}
+	nids := make([]tree.NodeID, 0, total)
+	for _, sibs := range chunkSibs {
+		nids = append(nids, sibs...)
+	}
+	return nids
+}
+
+// chunkSiblings returns the siblings on the Merkle paths of the sorted keys
+// in [start, end) which are not on the paths of the keys before start.
+func chunkSiblings(keys [][]byte, start, end int) []tree.NodeID {
+	// Depths above which each key has new siblings.
+	shared := make([]int, end-start)
+	count := 0
+	for i := start; i < end; i++ {
+		if i > 0 {
+			shared[i-start] = commonPrefixBits(keys[i-1], keys[i])
+		}
+		count += len(keys[i])*8 - shared[i-start]
+	}
+
+	sibs := make([]tree.NodeID, 0, count)
+	for i := start; i < end; i++ {
+		nid := tree.NewNodeIDFromHash(keys[i])
+		// Nodes closest to the leaves first, like NodeID.Siblings.
+		for depth := nid.PrefixLenBits; depth > shared[i-start]; depth-- {
+			sibs = append(sibs, nid.Neighbor(depth))
+		}
+	}
+	return sibs
+}
+
+// commonPrefixBits returns the length in bits of the longest common prefix
+// of a and b.
+func commonPrefixBits(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if x := a[i] ^ b[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+	if len(a) < len(b) {
+		return len(a) * 8
+	}
+	return len(b) * 8
+}
@@ -0,0 +1,203 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/tree"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/testonly/datagen"
+
+	mtestonly "github.com/google/trillian/monitoring/testonly"
+)
+
+func preloadLeaves(n int) []merkle.HashKeyValue {
+	hkv := make([]merkle.HashKeyValue, n)
+	for i := range hkv {
+		hkv[i].HashedKey = testonly.TransparentHash(string(rune('a' + i)))
+	}
+	return hkv
+}
+
+func TestNewPreloadStrategy(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		minBatch    int
+		parallelism int
+		want        PreloadStrategy
+		wantError   bool
+	}{
+		{name: PreloadNone, want: NoPreload{}},
+		{name: PreloadFullSibling, parallelism: 2, want: FullSiblingPreload{Parallelism: 2}},
+		{name: PreloadAdaptive, minBatch: 3, want: AdaptivePreload{MinBatchSize: 3}},
+		{name: PreloadAdaptive, wantError: true},
+		{name: "large", wantError: true},
+	} {
+		got, err := NewPreloadStrategy(test.name, test.minBatch, test.parallelism)
+		if gotErr := err != nil; gotErr != test.wantError {
+			t.Errorf("NewPreloadStrategy(%q, %d): %v, want error: %v", test.name, test.minBatch, err, test.wantError)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("NewPreloadStrategy(%q, %d)=%v, want %v", test.name, test.minBatch, got, test.want)
+		}
+	}
+}
+
+func TestPreloadStrategy_NodesToPreload(t *testing.T) {
+	ctx := context.Background()
+	const depth = 256
+	small, large := preloadLeaves(2), preloadLeaves(4)
+	all := len(calcAllSiblingsParallel(ctx, large, 0))
+	for _, test := range []struct {
+		desc     string
+		strategy PreloadStrategy
+		hkv      []merkle.HashKeyValue
+		want     int
+	}{
+		{desc: "none", strategy: NoPreload{}, hkv: large, want: 0},
+		{desc: "full", strategy: FullSiblingPreload{}, hkv: large, want: all},
+		{desc: "adaptive-small", strategy: AdaptivePreload{MinBatchSize: 3}, hkv: small, want: 0},
+		{desc: "adaptive-large", strategy: AdaptivePreload{MinBatchSize: 3}, hkv: large, want: all},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := len(test.strategy.NodesToPreload(ctx, depth, test.hkv)); got != test.want {
+				t.Errorf("NodesToPreload() returned %d nodes, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDoPreload_Metrics(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hkv := preloadLeaves(2)
+	nids := calcAllSiblingsParallel(ctx, hkv, 0)
+	tx := storage.NewMockMapTreeTX(ctrl)
+	tx.EXPECT().ReadRevision(gomock.Any()).Return(int64(5), nil)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(5), nids).Return([]tree.Node{{NodeID: nids[0]}}, nil)
+
+	server := NewTrillianMapServer(extension.Registry{}, TrillianMapServerOptions{Preload: FullSiblingPreload{}})
+	requested := mtestonly.NewCounterSnapshot(server.preloadNodeCounter, "1", PreloadFullSibling)
+	hits := mtestonly.NewCounterSnapshot(server.preloadHitCounter, "1", PreloadFullSibling)
+	if err := server.doPreload(ctx, 1, tx, 256, hkv); err != nil {
+		t.Fatalf("doPreload(): %v", err)
+	}
+	if got, want := requested.Delta(), float64(len(nids)); got != want {
+		t.Errorf("preload_nodes delta=%v, want %v", got, want)
+	}
+	if got, want := hits.Delta(), 1.0; got != want {
+		t.Errorf("preload_node_hits delta=%v, want %v", got, want)
+	}
+}
+
+func TestCalcAllSiblingsParallel(t *testing.T) {
+	ctx := context.Background()
+	for _, n := range []int{0, 1, siblingChunkSize, 3*siblingChunkSize + 1} {
+		hkv := benchmarkLeaves(n)
+		// Duplicate keys have no additional siblings.
+		if n > 0 {
+			hkv = append(hkv, hkv[0])
+		}
+		want := make(map[string]bool)
+		for _, kv := range hkv {
+			for _, sib := range tree.NewNodeIDFromHash(kv.HashedKey).Siblings() {
+				want[sib.AsKey()] = true
+			}
+		}
+
+		first := calcAllSiblingsParallel(ctx, hkv, 1)
+		got := make(map[string]bool)
+		for _, nid := range first {
+			if id := nid.AsKey(); got[id] {
+				t.Errorf("calcAllSiblingsParallel(%d leaves) returned %s twice", n, id)
+			} else {
+				got[id] = true
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("calcAllSiblingsParallel(%d leaves) returned %d nodes, want %d", n, len(got), len(want))
+		}
+		for _, parallelism := range []int{0, 3, 100} {
+			if got := calcAllSiblingsParallel(ctx, hkv, parallelism); !reflect.DeepEqual(got, first) {
+				t.Errorf("calcAllSiblingsParallel(%d leaves, %d) differs from calcAllSiblingsParallel(%d leaves, 1)", n, parallelism, n)
+			}
+		}
+	}
+}
+
+func benchmarkLeaves(n int) []merkle.HashKeyValue {
+	hkv := make([]merkle.HashKeyValue, n)
+	for i := range hkv {
+		hkv[i].HashedKey = testonly.TransparentHash(fmt.Sprintf("key-%d", i))
+	}
+	return hkv
+}
+
+func BenchmarkCalcAllSiblingsParallel(b *testing.B) {
+	ctx := context.Background()
+	for _, n := range []int{100, 10000, 100000} {
+		hkv := benchmarkLeaves(n)
+		for _, parallelism := range []int{1, 4} {
+			b.Run(fmt.Sprintf("leaves-%d/parallelism-%d", n, parallelism), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					calcAllSiblingsParallel(ctx, hkv, parallelism)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkCalcAllSiblingsParallelKeys compares key distributions. Clustered
+// keys share long prefixes, so they have fewer distinct siblings than
+// uniformly random keys.
+func BenchmarkCalcAllSiblingsParallelKeys(b *testing.B) {
+	ctx := context.Background()
+	clustered, err := datagen.NewClustered(32, 16, 24)
+	if err != nil {
+		b.Fatalf("NewClustered(): %v", err)
+	}
+	for _, test := range []struct {
+		name string
+		keys datagen.KeyDistribution
+	}{
+		{name: "uniform", keys: datagen.Uniform{Size: 32}},
+		{name: "clustered", keys: clustered},
+	} {
+		indices := datagen.Indices(test.keys, rand.New(rand.NewSource(1)), 10000)
+		hkv := make([]merkle.HashKeyValue, len(indices))
+		for i, index := range indices {
+			hkv[i].HashedKey = index
+		}
+		b.Run(test.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				calcAllSiblingsParallel(ctx, hkv, 4)
+			}
+		})
+	}
+}
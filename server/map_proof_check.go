@@ -0,0 +1,89 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Results recorded by the inclusion_proof_checks metric.
+const (
+	proofCheckOK     = "ok"
+	proofCheckFailed = "failed"
+)
+
+// sampleProofChecks returns a random sample of hkv, each element of which is
+// picked with probability rate.
+func sampleProofChecks(hkv []merkle.HashKeyValue, rate float64) []merkle.HashKeyValue {
+	if rate >= 1 {
+		return hkv
+	}
+	var sample []merkle.HashKeyValue
+	for _, kv := range hkv {
+		if rand.Float64() < rate {
+			sample = append(sample, kv)
+		}
+	}
+	return sample
+}
+
+// checkProofs reads the inclusion proofs of a sample of the leaves of hkv,
+// just written at revision rev, through txRunner, and verifies them against
+// rootHash, the root calculated for the revision, before it is signed. The
+// sample size is set by the ProofCheckRate option. A proof which does not
+// verify means the sparse Merkle tree writer or the storage lost or corrupted
+// a node, and fails the write with an Internal error.
+func (t *TrillianMapServer) checkProofs(ctx context.Context, tree *trillian.Tree, hasher hashers.MapHasher, txRunner merkle.TXRunner, hkv []merkle.HashKeyValue, rev int64, rootHash []byte) error {
+	if t.opts.ProofCheckRate <= 0 {
+		return nil
+	}
+	sample := sampleProofChecks(hkv, t.opts.ProofCheckRate)
+	if len(sample) == 0 {
+		return nil
+	}
+	indices := make([][]byte, 0, len(sample))
+	for _, kv := range sample {
+		indices = append(indices, kv.HashedKey)
+	}
+	var proofs map[string][][]byte
+	if err := txRunner.RunTX(ctx, func(ctx context.Context, tx storage.MapTreeTX) error {
+		var err error
+		proofs, err = merkle.NewSparseMerkleTreeReader(rev, hasher, tx).BatchInclusionProof(ctx, rev, indices)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	label := strconv.FormatInt(tree.TreeId, 10)
+	for _, kv := range sample {
+		if err := merkle.VerifyMapInclusionProofForHash(tree.TreeId, kv.HashedKey, kv.HashedValue, rootHash, proofs[string(kv.HashedKey)], hasher); err != nil {
+			t.proofCheckCounter.Inc(label, proofCheckFailed)
+			glog.Errorf("%v: inclusion proof of index %x at revision %v does not verify against new root %x: %v", tree.TreeId, kv.HashedKey, rev, rootHash, err)
+			return status.Errorf(codes.Internal, "inclusion proof of index %x at revision %d does not verify against the new root: %v", kv.HashedKey, rev, err)
+		}
+	}
+	t.proofCheckCounter.Add(float64(len(sample)), label, proofCheckOK)
+	return nil
+}
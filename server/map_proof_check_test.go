@@ -0,0 +1,140 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/tree"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	mtestonly "github.com/google/trillian/monitoring/testonly"
+	stestonly "github.com/google/trillian/storage/testonly"
+)
+
+// fakeNodes holds the Merkle nodes written through a mock transaction, and
+// optionally corrupts them as they are written.
+type fakeNodes struct {
+	mu      sync.Mutex
+	nodes   map[string]tree.Node
+	corrupt bool
+}
+
+func (f *fakeNodes) get(_ context.Context, _ int64, ids []tree.NodeID) ([]tree.Node, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var nodes []tree.Node
+	for _, id := range ids {
+		if n, ok := f.nodes[id.AsKey()]; ok {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+func (f *fakeNodes) set(_ context.Context, nodes []tree.Node) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, n := range nodes {
+		hash := append([]byte(nil), n.Hash...)
+		if f.corrupt {
+			hash[0] ^= 1
+		}
+		n.Hash = hash
+		f.nodes[n.NodeID.AsKey()] = n
+	}
+	return nil
+}
+
+func TestSetLeaves_ProofCheck(t *testing.T) {
+	for _, test := range []struct {
+		desc     string
+		rate     float64
+		corrupt  bool
+		wantCode codes.Code
+		wantOK   float64
+		wantFail float64
+	}{
+		{desc: "disabled", corrupt: true},
+		{desc: "ok", rate: 1, wantOK: 10},
+		{desc: "corrupt", rate: 1, corrupt: true, wantCode: codes.Internal, wantFail: 1},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			nodes := &fakeNodes{nodes: make(map[string]tree.Node), corrupt: test.corrupt}
+			tx := storage.NewMockMapTreeTX(ctrl)
+			tx.EXPECT().WriteRevision(gomock.Any()).Return(int64(1), nil)
+			tx.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+			tx.EXPECT().GetMerkleNodes(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(nodes.get)
+			tx.EXPECT().SetMerkleNodes(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(nodes.set)
+			tx.EXPECT().StoreSignedMapRoot(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+			tx.EXPECT().Commit(gomock.Any()).AnyTimes().Return(nil)
+			tx.EXPECT().Close().AnyTimes().Return(nil)
+
+			server := NewTrillianMapServer(extension.Registry{
+				AdminStorage:  fakeAdminStorageForMap(ctrl, 1, mapID1),
+				MapStorage:    &stestonly.FakeMapStorage{TX: tx},
+				MetricFactory: monitoring.InertMetricFactory{},
+			}, TrillianMapServerOptions{
+				UseSingleTransaction: true,
+				ProofCheckRate:       test.rate,
+			})
+			ok := mtestonly.NewCounterSnapshot(server.proofCheckCounter, "1", proofCheckOK)
+			failed := mtestonly.NewCounterSnapshot(server.proofCheckCounter, "1", proofCheckFailed)
+
+			var leaves []*trillian.MapLeaf
+			for i := 0; i < 10; i++ {
+				index := make([]byte, 32)
+				index[0], index[31] = byte(i*25), byte(i)
+				leaves = append(leaves, &trillian.MapLeaf{Index: index, LeafValue: []byte(fmt.Sprintf("value-%d", i))})
+			}
+			_, err := server.SetLeaves(context.Background(), &trillian.SetMapLeavesRequest{MapId: mapID1, Leaves: leaves})
+			if got := status.Code(err); got != test.wantCode {
+				t.Errorf("SetLeaves(): %v, want code %v", err, test.wantCode)
+			}
+			if got := ok.Delta(); got != test.wantOK {
+				t.Errorf("%s proof checks: %v, want %v", proofCheckOK, got, test.wantOK)
+			}
+			if got := failed.Delta(); got != test.wantFail {
+				t.Errorf("%s proof checks: %v, want %v", proofCheckFailed, got, test.wantFail)
+			}
+		})
+	}
+}
+
+func TestSampleProofChecks(t *testing.T) {
+	hkv := make([]merkle.HashKeyValue, 1000)
+	if got, want := len(sampleProofChecks(hkv, 1)), len(hkv); got != want {
+		t.Errorf("sampleProofChecks(rate 1): %d leaves, want %d", got, want)
+	}
+	if got := len(sampleProofChecks(hkv, 0)); got != 0 {
+		t.Errorf("sampleProofChecks(rate 0): %d leaves, want 0", got)
+	}
+	if got := len(sampleProofChecks(hkv, 0.5)); got < 350 || got > 650 {
+		t.Errorf("sampleProofChecks(rate 0.5): %d leaves, want about 500", got)
+	}
+}
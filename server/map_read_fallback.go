@@ -0,0 +1,85 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// verifyInclusions verifies the inclusion proofs of resp against its map root.
+func verifyInclusions(treeID int64, hasher hashers.MapHasher, resp *trillian.GetMapLeavesResponse) error {
+	var root types.MapRootV1
+	if err := root.UnmarshalBinary(resp.MapRoot.MapRoot); err != nil {
+		return err
+	}
+	for _, inc := range resp.MapLeafInclusion {
+		if err := merkle.VerifyMapInclusionProof(treeID, inc.Leaf, root.RootHash, inc.Inclusion, hasher); err != nil {
+			return fmt.Errorf("inclusion proof of index %x at revision %d: %v", inc.Leaf.Index, root.Revision, err)
+		}
+	}
+	return nil
+}
+
+// fallBackIfPartial handles the result, resp or err, of reading leaves at the
+// latest revision, whose root is root. A NotFound error means that some of the
+// leaves or Merkle nodes of the revision are missing. Bigtable storage, which
+// doesn't write revisions atomically, reports this when a read at the latest
+// revision doesn't find all of the cells its commit intent lists, e.g. on a
+// replica which hasn't caught up with the revision yet. The leaves are then read again at the previous revision, which was complete
+// before the latest one was written, so that reads keep being served while the
+// map is recovered. Fallbacks are counted by the partial_revision_fallbacks
+// metric.
+//
+// The inclusion proofs of the leaves read are verified against their root. A
+// proof which doesn't verify doesn't show that the revision is partial, so it
+// fails the read with DataLoss rather than being served from another revision.
+func (t *TrillianMapServer) fallBackIfPartial(ctx context.Context, tx storage.ReadOnlyMapTreeTX, hasher hashers.MapHasher, tree *trillian.Tree, indices [][]byte, root *trillian.SignedMapRoot, resp *trillian.GetMapLeavesResponse, err error) (*trillian.GetMapLeavesResponse, error) {
+	if status.Code(err) == codes.NotFound {
+		var mapRoot types.MapRootV1
+		if rerr := mapRoot.UnmarshalBinary(root.MapRoot); rerr != nil {
+			return nil, rerr
+		}
+		rev := int64(mapRoot.Revision)
+		if rev <= 0 {
+			return nil, err
+		}
+		glog.Warningf("%v: latest revision %d is partially written, reading revision %d: %v", tree.TreeId, rev, rev-1, err)
+		t.partialRevisionCounter.Inc(strconv.FormatInt(tree.TreeId, 10))
+
+		prev, rerr := tx.GetSignedMapRoot(ctx, rev-1)
+		if rerr != nil {
+			return nil, fmt.Errorf("could not fetch SignedMapRoot %v: %v", rev-1, rerr)
+		}
+		resp, err = t.readLeavesAtRoot(ctx, tx, hasher, tree, indices, prev)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyInclusions(tree.TreeId, hasher, resp); err != nil {
+		return nil, status.Errorf(codes.DataLoss, "%v", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,318 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/bigtable/bttest"
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/bigtable"
+	"github.com/google/trillian/storage/tree"
+	"github.com/google/trillian/types"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	bt "cloud.google.com/go/bigtable"
+	mtestonly "github.com/google/trillian/monitoring/testonly"
+	stestonly "github.com/google/trillian/storage/testonly"
+)
+
+// writeFallbackTestMap writes leaves to map mapID1 at revision 1, through a
+// mock transaction which stores its Merkle nodes in nodes and its leaves in
+// stored, and returns its root.
+func writeFallbackTestMap(t *testing.T, ctrl *gomock.Controller, nodes *fakeNodes, stored *sync.Map, leaves []*trillian.MapLeaf) *trillian.SignedMapRoot {
+	t.Helper()
+	var root *trillian.SignedMapRoot
+	tx := storage.NewMockMapTreeTX(ctrl)
+	tx.EXPECT().WriteRevision(gomock.Any()).Return(int64(1), nil)
+	tx.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(_ context.Context, _ []byte, leaf *trillian.MapLeaf) error {
+		stored.Store(string(leaf.Index), leaf)
+		return nil
+	})
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(nodes.get)
+	tx.EXPECT().SetMerkleNodes(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(nodes.set)
+	tx.EXPECT().StoreSignedMapRoot(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, r *trillian.SignedMapRoot) error {
+		root = r
+		return nil
+	})
+	tx.EXPECT().Commit(gomock.Any()).AnyTimes().Return(nil)
+	tx.EXPECT().Close().AnyTimes().Return(nil)
+
+	server := NewTrillianMapServer(extension.Registry{
+		AdminStorage:  fakeAdminStorageForMap(ctrl, 1, mapID1),
+		MapStorage:    &stestonly.FakeMapStorage{TX: tx},
+		MetricFactory: monitoring.InertMetricFactory{},
+	}, TrillianMapServerOptions{UseSingleTransaction: true})
+	if _, err := server.SetLeaves(context.Background(), &trillian.SetMapLeavesRequest{MapId: mapID1, Leaves: leaves}); err != nil {
+		t.Fatalf("SetLeaves(): %v", err)
+	}
+	return root
+}
+
+// corruptMapRoot returns a root at revision whose hash doesn't match the
+// stored Merkle nodes, as if they had been corrupted.
+func corruptMapRoot(t *testing.T, revision uint64) *trillian.SignedMapRoot {
+	t.Helper()
+	root, err := (&types.MapRootV1{RootHash: bytes.Repeat([]byte{1}, 32), Revision: revision}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary(): %v", err)
+	}
+	return &trillian.SignedMapRoot{MapRoot: root}
+}
+
+func TestGetLeaves_PartialRevisionFallback(t *testing.T) {
+	for _, test := range []struct {
+		desc     string
+		fallback bool
+		// latestPartial and prevPartial make storage report the leaves and
+		// Merkle nodes of the latest revision, 2, and the previous one, 1, as
+		// NotFound.
+		latestPartial, prevPartial bool
+		// corrupt makes the hash of the latest root, at revision 2, not match
+		// the stored Merkle nodes.
+		corrupt       bool
+		wantCode      codes.Code
+		wantRevision  uint64
+		wantFallbacks float64
+	}{
+		{desc: "disabled", latestPartial: true, wantCode: codes.NotFound},
+		{desc: "disabledCorrupt", corrupt: true, wantRevision: 2},
+		{desc: "complete", fallback: true, wantRevision: 1},
+		{desc: "partial", fallback: true, latestPartial: true, wantRevision: 1, wantFallbacks: 1},
+		{desc: "prevPartial", fallback: true, latestPartial: true, prevPartial: true, wantCode: codes.NotFound, wantFallbacks: 1},
+		{desc: "corrupt", fallback: true, corrupt: true, wantCode: codes.DataLoss},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			var leaves [][]byte
+			var mapLeaves []*trillian.MapLeaf
+			for i := 0; i < 5; i++ {
+				index := make([]byte, 32)
+				index[0], index[31] = byte(i*50), byte(i)
+				leaves = append(leaves, index)
+				mapLeaves = append(mapLeaves, &trillian.MapLeaf{Index: index, LeafValue: []byte(fmt.Sprintf("value-%d", i))})
+			}
+			nodes := &fakeNodes{nodes: make(map[string]tree.Node)}
+			var stored sync.Map
+			root := writeFallbackTestMap(t, ctrl, nodes, &stored, mapLeaves)
+
+			latest := root
+			missing := make(map[int64]bool)
+			if test.latestPartial {
+				latest = corruptMapRoot(t, 2)
+				missing[2] = true
+			}
+			if test.corrupt {
+				latest = corruptMapRoot(t, 2)
+			}
+			if test.prevPartial {
+				missing[1] = true
+			}
+			tx := storage.NewMockMapTreeTX(ctrl)
+			tx.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(latest, nil)
+			tx.EXPECT().GetSignedMapRoot(gomock.Any(), int64(1)).AnyTimes().Return(root, nil)
+			tx.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(_ context.Context, rev int64, indices [][]byte) ([]*trillian.MapLeaf, error) {
+				if missing[rev] {
+					return nil, status.Errorf(codes.NotFound, "leaves at revision %d not found", rev)
+				}
+				var ret []*trillian.MapLeaf
+				for _, index := range indices {
+					if l, ok := stored.Load(string(index)); ok {
+						ret = append(ret, l.(*trillian.MapLeaf))
+					}
+				}
+				return ret, nil
+			})
+			tx.EXPECT().GetMerkleNodes(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, rev int64, ids []tree.NodeID) ([]tree.Node, error) {
+				if missing[rev] {
+					return nil, status.Errorf(codes.NotFound, "subtrees at revision %d not found", rev)
+				}
+				return nodes.get(ctx, rev, ids)
+			})
+			tx.EXPECT().Commit(gomock.Any()).AnyTimes().Return(nil)
+			tx.EXPECT().Close().AnyTimes().Return(nil)
+
+			server := NewTrillianMapServer(extension.Registry{
+				AdminStorage:  fakeAdminStorageForMap(ctrl, 1, mapID1),
+				MapStorage:    &stestonly.FakeMapStorage{ReadOnlyTX: tx},
+				MetricFactory: monitoring.InertMetricFactory{},
+			}, TrillianMapServerOptions{PartialRevisionFallback: test.fallback})
+			fallbacks := mtestonly.NewCounterSnapshot(server.partialRevisionCounter, "1")
+
+			resp, err := server.GetLeaves(context.Background(), &trillian.GetMapLeavesRequest{MapId: mapID1, Index: leaves})
+			if got := status.Code(err); got != test.wantCode {
+				t.Fatalf("GetLeaves(): %v, want code %v", err, test.wantCode)
+			}
+			if got := fallbacks.Delta(); got != test.wantFallbacks {
+				t.Errorf("partial revision fallbacks: %v, want %v", got, test.wantFallbacks)
+			}
+			if err != nil {
+				return
+			}
+			var mapRoot types.MapRootV1
+			if err := mapRoot.UnmarshalBinary(resp.MapRoot.MapRoot); err != nil {
+				t.Fatalf("UnmarshalBinary(): %v", err)
+			}
+			if got := mapRoot.Revision; got != test.wantRevision {
+				t.Errorf("GetLeaves() at revision %d, want %d", got, test.wantRevision)
+			}
+			for i, inc := range resp.MapLeafInclusion {
+				if got, want := inc.Leaf.LeafValue, mapLeaves[i].LeafValue; !bytes.Equal(got, want) {
+					t.Errorf("leaf %d value %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+// newBigtableForFallbackTests starts an in-memory Bigtable server, and returns
+// a table on it created by bigtable.CreateTable. The returned function stops
+// the server.
+func newBigtableForFallbackTests(ctx context.Context, t *testing.T) (*bt.Table, func()) {
+	t.Helper()
+	srv, err := bttest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("bttest.NewServer(): %v", err)
+	}
+	conn, err := grpc.Dial(srv.Addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("grpc.Dial(%v): %v", srv.Addr, err)
+	}
+	admin, err := bt.NewAdminClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewAdminClient(): %v", err)
+	}
+	if err := bigtable.CreateTable(ctx, admin, "trillian"); err != nil {
+		t.Fatalf("CreateTable(): %v", err)
+	}
+	client, err := bt.NewClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewClient(): %v", err)
+	}
+	return client.Open("trillian"), func() {
+		client.Close()
+		admin.Close()
+		conn.Close()
+		srv.Close()
+	}
+}
+
+// dropLatestSubtreeVersion deletes the newest version of a subtree of the map
+// with mapID in tbl, as if the cluster read from hadn't replicated it yet. It
+// relies on the row layout documented by package bigtable.
+func dropLatestSubtreeVersion(ctx context.Context, t *testing.T, tbl *bt.Table, mapID int64) {
+	t.Helper()
+	var key string
+	var latest bt.Timestamp
+	err := tbl.ReadRows(ctx, bt.PrefixRange(fmt.Sprintf("%016x/s/", uint64(mapID))), func(r bt.Row) bool {
+		for _, item := range r["v"] {
+			if item.Timestamp > latest {
+				key, latest = r.Key(), item.Timestamp
+			}
+		}
+		return true
+	}, bt.RowFilter(bt.ChainFilters(bt.FamilyFilter("v"), bt.LatestNFilter(1))))
+	if err != nil || key == "" {
+		t.Fatalf("ReadRows(): %q, %v, want a subtree", key, err)
+	}
+	m := bt.NewMutation()
+	m.DeleteTimestampRange("v", "st", latest, latest+1000)
+	if err := tbl.Apply(ctx, key, m); err != nil {
+		t.Fatalf("Apply(): %v", err)
+	}
+}
+
+func TestGetLeaves_PartialRevisionFallbackBigtable(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		desc     string
+		fallback bool
+		// partial drops a subtree version written at the latest revision.
+		partial       bool
+		wantCode      codes.Code
+		wantRevision  uint64
+		wantValue     string
+		wantFallbacks float64
+	}{
+		{desc: "complete", fallback: true, wantRevision: 2, wantValue: "two"},
+		{desc: "disabled", partial: true, wantCode: codes.NotFound},
+		{desc: "partial", fallback: true, partial: true, wantRevision: 1, wantValue: "one", wantFallbacks: 1},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			tbl, done := newBigtableForFallbackTests(ctx, t)
+			defer done()
+			opts := bigtable.TreeStorageOptions{}
+			reg := extension.Registry{
+				AdminStorage:  bigtable.NewAdminStorage(tbl),
+				MapStorage:    bigtable.NewMapStorage(tbl, opts),
+				MetricFactory: monitoring.InertMetricFactory{},
+			}
+			mapTree, err := storage.CreateTree(ctx, reg.AdminStorage, stestonly.MapTree)
+			if err != nil {
+				t.Fatalf("CreateTree(): %v", err)
+			}
+			writer := NewTrillianMapServer(reg, TrillianMapServerOptions{UseSingleTransaction: true})
+			if _, err := writer.InitMap(ctx, &trillian.InitMapRequest{MapId: mapTree.TreeId}); err != nil {
+				t.Fatalf("InitMap(): %v", err)
+			}
+			index := make([]byte, 32)
+			for _, value := range []string{"one", "two"} {
+				req := &trillian.SetMapLeavesRequest{MapId: mapTree.TreeId, Leaves: []*trillian.MapLeaf{{Index: index, LeafValue: []byte(value)}}}
+				if _, err := writer.SetLeaves(ctx, req); err != nil {
+					t.Fatalf("SetLeaves(): %v", err)
+				}
+			}
+			if test.partial {
+				dropLatestSubtreeVersion(ctx, t, tbl, mapTree.TreeId)
+			}
+
+			server := NewTrillianMapServer(reg, TrillianMapServerOptions{PartialRevisionFallback: test.fallback})
+			fallbacks := mtestonly.NewCounterSnapshot(server.partialRevisionCounter, fmt.Sprint(mapTree.TreeId))
+			resp, err := server.GetLeaves(ctx, &trillian.GetMapLeavesRequest{MapId: mapTree.TreeId, Index: [][]byte{index}})
+			if got := status.Code(err); got != test.wantCode {
+				t.Fatalf("GetLeaves(): %v, want code %v", err, test.wantCode)
+			}
+			if got := fallbacks.Delta(); got != test.wantFallbacks {
+				t.Errorf("partial revision fallbacks: %v, want %v", got, test.wantFallbacks)
+			}
+			if err != nil {
+				return
+			}
+			var mapRoot types.MapRootV1
+			if err := mapRoot.UnmarshalBinary(resp.MapRoot.MapRoot); err != nil {
+				t.Fatalf("UnmarshalBinary(): %v", err)
+			}
+			if got := mapRoot.Revision; got != test.wantRevision {
+				t.Errorf("GetLeaves() at revision %d, want %d", got, test.wantRevision)
+			}
+			if got := string(resp.MapLeafInclusion[0].Leaf.LeafValue); got != test.wantValue {
+				t.Errorf("GetLeaves() value %q, want %q", got, test.wantValue)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+)
+
+// MapRevisionGC garbage collects map revisions which are no longer required by
+// the RevisionRetentionPolicy of their tree.
+//
+// Collecting a revision removes its root, along with the leaf values and
+// subtrees which were superseded at or before the oldest retained revision.
+// Maps without a retention policy are left untouched.
+type MapRevisionGC struct {
+	admin storage.AdminStorage
+	maps  storage.MapStorage
+
+	// minRunInterval defines how frequently sweeps for old revisions are
+	// performed. Actual runs happen randomly between [minInterval,2*minInterval).
+	minRunInterval time.Duration
+	timeSource     clock.TimeSource
+
+	gcCounter monitoring.Counter
+}
+
+// NewMapRevisionGC returns a new MapRevisionGC.
+func NewMapRevisionGC(admin storage.AdminStorage, maps storage.MapStorage, minRunInterval time.Duration, timeSource clock.TimeSource, mf monitoring.MetricFactory) *MapRevisionGC {
+	if mf == nil {
+		mf = monitoring.InertMetricFactory{}
+	}
+	return &MapRevisionGC{
+		admin:          admin,
+		maps:           maps,
+		minRunInterval: minRunInterval,
+		timeSource:     timeSource,
+		gcCounter: mf.NewCounter(
+			"map_revision_gc_runs",
+			"Number of revision garbage collection runs per map",
+			monitoring.TreeIDLabel, "success"),
+	}
+}
+
+// Run starts the revision garbage collection process. It runs until ctx is
+// cancelled.
+func (gc *MapRevisionGC) Run(ctx context.Context) {
+	for {
+		count, err := gc.RunOnce(ctx)
+		if err != nil {
+			glog.Errorf("MapRevisionGC.Run: %v", err)
+		}
+		if count > 0 {
+			glog.V(1).Infof("MapRevisionGC.Run: collected old revisions of %v maps", count)
+		}
+
+		d := gc.minRunInterval + time.Duration(rand.Int63n(gc.minRunInterval.Nanoseconds()))
+		if err := clock.SleepSource(ctx, d, gc.timeSource); err != nil {
+			return
+		}
+	}
+}
+
+// RunOnce performs a single garbage collection sweep over all maps with a
+// retention policy. Returns the number of maps which were successfully
+// processed.
+//
+// It attempts to process as many maps as possible, regardless of failures. If
+// it encounters any failures the resulting error is non-nil.
+func (gc *MapRevisionGC) RunOnce(ctx context.Context) (int, error) {
+	trees, err := storage.ListTrees(ctx, gc.admin, false /* includeDeleted */)
+	if err != nil {
+		return 0, fmt.Errorf("error listing trees: %v", err)
+	}
+
+	count := 0
+	var errs []error
+	for _, tree := range trees {
+		if tree.TreeType != trillian.TreeType_MAP || tree.RevisionRetentionPolicy == nil {
+			continue
+		}
+		if err := gc.collect(ctx, tree); err != nil {
+			errs = append(errs, fmt.Errorf("error collecting revisions of map %v: %v", tree.TreeId, err))
+			gc.gcCounter.Inc(fmt.Sprint(tree.TreeId), "false")
+			continue
+		}
+		count++
+		gc.gcCounter.Inc(fmt.Sprint(tree.TreeId), "true")
+	}
+
+	if len(errs) == 0 {
+		return count, nil
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("encountered errors collecting map revisions:")
+	for _, err := range errs {
+		buf.WriteString("\n\t")
+		buf.WriteString(err.Error())
+	}
+	return count, errors.New(buf.String())
+}
+
+// collect deletes the revisions of tree which its retention policy no longer
+// requires.
+func (gc *MapRevisionGC) collect(ctx context.Context, tree *trillian.Tree) error {
+	policy := tree.RevisionRetentionPolicy
+	var keepDuration time.Duration
+	if policy.KeepDuration != nil {
+		var err error
+		if keepDuration, err = ptypes.Duration(policy.KeepDuration); err != nil {
+			return fmt.Errorf("error parsing keep_duration: %v", err)
+		}
+	}
+
+	return gc.maps.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		root, err := tx.LatestSignedMapRoot(ctx)
+		if err == storage.ErrTreeNeedsInit {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var mapRoot types.MapRootV1
+		if err := mapRoot.UnmarshalBinary(root.MapRoot); err != nil {
+			return err
+		}
+		latest := int64(mapRoot.Revision)
+
+		// A revision must be kept if any part of the policy requires it, so the
+		// cutoff is the lowest of those required by each part.
+		cutoff := latest
+		if n := policy.KeepRevisions; n > 0 && latest-n+1 < cutoff {
+			cutoff = latest - n + 1
+		}
+		if keepDuration > 0 {
+			// A revision is superseded when the following one is published.
+			since, err := tx.EarliestRevisionSince(ctx, gc.timeSource.Now().Add(-keepDuration))
+			if err != nil {
+				return err
+			}
+			if since-1 < cutoff {
+				cutoff = since - 1
+			}
+		}
+		if cutoff <= 0 {
+			return nil
+		}
+
+		glog.V(1).Infof("MapRevisionGC: deleting revisions of map %v before %d (latest=%d)", tree.TreeId, cutoff, latest)
+		return tx.DeleteRevisionsBefore(ctx, cutoff)
+	})
+}
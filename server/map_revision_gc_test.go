@@ -0,0 +1,150 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	stestonly "github.com/google/trillian/storage/testonly"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+)
+
+func TestMapRevisionGC_RunOnce(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	const latest = 100
+
+	for _, test := range []struct {
+		desc string
+		// policy is the retention policy of the map; nil means none.
+		policy *trillian.RevisionRetentionPolicy
+		// since is the revision returned by EarliestRevisionSince, if it is
+		// expected to be called.
+		since int64
+		// wantCutoff is the expected argument of DeleteRevisionsBefore, or 0
+		// if it should not be called.
+		wantCutoff int64
+	}{
+		{desc: "no policy"},
+		{
+			desc:       "keep revisions",
+			policy:     &trillian.RevisionRetentionPolicy{KeepRevisions: 10},
+			wantCutoff: 91,
+		},
+		{
+			desc:   "keep more revisions than exist",
+			policy: &trillian.RevisionRetentionPolicy{KeepRevisions: 1000},
+		},
+		{
+			desc:       "keep duration",
+			policy:     &trillian.RevisionRetentionPolicy{KeepDuration: ptypes.DurationProto(time.Hour)},
+			since:      80,
+			wantCutoff: 79,
+		},
+		{
+			desc:       "keep duration with no recent revisions",
+			policy:     &trillian.RevisionRetentionPolicy{KeepDuration: ptypes.DurationProto(time.Hour)},
+			since:      latest + 1,
+			wantCutoff: latest,
+		},
+		{
+			desc:       "duration keeps more",
+			policy:     &trillian.RevisionRetentionPolicy{KeepRevisions: 10, KeepDuration: ptypes.DurationProto(time.Hour)},
+			since:      50,
+			wantCutoff: 49,
+		},
+		{
+			desc:       "revisions keep more",
+			policy:     &trillian.RevisionRetentionPolicy{KeepRevisions: 10, KeepDuration: ptypes.DurationProto(time.Hour)},
+			since:      95,
+			wantCutoff: 91,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tree := proto.Clone(stestonly.MapTree).(*trillian.Tree)
+			tree.RevisionRetentionPolicy = test.policy
+			logTree := proto.Clone(stestonly.LogTree).(*trillian.Tree)
+
+			listTX := storage.NewMockReadOnlyAdminTX(ctrl)
+			listTX.EXPECT().ListTrees(gomock.Any(), false).Return([]*trillian.Tree{logTree, tree}, nil)
+			listTX.EXPECT().Close().Return(nil)
+			listTX.EXPECT().Commit().Return(nil)
+			as := &stestonly.FakeAdminStorage{ReadOnlyTX: []storage.ReadOnlyAdminTX{listTX}}
+
+			mapTX := storage.NewMockMapTreeTX(ctrl)
+			ms := &stestonly.FakeMapStorage{TX: mapTX}
+			wantCount := 0
+			if test.policy != nil {
+				wantCount = 1
+				root, err := (&types.MapRootV1{Revision: latest}).MarshalBinary()
+				if err != nil {
+					t.Fatalf("MarshalBinary(): %v", err)
+				}
+				mapTX.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(&trillian.SignedMapRoot{MapRoot: root}, nil)
+				if test.since != 0 {
+					mapTX.EXPECT().EarliestRevisionSince(gomock.Any(), now.Add(-time.Hour)).Return(test.since, nil)
+				}
+				if test.wantCutoff != 0 {
+					mapTX.EXPECT().DeleteRevisionsBefore(gomock.Any(), test.wantCutoff).Return(nil)
+				}
+				mapTX.EXPECT().Commit(gomock.Any()).Return(nil)
+				mapTX.EXPECT().Close().Return(nil)
+			}
+
+			gc := NewMapRevisionGC(as, ms, time.Minute, clock.NewFake(now), nil)
+			count, err := gc.RunOnce(context.Background())
+			if err != nil {
+				t.Fatalf("RunOnce(): %v", err)
+			}
+			if count != wantCount {
+				t.Errorf("RunOnce()=%v, want %v", count, wantCount)
+			}
+		})
+	}
+}
+
+func TestMapRevisionGC_RunOnceUninitialized(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tree := proto.Clone(stestonly.MapTree).(*trillian.Tree)
+	tree.RevisionRetentionPolicy = &trillian.RevisionRetentionPolicy{KeepRevisions: 1}
+
+	listTX := storage.NewMockReadOnlyAdminTX(ctrl)
+	listTX.EXPECT().ListTrees(gomock.Any(), false).Return([]*trillian.Tree{tree}, nil)
+	listTX.EXPECT().Close().Return(nil)
+	listTX.EXPECT().Commit().Return(nil)
+	as := &stestonly.FakeAdminStorage{ReadOnlyTX: []storage.ReadOnlyAdminTX{listTX}}
+
+	mapTX := storage.NewMockMapTreeTX(ctrl)
+	mapTX.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(nil, storage.ErrTreeNeedsInit)
+	mapTX.EXPECT().Commit(gomock.Any()).Return(nil)
+	mapTX.EXPECT().Close().Return(nil)
+
+	gc := NewMapRevisionGC(as, &stestonly.FakeMapStorage{TX: mapTX}, time.Minute, clock.System, nil)
+	if count, err := gc.RunOnce(context.Background()); err != nil || count != 1 {
+		t.Errorf("RunOnce()=%v, %v, want 1, nil", count, err)
+	}
+}
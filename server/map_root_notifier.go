@@ -0,0 +1,64 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import "sync"
+
+// rootNotifier wakes up subscribers when a new root is published for a map by
+// this server. Roots published by other servers sharing the same storage are
+// not notified, so subscribers must also poll storage.
+type rootNotifier struct {
+	mu   sync.Mutex
+	subs map[int64]map[chan struct{}]bool
+}
+
+func newRootNotifier() *rootNotifier {
+	return &rootNotifier{subs: make(map[int64]map[chan struct{}]bool)}
+}
+
+// subscribe returns a channel which receives a value after each call to
+// notify for mapID, and a function which must be called to unsubscribe.
+// Notifications are coalesced: a slow subscriber receives at most one pending
+// value, however many roots were published in the meantime.
+func (n *rootNotifier) subscribe(mapID int64) (<-chan struct{}, func()) {
+	c := make(chan struct{}, 1)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.subs[mapID] == nil {
+		n.subs[mapID] = make(map[chan struct{}]bool)
+	}
+	n.subs[mapID][c] = true
+
+	return c, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.subs[mapID], c)
+		if len(n.subs[mapID]) == 0 {
+			delete(n.subs, mapID)
+		}
+	}
+}
+
+// notify wakes up all the current subscribers for mapID. It never blocks.
+func (n *rootNotifier) notify(mapID int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for c := range n.subs[mapID] {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
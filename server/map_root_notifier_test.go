@@ -0,0 +1,62 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import "testing"
+
+func pending(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestRootNotifier(t *testing.T) {
+	n := newRootNotifier()
+	c1, cancel1 := n.subscribe(1)
+	c2, cancel2 := n.subscribe(1)
+	other, cancelOther := n.subscribe(2)
+	defer cancelOther()
+
+	// Notifications are coalesced, and only delivered to subscribers of the
+	// notified map.
+	n.notify(1)
+	n.notify(1)
+	if !pending(c1) || !pending(c2) {
+		t.Fatal("notify(1) did not wake up all subscribers of map 1")
+	}
+	if pending(c1) || pending(c2) {
+		t.Fatal("notify(1) was not coalesced")
+	}
+	if pending(other) {
+		t.Fatal("notify(1) woke up a subscriber of map 2")
+	}
+
+	cancel1()
+	n.notify(1)
+	if pending(c1) {
+		t.Error("notify(1) woke up a cancelled subscriber")
+	}
+	if !pending(c2) {
+		t.Error("notify(1) did not wake up a remaining subscriber")
+	}
+
+	cancel2()
+	if got := len(n.subs); got != 1 {
+		t.Errorf("len(subs)=%d after cancelling all subscribers of map 1, want 1", got)
+	}
+}
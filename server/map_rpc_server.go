@@ -15,32 +15,83 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keys/der"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/merkle/hashers"
 	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/server/errmsg"
 	"github.com/google/trillian/storage"
-	"github.com/google/trillian/storage/tree"
+	"github.com/google/trillian/storage/compression"
+	"github.com/google/trillian/storage/querytag"
 	"github.com/google/trillian/trees"
 	"github.com/google/trillian/types"
 
 	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+
+	tcrypto "github.com/google/trillian/crypto"
 )
 
 const (
 	// Used internally by GetLeaves.
 	mostRecentRevision = -1
+
+	// defaultListPageSize is the number of leaves in each ListLeavesByRevision
+	// response, or of roots or leaves in each ExportMap response, if the
+	// request doesn't specify a page size.
+	defaultListPageSize = 256
+	// maxListPageSize is the maximum number of leaves in each
+	// ListLeavesByRevision response, or of roots or leaves in each ExportMap
+	// response.
+	maxListPageSize = 4096
+
+	// defaultLeafHistoryCount is the number of revisions returned by
+	// GetLeafHistory if the request doesn't specify a count.
+	defaultLeafHistoryCount = 100
+	// maxLeafHistoryCount is the maximum number of revisions returned by
+	// GetLeafHistory.
+	maxLeafHistoryCount = 1000
+
+	// defaultRootListPageSize is the number of roots in each ListSignedMapRoots
+	// response if the request doesn't specify a page size.
+	defaultRootListPageSize = 100
+	// maxRootListPageSize is the maximum number of roots in each
+	// ListSignedMapRoots response.
+	maxRootListPageSize = 1000
+
+	// DefaultWatchPollInterval is the default interval at which
+	// WatchSignedMapRoots streams check storage for new map roots.
+	DefaultWatchPollInterval = 5 * time.Second
+	// maxWatchCatchUp is the maximum number of intermediate roots a
+	// WatchSignedMapRoots stream sends when it falls behind. Older roots
+	// are skipped, and can be fetched with GetSignedMapRootByRevision.
+	maxWatchCatchUp = 1000
+
+	// maxIdempotencyTokenSize is the maximum size of the idempotency token of
+	// a write, which storage implementations must be able to index.
+	maxIdempotencyTokenSize = 255
 )
 
 var (
+	// errDryRun fails the transactions of dry runs, so that they are not
+	// committed.
+	errDryRun = errors.New("dry run")
+
 	optsMapInit  = trees.NewGetOpts(trees.Admin, trillian.TreeType_MAP)
 	optsMapRead  = trees.NewGetOpts(trees.Query, trillian.TreeType_MAP)
 	optsMapWrite = trees.NewGetOpts(trees.UpdateMap, trillian.TreeType_MAP)
@@ -56,9 +107,71 @@ type TrillianMapServerOptions struct {
 	// attempted within a single transaction.
 	UseSingleTransaction bool
 
-	// UseLargePreload enables the performance workaround applied when
-	// UseSingleTransaction is set.
-	UseLargePreload bool
+	// Preload selects the Merkle nodes loaded ahead of each update when
+	// UseSingleTransaction is set, to work around locking performance issues.
+	// If nil, no nodes are preloaded.
+	Preload PreloadStrategy
+
+	// NodeCacheSize is the number of Merkle nodes of published revisions kept
+	// in an in-process LRU cache for inclusion proofs, so that the nodes near
+	// the root are not read from storage by every request. If zero, nodes are
+	// not cached.
+	NodeCacheSize int
+
+	// Limits bounds the work done by each request.
+	Limits MapLimits
+
+	// ReadOnly rejects the RPCs which write to the map storage with
+	// FailedPrecondition, e.g. for servers which read from database replicas.
+	ReadOnly bool
+
+	// QueueWrites makes the WriteLeaves RPC of the map write server queue the
+	// leaves to be merged into a later revision by a MapMerger, rather than
+	// writing a new revision. The map storage must implement
+	// storage.MapWriteQueuer.
+	QueueWrites bool
+
+	// WatchPollInterval is the interval at which WatchSignedMapRoots streams
+	// check storage for roots published by other servers. Roots published by
+	// this server are sent immediately. If zero, DefaultWatchPollInterval is
+	// used.
+	WatchPollInterval time.Duration
+
+	// HedgeDelay, if set, hedges the leaf and inclusion proof reads of
+	// requests for leaves: a read which has not returned after HedgeDelay is
+	// issued again on a new snapshot, and the first result is used. This cuts
+	// the tail latency of storage backends like Cloud Spanner, at the cost of
+	// extra reads.
+	HedgeDelay time.Duration
+
+	// ShadowMaps maps the IDs of maps to the IDs of their shadow maps. The
+	// leaves written to a map by SetLeaves or WriteLeaves are also written to
+	// its shadow map in the background, at the same revision, and the root
+	// hashes of both are compared, so that a candidate hasher or storage
+	// layout can be validated on production traffic without affecting the
+	// authoritative map. Results are counted by the shadow_writes metric.
+	ShadowMaps map[int64]int64
+
+	// HotKeys configures the counting of the leaves written to each map by
+	// index prefix, which the GetHotKeys RPC of the map write server reports.
+	HotKeys MapHotKeyOptions
+
+	// ProofCheckRate is the fraction, from 0 to 1, of the leaves written to
+	// each map revision whose inclusion proofs are read back and verified
+	// against the new root before it is signed. A proof which does not
+	// verify fails the write with Internal, so that bugs in the sparse Merkle
+	// tree writer or storage are caught by the write which triggers them.
+	// Checks are counted by the inclusion_proof_checks metric. If zero, no
+	// proofs are checked.
+	ProofCheckRate float64
+
+	// PartialRevisionFallback reads leaves at the previous revision when
+	// storage reports with NotFound that leaves or Merkle nodes of the latest
+	// revision are missing, because it is only partially written. The
+	// inclusion proofs of the leaves read are verified, and a proof which
+	// doesn't verify fails the read with DataLoss. Fallbacks are counted by
+	// the partial_revision_fallbacks metric.
+	PartialRevisionFallback bool
 }
 
 // TrillianMapServer implements the RPC API defined in the proto
@@ -66,9 +179,27 @@ type TrillianMapServer struct {
 	trillian.UnimplementedTrillianMapServer
 	registry extension.Registry
 	opts     TrillianMapServerOptions
+	notifier *rootNotifier
+	// nodeCache is nil if nodes are not cached.
+	nodeCache *nodeCache
 
 	setLeafCounter monitoring.Counter
 	getLeafCounter monitoring.Counter
+	rejectCounter  monitoring.Counter
+
+	preloadNodeCounter monitoring.Counter
+	preloadHitCounter  monitoring.Counter
+
+	hedgeCounter    monitoring.Counter
+	hedgeWinCounter monitoring.Counter
+
+	stageLatency monitoring.Histogram
+
+	shadows *mapShadows
+	hotKeys *hotKeys
+
+	proofCheckCounter      monitoring.Counter
+	partialRevisionCounter monitoring.Counter
 }
 
 // NewTrillianMapServer creates a new RPC server backed by registry
@@ -80,10 +211,22 @@ func NewTrillianMapServer(registry extension.Registry, opts TrillianMapServerOpt
 	if mf == nil {
 		mf = monitoring.InertMetricFactory{}
 	}
+	if opts.WatchPollInterval <= 0 {
+		opts.WatchPollInterval = DefaultWatchPollInterval
+	}
+
+	var nc *nodeCache
+	if opts.NodeCacheSize > 0 {
+		nc = newNodeCache(opts.NodeCacheSize, mf)
+	}
 
 	return &TrillianMapServer{
-		registry: registry,
-		opts:     opts,
+		registry:  registry,
+		opts:      opts,
+		notifier:  newRootNotifier(),
+		nodeCache: nc,
+		shadows:   newMapShadows(opts.ShadowMaps, mf),
+		hotKeys:   newHotKeys(opts.HotKeys, mf),
 		setLeafCounter: mf.NewCounter(
 			"set_leaves",
 			"Number of map leaves requested to be set",
@@ -94,29 +237,86 @@ func NewTrillianMapServer(registry extension.Registry, opts TrillianMapServerOpt
 			"Number of map leaves request to be read",
 			"map_id",
 		),
+		rejectCounter: mf.NewCounter(
+			"rejected_requests",
+			"Number of map requests rejected for exceeding a server limit",
+			"map_id", "reason",
+		),
+		preloadNodeCounter: mf.NewCounter(
+			"preload_nodes",
+			"Number of Merkle nodes requested by preloads",
+			"map_id", "strategy",
+		),
+		preloadHitCounter: mf.NewCounter(
+			"preload_node_hits",
+			"Number of Merkle nodes requested by preloads which were found in storage",
+			"map_id", "strategy",
+		),
+		hedgeCounter: mf.NewCounter(
+			"hedged_reads",
+			"Number of leaf and proof reads issued again on a new snapshot after the hedge delay",
+			"map_id", "kind",
+		),
+		hedgeWinCounter: mf.NewCounter(
+			"hedged_read_wins",
+			"Number of hedged reads which returned before the original read",
+			"map_id", "kind",
+		),
+		stageLatency: mf.NewHistogram(
+			"write_stage_latency",
+			"Latency of each stage of map writes in seconds",
+			"map_id", "stage",
+		),
+		proofCheckCounter: mf.NewCounter(
+			"inclusion_proof_checks",
+			"Number of inclusion proofs of written map leaves checked against the new root, by result",
+			"map_id", "result",
+		),
+		partialRevisionCounter: mf.NewCounter(
+			"partial_revision_fallbacks",
+			"Number of reads of the latest map revision served from the previous revision, because the latest one is partially written",
+			"map_id",
+		),
 	}
 }
 
-// IsHealthy returns nil if the server is healthy, error otherwise.
-func (t *TrillianMapServer) IsHealthy() error {
-	ctx, spanEnd := spanFor(context.Background(), "IsHealthy")
-	defer spanEnd()
-	return t.registry.MapStorage.CheckDatabaseAccessible(ctx)
+// checkWritable returns an error if the server is read-only, and so does not
+// serve method.
+func (t *TrillianMapServer) checkWritable(method string) error {
+	if t.opts.ReadOnly {
+		return errmsg.New(codes.FailedPrecondition, errmsg.ServerReadOnly, errmsg.Params{"method": method})
+	}
+	return nil
 }
 
 // GetLeaves implements the GetLeaves RPC method.  Each requested index will
 // return an inclusion proof to the leaf, or nil if the leaf does not exist.
 func (t *TrillianMapServer) GetLeaves(ctx context.Context, req *trillian.GetMapLeavesRequest) (*trillian.GetMapLeavesResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetLeaves")
+	ctx, spanEnd := spanFor(ctx, "GetLeaves", treeAttr(req.MapId), batchAttr(len(req.Index)))
 	defer spanEnd()
-	return t.getLeavesByRevision(ctx, req.MapId, req.Index, mostRecentRevision)
+	if req.MaxResponseBytes < 0 {
+		return nil, errNegative("GetMapLeavesRequest.MaxResponseBytes", int64(req.MaxResponseBytes))
+	}
+	return t.getLeavesByRevision(ctx, req.MapId, req.Index, mostRecentRevision, leavesPage{
+		maxBytes: int(req.MaxResponseBytes),
+		token:    req.PageToken,
+	})
+}
+
+// leavesPage selects the part of the requested indices which is returned by
+// getLeavesByRevision.
+type leavesPage struct {
+	// maxBytes bounds the size of the response, if positive.
+	maxBytes int
+	// token is the page token of the request, if any.
+	token string
 }
 
 // GetLeaf returns an inclusion proof to the leaf, or nil if the leaf does not exist.
 func (t *TrillianMapServer) GetLeaf(ctx context.Context, req *trillian.GetMapLeafRequest) (*trillian.GetMapLeafResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetLeaf")
+	ctx, spanEnd := spanFor(ctx, "GetLeaf", treeAttr(req.MapId), batchAttr(1))
 	defer spanEnd()
-	ret, err := t.getLeavesByRevision(ctx, req.MapId, [][]byte{req.Index}, mostRecentRevision)
+	ret, err := t.getLeavesByRevision(ctx, req.MapId, [][]byte{req.Index}, mostRecentRevision, leavesPage{})
 	if err != nil {
 		return nil, err
 	}
@@ -131,9 +331,9 @@ func (t *TrillianMapServer) GetLeaf(ctx context.Context, req *trillian.GetMapLea
 
 // GetLeafByRevision returns an inclusion proof to the leaf, or nil if the leaf does not exist.
 func (t *TrillianMapServer) GetLeafByRevision(ctx context.Context, req *trillian.GetMapLeafByRevisionRequest) (*trillian.GetMapLeafResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetLeafByRevision")
+	ctx, spanEnd := spanFor(ctx, "GetLeafByRevision", treeAttr(req.MapId), revisionAttr(req.Revision), batchAttr(1))
 	defer spanEnd()
-	ret, err := t.getLeavesByRevision(ctx, req.MapId, [][]byte{req.Index}, req.Revision)
+	ret, err := t.getLeavesByRevision(ctx, req.MapId, [][]byte{req.Index}, req.Revision, leavesPage{})
 	if err != nil {
 		return nil, err
 	}
@@ -148,24 +348,29 @@ func (t *TrillianMapServer) GetLeafByRevision(ctx context.Context, req *trillian
 
 // GetLeavesByRevision implements the GetLeavesByRevision RPC method.
 func (t *TrillianMapServer) GetLeavesByRevision(ctx context.Context, req *trillian.GetMapLeavesByRevisionRequest) (*trillian.GetMapLeavesResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetLeavesByRevision")
+	ctx, spanEnd := spanFor(ctx, "GetLeavesByRevision", treeAttr(req.MapId), revisionAttr(req.Revision), batchAttr(len(req.Index)))
 	defer spanEnd()
 	if req.Revision < 0 {
 		return nil, fmt.Errorf("map revision %d must be >= 0", req.Revision)
 	}
-	return t.getLeavesByRevision(ctx, req.MapId, req.Index, req.Revision)
+	return t.getLeavesByRevision(ctx, req.MapId, req.Index, req.Revision, leavesPage{})
 }
 
 // GetLeavesByRevisionNoProof implements the GetLeavesByRevision RPC method.
-func (t *TrillianMapServer) GetLeavesByRevisionNoProof(ctx context.Context, req *trillian.GetMapLeavesByRevisionRequest) (*trillian.MapLeaves, error) {
+func (t *TrillianMapServer) GetLeavesByRevisionNoProof(ctx context.Context, req *trillian.GetMapLeavesByRevisionRequest) (_ *trillian.MapLeaves, err error) {
 	if req.Revision < 0 {
 		return nil, fmt.Errorf("map revision %d must be >= 0", req.Revision)
 	}
+	if err := t.checkIndexCount(req.MapId, len(req.Index)); err != nil {
+		return nil, err
+	}
+	ctx, finish := t.withRequestTimeout(ctx, req.MapId)
+	defer func() { err = finish(err) }()
 	tree, hasher, err := t.getTreeAndHasher(ctx, req.MapId, optsMapRead)
 	if err != nil {
 		return nil, fmt.Errorf("could not get map %v: %v", req.MapId, err)
 	}
-	if err := validateIndices(hasher.Size(), len(req.Index), func(i int) []byte { return req.Index[i] }); err != nil {
+	if err := validateIndices(hashers.IndexSize(hasher), len(req.Index), func(i int) []byte { return req.Index[i] }, repeatedField("index", "")); err != nil {
 		return nil, err
 	}
 
@@ -184,19 +389,52 @@ func (t *TrillianMapServer) GetLeavesByRevisionNoProof(ctx context.Context, req
 	for _, l := range leaves {
 		l.LeafHash = nil
 	}
+	resp := &trillian.MapLeaves{Leaves: leaves}
 
-	return &trillian.MapLeaves{Leaves: leaves}, nil
+	if req.IncludeMapRoot {
+		// Read from the same snapshot, so that the root covers the leaves.
+		root, err := tx.GetSignedMapRoot(ctx, req.Revision)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch SignedMapRoot %v: %v", req.Revision, err)
+		}
+		resp.MapRoot = root
+	}
+	return resp, nil
 }
 
-func (t *TrillianMapServer) getLeavesByRevision(ctx context.Context, mapID int64, indices [][]byte, revision int64) (*trillian.GetMapLeavesResponse, error) {
+// getLeavesByRevision reads the leaves at indices, and their inclusion proofs,
+// at revision, or at the latest revision if revision is negative. If page
+// limits the size of the response, only the leaves of the page are returned.
+func (t *TrillianMapServer) getLeavesByRevision(ctx context.Context, mapID int64, indices [][]byte, revision int64, page leavesPage) (_ *trillian.GetMapLeavesResponse, err error) {
+	if err := t.checkIndexCount(mapID, len(indices)); err != nil {
+		return nil, err
+	}
+	ctx, finish := t.withRequestTimeout(ctx, mapID)
+	defer func() { err = finish(err) }()
+
 	tree, hasher, err := t.getTreeAndHasher(ctx, mapID, optsMapRead)
 	if err != nil {
 		return nil, fmt.Errorf("could not get map %v: %v", mapID, err)
 	}
 
-	if err := validateIndices(hasher.Size(), len(indices), func(i int) []byte { return indices[i] }); err != nil {
+	if err := validateIndices(hashers.IndexSize(hasher), len(indices), func(i int) []byte { return indices[i] }, repeatedField("index", "")); err != nil {
 		return nil, err
 	}
+	// Pages after the first continue at the revision of the first one.
+	offset := 0
+	if page.token != "" {
+		if revision, offset, err = parseLeavesPageToken(page.token, len(indices)); err != nil {
+			return nil, err
+		}
+	}
+	all := indices
+	indices = indices[offset:]
+	if page.maxBytes > 0 {
+		// Each inclusion holds its index, so no more than this can fit.
+		if n := page.maxBytes/hashers.IndexSize(hasher) + 1; n < len(indices) {
+			indices = indices[:n]
+		}
+	}
 
 	ctx = trees.NewContext(ctx, tree)
 	t.getLeafCounter.Add(float64(len(indices)), string(mapID))
@@ -223,13 +461,105 @@ func (t *TrillianMapServer) getLeavesByRevision(ctx context.Context, mapID int64
 		root = r
 	}
 
+	resp, err := t.readLeavesAtRoot(ctx, tx, hasher, tree, indices, root)
+	if revision < 0 && t.opts.PartialRevisionFallback {
+		resp, err = t.fallBackIfPartial(ctx, tx, hasher, tree, indices, root, resp, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	root = resp.MapRoot
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("could not commit db transaction: %v", err)
+	}
+	if page.maxBytes > 0 {
+		var mapRoot types.MapRootV1
+		if err := mapRoot.UnmarshalBinary(root.MapRoot); err != nil {
+			return nil, err
+		}
+		// Leave room for the longest token this request can return.
+		budget := page.maxBytes - fieldSize(len(leavesPageToken(int64(mapRoot.Revision), len(all))))
+		resp.MapLeafInclusion = fitInclusions(resp, budget)
+		if next := offset + len(resp.MapLeafInclusion); next < len(all) {
+			resp.NextPageToken = leavesPageToken(int64(mapRoot.Revision), next)
+		}
+	}
+	return resp, nil
+}
+
+// fieldSize returns the encoded size of a field of the API protos holding n
+// bytes, with its one byte tag and its length.
+func fieldSize(n int) int {
+	return 1 + proto.SizeVarint(uint64(n)) + n
+}
+
+// fitInclusions returns the longest prefix of the inclusions of resp, with at
+// least one inclusion, which keeps the encoded response within maxBytes.
+func fitInclusions(resp *trillian.GetMapLeavesResponse, maxBytes int) []*trillian.MapLeafInclusion {
+	size := 0
+	if resp.MapRoot != nil {
+		size += fieldSize(proto.Size(resp.MapRoot))
+	}
+	for i, inc := range resp.MapLeafInclusion {
+		size += fieldSize(proto.Size(inc))
+		if size > maxBytes && i > 0 {
+			return resp.MapLeafInclusion[:i]
+		}
+	}
+	return resp.MapLeafInclusion
+}
+
+// leavesPageToken returns the page token which resumes a GetLeaves request at
+// the index at offset, at revision.
+func leavesPageToken(revision int64, offset int) string {
+	return fmt.Sprintf("%d:%d", revision, offset)
+}
+
+// parseLeavesPageToken returns the revision and offset encoded in token.
+// count is the number of indices in the request.
+func parseLeavesPageToken(token string, count int) (int64, int, error) {
+	parts := strings.Split(token, ":")
+	if len(parts) != 2 {
+		return 0, 0, errmsg.New(codes.InvalidArgument, errmsg.InvalidPageToken, errmsg.Params{"detail": "want revision:offset"})
+	}
+	revision, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, errmsg.New(codes.InvalidArgument, errmsg.InvalidPageToken, errmsg.Params{"detail": err})
+	}
+	offset, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, errmsg.New(codes.InvalidArgument, errmsg.InvalidPageToken, errmsg.Params{"detail": err})
+	}
+	if revision < 0 || offset <= 0 || offset >= count {
+		return 0, 0, errmsg.New(codes.InvalidArgument, errmsg.InvalidPageToken, errmsg.Params{
+			"detail": fmt.Sprintf("revision %d or offset %d out of range for %d indices", revision, offset, count),
+		})
+	}
+	return revision, offset, nil
+}
+
+// proofReader returns the transaction from which the inclusion proofs of the
+// map are read, which serves nodes from the node cache if it is enabled.
+func (t *TrillianMapServer) proofReader(mapID int64, tx storage.ReadOnlyMapTreeTX) storage.ReadOnlyMapTreeTX {
+	if t.nodeCache == nil {
+		return tx
+	}
+	return &cachingMapTX{ReadOnlyMapTreeTX: tx, treeID: mapID, cache: t.nodeCache}
+}
+
+// readLeavesAtRoot reads the leaves at indices, and their inclusion proofs, at
+// the revision of root. Either read may be hedged, see hedgedRead.
+func (t *TrillianMapServer) readLeavesAtRoot(ctx context.Context, tx storage.ReadOnlyMapTreeTX, hasher hashers.MapHasher, tree *trillian.Tree, indices [][]byte, root *trillian.SignedMapRoot) (*trillian.GetMapLeavesResponse, error) {
 	var mapRoot types.MapRootV1
 	if err := mapRoot.UnmarshalBinary(root.MapRoot); err != nil {
 		return nil, err
 	}
-	revision = int64(mapRoot.Revision)
+	revision := int64(mapRoot.Revision)
+	ctx = querytag.WithRevision(ctx, revision)
+	mapID := tree.TreeId
 
-	// Fetch leaves and their inclusion proofs concurrently:
+	// Fetch leaves and their inclusion proofs concurrently, on the same
+	// transaction, which is only closed once both reads have returned:
 	wg := &sync.WaitGroup{}
 
 	////////////////////////////////////////////////////
@@ -241,11 +571,14 @@ func (t *TrillianMapServer) getLeavesByRevision(ctx context.Context, mapID int64
 	go func() {
 		defer wg.Done()
 
-		leaves, err := tx.Get(ctx, revision, indices)
+		val, err := t.hedgedRead(ctx, tree, tx, hedgeLeaves, func(ctx context.Context, tx storage.ReadOnlyMapTreeTX) (interface{}, error) {
+			return tx.Get(ctx, revision, indices)
+		})
 		if err != nil {
-			errCh <- fmt.Errorf("could not fetch leaves: %v", err)
+			errCh <- status.Errorf(status.Code(err), "could not fetch leaves: %v", err)
 			return
 		}
+		leaves := val.([]*trillian.MapLeaf)
 		for _, l := range leaves {
 			leavesByIndex[string(l.Index)] = l
 		}
@@ -267,13 +600,16 @@ func (t *TrillianMapServer) getLeavesByRevision(ctx context.Context, mapID int64
 	go func() {
 		defer wg.Done()
 
-		var err error
 		// Fetch inclusion proofs in parallel.
-		smtReader := merkle.NewSparseMerkleTreeReader(revision, hasher, tx)
-		proofs, err = smtReader.BatchInclusionProof(ctx, revision, indices)
+		val, err := t.hedgedRead(ctx, tree, tx, hedgeProofs, func(ctx context.Context, tx storage.ReadOnlyMapTreeTX) (interface{}, error) {
+			smtReader := merkle.NewSparseMerkleTreeReader(revision, hasher, t.proofReader(mapID, tx))
+			return smtReader.BatchInclusionProof(ctx, revision, indices)
+		})
 		if err != nil {
-			errCh <- fmt.Errorf("could not fetch inclusion proofs: %v", err)
+			errCh <- status.Errorf(status.Code(err), "could not fetch inclusion proofs: %v", err)
+			return
 		}
+		proofs = val.(map[string][][]byte)
 	}()
 	////////////////////////////////////////////////////
 
@@ -287,10 +623,6 @@ func (t *TrillianMapServer) getLeavesByRevision(ctx context.Context, mapID int64
 		}
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return nil, fmt.Errorf("could not commit db transaction: %v", err)
-	}
-
 	inclusions := make([]*trillian.MapLeafInclusion, len(indices))
 	for i, index := range indices {
 		inclusions[i] = &trillian.MapLeafInclusion{
@@ -305,267 +637,921 @@ func (t *TrillianMapServer) getLeavesByRevision(ctx context.Context, mapID int64
 	}, nil
 }
 
-// SetLeaves implements the SetLeaves RPC method.
-func (t *TrillianMapServer) SetLeaves(ctx context.Context, req *trillian.SetMapLeavesRequest) (*trillian.SetMapLeavesResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "SetLeaves")
+// GetLeafHistory implements the GetLeafHistory RPC method. It returns the
+// leaf at the requested index, and its inclusion proof, at each revision of
+// the requested range, read from a single snapshot.
+func (t *TrillianMapServer) GetLeafHistory(ctx context.Context, req *trillian.GetMapLeafHistoryRequest) (_ *trillian.GetMapLeafHistoryResponse, err error) {
+	ctx, spanEnd := spanFor(ctx, "GetLeafHistory", treeAttr(req.MapId), revisionAttr(req.StartRevision), batchAttr(int(req.Count)))
 	defer spanEnd()
+	ctx, finish := t.withRequestTimeout(ctx, req.MapId)
+	defer func() { err = finish(err) }()
+	if req.StartRevision < 0 {
+		return nil, errNegative("GetMapLeafHistoryRequest.StartRevision", req.StartRevision)
+	}
+	if req.Count < 0 {
+		return nil, errNegative("GetMapLeafHistoryRequest.Count", int64(req.Count))
+	}
+	count := int64(req.Count)
+	if count == 0 {
+		count = defaultLeafHistoryCount
+	} else if count > maxLeafHistoryCount {
+		count = maxLeafHistoryCount
+	}
 
-	mapID := req.MapId
-	t.setLeafCounter.Add(float64(len(req.Leaves)), string(mapID))
-
-	tree, hasher, err := t.getTreeAndHasher(ctx, mapID, optsMapWrite)
+	tree, hasher, err := t.getTreeAndHasher(ctx, req.MapId, optsMapRead)
 	if err != nil {
+		return nil, fmt.Errorf("could not get map %v: %v", req.MapId, err)
+	}
+	indices := [][]byte{req.Index}
+	if err := validateIndices(hashers.IndexSize(hasher), len(indices), func(i int) []byte { return indices[i] }, func(int) string { return "index" }); err != nil {
 		return nil, err
 	}
 	ctx = trees.NewContext(ctx, tree)
 
-	if err := validateIndices(hasher.Size(), len(req.Leaves), func(i int) []byte { return req.Leaves[i].Index }); err != nil {
-		return nil, err
+	tx, err := t.snapshotForTree(ctx, tree, "GetLeafHistory")
+	if err != nil {
+		return nil, fmt.Errorf("could not create database snapshot: %v", err)
 	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetLeafHistory")
 
-	// Overwrite/set the leaf hashes in the request and create a summary of
-	// the leaf indices and new hash values.
-	hkv := make([]merkle.HashKeyValue, 0, len(req.Leaves))
-	for _, l := range req.Leaves {
-		l.LeafHash = hasher.HashLeaf(tree.TreeId, l.Index, l.LeafValue)
-		hkv = append(hkv, merkle.HashKeyValue{
-			HashedKey:   l.Index,
-			HashedValue: l.LeafHash,
-		})
+	latest, err := tx.LatestSignedMapRoot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch the latest SignedMapRoot: %v", err)
+	}
+	var latestRoot types.MapRootV1
+	if err := latestRoot.UnmarshalBinary(latest.MapRoot); err != nil {
+		return nil, err
+	}
+	end := req.StartRevision + count
+	if latestRev := int64(latestRoot.Revision); end > latestRev+1 {
+		end = latestRev + 1
 	}
 
-	var newRoot *trillian.SignedMapRoot
-	err = t.registry.MapStorage.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
-		writeRev, err := t.getWriteRevision(ctx, tree, tx, req.Revision)
+	resp := &trillian.GetMapLeafHistoryResponse{}
+	for rev := req.StartRevision; rev < end; rev++ {
+		root, err := tx.GetSignedMapRoot(ctx, rev)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("could not fetch SignedMapRoot %v: %v", rev, err)
 		}
-		glog.V(2).Infof("%v: Writing at revision %v", tree.TreeId, writeRev)
-
-		if err := t.writeLeaves(ctx, tx, req.Leaves); err != nil {
-			return err
+		leaves, err := t.readLeavesAtRoot(ctx, tx, hasher, tree, indices, root)
+		if err != nil {
+			return nil, err
 		}
+		resp.Leaves = append(resp.Leaves, &trillian.GetMapLeafResponse{
+			MapRoot:          leaves.MapRoot,
+			MapLeafInclusion: leaves.MapLeafInclusion[0],
+		})
+	}
+	t.getLeafCounter.Add(float64(len(resp.Leaves)), strconv.FormatInt(req.MapId, 10))
 
-		newRoot, err = t.updateTree(ctx, tree, hasher, tx, hkv, req.Metadata, writeRev)
-		return err
-	})
-	if err != nil {
+	if err := tx.Commit(ctx); err != nil {
+		glog.Warningf("%v: Commit failed for GetLeafHistory: %v", req.MapId, err)
 		return nil, err
 	}
-	return &trillian.SetMapLeavesResponse{MapRoot: newRoot}, nil
+	return resp, nil
 }
 
-// getWriteRevision returns the revision that this transaction will be written at.
-// Only one transaction can be committed for a given revision, thus this transaction
-// will compete with any other transactions with the same write revision.
-// if assertRev is non-zero then an error will be thrown if assertRev does not match
-// the write revision.
-func (t *TrillianMapServer) getWriteRevision(ctx context.Context, tree *trillian.Tree, tx storage.MapTreeTX, assertRev int64) (int64, error) {
-	writeRev, err := tx.WriteRevision(ctx)
-	if err != nil {
-		return 0, err
+// GetLeafByHash implements the GetLeafByHash RPC method. It returns the
+// leaves whose value has the requested leaf hash at the requested revision,
+// and their inclusion proofs, read from a single snapshot.
+func (t *TrillianMapServer) GetLeafByHash(ctx context.Context, req *trillian.GetMapLeafByHashRequest) (_ *trillian.GetMapLeavesResponse, err error) {
+	ctx, spanEnd := spanFor(ctx, "GetLeafByHash", treeAttr(req.MapId), revisionAttr(req.Revision))
+	defer spanEnd()
+	ctx, finish := t.withRequestTimeout(ctx, req.MapId)
+	defer func() { err = finish(err) }()
+	if len(req.LeafHash) == 0 {
+		return nil, errEmpty("GetMapLeafByHashRequest.LeafHash")
 	}
-	if assertRev != 0 && writeRev != assertRev {
-		return 0, status.Errorf(codes.FailedPrecondition, "can't write to revision %v", assertRev)
+	if req.Revision < 0 {
+		return nil, errNegative("GetMapLeafByHashRequest.Revision", req.Revision)
 	}
-	return writeRev, nil
-}
 
-// writeLeaves updates the leaf values, but does not calculate nor update the Merkle tree.
-func (t *TrillianMapServer) writeLeaves(ctx context.Context, tx storage.MapTreeTX, leaves []*trillian.MapLeaf) error {
-	for _, l := range leaves {
-		if err := tx.Set(ctx, l.Index, l); err != nil {
-			return err
-		}
+	tree, hasher, err := t.getTreeAndHasher(ctx, req.MapId, optsMapRead)
+	if err != nil {
+		return nil, fmt.Errorf("could not get map %v: %v", req.MapId, err)
 	}
-	return nil
-}
-
-// updateTree updates the sparse Merkle tree at the specified revision based on the passed-in
-// leaf changes, and writes it to the storage. Returns the new signed map root, which is also
-// submitted to storage.
-func (t *TrillianMapServer) updateTree(ctx context.Context, tree *trillian.Tree, hasher hashers.MapHasher, tx storage.MapTreeTX, hkv []merkle.HashKeyValue, metadata []byte, rev int64) (*trillian.SignedMapRoot, error) {
-	// Work around a performance issue when using the map in
-	// single-transaction mode by preloading all the nodes we know the
-	// sparse Merkle writer is going to need.
-	if t.opts.UseSingleTransaction && t.opts.UseLargePreload {
-		if err := doPreload(ctx, tx, hasher.BitLen(), hkv); err != nil {
-			return nil, err
-		}
+	if got, want := len(req.LeafHash), hasher.Size(); got != want {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.LeafHashWrongSize, errmsg.Params{"field": "GetMapLeafByHashRequest.LeafHash", "got": got, "want": want})
 	}
+	ctx = trees.NewContext(ctx, tree)
 
-	smtWriter, err := merkle.NewSparseMerkleTreeWriter(ctx, tree.TreeId, rev, hasher, t.newTXRunner(tree, tx))
+	tx, err := t.snapshotForTree(ctx, tree, "GetLeafByHash")
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("could not create database snapshot: %v", err)
 	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetLeafByHash")
 
-	if err = smtWriter.SetLeaves(ctx, hkv); err != nil {
+	var root *trillian.SignedMapRoot
+	if req.Revision == 0 {
+		if root, err = tx.LatestSignedMapRoot(ctx); err != nil {
+			return nil, fmt.Errorf("could not fetch the latest SignedMapRoot: %v", err)
+		}
+	} else if root, err = tx.GetSignedMapRoot(ctx, req.Revision); err != nil {
+		return nil, fmt.Errorf("could not fetch SignedMapRoot %v: %v", req.Revision, err)
+	}
+	var mapRoot types.MapRootV1
+	if err := mapRoot.UnmarshalBinary(root.MapRoot); err != nil {
 		return nil, err
 	}
 
-	rootHash, err := smtWriter.CalculateRoot(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("CalculateRoot(): %v", err)
+	indices, err := tx.GetIndexesByLeafHash(ctx, int64(mapRoot.Revision), req.LeafHash)
+	if err == storage.ErrNoLeafHashIndex {
+		return nil, errmsg.New(codes.FailedPrecondition, errmsg.LeafHashIndexDisabled, errmsg.Params{"map_id": req.MapId})
+	} else if err != nil {
+		return nil, fmt.Errorf("could not look up leaf hash: %v", err)
 	}
-
-	newRoot, err := t.makeSignedMapRoot(ctx, tree, time.Now(), rootHash, tree.TreeId, rev, metadata)
-	if err != nil {
-		return nil, fmt.Errorf("makeSignedMapRoot(): %v", err)
+	resp := &trillian.GetMapLeavesResponse{MapRoot: root}
+	if len(indices) > 0 {
+		if resp, err = t.readLeavesAtRoot(ctx, tx, hasher, tree, indices, root); err != nil {
+			return nil, err
+		}
 	}
+	t.getLeafCounter.Add(float64(len(indices)), strconv.FormatInt(req.MapId, 10))
 
-	if err := tx.StoreSignedMapRoot(ctx, newRoot); err != nil {
+	if err := tx.Commit(ctx); err != nil {
+		glog.Warningf("%v: Commit failed for GetLeafByHash: %v", req.MapId, err)
 		return nil, err
 	}
-	return newRoot, nil
-}
-
-func (t *TrillianMapServer) newTXRunner(tree *trillian.Tree, tx storage.MapTreeTX) merkle.TXRunner {
-	if t.opts.UseSingleTransaction {
-		return &singleTXRunner{tx: tx}
-	}
-	return &multiTXRunner{tree: tree, mapStorage: t.registry.MapStorage}
-}
-
-// singleTXRunner executes all calls to Run with the same underlying transaction.
-// If f is large, this may incur a performance penalty.
-type singleTXRunner struct {
-	tx storage.MapTreeTX
-}
-
-// RunTX executes a function in the transaction managed by the singleTXRunner.
-func (r *singleTXRunner) RunTX(ctx context.Context, f func(context.Context, storage.MapTreeTX) error) error {
-	return f(ctx, r.tx)
-}
-
-// multiTXRunner executes each call to Run using its own transaction.
-// This allows each invocation of f to proceed independently much faster.
-// However, If one transaction fails, the other will still succeed (In some cases this could cause data corruption).
-type multiTXRunner struct {
-	tree       *trillian.Tree
-	mapStorage storage.MapStorage
+	return resp, nil
 }
 
-// RunTX executes a function in a new transaction.
-func (r *multiTXRunner) RunTX(ctx context.Context, f func(context.Context, storage.MapTreeTX) error) error {
-	return r.mapStorage.ReadWriteTransaction(ctx, r.tree, f)
-}
-
-// doPreload causes the subtreeCache in tx to become populated with all subtrees
-// on the Merkle path for the indices specified in hkv.
-// This is a performance workaround for locking issues which occur when the
-// sparse Merkle tree code is used with a single transaction (and therefore
-// a single subtreeCache too).
-func doPreload(ctx context.Context, tx storage.MapTreeTX, treeDepth int, hkv []merkle.HashKeyValue) error {
-	ctx, spanEnd := spanFor(ctx, "doPreload")
+// GetConsistencyProof implements the GetConsistencyProof RPC method. It
+// returns the requested leaves at both revisions, and the hashes of the
+// subtrees around them at the second revision, read from a single snapshot.
+func (t *TrillianMapServer) GetConsistencyProof(ctx context.Context, req *trillian.GetMapConsistencyProofRequest) (_ *trillian.GetMapConsistencyProofResponse, err error) {
+	ctx, spanEnd := spanFor(ctx, "GetConsistencyProof", treeAttr(req.MapId), revisionAttr(req.SecondRevision), batchAttr(len(req.Index)))
 	defer spanEnd()
-
-	readRev, err := tx.ReadRevision(ctx)
-	if err != nil {
-		return err
+	if req.FirstRevision < 0 {
+		return nil, errNegative("GetMapConsistencyProofRequest.FirstRevision", req.FirstRevision)
 	}
-
-	nids := calcAllSiblingsParallel(ctx, treeDepth, hkv)
-	_, err = tx.GetMerkleNodes(ctx, readRev, nids)
-	return err
-}
-
-func calcAllSiblingsParallel(_ context.Context, treeDepth int, hkv []merkle.HashKeyValue) []tree.NodeID {
-	type nodeAndID struct {
-		id   string
-		node tree.NodeID
+	if req.SecondRevision < req.FirstRevision {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.RevisionsOutOfOrder, errmsg.Params{"first": req.FirstRevision, "second": req.SecondRevision})
 	}
-	c := make(chan nodeAndID, 2048)
-	var wg sync.WaitGroup
-
-	// Kick off producers.
-	for _, i := range hkv {
-		wg.Add(1)
-		go func(k []byte) {
-			defer wg.Done()
-			nid := tree.NewNodeIDFromHash(k)
-			sibs := nid.Siblings()
-			for _, sib := range sibs {
-				sibID := sib.AsKey()
-				sib := sib
-				c <- nodeAndID{sibID, sib}
-			}
-		}(i.HashedKey)
+	if err := t.checkIndexCount(req.MapId, len(req.Index)); err != nil {
+		return nil, err
 	}
+	ctx, finish := t.withRequestTimeout(ctx, req.MapId)
+	defer func() { err = finish(err) }()
 
-	// monitor for all the producers being complete to close the channel.
-	go func() {
-		wg.Wait()
-		close(c)
-	}()
-
-	nidSet := make(map[string]bool)
-	nids := make([]tree.NodeID, 0, len(hkv)*treeDepth)
-	// consume the produced IDs until the channel is closed.
-	for nai := range c {
-		if _, ok := nidSet[nai.id]; !ok {
-			nidSet[nai.id] = true
-			nids = append(nids, nai.node)
-		}
+	tree, hasher, err := t.getTreeAndHasher(ctx, req.MapId, optsMapRead)
+	if err != nil {
+		return nil, fmt.Errorf("could not get map %v: %v", req.MapId, err)
 	}
+	if err := validateIndices(hashers.IndexSize(hasher), len(req.Index), func(i int) []byte { return req.Index[i] }, repeatedField("index", "")); err != nil {
+		return nil, err
+	}
+	ctx = trees.NewContext(ctx, tree)
+	t.getLeafCounter.Add(float64(2*len(req.Index)), strconv.FormatInt(req.MapId, 10))
 
-	return nids
-}
-
-func (t *TrillianMapServer) makeSignedMapRoot(ctx context.Context, tree *trillian.Tree, smrTs time.Time,
-	rootHash []byte, mapID, revision int64, meta []byte) (*trillian.SignedMapRoot, error) {
-	smr := &types.MapRootV1{
-		RootHash:       rootHash,
-		TimestampNanos: uint64(smrTs.UnixNano()),
-		Revision:       uint64(revision),
-		Metadata:       meta,
+	tx, err := t.snapshotForTree(ctx, tree, "GetConsistencyProof")
+	if err != nil {
+		return nil, fmt.Errorf("could not create database snapshot: %v", err)
 	}
-	signer, err := trees.Signer(ctx, tree)
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetConsistencyProof")
+
+	firstRoot, err := tx.GetSignedMapRoot(ctx, req.FirstRevision)
 	if err != nil {
-		return nil, fmt.Errorf("trees.Signer(): %v", err)
+		return nil, fmt.Errorf("could not fetch SignedMapRoot %v: %v", req.FirstRevision, err)
 	}
-	root, err := signer.SignMapRoot(smr)
+	secondRoot, err := tx.GetSignedMapRoot(ctx, req.SecondRevision)
 	if err != nil {
-		return nil, fmt.Errorf("SignMapRoot(): %v", err)
+		return nil, fmt.Errorf("could not fetch SignedMapRoot %v: %v", req.SecondRevision, err)
 	}
-	return root, nil
-}
 
-// GetSignedMapRoot implements the GetSignedMapRoot RPC method.
-func (t *TrillianMapServer) GetSignedMapRoot(ctx context.Context, req *trillian.GetSignedMapRootRequest) (*trillian.GetSignedMapRootResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetSignedMapRoot")
-	defer spanEnd()
-	tree, ctx, err := t.getTreeAndContext(ctx, req.MapId, optsMapRead)
+	// The hashes of the unchanged subtrees are the same at both revisions, so
+	// only the inclusion proofs at the second revision are needed.
+	second, err := t.readLeavesAtRoot(ctx, tx, hasher, tree, req.Index, secondRoot)
 	if err != nil {
 		return nil, err
 	}
-	tx, err := t.snapshotForTree(ctx, tree, "GetSignedMapRoot")
+	firstLeaves, err := tx.Get(ctx, req.FirstRevision, req.Index)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("could not fetch leaves: %v", err)
+	}
+	firstByIndex := make(map[string]*trillian.MapLeaf)
+	for _, l := range firstLeaves {
+		firstByIndex[string(l.Index)] = l
 	}
-	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetSignedMapRoot")
 
-	r, err := tx.LatestSignedMapRoot(ctx)
-	if err != nil {
+	resp := &trillian.GetMapConsistencyProofResponse{
+		FirstMapRoot:  firstRoot,
+		SecondMapRoot: secondRoot,
+	}
+	inclusions := make(map[string][][]byte)
+	for i, index := range req.Index {
+		l, ok := firstByIndex[string(index)]
+		if !ok {
+			l = &trillian.MapLeaf{Index: index}
+		}
+		resp.FirstLeaves = append(resp.FirstLeaves, l)
+		resp.SecondLeaves = append(resp.SecondLeaves, second.MapLeafInclusion[i].Leaf)
+		inclusions[string(index)] = second.MapLeafInclusion[i].Inclusion
+	}
+	if resp.Proof, err = merkle.MapConsistencyProof(req.Index, inclusions); err != nil {
 		return nil, err
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		glog.Warningf("%v: Commit failed for GetSignedMapRoot: %v", req.MapId, err)
+		glog.Warningf("%v: Commit failed for GetConsistencyProof: %v", req.MapId, err)
 		return nil, err
 	}
-
-	return &trillian.GetSignedMapRootResponse{MapRoot: r}, nil
+	return resp, nil
 }
 
-// GetSignedMapRootByRevision implements the GetSignedMapRootByRevision RPC
-// method.
-func (t *TrillianMapServer) GetSignedMapRootByRevision(ctx context.Context, req *trillian.GetSignedMapRootByRevisionRequest) (*trillian.GetSignedMapRootResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "GetSignedMapRootByRevision")
+// GetLeavesByIndexPrefix implements the GetLeavesByIndexPrefix RPC method.
+// The proof is a batch proof of the indices of the leaves under the prefix,
+// which is built like a consistency proof from their inclusion proofs.
+func (t *TrillianMapServer) GetLeavesByIndexPrefix(ctx context.Context, req *trillian.GetMapLeavesByIndexPrefixRequest) (_ *trillian.GetMapLeavesByIndexPrefixResponse, err error) {
+	ctx, spanEnd := spanFor(ctx, "GetLeavesByIndexPrefix", treeAttr(req.MapId), revisionAttr(req.Revision))
 	defer spanEnd()
+	if len(req.IndexPrefix) == 0 {
+		return nil, errEmpty("GetMapLeavesByIndexPrefixRequest.IndexPrefix")
+	}
 	if req.Revision < 0 {
-		return nil, fmt.Errorf("map revision %d must be >= 0", req.Revision)
+		return nil, errNegative("GetMapLeavesByIndexPrefixRequest.Revision", req.Revision)
 	}
-	tree, ctx, err := t.getTreeAndContext(ctx, req.MapId, optsMapRead)
+	ctx, finish := t.withRequestTimeout(ctx, req.MapId)
+	defer func() { err = finish(err) }()
+
+	tree, hasher, err := t.getTreeAndHasher(ctx, req.MapId, optsMapRead)
+	if err != nil {
+		return nil, fmt.Errorf("could not get map %v: %v", req.MapId, err)
+	}
+	if got, max := len(req.IndexPrefix), hashers.IndexSize(hasher)-1; got > max {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.MapIndexPrefixTooLong, errmsg.Params{"got": got, "max": max})
+	}
+	ctx = querytag.WithRevision(trees.NewContext(ctx, tree), req.Revision)
+
+	tx, err := t.snapshotForTree(ctx, tree, "GetLeavesByIndexPrefix")
+	if err != nil {
+		return nil, fmt.Errorf("could not create database snapshot: %v", err)
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetLeavesByIndexPrefix")
+
+	root, err := tx.GetSignedMapRoot(ctx, req.Revision)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch SignedMapRoot %v: %v", req.Revision, err)
+	}
+	// One more leaf than allowed is enough to reject the request.
+	limit := 0
+	if max := t.opts.Limits.MaxGetIndices; max > 0 {
+		limit = max + 1
+	}
+	leaves, err := listPrefix(ctx, tx, req.Revision, req.IndexPrefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not list leaves: %v", err)
+	}
+	if err := t.checkIndexCount(req.MapId, len(leaves)); err != nil {
+		return nil, err
+	}
+	t.getLeafCounter.Add(float64(len(leaves)), strconv.FormatInt(req.MapId, 10))
+
+	indices := merkle.MapPrefixProofIndices(req.IndexPrefix, hashers.IndexSize(hasher), leaves)
+	smtReader := merkle.NewSparseMerkleTreeReader(req.Revision, hasher, t.proofReader(tree.TreeId, tx))
+	inclusions, err := smtReader.BatchInclusionProof(ctx, req.Revision, indices)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch inclusion proofs: %v", err)
+	}
+	proof, err := merkle.MapConsistencyProof(indices, inclusions)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		glog.Warningf("%v: Commit failed for GetLeavesByIndexPrefix: %v", req.MapId, err)
+		return nil, err
+	}
+	return &trillian.GetMapLeavesByIndexPrefixResponse{MapRoot: root, Leaves: leaves, Proof: proof}, nil
+}
+
+// ListLeavesByRevision implements the ListLeavesByRevision RPC method. It
+// streams pages of the leaves which exist at the requested revision, in
+// ascending index order. Cleared leaves, whose values are empty, are skipped,
+// and a page only carries a NextPageToken if more leaves follow it.
+func (t *TrillianMapServer) ListLeavesByRevision(req *trillian.ListMapLeavesByRevisionRequest, stream trillian.TrillianMap_ListLeavesByRevisionServer) error {
+	ctx, spanEnd := spanFor(stream.Context(), "ListLeavesByRevision", treeAttr(req.MapId), revisionAttr(req.Revision), batchAttr(int(req.PageSize)))
+	defer spanEnd()
+	if req.Revision < 0 {
+		return errNegative("ListMapLeavesByRevisionRequest.Revision", req.Revision)
+	}
+	if req.PageSize < 0 {
+		return errNegative("ListMapLeavesByRevisionRequest.PageSize", int64(req.PageSize))
+	}
+	pageSize := listPageSize(req.PageSize)
+
+	tree, hasher, err := t.getTreeAndHasher(ctx, req.MapId, optsMapRead)
+	if err != nil {
+		return fmt.Errorf("could not get map %v: %v", req.MapId, err)
+	}
+	ctx = trees.NewContext(ctx, tree)
+	after, err := parseLeafPageToken(req.PageToken, hashers.IndexSize(hasher))
+	if err != nil {
+		return err
+	}
+
+	tx, err := t.snapshotForTree(ctx, tree, "ListLeavesByRevision")
+	if err != nil {
+		return fmt.Errorf("could not create database snapshot: %v", err)
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "ListLeavesByRevision")
+
+	// Fail early for revisions which don't exist yet, rather than returning
+	// the leaves of the latest revision.
+	root, err := tx.GetSignedMapRoot(ctx, req.Revision)
+	if err != nil {
+		return err
+	}
+
+	// pending holds the leaves read from storage but not sent yet. Storage is
+	// read until it holds more than a page, so that a page is only given a
+	// token if another leaf follows it.
+	var pending []*trillian.MapLeaf
+	more := true
+	resp := &trillian.ListMapLeavesByRevisionResponse{MapRoot: root}
+	for {
+		for more && len(pending) <= pageSize {
+			leaves, err := tx.List(ctx, req.Revision, after, pageSize+1)
+			if err != nil {
+				return err
+			}
+			t.getLeafCounter.Add(float64(len(leaves)), strconv.FormatInt(req.MapId, 10))
+			more = len(leaves) > pageSize
+			if len(leaves) > 0 {
+				after = leaves[len(leaves)-1].Index
+			}
+			for _, l := range leaves {
+				if len(l.LeafValue) > 0 {
+					pending = append(pending, l)
+				}
+			}
+		}
+		page := pending
+		if len(page) > pageSize {
+			page = page[:pageSize]
+		}
+		pending = pending[len(page):]
+		resp.Leaves = page
+		if len(pending) > 0 {
+			resp.NextPageToken = leafPageToken(page[len(page)-1].Index)
+		}
+		// Always send the first response, so that the client gets the map root
+		// even if the map is empty.
+		if len(page) > 0 || resp.MapRoot != nil {
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+		resp = &trillian.ListMapLeavesByRevisionResponse{}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		glog.Warningf("%v: Commit failed for ListLeavesByRevision: %v", req.MapId, err)
+		return err
+	}
+	return nil
+}
+
+// listPageSize returns the number of items in each response of a listing
+// which requested n.
+func listPageSize(n int32) int {
+	switch {
+	case n == 0:
+		return defaultListPageSize
+	case n > maxListPageSize:
+		return maxListPageSize
+	}
+	return int(n)
+}
+
+// listPrefix returns the leaves stored at revision whose indexes start with
+// prefix, including cleared ones, in ascending index order. If limit is
+// positive, it returns at most limit leaves.
+func listPrefix(ctx context.Context, tx storage.ReadOnlyMapTreeTX, revision int64, prefix []byte, limit int) ([]*trillian.MapLeaf, error) {
+	var ret []*trillian.MapLeaf
+	// A proper prefix of an index sorts before all the indexes it prefixes.
+	after := prefix
+	for {
+		leaves, err := tx.List(ctx, revision, after, maxListPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range leaves {
+			if !bytes.HasPrefix(l.Index, prefix) || (limit > 0 && len(ret) == limit) {
+				return ret, nil
+			}
+			ret = append(ret, l)
+		}
+		if len(leaves) < maxListPageSize {
+			return ret, nil
+		}
+		after = leaves[len(leaves)-1].Index
+	}
+}
+
+// leafPageToken returns the page token which resumes a leaf listing after
+// index.
+func leafPageToken(index []byte) string {
+	return base64.RawURLEncoding.EncodeToString(index)
+}
+
+// parseLeafPageToken returns the index encoded in token, or nil if token is
+// empty. indexSize is the expected size of the index in bytes.
+func parseLeafPageToken(token string, indexSize int) ([]byte, error) {
+	if token == "" {
+		return nil, nil
+	}
+	index, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.InvalidPageToken, errmsg.Params{"detail": err})
+	}
+	if got, want := len(index), indexSize; got != want {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.InvalidPageToken, errmsg.Params{
+			"detail": fmt.Sprintf("index has wrong length: got=%d,want=%d", got, want),
+		})
+	}
+	return index, nil
+}
+
+// SetLeaves implements the SetLeaves RPC method.
+func (t *TrillianMapServer) SetLeaves(ctx context.Context, req *trillian.SetMapLeavesRequest) (_ *trillian.SetMapLeavesResponse, err error) {
+	ctx, spanEnd := spanFor(ctx, "SetLeaves", treeAttr(req.MapId), revisionAttr(req.Revision), batchAttr(len(req.Leaves)))
+	defer spanEnd()
+	if err := t.checkWritable("SetLeaves"); err != nil {
+		return nil, err
+	}
+	ctx, finish := t.withRequestTimeout(ctx, req.MapId)
+	defer func() { err = finish(err) }()
+
+	u, err := t.prepareUpdate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	ctx = trees.NewContext(ctx, u.tree)
+
+	// Dry runs fail the transaction once the root is calculated, so that
+	// nothing is committed. Their Merkle tree changes must be made in the
+	// same transaction, to be discarded with it.
+	singleTX := t.opts.UseSingleTransaction || req.DryRun
+	var newRoot *trillian.SignedMapRoot
+	calculated := false
+	txCtx, commit := t.newCommitStage(ctx, req.MapId)
+	err = t.readWriteTransaction(txCtx, u.tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		var err error
+		if newRoot, err = t.applyUpdate(ctx, u, tx, singleTX); err != nil {
+			return err
+		}
+		if req.DryRun {
+			calculated = true
+			return errDryRun
+		}
+		commit.begin()
+		return nil
+	})
+	err = commit.end(ctx, err)
+	if req.DryRun && calculated {
+		return &trillian.SetMapLeavesResponse{MapRoot: newRoot}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.notifier.notify(req.MapId)
+	if !u.replayed {
+		t.shadowUpdate(u, newRoot)
+	}
+	return &trillian.SetMapLeavesResponse{MapRoot: newRoot}, nil
+}
+
+// SetMultiMapLeaves implements the SetMultiMapLeaves RPC method.
+func (t *TrillianMapServer) SetMultiMapLeaves(ctx context.Context, req *trillian.SetMultiMapLeavesRequest) (_ *trillian.SetMultiMapLeavesResponse, err error) {
+	ctx, spanEnd := spanFor(ctx, "SetMultiMapLeaves", batchAttr(len(req.Requests)))
+	defer spanEnd()
+	if err := t.checkWritable("SetMultiMapLeaves"); err != nil {
+		return nil, err
+	}
+
+	if len(req.Requests) == 0 {
+		return nil, errEmpty("SetMultiMapLeavesRequest.Requests")
+	}
+	// Limits are counted against the first map, as the request is handled
+	// in a single transaction.
+	firstMap := req.Requests[0].MapId
+	leaves := 0
+	for _, r := range req.Requests {
+		leaves += len(r.Leaves)
+	}
+	if err := t.checkWriteSize(firstMap, leaves, req); err != nil {
+		return nil, err
+	}
+	ctx, finish := t.withRequestTimeout(ctx, firstMap)
+	defer func() { err = finish(err) }()
+	updates := make([]*mapUpdate, 0, len(req.Requests))
+	mapTrees := make([]*trillian.Tree, 0, len(req.Requests))
+	seen := make(map[int64]bool)
+	for _, r := range req.Requests {
+		if r.DryRun {
+			return nil, errmsg.New(codes.InvalidArgument, errmsg.DryRunUnsupported, errmsg.Params{"method": "SetMultiMapLeaves"})
+		}
+		if seen[r.MapId] {
+			return nil, errmsg.New(codes.InvalidArgument, errmsg.DuplicateMapRequest, errmsg.Params{"map_id": r.MapId})
+		}
+		seen[r.MapId] = true
+		u, err := t.prepareUpdate(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, u)
+		mapTrees = append(mapTrees, u.tree)
+	}
+
+	codecs := make([]compression.Codec, len(updates))
+	for i, u := range updates {
+		if codecs[i], err = compression.ForTree(u.tree); err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "compression.ForTree(): %v", err)
+		}
+	}
+
+	newRoots := make([]*trillian.SignedMapRoot, len(updates))
+	err = t.registry.MapStorage.ReadWriteMultiTransaction(ctx, mapTrees, func(ctx context.Context, txs []storage.MapTreeTX) error {
+		for i, u := range updates {
+			// The Merkle tree updates must be made in the shared transaction for
+			// the maps to be updated atomically, so UseSingleTransaction is
+			// implied here.
+			tx := compression.WrapTX(txs[i], codecs[i])
+			newRoot, err := t.applyUpdate(ctx, u, tx, true /* singleTX */)
+			if err != nil {
+				glog.Warningf("%v: SetMultiMapLeaves failed: %v", u.tree.TreeId, err)
+				return err
+			}
+			newRoots[i] = newRoot
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range req.Requests {
+		t.notifier.notify(r.MapId)
+	}
+	return &trillian.SetMultiMapLeavesResponse{MapRoots: newRoots}, nil
+}
+
+// mapUpdate is a validated SetMapLeavesRequest, together with the map it
+// applies to.
+type mapUpdate struct {
+	req    *trillian.SetMapLeavesRequest
+	tree   *trillian.Tree
+	hasher hashers.MapHasher
+	// hkv summarizes the leaf indices and their new hash values.
+	hkv []merkle.HashKeyValue
+	// replayed is set by applyUpdate if the update was already written by a
+	// previous one with the same idempotency token.
+	replayed bool
+}
+
+// prepareUpdate validates req and computes the hashes of its leaves, without
+// accessing the map storage.
+func (t *TrillianMapServer) prepareUpdate(ctx context.Context, req *trillian.SetMapLeavesRequest) (*mapUpdate, error) {
+	mapID := req.MapId
+	t.setLeafCounter.Add(float64(len(req.Leaves)), string(mapID))
+
+	if err := t.checkWriteSize(mapID, len(req.Leaves), req); err != nil {
+		return nil, err
+	}
+	tree, hasher, err := t.getTreeAndHasher(ctx, mapID, optsMapWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateIndices(hashers.IndexSize(hasher), len(req.Leaves), func(i int) []byte { return req.Leaves[i].Index }, repeatedField("leaves", ".index")); err != nil {
+		return nil, err
+	}
+	if got, want := len(req.IdempotencyToken), maxIdempotencyTokenSize; got > want {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.IdempotencyTokenTooLong, errmsg.Params{"got": got, "max": want})
+	}
+
+	// Overwrite/set the leaf hashes in the request and create a summary of
+	// the leaf indices and new hash values.
+	hkv := make([]merkle.HashKeyValue, 0, len(req.Leaves))
+	for _, l := range req.Leaves {
+		l.LeafHash = hasher.HashLeaf(tree.TreeId, l.Index, l.LeafValue)
+		hkv = append(hkv, merkle.HashKeyValue{
+			HashedKey:   l.Index,
+			HashedValue: l.LeafHash,
+		})
+	}
+	return &mapUpdate{req: req, tree: tree, hasher: hasher, hkv: hkv}, nil
+}
+
+// applyUpdate writes the leaves of u and the resulting Merkle tree changes in
+// tx, and returns the new signed map root. If singleTX is false, the Merkle
+// tree changes are made in separate transactions.
+// If the request has an idempotency token which was stored by a previous
+// update, nothing is written and the root of the revision created by that
+// update is returned.
+// If the request is a dry run, the root is not signed nor stored, and the
+// caller must discard tx instead of committing it.
+func (t *TrillianMapServer) applyUpdate(ctx context.Context, u *mapUpdate, tx storage.MapTreeTX, singleTX bool) (*trillian.SignedMapRoot, error) {
+	token := u.req.IdempotencyToken
+	if len(token) > 0 {
+		rev, found, err := tx.GetIdempotencyToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			glog.V(2).Infof("%v: Request with idempotency token %x already written at revision %v", u.tree.TreeId, token, rev)
+			u.replayed = true
+			return tx.GetSignedMapRoot(ctx, rev)
+		}
+	}
+
+	writeRev, err := t.getWriteRevision(ctx, u.tree, tx, u.req.Revision)
+	if err != nil {
+		return nil, err
+	}
+	glog.V(2).Infof("%v: Writing at revision %v", u.tree.TreeId, writeRev)
+	ctx = querytag.WithRevision(ctx, writeRev)
+	if err := t.checkRevisionLease(ctx, u.tree, writeRev, u.req.RevisionLease); err != nil {
+		return nil, err
+	}
+
+	if err := t.runStage(ctx, u.tree.TreeId, stageWriteLeaves, func(ctx context.Context) error {
+		return t.writeLeaves(ctx, u.tree.TreeId, tx, u.req.Leaves)
+	}); err != nil {
+		return nil, err
+	}
+	if u.req.DryRun {
+		rootHash, err := t.calculateRoot(ctx, u.tree, u.hasher, tx, u.hkv, writeRev, singleTX)
+		if err != nil {
+			return nil, err
+		}
+		return unsignedMapRoot(rootHash, time.Now(), writeRev, u.req.Metadata)
+	}
+	if len(token) > 0 {
+		if err := tx.StoreIdempotencyToken(ctx, token); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.updateTree(ctx, u.tree, u.hasher, tx, u.hkv, u.req.Metadata, writeRev, singleTX)
+}
+
+// getWriteRevision returns the revision that this transaction will be written at.
+// Only one transaction can be committed for a given revision, thus this transaction
+// will compete with any other transactions with the same write revision.
+// if assertRev is non-zero then an error will be thrown if assertRev does not match
+// the write revision.
+func (t *TrillianMapServer) getWriteRevision(ctx context.Context, tree *trillian.Tree, tx storage.MapTreeTX, assertRev int64) (int64, error) {
+	writeRev, err := tx.WriteRevision(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if assertRev != 0 && writeRev != assertRev {
+		return 0, errmsg.New(codes.FailedPrecondition, errmsg.RevisionMismatch, errmsg.Params{"revision": assertRev})
+	}
+	return writeRev, nil
+}
+
+// checkRevisionLease fails if rev is reserved by a lease whose token is not
+// token. Writes may only set token if the map storage supports reservations.
+func (t *TrillianMapServer) checkRevisionLease(ctx context.Context, tree *trillian.Tree, rev int64, token []byte) error {
+	reserver, ok := t.registry.MapStorage.(storage.MapRevisionReserver)
+	if !ok {
+		if len(token) > 0 {
+			return status.Error(codes.Unimplemented, "map storage does not support revision reservations")
+		}
+		return nil
+	}
+	lease, err := reserver.GetMapRevisionLease(ctx, tree, rev, time.Now())
+	if err != nil {
+		return err
+	}
+	if lease != nil && !bytes.Equal(lease.Token, token) {
+		return errmsg.New(codes.FailedPrecondition, errmsg.RevisionReserved, errmsg.Params{"revision": rev})
+	}
+	return nil
+}
+
+// writeLeaves updates the leaf values of map mapID, but does not calculate nor
+// update the Merkle tree. The leaves are counted by index prefix as they are
+// written, even if the transaction is later rolled back.
+func (t *TrillianMapServer) writeLeaves(ctx context.Context, mapID int64, tx storage.MapTreeTX, leaves []*trillian.MapLeaf) error {
+	for _, l := range leaves {
+		if err := tx.Set(ctx, l.Index, l); err != nil {
+			return err
+		}
+	}
+	t.hotKeys.record(mapID, leaves)
+	return nil
+}
+
+// updateTree updates the sparse Merkle tree at the specified revision based on the passed-in
+// leaf changes, and writes it to the storage. Returns the new signed map root, which is also
+// submitted to storage.
+func (t *TrillianMapServer) updateTree(ctx context.Context, tree *trillian.Tree, hasher hashers.MapHasher, tx storage.MapTreeTX, hkv []merkle.HashKeyValue, metadata []byte, rev int64, singleTX bool) (*trillian.SignedMapRoot, error) {
+	rootHash, err := t.calculateRoot(ctx, tree, hasher, tx, hkv, rev, singleTX)
+	if err != nil {
+		return nil, err
+	}
+
+	var newRoot *trillian.SignedMapRoot
+	err = t.runStage(ctx, tree.TreeId, stageSignRoot, func(ctx context.Context) error {
+		var err error
+		newRoot, err = t.makeSignedMapRoot(ctx, tree, time.Now(), rootHash, tree.TreeId, rev, metadata)
+		if err != nil {
+			return fmt.Errorf("makeSignedMapRoot(): %v", err)
+		}
+		// Signers don't take a context, so a root signed too late is
+		// discarded here.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return tx.StoreSignedMapRoot(ctx, newRoot)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newRoot, nil
+}
+
+// calculateRoot writes the sparse Merkle tree changes of hkv at the specified
+// revision, and returns the new root hash.
+func (t *TrillianMapServer) calculateRoot(ctx context.Context, tree *trillian.Tree, hasher hashers.MapHasher, tx storage.MapTreeTX, hkv []merkle.HashKeyValue, rev int64, singleTX bool) ([]byte, error) {
+	// Work around a performance issue when using the map in
+	// single-transaction mode by preloading the nodes we know the sparse
+	// Merkle writer is going to need.
+	if singleTX && t.opts.Preload != nil {
+		if err := t.runStage(ctx, tree.TreeId, stagePreload, func(ctx context.Context) error {
+			return t.doPreload(ctx, tree.TreeId, tx, hasher.BitLen(), hkv)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	var rootHash []byte
+	err := t.runStage(ctx, tree.TreeId, stageComputeRoot, func(ctx context.Context) error {
+		txRunner := t.newTXRunner(tree, tx, singleTX)
+		smtWriter, err := merkle.NewSparseMerkleTreeWriter(ctx, tree.TreeId, rev, hasher, txRunner)
+		if err != nil {
+			return err
+		}
+
+		if err = smtWriter.SetLeaves(ctx, hkv); err != nil {
+			return err
+		}
+
+		if rootHash, err = smtWriter.CalculateRoot(ctx); err != nil {
+			return fmt.Errorf("CalculateRoot(): %v", err)
+		}
+		return t.checkProofs(ctx, tree, hasher, txRunner, hkv, rev, rootHash)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rootHash, nil
+}
+
+func (t *TrillianMapServer) newTXRunner(tree *trillian.Tree, tx storage.MapTreeTX, singleTX bool) merkle.TXRunner {
+	if singleTX {
+		return &singleTXRunner{tx: tx}
+	}
+	return &multiTXRunner{tree: tree, mapStorage: t.registry.MapStorage}
+}
+
+// singleTXRunner executes all calls to Run with the same underlying transaction.
+// If f is large, this may incur a performance penalty.
+type singleTXRunner struct {
+	tx storage.MapTreeTX
+}
+
+// RunTX executes a function in the transaction managed by the singleTXRunner.
+func (r *singleTXRunner) RunTX(ctx context.Context, f func(context.Context, storage.MapTreeTX) error) error {
+	return f(ctx, r.tx)
+}
+
+// multiTXRunner executes each call to Run using its own transaction.
+// This allows each invocation of f to proceed independently much faster.
+// However, If one transaction fails, the other will still succeed (In some cases this could cause data corruption).
+type multiTXRunner struct {
+	tree       *trillian.Tree
+	mapStorage storage.MapStorage
+}
+
+// RunTX executes a function in a new transaction.
+func (r *multiTXRunner) RunTX(ctx context.Context, f func(context.Context, storage.MapTreeTX) error) error {
+	return r.mapStorage.ReadWriteTransaction(ctx, r.tree, f)
+}
+
+func (t *TrillianMapServer) makeSignedMapRoot(ctx context.Context, tree *trillian.Tree, smrTs time.Time,
+	rootHash []byte, mapID, revision int64, meta []byte) (*trillian.SignedMapRoot, error) {
+	smr := &types.MapRootV1{
+		RootHash:       rootHash,
+		TimestampNanos: uint64(smrTs.UnixNano()),
+		Revision:       uint64(revision),
+		Metadata:       meta,
+	}
+	signer, err := trees.Signer(ctx, tree)
+	if err != nil {
+		return nil, fmt.Errorf("trees.Signer(): %v", err)
+	}
+	root, err := signer.SignMapRoot(smr)
+	if err != nil {
+		return nil, fmt.Errorf("SignMapRoot(): %v", err)
+	}
+	return root, nil
+}
+
+// unsignedMapRoot returns a SignedMapRoot with the given contents and no
+// signature, as returned by dry runs.
+func unsignedMapRoot(rootHash []byte, ts time.Time, revision int64, meta []byte) (*trillian.SignedMapRoot, error) {
+	smr := &types.MapRootV1{
+		RootHash:       rootHash,
+		TimestampNanos: uint64(ts.UnixNano()),
+		Revision:       uint64(revision),
+		Metadata:       meta,
+	}
+	root, err := smr.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("MarshalBinary(): %v", err)
+	}
+	return &trillian.SignedMapRoot{MapRoot: root}, nil
+}
+
+// GetSignedMapRoot implements the GetSignedMapRoot RPC method.
+func (t *TrillianMapServer) GetSignedMapRoot(ctx context.Context, req *trillian.GetSignedMapRootRequest) (*trillian.GetSignedMapRootResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "GetSignedMapRoot", treeAttr(req.MapId))
+	defer spanEnd()
+	tree, ctx, err := t.getTreeAndContext(ctx, req.MapId, optsMapRead)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := t.snapshotForTree(ctx, tree, "GetSignedMapRoot")
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetSignedMapRoot")
+
+	r, err := tx.LatestSignedMapRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		glog.Warningf("%v: Commit failed for GetSignedMapRoot: %v", req.MapId, err)
+		return nil, err
+	}
+
+	return &trillian.GetSignedMapRootResponse{MapRoot: r}, nil
+}
+
+// GetCheckpoint implements the GetCheckpoint RPC method.
+func (t *TrillianMapServer) GetCheckpoint(ctx context.Context, req *trillian.GetMapCheckpointRequest) (*trillian.GetMapCheckpointResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "GetCheckpoint", treeAttr(req.MapId))
+	defer spanEnd()
+	if _, err := checkpointOrigin(req.MapId); err != nil {
+		return nil, err
+	}
+	tree, ctx, err := t.getTreeAndContext(ctx, req.MapId, optsMapRead)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := t.snapshotForTree(ctx, tree, "GetCheckpoint")
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetCheckpoint")
+
+	smr, err := tx.LatestSignedMapRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		glog.Warningf("%v: Commit failed for GetCheckpoint: %v", req.MapId, err)
+		return nil, err
+	}
+
+	var root types.MapRootV1
+	if err := root.UnmarshalBinary(smr.GetMapRoot()); err != nil {
+		return nil, status.Errorf(codes.Internal, "could not read current map root: %v", err)
+	}
+	cp, err := signedCheckpoint(ctx, tree, root.Revision, root.RootHash)
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.GetMapCheckpointResponse{Checkpoint: cp, MapRoot: smr}, nil
+}
+
+// GetSignedMapRootByRevision implements the GetSignedMapRootByRevision RPC
+// method.
+func (t *TrillianMapServer) GetSignedMapRootByRevision(ctx context.Context, req *trillian.GetSignedMapRootByRevisionRequest) (*trillian.GetSignedMapRootResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "GetSignedMapRootByRevision", treeAttr(req.MapId), revisionAttr(req.Revision))
+	defer spanEnd()
+	if req.Revision < 0 {
+		return nil, fmt.Errorf("map revision %d must be >= 0", req.Revision)
+	}
+	tree, ctx, err := t.getTreeAndContext(ctx, req.MapId, optsMapRead)
 	if err != nil {
 		return nil, err
 	}
@@ -588,12 +1574,449 @@ func (t *TrillianMapServer) GetSignedMapRootByRevision(ctx context.Context, req
 	return &trillian.GetSignedMapRootResponse{MapRoot: r}, nil
 }
 
+// ListSignedMapRoots implements the ListSignedMapRoots RPC method. It returns
+// a page of the roots which match the requested revision and time ranges.
+func (t *TrillianMapServer) ListSignedMapRoots(ctx context.Context, req *trillian.ListSignedMapRootsRequest) (*trillian.ListSignedMapRootsResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "ListSignedMapRoots", treeAttr(req.MapId))
+	defer spanEnd()
+	for _, f := range []struct {
+		name  string
+		value int64
+	}{
+		{"StartRevision", req.StartRevision},
+		{"EndRevision", req.EndRevision},
+		{"StartTimestampNanos", req.StartTimestampNanos},
+		{"EndTimestampNanos", req.EndTimestampNanos},
+		{"PageSize", int64(req.PageSize)},
+	} {
+		if f.value < 0 {
+			return nil, errNegative("ListSignedMapRootsRequest."+f.name, f.value)
+		}
+	}
+	pageSize := int(req.PageSize)
+	switch {
+	case pageSize == 0:
+		pageSize = defaultRootListPageSize
+	case pageSize > maxRootListPageSize:
+		pageSize = maxRootListPageSize
+	}
+
+	filter := storage.MapRootFilter{
+		StartRevision: req.StartRevision,
+		EndRevision:   req.EndRevision,
+		Descending:    req.NewestFirst,
+	}
+	if req.StartTimestampNanos > 0 {
+		filter.StartTime = time.Unix(0, req.StartTimestampNanos)
+	}
+	if req.EndTimestampNanos > 0 {
+		filter.EndTime = time.Unix(0, req.EndTimestampNanos)
+	}
+
+	tree, ctx, err := t.getTreeAndContext(ctx, req.MapId, optsMapRead)
+	if err != nil {
+		return nil, err
+	}
+	if req.PageToken != "" {
+		last, err := parseRootPageToken(req.PageToken)
+		if err != nil {
+			return nil, err
+		}
+		// Resume after the last root of the previous page.
+		if !filter.Descending && last+1 > filter.StartRevision {
+			filter.StartRevision = last + 1
+		} else if filter.Descending && (filter.EndRevision == 0 || last < filter.EndRevision) {
+			if last == 0 {
+				return &trillian.ListSignedMapRootsResponse{}, nil
+			}
+			filter.EndRevision = last
+		}
+	}
+
+	tx, err := t.snapshotForTree(ctx, tree, "ListSignedMapRoots")
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "ListSignedMapRoots")
+
+	roots, err := tx.ListSignedMapRoots(ctx, filter, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	resp := &trillian.ListSignedMapRootsResponse{MapRoots: roots}
+	if len(roots) == pageSize {
+		var last types.MapRootV1
+		if err := last.UnmarshalBinary(roots[len(roots)-1].MapRoot); err != nil {
+			return nil, err
+		}
+		// There is nothing before revision 0 in a descending listing.
+		if !filter.Descending || last.Revision > 0 {
+			resp.NextPageToken = strconv.FormatUint(last.Revision, 10)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		glog.Warningf("%v: Commit failed for ListSignedMapRoots: %v", req.MapId, err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// parseRootPageToken returns the revision of the last root of the page which
+// token follows.
+func parseRootPageToken(token string) (int64, error) {
+	rev, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		return 0, errmsg.New(codes.InvalidArgument, errmsg.InvalidPageToken, errmsg.Params{"detail": err})
+	}
+	if rev < 0 {
+		return 0, errmsg.New(codes.InvalidArgument, errmsg.InvalidPageToken, errmsg.Params{
+			"detail": fmt.Sprintf("negative revision %d", rev),
+		})
+	}
+	return rev, nil
+}
+
+// WatchSignedMapRoots implements the WatchSignedMapRoots RPC method. It sends
+// the latest root of the map, and then each newer root in revision order as
+// it is found in storage.
+func (t *TrillianMapServer) WatchSignedMapRoots(req *trillian.WatchSignedMapRootsRequest, stream trillian.TrillianMap_WatchSignedMapRootsServer) error {
+	ctx, spanEnd := spanFor(stream.Context(), "WatchSignedMapRoots", treeAttr(req.MapId))
+	defer spanEnd()
+	tree, ctx, err := t.getTreeAndContext(ctx, req.MapId, optsMapRead)
+	if err != nil {
+		return err
+	}
+
+	// Subscribe before the first read so that no root published by this
+	// server in between is missed.
+	wake, unsubscribe := t.notifier.subscribe(req.MapId)
+	defer unsubscribe()
+	ticker := time.NewTicker(t.opts.WatchPollInterval)
+	defer ticker.Stop()
+
+	next := int64(mostRecentRevision)
+	for {
+		roots, err := t.rootsSince(ctx, tree, next)
+		if err != nil {
+			return err
+		}
+		for _, r := range roots {
+			if err := stream.Send(&trillian.WatchSignedMapRootsResponse{MapRoot: r.smr}); err != nil {
+				return err
+			}
+			next = r.revision + 1
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// revisionedRoot is a SignedMapRoot along with its parsed revision.
+type revisionedRoot struct {
+	smr      *trillian.SignedMapRoot
+	revision int64
+}
+
+// rootsSince returns the roots of tree from revision next up to the latest
+// one, in revision order. If next is mostRecentRevision, only the latest root
+// is returned. No roots are returned if the map is not initialised yet.
+func (t *TrillianMapServer) rootsSince(ctx context.Context, tree *trillian.Tree, next int64) ([]revisionedRoot, error) {
+	tx, err := t.snapshotForTree(ctx, tree, "WatchSignedMapRoots")
+	if err == storage.ErrTreeNeedsInit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "WatchSignedMapRoots")
+
+	latest, err := tx.LatestSignedMapRoot(ctx)
+	if err == storage.ErrTreeNeedsInit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var mr types.MapRootV1
+	if err := mr.UnmarshalBinary(latest.MapRoot); err != nil {
+		return nil, err
+	}
+	latestRev := int64(mr.Revision)
+
+	var roots []revisionedRoot
+	if next != mostRecentRevision && next <= latestRev {
+		if latestRev-next > maxWatchCatchUp {
+			glog.Warningf("%v: WatchSignedMapRoots skipping revisions [%d, %d)", tree.TreeId, next, latestRev-maxWatchCatchUp)
+			next = latestRev - maxWatchCatchUp
+		}
+		for rev := next; rev < latestRev; rev++ {
+			r, err := tx.GetSignedMapRoot(ctx, rev)
+			if err != nil {
+				return nil, err
+			}
+			roots = append(roots, revisionedRoot{smr: r, revision: rev})
+		}
+	}
+	if next == mostRecentRevision || next <= latestRev {
+		roots = append(roots, revisionedRoot{smr: latest, revision: latestRev})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		glog.Warningf("%v: Commit failed for WatchSignedMapRoots: %v", tree.TreeId, err)
+		return nil, err
+	}
+	return roots, nil
+}
+
+// AddMapRootSignature implements the AddMapRootSignature RPC method. The
+// witness signature is stored once it verifies over the root of the requested
+// revision. Signatures don't change the map, so frozen maps can be witnessed.
+func (t *TrillianMapServer) AddMapRootSignature(ctx context.Context, req *trillian.AddMapRootSignatureRequest) (*trillian.AddMapRootSignatureResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "AddMapRootSignature", treeAttr(req.MapId), revisionAttr(req.Revision))
+	defer spanEnd()
+	if err := t.checkWritable("AddMapRootSignature"); err != nil {
+		return nil, err
+	}
+	if req.Revision < 0 {
+		return nil, errNegative("AddMapRootSignatureRequest.Revision", req.Revision)
+	}
+	pub, err := der.FromPublicProto(req.GetSignature().GetPublicKey())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "AddMapRootSignatureRequest.Signature.PublicKey: %v", err)
+	}
+	tree, ctx, err := t.getTreeAndContext(ctx, req.MapId, optsMapRead)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.readWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		root, err := tx.GetSignedMapRoot(ctx, req.Revision)
+		if err != nil {
+			return err
+		}
+		if err := tcrypto.Verify(pub, crypto.SHA256, root.MapRoot, req.Signature.Signature); err != nil {
+			glog.V(1).Infof("%v: witness signature over revision %d does not verify: %v", req.MapId, req.Revision, err)
+			return errmsg.New(codes.InvalidArgument, errmsg.MapRootSignatureInvalid, errmsg.Params{"revision": req.Revision})
+		}
+		return tx.StoreMapRootSignature(ctx, req.Revision, req.Signature)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &trillian.AddMapRootSignatureResponse{}, nil
+}
+
+// GetMapRootSignatures implements the GetMapRootSignatures RPC method.
+func (t *TrillianMapServer) GetMapRootSignatures(ctx context.Context, req *trillian.GetMapRootSignaturesRequest) (*trillian.GetMapRootSignaturesResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "GetMapRootSignatures", treeAttr(req.MapId), revisionAttr(req.Revision))
+	defer spanEnd()
+	if req.Revision < 0 {
+		return nil, errNegative("GetMapRootSignaturesRequest.Revision", req.Revision)
+	}
+	tree, ctx, err := t.getTreeAndContext(ctx, req.MapId, optsMapRead)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := t.snapshotForTree(ctx, tree, "GetMapRootSignatures")
+	if err != nil {
+		return nil, err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "GetMapRootSignatures")
+
+	root, err := tx.GetSignedMapRoot(ctx, req.Revision)
+	if err != nil {
+		return nil, err
+	}
+	sigs, err := tx.GetMapRootSignatures(ctx, req.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		glog.Warningf("%v: Commit failed for GetMapRootSignatures: %v", req.MapId, err)
+		return nil, err
+	}
+	return &trillian.GetMapRootSignaturesResponse{MapRoot: root, Signatures: sigs}, nil
+}
+
+// ExportMap implements the ExportMap RPC method.
+func (t *TrillianMapServer) ExportMap(req *trillian.ExportMapRequest, stream trillian.TrillianMap_ExportMapServer) error {
+	ctx, spanEnd := spanFor(stream.Context(), "ExportMap", treeAttr(req.MapId), revisionAttr(req.Revision))
+	defer spanEnd()
+	if req.Revision < 0 {
+		return errNegative("ExportMapRequest.Revision", req.Revision)
+	}
+	if req.PageSize < 0 {
+		return errNegative("ExportMapRequest.PageSize", int64(req.PageSize))
+	}
+	pageSize := listPageSize(req.PageSize)
+
+	tree, ctx, err := t.getTreeAndContext(ctx, req.MapId, optsMapRead)
+	if err != nil {
+		return err
+	}
+	tx, err := t.snapshotForTree(ctx, tree, "ExportMap")
+	if err != nil {
+		return err
+	}
+	defer t.closeAndLog(ctx, tree.TreeId, tx, "ExportMap")
+
+	// Fail early for revisions which don't exist yet.
+	if _, err := tx.GetSignedMapRoot(ctx, req.Revision); err != nil {
+		return err
+	}
+
+	// Revisions are contiguous, so each page starts where the previous one
+	// ended, once revision 0 is known to be there.
+	filter := storage.MapRootFilter{EndRevision: req.Revision + 1}
+	for filter.StartRevision < filter.EndRevision {
+		roots, err := tx.ListSignedMapRoots(ctx, filter, pageSize)
+		if err != nil {
+			return err
+		}
+		if filter.StartRevision == 0 {
+			var first types.MapRootV1
+			if len(roots) > 0 {
+				if err := first.UnmarshalBinary(roots[0].MapRoot); err != nil {
+					return err
+				}
+			}
+			if len(roots) == 0 || first.Revision != 0 {
+				return status.Errorf(codes.FailedPrecondition, "map %d can't be exported, as its early revisions were deleted", req.MapId)
+			}
+		}
+		if len(roots) == 0 {
+			break
+		}
+		if err := stream.Send(&trillian.ExportMapResponse{MapRoots: roots}); err != nil {
+			return err
+		}
+		filter.StartRevision += int64(len(roots))
+	}
+
+	var after []byte
+	for {
+		versions, err := tx.ListLeafVersions(ctx, after, pageSize)
+		if err != nil {
+			return err
+		}
+		resp := &trillian.ExportMapResponse{}
+		for _, v := range versions {
+			if v.Revision <= req.Revision {
+				resp.LeafVersions = append(resp.LeafVersions, &trillian.MapLeafVersion{Revision: v.Revision, Leaf: v.Leaf})
+			}
+		}
+		t.getLeafCounter.Add(float64(len(resp.LeafVersions)), strconv.FormatInt(req.MapId, 10))
+		if len(resp.LeafVersions) > 0 {
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+		if len(versions) == 0 {
+			break
+		}
+		after = versions[len(versions)-1].Leaf.Index
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		glog.Warningf("%v: Commit failed for ExportMap: %v", req.MapId, err)
+		return err
+	}
+	return nil
+}
+
+// ImportMapRevision implements the ImportMapRevision RPC method.
+func (t *TrillianMapServer) ImportMapRevision(ctx context.Context, req *trillian.ImportMapRevisionRequest) (*trillian.ImportMapRevisionResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "ImportMapRevision", treeAttr(req.MapId), batchAttr(len(req.Leaves)))
+	defer spanEnd()
+	if err := t.checkWritable("ImportMapRevision"); err != nil {
+		return nil, err
+	}
+	var exported types.MapRootV1
+	if err := exported.UnmarshalBinary(req.GetMapRoot().GetMapRoot()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "ImportMapRevisionRequest.MapRoot: %v", err)
+	}
+	rev := int64(exported.Revision)
+	opts := optsMapWrite
+	if rev == 0 {
+		if len(req.Leaves) > 0 {
+			return nil, status.Error(codes.InvalidArgument, "revision 0 of a map has no leaves")
+		}
+		// Importing revision 0 initialises the map.
+		opts = optsMapInit
+	}
+	tree, hasher, err := t.getTreeAndHasher(ctx, req.MapId, opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx = trees.NewContext(ctx, tree)
+	if err := validateIndices(hashers.IndexSize(hasher), len(req.Leaves), func(i int) []byte { return req.Leaves[i].Index }, repeatedField("leaves", ".index")); err != nil {
+		return nil, err
+	}
+	hkv := make([]merkle.HashKeyValue, 0, len(req.Leaves))
+	for _, l := range req.Leaves {
+		l.LeafHash = hasher.HashLeaf(tree.TreeId, l.Index, l.LeafValue)
+		hkv = append(hkv, merkle.HashKeyValue{HashedKey: l.Index, HashedValue: l.LeafHash})
+	}
+
+	var newRoot *trillian.SignedMapRoot
+	err = t.readWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		var rootHash []byte
+		if rev == 0 {
+			latestRoot, err := tx.LatestSignedMapRoot(ctx)
+			if err != nil && err != storage.ErrTreeNeedsInit {
+				return err
+			}
+			if latestRoot.GetMapRoot() != nil {
+				return errmsg.New(codes.AlreadyExists, errmsg.TreeAlreadyInitialized, errmsg.Params{"tree_type": "map"})
+			}
+			rootHash = hasher.HashEmpty(tree.TreeId, make([]byte, hashers.IndexSize(hasher)), hasher.BitLen())
+		} else {
+			if _, err := t.getWriteRevision(ctx, tree, tx, rev); err != nil {
+				return err
+			}
+			if err := t.writeLeaves(ctx, tree.TreeId, tx, req.Leaves); err != nil {
+				return err
+			}
+			var err error
+			if rootHash, err = t.calculateRoot(ctx, tree, hasher, tx, hkv, rev, t.opts.UseSingleTransaction); err != nil {
+				return err
+			}
+		}
+		if !bytes.Equal(rootHash, exported.RootHash) {
+			glog.Warningf("%v: imported revision %d has root hash %x, want %x", req.MapId, rev, rootHash, exported.RootHash)
+			return errmsg.New(codes.FailedPrecondition, errmsg.ImportedRootMismatch, errmsg.Params{"revision": rev})
+		}
+
+		// The root keeps its exported timestamp and metadata.
+		signer, err := trees.Signer(ctx, tree)
+		if err != nil {
+			return fmt.Errorf("trees.Signer(): %v", err)
+		}
+		if newRoot, err = signer.SignMapRoot(&exported); err != nil {
+			return fmt.Errorf("SignMapRoot(): %v", err)
+		}
+		return tx.StoreSignedMapRoot(ctx, newRoot)
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.notifier.notify(req.MapId)
+	return &trillian.ImportMapRevisionResponse{MapRoot: newRoot}, nil
+}
+
 func (t *TrillianMapServer) getTreeAndHasher(ctx context.Context, treeID int64, opts trees.GetOpts) (*trillian.Tree, hashers.MapHasher, error) {
 	tree, err := trees.GetTree(ctx, t.registry.AdminStorage, treeID, opts)
 	if err != nil {
 		return nil, nil, err
 	}
-	th, err := hashers.NewMapHasher(tree.HashStrategy)
+	th, err := t.registry.MapHashers.NewMapHasherForTree(tree)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -610,8 +2033,11 @@ func (t *TrillianMapServer) getTreeAndContext(ctx context.Context, treeID int64,
 
 // InitMap implements the RPC Method of the same name.
 func (t *TrillianMapServer) InitMap(ctx context.Context, req *trillian.InitMapRequest) (*trillian.InitMapResponse, error) {
-	ctx, spanEnd := spanFor(ctx, "InitMap")
+	ctx, spanEnd := spanFor(ctx, "InitMap", treeAttr(req.MapId))
 	defer spanEnd()
+	if err := t.checkWritable("InitMap"); err != nil {
+		return nil, err
+	}
 	mapID := req.MapId
 	tree, hasher, err := t.getTreeAndHasher(ctx, mapID, optsMapInit)
 	if err != nil {
@@ -620,7 +2046,7 @@ func (t *TrillianMapServer) InitMap(ctx context.Context, req *trillian.InitMapRe
 	ctx = trees.NewContext(ctx, tree)
 
 	var rev0Root *trillian.SignedMapRoot
-	err = t.registry.MapStorage.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+	err = t.readWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
 		// Check that the map actually needs initialising
 		latestRoot, err := tx.LatestSignedMapRoot(ctx)
 		if err != nil && err != storage.ErrTreeNeedsInit {
@@ -628,13 +2054,13 @@ func (t *TrillianMapServer) InitMap(ctx context.Context, req *trillian.InitMapRe
 		}
 		// Belt and braces check.
 		if latestRoot.GetMapRoot() != nil {
-			return status.Errorf(codes.AlreadyExists, "map is already initialised")
+			return errmsg.New(codes.AlreadyExists, errmsg.TreeAlreadyInitialized, errmsg.Params{"tree_type": "map"})
 		}
 
 		rev0Root = nil
 
 		glog.V(2).Infof("%v: Need to init map root revision 0", mapID)
-		rootHash := hasher.HashEmpty(mapID, make([]byte, hasher.Size()), hasher.BitLen())
+		rootHash := hasher.HashEmpty(mapID, make([]byte, hashers.IndexSize(hasher)), hasher.BitLen())
 		rev0Root, err = t.makeSignedMapRoot(ctx, tree, time.Now(), rootHash, mapID, 0 /*revision*/, nil /* metadata */)
 		if err != nil {
 			return fmt.Errorf("makeSignedMapRoot(): %v", err)
@@ -645,6 +2071,7 @@ func (t *TrillianMapServer) InitMap(ctx context.Context, req *trillian.InitMapRe
 	if err != nil {
 		return nil, err
 	}
+	t.notifier.notify(mapID)
 
 	return &trillian.InitMapResponse{
 		Created: rev0Root,
@@ -658,21 +2085,45 @@ func (t *TrillianMapServer) closeAndLog(ctx context.Context, logID int64, tx sto
 	}
 }
 
+// snapshotForTree returns a snapshot of tree, which decompresses the values of
+// the leaves read through it.
 func (t *TrillianMapServer) snapshotForTree(ctx context.Context, tree *trillian.Tree, method string) (storage.ReadOnlyMapTreeTX, error) {
+	codec, err := compression.ForTree(tree)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "compression.ForTree(): %v", err)
+	}
 	tx, err := t.registry.MapStorage.SnapshotForTree(ctx, tree)
 	if err != nil && tx != nil {
 		// Special case to handle ErrTreeNeedsInit, which leaves the TX open.
 		// To avoid leaking it make sure it's closed.
 		defer t.closeAndLog(ctx, tree.TreeId, tx, method)
 	}
-	return tx, err
+	if err != nil {
+		return tx, err
+	}
+	return compression.WrapReadOnlyTX(tx, codec), nil
+}
+
+// readWriteTransaction runs f in a read-write transaction on tree, which
+// compresses the values of the leaves set through it, and decompresses those
+// read.
+func (t *TrillianMapServer) readWriteTransaction(ctx context.Context, tree *trillian.Tree, f storage.MapTXFunc) error {
+	codec, err := compression.ForTree(tree)
+	if err != nil {
+		return status.Errorf(codes.FailedPrecondition, "compression.ForTree(): %v", err)
+	}
+	return t.registry.MapStorage.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		return f(ctx, compression.WrapTX(tx, codec))
+	})
 }
 
 // validateIndices confirms that all indices have the given size and there are no duplicates.
 // indexSize is the expected size of each index in bytes.
 // n is the number of indices to check.
 // indices is a function that returns indices from [0 .. n).
-func validateIndices(indexSize, n int, indices func(i int) []byte) error {
+// field is a function that returns the path of the request field holding each index.
+// All the invalid indices are reported in the BadRequest detail of the error.
+func validateIndices(indexSize, n int, indices func(i int) []byte, field func(i int) string) error {
 	// The parameter is named 'index' (here and in the RPC API) because it's the ordinal number
 	// of the leaf, but that number is obtained by hashing the key value that corresponds to the
 	// leaf.  Leaf "indices" are therefore sparsely scattered in the range [0, 2^hashsize) and
@@ -681,16 +2132,31 @@ func validateIndices(indexSize, n int, indices func(i int) []byte) error {
 	// We currently police this by requiring that the hash size for the index space be the same
 	// as the hash size for the tree itself, although that's not strictly required (e.g. could
 	// have SHA-256 for generating leaf indices, but SHA-512 for building the root hash).
+	var violations []errmsg.FieldViolation
 	seenIndices := make(map[string]bool)
 	for i := 0; i < n; i++ {
 		index := indices(i)
 		if got, want := len(index), indexSize; got != want {
-			return status.Errorf(codes.InvalidArgument, "index at position %d has wrong length: got=%d,want=%d", i, got, want)
+			violations = append(violations, errmsg.FieldViolation{Field: field(i), Reason: errmsg.MapIndexWrongSize, Params: errmsg.Params{"position": i, "got": got, "want": want}})
+			continue
 		}
 		if seenIndices[string(index)] {
-			return status.Errorf(codes.InvalidArgument, "duplicate index detected at position %d", i)
+			violations = append(violations, errmsg.FieldViolation{Field: field(i), Reason: errmsg.DuplicateMapIndex, Params: errmsg.Params{"position": i}})
+			continue
 		}
 		seenIndices[string(index)] = true
 	}
+	if len(violations) > 0 {
+		return errmsg.NewBadRequest(violations)
+	}
 	return nil
 }
+
+// repeatedField returns a function returning the path of the field named
+// suffix of the i-th element of the repeated request field name, e.g.
+// "leaves[3].index".
+func repeatedField(name, suffix string) func(i int) string {
+	return func(i int) string {
+		return fmt.Sprintf("%s[%d]%s", name, i, suffix)
+	}
+}
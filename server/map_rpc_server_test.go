@@ -15,54 +15,46 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keys/der"
+	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/extension"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/merkle/maphasher"
+	"github.com/google/trillian/server/errmsg"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/compression"
+	"github.com/google/trillian/storage/storagepb"
 	stestonly "github.com/google/trillian/storage/testonly"
+	"github.com/google/trillian/types"
 	"github.com/kylelemons/godebug/pretty"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+
+	tcrypto "github.com/google/trillian/crypto"
 )
 
 const mapID1 = int64(1)
 
-func TestIsHealthy(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-
-	tests := []struct {
-		desc          string
-		accessibleErr error
-	}{
-		{"healthy", nil},
-		{"unhealthy", errors.New("DB not happy")},
-	}
-
-	opts := TrillianMapServerOptions{}
-
-	for _, test := range tests {
-		fakeStorage := storage.NewMockMapStorage(ctrl)
-		fakeStorage.EXPECT().CheckDatabaseAccessible(gomock.Any()).Return(test.accessibleErr)
-
-		server := NewTrillianMapServer(extension.Registry{
-			AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
-			MapStorage:   fakeStorage,
-		}, opts)
-
-		wantErr := test.accessibleErr != nil
-		err := server.IsHealthy()
-		if gotErr := err != nil; gotErr != wantErr {
-			t.Errorf("%s: IsHealthy() err? %t want? %t (err=%v)", test.desc, gotErr, wantErr, err)
-		}
-	}
-}
-
 func TestInitMap(t *testing.T) {
 	ctx := context.Background()
 
@@ -336,69 +328,1556 @@ func TestGetSignedMapRootByRevision(t *testing.T) {
 	}
 }
 
-func fakeAdminStorageForMap(ctrl *gomock.Controller, times int, treeID int64) storage.AdminStorage {
-	tree := proto.Clone(stestonly.MapTree).(*trillian.Tree)
-	tree.TreeId = treeID
+func TestGetLeavesByRevisionNoProof_MapRoot(t *testing.T) {
+	ctx := context.Background()
+	index := make([]byte, 32)
+	mapRoot := &trillian.SignedMapRoot{MapRoot: []byte("root"), Signature: []byte("signature")}
 
-	adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
-	adminStorage := &stestonly.FakeAdminStorage{
-		ReadOnlyTX: []storage.ReadOnlyAdminTX{adminTX},
+	for _, test := range []struct {
+		desc     string
+		include  bool
+		rootErr  error
+		wantRoot *trillian.SignedMapRoot
+		wantErr  bool
+	}{
+		{desc: "without root"},
+		{desc: "with root", include: true, wantRoot: mapRoot},
+		{desc: "unpublished revision", include: true, rootErr: errors.New("sql: no rows in result set"), wantErr: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockTX := storage.NewMockMapTreeTX(ctrl)
+			mockTX.EXPECT().Get(gomock.Any(), int64(2), [][]byte{index}).Return(
+				[]*trillian.MapLeaf{{Index: index, LeafValue: []byte("value"), LeafHash: []byte("hash")}}, nil)
+			if test.include {
+				mockTX.EXPECT().GetSignedMapRoot(gomock.Any(), int64(2)).Return(test.wantRoot, test.rootErr)
+			}
+			mockTX.EXPECT().Close().Return(nil)
+			mockTX.EXPECT().IsOpen().AnyTimes().Return(false)
+
+			server := NewTrillianMapServer(extension.Registry{
+				AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+				MapStorage:   &stestonly.FakeMapStorage{ReadOnlyTX: mockTX},
+			}, TrillianMapServerOptions{})
+
+			rsp, err := server.GetLeavesByRevisionNoProof(ctx, &trillian.GetMapLeavesByRevisionRequest{
+				MapId:          mapID1,
+				Index:          [][]byte{index},
+				Revision:       2,
+				IncludeMapRoot: test.include,
+			})
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("GetLeavesByRevisionNoProof()=_, err? %t want? %t (err=%v)", gotErr, test.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			want := &trillian.MapLeaves{
+				Leaves:  []*trillian.MapLeaf{{Index: index, LeafValue: []byte("value")}},
+				MapRoot: test.wantRoot,
+			}
+			if !proto.Equal(rsp, want) {
+				t.Errorf("GetLeavesByRevisionNoProof()=%v, want %v", rsp, want)
+			}
+		})
 	}
+}
 
-	adminTX.EXPECT().GetTree(gomock.Any(), treeID).MaxTimes(times).Return(tree, nil)
-	adminTX.EXPECT().Close().MaxTimes(times).Return(nil)
-	adminTX.EXPECT().Commit().MaxTimes(times).Return(nil)
+// fakeListLeavesStream records the responses sent by ListLeavesByRevision.
+type fakeListLeavesStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	resps []*trillian.ListMapLeavesByRevisionResponse
+}
 
-	return adminStorage
+func (s *fakeListLeavesStream) Context() context.Context {
+	return s.ctx
 }
 
-func TestRequestIndexValidator(t *testing.T) {
+func (s *fakeListLeavesStream) Send(resp *trillian.ListMapLeavesByRevisionResponse) error {
+	s.resps = append(s.resps, resp)
+	return nil
+}
+
+func TestListLeavesByRevision(t *testing.T) {
+	ctx := context.Background()
+	mapRoot := &trillian.SignedMapRoot{Signature: []byte("notempty")}
+	index := func(b byte) []byte { return append(bytes.Repeat([]byte{0}, 31), b) }
+	leaf := func(b byte) *trillian.MapLeaf {
+		return &trillian.MapLeaf{Index: index(b), LeafValue: []byte{b}}
+	}
+	cleared := func(b byte) *trillian.MapLeaf {
+		return &trillian.MapLeaf{Index: index(b)}
+	}
+	listErr := errors.New("list failed")
+
+	type listCall struct {
+		after  []byte
+		leaves []*trillian.MapLeaf
+		err    error
+	}
 	tests := []struct {
 		desc      string
-		indexSize int
-		indices   [][]byte
+		req       *trillian.ListMapLeavesByRevisionRequest
+		smrErr    error
+		calls     []listCall
+		want      []*trillian.ListMapLeavesByRevisionResponse
+		wantCode  codes.Code
 		wantErr   bool
+		noStorage bool
 	}{
 		{
-			desc:      "Single index of correct length",
-			indexSize: 1,
-			indices:   [][]byte{{'a'}},
+			desc:      "negative revision",
+			req:       &trillian.ListMapLeavesByRevisionRequest{MapId: mapID1, Revision: -1},
+			wantErr:   true,
+			wantCode:  codes.InvalidArgument,
+			noStorage: true,
 		},
 		{
-			desc:      "Single index of longer correct length",
-			indexSize: 4,
-			indices:   [][]byte{{'a', 'b', 'c', 'd'}},
+			desc:      "negative page size",
+			req:       &trillian.ListMapLeavesByRevisionRequest{MapId: mapID1, Revision: 1, PageSize: -1},
+			wantErr:   true,
+			wantCode:  codes.InvalidArgument,
+			noStorage: true,
 		},
 		{
-			desc:      "Single index too long",
-			indexSize: 1,
-			indices:   [][]byte{{'a', 'b'}},
+			desc:      "bad page token",
+			req:       &trillian.ListMapLeavesByRevisionRequest{MapId: mapID1, Revision: 1, PageToken: leafPageToken([]byte("short"))},
 			wantErr:   true,
+			wantCode:  codes.InvalidArgument,
+			noStorage: true,
 		},
 		{
-			desc:      "Single index too short",
-			indexSize: 2,
-			indices:   [][]byte{{'a'}},
-			wantErr:   true,
+			desc:    "unknown revision",
+			req:     &trillian.ListMapLeavesByRevisionRequest{MapId: mapID1, Revision: 7},
+			smrErr:  status.Error(codes.NotFound, "no such revision"),
+			wantErr: true,
 		},
 		{
-			desc:      "Multiple indices of correct length & no duplicates",
-			indexSize: 1,
-			indices:   [][]byte{{'a'}, {'b'}},
+			desc:  "empty map",
+			req:   &trillian.ListMapLeavesByRevisionRequest{MapId: mapID1, Revision: 1, PageSize: 2},
+			calls: []listCall{{}},
+			want:  []*trillian.ListMapLeavesByRevisionResponse{{MapRoot: mapRoot}},
 		},
 		{
-			desc:      "Multiple indices of correct length with duplicates",
-			indexSize: 1,
-			indices:   [][]byte{{'a'}, {'a'}},
-			wantErr:   true,
+			desc: "multiple pages",
+			req:  &trillian.ListMapLeavesByRevisionRequest{MapId: mapID1, Revision: 1, PageSize: 2},
+			calls: []listCall{
+				{leaves: []*trillian.MapLeaf{leaf(1), leaf(2), leaf(3)}},
+				{after: index(3)},
+			},
+			want: []*trillian.ListMapLeavesByRevisionResponse{
+				{MapRoot: mapRoot, Leaves: []*trillian.MapLeaf{leaf(1), leaf(2)}, NextPageToken: leafPageToken(index(2))},
+				{Leaves: []*trillian.MapLeaf{leaf(3)}},
+			},
+		},
+		{
+			desc: "exact page",
+			req:  &trillian.ListMapLeavesByRevisionRequest{MapId: mapID1, Revision: 1, PageSize: 1},
+			calls: []listCall{
+				{leaves: []*trillian.MapLeaf{leaf(1)}},
+			},
+			want: []*trillian.ListMapLeavesByRevisionResponse{
+				{MapRoot: mapRoot, Leaves: []*trillian.MapLeaf{leaf(1)}},
+			},
+		},
+		{
+			desc: "exact pages",
+			req:  &trillian.ListMapLeavesByRevisionRequest{MapId: mapID1, Revision: 1, PageSize: 1},
+			calls: []listCall{
+				{leaves: []*trillian.MapLeaf{leaf(1), leaf(2)}},
+				{after: index(2)},
+			},
+			want: []*trillian.ListMapLeavesByRevisionResponse{
+				{MapRoot: mapRoot, Leaves: []*trillian.MapLeaf{leaf(1)}, NextPageToken: leafPageToken(index(1))},
+				{Leaves: []*trillian.MapLeaf{leaf(2)}},
+			},
+		},
+		{
+			desc: "cleared leaves",
+			req:  &trillian.ListMapLeavesByRevisionRequest{MapId: mapID1, Revision: 1, PageSize: 2},
+			calls: []listCall{
+				{leaves: []*trillian.MapLeaf{leaf(1), cleared(2), leaf(3)}},
+				{after: index(3), leaves: []*trillian.MapLeaf{cleared(4), leaf(5), cleared(6)}},
+				{after: index(6), leaves: []*trillian.MapLeaf{cleared(7)}},
+			},
+			want: []*trillian.ListMapLeavesByRevisionResponse{
+				{MapRoot: mapRoot, Leaves: []*trillian.MapLeaf{leaf(1), leaf(3)}, NextPageToken: leafPageToken(index(3))},
+				{Leaves: []*trillian.MapLeaf{leaf(5)}},
+			},
+		},
+		{
+			desc: "only cleared leaves",
+			req:  &trillian.ListMapLeavesByRevisionRequest{MapId: mapID1, Revision: 1, PageSize: 2},
+			calls: []listCall{
+				{leaves: []*trillian.MapLeaf{cleared(1), cleared(2)}},
+			},
+			want: []*trillian.ListMapLeavesByRevisionResponse{{MapRoot: mapRoot}},
+		},
+		{
+			desc: "resume from token",
+			req:  &trillian.ListMapLeavesByRevisionRequest{MapId: mapID1, Revision: 1, PageSize: 2, PageToken: leafPageToken(index(2))},
+			calls: []listCall{
+				{after: index(2), leaves: []*trillian.MapLeaf{leaf(3)}},
+			},
+			want: []*trillian.ListMapLeavesByRevisionResponse{
+				{MapRoot: mapRoot, Leaves: []*trillian.MapLeaf{leaf(3)}},
+			},
+		},
+		{
+			desc:    "list error",
+			req:     &trillian.ListMapLeavesByRevisionRequest{MapId: mapID1, Revision: 1, PageSize: 2},
+			calls:   []listCall{{err: listErr}},
+			wantErr: true,
 		},
 	}
-	for _, tt := range tests {
-		t.Run(tt.desc, func(t *testing.T) {
-			err := validateIndices(tt.indexSize, len(tt.indices), func(i int) []byte { return tt.indices[i] })
 
-			if (err != nil) != tt.wantErr {
-				t.Errorf("validateIndices() error = %v, wantErr %v", err, tt.wantErr)
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			adminStorage := fakeAdminStorageForMap(ctrl, 1, mapID1)
+			fakeStorage := storage.NewMockMapStorage(ctrl)
+			mockTX := storage.NewMockMapTreeTX(ctrl)
+
+			if !test.noStorage {
+				fakeStorage.EXPECT().SnapshotForTree(gomock.Any(), gomock.Any()).Return(mockTX, nil)
+				mockTX.EXPECT().GetSignedMapRoot(gomock.Any(), test.req.Revision).Return(mapRoot, test.smrErr)
+				for _, c := range test.calls {
+					// One more leaf than a page is read, to tell whether
+					// another page follows.
+					mockTX.EXPECT().List(gomock.Any(), test.req.Revision, c.after, int(test.req.PageSize)+1).Return(c.leaves, c.err)
+				}
+				if !test.wantErr {
+					mockTX.EXPECT().Commit(gomock.Any()).Return(nil)
+				}
+				mockTX.EXPECT().Close().Return(nil)
+				mockTX.EXPECT().IsOpen().AnyTimes().Return(false)
+			}
+
+			server := NewTrillianMapServer(extension.Registry{
+				AdminStorage: adminStorage,
+				MapStorage:   fakeStorage,
+			}, TrillianMapServerOptions{})
+
+			stream := &fakeListLeavesStream{ctx: ctx}
+			err := server.ListLeavesByRevision(test.req, stream)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("ListLeavesByRevision()=%v, want err? %t", err, test.wantErr)
+			}
+			if err != nil {
+				if test.wantCode != codes.OK {
+					if got := status.Code(err); got != test.wantCode {
+						t.Errorf("ListLeavesByRevision()=%v, want code %v", err, test.wantCode)
+					}
+				}
+				return
+			}
+			if got, want := len(stream.resps), len(test.want); got != want {
+				t.Fatalf("ListLeavesByRevision() sent %d responses, want %d", got, want)
+			}
+			for i, got := range stream.resps {
+				if want := test.want[i]; !proto.Equal(got, want) {
+					diff := pretty.Compare(got, want)
+					t.Errorf("ListLeavesByRevision() response %d diff:\n%v", i, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestListSignedMapRoots(t *testing.T) {
+	ctx := context.Background()
+	root := func(rev uint64) *trillian.SignedMapRoot {
+		mapRoot, err := (&types.MapRootV1{Revision: rev, TimestampNanos: rev * 1000}).MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary(): %v", err)
+		}
+		return &trillian.SignedMapRoot{MapRoot: mapRoot}
+	}
+
+	tests := []struct {
+		desc       string
+		req        *trillian.ListSignedMapRootsRequest
+		wantFilter storage.MapRootFilter
+		wantLimit  int
+		roots      []*trillian.SignedMapRoot
+		want       *trillian.ListSignedMapRootsResponse
+		wantCode   codes.Code
+		noStorage  bool
+	}{
+		{
+			desc:      "negative revision",
+			req:       &trillian.ListSignedMapRootsRequest{MapId: mapID1, StartRevision: -1},
+			wantCode:  codes.InvalidArgument,
+			noStorage: true,
+		},
+		{
+			desc:      "negative timestamp",
+			req:       &trillian.ListSignedMapRootsRequest{MapId: mapID1, EndTimestampNanos: -1},
+			wantCode:  codes.InvalidArgument,
+			noStorage: true,
+		},
+		{
+			desc:      "negative page size",
+			req:       &trillian.ListSignedMapRootsRequest{MapId: mapID1, PageSize: -1},
+			wantCode:  codes.InvalidArgument,
+			noStorage: true,
+		},
+		{
+			desc:      "bad page token",
+			req:       &trillian.ListSignedMapRootsRequest{MapId: mapID1, PageToken: "x"},
+			wantCode:  codes.InvalidArgument,
+			noStorage: true,
+		},
+		{
+			desc:      "default page size",
+			req:       &trillian.ListSignedMapRootsRequest{MapId: mapID1},
+			wantLimit: defaultRootListPageSize,
+			roots:     []*trillian.SignedMapRoot{root(0), root(1)},
+			want:      &trillian.ListSignedMapRootsResponse{MapRoots: []*trillian.SignedMapRoot{root(0), root(1)}},
+		},
+		{
+			desc: "filters",
+			req: &trillian.ListSignedMapRootsRequest{
+				MapId:               mapID1,
+				StartRevision:       1,
+				EndRevision:         10,
+				StartTimestampNanos: 1000,
+				EndTimestampNanos:   5000,
+				PageSize:            2,
+			},
+			wantFilter: storage.MapRootFilter{
+				StartRevision: 1,
+				EndRevision:   10,
+				StartTime:     time.Unix(0, 1000),
+				EndTime:       time.Unix(0, 5000),
+			},
+			wantLimit: 2,
+			roots:     []*trillian.SignedMapRoot{root(1), root(2)},
+			want:      &trillian.ListSignedMapRootsResponse{MapRoots: []*trillian.SignedMapRoot{root(1), root(2)}, NextPageToken: "2"},
+		},
+		{
+			desc:       "resume ascending",
+			req:        &trillian.ListSignedMapRootsRequest{MapId: mapID1, StartRevision: 1, PageSize: 2, PageToken: "2"},
+			wantFilter: storage.MapRootFilter{StartRevision: 3},
+			wantLimit:  2,
+			roots:      []*trillian.SignedMapRoot{root(3)},
+			want:       &trillian.ListSignedMapRootsResponse{MapRoots: []*trillian.SignedMapRoot{root(3)}},
+		},
+		{
+			desc:       "newest first",
+			req:        &trillian.ListSignedMapRootsRequest{MapId: mapID1, NewestFirst: true, PageSize: 2},
+			wantFilter: storage.MapRootFilter{Descending: true},
+			wantLimit:  2,
+			roots:      []*trillian.SignedMapRoot{root(5), root(4)},
+			want:       &trillian.ListSignedMapRootsResponse{MapRoots: []*trillian.SignedMapRoot{root(5), root(4)}, NextPageToken: "4"},
+		},
+		{
+			desc:       "resume descending",
+			req:        &trillian.ListSignedMapRootsRequest{MapId: mapID1, NewestFirst: true, EndRevision: 10, PageSize: 2, PageToken: "4"},
+			wantFilter: storage.MapRootFilter{EndRevision: 4, Descending: true},
+			wantLimit:  2,
+			roots:      []*trillian.SignedMapRoot{root(1), root(0)},
+			want:       &trillian.ListSignedMapRootsResponse{MapRoots: []*trillian.SignedMapRoot{root(1), root(0)}},
+		},
+		{
+			desc:      "descending past revision 0",
+			req:       &trillian.ListSignedMapRootsRequest{MapId: mapID1, NewestFirst: true, PageToken: "0"},
+			want:      &trillian.ListSignedMapRootsResponse{},
+			noStorage: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			adminStorage := fakeAdminStorageForMap(ctrl, 1, mapID1)
+			fakeStorage := storage.NewMockMapStorage(ctrl)
+			if !test.noStorage {
+				mockTX := storage.NewMockMapTreeTX(ctrl)
+				fakeStorage.EXPECT().SnapshotForTree(gomock.Any(), gomock.Any()).Return(mockTX, nil)
+				mockTX.EXPECT().ListSignedMapRoots(gomock.Any(), test.wantFilter, test.wantLimit).Return(test.roots, nil)
+				mockTX.EXPECT().Commit(gomock.Any()).Return(nil)
+				mockTX.EXPECT().Close().Return(nil)
+				mockTX.EXPECT().IsOpen().AnyTimes().Return(false)
+			}
+
+			server := NewTrillianMapServer(extension.Registry{
+				AdminStorage: adminStorage,
+				MapStorage:   fakeStorage,
+			}, TrillianMapServerOptions{})
+
+			got, err := server.ListSignedMapRoots(ctx, test.req)
+			if gotCode := status.Code(err); gotCode != test.wantCode {
+				t.Fatalf("ListSignedMapRoots()=%v, want code %v", err, test.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if !proto.Equal(got, test.want) {
+				diff := pretty.Compare(got, test.want)
+				t.Errorf("ListSignedMapRoots() diff:\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestLeavesPageToken(t *testing.T) {
+	token := leavesPageToken(7, 2)
+	rev, offset, err := parseLeavesPageToken(token, 3)
+	if err != nil {
+		t.Fatalf("parseLeavesPageToken(%q): %v", token, err)
+	}
+	if rev != 7 || offset != 2 {
+		t.Errorf("parseLeavesPageToken(%q)=%d, %d, want 7, 2", token, rev, offset)
+	}
+
+	for _, token := range []string{"", "7", "x:2", "7:x", "-1:2", "7:0", "7:3", "7:2:1"} {
+		if _, _, err := parseLeavesPageToken(token, 3); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("parseLeavesPageToken(%q)=%v, want code %v", token, err, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestFitInclusions(t *testing.T) {
+	inc := func(i byte) *trillian.MapLeafInclusion {
+		return &trillian.MapLeafInclusion{
+			Leaf:      &trillian.MapLeaf{Index: bytes.Repeat([]byte{i}, 32)},
+			Inclusion: [][]byte{bytes.Repeat([]byte{i}, 32)},
+		}
+	}
+	resp := &trillian.GetMapLeavesResponse{
+		MapRoot:          &trillian.SignedMapRoot{MapRoot: []byte("root")},
+		MapLeafInclusion: []*trillian.MapLeafInclusion{inc(1), inc(2), inc(3)},
+	}
+	total := proto.Size(resp)
+
+	for _, test := range []struct {
+		maxBytes int
+		want     int
+	}{
+		{maxBytes: 1, want: 1},
+		{maxBytes: total - 1, want: 2},
+		{maxBytes: total, want: 3},
+	} {
+		if got := len(fitInclusions(resp, test.maxBytes)); got != test.want {
+			t.Errorf("fitInclusions(%d): got %d inclusions, want %d", test.maxBytes, got, test.want)
+		}
+	}
+}
+
+func TestGetLeafHistory_Invalid(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := NewTrillianMapServer(extension.Registry{
+		AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+		MapStorage:   storage.NewMockMapStorage(ctrl),
+	}, TrillianMapServerOptions{})
+
+	for _, test := range []struct {
+		desc string
+		req  *trillian.GetMapLeafHistoryRequest
+	}{
+		{desc: "negative revision", req: &trillian.GetMapLeafHistoryRequest{MapId: mapID1, Index: make([]byte, 32), StartRevision: -1}},
+		{desc: "negative count", req: &trillian.GetMapLeafHistoryRequest{MapId: mapID1, Index: make([]byte, 32), Count: -1}},
+		{desc: "short index", req: &trillian.GetMapLeafHistoryRequest{MapId: mapID1, Index: make([]byte, 31)}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := server.GetLeafHistory(ctx, test.req)
+			if got, want := status.Code(err), codes.InvalidArgument; got != want {
+				t.Errorf("GetLeafHistory()=%v, want code %v", err, want)
+			}
+		})
+	}
+}
+
+func TestGetLeafByHash(t *testing.T) {
+	ctx := context.Background()
+	index, leafHash := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	rootBytes, err := (&types.MapRootV1{Revision: 2}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary(): %v", err)
+	}
+	root := &trillian.SignedMapRoot{MapRoot: rootBytes}
+
+	for _, test := range []struct {
+		desc       string
+		revision   int64
+		indexes    [][]byte
+		indexErr   error
+		wantLeaves int
+		wantCode   codes.Code
+		wantReason errmsg.Reason
+	}{
+		{desc: "latest", indexes: [][]byte{index}, wantLeaves: 1},
+		{desc: "byRevision", revision: 2, indexes: [][]byte{index}, wantLeaves: 1},
+		{desc: "notFound"},
+		{desc: "noIndex", indexErr: storage.ErrNoLeafHashIndex, wantCode: codes.FailedPrecondition, wantReason: errmsg.LeafHashIndexDisabled},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tx := storage.NewMockReadOnlyMapTreeTX(ctrl)
+			if test.revision == 0 {
+				tx.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(root, nil)
+			} else {
+				tx.EXPECT().GetSignedMapRoot(gomock.Any(), test.revision).Return(root, nil)
+			}
+			tx.EXPECT().GetIndexesByLeafHash(gomock.Any(), int64(2), leafHash).Return(test.indexes, test.indexErr)
+			if len(test.indexes) > 0 {
+				tx.EXPECT().Get(gomock.Any(), int64(2), test.indexes).Return([]*trillian.MapLeaf{{Index: index, LeafValue: []byte("value"), LeafHash: leafHash}}, nil)
+				tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(2), gomock.Any()).AnyTimes().Return(nil, nil)
+			}
+			if test.wantCode == codes.OK {
+				tx.EXPECT().Commit(gomock.Any()).Return(nil)
+			}
+			tx.EXPECT().Close().Return(nil)
+
+			server := NewTrillianMapServer(extension.Registry{
+				AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+				MapStorage:   &stestonly.FakeMapStorage{ReadOnlyTX: tx},
+			}, TrillianMapServerOptions{})
+
+			resp, err := server.GetLeafByHash(ctx, &trillian.GetMapLeafByHashRequest{MapId: mapID1, LeafHash: leafHash, Revision: test.revision})
+			if got := status.Code(err); got != test.wantCode {
+				t.Fatalf("GetLeafByHash()=%v, want code %v", err, test.wantCode)
+			}
+			if err != nil {
+				if got := errmsg.Reason(errmsg.Info(err).GetReason()); got != test.wantReason {
+					t.Errorf("GetLeafByHash() reason=%v, want %v", got, test.wantReason)
+				}
+				return
+			}
+			if !proto.Equal(resp.MapRoot, root) {
+				t.Errorf("GetLeafByHash().MapRoot=%v, want %v", resp.MapRoot, root)
+			}
+			if got := len(resp.MapLeafInclusion); got != test.wantLeaves {
+				t.Fatalf("GetLeafByHash() returned %d leaves, want %d", got, test.wantLeaves)
+			}
+			if got := resp.MapLeafInclusion; len(got) > 0 && !bytes.Equal(got[0].Leaf.LeafHash, leafHash) {
+				t.Errorf("GetLeafByHash() LeafHash=%x, want %x", got[0].Leaf.LeafHash, leafHash)
+			}
+		})
+	}
+}
+
+func TestGetLeafByHash_Invalid(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := NewTrillianMapServer(extension.Registry{
+		AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+		MapStorage:   storage.NewMockMapStorage(ctrl),
+	}, TrillianMapServerOptions{})
+
+	for _, test := range []struct {
+		desc string
+		req  *trillian.GetMapLeafByHashRequest
+	}{
+		{desc: "empty hash", req: &trillian.GetMapLeafByHashRequest{MapId: mapID1}},
+		{desc: "negative revision", req: &trillian.GetMapLeafByHashRequest{MapId: mapID1, LeafHash: make([]byte, 32), Revision: -1}},
+		{desc: "short hash", req: &trillian.GetMapLeafByHashRequest{MapId: mapID1, LeafHash: make([]byte, 31)}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := server.GetLeafByHash(ctx, test.req)
+			if got, want := status.Code(err), codes.InvalidArgument; got != want {
+				t.Errorf("GetLeafByHash()=%v, want code %v", err, want)
+			}
+		})
+	}
+}
+
+func TestGetConsistencyProof_Invalid(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := NewTrillianMapServer(extension.Registry{
+		AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+		MapStorage:   storage.NewMockMapStorage(ctrl),
+	}, TrillianMapServerOptions{})
+
+	index := make([]byte, 32)
+	for _, test := range []struct {
+		desc string
+		req  *trillian.GetMapConsistencyProofRequest
+	}{
+		{desc: "negative revision", req: &trillian.GetMapConsistencyProofRequest{MapId: mapID1, FirstRevision: -1, Index: [][]byte{index}}},
+		{desc: "revisions out of order", req: &trillian.GetMapConsistencyProofRequest{MapId: mapID1, FirstRevision: 2, SecondRevision: 1, Index: [][]byte{index}}},
+		{desc: "short index", req: &trillian.GetMapConsistencyProofRequest{MapId: mapID1, SecondRevision: 1, Index: [][]byte{index[1:]}}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := server.GetConsistencyProof(ctx, test.req)
+			if got, want := status.Code(err), codes.InvalidArgument; got != want {
+				t.Errorf("GetConsistencyProof()=%v, want code %v", err, want)
+			}
+		})
+	}
+}
+
+func TestSetMultiMapLeaves_Invalid(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := NewTrillianMapServer(extension.Registry{
+		AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+		MapStorage:   storage.NewMockMapStorage(ctrl),
+	}, TrillianMapServerOptions{})
+
+	for _, test := range []struct {
+		desc string
+		req  *trillian.SetMultiMapLeavesRequest
+	}{
+		{desc: "no requests", req: &trillian.SetMultiMapLeavesRequest{}},
+		{
+			desc: "duplicate map",
+			req: &trillian.SetMultiMapLeavesRequest{Requests: []*trillian.SetMapLeavesRequest{
+				{MapId: mapID1},
+				{MapId: mapID1},
+			}},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := server.SetMultiMapLeaves(ctx, test.req)
+			if got, want := status.Code(err), codes.InvalidArgument; got != want {
+				t.Errorf("SetMultiMapLeaves()=%v, want code %v", err, want)
+			}
+		})
+	}
+}
+
+func TestSetMultiMapLeaves_RevisionMismatch(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const mapID2 = mapID1 + 1
+	var adminTXs []storage.ReadOnlyAdminTX
+	for _, id := range []int64{mapID1, mapID2} {
+		tree := proto.Clone(stestonly.MapTree).(*trillian.Tree)
+		tree.TreeId = id
+		adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+		adminTX.EXPECT().GetTree(gomock.Any(), id).Return(tree, nil)
+		adminTX.EXPECT().Close().Return(nil)
+		adminTX.EXPECT().Commit().Return(nil)
+		adminTXs = append(adminTXs, adminTX)
+	}
+
+	// The first map is not at the requested revision, so neither map must be
+	// written, and the transaction must not be committed.
+	tx1 := storage.NewMockMapTreeTX(ctrl)
+	tx1.EXPECT().WriteRevision(gomock.Any()).Return(int64(5), nil)
+	tx1.EXPECT().Close().Return(nil)
+	tx2 := storage.NewMockMapTreeTX(ctrl)
+	tx2.EXPECT().Close().Return(nil)
+
+	server := NewTrillianMapServer(extension.Registry{
+		AdminStorage: &stestonly.FakeAdminStorage{ReadOnlyTX: adminTXs},
+		MapStorage:   &stestonly.FakeMapStorage{MultiTX: []storage.MapTreeTX{tx1, tx2}},
+	}, TrillianMapServerOptions{})
+
+	_, err := server.SetMultiMapLeaves(ctx, &trillian.SetMultiMapLeavesRequest{
+		Requests: []*trillian.SetMapLeavesRequest{
+			{MapId: mapID1, Revision: 2},
+			{MapId: mapID2, Revision: 2},
+		},
+	})
+	if got, want := status.Code(err), codes.FailedPrecondition; got != want {
+		t.Errorf("SetMultiMapLeaves()=%v, want code %v", err, want)
+	}
+}
+
+func TestSetLeaves_IdempotencyToken(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	token := []byte("token")
+	root := &trillian.SignedMapRoot{MapRoot: []byte("root"), Signature: []byte("sig")}
+
+	// The token was stored by a previous request which created revision 2, so
+	// the retried request must not be written at the current revision 5.
+	tx := storage.NewMockMapTreeTX(ctrl)
+	tx.EXPECT().GetIdempotencyToken(gomock.Any(), token).Return(int64(2), true, nil)
+	tx.EXPECT().GetSignedMapRoot(gomock.Any(), int64(2)).Return(root, nil)
+	tx.EXPECT().Commit(gomock.Any()).Return(nil)
+	tx.EXPECT().Close().Return(nil)
+
+	server := NewTrillianMapServer(extension.Registry{
+		AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+		MapStorage:   &stestonly.FakeMapStorage{TX: tx},
+	}, TrillianMapServerOptions{})
+
+	rsp, err := server.SetLeaves(ctx, &trillian.SetMapLeavesRequest{
+		MapId:            mapID1,
+		Leaves:           []*trillian.MapLeaf{{Index: make([]byte, 32), LeafValue: []byte("value")}},
+		Revision:         2,
+		IdempotencyToken: token,
+	})
+	if err != nil {
+		t.Fatalf("SetLeaves(): %v", err)
+	}
+	if got, want := rsp.MapRoot, root; !proto.Equal(got, want) {
+		t.Errorf("SetLeaves().MapRoot=%v, want %v", got, want)
+	}
+}
+
+func TestSetLeaves_DryRun(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The leaves and Merkle nodes are written in the transaction, which must
+	// not be committed, and no root is stored.
+	tx := storage.NewMockMapTreeTX(ctrl)
+	tx.EXPECT().WriteRevision(gomock.Any()).Return(int64(3), nil)
+	tx.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil, nil)
+	tx.EXPECT().SetMerkleNodes(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	tx.EXPECT().Close().Return(nil)
+
+	server := NewTrillianMapServer(extension.Registry{
+		AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+		MapStorage:   &stestonly.FakeMapStorage{TX: tx},
+	}, TrillianMapServerOptions{})
+
+	rsp, err := server.SetLeaves(ctx, &trillian.SetMapLeavesRequest{
+		MapId:    mapID1,
+		Leaves:   []*trillian.MapLeaf{{Index: make([]byte, 32), LeafValue: []byte("value")}},
+		Metadata: []byte("meta"),
+		DryRun:   true,
+	})
+	if err != nil {
+		t.Fatalf("SetLeaves(): %v", err)
+	}
+	if got := rsp.MapRoot.Signature; len(got) != 0 {
+		t.Errorf("SetLeaves().MapRoot.Signature=%x, want unsigned", got)
+	}
+	var root types.MapRootV1
+	if err := root.UnmarshalBinary(rsp.MapRoot.MapRoot); err != nil {
+		t.Fatalf("UnmarshalBinary(): %v", err)
+	}
+	if got, want := root.Revision, uint64(3); got != want {
+		t.Errorf("SetLeaves() root revision=%v, want %v", got, want)
+	}
+	if got, want := string(root.Metadata), "meta"; got != want {
+		t.Errorf("SetLeaves() root metadata=%q, want %q", got, want)
+	}
+	if len(root.RootHash) == 0 {
+		t.Error("SetLeaves() root has no root hash")
+	}
+}
+
+func TestSetMultiMapLeaves_DryRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := NewTrillianMapServer(extension.Registry{
+		MapStorage: storage.NewMockMapStorage(ctrl),
+	}, TrillianMapServerOptions{})
+
+	_, err := server.SetMultiMapLeaves(context.Background(), &trillian.SetMultiMapLeavesRequest{
+		Requests: []*trillian.SetMapLeavesRequest{{MapId: mapID1, DryRun: true}},
+	})
+	if info := errmsg.Info(err); info == nil || info.Reason != string(errmsg.DryRunUnsupported) {
+		t.Errorf("SetMultiMapLeaves()=%v, want reason %v", err, errmsg.DryRunUnsupported)
+	}
+}
+
+func TestSetLeaves_IdempotencyTokenTooLong(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := NewTrillianMapServer(extension.Registry{
+		AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+		MapStorage:   storage.NewMockMapStorage(ctrl),
+	}, TrillianMapServerOptions{})
+
+	_, err := server.SetLeaves(context.Background(), &trillian.SetMapLeavesRequest{
+		MapId:            mapID1,
+		IdempotencyToken: make([]byte, maxIdempotencyTokenSize+1),
+	})
+	if got, want := status.Code(err), codes.InvalidArgument; got != want {
+		t.Errorf("SetLeaves()=%v, want code %v", err, want)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// No storage calls are expected, as writes are rejected up front.
+	server := NewTrillianMapServer(extension.Registry{
+		AdminStorage: &stestonly.FakeAdminStorage{},
+		MapStorage:   storage.NewMockMapStorage(ctrl),
+	}, TrillianMapServerOptions{ReadOnly: true})
+
+	for _, test := range []struct {
+		desc string
+		call func() error
+	}{
+		{
+			desc: "SetLeaves",
+			call: func() error {
+				_, err := server.SetLeaves(ctx, &trillian.SetMapLeavesRequest{MapId: mapID1})
+				return err
+			},
+		},
+		{
+			desc: "SetMultiMapLeaves",
+			call: func() error {
+				_, err := server.SetMultiMapLeaves(ctx, &trillian.SetMultiMapLeavesRequest{
+					Requests: []*trillian.SetMapLeavesRequest{{MapId: mapID1}},
+				})
+				return err
+			},
+		},
+		{
+			desc: "InitMap",
+			call: func() error {
+				_, err := server.InitMap(ctx, &trillian.InitMapRequest{MapId: mapID1})
+				return err
+			},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			err := test.call()
+			if got, want := status.Code(err), codes.FailedPrecondition; got != want {
+				t.Errorf("%s()=%v, want code %v", test.desc, err, want)
+			}
+		})
+	}
+}
+
+func fakeAdminStorageForMap(ctrl *gomock.Controller, times int, treeID int64) storage.AdminStorage {
+	tree := proto.Clone(stestonly.MapTree).(*trillian.Tree)
+	tree.TreeId = treeID
+
+	adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+	adminStorage := &stestonly.FakeAdminStorage{
+		ReadOnlyTX: []storage.ReadOnlyAdminTX{adminTX},
+	}
+
+	adminTX.EXPECT().GetTree(gomock.Any(), treeID).MaxTimes(times).Return(tree, nil)
+	adminTX.EXPECT().Close().MaxTimes(times).Return(nil)
+	adminTX.EXPECT().Commit().MaxTimes(times).Return(nil)
+
+	return adminStorage
+}
+
+func TestGetTreeAndHasher_MapHashers(t *testing.T) {
+	const custom = trillian.HashStrategy(1000)
+	tree := proto.Clone(stestonly.MapTree).(*trillian.Tree)
+	tree.TreeId = mapID1
+	tree.HashStrategy = custom
+	hasher := maphasher.New(crypto.SHA512)
+
+	for _, test := range []struct {
+		desc       string
+		mapHashers hashers.MapHasherRegistry
+		wantErr    bool
+	}{
+		{desc: "unknown strategy", wantErr: true},
+		{desc: "registry", mapHashers: hashers.MapHasherRegistry{custom: hasher}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+			adminTX.EXPECT().GetTree(gomock.Any(), mapID1).Return(tree, nil)
+			adminTX.EXPECT().Close().Return(nil)
+			adminTX.EXPECT().Commit().Return(nil)
+
+			server := NewTrillianMapServer(extension.Registry{
+				AdminStorage: &stestonly.FakeAdminStorage{ReadOnlyTX: []storage.ReadOnlyAdminTX{adminTX}},
+				MapHashers:   test.mapHashers,
+			}, TrillianMapServerOptions{})
+
+			_, got, err := server.getTreeAndHasher(context.Background(), mapID1, optsMapRead)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("getTreeAndHasher()=_, _, err? %t want? %t (err=%v)", gotErr, test.wantErr, err)
+			}
+			if err == nil && got != hasher {
+				t.Errorf("getTreeAndHasher() returned hasher %v, want %v", got, hasher)
+			}
+		})
+	}
+}
+
+func TestRequestIndexValidator(t *testing.T) {
+	tests := []struct {
+		desc      string
+		indexSize int
+		indices   [][]byte
+		wantErr   bool
+	}{
+		{
+			desc:      "Single index of correct length",
+			indexSize: 1,
+			indices:   [][]byte{{'a'}},
+		},
+		{
+			desc:      "Single index of longer correct length",
+			indexSize: 4,
+			indices:   [][]byte{{'a', 'b', 'c', 'd'}},
+		},
+		{
+			desc:      "Single index too long",
+			indexSize: 1,
+			indices:   [][]byte{{'a', 'b'}},
+			wantErr:   true,
+		},
+		{
+			desc:      "Single index too short",
+			indexSize: 2,
+			indices:   [][]byte{{'a'}},
+			wantErr:   true,
+		},
+		{
+			desc:      "Multiple indices of correct length & no duplicates",
+			indexSize: 1,
+			indices:   [][]byte{{'a'}, {'b'}},
+		},
+		{
+			desc:      "Multiple indices of correct length with duplicates",
+			indexSize: 1,
+			indices:   [][]byte{{'a'}, {'a'}},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			err := validateIndices(tt.indexSize, len(tt.indices), func(i int) []byte { return tt.indices[i] }, repeatedField("index", ""))
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateIndices() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequestIndexValidator_AllViolations(t *testing.T) {
+	indices := [][]byte{{'a'}, {'a', 'b'}, {'b'}, {'a'}, {}, {'b'}}
+	err := validateIndices(1, len(indices), func(i int) []byte { return indices[i] }, repeatedField("leaves", ".index"))
+	if got, want := status.Code(err), codes.InvalidArgument; got != want {
+		t.Fatalf("validateIndices(): %v, want code %v", err, want)
+	}
+	if got, want := errmsg.Info(err).GetReason(), string(errmsg.MapIndexWrongSize); got != want {
+		t.Errorf("validateIndices() reason %q, want %q of the first violation", got, want)
+	}
+	br := errmsg.BadRequest(err)
+	if br == nil {
+		t.Fatalf("validateIndices(): %v, want BadRequest detail", err)
+	}
+	var got []string
+	for _, v := range br.FieldViolations {
+		got = append(got, v.Field+" "+v.Description[:strings.Index(v.Description, ":")])
+	}
+	want := []string{
+		"leaves[1].index MAP_INDEX_WRONG_SIZE",
+		"leaves[3].index DUPLICATE_MAP_INDEX",
+		"leaves[4].index MAP_INDEX_WRONG_SIZE",
+		"leaves[5].index DUPLICATE_MAP_INDEX",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("validateIndices() violations %v, want %v", got, want)
+	}
+}
+
+// fakeWatchRootsStream records the roots sent by WatchSignedMapRoots.
+type fakeWatchRootsStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	roots []*trillian.SignedMapRoot
+}
+
+func (s *fakeWatchRootsStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeWatchRootsStream) Send(resp *trillian.WatchSignedMapRootsResponse) error {
+	s.roots = append(s.roots, resp.MapRoot)
+	return nil
+}
+
+func TestWatchSignedMapRoots(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	root := func(rev uint64) *trillian.SignedMapRoot {
+		b, err := (&types.MapRootV1{Revision: rev}).MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary(): %v", err)
+		}
+		return &trillian.SignedMapRoot{MapRoot: b}
+	}
+
+	adminStorage := fakeAdminStorageForMap(ctrl, 1, mapID1)
+	fakeStorage := storage.NewMockMapStorage(ctrl)
+	mockTX := storage.NewMockMapTreeTX(ctrl)
+	server := NewTrillianMapServer(extension.Registry{
+		AdminStorage: adminStorage,
+		MapStorage:   fakeStorage,
+	}, TrillianMapServerOptions{WatchPollInterval: time.Hour})
+
+	// The first poll sends the latest root, the second one catches up with
+	// all the roots published since, and the third one finds nothing new.
+	// Polls are triggered by notifications rather than by the poll interval.
+	notify := func(context.Context) { server.notifier.notify(mapID1) }
+	fakeStorage.EXPECT().SnapshotForTree(gomock.Any(), gomock.Any()).Times(3).Return(mockTX, nil)
+	gomock.InOrder(
+		mockTX.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(root(2), nil),
+		mockTX.EXPECT().Commit(gomock.Any()).Do(notify).Return(nil),
+		mockTX.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(root(5), nil),
+		mockTX.EXPECT().GetSignedMapRoot(gomock.Any(), int64(3)).Return(root(3), nil),
+		mockTX.EXPECT().GetSignedMapRoot(gomock.Any(), int64(4)).Return(root(4), nil),
+		mockTX.EXPECT().Commit(gomock.Any()).Do(notify).Return(nil),
+		mockTX.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(root(5), nil),
+		mockTX.EXPECT().Commit(gomock.Any()).Do(func(context.Context) { cancel() }).Return(nil),
+	)
+	mockTX.EXPECT().Close().Times(3).Return(nil)
+
+	stream := &fakeWatchRootsStream{ctx: ctx}
+	if err := server.WatchSignedMapRoots(&trillian.WatchSignedMapRootsRequest{MapId: mapID1}, stream); err != context.Canceled {
+		t.Errorf("WatchSignedMapRoots()=%v, want %v", err, context.Canceled)
+	}
+	want := []*trillian.SignedMapRoot{root(2), root(3), root(4), root(5)}
+	if got, want := len(stream.roots), len(want); got != want {
+		t.Fatalf("WatchSignedMapRoots() sent %d roots, want %d", got, want)
+	}
+	for i, got := range stream.roots {
+		if !proto.Equal(got, want[i]) {
+			diff := pretty.Compare(got, want[i])
+			t.Errorf("WatchSignedMapRoots() root %d diff:\n%v", i, diff)
+		}
+	}
+}
+
+func TestLeafCompression(t *testing.T) {
+	for _, c := range []storagepb.LeafCompression{storagepb.LeafCompression_SNAPPY, storagepb.LeafCompression_ZSTD} {
+		t.Run(c.String(), func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			settings, err := ptypes.MarshalAny(&storagepb.MapStorageSettings{LeafCompression: c})
+			if err != nil {
+				t.Fatalf("MarshalAny(): %v", err)
+			}
+			tree := &trillian.Tree{TreeId: mapID1, TreeType: trillian.TreeType_MAP, StorageSettings: settings}
+			index, value := []byte{1}, bytes.Repeat([]byte("value"), 10)
+			codec, err := compression.NewCodec(c)
+			if err != nil {
+				t.Fatalf("NewCodec(): %v", err)
+			}
+			stored, err := codec.Compress(value)
+			if err != nil {
+				t.Fatalf("Compress(): %v", err)
+			}
+
+			tx := storage.NewMockMapTreeTX(ctrl)
+			tx.EXPECT().Set(gomock.Any(), index, &trillian.MapLeaf{Index: index, LeafValue: stored}).Return(nil)
+			tx.EXPECT().Commit(gomock.Any()).Return(nil)
+			tx.EXPECT().Close().Return(nil)
+			roTX := storage.NewMockReadOnlyMapTreeTX(ctrl)
+			roTX.EXPECT().Get(gomock.Any(), int64(1), [][]byte{index}).Return([]*trillian.MapLeaf{{Index: index, LeafValue: stored}}, nil)
+			server := NewTrillianMapServer(extension.Registry{
+				MapStorage: &stestonly.FakeMapStorage{TX: tx, ReadOnlyTX: roTX},
+			}, TrillianMapServerOptions{})
+
+			leaf := &trillian.MapLeaf{Index: index, LeafValue: value}
+			if err := server.readWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+				return server.writeLeaves(ctx, mapID1, tx, []*trillian.MapLeaf{leaf})
+			}); err != nil {
+				t.Fatalf("writeLeaves(): %v", err)
+			}
+			if !bytes.Equal(leaf.LeafValue, value) {
+				t.Errorf("writeLeaves() changed LeafValue to %x, want %x", leaf.LeafValue, value)
+			}
+
+			snapshot, err := server.snapshotForTree(ctx, tree, "TestLeafCompression")
+			if err != nil {
+				t.Fatalf("snapshotForTree(): %v", err)
+			}
+			leaves, err := snapshot.Get(ctx, 1, [][]byte{index})
+			if err != nil {
+				t.Fatalf("Get(): %v", err)
+			}
+			if got := leaves[0].LeafValue; !bytes.Equal(got, value) {
+				t.Errorf("Get() = %x, want %x", got, value)
+			}
+		})
+	}
+}
+
+func TestAddMapRootSignature(t *testing.T) {
+	ctx := context.Background()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey(): %v", err)
+	}
+	pub, err := der.ToPublicProto(key.Public())
+	if err != nil {
+		t.Fatalf("ToPublicProto(): %v", err)
+	}
+	witness := tcrypto.NewSHA256Signer(key)
+	root := &trillian.SignedMapRoot{MapRoot: []byte("root at revision 1")}
+	sign := func(data string) []byte {
+		sig, err := witness.Sign([]byte(data))
+		if err != nil {
+			t.Fatalf("Sign(): %v", err)
+		}
+		return sig
+	}
+
+	for _, tc := range []struct {
+		desc       string
+		revision   int64
+		sig        *trillian.MapRootSignature
+		wantRead   bool
+		wantStore  bool
+		wantCode   codes.Code
+		wantReason errmsg.Reason
+	}{
+		{
+			desc:      "valid",
+			revision:  1,
+			sig:       &trillian.MapRootSignature{PublicKey: pub, Signature: sign("root at revision 1")},
+			wantRead:  true,
+			wantStore: true,
+		},
+		{
+			desc:       "otherRoot",
+			revision:   1,
+			sig:        &trillian.MapRootSignature{PublicKey: pub, Signature: sign("other root")},
+			wantRead:   true,
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.MapRootSignatureInvalid,
+		},
+		{
+			desc:     "badKey",
+			revision: 1,
+			sig:      &trillian.MapRootSignature{PublicKey: &keyspb.PublicKey{Der: []byte("not a key")}, Signature: sign("root at revision 1")},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			desc:       "negativeRevision",
+			revision:   -1,
+			sig:        &trillian.MapRootSignature{PublicKey: pub, Signature: sign("root at revision 1")},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.FieldNegative,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			tx := storage.NewMockMapTreeTX(ctrl)
+			if tc.wantRead {
+				tx.EXPECT().GetSignedMapRoot(gomock.Any(), tc.revision).Return(root, nil)
+				tx.EXPECT().Close().Return(nil)
+			}
+			if tc.wantStore {
+				tx.EXPECT().StoreMapRootSignature(gomock.Any(), tc.revision, tc.sig).Return(nil)
+				tx.EXPECT().Commit(gomock.Any()).Return(nil)
+			}
+			server := NewTrillianMapServer(extension.Registry{
+				AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+				MapStorage:   &stestonly.FakeMapStorage{TX: tx},
+			}, TrillianMapServerOptions{})
+
+			_, err := server.AddMapRootSignature(ctx, &trillian.AddMapRootSignatureRequest{MapId: mapID1, Revision: tc.revision, Signature: tc.sig})
+			if got := status.Code(err); got != tc.wantCode {
+				t.Fatalf("AddMapRootSignature(): %v, want code %v", err, tc.wantCode)
+			}
+			if tc.wantReason != "" {
+				if info := errmsg.Info(err); info == nil || info.Reason != string(tc.wantReason) {
+					t.Errorf("AddMapRootSignature(): %v, want reason %v", err, tc.wantReason)
+				}
+			}
+		})
+	}
+}
+
+func TestGetMapRootSignatures(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	root := &trillian.SignedMapRoot{MapRoot: []byte("root at revision 2")}
+	sigs := []*trillian.MapRootSignature{
+		{PublicKey: &keyspb.PublicKey{Der: []byte("key1")}, Signature: []byte("sig1")},
+		{PublicKey: &keyspb.PublicKey{Der: []byte("key2")}, Signature: []byte("sig2")},
+	}
+	tx := storage.NewMockMapTreeTX(ctrl)
+	tx.EXPECT().GetSignedMapRoot(gomock.Any(), int64(2)).Return(root, nil)
+	tx.EXPECT().GetMapRootSignatures(gomock.Any(), int64(2)).Return(sigs, nil)
+	tx.EXPECT().Commit(gomock.Any()).Return(nil)
+	tx.EXPECT().Close().Return(nil)
+	server := NewTrillianMapServer(extension.Registry{
+		AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+		MapStorage:   &stestonly.FakeMapStorage{ReadOnlyTX: tx},
+	}, TrillianMapServerOptions{})
+
+	resp, err := server.GetMapRootSignatures(ctx, &trillian.GetMapRootSignaturesRequest{MapId: mapID1, Revision: 2})
+	if err != nil {
+		t.Fatalf("GetMapRootSignatures(): %v", err)
+	}
+	want := &trillian.GetMapRootSignaturesResponse{MapRoot: root, Signatures: sigs}
+	if !proto.Equal(resp, want) {
+		t.Errorf("GetMapRootSignatures(): %v, want %v", resp, want)
+	}
+}
+
+func TestGetMapCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	defer setCheckpointOriginPrefix("example.com/maps")()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	b, err := (&types.MapRootV1{Revision: 3, RootHash: []byte("root")}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary(): %v", err)
+	}
+	smr := &trillian.SignedMapRoot{MapRoot: b}
+	tx := storage.NewMockMapTreeTX(ctrl)
+	tx.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(smr, nil)
+	tx.EXPECT().Commit(gomock.Any()).Return(nil)
+	tx.EXPECT().Close().Return(nil)
+	server := NewTrillianMapServer(extension.Registry{
+		AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+		MapStorage:   &stestonly.FakeMapStorage{ReadOnlyTX: tx},
+	}, TrillianMapServerOptions{})
+
+	resp, err := server.GetCheckpoint(ctx, &trillian.GetMapCheckpointRequest{MapId: mapID1})
+	if err != nil {
+		t.Fatalf("GetCheckpoint(): %v", err)
+	}
+	if !proto.Equal(resp.MapRoot, smr) {
+		t.Errorf("GetCheckpoint().MapRoot=%v, want %v", resp.MapRoot, smr)
+	}
+	origin := fmt.Sprintf("example.com/maps/%d", mapID1)
+	body := verifyCheckpoint(t, resp.Checkpoint, origin, stestonly.MapTree.PublicKey.Der)
+	if want := origin + "\n3\ncm9vdA==\n"; body != want {
+		t.Errorf("GetCheckpoint() body %q, want %q", body, want)
+	}
+}
+
+// fakeExportMapStream records the responses sent by ExportMap.
+type fakeExportMapStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	resps []*trillian.ExportMapResponse
+}
+
+func (s *fakeExportMapStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeExportMapStream) Send(resp *trillian.ExportMapResponse) error {
+	s.resps = append(s.resps, resp)
+	return nil
+}
+
+// signedMapRoot returns a SignedMapRoot holding a MapRootV1 at rev.
+func signedMapRoot(t *testing.T, rev uint64, rootHash []byte) *trillian.SignedMapRoot {
+	t.Helper()
+	root, err := (&types.MapRootV1{Revision: rev, RootHash: rootHash}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary(): %v", err)
+	}
+	return &trillian.SignedMapRoot{MapRoot: root}
+}
+
+func TestExportMap(t *testing.T) {
+	ctx := context.Background()
+	root0 := signedMapRoot(t, 0, []byte("root 0"))
+	root1 := signedMapRoot(t, 1, []byte("root 1"))
+	index := func(b byte) []byte { return append(bytes.Repeat([]byte{0}, 31), b) }
+	version := func(rev int64, b byte) storage.MapLeafVersion {
+		return storage.MapLeafVersion{Revision: rev, Leaf: &trillian.MapLeaf{Index: index(b), LeafValue: []byte{byte(rev)}}}
+	}
+
+	type rootsCall struct {
+		start int64
+		roots []*trillian.SignedMapRoot
+	}
+	type versionsCall struct {
+		after    []byte
+		versions []storage.MapLeafVersion
+	}
+	for _, tc := range []struct {
+		desc         string
+		rootsCalls   []rootsCall
+		versionCalls []versionsCall
+		want         []*trillian.ExportMapResponse
+		wantCode     codes.Code
+	}{
+		{
+			desc: "export",
+			rootsCalls: []rootsCall{
+				{start: 0, roots: []*trillian.SignedMapRoot{root0}},
+				{start: 1, roots: []*trillian.SignedMapRoot{root1}},
+			},
+			versionCalls: []versionsCall{
+				// The version written after the exported revision is skipped.
+				{versions: []storage.MapLeafVersion{version(1, 1), version(2, 1)}},
+				{after: index(1), versions: []storage.MapLeafVersion{version(2, 2)}},
+				{after: index(2)},
+			},
+			want: []*trillian.ExportMapResponse{
+				{MapRoots: []*trillian.SignedMapRoot{root0}},
+				{MapRoots: []*trillian.SignedMapRoot{root1}},
+				{LeafVersions: []*trillian.MapLeafVersion{{Revision: 1, Leaf: version(1, 1).Leaf}}},
+			},
+		},
+		{
+			desc:       "pruned",
+			rootsCalls: []rootsCall{{start: 0, roots: []*trillian.SignedMapRoot{root1}}},
+			wantCode:   codes.FailedPrecondition,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tx := storage.NewMockMapTreeTX(ctrl)
+			tx.EXPECT().GetSignedMapRoot(gomock.Any(), int64(1)).Return(root1, nil)
+			for _, c := range tc.rootsCalls {
+				filter := storage.MapRootFilter{StartRevision: c.start, EndRevision: 2}
+				tx.EXPECT().ListSignedMapRoots(gomock.Any(), filter, 1).Return(c.roots, nil)
+			}
+			for _, c := range tc.versionCalls {
+				tx.EXPECT().ListLeafVersions(gomock.Any(), c.after, 1).Return(c.versions, nil)
+			}
+			if tc.wantCode == codes.OK {
+				tx.EXPECT().Commit(gomock.Any()).Return(nil)
+			}
+			tx.EXPECT().Close().Return(nil)
+			tx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+			server := NewTrillianMapServer(extension.Registry{
+				AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+				MapStorage:   &stestonly.FakeMapStorage{ReadOnlyTX: tx},
+			}, TrillianMapServerOptions{})
+
+			stream := &fakeExportMapStream{ctx: ctx}
+			err := server.ExportMap(&trillian.ExportMapRequest{MapId: mapID1, Revision: 1, PageSize: 1}, stream)
+			if got := status.Code(err); got != tc.wantCode {
+				t.Fatalf("ExportMap(): %v, want code %v", err, tc.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if got, want := len(stream.resps), len(tc.want); got != want {
+				t.Fatalf("ExportMap() sent %d responses, want %d", got, want)
+			}
+			for i, got := range stream.resps {
+				if want := tc.want[i]; !proto.Equal(got, want) {
+					t.Errorf("ExportMap() response %d diff:\n%v", i, pretty.Compare(got, want))
+				}
+			}
+		})
+	}
+}
+
+func TestImportMapRevision(t *testing.T) {
+	ctx := context.Background()
+	hasher := maphasher.Default
+	emptyRoot := hasher.HashEmpty(mapID1, make([]byte, hasher.Size()), hasher.BitLen())
+
+	for _, tc := range []struct {
+		desc       string
+		req        *trillian.ImportMapRevisionRequest
+		writeRev   int64
+		wantInit   bool
+		wantStore  bool
+		wantCode   codes.Code
+		wantReason errmsg.Reason
+	}{
+		{
+			desc:      "revision0",
+			req:       &trillian.ImportMapRevisionRequest{MapId: mapID1, MapRoot: signedMapRoot(t, 0, emptyRoot)},
+			wantInit:  true,
+			wantStore: true,
+		},
+		{
+			desc:       "revision0Mismatch",
+			req:        &trillian.ImportMapRevisionRequest{MapId: mapID1, MapRoot: signedMapRoot(t, 0, []byte("other root"))},
+			wantInit:   true,
+			wantCode:   codes.FailedPrecondition,
+			wantReason: errmsg.ImportedRootMismatch,
+		},
+		{
+			desc: "revision0Leaves",
+			req: &trillian.ImportMapRevisionRequest{MapId: mapID1, MapRoot: signedMapRoot(t, 0, emptyRoot),
+				Leaves: []*trillian.MapLeaf{{Index: make([]byte, 32)}}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			desc:       "revisionMismatch",
+			req:        &trillian.ImportMapRevisionRequest{MapId: mapID1, MapRoot: signedMapRoot(t, 3, []byte("root 3"))},
+			writeRev:   2,
+			wantCode:   codes.FailedPrecondition,
+			wantReason: errmsg.RevisionMismatch,
+		},
+		{
+			desc:     "badRoot",
+			req:      &trillian.ImportMapRevisionRequest{MapId: mapID1, MapRoot: &trillian.SignedMapRoot{MapRoot: []byte("garbage")}},
+			wantCode: codes.InvalidArgument,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tx := storage.NewMockMapTreeTX(ctrl)
+			if tc.wantInit {
+				tx.EXPECT().LatestSignedMapRoot(gomock.Any()).Return(nil, storage.ErrTreeNeedsInit)
+			}
+			if tc.writeRev != 0 {
+				tx.EXPECT().WriteRevision(gomock.Any()).Return(tc.writeRev, nil)
+			}
+			if tc.wantInit || tc.writeRev != 0 {
+				tx.EXPECT().Close().Return(nil)
+				tx.EXPECT().IsOpen().AnyTimes().Return(false)
+			}
+			if tc.wantStore {
+				tx.EXPECT().StoreSignedMapRoot(gomock.Any(), gomock.Any()).Return(nil)
+				tx.EXPECT().Commit(gomock.Any()).Return(nil)
+			}
+			server := NewTrillianMapServer(extension.Registry{
+				AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+				MapStorage:   &stestonly.FakeMapStorage{TX: tx},
+			}, TrillianMapServerOptions{})
+
+			resp, err := server.ImportMapRevision(ctx, tc.req)
+			if got := status.Code(err); got != tc.wantCode {
+				t.Fatalf("ImportMapRevision(): %v, want code %v", err, tc.wantCode)
+			}
+			if tc.wantReason != "" {
+				if info := errmsg.Info(err); info == nil || info.Reason != string(tc.wantReason) {
+					t.Errorf("ImportMapRevision(): %v, want reason %v", err, tc.wantReason)
+				}
+			}
+			if err != nil {
+				return
+			}
+			var got types.MapRootV1
+			if err := got.UnmarshalBinary(resp.MapRoot.MapRoot); err != nil {
+				t.Fatalf("UnmarshalBinary(): %v", err)
+			}
+			if !bytes.Equal(got.RootHash, emptyRoot) {
+				t.Errorf("ImportMapRevision(): root hash %x, want %x", got.RootHash, emptyRoot)
+			}
+		})
+	}
+}
+
+func TestGetLeavesByIndexPrefix(t *testing.T) {
+	ctx := context.Background()
+	hasher := maphasher.Default
+	index := func(b byte) []byte { return append([]byte{1}, bytes.Repeat([]byte{b}, 31)...) }
+	leaf := &trillian.MapLeaf{Index: index(1), LeafValue: []byte("value")}
+	leaf.LeafHash = hasher.HashLeaf(mapID1, leaf.Index, leaf.LeafValue)
+	// The map holds only leaf, so its inclusion proof is all empty.
+	hs2 := merkle.NewHStar2(mapID1, hasher)
+	rootHash, err := hs2.HStar2Root(hasher.BitLen(), []*merkle.HStar2LeafHash{{Index: new(big.Int).SetBytes(leaf.Index), LeafHash: leaf.LeafHash}})
+	if err != nil {
+		t.Fatalf("HStar2Root(): %v", err)
+	}
+	root := signedMapRoot(t, 2, rootHash)
+
+	for _, tc := range []struct {
+		desc       string
+		req        *trillian.GetMapLeavesByIndexPrefixRequest
+		limits     MapLimits
+		leaves     []*trillian.MapLeaf
+		wantRead   bool
+		wantCode   codes.Code
+		wantReason errmsg.Reason
+	}{
+		{
+			desc:     "leaf",
+			req:      &trillian.GetMapLeavesByIndexPrefixRequest{MapId: mapID1, IndexPrefix: []byte{1}, Revision: 2},
+			leaves:   []*trillian.MapLeaf{leaf},
+			wantRead: true,
+		},
+		{
+			desc:       "tooManyLeaves",
+			req:        &trillian.GetMapLeavesByIndexPrefixRequest{MapId: mapID1, IndexPrefix: []byte{1}, Revision: 2},
+			limits:     MapLimits{MaxGetIndices: 1},
+			leaves:     []*trillian.MapLeaf{leaf, {Index: index(2)}},
+			wantRead:   true,
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.TooManyIndices,
+		},
+		{
+			desc:     "emptyPrefix",
+			req:      &trillian.GetMapLeavesByIndexPrefixRequest{MapId: mapID1, Revision: 2},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			desc:       "prefixTooLong",
+			req:        &trillian.GetMapLeavesByIndexPrefixRequest{MapId: mapID1, IndexPrefix: index(1), Revision: 2},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.MapIndexPrefixTooLong,
+		},
+		{
+			desc:       "negativeRevision",
+			req:        &trillian.GetMapLeavesByIndexPrefixRequest{MapId: mapID1, IndexPrefix: []byte{1}, Revision: -1},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.FieldNegative,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tx := storage.NewMockMapTreeTX(ctrl)
+			if tc.wantRead {
+				tx.EXPECT().GetSignedMapRoot(gomock.Any(), int64(2)).Return(root, nil)
+				tx.EXPECT().List(gomock.Any(), int64(2), []byte{1}, maxListPageSize).Return(tc.leaves, nil)
+				tx.EXPECT().GetMerkleNodes(gomock.Any(), int64(2), gomock.Any()).AnyTimes().Return(nil, nil)
+				if tc.wantCode == codes.OK {
+					tx.EXPECT().Commit(gomock.Any()).Return(nil)
+				}
+				tx.EXPECT().Close().Return(nil)
+				tx.EXPECT().IsOpen().AnyTimes().Return(false)
+			}
+			server := NewTrillianMapServer(extension.Registry{
+				AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+				MapStorage:   &stestonly.FakeMapStorage{ReadOnlyTX: tx},
+			}, TrillianMapServerOptions{Limits: tc.limits})
+
+			resp, err := server.GetLeavesByIndexPrefix(ctx, tc.req)
+			if got := status.Code(err); got != tc.wantCode {
+				t.Fatalf("GetLeavesByIndexPrefix(): %v, want code %v", err, tc.wantCode)
+			}
+			if tc.wantReason != "" {
+				if info := errmsg.Info(err); info == nil || info.Reason != string(tc.wantReason) {
+					t.Errorf("GetLeavesByIndexPrefix(): %v, want reason %v", err, tc.wantReason)
+				}
+			}
+			if err != nil {
+				return
+			}
+			if err := merkle.VerifyMapPrefixProof(mapID1, tc.req.IndexPrefix, resp.Leaves, rootHash, resp.Proof, hasher); err != nil {
+				t.Errorf("VerifyMapPrefixProof(): %v", err)
 			}
 		})
 	}
@@ -0,0 +1,177 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/trees"
+	"github.com/google/trillian/types"
+)
+
+// Results recorded by the shadow_writes metric.
+const (
+	shadowMatch    = "match"
+	shadowDiverged = "diverged"
+	shadowError    = "error"
+)
+
+// mapShadows applies the writes of maps to their shadow maps, which are
+// created with a candidate hasher or storage layout, e.g. a custom hash
+// strategy registered in extension.Registry.MapHashers or other
+// storage_settings, and compares the roots of both. The shadow maps must be
+// initialised, and must produce the same root hashes as the maps they shadow.
+type mapShadows struct {
+	// ids maps the ID of each shadowed map to the ID of its shadow map.
+	ids map[int64]int64
+	// mus serializes the writes to each shadow map, by shadowed map ID.
+	mus map[int64]*sync.Mutex
+	// wg tracks the shadow writes in progress.
+	wg      sync.WaitGroup
+	counter monitoring.Counter
+}
+
+func newMapShadows(ids map[int64]int64, mf monitoring.MetricFactory) *mapShadows {
+	mus := make(map[int64]*sync.Mutex, len(ids))
+	for id := range ids {
+		mus[id] = &sync.Mutex{}
+	}
+	return &mapShadows{
+		ids: ids,
+		mus: mus,
+		counter: mf.NewCounter(
+			"shadow_writes",
+			"Number of map writes applied to a shadow map, by the result of comparing their roots",
+			"map_id", "result",
+		),
+	}
+}
+
+// ParseShadowMaps parses a comma-separated list of map_id=shadow_map_id pairs,
+// as set in TrillianMapServerOptions.ShadowMaps.
+func ParseShadowMaps(s string) (map[int64]int64, error) {
+	ids := make(map[int64]int64)
+	if s == "" {
+		return ids, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid shadow map %q, want map_id=shadow_map_id", pair)
+		}
+		id, err := strconv.ParseInt(kv[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid map ID in %q: %v", pair, err)
+		}
+		shadowID, err := strconv.ParseInt(kv[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shadow map ID in %q: %v", pair, err)
+		}
+		if _, ok := ids[id]; ok || id == shadowID {
+			return nil, fmt.Errorf("map %d must have a single shadow map other than itself", id)
+		}
+		ids[id] = shadowID
+	}
+	return ids, nil
+}
+
+// shadowUpdate writes the leaves of u, which created root, to the shadow map
+// of u's map, if it has one. The write is made in the background, so it does
+// not delay nor affect the write to the shadowed map, and its result is only
+// logged and counted.
+func (t *TrillianMapServer) shadowUpdate(u *mapUpdate, root *trillian.SignedMapRoot) {
+	shadowID, ok := t.shadows.ids[u.tree.TreeId]
+	if !ok {
+		return
+	}
+	var mr types.MapRootV1
+	if err := mr.UnmarshalBinary(root.MapRoot); err != nil {
+		glog.Errorf("%v: failed to parse root for shadow map %v: %v", u.tree.TreeId, shadowID, err)
+		return
+	}
+	// The shadow map hashes the leaves with its own hasher.
+	leaves := make([]*trillian.MapLeaf, 0, len(u.req.Leaves))
+	for _, l := range u.req.Leaves {
+		leaves = append(leaves, proto.Clone(l).(*trillian.MapLeaf))
+	}
+	req := &trillian.SetMapLeavesRequest{
+		MapId:    shadowID,
+		Leaves:   leaves,
+		Metadata: u.req.Metadata,
+		Revision: int64(mr.Revision),
+	}
+
+	mu := t.shadows.mus[u.tree.TreeId]
+	t.shadows.wg.Add(1)
+	go func() {
+		defer t.shadows.wg.Done()
+		mu.Lock()
+		defer mu.Unlock()
+
+		label := strconv.FormatInt(u.tree.TreeId, 10)
+		shadowHash, err := t.writeShadow(req)
+		switch {
+		case err != nil:
+			glog.Warningf("%v: failed to write revision %v to shadow map %v: %v", u.tree.TreeId, mr.Revision, shadowID, err)
+			t.shadows.counter.Inc(label, shadowError)
+		case !bytes.Equal(shadowHash, mr.RootHash):
+			glog.Errorf("%v: revision %v diverged in shadow map %v: root hash %x, shadow root hash %x", u.tree.TreeId, mr.Revision, shadowID, mr.RootHash, shadowHash)
+			t.shadows.counter.Inc(label, shadowDiverged)
+		default:
+			t.shadows.counter.Inc(label, shadowMatch)
+		}
+	}()
+}
+
+// writeShadow applies req to a shadow map, and returns its new root hash.
+func (t *TrillianMapServer) writeShadow(req *trillian.SetMapLeavesRequest) (_ []byte, err error) {
+	ctx, spanEnd := spanFor(context.Background(), "writeShadow", treeAttr(req.MapId), revisionAttr(req.Revision), batchAttr(len(req.Leaves)))
+	defer spanEnd()
+	ctx, finish := t.withRequestTimeout(ctx, req.MapId)
+	defer func() { err = finish(err) }()
+
+	u, err := t.prepareUpdate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	ctx = trees.NewContext(ctx, u.tree)
+
+	var newRoot *trillian.SignedMapRoot
+	err = t.readWriteTransaction(ctx, u.tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		var err error
+		newRoot, err = t.applyUpdate(ctx, u, tx, t.opts.UseSingleTransaction)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.notifier.notify(req.MapId)
+
+	var mr types.MapRootV1
+	if err := mr.UnmarshalBinary(newRoot.MapRoot); err != nil {
+		return nil, err
+	}
+	return mr.RootHash, nil
+}
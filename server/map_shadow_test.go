@@ -0,0 +1,143 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	stestonly "github.com/google/trillian/storage/testonly"
+
+	_ "github.com/google/trillian/merkle/coniks" // register CONIKS_SHA256
+	mtestonly "github.com/google/trillian/monitoring/testonly"
+)
+
+func TestParseShadowMaps(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		want    map[int64]int64
+		wantErr bool
+	}{
+		{in: "", want: map[int64]int64{}},
+		{in: "1=2", want: map[int64]int64{1: 2}},
+		{in: "1=2, 3=4", want: map[int64]int64{1: 2, 3: 4}},
+		{in: "1", wantErr: true},
+		{in: "x=2", wantErr: true},
+		{in: "1=y", wantErr: true},
+		{in: "1=1", wantErr: true},
+		{in: "1=2,1=3", wantErr: true},
+	} {
+		got, err := ParseShadowMaps(test.in)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("ParseShadowMaps(%q) = (_, %v), want err %v", test.in, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("ParseShadowMaps(%q) = %v, want %v", test.in, got, test.want)
+		}
+		for id, shadowID := range test.want {
+			if got[id] != shadowID {
+				t.Errorf("ParseShadowMaps(%q) = %v, want %v", test.in, got, test.want)
+			}
+		}
+	}
+}
+
+func TestSetLeaves_Shadow(t *testing.T) {
+	const shadowID = mapID1 + 1
+
+	for _, test := range []struct {
+		desc        string
+		shadowHash  trillian.HashStrategy
+		shadowFails bool
+		wantResult  string
+	}{
+		{desc: "match", shadowHash: trillian.HashStrategy_TEST_MAP_HASHER, wantResult: shadowMatch},
+		{desc: "diverged", shadowHash: trillian.HashStrategy_CONIKS_SHA256, wantResult: shadowDiverged},
+		{desc: "error", shadowHash: trillian.HashStrategy_TEST_MAP_HASHER, shadowFails: true, wantResult: shadowError},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			var adminTXs []storage.ReadOnlyAdminTX
+			for _, id := range []int64{mapID1, shadowID} {
+				tree := proto.Clone(stestonly.MapTree).(*trillian.Tree)
+				tree.TreeId = id
+				if id == shadowID {
+					tree.HashStrategy = test.shadowHash
+				}
+				adminTX := storage.NewMockReadOnlyAdminTX(ctrl)
+				adminTX.EXPECT().GetTree(gomock.Any(), id).Return(tree, nil)
+				adminTX.EXPECT().Close().Return(nil)
+				adminTX.EXPECT().Commit().Return(nil)
+				adminTXs = append(adminTXs, adminTX)
+			}
+
+			// The map and its shadow are written at the same revision, in
+			// separate transactions.
+			writes := 2
+			if test.shadowFails {
+				writes = 1
+			}
+			tx := storage.NewMockMapTreeTX(ctrl)
+			revs := []int64{3, 3}
+			if test.shadowFails {
+				revs[1] = 4
+			}
+			gomock.InOrder(
+				tx.EXPECT().WriteRevision(gomock.Any()).Return(revs[0], nil),
+				tx.EXPECT().WriteRevision(gomock.Any()).Return(revs[1], nil),
+			)
+			tx.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Times(writes).Return(nil)
+			tx.EXPECT().GetMerkleNodes(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil, nil)
+			tx.EXPECT().SetMerkleNodes(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+			tx.EXPECT().StoreSignedMapRoot(gomock.Any(), gomock.Any()).Times(writes).Return(nil)
+			tx.EXPECT().Commit(gomock.Any()).Times(writes).Return(nil)
+			tx.EXPECT().Close().Times(2).Return(nil)
+
+			server := NewTrillianMapServer(extension.Registry{
+				AdminStorage:  &stestonly.FakeAdminStorage{ReadOnlyTX: adminTXs},
+				MapStorage:    &stestonly.FakeMapStorage{TX: tx},
+				MetricFactory: monitoring.InertMetricFactory{},
+			}, TrillianMapServerOptions{
+				UseSingleTransaction: true,
+				ShadowMaps:           map[int64]int64{mapID1: shadowID},
+			})
+			results := mtestonly.NewCounterSnapshot(server.shadows.counter, "1", test.wantResult)
+
+			leaf := &trillian.MapLeaf{Index: make([]byte, 32), LeafValue: []byte("value")}
+			if _, err := server.SetLeaves(context.Background(), &trillian.SetMapLeavesRequest{
+				MapId:  mapID1,
+				Leaves: []*trillian.MapLeaf{leaf},
+			}); err != nil {
+				t.Fatalf("SetLeaves(): %v", err)
+			}
+			server.shadows.wg.Wait()
+			if got, want := results.Delta(), 1.0; got != want {
+				t.Errorf("shadow writes with result %v: %v, want %v", test.wantResult, got, want)
+			}
+		})
+	}
+}
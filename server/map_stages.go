@@ -0,0 +1,153 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/trillian/server/errmsg"
+	"google.golang.org/grpc/codes"
+)
+
+// Stages of a map write, recorded by the write_stage_latency metric.
+const (
+	stageWriteLeaves = "write_leaves"
+	stagePreload     = "preload"
+	stageComputeRoot = "compute_root"
+	stageSignRoot    = "sign_root"
+	stageCommit      = "commit"
+)
+
+// MapStageTimeouts bounds the duration of each stage of a map write, so that
+// operators can bound where slow revisions spend their time. The stages are
+// those of SetLeaves, and the other writes which share them, e.g.
+// DeleteLeafRange, are bounded too. Zero values disable the corresponding
+// limit.
+type MapStageTimeouts struct {
+	// WriteLeaves bounds writing the leaves of the request to storage.
+	WriteLeaves time.Duration
+	// Preload bounds reading the Merkle nodes selected by the Preload
+	// strategy of the server.
+	Preload time.Duration
+	// ComputeRoot bounds updating the sparse Merkle tree and calculating its
+	// new root hash, including checking the inclusion proofs sampled by
+	// TrillianMapServerOptions.ProofCheckRate.
+	ComputeRoot time.Duration
+	// SignRoot bounds signing and storing the new map root. Signers can't be
+	// interrupted, so a root signed after the timeout is discarded.
+	SignRoot time.Duration
+	// Commit bounds committing the transaction of SetLeaves and WriteLeaves
+	// requests.
+	Commit time.Duration
+}
+
+// stageTimeout returns the timeout of stage, or zero if it is not bounded.
+func (t *TrillianMapServer) stageTimeout(stage string) time.Duration {
+	s := t.opts.Limits.StageTimeouts
+	switch stage {
+	case stageWriteLeaves:
+		return s.WriteLeaves
+	case stagePreload:
+		return s.Preload
+	case stageComputeRoot:
+		return s.ComputeRoot
+	case stageSignRoot:
+		return s.SignRoot
+	case stageCommit:
+		return s.Commit
+	}
+	return 0
+}
+
+// runStage runs f as stage of a write to mapID, with a context which expires
+// after the stage timeout, and records its latency. Errors caused by the
+// expiry of the stage timeout are turned into DeadlineExceeded errors.
+func (t *TrillianMapServer) runStage(ctx context.Context, mapID int64, stage string, f func(context.Context) error) error {
+	label := strconv.FormatInt(mapID, 10)
+	start := time.Now()
+	defer func() { t.stageLatency.Observe(time.Since(start).Seconds(), label, stage) }()
+
+	timeout := t.stageTimeout(stage)
+	if timeout <= 0 {
+		return f(ctx)
+	}
+	sctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := f(sctx)
+	// Only errors after the stage deadline, rather than an earlier one, are
+	// rewritten.
+	if err == nil || sctx.Err() != context.DeadlineExceeded || ctx.Err() != nil {
+		return err
+	}
+	return t.errStageTimeout(label, stage, timeout)
+}
+
+func (t *TrillianMapServer) errStageTimeout(label, stage string, timeout time.Duration) error {
+	t.rejectCounter.Inc(label, rejectStageTimeout)
+	return errmsg.New(codes.DeadlineExceeded, errmsg.StageTimedOut, errmsg.Params{"stage": stage, "timeout": timeout})
+}
+
+// commitStage bounds and measures the commit of a read-write transaction,
+// which storage makes after the function run in the transaction returns, so
+// it can't be wrapped by runStage.
+type commitStage struct {
+	t       *TrillianMapServer
+	label   string
+	timeout time.Duration
+	cancel  context.CancelFunc
+	start   time.Time
+	timer   *time.Timer
+}
+
+// newCommitStage returns a context for the transaction of a write to mapID,
+// which is cancelled if the commit of the transaction times out.
+func (t *TrillianMapServer) newCommitStage(ctx context.Context, mapID int64) (context.Context, *commitStage) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, &commitStage{
+		t:       t,
+		label:   strconv.FormatInt(mapID, 10),
+		timeout: t.stageTimeout(stageCommit),
+		cancel:  cancel,
+	}
+}
+
+// begin must be called as the function run in the transaction returns
+// successfully. If storage retries the function, the commit starts again.
+func (c *commitStage) begin() {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.start = time.Now()
+	if c.timeout > 0 {
+		c.timer = time.AfterFunc(c.timeout, c.cancel)
+	}
+}
+
+// end must be called with the error returned by the transaction. It records
+// the latency of the commit, releases the context, and turns errors caused by
+// the expiry of the commit timeout into DeadlineExceeded errors.
+func (c *commitStage) end(ctx context.Context, err error) error {
+	defer c.cancel()
+	if c.start.IsZero() {
+		return err
+	}
+	c.t.stageLatency.Observe(time.Since(c.start).Seconds(), c.label, stageCommit)
+	if c.timer == nil || c.timer.Stop() || err == nil || ctx.Err() != nil {
+		return err
+	}
+	return c.t.errStageTimeout(c.label, stageCommit, c.timeout)
+}
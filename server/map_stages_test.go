@@ -0,0 +1,123 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/server/errmsg"
+	"github.com/google/trillian/storage"
+	stestonly "github.com/google/trillian/storage/testonly"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRunStage(t *testing.T) {
+	errStage := errors.New("stage failed")
+	// block waits for the stage context to expire.
+	block := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	for _, test := range []struct {
+		desc       string
+		timeouts   MapStageTimeouts
+		f          func(context.Context) error
+		wantErr    error
+		wantReason errmsg.Reason
+	}{
+		{desc: "unbounded", f: func(context.Context) error { return nil }},
+		{desc: "failed", timeouts: MapStageTimeouts{ComputeRoot: time.Minute}, f: func(context.Context) error { return errStage }, wantErr: errStage},
+		{desc: "timedOut", timeouts: MapStageTimeouts{ComputeRoot: time.Millisecond}, f: block, wantReason: errmsg.StageTimedOut},
+		{desc: "otherStageTimeout", timeouts: MapStageTimeouts{Preload: time.Millisecond}, f: func(ctx context.Context) error {
+			if _, ok := ctx.Deadline(); ok {
+				return errors.New("stage has a deadline")
+			}
+			return nil
+		}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			server := NewTrillianMapServer(extension.Registry{MetricFactory: monitoring.InertMetricFactory{}},
+				TrillianMapServerOptions{Limits: MapLimits{StageTimeouts: test.timeouts}})
+			before, _ := server.stageLatency.Info("1", stageComputeRoot)
+
+			err := server.runStage(context.Background(), 1, stageComputeRoot, test.f)
+			if test.wantReason != "" {
+				if got, want := status.Code(err), codes.DeadlineExceeded; got != want {
+					t.Errorf("runStage(): %v, want code %v", err, want)
+				}
+				if info := errmsg.Info(err); info == nil || info.Reason != string(test.wantReason) {
+					t.Errorf("runStage(): %v, want reason %v", err, test.wantReason)
+				}
+			} else if err != test.wantErr {
+				t.Errorf("runStage(): %v, want %v", err, test.wantErr)
+			}
+			if after, _ := server.stageLatency.Info("1", stageComputeRoot); after != before+1 {
+				t.Errorf("runStage() recorded %d latencies, want 1", after-before)
+			}
+		})
+	}
+}
+
+func TestSetLeaves_CommitTimeout(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tx := storage.NewMockMapTreeTX(ctrl)
+	tx.EXPECT().WriteRevision(gomock.Any()).Return(int64(3), nil)
+	tx.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil, nil)
+	tx.EXPECT().SetMerkleNodes(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	tx.EXPECT().StoreSignedMapRoot(gomock.Any(), gomock.Any()).Return(nil)
+	// The commit blocks until the commit timeout cancels its context.
+	tx.EXPECT().Commit(gomock.Any()).DoAndReturn(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	tx.EXPECT().Close().Return(nil)
+
+	server := NewTrillianMapServer(extension.Registry{
+		AdminStorage:  fakeAdminStorageForMap(ctrl, 1, mapID1),
+		MapStorage:    &stestonly.FakeMapStorage{TX: tx},
+		MetricFactory: monitoring.InertMetricFactory{},
+	}, TrillianMapServerOptions{
+		UseSingleTransaction: true,
+		Limits:               MapLimits{StageTimeouts: MapStageTimeouts{Commit: time.Millisecond}},
+	})
+	before, _ := server.stageLatency.Info("1", stageCommit)
+
+	_, err := server.SetLeaves(ctx, &trillian.SetMapLeavesRequest{
+		MapId:  mapID1,
+		Leaves: []*trillian.MapLeaf{{Index: make([]byte, 32), LeafValue: []byte("value")}},
+	})
+	if got, want := status.Code(err), codes.DeadlineExceeded; got != want {
+		t.Fatalf("SetLeaves(): %v, want code %v", err, want)
+	}
+	if info := errmsg.Info(err); info == nil || info.Reason != string(errmsg.StageTimedOut) || info.Params["stage"] != stageCommit {
+		t.Errorf("SetLeaves(): %v, want reason %v in stage %v", err, errmsg.StageTimedOut, stageCommit)
+	}
+	if after, _ := server.stageLatency.Info("1", stageCommit); after != before+1 {
+		t.Errorf("SetLeaves() recorded %d commit latencies, want 1", after-before)
+	}
+}
@@ -15,12 +15,38 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"strconv"
+	"time"
 
+	"github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/extension"
 	"github.com/google/trillian/maps"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/server/errmsg"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/querytag"
 	"github.com/google/trillian/trees"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultRevisionLease is the lease of a reserved revision if the request
+	// doesn't specify one.
+	defaultRevisionLease = time.Minute
+	// maxRevisionLease is the longest lease of a reserved revision.
+	maxRevisionLease = time.Hour
+	// revisionLeaseTokenSize is the size of the random lease tokens.
+	revisionLeaseTokenSize = 16
+	// defaultDeleteRangeLeaves is the maximum number of leaves cleared by
+	// each DeleteLeafRange request if MaxSetLeaves is not set.
+	defaultDeleteRangeLeaves = 10000
 )
 
 // TrillianMapWriteServer implements the Write RPC API
@@ -41,6 +67,9 @@ func (t *TrillianMapWriteServer) GetLeavesByRevision(ctx context.Context, req *t
 
 // WriteLeaves implements the WriteLeaves write RPC method.
 func (t *TrillianMapWriteServer) WriteLeaves(ctx context.Context, req *trillian.WriteMapLeavesRequest) (*trillian.WriteMapLeavesResponse, error) {
+	if t.mapServer.opts.QueueWrites {
+		return t.queueLeaves(ctx, req)
+	}
 	tree, err := trees.GetTree(ctx, t.registry.AdminStorage, req.MapId, optsMapWrite)
 	if err != nil {
 		return nil, err
@@ -50,23 +79,258 @@ func (t *TrillianMapWriteServer) WriteLeaves(ctx context.Context, req *trillian.
 		return nil, err
 	}
 	setLeavesReq := trillian.SetMapLeavesRequest{
+		MapId:            req.MapId,
+		Leaves:           req.Leaves,
+		Metadata:         req.Metadata,
+		Revision:         req.ExpectRevision,
+		IdempotencyToken: req.IdempotencyToken,
+		DryRun:           req.DryRun,
+		RevisionLease:    req.RevisionLease}
+
+	resp, err := t.mapServer.SetLeaves(ctx, &setLeavesReq)
+	if err != nil {
+		return nil, err
+	}
+	var root types.MapRootV1
+	if req.DryRun {
+		// The roots of dry runs are not signed.
+		if err := root.UnmarshalBinary(resp.MapRoot.MapRoot); err != nil {
+			return nil, err
+		}
+	} else {
+		verified, err := rootVerifier.VerifySignedMapRoot(resp.MapRoot)
+		if err != nil {
+			return nil, err
+		}
+		root = *verified
+	}
+	return &trillian.WriteMapLeavesResponse{Revision: int64(root.Revision), RootHash: root.RootHash}, nil
+}
+
+// queueLeaves validates req and queues its leaves to be merged into a later
+// revision of the map by a MapMerger.
+func (t *TrillianMapWriteServer) queueLeaves(ctx context.Context, req *trillian.WriteMapLeavesRequest) (*trillian.WriteMapLeavesResponse, error) {
+	if err := t.mapServer.checkWritable("WriteLeaves"); err != nil {
+		return nil, err
+	}
+	// The revision which merges the leaves is not known until the merge, so
+	// neither the expected revision nor the revision of a previous write with
+	// the same token can be checked, nor can the root of a dry run be
+	// calculated.
+	if req.ExpectRevision != 0 {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.QueuedWriteUnsupported, errmsg.Params{"field": "WriteMapLeavesRequest.ExpectRevision"})
+	}
+	if len(req.IdempotencyToken) > 0 {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.QueuedWriteUnsupported, errmsg.Params{"field": "WriteMapLeavesRequest.IdempotencyToken"})
+	}
+	if req.DryRun {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.QueuedWriteUnsupported, errmsg.Params{"field": "WriteMapLeavesRequest.DryRun"})
+	}
+	if len(req.RevisionLease) > 0 {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.QueuedWriteUnsupported, errmsg.Params{"field": "WriteMapLeavesRequest.RevisionLease"})
+	}
+	queuer, ok := t.registry.MapStorage.(storage.MapWriteQueuer)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "map storage does not support queued writes")
+	}
+
+	u, err := t.mapServer.prepareUpdate(ctx, &trillian.SetMapLeavesRequest{
 		MapId:    req.MapId,
 		Leaves:   req.Leaves,
 		Metadata: req.Metadata,
-		Revision: req.ExpectRevision}
+	})
+	if err != nil {
+		return nil, err
+	}
+	w := &storage.QueuedMapWrite{Leaves: req.Leaves, Metadata: req.Metadata, QueueTimestamp: time.Now()}
+	if err := queuer.QueueMapWrite(trees.NewContext(ctx, u.tree), u.tree, w); err != nil {
+		return nil, err
+	}
+	return &trillian.WriteMapLeavesResponse{}, nil
+}
 
-	resp, err := t.mapServer.SetLeaves(ctx, &setLeavesReq)
+// DeleteLeafRange implements the DeleteLeafRange write RPC method. The leaves
+// which exist under the prefix at the latest revision are listed and set to
+// empty values in a single new revision, which is how WriteLeaves deletes
+// leaves, so the result is indistinguishable from deleting each of them. At
+// most MaxSetLeaves leaves, or defaultDeleteRangeLeaves if it's not set, are
+// cleared by each request, and the response tells the client whether more
+// remain.
+func (t *TrillianMapWriteServer) DeleteLeafRange(ctx context.Context, req *trillian.DeleteMapLeafRangeRequest) (_ *trillian.DeleteMapLeafRangeResponse, err error) {
+	ctx, spanEnd := spanFor(ctx, "DeleteLeafRange", treeAttr(req.MapId))
+	defer spanEnd()
+	if err := t.mapServer.checkWritable("DeleteLeafRange"); err != nil {
+		return nil, err
+	}
+	if len(req.IndexPrefix) == 0 {
+		return nil, errEmpty("DeleteMapLeafRangeRequest.IndexPrefix")
+	}
+	if req.ExpectRevision < 0 {
+		return nil, errNegative("DeleteMapLeafRangeRequest.ExpectRevision", req.ExpectRevision)
+	}
+	if err := t.mapServer.checkWriteSize(req.MapId, 0, req); err != nil {
+		return nil, err
+	}
+	ctx, finish := t.mapServer.withRequestTimeout(ctx, req.MapId)
+	defer func() { err = finish(err) }()
+
+	tree, hasher, err := t.mapServer.getTreeAndHasher(ctx, req.MapId, optsMapWrite)
+	if err != nil {
+		return nil, err
+	}
+	if got, max := len(req.IndexPrefix), hashers.IndexSize(hasher)-1; got > max {
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.MapIndexPrefixTooLong, errmsg.Params{"got": got, "max": max})
+	}
+	ctx = trees.NewContext(ctx, tree)
+
+	resp := &trillian.DeleteMapLeafRangeResponse{}
+	err = t.mapServer.readWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		rev, err := t.mapServer.getWriteRevision(ctx, tree, tx, req.ExpectRevision)
+		if err != nil {
+			return err
+		}
+		ctx = querytag.WithRevision(ctx, rev)
+		leaves, more, err := listRange(ctx, tx, rev-1, req.IndexPrefix, t.deleteRangeLimit())
+		if err != nil {
+			return err
+		}
+		glog.V(2).Infof("%v: Deleting %d leaves with prefix %x at revision %v", tree.TreeId, len(leaves), req.IndexPrefix, rev)
+
+		hkv := make([]merkle.HashKeyValue, 0, len(leaves))
+		for _, l := range leaves {
+			l.LeafValue, l.ExtraData = nil, nil
+			l.LeafHash = hasher.HashLeaf(tree.TreeId, l.Index, l.LeafValue)
+			hkv = append(hkv, merkle.HashKeyValue{HashedKey: l.Index, HashedValue: l.LeafHash})
+		}
+		if err := t.mapServer.checkRevisionLease(ctx, tree, rev, nil); err != nil {
+			return err
+		}
+		if err := t.mapServer.writeLeaves(ctx, tree.TreeId, tx, leaves); err != nil {
+			return err
+		}
+		if _, err := t.mapServer.updateTree(ctx, tree, hasher, tx, hkv, req.Metadata, rev, t.mapServer.opts.UseSingleTransaction); err != nil {
+			return err
+		}
+		resp.Revision = rev
+		resp.DeletedCount = int64(len(leaves))
+		resp.More = more
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.mapServer.setLeafCounter.Add(float64(resp.DeletedCount), strconv.FormatInt(req.MapId, 10))
+	t.mapServer.notifier.notify(req.MapId)
+	return resp, nil
+}
+
+// ReserveRevision implements the ReserveRevision write RPC method.
+func (t *TrillianMapWriteServer) ReserveRevision(ctx context.Context, req *trillian.ReserveMapRevisionRequest) (_ *trillian.ReserveMapRevisionResponse, err error) {
+	ctx, spanEnd := spanFor(ctx, "ReserveRevision", treeAttr(req.MapId))
+	defer spanEnd()
+	if err := t.mapServer.checkWritable("ReserveRevision"); err != nil {
+		return nil, err
+	}
+	// Queued writes are merged at whichever revision is next, so they can't
+	// honour reservations.
+	if t.mapServer.opts.QueueWrites {
+		return nil, errmsg.New(codes.FailedPrecondition, errmsg.QueuedWriteUnsupported, errmsg.Params{"field": "ReserveRevision"})
+	}
+	lease := time.Duration(req.LeaseDurationNanos)
+	switch {
+	case lease < 0:
+		return nil, errNegative("ReserveMapRevisionRequest.LeaseDurationNanos", req.LeaseDurationNanos)
+	case lease == 0:
+		lease = defaultRevisionLease
+	case lease > maxRevisionLease:
+		return nil, errmsg.New(codes.InvalidArgument, errmsg.RevisionLeaseTooLong, errmsg.Params{"got": lease, "max": maxRevisionLease})
+	}
+	reserver, ok := t.registry.MapStorage.(storage.MapRevisionReserver)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "map storage does not support revision reservations")
+	}
+	ctx, finish := t.mapServer.withRequestTimeout(ctx, req.MapId)
+	defer func() { err = finish(err) }()
+
+	tree, err := trees.GetTree(ctx, t.registry.AdminStorage, req.MapId, optsMapWrite)
 	if err != nil {
 		return nil, err
 	}
-	root, err := rootVerifier.VerifySignedMapRoot(resp.MapRoot)
+	token := make([]byte, revisionLeaseTokenSize)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	expiry := now.Add(lease)
+	rev, err := reserver.ReserveMapRevision(trees.NewContext(ctx, tree), tree, token, now, expiry)
 	if err != nil {
 		return nil, err
 	}
-	return &trillian.WriteMapLeavesResponse{Revision: int64(root.Revision)}, nil
+	glog.V(2).Infof("%v: Reserved revision %v until %v", tree.TreeId, rev, expiry)
+	return &trillian.ReserveMapRevisionResponse{
+		Revision:         rev,
+		LeaseToken:       token,
+		LeaseExpiryNanos: expiry.UnixNano(),
+	}, nil
+}
+
+// GetHotKeys implements the GetHotKeys write RPC method.
+func (t *TrillianMapWriteServer) GetHotKeys(ctx context.Context, req *trillian.GetMapHotKeysRequest) (*trillian.GetMapHotKeysResponse, error) {
+	ctx, spanEnd := spanFor(ctx, "GetHotKeys", treeAttr(req.MapId))
+	defer spanEnd()
+	if req.Limit < 0 {
+		return nil, errNegative("GetMapHotKeysRequest.Limit", int64(req.Limit))
+	}
+	if t.mapServer.opts.HotKeys.PrefixBytes <= 0 {
+		return nil, status.Error(codes.FailedPrecondition, "the server does not count writes by index prefix")
+	}
+	if _, err := trees.GetTree(ctx, t.registry.AdminStorage, req.MapId, optsMapRead); err != nil {
+		return nil, err
+	}
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = defaultHotKeysLimit
+	}
+	return t.mapServer.hotKeys.get(req.MapId, limit), nil
 }
 
-// IsHealthy returns nil if the server is healthy, error otherwise.
-func (t *TrillianMapWriteServer) IsHealthy() error {
-	return t.mapServer.IsHealthy()
+// deleteRangeLimit returns the maximum number of leaves cleared by each
+// DeleteLeafRange request.
+func (t *TrillianMapWriteServer) deleteRangeLimit() int {
+	if max := t.mapServer.opts.Limits.MaxSetLeaves; max > 0 {
+		return max
+	}
+	return defaultDeleteRangeLeaves
+}
+
+// listRange returns the first limit leaves with non-empty values whose
+// indexes start with prefix at revision, and whether there are more. The range
+// is read a page at a time, so that large ranges are not read into memory.
+func listRange(ctx context.Context, tx storage.ReadOnlyMapTreeTX, revision int64, prefix []byte, limit int) ([]*trillian.MapLeaf, bool, error) {
+	var ret []*trillian.MapLeaf
+	// A proper prefix of an index sorts before all the indexes it prefixes.
+	after := prefix
+	for {
+		leaves, err := tx.List(ctx, revision, after, maxListPageSize)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, l := range leaves {
+			if !bytes.HasPrefix(l.Index, prefix) {
+				return ret, false, nil
+			}
+			// Leaves which were already deleted need not be deleted again.
+			if len(l.LeafValue) == 0 {
+				continue
+			}
+			if len(ret) == limit {
+				return ret, true, nil
+			}
+			ret = append(ret, l)
+		}
+		if len(leaves) < maxListPageSize {
+			return ret, false, nil
+		}
+		after = leaves[len(leaves)-1].Index
+	}
 }
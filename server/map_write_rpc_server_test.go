@@ -0,0 +1,464 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/server/errmsg"
+	"github.com/google/trillian/storage"
+	stestonly "github.com/google/trillian/storage/testonly"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDeleteLeafRange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	index := func(first, rest byte) []byte { return append([]byte{first}, bytes.Repeat([]byte{rest}, 31)...) }
+	listed := []*trillian.MapLeaf{
+		{Index: index(1, 0), LeafValue: []byte("a")},
+		{Index: index(1, 1)}, // Already deleted.
+		{Index: index(1, 2), LeafValue: []byte("b"), ExtraData: []byte("extra")},
+		{Index: index(2, 0), LeafValue: []byte("c")},
+	}
+
+	tx := storage.NewMockMapTreeTX(ctrl)
+	tx.EXPECT().WriteRevision(gomock.Any()).Return(int64(3), nil)
+	tx.EXPECT().List(gomock.Any(), int64(2), []byte{1}, maxListPageSize).Return(listed, nil)
+	var deleted [][]byte
+	tx.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Times(2).DoAndReturn(
+		func(_ context.Context, index []byte, l *trillian.MapLeaf) error {
+			if len(l.LeafValue) != 0 || len(l.ExtraData) != 0 {
+				t.Errorf("Set(%x): got value %q and extra data %q, want empty", index, l.LeafValue, l.ExtraData)
+			}
+			deleted = append(deleted, index)
+			return nil
+		})
+	tx.EXPECT().GetMerkleNodes(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil, nil)
+	tx.EXPECT().SetMerkleNodes(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	tx.EXPECT().StoreSignedMapRoot(gomock.Any(), gomock.Any()).Return(nil)
+	tx.EXPECT().Commit(gomock.Any()).Return(nil)
+	tx.EXPECT().Close().Return(nil)
+
+	registry := extension.Registry{
+		AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+		MapStorage:   &stestonly.FakeMapStorage{TX: tx},
+	}
+	mapServer := NewTrillianMapServer(registry, TrillianMapServerOptions{UseSingleTransaction: true})
+	writeServer := NewTrillianMapWriteServer(registry, mapServer)
+
+	rsp, err := writeServer.DeleteLeafRange(context.Background(), &trillian.DeleteMapLeafRangeRequest{MapId: mapID1, IndexPrefix: []byte{1}})
+	if err != nil {
+		t.Fatalf("DeleteLeafRange(): %v", err)
+	}
+	if got, want := rsp.Revision, int64(3); got != want {
+		t.Errorf("DeleteLeafRange().Revision=%v, want %v", got, want)
+	}
+	if got, want := rsp.DeletedCount, int64(2); got != want {
+		t.Errorf("DeleteLeafRange().DeletedCount=%v, want %v", got, want)
+	}
+	if len(deleted) != 2 || !bytes.Equal(deleted[0], listed[0].Index) || !bytes.Equal(deleted[1], listed[2].Index) {
+		t.Errorf("deleted indexes %x, want %x and %x", deleted, listed[0].Index, listed[2].Index)
+	}
+}
+
+func TestDeleteLeafRange_Limit(t *testing.T) {
+	leaf := func(first byte, i int, value string) *trillian.MapLeaf {
+		index := make([]byte, 32)
+		index[0] = first
+		binary.BigEndian.PutUint32(index[28:], uint32(i))
+		return &trillian.MapLeaf{Index: index, LeafValue: []byte(value)}
+	}
+	// The pages of leaves listed under prefix 1 are created anew for each
+	// test, as deleting them clears them.
+	onePage := func() [][]*trillian.MapLeaf {
+		return [][]*trillian.MapLeaf{{
+			leaf(1, 0, "a"),
+			leaf(1, 1, ""), // Already deleted, so not counted.
+			leaf(1, 2, "b"),
+			leaf(1, 3, "c"),
+		}}
+	}
+	// twoPages has a full page of deleted leaves but the first one, and then
+	// one more leaf under the prefix.
+	twoPages := func() [][]*trillian.MapLeaf {
+		first := make([]*trillian.MapLeaf, maxListPageSize)
+		for i := range first {
+			first[i] = leaf(1, i, "")
+		}
+		first[0].LeafValue = []byte("a")
+		return [][]*trillian.MapLeaf{first, {leaf(1, maxListPageSize, "b"), leaf(2, 0, "c")}}
+	}
+
+	for _, test := range []struct {
+		desc        string
+		pages       func() [][]*trillian.MapLeaf
+		maxLeaves   int
+		wantDeleted int64
+		wantMore    bool
+	}{
+		{desc: "unlimited", pages: onePage, wantDeleted: 3},
+		{desc: "at limit", pages: onePage, maxLeaves: 3, wantDeleted: 3},
+		{desc: "over limit", pages: onePage, maxLeaves: 2, wantDeleted: 2, wantMore: true},
+		{desc: "pages", pages: twoPages, wantDeleted: 2},
+		{desc: "pages over limit", pages: twoPages, maxLeaves: 1, wantDeleted: 1, wantMore: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tx := storage.NewMockMapTreeTX(ctrl)
+			tx.EXPECT().WriteRevision(gomock.Any()).Return(int64(3), nil)
+			after := []byte{1}
+			for _, page := range test.pages() {
+				tx.EXPECT().List(gomock.Any(), int64(2), after, maxListPageSize).Return(page, nil)
+				after = page[len(page)-1].Index
+			}
+			tx.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Times(int(test.wantDeleted)).Return(nil)
+			tx.EXPECT().GetMerkleNodes(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil, nil)
+			tx.EXPECT().SetMerkleNodes(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+			tx.EXPECT().StoreSignedMapRoot(gomock.Any(), gomock.Any()).Return(nil)
+			tx.EXPECT().Commit(gomock.Any()).Return(nil)
+			tx.EXPECT().Close().Return(nil)
+
+			registry := extension.Registry{
+				AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+				MapStorage:   &stestonly.FakeMapStorage{TX: tx},
+			}
+			mapServer := NewTrillianMapServer(registry, TrillianMapServerOptions{
+				UseSingleTransaction: true,
+				Limits:               MapLimits{MaxSetLeaves: test.maxLeaves},
+			})
+			writeServer := NewTrillianMapWriteServer(registry, mapServer)
+
+			rsp, err := writeServer.DeleteLeafRange(context.Background(), &trillian.DeleteMapLeafRangeRequest{MapId: mapID1, IndexPrefix: []byte{1}})
+			if err != nil {
+				t.Fatalf("DeleteLeafRange(): %v", err)
+			}
+			if got, want := rsp.DeletedCount, test.wantDeleted; got != want {
+				t.Errorf("DeleteLeafRange().DeletedCount=%v, want %v", got, want)
+			}
+			if got, want := rsp.More, test.wantMore; got != want {
+				t.Errorf("DeleteLeafRange().More=%v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDeleteLeafRange_Invalid(t *testing.T) {
+	for _, test := range []struct {
+		desc       string
+		req        *trillian.DeleteMapLeafRangeRequest
+		readOnly   bool
+		limits     MapLimits
+		wantCode   codes.Code
+		wantReason errmsg.Reason
+	}{
+		{
+			desc:       "empty prefix",
+			req:        &trillian.DeleteMapLeafRangeRequest{MapId: mapID1},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.FieldEmpty,
+		},
+		{
+			desc:       "prefix too long",
+			req:        &trillian.DeleteMapLeafRangeRequest{MapId: mapID1, IndexPrefix: make([]byte, 32)},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.MapIndexPrefixTooLong,
+		},
+		{
+			desc:       "negative revision",
+			req:        &trillian.DeleteMapLeafRangeRequest{MapId: mapID1, IndexPrefix: []byte{1}, ExpectRevision: -1},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.FieldNegative,
+		},
+		{
+			desc:       "too large",
+			req:        &trillian.DeleteMapLeafRangeRequest{MapId: mapID1, IndexPrefix: []byte{1}, Metadata: make([]byte, 100)},
+			limits:     MapLimits{MaxRequestBytes: 50},
+			wantCode:   codes.ResourceExhausted,
+			wantReason: errmsg.RequestTooLarge,
+		},
+		{
+			desc:       "read only",
+			req:        &trillian.DeleteMapLeafRangeRequest{MapId: mapID1, IndexPrefix: []byte{1}},
+			readOnly:   true,
+			wantCode:   codes.FailedPrecondition,
+			wantReason: errmsg.ServerReadOnly,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			registry := extension.Registry{
+				AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+				MapStorage:   &stestonly.FakeMapStorage{},
+			}
+			mapServer := NewTrillianMapServer(registry, TrillianMapServerOptions{ReadOnly: test.readOnly, Limits: test.limits})
+			writeServer := NewTrillianMapWriteServer(registry, mapServer)
+
+			_, err := writeServer.DeleteLeafRange(context.Background(), test.req)
+			if got, want := status.Code(err), test.wantCode; got != want {
+				t.Fatalf("DeleteLeafRange(): %v, want code %v", err, want)
+			}
+			if info := errmsg.Info(err); info == nil || info.Reason != string(test.wantReason) {
+				t.Errorf("DeleteLeafRange(): %v, want reason %v", err, test.wantReason)
+			}
+		})
+	}
+}
+
+// leaseStorage reserves revisions from next onwards, and holds a single lease.
+type leaseStorage struct {
+	stestonly.FakeMapStorage
+	next  int64
+	lease *storage.MapRevisionLease
+}
+
+func (s *leaseStorage) ReserveMapRevision(ctx context.Context, tree *trillian.Tree, token []byte, now, expiry time.Time) (int64, error) {
+	s.lease = &storage.MapRevisionLease{Revision: s.next, Token: token, Expiry: expiry}
+	s.next++
+	return s.lease.Revision, nil
+}
+
+func (s *leaseStorage) GetMapRevisionLease(ctx context.Context, tree *trillian.Tree, revision int64, now time.Time) (*storage.MapRevisionLease, error) {
+	if s.lease == nil || s.lease.Revision != revision || !now.Before(s.lease.Expiry) {
+		return nil, nil
+	}
+	return s.lease, nil
+}
+
+func TestReserveRevision(t *testing.T) {
+	for _, test := range []struct {
+		desc       string
+		req        *trillian.ReserveMapRevisionRequest
+		noReserver bool
+		queue      bool
+		wantLease  time.Duration
+		wantCode   codes.Code
+		wantReason errmsg.Reason
+	}{
+		{desc: "default lease", req: &trillian.ReserveMapRevisionRequest{MapId: mapID1}, wantLease: defaultRevisionLease},
+		{desc: "lease", req: &trillian.ReserveMapRevisionRequest{MapId: mapID1, LeaseDurationNanos: int64(time.Hour)}, wantLease: time.Hour},
+		{
+			desc:       "negative lease",
+			req:        &trillian.ReserveMapRevisionRequest{MapId: mapID1, LeaseDurationNanos: -1},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.FieldNegative,
+		},
+		{
+			desc:       "lease too long",
+			req:        &trillian.ReserveMapRevisionRequest{MapId: mapID1, LeaseDurationNanos: int64(maxRevisionLease + 1)},
+			wantCode:   codes.InvalidArgument,
+			wantReason: errmsg.RevisionLeaseTooLong,
+		},
+		{
+			desc:       "queued writes",
+			req:        &trillian.ReserveMapRevisionRequest{MapId: mapID1},
+			queue:      true,
+			wantCode:   codes.FailedPrecondition,
+			wantReason: errmsg.QueuedWriteUnsupported,
+		},
+		{
+			desc:       "storage without reservations",
+			req:        &trillian.ReserveMapRevisionRequest{MapId: mapID1},
+			noReserver: true,
+			wantCode:   codes.Unimplemented,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ls := &leaseStorage{next: 4}
+			registry := extension.Registry{
+				AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+				MapStorage:   ls,
+			}
+			if test.noReserver {
+				registry.MapStorage = &stestonly.FakeMapStorage{}
+			}
+			mapServer := NewTrillianMapServer(registry, TrillianMapServerOptions{QueueWrites: test.queue})
+			writeServer := NewTrillianMapWriteServer(registry, mapServer)
+
+			start := time.Now()
+			rsp, err := writeServer.ReserveRevision(context.Background(), test.req)
+			if got, want := status.Code(err), test.wantCode; got != want {
+				t.Fatalf("ReserveRevision(): %v, want code %v", err, want)
+			}
+			if test.wantReason != "" {
+				if info := errmsg.Info(err); info == nil || info.Reason != string(test.wantReason) {
+					t.Errorf("ReserveRevision(): %v, want reason %v", err, test.wantReason)
+				}
+			}
+			if err != nil {
+				return
+			}
+			if got, want := rsp.Revision, int64(4); got != want {
+				t.Errorf("ReserveRevision().Revision=%v, want %v", got, want)
+			}
+			if got, want := len(rsp.LeaseToken), revisionLeaseTokenSize; got != want {
+				t.Errorf("ReserveRevision() returned %d byte token, want %d", got, want)
+			}
+			if !bytes.Equal(ls.lease.Token, rsp.LeaseToken) {
+				t.Errorf("ReserveRevision() stored token %x, returned %x", ls.lease.Token, rsp.LeaseToken)
+			}
+			expiry := time.Unix(0, rsp.LeaseExpiryNanos)
+			if expiry.Before(start.Add(test.wantLease)) || expiry.After(time.Now().Add(test.wantLease)) {
+				t.Errorf("ReserveRevision().LeaseExpiryNanos=%v, want %v after the request", expiry, test.wantLease)
+			}
+		})
+	}
+}
+
+func TestSetLeaves_RevisionLease(t *testing.T) {
+	token := []byte("token")
+	for _, test := range []struct {
+		desc     string
+		lease    []byte
+		expired  bool
+		wantCode codes.Code
+	}{
+		{desc: "holder", lease: token},
+		{desc: "no lease", wantCode: codes.FailedPrecondition},
+		{desc: "other lease", lease: []byte("other"), wantCode: codes.FailedPrecondition},
+		{desc: "expired", expired: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tx := storage.NewMockMapTreeTX(ctrl)
+			tx.EXPECT().WriteRevision(gomock.Any()).Return(int64(3), nil)
+			if test.wantCode == codes.OK {
+				tx.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				tx.EXPECT().GetMerkleNodes(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil, nil)
+				tx.EXPECT().SetMerkleNodes(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+				tx.EXPECT().StoreSignedMapRoot(gomock.Any(), gomock.Any()).Return(nil)
+				tx.EXPECT().Commit(gomock.Any()).Return(nil)
+			}
+			tx.EXPECT().Close().Return(nil)
+
+			expiry := time.Now().Add(time.Minute)
+			if test.expired {
+				expiry = time.Now()
+			}
+			ls := &leaseStorage{
+				FakeMapStorage: stestonly.FakeMapStorage{TX: tx},
+				lease:          &storage.MapRevisionLease{Revision: 3, Token: token, Expiry: expiry},
+			}
+			server := NewTrillianMapServer(extension.Registry{
+				AdminStorage: fakeAdminStorageForMap(ctrl, 1, mapID1),
+				MapStorage:   ls,
+			}, TrillianMapServerOptions{UseSingleTransaction: true})
+
+			_, err := server.SetLeaves(context.Background(), &trillian.SetMapLeavesRequest{
+				MapId:         mapID1,
+				Leaves:        []*trillian.MapLeaf{{Index: make([]byte, 32), LeafValue: []byte("value")}},
+				RevisionLease: test.lease,
+			})
+			if got, want := status.Code(err), test.wantCode; got != want {
+				t.Fatalf("SetLeaves(): %v, want code %v", err, want)
+			}
+			if err != nil {
+				if info := errmsg.Info(err); info == nil || info.Reason != string(errmsg.RevisionReserved) {
+					t.Errorf("SetLeaves(): %v, want reason %v", err, errmsg.RevisionReserved)
+				}
+			}
+		})
+	}
+}
+
+func TestGetHotKeys(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		desc     string
+		opts     MapHotKeyOptions
+		req      *trillian.GetMapHotKeysRequest
+		wantTree bool
+		wantCode codes.Code
+		want     []string
+	}{
+		{
+			desc:     "default limit",
+			opts:     MapHotKeyOptions{PrefixBytes: 1},
+			req:      &trillian.GetMapHotKeysRequest{MapId: mapID1},
+			wantTree: true,
+			want:     []string{"a", "b"},
+		},
+		{
+			desc:     "limit",
+			opts:     MapHotKeyOptions{PrefixBytes: 1},
+			req:      &trillian.GetMapHotKeysRequest{MapId: mapID1, Limit: 1},
+			wantTree: true,
+			want:     []string{"a"},
+		},
+		{
+			desc:     "disabled",
+			req:      &trillian.GetMapHotKeysRequest{MapId: mapID1},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			desc:     "negative limit",
+			opts:     MapHotKeyOptions{PrefixBytes: 1},
+			req:      &trillian.GetMapHotKeysRequest{MapId: mapID1, Limit: -1},
+			wantCode: codes.InvalidArgument,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			times := 0
+			if tc.wantTree {
+				times = 1
+			}
+			registry := extension.Registry{
+				AdminStorage: fakeAdminStorageForMap(ctrl, times, mapID1),
+				MapStorage:   &stestonly.FakeMapStorage{},
+			}
+			mapServer := NewTrillianMapServer(registry, TrillianMapServerOptions{HotKeys: tc.opts})
+			mapServer.hotKeys.record(mapID1, leavesUnder("a", 2))
+			mapServer.hotKeys.record(mapID1, leavesUnder("b", 1))
+			writeServer := NewTrillianMapWriteServer(registry, mapServer)
+
+			resp, err := writeServer.GetHotKeys(ctx, tc.req)
+			if got := status.Code(err); got != tc.wantCode {
+				t.Fatalf("GetHotKeys(): %v, want code %v", err, tc.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			var got []string
+			for _, k := range resp.HotKeys {
+				got = append(got, string(k.IndexPrefix))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("GetHotKeys(): got prefixes %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
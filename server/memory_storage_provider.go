@@ -22,7 +22,7 @@ import (
 )
 
 func init() {
-	if err := RegisterStorageProvider("memory", newMemoryStorageProvider); err != nil {
+	if err := storage.RegisterProvider("memory", newMemoryStorageProvider); err != nil {
 		glog.Fatalf("Failed to register storage provider memory: %v", err)
 	}
 }
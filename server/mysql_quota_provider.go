@@ -27,7 +27,7 @@ const QuotaMySQL = "mysql"
 
 var (
 	maxUnsequencedRows = flag.Int("max_unsequenced_rows", mysqlqm.DefaultMaxUnsequenced, "Max number of unsequenced rows before rate limiting kicks in. "+
-		"Only effective for quota_system=mysql.")
+		"Only effective for quota_system=mysql, quota_system=postgres and quota_system=crdb.")
 )
 
 func init() {
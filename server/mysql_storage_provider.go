@@ -33,38 +33,78 @@ var (
 	maxConns = flag.Int("mysql_max_conns", 0, "Maximum connections to the database")
 	maxIdle  = flag.Int("mysql_max_idle_conns", -1, "Maximum idle database connections in the connection pool")
 
+	mySQLConnMaxLifetime    = flag.Duration("mysql_conn_max_lifetime", 0, "If positive, how long connections to the database are reused for")
+	mySQLStatementCacheSize = flag.Int("mysql_statement_cache_size", 0, "If positive, the maximum number of prepared statements cached per database. Unlimited otherwise")
+	mySQLSubtreeBatchSize   = flag.Int("mysql_subtree_write_batch_size", 0, "If positive, the maximum number of Merkle subtrees written by each statement when a transaction commits. 1000 otherwise")
+
+	mySQLReplicaURI          = flag.String("mysql_replica_uri", "", "Connection URI for a read replica of the MySQL database, on which read-only snapshots run. Unused if empty")
+	mySQLReplicaMaxStaleness = flag.Duration("mysql_replica_max_staleness", 0, "If positive, snapshots of trees whose latest root on the replica is older than this run on the primary database")
+
 	mysqlOnce            sync.Once
 	mysqlOnceErr         error
 	mySQLstorageInstance *mysqlProvider
 )
 
 func init() {
-	if err := RegisterStorageProvider("mysql", newMySQLStorageProvider); err != nil {
+	if err := storage.RegisterProvider("mysql", newMySQLStorageProvider); err != nil {
 		glog.Fatalf("Failed to register storage provider mysql: %v", err)
 	}
 }
 
 type mysqlProvider struct {
-	db *sql.DB
-	mf monitoring.MetricFactory
+	db          *sql.DB
+	mf          monitoring.MetricFactory
+	opts        mysql.TreeStorageOptions
+	stopMetrics []func()
+}
+
+// openMySQL opens the database at uri, with the pool options set by the flags.
+func openMySQL(uri string) (*sql.DB, error) {
+	db, err := mysql.OpenDB(uri)
+	if err != nil {
+		return nil, err
+	}
+	poolOptions(*maxConns, *maxIdle, *mySQLConnMaxLifetime).Apply(db)
+	return db, nil
 }
 
 func newMySQLStorageProvider(mf monitoring.MetricFactory) (StorageProvider, error) {
 	mysqlOnce.Do(func() {
+		opts := mysql.TreeStorageOptions{
+			QueryTags:             *queryTags,
+			StatementCacheSize:    *mySQLStatementCacheSize,
+			SubtreeWriteBatchSize: *mySQLSubtreeBatchSize,
+		}
+		opts.SubtreeCodec, mysqlOnceErr = subtreeCodecFromFlags(mysql.SubtreeCodecs)
+		if mysqlOnceErr != nil {
+			return
+		}
 		var db *sql.DB
-		db, mysqlOnceErr = mysql.OpenDB(*mySQLURI)
+		db, mysqlOnceErr = openMySQL(*mySQLURI)
 		if mysqlOnceErr != nil {
 			return
 		}
-		if *maxConns > 0 {
-			db.SetMaxOpenConns(*maxConns)
+		if mysqlOnceErr = checkSchema("mysql", db, mysql.Schema); mysqlOnceErr != nil {
+			db.Close()
+			return
+		}
+		if *mySQLReplicaURI != "" {
+			opts.MaxReplicaStaleness = *mySQLReplicaMaxStaleness
+			opts.Replica, mysqlOnceErr = openMySQL(*mySQLReplicaURI)
+			if mysqlOnceErr != nil {
+				db.Close()
+				return
+			}
 		}
-		if *maxIdle >= 0 {
-			db.SetMaxIdleConns(*maxIdle)
+		stopMetrics := []func(){exportPoolMetrics(mf, "mysql", db)}
+		if opts.Replica != nil {
+			stopMetrics = append(stopMetrics, exportPoolMetrics(mf, "mysql_replica", opts.Replica))
 		}
 		mySQLstorageInstance = &mysqlProvider{
-			db: db,
-			mf: mf,
+			db:          db,
+			mf:          mf,
+			opts:        opts,
+			stopMetrics: stopMetrics,
 		}
 	})
 	if mysqlOnceErr != nil {
@@ -74,11 +114,14 @@ func newMySQLStorageProvider(mf monitoring.MetricFactory) (StorageProvider, erro
 }
 
 func (s *mysqlProvider) LogStorage() storage.LogStorage {
-	return mysql.NewLogStorage(s.db, s.mf)
+	return mysql.NewLogStorageWithOpts(s.db, s.mf, s.opts)
 }
 
 func (s *mysqlProvider) MapStorage() storage.MapStorage {
-	return mysql.NewMapStorage(s.db)
+	if s.mf != nil {
+		mysql.InitMapMetrics(s.mf)
+	}
+	return mysql.NewMapStorageWithOpts(s.db, s.opts)
 }
 
 func (s *mysqlProvider) AdminStorage() storage.AdminStorage {
@@ -86,5 +129,13 @@ func (s *mysqlProvider) AdminStorage() storage.AdminStorage {
 }
 
 func (s *mysqlProvider) Close() error {
+	for _, stop := range s.stopMetrics {
+		stop()
+	}
+	if s.opts.Replica != nil {
+		if err := s.opts.Replica.Close(); err != nil {
+			glog.Warningf("Failed to close MySQL replica: %v", err)
+		}
+	}
 	return s.db.Close()
 }
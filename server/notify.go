@@ -0,0 +1,40 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/google/trillian/server/notify"
+)
+
+var (
+	treeEventWebhookURL     = flag.String("tree_event_webhook_url", "", "If set, an event is POSTed as JSON to this URL whenever the state of a tree is changed through the admin API")
+	treeEventWebhookTimeout = flag.Duration("tree_event_webhook_timeout", 10*time.Second, "Timeout of each POST to --tree_event_webhook_url")
+)
+
+// NewTreeNotifierFromFlags returns the notifier of tree state changes
+// configured by flags, or nil if there is none.
+func NewTreeNotifierFromFlags() notify.Notifier {
+	if *treeEventWebhookURL == "" {
+		return nil
+	}
+	return &notify.Webhook{
+		URL:    *treeEventWebhookURL,
+		Client: &http.Client{Timeout: *treeEventWebhookTimeout},
+	}
+}
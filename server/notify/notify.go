@@ -0,0 +1,70 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify delivers events describing changes of the state of trees to
+// external systems, e.g. provisioning systems, so that they can react to them
+// without polling ListTrees.
+package notify
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/trillian"
+)
+
+// Kind is what happened to a tree.
+type Kind string
+
+const (
+	// StateChanged means the tree_state of the tree changed.
+	StateChanged Kind = "STATE_CHANGED"
+	// Deleted means the tree was soft-deleted.
+	Deleted Kind = "DELETED"
+	// Undeleted means the tree was undeleted.
+	Undeleted Kind = "UNDELETED"
+	// Purged means the tree was hard-deleted.
+	Purged Kind = "PURGED"
+)
+
+// Event describes a change of the state of a tree.
+type Event struct {
+	Kind Kind `json:"kind"`
+	// Time is the time at which the change was made.
+	Time     time.Time `json:"time"`
+	TreeID   int64     `json:"tree_id"`
+	TreeType string    `json:"tree_type"`
+	// TreeState is the state of the tree after the change.
+	TreeState string `json:"tree_state"`
+	// PreviousTreeState is the state of the tree before a StateChanged event.
+	PreviousTreeState string `json:"previous_tree_state,omitempty"`
+}
+
+// NewEvent returns an event of kind for tree, which is in its state after the
+// change.
+func NewEvent(kind Kind, tree *trillian.Tree, now time.Time) *Event {
+	return &Event{
+		Kind:      kind,
+		Time:      now,
+		TreeID:    tree.TreeId,
+		TreeType:  tree.TreeType.String(),
+		TreeState: tree.TreeState.String(),
+	}
+}
+
+// Notifier delivers events to an external system, e.g. by POSTing them to a
+// webhook or publishing them to a Pub/Sub topic.
+type Notifier interface {
+	Notify(ctx context.Context, event *Event) error
+}
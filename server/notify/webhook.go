@@ -0,0 +1,86 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// webhookAttempts is the number of times an event is POSTed to a webhook
+// which fails.
+const webhookAttempts = 3
+
+// webhookRetryDelay is the delay before the first retry, which doubles for
+// each subsequent one.
+var webhookRetryDelay = time.Second
+
+// Webhook POSTs each event as a JSON object to a URL. Events which get an
+// error or a non-2xx response are retried a few times.
+type Webhook struct {
+	URL string
+	// Client is used to make the requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Notify implements Notifier.
+func (w *Webhook) Notify(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	delay := webhookRetryDelay
+	for attempt := 1; ; attempt++ {
+		err = w.post(ctx, body)
+		if err == nil || attempt == webhookAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	rsp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	// Drain the body, so that the connection can be reused.
+	io.Copy(ioutil.Discard, rsp.Body)
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return fmt.Errorf("POST %s: %s", w.URL, rsp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+)
+
+func TestWebhook_Notify(t *testing.T) {
+	tree := &trillian.Tree{TreeId: 10, TreeType: trillian.TreeType_LOG, TreeState: trillian.TreeState_FROZEN}
+	event := NewEvent(StateChanged, tree, time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC))
+	event.PreviousTreeState = trillian.TreeState_ACTIVE.String()
+
+	defer func(d time.Duration) { webhookRetryDelay = d }(webhookRetryDelay)
+	webhookRetryDelay = time.Millisecond
+
+	for _, test := range []struct {
+		desc      string
+		failures  int
+		wantPosts int
+		wantErr   bool
+	}{
+		{desc: "ok", wantPosts: 1},
+		{desc: "retried", failures: 1, wantPosts: 2},
+		{desc: "failed", failures: webhookAttempts, wantPosts: webhookAttempts, wantErr: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var posts int
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				posts++
+				if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("got %s request with Content-Type %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+				}
+				var got Event
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Errorf("Decode(): %v", err)
+				}
+				if !reflect.DeepEqual(&got, event) {
+					t.Errorf("got event %+v, want %+v", got, event)
+				}
+				if posts <= test.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+			defer s.Close()
+
+			w := &Webhook{URL: s.URL}
+			err := w.Notify(context.Background(), event)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("Notify() returned err = %v, wantErr %v", err, test.wantErr)
+			}
+			if posts != test.wantPosts {
+				t.Errorf("Notify() made %d posts, want %d", posts, test.wantPosts)
+			}
+		})
+	}
+}
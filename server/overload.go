@@ -0,0 +1,55 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"flag"
+	"time"
+
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/server/interceptor"
+	"github.com/google/trillian/util/clock"
+)
+
+var (
+	maxInFlightPerTree  = flag.Int("max_in_flight_per_tree", 0, "Maximum number of requests for a single tree handled concurrently, after which its requests fail with ResourceExhausted. Zero means unlimited")
+	overloadTimeoutRate = flag.Float64("overload_timeout_rate", 0, "Fraction of the requests for a tree in --overload_window which may time out before its requests fail with ResourceExhausted for --overload_cooldown. Zero disables the circuit breaker")
+	overloadMinRequests = flag.Int("overload_min_requests", 20, "Number of requests for a tree in --overload_window below which its timeout rate is not considered")
+	overloadWindow      = flag.Duration("overload_window", 10*time.Second, "Period over which the timeout rate of each tree is measured")
+	overloadCooldown    = flag.Duration("overload_cooldown", 5*time.Second, "Period for which the requests for a tree are shed once its circuit breaker opens")
+)
+
+// NewOverloadBreakerFromFlags returns an OverloadBreaker configured by flags.
+// It returns nil if no overload limit is set.
+func NewOverloadBreakerFromFlags(registry extension.Registry) (*interceptor.OverloadBreaker, error) {
+	limits := interceptor.OverloadLimits{
+		MaxInFlight: *maxInFlightPerTree,
+		TimeoutRate: *overloadTimeoutRate,
+		MinRequests: *overloadMinRequests,
+		Window:      *overloadWindow,
+		Cooldown:    *overloadCooldown,
+	}
+	if !limits.Enabled() {
+		return nil, nil
+	}
+	if limits.TimeoutRate > 1 {
+		return nil, errors.New("--overload_timeout_rate must be at most 1")
+	}
+	if limits.TimeoutRate > 0 && (limits.Window <= 0 || limits.Cooldown <= 0) {
+		return nil, errors.New("--overload_timeout_rate requires a positive --overload_window and --overload_cooldown")
+	}
+	return interceptor.NewOverloadBreaker(limits, clock.System, registry.MetricFactory), nil
+}
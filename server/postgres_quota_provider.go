@@ -0,0 +1,58 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/golang/glog"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/quota/postgresqm"
+)
+
+const (
+	// QuotaPostgres represents the PostgreSQL quota implementation.
+	QuotaPostgres = "postgres"
+	// QuotaCockroachDB represents the PostgreSQL quota implementation for
+	// CockroachDB storage.
+	QuotaCockroachDB = "crdb"
+)
+
+func init() {
+	if err := RegisterQuotaManager(QuotaPostgres, newPostgresQuotaManager); err != nil {
+		glog.Fatalf("Failed to register quota manager %v: %v", QuotaPostgres, err)
+	}
+	if err := RegisterQuotaManager(QuotaCockroachDB, newCockroachDBQuotaManager); err != nil {
+		glog.Fatalf("Failed to register quota manager %v: %v", QuotaCockroachDB, err)
+	}
+}
+
+func newPostgresQuotaManager() (quota.Manager, error) {
+	qm := &postgresqm.QuotaManager{
+		DB:                 pgStorageInstance.db,
+		MaxUnsequencedRows: *maxUnsequencedRows,
+	}
+	glog.Info("Using PostgreSQL QuotaManager")
+	return qm, nil
+}
+
+func newCockroachDBQuotaManager() (quota.Manager, error) {
+	qm := &postgresqm.QuotaManager{
+		DB:                 crdbStorageInstance.db,
+		MaxUnsequencedRows: *maxUnsequencedRows,
+		// CockroachDB doesn't keep the statistics of PostgreSQL.
+		UseSelectCount: true,
+	}
+	glog.Info("Using CockroachDB QuotaManager")
+	return qm, nil
+}
@@ -29,34 +29,54 @@ import (
 )
 
 var (
-	pgConnStr         = flag.String("pg_conn_str", "user=postgres dbname=test port=5432 sslmode=disable", "Connection string for Postgres database")
+	pgConnStr = flag.String("pg_conn_str", "user=postgres dbname=test port=5432 sslmode=disable", "Connection string for Postgres database")
+
+	pgMaxConns           = flag.Int("pg_max_conns", 0, "Maximum connections to the database")
+	pgMaxIdle            = flag.Int("pg_max_idle_conns", -1, "Maximum idle database connections in the connection pool")
+	pgConnMaxLifetime    = flag.Duration("pg_conn_max_lifetime", 0, "If positive, how long connections to the database are reused for")
+	pgStatementCacheSize = flag.Int("pg_statement_cache_size", 0, "If positive, the maximum number of prepared statements cached. Unlimited otherwise")
+
 	pgOnce            sync.Once
 	pgOnceErr         error
 	pgStorageInstance *pgProvider
 )
 
 func init() {
-	if err := RegisterStorageProvider("postgres", newPGProvider); err != nil {
+	if err := storage.RegisterProvider("postgres", newPGProvider); err != nil {
 		glog.Fatalf("Failed to register storage provider postgres: %v", err)
 	}
 }
 
 type pgProvider struct {
-	db *sql.DB
-	mf monitoring.MetricFactory
+	db          *sql.DB
+	mf          monitoring.MetricFactory
+	opts        postgres.TreeStorageOptions
+	stopMetrics func()
 }
 
 func newPGProvider(mf monitoring.MetricFactory) (StorageProvider, error) {
 	pgOnce.Do(func() {
+		opts := postgres.TreeStorageOptions{StatementCacheSize: *pgStatementCacheSize}
+		opts.SubtreeCodec, pgOnceErr = subtreeCodecFromFlags(postgres.SubtreeCodecs)
+		if pgOnceErr != nil {
+			return
+		}
 		var db *sql.DB
 		db, pgOnceErr = postgres.OpenDB(*pgConnStr)
 		if pgOnceErr != nil {
 			return
 		}
+		if pgOnceErr = checkSchema("postgres", db, postgres.Schema); pgOnceErr != nil {
+			db.Close()
+			return
+		}
+		poolOptions(*pgMaxConns, *pgMaxIdle, *pgConnMaxLifetime).Apply(db)
 
 		pgStorageInstance = &pgProvider{
-			db: db,
-			mf: mf,
+			db:          db,
+			mf:          mf,
+			opts:        opts,
+			stopMetrics: exportPoolMetrics(mf, "postgres", db),
 		}
 	})
 	if pgOnceErr != nil {
@@ -66,13 +86,11 @@ func newPGProvider(mf monitoring.MetricFactory) (StorageProvider, error) {
 }
 
 func (s *pgProvider) LogStorage() storage.LogStorage {
-
-	glog.Warningf("Support for the PostgreSQL log is experimental.  Please use at your own risk!!!")
-	return postgres.NewLogStorage(s.db, s.mf)
+	return postgres.NewLogStorageWithOpts(s.db, s.mf, s.opts)
 }
 
 func (s *pgProvider) MapStorage() storage.MapStorage {
-	panic("Not Implemented")
+	return postgres.NewMapStorageWithOpts(s.db, s.opts)
 }
 
 func (s *pgProvider) AdminStorage() storage.AdminStorage {
@@ -80,5 +98,6 @@ func (s *pgProvider) AdminStorage() storage.AdminStorage {
 }
 
 func (s *pgProvider) Close() error {
+	s.stopMetrics()
 	return s.db.Close()
 }
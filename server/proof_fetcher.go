@@ -34,13 +34,18 @@ const proofMaxBitLen = 64
 // revisions. This code only relies on the NodeReader interface so can be tested without
 // a complete storage implementation.
 func fetchNodesAndBuildProof(ctx context.Context, tx storage.NodeReader, th hashers.LogHasher, treeRevision, leafIndex int64, proofNodeFetches []merkle.NodeFetch) (*trillian.Proof, error) {
-	ctx, spanEnd := spanFor(ctx, "fetchNodesAndBuildProof")
+	ctx, spanEnd := spanFor(ctx, "fetchNodesAndBuildProof", revisionAttr(treeRevision), batchAttr(len(proofNodeFetches)))
 	defer spanEnd()
 	proofNodes, err := fetchNodes(ctx, tx, treeRevision, proofNodeFetches)
 	if err != nil {
 		return nil, err
 	}
+	return buildProof(th, leafIndex, proofNodes, proofNodeFetches)
+}
 
+// buildProof converts the fetched proofNodes, in the order of
+// proofNodeFetches, into the proof proto, rehashing them where necessary.
+func buildProof(th hashers.LogHasher, leafIndex int64, proofNodes []tree.Node, proofNodeFetches []merkle.NodeFetch) (*trillian.Proof, error) {
 	r := &rehasher{th: th}
 	for i, node := range proofNodes {
 		r.process(node, proofNodeFetches[i])
@@ -107,7 +112,7 @@ func (r *rehasher) rehashedProof(leafIndex int64) (*trillian.Proof, error) {
 // fetchNodes extracts the NodeIDs from a list of NodeFetch structs and passes them
 // to storage, returning the result after some additional validation checks.
 func fetchNodes(ctx context.Context, tx storage.NodeReader, treeRevision int64, fetches []merkle.NodeFetch) ([]tree.Node, error) {
-	ctx, spanEnd := spanFor(ctx, "fetchNodes")
+	ctx, spanEnd := spanFor(ctx, "fetchNodes", revisionAttr(treeRevision), batchAttr(len(fetches)))
 	defer spanEnd()
 	proofNodeIDs := make([]tree.NodeID, 0, len(fetches))
 
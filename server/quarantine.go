@@ -0,0 +1,53 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"flag"
+	"time"
+
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/server/quarantine"
+	"github.com/google/trillian/util/clock"
+)
+
+// DefaultQuarantineInterval is the suggested min interval between checks of
+// scrub results for trees to quarantine.
+const DefaultQuarantineInterval = 5 * time.Minute
+
+var (
+	quarantineOnScrubFailure = flag.Bool("quarantine_on_scrub_failure", false, "If true, trees which fail an integrity check of the registry's scrubber are moved to the read-only QUARANTINED state, until lifted with the LiftQuarantine RPC")
+	quarantineInterval       = flag.Duration("quarantine_interval", DefaultQuarantineInterval, "Minimum interval between checks of scrub results for trees to quarantine. Actual runs happen randomly between [minInterval,2*minInterval).")
+)
+
+// NewQuarantinerFromFlags returns a Quarantiner of the trees of registry
+// failing the checks of its Scrubber, configured by flags. It returns nil if
+// quarantines are not enabled.
+func NewQuarantinerFromFlags(registry extension.Registry) (*quarantine.Quarantiner, error) {
+	if !*quarantineOnScrubFailure {
+		return nil, nil
+	}
+	if registry.Scrubber == nil {
+		return nil, errors.New("--quarantine_on_scrub_failure requires a scrubber in the extension registry")
+	}
+	return quarantine.NewQuarantiner(quarantine.Config{
+		Admin:          registry.AdminStorage,
+		Scrubber:       registry.Scrubber,
+		MinRunInterval: *quarantineInterval,
+		TimeSource:     clock.System,
+		MetricFactory:  registry.MetricFactory,
+	})
+}
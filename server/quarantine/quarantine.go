@@ -0,0 +1,162 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package quarantine moves trees which failed an integrity check into the
+// read-only QUARANTINED state, so that no more data is written on top of bad
+// data until an administrator has investigated, and lifted the quarantine with
+// the LiftQuarantine RPC.
+package quarantine
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/server/attestation"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util/clock"
+)
+
+// Quarantine moves a tree into the QUARANTINED state, and returns it. Trees
+// which are already quarantined are returned unchanged. Verifiers which find
+// a tree inconsistent can call it directly.
+func Quarantine(ctx context.Context, admin storage.AdminStorage, treeID int64) (*trillian.Tree, error) {
+	var tree *trillian.Tree
+	err := admin.ReadWriteTransaction(ctx, func(ctx context.Context, tx storage.AdminTX) error {
+		var err error
+		if tree, err = tx.GetTree(ctx, treeID); err != nil {
+			return err
+		}
+		if tree.TreeState == trillian.TreeState_QUARANTINED {
+			return nil
+		}
+		tree, err = tx.UpdateTree(ctx, treeID, func(t *trillian.Tree) {
+			t.TreeState = trillian.TreeState_QUARANTINED
+		})
+		return err
+	})
+	return tree, err
+}
+
+// Config holds the dependencies and parameters of a Quarantiner.
+type Config struct {
+	Admin    storage.AdminStorage
+	Scrubber attestation.ScrubReporter
+	// Alert, if set, is called for each tree which is quarantined, in
+	// addition to the error logged and the metric incremented.
+	Alert func(ctx context.Context, tree *trillian.Tree, result attestation.ScrubResult)
+
+	// MinRunInterval defines how frequently scrub results are checked.
+	// Actual runs happen randomly between [minInterval,2*minInterval).
+	MinRunInterval time.Duration
+	TimeSource     clock.TimeSource
+	MetricFactory  monitoring.MetricFactory
+}
+
+// Quarantiner periodically reads the results of a scrubber, and quarantines
+// the trees which failed their integrity checks.
+//
+// Each failed result quarantines its tree once: a tree whose quarantine was
+// lifted is only quarantined again by a later failure. The results acted on
+// are not persisted, so after a restart the latest failed result of a tree
+// quarantines it again, unless the scrubber has since reported it OK.
+type Quarantiner struct {
+	cfg         Config
+	quarantined monitoring.Counter
+	// handled holds the time of the latest failed result acted on, by tree.
+	handled map[int64]time.Time
+}
+
+// NewQuarantiner returns a new Quarantiner.
+func NewQuarantiner(cfg Config) (*Quarantiner, error) {
+	if cfg.Admin == nil {
+		return nil, fmt.Errorf("quarantine: admin storage is required")
+	}
+	if cfg.Scrubber == nil {
+		return nil, fmt.Errorf("quarantine: scrubber is required")
+	}
+	if cfg.MinRunInterval <= 0 {
+		return nil, fmt.Errorf("quarantine: run interval must be positive, got %v", cfg.MinRunInterval)
+	}
+	if cfg.TimeSource == nil {
+		cfg.TimeSource = clock.System
+	}
+	if cfg.MetricFactory == nil {
+		cfg.MetricFactory = monitoring.InertMetricFactory{}
+	}
+	return &Quarantiner{
+		cfg: cfg,
+		quarantined: cfg.MetricFactory.NewCounter(
+			"quarantined_trees",
+			"Number of times trees were quarantined after failing an integrity check",
+			"tree_id"),
+		handled: make(map[int64]time.Time),
+	}, nil
+}
+
+// Run checks scrub results until ctx is cancelled.
+func (q *Quarantiner) Run(ctx context.Context) {
+	for {
+		if _, err := q.RunOnce(ctx); err != nil {
+			glog.Errorf("Quarantiner.Run: %v", err)
+		}
+
+		d := q.cfg.MinRunInterval + time.Duration(rand.Int63n(q.cfg.MinRunInterval.Nanoseconds()))
+		if err := clock.SleepSource(ctx, d, q.cfg.TimeSource); err != nil {
+			return
+		}
+	}
+}
+
+// RunOnce reads the scrub results once, quarantines the trees which newly
+// failed their integrity checks, and returns the IDs of those trees. Trees
+// which can't be quarantined are retried on the next run.
+func (q *Quarantiner) RunOnce(ctx context.Context) ([]int64, error) {
+	results, err := q.cfg.Scrubber.ScrubResults(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading scrub results: %v", err)
+	}
+
+	var ids []int64
+	var firstErr error
+	for _, result := range results {
+		if result.OK {
+			continue
+		}
+		if last, ok := q.handled[result.TreeID]; ok && !result.Time.After(last) {
+			continue
+		}
+		tree, err := Quarantine(ctx, q.cfg.Admin, result.TreeID)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error quarantining tree %d: %v", result.TreeID, err)
+			}
+			continue
+		}
+		q.handled[result.TreeID] = result.Time
+		ids = append(ids, result.TreeID)
+
+		glog.Errorf("ALERT: tree %d quarantined after failing an integrity check at %v: %s", result.TreeID, result.Time, result.Details)
+		q.quarantined.Inc(strconv.FormatInt(result.TreeID, 10))
+		if q.cfg.Alert != nil {
+			q.cfg.Alert(ctx, tree, result)
+		}
+	}
+	return ids, firstErr
+}
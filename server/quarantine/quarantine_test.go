@@ -0,0 +1,134 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quarantine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/server/attestation"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+
+	_ "github.com/google/trillian/crypto/keys/der/proto" // Register PrivateKey ProtoHandler
+	stestonly "github.com/google/trillian/storage/testonly"
+)
+
+type fakeScrubber struct {
+	results []attestation.ScrubResult
+}
+
+func (s *fakeScrubber) ScrubResults(ctx context.Context) ([]attestation.ScrubResult, error) {
+	return s.results, nil
+}
+
+func TestQuarantiner_RunOnce(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	as := memory.NewAdminStorage(memory.NewTreeStorage())
+
+	var ids []int64
+	for _, tree := range []*trillian.Tree{stestonly.LogTree, stestonly.MapTree} {
+		created, err := storage.CreateTree(ctx, as, proto.Clone(tree).(*trillian.Tree))
+		if err != nil {
+			t.Fatalf("CreateTree(): %v", err)
+		}
+		ids = append(ids, created.TreeId)
+	}
+	logID, mapID := ids[0], ids[1]
+
+	scrubber := &fakeScrubber{}
+	var alerted []int64
+	q, err := NewQuarantiner(Config{
+		Admin:    as,
+		Scrubber: scrubber,
+		Alert: func(ctx context.Context, tree *trillian.Tree, result attestation.ScrubResult) {
+			alerted = append(alerted, tree.TreeId)
+		},
+		MinRunInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewQuarantiner(): %v", err)
+	}
+
+	lift := func(treeID int64) {
+		t.Helper()
+		if _, err := storage.UpdateTree(ctx, as, treeID, func(tree *trillian.Tree) {
+			tree.TreeState = trillian.TreeState_ACTIVE
+		}); err != nil {
+			t.Fatalf("UpdateTree(): %v", err)
+		}
+	}
+
+	for _, step := range []struct {
+		desc    string
+		results []attestation.ScrubResult
+		lift    int64
+		want    []int64
+		states  map[int64]trillian.TreeState
+	}{
+		{
+			desc:    "ok",
+			results: []attestation.ScrubResult{{TreeID: logID, Time: now, OK: true}, {TreeID: mapID, Time: now, OK: true}},
+			states:  map[int64]trillian.TreeState{logID: trillian.TreeState_ACTIVE, mapID: trillian.TreeState_ACTIVE},
+		},
+		{
+			desc:    "mapFailed",
+			results: []attestation.ScrubResult{{TreeID: logID, Time: now, OK: true}, {TreeID: mapID, Time: now, Details: "bad root"}},
+			want:    []int64{mapID},
+			states:  map[int64]trillian.TreeState{logID: trillian.TreeState_ACTIVE, mapID: trillian.TreeState_QUARANTINED},
+		},
+		{
+			desc:    "sameFailureAfterLift",
+			results: []attestation.ScrubResult{{TreeID: mapID, Time: now, Details: "bad root"}},
+			lift:    mapID,
+			states:  map[int64]trillian.TreeState{mapID: trillian.TreeState_ACTIVE},
+		},
+		{
+			desc:    "newFailure",
+			results: []attestation.ScrubResult{{TreeID: mapID, Time: now.Add(time.Hour), Details: "bad root"}},
+			want:    []int64{mapID},
+			states:  map[int64]trillian.TreeState{mapID: trillian.TreeState_QUARANTINED},
+		},
+	} {
+		if step.lift != 0 {
+			lift(step.lift)
+		}
+		scrubber.results = step.results
+		got, err := q.RunOnce(ctx)
+		if err != nil {
+			t.Fatalf("%v: RunOnce(): %v", step.desc, err)
+		}
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%v: RunOnce() = %v, want %v", step.desc, got, step.want)
+		}
+		for id, want := range step.states {
+			tree, err := storage.GetTree(ctx, as, id)
+			if err != nil {
+				t.Fatalf("%v: GetTree(%d): %v", step.desc, id, err)
+			}
+			if got := tree.TreeState; got != want {
+				t.Errorf("%v: tree %d state = %v, want %v", step.desc, id, got, want)
+			}
+		}
+	}
+	if want := []int64{mapID, mapID}; !reflect.DeepEqual(alerted, want) {
+		t.Errorf("alerted = %v, want %v", alerted, want)
+	}
+}
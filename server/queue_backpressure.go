@@ -0,0 +1,87 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/google/trillian/quota"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+)
+
+var (
+	backpressureTokens   = flag.Int("queue_backpressure_tokens", 0, "Number of write tokens left for a log, or for all logs, below which QueueLeaves responses ask submitters to back off before queuing more leaves. Zero disables the hints")
+	backpressureMaxDelay = flag.Duration("queue_backpressure_max_delay", 10*time.Second, "Delay suggested by QueueLeaves responses when no write tokens are left, with --queue_backpressure_tokens. More tokens left suggest proportionally shorter delays")
+)
+
+// backpressureDelay returns how long submitters should wait before queuing
+// more leaves, given the number of write tokens left, or zero if they don't
+// need to back off.
+func backpressureDelay(tokens, threshold int, maxDelay time.Duration) time.Duration {
+	if threshold <= 0 || tokens >= threshold {
+		return 0
+	}
+	if tokens <= 0 {
+		return maxDelay
+	}
+	return maxDelay * time.Duration(threshold-tokens) / time.Duration(threshold)
+}
+
+// queueBackpressure returns the backpressure status of QueueLeaves responses
+// for the tree, or nil if submitters don't need to back off. The write tokens
+// left for the tree reflect the backlog of leaves waiting to be sequenced
+// with quota managers such as mysqlqm, which limit the number of unsequenced
+// leaves.
+func (t *TrillianLogRPCServer) queueBackpressure(ctx context.Context, treeID int64) *rpcstatus.Status {
+	if *backpressureTokens <= 0 || t.registry.QuotaManager == nil {
+		return nil
+	}
+	specs := []quota.Spec{
+		{Group: quota.Tree, Kind: quota.Write, TreeID: treeID},
+		{Group: quota.Global, Kind: quota.Write},
+	}
+	available, err := t.registry.QuotaManager.PeekTokens(ctx, specs)
+	if err != nil {
+		// The leaves are queued, and the hint is only advisory.
+		glog.Warningf("%d: failed to peek quota tokens for backpressure: %v", treeID, err)
+		return nil
+	}
+	tokens := quota.MaxTokens
+	for _, spec := range specs {
+		if n, ok := available[spec]; ok && n < tokens {
+			tokens = n
+		}
+	}
+	delay := backpressureDelay(tokens, *backpressureTokens, *backpressureMaxDelay)
+	if delay <= 0 {
+		return nil
+	}
+	// status.WithDetails refuses to add details to OK statuses, so this builds
+	// the status proto directly.
+	info, err := ptypes.MarshalAny(&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(delay)})
+	if err != nil {
+		glog.Warningf("%d: failed to marshal backpressure retry info: %v", treeID, err)
+		return nil
+	}
+	return &rpcstatus.Status{Code: int32(codes.OK), Details: []*any.Any{info}}
+}
@@ -0,0 +1,124 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/storage"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+)
+
+func TestBackpressureDelay(t *testing.T) {
+	for _, test := range []struct {
+		tokens, threshold int
+		want              time.Duration
+	}{
+		{tokens: 100, threshold: 0, want: 0},
+		{tokens: 100, threshold: 100, want: 0},
+		{tokens: 75, threshold: 100, want: 2500 * time.Millisecond},
+		{tokens: 1, threshold: 100, want: 9900 * time.Millisecond},
+		{tokens: 0, threshold: 100, want: 10 * time.Second},
+		{tokens: -5, threshold: 100, want: 10 * time.Second},
+	} {
+		t.Run(fmt.Sprintf("%d-of-%d", test.tokens, test.threshold), func(t *testing.T) {
+			if got := backpressureDelay(test.tokens, test.threshold, 10*time.Second); got != test.want {
+				t.Errorf("backpressureDelay()=%v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestQueueLeavesBackpressure(t *testing.T) {
+	defer func(tokens int) { *backpressureTokens = tokens }(*backpressureTokens)
+	*backpressureTokens = 100
+
+	treeSpec := quota.Spec{Group: quota.Tree, Kind: quota.Write, TreeID: queueRequest0.LogId}
+	globalSpec := quota.Spec{Group: quota.Global, Kind: quota.Write}
+	for _, test := range []struct {
+		desc      string
+		available map[quota.Spec]int
+		peekErr   error
+		want      time.Duration
+	}{
+		{
+			desc:      "plentyOfTokens",
+			available: map[quota.Spec]int{treeSpec: quota.MaxTokens, globalSpec: 1000},
+		},
+		{
+			desc:      "globalBacklog",
+			available: map[quota.Spec]int{treeSpec: quota.MaxTokens, globalSpec: 50},
+			want:      5 * time.Second,
+		},
+		{
+			desc:      "treeExhausted",
+			available: map[quota.Spec]int{treeSpec: 0, globalSpec: 1000},
+			want:      10 * time.Second,
+		},
+		{
+			desc:    "peekFails",
+			peekErr: errors.New("quota unavailable"),
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := storage.NewMockLogStorage(ctrl)
+			mockStorage.EXPECT().QueueLeaves(gomock.Any(), tree1, []*trillian.LogLeaf{leaf1}, fakeTime).Return([]*trillian.QueuedLogLeaf{okQueuedLeaf(leaf1)}, nil)
+			qm := quota.NewMockManager(ctrl)
+			qm.EXPECT().PeekTokens(gomock.Any(), []quota.Spec{treeSpec, globalSpec}).Return(test.available, test.peekErr)
+
+			registry := extension.Registry{
+				AdminStorage: fakeAdminStorage(ctrl, storageParams{treeID: queueRequest0.LogId, numSnapshots: 1}),
+				LogStorage:   mockStorage,
+				QuotaManager: qm,
+			}
+			server := NewTrillianLogRPCServer(registry, fakeTimeSource)
+			rsp, err := server.QueueLeaves(context.Background(), &queueRequest0)
+			if err != nil {
+				t.Fatalf("QueueLeaves(): %v", err)
+			}
+
+			bp := rsp.Backpressure
+			if test.want == 0 {
+				if bp != nil {
+					t.Errorf("QueueLeaves().Backpressure=%v, want nil", bp)
+				}
+				return
+			}
+			if bp == nil || bp.Code != int32(codes.OK) || len(bp.Details) != 1 {
+				t.Fatalf("QueueLeaves().Backpressure=%v, want OK with retry info", bp)
+			}
+			var info errdetails.RetryInfo
+			if err := ptypes.UnmarshalAny(bp.Details[0], &info); err != nil {
+				t.Fatalf("UnmarshalAny(): %v", err)
+			}
+			if got, err := ptypes.Duration(info.RetryDelay); err != nil || got != test.want {
+				t.Errorf("retry delay %v (err %v), want %v", got, err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"database/sql"
+	"flag"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/sqlite"
+)
+
+var (
+	sqliteFile = flag.String("sqlite_file", "trillian.db", "File holding the SQLite database, which is created if it doesn't exist")
+
+	sqliteStatementCacheSize = flag.Int("sqlite_statement_cache_size", 0, "If positive, the maximum number of prepared statements cached. Unlimited otherwise")
+
+	sqliteOnce            sync.Once
+	sqliteOnceErr         error
+	sqliteStorageInstance *sqliteProvider
+)
+
+func init() {
+	if err := storage.RegisterProvider("sqlite", newSQLiteStorageProvider); err != nil {
+		glog.Fatalf("Failed to register storage provider sqlite: %v", err)
+	}
+}
+
+type sqliteProvider struct {
+	db          *sql.DB
+	mf          monitoring.MetricFactory
+	opts        sqlite.TreeStorageOptions
+	stopMetrics func()
+}
+
+func newSQLiteStorageProvider(mf monitoring.MetricFactory) (StorageProvider, error) {
+	sqliteOnce.Do(func() {
+		opts := sqlite.TreeStorageOptions{
+			QueryTags:          *queryTags,
+			StatementCacheSize: *sqliteStatementCacheSize,
+		}
+		opts.SubtreeCodec, sqliteOnceErr = subtreeCodecFromFlags(sqlite.SubtreeCodecs)
+		if sqliteOnceErr != nil {
+			return
+		}
+		var db *sql.DB
+		db, sqliteOnceErr = sqlite.OpenDB(*sqliteFile)
+		if sqliteOnceErr != nil {
+			return
+		}
+		if sqliteOnceErr = checkSchema("sqlite", db, sqlite.Schema); sqliteOnceErr != nil {
+			db.Close()
+			return
+		}
+		sqliteStorageInstance = &sqliteProvider{
+			db:          db,
+			mf:          mf,
+			opts:        opts,
+			stopMetrics: exportPoolMetrics(mf, "sqlite", db),
+		}
+	})
+	if sqliteOnceErr != nil {
+		return nil, sqliteOnceErr
+	}
+	return sqliteStorageInstance, nil
+}
+
+func (s *sqliteProvider) LogStorage() storage.LogStorage {
+	return sqlite.NewLogStorageWithOpts(s.db, s.mf, s.opts)
+}
+
+func (s *sqliteProvider) MapStorage() storage.MapStorage {
+	return sqlite.NewMapStorageWithOpts(s.db, s.opts)
+}
+
+func (s *sqliteProvider) AdminStorage() storage.AdminStorage {
+	return sqlite.NewAdminStorage(s.db)
+}
+
+func (s *sqliteProvider) Close() error {
+	s.stopMetrics()
+	return s.db.Close()
+}
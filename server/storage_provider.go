@@ -15,41 +15,40 @@
 package server
 
 import (
+	"context"
+	"database/sql"
 	"flag"
 	"fmt"
-	"sync"
+	"time"
 
+	"github.com/golang/glog"
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/storage/migrate"
+	"github.com/google/trillian/storage/sqlpool"
+	"github.com/google/trillian/storage/subtreecodec"
 )
 
 // NewStorageProviderFunc is the signature of a function which can be registered
 // to provide instances of storage providers.
-type NewStorageProviderFunc func(monitoring.MetricFactory) (StorageProvider, error)
+type NewStorageProviderFunc = storage.NewProviderFunc
 
 var (
-	storageSystem = flag.String("storage_system", "mysql", fmt.Sprintf("Storage system to use. One of: %v", storageProviders()))
+	storageSystem = flag.String("storage_system", "mysql", "Storage system to use, e.g. mysql, postgres, crdb, sqlite, bigtable, cloud_spanner or memory. Any storage system registered with storage.RegisterProvider can be used")
+	subtreeCodec  = flag.String("subtree_codec", "proto", "Codec with which Merkle subtrees are written to storage. One of: proto, packed, deflate, zstd. Subtrees are read with whichever codec they were written with, so it can be changed at any time")
+	queryTags     = flag.Bool("storage_query_tags", false, "If true, the RPC, tree and revision of each request are appended to the SQL queries it causes as a comment, so that slow query logs can be attributed. Supported by mysql and cloud_spanner")
 
-	spMu     sync.RWMutex
-	spOnce   sync.Once
-	spByName map[string]NewStorageProviderFunc
+	schemaCheck = flag.Bool("storage_schema_check", true, "If true, the mysql, postgres, crdb and sqlite storage systems fail to start unless their schema is at the version expected by this binary. Databases which don't record their schema version are only warned about")
+
+	poolMetricsInterval = flag.Duration("storage_pool_metrics_interval", 10*time.Second, "How often the utilization of the connection pools of the mysql, postgres, crdb and sqlite storage systems is exported as metrics")
 )
 
 // RegisterStorageProvider registers the provided StorageProvider.
+//
+// Deprecated: Use storage.RegisterProvider.
 func RegisterStorageProvider(name string, sp NewStorageProviderFunc) error {
-	spMu.Lock()
-	defer spMu.Unlock()
-
-	spOnce.Do(func() {
-		spByName = make(map[string]NewStorageProviderFunc)
-	})
-
-	_, exists := spByName[name]
-	if exists {
-		return fmt.Errorf("storage provider %v already registered", name)
-	}
-	spByName[name] = sp
-	return nil
+	return storage.RegisterProvider(name, sp)
 }
 
 // NewStorageProviderFromFlags returns a new StorageProvider instance of the type
@@ -59,42 +58,67 @@ func NewStorageProviderFromFlags(mf monitoring.MetricFactory) (StorageProvider,
 }
 
 // NewStorageProvider returns a new StorageProvider instance of the type
-// specified by name.
+// specified by name. It also initializes the subtree cache metrics with mf.
 func NewStorageProvider(name string, mf monitoring.MetricFactory) (StorageProvider, error) {
-	spMu.RLock()
-	defer spMu.RUnlock()
+	if mf != nil {
+		cache.InitMetrics(mf)
+	}
+	return storage.NewProvider(name, mf)
+}
+
+// subtreeCodecFromFlags returns the subtree codec specified by flag, if it is
+// one of the codecs supported by the storage system.
+func subtreeCodecFromFlags(supported []subtreecodec.Codec) (subtreecodec.Codec, error) {
+	c, err := subtreecodec.Parse(*subtreeCodec)
+	if err != nil {
+		return 0, err
+	}
+	return c, subtreecodec.Check(c, supported)
+}
 
-	sp := spByName[name]
-	if sp == nil {
-		return nil, fmt.Errorf("no such storage provider %v", name)
+// poolOptions returns the connection pool options set by the flags of a
+// storage system, whose maxIdle is negative to leave the default.
+func poolOptions(maxConns, maxIdle int, connMaxLifetime time.Duration) sqlpool.Options {
+	opts := sqlpool.Options{MaxOpenConns: maxConns, ConnMaxLifetime: connMaxLifetime}
+	switch {
+	case maxIdle == 0:
+		opts.MaxIdleConns = -1
+	case maxIdle > 0:
+		opts.MaxIdleConns = maxIdle
 	}
+	return opts
+}
 
-	return sp(mf)
+// exportPoolMetrics exports the utilization of the connection pool of db,
+// labelled with name, until the returned function is called.
+func exportPoolMetrics(mf monitoring.MetricFactory, name string, db *sql.DB) func() {
+	return sqlpool.ExportMetrics(mf, name, db, *poolMetricsInterval)
 }
 
 // storageProviders returns a slice of all registered storage provider names.
 func storageProviders() []string {
-	spMu.RLock()
-	defer spMu.RUnlock()
-
-	r := []string{}
-	for k := range spByName {
-		r = append(r, k)
-	}
-
-	return r
+	return storage.Providers()
 }
 
 // StorageProvider is an interface which allows trillian binaries to use
 // different storage implementations.
-type StorageProvider interface {
-	// LogStorage creates and returns a LogStorage implementation.
-	LogStorage() storage.LogStorage
-	// MapStorage creates and returns a MapStorage implementation.
-	MapStorage() storage.MapStorage
-	// AdminStorage creates and returns a AdminStorage implementation.
-	AdminStorage() storage.AdminStorage
-
-	// Close closes the underlying storage.
-	Close() error
+type StorageProvider = storage.Provider
+
+// checkSchema returns an error if the schema of the database of storage system
+// name isn't at the latest version of s, unless the check is disabled by flag.
+// Unversioned databases are only warned about, so that they keep working
+// until they are baselined with trillian_migrate.
+func checkSchema(name string, db *sql.DB, s migrate.Schema) error {
+	if !*schemaCheck {
+		return nil
+	}
+	switch err := s.Check(context.TODO(), db); err {
+	case nil:
+		return nil
+	case migrate.ErrUnversioned:
+		glog.Warningf("The %s database doesn't record its schema version, baseline it with trillian_migrate and upgrade it", name)
+		return nil
+	default:
+		return fmt.Errorf("%s: %v", name, err)
+	}
 }
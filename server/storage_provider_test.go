@@ -15,10 +15,21 @@
 package server
 
 import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/migrate"
+	"github.com/google/trillian/storage/sqlite"
+	"github.com/google/trillian/storage/sqlpool"
+	"github.com/google/trillian/storage/subtreecodec"
+	"github.com/google/trillian/testonly/flagsaver"
 )
 
 type provider struct {
@@ -101,3 +112,87 @@ func TestStorageProviders(t *testing.T) {
 		t.Errorf("StorageProviders() gave %d 'b', want 1", b)
 	}
 }
+
+func TestPoolOptions(t *testing.T) {
+	for _, test := range []struct {
+		desc     string
+		maxConns int
+		maxIdle  int
+		lifetime time.Duration
+		want     sqlpool.Options
+	}{
+		{desc: "defaults", maxIdle: -1, want: sqlpool.Options{}},
+		{desc: "limits", maxConns: 10, maxIdle: 5, lifetime: time.Minute, want: sqlpool.Options{MaxOpenConns: 10, MaxIdleConns: 5, ConnMaxLifetime: time.Minute}},
+		{desc: "no-idle", maxIdle: 0, want: sqlpool.Options{MaxIdleConns: -1}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := poolOptions(test.maxConns, test.maxIdle, test.lifetime); got != test.want {
+				t.Errorf("poolOptions(%d, %d, %v): %+v, want %+v", test.maxConns, test.maxIdle, test.lifetime, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSubtreeCodecFromFlags(t *testing.T) {
+	defer flagsaver.Save().MustRestore()
+	for _, test := range []struct {
+		codec   string
+		want    subtreecodec.Codec
+		wantErr bool
+	}{
+		{codec: "proto", want: subtreecodec.Proto},
+		{codec: "deflate", want: subtreecodec.Deflate},
+		{codec: "zstd", want: subtreecodec.Zstd},
+		{codec: "lz4", wantErr: true},
+	} {
+		t.Run(test.codec, func(t *testing.T) {
+			if err := flag.Set("subtree_codec", test.codec); err != nil {
+				t.Fatalf("Failed to set flag: %v", err)
+			}
+			got, err := subtreeCodecFromFlags(sqlite.SubtreeCodecs)
+			if gotErr := err != nil; gotErr != test.wantErr || got != test.want {
+				t.Errorf("subtreeCodecFromFlags(): %v, %v, want %v, err %v", got, err, test.want, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	defer flagsaver.Save().MustRestore()
+	dir, err := ioutil.TempDir("", "sqlite")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := sqlite.OpenDB(filepath.Join(dir, "trillian.db"))
+	if err != nil {
+		t.Fatalf("OpenDB(): %v", err)
+	}
+	defer db.Close()
+
+	newer := sqlite.Schema
+	newer.Steps = append(newer.Steps[:len(newer.Steps):len(newer.Steps)], migrate.Step{Version: newer.Latest() + 1})
+	if err := checkSchema("sqlite", db, sqlite.Schema); err != nil {
+		t.Errorf("checkSchema(): %v", err)
+	}
+	if err := checkSchema("sqlite", db, newer); err == nil {
+		t.Error("checkSchema() of an outdated schema: nil, want err")
+	}
+	if err := flag.Set("storage_schema_check", "false"); err != nil {
+		t.Fatalf("Failed to set flag: %v", err)
+	}
+	if err := checkSchema("sqlite", db, newer); err != nil {
+		t.Errorf("checkSchema() with the check disabled: %v", err)
+	}
+	if err := flag.Set("storage_schema_check", "true"); err != nil {
+		t.Fatalf("Failed to set flag: %v", err)
+	}
+
+	// Unversioned databases are only warned about.
+	if _, err := db.ExecContext(context.Background(), "DROP TABLE SchemaVersion"); err != nil {
+		t.Fatalf("DROP TABLE: %v", err)
+	}
+	if err := checkSchema("sqlite", db, newer); err != nil {
+		t.Errorf("checkSchema() of an unversioned database: %v", err)
+	}
+}
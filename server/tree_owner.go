@@ -0,0 +1,45 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"flag"
+	"strings"
+
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/server/interceptor"
+)
+
+var (
+	enforceTreeOwners   = flag.Bool("enforce_tree_owners", false, "If true, requests for a tree are only served if it's owned by their principal, the common name of their client certificate. Requires --tls_client_ca_file")
+	treeOwnerSuperusers = flag.String("tree_owner_superusers", "", "Comma-separated principals which may access all trees and change their owners when --enforce_tree_owners is set")
+)
+
+// NewTreeOwnershipFromFlags returns a TreeOwnership configured by flags. It
+// returns nil unless tree owners are enforced.
+func NewTreeOwnershipFromFlags(registry extension.Registry) (*interceptor.TreeOwnership, error) {
+	if !*enforceTreeOwners {
+		if *treeOwnerSuperusers != "" {
+			return nil, errors.New("--tree_owner_superusers requires --enforce_tree_owners")
+		}
+		return nil, nil
+	}
+	var superusers []string
+	if *treeOwnerSuperusers != "" {
+		superusers = strings.Split(*treeOwnerSuperusers, ",")
+	}
+	return interceptor.NewTreeOwnership(registry.AdminStorage, interceptor.TLSPrincipal, superusers, registry.MetricFactory), nil
+}
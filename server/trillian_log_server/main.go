@@ -37,6 +37,7 @@ import (
 	"github.com/google/trillian/quota/etcd/quotaapi"
 	"github.com/google/trillian/quota/etcd/quotapb"
 	"github.com/google/trillian/server"
+	"github.com/google/trillian/server/canary"
 	"github.com/google/trillian/util/clock"
 	"github.com/google/trillian/util/etcd"
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
@@ -57,15 +58,22 @@ var (
 	healthzTimeout  = flag.Duration("healthz_timeout", time.Second*5, "Timeout used during healthz checks")
 	tlsCertFile     = flag.String("tls_cert_file", "", "Path to the TLS server certificate. If unset, the server will use unsecured connections.")
 	tlsKeyFile      = flag.String("tls_key_file", "", "Path to the TLS server key. If unset, the server will use unsecured connections.")
+	tlsClientCAFile = flag.String("tls_client_ca_file", "", "Path to the PEM certificates of the CAs which sign the certificates of RPC clients. If set, clients without such a certificate are rejected. Requires --tls_cert_file and --tls_key_file.")
 	etcdService     = flag.String("etcd_service", "trillian-logserver", "Service name to announce ourselves under")
 	etcdHTTPService = flag.String("etcd_http_service", "trillian-logserver-http", "Service name to announce our HTTP endpoint under")
 
+	healthReadiness     = flag.Bool("health_readiness", false, "If true, the gRPC health service and /readyz also require the signer keys of the writable trees served to load")
+	healthCheckInterval = flag.Duration("health_check_interval", server.DefaultHealthCheckInterval, "Interval between checks of the status reported by the gRPC health service")
+
 	quotaDryRun = flag.Bool("quota_dry_run", false, "If true no requests are blocked due to lack of tokens")
 
 	treeGCEnabled            = flag.Bool("tree_gc", true, "If true, tree garbage collection (hard-deletion) is periodically performed")
-	treeDeleteThreshold      = flag.Duration("tree_delete_threshold", server.DefaultTreeDeleteThreshold, "Minimum period a tree has to remain deleted before being hard-deleted")
+	treeDeleteThreshold      = flag.Duration("tree_delete_threshold", server.DefaultTreeDeleteThreshold, "Minimum period a tree has to remain deleted before being hard-deleted, unless the tree sets its delete_retention")
 	treeDeleteMinRunInterval = flag.Duration("tree_delete_min_run_interval", server.DefaultTreeDeleteMinInterval, "Minimum interval between tree garbage collection sweeps. Actual runs happen randomly between [minInterval,2*minInterval).")
 
+	keyRotationEnabled     = flag.Bool("key_rotation", true, "If true, the old keys of trees are periodically retired once their key rotations are due")
+	keyRotationMinInterval = flag.Duration("key_rotation_min_run_interval", server.DefaultKeyRotationMinInterval, "Minimum interval between key retirement sweeps. Actual runs happen randomly between [minInterval,2*minInterval).")
+
 	tracing          = flag.Bool("tracing", false, "If true opencensus Stackdriver tracing will be enabled. See https://opencensus.io/.")
 	tracingProjectID = flag.String("tracing_project_id", "", "project ID to pass to stackdriver. Can be empty for GCP, consult docs for other platforms.")
 	tracingPercent   = flag.Int("tracing_percent", 0, "Percent of requests to be traced. Zero is a special case to use the DefaultSampler")
@@ -134,6 +142,7 @@ func main() {
 		NewKeyProto: func(ctx context.Context, spec *keyspb.Specification) (proto.Message, error) {
 			return der.NewProtoFromSpec(spec)
 		},
+		TreeNotifier: server.NewTreeNotifierFromFlags(),
 	}
 
 	// Enable CPU profile if requested.
@@ -143,16 +152,42 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
+	attester, err := server.NewAttesterFromFlags(registry)
+	if err != nil {
+		glog.Exitf("Failed to create attester: %v", err)
+	}
+	quarantiner, err := server.NewQuarantinerFromFlags(registry)
+	if err != nil {
+		glog.Exitf("Failed to create quarantiner: %v", err)
+	}
+	overloadBreaker, err := server.NewOverloadBreakerFromFlags(registry)
+	if err != nil {
+		glog.Exitf("Failed to create overload breaker: %v", err)
+	}
+	treeOwnership, err := server.NewTreeOwnershipFromFlags(registry)
+	if err != nil {
+		glog.Exitf("Failed to create tree ownership: %v", err)
+	}
+	// A nil *canary.Canary must not be stored in the ReadinessCheck field.
+	var readiness server.ReadinessCheck
+	c, err := canary.NewFromFlags(*rpcEndpoint, *tlsCertFile, registry.MetricFactory)
+	if err != nil {
+		glog.Exitf("Failed to create canary: %v", err)
+	} else if c != nil {
+		readiness = c
+	}
+
 	m := server.Main{
-		RPCEndpoint:  *rpcEndpoint,
-		HTTPEndpoint: *httpEndpoint,
-		TLSCertFile:  *tlsCertFile,
-		TLSKeyFile:   *tlsKeyFile,
-		StatsPrefix:  "log",
-		ExtraOptions: options,
-		QuotaDryRun:  *quotaDryRun,
-		DBClose:      sp.Close,
-		Registry:     registry,
+		RPCEndpoint:     *rpcEndpoint,
+		HTTPEndpoint:    *httpEndpoint,
+		TLSCertFile:     *tlsCertFile,
+		TLSKeyFile:      *tlsKeyFile,
+		TLSClientCAFile: *tlsClientCAFile,
+		StatsPrefix:     "log",
+		ExtraOptions:    options,
+		QuotaDryRun:     *quotaDryRun,
+		DBClose:         sp.Close,
+		Registry:        registry,
 		RegisterHandlerFn: func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error {
 			if err := trillian.RegisterTrillianLogHandlerFromEndpoint(ctx, mux, endpoint, opts); err != nil {
 				return err
@@ -164,10 +199,12 @@ func main() {
 		},
 		RegisterServerFn: func(s *grpc.Server, registry extension.Registry) error {
 			logServer := server.NewTrillianLogRPCServer(registry, clock.System)
-			if err := logServer.IsHealthy(); err != nil {
-				return err
-			}
 			trillian.RegisterTrillianLogServer(s, logServer)
+			go func() {
+				if err := logServer.LoadFrozenLogs(context.Background()); err != nil {
+					glog.Errorf("Failed to load frozen logs: %v", err)
+				}
+			}()
 			if *server.QuotaSystem == server.QuotaEtcd {
 				quotapb.RegisterQuotaServer(s, quotaapi.NewServer(client))
 			}
@@ -177,11 +214,23 @@ func main() {
 			as := sp.AdminStorage()
 			return as.CheckDatabaseAccessible(ctx)
 		},
-		HealthyDeadline:       *healthzTimeout,
-		AllowedTreeTypes:      []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG},
-		TreeGCEnabled:         *treeGCEnabled,
-		TreeDeleteThreshold:   *treeDeleteThreshold,
-		TreeDeleteMinInterval: *treeDeleteMinRunInterval,
+		HealthyDeadline:        *healthzTimeout,
+		AllowedTreeTypes:       []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG},
+		TreeGCEnabled:          *treeGCEnabled,
+		TreeDeleteThreshold:    *treeDeleteThreshold,
+		TreeDeleteMinInterval:  *treeDeleteMinRunInterval,
+		KeyRotationEnabled:     *keyRotationEnabled,
+		KeyRotationMinInterval: *keyRotationMinInterval,
+		Attester:               attester,
+		Quarantiner:            quarantiner,
+		Canary:                 readiness,
+		OverloadBreaker:        overloadBreaker,
+		TreeOwnership:          treeOwnership,
+		Health: server.HealthOptions{
+			Readiness:     *healthReadiness,
+			CheckInterval: *healthCheckInterval,
+			CheckTimeout:  *healthzTimeout,
+		},
 	}
 
 	if err := m.Run(ctx); err != nil {
@@ -37,6 +37,7 @@ import (
 	"github.com/google/trillian/util/election"
 	"github.com/google/trillian/util/election2"
 	etcdelect "github.com/google/trillian/util/election2/etcd"
+	k8select "github.com/google/trillian/util/election2/k8s"
 	"github.com/google/trillian/util/etcd"
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"google.golang.org/grpc"
@@ -57,13 +58,24 @@ var (
 	tlsCertFile              = flag.String("tls_cert_file", "", "Path to the TLS server certificate. If unset, the server will use unsecured connections.")
 	tlsKeyFile               = flag.String("tls_key_file", "", "Path to the TLS server key. If unset, the server will use unsecured connections.")
 	sequencerIntervalFlag    = flag.Duration("sequencer_interval", 100*time.Millisecond, "Time between each sequencing pass through all logs")
-	batchSizeFlag            = flag.Int("batch_size", 1000, "Max number of leaves to process per batch")
+	batchSizeFlag            = flag.Int("batch_size", 1000, "Max number of leaves to process per batch. With --adaptive_batch_size, the initial batch size of each log")
+	adaptiveBatchSize        = flag.Bool("adaptive_batch_size", true, "If true, the batch size of each log grows while its batches are full and integrate well within --batch_target_latency, and shrinks when they take longer")
+	minBatchSize             = flag.Int("min_batch_size", 10, "Minimum batch size of each log, with --adaptive_batch_size")
+	maxBatchSize             = flag.Int("max_batch_size", 10000, "Maximum batch size of each log, with --adaptive_batch_size")
+	batchTargetLatency       = flag.Duration("batch_target_latency", time.Second, "Longest a batch should take to integrate, with --adaptive_batch_size")
 	numSeqFlag               = flag.Int("num_sequencers", 10, "Number of sequencer workers to run in parallel")
 	sequencerGuardWindowFlag = flag.Duration("sequencer_guard_window", 0, "If set, the time elapsed before submitted leaves are eligible for sequencing")
 	forceMaster              = flag.Bool("force_master", false, "If true, assume master for all logs")
 	etcdHTTPService          = flag.String("etcd_http_service", "trillian-logsigner-http", "Service name to announce our HTTP endpoint under")
 	lockDir                  = flag.String("lock_file_path", "/test/multimaster", "etcd lock file directory path")
+	electionSystem           = flag.String("election_system", "etcd", "Master election backend: etcd (requires --etcd_servers), k8s (Kubernetes Leases, when running in a cluster), or a registered provider such as consul")
+	k8sLeasePrefix           = flag.String("k8s_lease_prefix", "trillian-log-signer", "Prefix of the names of the Leases used with --election_system=k8s, followed by the log ID")
+	k8sNamespace             = flag.String("k8s_namespace", "", "Namespace of the Leases used with --election_system=k8s, empty means the namespace of the pod")
+	k8sLeaseDuration         = flag.Duration("k8s_lease_duration", 15*time.Second, "How long a Lease is held after its last renewal, with --election_system=k8s")
+	k8sRetryPeriod           = flag.Duration("k8s_retry_period", 2*time.Second, "Interval between attempts to acquire or renew a Lease, with --election_system=k8s")
 	healthzTimeout           = flag.Duration("healthz_timeout", time.Second*5, "Timeout used during healthz checks")
+	dryRun                   = flag.Bool("dry_run", false, "If true, sequence and sign batches, but roll back the updates and only log the roots which would have been produced, e.g. to validate a storage backend or hasher against production traffic. Leaves stay queued")
+	mergeDelaySLO            = flag.Duration("merge_delay_slo", 0, "If set, the longest leaves should wait between being queued and integrated. Leaves which wait longer are logged as errors and counted by the sequencer_merge_delay_slo_violations metric")
 
 	quotaIncreaseFactor = flag.Float64("quota_increase_factor", log.QuotaIncreaseFactor,
 		"Increase factor for tokens replenished by sequencing-based quotas (1 means a 1:1 relationship between sequenced leaves and replenished tokens)."+
@@ -121,10 +133,26 @@ func main() {
 	case *forceMaster:
 		glog.Warning("**** Acting as master for all logs ****")
 		electionFactory = election2.NoopFactory{}
+	case *electionSystem == "k8s":
+		k8sClient, err := k8select.NewInClusterClient(*k8sNamespace)
+		if err != nil {
+			glog.Exitf("Failed to create Kubernetes client: %v", err)
+		}
+		electionFactory, err = k8select.NewFactory(instanceID, k8sClient, *k8sLeasePrefix, k8select.Options{
+			LeaseDuration: *k8sLeaseDuration,
+			RetryPeriod:   *k8sRetryPeriod,
+		})
+		if err != nil {
+			glog.Exitf("Failed to create Kubernetes election factory: %v", err)
+		}
+	case *electionSystem != "etcd":
+		if electionFactory, err = server.NewElectionFactory(*electionSystem, instanceID); err != nil {
+			glog.Exitf("Failed to create election factory: %v (registered: %v)", err, server.ElectionSystems())
+		}
 	case client != nil:
 		electionFactory = etcdelect.NewFactory(instanceID, client, *lockDir)
 	default:
-		glog.Exit("Either --force_master or --etcd_servers must be supplied")
+		glog.Exit("Either --force_master, --etcd_servers or another --election_system must be supplied")
 	}
 
 	qm, err := server.NewQuotaManagerFromFlags()
@@ -151,13 +179,31 @@ func main() {
 	// both sequencing and signing.
 	// TODO(Martin2112): Should respect read only mode and the flags in tree control etc
 	log.QuotaIncreaseFactor = *quotaIncreaseFactor
+	log.MergeDelaySLO = *mergeDelaySLO
+	if *dryRun {
+		glog.Warning("**** Dry run: sequenced batches are not committed ****")
+	}
 	sequencerManager := log.NewSequencerManager(registry, *sequencerGuardWindowFlag)
+	var batchSizer *log.BatchSizer
+	if *adaptiveBatchSize {
+		batchSizer, err = log.NewBatchSizer(log.BatchSizerOptions{
+			Initial:       *batchSizeFlag,
+			Min:           *minBatchSize,
+			Max:           *maxBatchSize,
+			TargetLatency: *batchTargetLatency,
+		}, mf)
+		if err != nil {
+			glog.Exitf("Invalid adaptive batch size flags: %v", err)
+		}
+	}
 	info := log.OperationInfo{
 		Registry:    registry,
 		BatchSize:   *batchSizeFlag,
+		BatchSizer:  batchSizer,
 		NumWorkers:  *numSeqFlag,
 		RunInterval: *sequencerIntervalFlag,
 		TimeSource:  clock.System,
+		DryRun:      *dryRun,
 		ElectionConfig: election.RunnerConfig{
 			PreElectionPause:   *preElectionPause,
 			MasterHoldInterval: *masterHoldInterval,
@@ -35,6 +35,8 @@ import (
 	"github.com/google/trillian/quota/etcd/quotaapi"
 	"github.com/google/trillian/quota/etcd/quotapb"
 	"github.com/google/trillian/server"
+	"github.com/google/trillian/server/canary"
+	"github.com/google/trillian/util/clock"
 	"github.com/google/trillian/util/etcd"
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"google.golang.org/grpc"
@@ -50,18 +52,28 @@ import (
 )
 
 var (
-	rpcEndpoint    = flag.String("rpc_endpoint", "localhost:8090", "Endpoint for RPC requests (host:port)")
-	httpEndpoint   = flag.String("http_endpoint", "localhost:8091", "Endpoint for HTTP metrics and REST requests on (host:port, empty means disabled)")
-	healthzTimeout = flag.Duration("healthz_timeout", time.Second*5, "Timeout used during healthz checks")
-	tlsCertFile    = flag.String("tls_cert_file", "", "Path to the TLS server certificate. If unset, the server will use unsecured connections.")
-	tlsKeyFile     = flag.String("tls_key_file", "", "Path to the TLS server key. If unset, the server will use unsecured connections.")
+	rpcEndpoint     = flag.String("rpc_endpoint", "localhost:8090", "Endpoint for RPC requests (host:port)")
+	httpEndpoint    = flag.String("http_endpoint", "localhost:8091", "Endpoint for HTTP metrics and REST requests on (host:port, empty means disabled)")
+	healthzTimeout  = flag.Duration("healthz_timeout", time.Second*5, "Timeout used during healthz checks")
+	tlsCertFile     = flag.String("tls_cert_file", "", "Path to the TLS server certificate. If unset, the server will use unsecured connections.")
+	tlsKeyFile      = flag.String("tls_key_file", "", "Path to the TLS server key. If unset, the server will use unsecured connections.")
+	tlsClientCAFile = flag.String("tls_client_ca_file", "", "Path to the PEM certificates of the CAs which sign the certificates of RPC clients. If set, clients without such a certificate are rejected. Requires --tls_cert_file and --tls_key_file.")
+
+	healthReadiness     = flag.Bool("health_readiness", false, "If true, the gRPC health service and /readyz also require the signer keys of the writable trees served to load")
+	healthCheckInterval = flag.Duration("health_check_interval", server.DefaultHealthCheckInterval, "Interval between checks of the status reported by the gRPC health service")
 
 	quotaDryRun = flag.Bool("quota_dry_run", false, "If true no requests are blocked due to lack of tokens")
 
 	treeGCEnabled            = flag.Bool("tree_gc", true, "If true, tree garbage collection (hard-deletion) is periodically performed")
-	treeDeleteThreshold      = flag.Duration("tree_delete_threshold", server.DefaultTreeDeleteThreshold, "Minimum period a tree has to remain deleted before being hard-deleted")
+	treeDeleteThreshold      = flag.Duration("tree_delete_threshold", server.DefaultTreeDeleteThreshold, "Minimum period a tree has to remain deleted before being hard-deleted, unless the tree sets its delete_retention")
 	treeDeleteMinRunInterval = flag.Duration("tree_delete_min_run_interval", server.DefaultTreeDeleteMinInterval, "Minimum interval between tree garbage collection sweeps. Actual runs happen randomly between [minInterval,2*minInterval).")
 
+	keyRotationEnabled     = flag.Bool("key_rotation", true, "If true, the old keys of trees are periodically retired once their key rotations are due")
+	keyRotationMinInterval = flag.Duration("key_rotation_min_run_interval", server.DefaultKeyRotationMinInterval, "Minimum interval between key retirement sweeps. Actual runs happen randomly between [minInterval,2*minInterval).")
+
+	revisionGCEnabled        = flag.Bool("revision_gc", true, "If true, map revisions are periodically garbage collected according to the retention policy of each map")
+	revisionGCMinRunInterval = flag.Duration("revision_gc_min_run_interval", server.DefaultRevisionGCMinInterval, "Minimum interval between map revision garbage collection sweeps. Actual runs happen randomly between [minInterval,2*minInterval).")
+
 	tracing          = flag.Bool("tracing", false, "If true opencensus Stackdriver tracing will be enabled. See https://opencensus.io/.")
 	tracingProjectID = flag.String("tracing_project_id", "", "project ID to pass to Stackdriver client. Can be empty for GCP, consult docs for other platforms.")
 	tracingPercent   = flag.Int("tracing_percent", 0, "Percent of requests to be traced. Zero is a special case to use the DefaultSampler")
@@ -69,7 +81,33 @@ var (
 	configFile = flag.String("config", "", "Config file containing flags, file contents can be overridden by command line flags")
 
 	useSingleTransaction = flag.Bool("single_transaction", false, "Experimental: use a single transaction when updating the map")
-	largePreload         = flag.Bool("large_preload_fix", true, "Experimental: work-around locking performance issues when using useSingleTransaction mode")
+	preloadStrategy      = flag.String("preload_strategy", server.PreloadFullSibling, "Experimental: Merkle nodes preloaded to work-around locking performance issues when using single_transaction mode. One of none, full_sibling or adaptive")
+	preloadMinBatchSize  = flag.Int("preload_min_batch_size", server.DefaultAdaptivePreloadMinBatchSize, "Minimum number of leaves in an update for the adaptive preload strategy to preload nodes")
+	preloadParallelism   = flag.Int("preload_parallelism", 0, "Maximum number of goroutines computing the nodes to preload for each update. If zero, GOMAXPROCS is used")
+	nodeCacheSize        = flag.Int("node_cache_size", 0, "Number of Merkle nodes of published map revisions cached in memory for inclusion proofs. If zero, nodes are not cached")
+	watchPollInterval    = flag.Duration("watch_poll_interval", server.DefaultWatchPollInterval, "Interval at which WatchSignedMapRoots streams check storage for new map roots")
+	hedgeDelay           = flag.Duration("hedge_delay", 0, "If set, leaf and inclusion proof reads which have not returned after this delay are issued again on a new storage snapshot, and the first result is used. Reduces tail latency on backends like Cloud Spanner")
+	shadowMaps           = flag.String("shadow_maps", "", "Comma-separated map_id=shadow_map_id pairs. Leaves written to each map are also written to its shadow map, which is created with a candidate hasher or storage layout, and the root hashes of both are compared in the shadow_writes metric")
+	maxGetIndices        = flag.Int("max_get_indices", 0, "Maximum number of indices read by each GetLeaves request. If zero, there is no limit")
+	maxSetLeaves         = flag.Int("max_set_leaves", 0, "Maximum number of leaves written by each SetLeaves, WriteLeaves or SetMultiMapLeaves request, or deleted by each DeleteLeafRange request. If zero, there is no limit, except that DeleteLeafRange deletes at most 10000 leaves")
+	maxRequestBytes      = flag.Int("max_request_bytes", 0, "Maximum size in bytes of each map write request. If zero, there is no limit")
+	requestTimeout       = flag.Duration("request_timeout", 0, "Maximum duration of each unary map request, in addition to client deadlines. If zero, there is no limit")
+	writeLeavesTimeout   = flag.Duration("write_leaves_timeout", 0, "Maximum duration of writing the leaves of each map write to storage. If zero, there is no limit")
+	preloadTimeout       = flag.Duration("preload_timeout", 0, "Maximum duration of preloading the Merkle nodes of each map write. If zero, there is no limit")
+	computeRootTimeout   = flag.Duration("compute_root_timeout", 0, "Maximum duration of updating the sparse Merkle tree of each map write and calculating its root. If zero, there is no limit")
+	signRootTimeout      = flag.Duration("sign_root_timeout", 0, "Maximum duration of signing and storing the root of each map write. If zero, there is no limit")
+	commitTimeout        = flag.Duration("commit_timeout", 0, "Maximum duration of committing the transaction of each SetLeaves or WriteLeaves request. If zero, there is no limit")
+	writeAPI             = flag.Bool("write_api", true, "If true, the TrillianMapWrite API is served alongside the TrillianMap API. Disable it when writes are served by trillian_map_write_server")
+	readOnly             = flag.Bool("read_only", false, "If true, writes are rejected with FailedPrecondition, the write API is not served and garbage collection is disabled, e.g. for servers which read from database replicas")
+	queueWrites          = flag.Bool("queue_writes", false, "If true, WriteLeaves queues the leaves to be merged into a later map revision and returns immediately. Requires MySQL storage")
+	mergeQueuedWrites    = flag.Bool("merge_queued_writes", false, "If true, writes queued by servers with --queue_writes are periodically merged into new map revisions")
+	mergeMinRunInterval  = flag.Duration("merge_min_run_interval", server.DefaultMergeMinInterval, "Minimum interval between merges of queued map writes. Actual runs happen randomly between [minInterval,2*minInterval).")
+	mergeBatchSize       = flag.Int("merge_batch_size", server.DefaultMergeBatchSize, "Maximum number of queued writes merged into each map revision")
+	hotKeyPrefixBytes    = flag.Int("hot_key_prefix_bytes", 0, "If set, the leaves written to each map are counted by this many leading bytes of their indices, and reported by the GetHotKeys RPC and the hottest_prefix_write_share metric")
+	hotKeyMaxPrefixes    = flag.Int("hot_key_max_prefixes", server.DefaultHotKeyMaxPrefixes, "Number of index prefixes counted for each map with --hot_key_prefix_bytes. Less written prefixes are replaced by new ones")
+	proofCheckRate       = flag.Float64("proof_check_rate", 0, "Fraction, from 0 to 1, of the leaves written to each map revision whose inclusion proofs are verified against the new root before it is signed. Writes whose proofs don't verify fail with Internal")
+
+	partialRevisionFallback = flag.Bool("partial_revision_fallback", false, "If true, leaves are read at the previous map revision if storage reports leaves or Merkle nodes of the latest one as NotFound, and the inclusion proofs of the leaves read are verified")
 
 	// Profiling related flags.
 	cpuProfile = flag.String("cpuprofile", "", "If set, write CPU profile to this file")
@@ -122,6 +160,7 @@ func main() {
 		NewKeyProto: func(ctx context.Context, spec *keyspb.Specification) (proto.Message, error) {
 			return der.NewProtoFromSpec(spec)
 		},
+		TreeNotifier: server.NewTreeNotifierFromFlags(),
 	}
 
 	// Enable CPU profile if requested.
@@ -133,16 +172,93 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
+	preload, err := server.NewPreloadStrategy(*preloadStrategy, *preloadMinBatchSize, *preloadParallelism)
+	if err != nil {
+		glog.Exitf("Invalid --preload_strategy: %v", err)
+	}
+	shadows, err := server.ParseShadowMaps(*shadowMaps)
+	if err != nil {
+		glog.Exitf("Invalid --shadow_maps: %v", err)
+	}
+
+	attester, err := server.NewAttesterFromFlags(registry)
+	if err != nil {
+		glog.Exitf("Failed to create attester: %v", err)
+	}
+	quarantiner, err := server.NewQuarantinerFromFlags(registry)
+	if err != nil {
+		glog.Exitf("Failed to create quarantiner: %v", err)
+	} else if quarantiner != nil && *readOnly {
+		glog.Exit("Trees cannot be quarantined by --read_only servers")
+	}
+	overloadBreaker, err := server.NewOverloadBreakerFromFlags(registry)
+	if err != nil {
+		glog.Exitf("Failed to create overload breaker: %v", err)
+	}
+	treeOwnership, err := server.NewTreeOwnershipFromFlags(registry)
+	if err != nil {
+		glog.Exitf("Failed to create tree ownership: %v", err)
+	}
+	// A nil *canary.Canary must not be stored in the ReadinessCheck field.
+	var readiness server.ReadinessCheck
+	c, err := canary.NewFromFlags(*rpcEndpoint, *tlsCertFile, registry.MetricFactory)
+	if err != nil {
+		glog.Exitf("Failed to create canary: %v", err)
+	} else if c != nil {
+		if *readOnly {
+			glog.Exit("Canary trees cannot be exercised by --read_only servers")
+		}
+		readiness = c
+	}
+
+	// The map merger notifies WatchSignedMapRoots streams of the roots it
+	// publishes, so it shares the map server.
+	mapServer := server.NewTrillianMapServer(registry,
+		server.TrillianMapServerOptions{
+			UseSingleTransaction: *useSingleTransaction,
+			Preload:              preload,
+			WatchPollInterval:    *watchPollInterval,
+			HedgeDelay:           *hedgeDelay,
+			ShadowMaps:           shadows,
+			NodeCacheSize:        *nodeCacheSize,
+			ReadOnly:             *readOnly,
+			QueueWrites:          *queueWrites,
+			ProofCheckRate:       *proofCheckRate,
+			HotKeys: server.MapHotKeyOptions{
+				PrefixBytes: *hotKeyPrefixBytes,
+				MaxPrefixes: *hotKeyMaxPrefixes,
+			},
+			Limits: server.MapLimits{
+				MaxGetIndices:   *maxGetIndices,
+				MaxSetLeaves:    *maxSetLeaves,
+				MaxRequestBytes: *maxRequestBytes,
+				RequestTimeout:  *requestTimeout,
+				StageTimeouts: server.MapStageTimeouts{
+					WriteLeaves: *writeLeavesTimeout,
+					Preload:     *preloadTimeout,
+					ComputeRoot: *computeRootTimeout,
+					SignRoot:    *signRootTimeout,
+					Commit:      *commitTimeout,
+				},
+			},
+			PartialRevisionFallback: *partialRevisionFallback,
+		})
+	var merger *server.MapMerger
+	if *mergeQueuedWrites && !*readOnly {
+		merger = server.NewMapMerger(mapServer, *mergeBatchSize, *mergeMinRunInterval, clock.System, mf)
+	}
+
 	m := server.Main{
-		RPCEndpoint:  *rpcEndpoint,
-		HTTPEndpoint: *httpEndpoint,
-		TLSCertFile:  *tlsCertFile,
-		TLSKeyFile:   *tlsKeyFile,
-		StatsPrefix:  "map",
-		ExtraOptions: options,
-		QuotaDryRun:  *quotaDryRun,
-		DBClose:      sp.Close,
-		Registry:     registry,
+		RPCEndpoint:     *rpcEndpoint,
+		HTTPEndpoint:    *httpEndpoint,
+		TLSCertFile:     *tlsCertFile,
+		TLSKeyFile:      *tlsKeyFile,
+		TLSClientCAFile: *tlsClientCAFile,
+		StatsPrefix:     "map",
+		ExtraOptions:    options,
+		QuotaDryRun:     *quotaDryRun,
+		DBClose:         sp.Close,
+		Registry:        registry,
 		RegisterHandlerFn: func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error {
 			if err := trillian.RegisterTrillianMapHandlerFromEndpoint(ctx, mux, endpoint, opts); err != nil {
 				return err
@@ -153,24 +269,15 @@ func main() {
 			return nil
 		},
 		RegisterServerFn: func(s *grpc.Server, registry extension.Registry) error {
-			mapServer := server.NewTrillianMapServer(registry,
-				server.TrillianMapServerOptions{
-					UseSingleTransaction: *useSingleTransaction,
-					UseLargePreload:      *largePreload,
-				})
-			if err := mapServer.IsHealthy(); err != nil {
-				return err
-			}
 			trillian.RegisterTrillianMapServer(s, mapServer)
 
-			if !*useSingleTransaction {
-				glog.Warning("Write API not recommended without single_transaction enabled")
+			if *writeAPI && !*readOnly {
+				if !*useSingleTransaction && !*queueWrites {
+					glog.Warning("Write API not recommended without single_transaction enabled")
+				}
+				writeServer := server.NewTrillianMapWriteServer(registry, mapServer)
+				trillian.RegisterTrillianMapWriteServer(s, writeServer)
 			}
-			writeServer := server.NewTrillianMapWriteServer(registry, mapServer)
-			if err := writeServer.IsHealthy(); err != nil {
-				return err
-			}
-			trillian.RegisterTrillianMapWriteServer(s, writeServer)
 
 			if *server.QuotaSystem == server.QuotaEtcd {
 				quotapb.RegisterQuotaServer(s, quotaapi.NewServer(client))
@@ -181,11 +288,26 @@ func main() {
 			as := sp.AdminStorage()
 			return as.CheckDatabaseAccessible(ctx)
 		},
-		HealthyDeadline:       *healthzTimeout,
-		AllowedTreeTypes:      []trillian.TreeType{trillian.TreeType_MAP},
-		TreeGCEnabled:         *treeGCEnabled,
-		TreeDeleteThreshold:   *treeDeleteThreshold,
-		TreeDeleteMinInterval: *treeDeleteMinRunInterval,
+		HealthyDeadline:        *healthzTimeout,
+		AllowedTreeTypes:       []trillian.TreeType{trillian.TreeType_MAP},
+		TreeGCEnabled:          *treeGCEnabled && !*readOnly,
+		TreeDeleteThreshold:    *treeDeleteThreshold,
+		TreeDeleteMinInterval:  *treeDeleteMinRunInterval,
+		KeyRotationEnabled:     *keyRotationEnabled && !*readOnly,
+		KeyRotationMinInterval: *keyRotationMinInterval,
+		RevisionGCEnabled:      *revisionGCEnabled && !*readOnly,
+		RevisionGCMinInterval:  *revisionGCMinRunInterval,
+		MapMerger:              merger,
+		Attester:               attester,
+		Quarantiner:            quarantiner,
+		Canary:                 readiness,
+		OverloadBreaker:        overloadBreaker,
+		TreeOwnership:          treeOwnership,
+		Health: server.HealthOptions{
+			Readiness:     *healthReadiness,
+			CheckInterval: *healthCheckInterval,
+			CheckTimeout:  *healthzTimeout,
+		},
 	}
 
 	ctx := context.Background()
@@ -0,0 +1,210 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The trillian_map_write_server binary serves the TrillianMapWrite API on its
+// own, so that the write path of maps can be scaled, rate limited and
+// firewalled separately from the read path served by trillian_map_server.
+package main
+
+import (
+	"context"
+	"flag"
+	_ "net/http/pprof" // Register pprof HTTP handlers.
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/cmd"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/monitoring/opencensus"
+	"github.com/google/trillian/monitoring/prometheus"
+	"github.com/google/trillian/server"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/grpc"
+
+	// Register key ProtoHandlers
+	_ "github.com/google/trillian/crypto/keys/der/proto"
+	_ "github.com/google/trillian/crypto/keys/pem/proto"
+	_ "github.com/google/trillian/crypto/keys/pkcs11/proto"
+
+	// Load hashers
+	_ "github.com/google/trillian/merkle/coniks"
+	_ "github.com/google/trillian/merkle/maphasher"
+)
+
+var (
+	rpcEndpoint     = flag.String("rpc_endpoint", "localhost:8094", "Endpoint for RPC requests (host:port)")
+	httpEndpoint    = flag.String("http_endpoint", "localhost:8095", "Endpoint for HTTP metrics and health checks (host:port, empty means disabled)")
+	healthzTimeout  = flag.Duration("healthz_timeout", time.Second*5, "Timeout used during healthz checks")
+	tlsCertFile     = flag.String("tls_cert_file", "", "Path to the TLS server certificate. If unset, the server will use unsecured connections.")
+	tlsKeyFile      = flag.String("tls_key_file", "", "Path to the TLS server key. If unset, the server will use unsecured connections.")
+	tlsClientCAFile = flag.String("tls_client_ca_file", "", "Path to the PEM certificates of the CAs which sign the certificates of RPC clients. If set, clients without such a certificate are rejected. Requires --tls_cert_file and --tls_key_file.")
+
+	healthReadiness     = flag.Bool("health_readiness", false, "If true, the gRPC health service and /readyz also require the signer keys of the writable trees served to load")
+	healthCheckInterval = flag.Duration("health_check_interval", server.DefaultHealthCheckInterval, "Interval between checks of the status reported by the gRPC health service")
+
+	quotaDryRun = flag.Bool("quota_dry_run", false, "If true no requests are blocked due to lack of tokens")
+
+	tracing          = flag.Bool("tracing", false, "If true opencensus Stackdriver tracing will be enabled. See https://opencensus.io/.")
+	tracingProjectID = flag.String("tracing_project_id", "", "project ID to pass to Stackdriver client. Can be empty for GCP, consult docs for other platforms.")
+	tracingPercent   = flag.Int("tracing_percent", 0, "Percent of requests to be traced. Zero is a special case to use the DefaultSampler")
+
+	configFile = flag.String("config", "", "Config file containing flags, file contents can be overridden by command line flags")
+
+	useSingleTransaction = flag.Bool("single_transaction", false, "Experimental: use a single transaction when updating the map")
+	preloadStrategy      = flag.String("preload_strategy", server.PreloadFullSibling, "Experimental: Merkle nodes preloaded to work-around locking performance issues when using single_transaction mode. One of none, full_sibling or adaptive")
+	preloadMinBatchSize  = flag.Int("preload_min_batch_size", server.DefaultAdaptivePreloadMinBatchSize, "Minimum number of leaves in an update for the adaptive preload strategy to preload nodes")
+	preloadParallelism   = flag.Int("preload_parallelism", 0, "Maximum number of goroutines computing the nodes to preload for each update. If zero, GOMAXPROCS is used")
+	maxGetIndices        = flag.Int("max_get_indices", 0, "Maximum number of indices read by each GetLeavesByRevision request. If zero, there is no limit")
+	maxSetLeaves         = flag.Int("max_set_leaves", 0, "Maximum number of leaves written by each WriteLeaves request, or deleted by each DeleteLeafRange request. If zero, there is no limit, except that DeleteLeafRange deletes at most 10000 leaves")
+	maxRequestBytes      = flag.Int("max_request_bytes", 0, "Maximum size in bytes of each WriteLeaves request. If zero, there is no limit")
+	requestTimeout       = flag.Duration("request_timeout", 0, "Maximum duration of each request, in addition to client deadlines. If zero, there is no limit")
+	writeLeavesTimeout   = flag.Duration("write_leaves_timeout", 0, "Maximum duration of writing the leaves of each map write to storage. If zero, there is no limit")
+	preloadTimeout       = flag.Duration("preload_timeout", 0, "Maximum duration of preloading the Merkle nodes of each map write. If zero, there is no limit")
+	computeRootTimeout   = flag.Duration("compute_root_timeout", 0, "Maximum duration of updating the sparse Merkle tree of each map write and calculating its root. If zero, there is no limit")
+	signRootTimeout      = flag.Duration("sign_root_timeout", 0, "Maximum duration of signing and storing the root of each map write. If zero, there is no limit")
+	commitTimeout        = flag.Duration("commit_timeout", 0, "Maximum duration of committing the transaction of each SetLeaves or WriteLeaves request. If zero, there is no limit")
+	queueWrites          = flag.Bool("queue_writes", false, "If true, WriteLeaves queues the leaves to be merged into a later map revision by a trillian_map_server with --merge_queued_writes, and returns immediately. Requires MySQL storage")
+	hotKeyPrefixBytes    = flag.Int("hot_key_prefix_bytes", 0, "If set, the leaves written to each map are counted by this many leading bytes of their indices, and reported by the GetHotKeys RPC and the hottest_prefix_write_share metric")
+	hotKeyMaxPrefixes    = flag.Int("hot_key_max_prefixes", server.DefaultHotKeyMaxPrefixes, "Number of index prefixes counted for each map with --hot_key_prefix_bytes. Less written prefixes are replaced by new ones")
+	proofCheckRate       = flag.Float64("proof_check_rate", 0, "Fraction, from 0 to 1, of the leaves written to each map revision whose inclusion proofs are verified against the new root before it is signed. Writes whose proofs don't verify fail with Internal")
+)
+
+func main() {
+	flag.Parse()
+
+	if *configFile != "" {
+		if err := cmd.ParseFlagFile(*configFile); err != nil {
+			glog.Exitf("Failed to load flags from config file %q: %s", *configFile, err)
+		}
+	}
+
+	var options []grpc.ServerOption
+	mf := prometheus.MetricFactory{}
+	monitoring.SetStartSpan(opencensus.StartSpan)
+
+	if *tracing {
+		opts, err := opencensus.EnableRPCServerTracing(*tracingProjectID, *tracingPercent)
+		if err != nil {
+			glog.Exitf("Failed to initialize stackdriver / opencensus tracing: %v", err)
+		}
+		// Enable the server request counter tracing etc.
+		options = append(options, opts...)
+	}
+
+	// The storage provider has its own connection pool, sized by the storage
+	// flags of this binary, e.g. --mysql_max_conns.
+	sp, err := server.NewStorageProviderFromFlags(mf)
+	if err != nil {
+		glog.Exitf("Failed to get storage provider: %v", err)
+	}
+	defer sp.Close()
+
+	qm, err := server.NewQuotaManagerFromFlags()
+	if err != nil {
+		glog.Exitf("Error creating quota manager: %v", err)
+	}
+
+	registry := extension.Registry{
+		AdminStorage:  sp.AdminStorage(),
+		MapStorage:    sp.MapStorage(),
+		QuotaManager:  qm,
+		MetricFactory: mf,
+	}
+
+	preload, err := server.NewPreloadStrategy(*preloadStrategy, *preloadMinBatchSize, *preloadParallelism)
+	if err != nil {
+		glog.Exitf("Invalid --preload_strategy: %v", err)
+	}
+
+	overloadBreaker, err := server.NewOverloadBreakerFromFlags(registry)
+	if err != nil {
+		glog.Exitf("Failed to create overload breaker: %v", err)
+	}
+	treeOwnership, err := server.NewTreeOwnershipFromFlags(registry)
+	if err != nil {
+		glog.Exitf("Failed to create tree ownership: %v", err)
+	}
+
+	m := server.Main{
+		RPCEndpoint:     *rpcEndpoint,
+		HTTPEndpoint:    *httpEndpoint,
+		TLSCertFile:     *tlsCertFile,
+		TLSKeyFile:      *tlsKeyFile,
+		TLSClientCAFile: *tlsClientCAFile,
+		StatsPrefix:     "mapwrite",
+		ExtraOptions:    options,
+		QuotaDryRun:     *quotaDryRun,
+		DBClose:         sp.Close,
+		Registry:        registry,
+		OverloadBreaker: overloadBreaker,
+		TreeOwnership:   treeOwnership,
+		// The TrillianMapWrite API has no REST mapping.
+		RegisterHandlerFn: func(context.Context, *runtime.ServeMux, string, []grpc.DialOption) error {
+			return nil
+		},
+		RegisterServerFn: func(s *grpc.Server, registry extension.Registry) error {
+			// The map server is only used for its implementation of writes, and
+			// is not registered.
+			mapServer := server.NewTrillianMapServer(registry,
+				server.TrillianMapServerOptions{
+					UseSingleTransaction: *useSingleTransaction,
+					Preload:              preload,
+					QueueWrites:          *queueWrites,
+					ProofCheckRate:       *proofCheckRate,
+					HotKeys: server.MapHotKeyOptions{
+						PrefixBytes: *hotKeyPrefixBytes,
+						MaxPrefixes: *hotKeyMaxPrefixes,
+					},
+					Limits: server.MapLimits{
+						MaxGetIndices:   *maxGetIndices,
+						MaxSetLeaves:    *maxSetLeaves,
+						MaxRequestBytes: *maxRequestBytes,
+						RequestTimeout:  *requestTimeout,
+						StageTimeouts: server.MapStageTimeouts{
+							WriteLeaves: *writeLeavesTimeout,
+							Preload:     *preloadTimeout,
+							ComputeRoot: *computeRootTimeout,
+							SignRoot:    *signRootTimeout,
+							Commit:      *commitTimeout,
+						},
+					},
+				})
+			if !*useSingleTransaction && !*queueWrites {
+				glog.Warning("Write API not recommended without single_transaction enabled")
+			}
+			writeServer := server.NewTrillianMapWriteServer(registry, mapServer)
+			trillian.RegisterTrillianMapWriteServer(s, writeServer)
+			return nil
+		},
+		IsHealthy: func(ctx context.Context) error {
+			as := sp.AdminStorage()
+			return as.CheckDatabaseAccessible(ctx)
+		},
+		HealthyDeadline: *healthzTimeout,
+		// Trees are managed through trillian_map_server.
+		AdminDisabled: true,
+		Health: server.HealthOptions{
+			Readiness:     *healthReadiness,
+			CheckInterval: *healthCheckInterval,
+			CheckTimeout:  *healthzTimeout,
+		},
+	}
+
+	ctx := context.Background()
+	if err := m.Run(ctx); err != nil {
+		glog.Exitf("Server exited with error: %v", err)
+	}
+}
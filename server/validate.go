@@ -19,40 +19,52 @@ import (
 
 	"github.com/google/trillian"
 	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/server/errmsg"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 func validateGetInclusionProofRequest(req *trillian.GetInclusionProofRequest) error {
 	if req.TreeSize <= 0 {
-		return status.Errorf(codes.InvalidArgument, "GetInclusionProofRequest.TreeSize: %v, want > 0", req.TreeSize)
+		return errNotPositive("GetInclusionProofRequest.TreeSize", req.TreeSize)
 	}
 	if req.LeafIndex < 0 {
-		return status.Errorf(codes.InvalidArgument, "GetInclusionProofRequest.LeafIndex: %v, want >= 0", req.LeafIndex)
+		return errNegative("GetInclusionProofRequest.LeafIndex", req.LeafIndex)
 	}
 	if req.LeafIndex >= req.TreeSize {
-		return status.Errorf(codes.InvalidArgument, "GetInclusionProofRequest.LeafIndex: %v >= TreeSize: %v, want < ", req.LeafIndex, req.TreeSize)
+		return errBeyondTreeSize("GetInclusionProofRequest.LeafIndex", req.LeafIndex, req.TreeSize)
 	}
 	return nil
 }
 
 func validateGetInclusionProofByHashRequest(req *trillian.GetInclusionProofByHashRequest, hasher hashers.LogHasher) error {
 	if req.TreeSize <= 0 {
-		return status.Errorf(codes.InvalidArgument, "GetInclusionProofByHashRequest.TreeSize: %v, want > 0", req.TreeSize)
+		return errNotPositive("GetInclusionProofByHashRequest.TreeSize", req.TreeSize)
 	}
-	if err := validateLeafHash(req.LeafHash, hasher); err != nil {
-		return status.Errorf(codes.InvalidArgument, "GetInclusionProofByHashRequest.LeafHash: %v", err)
+	return validateLeafHash(req.LeafHash, hasher, "GetInclusionProofByHashRequest.LeafHash")
+}
+
+func validateGetInclusionProofByHashBatchRequest(req *trillian.GetInclusionProofByHashBatchRequest, hasher hashers.LogHasher) error {
+	if req.TreeSize <= 0 {
+		return errNotPositive("GetInclusionProofByHashBatchRequest.TreeSize", req.TreeSize)
+	}
+	if len(req.LeafHash) == 0 {
+		return errEmpty("GetInclusionProofByHashBatchRequest.LeafHash")
+	}
+	for i, hash := range req.LeafHash {
+		if err := validateLeafHash(hash, hasher, fmt.Sprintf("GetInclusionProofByHashBatchRequest.LeafHash[%v]", i)); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
 func validateGetLeavesByHashRequest(req *trillian.GetLeavesByHashRequest, hasher hashers.LogHasher) error {
 	if len(req.LeafHash) == 0 {
-		return status.Error(codes.InvalidArgument, "GetLeavesByHashRequest.LeafHash empty")
+		return errEmpty("GetLeavesByHashRequest.LeafHash")
 	}
 	for i, hash := range req.LeafHash {
-		if err := validateLeafHash(hash, hasher); err != nil {
-			return status.Errorf(codes.InvalidArgument, "GetLeavesByHashRequest.LeafHash[%v]: %v", i, err)
+		if err := validateLeafHash(hash, hasher, fmt.Sprintf("GetLeavesByHashRequest.LeafHash[%v]", i)); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -60,11 +72,11 @@ func validateGetLeavesByHashRequest(req *trillian.GetLeavesByHashRequest, hasher
 
 func validateGetLeavesByIndexRequest(req *trillian.GetLeavesByIndexRequest) error {
 	if len(req.LeafIndex) == 0 {
-		return status.Error(codes.InvalidArgument, "GetLeavesByIndexRequest.LeafIndex empty")
+		return errEmpty("GetLeavesByIndexRequest.LeafIndex")
 	}
 	for i, leafIndex := range req.LeafIndex {
 		if leafIndex < 0 {
-			return status.Errorf(codes.InvalidArgument, "GetLeavesByIndexRequest.LeafIndex[%v]: %v, want >= 0", i, leafIndex)
+			return errNegative(fmt.Sprintf("GetLeavesByIndexRequest.LeafIndex[%v]", i), leafIndex)
 		}
 	}
 	return nil
@@ -72,36 +84,65 @@ func validateGetLeavesByIndexRequest(req *trillian.GetLeavesByIndexRequest) erro
 
 func validateGetLeavesByRangeRequest(req *trillian.GetLeavesByRangeRequest) error {
 	if req.StartIndex < 0 {
-		return status.Errorf(codes.InvalidArgument, "GetLeavesByRangeRequest.StartIndex: %v, want >= 0", req.StartIndex)
+		return errNegative("GetLeavesByRangeRequest.StartIndex", req.StartIndex)
 	}
 	if req.Count <= 0 {
-		return status.Errorf(codes.InvalidArgument, "GetLeavesByRangeRequest.Count: %v, want > 0", req.Count)
+		return errNotPositive("GetLeavesByRangeRequest.Count", req.Count)
+	}
+	if req.TreeSize < 0 {
+		return errNegative("GetLeavesByRangeRequest.TreeSize", req.TreeSize)
+	}
+	return nil
+}
+
+func validateGetLeavesByRangeStreamRequest(req *trillian.GetLeavesByRangeStreamRequest) error {
+	if req.StartIndex < 0 {
+		return errNegative("GetLeavesByRangeStreamRequest.StartIndex", req.StartIndex)
+	}
+	if req.Count < 0 {
+		return errNegative("GetLeavesByRangeStreamRequest.Count", req.Count)
+	}
+	if req.TreeSize < 0 {
+		return errNegative("GetLeavesByRangeStreamRequest.TreeSize", req.TreeSize)
+	}
+	if req.ChunkSize < 0 {
+		return errNegative("GetLeavesByRangeStreamRequest.ChunkSize", int64(req.ChunkSize))
 	}
 	return nil
 }
 
 func validateGetConsistencyProofRequest(req *trillian.GetConsistencyProofRequest) error {
 	if req.FirstTreeSize <= 0 {
-		return status.Errorf(codes.InvalidArgument, "GetConsistencyProofRequest.FirstTreeSize: %v, want > 0", req.FirstTreeSize)
+		return errNotPositive("GetConsistencyProofRequest.FirstTreeSize", req.FirstTreeSize)
 	}
 	if req.SecondTreeSize <= 0 {
-		return status.Errorf(codes.InvalidArgument, "GetConsistencyProofRequest.SecondTreeSize: %v, want > 0", req.SecondTreeSize)
+		return errNotPositive("GetConsistencyProofRequest.SecondTreeSize", req.SecondTreeSize)
 	}
 	if req.SecondTreeSize < req.FirstTreeSize {
-		return status.Errorf(codes.InvalidArgument, "GetConsistencyProofRequest.SecondTreeSize: %v < GetConsistencyProofRequest.FirstTreeSize: %v, want >= ", req.SecondTreeSize, req.FirstTreeSize)
+		return errmsg.New(codes.InvalidArgument, errmsg.TreeSizesOutOfOrder, errmsg.Params{"first": req.FirstTreeSize, "second": req.SecondTreeSize})
+	}
+	return nil
+}
+
+func validateGetConsistencyProofByRootHashRequest(req *trillian.GetConsistencyProofByRootHashRequest) error {
+	if len(req.FirstRootHash) == 0 {
+		return errEmpty("GetConsistencyProofByRootHashRequest.FirstRootHash")
+	}
+	if len(req.SecondRootHash) == 0 {
+		return errEmpty("GetConsistencyProofByRootHashRequest.SecondRootHash")
 	}
 	return nil
 }
 
 func validateGetEntryAndProofRequest(req *trillian.GetEntryAndProofRequest) error {
 	if req.TreeSize <= 0 {
-		return status.Errorf(codes.InvalidArgument, "GetEntryAndProofRequest.TreeSize: %v, want > 0", req.TreeSize)
+		return errNotPositive("GetEntryAndProofRequest.TreeSize", req.TreeSize)
 	}
 	if req.LeafIndex < 0 {
-		return status.Errorf(codes.InvalidArgument, "GetEntryAndProofRequest.LeafIndex: %v, want >= 0", req.LeafIndex)
+		return errNegative("GetEntryAndProofRequest.LeafIndex", req.LeafIndex)
 	}
 	if req.LeafIndex >= req.TreeSize {
-		return status.Errorf(codes.InvalidArgument, "GetEntryAndProofRequest.LeafIndex: %v >= TreeSize: %v, want < ", req.LeafIndex, req.TreeSize)
+		return errBeyondTreeSize("GetEntryAndProofRequest.LeafIndex", req.LeafIndex, req.TreeSize)
 	}
 	return nil
 }
@@ -113,10 +154,33 @@ func validateAddSequencedLeavesRequest(req *trillian.AddSequencedLeavesRequest)
 	}
 
 	// Note: Not empty, as verified by validateLogLeaves.
-	nextIndex := req.Leaves[0].LeafIndex
-	for i, leaf := range req.Leaves {
+	return validateLeafIndices(req.Leaves, req.Leaves[0].LeafIndex, prefix)
+}
+
+// validateAddSequencedLeavesStreamRequest checks a request of an
+// AddSequencedLeavesStream, whose leaves must start at nextIndex unless it's
+// the first request of the stream.
+func validateAddSequencedLeavesStreamRequest(req *trillian.AddSequencedLeavesStreamRequest, first bool, nextIndex int64) error {
+	prefix := "AddSequencedLeavesStreamRequest"
+	if err := validateLogLeaves(req.Leaves, prefix); err != nil {
+		return err
+	}
+	if first {
+		nextIndex = req.Leaves[0].LeafIndex
+	}
+	return validateLeafIndices(req.Leaves, nextIndex, prefix)
+}
+
+// validateLeafIndices checks that the indices of leaves are contiguous, from
+// nextIndex.
+func validateLeafIndices(leaves []*trillian.LogLeaf, nextIndex int64, errPrefix string) error {
+	for i, leaf := range leaves {
 		if leaf.LeafIndex != nextIndex {
-			return status.Errorf(codes.FailedPrecondition, "%v.Leaves[%v].LeafIndex=%v, want %v", prefix, i, leaf.LeafIndex, nextIndex)
+			return errmsg.New(codes.FailedPrecondition, errmsg.LeafIndexNotSequential, errmsg.Params{
+				"field": fmt.Sprintf("%v.Leaves[%v].LeafIndex", errPrefix, i),
+				"value": leaf.LeafIndex,
+				"want":  nextIndex,
+			})
 		}
 		nextIndex++
 	}
@@ -125,11 +189,11 @@ func validateAddSequencedLeavesRequest(req *trillian.AddSequencedLeavesRequest)
 
 func validateLogLeaves(leaves []*trillian.LogLeaf, errPrefix string) error {
 	if len(leaves) == 0 {
-		return status.Errorf(codes.InvalidArgument, "%v.Leaves empty", errPrefix)
+		return errEmpty(errPrefix + ".Leaves")
 	}
 	for i, leaf := range leaves {
-		if err := validateLogLeaf(leaf, ""); err != nil {
-			return status.Errorf(codes.InvalidArgument, "%v.Leaves[%v]%v", errPrefix, i, err)
+		if err := validateLogLeaf(leaf, fmt.Sprintf("%v.Leaves[%v]", errPrefix, i)); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -137,20 +201,36 @@ func validateLogLeaves(leaves []*trillian.LogLeaf, errPrefix string) error {
 
 func validateLogLeaf(leaf *trillian.LogLeaf, errPrefix string) error {
 	if leaf == nil {
-		return status.Errorf(codes.InvalidArgument, "%v empty", errPrefix)
+		return errEmpty(errPrefix)
 	}
 	switch {
 	case len(leaf.LeafValue) == 0:
-		return status.Errorf(codes.InvalidArgument, "%v.LeafValue: empty", errPrefix)
+		return errEmpty(errPrefix + ".LeafValue")
 	case leaf.LeafIndex < 0:
-		return status.Errorf(codes.InvalidArgument, "%v.LeafIndex: %v, want >= 0", errPrefix, leaf.LeafIndex)
+		return errNegative(errPrefix+".LeafIndex", leaf.LeafIndex)
 	}
 	return nil
 }
 
-func validateLeafHash(hash []byte, hasher hashers.LogHasher) error {
+func validateLeafHash(hash []byte, hasher hashers.LogHasher, field string) error {
 	if got, want := len(hash), hasher.Size(); got != want {
-		return fmt.Errorf("%d bytes, want %d", got, want)
+		return errmsg.New(codes.InvalidArgument, errmsg.LeafHashWrongSize, errmsg.Params{"field": field, "got": got, "want": want})
 	}
 	return nil
 }
+
+func errEmpty(field string) error {
+	return errmsg.New(codes.InvalidArgument, errmsg.FieldEmpty, errmsg.Params{"field": field})
+}
+
+func errNegative(field string, value int64) error {
+	return errmsg.New(codes.InvalidArgument, errmsg.FieldNegative, errmsg.Params{"field": field, "value": value})
+}
+
+func errNotPositive(field string, value int64) error {
+	return errmsg.New(codes.InvalidArgument, errmsg.FieldNotPositive, errmsg.Params{"field": field, "value": value})
+}
+
+func errBeyondTreeSize(field string, index, size int64) error {
+	return errmsg.New(codes.InvalidArgument, errmsg.IndexBeyondTreeSize, errmsg.Params{"field": field, "value": index, "size": size})
+}
@@ -69,6 +69,27 @@ func CreateTree(ctx context.Context, admin AdminStorage, tree *trillian.Tree) (*
 	return createdTree, err
 }
 
+// CreateTrees creates trees in storage in a single transaction, so either all
+// of them are created or none is.
+// It's a convenience wrapper around ReadWriteTransaction and AdminWriter's CreateTree.
+func CreateTrees(ctx context.Context, admin AdminStorage, trees []*trillian.Tree) ([]*trillian.Tree, error) {
+	ctx, spanEnd := spanFor(ctx, "CreateTrees")
+	defer spanEnd()
+	var createdTrees []*trillian.Tree
+	err := admin.ReadWriteTransaction(ctx, func(ctx context.Context, tx AdminTX) error {
+		createdTrees = make([]*trillian.Tree, 0, len(trees))
+		for _, tree := range trees {
+			createdTree, err := tx.CreateTree(ctx, tree)
+			if err != nil {
+				return err
+			}
+			createdTrees = append(createdTrees, createdTree)
+		}
+		return nil
+	})
+	return createdTrees, err
+}
+
 // UpdateTree updates a tree in storage.
 // It's a convenience wrapper around ReadWriteTransaction and AdminWriter's UpdateTree.
 // See ReadWriteTransaction if you need to perform more than one action per transaction.
@@ -94,8 +94,10 @@ type AdminReader interface {
 type AdminWriter interface {
 	// CreateTree inserts the specified tree in storage, returning a tree
 	// with all storage-generated fields set.
-	// Note that treeID and timestamps will be automatically generated by
-	// the storage layer, thus may be ignored by the implementation.
+	// The tree gets a new random ID, unless its treeID is set, in which case
+	// creation fails with codes.AlreadyExists if another tree has that ID.
+	// Note that timestamps will be automatically generated by the storage
+	// layer, thus may be ignored by the implementation.
 	// Remaining fields must be set to valid values.
 	// Returns an error if the tree is invalid or creation fails.
 	CreateTree(ctx context.Context, tree *trillian.Tree) (*trillian.Tree, error)
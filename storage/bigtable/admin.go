@@ -0,0 +1,423 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	bt "cloud.google.com/go/bigtable"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NewAdminStorage returns a Cloud Bigtable storage.AdminStorage implementation
+// backed by tbl, which must have been created by CreateTable.
+func NewAdminStorage(tbl *bt.Table) storage.AdminStorage {
+	return &adminStorage{ts: newTreeStorage(tbl, TreeStorageOptions{})}
+}
+
+// adminStorage implements storage.AdminStorage.
+type adminStorage struct {
+	ts *treeStorage
+}
+
+func (s *adminStorage) Snapshot(ctx context.Context) (storage.ReadOnlyAdminTX, error) {
+	return s.begin(), nil
+}
+
+func (s *adminStorage) begin() *adminTX {
+	return &adminTX{ts: s.ts, pending: make(map[int64]*pendingTree)}
+}
+
+func (s *adminStorage) ReadWriteTransaction(ctx context.Context, f storage.AdminTXFunc) error {
+	tx := s.begin()
+	defer tx.Close()
+	if err := f(ctx, tx); err != nil {
+		return err
+	}
+	return tx.commit(ctx)
+}
+
+func (s *adminStorage) CheckDatabaseAccessible(ctx context.Context) error {
+	return checkDatabaseAccessible(ctx, s.ts.tbl)
+}
+
+// pendingTree is a change to a tree made by an adminTX.
+type pendingTree struct {
+	// tree is the tree after the change, and nil if it was hard deleted.
+	tree    *trillian.Tree
+	created bool
+}
+
+// adminTX buffers its changes to trees, which it sees in its reads, until it
+// commits. Each tree is changed atomically, but the changes to several trees
+// are not.
+type adminTX struct {
+	ts *treeStorage
+
+	// mu guards closed and the pending changes.
+	mu      sync.RWMutex
+	closed  bool
+	pending map[int64]*pendingTree
+	// order holds the IDs of the changed trees, in the order of their first
+	// change.
+	order []int64
+}
+
+// Commit closes a read-only transaction. Read-write transactions are
+// committed by ReadWriteTransaction.
+func (t *adminTX) Commit() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	return nil
+}
+
+func (t *adminTX) commit(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrTransactionClosed
+	}
+	t.closed = true
+	for _, id := range t.order {
+		if err := t.apply(ctx, id, t.pending[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply writes the change p to the tree with treeID.
+func (t *adminTX) apply(ctx context.Context, treeID int64, p *pendingTree) error {
+	if p.tree == nil {
+		return t.hardDelete(ctx, treeID)
+	}
+	b, err := proto.Marshal(p.tree)
+	if err != nil {
+		return fmt.Errorf("could not marshal tree %d: %v", treeID, err)
+	}
+	m := bt.NewMutation()
+	m.Set(metaFamily, colTree, 0, b)
+	if !p.created {
+		return t.ts.tbl.Apply(ctx, treeKey(treeID), m)
+	}
+	m.Set(metaFamily, colHead, 0, []byte(head{rev: -1}.String()))
+	var exists bool
+	cond := bt.NewCondMutation(column(metaFamily, colTree), nil, m)
+	if err := t.ts.tbl.Apply(ctx, treeKey(treeID), cond, bt.GetCondMutationResult(&exists)); err != nil {
+		return err
+	}
+	if exists {
+		return status.Errorf(codes.AlreadyExists, "tree %d already exists", treeID)
+	}
+	return nil
+}
+
+// hardDelete deletes the rows of the tree with treeID, and then the tree.
+func (t *adminTX) hardDelete(ctx context.Context, treeID int64) error {
+	var rows batch
+	prefix := fmt.Sprintf("%016x/", uint64(treeID))
+	err := t.ts.tbl.ReadRows(ctx, bt.PrefixRange(prefix), func(r bt.Row) bool {
+		rows.row(r.Key()).DeleteRow()
+		return true
+	}, bt.RowFilter(bt.ChainFilters(bt.CellsPerRowLimitFilter(1), bt.StripValueFilter())))
+	if err != nil {
+		return err
+	}
+	if err := rows.apply(ctx, t.ts.tbl); err != nil {
+		return err
+	}
+	return t.ts.tbl.Apply(ctx, treeKey(treeID), deleteRow())
+}
+
+func (t *adminTX) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	t.pending, t.order = nil, nil
+	return nil
+}
+
+func (t *adminTX) IsClosed() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.closed
+}
+
+func (t *adminTX) Close() error {
+	if !t.IsClosed() {
+		if err := t.Rollback(); err != nil {
+			glog.Warningf("Rollback error on Close(): %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// setPending records a change to the tree with treeID.
+func (t *adminTX) setPending(treeID int64, p *pendingTree) {
+	if old, ok := t.pending[treeID]; ok {
+		// A tree created by the transaction is still created on commit.
+		p.created = p.created || (old.created && p.tree != nil)
+	} else {
+		t.order = append(t.order, treeID)
+	}
+	t.pending[treeID] = p
+}
+
+// getTree returns the tree with treeID as seen by the transaction.
+func (t *adminTX) getTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
+	if t.closed {
+		return nil, ErrTransactionClosed
+	}
+	if p, ok := t.pending[treeID]; ok {
+		if p.tree == nil {
+			return nil, status.Errorf(codes.NotFound, "tree %v not found", treeID)
+		}
+		return proto.Clone(p.tree).(*trillian.Tree), nil
+	}
+	tree, _, err := t.ts.readTree(ctx, treeID)
+	return tree, err
+}
+
+func (t *adminTX) GetTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.getTree(ctx, treeID)
+}
+
+// readTrees returns all the trees stored in tbl, in ascending order of ID.
+func readTrees(ctx context.Context, tbl *bt.Table) ([]*trillian.Tree, error) {
+	trees := []*trillian.Tree{}
+	var readErr error
+	err := tbl.ReadRows(ctx, bt.PrefixRange("trees/"), func(r bt.Row) bool {
+		b, _ := cellValue(r, metaFamily, colTree)
+		tree := &trillian.Tree{}
+		if readErr = proto.Unmarshal(b, tree); readErr != nil {
+			readErr = fmt.Errorf("could not unmarshal tree from row %q: %v", r.Key(), readErr)
+			return false
+		}
+		trees = append(trees, tree)
+		return true
+	}, bt.RowFilter(column(metaFamily, colTree)))
+	if err != nil {
+		return nil, err
+	}
+	return trees, readErr
+}
+
+func (t *adminTX) ListTreeIDs(ctx context.Context, includeDeleted bool) ([]int64, error) {
+	trees, err := t.ListTrees(ctx, includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	treeIDs := []int64{}
+	for _, tree := range trees {
+		treeIDs = append(treeIDs, tree.TreeId)
+	}
+	return treeIDs, nil
+}
+
+func (t *adminTX) ListTrees(ctx context.Context, includeDeleted bool) ([]*trillian.Tree, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return nil, ErrTransactionClosed
+	}
+
+	stored, err := readTrees(ctx, t.ts.tbl)
+	if err != nil {
+		return nil, err
+	}
+	trees := []*trillian.Tree{}
+	for _, tree := range stored {
+		if _, ok := t.pending[tree.TreeId]; !ok {
+			trees = append(trees, tree)
+		}
+	}
+	for _, p := range t.pending {
+		if p.tree != nil {
+			trees = append(trees, proto.Clone(p.tree).(*trillian.Tree))
+		}
+	}
+	sort.Slice(trees, func(i, j int) bool { return trees[i].TreeId < trees[j].TreeId })
+
+	if includeDeleted {
+		return trees, nil
+	}
+	ret := []*trillian.Tree{}
+	for _, tree := range trees {
+		if !tree.Deleted {
+			ret = append(ret, tree)
+		}
+	}
+	return ret, nil
+}
+
+// now returns the current time, truncated to millis like the other storage
+// implementations.
+func now() time.Time {
+	return storage.FromMillisSinceEpoch(storage.ToMillisSinceEpoch(time.Now()))
+}
+
+func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillian.Tree, error) {
+	if err := storage.ValidateTreeForCreation(ctx, tree); err != nil {
+		return nil, err
+	}
+	if err := validateStorageSettings(tree); err != nil {
+		return nil, err
+	}
+
+	id, err := storage.TreeIDForCreation(tree)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tree.TreeId != 0 {
+		if _, err := t.getTree(ctx, id); status.Code(err) != codes.NotFound {
+			if err == nil {
+				err = status.Errorf(codes.AlreadyExists, "tree %d already exists", id)
+			}
+			return nil, err
+		}
+	}
+
+	newTree := proto.Clone(tree).(*trillian.Tree)
+	newTree.TreeId = id
+	newTree.CreateTime, err = ptypes.TimestampProto(now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to build create time: %v", err)
+	}
+	newTree.UpdateTime = newTree.CreateTime
+	t.setPending(id, &pendingTree{tree: newTree, created: true})
+	return proto.Clone(newTree).(*trillian.Tree), nil
+}
+
+func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(*trillian.Tree)) (*trillian.Tree, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tree, err := t.getTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+
+	beforeUpdate := proto.Clone(tree).(*trillian.Tree)
+	updateFunc(tree)
+	if err := storage.ValidateTreeForUpdate(ctx, beforeUpdate, tree); err != nil {
+		return nil, err
+	}
+	if err := validateStorageSettings(tree); err != nil {
+		return nil, err
+	}
+	if !proto.Equal(beforeUpdate.StorageSettings, tree.StorageSettings) {
+		return nil, status.Error(codes.InvalidArgument, "readonly field changed: storage_settings")
+	}
+
+	if tree.UpdateTime, err = ptypes.TimestampProto(now()); err != nil {
+		return nil, fmt.Errorf("failed to build update time: %v", err)
+	}
+	t.setPending(treeID, &pendingTree{tree: tree})
+	return proto.Clone(tree).(*trillian.Tree), nil
+}
+
+func (t *adminTX) SoftDeleteTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
+	return t.updateDeleted(ctx, treeID, true /* deleted */)
+}
+
+func (t *adminTX) UndeleteTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
+	return t.updateDeleted(ctx, treeID, false /* deleted */)
+}
+
+// updateDeleted updates the Deleted and DeleteTime fields of the specified tree.
+func (t *adminTX) updateDeleted(ctx context.Context, treeID int64, deleted bool) (*trillian.Tree, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tree, err := t.validateDeleted(ctx, treeID, !deleted)
+	if err != nil {
+		return nil, err
+	}
+	tree.Deleted, tree.DeleteTime = deleted, nil
+	if deleted {
+		if tree.DeleteTime, err = ptypes.TimestampProto(now()); err != nil {
+			return nil, fmt.Errorf("failed to build delete time: %v", err)
+		}
+	}
+	t.setPending(treeID, &pendingTree{tree: tree})
+	return proto.Clone(tree).(*trillian.Tree), nil
+}
+
+func (t *adminTX) HardDeleteTree(ctx context.Context, treeID int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.validateDeleted(ctx, treeID, true /* wantDeleted */); err != nil {
+		return err
+	}
+	t.setPending(treeID, &pendingTree{})
+	return nil
+}
+
+// validateDeleted returns the tree with treeID if its soft deletion state is
+// wantDeleted.
+func (t *adminTX) validateDeleted(ctx context.Context, treeID int64, wantDeleted bool) (*trillian.Tree, error) {
+	tree, err := t.getTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case wantDeleted && !tree.Deleted:
+		return nil, status.Errorf(codes.FailedPrecondition, "tree %v is not soft deleted", treeID)
+	case !wantDeleted && tree.Deleted:
+		return nil, status.Errorf(codes.FailedPrecondition, "tree %v already soft deleted", treeID)
+	}
+	return tree, nil
+}
+
+// validateStorageSettings checks that tree has no storage_settings, other than
+// the MapStorageSettings of maps.
+func validateStorageSettings(tree *trillian.Tree) error {
+	if tree.StorageSettings == nil {
+		return nil
+	}
+	if tree.TreeType != trillian.TreeType_MAP {
+		return fmt.Errorf("storage_settings not supported, but got %v", tree.StorageSettings)
+	}
+	_, err := mapStorageSettings(tree)
+	return err
+}
+
+// mapStorageSettings returns the MapStorageSettings held in the
+// storage_settings of tree, which are empty if it has none.
+func mapStorageSettings(tree *trillian.Tree) (*storagepb.MapStorageSettings, error) {
+	settings := &storagepb.MapStorageSettings{}
+	if tree.StorageSettings == nil {
+		return settings, nil
+	}
+	if err := ptypes.UnmarshalAny(tree.StorageSettings, settings); err != nil {
+		return nil, fmt.Errorf("storage_settings not supported, but got %v: %v", tree.StorageSettings, err)
+	}
+	return settings, nil
+}
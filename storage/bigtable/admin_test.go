@@ -0,0 +1,40 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/testonly"
+
+	bt "cloud.google.com/go/bigtable"
+)
+
+func TestBigtableAdminStorage(t *testing.T) {
+	ctx := context.Background()
+	var done func()
+	tester := &testonly.AdminStorageTester{NewAdminStorage: func() storage.AdminStorage {
+		if done != nil {
+			done()
+		}
+		var tbl *bt.Table
+		tbl, done = newTestTable(ctx, t)
+		return NewAdminStorage(tbl)
+	}}
+	defer func() { done() }()
+	tester.RunAllTests(t)
+}
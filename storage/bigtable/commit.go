@@ -0,0 +1,541 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	bt "cloud.google.com/go/bigtable"
+	"github.com/golang/glog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxBulkRows is the number of rows written by a single ApplyBulk call.
+	maxBulkRows = 500
+
+	// leaseRetryInterval is how often a writer waiting for the commit lease of
+	// a tree checks whether it has been released.
+	leaseRetryInterval = 50 * time.Millisecond
+
+	stateCommitted = "committed"
+	stateAborted   = "aborted"
+)
+
+// batch is a set of row mutations, which are applied in the order in which
+// their rows were first added.
+type batch struct {
+	keys []string
+	muts map[string]*bt.Mutation
+}
+
+// row returns the mutation of the row with key.
+func (b *batch) row(key string) *bt.Mutation {
+	if b.muts == nil {
+		b.muts = make(map[string]*bt.Mutation)
+	}
+	m, ok := b.muts[key]
+	if !ok {
+		m = bt.NewMutation()
+		b.muts[key] = m
+		b.keys = append(b.keys, key)
+	}
+	return m
+}
+
+func (b *batch) empty() bool {
+	return len(b.keys) == 0
+}
+
+// apply applies the mutations of b. Each row is mutated atomically, but b as a
+// whole is not.
+func (b *batch) apply(ctx context.Context, tbl *bt.Table) error {
+	for start := 0; start < len(b.keys); start += maxBulkRows {
+		end := start + maxBulkRows
+		if end > len(b.keys) {
+			end = len(b.keys)
+		}
+		keys := b.keys[start:end]
+		muts := make([]*bt.Mutation, len(keys))
+		for i, key := range keys {
+			muts[i] = b.muts[key]
+		}
+		errs, err := tbl.ApplyBulk(ctx, keys, muts)
+		if err != nil {
+			return err
+		}
+		for i, err := range errs {
+			if err != nil {
+				return fmt.Errorf("failed to write row %q: %v", keys[i], err)
+			}
+		}
+	}
+	return nil
+}
+
+// cellRef names a cell written by a commit.
+type cellRef struct {
+	Row, Family, Column string
+}
+
+// intent is the record, kept in the row of a tree while a revision is being
+// committed, of the cells written at the revision.
+type intent struct {
+	Revision int64
+	// Commit is the key of the commit record deciding the outcome of a commit
+	// spanning several trees, and empty for a commit to a single tree.
+	Commit string `json:",omitempty"`
+	Cells  []cellRef
+}
+
+// writer buffers the writes of a read-write transaction on a tree.
+type writer struct {
+	treeID int64
+	// lease is the head of the tree while the transaction holds its lease.
+	lease head
+	// rev is the revision the transaction writes.
+	rev int64
+
+	// pending holds the cells written at rev, which become visible when the
+	// head is set to rev, and cells lists them.
+	pending batch
+	cells   []cellRef
+	// direct holds changes to committed revisions.
+	direct batch
+	// post holds the deletions applied once the transaction has committed.
+	post batch
+	// root is set once the root of rev has been written.
+	root bool
+	// keepIntent is set for maps, whose roots keep the intents of their
+	// revisions, see keep.
+	keepIntent bool
+}
+
+func newWriter(treeID int64, lease head) *writer {
+	return &writer{treeID: treeID, lease: lease, rev: lease.rev + 1}
+}
+
+// set buffers the write of value to the cell family:col of row at the write
+// revision. Cells of the meta family are written at timestamp 0.
+func (w *writer) set(row, family, col string, value []byte) {
+	ts := bt.Timestamp(0)
+	if family == verFamily {
+		ts = revTS(w.rev)
+	}
+	w.pending.row(row).Set(family, col, ts, value)
+	w.cells = append(w.cells, cellRef{Row: row, Family: family, Column: col})
+}
+
+// keep adds a copy of in, the intent of the revision of w, to its root row if
+// w keeps intents, so that readers can tell which cells of the revision they
+// should find. The copy lists itself, so that it's rolled back with the
+// revision.
+func (w *writer) keep(in *intent) error {
+	if !w.keepIntent {
+		return nil
+	}
+	key := rootKey(w.treeID, w.rev)
+	in.Cells = append(in.Cells, cellRef{Row: key, Family: metaFamily, Column: colIntent})
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	w.pending.row(key).Set(metaFamily, colIntent, 0, b)
+	return nil
+}
+
+// newNonce returns a random identifier of a writer or a commit.
+func newNonce() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// readHead returns the head of the tree with treeID.
+func (t *treeStorage) readHead(ctx context.Context, treeID int64) (head, error) {
+	row, err := t.tbl.ReadRow(ctx, treeKey(treeID), bt.RowFilter(column(metaFamily, colHead)))
+	if err != nil {
+		return head{}, err
+	}
+	b, ok := cellValue(row, metaFamily, colHead)
+	if !ok {
+		return head{}, status.Errorf(codes.NotFound, "tree %v not found", treeID)
+	}
+	return parseHead(b)
+}
+
+// headIs returns a filter passing the head of a tree if it is h.
+func headIs(h head) bt.Filter {
+	return bt.ChainFilters(column(metaFamily, colHead), bt.ValueFilter(regexp.QuoteMeta(h.String())))
+}
+
+// swapHead sets the head of the tree with treeID to next, and applies extra
+// to its row, if the head is old. It reports whether it was.
+func (t *treeStorage) swapHead(ctx context.Context, treeID int64, old, next head, extra func(*bt.Mutation)) (bool, error) {
+	m := bt.NewMutation()
+	m.Set(metaFamily, colHead, 0, []byte(next.String()))
+	if extra != nil {
+		extra(m)
+	}
+	return t.applyIf(ctx, treeKey(treeID), headIs(old), m)
+}
+
+// applyIf applies m to the row with key if cond passes any of its cells, and
+// reports whether it did.
+func (t *treeStorage) applyIf(ctx context.Context, key string, cond bt.Filter, m *bt.Mutation) (bool, error) {
+	var matched bool
+	if err := t.tbl.Apply(ctx, key, bt.NewCondMutation(cond, m, nil), bt.GetCondMutationResult(&matched)); err != nil {
+		return false, err
+	}
+	return matched, nil
+}
+
+func deleteIntent(m *bt.Mutation) {
+	m.DeleteCellsInColumn(metaFamily, colIntent)
+}
+
+// acquire takes the commit lease of the tree with treeID, waiting for the
+// current holder to release it or for its lease to expire, and returns it.
+func (t *treeStorage) acquire(ctx context.Context, treeID int64) (head, error) {
+	for {
+		cur, err := t.readHead(ctx, treeID)
+		if err != nil {
+			return head{}, err
+		}
+		now := time.Now()
+		if cur.holder != "" && now.Before(cur.expiry) {
+			wait := cur.expiry.Sub(now)
+			if wait > leaseRetryInterval {
+				wait = leaseRetryInterval
+			}
+			select {
+			case <-ctx.Done():
+				return head{}, ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+		lease := head{rev: cur.rev, holder: newNonce(), expiry: now.Add(t.opts.CommitLease)}
+		ok, err := t.swapHead(ctx, treeID, cur, lease, nil)
+		if err != nil {
+			return head{}, err
+		}
+		if ok {
+			return t.recover(ctx, treeID, lease)
+		}
+	}
+}
+
+// release releases the lease of w, leaving the revision of the tree as it
+// was.
+func (t *treeStorage) release(ctx context.Context, w *writer) error {
+	ok, err := t.swapHead(ctx, w.treeID, w.lease, head{rev: w.lease.rev}, nil)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// recover finishes the commit to the tree with treeID left unfinished by a
+// writer which failed, or whose lease expired, while holding lease. It returns
+// the lease, with the revision of the tree after recovery.
+func (t *treeStorage) recover(ctx context.Context, treeID int64, lease head) (head, error) {
+	row, err := t.tbl.ReadRow(ctx, treeKey(treeID), bt.RowFilter(column(metaFamily, colIntent)))
+	if err != nil {
+		return head{}, err
+	}
+	b, ok := cellValue(row, metaFamily, colIntent)
+	if !ok {
+		return lease, nil
+	}
+	var in intent
+	if err := json.Unmarshal(b, &in); err != nil {
+		return head{}, fmt.Errorf("tree %d: malformed intent: %v", treeID, err)
+	}
+	if in.Revision <= lease.rev {
+		// The revision was committed, and only the intent was left behind.
+		return lease, t.clearIntent(ctx, treeID, lease)
+	}
+
+	committed := false
+	if in.Commit != "" {
+		if committed, err = t.decide(ctx, in.Commit, false); err != nil {
+			return head{}, err
+		}
+	}
+	if committed {
+		glog.Infof("Tree %d: rolling forward revision %d of commit %s", treeID, in.Revision, in.Commit)
+		next := lease
+		next.rev = in.Revision
+		ok, err := t.swapHead(ctx, treeID, lease, next, deleteIntent)
+		if err != nil {
+			return head{}, err
+		}
+		if !ok {
+			return head{}, ErrLeaseLost
+		}
+		return next, nil
+	}
+
+	glog.Infof("Tree %d: rolling back revision %d", treeID, in.Revision)
+	if err := t.rollBack(ctx, &in); err != nil {
+		return head{}, err
+	}
+	return lease, t.clearIntent(ctx, treeID, lease)
+}
+
+// decide records the outcome of the commit with key, unless it has already
+// been recorded, and reports whether the commit is committed.
+func (t *treeStorage) decide(ctx context.Context, key string, commit bool) (bool, error) {
+	state := stateAborted
+	if commit {
+		state = stateCommitted
+	}
+	m := bt.NewMutation()
+	m.Set(metaFamily, colState, 0, []byte(state))
+	var exists bool
+	if err := t.tbl.Apply(ctx, "commits/"+key, bt.NewCondMutation(column(metaFamily, colState), nil, m), bt.GetCondMutationResult(&exists)); err != nil {
+		return false, err
+	}
+	if !exists {
+		return commit, nil
+	}
+	row, err := t.tbl.ReadRow(ctx, "commits/"+key, bt.RowFilter(column(metaFamily, colState)))
+	if err != nil {
+		return false, err
+	}
+	b, _ := cellValue(row, metaFamily, colState)
+	return string(b) == stateCommitted, nil
+}
+
+// rollBack deletes the cells written at the revision of in.
+func (t *treeStorage) rollBack(ctx context.Context, in *intent) error {
+	var b batch
+	for _, c := range in.Cells {
+		m := b.row(c.Row)
+		if c.Family == verFamily {
+			m.DeleteTimestampRange(c.Family, c.Column, revTS(in.Revision), revTS(in.Revision+1))
+		} else {
+			m.DeleteCellsInColumn(c.Family, c.Column)
+		}
+	}
+	return b.apply(ctx, t.tbl)
+}
+
+// writeIntent writes in to the row of the tree of w, if w still holds its
+// lease.
+func (t *treeStorage) writeIntent(ctx context.Context, w *writer, in *intent) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	m := bt.NewMutation()
+	m.Set(metaFamily, colIntent, 0, b)
+	ok, err := t.applyIf(ctx, treeKey(w.treeID), headIs(w.lease), m)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// clearIntent deletes the intent of the tree with treeID, if lease is still
+// held.
+func (t *treeStorage) clearIntent(ctx context.Context, treeID int64, lease head) error {
+	m := bt.NewMutation()
+	deleteIntent(m)
+	ok, err := t.applyIf(ctx, treeKey(treeID), headIs(lease), m)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// writeCells writes the pending cells of w, if its lease has not expired.
+func (t *treeStorage) writeCells(ctx context.Context, w *writer) error {
+	if !time.Now().Before(w.lease.expiry) {
+		return ErrLeaseLost
+	}
+	return w.pending.apply(ctx, t.tbl)
+}
+
+// abort undoes what a failed commit of w wrote, and releases its lease. It is
+// best effort: whatever it leaves behind is rolled back by the next writer.
+func (t *treeStorage) abort(ctx context.Context, w *writer, in *intent) {
+	if in != nil {
+		if err := t.rollBack(ctx, in); err != nil {
+			glog.Warningf("Tree %d: failed to roll back revision %d: %v", w.treeID, w.rev, err)
+			return
+		}
+		if err := t.clearIntent(ctx, w.treeID, w.lease); err != nil {
+			glog.Warningf("Tree %d: failed to delete intent: %v", w.treeID, err)
+			return
+		}
+	}
+	if err := t.release(ctx, w); err != nil {
+		glog.Warningf("Tree %d: failed to release lease: %v", w.treeID, err)
+	}
+}
+
+// finish applies the writes of w which follow its commit.
+func (t *treeStorage) finish(ctx context.Context, w *writer) error {
+	if err := w.direct.apply(ctx, t.tbl); err != nil {
+		return err
+	}
+	if err := w.post.apply(ctx, t.tbl); err != nil {
+		// Whatever post deletes is no longer read, so it can be left behind.
+		glog.Warningf("Tree %d: failed to clean up after revision %d: %v", w.treeID, w.rev, err)
+	}
+	return nil
+}
+
+// commit commits the writes of w. The new revision becomes visible when the
+// head of the tree is set to it, which also releases the lease.
+func (t *treeStorage) commit(ctx context.Context, w *writer) error {
+	if !w.root {
+		if !w.pending.empty() {
+			t.abort(ctx, w, nil)
+			return status.Errorf(codes.FailedPrecondition, "tree %d: writes at revision %d without a root", w.treeID, w.rev)
+		}
+		if err := t.release(ctx, w); err != nil {
+			return err
+		}
+		return t.finish(ctx, w)
+	}
+
+	in := &intent{Revision: w.rev, Cells: w.cells}
+	if err := w.keep(in); err != nil {
+		t.abort(ctx, w, nil)
+		return err
+	}
+	if err := t.writeIntent(ctx, w, in); err != nil {
+		t.abort(ctx, w, nil)
+		return err
+	}
+	if err := t.writeCells(ctx, w); err != nil {
+		t.abort(ctx, w, in)
+		return err
+	}
+	ok, err := t.swapHead(ctx, w.treeID, w.lease, head{rev: w.rev}, deleteIntent)
+	if err != nil {
+		// The outcome is unknown. If the head was not set, the next writer
+		// rolls the revision back.
+		return err
+	}
+	if !ok {
+		return ErrLeaseLost
+	}
+	return t.finish(ctx, w)
+}
+
+// commitMulti atomically commits the writes of ws, which must hold the leases
+// of distinct trees.
+func (t *treeStorage) commitMulti(ctx context.Context, ws []*writer) error {
+	key := newNonce() + newNonce()
+	intents := make([]*intent, len(ws))
+	abortAll := func() {
+		for i, w := range ws {
+			t.abort(ctx, w, intents[i])
+		}
+	}
+
+	for i, w := range ws {
+		if !w.root {
+			if !w.pending.empty() {
+				abortAll()
+				return status.Errorf(codes.FailedPrecondition, "tree %d: writes at revision %d without a root", w.treeID, w.rev)
+			}
+			continue
+		}
+		in := &intent{Revision: w.rev, Commit: key, Cells: w.cells}
+		if err := w.keep(in); err != nil {
+			abortAll()
+			return err
+		}
+		if err := t.writeIntent(ctx, w, in); err != nil {
+			abortAll()
+			return err
+		}
+		intents[i] = in
+	}
+	for _, w := range ws {
+		if err := t.writeCells(ctx, w); err != nil {
+			abortAll()
+			return err
+		}
+	}
+	for _, w := range ws {
+		if !time.Now().Before(w.lease.expiry) {
+			abortAll()
+			return ErrLeaseLost
+		}
+	}
+	committed, err := t.decide(ctx, key, true)
+	if err != nil {
+		// The outcome is unknown, and is settled by the next writers.
+		return err
+	}
+	if !committed {
+		abortAll()
+		return ErrLeaseLost
+	}
+
+	flipped := true
+	for _, w := range ws {
+		next := head{rev: w.lease.rev}
+		if w.root {
+			next.rev = w.rev
+		}
+		if ok, err := t.swapHead(ctx, w.treeID, w.lease, next, deleteIntent); err != nil || !ok {
+			// The next writer rolls the revision forward.
+			glog.Warningf("Tree %d: failed to set head to revision %d: %v", w.treeID, next.rev, err)
+			flipped = false
+		}
+	}
+	if flipped {
+		if err := t.tbl.Apply(ctx, "commits/"+key, deleteRow()); err != nil {
+			glog.Warningf("Failed to delete commit record %s: %v", key, err)
+		}
+	}
+	for _, w := range ws {
+		if err := t.finish(ctx, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteRow() *bt.Mutation {
+	m := bt.NewMutation()
+	m.DeleteRow()
+	return m
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bigtable provides a Cloud Bigtable backed implementation of the log,
+// map and admin storage interfaces.
+//
+// All trees are kept in a single table, created by CreateTable, with two
+// column families: "m" holds unversioned cells, written at timestamp 0, and
+// "v" holds versioned cells, which are written at the timestamp
+// (revision+1)*1000 microseconds of the tree revision they belong to.
+// Bigtable keeps every version of a cell, so the table must not have a
+// garbage collection policy.
+//
+// Tree IDs, revisions, leaf indices and timestamps are written in row keys as
+// 16 hex digits, so that keys sort in numeric order, and hashes, map indices
+// and tokens as lower case hex. The rows of a tree are:
+//
+//	trees/<tree>                    m:tree    the trillian.Tree
+//	                                m:head    the committed revision, and the
+//	                                          commit lease of a writer
+//	                                m:intent  the cells of an unfinished commit
+//	<tree>/r/<revision>             m:root    the signed root of the revision
+//	                                m:marker  the recovery marker of the root
+//	                                m:sig/<h> the witness signature by the key
+//	                                          whose DER has the SHA-256 hash h
+//	<tree>/rh/<root hash>           v:rev     a version at each revision whose
+//	                                          root has the hash
+//	<tree>/s/<subtree prefix>       v:st      the versions of a subtree
+//
+// Logs also have the rows:
+//
+//	<tree>/l/<identity hash>        m:leaf    the data of a leaf
+//	                                m:q       the key of its queue entry
+//	<tree>/q/<queue time>/<identity hash>/<nonce>
+//	                                m:leaf    a queued leaf
+//	                                v:seq     the index it was sequenced at
+//	<tree>/n/<leaf index>           v:leaf    a sequenced leaf
+//	<tree>/h/<merkle hash>/<leaf index>
+//	                                v:n       an index entry for the leaf
+//
+// and maps the rows:
+//
+//	<tree>/r/<revision>             m:intent  a copy of the intent of the
+//	                                          revision
+//	<tree>/v/<map index>            v:leaf    the versions of a map leaf
+//	<tree>/x/<leaf hash>/<map index>
+//	                                v:x       an index entry for the leaf, only
+//	                                          kept if the map's
+//	                                          MapStorageSettings ask for it
+//	<tree>/k/<token>                v:rev     the revision written with an
+//	                                          idempotency token
+//
+// Bigtable only makes writes to a single row atomic, so a read-write
+// transaction buffers its writes and commits them in steps. It first takes a
+// lease on the tree, by replacing the head with one naming the writer, which
+// excludes other writers until the lease expires. At commit it records the
+// cells it is about to write in the intent, writes them, and then sets the
+// head to the new revision and deletes the intent in one mutation. Reads never
+// look past the committed head, so the new cells only become visible then. A
+// writer which finds an intent when it takes the lease, left by a writer which
+// failed or whose lease expired, deletes the cells it names. Leases must
+// therefore be longer than the time taken to commit a transaction.
+//
+// Map commits also keep a copy of their intent in the row of their root. A
+// read at the head revision of a map checks that it found the versions
+// written at the head of every row the kept intent lists, and returns
+// NotFound if it didn't, e.g. because the cluster it read from hasn't
+// replicated all of the revision yet, or because the table was restored from
+// a backup taken while the revision was written. Map servers can then fall
+// back to the previous revision.
+//
+// A transaction over several maps writes a commit record, commits/<nonce>, once
+// all of its cells are written, and only then sets the heads. The record
+// decides the outcome: a writer which finds an intent referring to a record
+// rolls the revision forward if the record says it was committed, and aborts
+// it otherwise. Readers may briefly see some of the maps at the new revision
+// and others at the old one. Records of aborted commits are never deleted, so
+// that a writer which outlived its lease cannot commit them later.
+package bigtable
@@ -0,0 +1,704 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	bt "cloud.google.com/go/bigtable"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/types"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// queueClaimTimeout is how long a queue entry may wait for its leaf to
+	// be written before DequeueLeaves deletes it.
+	queueClaimTimeout = time.Minute
+
+	// maxParallelWrites is the number of conditional writes QueueLeaves has
+	// in flight at once.
+	maxParallelWrites = 64
+)
+
+var logTypes = []trillian.TreeType{trillian.TreeType_LOG, trillian.TreeType_PREORDERED_LOG}
+
+// logStorage provides a Cloud Bigtable backed storage.LogStorage
+// implementation.
+type logStorage struct {
+	ts *treeStorage
+}
+
+// NewLogStorage creates a storage.LogStorage instance backed by tbl, which
+// must have been created by CreateTable.
+func NewLogStorage(tbl *bt.Table, opts TreeStorageOptions) storage.LogStorage {
+	return &logStorage{ts: newTreeStorage(tbl, opts)}
+}
+
+func (ls *logStorage) CheckDatabaseAccessible(ctx context.Context) error {
+	return checkDatabaseAccessible(ctx, ls.ts.tbl)
+}
+
+func (ls *logStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
+	return &readOnlyLogTX{ls: ls}, nil
+}
+
+// readOnlyLogTX implements storage.ReadOnlyLogTX. Bigtable has no
+// transactions across rows, so it only reads the latest data.
+type readOnlyLogTX struct {
+	ls *logStorage
+}
+
+func (t *readOnlyLogTX) Commit(context.Context) error {
+	return nil
+}
+
+func (t *readOnlyLogTX) Rollback() error {
+	return nil
+}
+
+func (t *readOnlyLogTX) Close() error {
+	return nil
+}
+
+func (t *readOnlyLogTX) GetActiveLogIDs(ctx context.Context) ([]int64, error) {
+	trees, err := readTrees(ctx, t.ls.ts.tbl)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int64{}
+	for _, tree := range trees {
+		// Include logs that are DRAINING in the active list as we're still
+		// integrating leaves into them.
+		if hasType(tree, logTypes) && !tree.Deleted &&
+			(tree.TreeState == trillian.TreeState_ACTIVE || tree.TreeState == trillian.TreeState_DRAINING) {
+			ids = append(ids, tree.TreeId)
+		}
+	}
+	return ids, nil
+}
+
+func newLogCache(tree *trillian.Tree) (*cache.SubtreeCache, error) {
+	hasher, err := hashers.NewLogHasher(tree.HashStrategy)
+	if err != nil {
+		return nil, err
+	}
+	return cache.NewLogSubtreeCache(defLogStrata, hasher), nil
+}
+
+// begin returns a transaction on the log tree. If the log has no root yet, it
+// returns the transaction along with storage.ErrTreeNeedsInit.
+func (ls *logStorage) begin(ctx context.Context, tree *trillian.Tree, readonly bool) (*logTX, error) {
+	ttx, err := ls.ts.begin(ctx, tree, readonly, newLogCache, logTypes...)
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := hashers.NewLogHasher(ttx.tree.HashStrategy)
+	if err != nil {
+		ttx.Close()
+		return nil, err
+	}
+	tx := &logTX{
+		treeTX:   ttx,
+		hashSize: hasher.Size(),
+		dequeued: make(map[string]string),
+	}
+	if ttx.head < 0 {
+		return tx, storage.ErrTreeNeedsInit
+	}
+	if tx.slr, err = tx.readRoot(ctx, ttx.head); err == nil {
+		err = tx.root.UnmarshalBinary(tx.slr.LogRoot)
+	}
+	if err != nil {
+		ttx.Close()
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (ls *logStorage) ReadWriteTransaction(ctx context.Context, tree *trillian.Tree, f storage.LogTXFunc) error {
+	tx, err := ls.begin(ctx, tree, false /* readonly */)
+	if err != nil && err != storage.ErrTreeNeedsInit {
+		return err
+	}
+	defer tx.Close()
+	if err := f(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (ls *logStorage) SnapshotForTree(ctx context.Context, tree *trillian.Tree) (storage.ReadOnlyLogTreeTX, error) {
+	tx, err := ls.begin(ctx, tree, true /* readonly */)
+	if err != nil && err != storage.ErrTreeNeedsInit {
+		return nil, err
+	}
+	return tx, err
+}
+
+func (ls *logStorage) AddSequencedLeaves(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, timestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
+	return nil, ErrNotImplemented
+}
+
+// QueueLeaves queues leaves without taking the commit lease of the log, so
+// that queueing does not contend with sequencing. Each leaf first gets a queue
+// entry, and is then written to the row of its identity hash unless a leaf
+// with the same hash was written before. The entries of leaves which turn out
+// to be duplicates are deleted, and so are any left behind by a failure, once
+// DequeueLeaves comes across them.
+func (ls *logStorage) QueueLeaves(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, queueTimestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
+	stored, h, err := ls.ts.readTree(ctx, tree.TreeId)
+	if err != nil {
+		return nil, err
+	}
+	if !hasType(stored, logTypes) {
+		return nil, fmt.Errorf("QueueLeaves(tree.TreeType: %v), want one of %v", stored.TreeType, logTypes)
+	}
+	if h.rev < 0 {
+		return nil, storage.ErrTreeNeedsInit
+	}
+	hasher, err := hashers.NewLogHasher(stored.HashStrategy)
+	if err != nil {
+		return nil, err
+	}
+	qTimestamp, err := ptypes.TimestampProto(queueTimestamp)
+	if err != nil {
+		return nil, fmt.Errorf("got invalid queue timestamp: %v", err)
+	}
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if len(leaf.LeafIdentityHash) != hasher.Size() {
+			return nil, fmt.Errorf("queued leaf must have a leaf ID hash of length %d", hasher.Size())
+		}
+		leaf.QueueTimestamp = qTimestamp
+	}
+
+	// first maps the identity hashes of the batch to the first leaf with each.
+	first := make(map[string]int)
+	qKeys := make([]string, len(leaves))
+	var entries batch
+	for i, leaf := range leaves {
+		id := hex.EncodeToString(leaf.LeafIdentityHash)
+		if _, ok := first[id]; ok {
+			continue
+		}
+		first[id] = i
+		b, err := proto.Marshal(&trillian.LogLeaf{
+			LeafIdentityHash: leaf.LeafIdentityHash,
+			MerkleLeafHash:   leaf.MerkleLeafHash,
+			QueueTimestamp:   leaf.QueueTimestamp,
+		})
+		if err != nil {
+			return nil, err
+		}
+		qKeys[i] = rowKey(stored.TreeId, "q", numKey(queueTimestamp.UnixNano()), id, newNonce())
+		entries.row(qKeys[i]).Set(metaFamily, colLeaf, 0, b)
+	}
+	if err := entries.apply(ctx, ls.ts.tbl); err != nil {
+		return nil, err
+	}
+
+	dup := make([]bool, len(leaves))
+	g, gctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, maxParallelWrites)
+	for id, i := range first {
+		id, i := id, i
+		b, err := proto.Marshal(leaves[i])
+		if err != nil {
+			return nil, err
+		}
+		sem <- struct{}{}
+		g.Go(func() error {
+			defer func() { <-sem }()
+			m := bt.NewMutation()
+			m.Set(metaFamily, colLeaf, 0, b)
+			m.Set(metaFamily, colQueue, 0, []byte(qKeys[i]))
+			cond := bt.NewCondMutation(column(metaFamily, colLeaf), nil, m)
+			return ls.ts.tbl.Apply(gctx, rowKey(stored.TreeId, "l", id), cond, bt.GetCondMutationResult(&dup[i]))
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	var discard batch
+	var dupKeys bt.RowList
+	for id, i := range first {
+		if dup[i] {
+			discard.row(qKeys[i]).DeleteRow()
+			dupKeys = append(dupKeys, rowKey(stored.TreeId, "l", id))
+		}
+	}
+	existing := make(map[string]*trillian.LogLeaf)
+	if len(dupKeys) > 0 {
+		if err := discard.apply(ctx, ls.ts.tbl); err != nil {
+			glog.Warningf("Failed to delete queue entries of duplicate leaves: %v", err)
+		}
+		var readErr error
+		err := ls.ts.tbl.ReadRows(ctx, dupKeys, func(r bt.Row) bool {
+			b, _ := cellValue(r, metaFamily, colLeaf)
+			leaf := &trillian.LogLeaf{}
+			if readErr = proto.Unmarshal(b, leaf); readErr != nil {
+				return false
+			}
+			existing[hex.EncodeToString(leaf.LeafIdentityHash)] = leaf
+			return true
+		}, bt.RowFilter(column(metaFamily, colLeaf)))
+		if err == nil {
+			err = readErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve existing leaves: %v", err)
+		}
+	}
+
+	ret := make([]*trillian.QueuedLogLeaf, len(leaves))
+	for i, leaf := range leaves {
+		id := hex.EncodeToString(leaf.LeafIdentityHash)
+		j := first[id]
+		if j == i && !dup[i] {
+			ret[i] = &trillian.QueuedLogLeaf{Leaf: leaf}
+			continue
+		}
+		e := existing[id]
+		if !dup[j] {
+			e = leaves[j]
+		}
+		if e == nil {
+			return nil, fmt.Errorf("failed to find existing leaf for hash %x", leaf.LeafIdentityHash)
+		}
+		ret[i] = &trillian.QueuedLogLeaf{
+			Leaf:   e,
+			Status: status.Newf(codes.AlreadyExists, "leaf already exists: %v", e.LeafIdentityHash).Proto(),
+		}
+	}
+	return ret, nil
+}
+
+// logTX implements storage.LogTreeTX.
+type logTX struct {
+	*treeTX
+	hashSize int
+	slr      *trillian.SignedLogRoot
+	root     types.LogRootV1
+	// dequeued maps the identity hashes of the leaves returned by
+	// DequeueLeaves to the keys of their queue entries.
+	dequeued map[string]string
+}
+
+// readRoot returns the signed root of the log at revision rev.
+func (t *logTX) readRoot(ctx context.Context, rev int64) (*trillian.SignedLogRoot, error) {
+	if rev >= 0 && rev <= t.head {
+		row, err := t.ts.tbl.ReadRow(ctx, rootKey(t.treeID, rev), bt.RowFilter(column(metaFamily, colRoot)))
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := cellValue(row, metaFamily, colRoot); ok {
+			slr := &trillian.SignedLogRoot{}
+			if err := proto.Unmarshal(b, slr); err != nil {
+				return nil, fmt.Errorf("could not unmarshal log root %d: %v", rev, err)
+			}
+			slr.KeyHint = types.SerializeKeyHint(t.treeID)
+			return slr, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "log root %d not found", rev)
+}
+
+func (t *logTX) LatestSignedLogRoot(ctx context.Context) (*trillian.SignedLogRoot, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.slr == nil {
+		return nil, storage.ErrTreeNeedsInit
+	}
+	return t.slr, nil
+}
+
+func (t *logTX) GetSignedLogRoot(ctx context.Context, revision int64) (*trillian.SignedLogRoot, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.readRoot(ctx, revision)
+}
+
+func (t *logTX) GetSignedLogRootByHash(ctx context.Context, rootHash []byte) (*trillian.SignedLogRoot, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.head >= 0 {
+		key := rowKey(t.treeID, "rh", hex.EncodeToString(rootHash))
+		filter := bt.ChainFilters(column(verFamily, colRev), bt.TimestampRangeFilterMicros(0, revTS(t.head+1)))
+		row, err := t.ts.tbl.ReadRow(ctx, key, bt.RowFilter(filter))
+		if err != nil {
+			return nil, err
+		}
+		// Versions are ordered newest first.
+		if items := row[verFamily]; len(items) > 0 {
+			return t.readRoot(ctx, tsRev(items[len(items)-1].Timestamp))
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "log root with hash %x not found", rootHash)
+}
+
+func (t *logTX) GetLogRootSignatures(ctx context.Context, revision int64) ([]*trillian.LogRootSignature, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if revision > t.head {
+		return nil, nil
+	}
+	row, err := t.ts.tbl.ReadRow(ctx, rootKey(t.treeID, revision), bt.RowFilter(column(metaFamily, colSig+".*")))
+	if err != nil {
+		return nil, err
+	}
+	// Columns are ordered by name, and so by the hash of the key.
+	var sigs []*trillian.LogRootSignature
+	for _, item := range row[metaFamily] {
+		sig := &trillian.LogRootSignature{}
+		if err := proto.Unmarshal(item.Value, sig); err != nil {
+			return nil, fmt.Errorf("could not unmarshal log root signature: %v", err)
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+func (t *logTX) StoreLogRootSignature(ctx context.Context, revision int64, sig *trillian.LogRootSignature) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w == nil {
+		return ErrWrongTXType
+	}
+	keyHash := sha256.Sum256(sig.GetPublicKey().GetDer())
+	b, err := proto.Marshal(sig)
+	if err != nil {
+		return err
+	}
+	t.w.storeSignature(t.treeID, revision, hex.EncodeToString(keyHash[:]), b)
+	return nil
+}
+
+// storeSignature buffers the write of a signature over the root at revision.
+// A signature over a committed root is written in place.
+func (w *writer) storeSignature(treeID, revision int64, keyHash string, sig []byte) {
+	key := rootKey(treeID, revision)
+	if revision < w.rev {
+		w.direct.row(key).Set(metaFamily, colSig+keyHash, 0, sig)
+		return
+	}
+	w.set(key, metaFamily, colSig+keyHash, sig)
+}
+
+func (t *logTX) StoreSignedLogRoot(ctx context.Context, root *trillian.SignedLogRoot) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w == nil {
+		return ErrWrongTXType
+	}
+
+	var logRoot types.LogRootV1
+	if err := logRoot.UnmarshalBinary(root.LogRoot); err != nil {
+		glog.Warningf("Failed to parse log root: %x %v", root.LogRoot, err)
+		return err
+	}
+	if got, want := int64(logRoot.Revision), t.w.rev; got != want {
+		return fmt.Errorf("root revision %d, want %d", got, want)
+	}
+	b, err := proto.Marshal(root)
+	if err != nil {
+		return err
+	}
+	t.w.set(rootKey(t.treeID, t.w.rev), metaFamily, colRoot, b)
+	t.w.set(rowKey(t.treeID, "rh", hex.EncodeToString(logRoot.RootHash)), verFamily, colRev, []byte(numKey(t.w.rev)))
+	t.storeRecoveryMarker()
+	t.w.root = true
+	return nil
+}
+
+func (t *logTX) GetSequencedLeafCount(ctx context.Context) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return int64(t.root.TreeSize), nil
+}
+
+func (t *logTX) QueueLeaves(ctx context.Context, leaves []*trillian.LogLeaf, queueTimestamp time.Time) ([]*trillian.LogLeaf, error) {
+	return nil, ErrNotImplemented
+}
+
+func (t *logTX) AddSequencedLeaves(ctx context.Context, leaves []*trillian.LogLeaf, timestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
+	return nil, ErrNotImplemented
+}
+
+// DequeueLeaves returns the leaves of the oldest queue entries which own the
+// row of their leaf. Entries left behind by leaves which were sequenced or
+// turned out to be duplicates are deleted once the transaction commits.
+func (t *logTX) DequeueLeaves(ctx context.Context, limit int, cutoffTime time.Time) ([]*trillian.LogLeaf, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w == nil {
+		return nil, ErrWrongTXType
+	}
+
+	if t.tree.TreeType == trillian.TreeType_PREORDERED_LOG {
+		return t.getLeavesByRange(ctx, int64(t.root.TreeSize), int64(limit))
+	}
+	leaves := make([]*trillian.LogLeaf, 0, limit)
+	if limit <= 0 {
+		return leaves, nil
+	}
+
+	type entry struct {
+		key  string
+		leaf *trillian.LogLeaf
+	}
+	var entries []entry
+	var readErr error
+	prefix := rowPrefix(t.treeID, "q")
+	rows := bt.NewRange(prefix, prefix+numKey(cutoffTime.UnixNano()+1))
+	err := t.ts.tbl.ReadRows(ctx, rows, func(r bt.Row) bool {
+		if _, ok := cellValue(r, verFamily, colSeq); ok {
+			t.w.post.row(r.Key()).DeleteRow()
+			return true
+		}
+		b, _ := cellValue(r, metaFamily, colLeaf)
+		leaf := &trillian.LogLeaf{}
+		if readErr = proto.Unmarshal(b, leaf); readErr != nil {
+			return false
+		}
+		entries = append(entries, entry{key: r.Key(), leaf: leaf})
+		return len(entries) < limit
+	}, bt.RowFilter(atRevision(t.head)))
+	if err == nil {
+		err = readErr
+	}
+	if err != nil || len(entries) == 0 {
+		return leaves, err
+	}
+
+	keys := make(bt.RowList, 0, len(entries))
+	for _, e := range entries {
+		keys = append(keys, rowKey(t.treeID, "l", hex.EncodeToString(e.leaf.LeafIdentityHash)))
+	}
+	owners := make(map[string]string)
+	data := make(map[string]*trillian.LogLeaf)
+	err = t.ts.tbl.ReadRows(ctx, keys, func(r bt.Row) bool {
+		q, _ := cellValue(r, metaFamily, colQueue)
+		b, _ := cellValue(r, metaFamily, colLeaf)
+		leaf := &trillian.LogLeaf{}
+		if readErr = proto.Unmarshal(b, leaf); readErr != nil {
+			return false
+		}
+		owners[r.Key()], data[r.Key()] = string(q), leaf
+		return true
+	}, bt.RowFilter(bt.FamilyFilter(metaFamily)))
+	if err == nil {
+		err = readErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, e := range entries {
+		owner, ok := owners[keys[i]]
+		if ok && owner == e.key {
+			leaf := data[keys[i]]
+			if len(leaf.LeafIdentityHash) != t.hashSize {
+				return nil, errors.New("dequeued a leaf with incorrect hash size")
+			}
+			leaves = append(leaves, leaf)
+			t.dequeued[hex.EncodeToString(leaf.LeafIdentityHash)] = e.key
+			continue
+		}
+		queued, err := ptypes.Timestamp(e.leaf.QueueTimestamp)
+		if err != nil {
+			return nil, fmt.Errorf("got invalid queue timestamp: %v", err)
+		}
+		if ok || time.Since(queued) > queueClaimTimeout {
+			// The leaf is a duplicate, or whoever queued it failed.
+			t.w.post.row(e.key).DeleteRow()
+		}
+	}
+	return leaves, nil
+}
+
+// UpdateSequencedLeaves writes the leaves, which must have been returned by
+// DequeueLeaves, at their indices, and marks their queue entries as sequenced.
+func (t *logTX) UpdateSequencedLeaves(ctx context.Context, leaves []*trillian.LogLeaf) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w == nil {
+		return ErrWrongTXType
+	}
+
+	for _, leaf := range leaves {
+		if len(leaf.LeafIdentityHash) != t.hashSize {
+			return errors.New("sequenced leaf has incorrect hash size")
+		}
+		qKey, ok := t.dequeued[hex.EncodeToString(leaf.LeafIdentityHash)]
+		if !ok {
+			return fmt.Errorf("leaf %x was not dequeued", leaf.LeafIdentityHash)
+		}
+		b, err := proto.Marshal(leaf)
+		if err != nil {
+			return err
+		}
+		index := numKey(leaf.LeafIndex)
+		t.w.set(rowKey(t.treeID, "n", index), verFamily, colLeaf, b)
+		t.w.set(rowKey(t.treeID, "h", hex.EncodeToString(leaf.MerkleLeafHash), index), verFamily, colIndex, []byte(index))
+		t.w.set(qKey, verFamily, colSeq, []byte(index))
+		t.w.post.row(qKey).DeleteRow()
+	}
+	return nil
+}
+
+// readLeaves returns the sequenced leaves in rows, in the order of their
+// indices.
+func (t *logTX) readLeaves(ctx context.Context, rows bt.RowSet, opts ...bt.ReadOption) ([]*trillian.LogLeaf, error) {
+	var ret []*trillian.LogLeaf
+	var readErr error
+	opts = append(opts, bt.RowFilter(bt.ChainFilters(column(verFamily, colLeaf), atRevision(t.head))))
+	err := t.ts.tbl.ReadRows(ctx, rows, func(r bt.Row) bool {
+		b, _ := cellValue(r, verFamily, colLeaf)
+		leaf := &trillian.LogLeaf{}
+		if readErr = proto.Unmarshal(b, leaf); readErr != nil {
+			return false
+		}
+		ret = append(ret, leaf)
+		return true
+	}, opts...)
+	if err == nil {
+		err = readErr
+	}
+	return ret, err
+}
+
+func (t *logTX) GetLeavesByIndex(ctx context.Context, leaves []int64) ([]*trillian.LogLeaf, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tree.TreeType == trillian.TreeType_LOG {
+		treeSize := int64(t.root.TreeSize)
+		for _, leaf := range leaves {
+			if leaf < 0 {
+				return nil, status.Errorf(codes.InvalidArgument, "index %d is < 0", leaf)
+			}
+			if leaf >= treeSize {
+				return nil, status.Errorf(codes.OutOfRange, "invalid leaf index %d, want < TreeSize(%d)", leaf, treeSize)
+			}
+		}
+	}
+	ret := make([]*trillian.LogLeaf, 0, len(leaves))
+	if len(leaves) > 0 {
+		keys := make(bt.RowList, 0, len(leaves))
+		for _, leaf := range leaves {
+			keys = append(keys, rowKey(t.treeID, "n", numKey(leaf)))
+		}
+		got, err := t.readLeaves(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, got...)
+	}
+	if got, want := len(ret), len(leaves); got != want {
+		return nil, status.Errorf(codes.Internal, "len(ret): %d, want %d", got, want)
+	}
+	return ret, nil
+}
+
+func (t *logTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]*trillian.LogLeaf, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.getLeavesByRange(ctx, start, count)
+}
+
+func (t *logTX) getLeavesByRange(ctx context.Context, start, count int64) ([]*trillian.LogLeaf, error) {
+	if count <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid count %d, want > 0", count)
+	}
+	if start < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid start %d, want >= 0", start)
+	}
+
+	if t.tree.TreeType == trillian.TreeType_LOG {
+		treeSize := int64(t.root.TreeSize)
+		if treeSize <= 0 {
+			return nil, status.Errorf(codes.OutOfRange, "empty tree")
+		} else if start >= treeSize {
+			return nil, status.Errorf(codes.OutOfRange, "invalid start %d, want < TreeSize(%d)", start, treeSize)
+		}
+		// Ensure no entries queried/returned beyond the tree.
+		if maxCount := treeSize - start; count > maxCount {
+			count = maxCount
+		}
+	}
+
+	rows := bt.NewRange(rowKey(t.treeID, "n", numKey(start)), rowKey(t.treeID, "n", numKey(start+count)))
+	leaves, err := t.readLeaves(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+	// Return the contiguous prefix of the range.
+	ret := make([]*trillian.LogLeaf, 0, len(leaves))
+	for i, leaf := range leaves {
+		if wantIndex := start + int64(i); leaf.LeafIndex != wantIndex {
+			if wantIndex < int64(t.root.TreeSize) {
+				return nil, fmt.Errorf("got unexpected index %d, want %d", leaf.LeafIndex, wantIndex)
+			}
+			break
+		}
+		ret = append(ret, leaf)
+	}
+	return ret, nil
+}
+
+func (t *logTX) GetLeavesByHash(ctx context.Context, leafHashes [][]byte, orderBySequence bool) ([]*trillian.LogLeaf, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var keys bt.RowList
+	for _, hash := range leafHashes {
+		prefix := rowPrefix(t.treeID, "h") + hex.EncodeToString(hash) + "/"
+		err := t.ts.tbl.ReadRows(ctx, bt.PrefixRange(prefix), func(r bt.Row) bool {
+			keys = append(keys, rowKey(t.treeID, "n", r.Key()[len(prefix):]))
+			return true
+		}, bt.RowFilter(bt.ChainFilters(column(verFamily, colIndex), atRevision(t.head), bt.StripValueFilter())))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	leaves, err := t.readLeaves(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	if orderBySequence {
+		sort.Slice(leaves, func(i, j int) bool { return leaves[i].LeafIndex < leaves[j].LeafIndex })
+	}
+	return leaves, nil
+}
@@ -0,0 +1,266 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/rfc6962"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc/codes"
+
+	bt "cloud.google.com/go/bigtable"
+	tcrypto "github.com/google/trillian/crypto"
+	storageto "github.com/google/trillian/storage/testonly"
+)
+
+var (
+	fixedSigner   = tcrypto.NewSigner(0, testonly.NewSignerWithFixedSig(nil, []byte("notempty")), crypto.SHA256)
+	fakeQueueTime = time.Date(2016, 11, 10, 15, 16, 27, 0, time.UTC)
+)
+
+// createLogForTests creates a log in tbl, and stores its empty root.
+func createLogForTests(ctx context.Context, t *testing.T, tbl *bt.Table, s storage.LogStorage) *trillian.Tree {
+	t.Helper()
+	tree, err := storage.CreateTree(ctx, NewAdminStorage(tbl), storageto.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	storeRoot(ctx, t, s, tree, &types.LogRootV1{RootHash: rfc6962.DefaultHasher.EmptyRoot()})
+	return tree
+}
+
+// storeRoot stores root as the next root of tree.
+func storeRoot(ctx context.Context, t *testing.T, s storage.LogStorage, tree *trillian.Tree, root *types.LogRootV1) {
+	t.Helper()
+	err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		rev, err := tx.WriteRevision(ctx)
+		if err != nil {
+			return err
+		}
+		root.Revision = uint64(rev)
+		slr, err := fixedSigner.SignLogRoot(root)
+		if err != nil {
+			return err
+		}
+		return tx.StoreSignedLogRoot(ctx, slr)
+	})
+	if err != nil {
+		t.Fatalf("Failed to store root %+v: %v", root, err)
+	}
+}
+
+func createTestLeaves(n int) []*trillian.LogLeaf {
+	var leaves []*trillian.LogLeaf
+	for i := 0; i < n; i++ {
+		value := []byte(fmt.Sprintf("leaf %d", i))
+		hash := sha256.Sum256(value)
+		leaves = append(leaves, &trillian.LogLeaf{
+			LeafValue:        value,
+			LeafIdentityHash: hash[:],
+			MerkleLeafHash:   rfc6962.DefaultHasher.HashLeaf(value),
+		})
+	}
+	return leaves
+}
+
+func TestQueueDuplicateLeaves(t *testing.T) {
+	ctx := context.Background()
+	tbl, done := newTestTable(ctx, t)
+	defer done()
+	s := NewLogStorage(tbl, TreeStorageOptions{})
+	tree := createLogForTests(ctx, t, tbl, s)
+
+	leaves := createTestLeaves(4)
+	if _, err := s.QueueLeaves(ctx, tree, leaves[:2], fakeQueueTime); err != nil {
+		t.Fatalf("QueueLeaves(): %v", err)
+	}
+	queued, err := s.QueueLeaves(ctx, tree, []*trillian.LogLeaf{leaves[2], leaves[0], leaves[2], leaves[3]}, fakeQueueTime)
+	if err != nil {
+		t.Fatalf("QueueLeaves(): %v", err)
+	}
+	for i, want := range []codes.Code{codes.OK, codes.AlreadyExists, codes.AlreadyExists, codes.OK} {
+		if got := codes.Code(queued[i].GetStatus().GetCode()); got != want {
+			t.Errorf("QueueLeaves()[%d].Status = %v, want %v", i, got, want)
+		}
+	}
+	if got, want := queued[1].Leaf.LeafValue, leaves[0].LeafValue; !bytes.Equal(got, want) {
+		t.Errorf("QueueLeaves()[1].Leaf.LeafValue = %s, want %s", got, want)
+	}
+}
+
+func TestQueueAndSequenceLeaves(t *testing.T) {
+	ctx := context.Background()
+	tbl, done := newTestTable(ctx, t)
+	defer done()
+	s := NewLogStorage(tbl, TreeStorageOptions{})
+	tree := createLogForTests(ctx, t, tbl, s)
+
+	leaves := createTestLeaves(5)
+	if _, err := s.QueueLeaves(ctx, tree, leaves, fakeQueueTime); err != nil {
+		t.Fatalf("QueueLeaves(): %v", err)
+	}
+
+	var rootHash []byte
+	err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		if got, err := tx.DequeueLeaves(ctx, 10, fakeQueueTime.Add(-time.Second)); err != nil || len(got) != 0 {
+			t.Errorf("DequeueLeaves() before the queue time = (%d leaves, %v), want (0, nil)", len(got), err)
+		}
+		dequeued, err := tx.DequeueLeaves(ctx, 10, fakeQueueTime)
+		if err != nil {
+			return err
+		}
+		if got, want := len(dequeued), len(leaves); got != want {
+			t.Fatalf("DequeueLeaves() returned %d leaves, want %d", got, want)
+		}
+		for i, leaf := range dequeued {
+			leaf.LeafIndex = int64(i)
+		}
+		if err := tx.UpdateSequencedLeaves(ctx, dequeued); err != nil {
+			return err
+		}
+		rev, err := tx.WriteRevision(ctx)
+		if err != nil {
+			return err
+		}
+		rootHash = sha256.New().Sum([]byte("root"))
+		slr, err := fixedSigner.SignLogRoot(&types.LogRootV1{
+			TreeSize: uint64(len(dequeued)),
+			RootHash: rootHash,
+			Revision: uint64(rev),
+		})
+		if err != nil {
+			return err
+		}
+		return tx.StoreSignedLogRoot(ctx, slr)
+	})
+	if err != nil {
+		t.Fatalf("ReadWriteTransaction(): %v", err)
+	}
+
+	err = s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		if got, err := tx.DequeueLeaves(ctx, 10, fakeQueueTime); err != nil || len(got) != 0 {
+			t.Errorf("DequeueLeaves() after sequencing = (%d leaves, %v), want (0, nil)", len(got), err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadWriteTransaction(): %v", err)
+	}
+
+	tx, err := s.SnapshotForTree(ctx, tree)
+	if err != nil {
+		t.Fatalf("SnapshotForTree(): %v", err)
+	}
+	defer tx.Close()
+	if got, err := tx.GetSequencedLeafCount(ctx); err != nil || got != 5 {
+		t.Errorf("GetSequencedLeafCount() = (%d, %v), want (5, nil)", got, err)
+	}
+	byRange, err := tx.GetLeavesByRange(ctx, 1, 3)
+	if err != nil {
+		t.Fatalf("GetLeavesByRange(): %v", err)
+	}
+	if got, want := len(byRange), 3; got != want {
+		t.Fatalf("GetLeavesByRange() returned %d leaves, want %d", got, want)
+	}
+	for i, leaf := range byRange {
+		if got, want := leaf.LeafIndex, int64(i+1); got != want {
+			t.Errorf("GetLeavesByRange()[%d].LeafIndex = %d, want %d", i, got, want)
+		}
+	}
+	byIndex, err := tx.GetLeavesByIndex(ctx, []int64{4, 0})
+	if err != nil {
+		t.Fatalf("GetLeavesByIndex(): %v", err)
+	}
+	if len(byIndex) != 2 || byIndex[0].LeafIndex != 0 || byIndex[1].LeafIndex != 4 {
+		t.Errorf("GetLeavesByIndex([4, 0]) = %v, want leaves 0 and 4", byIndex)
+	}
+	if _, err := tx.GetLeavesByIndex(ctx, []int64{5}); err == nil {
+		t.Error("GetLeavesByIndex([5]) = nil error, want error")
+	}
+	byHash, err := tx.GetLeavesByHash(ctx, [][]byte{byRange[0].MerkleLeafHash}, false)
+	if err != nil {
+		t.Fatalf("GetLeavesByHash(): %v", err)
+	}
+	if len(byHash) != 1 || !bytes.Equal(byHash[0].LeafValue, byRange[0].LeafValue) {
+		t.Errorf("GetLeavesByHash() = %v, want [%v]", byHash, byRange[0])
+	}
+	slr, err := tx.GetSignedLogRootByHash(ctx, rootHash)
+	if err != nil {
+		t.Fatalf("GetSignedLogRootByHash(): %v", err)
+	}
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		t.Fatalf("UnmarshalBinary(): %v", err)
+	}
+	if got, want := root.TreeSize, uint64(5); got != want {
+		t.Errorf("GetSignedLogRootByHash().TreeSize = %d, want %d", got, want)
+	}
+}
+
+func TestExpiredLeaseTakenOver(t *testing.T) {
+	ctx := context.Background()
+	tbl, done := newTestTable(ctx, t)
+	defer done()
+	s := NewLogStorage(tbl, TreeStorageOptions{CommitLease: 100 * time.Millisecond})
+	tree := createLogForTests(ctx, t, tbl, s)
+
+	// Begin a transaction which writes a root and then stalls past its lease.
+	stalled, err := s.(*logStorage).begin(ctx, tree, false /* readonly */)
+	if err != nil {
+		t.Fatalf("begin(): %v", err)
+	}
+	rev, err := stalled.WriteRevision(ctx)
+	if err != nil {
+		t.Fatalf("WriteRevision(): %v", err)
+	}
+	slr, err := fixedSigner.SignLogRoot(&types.LogRootV1{
+		TreeSize: 1,
+		RootHash: []byte("stalled"),
+		Revision: uint64(rev),
+	})
+	if err != nil {
+		t.Fatalf("SignLogRoot(): %v", err)
+	}
+	if err := stalled.StoreSignedLogRoot(ctx, slr); err != nil {
+		t.Fatalf("StoreSignedLogRoot(): %v", err)
+	}
+
+	storeRoot(ctx, t, s, tree, &types.LogRootV1{TreeSize: 2, RootHash: []byte("taken over")})
+	if err := stalled.Commit(ctx); err != ErrLeaseLost {
+		t.Errorf("Commit() of the stalled transaction = %v, want %v", err, ErrLeaseLost)
+	}
+
+	tx, err := s.SnapshotForTree(ctx, tree)
+	if err != nil {
+		t.Fatalf("SnapshotForTree(): %v", err)
+	}
+	defer tx.Close()
+	if got, err := tx.GetSequencedLeafCount(ctx); err != nil || got != 2 {
+		t.Errorf("GetSequencedLeafCount() = (%d, %v), want (2, nil)", got, err)
+	}
+	if got, err := tx.ReadRevision(ctx); err != nil || got != 1 {
+		t.Errorf("ReadRevision() = (%d, %v), want (1, nil)", got, err)
+	}
+}
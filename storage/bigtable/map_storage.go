@@ -0,0 +1,710 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	bt "cloud.google.com/go/bigtable"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rootBatchSize is the number of revisions ListSignedMapRoots reads at a time
+// when listing roots in descending order.
+const rootBatchSize = 100
+
+// mapStorage provides a Cloud Bigtable backed storage.MapStorage
+// implementation.
+type mapStorage struct {
+	ts *treeStorage
+}
+
+// NewMapStorage creates a storage.MapStorage instance backed by tbl, which
+// must have been created by CreateTable.
+func NewMapStorage(tbl *bt.Table, opts TreeStorageOptions) storage.MapStorage {
+	return &mapStorage{ts: newTreeStorage(tbl, opts)}
+}
+
+func (ms *mapStorage) CheckDatabaseAccessible(ctx context.Context) error {
+	return checkDatabaseAccessible(ctx, ms.ts.tbl)
+}
+
+func (ms *mapStorage) newCache(tree *trillian.Tree) (*cache.SubtreeCache, error) {
+	hasher, err := ms.ts.opts.MapHashers.NewMapHasherForTree(tree)
+	if err != nil {
+		return nil, err
+	}
+	return cache.NewMapSubtreeCache(cache.MapStrata(defMapStrata, hasher.BitLen()), tree.TreeId, hasher), nil
+}
+
+// begin returns a transaction on the map tree. If the map has no root yet, a
+// read-write transaction is returned along with storage.ErrTreeNeedsInit.
+func (ms *mapStorage) begin(ctx context.Context, tree *trillian.Tree, readonly bool) (*mapTX, error) {
+	ttx, err := ms.ts.begin(ctx, tree, readonly, ms.newCache, trillian.TreeType_MAP)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := mapStorageSettings(ttx.tree)
+	if err != nil {
+		ttx.Close()
+		return nil, err
+	}
+	tx := &mapTX{treeTX: ttx, leafHashIndex: settings.LeafHashIndex}
+	tx.checkHead = true
+	if tx.w != nil {
+		tx.w.keepIntent = true
+	}
+	if !readonly && ttx.head < 0 {
+		return tx, storage.ErrTreeNeedsInit
+	}
+	return tx, nil
+}
+
+func (ms *mapStorage) SnapshotForTree(ctx context.Context, tree *trillian.Tree) (storage.ReadOnlyMapTreeTX, error) {
+	return ms.begin(ctx, tree, true /* readonly */)
+}
+
+func (ms *mapStorage) ReadWriteTransaction(ctx context.Context, tree *trillian.Tree, f storage.MapTXFunc) error {
+	tx, err := ms.begin(ctx, tree, false /* readonly */)
+	if err != nil && err != storage.ErrTreeNeedsInit {
+		return err
+	}
+	defer tx.Close()
+	if err := f(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ReadWriteMultiTransaction takes the commit leases of the trees in order of
+// tree ID, so that concurrent transactions can't deadlock, and commits their
+// writes with a commit record.
+func (ms *mapStorage) ReadWriteMultiTransaction(ctx context.Context, trees []*trillian.Tree, f storage.MultiMapTXFunc) error {
+	order := make([]int, len(trees))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return trees[order[i]].TreeId < trees[order[j]].TreeId })
+	for i := 1; i < len(order); i++ {
+		if id := trees[order[i]].TreeId; id == trees[order[i-1]].TreeId {
+			return status.Errorf(codes.InvalidArgument, "tree %d appears more than once", id)
+		}
+	}
+
+	mtxs := make([]*mapTX, len(trees))
+	defer func() {
+		for _, mtx := range mtxs {
+			if mtx != nil {
+				mtx.Close()
+			}
+		}
+	}()
+	for _, i := range order {
+		mtx, err := ms.begin(ctx, trees[i], false /* readonly */)
+		if err != nil && err != storage.ErrTreeNeedsInit {
+			return err
+		}
+		mtxs[i] = mtx
+	}
+	txs := make([]storage.MapTreeTX, len(mtxs))
+	for i, mtx := range mtxs {
+		txs[i] = mtx
+	}
+	if err := f(ctx, txs); err != nil {
+		return err
+	}
+
+	ws := make([]*writer, 0, len(order))
+	for _, i := range order {
+		mtx := mtxs[i]
+		mtx.mu.Lock()
+		defer mtx.mu.Unlock()
+		if mtx.closed {
+			return ErrTransactionClosed
+		}
+		if err := mtx.cache.Flush(ctx, mtx.storeSubtrees); err != nil {
+			return err
+		}
+		ws = append(ws, mtx.w)
+	}
+	// From here on, the commit releases the leases whatever happens.
+	for _, mtx := range mtxs {
+		mtx.closed = true
+	}
+	return ms.ts.commitMulti(ctx, ws)
+}
+
+// mapTX implements storage.MapTreeTX.
+type mapTX struct {
+	*treeTX
+	// leafHashIndex is set if the map keeps the x rows, which index leaves by
+	// their hash.
+	leafHashIndex bool
+}
+
+// readRevision returns the revision at which to read the map when asked for
+// revision, which is capped at the head, and means the head if negative.
+func (t *mapTX) readRevision(revision int64) int64 {
+	if revision < 0 || revision > t.head {
+		return t.head
+	}
+	return revision
+}
+
+func leafKey(treeID int64, index []byte) string {
+	return rowKey(treeID, "v", hex.EncodeToString(index))
+}
+
+// readMapLeaves calls fn with the leaves in rows, and the timestamps at which
+// they were written, filtered by filter.
+func (t *mapTX) readMapLeaves(ctx context.Context, rows bt.RowSet, filter bt.Filter, fn func(bt.ReadItem, *trillian.MapLeaf), opts ...bt.ReadOption) error {
+	prefix := rowPrefix(t.treeID, "v")
+	var readErr error
+	opts = append(opts, bt.RowFilter(bt.ChainFilters(column(verFamily, colLeaf), filter)))
+	err := t.ts.tbl.ReadRows(ctx, rows, func(r bt.Row) bool {
+		index, err := hex.DecodeString(r.Key()[len(prefix):])
+		if err != nil {
+			readErr = fmt.Errorf("malformed map leaf key %q: %v", r.Key(), err)
+			return false
+		}
+		for _, item := range r[verFamily] {
+			leaf, err := unmarshalMapLeaf(item.Value, index)
+			if err != nil {
+				readErr = err
+				return false
+			}
+			fn(item, leaf)
+		}
+		return true
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	return readErr
+}
+
+func unmarshalMapLeaf(marshaledLeaf, index []byte) (*trillian.MapLeaf, error) {
+	var mapLeaf trillian.MapLeaf
+	if err := proto.Unmarshal(marshaledLeaf, &mapLeaf); err != nil {
+		return nil, fmt.Errorf("could not unmarshal map leaf: %v", err)
+	}
+	mapLeaf.Index = index
+	return &mapLeaf, nil
+}
+
+func (t *mapTX) Set(ctx context.Context, keyHash []byte, value *trillian.MapLeaf) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w == nil {
+		return ErrWrongTXType
+	}
+
+	b, err := proto.Marshal(value)
+	if err != nil {
+		return err
+	}
+	t.w.set(leafKey(t.treeID, keyHash), verFamily, colLeaf, b)
+	// Deleted leaves can't be looked up by hash.
+	if t.leafHashIndex && len(value.LeafValue) > 0 {
+		t.w.set(rowKey(t.treeID, "x", hex.EncodeToString(value.LeafHash), hex.EncodeToString(keyHash)), verFamily, colHash, nil)
+	}
+	return nil
+}
+
+// Get returns a list of map leaves indicated by indexes.
+// If an index is not found, no corresponding entry is returned.
+func (t *mapTX) Get(ctx context.Context, revision int64, indexes [][]byte) ([]*trillian.MapLeaf, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.get(ctx, revision, indexes)
+}
+
+func (t *mapTX) get(ctx context.Context, revision int64, indexes [][]byte) ([]*trillian.MapLeaf, error) {
+	ret := make([]*trillian.MapLeaf, 0, len(indexes))
+	rev := t.readRevision(revision)
+	if len(indexes) == 0 || rev < 0 {
+		return ret, nil
+	}
+	keys := make(bt.RowList, 0, len(indexes))
+	for _, index := range indexes {
+		keys = append(keys, leafKey(t.treeID, index))
+	}
+	revs := make(map[string]int64, len(indexes))
+	err := t.readMapLeaves(ctx, keys, atRevision(rev), func(item bt.ReadItem, leaf *trillian.MapLeaf) {
+		revs[item.Row] = tsRev(item.Timestamp)
+		ret = append(ret, leaf)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rev == t.head {
+		if err := t.checkHeadRows(ctx, keys, revs); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// List returns up to limit map leaves which exist at revision, ordered by
+// index, starting with the first index greater than after.
+func (t *mapTX) List(ctx context.Context, revision int64, after []byte, limit int) ([]*trillian.MapLeaf, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ret := make([]*trillian.MapLeaf, 0)
+	rev := t.readRevision(revision)
+	if limit <= 0 || rev < 0 {
+		return ret, nil
+	}
+	rows := afterRange(rowPrefix(t.treeID, "v"), hex.EncodeToString(after))
+	err := t.readMapLeaves(ctx, rows, atRevision(rev), func(_ bt.ReadItem, leaf *trillian.MapLeaf) {
+		ret = append(ret, leaf)
+	}, bt.LimitRows(int64(limit)))
+	return ret, err
+}
+
+// ListLeafVersions returns all the versions of up to limit map leaves, ordered
+// by index and revision, starting with the first index greater than after.
+func (t *mapTX) ListLeafVersions(ctx context.Context, after []byte, limit int) ([]storage.MapLeafVersion, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ret := make([]storage.MapLeafVersion, 0)
+	if limit <= 0 || t.head < 0 {
+		return ret, nil
+	}
+	rows := afterRange(rowPrefix(t.treeID, "v"), hex.EncodeToString(after))
+	// Versions are read newest first, so each leaf's are reversed.
+	start := 0
+	var index []byte
+	err := t.readMapLeaves(ctx, rows, bt.TimestampRangeFilterMicros(0, revTS(t.head+1)), func(item bt.ReadItem, leaf *trillian.MapLeaf) {
+		if !bytes.Equal(leaf.Index, index) {
+			reverseVersions(ret[start:])
+			start, index = len(ret), leaf.Index
+		}
+		ret = append(ret, storage.MapLeafVersion{Revision: tsRev(item.Timestamp), Leaf: leaf})
+	}, bt.LimitRows(int64(limit)))
+	reverseVersions(ret[start:])
+	return ret, err
+}
+
+func reverseVersions(v []storage.MapLeafVersion) {
+	for i, j := 0, len(v)-1; i < j; i, j = i+1, j-1 {
+		v[i], v[j] = v[j], v[i]
+	}
+}
+
+func (t *mapTX) SetLeafHash(ctx context.Context, revision int64, index, leafHash []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w == nil {
+		return ErrWrongTXType
+	}
+
+	// Leaves are stored as marshalled MapLeaf protos, so the hash can only be
+	// replaced by rewriting the whole leaf.
+	var mapLeaf *trillian.MapLeaf
+	if revision >= 0 && revision <= t.head {
+		key := leafKey(t.treeID, index)
+		filter := bt.TimestampRangeFilterMicros(revTS(revision), revTS(revision+1))
+		err := t.readMapLeaves(ctx, bt.SingleRow(key), filter, func(_ bt.ReadItem, leaf *trillian.MapLeaf) {
+			mapLeaf = leaf
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if mapLeaf == nil {
+		return status.Errorf(codes.NotFound, "leaf %x at revision %d not found", index, revision)
+	}
+	if bytes.Equal(mapLeaf.LeafHash, leafHash) {
+		// The hash is unchanged, so there is nothing to rewrite.
+		return nil
+	}
+	oldHash := mapLeaf.LeafHash
+	mapLeaf.LeafHash = leafHash
+	b, err := proto.Marshal(mapLeaf)
+	if err != nil {
+		return err
+	}
+	t.w.direct.row(leafKey(t.treeID, index)).Set(verFamily, colLeaf, revTS(revision), b)
+	if !t.leafHashIndex || len(mapLeaf.LeafValue) == 0 {
+		return nil
+	}
+	// The version may have had no hash to index, so it is indexed afresh.
+	t.w.direct.row(rowKey(t.treeID, "x", hex.EncodeToString(oldHash), hex.EncodeToString(index))).
+		DeleteTimestampRange(verFamily, colHash, revTS(revision), revTS(revision+1))
+	t.w.direct.row(rowKey(t.treeID, "x", hex.EncodeToString(leafHash), hex.EncodeToString(index))).
+		Set(verFamily, colHash, revTS(revision), nil)
+	return nil
+}
+
+// GetIndexesByLeafHash returns the indexes of the leaves whose value at
+// revision has leafHash, ordered by index. The x rows record every hash a leaf
+// has had, so the candidates they give are checked against the leaves.
+func (t *mapTX) GetIndexesByLeafHash(ctx context.Context, revision int64, leafHash []byte) ([][]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.leafHashIndex {
+		return nil, storage.ErrNoLeafHashIndex
+	}
+	rev := t.readRevision(revision)
+	if rev < 0 {
+		return nil, nil
+	}
+	prefix := rowPrefix(t.treeID, "x") + hex.EncodeToString(leafHash) + "/"
+	var candidates [][]byte
+	var readErr error
+	filter := bt.ChainFilters(column(verFamily, colHash), bt.TimestampRangeFilterMicros(0, revTS(rev+1)), bt.CellsPerRowLimitFilter(1))
+	err := t.ts.tbl.ReadRows(ctx, bt.PrefixRange(prefix), func(r bt.Row) bool {
+		var index []byte
+		if index, readErr = hex.DecodeString(r.Key()[len(prefix):]); readErr != nil {
+			return false
+		}
+		candidates = append(candidates, index)
+		return true
+	}, bt.RowFilter(filter))
+	if err == nil {
+		err = readErr
+	}
+	if err != nil || len(candidates) == 0 {
+		return nil, err
+	}
+	leaves, err := t.get(ctx, rev, candidates)
+	if err != nil {
+		return nil, err
+	}
+	var indexes [][]byte
+	for _, leaf := range leaves {
+		if len(leaf.LeafValue) > 0 && bytes.Equal(leaf.LeafHash, leafHash) {
+			indexes = append(indexes, leaf.Index)
+		}
+	}
+	return indexes, nil
+}
+
+// readMapRoot returns the signed root of the map at revision rev, or nil if
+// there is none.
+func (t *mapTX) readMapRoot(ctx context.Context, rev int64) (*trillian.SignedMapRoot, error) {
+	if rev < 0 || rev > t.head {
+		return nil, nil
+	}
+	row, err := t.ts.tbl.ReadRow(ctx, rootKey(t.treeID, rev), bt.RowFilter(column(metaFamily, colRoot)))
+	if err != nil {
+		return nil, err
+	}
+	b, ok := cellValue(row, metaFamily, colRoot)
+	if !ok {
+		return nil, nil
+	}
+	return unmarshalMapRoot(b)
+}
+
+func unmarshalMapRoot(b []byte) (*trillian.SignedMapRoot, error) {
+	root := &trillian.SignedMapRoot{}
+	if err := proto.Unmarshal(b, root); err != nil {
+		return nil, fmt.Errorf("could not unmarshal map root: %v", err)
+	}
+	return root, nil
+}
+
+// readMapRoots calls fn with the roots of the revisions in [start, end), in
+// ascending order, until it returns false.
+func (t *mapTX) readMapRoots(ctx context.Context, start, end int64, fn func(*trillian.SignedMapRoot, *types.MapRootV1) bool) error {
+	if end > t.head+1 {
+		end = t.head + 1
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start >= end {
+		return nil
+	}
+	rows := bt.NewRange(rootKey(t.treeID, start), rootKey(t.treeID, end))
+	var readErr error
+	err := t.ts.tbl.ReadRows(ctx, rows, func(r bt.Row) bool {
+		b, _ := cellValue(r, metaFamily, colRoot)
+		var root *trillian.SignedMapRoot
+		if root, readErr = unmarshalMapRoot(b); readErr != nil {
+			return false
+		}
+		var mr types.MapRootV1
+		if readErr = mr.UnmarshalBinary(root.MapRoot); readErr != nil {
+			return false
+		}
+		return fn(root, &mr)
+	}, bt.RowFilter(column(metaFamily, colRoot)))
+	if err != nil {
+		return err
+	}
+	return readErr
+}
+
+func (t *mapTX) GetSignedMapRoot(ctx context.Context, revision int64) (*trillian.SignedMapRoot, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	root, err := t.readMapRoot(ctx, revision)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		if revision == 0 {
+			return nil, storage.ErrTreeNeedsInit
+		}
+		return nil, status.Errorf(codes.NotFound, "map root %d not found", revision)
+	}
+	return root, nil
+}
+
+func (t *mapTX) LatestSignedMapRoot(ctx context.Context) (*trillian.SignedMapRoot, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	root, err := t.readMapRoot(ctx, t.head)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		// It's possible there are no roots for this tree yet
+		return nil, storage.ErrTreeNeedsInit
+	}
+	return root, nil
+}
+
+func (t *mapTX) EarliestRevisionSince(ctx context.Context, ts time.Time) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.head < 0 {
+		return 0, storage.ErrTreeNeedsInit
+	}
+	rev := t.head + 1
+	err := t.readMapRoots(ctx, 0, t.head+1, func(_ *trillian.SignedMapRoot, mr *types.MapRootV1) bool {
+		if int64(mr.TimestampNanos) >= ts.UnixNano() {
+			rev = int64(mr.Revision)
+			return false
+		}
+		return true
+	})
+	return rev, err
+}
+
+// ListSignedMapRoots returns up to limit roots which match filter, ordered by
+// revision. Bigtable only scans rows in ascending order, so descending lists
+// are read in batches of revisions, from the newest down.
+func (t *mapTX) ListSignedMapRoots(ctx context.Context, filter storage.MapRootFilter, limit int) ([]*trillian.SignedMapRoot, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ret := make([]*trillian.SignedMapRoot, 0)
+	if limit <= 0 {
+		return ret, nil
+	}
+	end := filter.EndRevision
+	if end <= 0 || end > t.head+1 {
+		end = t.head + 1
+	}
+	match := func(mr *types.MapRootV1) bool {
+		ts := time.Unix(0, int64(mr.TimestampNanos))
+		return (filter.StartTime.IsZero() || !ts.Before(filter.StartTime)) &&
+			(filter.EndTime.IsZero() || ts.Before(filter.EndTime))
+	}
+
+	if !filter.Descending {
+		err := t.readMapRoots(ctx, filter.StartRevision, end, func(root *trillian.SignedMapRoot, mr *types.MapRootV1) bool {
+			if match(mr) {
+				ret = append(ret, root)
+			}
+			return len(ret) < limit
+		})
+		return ret, err
+	}
+
+	for end > filter.StartRevision && end > 0 && len(ret) < limit {
+		start := end - rootBatchSize
+		if start < filter.StartRevision {
+			start = filter.StartRevision
+		}
+		var batch []*trillian.SignedMapRoot
+		err := t.readMapRoots(ctx, start, end, func(root *trillian.SignedMapRoot, mr *types.MapRootV1) bool {
+			if match(mr) {
+				batch = append(batch, root)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := len(batch) - 1; i >= 0 && len(ret) < limit; i-- {
+			ret = append(ret, batch[i])
+		}
+		end = start
+	}
+	return ret, nil
+}
+
+func (t *mapTX) GetIdempotencyToken(ctx context.Context, token []byte) (int64, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.head < 0 {
+		return 0, false, nil
+	}
+	key := rowKey(t.treeID, "k", hex.EncodeToString(token))
+	row, err := t.ts.tbl.ReadRow(ctx, key, bt.RowFilter(bt.ChainFilters(column(verFamily, colRev), atRevision(t.head))))
+	if err != nil {
+		return 0, false, err
+	}
+	if items := row[verFamily]; len(items) > 0 {
+		return tsRev(items[0].Timestamp), true, nil
+	}
+	return 0, false, nil
+}
+
+func (t *mapTX) StoreIdempotencyToken(ctx context.Context, token []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w == nil {
+		return ErrWrongTXType
+	}
+	t.w.set(rowKey(t.treeID, "k", hex.EncodeToString(token)), verFamily, colRev, []byte(numKey(t.w.rev)))
+	return nil
+}
+
+func (t *mapTX) GetMapRootSignatures(ctx context.Context, revision int64) ([]*trillian.MapRootSignature, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if revision > t.head {
+		return nil, nil
+	}
+	row, err := t.ts.tbl.ReadRow(ctx, rootKey(t.treeID, revision), bt.RowFilter(column(metaFamily, colSig+".*")))
+	if err != nil {
+		return nil, err
+	}
+	// Columns are ordered by name, and so by the hash of the key.
+	var sigs []*trillian.MapRootSignature
+	for _, item := range row[metaFamily] {
+		sig := &trillian.MapRootSignature{}
+		if err := proto.Unmarshal(item.Value, sig); err != nil {
+			return nil, fmt.Errorf("could not unmarshal map root signature: %v", err)
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+func (t *mapTX) StoreMapRootSignature(ctx context.Context, revision int64, sig *trillian.MapRootSignature) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w == nil {
+		return ErrWrongTXType
+	}
+	keyHash := sha256.Sum256(sig.GetPublicKey().GetDer())
+	b, err := proto.Marshal(sig)
+	if err != nil {
+		return err
+	}
+	t.w.storeSignature(t.treeID, revision, hex.EncodeToString(keyHash[:]), b)
+	return nil
+}
+
+// DeleteRevisionsBefore deletes the rows of the roots before revision, with
+// their signatures and recovery markers, the tokens written before it, and
+// the versions of leaves and subtrees superseded at or before it. The
+// deletions are applied once the transaction commits.
+func (t *mapTX) DeleteRevisionsBefore(ctx context.Context, revision int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w == nil {
+		return ErrWrongTXType
+	}
+
+	keysOnly := bt.ChainFilters(bt.CellsPerRowLimitFilter(1), bt.StripValueFilter())
+	rows := bt.NewRange(rowPrefix(t.treeID, "r"), rootKey(t.treeID, revision))
+	err := t.ts.tbl.ReadRows(ctx, rows, func(r bt.Row) bool {
+		t.w.post.row(r.Key()).DeleteRow()
+		return true
+	}, bt.RowFilter(keysOnly))
+	if err != nil {
+		return err
+	}
+
+	before := bt.TimestampRangeFilterMicros(0, revTS(revision))
+	err = t.ts.tbl.ReadRows(ctx, bt.PrefixRange(rowPrefix(t.treeID, "k")), func(r bt.Row) bool {
+		t.w.post.row(r.Key()).DeleteTimestampRange(verFamily, colRev, 0, revTS(revision))
+		return true
+	}, bt.RowFilter(bt.ChainFilters(column(verFamily, colRev), before, keysOnly)))
+	if err != nil {
+		return err
+	}
+
+	kinds := []struct{ kind, col string }{{"v", colLeaf}, {"s", colSubtree}}
+	if t.leafHashIndex {
+		kinds = append(kinds, struct{ kind, col string }{"x", colHash})
+	}
+	upTo := bt.ChainFilters(bt.TimestampRangeFilterMicros(0, revTS(revision+1)), bt.LatestNFilter(2), bt.StripValueFilter())
+	for _, k := range kinds {
+		err := t.ts.tbl.ReadRows(ctx, bt.PrefixRange(rowPrefix(t.treeID, k.kind)), func(r bt.Row) bool {
+			// Versions are ordered newest first, and the newest one at
+			// revision is kept.
+			if items := r[verFamily]; len(items) > 1 {
+				t.w.post.row(r.Key()).DeleteTimestampRange(verFamily, k.col, 0, items[0].Timestamp)
+			}
+			return true
+		}, bt.RowFilter(bt.ChainFilters(column(verFamily, k.col), upTo)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *mapTX) StoreSignedMapRoot(ctx context.Context, root *trillian.SignedMapRoot) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w == nil {
+		return ErrWrongTXType
+	}
+
+	var r types.MapRootV1
+	if err := r.UnmarshalBinary(root.MapRoot); err != nil {
+		return err
+	}
+	if got, want := int64(r.Revision), t.w.rev; got != want {
+		return fmt.Errorf("root revision %d, want %d", got, want)
+	}
+	b, err := proto.Marshal(root)
+	if err != nil {
+		return err
+	}
+	t.w.set(rootKey(t.treeID, t.w.rev), metaFamily, colRoot, b)
+	t.storeRecoveryMarker()
+	t.w.root = true
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/integration/storagetest"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	bt "cloud.google.com/go/bigtable"
+	storageto "github.com/google/trillian/storage/testonly"
+)
+
+func TestMapIntegration(t *testing.T) {
+	var done func()
+	storageFactory := func(ctx context.Context, t *testing.T) (storage.MapStorage, storage.AdminStorage) {
+		if done != nil {
+			done()
+		}
+		var tbl *bt.Table
+		tbl, done = newTestTable(ctx, t)
+		return NewMapStorage(tbl, TreeStorageOptions{}), NewAdminStorage(tbl)
+	}
+	defer func() { done() }()
+
+	storagetest.RunMapStorageTests(t, storageFactory)
+}
+
+// setMapLeaves writes the next revision of tree, which sets leaves, and returns
+// its number.
+func setMapLeaves(ctx context.Context, t *testing.T, s storage.MapStorage, tree *trillian.Tree, leaves ...*trillian.MapLeaf) int64 {
+	t.Helper()
+	var rev int64
+	err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		var err error
+		if rev, err = tx.WriteRevision(ctx); err != nil {
+			return err
+		}
+		for _, leaf := range leaves {
+			if err := tx.Set(ctx, leaf.Index, leaf); err != nil {
+				return err
+			}
+		}
+		root, err := (&types.MapRootV1{Revision: uint64(rev)}).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return tx.StoreSignedMapRoot(ctx, &trillian.SignedMapRoot{MapRoot: root})
+	})
+	if err != nil {
+		t.Fatalf("Failed to write revision of map: %v", err)
+	}
+	return rev
+}
+
+func TestMapGetPartialRevision(t *testing.T) {
+	ctx := context.Background()
+	tbl, done := newTestTable(ctx, t)
+	defer done()
+	s := NewMapStorage(tbl, TreeStorageOptions{})
+	tree, err := storage.CreateTree(ctx, NewAdminStorage(tbl), storageto.MapTree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+
+	index1, index2 := make([]byte, 32), make([]byte, 32)
+	index1[0], index2[0] = 1, 2
+	setMapLeaves(ctx, t, s, tree)
+	setMapLeaves(ctx, t, s, tree, &trillian.MapLeaf{Index: index1, LeafValue: []byte("one")}, &trillian.MapLeaf{Index: index2, LeafValue: []byte("one")})
+	head := setMapLeaves(ctx, t, s, tree, &trillian.MapLeaf{Index: index1, LeafValue: []byte("two")})
+
+	get := func(rev int64, index []byte) (string, error) {
+		var value string
+		err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+			leaves, err := tx.Get(ctx, rev, [][]byte{index})
+			if err != nil {
+				return err
+			}
+			if len(leaves) != 1 {
+				return fmt.Errorf("got %d leaves, want 1", len(leaves))
+			}
+			value = string(leaves[0].LeafValue)
+			return nil
+		})
+		return value, err
+	}
+	if got, err := get(head, index1); err != nil || got != "two" {
+		t.Fatalf("Get(%d)=%q, %v, want two, nil", head, got, err)
+	}
+
+	// The version of the leaf written at the head is lost, as if the cluster
+	// read from hadn't replicated it yet.
+	m := bt.NewMutation()
+	m.DeleteTimestampRange(verFamily, colLeaf, revTS(head), revTS(head+1))
+	if err := tbl.Apply(ctx, leafKey(tree.TreeId, index1), m); err != nil {
+		t.Fatalf("Apply(): %v", err)
+	}
+	for _, test := range []struct {
+		desc     string
+		rev      int64
+		index    []byte
+		want     string
+		wantCode codes.Code
+	}{
+		{desc: "partial", rev: head, index: index1, wantCode: codes.NotFound},
+		{desc: "unwritten", rev: head, index: index2, want: "one"},
+		{desc: "previous", rev: head - 1, index: index1, want: "one"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := get(test.rev, test.index)
+			if status.Code(err) != test.wantCode {
+				t.Fatalf("Get(%d): %v, want code %v", test.rev, err, test.wantCode)
+			}
+			if got != test.want {
+				t.Errorf("Get(%d)=%q, want %q", test.rev, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"context"
+	"time"
+
+	bt "cloud.google.com/go/bigtable"
+	"github.com/google/trillian/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// storeRecoveryMarker buffers the recovery marker of the root at the write
+// revision. The caller must hold t.mu.
+//
+// The position of a marker is the time at which its root was written, which
+// identifies the Bigtable backups that contain the root.
+func (t *treeTX) storeRecoveryMarker() {
+	t.w.set(rootKey(t.treeID, t.w.rev), metaFamily, colMarker, []byte(time.Now().UTC().Format(time.RFC3339Nano)))
+}
+
+// GetRecoveryMarker implements storage.RecoveryMarkerReader.
+func (t *treeTX) GetRecoveryMarker(ctx context.Context, revision int64) (*storage.RecoveryMarker, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.readRecoveryMarker(ctx, revision)
+}
+
+// LatestRecoveryMarker implements storage.RecoveryMarkerReader.
+func (t *treeTX) LatestRecoveryMarker(ctx context.Context) (*storage.RecoveryMarker, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.readRecoveryMarker(ctx, t.head)
+}
+
+func (t *treeTX) readRecoveryMarker(ctx context.Context, revision int64) (*storage.RecoveryMarker, error) {
+	if revision >= 0 && revision <= t.head {
+		row, err := t.ts.tbl.ReadRow(ctx, rootKey(t.treeID, revision), bt.RowFilter(column(metaFamily, colMarker)))
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := cellValue(row, metaFamily, colMarker); ok {
+			return &storage.RecoveryMarker{TreeID: t.treeID, Revision: revision, Position: string(b)}, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "no recovery marker for tree %d", t.treeID)
+}
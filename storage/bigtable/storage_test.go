@@ -0,0 +1,69 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/bigtable/bttest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+
+	bt "cloud.google.com/go/bigtable"
+)
+
+const testTable = "trillian"
+
+// newTestTable starts an in-memory Bigtable server, and returns a table on it
+// created by CreateTable. The returned function stops the server.
+func newTestTable(ctx context.Context, t *testing.T) (*bt.Table, func()) {
+	t.Helper()
+	srv, err := bttest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("bttest.NewServer(): %v", err)
+	}
+	conn, err := grpc.Dial(srv.Addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("grpc.Dial(%v): %v", srv.Addr, err)
+	}
+	admin, err := bt.NewAdminClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewAdminClient(): %v", err)
+	}
+	if err := CreateTable(ctx, admin, testTable); err != nil {
+		t.Fatalf("CreateTable(): %v", err)
+	}
+	client, err := bt.NewClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewClient(): %v", err)
+	}
+	return client.Open(testTable), func() {
+		client.Close()
+		admin.Close()
+		conn.Close()
+		srv.Close()
+	}
+}
+
+func TestCheckDatabaseAccessible(t *testing.T) {
+	ctx := context.Background()
+	tbl, done := newTestTable(ctx, t)
+	defer done()
+
+	if err := NewAdminStorage(tbl).CheckDatabaseAccessible(ctx); err != nil {
+		t.Errorf("CheckDatabaseAccessible() = %v, want nil", err)
+	}
+}
@@ -0,0 +1,576 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtable
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bt "cloud.google.com/go/bigtable"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/storage/storagepb"
+	"github.com/google/trillian/storage/subtreecodec"
+	"github.com/google/trillian/storage/tree"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrNotImplemented is returned by any interface methods which have not been
+	// implemented yet.
+	ErrNotImplemented = errors.New("not implemented")
+
+	// ErrTransactionClosed is returned by interface methods when an operation is
+	// attempted on a transaction whose Commit or Rollback methods have
+	// previously been called.
+	ErrTransactionClosed = errors.New("transaction is closed")
+
+	// ErrWrongTXType is returned when, somehow, a write operation is attempted
+	// with a read-only transaction.  This should not even be possible.
+	ErrWrongTXType = errors.New("mutating method called on read-only transaction")
+
+	// ErrLeaseLost is returned when a read-write transaction fails to commit
+	// because its commit lease expired, and another writer may have taken over
+	// the tree.
+	ErrLeaseLost = status.Error(codes.Aborted, "commit lease lost")
+)
+
+const (
+	// DefaultCommitLease is the commit lease used if TreeStorageOptions leave
+	// it unset.
+	DefaultCommitLease = 30 * time.Second
+
+	metaFamily = "m"
+	verFamily  = "v"
+
+	colTree    = "tree"
+	colHead    = "head"
+	colIntent  = "intent"
+	colRoot    = "root"
+	colMarker  = "marker"
+	colSig     = "sig/"
+	colSubtree = "st"
+	colLeaf    = "leaf"
+	colSeq     = "seq"
+	colQueue   = "q"
+	colIndex   = "n"
+	colHash    = "x"
+	colRev     = "rev"
+	colState   = "state"
+)
+
+// TreeStorageOptions holds various levers for configuring the tree storage instance.
+type TreeStorageOptions struct {
+	// SubtreeCodec is the codec with which subtrees are written. Subtrees are
+	// read with whichever codec they were written with, so it can be changed
+	// at any time.
+	SubtreeCodec subtreecodec.Codec
+
+	// CommitLease is how long a read-write transaction excludes the other
+	// writers of its tree. Transactions must commit well within their lease,
+	// as another writer may roll them back once it expires.
+	CommitLease time.Duration
+
+	// MapHashers provides the hashers of map hash strategies, in addition to
+	// the ones registered with hashers.RegisterMapHasher. It is only used by
+	// map storage.
+	MapHashers hashers.MapHasherRegistry
+}
+
+// SubtreeCodecs are the subtree codecs supported by the Bigtable storage.
+var SubtreeCodecs = []subtreecodec.Codec{subtreecodec.Proto, subtreecodec.Packed, subtreecodec.Deflate}
+
+// CreateTable creates table in the instance of admin, with the column families
+// used by the storage.
+func CreateTable(ctx context.Context, admin *bt.AdminClient, table string) error {
+	if err := admin.CreateTable(ctx, table); err != nil {
+		return err
+	}
+	for _, family := range []string{metaFamily, verFamily} {
+		if err := admin.CreateColumnFamily(ctx, table, family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// treeKey returns the key of the row holding the tree with treeID.
+func treeKey(treeID int64) string {
+	return fmt.Sprintf("trees/%016x", uint64(treeID))
+}
+
+// rowPrefix returns the prefix of the keys of the rows of kind of a tree.
+func rowPrefix(treeID int64, kind string) string {
+	return fmt.Sprintf("%016x/%s/", uint64(treeID), kind)
+}
+
+// rowKey returns the key of the row of kind of a tree with the given parts.
+func rowKey(treeID int64, kind string, parts ...string) string {
+	return rowPrefix(treeID, kind) + strings.Join(parts, "/")
+}
+
+// numKey returns n as written in row keys, so that keys sort numerically.
+func numKey(n int64) string {
+	return fmt.Sprintf("%016x", uint64(n))
+}
+
+// parseNumKey parses the number written by numKey.
+func parseNumKey(s string) (int64, error) {
+	n, err := strconv.ParseUint(s, 16, 64)
+	return int64(n), err
+}
+
+// rootKey returns the key of the row holding the root of revision rev.
+func rootKey(treeID, rev int64) string {
+	return rowKey(treeID, "r", numKey(rev))
+}
+
+// prefixEnd returns the first key after all the keys with prefix, which must
+// end with a "/".
+func prefixEnd(prefix string) string {
+	return prefix[:len(prefix)-1] + "0"
+}
+
+// afterRange returns the range of the rows with prefix whose keys sort after
+// prefix+after, or all of them if after is empty.
+func afterRange(prefix, after string) bt.RowRange {
+	if after == "" {
+		return bt.PrefixRange(prefix)
+	}
+	return bt.NewRange(prefix+after+"\x00", prefixEnd(prefix))
+}
+
+// revTS returns the timestamp of the cells written at revision rev. Bigtable
+// timestamps have millisecond granularity, and revision -1 maps to the
+// timestamp 0 of unversioned cells.
+func revTS(rev int64) bt.Timestamp {
+	return bt.Timestamp((rev + 1) * 1000)
+}
+
+// tsRev returns the revision of the cells written at ts.
+func tsRev(ts bt.Timestamp) int64 {
+	return int64(ts)/1000 - 1
+}
+
+// atRevision returns a filter which passes the latest version of each cell at
+// or below revision rev, and all unversioned cells.
+func atRevision(rev int64) bt.Filter {
+	return bt.ChainFilters(bt.TimestampRangeFilterMicros(0, revTS(rev+1)), bt.LatestNFilter(1))
+}
+
+// column returns a filter which passes the cells of the columns of family
+// matching the regular expression col.
+func column(family, col string) bt.Filter {
+	return bt.ChainFilters(bt.FamilyFilter(family), bt.ColumnFilter(col))
+}
+
+// cellValue returns the value of the first cell of family:col in row.
+func cellValue(row bt.Row, family, col string) ([]byte, bool) {
+	for _, item := range row[family] {
+		if item.Column == family+":"+col {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+// head is the value of the head cell of a tree: its latest committed revision,
+// and the writer holding its commit lease, if any.
+type head struct {
+	rev    int64
+	holder string
+	expiry time.Time
+}
+
+func (h head) String() string {
+	if h.holder == "" {
+		return strconv.FormatInt(h.rev, 10)
+	}
+	return fmt.Sprintf("%d %s %d", h.rev, h.holder, h.expiry.UnixNano())
+}
+
+func parseHead(b []byte) (head, error) {
+	f := strings.Fields(string(b))
+	if len(f) != 1 && len(f) != 3 {
+		return head{}, fmt.Errorf("malformed head %q", b)
+	}
+	rev, err := strconv.ParseInt(f[0], 10, 64)
+	if err != nil {
+		return head{}, fmt.Errorf("malformed head %q: %v", b, err)
+	}
+	h := head{rev: rev}
+	if len(f) == 3 {
+		nanos, err := strconv.ParseInt(f[2], 10, 64)
+		if err != nil {
+			return head{}, fmt.Errorf("malformed head %q: %v", b, err)
+		}
+		h.holder, h.expiry = f[1], time.Unix(0, nanos)
+	}
+	return h, nil
+}
+
+// treeStorage provides a shared base for the Bigtable-backed implementation of
+// the Trillian storage.LogStorage and storage.MapStorage interfaces.
+type treeStorage struct {
+	tbl  *bt.Table
+	opts TreeStorageOptions
+}
+
+func newTreeStorage(tbl *bt.Table, opts TreeStorageOptions) *treeStorage {
+	if opts.CommitLease <= 0 {
+		opts.CommitLease = DefaultCommitLease
+	}
+	return &treeStorage{tbl: tbl, opts: opts}
+}
+
+// readTree returns the stored tree with treeID, and its head.
+func (t *treeStorage) readTree(ctx context.Context, treeID int64) (*trillian.Tree, head, error) {
+	row, err := t.tbl.ReadRow(ctx, treeKey(treeID), bt.RowFilter(column(metaFamily, colTree+"|"+colHead)))
+	if err != nil {
+		return nil, head{}, err
+	}
+	b, ok := cellValue(row, metaFamily, colTree)
+	if !ok {
+		return nil, head{}, status.Errorf(codes.NotFound, "tree %v not found", treeID)
+	}
+	tree := &trillian.Tree{}
+	if err := proto.Unmarshal(b, tree); err != nil {
+		return nil, head{}, fmt.Errorf("could not unmarshal tree %d: %v", treeID, err)
+	}
+	b, ok = cellValue(row, metaFamily, colHead)
+	if !ok {
+		return nil, head{}, fmt.Errorf("tree %d has no head", treeID)
+	}
+	h, err := parseHead(b)
+	if err != nil {
+		return nil, head{}, fmt.Errorf("tree %d: %v", treeID, err)
+	}
+	return tree, h, nil
+}
+
+type newCacheFn func(*trillian.Tree) (*cache.SubtreeCache, error)
+
+// begin returns a transaction on tree, which must have one of types. A
+// read-write transaction first takes the commit lease of the tree.
+func (t *treeStorage) begin(ctx context.Context, tree *trillian.Tree, readonly bool, newCache newCacheFn, types ...trillian.TreeType) (*treeTX, error) {
+	stored, h, err := t.readTree(ctx, tree.TreeId)
+	if err != nil {
+		return nil, err
+	}
+	if !hasType(stored, types) {
+		return nil, fmt.Errorf("begin(tree.TreeType: %v), want one of %v", stored.TreeType, types)
+	}
+	subtreeCache, err := newCache(stored)
+	if err != nil {
+		return nil, err
+	}
+	tx := &treeTX{
+		ts:     t,
+		treeID: stored.TreeId,
+		tree:   stored,
+		cache:  subtreeCache,
+	}
+	if !readonly {
+		if h, err = t.acquire(ctx, stored.TreeId); err != nil {
+			return nil, err
+		}
+		tx.w = newWriter(stored.TreeId, h)
+	}
+	tx.head = h.rev
+	return tx, nil
+}
+
+func hasType(tree *trillian.Tree, types []trillian.TreeType) bool {
+	for _, t := range types {
+		if tree.TreeType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// treeTX is a concrete implementation of the Trillian storage.TreeTX
+// interface.
+type treeTX struct {
+	ts     *treeStorage
+	treeID int64
+	// tree is the tree as stored when the transaction began.
+	tree *trillian.Tree
+
+	// mu guards closed, and the writes buffered in w.
+	mu     sync.Mutex
+	closed bool
+
+	// head is the revision read by the transaction, which is -1 if the tree
+	// has no root yet.
+	head  int64
+	cache *cache.SubtreeCache
+	// w buffers the writes of a read-write transaction, and is nil for
+	// snapshots.
+	w *writer
+
+	// checkHead is set for maps, whose reads at the head revision check that
+	// they find the cells written at it, see checkHeadRows.
+	checkHead bool
+	// headRows holds the rows of the versioned cells written at head, once
+	// read by checkHeadRows.
+	headRows map[string]bool
+}
+
+func (t *treeTX) writeRev() int64 {
+	if t.w != nil {
+		return t.w.rev
+	}
+	return t.head + 1
+}
+
+// ReadRevision returns the tree revision at which the transaction reads.
+func (t *treeTX) ReadRevision(ctx context.Context) (int64, error) {
+	return t.head, nil
+}
+
+// WriteRevision returns the tree revision at which any tree-modifying
+// operations will write.
+func (t *treeTX) WriteRevision(ctx context.Context) (int64, error) {
+	return t.writeRev(), nil
+}
+
+// subtreePrefix returns the prefix of the subtree rooted at the passed-in node
+// ID. Returns an error if the ID is not aligned to bytes.
+func subtreePrefix(id tree.NodeID) ([]byte, error) {
+	if id.PrefixLenBits%8 != 0 {
+		return nil, fmt.Errorf("invalid subtree ID - not multiple of 8: %d", id.PrefixLenBits)
+	}
+	if bytes := id.Path; bytes != nil {
+		return bytes[:id.PrefixLenBits/8], nil
+	}
+	return []byte{}, nil
+}
+
+// getSubtrees returns the most recent versions of the subtrees with ids at or
+// below revision rev. Subtrees which don't exist are omitted.
+func (t *treeTX) getSubtrees(ctx context.Context, rev int64, ids []tree.NodeID) ([]*storagepb.SubtreeProto, error) {
+	if rev > t.head {
+		rev = t.head
+	}
+	if rev < 0 || len(ids) == 0 {
+		return nil, nil
+	}
+	keys := make(bt.RowList, 0, len(ids))
+	for _, id := range ids {
+		prefix, err := subtreePrefix(id)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, rowKey(t.treeID, "s", hex.EncodeToString(prefix)))
+	}
+
+	ret := make([]*storagepb.SubtreeProto, 0, len(ids))
+	revs := make(map[string]int64, len(ids))
+	var stErr error
+	err := t.ts.tbl.ReadRows(ctx, keys, func(r bt.Row) bool {
+		for _, item := range r[verFamily] {
+			revs[r.Key()] = tsRev(item.Timestamp)
+		}
+		b, _ := cellValue(r, verFamily, colSubtree)
+		var st storagepb.SubtreeProto
+		if _, stErr = subtreecodec.Unmarshal(b, &st); stErr != nil {
+			return false
+		}
+		// A subtree with a zero-length prefix needs an empty Prefix field.
+		if st.Prefix == nil {
+			st.Prefix = []byte{}
+		}
+		ret = append(ret, &st)
+		return true
+	}, bt.RowFilter(bt.ChainFilters(column(verFamily, colSubtree), atRevision(rev))))
+	if err != nil {
+		return nil, err
+	}
+	if stErr != nil {
+		return nil, fmt.Errorf("could not unmarshal subtree: %v", stErr)
+	}
+	if rev == t.head {
+		if err := t.checkHeadRows(ctx, keys, revs); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// checkHeadRows returns NotFound if the transaction checks reads at the head
+// revision, and any of the rows with keys which the intent kept with the root
+// of the head lists as written at it was read, at the revision in revs, at an
+// older revision or not at all. The revision is then only partially visible,
+// e.g. because the cluster read from hasn't replicated all of it yet, or the
+// table was restored from a backup taken while it was written.
+func (t *treeTX) checkHeadRows(ctx context.Context, keys []string, revs map[string]int64) error {
+	if !t.checkHead || t.head < 0 {
+		return nil
+	}
+	if t.headRows == nil {
+		row, err := t.ts.tbl.ReadRow(ctx, rootKey(t.treeID, t.head), bt.RowFilter(column(metaFamily, colIntent)))
+		if err != nil {
+			return err
+		}
+		t.headRows = make(map[string]bool)
+		// Revisions written before intents were kept with roots have none.
+		if b, ok := cellValue(row, metaFamily, colIntent); ok {
+			var in intent
+			if err := json.Unmarshal(b, &in); err != nil {
+				return fmt.Errorf("tree %d: malformed intent of revision %d: %v", t.treeID, t.head, err)
+			}
+			for _, c := range in.Cells {
+				if c.Family == verFamily {
+					t.headRows[c.Row] = true
+				}
+			}
+		}
+	}
+	for _, key := range keys {
+		if rev, ok := revs[key]; t.headRows[key] && (!ok || rev < t.head) {
+			return status.Errorf(codes.NotFound, "tree %d: revision %d is partially written, row %q is missing", t.treeID, t.head, key)
+		}
+	}
+	return nil
+}
+
+// storeSubtrees buffers the writes of the passed in subtrees.
+func (t *treeTX) storeSubtrees(ctx context.Context, sts []*storagepb.SubtreeProto) error {
+	if t.w == nil {
+		return ErrWrongTXType
+	}
+	for _, st := range sts {
+		if st == nil {
+			continue
+		}
+		b, err := subtreecodec.Marshal(st, t.ts.opts.SubtreeCodec)
+		if err != nil {
+			return err
+		}
+		t.w.set(rowKey(t.treeID, "s", hex.EncodeToString(st.Prefix)), verFamily, colSubtree, b)
+	}
+	return nil
+}
+
+// GetMerkleNodes returns the requested set of nodes at, or before, the
+// specified tree revision.
+func (t *treeTX) GetMerkleNodes(ctx context.Context, rev int64, ids []tree.NodeID) ([]tree.Node, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, ErrTransactionClosed
+	}
+	return t.cache.GetNodes(ids, func(ids []tree.NodeID) ([]*storagepb.SubtreeProto, error) {
+		return t.getSubtrees(ctx, rev, ids)
+	})
+}
+
+// SetMerkleNodes stores the provided merkle nodes at the write revision of the
+// transaction.
+func (t *treeTX) SetMerkleNodes(ctx context.Context, nodes []tree.Node) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrTransactionClosed
+	}
+	if t.w == nil {
+		return ErrWrongTXType
+	}
+	for _, n := range nodes {
+		err := t.cache.SetNodeHash(n.NodeID, n.Hash,
+			func(nID tree.NodeID) (*storagepb.SubtreeProto, error) {
+				sts, err := t.getSubtrees(ctx, t.head, []tree.NodeID{nID})
+				if err != nil || len(sts) == 0 {
+					return nil, err
+				}
+				return sts[0], nil
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit commits the writes of a read-write transaction. If this call returns
+// an error, any values READ via this transaction MUST NOT be used.
+// On return from the call, this transaction will be in a closed state.
+func (t *treeTX) Commit(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrTransactionClosed
+	}
+	t.closed = true
+	t.cache.Release()
+	if t.w == nil {
+		return nil
+	}
+	if err := t.cache.Flush(ctx, t.storeSubtrees); err != nil {
+		t.ts.abort(ctx, t.w, nil)
+		return err
+	}
+	return t.ts.commit(ctx, t.w)
+}
+
+// Rollback discards the writes of the transaction, and releases its commit
+// lease. On return from the call, this transaction will be in a closed state.
+func (t *treeTX) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrTransactionClosed
+	}
+	t.closed = true
+	t.cache.Release()
+	if t.w == nil {
+		return nil
+	}
+	return t.ts.release(context.Background(), t.w)
+}
+
+func (t *treeTX) Close() error {
+	if t.IsOpen() {
+		if err := t.Rollback(); err != nil && err != ErrTransactionClosed {
+			glog.Warningf("Rollback error on Close(): %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// IsOpen returns true iff neither Commit nor Rollback have been called.
+func (t *treeTX) IsOpen() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.closed
+}
+
+func checkDatabaseAccessible(ctx context.Context, tbl *bt.Table) error {
+	return tbl.ReadRows(ctx, bt.PrefixRange("trees/"), func(bt.Row) bool { return false },
+		bt.LimitRows(1), bt.RowFilter(bt.StripValueFilter()))
+}
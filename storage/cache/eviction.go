@@ -0,0 +1,203 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"flag"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/trillian/monitoring"
+)
+
+var (
+	cacheSize     = flag.Int("subtree_cache_size", 0, "If positive, the maximum number of clean subtrees held by subtree caches, beyond which they are evicted. Subtrees with unwritten changes are never evicted. Unlimited otherwise")
+	cacheEviction = flag.String("subtree_cache_eviction", "lru", "Which clean subtrees a full subtree cache evicts. One of: lru, fifo")
+	cacheScope    = flag.String("subtree_cache_scope", "tree", "Scope of --subtree_cache_size. One of: tree, where it bounds the cache of each tree transaction, or shared, where it bounds the caches of all transactions together")
+)
+
+// EvictionPolicy selects which clean subtrees a full SubtreeCache evicts.
+type EvictionPolicy int
+
+const (
+	// LRU evicts the least recently used subtrees.
+	LRU EvictionPolicy = iota
+	// FIFO evicts the subtrees which were read from storage first.
+	FIFO
+)
+
+// ParseEvictionPolicy returns the eviction policy called name, lru or fifo.
+func ParseEvictionPolicy(name string) (EvictionPolicy, error) {
+	switch name {
+	case "lru":
+		return LRU, nil
+	case "fifo":
+		return FIFO, nil
+	}
+	return 0, fmt.Errorf("unknown subtree cache eviction policy %q", name)
+}
+
+// Options configures how many subtrees a SubtreeCache holds.
+type Options struct {
+	// MaxSubtrees, if positive, is the maximum number of clean subtrees held,
+	// beyond which subtrees are evicted according to Eviction. Subtrees with
+	// changes which aren't flushed yet are never evicted, and don't count.
+	MaxSubtrees int
+	// Eviction selects the subtrees which are evicted.
+	Eviction EvictionPolicy
+	// Shared makes MaxSubtrees bound the clean subtrees held by all the caches
+	// with Shared set in this process together, rather than by each cache.
+	// A cache over the bound evicts its own subtrees.
+	Shared bool
+}
+
+// optionsFromFlags returns the options set by the flags.
+func optionsFromFlags() Options {
+	eviction, err := ParseEvictionPolicy(*cacheEviction)
+	if err != nil {
+		panic(err)
+	}
+	opts := Options{MaxSubtrees: *cacheSize, Eviction: eviction}
+	switch *cacheScope {
+	case "tree":
+	case "shared":
+		opts.Shared = true
+	default:
+		panic(fmt.Errorf("unknown subtree cache scope %q", *cacheScope))
+	}
+	return opts
+}
+
+// sharedHeld counts the clean subtrees held by the caches with Shared set.
+var sharedHeld int64
+
+// evictor tracks the clean subtrees of a SubtreeCache in eviction order.
+type evictor struct {
+	max      int64
+	eviction EvictionPolicy
+	// held counts the clean subtrees which count towards max, and is shared
+	// by the caches with Shared set.
+	held *int64
+	// evict removes the subtree at a key from the cache.
+	evict func(key string)
+
+	mu    sync.Mutex
+	clean *list.List // Of keys, the next to evict first.
+	elems map[string]*list.Element
+}
+
+// newEvictor returns an evictor for opts which evicts subtrees with evict, or
+// nil if the cache is unbounded.
+func newEvictor(opts Options, evict func(key string)) *evictor {
+	if opts.MaxSubtrees <= 0 {
+		return nil
+	}
+	held := new(int64)
+	if opts.Shared {
+		held = &sharedHeld
+	}
+	return &evictor{
+		max:      int64(opts.MaxSubtrees),
+		eviction: opts.Eviction,
+		held:     held,
+		evict:    evict,
+		clean:    list.New(),
+		elems:    make(map[string]*list.Element),
+	}
+}
+
+// added records that the clean subtree at key was cached, and evicts other
+// subtrees to stay within bounds.
+func (e *evictor) added(key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.elems[key]; ok {
+		return
+	}
+	e.elems[key] = e.clean.PushBack(key)
+	evicted := 0
+	for held := atomic.AddInt64(e.held, 1); held > e.max; held = atomic.AddInt64(e.held, -1) {
+		oldest := e.clean.Front()
+		if oldest.Value.(string) == key {
+			break
+		}
+		k := e.clean.Remove(oldest).(string)
+		delete(e.elems, k)
+		e.evict(k)
+		evicted++
+	}
+	addMetric(evictedCounter, evicted)
+}
+
+// used records a use of the clean subtree at key.
+func (e *evictor) used(key string) {
+	if e.eviction != LRU {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if el, ok := e.elems[key]; ok {
+		e.clean.MoveToBack(el)
+	}
+}
+
+// dirtied records that the subtree at key has changes, so can't be evicted.
+// The subtree is stored by store with e.mu held, so that it can't be evicted
+// in between.
+func (e *evictor) dirtied(key string, store func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if el, ok := e.elems[key]; ok {
+		e.clean.Remove(el)
+		delete(e.elems, key)
+		atomic.AddInt64(e.held, -1)
+	}
+	store()
+}
+
+// release stops counting the clean subtrees of the cache.
+func (e *evictor) release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	atomic.AddInt64(e.held, -int64(e.clean.Len()))
+	e.clean.Init()
+	e.elems = make(map[string]*list.Element)
+}
+
+var (
+	metricsOnce    sync.Once
+	hitCounter     monitoring.Counter
+	missCounter    monitoring.Counter
+	evictedCounter monitoring.Counter
+)
+
+// InitMetrics initializes the subtree cache metrics using mf to create them.
+// May be called multiple times. If so, the first call is the one that counts.
+func InitMetrics(mf monitoring.MetricFactory) {
+	metricsOnce.Do(func() {
+		hitCounter = mf.NewCounter("subtree_cache_hits", "Number of subtree lookups served by subtree caches")
+		missCounter = mf.NewCounter("subtree_cache_misses", "Number of subtree lookups which subtree caches read from storage")
+		evictedCounter = mf.NewCounter("subtree_cache_evictions", "Number of clean subtrees evicted from subtree caches")
+	})
+}
+
+// addMetric adds n to c, unless the metrics aren't initialized.
+func addMetric(c monitoring.Counter, n int) {
+	if c != nil && n > 0 {
+		c.Add(float64(n))
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/trillian/merkle/maphasher"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/storage/storagepb"
+	"github.com/google/trillian/storage/tree"
+)
+
+// countingStorage returns empty subtrees, and counts the reads of each.
+type countingStorage map[string]int
+
+func (c countingStorage) getSubtree(id tree.NodeID) (*storagepb.SubtreeProto, error) {
+	c[string(id.Path[:id.PrefixLenBits/8])]++
+	return nil, nil
+}
+
+// mapNodeID returns the ID of a map leaf in the subtree named by prefix.
+func mapNodeID(prefix string) tree.NodeID {
+	return tree.NewNodeIDFromHash([]byte(fmt.Sprintf("%-10s%22s", prefix, "")))
+}
+
+func newBoundedCache(opts Options) *SubtreeCache {
+	return NewSubtreeCacheWithOptions(defaultMapStrata, populateMapSubtreeNodes(treeID, maphasher.Default), prepareMapSubtreeWrite(), opts)
+}
+
+func TestEviction(t *testing.T) {
+	for _, tc := range []struct {
+		desc  string
+		opts  Options
+		reads []string
+		// wantReads is how often each subtree is read from storage.
+		wantReads map[string]int
+	}{
+		{
+			desc:      "unbounded",
+			reads:     []string{"a", "b", "a", "c", "a", "b"},
+			wantReads: map[string]int{"a": 1, "b": 1, "c": 1},
+		},
+		{
+			desc:      "lru",
+			opts:      Options{MaxSubtrees: 2, Eviction: LRU},
+			reads:     []string{"a", "b", "a", "c", "a", "b"},
+			wantReads: map[string]int{"a": 1, "b": 2, "c": 1},
+		},
+		{
+			desc:      "fifo",
+			opts:      Options{MaxSubtrees: 2, Eviction: FIFO},
+			reads:     []string{"a", "b", "a", "c", "a", "b"},
+			wantReads: map[string]int{"a": 2, "b": 2, "c": 1},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := newBoundedCache(tc.opts)
+			defer c.Release()
+			s := countingStorage{}
+			for _, r := range tc.reads {
+				if _, err := c.getNodeHash(mapNodeID(r), s.getSubtree); err != nil {
+					t.Fatalf("getNodeHash(%s): %v", r, err)
+				}
+			}
+			for prefix, want := range tc.wantReads {
+				if got := s[fmt.Sprintf("%-10s", prefix)]; got != want {
+					t.Errorf("subtree %s read %d times, want %d", prefix, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEvictionKeepsDirtySubtrees(t *testing.T) {
+	ctx := context.Background()
+	c := newBoundedCache(Options{MaxSubtrees: 1})
+	defer c.Release()
+	s := countingStorage{}
+
+	if err := c.SetNodeHash(mapNodeID("a"), []byte("hash"), s.getSubtree); err != nil {
+		t.Fatalf("SetNodeHash(a): %v", err)
+	}
+	for _, r := range []string{"b", "c", "d"} {
+		if _, err := c.getNodeHash(mapNodeID(r), s.getSubtree); err != nil {
+			t.Fatalf("getNodeHash(%s): %v", r, err)
+		}
+	}
+
+	var written []string
+	if err := c.Flush(ctx, func(_ context.Context, sts []*storagepb.SubtreeProto) error {
+		for _, st := range sts {
+			written = append(written, string(st.Prefix))
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Flush(): %v", err)
+	}
+	if want := fmt.Sprintf("%-10s", "a"); len(written) != 1 || written[0] != want {
+		t.Errorf("Flush() wrote %q, want [%q]", written, want)
+	}
+}
+
+func TestSharedEviction(t *testing.T) {
+	opts := Options{MaxSubtrees: 2, Shared: true}
+	c1, c2 := newBoundedCache(opts), newBoundedCache(opts)
+	s1, s2 := countingStorage{}, countingStorage{}
+	read := func(c *SubtreeCache, s countingStorage, prefix string) {
+		t.Helper()
+		if _, err := c.getNodeHash(mapNodeID(prefix), s.getSubtree); err != nil {
+			t.Fatalf("getNodeHash(%s): %v", prefix, err)
+		}
+	}
+
+	read(c1, s1, "a")
+	read(c1, s1, "b")
+	// The caches are full together, but c2 keeps the subtree it just read.
+	read(c2, s2, "c")
+	read(c2, s2, "d")
+	read(c2, s2, "c")
+	if got, want := s2[fmt.Sprintf("%-10s", "c")], 2; got != want {
+		t.Errorf("subtree c read %d times, want %d", got, want)
+	}
+
+	// Once c1 is released, c2 has room for two subtrees.
+	c1.Release()
+	read(c2, s2, "d")
+	read(c2, s2, "c")
+	if got, want := s2[fmt.Sprintf("%-10s", "c")], 2; got != want {
+		t.Errorf("subtree c read %d times after release, want %d", got, want)
+	}
+	c2.Release()
+	if got := sharedHeld; got != 0 {
+		t.Errorf("sharedHeld = %d after release, want 0", got)
+	}
+}
+
+func TestEvictionMetrics(t *testing.T) {
+	InitMetrics(monitoring.InertMetricFactory{})
+	hits, misses, evictions := hitCounter.Value(), missCounter.Value(), evictedCounter.Value()
+
+	c := newBoundedCache(Options{MaxSubtrees: 1})
+	defer c.Release()
+	s := countingStorage{}
+	get := func(ids ...tree.NodeID) {
+		t.Helper()
+		if _, err := c.GetNodes(ids, func(ids []tree.NodeID) ([]*storagepb.SubtreeProto, error) {
+			for _, id := range ids {
+				s.getSubtree(id)
+			}
+			return nil, nil
+		}); err != nil {
+			t.Fatalf("GetNodes(): %v", err)
+		}
+	}
+	get(mapNodeID("a"))
+	get(mapNodeID("a"))
+	get(mapNodeID("b"))
+
+	if got, want := hitCounter.Value()-hits, 1.0; got != want {
+		t.Errorf("subtree_cache_hits increased by %v, want %v", got, want)
+	}
+	if got, want := missCounter.Value()-misses, 2.0; got != want {
+		t.Errorf("subtree_cache_misses increased by %v, want %v", got, want)
+	}
+	if got, want := evictedCounter.Value()-evictions, 1.0; got != want {
+		t.Errorf("subtree_cache_evictions increased by %v, want %v", got, want)
+	}
+}
+
+func TestParseEvictionPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		want    EvictionPolicy
+		wantErr bool
+	}{
+		{name: "lru", want: LRU},
+		{name: "fifo", want: FIFO},
+		{name: "random", wantErr: true},
+	} {
+		got, err := ParseEvictionPolicy(tc.name)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("ParseEvictionPolicy(%q): %v, want err %v", tc.name, err, tc.wantErr)
+		} else if got != tc.want {
+			t.Errorf("ParseEvictionPolicy(%q): %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -32,6 +32,23 @@ func NewMapSubtreeCache(mapStrata []int, treeID int64, hasher hashers.MapHasher)
 	return NewSubtreeCache(mapStrata, populateMapSubtreeNodes(treeID, hasher), prepareMapSubtreeWrite())
 }
 
+// MapStrata returns the strata depths for a map of the given height, which
+// are the leading strata of mapStrata, the last one cut short at the height.
+func MapStrata(mapStrata []int, height int) []int {
+	var strata []int
+	for _, depth := range mapStrata {
+		if height <= 0 {
+			break
+		}
+		if depth > height {
+			depth = height
+		}
+		strata = append(strata, depth)
+		height -= depth
+	}
+	return strata
+}
+
 // populateMapSubtreeNodes re-creates Map subtree's InternalNodes from the
 // subtree Leaves map.
 //
@@ -71,34 +71,56 @@ type SubtreeCache struct {
 	populateConcurrency int
 	// prepare is used for preparation work when subtrees are about to be written to storage.
 	prepare tree.PrepareSubtreeWriteFunc
+	// evictor evicts clean subtrees when the cache is full, and is nil if the
+	// cache is unbounded.
+	evictor *evictor
 }
 
 // NewSubtreeCache returns a newly intialised cache ready for use.
 // populateSubtree is a function which knows how to populate a subtree's
 // internal nodes given its leaves, and will be called for each subtree loaded
-// from storage.
+// from storage. The capacity of the cache is set by the subtree_cache flags.
 func NewSubtreeCache(strataDepths []int, populateSubtree tree.PopulateSubtreeFunc, prepareSubtreeWrite tree.PrepareSubtreeWriteFunc) *SubtreeCache {
+	return NewSubtreeCacheWithOptions(strataDepths, populateSubtree, prepareSubtreeWrite, optionsFromFlags())
+}
+
+// NewSubtreeCacheWithOptions returns a newly intialised cache ready for use,
+// whose capacity is set by opts. Caches bounded by opts should be released
+// with Release once they're no longer used.
+func NewSubtreeCacheWithOptions(strataDepths []int, populateSubtree tree.PopulateSubtreeFunc, prepareSubtreeWrite tree.PrepareSubtreeWriteFunc, opts Options) *SubtreeCache {
 	// TODO(al): pass this in
 	maxTreeDepth := maxSupportedTreeDepth
 	glog.V(1).Infof("Creating new subtree cache maxDepth=%d strataDepths=%v", maxTreeDepth, strataDepths)
 	layout := tree.NewLayout(strataDepths)
 
-	// TODO(al): This needs to be passed in, particularly for Map use cases where
-	// we need to know it matches the number of bits in the chosen hash function.
-	if got, want := layout.Height, maxTreeDepth; got != want {
-		panic(fmt.Errorf("strata indicate tree of depth %d, but expected %d", got, want))
+	// Maps with indices shorter than their hashes have shallower strata, see
+	// MapStrata.
+	if got, max := layout.Height, maxTreeDepth; got <= 0 || got > max {
+		panic(fmt.Errorf("strata indicate tree of depth %d, but expected 1 to %d", got, max))
 	}
 
 	if *populateConcurrency <= 0 {
 		panic(fmt.Errorf("populate_subtree_concurrency must be set to >= 1"))
 	}
 
-	return &SubtreeCache{
+	s := &SubtreeCache{
 		layout:              layout,
 		populate:            populateSubtree,
 		populateConcurrency: *populateConcurrency,
 		prepare:             prepareSubtreeWrite,
 	}
+	s.evictor = newEvictor(opts, func(key string) { s.subtrees.Delete(key) })
+	return s
+}
+
+// Release stops counting the clean subtrees of the cache towards a shared
+// bound, once the cache is no longer used. It is a no-op for nil or unbounded
+// caches.
+func (s *SubtreeCache) Release() {
+	if s == nil || s.evictor == nil {
+		return
+	}
+	s.evictor.release()
 }
 
 // preload calculates the set of subtrees required to know the hashes of the
@@ -107,17 +129,23 @@ func NewSubtreeCache(strataDepths []int, populateSubtree tree.PopulateSubtreeFun
 func (s *SubtreeCache) preload(ids []tree.NodeID, getSubtrees GetSubtreesFunc) error {
 	// Figure out the set of subtrees we need.
 	want := make(map[string]tree.TileID)
+	seen := make(map[string]bool)
 	for _, id := range ids {
 		subID := s.layout.GetTileID(id)
 		subKey := subID.AsKey()
-		if _, ok := want[subKey]; ok {
+		if seen[subKey] {
 			// No need to check s.subtrees map twice.
 			continue
 		}
+		seen[subKey] = true
 		if _, ok := s.subtrees.Load(subKey); !ok {
 			want[subKey] = subID
+		} else {
+			s.used(subKey)
 		}
 	}
+	addMetric(hitCounter, len(seen)-len(want))
+	addMetric(missCounter, len(want))
 	// Note: At this point multiple parallel preload invocations can happen to
 	// getSubtrees with overlapping sets of IDs. It's okay because we collapse
 	// results further below.
@@ -193,10 +221,27 @@ func (s *SubtreeCache) cacheSubtree(t *storagepb.SubtreeProto) error {
 		} else if !proto.Equal(t, subtree) {
 			return fmt.Errorf("at %x: subtree mismatch", t.Prefix)
 		}
+		return nil
 	}
+	s.added(string(t.Prefix))
 	return nil
 }
 
+// added records that the clean subtree at prefixKey was read into the cache,
+// evicting other subtrees if the cache is full.
+func (s *SubtreeCache) added(prefixKey string) {
+	if s.evictor != nil {
+		s.evictor.added(prefixKey)
+	}
+}
+
+// used records a use of the subtree at prefixKey.
+func (s *SubtreeCache) used(prefixKey string) {
+	if s.evictor != nil {
+		s.evictor.used(prefixKey)
+	}
+}
+
 // GetNodes returns the requested nodes, calling the getSubtrees function if
 // they are not already cached.
 func (s *SubtreeCache) GetNodes(ids []tree.NodeID, getSubtrees GetSubtreesFunc) ([]tree.Node, error) {
@@ -216,8 +261,12 @@ func (s *SubtreeCache) GetNodes(ids []tree.NodeID, getSubtrees GetSubtreesFunc)
 			id,
 			func(n tree.NodeID) (*storagepb.SubtreeProto, error) {
 				// This should never happen - we should've already read all the data we
-				// need above, in Preload()
-				glog.Warningf("Unexpectedly reading from within getNodeHash(): %s", n.String())
+				// need above, in Preload(), unless it has since been evicted.
+				if s.evictor == nil {
+					glog.Warningf("Unexpectedly reading from within getNodeHash(): %s", n.String())
+				} else {
+					glog.V(1).Infof("Reading evicted subtree from within getNodeHash(): %s", n.String())
+				}
 				ret, err := getSubtrees([]tree.NodeID{n})
 				if err != nil || len(ret) == 0 {
 					return nil, err
@@ -262,6 +311,37 @@ func (s *SubtreeCache) prefixIsDirty(prefixKey string) bool {
 	return found
 }
 
+// getSubtree returns the subtree subID, which node id is in, from the cache,
+// or reads it with getSubtree and caches it if it isn't there.
+func (s *SubtreeCache) getSubtree(id tree.NodeID, subID tree.TileID, getSubtree GetSubtreeFunc) (*storagepb.SubtreeProto, error) {
+	subKey := subID.AsKey()
+	if c := s.getCachedSubtree(subKey); c != nil {
+		s.used(subKey)
+		return c, nil
+	}
+	glog.V(2).Infof("Cache miss for %x so we'll try to fetch from storage", subKey)
+	addMetric(missCounter, 1)
+	// Cache miss, so we'll try to fetch from storage.
+	c, err := getSubtree(subID.Root)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		c = s.newEmptySubtree(subID)
+	} else {
+		if err := s.populate(c); err != nil {
+			return nil, err
+		}
+	}
+	if c.Prefix == nil {
+		panic(fmt.Errorf("getNodeHash nil prefix on %v for id %v with px %x", c, id.String(), subKey))
+	}
+
+	s.subtrees.Store(subKey, c)
+	s.added(subKey)
+	return c, nil
+}
+
 // getNodeHash returns a single node hash from the cache.
 func (s *SubtreeCache) getNodeHash(id tree.NodeID, getSubtree GetSubtreeFunc) ([]byte, error) {
 	if glog.V(3) {
@@ -270,26 +350,9 @@ func (s *SubtreeCache) getNodeHash(id tree.NodeID, getSubtree GetSubtreeFunc) ([
 
 	subID, sx := s.layout.Split(id)
 	subKey := subID.AsKey()
-	c := s.getCachedSubtree(subKey)
-	if c == nil {
-		glog.V(2).Infof("Cache miss for %x so we'll try to fetch from storage", subKey)
-		// Cache miss, so we'll try to fetch from storage.
-		var err error
-		if c, err = getSubtree(subID.Root); err != nil {
-			return nil, err
-		}
-		if c == nil {
-			c = s.newEmptySubtree(subID)
-		} else {
-			if err := s.populate(c); err != nil {
-				return nil, err
-			}
-		}
-		if c.Prefix == nil {
-			panic(fmt.Errorf("getNodeHash nil prefix on %v for id %v with px %x", c, id.String(), subKey))
-		}
-
-		s.subtrees.Store(subKey, c)
+	c, err := s.getSubtree(id, subID, getSubtree)
+	if err != nil {
+		return nil, err
 	}
 
 	// finally look for the particular node within the subtree so we can return
@@ -330,20 +393,15 @@ func (s *SubtreeCache) SetNodeHash(id tree.NodeID, h []byte, getSubtree GetSubtr
 
 	subID, sx := s.layout.Split(id)
 	subKey := subID.AsKey()
-	c := s.getCachedSubtree(subKey)
-	if c == nil {
-		// TODO(al): This is ok, IFF *all* leaves in the subtree are being set,
-		// verify that this is the case when it happens.
-		// For now, just read from storage if we don't already have it.
-		glog.V(1).Infof("attempting to write to unread subtree for %v, reading now", id.String())
-		if _, err := s.getNodeHash(id, getSubtree); err != nil {
-			return err
-		}
-		// There must be a subtree present in the cache now, even if storage didn't have anything for us.
-		c = s.getCachedSubtree(subKey)
-		if c == nil {
-			return fmt.Errorf("internal error, subtree cache for %v is nil after a read attempt", id.String())
-		}
+	if glog.V(1) && s.getCachedSubtree(subKey) == nil {
+		glog.Infof("attempting to write to unread subtree for %v, reading now", id.String())
+	}
+	// TODO(al): Reading is unnecessary IFF *all* leaves in the subtree are
+	// being set, verify that this is the case when it happens.
+	// For now, just read from storage if we don't already have it.
+	c, err := s.getSubtree(id, subID, getSubtree)
+	if err != nil {
+		return err
 	}
 	if c.Prefix == nil {
 		return fmt.Errorf("nil prefix for %v (key %v)", id.String(), subKey)
@@ -368,7 +426,15 @@ func (s *SubtreeCache) SetNodeHash(id tree.NodeID, h []byte, getSubtree GetSubtr
 		}
 		c.InternalNodes[sfxKey] = h
 	}
-	s.dirtyPrefixes.Store(subKey, nil)
+	if s.evictor == nil {
+		s.dirtyPrefixes.Store(subKey, nil)
+	} else {
+		// c may have been evicted while it was clean, so store it again.
+		s.evictor.dirtied(subKey, func() {
+			s.dirtyPrefixes.Store(subKey, nil)
+			s.subtrees.Store(subKey, c)
+		})
+	}
 	if glog.V(3) {
 		b, err := base64.StdEncoding.DecodeString(sfxKey)
 		if err != nil {
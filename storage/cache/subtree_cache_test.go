@@ -19,6 +19,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/google/trillian/merkle/compact"
@@ -381,3 +382,19 @@ func TestIdempotentWrites(t *testing.T) {
 		}
 	}
 }
+
+func TestMapStrata(t *testing.T) {
+	for _, tc := range []struct {
+		height int
+		want   []int
+	}{
+		{height: 256, want: defaultMapStrata},
+		{height: 160, want: []int{8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 80}},
+		{height: 80, want: []int{8, 8, 8, 8, 8, 8, 8, 8, 8, 8}},
+		{height: 12, want: []int{8, 4}},
+	} {
+		if got := MapStrata(defaultMapStrata, tc.height); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("MapStrata(%d): %v, want %v", tc.height, got, tc.want)
+		}
+	}
+}
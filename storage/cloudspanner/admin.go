@@ -25,6 +25,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/crypto/sigpb"
@@ -45,8 +46,10 @@ var (
 	errRollback = errors.New("rollback")
 
 	treeStateMap = map[trillian.TreeState]spannerpb.TreeState{
-		trillian.TreeState_ACTIVE: spannerpb.TreeState_ACTIVE,
-		trillian.TreeState_FROZEN: spannerpb.TreeState_FROZEN,
+		trillian.TreeState_ACTIVE:      spannerpb.TreeState_ACTIVE,
+		trillian.TreeState_FROZEN:      spannerpb.TreeState_FROZEN,
+		trillian.TreeState_QUARANTINED: spannerpb.TreeState_QUARANTINED,
+		trillian.TreeState_PAUSED:      spannerpb.TreeState_PAUSED,
 	}
 	treeTypeMap = map[trillian.TreeType]spannerpb.TreeType{
 		trillian.TreeType_LOG: spannerpb.TreeType_LOG,
@@ -352,7 +355,8 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 		return nil, err
 	}
 
-	id, err := storage.NewTreeID()
+	// Inserting a taken ID fails with codes.AlreadyExists.
+	id, err := storage.TreeIDForCreation(tree)
 	if err != nil {
 		return nil, err
 	}
@@ -440,6 +444,23 @@ func newTreeInfo(tree *trillian.Tree, treeID int64, now time.Time) (*spannerpb.T
 		PrivateKey:            tree.GetPrivateKey(),
 		PublicKeyDer:          tree.GetPublicKey().GetDer(),
 		MaxRootDurationMillis: int64(maxRootDuration / time.Millisecond),
+		MapIndexBits:          tree.MapIndexBits,
+		DuplicateLeafPolicy:   int32(tree.DuplicateLeafPolicy),
+		AdditionalPrivateKeys: tree.AdditionalPrivateKeys,
+		SignatureThreshold:    tree.SignatureThreshold,
+		Labels:                tree.Labels,
+		ReadTokensPerSecond:   tree.Quota.GetReadTokensPerSecond(),
+		WriteTokensPerSecond:  tree.Quota.GetWriteTokensPerSecond(),
+		Owner:                 tree.Owner,
+	}
+	if err := setDeleteRetention(info, tree.DeleteRetention); err != nil {
+		return nil, err
+	}
+	for _, key := range tree.AdditionalPublicKeys {
+		info.AdditionalPublicKeyDers = append(info.AdditionalPublicKeyDers, key.GetDer())
+	}
+	if err := setRevisionRetention(info, tree.RevisionRetentionPolicy); err != nil {
+		return nil, err
 	}
 
 	switch tree.TreeType {
@@ -535,6 +556,21 @@ func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(
 	info.UpdateTimeNanos = now.UnixNano()
 	info.MaxRootDurationMillis = int64(maxRootDuration / time.Millisecond)
 	info.PrivateKey = tree.PrivateKey
+	info.PublicKeyDer = tree.GetPublicKey().GetDer()
+	info.DuplicateLeafPolicy = int32(tree.DuplicateLeafPolicy)
+	info.Labels = tree.Labels
+	info.ReadTokensPerSecond = tree.Quota.GetReadTokensPerSecond()
+	info.WriteTokensPerSecond = tree.Quota.GetWriteTokensPerSecond()
+	info.Owner = tree.Owner
+	if err := setDeleteRetention(info, tree.DeleteRetention); err != nil {
+		return nil, err
+	}
+	if err := setKeyRotation(info, tree.KeyRotation); err != nil {
+		return nil, err
+	}
+	if err := setRevisionRetention(info, tree.RevisionRetentionPolicy); err != nil {
+		return nil, err
+	}
 
 	if err := t.updateTreeInfo(ctx, info); err != nil {
 		return nil, err
@@ -615,11 +651,15 @@ func (t *adminTX) HardDeleteTree(ctx context.Context, treeID int64) error {
 	return stx.BufferWrite([]*spanner.Mutation{
 		spanner.Delete("TreeRoots", spanner.Key{info.TreeId}),
 		spanner.Delete("TreeHeads", spanner.Key{info.TreeId}.AsPrefix()),
+		spanner.Delete("LogRootSignatures", spanner.Key{info.TreeId}.AsPrefix()),
+		spanner.Delete("RecoveryMarkers", spanner.Key{info.TreeId}.AsPrefix()),
 		spanner.Delete("SubtreeData", spanner.Key{info.TreeId}.AsPrefix()),
 		spanner.Delete("LeafData", spanner.Key{info.TreeId}.AsPrefix()),
 		spanner.Delete("SequencedLeafData", spanner.Key{info.TreeId}.AsPrefix()),
 		spanner.Delete("Unsequenced", spanner.Key{info.TreeId}.AsPrefix()),
 		spanner.Delete("MapLeafData", spanner.Key{info.TreeId}.AsPrefix()),
+		spanner.Delete("MapIdempotencyTokens", spanner.Key{info.TreeId}.AsPrefix()),
+		spanner.Delete("MapRootSignatures", spanner.Key{info.TreeId}.AsPrefix()),
 	})
 }
 
@@ -691,6 +731,27 @@ func toTrillianTree(info *spannerpb.TreeInfo) (*trillian.Tree, error) {
 		return nil, status.Errorf(codes.Internal, "unexpected SignatureAlgorithm: %s", info.SignatureAlgorithm)
 	}
 	tree.SignatureAlgorithm = sa
+	tree.MapIndexBits = info.MapIndexBits
+	tree.DuplicateLeafPolicy = trillian.DuplicateLeafPolicy(info.DuplicateLeafPolicy)
+	tree.AdditionalPrivateKeys = info.AdditionalPrivateKeys
+	for _, der := range info.AdditionalPublicKeyDers {
+		tree.AdditionalPublicKeys = append(tree.AdditionalPublicKeys, &keyspb.PublicKey{Der: der})
+	}
+	tree.SignatureThreshold = info.SignatureThreshold
+	tree.Labels = info.Labels
+	if info.DeleteRetentionMillis != 0 {
+		tree.DeleteRetention = ptypes.DurationProto(time.Duration(info.DeleteRetentionMillis) * time.Millisecond)
+	}
+	if len(info.KeyRotation) > 0 {
+		tree.KeyRotation = &trillian.KeyRotation{}
+		if err := proto.Unmarshal(info.KeyRotation, tree.KeyRotation); err != nil {
+			return nil, status.Errorf(codes.Internal, "could not unmarshal KeyRotation: %v", err)
+		}
+	}
+	if info.ReadTokensPerSecond != 0 || info.WriteTokensPerSecond != 0 {
+		tree.Quota = &trillian.TreeQuota{ReadTokensPerSecond: info.ReadTokensPerSecond, WriteTokensPerSecond: info.WriteTokensPerSecond}
+	}
+	tree.Owner = info.Owner
 
 	var config proto.Message
 	switch info.TreeType {
@@ -705,6 +766,13 @@ func toTrillianTree(info *spannerpb.TreeInfo) (*trillian.Tree, error) {
 	}
 	tree.StorageSettings = settings
 
+	if info.RetainRevisions != 0 || info.RetainDurationMillis != 0 {
+		tree.RevisionRetentionPolicy = &trillian.RevisionRetentionPolicy{KeepRevisions: info.RetainRevisions}
+		if info.RetainDurationMillis != 0 {
+			tree.RevisionRetentionPolicy.KeepDuration = ptypes.DurationProto(time.Duration(info.RetainDurationMillis) * time.Millisecond)
+		}
+	}
+
 	if info.Deleted {
 		tree.Deleted = info.Deleted
 	}
@@ -719,6 +787,55 @@ func toTrillianTree(info *spannerpb.TreeInfo) (*trillian.Tree, error) {
 	return tree, nil
 }
 
+// setRevisionRetention stores the given revision retention policy in info. A
+// nil policy means that all revisions are kept.
+func setRevisionRetention(info *spannerpb.TreeInfo, policy *trillian.RevisionRetentionPolicy) error {
+	info.RetainRevisions, info.RetainDurationMillis = 0, 0
+	if policy == nil {
+		return nil
+	}
+	var keepDuration time.Duration
+	if policy.KeepDuration != nil {
+		var err error
+		if keepDuration, err = ptypes.Duration(policy.KeepDuration); err != nil {
+			return status.Errorf(codes.InvalidArgument, "malformed KeepDuration: %v", err)
+		}
+	}
+	info.RetainRevisions = policy.KeepRevisions
+	info.RetainDurationMillis = int64(keepDuration / time.Millisecond)
+	return nil
+}
+
+// setDeleteRetention stores the given delete retention in info. A nil
+// retention means the server's default.
+func setDeleteRetention(info *spannerpb.TreeInfo, retention *duration.Duration) error {
+	info.DeleteRetentionMillis = 0
+	if retention == nil {
+		return nil
+	}
+	d, err := ptypes.Duration(retention)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "malformed DeleteRetention: %v", err)
+	}
+	info.DeleteRetentionMillis = int64(d / time.Millisecond)
+	return nil
+}
+
+// setKeyRotation stores the given key rotation in info. A nil rotation means
+// the tree isn't rotating its key.
+func setKeyRotation(info *spannerpb.TreeInfo, rotation *trillian.KeyRotation) error {
+	info.KeyRotation = nil
+	if rotation == nil {
+		return nil
+	}
+	b, err := proto.Marshal(rotation)
+	if err != nil {
+		return status.Errorf(codes.Internal, "could not marshal KeyRotation: %v", err)
+	}
+	info.KeyRotation = b
+	return nil
+}
+
 // unmarshalSettings returns the message obtained from tree.StorageSettings.
 // If tree.StorageSettings is nil no unmarshaling will be attempted; instead the method will return
 // (nil, nil).
@@ -17,6 +17,7 @@ package cloudspanner
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"math/rand"
 	"sort"
@@ -27,6 +28,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/merkle/hashers"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cache"
@@ -41,6 +43,8 @@ const (
 	seqDataByMerkleHashIdx = "SequenceByMerkleHash"
 	seqDataTbl             = "SequencedLeafData"
 	unseqTable             = "Unsequenced"
+	logRootSignatureTbl    = "LogRootSignatures"
+	colTreeRevision        = "TreeRevision"
 
 	unsequencedCountSQL = "SELECT Unsequenced.TreeID, COUNT(1) FROM Unsequenced GROUP BY TreeID"
 
@@ -324,27 +328,129 @@ func (tx *logTX) LatestSignedLogRoot(ctx context.Context) (*trillian.SignedLogRo
 		return nil, fmt.Errorf("inconsistency: currentSTH.TreeRevision+1 (%d) != writeRev (%d)", got, want)
 	}
 
-	// Put logRoot back together. Fortunately LogRoot has a deterministic serialization.
+	// We already read the latest root as part of starting the transaction (in
+	// order to calculate the writeRevision), so we just return that data here:
+	return sthToSLR(tx.treeID, currentSTH)
+}
+
+// sthToSLR puts a SignedLogRoot of the log with treeID back together from its
+// TreeHead.
+func sthToSLR(treeID int64, sth *spannerpb.TreeHead) (*trillian.SignedLogRoot, error) {
+	// Fortunately LogRoot has a deterministic serialization.
 	logRoot, err := (&types.LogRootV1{
-		TimestampNanos: uint64(currentSTH.TsNanos),
-		RootHash:       currentSTH.RootHash,
-		TreeSize:       uint64(currentSTH.TreeSize),
-		Revision:       uint64(currentSTH.TreeRevision),
-		Metadata:       currentSTH.Metadata,
+		TimestampNanos: uint64(sth.TsNanos),
+		RootHash:       sth.RootHash,
+		TreeSize:       uint64(sth.TreeSize),
+		Revision:       uint64(sth.TreeRevision),
+		Metadata:       sth.Metadata,
 	}).MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
 
-	// We already read the latest root as part of starting the transaction (in
-	// order to calculate the writeRevision), so we just return that data here:
 	return &trillian.SignedLogRoot{
-		KeyHint:          types.SerializeKeyHint(tx.treeID),
-		LogRoot:          logRoot,
-		LogRootSignature: currentSTH.Signature,
+		KeyHint:              types.SerializeKeyHint(treeID),
+		LogRoot:              logRoot,
+		LogRootSignature:     sth.Signature,
+		AdditionalSignatures: sth.AdditionalSignatures,
 	}, nil
 }
 
+// GetSignedLogRoot returns the SignedLogRoot at revision.
+func (tx *logTX) GetSignedLogRoot(ctx context.Context, revision int64) (*trillian.SignedLogRoot, error) {
+	query := spanner.NewStatement(
+		`SELECT t.TreeID, t.TimestampNanos, t.TreeSize, t.RootHash, t.RootSignature, t.TreeRevision, t.TreeMetadata, t.AdditionalSignatures FROM TreeHeads t
+				WHERE t.TreeID = @tree_id
+				AND t.TreeRevision = @tree_rev
+				LIMIT 1`)
+	query.Params["tree_id"] = tx.treeID
+	query.Params["tree_rev"] = revision
+
+	th, err := tx.queryTreeHead(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if th == nil {
+		return nil, status.Errorf(codes.NotFound, "log root %v not found", revision)
+	}
+	return sthToSLR(tx.treeID, th)
+}
+
+// GetSignedLogRootByHash returns the earliest SignedLogRoot with rootHash.
+func (tx *logTX) GetSignedLogRootByHash(ctx context.Context, rootHash []byte) (*trillian.SignedLogRoot, error) {
+	query := spanner.NewStatement(
+		`SELECT t.TreeID, t.TimestampNanos, t.TreeSize, t.RootHash, t.RootSignature, t.TreeRevision, t.TreeMetadata, t.AdditionalSignatures FROM TreeHeads@{FORCE_INDEX=TreeHeadsByRootHash} t
+				WHERE t.TreeID = @tree_id
+				AND t.RootHash = @root_hash
+				ORDER BY t.TreeRevision
+				LIMIT 1`)
+	query.Params["tree_id"] = tx.treeID
+	query.Params["root_hash"] = rootHash
+
+	th, err := tx.queryTreeHead(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if th == nil {
+		return nil, status.Errorf(codes.NotFound, "log root with hash %x not found", rootHash)
+	}
+	return sthToSLR(tx.treeID, th)
+}
+
+// queryTreeHead returns the TreeHead returned by query, or nil if there is
+// none.
+func (tx *logTX) queryTreeHead(ctx context.Context, query spanner.Statement) (*spannerpb.TreeHead, error) {
+	var th *spannerpb.TreeHead
+	rows := tx.stx.Query(ctx, tx.tag(ctx, query))
+	err := rows.Do(func(r *spanner.Row) error {
+		tth := &spannerpb.TreeHead{}
+		if err := r.Columns(&tth.TreeId, &tth.TsNanos, &tth.TreeSize, &tth.RootHash, &tth.Signature, &tth.TreeRevision, &tth.Metadata, &tth.AdditionalSignatures); err != nil {
+			return err
+		}
+
+		th = tth
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return th, nil
+}
+
+// GetLogRootSignatures returns the witness signatures over the root at
+// revision.
+func (tx *logTX) GetLogRootSignatures(ctx context.Context, revision int64) ([]*trillian.LogRootSignature, error) {
+	var sigs []*trillian.LogRootSignature
+	rows := tx.stx.Read(ctx, logRootSignatureTbl, spanner.Key{tx.treeID, revision}.AsPrefix(), []string{colPublicKey, colSignature})
+	err := rows.Do(func(r *spanner.Row) error {
+		var der, sig []byte
+		if err := r.Columns(&der, &sig); err != nil {
+			return err
+		}
+		sigs = append(sigs, &trillian.LogRootSignature{PublicKey: &keyspb.PublicKey{Der: der}, Signature: sig})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sigs, nil
+}
+
+// StoreLogRootSignature stores the witness signature over the root at
+// revision, replacing any signature with the same public key.
+func (tx *logTX) StoreLogRootSignature(ctx context.Context, revision int64, sig *trillian.LogRootSignature) error {
+	stx, ok := tx.stx.(*spanner.ReadWriteTransaction)
+	if !ok {
+		return ErrWrongTXType
+	}
+	der := sig.GetPublicKey().GetDer()
+	keyHash := sha256.Sum256(der)
+	m := spanner.InsertOrUpdate(logRootSignatureTbl,
+		[]string{colTreeID, colTreeRevision, colKeyHash, colPublicKey, colSignature},
+		[]interface{}{tx.treeID, revision, keyHash[:], der, sig.GetSignature()})
+	return stx.BufferWrite([]*spanner.Mutation{m})
+}
+
 // StoreSignedLogRoot stores the provided root.
 // This method will return an error if the caller attempts to store more than
 // one root per log for a given tree size.
@@ -372,6 +478,7 @@ func (tx *logTX) StoreSignedLogRoot(ctx context.Context, root *trillian.SignedLo
 			"RootSignature",
 			"TreeRevision",
 			"TreeMetadata",
+			"AdditionalSignatures",
 		},
 		[]interface{}{
 			int64(tx.treeID),
@@ -381,13 +488,14 @@ func (tx *logTX) StoreSignedLogRoot(ctx context.Context, root *trillian.SignedLo
 			root.LogRootSignature,
 			writeRev,
 			logRoot.Metadata,
+			root.AdditionalSignatures,
 		})
 
 	stx, ok := tx.stx.(*spanner.ReadWriteTransaction)
 	if !ok {
 		return ErrWrongTXType
 	}
-	return stx.BufferWrite([]*spanner.Mutation{m})
+	return stx.BufferWrite([]*spanner.Mutation{m, tx.recoveryMarkerMutation(writeRev)})
 }
 
 func readLeaves(ctx context.Context, stx *spanner.ReadOnlyTransaction, logID int64, ids [][]byte, f func(*trillian.LogLeaf)) error {
@@ -475,7 +583,7 @@ func (tx *logTX) DequeueLeaves(ctx context.Context, limit int, cutoff time.Time)
 	stmt.Params["max_num"] = limit
 
 	ret := make([]*trillian.LogLeaf, 0, limit)
-	rows := tx.stx.Query(ctx, stmt)
+	rows := tx.stx.Query(ctx, tx.tag(ctx, stmt))
 	if err := rows.Do(func(r *spanner.Row) error {
 		var l trillian.LogLeaf
 		var qe QueuedEntry
@@ -683,7 +791,7 @@ WHERE sd.TreeID = @tree_id and sd.SequenceNumber IN UNNEST(@seq_nums)`)
 	stmt.Params["tree_id"] = tx.treeID
 	stmt.Params["seq_nums"] = indices
 
-	rows := tx.stx.Query(ctx, stmt)
+	rows := tx.stx.Query(ctx, tx.tag(ctx, stmt))
 	if err := rows.Do(leaves.addFullRow); err != nil {
 		return nil, err
 	}
@@ -749,7 +857,7 @@ WHERE sd.TreeID = @tree_id AND sd.SequenceNumber >= @start AND sd.SequenceNumber
 	// Results need to be returned in order [start, end), all of which should be
 	// available (as we restricted xend/count to TreeSize).
 	leaves := make(leafmap)
-	rows := tx.stx.Query(ctx, stmt)
+	rows := tx.stx.Query(ctx, tx.tag(ctx, stmt))
 	if err := rows.Do(leaves.addFullRow); err != nil {
 		return nil, err
 	}
@@ -863,7 +971,7 @@ func (tx *readOnlyLogTX) GetActiveLogIDs(ctx context.Context) ([]int64, error) {
 	ids := []int64{}
 	// We have to use SQL as Read() doesn't work against an index.
 	stmt := spanner.NewStatement(getActiveLogIDsSQL)
-	rows := tx.stx.Query(ctx, stmt)
+	rows := tx.stx.Query(ctx, tx.ls.ts.tag(ctx, 0, stmt))
 	if err := rows.Do(func(r *spanner.Row) error {
 		var id int64
 		if err := r.Columns(&id); err != nil {
@@ -881,7 +989,7 @@ func (tx *readOnlyLogTX) GetActiveLogIDs(ctx context.Context) ([]int64, error) {
 func (tx *readOnlyLogTX) GetUnsequencedCounts(ctx context.Context) (storage.CountByLogID, error) {
 	stmt := spanner.NewStatement(unsequencedCountSQL)
 	ret := make(storage.CountByLogID)
-	rows := tx.stx.Query(ctx, stmt)
+	rows := tx.stx.Query(ctx, tx.ls.ts.tag(ctx, 0, stmt))
 	if err := rows.Do(func(r *spanner.Row) error {
 		var id, c int64
 		if err := r.Columns(&id, &c); err != nil {
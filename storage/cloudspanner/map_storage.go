@@ -17,13 +17,21 @@ package cloudspanner
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 
 	"cloud.google.com/go/spanner"
 	"github.com/golang/glog"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cache"
 	"github.com/google/trillian/storage/cloudspanner/spannerpb"
@@ -33,11 +41,22 @@ import (
 )
 
 const (
-	mapLeafDataTbl = "MapLeafData"
+	mapLeafDataTbl         = "MapLeafData"
+	mapIdempotencyTokenTbl = "MapIdempotencyTokens"
+	mapRootSignatureTbl    = "MapRootSignatures"
 	// Spanner DB columns:
 	colLeafIndex   = "LeafIndex"
 	colMapRevision = "MapRevision"
 	colLeafHash    = "LeafHash"
+	colToken       = "Token"
+	colKeyHash     = "KeyHash"
+	colPublicKey   = "PublicKey"
+	colSignature   = "Signature"
+
+	// gcBatchSize is the number of rows read by each page of the deletion of
+	// superseded map data, whose deletions are committed together. It keeps
+	// each commit well within the Spanner limit on mutations.
+	gcBatchSize = 2000
 )
 
 var errFinished = errors.New("finished")
@@ -45,6 +64,10 @@ var errFinished = errors.New("finished")
 // MapStorageOptions is used to configure various parameters of the spanner map storage layer.
 type MapStorageOptions struct {
 	TreeStorageOptions
+
+	// MapHashers provides the hashers of map hash strategies, in addition to
+	// the ones registered with hashers.RegisterMapHasher.
+	MapHashers hashers.MapHasherRegistry
 }
 
 // NewMapStorage initialises and returns a new MapStorage.
@@ -75,17 +98,17 @@ func (ms *mapStorage) CheckDatabaseAccessible(ctx context.Context) error {
 	return checkDatabaseAccessible(ctx, ms.ts.client)
 }
 
-func newMapCache(tree *trillian.Tree) (*cache.SubtreeCache, error) {
-	hasher, err := hashers.NewMapHasher(tree.HashStrategy)
+func (ms *mapStorage) newMapCache(tree *trillian.Tree) (*cache.SubtreeCache, error) {
+	hasher, err := ms.opts.MapHashers.NewMapHasherForTree(tree)
 	if err != nil {
 		return nil, err
 	}
-	return cache.NewMapSubtreeCache(defMapStrata, tree.TreeId, hasher), nil
+	return cache.NewMapSubtreeCache(cache.MapStrata(defMapStrata, hasher.BitLen()), tree.TreeId, hasher), nil
 }
 
 // Returns a ready-to-use MapTreeTX.
 func (ms *mapStorage) begin(ctx context.Context, tree *trillian.Tree, readonly bool, stx spanRead) (*mapTX, error) {
-	tx, err := ms.ts.begin(ctx, tree, newMapCache, stx)
+	tx, err := ms.ts.begin(ctx, tree, ms.newMapCache, stx)
 	if err != nil {
 		glog.Errorf("failed to treeStorage.begin(treeID=%d): %v", tree.TreeId, err)
 		return nil, err
@@ -111,6 +134,7 @@ func (ms *mapStorage) SnapshotForTree(ctx context.Context, tree *trillian.Tree)
 }
 
 func (ms *mapStorage) ReadWriteTransaction(ctx context.Context, tree *trillian.Tree, f storage.MapTXFunc) error {
+	var committed *mapTX
 	_, err := ms.ts.client.ReadWriteTransaction(ctx, func(ctx context.Context, stx *spanner.ReadWriteTransaction) error {
 		tx, err := ms.begin(ctx, tree, false /* readonly */, stx)
 		if err != nil {
@@ -124,9 +148,79 @@ func (ms *mapStorage) ReadWriteTransaction(ctx context.Context, tree *trillian.T
 			glog.Errorf("failed to tx.flushSubtrees(): %v", err)
 			return err
 		}
+		committed = tx
 		return nil
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	ms.collectGarbage(ctx, committed)
+	return nil
+}
+
+func (ms *mapStorage) ReadWriteMultiTransaction(ctx context.Context, trees []*trillian.Tree, f storage.MultiMapTXFunc) error {
+	var committed []*mapTX
+	_, err := ms.ts.client.ReadWriteTransaction(ctx, func(ctx context.Context, stx *spanner.ReadWriteTransaction) error {
+		mtxs := make([]*mapTX, 0, len(trees))
+		txs := make([]storage.MapTreeTX, 0, len(trees))
+		for _, tree := range trees {
+			tx, err := ms.begin(ctx, tree, false /* readonly */, stx)
+			if err != nil {
+				glog.Errorf("failed to mapStorage.begin(treeID=%d): %v", tree.TreeId, err)
+				return err
+			}
+			mtxs = append(mtxs, tx)
+			txs = append(txs, tx)
+		}
+		if err := f(ctx, txs); err != nil {
+			return err
+		}
+		for _, tx := range mtxs {
+			if err := tx.flushSubtrees(ctx); err != nil {
+				glog.Errorf("failed to tx.flushSubtrees(treeID=%d): %v", tx.treeID, err)
+				return err
+			}
+		}
+		committed = mtxs
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	ms.collectGarbage(ctx, committed...)
+	return nil
+}
+
+var (
+	gcMetricsOnce    sync.Once
+	gcFailureCounter monitoring.Counter
+)
+
+// InitMapMetrics initializes the metrics of map storage using mf to create
+// them. May be called multiple times. If so, the first call is the one that
+// counts.
+func InitMapMetrics(mf monitoring.MetricFactory) {
+	gcMetricsOnce.Do(func() {
+		gcFailureCounter = mf.NewCounter("cloudspanner_map_gc_failures", "Number of failed deletions of map data superseded at a deleted revision, which are retried by the next deletion", "mapid")
+	})
+}
+
+// collectGarbage deletes the data superseded at the revisions passed to
+// DeleteRevisionsBefore by the committed transactions txs. The transactions
+// have committed, so failures are logged and counted rather than returned,
+// and the rest of the data is deleted by the next call.
+func (ms *mapStorage) collectGarbage(ctx context.Context, txs ...*mapTX) {
+	for _, tx := range txs {
+		if tx.gcRevision <= 0 {
+			continue
+		}
+		if err := ms.deleteSuperseded(ctx, tx.treeID, tx.gcRevision); err != nil {
+			glog.Errorf("failed to delete data of map %d superseded at revision %d: %v", tx.treeID, tx.gcRevision, err)
+			if gcFailureCounter != nil {
+				gcFailureCounter.Inc(strconv.FormatInt(tx.treeID, 10))
+			}
+		}
+	}
 }
 
 // mapTX is a concrete implementation of the Trillian storage.MapStorage
@@ -137,6 +231,10 @@ type mapTX struct {
 
 	// ms is the MapStorage which begat this mapTX.
 	ms *mapStorage
+
+	// gcRevision is the revision passed to DeleteRevisionsBefore, at which the
+	// superseded data is deleted once the transaction commits, or 0.
+	gcRevision int64
 }
 
 // sthToSMR converts a spannerpb.TreeHead to a trillian.SignedMapRoot.
@@ -152,8 +250,9 @@ func sthToSMR(sth *spannerpb.TreeHead) (*trillian.SignedMapRoot, error) {
 	}
 
 	return &trillian.SignedMapRoot{
-		MapRoot:   mapRoot,
-		Signature: sth.Signature,
+		MapRoot:              mapRoot,
+		Signature:            sth.Signature,
+		AdditionalSignatures: sth.AdditionalSignatures,
 	}, nil
 }
 
@@ -179,6 +278,197 @@ func (tx *mapTX) LatestSignedMapRoot(ctx context.Context) (*trillian.SignedMapRo
 	return sthToSMR(currentSTH)
 }
 
+// EarliestRevisionSince returns the earliest revision whose root was published
+// at or after ts, or the latest revision plus one if there is none.
+func (tx *mapTX) EarliestRevisionSince(ctx context.Context, ts time.Time) (int64, error) {
+	currentSTH, err := tx.currentSTH(ctx)
+	if err != nil {
+		return 0, err
+	}
+	query := spanner.NewStatement(
+		`SELECT MIN(t.TreeRevision) FROM TreeHeads t
+				WHERE t.TreeID = @tree_id
+				AND t.TimestampNanos >= @ts_nanos`)
+	query.Params["tree_id"] = tx.treeID
+	query.Params["ts_nanos"] = ts.UnixNano()
+
+	rev := spanner.NullInt64{Int64: currentSTH.TreeRevision + 1, Valid: true}
+	err = tx.stx.Query(ctx, tx.tag(ctx, query)).Do(func(r *spanner.Row) error {
+		var min spanner.NullInt64
+		if err := r.Columns(&min); err != nil {
+			return err
+		}
+		if min.Valid {
+			rev = min
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rev.Int64, nil
+}
+
+// ListSignedMapRoots returns up to limit roots which match filter, ordered by
+// revision.
+func (tx *mapTX) ListSignedMapRoots(ctx context.Context, filter storage.MapRootFilter, limit int) ([]*trillian.SignedMapRoot, error) {
+	if limit <= 0 {
+		return []*trillian.SignedMapRoot{}, nil
+	}
+	order := "ASC"
+	if filter.Descending {
+		order = "DESC"
+	}
+	query := spanner.NewStatement(
+		`SELECT t.TreeID, t.TimestampNanos, t.TreeSize, t.RootHash, t.RootSignature, t.TreeRevision, t.TreeMetadata, t.AdditionalSignatures FROM TreeHeads t
+				WHERE t.TreeID = @tree_id
+				AND t.TreeRevision >= @start_rev AND t.TreeRevision < @end_rev
+				AND t.TimestampNanos >= @start_ts AND t.TimestampNanos < @end_ts
+				ORDER BY t.TreeRevision ` + order + `
+				LIMIT @limit`)
+	query.Params["tree_id"] = tx.treeID
+	query.Params["start_rev"] = filter.StartRevision
+	query.Params["end_rev"] = int64(math.MaxInt64)
+	if filter.EndRevision > 0 {
+		query.Params["end_rev"] = filter.EndRevision
+	}
+	query.Params["start_ts"] = int64(0)
+	if !filter.StartTime.IsZero() {
+		query.Params["start_ts"] = filter.StartTime.UnixNano()
+	}
+	query.Params["end_ts"] = int64(math.MaxInt64)
+	if !filter.EndTime.IsZero() {
+		query.Params["end_ts"] = filter.EndTime.UnixNano()
+	}
+	query.Params["limit"] = int64(limit)
+
+	ret := make([]*trillian.SignedMapRoot, 0, limit)
+	err := tx.stx.Query(ctx, tx.tag(ctx, query)).Do(func(r *spanner.Row) error {
+		th := &spannerpb.TreeHead{}
+		if err := r.Columns(&th.TreeId, &th.TsNanos, &th.TreeSize, &th.RootHash, &th.Signature, &th.TreeRevision, &th.Metadata, &th.AdditionalSignatures); err != nil {
+			return err
+		}
+		root, err := sthToSMR(th)
+		if err != nil {
+			return err
+		}
+		ret = append(ret, root)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// DeleteRevisionsBefore deletes the roots of all revisions older than
+// revision, with their signatures and recovery markers. Once the transaction
+// commits, the leaf and subtree versions superseded at revision and the
+// idempotency tokens of the deleted revisions are deleted too, in batches of
+// separate transactions, as there may be more of them than a single commit
+// can hold. If that fails, the rest is deleted by the next call.
+func (tx *mapTX) DeleteRevisionsBefore(ctx context.Context, revision int64) error {
+	stx, ok := tx.stx.(*spanner.ReadWriteTransaction)
+	if !ok {
+		return ErrWrongTXType
+	}
+	if revision <= 0 {
+		return nil
+	}
+
+	// TreeHeads and RecoveryMarkers are keyed by descending revision, so this
+	// range covers all the revisions before the given one.
+	heads := spanner.KeyRange{Start: spanner.Key{tx.treeID, revision - 1}, End: spanner.Key{tx.treeID}, Kind: spanner.ClosedClosed}
+	sigs := spanner.KeyRange{Start: spanner.Key{tx.treeID}, End: spanner.Key{tx.treeID, revision}, Kind: spanner.ClosedOpen}
+	if err := stx.BufferWrite([]*spanner.Mutation{
+		spanner.Delete(treeHeadTbl, heads),
+		spanner.Delete(recoveryMarkerTbl, heads),
+		spanner.Delete(mapRootSignatureTbl, sigs),
+	}); err != nil {
+		return err
+	}
+	tx.gcRevision = revision
+	return nil
+}
+
+// deleteSuperseded deletes the versions of the leaves and subtrees of a map
+// which are superseded at revision, and the idempotency tokens of the
+// revisions before it.
+func (ms *mapStorage) deleteSuperseded(ctx context.Context, treeID, revision int64) error {
+	if err := ms.deleteSupersededVersions(ctx, treeID, revision, mapLeafDataTbl, colLeafIndex, colMapRevision); err != nil {
+		return err
+	}
+	if err := ms.deleteSupersededVersions(ctx, treeID, revision, subtreeTbl, "SubtreeID", "Revision"); err != nil {
+		return err
+	}
+	// Each token row is deleted independently of the others, so partitioned
+	// DML can delete them without a limit on their number.
+	tokens := spanner.NewStatement(`DELETE FROM MapIdempotencyTokens
+		WHERE TreeID = @tree_id AND MapRevision < @map_rev`)
+	tokens.Params["tree_id"] = treeID
+	tokens.Params["map_rev"] = revision
+	_, err := ms.ts.client.PartitionedUpdate(ctx, ms.ts.tag(ctx, treeID, tokens))
+	return err
+}
+
+// deleteSupersededVersions deletes the rows of table at or before revision
+// which are not the most recent version of their key. The table must be keyed
+// by tree ID, keyCol, and revCol descending. The rows are read in pages of
+// gcBatchSize in key order, and the deletions of each page are committed
+// before the next one is read.
+func (ms *mapStorage) deleteSupersededVersions(ctx context.Context, treeID, revision int64, table, keyCol, revCol string) error {
+	query := fmt.Sprintf(`SELECT %[2]s, %[3]s FROM %[1]s
+		WHERE TreeID = @tree_id AND %[3]s <= @rev %%s
+		ORDER BY %[2]s, %[3]s DESC LIMIT @limit`, table, keyCol, revCol)
+	after := fmt.Sprintf(`AND (%[1]s > @key OR (%[1]s = @key AND %[2]s < @key_rev))`, keyCol, revCol)
+
+	var prev []byte
+	var prevRev int64
+	seen := false
+	for {
+		stmt := spanner.NewStatement(fmt.Sprintf(query, ""))
+		if seen {
+			stmt = spanner.NewStatement(fmt.Sprintf(query, after))
+			stmt.Params["key"] = prev
+			stmt.Params["key_rev"] = prevRev
+		}
+		stmt.Params["tree_id"] = treeID
+		stmt.Params["rev"] = revision
+		stmt.Params["limit"] = int64(gcBatchSize)
+
+		var muts []*spanner.Mutation
+		rows := 0
+		err := ms.ts.client.Single().Query(ctx, ms.ts.tag(ctx, treeID, stmt)).Do(func(r *spanner.Row) error {
+			var key []byte
+			var rev int64
+			if err := r.Columns(&key, &rev); err != nil {
+				return err
+			}
+			// Versions are ordered newest first, and the newest one at or
+			// before revision is kept.
+			if seen && bytes.Equal(prev, key) {
+				muts = append(muts, spanner.Delete(table, spanner.Key{treeID, key, rev}))
+			}
+			prev, prevRev, seen = key, rev, true
+			rows++
+			return nil
+		})
+		if err != nil {
+			glog.Errorf("failed to read superseded %s rows: %v", table, err)
+			return err
+		}
+		if len(muts) > 0 {
+			if _, err := ms.ts.client.Apply(ctx, muts); err != nil {
+				glog.Errorf("failed to delete superseded %s rows: %v", table, err)
+				return err
+			}
+		}
+		if rows < gcBatchSize {
+			return nil
+		}
+	}
+}
+
 // StoreSignedMapRoot stores the provided root.
 // This method will return an error if the caller attempts to store more than
 // one root per map for a given map revision.
@@ -199,12 +489,13 @@ func (tx *mapTX) StoreSignedMapRoot(ctx context.Context, root *trillian.SignedMa
 		return err
 	}
 	sth := spannerpb.TreeHead{
-		TsNanos:      int64(r.TimestampNanos),
-		RootHash:     r.RootHash,
-		Metadata:     r.Metadata,
-		TreeId:       tx.treeID,
-		TreeRevision: writeRev,
-		Signature:    root.Signature,
+		TsNanos:              int64(r.TimestampNanos),
+		RootHash:             r.RootHash,
+		Metadata:             r.Metadata,
+		TreeId:               tx.treeID,
+		TreeRevision:         writeRev,
+		Signature:            root.Signature,
+		AdditionalSignatures: root.AdditionalSignatures,
 	}
 
 	// TODO(al): consider replacing these with InsertStruct throughout.
@@ -219,6 +510,7 @@ func (tx *mapTX) StoreSignedMapRoot(ctx context.Context, root *trillian.SignedMa
 			"RootSignature",
 			"TreeRevision",
 			"TreeMetadata",
+			"AdditionalSignatures",
 		},
 		[]interface{}{
 			int64(tx.treeID),
@@ -228,9 +520,10 @@ func (tx *mapTX) StoreSignedMapRoot(ctx context.Context, root *trillian.SignedMa
 			sth.Signature,
 			writeRev,
 			sth.Metadata,
+			sth.AdditionalSignatures,
 		})
 
-	return stx.BufferWrite([]*spanner.Mutation{m})
+	return stx.BufferWrite([]*spanner.Mutation{m, tx.recoveryMarkerMutation(writeRev)})
 }
 
 // Set sets the leaf with the specified index to value.
@@ -333,11 +626,232 @@ func (tx *mapTX) Get(ctx context.Context, revision int64, indexes [][]byte) ([]*
 	return ret, nil
 }
 
+// List returns up to limit MapLeaves which exist at revision, ordered by
+// index, starting with the first index greater than after.
+// An error will be returned if there is a problem with the underlying
+// storage.
+func (tx *mapTX) List(ctx context.Context, revision int64, after []byte, limit int) ([]*trillian.MapLeaf, error) {
+	if limit <= 0 {
+		return []*trillian.MapLeaf{}, nil
+	}
+	if after == nil {
+		after = []byte{}
+	}
+	query := spanner.NewStatement(
+		`SELECT l.LeafIndex, l.MapRevision, l.LeafHash, l.LeafValue, l.ExtraData FROM MapLeafData l
+				WHERE l.TreeID = @tree_id
+				AND l.LeafIndex > @after
+				AND l.MapRevision <= @map_rev
+				ORDER BY l.LeafIndex, l.MapRevision DESC`)
+	query.Params["tree_id"] = tx.treeID
+	query.Params["after"] = after
+	query.Params["map_rev"] = revision
+
+	ret := make([]*trillian.MapLeaf, 0, limit)
+	rows := tx.stx.Query(ctx, tx.tag(ctx, query))
+	err := rows.Do(func(r *spanner.Row) error {
+		var rev int64
+		var leaf trillian.MapLeaf
+		if err := r.Columns(&leaf.Index, &rev, &leaf.LeafHash, &leaf.LeafValue, &leaf.ExtraData); err != nil {
+			return err
+		}
+		// Rows for an index are ordered by descending revision, so only the
+		// first one we see for each index is the value at revision.
+		if n := len(ret); n > 0 && bytes.Equal(ret[n-1].Index, leaf.Index) {
+			return nil
+		}
+		if len(ret) == limit {
+			return errFinished
+		}
+		ret = append(ret, &leaf)
+		return nil
+	})
+	if err != nil && err != errFinished {
+		glog.Errorf("failed to list MapLeafData rows for rev %d after %x: %v", revision, after, err)
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ListLeafVersions returns all the stored versions of up to limit MapLeaves,
+// ordered by index and revision, starting with the first index greater than
+// after.
+// An error will be returned if there is a problem with the underlying
+// storage.
+func (tx *mapTX) ListLeafVersions(ctx context.Context, after []byte, limit int) ([]storage.MapLeafVersion, error) {
+	if limit <= 0 {
+		return []storage.MapLeafVersion{}, nil
+	}
+	if after == nil {
+		after = []byte{}
+	}
+	query := spanner.NewStatement(
+		`SELECT l.LeafIndex, l.MapRevision, l.LeafHash, l.LeafValue, l.ExtraData FROM MapLeafData l
+				WHERE l.TreeID = @tree_id
+				AND l.LeafIndex > @after
+				ORDER BY l.LeafIndex, l.MapRevision`)
+	query.Params["tree_id"] = tx.treeID
+	query.Params["after"] = after
+
+	ret := make([]storage.MapLeafVersion, 0, limit)
+	leaves := 0
+	rows := tx.stx.Query(ctx, tx.tag(ctx, query))
+	err := rows.Do(func(r *spanner.Row) error {
+		var v storage.MapLeafVersion
+		var leaf trillian.MapLeaf
+		if err := r.Columns(&leaf.Index, &v.Revision, &leaf.LeafHash, &leaf.LeafValue, &leaf.ExtraData); err != nil {
+			return err
+		}
+		if n := len(ret); n == 0 || !bytes.Equal(ret[n-1].Leaf.Index, leaf.Index) {
+			if leaves == limit {
+				return errFinished
+			}
+			leaves++
+		}
+		v.Leaf = &leaf
+		ret = append(ret, v)
+		return nil
+	})
+	if err != nil && err != errFinished {
+		glog.Errorf("failed to list MapLeafData versions after %x: %v", after, err)
+		return nil, err
+	}
+	return ret, nil
+}
+
+// SetLeafHash replaces the LeafHash of the leaf at index written at revision.
+func (tx *mapTX) SetLeafHash(ctx context.Context, revision int64, index, leafHash []byte) error {
+	stx, ok := tx.stx.(*spanner.ReadWriteTransaction)
+	if !ok {
+		return ErrWrongTXType
+	}
+	m := spanner.Update(mapLeafDataTbl,
+		[]string{colTreeID, colLeafIndex, colMapRevision, colLeafHash},
+		[]interface{}{tx.treeID, index, revision, leafHash})
+	return stx.BufferWrite([]*spanner.Mutation{m})
+}
+
+// GetIndexesByLeafHash returns the indexes of the leaves whose value at
+// revision has leafHash, ordered by index. The map must have leaf_hash_index
+// set in its MapStorageConfig, and the database the MapLeafDataByLeafHash
+// index.
+func (tx *mapTX) GetIndexesByLeafHash(ctx context.Context, revision int64, leafHash []byte) ([][]byte, error) {
+	if cfg, ok := tx.config.(*spannerpb.MapStorageConfig); !ok || !cfg.GetLeafHashIndex() {
+		return nil, storage.ErrNoLeafHashIndex
+	}
+	query := spanner.NewStatement(
+		`SELECT DISTINCT l.LeafIndex FROM MapLeafData@{FORCE_INDEX=MapLeafDataByLeafHash} l
+				WHERE l.TreeID = @tree_id
+				AND l.LeafHash = @leaf_hash
+				AND l.MapRevision <= @map_rev`)
+	query.Params["tree_id"] = tx.treeID
+	query.Params["leaf_hash"] = leafHash
+	query.Params["map_rev"] = revision
+
+	var candidates [][]byte
+	rows := tx.stx.Query(ctx, tx.tag(ctx, query))
+	if err := rows.Do(func(r *spanner.Row) error {
+		var index []byte
+		if err := r.Columns(&index); err != nil {
+			return err
+		}
+		candidates = append(candidates, index)
+		return nil
+	}); err != nil {
+		glog.Errorf("failed to look up MapLeafData by leaf hash %x at rev %d: %v", leafHash, revision, err)
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	// A candidate may have had the hash in an earlier version only.
+	leaves, err := tx.Get(ctx, revision, candidates)
+	if err != nil {
+		return nil, err
+	}
+	var indexes [][]byte
+	for _, l := range leaves {
+		if len(l.LeafValue) > 0 && bytes.Equal(l.LeafHash, leafHash) {
+			indexes = append(indexes, l.Index)
+		}
+	}
+	sort.Slice(indexes, func(i, j int) bool { return bytes.Compare(indexes[i], indexes[j]) < 0 })
+	return indexes, nil
+}
+
+// GetIdempotencyToken returns the revision written by the request which
+// stored token, if any.
+func (tx *mapTX) GetIdempotencyToken(ctx context.Context, token []byte) (int64, bool, error) {
+	row, err := tx.stx.ReadRow(ctx, mapIdempotencyTokenTbl, spanner.Key{tx.treeID, token}, []string{colMapRevision})
+	if spanner.ErrCode(err) == codes.NotFound {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	var rev int64
+	if err := row.Columns(&rev); err != nil {
+		return 0, false, err
+	}
+	return rev, true, nil
+}
+
+// StoreIdempotencyToken associates token with the write revision.
+func (tx *mapTX) StoreIdempotencyToken(ctx context.Context, token []byte) error {
+	stx, ok := tx.stx.(*spanner.ReadWriteTransaction)
+	if !ok {
+		return ErrWrongTXType
+	}
+	writeRev, err := tx.writeRev(ctx)
+	if err != nil {
+		glog.Errorf("failed to determine write revision: %v", err)
+		return err
+	}
+	m := spanner.Insert(mapIdempotencyTokenTbl,
+		[]string{colTreeID, colToken, colMapRevision},
+		[]interface{}{tx.treeID, token, writeRev})
+	return stx.BufferWrite([]*spanner.Mutation{m})
+}
+
+// GetMapRootSignatures returns the witness signatures over the root at
+// revision.
+func (tx *mapTX) GetMapRootSignatures(ctx context.Context, revision int64) ([]*trillian.MapRootSignature, error) {
+	var sigs []*trillian.MapRootSignature
+	rows := tx.stx.Read(ctx, mapRootSignatureTbl, spanner.Key{tx.treeID, revision}.AsPrefix(), []string{colPublicKey, colSignature})
+	err := rows.Do(func(r *spanner.Row) error {
+		var der, sig []byte
+		if err := r.Columns(&der, &sig); err != nil {
+			return err
+		}
+		sigs = append(sigs, &trillian.MapRootSignature{PublicKey: &keyspb.PublicKey{Der: der}, Signature: sig})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sigs, nil
+}
+
+// StoreMapRootSignature stores the witness signature over the root at
+// revision, replacing any signature with the same public key.
+func (tx *mapTX) StoreMapRootSignature(ctx context.Context, revision int64, sig *trillian.MapRootSignature) error {
+	stx, ok := tx.stx.(*spanner.ReadWriteTransaction)
+	if !ok {
+		return ErrWrongTXType
+	}
+	der := sig.GetPublicKey().GetDer()
+	keyHash := sha256.Sum256(der)
+	m := spanner.InsertOrUpdate(mapRootSignatureTbl,
+		[]string{colTreeID, colMapRevision, colKeyHash, colPublicKey, colSignature},
+		[]interface{}{tx.treeID, revision, keyHash[:], der, sig.GetSignature()})
+	return stx.BufferWrite([]*spanner.Mutation{m})
+}
+
 // GetSignedMapRoot returns the SignedMapRoot for revision.
 // An error will be returned if there is a problem with the underlying storage.
 func (tx *mapTX) GetSignedMapRoot(ctx context.Context, revision int64) (*trillian.SignedMapRoot, error) {
 	query := spanner.NewStatement(
-		`SELECT t.TreeID, t.TimestampNanos, t.TreeSize, t.RootHash, t.RootSignature, t.TreeRevision, t.TreeMetadata FROM TreeHeads t
+		`SELECT t.TreeID, t.TimestampNanos, t.TreeSize, t.RootHash, t.RootSignature, t.TreeRevision, t.TreeMetadata, t.AdditionalSignatures FROM TreeHeads t
 				WHERE t.TreeID = @tree_id
 				AND t.TreeRevision = @tree_rev
 				LIMIT 1`)
@@ -345,10 +859,10 @@ func (tx *mapTX) GetSignedMapRoot(ctx context.Context, revision int64) (*trillia
 	query.Params["tree_rev"] = revision
 
 	var th *spannerpb.TreeHead
-	rows := tx.stx.Query(ctx, query)
+	rows := tx.stx.Query(ctx, tx.tag(ctx, query))
 	err := rows.Do(func(r *spanner.Row) error {
 		tth := &spannerpb.TreeHead{}
-		if err := r.Columns(&tth.TreeId, &tth.TsNanos, &tth.TreeSize, &tth.RootHash, &tth.Signature, &tth.TreeRevision, &tth.Metadata); err != nil {
+		if err := r.Columns(&tth.TreeId, &tth.TsNanos, &tth.TreeSize, &tth.RootHash, &tth.Signature, &tth.TreeRevision, &tth.Metadata, &tth.AdditionalSignatures); err != nil {
 			return err
 		}
 
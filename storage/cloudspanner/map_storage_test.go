@@ -60,11 +60,15 @@ func cleanTestDB(ctx context.Context, t *testing.T, db *spanner.Client) {
 		for _, table := range []string{
 			"TreeRoots",
 			"TreeHeads",
+			"LogRootSignatures",
+			"RecoveryMarkers",
 			"SubtreeData",
 			"LeafData",
 			"SequencedLeafData",
 			"Unsequenced",
 			"MapLeafData",
+			"MapIdempotencyTokens",
+			"MapRootSignatures",
 		} {
 			mutations = append(mutations, spanner.Delete(table, spanner.AllKeys()))
 		}
@@ -0,0 +1,88 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudspanner
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/trillian/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	recoveryMarkerTbl  = "RecoveryMarkers"
+	colCommitTimestamp = "CommitTimestamp"
+)
+
+// recoveryMarkerMutation returns the mutation which stores the recovery marker
+// of the root at revision. The position of the marker is the commit timestamp
+// of the transaction, so restoring the database to that timestamp or later
+// includes the root.
+func (t *treeTX) recoveryMarkerMutation(revision int64) *spanner.Mutation {
+	return spanner.Insert(recoveryMarkerTbl,
+		[]string{colTreeID, colRevision, colCommitTimestamp},
+		[]interface{}{t.treeID, revision, spanner.CommitTimestamp})
+}
+
+// GetRecoveryMarker implements storage.RecoveryMarkerReader.
+func (t *treeTX) GetRecoveryMarker(ctx context.Context, revision int64) (*storage.RecoveryMarker, error) {
+	row, err := t.stx.ReadRow(ctx, recoveryMarkerTbl, spanner.Key{t.treeID, revision}, []string{colRevision, colCommitTimestamp})
+	if spanner.ErrCode(err) == codes.NotFound {
+		return nil, status.Errorf(codes.NotFound, "no recovery marker for tree %d at revision %d", t.treeID, revision)
+	} else if err != nil {
+		return nil, err
+	}
+	return t.recoveryMarkerFromRow(row)
+}
+
+// LatestRecoveryMarker implements storage.RecoveryMarkerReader.
+func (t *treeTX) LatestRecoveryMarker(ctx context.Context) (*storage.RecoveryMarker, error) {
+	query := spanner.NewStatement(
+		`SELECT m.Revision, m.CommitTimestamp FROM RecoveryMarkers m
+				WHERE m.TreeID = @tree_id
+				ORDER BY m.Revision DESC
+				LIMIT 1`)
+	query.Params["tree_id"] = t.treeID
+
+	var marker *storage.RecoveryMarker
+	err := t.stx.Query(ctx, t.tag(ctx, query)).Do(func(r *spanner.Row) error {
+		var err error
+		marker, err = t.recoveryMarkerFromRow(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if marker == nil {
+		return nil, status.Errorf(codes.NotFound, "no recovery marker for tree %d", t.treeID)
+	}
+	return marker, nil
+}
+
+func (t *treeTX) recoveryMarkerFromRow(r *spanner.Row) (*storage.RecoveryMarker, error) {
+	var rev int64
+	var ts time.Time
+	if err := r.Columns(&rev, &ts); err != nil {
+		return nil, err
+	}
+	return &storage.RecoveryMarker{
+		TreeID:   t.treeID,
+		Revision: rev,
+		Position: ts.UTC().Format(time.RFC3339Nano),
+	}, nil
+}
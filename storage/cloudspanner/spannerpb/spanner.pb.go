@@ -1,5 +1,5 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
-// source: spanner.proto
+// source: storage/cloudspanner/spannerpb/spanner.proto
 
 package spannerpb
 
@@ -7,6 +7,7 @@ import (
 	fmt "fmt"
 	proto "github.com/golang/protobuf/proto"
 	any "github.com/golang/protobuf/ptypes/any"
+	storagepb "github.com/google/trillian/storage/storagepb"
 	math "math"
 )
 
@@ -29,18 +30,24 @@ const (
 	TreeState_UNKNOWN_TREE_STATE TreeState = 0
 	TreeState_ACTIVE             TreeState = 1
 	TreeState_FROZEN             TreeState = 2
+	TreeState_QUARANTINED        TreeState = 6
+	TreeState_PAUSED             TreeState = 7
 )
 
 var TreeState_name = map[int32]string{
 	0: "UNKNOWN_TREE_STATE",
 	1: "ACTIVE",
 	2: "FROZEN",
+	6: "QUARANTINED",
+	7: "PAUSED",
 }
 
 var TreeState_value = map[string]int32{
 	"UNKNOWN_TREE_STATE": 0,
 	"ACTIVE":             1,
 	"FROZEN":             2,
+	"QUARANTINED":        6,
+	"PAUSED":             7,
 }
 
 func (x TreeState) String() string {
@@ -48,7 +55,7 @@ func (x TreeState) String() string {
 }
 
 func (TreeState) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_879d3e919e93c6ba, []int{0}
+	return fileDescriptor_b439183f89a9cab9, []int{0}
 }
 
 // Type of the Tree.
@@ -78,7 +85,7 @@ func (x TreeType) String() string {
 }
 
 func (TreeType) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_879d3e919e93c6ba, []int{1}
+	return fileDescriptor_b439183f89a9cab9, []int{1}
 }
 
 // Defines the preimage protection used for tree leaves / nodes.
@@ -118,7 +125,7 @@ func (x HashStrategy) String() string {
 }
 
 func (HashStrategy) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_879d3e919e93c6ba, []int{2}
+	return fileDescriptor_b439183f89a9cab9, []int{2}
 }
 
 // Supported hash algorithms.
@@ -149,7 +156,7 @@ func (x HashAlgorithm) String() string {
 }
 
 func (HashAlgorithm) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_879d3e919e93c6ba, []int{3}
+	return fileDescriptor_b439183f89a9cab9, []int{3}
 }
 
 // Supported signature algorithms.
@@ -184,7 +191,7 @@ func (x SignatureAlgorithm) String() string {
 }
 
 func (SignatureAlgorithm) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_879d3e919e93c6ba, []int{4}
+	return fileDescriptor_b439183f89a9cab9, []int{4}
 }
 
 // LogStorageConfig holds settings which tune the storage implementation for
@@ -213,7 +220,7 @@ func (m *LogStorageConfig) Reset()         { *m = LogStorageConfig{} }
 func (m *LogStorageConfig) String() string { return proto.CompactTextString(m) }
 func (*LogStorageConfig) ProtoMessage()    {}
 func (*LogStorageConfig) Descriptor() ([]byte, []int) {
-	return fileDescriptor_879d3e919e93c6ba, []int{0}
+	return fileDescriptor_b439183f89a9cab9, []int{0}
 }
 
 func (m *LogStorageConfig) XXX_Unmarshal(b []byte) error {
@@ -251,6 +258,13 @@ func (m *LogStorageConfig) GetNumMerkleBuckets() int64 {
 // MapStorageConfig holds settings which tune the storage implementation for
 // a given map tree.
 type MapStorageConfig struct {
+	// leaf_compression is the compression of the leaf values, which the map
+	// server applies. It can't be changed after tree creation.
+	LeafCompression storagepb.LeafCompression `protobuf:"varint,1,opt,name=leaf_compression,json=leafCompression,proto3,enum=storagepb.LeafCompression" json:"leaf_compression,omitempty"`
+	// If set, leaves can be looked up by leaf hash, which needs the optional
+	// MapLeafDataByLeafHash index of the schema. It can't be changed after tree
+	// creation.
+	LeafHashIndex        bool     `protobuf:"varint,2,opt,name=leaf_hash_index,json=leafHashIndex,proto3" json:"leaf_hash_index,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -260,7 +274,7 @@ func (m *MapStorageConfig) Reset()         { *m = MapStorageConfig{} }
 func (m *MapStorageConfig) String() string { return proto.CompactTextString(m) }
 func (*MapStorageConfig) ProtoMessage()    {}
 func (*MapStorageConfig) Descriptor() ([]byte, []int) {
-	return fileDescriptor_879d3e919e93c6ba, []int{1}
+	return fileDescriptor_b439183f89a9cab9, []int{1}
 }
 
 func (m *MapStorageConfig) XXX_Unmarshal(b []byte) error {
@@ -281,6 +295,20 @@ func (m *MapStorageConfig) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_MapStorageConfig proto.InternalMessageInfo
 
+func (m *MapStorageConfig) GetLeafCompression() storagepb.LeafCompression {
+	if m != nil {
+		return m.LeafCompression
+	}
+	return storagepb.LeafCompression_NO_COMPRESSION
+}
+
+func (m *MapStorageConfig) GetLeafHashIndex() bool {
+	if m != nil {
+		return m.LeafHashIndex
+	}
+	return false
+}
+
 // TreeInfo stores information about a Trillian tree.
 type TreeInfo struct {
 	// tree_id is the ID of the tree, and is used as a primary key.
@@ -325,7 +353,40 @@ type TreeInfo struct {
 	// If true the tree was soft deleted.
 	Deleted bool `protobuf:"varint,18,opt,name=deleted,proto3" json:"deleted,omitempty"`
 	// Time of tree deletion, if any.
-	DeleteTimeNanos      int64    `protobuf:"varint,19,opt,name=delete_time_nanos,json=deleteTimeNanos,proto3" json:"delete_time_nanos,omitempty"`
+	DeleteTimeNanos int64 `protobuf:"varint,19,opt,name=delete_time_nanos,json=deleteTimeNanos,proto3" json:"delete_time_nanos,omitempty"`
+	// retain_revisions is the number of most recent revisions of a map to keep.
+	// Zero means no limit.
+	RetainRevisions int64 `protobuf:"varint,20,opt,name=retain_revisions,json=retainRevisions,proto3" json:"retain_revisions,omitempty"`
+	// retain_duration_millis is the period for which superseded revisions of a
+	// map are kept. Zero means no limit.
+	RetainDurationMillis int64 `protobuf:"varint,21,opt,name=retain_duration_millis,json=retainDurationMillis,proto3" json:"retain_duration_millis,omitempty"`
+	// map_index_bits is the number of bits of the indices of a map. Zero means
+	// the hash length.
+	MapIndexBits int32 `protobuf:"varint,22,opt,name=map_index_bits,json=mapIndexBits,proto3" json:"map_index_bits,omitempty"`
+	// duplicate_leaf_policy is the number of the trillian.DuplicateLeafPolicy
+	// of a log.
+	DuplicateLeafPolicy int32 `protobuf:"varint,23,opt,name=duplicate_leaf_policy,json=duplicateLeafPolicy,proto3" json:"duplicate_leaf_policy,omitempty"`
+	// additional_private_keys sign roots along with private_key.
+	AdditionalPrivateKeys []*any.Any `protobuf:"bytes,24,rep,name=additional_private_keys,json=additionalPrivateKeys,proto3" json:"additional_private_keys,omitempty"`
+	// additional_public_key_ders verify the signatures of
+	// additional_private_keys, in the same order, in DER-encoded PKIX form.
+	AdditionalPublicKeyDers [][]byte `protobuf:"bytes,25,rep,name=additional_public_key_ders,json=additionalPublicKeyDers,proto3" json:"additional_public_key_ders,omitempty"`
+	// signature_threshold is the number of valid signatures roots must carry.
+	// Zero means all of the keys.
+	SignatureThreshold int32 `protobuf:"varint,26,opt,name=signature_threshold,json=signatureThreshold,proto3" json:"signature_threshold,omitempty"`
+	// labels tag the tree for operators' tooling.
+	Labels map[string]string `protobuf:"bytes,27,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// delete_retention_millis is the period a deleted tree is kept before it's
+	// hard-deleted. Zero means the server's default.
+	DeleteRetentionMillis int64 `protobuf:"varint,28,opt,name=delete_retention_millis,json=deleteRetentionMillis,proto3" json:"delete_retention_millis,omitempty"`
+	// key_rotation is the marshalled trillian.KeyRotation of the tree, if any.
+	KeyRotation []byte `protobuf:"bytes,29,opt,name=key_rotation,json=keyRotation,proto3" json:"key_rotation,omitempty"`
+	// read_tokens_per_second and write_tokens_per_second hold the
+	// trillian.TreeQuota of the tree. Zero means unlimited.
+	ReadTokensPerSecond  int64 `protobuf:"varint,30,opt,name=read_tokens_per_second,json=readTokensPerSecond,proto3" json:"read_tokens_per_second,omitempty"`
+	WriteTokensPerSecond int64 `protobuf:"varint,31,opt,name=write_tokens_per_second,json=writeTokensPerSecond,proto3" json:"write_tokens_per_second,omitempty"`
+	// owner is the principal which owns the tree, if any.
+	Owner                string   `protobuf:"bytes,32,opt,name=owner,proto3" json:"owner,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -335,7 +396,7 @@ func (m *TreeInfo) Reset()         { *m = TreeInfo{} }
 func (m *TreeInfo) String() string { return proto.CompactTextString(m) }
 func (*TreeInfo) ProtoMessage()    {}
 func (*TreeInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor_879d3e919e93c6ba, []int{2}
+	return fileDescriptor_b439183f89a9cab9, []int{2}
 }
 
 func (m *TreeInfo) XXX_Unmarshal(b []byte) error {
@@ -505,6 +566,97 @@ func (m *TreeInfo) GetDeleteTimeNanos() int64 {
 	return 0
 }
 
+func (m *TreeInfo) GetRetainRevisions() int64 {
+	if m != nil {
+		return m.RetainRevisions
+	}
+	return 0
+}
+
+func (m *TreeInfo) GetRetainDurationMillis() int64 {
+	if m != nil {
+		return m.RetainDurationMillis
+	}
+	return 0
+}
+
+func (m *TreeInfo) GetMapIndexBits() int32 {
+	if m != nil {
+		return m.MapIndexBits
+	}
+	return 0
+}
+
+func (m *TreeInfo) GetDuplicateLeafPolicy() int32 {
+	if m != nil {
+		return m.DuplicateLeafPolicy
+	}
+	return 0
+}
+
+func (m *TreeInfo) GetAdditionalPrivateKeys() []*any.Any {
+	if m != nil {
+		return m.AdditionalPrivateKeys
+	}
+	return nil
+}
+
+func (m *TreeInfo) GetAdditionalPublicKeyDers() [][]byte {
+	if m != nil {
+		return m.AdditionalPublicKeyDers
+	}
+	return nil
+}
+
+func (m *TreeInfo) GetSignatureThreshold() int32 {
+	if m != nil {
+		return m.SignatureThreshold
+	}
+	return 0
+}
+
+func (m *TreeInfo) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *TreeInfo) GetDeleteRetentionMillis() int64 {
+	if m != nil {
+		return m.DeleteRetentionMillis
+	}
+	return 0
+}
+
+func (m *TreeInfo) GetKeyRotation() []byte {
+	if m != nil {
+		return m.KeyRotation
+	}
+	return nil
+}
+
+func (m *TreeInfo) GetReadTokensPerSecond() int64 {
+	if m != nil {
+		return m.ReadTokensPerSecond
+	}
+	return 0
+}
+
+func (m *TreeInfo) GetWriteTokensPerSecond() int64 {
+	if m != nil {
+		return m.WriteTokensPerSecond
+	}
+	return 0
+}
+
+func (m *TreeInfo) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
 // XXX_OneofWrappers is for the internal use of the proto package.
 func (*TreeInfo) XXX_OneofWrappers() []interface{} {
 	return []interface{}{
@@ -529,8 +681,11 @@ type TreeHead struct {
 	// (not present) represented by the data in this TreeHead.
 	Signature []byte `protobuf:"bytes,10,opt,name=signature,proto3" json:"signature,omitempty"`
 	// tree_revision identifies the revision at which the TreeHead was created.
-	TreeRevision         int64    `protobuf:"varint,6,opt,name=tree_revision,json=treeRevision,proto3" json:"tree_revision,omitempty"`
-	Metadata             []byte   `protobuf:"bytes,9,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	TreeRevision int64  `protobuf:"varint,6,opt,name=tree_revision,json=treeRevision,proto3" json:"tree_revision,omitempty"`
+	Metadata     []byte `protobuf:"bytes,9,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	// additional_signatures holds the signatures by the additional keys of the
+	// tree, in the order of its additional_private_keys.
+	AdditionalSignatures [][]byte `protobuf:"bytes,11,rep,name=additional_signatures,json=additionalSignatures,proto3" json:"additional_signatures,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -540,7 +695,7 @@ func (m *TreeHead) Reset()         { *m = TreeHead{} }
 func (m *TreeHead) String() string { return proto.CompactTextString(m) }
 func (*TreeHead) ProtoMessage()    {}
 func (*TreeHead) Descriptor() ([]byte, []int) {
-	return fileDescriptor_879d3e919e93c6ba, []int{3}
+	return fileDescriptor_b439183f89a9cab9, []int{3}
 }
 
 func (m *TreeHead) XXX_Unmarshal(b []byte) error {
@@ -610,6 +765,13 @@ func (m *TreeHead) GetMetadata() []byte {
 	return nil
 }
 
+func (m *TreeHead) GetAdditionalSignatures() [][]byte {
+	if m != nil {
+		return m.AdditionalSignatures
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("spannerpb.TreeState", TreeState_name, TreeState_value)
 	proto.RegisterEnum("spannerpb.TreeType", TreeType_name, TreeType_value)
@@ -619,71 +781,101 @@ func init() {
 	proto.RegisterType((*LogStorageConfig)(nil), "spannerpb.LogStorageConfig")
 	proto.RegisterType((*MapStorageConfig)(nil), "spannerpb.MapStorageConfig")
 	proto.RegisterType((*TreeInfo)(nil), "spannerpb.TreeInfo")
+	proto.RegisterMapType((map[string]string)(nil), "spannerpb.TreeInfo.LabelsEntry")
 	proto.RegisterType((*TreeHead)(nil), "spannerpb.TreeHead")
 }
 
-func init() { proto.RegisterFile("spanner.proto", fileDescriptor_879d3e919e93c6ba) }
-
-var fileDescriptor_879d3e919e93c6ba = []byte{
-	// 958 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x55, 0xed, 0x6e, 0x1a, 0x47,
-	0x14, 0xf5, 0x1a, 0x0c, 0xcb, 0x35, 0xd8, 0xe3, 0x71, 0xdc, 0xac, 0x93, 0x56, 0x42, 0x6e, 0x2b,
-	0x51, 0x54, 0x41, 0xeb, 0xc8, 0x8e, 0xa2, 0x54, 0xaa, 0xd6, 0x18, 0x07, 0x9b, 0xb0, 0x44, 0xb3,
-	0xeb, 0x56, 0xc9, 0x9f, 0xd5, 0xc0, 0x8e, 0x61, 0xe5, 0xfd, 0xea, 0xee, 0x6c, 0x14, 0xf2, 0x0c,
-	0xfd, 0xd3, 0xc7, 0xec, 0x5b, 0x54, 0x33, 0xb3, 0x60, 0x8c, 0xd5, 0x7f, 0x33, 0xe7, 0x9c, 0x7b,
-	0xaf, 0xe7, 0xfa, 0x9c, 0x05, 0x1a, 0x59, 0x42, 0xa3, 0x88, 0xa5, 0x9d, 0x24, 0x8d, 0x79, 0x8c,
-	0x6b, 0xc5, 0x35, 0x99, 0xbc, 0x38, 0x9e, 0xc5, 0xf1, 0x2c, 0x60, 0x5d, 0x49, 0x4c, 0xf2, 0xbb,
-	0x2e, 0x8d, 0x16, 0x4a, 0x75, 0x12, 0x00, 0x7a, 0x1f, 0xcf, 0x6c, 0x1e, 0xa7, 0x74, 0xc6, 0x7a,
-	0x71, 0x74, 0xe7, 0xcf, 0x70, 0x1b, 0x0e, 0xa2, 0x3c, 0x74, 0xf3, 0x28, 0x63, 0x7f, 0xb9, 0x93,
-	0x7c, 0x7a, 0xcf, 0x78, 0x66, 0x68, 0x4d, 0xad, 0x55, 0x22, 0xfb, 0x51, 0x1e, 0xde, 0x0a, 0xfc,
-	0x42, 0xc1, 0xf8, 0x67, 0xc0, 0x42, 0x1b, 0xb2, 0xf4, 0x3e, 0x60, 0x2b, 0xf1, 0xb6, 0x14, 0xa3,
-	0x28, 0x0f, 0x47, 0x92, 0x28, 0xd4, 0x27, 0x18, 0xd0, 0x88, 0x26, 0x8f, 0xa6, 0x9d, 0xfc, 0x5d,
-	0x05, 0xdd, 0x49, 0x19, 0xbb, 0x8e, 0xee, 0x62, 0xfc, 0x1c, 0xaa, 0x3c, 0x65, 0xcc, 0xf5, 0xbd,
-	0x62, 0x60, 0x45, 0x5c, 0xaf, 0x3d, 0x7c, 0x04, 0x95, 0x7b, 0xb6, 0x10, 0xb8, 0xea, 0xbd, 0x73,
-	0xcf, 0x16, 0xd7, 0x1e, 0xc6, 0x50, 0x8e, 0x68, 0xc8, 0x8c, 0x52, 0x53, 0x6b, 0xd5, 0x88, 0x3c,
-	0xe3, 0x26, 0xec, 0x7a, 0x2c, 0x9b, 0xa6, 0x7e, 0xc2, 0xfd, 0x38, 0x32, 0xca, 0x92, 0x5a, 0x87,
-	0xf0, 0x2f, 0x50, 0x93, 0x53, 0xf8, 0x22, 0x61, 0xc6, 0x4e, 0x53, 0x6b, 0xed, 0x9d, 0x1e, 0x76,
-	0x56, 0xeb, 0xea, 0x88, 0xbf, 0xc6, 0x59, 0x24, 0x8c, 0xe8, 0xbc, 0x38, 0xe1, 0x57, 0x00, 0xb2,
-	0x22, 0xe3, 0x94, 0x33, 0x43, 0x97, 0x25, 0xcf, 0x36, 0x4a, 0x6c, 0xc1, 0x11, 0xd9, 0x59, 0x1e,
-	0xf1, 0x6f, 0xd0, 0x98, 0xd3, 0x6c, 0xee, 0x66, 0x3c, 0xa5, 0x9c, 0xcd, 0x16, 0x46, 0x4d, 0xd6,
-	0x3d, 0x5f, 0xab, 0x1b, 0xd0, 0x6c, 0x6e, 0x17, 0x34, 0xa9, 0xcf, 0xd7, 0x6e, 0xf8, 0x77, 0xd8,
-	0x93, 0xd5, 0x34, 0x98, 0xc5, 0xa9, 0xcf, 0xe7, 0xa1, 0x01, 0xb2, 0xdc, 0xd8, 0x28, 0x37, 0x97,
-	0x3c, 0x91, 0xd3, 0x56, 0x57, 0x6c, 0xc1, 0x61, 0xe6, 0xcf, 0x22, 0xca, 0xf3, 0x94, 0xad, 0x75,
-	0xd9, 0x95, 0x5d, 0xbe, 0x5b, 0xeb, 0x62, 0x2f, 0x55, 0x0f, 0xad, 0x70, 0xf6, 0x04, 0x13, 0xb6,
-	0x98, 0xa6, 0x8c, 0x72, 0xe6, 0x72, 0x3f, 0x64, 0x6e, 0x44, 0xa3, 0x38, 0x33, 0x1a, 0xca, 0x16,
-	0x8a, 0x70, 0xfc, 0x90, 0x59, 0x02, 0x16, 0xda, 0x3c, 0xf1, 0x36, 0xb4, 0x7b, 0x4a, 0xab, 0x88,
-	0x07, 0xed, 0x19, 0xec, 0x26, 0xa9, 0xff, 0x59, 0x88, 0xef, 0xd9, 0xc2, 0xd8, 0x6f, 0x6a, 0xad,
-	0xdd, 0xd3, 0x67, 0x1d, 0xe5, 0xd9, 0xce, 0xd2, 0xb3, 0x1d, 0x33, 0x5a, 0x10, 0x28, 0x84, 0x43,
-	0xb6, 0xc0, 0x3f, 0xc0, 0x5e, 0x92, 0x4f, 0x02, 0x7f, 0x2a, 0xaa, 0x5c, 0x8f, 0xa5, 0x06, 0x6a,
-	0x6a, 0xad, 0x3a, 0xa9, 0x2b, 0x74, 0xc8, 0x16, 0x97, 0x2c, 0xc5, 0x43, 0xc0, 0x41, 0x3c, 0x73,
-	0x33, 0x65, 0x39, 0x77, 0x2a, 0x3d, 0x67, 0x54, 0xe4, 0x8c, 0x97, 0x6b, 0x3b, 0xd8, 0x0c, 0xc1,
-	0x60, 0x8b, 0xa0, 0x60, 0x33, 0x18, 0x43, 0xc0, 0x21, 0x4d, 0x36, 0x9b, 0x55, 0x9f, 0x34, 0xdb,
-	0xf4, 0xb8, 0x68, 0x16, 0x6e, 0x60, 0xf8, 0x35, 0x18, 0x21, 0xfd, 0xe2, 0xa6, 0x71, 0xcc, 0x5d,
-	0x2f, 0x4f, 0xa9, 0x70, 0xa6, 0x1b, 0xfa, 0x41, 0xe0, 0x67, 0xc6, 0x81, 0xdc, 0xd4, 0x51, 0x48,
-	0xbf, 0x90, 0x38, 0xe6, 0x97, 0x05, 0x3b, 0x92, 0x24, 0x36, 0xa0, 0xea, 0xb1, 0x80, 0x71, 0xe6,
-	0x19, 0xb8, 0xa9, 0xb5, 0x74, 0xb2, 0xbc, 0x8a, 0xad, 0xab, 0xe3, 0xfa, 0xd6, 0x0f, 0xd5, 0xd6,
-	0x15, 0xb1, 0xda, 0xfa, 0x05, 0x82, 0xbd, 0xc7, 0xef, 0xb8, 0x29, 0xeb, 0x75, 0xd4, 0x38, 0xf9,
-	0x57, 0x53, 0x71, 0x1c, 0x30, 0xea, 0xfd, 0x7f, 0x1c, 0x8f, 0x41, 0xe7, 0x59, 0x31, 0x40, 0x05,
-	0xb2, 0xca, 0x33, 0xf5, 0xef, 0x7c, 0x59, 0x84, 0x2b, 0xf3, 0xbf, 0xaa, 0x5c, 0x96, 0x54, 0x8e,
-	0x6c, 0xff, 0x2b, 0x13, 0xa4, 0x7c, 0xb0, 0x70, 0xaa, 0x4c, 0x66, 0x9d, 0xe8, 0x02, 0x10, 0x46,
-	0xc6, 0xdf, 0x42, 0x6d, 0x65, 0x3b, 0x69, 0xf6, 0x3a, 0x79, 0x00, 0xf0, 0xf7, 0xd0, 0x90, 0x7d,
-	0x53, 0xf6, 0xd9, 0xcf, 0x44, 0xb0, 0x2b, 0xb2, 0x77, 0x5d, 0x80, 0xa4, 0xc0, 0xf0, 0x0b, 0xd0,
-	0x43, 0xc6, 0xa9, 0x47, 0x39, 0x95, 0x69, 0xab, 0x93, 0xd5, 0xfd, 0xa6, 0xac, 0xef, 0xa0, 0xca,
-	0x4d, 0x59, 0xd7, 0x51, 0xed, 0xa6, 0xac, 0x57, 0x91, 0xde, 0x7e, 0x0b, 0xb5, 0x55, 0x70, 0xf1,
-	0x37, 0x80, 0x6f, 0xad, 0xa1, 0x35, 0xfe, 0xd3, 0x72, 0x1d, 0xd2, 0xef, 0xbb, 0xb6, 0x63, 0x3a,
-	0x7d, 0xb4, 0x85, 0x01, 0x2a, 0x66, 0xcf, 0xb9, 0xfe, 0xa3, 0x8f, 0x34, 0x71, 0xbe, 0x22, 0xe3,
-	0x4f, 0x7d, 0x0b, 0x6d, 0xb7, 0x7f, 0x52, 0x7b, 0x92, 0x9f, 0x87, 0x5d, 0xa8, 0x16, 0xb5, 0x68,
-	0x0b, 0x57, 0xa1, 0xf4, 0x7e, 0xfc, 0x0e, 0x69, 0xe2, 0x30, 0x32, 0x3f, 0xa0, 0xed, 0xf6, 0x3f,
-	0x1a, 0xd4, 0xd7, 0x93, 0x8e, 0x8f, 0xe1, 0x68, 0x39, 0x6b, 0x60, 0xda, 0x03, 0xd7, 0x76, 0x88,
-	0xe9, 0xf4, 0xdf, 0x7d, 0x44, 0x5b, 0xb8, 0x0e, 0x3a, 0xb9, 0xea, 0xb9, 0xe7, 0x6f, 0xce, 0x4f,
-	0x91, 0x86, 0x0f, 0x61, 0xdf, 0xe9, 0xdb, 0x8e, 0x3b, 0x32, 0x3f, 0x48, 0x65, 0x9f, 0xa0, 0x6d,
-	0x51, 0x3d, 0xbe, 0xb8, 0xe9, 0xf7, 0x1c, 0x97, 0x5c, 0xf5, 0x84, 0xd0, 0xb5, 0x07, 0xe6, 0xe9,
-	0xd9, 0x39, 0x2a, 0xe1, 0x23, 0x38, 0xe8, 0x8d, 0xad, 0xeb, 0xa1, 0x2d, 0xa0, 0xb3, 0x5f, 0x4f,
-	0x5d, 0x01, 0x97, 0xf1, 0x01, 0x34, 0x1e, 0x60, 0x01, 0xed, 0xb4, 0x7f, 0x84, 0xc6, 0xa3, 0xaf,
-	0x07, 0xd6, 0xa1, 0x6c, 0x8d, 0xad, 0xe2, 0xc5, 0x85, 0xac, 0xdc, 0x7e, 0x0d, 0xf8, 0xe9, 0xe7,
-	0x01, 0x37, 0xa0, 0x66, 0x5a, 0x63, 0xeb, 0xe3, 0x68, 0x7c, 0x6b, 0xab, 0x17, 0x13, 0xdb, 0x44,
-	0x1a, 0xae, 0xc1, 0x4e, 0xbf, 0x77, 0x69, 0x9b, 0xa8, 0x74, 0xf1, 0xf6, 0xd3, 0x9b, 0x99, 0xcf,
-	0xe7, 0xf9, 0xa4, 0x33, 0x8d, 0xc3, 0x6e, 0xf1, 0x03, 0xc4, 0x53, 0x61, 0x61, 0x1a, 0x75, 0x0b,
-	0xeb, 0x75, 0xa7, 0x41, 0x9c, 0x7b, 0x45, 0x70, 0xba, 0xab, 0x00, 0x4d, 0x2a, 0x32, 0xf5, 0xaf,
-	0xfe, 0x0b, 0x00, 0x00, 0xff, 0xff, 0xba, 0x57, 0xcd, 0x18, 0xd3, 0x06, 0x00, 0x00,
+func init() {
+	proto.RegisterFile("storage/cloudspanner/spannerpb/spanner.proto", fileDescriptor_b439183f89a9cab9)
+}
+
+var fileDescriptor_b439183f89a9cab9 = []byte{
+	// 1377 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x56, 0x6d, 0x53, 0xdb, 0xc6,
+	0x16, 0xc6, 0xd8, 0xd8, 0xf2, 0xb1, 0x0d, 0x62, 0x81, 0x20, 0x48, 0x72, 0xe3, 0xcb, 0xcd, 0xbd,
+	0x43, 0x98, 0x8c, 0xb9, 0x85, 0x42, 0x9a, 0xa6, 0x33, 0x1d, 0x61, 0x9c, 0xf0, 0x6a, 0xd3, 0x95,
+	0x68, 0x9b, 0x7c, 0xd1, 0xac, 0xad, 0xc5, 0xd6, 0x58, 0x6f, 0xd5, 0xae, 0x93, 0x38, 0xdf, 0xfa,
+	0x13, 0xfa, 0x07, 0xfa, 0xab, 0xfa, 0x83, 0x3a, 0xbb, 0x2b, 0xdb, 0xc2, 0x4c, 0xfa, 0xc9, 0xbb,
+	0xcf, 0xf3, 0x9c, 0xa3, 0xdd, 0xb3, 0xe7, 0xc5, 0xf0, 0x92, 0xf1, 0x28, 0x21, 0x7d, 0xba, 0xdf,
+	0xf3, 0xa3, 0x91, 0xcb, 0x62, 0x12, 0x86, 0x34, 0xd9, 0x4f, 0x7f, 0xe3, 0xee, 0x64, 0xd5, 0x88,
+	0x93, 0x88, 0x47, 0xa8, 0x3c, 0x25, 0xb6, 0xb7, 0xfa, 0x51, 0xd4, 0xf7, 0xe9, 0xbe, 0x24, 0xba,
+	0xa3, 0xbb, 0x7d, 0x12, 0x8e, 0x95, 0x6a, 0xfb, 0xd9, 0xc4, 0x67, 0xfa, 0x1b, 0x77, 0x27, 0x2b,
+	0x25, 0xd8, 0xf1, 0x41, 0xbf, 0x8a, 0xfa, 0x96, 0xc2, 0x9a, 0x51, 0x78, 0xe7, 0xf5, 0xd1, 0x1e,
+	0xac, 0x86, 0xa3, 0xc0, 0x19, 0x85, 0x8c, 0xfe, 0xe6, 0x74, 0x47, 0xbd, 0x21, 0xe5, 0xcc, 0xc8,
+	0xd5, 0x73, 0xbb, 0x79, 0xbc, 0x12, 0x8e, 0x82, 0x5b, 0x81, 0x9f, 0x28, 0x18, 0xbd, 0x04, 0x24,
+	0xb4, 0x01, 0x4d, 0x86, 0x3e, 0x9d, 0x8a, 0x17, 0xa5, 0x58, 0x0f, 0x47, 0xc1, 0xb5, 0x24, 0x52,
+	0xf5, 0xce, 0xef, 0x39, 0xd0, 0xaf, 0x49, 0x7c, 0xff, 0x73, 0x2d, 0xd0, 0x7d, 0x4a, 0xee, 0x9c,
+	0x5e, 0x14, 0xc4, 0x09, 0x65, 0xcc, 0x8b, 0x42, 0xf9, 0xb5, 0xe5, 0x83, 0xed, 0xc6, 0xf4, 0xd8,
+	0x8d, 0x2b, 0x4a, 0xee, 0x9a, 0x33, 0x05, 0x5e, 0xf1, 0xef, 0x03, 0xe8, 0x7f, 0x20, 0x21, 0x67,
+	0x40, 0xd8, 0xc0, 0xf1, 0x42, 0x97, 0x7e, 0x96, 0xc7, 0xd0, 0x70, 0x4d, 0xc0, 0x67, 0x84, 0x0d,
+	0xce, 0x05, 0xb8, 0xf3, 0x57, 0x15, 0x34, 0x3b, 0xa1, 0xf4, 0x3c, 0xbc, 0x8b, 0xd0, 0x26, 0x94,
+	0x78, 0x42, 0xa9, 0xe3, 0xb9, 0xe9, 0x05, 0x8b, 0x62, 0x7b, 0xee, 0xa2, 0x0d, 0x28, 0x0e, 0xe9,
+	0x58, 0xe0, 0xea, 0x2e, 0x4b, 0x43, 0x3a, 0x3e, 0x77, 0x11, 0x82, 0x42, 0x48, 0x02, 0x6a, 0xe4,
+	0xeb, 0xb9, 0xdd, 0x32, 0x96, 0x6b, 0x54, 0x87, 0x8a, 0x4b, 0x59, 0x2f, 0xf1, 0x62, 0x2e, 0x8e,
+	0x5e, 0x90, 0x54, 0x16, 0x42, 0xff, 0x87, 0xb2, 0xfc, 0x0a, 0x1f, 0xc7, 0xd4, 0x58, 0x92, 0x57,
+	0x5b, 0x6b, 0x4c, 0xdf, 0xaf, 0x21, 0x4e, 0x63, 0x8f, 0x63, 0x8a, 0x35, 0x9e, 0xae, 0xd0, 0x21,
+	0x80, 0xb4, 0x60, 0x9c, 0x70, 0x6a, 0x68, 0xd2, 0x64, 0x7d, 0xce, 0xc4, 0x12, 0x1c, 0x2e, 0xf3,
+	0xc9, 0x12, 0xfd, 0x00, 0x35, 0x79, 0x79, 0xc6, 0x13, 0xc2, 0x69, 0x7f, 0x6c, 0x94, 0xa5, 0xdd,
+	0x66, 0xc6, 0x4e, 0x84, 0xc1, 0x4a, 0x69, 0x5c, 0x1d, 0x64, 0x76, 0xe8, 0x47, 0x58, 0x96, 0xd6,
+	0xc4, 0xef, 0x47, 0x89, 0xc7, 0x07, 0x81, 0x01, 0xd2, 0xdc, 0x98, 0x33, 0x37, 0x27, 0x3c, 0xae,
+	0x0d, 0xb2, 0x5b, 0xd4, 0x86, 0x35, 0xe6, 0xf5, 0x43, 0xc2, 0x47, 0x09, 0xcd, 0x78, 0xa9, 0x48,
+	0x2f, 0x4f, 0x33, 0x5e, 0xac, 0x89, 0x6a, 0xe6, 0x0a, 0xb1, 0x07, 0x98, 0x48, 0xc3, 0x5e, 0x42,
+	0x09, 0xa7, 0x0e, 0xf7, 0x02, 0xea, 0x84, 0x24, 0x8c, 0x98, 0x51, 0x53, 0x69, 0xa8, 0x08, 0xdb,
+	0x0b, 0x68, 0x5b, 0xc0, 0x42, 0x3b, 0x8a, 0xdd, 0x39, 0xed, 0xb2, 0xd2, 0x2a, 0x62, 0xa6, 0x3d,
+	0x82, 0x4a, 0x9c, 0x78, 0x1f, 0x85, 0x78, 0x48, 0xc7, 0xc6, 0x4a, 0x3d, 0xb7, 0x5b, 0x39, 0x58,
+	0x6f, 0xa8, 0x22, 0x6a, 0x4c, 0x8a, 0xa8, 0x61, 0x86, 0x63, 0x0c, 0xa9, 0xf0, 0x92, 0x8e, 0xd1,
+	0x73, 0x58, 0x8e, 0x47, 0x5d, 0xdf, 0xeb, 0x09, 0x2b, 0xc7, 0xa5, 0x89, 0xa1, 0xd7, 0x73, 0xbb,
+	0x55, 0x5c, 0x55, 0xe8, 0x25, 0x1d, 0x9f, 0xd2, 0x04, 0x5d, 0x02, 0xf2, 0xa3, 0xbe, 0x93, 0xe6,
+	0xad, 0xd3, 0x93, 0x29, 0x6e, 0x14, 0xe5, 0x37, 0x1e, 0x67, 0x62, 0x30, 0x5f, 0x74, 0x67, 0x0b,
+	0x58, 0xf7, 0xe7, 0x30, 0xe1, 0x2c, 0x20, 0xf1, 0xbc, 0xb3, 0xd2, 0x03, 0x67, 0xf3, 0x25, 0x25,
+	0x9c, 0x05, 0x73, 0x18, 0x7a, 0x05, 0x46, 0x40, 0x3e, 0x3b, 0x49, 0x14, 0x71, 0xc7, 0x1d, 0x25,
+	0x44, 0x64, 0xa6, 0x13, 0x78, 0xbe, 0xef, 0x31, 0x63, 0x55, 0x46, 0x6a, 0x23, 0x20, 0x9f, 0x71,
+	0x14, 0xf1, 0xd3, 0x94, 0xbd, 0x96, 0x24, 0x32, 0xa0, 0xe4, 0x52, 0x9f, 0x72, 0xea, 0x1a, 0x48,
+	0x16, 0xd4, 0x64, 0x2b, 0xa2, 0xae, 0x96, 0xd9, 0xa8, 0xaf, 0xa9, 0xa8, 0x2b, 0x62, 0x16, 0xf5,
+	0x17, 0xa0, 0x27, 0x94, 0x13, 0x2f, 0x74, 0x12, 0xfa, 0xd1, 0x13, 0x15, 0xcb, 0x8c, 0x75, 0x25,
+	0x55, 0x38, 0x9e, 0xc0, 0xe8, 0x5b, 0x78, 0x94, 0x4a, 0xe7, 0xcf, 0xb9, 0x21, 0x0d, 0xd6, 0x15,
+	0x3b, 0x77, 0xcc, 0xe7, 0xb0, 0x2c, 0x82, 0x25, 0x2b, 0xdf, 0xe9, 0x7a, 0x9c, 0x19, 0x8f, 0xea,
+	0xb9, 0xdd, 0x25, 0x5c, 0x0d, 0x48, 0x2c, 0x2b, 0xff, 0xc4, 0xe3, 0x0c, 0x1d, 0xc0, 0x86, 0x3b,
+	0x8a, 0x7d, 0xaf, 0x27, 0x9e, 0x5f, 0xf6, 0x8b, 0x38, 0xf2, 0xbd, 0xde, 0xd8, 0xd8, 0x94, 0xe2,
+	0xb5, 0x29, 0x29, 0xfa, 0xcd, 0x8d, 0xa4, 0xd0, 0x15, 0x6c, 0x12, 0xd7, 0xf5, 0xc4, 0xb7, 0x88,
+	0xef, 0x64, 0x72, 0x87, 0x19, 0x46, 0x3d, 0xff, 0xd5, 0xe4, 0xd9, 0x98, 0x19, 0xdd, 0x4c, 0xd3,
+	0x88, 0xa1, 0x37, 0xb0, 0x9d, 0xf5, 0x76, 0x2f, 0xa5, 0x98, 0xb1, 0x55, 0xcf, 0xef, 0x56, 0x71,
+	0xe6, 0x7b, 0x37, 0x99, 0xec, 0x62, 0x68, 0x3f, 0x5b, 0x63, 0x7c, 0x90, 0x50, 0x36, 0x88, 0x7c,
+	0xd7, 0xd8, 0x96, 0x87, 0x9f, 0x15, 0x91, 0x3d, 0x61, 0xd0, 0x2b, 0x28, 0xfa, 0xa4, 0x4b, 0x7d,
+	0x66, 0x3c, 0x96, 0x47, 0x7d, 0x36, 0xd7, 0x44, 0x44, 0x17, 0x6c, 0x5c, 0x49, 0x45, 0x2b, 0xe4,
+	0xc9, 0x18, 0xa7, 0x72, 0x74, 0x0c, 0x9b, 0xe9, 0xdb, 0x26, 0x94, 0xd3, 0x30, 0xfb, 0x0a, 0x4f,
+	0x54, 0xb6, 0x28, 0x1a, 0x4f, 0xd8, 0xf4, 0x19, 0xfe, 0x0d, 0x55, 0x71, 0x99, 0x24, 0xe2, 0xf2,
+	0x71, 0x8c, 0xa7, 0xb2, 0x48, 0x2a, 0x43, 0x3a, 0xc6, 0x29, 0x84, 0x0e, 0xc5, 0xfb, 0x12, 0xd7,
+	0xe1, 0xd1, 0x90, 0x86, 0xcc, 0x89, 0x69, 0xe2, 0x30, 0xda, 0x8b, 0x42, 0xd7, 0xf8, 0x97, 0xf4,
+	0xbc, 0x26, 0x58, 0x5b, 0x92, 0x37, 0x34, 0xb1, 0x24, 0x85, 0x8e, 0x60, 0xf3, 0x53, 0xe2, 0x89,
+	0x54, 0x7b, 0x60, 0xf5, 0x4c, 0x65, 0x85, 0xa4, 0xe7, 0xcd, 0xd6, 0x61, 0x29, 0xfa, 0x14, 0xd2,
+	0xc4, 0xa8, 0xcb, 0xb6, 0xac, 0x36, 0xdb, 0xaf, 0xa1, 0x92, 0xb9, 0x33, 0xd2, 0x21, 0x2f, 0x3a,
+	0x41, 0x4e, 0x4a, 0xc4, 0x52, 0x98, 0x7d, 0x24, 0xfe, 0x88, 0xca, 0xee, 0x5f, 0xc6, 0x6a, 0xf3,
+	0xfd, 0xe2, 0x77, 0xb9, 0x13, 0x1d, 0x96, 0xef, 0xd7, 0xe3, 0x45, 0x41, 0xab, 0xea, 0xb5, 0x9d,
+	0x3f, 0x17, 0xd5, 0x58, 0x39, 0xa3, 0xc4, 0xfd, 0xfa, 0x58, 0xd9, 0x02, 0x8d, 0xb3, 0xb4, 0x50,
+	0xd4, 0x60, 0x29, 0x71, 0xa6, 0x0a, 0xe4, 0x71, 0x3a, 0x24, 0x98, 0xf7, 0x45, 0xcd, 0x97, 0xbc,
+	0x9a, 0x07, 0x96, 0xf7, 0x85, 0x0a, 0x52, 0x16, 0xae, 0xe8, 0xb8, 0x72, 0xc2, 0x54, 0xb1, 0x26,
+	0x00, 0xd1, 0x90, 0xd1, 0x13, 0x28, 0x4f, 0x5f, 0x5e, 0x36, 0xed, 0x2a, 0x9e, 0x01, 0xe8, 0x3f,
+	0x50, 0x93, 0x7e, 0x27, 0x65, 0x27, 0x9b, 0x51, 0x1e, 0x57, 0x05, 0x38, 0xa9, 0x39, 0xb4, 0x0d,
+	0x5a, 0x40, 0x39, 0x71, 0x09, 0x27, 0x72, 0x6a, 0x54, 0xf1, 0x74, 0x8f, 0x0e, 0x21, 0x93, 0xc9,
+	0xce, 0xd4, 0x31, 0x33, 0x2a, 0x32, 0x57, 0xd7, 0x67, 0xe4, 0xb4, 0xb1, 0xb3, 0x8b, 0x82, 0xb6,
+	0xa4, 0x17, 0x2f, 0x0a, 0x9a, 0xa6, 0x97, 0x2f, 0x0a, 0x5a, 0x49, 0xd7, 0xf6, 0x7e, 0x85, 0xf2,
+	0x74, 0x6a, 0xa1, 0x47, 0x80, 0x6e, 0xdb, 0x97, 0xed, 0xce, 0x2f, 0x6d, 0xc7, 0xc6, 0xad, 0x96,
+	0x63, 0xd9, 0xa6, 0xdd, 0xd2, 0x17, 0x10, 0x40, 0xd1, 0x6c, 0xda, 0xe7, 0x3f, 0xb7, 0xf4, 0x9c,
+	0x58, 0xbf, 0xc5, 0x9d, 0x0f, 0xad, 0xb6, 0xbe, 0x88, 0x56, 0xa0, 0xf2, 0xd3, 0xad, 0x89, 0xcd,
+	0xb6, 0x7d, 0xde, 0x6e, 0x9d, 0xea, 0x45, 0x41, 0xde, 0x98, 0xb7, 0x56, 0xeb, 0x54, 0x2f, 0xed,
+	0xbd, 0x50, 0x91, 0x97, 0x83, 0xb3, 0x02, 0xa5, 0xd4, 0xb1, 0xbe, 0x80, 0x4a, 0x90, 0xbf, 0xea,
+	0xbc, 0xd3, 0x73, 0x62, 0x71, 0x6d, 0xde, 0xe8, 0x8b, 0x7b, 0x7f, 0xe4, 0xa0, 0x9a, 0x9d, 0x81,
+	0x68, 0x0b, 0x36, 0x26, 0x07, 0x39, 0x33, 0xad, 0x33, 0xc7, 0xb2, 0xb1, 0x69, 0xb7, 0xde, 0xbd,
+	0xd7, 0x17, 0x50, 0x15, 0x34, 0xfc, 0xb6, 0xe9, 0x1c, 0xbf, 0x3e, 0x3e, 0xd0, 0x73, 0x68, 0x0d,
+	0x56, 0xec, 0x96, 0x65, 0x3b, 0xd7, 0xe6, 0x8d, 0x54, 0xb6, 0xb0, 0xbe, 0x28, 0xac, 0x3b, 0x27,
+	0x17, 0xad, 0xa6, 0xed, 0xe0, 0xb7, 0x4d, 0x21, 0x74, 0xac, 0x33, 0xf3, 0xe0, 0xe8, 0x58, 0xcf,
+	0xa3, 0x0d, 0x58, 0x6d, 0x76, 0xda, 0xe7, 0x97, 0x96, 0x80, 0x8e, 0xbe, 0x39, 0x70, 0x04, 0x5c,
+	0x40, 0xab, 0x50, 0x9b, 0xc1, 0x02, 0x5a, 0xda, 0xfb, 0x2f, 0xd4, 0xee, 0xcd, 0x55, 0xa4, 0x41,
+	0xa1, 0xdd, 0x69, 0xa7, 0xe1, 0x48, 0x65, 0x85, 0xbd, 0x57, 0x80, 0x1e, 0x0e, 0x4e, 0x54, 0x83,
+	0xb2, 0xd9, 0xee, 0xb4, 0xdf, 0x5f, 0x77, 0x6e, 0x2d, 0x75, 0x63, 0x6c, 0x99, 0x7a, 0x0e, 0x95,
+	0x61, 0xa9, 0xd5, 0x3c, 0xb5, 0x4c, 0x3d, 0x7f, 0xf2, 0xe6, 0xc3, 0xeb, 0xbe, 0xc7, 0x07, 0xa3,
+	0x6e, 0xa3, 0x17, 0x05, 0xfb, 0xe9, 0x7f, 0x45, 0x9e, 0x88, 0x72, 0x25, 0xe1, 0xfe, 0x3f, 0xff,
+	0xe9, 0xec, 0x16, 0x65, 0x4b, 0x3b, 0xfc, 0x7b, 0x00, 0xc4, 0x07, 0x5d, 0xa3, 0x9d, 0x0a, 0x00,
+	0x00,
 }
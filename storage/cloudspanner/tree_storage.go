@@ -29,7 +29,9 @@ import (
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cache"
 	"github.com/google/trillian/storage/cloudspanner/spannerpb"
+	"github.com/google/trillian/storage/querytag"
 	"github.com/google/trillian/storage/storagepb"
+	"github.com/google/trillian/storage/subtreecodec"
 	"github.com/google/trillian/storage/tree"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -81,8 +83,21 @@ type TreeStorageOptions struct {
 	// to help with performance.
 	// See https://cloud.google.com/spanner/docs/timestamp-bounds for more details.
 	ReadOnlyStaleness time.Duration
+
+	// SubtreeCodec is the codec with which subtrees are written. Subtrees are
+	// read with whichever codec they were written with, so it can be changed
+	// at any time.
+	SubtreeCodec subtreecodec.Codec
+
+	// QueryTags appends the querytag tags of the context of each SQL query to
+	// its text, so that queries can be attributed to requests in query
+	// statistics. Reads by key are not tagged.
+	QueryTags bool
 }
 
+// SubtreeCodecs are the subtree codecs supported by the CloudSpanner storage.
+var SubtreeCodecs = []subtreecodec.Codec{subtreecodec.Proto, subtreecodec.Packed, subtreecodec.Deflate}
+
 func newTreeStorageWithOpts(client *spanner.Client, opts TreeStorageOptions) *treeStorage {
 	return &treeStorage{client: client, admin: nil, opts: opts}
 }
@@ -95,21 +110,35 @@ type spanRead interface {
 	ReadWithOptions(ctx context.Context, table string, keys spanner.KeySet, columns []string, opts *spanner.ReadOptions) (ri *spanner.RowIterator)
 }
 
+// tag returns stmt with the tags of ctx appended to its SQL if QueryTags is
+// set, using treeID if ctx has no tree ID.
+func (t *treeStorage) tag(ctx context.Context, treeID int64, stmt spanner.Statement) spanner.Statement {
+	if !t.opts.QueryTags {
+		return stmt
+	}
+	tags := querytag.FromContext(ctx)
+	if tags.TreeID == 0 {
+		tags.TreeID = treeID
+	}
+	stmt.SQL = tags.Append(stmt.SQL)
+	return stmt
+}
+
 // latestSTH reads and returns the newest STH.
 func (t *treeStorage) latestSTH(ctx context.Context, stx spanRead, treeID int64) (*spannerpb.TreeHead, error) {
 	query := spanner.NewStatement(
-		"SELECT t.TreeID, t.TimestampNanos, t.TreeSize, t.RootHash, t.RootSignature, t.TreeRevision, t.TreeMetadata FROM TreeHeads t" +
+		"SELECT t.TreeID, t.TimestampNanos, t.TreeSize, t.RootHash, t.RootSignature, t.TreeRevision, t.TreeMetadata, t.AdditionalSignatures FROM TreeHeads t" +
 			"   WHERE t.TreeID = @tree_id" +
 			"   ORDER BY t.TreeRevision DESC " +
 			"   LIMIT 1")
 	query.Params["tree_id"] = treeID
 
 	var th *spannerpb.TreeHead
-	rows := stx.Query(ctx, query)
+	rows := stx.Query(ctx, t.tag(ctx, treeID, query))
 	defer rows.Stop()
 	err := rows.Do(func(r *spanner.Row) error {
 		tth := &spannerpb.TreeHead{}
-		if err := r.Columns(&tth.TreeId, &tth.TsNanos, &tth.TreeSize, &tth.RootHash, &tth.Signature, &tth.TreeRevision, &tth.Metadata); err != nil {
+		if err := r.Columns(&tth.TreeId, &tth.TsNanos, &tth.TreeSize, &tth.RootHash, &tth.Signature, &tth.TreeRevision, &tth.Metadata, &tth.AdditionalSignatures); err != nil {
 			return err
 		}
 
@@ -159,6 +188,12 @@ func (t *treeStorage) begin(ctx context.Context, tree *trillian.Tree, newCache n
 
 // getLatestRoot populates this TX with the newest tree root visible (when
 // taking read-staleness into account) by this transaction.
+// tag returns stmt with the tags of ctx appended to its SQL if QueryTags is
+// set.
+func (t *treeTX) tag(ctx context.Context, stmt spanner.Statement) spanner.Statement {
+	return t.ts.tag(ctx, t.treeID, stmt)
+}
+
 func (t *treeTX) getLatestRoot(ctx context.Context) error {
 	t.getLatestRootOnce.Do(func() {
 		t._currentSTH, t._currentSTHErr = t.ts.latestSTH(ctx, t.stx, t.treeID)
@@ -227,7 +262,7 @@ func (t *treeTX) storeSubtrees(ctx context.Context, sts []*storagepb.SubtreeProt
 		if st == nil {
 			continue
 		}
-		stBytes, err := proto.Marshal(st)
+		stBytes, err := subtreecodec.Marshal(st, t.ts.opts.SubtreeCodec)
 		if err != nil {
 			return err
 		}
@@ -255,6 +290,7 @@ func (t *treeTX) Commit(ctx context.Context) error {
 	t.mu.Lock()
 	defer func() {
 		t.stx = nil
+		t.cache.Release()
 		t.mu.Unlock()
 	}()
 
@@ -280,6 +316,7 @@ func (t *treeTX) Rollback() error {
 	t.mu.Lock()
 	defer func() {
 		t.stx = nil
+		t.cache.Release()
 		t.mu.Unlock()
 	}()
 
@@ -364,7 +401,7 @@ func (t *treeTX) getSubtree(ctx context.Context, rev int64, id tree.NodeID) (p *
 	stmt.Params["subtree_id"] = stID
 	stmt.Params["revision"] = rev
 
-	rows := t.stx.Query(ctx, stmt)
+	rows := t.stx.Query(ctx, t.tag(ctx, stmt))
 	err = rows.Do(func(r *spanner.Row) error {
 		if ret != nil {
 			return nil
@@ -376,7 +413,7 @@ func (t *treeTX) getSubtree(ctx context.Context, rev int64, id tree.NodeID) (p *
 		if err = r.Columns(&rRev, &stBytes); err != nil {
 			return err
 		}
-		if err = proto.Unmarshal(stBytes, &st); err != nil {
+		if _, err = subtreecodec.Unmarshal(stBytes, &st); err != nil {
 			return err
 		}
 
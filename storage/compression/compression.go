@@ -0,0 +1,247 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compression compresses the values of map leaves at rest.
+//
+// The compression of a map is set by the leaf_compression field of its
+// storage_settings, and can't be changed after the map is created. The map
+// server wraps the transactions of such maps with WrapTX and WrapReadOnlyTX,
+// so leaf values are compressed before they are stored and decompressed as
+// they are read. Leaf hashes are always computed over the uncompressed
+// values, and empty values, which delete leaves, are stored as they are.
+package compression
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/snappy"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
+	"github.com/google/trillian/storage/zstd"
+)
+
+// Codec compresses and decompresses leaf values.
+type Codec interface {
+	// Compress returns the compressed form of value.
+	Compress(value []byte) ([]byte, error)
+	// Decompress returns the value which was compressed into data.
+	Decompress(data []byte) ([]byte, error)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[storagepb.LeafCompression]Codec{
+		storagepb.LeafCompression_SNAPPY: snappyCodec{},
+		storagepb.LeafCompression_ZSTD:   zstdCodec{},
+	}
+)
+
+// RegisterCodec makes codec available for maps whose leaves are compressed
+// with c, replacing any codec already registered for it. SNAPPY and ZSTD are
+// built in, and can be replaced by codecs producing compatible data.
+func RegisterCodec(c storagepb.LeafCompression, codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c] = codec
+}
+
+// NewCodec returns the codec registered for c, or nil for NO_COMPRESSION.
+func NewCodec(c storagepb.LeafCompression) (Codec, error) {
+	if c == storagepb.LeafCompression_NO_COMPRESSION {
+		return nil, nil
+	}
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	codec, ok := codecs[c]
+	if !ok {
+		return nil, fmt.Errorf("no codec registered for leaf compression %v", c)
+	}
+	return codec, nil
+}
+
+// settings is implemented by the storage settings messages which configure
+// leaf compression.
+type settings interface {
+	GetLeafCompression() storagepb.LeafCompression
+}
+
+// ForTree returns the codec which compresses the leaves of tree, or nil if
+// they are not compressed.
+func ForTree(tree *trillian.Tree) (Codec, error) {
+	if tree.TreeType != trillian.TreeType_MAP || tree.StorageSettings == nil {
+		return nil, nil
+	}
+	var any ptypes.DynamicAny
+	if err := ptypes.UnmarshalAny(tree.StorageSettings, &any); err != nil {
+		return nil, fmt.Errorf("invalid storage_settings: %v", err)
+	}
+	s, ok := any.Message.(settings)
+	if !ok {
+		return nil, nil
+	}
+	return NewCodec(s.GetLeafCompression())
+}
+
+// snappyCodec compresses leaf values with Snappy.
+type snappyCodec struct{}
+
+func (snappyCodec) Compress(value []byte) ([]byte, error) {
+	return snappy.Encode(nil, value), nil
+}
+
+func (snappyCodec) Decompress(data []byte) ([]byte, error) {
+	return snappy.Decode(nil, data)
+}
+
+// zstdCodec compresses leaf values with Zstandard.
+type zstdCodec struct{}
+
+func (zstdCodec) Compress(value []byte) ([]byte, error) {
+	return zstd.Compress(nil, value)
+}
+
+func (zstdCodec) Decompress(data []byte) ([]byte, error) {
+	return zstd.Decompress(data)
+}
+
+// WrapTX returns a MapTreeTX which compresses the values of the leaves set
+// through tx with codec, and decompresses those read. If codec is nil, tx is
+// returned unchanged.
+func WrapTX(tx storage.MapTreeTX, codec Codec) storage.MapTreeTX {
+	if codec == nil {
+		return tx
+	}
+	return &mapTreeTX{
+		MapTreeTX: tx,
+		reader:    &readOnlyMapTreeTX{ReadOnlyMapTreeTX: tx, codec: codec},
+		codec:     codec,
+	}
+}
+
+// WrapReadOnlyTX returns a ReadOnlyMapTreeTX which decompresses the values of
+// the leaves read through tx with codec. If codec is nil, tx is returned
+// unchanged.
+func WrapReadOnlyTX(tx storage.ReadOnlyMapTreeTX, codec Codec) storage.ReadOnlyMapTreeTX {
+	if codec == nil {
+		return tx
+	}
+	return &readOnlyMapTreeTX{ReadOnlyMapTreeTX: tx, codec: codec}
+}
+
+type readOnlyMapTreeTX struct {
+	storage.ReadOnlyMapTreeTX
+	codec Codec
+}
+
+func (t *readOnlyMapTreeTX) Get(ctx context.Context, revision int64, keyHashes [][]byte) ([]*trillian.MapLeaf, error) {
+	leaves, err := t.ReadOnlyMapTreeTX.Get(ctx, revision, keyHashes)
+	if err != nil {
+		return nil, err
+	}
+	if err := decompressLeaves(t.codec, leaves); err != nil {
+		return nil, err
+	}
+	return leaves, nil
+}
+
+func (t *readOnlyMapTreeTX) List(ctx context.Context, revision int64, after []byte, limit int) ([]*trillian.MapLeaf, error) {
+	leaves, err := t.ReadOnlyMapTreeTX.List(ctx, revision, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := decompressLeaves(t.codec, leaves); err != nil {
+		return nil, err
+	}
+	return leaves, nil
+}
+
+func (t *readOnlyMapTreeTX) ListLeafVersions(ctx context.Context, after []byte, limit int) ([]storage.MapLeafVersion, error) {
+	versions, err := t.ReadOnlyMapTreeTX.ListLeafVersions(ctx, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := decompressVersions(t.codec, versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// mapTreeTX reads leaves through reader, which wraps the same transaction.
+type mapTreeTX struct {
+	storage.MapTreeTX
+	reader *readOnlyMapTreeTX
+	codec  Codec
+}
+
+func (t *mapTreeTX) Get(ctx context.Context, revision int64, keyHashes [][]byte) ([]*trillian.MapLeaf, error) {
+	return t.reader.Get(ctx, revision, keyHashes)
+}
+
+func (t *mapTreeTX) List(ctx context.Context, revision int64, after []byte, limit int) ([]*trillian.MapLeaf, error) {
+	return t.reader.List(ctx, revision, after, limit)
+}
+
+func (t *mapTreeTX) ListLeafVersions(ctx context.Context, after []byte, limit int) ([]storage.MapLeafVersion, error) {
+	return t.reader.ListLeafVersions(ctx, after, limit)
+}
+
+// Set stores a copy of value with its LeafValue compressed, leaving value
+// unchanged.
+func (t *mapTreeTX) Set(ctx context.Context, keyHash []byte, value *trillian.MapLeaf) error {
+	if len(value.LeafValue) > 0 {
+		compressed, err := t.codec.Compress(value.LeafValue)
+		if err != nil {
+			return fmt.Errorf("failed to compress leaf %x: %v", value.Index, err)
+		}
+		value = proto.Clone(value).(*trillian.MapLeaf)
+		value.LeafValue = compressed
+	}
+	return t.MapTreeTX.Set(ctx, keyHash, value)
+}
+
+// decompressLeaves decompresses the values of leaves in place.
+func decompressLeaves(codec Codec, leaves []*trillian.MapLeaf) error {
+	for _, l := range leaves {
+		if err := decompressLeaf(codec, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decompressVersions(codec Codec, versions []storage.MapLeafVersion) error {
+	for _, v := range versions {
+		if err := decompressLeaf(codec, v.Leaf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decompressLeaf(codec Codec, l *trillian.MapLeaf) error {
+	if len(l.LeafValue) == 0 {
+		return nil
+	}
+	value, err := codec.Decompress(l.LeafValue)
+	if err != nil {
+		return fmt.Errorf("failed to decompress leaf %x: %v", l.Index, err)
+	}
+	l.LeafValue = value
+	return nil
+}
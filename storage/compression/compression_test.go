@@ -0,0 +1,189 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
+)
+
+func TestForTree(t *testing.T) {
+	mustMarshal := func(pb proto.Message) *trillian.Tree {
+		t.Helper()
+		settings, err := ptypes.MarshalAny(pb)
+		if err != nil {
+			t.Fatalf("MarshalAny(): %v", err)
+		}
+		return &trillian.Tree{TreeType: trillian.TreeType_MAP, StorageSettings: settings}
+	}
+	snappySettings := &storagepb.MapStorageSettings{LeafCompression: storagepb.LeafCompression_SNAPPY}
+	snappyLog := mustMarshal(snappySettings)
+	snappyLog.TreeType = trillian.TreeType_LOG
+
+	for _, test := range []struct {
+		desc      string
+		tree      *trillian.Tree
+		wantCodec bool
+		wantErr   bool
+	}{
+		{desc: "noSettings", tree: &trillian.Tree{TreeType: trillian.TreeType_MAP}},
+		{desc: "otherSettings", tree: mustMarshal(&empty.Empty{})},
+		{desc: "noCompression", tree: mustMarshal(&storagepb.MapStorageSettings{})},
+		{desc: "snappy", tree: mustMarshal(snappySettings), wantCodec: true},
+		{desc: "zstd", tree: mustMarshal(&storagepb.MapStorageSettings{LeafCompression: storagepb.LeafCompression_ZSTD}), wantCodec: true},
+		{desc: "log", tree: snappyLog},
+		{desc: "unregistered", tree: mustMarshal(&storagepb.MapStorageSettings{LeafCompression: storagepb.LeafCompression(99)}), wantErr: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			codec, err := ForTree(test.tree)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("ForTree() = (_, %v), want err %v", err, test.wantErr)
+			}
+			if got := codec != nil; got != test.wantCodec {
+				t.Errorf("ForTree() = %v, want codec %v", codec, test.wantCodec)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, c := range []storagepb.LeafCompression{storagepb.LeafCompression_SNAPPY, storagepb.LeafCompression_ZSTD} {
+		t.Run(c.String(), func(t *testing.T) {
+			codec, err := NewCodec(c)
+			if err != nil {
+				t.Fatalf("NewCodec(): %v", err)
+			}
+			value := bytes.Repeat([]byte("value"), 100)
+			data, err := codec.Compress(value)
+			if err != nil {
+				t.Fatalf("Compress(): %v", err)
+			}
+			if len(data) >= len(value) {
+				t.Errorf("Compress() returned %d bytes, want fewer than %d", len(data), len(value))
+			}
+			got, err := codec.Decompress(data)
+			if err != nil {
+				t.Fatalf("Decompress(): %v", err)
+			}
+			if !bytes.Equal(got, value) {
+				t.Errorf("Decompress() = %x, want %x", got, value)
+			}
+			if _, err := codec.Decompress([]byte("not compressed")); err == nil {
+				t.Error("Decompress(garbage) = nil error, want error")
+			}
+		})
+	}
+}
+
+// reverseCodec "compresses" values by reversing them, to make stored values
+// easy to predict.
+type reverseCodec struct{}
+
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, c := range b {
+		r[len(b)-1-i] = c
+	}
+	return r
+}
+
+func (reverseCodec) Compress(value []byte) ([]byte, error)  { return reverse(value), nil }
+func (reverseCodec) Decompress(data []byte) ([]byte, error) { return reverse(data), nil }
+
+func TestWrapTX(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	if tx := storage.NewMockMapTreeTX(ctrl); WrapTX(tx, nil) != tx {
+		t.Error("WrapTX(tx, nil) != tx")
+	}
+
+	index, keys := []byte{1}, [][]byte{{1}}
+	stored := func() *trillian.MapLeaf { return &trillian.MapLeaf{Index: index, LeafValue: []byte("cba")} }
+	tx := storage.NewMockMapTreeTX(ctrl)
+	tx.EXPECT().Set(ctx, index, &trillian.MapLeaf{Index: index, LeafValue: []byte("cba"), LeafHash: []byte("h")}).Return(nil)
+	tx.EXPECT().Set(ctx, index, &trillian.MapLeaf{Index: index}).Return(nil)
+	tx.EXPECT().Get(ctx, int64(1), keys).Return([]*trillian.MapLeaf{stored(), {Index: []byte{2}}}, nil)
+	tx.EXPECT().List(ctx, int64(1), nil, 10).Return([]*trillian.MapLeaf{stored()}, nil)
+	tx.EXPECT().ListLeafVersions(ctx, nil, 10).Return([]storage.MapLeafVersion{{Revision: 1, Leaf: stored()}}, nil)
+
+	wrapped := WrapTX(tx, reverseCodec{})
+	leaf := &trillian.MapLeaf{Index: index, LeafValue: []byte("abc"), LeafHash: []byte("h")}
+	if err := wrapped.Set(ctx, index, leaf); err != nil {
+		t.Fatalf("Set(): %v", err)
+	}
+	if got, want := string(leaf.LeafValue), "abc"; got != want {
+		t.Errorf("Set() changed LeafValue to %q, want %q", got, want)
+	}
+	if err := wrapped.Set(ctx, index, &trillian.MapLeaf{Index: index}); err != nil {
+		t.Fatalf("Set(empty): %v", err)
+	}
+
+	leaves, err := wrapped.Get(ctx, 1, keys)
+	if err != nil {
+		t.Fatalf("Get(): %v", err)
+	}
+	if got := string(leaves[0].LeafValue); got != "abc" {
+		t.Errorf("Get()[0].LeafValue = %q, want %q", got, "abc")
+	}
+	if got := leaves[1].LeafValue; len(got) != 0 {
+		t.Errorf("Get()[1].LeafValue = %q, want empty", got)
+	}
+	leaves, err = wrapped.List(ctx, 1, nil, 10)
+	if err != nil {
+		t.Fatalf("List(): %v", err)
+	}
+	if got := string(leaves[0].LeafValue); got != "abc" {
+		t.Errorf("List()[0].LeafValue = %q, want %q", got, "abc")
+	}
+	versions, err := wrapped.ListLeafVersions(ctx, nil, 10)
+	if err != nil {
+		t.Fatalf("ListLeafVersions(): %v", err)
+	}
+	if got := string(versions[0].Leaf.LeafValue); got != "abc" {
+		t.Errorf("ListLeafVersions()[0].LeafValue = %q, want %q", got, "abc")
+	}
+}
+
+func TestWrapReadOnlyTX(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	if tx := storage.NewMockReadOnlyMapTreeTX(ctrl); WrapReadOnlyTX(tx, nil) != tx {
+		t.Error("WrapReadOnlyTX(tx, nil) != tx")
+	}
+
+	tx := storage.NewMockReadOnlyMapTreeTX(ctrl)
+	tx.EXPECT().Get(ctx, int64(1), [][]byte{{1}}).Return([]*trillian.MapLeaf{{Index: []byte{1}, LeafValue: []byte("cba")}}, nil)
+	codec, err := NewCodec(storagepb.LeafCompression_SNAPPY)
+	if err != nil {
+		t.Fatalf("NewCodec(): %v", err)
+	}
+	// "cba" is not valid Snappy data.
+	if _, err := WrapReadOnlyTX(tx, codec).Get(ctx, 1, [][]byte{{1}}); err == nil {
+		t.Error("Get() = nil error, want decompression error")
+	}
+}
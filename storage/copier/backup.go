@@ -0,0 +1,380 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package copier
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/compact"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// backupMagic starts every backup.
+const backupMagic = "TRILLIAN LOG BACKUP 1\n"
+
+// A backup is backupMagic followed by records, each of which is a tag, the
+// length of its message as a uvarint, and the message. The first record holds
+// the tree, and each of the following roots is preceded by the leaves it adds
+// and followed by its witness signatures. The last record is empty, so that
+// truncated backups are detected.
+const (
+	tagTree byte = 't' // trillian.Tree
+	tagLeaf byte = 'l' // trillian.LogLeaf
+	tagRoot byte = 'r' // trillian.SignedLogRoot
+	tagSig  byte = 's' // trillian.LogRootSignature
+	tagEnd  byte = 'e' // empty
+)
+
+// maxRecordSize bounds the size of the records read from a backup.
+const maxRecordSize = 64 << 20
+
+// BackupLog writes a backup of the log with treeID in p to w: its tree, and
+// its signed roots with their witness signatures and the leaves they add, up
+// to the latest root for which include returns true, or the latest root if
+// include is nil. Merkle nodes aren't backed up, as they are recomputed from
+// the leaves on restore.
+//
+// Backups hold the tree as stored, including its private key, so they must be
+// protected like the database.
+func BackupLog(ctx context.Context, p storage.Provider, treeID int64, include func(*types.LogRootV1) bool, w io.Writer) (Stats, error) {
+	var stats Stats
+	tree, err := storage.GetTree(ctx, p.AdminStorage(), treeID)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read tree %d: %v", treeID, err)
+	}
+	if tree.TreeType != trillian.TreeType_LOG && tree.TreeType != trillian.TreeType_PREORDERED_LOG {
+		return stats, fmt.Errorf("tree %d is a %s, only logs can be backed up", treeID, tree.TreeType)
+	}
+	hasher, err := hashers.NewLogHasher(tree.HashStrategy)
+	if err != nil {
+		return stats, err
+	}
+	ls := p.LogStorage()
+	latest, err := latestRoot(ctx, ls, tree)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read the latest root of tree %d: %v", treeID, err)
+	}
+	if latest == nil {
+		return stats, fmt.Errorf("tree %d has no root", treeID)
+	}
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(backupMagic); err != nil {
+		return stats, err
+	}
+	if err := writeRecord(bw, tagTree, tree); err != nil {
+		return stats, err
+	}
+	var size uint64
+	for rev := int64(0); rev <= int64(latest.Revision); rev++ {
+		var slr *trillian.SignedLogRoot
+		var sigs []*trillian.LogRootSignature
+		err := snapshot(ctx, ls, tree, func(tx storage.ReadOnlyLogTreeTX) error {
+			var err error
+			if slr, err = tx.GetSignedLogRoot(ctx, rev); err != nil {
+				return err
+			}
+			sigs, err = tx.GetLogRootSignatures(ctx, rev)
+			return err
+		})
+		if status.Code(err) == codes.NotFound {
+			continue
+		} else if err != nil {
+			return stats, fmt.Errorf("failed to read root %d of tree %d: %v", rev, treeID, err)
+		}
+		var root types.LogRootV1
+		if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+			return stats, err
+		}
+		if include != nil && !include(&root) {
+			break
+		}
+		if root.TreeSize < size {
+			return stats, fmt.Errorf("root %d of tree %d has tree size %d, smaller than the previous %d", rev, treeID, root.TreeSize, size)
+		}
+
+		for size < root.TreeSize {
+			leaves, err := readLeaves(ctx, ls, tree, size, root.TreeSize, DefaultBatchSize)
+			if err != nil {
+				return stats, fmt.Errorf("tree %d: %v", treeID, err)
+			}
+			if err := checkLeaves(hasher, leaves, size); err != nil {
+				return stats, fmt.Errorf("tree %d: %v", treeID, err)
+			}
+			for _, leaf := range leaves {
+				if err := writeRecord(bw, tagLeaf, leaf); err != nil {
+					return stats, err
+				}
+			}
+			size += uint64(len(leaves))
+			stats.Leaves += int64(len(leaves))
+		}
+		if err := writeRecord(bw, tagRoot, slr); err != nil {
+			return stats, err
+		}
+		for _, sig := range sigs {
+			if err := writeRecord(bw, tagSig, sig); err != nil {
+				return stats, err
+			}
+		}
+		stats.Roots++
+	}
+	if stats.Roots == 0 {
+		return stats, fmt.Errorf("tree %d has no root to back up", treeID)
+	}
+	if err := writeRecord(bw, tagEnd, nil); err != nil {
+		return stats, err
+	}
+	return stats, bw.Flush()
+}
+
+// RestoreLog recreates the log backed up in r in p, under its ID, which must
+// not exist in p. Each root is restored in its own transaction, as by
+// Copier.CopyLog, and the restored log is verified against the last root of
+// the backup. It returns the restored tree.
+func RestoreLog(ctx context.Context, p storage.Provider, r io.ReadSeeker) (*trillian.Tree, Stats, error) {
+	var stats Stats
+	br := &backupReader{rs: r, r: bufio.NewReader(r)}
+	magic := make([]byte, len(backupMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != backupMagic {
+		return nil, stats, errors.New("not a log backup")
+	}
+	src := &trillian.Tree{}
+	if err := br.readMessage(tagTree, src); err != nil {
+		return nil, stats, err
+	}
+	hasher, err := hashers.NewLogHasher(src.HashStrategy)
+	if err != nil {
+		return nil, stats, err
+	}
+	admin := p.AdminStorage()
+	switch exists, err := treeExists(ctx, admin, src.TreeId); {
+	case err != nil:
+		return nil, stats, err
+	case exists:
+		return nil, stats, fmt.Errorf("tree %d already exists", src.TreeId)
+	}
+	tree, err := createTree(ctx, admin, src)
+	if err != nil {
+		return nil, stats, err
+	}
+
+	ls := p.LogStorage()
+	fact := compact.RangeFactory{Hash: hasher.HashChildren}
+	cr := fact.NewEmptyRange(0)
+	var last *types.LogRootV1
+	for {
+		// Skip the leaves of the next root, which copyRoot reads.
+		start, size := br.off, cr.End()
+		tag, err := br.skip(tagLeaf)
+		if err != nil {
+			return nil, stats, err
+		}
+		if tag == tagEnd {
+			break
+		}
+		slr := &trillian.SignedLogRoot{}
+		if err := br.readMessage(tagRoot, slr); err != nil {
+			return nil, stats, err
+		}
+		var sigs []*trillian.LogRootSignature
+		for {
+			if tag, err := br.peek(); err != nil {
+				return nil, stats, err
+			} else if tag != tagSig {
+				break
+			}
+			sig := &trillian.LogRootSignature{}
+			if err := br.readMessage(tagSig, sig); err != nil {
+				return nil, stats, err
+			}
+			sigs = append(sigs, sig)
+		}
+		next := br.off
+
+		readLeaves := func(begin, end uint64) ([]*trillian.LogLeaf, error) {
+			if begin == size {
+				if err := br.seek(start); err != nil {
+					return nil, err
+				}
+			}
+			var leaves []*trillian.LogLeaf
+			for n := begin; n < end && len(leaves) < DefaultBatchSize; n++ {
+				if tag, err := br.peek(); err != nil || tag != tagLeaf {
+					return leaves, err
+				}
+				leaf := &trillian.LogLeaf{}
+				if err := br.readMessage(tagLeaf, leaf); err != nil {
+					return nil, err
+				}
+				leaves = append(leaves, leaf)
+			}
+			return leaves, nil
+		}
+		if cr, err = copyRoot(ctx, ls, tree, hasher, cr, slr, sigs, readLeaves); err != nil {
+			return nil, stats, fmt.Errorf("failed to restore root %d of tree %d: %v", stats.Roots, tree.TreeId, err)
+		}
+		if err := br.seek(next); err != nil {
+			return nil, stats, err
+		}
+		last = &types.LogRootV1{}
+		if err := last.UnmarshalBinary(slr.LogRoot); err != nil {
+			return nil, stats, err
+		}
+		stats.Roots++
+		stats.Leaves += int64(cr.End() - size)
+		glog.V(1).Infof("%d: restored root %d at tree size %d", tree.TreeId, last.Revision, cr.End())
+	}
+	if last == nil {
+		return nil, stats, fmt.Errorf("backup of tree %d has no root", tree.TreeId)
+	}
+
+	if err := verifyLog(ctx, ls, tree, hasher, last, DefaultBatchSize); err != nil {
+		return nil, stats, fmt.Errorf("tree %d: %v", tree.TreeId, err)
+	}
+	if err := setState(ctx, admin, tree, src.TreeState); err != nil {
+		return nil, stats, err
+	}
+	tree, err = storage.GetTree(ctx, admin, tree.TreeId)
+	return tree, stats, err
+}
+
+// writeRecord writes msg to w as a record with tag.
+func writeRecord(w *bufio.Writer, tag byte, msg proto.Message) error {
+	var data []byte
+	if msg != nil {
+		var err error
+		if data, err = proto.Marshal(msg); err != nil {
+			return err
+		}
+	}
+	var hdr [1 + binary.MaxVarintLen64]byte
+	hdr[0] = tag
+	n := binary.PutUvarint(hdr[1:], uint64(len(data)))
+	if _, err := w.Write(hdr[:1+n]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// backupReader reads the records of a backup, keeping track of its offset so
+// that it can go back to earlier records.
+type backupReader struct {
+	rs  io.ReadSeeker
+	r   *bufio.Reader
+	off int64
+}
+
+func (b *backupReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.off += int64(n)
+	return n, err
+}
+
+func (b *backupReader) ReadByte() (byte, error) {
+	c, err := b.r.ReadByte()
+	if err == nil {
+		b.off++
+	}
+	return c, err
+}
+
+// seek moves to offset off of the backup.
+func (b *backupReader) seek(off int64) error {
+	if _, err := b.rs.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	b.r.Reset(b.rs)
+	b.off = off
+	return nil
+}
+
+// peek returns the tag of the next record.
+func (b *backupReader) peek() (byte, error) {
+	tag, err := b.r.Peek(1)
+	if err == io.EOF {
+		return 0, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return 0, err
+	}
+	return tag[0], nil
+}
+
+// next reads the next record, and returns its tag and message.
+func (b *backupReader) next() (byte, []byte, error) {
+	tag, err := b.ReadByte()
+	if err == io.EOF {
+		return 0, nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return 0, nil, err
+	}
+	size, err := binary.ReadUvarint(b)
+	if err != nil {
+		return 0, nil, err
+	}
+	if size > maxRecordSize {
+		return 0, nil, fmt.Errorf("record of %d bytes is too large", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(b, data); err != nil {
+		return 0, nil, err
+	}
+	return tag, data, nil
+}
+
+// readMessage reads the next record, which must have tag, into msg.
+func (b *backupReader) readMessage(tag byte, msg proto.Message) error {
+	got, data, err := b.next()
+	if err != nil {
+		return err
+	}
+	if got != tag {
+		return fmt.Errorf("got record %q at offset %d, want %q", got, b.off, tag)
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// skip skips the records with tag, and returns the tag of the next record,
+// which is only read if it's tagEnd.
+func (b *backupReader) skip(tag byte) (byte, error) {
+	for {
+		got, err := b.peek()
+		if err != nil {
+			return 0, err
+		}
+		if got != tag && got != tagEnd {
+			return got, nil
+		}
+		if _, _, err := b.next(); err != nil {
+			return 0, err
+		}
+		if got == tagEnd {
+			return got, nil
+		}
+	}
+}
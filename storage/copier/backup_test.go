@@ -0,0 +1,133 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package copier
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/types"
+)
+
+func mustBackupLog(ctx context.Context, t *testing.T, l *testLog, include func(*types.LogRootV1) bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := BackupLog(ctx, l.p, l.tree.TreeId, include, &buf); err != nil {
+		t.Fatalf("BackupLog(): %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestBackupRestoreLog(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newMemProvider(), newMemProvider()
+	l := newTestLog(ctx, t, src, 12345)
+	for _, count := range []int{5, DefaultBatchSize + 100, 7} {
+		l.add(ctx, t, count)
+	}
+	if _, err := storage.UpdateTree(ctx, src.AdminStorage(), l.tree.TreeId, func(tree *trillian.Tree) {
+		tree.TreeState = trillian.TreeState_FROZEN
+	}); err != nil {
+		t.Fatalf("UpdateTree(): %v", err)
+	}
+
+	var buf bytes.Buffer
+	stats, err := BackupLog(ctx, src, l.tree.TreeId, nil, &buf)
+	if err != nil {
+		t.Fatalf("BackupLog(): %v", err)
+	}
+	want := Stats{Roots: 4, Leaves: int64(l.size)}
+	if stats != want {
+		t.Errorf("BackupLog()=%+v, want %+v", stats, want)
+	}
+
+	tree, stats, err := RestoreLog(ctx, dst, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("RestoreLog(): %v", err)
+	}
+	if stats != want {
+		t.Errorf("RestoreLog()=%+v, want %+v", stats, want)
+	}
+	if got, want := tree.TreeState, trillian.TreeState_FROZEN; got != want {
+		t.Errorf("TreeState=%v, want %v", got, want)
+	}
+	if got, want := mustLatestRoot(ctx, t, dst, l.tree), mustLatestRoot(ctx, t, src, l.tree); !reflect.DeepEqual(got, want) {
+		t.Errorf("latest root=%+v, want %+v", got, want)
+	}
+	if err := (&Copier{Src: src, Dst: dst}).VerifyLog(ctx, l.tree.TreeId); err != nil {
+		t.Errorf("VerifyLog(): %v", err)
+	}
+
+	// A tree can't be restored over an existing one.
+	if _, _, err := RestoreLog(ctx, dst, bytes.NewReader(buf.Bytes())); err == nil || !strings.Contains(err.Error(), "exists") {
+		t.Errorf("RestoreLog() of an existing tree: %v, want exists error", err)
+	}
+}
+
+func TestBackupLogPointInTime(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newMemProvider(), newMemProvider()
+	l := newTestLog(ctx, t, src, 12345)
+	l.add(ctx, t, 3)
+	l.add(ctx, t, 4)
+	at := mustLatestRoot(ctx, t, src, l.tree)
+	l.add(ctx, t, 5)
+
+	backup := mustBackupLog(ctx, t, l, func(root *types.LogRootV1) bool {
+		return root.TimestampNanos <= at.TimestampNanos
+	})
+	if _, _, err := RestoreLog(ctx, dst, bytes.NewReader(backup)); err != nil {
+		t.Fatalf("RestoreLog(): %v", err)
+	}
+	if got := mustLatestRoot(ctx, t, dst, l.tree); !reflect.DeepEqual(got, at) {
+		t.Errorf("latest root=%+v, want %+v", got, at)
+	}
+}
+
+func TestRestoreLogCorrupt(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLog(ctx, t, newMemProvider(), 12345)
+	l.add(ctx, t, 6)
+	backup := mustBackupLog(ctx, t, l, nil)
+	leafValue := []byte("tree 12345 leaf 2")
+	i := bytes.Index(backup, leafValue)
+	if i < 0 {
+		t.Fatal("backup doesn't hold leaf 2")
+	}
+
+	for _, test := range []struct {
+		desc    string
+		backup  []byte
+		wantErr string
+	}{
+		{desc: "empty", wantErr: "not a log backup"},
+		{desc: "no-magic", backup: backup[1:], wantErr: "not a log backup"},
+		{desc: "truncated", backup: backup[:i], wantErr: "EOF"},
+		{desc: "no-end", backup: backup[:len(backup)-2], wantErr: "EOF"},
+		{desc: "bad-leaf", backup: bytes.Replace(backup, leafValue, []byte("tree 12345 leaf 9"), 1), wantErr: "leaf 2"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			_, _, err := RestoreLog(ctx, newMemProvider(), bytes.NewReader(test.backup))
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("RestoreLog(): %v, want err containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
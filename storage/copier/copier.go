@@ -0,0 +1,558 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package copier copies logs, with their leaves, Merkle nodes and signed roots,
+// and maps, with their leaves, subtrees and signed roots, from one storage
+// system to another, e.g. to move a deployment from MySQL to CloudSpanner, and
+// verifies the root hashes of the copies.
+package copier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/compact"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/tree"
+	"github.com/google/trillian/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxTreeDepth is the depth of the Merkle trees of logs, as in the log package.
+const maxTreeDepth = 64
+
+// DefaultBatchSize is the number of leaves read from the source storage at a
+// time, unless set otherwise.
+const DefaultBatchSize = 1000
+
+// Copier copies logs and maps from the storage system Src to Dst.
+//
+// Each signed root of a log is copied by CopyLog in its own transaction, with
+// the leaves it adds and the Merkle nodes they change, which are recomputed
+// from the leaf hashes and checked against the root hash. The roots keep their
+// revisions and signatures, so they stay valid under the log's public key, and
+// so do proofs served from either storage system. The witness signatures of
+// the roots are copied too; the queue and integrate timestamps of the leaves,
+// and leaves not yet integrated into a root, are not. Maps are copied likewise
+// by CopyMap.
+//
+// Copying a tree which exists in Dst resumes from its latest root there, which
+// must be one of the roots of the tree in Src, so the source can keep serving
+// while it's copied, and the roots published since can be copied later.
+type Copier struct {
+	Src, Dst storage.Provider
+	// BatchSize is the number of leaves read from Src at a time. If zero,
+	// DefaultBatchSize is used.
+	BatchSize int
+}
+
+// Stats counts the data copied by CopyLog.
+type Stats struct {
+	Roots  int64
+	Leaves int64
+}
+
+func (c *Copier) batchSize() int64 {
+	if c.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+	return int64(c.BatchSize)
+}
+
+// CopyLog copies the log with treeID from Src to Dst, creating it in Dst if
+// need be, up to its latest root in Src. Deleted trees can't be copied.
+func (c *Copier) CopyLog(ctx context.Context, treeID int64) (Stats, error) {
+	var stats Stats
+	src, err := storage.GetTree(ctx, c.Src.AdminStorage(), treeID)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read tree %d: %v", treeID, err)
+	}
+	if src.TreeType != trillian.TreeType_LOG && src.TreeType != trillian.TreeType_PREORDERED_LOG {
+		return stats, fmt.Errorf("tree %d is a %s, only logs can be copied", treeID, src.TreeType)
+	}
+	if src.Deleted {
+		return stats, fmt.Errorf("tree %d is deleted", treeID)
+	}
+	hasher, err := hashers.NewLogHasher(src.HashStrategy)
+	if err != nil {
+		return stats, err
+	}
+	dst, err := c.dstTree(ctx, src)
+	if err != nil {
+		return stats, err
+	}
+
+	latest, err := latestRoot(ctx, c.Src.LogStorage(), src)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read the latest root of tree %d: %v", treeID, err)
+	}
+	cr, next, err := c.resume(ctx, src, dst, hasher)
+	if err != nil {
+		return stats, fmt.Errorf("failed to resume copying tree %d: %v", treeID, err)
+	}
+	if latest != nil {
+		for rev := next; rev <= int64(latest.Revision); rev++ {
+			slr, sigs, err := c.srcRoot(ctx, src, rev)
+			if status.Code(err) == codes.NotFound {
+				continue
+			} else if err != nil {
+				return stats, fmt.Errorf("failed to read root %d of tree %d: %v", rev, treeID, err)
+			}
+			before := cr.End()
+			readLeaves := func(begin, end uint64) ([]*trillian.LogLeaf, error) {
+				return readLeaves(ctx, c.Src.LogStorage(), src, begin, end, c.batchSize())
+			}
+			if cr, err = copyRoot(ctx, c.Dst.LogStorage(), dst, hasher, cr, slr, sigs, readLeaves); err != nil {
+				return stats, fmt.Errorf("failed to copy root %d of tree %d: %v", rev, treeID, err)
+			}
+			stats.Roots++
+			stats.Leaves += int64(cr.End() - before)
+			glog.V(1).Infof("%d: copied root %d at tree size %d", treeID, rev, cr.End())
+		}
+	}
+
+	return stats, setState(ctx, c.Dst.AdminStorage(), dst, src.TreeState)
+}
+
+// VerifyLog checks that the latest root of the log with treeID in Dst is one
+// of its roots in Src, that the leaves in Dst add up to the root hash, and
+// that the Merkle nodes in Dst do too.
+func (c *Copier) VerifyLog(ctx context.Context, treeID int64) error {
+	src, err := storage.GetTree(ctx, c.Src.AdminStorage(), treeID)
+	if err != nil {
+		return fmt.Errorf("failed to read tree %d: %v", treeID, err)
+	}
+	dst, err := storage.GetTree(ctx, c.Dst.AdminStorage(), treeID)
+	if err != nil {
+		return fmt.Errorf("failed to read copied tree %d: %v", treeID, err)
+	}
+	hasher, err := hashers.NewLogHasher(dst.HashStrategy)
+	if err != nil {
+		return err
+	}
+	root, err := c.checkedDstRoot(ctx, src, dst)
+	if err != nil {
+		return fmt.Errorf("tree %d: %v", treeID, err)
+	}
+	if root == nil {
+		return fmt.Errorf("tree %d: no root has been copied", treeID)
+	}
+	if err := verifyLog(ctx, c.Dst.LogStorage(), dst, hasher, root, c.batchSize()); err != nil {
+		return fmt.Errorf("tree %d: %v", treeID, err)
+	}
+	return nil
+}
+
+// verifyLog checks that the leaves of the log in ls add up to the hash of
+// root, which is its latest root, and that its Merkle nodes do too.
+func verifyLog(ctx context.Context, ls storage.LogStorage, tree *trillian.Tree, hasher hashers.LogHasher, root *types.LogRootV1, batchSize int64) error {
+	fact := compact.RangeFactory{Hash: hasher.HashChildren}
+	cr := fact.NewEmptyRange(0)
+	for begin := uint64(0); begin < root.TreeSize; {
+		leaves, err := readLeaves(ctx, ls, tree, begin, root.TreeSize, batchSize)
+		if err != nil {
+			return err
+		}
+		if err := checkLeaves(hasher, leaves, begin); err != nil {
+			return err
+		}
+		for _, leaf := range leaves {
+			if err := cr.Append(leaf.MerkleLeafHash, nil); err != nil {
+				return err
+			}
+		}
+		begin += uint64(len(leaves))
+	}
+	if err := checkRootHash(hasher, cr, root); err != nil {
+		return fmt.Errorf("leaves: %v", err)
+	}
+
+	var count int64
+	err := snapshot(ctx, ls, tree, func(tx storage.ReadOnlyLogTreeTX) error {
+		var err error
+		if count, err = tx.GetSequencedLeafCount(ctx); err != nil {
+			return err
+		}
+		_, err = rangeFromNodes(ctx, tx, hasher, root)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Merkle nodes: %v", err)
+	}
+	if count != int64(root.TreeSize) {
+		return fmt.Errorf("has %d leaves, want %d", count, root.TreeSize)
+	}
+	return nil
+}
+
+// dstTree returns the copy of tree src in Dst, creating it if it doesn't
+// exist.
+func (c *Copier) dstTree(ctx context.Context, src *trillian.Tree) (*trillian.Tree, error) {
+	admin := c.Dst.AdminStorage()
+	exists, err := treeExists(ctx, admin, src.TreeId)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		dst, err := storage.GetTree(ctx, admin, src.TreeId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read copied tree %d: %v", src.TreeId, err)
+		}
+		if dst.TreeType != src.TreeType || dst.HashStrategy != src.HashStrategy || !proto.Equal(dst.PublicKey, src.PublicKey) {
+			return nil, fmt.Errorf("tree %d exists in the destination storage with different parameters", src.TreeId)
+		}
+		return dst, nil
+	}
+
+	return createTree(ctx, admin, src)
+}
+
+// treeExists returns whether admin has a tree with treeID, even deleted.
+func treeExists(ctx context.Context, admin storage.AdminStorage, treeID int64) (bool, error) {
+	var exists bool
+	err := storage.RunInAdminSnapshot(ctx, admin, func(tx storage.ReadOnlyAdminTX) error {
+		ids, err := tx.ListTreeIDs(ctx, true /* includeDeleted */)
+		for _, id := range ids {
+			exists = exists || id == treeID
+		}
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list trees: %v", err)
+	}
+	return exists, nil
+}
+
+// createTree creates a copy of tree src, with the same ID, in admin. Trees are
+// created active, and get the state of src with setState once copied.
+func createTree(ctx context.Context, admin storage.AdminStorage, src *trillian.Tree) (*trillian.Tree, error) {
+	create := proto.Clone(src).(*trillian.Tree)
+	create.TreeState = trillian.TreeState_ACTIVE
+	create.CreateTime, create.UpdateTime = nil, nil
+	create.KeyRotation = nil
+	dst, err := storage.CreateTree(ctx, admin, create)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tree %d: %v", src.TreeId, err)
+	}
+	return dst, nil
+}
+
+// setState sets the state of tree in admin, unless it's already in it.
+func setState(ctx context.Context, admin storage.AdminStorage, tree *trillian.Tree, state trillian.TreeState) error {
+	if tree.TreeState == state {
+		return nil
+	}
+	if _, err := storage.UpdateTree(ctx, admin, tree.TreeId, func(t *trillian.Tree) {
+		t.TreeState = state
+	}); err != nil {
+		return fmt.Errorf("failed to set the state of tree %d: %v", tree.TreeId, err)
+	}
+	return nil
+}
+
+// resume returns the compact range of the latest root of the log in Dst, and
+// the revision of the next root to copy.
+func (c *Copier) resume(ctx context.Context, src, dst *trillian.Tree, hasher hashers.LogHasher) (*compact.Range, int64, error) {
+	root, err := c.checkedDstRoot(ctx, src, dst)
+	if err != nil {
+		return nil, 0, err
+	}
+	if root == nil {
+		fact := compact.RangeFactory{Hash: hasher.HashChildren}
+		return fact.NewEmptyRange(0), 0, nil
+	}
+	var cr *compact.Range
+	err = snapshot(ctx, c.Dst.LogStorage(), dst, func(tx storage.ReadOnlyLogTreeTX) error {
+		cr, err = rangeFromNodes(ctx, tx, hasher, root)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return cr, int64(root.Revision) + 1, nil
+}
+
+// checkedDstRoot returns the latest root of the log in Dst, or nil if it has
+// none, after checking that Src has the same root at its revision.
+func (c *Copier) checkedDstRoot(ctx context.Context, src, dst *trillian.Tree) (*types.LogRootV1, error) {
+	root, err := latestRoot(ctx, c.Dst.LogStorage(), dst)
+	if err != nil || root == nil {
+		return nil, err
+	}
+	want, _, err := c.srcRoot(ctx, src, int64(root.Revision))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read root %d of the source: %v", root.Revision, err)
+	}
+	got, err := root.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(got, want.LogRoot) {
+		return nil, fmt.Errorf("root %d differs from the source", root.Revision)
+	}
+	return root, nil
+}
+
+// srcRoot returns the root of the log in Src at revision rev, with its witness
+// signatures.
+func (c *Copier) srcRoot(ctx context.Context, tree *trillian.Tree, rev int64) (*trillian.SignedLogRoot, []*trillian.LogRootSignature, error) {
+	var slr *trillian.SignedLogRoot
+	var sigs []*trillian.LogRootSignature
+	err := snapshot(ctx, c.Src.LogStorage(), tree, func(tx storage.ReadOnlyLogTreeTX) error {
+		var err error
+		if slr, err = tx.GetSignedLogRoot(ctx, rev); err != nil {
+			return err
+		}
+		sigs, err = tx.GetLogRootSignatures(ctx, rev)
+		return err
+	})
+	return slr, sigs, err
+}
+
+// leafReader returns the next leaves from begin up to end of a log, at least
+// one of them. It's called with the size of the previous root as begin when
+// the leaves of a root have to be read again.
+type leafReader func(begin, end uint64) ([]*trillian.LogLeaf, error)
+
+// copyRoot writes the signed root slr to the log in ls, with the leaves read
+// by readLeaves which it adds to the tree of cr, and the Merkle nodes they
+// change. It returns the compact range of the new root.
+func copyRoot(ctx context.Context, ls storage.LogStorage, dst *trillian.Tree, hasher hashers.LogHasher, cr *compact.Range, slr *trillian.SignedLogRoot, sigs []*trillian.LogRootSignature, readLeaves leafReader) (*compact.Range, error) {
+	var root types.LogRootV1
+	if err := root.UnmarshalBinary(slr.LogRoot); err != nil {
+		return nil, err
+	}
+	if root.TreeSize < cr.End() {
+		return nil, fmt.Errorf("tree size %d is smaller than the previous %d", root.TreeSize, cr.End())
+	}
+
+	var next *compact.Range
+	err := ls.ReadWriteTransaction(ctx, dst, func(ctx context.Context, tx storage.LogTreeTX) error {
+		// The function may be retried, so it starts from a copy of cr.
+		fact := compact.RangeFactory{Hash: hasher.HashChildren}
+		var err error
+		if next, err = fact.NewRange(0, cr.End(), append([][]byte(nil), cr.Hashes()...)); err != nil {
+			return err
+		}
+		if root.TreeSize == next.End() {
+			return storeRoot(ctx, tx, slr, int64(root.Revision), sigs)
+		}
+
+		// Uninitialised logs have no write revision, so their first root
+		// can't add leaves.
+		rev, err := tx.WriteRevision(ctx)
+		if err != nil {
+			return err
+		}
+		if rev > int64(root.Revision) {
+			return fmt.Errorf("can't be written at revision %d", rev)
+		}
+		nodeMap := make(map[compact.NodeID][]byte)
+		store := func(id compact.NodeID, hash []byte) { nodeMap[id] = hash }
+		now := time.Now()
+		for begin := next.End(); begin < root.TreeSize; begin = next.End() {
+			leaves, err := readLeaves(begin, root.TreeSize)
+			if err != nil {
+				return err
+			}
+			if err := checkLeaves(hasher, leaves, begin); err != nil {
+				return err
+			}
+			res, err := tx.AddSequencedLeaves(ctx, leaves, now)
+			if err != nil {
+				return err
+			}
+			for i, r := range res {
+				if code := codes.Code(r.GetStatus().GetCode()); code != codes.OK {
+					return fmt.Errorf("failed to add leaf %d: %s", leaves[i].LeafIndex, r.GetStatus().GetMessage())
+				}
+			}
+			for _, leaf := range leaves {
+				store(compact.NewNodeID(0, uint64(leaf.LeafIndex)), leaf.MerkleLeafHash)
+				if err := next.Append(leaf.MerkleLeafHash, store); err != nil {
+					return err
+				}
+			}
+		}
+		// Store the ephemeral nodes on the right border of the tree too.
+		if _, err := next.GetRootHash(store); err != nil {
+			return err
+		}
+		if err := checkRootHash(hasher, next, &root); err != nil {
+			return err
+		}
+
+		nodes := make([]tree.Node, 0, len(nodeMap))
+		for id, hash := range nodeMap {
+			nodeID, err := tree.NewNodeIDForTreeCoords(int64(id.Level), int64(id.Index), maxTreeDepth)
+			if err != nil {
+				return err
+			}
+			nodes = append(nodes, tree.Node{NodeID: nodeID, Hash: hash, NodeRevision: rev})
+		}
+		if err := tx.SetMerkleNodes(ctx, nodes); err != nil {
+			return err
+		}
+		return storeRoot(ctx, tx, slr, int64(root.Revision), sigs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// storeRoot stores the signed root slr at revision rev, with its witness
+// signatures.
+func storeRoot(ctx context.Context, tx storage.LogTreeTX, slr *trillian.SignedLogRoot, rev int64, sigs []*trillian.LogRootSignature) error {
+	if err := tx.StoreSignedLogRoot(ctx, slr); err != nil {
+		return err
+	}
+	for _, sig := range sigs {
+		if err := tx.StoreLogRootSignature(ctx, rev, sig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLeaves reads a batch of up to count leaves from begin up to end of the
+// log in ls.
+func readLeaves(ctx context.Context, ls storage.LogStorage, tree *trillian.Tree, begin, end uint64, count int64) ([]*trillian.LogLeaf, error) {
+	if left := int64(end - begin); left < count {
+		count = left
+	}
+	var leaves []*trillian.LogLeaf
+	err := snapshot(ctx, ls, tree, func(tx storage.ReadOnlyLogTreeTX) error {
+		var err error
+		leaves, err = tx.GetLeavesByRange(ctx, int64(begin), count)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read leaves from %d: %v", begin, err)
+	}
+	return leaves, nil
+}
+
+// checkLeaves checks that leaves start at index begin, and have the Merkle
+// leaf hashes of their values.
+func checkLeaves(hasher hashers.LogHasher, leaves []*trillian.LogLeaf, begin uint64) error {
+	if len(leaves) == 0 {
+		return fmt.Errorf("leaf %d is missing", begin)
+	}
+	for i, leaf := range leaves {
+		if want := int64(begin) + int64(i); leaf.LeafIndex != want {
+			return fmt.Errorf("got leaf %d, want %d", leaf.LeafIndex, want)
+		}
+		if hash := hasher.HashLeaf(leaf.LeafValue); !bytes.Equal(hash, leaf.MerkleLeafHash) {
+			return fmt.Errorf("leaf %d has Merkle leaf hash %x, want %x", leaf.LeafIndex, leaf.MerkleLeafHash, hash)
+		}
+	}
+	return nil
+}
+
+// rangeFromNodes reads the compact range of root from the Merkle nodes of tx,
+// and checks that it matches the root hash.
+func rangeFromNodes(ctx context.Context, tx storage.ReadOnlyLogTreeTX, hasher hashers.LogHasher, root *types.LogRootV1) (*compact.Range, error) {
+	fact := compact.RangeFactory{Hash: hasher.HashChildren}
+	cr := fact.NewEmptyRange(0)
+	if root.TreeSize > 0 {
+		ids := compact.RangeNodes(0, root.TreeSize)
+		storIDs := make([]tree.NodeID, len(ids))
+		for i, id := range ids {
+			nodeID, err := tree.NewNodeIDForTreeCoords(int64(id.Level), int64(id.Index), maxTreeDepth)
+			if err != nil {
+				return nil, err
+			}
+			storIDs[i] = nodeID
+		}
+		nodes, err := tx.GetMerkleNodes(ctx, int64(root.Revision), storIDs)
+		if err != nil {
+			return nil, err
+		}
+		if got, want := len(nodes), len(storIDs); got != want {
+			return nil, fmt.Errorf("got %d nodes at revision %d, want %d", got, root.Revision, want)
+		}
+		hashes := make([][]byte, len(nodes))
+		for i, node := range nodes {
+			if !node.NodeID.Equivalent(storIDs[i]) {
+				return nil, fmt.Errorf("node ID mismatch at %d", i)
+			}
+			hashes[i] = node.Hash
+		}
+		if cr, err = fact.NewRange(0, root.TreeSize, hashes); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkRootHash(hasher, cr, root); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// checkRootHash returns an error unless cr has the root hash of root.
+func checkRootHash(hasher hashers.LogHasher, cr *compact.Range, root *types.LogRootV1) error {
+	if cr.End() != root.TreeSize {
+		return fmt.Errorf("got tree size %d, want %d", cr.End(), root.TreeSize)
+	}
+	hash := hasher.EmptyRoot()
+	if root.TreeSize > 0 {
+		var err error
+		if hash, err = cr.GetRootHash(nil); err != nil {
+			return err
+		}
+	}
+	if !bytes.Equal(hash, root.RootHash) {
+		return fmt.Errorf("root hash mismatch at tree size %d: got %x, want %x", root.TreeSize, hash, root.RootHash)
+	}
+	return nil
+}
+
+// latestRoot returns the latest root of the log in ls, or nil if it has none.
+func latestRoot(ctx context.Context, ls storage.LogStorage, tree *trillian.Tree) (*types.LogRootV1, error) {
+	var root *types.LogRootV1
+	err := snapshot(ctx, ls, tree, func(tx storage.ReadOnlyLogTreeTX) error {
+		slr, err := tx.LatestSignedLogRoot(ctx)
+		if err != nil {
+			return err
+		}
+		root = &types.LogRootV1{}
+		return root.UnmarshalBinary(slr.LogRoot)
+	})
+	if err == storage.ErrTreeNeedsInit {
+		return nil, nil
+	}
+	return root, err
+}
+
+// snapshot runs f in a snapshot of the log in ls.
+func snapshot(ctx context.Context, ls storage.LogStorage, tree *trillian.Tree, f func(storage.ReadOnlyLogTreeTX) error) error {
+	tx, err := ls.SnapshotForTree(ctx, tree)
+	if tx != nil {
+		defer tx.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
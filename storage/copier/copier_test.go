@@ -0,0 +1,256 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package copier
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	tcrypto "github.com/google/trillian/crypto"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/quota"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/memory"
+	storageto "github.com/google/trillian/storage/testonly"
+	"github.com/google/trillian/trees"
+	"github.com/google/trillian/types"
+	"github.com/google/trillian/util/clock"
+
+	_ "github.com/google/trillian/merkle/rfc6962" // Register the hasher.
+)
+
+// memProvider is a storage.Provider backed by memory storage.
+type memProvider struct {
+	ts *memory.TreeStorage
+}
+
+func newMemProvider() *memProvider {
+	return &memProvider{ts: memory.NewTreeStorage()}
+}
+
+func (p *memProvider) LogStorage() storage.LogStorage {
+	return memory.NewLogStorage(p.ts, monitoring.InertMetricFactory{})
+}
+func (p *memProvider) MapStorage() storage.MapStorage     { return nil }
+func (p *memProvider) AdminStorage() storage.AdminStorage { return memory.NewAdminStorage(p.ts) }
+func (p *memProvider) Close() error                       { return nil }
+
+// testLog is a log in memory storage, whose leaves are integrated by a
+// sequencer.
+type testLog struct {
+	p      *memProvider
+	tree   *trillian.Tree
+	seq    *log.Sequencer
+	ts     *clock.FakeTimeSource
+	hasher hashers.LogHasher
+	size   int
+}
+
+func newTestLog(ctx context.Context, t *testing.T, p *memProvider, treeID int64) *testLog {
+	t.Helper()
+	tree := proto.Clone(storageto.LogTree).(*trillian.Tree)
+	tree.TreeId = treeID
+	tree, err := storage.CreateTree(ctx, p.AdminStorage(), tree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	hasher, err := hashers.NewLogHasher(tree.HashStrategy)
+	if err != nil {
+		t.Fatalf("NewLogHasher(): %v", err)
+	}
+	signer, err := trees.Signer(ctx, tree)
+	if err != nil {
+		t.Fatalf("Signer(): %v", err)
+	}
+	ts := clock.NewFake(time.Unix(1000, 0))
+	l := &testLog{
+		p:      p,
+		tree:   tree,
+		seq:    log.NewSequencer(hasher, ts, p.LogStorage(), signer, nil, quota.Noop()),
+		ts:     ts,
+		hasher: hasher,
+	}
+	l.init(ctx, t, signer)
+	return l
+}
+
+func (l *testLog) init(ctx context.Context, t *testing.T, signer *tcrypto.Signer) {
+	t.Helper()
+	root, err := signer.SignLogRoot(&types.LogRootV1{
+		TimestampNanos: uint64(l.ts.Now().UnixNano()),
+		RootHash:       l.hasher.EmptyRoot(),
+	})
+	if err != nil {
+		t.Fatalf("SignLogRoot(): %v", err)
+	}
+	err = l.p.LogStorage().ReadWriteTransaction(ctx, l.tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		return tx.StoreSignedLogRoot(ctx, root)
+	})
+	if err != nil {
+		t.Fatalf("StoreSignedLogRoot(): %v", err)
+	}
+}
+
+// add integrates a batch of count new leaves into the log, under a new root.
+func (l *testLog) add(ctx context.Context, t *testing.T, count int) {
+	t.Helper()
+	leaves := make([]*trillian.LogLeaf, count)
+	for i := range leaves {
+		value := []byte(fmt.Sprintf("tree %d leaf %d", l.tree.TreeId, l.size+i))
+		id := sha256.Sum256(value)
+		leaves[i] = &trillian.LogLeaf{
+			LeafValue:        value,
+			LeafIdentityHash: id[:],
+			MerkleLeafHash:   l.hasher.HashLeaf(value),
+		}
+	}
+	if _, err := l.p.LogStorage().QueueLeaves(ctx, l.tree, leaves, l.ts.Now()); err != nil {
+		t.Fatalf("QueueLeaves(): %v", err)
+	}
+	l.ts.Set(l.ts.Now().Add(time.Second))
+	if n, err := l.seq.IntegrateBatch(ctx, l.tree, count, 0, time.Hour); err != nil || n != count {
+		t.Fatalf("IntegrateBatch()=%d, %v, want %d, nil", n, err, count)
+	}
+	l.size += count
+}
+
+func mustLatestRoot(ctx context.Context, t *testing.T, p storage.Provider, tree *trillian.Tree) *types.LogRootV1 {
+	t.Helper()
+	root, err := latestRoot(ctx, p.LogStorage(), tree)
+	if err != nil || root == nil {
+		t.Fatalf("latestRoot()=%v, %v, want root", root, err)
+	}
+	return root
+}
+
+func TestCopyLog(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newMemProvider(), newMemProvider()
+	l := newTestLog(ctx, t, src, 12345)
+	for _, count := range []int{5, 1, 16, 7} {
+		l.add(ctx, t, count)
+	}
+	c := &Copier{Src: src, Dst: dst, BatchSize: 3}
+
+	stats, err := c.CopyLog(ctx, l.tree.TreeId)
+	if err != nil {
+		t.Fatalf("CopyLog(): %v", err)
+	}
+	if want := (Stats{Roots: 5, Leaves: 29}); stats != want {
+		t.Errorf("CopyLog()=%+v, want %+v", stats, want)
+	}
+	if err := c.VerifyLog(ctx, l.tree.TreeId); err != nil {
+		t.Errorf("VerifyLog(): %v", err)
+	}
+	if got, want := mustLatestRoot(ctx, t, dst, l.tree), mustLatestRoot(ctx, t, src, l.tree); !reflect.DeepEqual(got, want) {
+		t.Errorf("latest root=%+v, want %+v", got, want)
+	}
+
+	// Copying again resumes from the latest root copied.
+	l.add(ctx, t, 9)
+	if _, err := storage.UpdateTree(ctx, src.AdminStorage(), l.tree.TreeId, func(tree *trillian.Tree) {
+		tree.TreeState = trillian.TreeState_FROZEN
+	}); err != nil {
+		t.Fatalf("UpdateTree(): %v", err)
+	}
+	stats, err = c.CopyLog(ctx, l.tree.TreeId)
+	if err != nil {
+		t.Fatalf("CopyLog() again: %v", err)
+	}
+	if want := (Stats{Roots: 1, Leaves: 9}); stats != want {
+		t.Errorf("CopyLog() again=%+v, want %+v", stats, want)
+	}
+	if err := c.VerifyLog(ctx, l.tree.TreeId); err != nil {
+		t.Errorf("VerifyLog() again: %v", err)
+	}
+	tree, err := storage.GetTree(ctx, dst.AdminStorage(), l.tree.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree(): %v", err)
+	}
+	if got, want := tree.TreeState, trillian.TreeState_FROZEN; got != want {
+		t.Errorf("TreeState=%v, want %v", got, want)
+	}
+
+	// Nothing is left to copy.
+	if stats, err := c.CopyLog(ctx, l.tree.TreeId); err != nil || stats != (Stats{}) {
+		t.Errorf("CopyLog() of a copied log=%+v, %v, want no stats, nil", stats, err)
+	}
+}
+
+func TestCopyLogDiverged(t *testing.T) {
+	ctx := context.Background()
+	src, other, dst := newMemProvider(), newMemProvider(), newMemProvider()
+	l := newTestLog(ctx, t, src, 12345)
+	l.add(ctx, t, 4)
+	if _, err := (&Copier{Src: src, Dst: dst}).CopyLog(ctx, l.tree.TreeId); err != nil {
+		t.Fatalf("CopyLog(): %v", err)
+	}
+
+	// A different log under the same ID doesn't have the root copied.
+	o := newTestLog(ctx, t, other, l.tree.TreeId)
+	o.add(ctx, t, 3)
+	o.add(ctx, t, 5)
+	c := &Copier{Src: other, Dst: dst}
+	if _, err := c.CopyLog(ctx, l.tree.TreeId); err == nil || !strings.Contains(err.Error(), "differs") {
+		t.Errorf("CopyLog() of another log: %v, want root mismatch", err)
+	}
+	if err := c.VerifyLog(ctx, l.tree.TreeId); err == nil {
+		t.Error("VerifyLog() of another log: nil, want err")
+	}
+}
+
+func TestCopyLogBadLeaf(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newMemProvider(), newMemProvider()
+	l := newTestLog(ctx, t, src, 12345)
+	l.add(ctx, t, 4)
+
+	// Corrupt the value of a leaf.
+	err := src.LogStorage().ReadWriteTransaction(ctx, l.tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		leaves, err := tx.GetLeavesByRange(ctx, 2, 1)
+		if err != nil {
+			return err
+		}
+		leaves[0].LeafValue = []byte("corrupt")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadWriteTransaction(): %v", err)
+	}
+	if _, err := (&Copier{Src: src, Dst: dst}).CopyLog(ctx, l.tree.TreeId); err == nil || !strings.Contains(err.Error(), "leaf 2") {
+		t.Errorf("CopyLog(): %v, want leaf 2 hash mismatch", err)
+	}
+}
+
+func TestCopyLogOfMap(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newMemProvider(), newMemProvider()
+	tree, err := storage.CreateTree(ctx, src.AdminStorage(), storageto.MapTree)
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	if _, err := (&Copier{Src: src, Dst: dst}).CopyLog(ctx, tree.TreeId); err == nil {
+		t.Error("CopyLog() of a map: nil, want err")
+	}
+}
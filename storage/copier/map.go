@@ -0,0 +1,381 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package copier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/types"
+)
+
+// CopyMap copies the map with treeID from Src to Dst, creating it in Dst if
+// need be, up to its latest root in Src. Deleted trees can't be copied.
+//
+// Each signed root of a map is copied in its own transaction, with the leaf
+// values written at its revision and the subtrees they change, which are
+// recomputed from the leaf hashes and checked against the root hash. As with
+// logs, the roots keep their revisions, timestamps, metadata and signatures,
+// and their witness signatures are copied too; the idempotency tokens of the
+// writes are not. The leaf versions still to copy are read ahead and held in
+// memory, grouped by revision.
+//
+// All the revisions of the map must be in Src, so maps whose older revisions
+// have been garbage collected can't be copied.
+func (c *Copier) CopyMap(ctx context.Context, treeID int64) (Stats, error) {
+	var stats Stats
+	src, err := storage.GetTree(ctx, c.Src.AdminStorage(), treeID)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read tree %d: %v", treeID, err)
+	}
+	if src.TreeType != trillian.TreeType_MAP {
+		return stats, fmt.Errorf("tree %d is a %s, not a map", treeID, src.TreeType)
+	}
+	if src.Deleted {
+		return stats, fmt.Errorf("tree %d is deleted", treeID)
+	}
+	hasher, err := hashers.NewMapHasherForTree(src)
+	if err != nil {
+		return stats, err
+	}
+	dst, err := c.dstTree(ctx, src)
+	if err != nil {
+		return stats, err
+	}
+
+	latest, err := latestMapRoot(ctx, c.Src.MapStorage(), src)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read the latest root of tree %d: %v", treeID, err)
+	}
+	copied, err := c.checkedDstMapRoot(ctx, src, dst)
+	if err != nil {
+		return stats, fmt.Errorf("failed to resume copying tree %d: %v", treeID, err)
+	}
+	next := int64(0)
+	if copied != nil {
+		next = int64(copied.Revision) + 1
+	}
+	if latest != nil && next <= int64(latest.Revision) {
+		leaves, err := readLeafVersions(ctx, c.Src.MapStorage(), src, next, int64(latest.Revision), c.batchSize())
+		if err != nil {
+			return stats, fmt.Errorf("failed to read the leaves of tree %d: %v", treeID, err)
+		}
+		for rev := next; rev <= int64(latest.Revision); rev++ {
+			smr, sigs, err := c.srcMapRoot(ctx, src, rev)
+			if err != nil {
+				return stats, fmt.Errorf("failed to read root %d of tree %d: %v", rev, treeID, err)
+			}
+			if err := copyMapRoot(ctx, c.Dst.MapStorage(), dst, hasher, smr, sigs, leaves[rev]); err != nil {
+				return stats, fmt.Errorf("failed to copy root %d of tree %d: %v", rev, treeID, err)
+			}
+			stats.Roots++
+			stats.Leaves += int64(len(leaves[rev]))
+			delete(leaves, rev)
+			glog.V(1).Infof("%d: copied map root %d", treeID, rev)
+		}
+	}
+
+	return stats, setState(ctx, c.Dst.AdminStorage(), dst, src.TreeState)
+}
+
+// VerifyMap checks that the latest root of the map with treeID in Dst is one
+// of its roots in Src, that the leaves in Dst at its revision are those in
+// Src, with the leaf hashes of their values, and that their inclusion proofs
+// from the subtrees in Dst reproduce the root hash.
+func (c *Copier) VerifyMap(ctx context.Context, treeID int64) error {
+	src, err := storage.GetTree(ctx, c.Src.AdminStorage(), treeID)
+	if err != nil {
+		return fmt.Errorf("failed to read tree %d: %v", treeID, err)
+	}
+	dst, err := storage.GetTree(ctx, c.Dst.AdminStorage(), treeID)
+	if err != nil {
+		return fmt.Errorf("failed to read copied tree %d: %v", treeID, err)
+	}
+	hasher, err := hashers.NewMapHasherForTree(dst)
+	if err != nil {
+		return err
+	}
+	root, err := c.checkedDstMapRoot(ctx, src, dst)
+	if err != nil {
+		return fmt.Errorf("tree %d: %v", treeID, err)
+	}
+	if root == nil {
+		return fmt.Errorf("tree %d: no root has been copied", treeID)
+	}
+
+	rev := int64(root.Revision)
+	var after []byte
+	for {
+		want, err := listLeaves(ctx, c.Src.MapStorage(), src, rev, after, c.batchSize())
+		if err != nil {
+			return fmt.Errorf("tree %d: failed to read leaves of the source: %v", treeID, err)
+		}
+		var got []*trillian.MapLeaf
+		err = mapSnapshot(ctx, c.Dst.MapStorage(), dst, func(tx storage.ReadOnlyMapTreeTX) error {
+			var err error
+			if got, err = tx.List(ctx, rev, after, int(c.batchSize())); err != nil {
+				return err
+			}
+			if err := checkMapLeaves(dst.TreeId, hasher, got); err != nil {
+				return err
+			}
+			return checkInclusion(ctx, tx, dst.TreeId, hasher, root, got)
+		})
+		if err != nil {
+			return fmt.Errorf("tree %d: %v", treeID, err)
+		}
+		if err := compareMapLeaves(got, want); err != nil {
+			return fmt.Errorf("tree %d: %v", treeID, err)
+		}
+		if len(got) < int(c.batchSize()) {
+			return nil
+		}
+		after = got[len(got)-1].Index
+	}
+}
+
+// checkedDstMapRoot returns the latest root of the map in Dst, or nil if it
+// has none, after checking that Src has the same root at its revision.
+func (c *Copier) checkedDstMapRoot(ctx context.Context, src, dst *trillian.Tree) (*types.MapRootV1, error) {
+	root, err := latestMapRoot(ctx, c.Dst.MapStorage(), dst)
+	if err != nil || root == nil {
+		return nil, err
+	}
+	want, _, err := c.srcMapRoot(ctx, src, int64(root.Revision))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read root %d of the source: %v", root.Revision, err)
+	}
+	got, err := root.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(got, want.MapRoot) {
+		return nil, fmt.Errorf("root %d differs from the source", root.Revision)
+	}
+	return root, nil
+}
+
+// srcMapRoot returns the root of the map in Src at revision rev, with its
+// witness signatures.
+func (c *Copier) srcMapRoot(ctx context.Context, tree *trillian.Tree, rev int64) (*trillian.SignedMapRoot, []*trillian.MapRootSignature, error) {
+	var smr *trillian.SignedMapRoot
+	var sigs []*trillian.MapRootSignature
+	err := mapSnapshot(ctx, c.Src.MapStorage(), tree, func(tx storage.ReadOnlyMapTreeTX) error {
+		var err error
+		if smr, err = tx.GetSignedMapRoot(ctx, rev); err != nil {
+			return err
+		}
+		sigs, err = tx.GetMapRootSignatures(ctx, rev)
+		return err
+	})
+	return smr, sigs, err
+}
+
+// copyMapRoot writes the signed root smr to the map in ms, with leaves, which
+// are the leaf values written at its revision, and the subtrees they change.
+func copyMapRoot(ctx context.Context, ms storage.MapStorage, dst *trillian.Tree, hasher hashers.MapHasher, smr *trillian.SignedMapRoot, sigs []*trillian.MapRootSignature, leaves []*trillian.MapLeaf) error {
+	var root types.MapRootV1
+	if err := root.UnmarshalBinary(smr.MapRoot); err != nil {
+		return err
+	}
+	rev := int64(root.Revision)
+	if err := checkMapLeaves(dst.TreeId, hasher, leaves); err != nil {
+		return err
+	}
+
+	return ms.ReadWriteTransaction(ctx, dst, func(ctx context.Context, tx storage.MapTreeTX) error {
+		hash := hasher.HashEmpty(dst.TreeId, make([]byte, hashers.IndexSize(hasher)), hasher.BitLen())
+		if rev == 0 {
+			if len(leaves) > 0 {
+				return fmt.Errorf("revision 0 has %d leaves", len(leaves))
+			}
+		} else {
+			wrev, err := tx.WriteRevision(ctx)
+			if err != nil {
+				return err
+			}
+			if wrev != rev {
+				return fmt.Errorf("can't be written at revision %d", wrev)
+			}
+			hkv := make([]merkle.HashKeyValue, 0, len(leaves))
+			for _, leaf := range leaves {
+				if err := tx.Set(ctx, leaf.Index, leaf); err != nil {
+					return err
+				}
+				hkv = append(hkv, merkle.HashKeyValue{HashedKey: leaf.Index, HashedValue: leaf.LeafHash})
+			}
+			w, err := merkle.NewSparseMerkleTreeWriter(ctx, dst.TreeId, rev, hasher, &txRunner{tx: tx})
+			if err != nil {
+				return err
+			}
+			if err := w.SetLeaves(ctx, hkv); err != nil {
+				return err
+			}
+			if hash, err = w.CalculateRoot(ctx); err != nil {
+				return err
+			}
+		}
+		if !bytes.Equal(hash, root.RootHash) {
+			return fmt.Errorf("root hash mismatch at revision %d: got %x, want %x", rev, hash, root.RootHash)
+		}
+
+		if err := tx.StoreSignedMapRoot(ctx, smr); err != nil {
+			return err
+		}
+		for _, sig := range sigs {
+			if err := tx.StoreMapRootSignature(ctx, rev, sig); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// txRunner runs all the transactions of a SparseMerkleTreeWriter in tx, so
+// that the subtrees are written with the leaves and the root.
+type txRunner struct {
+	tx storage.MapTreeTX
+}
+
+// RunTX calls f with the transaction of r.
+func (r *txRunner) RunTX(ctx context.Context, f func(context.Context, storage.MapTreeTX) error) error {
+	return f(ctx, r.tx)
+}
+
+// readLeafVersions reads the versions of the leaves of the map in ms written
+// from revision begin up to end, in batches of batchSize leaves, and returns
+// them by revision.
+func readLeafVersions(ctx context.Context, ms storage.MapStorage, tree *trillian.Tree, begin, end, batchSize int64) (map[int64][]*trillian.MapLeaf, error) {
+	leaves := make(map[int64][]*trillian.MapLeaf)
+	var after []byte
+	for {
+		var versions []storage.MapLeafVersion
+		err := mapSnapshot(ctx, ms, tree, func(tx storage.ReadOnlyMapTreeTX) error {
+			var err error
+			versions, err = tx.ListLeafVersions(ctx, after, int(batchSize))
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			return leaves, nil
+		}
+		for _, v := range versions {
+			if v.Revision >= begin && v.Revision <= end {
+				leaves[v.Revision] = append(leaves[v.Revision], v.Leaf)
+			}
+		}
+		after = versions[len(versions)-1].Leaf.Index
+	}
+}
+
+// listLeaves reads a batch of up to count leaves of the map in ms at revision
+// rev, after the index after.
+func listLeaves(ctx context.Context, ms storage.MapStorage, tree *trillian.Tree, rev int64, after []byte, count int64) ([]*trillian.MapLeaf, error) {
+	var leaves []*trillian.MapLeaf
+	err := mapSnapshot(ctx, ms, tree, func(tx storage.ReadOnlyMapTreeTX) error {
+		var err error
+		leaves, err = tx.List(ctx, rev, after, int(count))
+		return err
+	})
+	return leaves, err
+}
+
+// checkMapLeaves checks that leaves have the leaf hashes of their values.
+func checkMapLeaves(treeID int64, hasher hashers.MapHasher, leaves []*trillian.MapLeaf) error {
+	for _, leaf := range leaves {
+		if hash := hasher.HashLeaf(treeID, leaf.Index, leaf.LeafValue); !bytes.Equal(hash, leaf.LeafHash) {
+			return fmt.Errorf("leaf %x has leaf hash %x, want %x", leaf.Index, leaf.LeafHash, hash)
+		}
+	}
+	return nil
+}
+
+// compareMapLeaves checks that the leaves got are the leaves want.
+func compareMapLeaves(got, want []*trillian.MapLeaf) error {
+	for i := 0; i < len(got) || i < len(want); i++ {
+		switch {
+		case i >= len(want):
+			return fmt.Errorf("leaf %x is not in the source", got[i].Index)
+		case i >= len(got) || !bytes.Equal(got[i].Index, want[i].Index):
+			return fmt.Errorf("leaf %x is missing", want[i].Index)
+		case !bytes.Equal(got[i].LeafValue, want[i].LeafValue) || !bytes.Equal(got[i].ExtraData, want[i].ExtraData):
+			return fmt.Errorf("leaf %x differs from the source", got[i].Index)
+		}
+	}
+	return nil
+}
+
+// checkInclusion checks that the inclusion proofs of leaves read from the
+// subtrees of tx reproduce the hash of root.
+func checkInclusion(ctx context.Context, tx storage.ReadOnlyMapTreeTX, treeID int64, hasher hashers.MapHasher, root *types.MapRootV1, leaves []*trillian.MapLeaf) error {
+	if len(leaves) == 0 {
+		return nil
+	}
+	indices := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		indices[i] = leaf.Index
+	}
+	proofs, err := merkle.NewSparseMerkleTreeReader(int64(root.Revision), hasher, tx).BatchInclusionProof(ctx, int64(root.Revision), indices)
+	if err != nil {
+		return err
+	}
+	for _, leaf := range leaves {
+		if err := merkle.VerifyMapInclusionProofForHash(treeID, leaf.Index, leaf.LeafHash, root.RootHash, proofs[string(leaf.Index)], hasher); err != nil {
+			return fmt.Errorf("subtrees: leaf %x: %v", leaf.Index, err)
+		}
+	}
+	return nil
+}
+
+// latestMapRoot returns the latest root of the map in ms, or nil if it has
+// none.
+func latestMapRoot(ctx context.Context, ms storage.MapStorage, tree *trillian.Tree) (*types.MapRootV1, error) {
+	var root *types.MapRootV1
+	err := mapSnapshot(ctx, ms, tree, func(tx storage.ReadOnlyMapTreeTX) error {
+		smr, err := tx.LatestSignedMapRoot(ctx)
+		if err != nil {
+			return err
+		}
+		root = &types.MapRootV1{}
+		return root.UnmarshalBinary(smr.MapRoot)
+	})
+	if err == storage.ErrTreeNeedsInit {
+		return nil, nil
+	}
+	return root, err
+}
+
+// mapSnapshot runs f in a snapshot of the map in ms.
+func mapSnapshot(ctx context.Context, ms storage.MapStorage, tree *trillian.Tree, f func(storage.ReadOnlyMapTreeTX) error) error {
+	tx, err := ms.SnapshotForTree(ctx, tree)
+	if tx != nil {
+		defer tx.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
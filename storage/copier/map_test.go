@@ -0,0 +1,193 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package copier
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/extension"
+	"github.com/google/trillian/server"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly/integration"
+	"github.com/google/trillian/types"
+
+	_ "github.com/google/trillian/merkle/maphasher" // Register the hasher.
+	storageto "github.com/google/trillian/storage/testonly"
+)
+
+// registryProvider is a storage.Provider serving the storage of a registry.
+type registryProvider struct {
+	reg extension.Registry
+}
+
+func (p *registryProvider) LogStorage() storage.LogStorage     { return p.reg.LogStorage }
+func (p *registryProvider) MapStorage() storage.MapStorage     { return p.reg.MapStorage }
+func (p *registryProvider) AdminStorage() storage.AdminStorage { return p.reg.AdminStorage }
+func (p *registryProvider) Close() error                       { return nil }
+
+func newSQLiteProvider(ctx context.Context, t *testing.T) (*registryProvider, func(context.Context)) {
+	t.Helper()
+	reg, done, err := integration.NewSQLiteRegistryForTests(ctx)
+	if err != nil {
+		t.Fatalf("NewSQLiteRegistryForTests(): %v", err)
+	}
+	return &registryProvider{reg: reg}, done
+}
+
+// testMap is a map in the storage of a registryProvider, written through a
+// map server.
+type testMap struct {
+	tree   *trillian.Tree
+	server *server.TrillianMapServer
+}
+
+func newTestMap(ctx context.Context, t *testing.T, p *registryProvider) *testMap {
+	t.Helper()
+	tree, err := storage.CreateTree(ctx, p.AdminStorage(), proto.Clone(storageto.MapTree).(*trillian.Tree))
+	if err != nil {
+		t.Fatalf("CreateTree(): %v", err)
+	}
+	m := &testMap{tree: tree, server: server.NewTrillianMapServer(p.reg, server.TrillianMapServerOptions{UseSingleTransaction: true})}
+	if _, err := m.server.InitMap(ctx, &trillian.InitMapRequest{MapId: tree.TreeId}); err != nil {
+		t.Fatalf("InitMap(): %v", err)
+	}
+	return m
+}
+
+// set writes a new revision of the map, which sets the leaves with keys to
+// value.
+func (m *testMap) set(ctx context.Context, t *testing.T, value string, keys ...int) {
+	t.Helper()
+	leaves := make([]*trillian.MapLeaf, len(keys))
+	for i, key := range keys {
+		index := sha256.Sum256([]byte(fmt.Sprintf("key %d", key)))
+		leaves[i] = &trillian.MapLeaf{Index: index[:], LeafValue: []byte(value)}
+	}
+	if _, err := m.server.SetLeaves(ctx, &trillian.SetMapLeavesRequest{MapId: m.tree.TreeId, Leaves: leaves, Metadata: []byte(value)}); err != nil {
+		t.Fatalf("SetLeaves(): %v", err)
+	}
+}
+
+func mustSignedMapRoot(ctx context.Context, t *testing.T, p storage.Provider, tree *trillian.Tree, rev int64) *trillian.SignedMapRoot {
+	t.Helper()
+	var smr *trillian.SignedMapRoot
+	err := mapSnapshot(ctx, p.MapStorage(), tree, func(tx storage.ReadOnlyMapTreeTX) error {
+		var err error
+		smr, err = tx.GetSignedMapRoot(ctx, rev)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("GetSignedMapRoot(%d): %v", rev, err)
+	}
+	return smr
+}
+
+func mustRootHash(t *testing.T, smr *trillian.SignedMapRoot) []byte {
+	t.Helper()
+	var root types.MapRootV1
+	if err := root.UnmarshalBinary(smr.MapRoot); err != nil {
+		t.Fatalf("UnmarshalBinary(): %v", err)
+	}
+	return root.RootHash
+}
+
+func TestCopyMap(t *testing.T) {
+	ctx := context.Background()
+	src, srcDone := newSQLiteProvider(ctx, t)
+	defer srcDone(ctx)
+	dst, dstDone := newSQLiteProvider(ctx, t)
+	defer dstDone(ctx)
+	m := newTestMap(ctx, t, src)
+	m.set(ctx, t, "one", 1, 2, 3, 4)
+	m.set(ctx, t, "two", 2, 5)
+	m.set(ctx, t, "", 3)
+	c := &Copier{Src: src, Dst: dst, BatchSize: 2}
+
+	stats, err := c.CopyMap(ctx, m.tree.TreeId)
+	if err != nil {
+		t.Fatalf("CopyMap(): %v", err)
+	}
+	if want := (Stats{Roots: 4, Leaves: 7}); stats != want {
+		t.Errorf("CopyMap()=%+v, want %+v", stats, want)
+	}
+	if err := c.VerifyMap(ctx, m.tree.TreeId); err != nil {
+		t.Errorf("VerifyMap(): %v", err)
+	}
+	for rev := int64(0); rev <= 3; rev++ {
+		if got, want := mustSignedMapRoot(ctx, t, dst, m.tree, rev), mustSignedMapRoot(ctx, t, src, m.tree, rev); !proto.Equal(got, want) {
+			t.Errorf("root %d=%v, want %v", rev, got, want)
+		}
+	}
+
+	// Copying again resumes from the latest root copied.
+	m.set(ctx, t, "three", 1, 6)
+	stats, err = c.CopyMap(ctx, m.tree.TreeId)
+	if err != nil {
+		t.Fatalf("CopyMap() again: %v", err)
+	}
+	if want := (Stats{Roots: 1, Leaves: 2}); stats != want {
+		t.Errorf("CopyMap() again=%+v, want %+v", stats, want)
+	}
+	if err := c.VerifyMap(ctx, m.tree.TreeId); err != nil {
+		t.Errorf("VerifyMap() again: %v", err)
+	}
+
+	// Nothing is left to copy.
+	if stats, err := c.CopyMap(ctx, m.tree.TreeId); err != nil || stats != (Stats{}) {
+		t.Errorf("CopyMap() of a copied map=%+v, %v, want no stats, nil", stats, err)
+	}
+
+	// The subtrees of the copy are updated by the next write as in the
+	// source.
+	copied := &testMap{tree: m.tree, server: server.NewTrillianMapServer(dst.reg, server.TrillianMapServerOptions{UseSingleTransaction: true})}
+	copied.set(ctx, t, "four", 2, 7)
+	m.set(ctx, t, "four", 2, 7)
+	if got, want := mustRootHash(t, mustSignedMapRoot(ctx, t, dst, m.tree, 5)), mustRootHash(t, mustSignedMapRoot(ctx, t, src, m.tree, 5)); !bytes.Equal(got, want) {
+		t.Errorf("root hash of revision 5 of the copy=%x, want %x", got, want)
+	}
+}
+
+func TestVerifyMapBadLeaf(t *testing.T) {
+	ctx := context.Background()
+	src, srcDone := newSQLiteProvider(ctx, t)
+	defer srcDone(ctx)
+	dst, dstDone := newSQLiteProvider(ctx, t)
+	defer dstDone(ctx)
+	m := newTestMap(ctx, t, src)
+	m.set(ctx, t, "one", 1, 2, 3)
+	c := &Copier{Src: src, Dst: dst}
+	if _, err := c.CopyMap(ctx, m.tree.TreeId); err != nil {
+		t.Fatalf("CopyMap(): %v", err)
+	}
+
+	// The copy of a leaf has the wrong leaf hash.
+	err := dst.MapStorage().ReadWriteTransaction(ctx, m.tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		index := sha256.Sum256([]byte("key 2"))
+		return tx.SetLeafHash(ctx, 1, index[:], []byte("corrupt"))
+	})
+	if err != nil {
+		t.Fatalf("ReadWriteTransaction(): %v", err)
+	}
+	if err := c.VerifyMap(ctx, m.tree.TreeId); err == nil || !strings.Contains(err.Error(), "leaf hash") {
+		t.Errorf("VerifyMap(): %v, want leaf hash mismatch", err)
+	}
+}
@@ -62,6 +62,15 @@ type ReadOnlyLogTreeTX interface {
 	GetLeavesByHash(ctx context.Context, leafHashes [][]byte, orderBySequence bool) ([]*trillian.LogLeaf, error)
 	// LatestSignedLogRoot returns the most recent SignedLogRoot, if any.
 	LatestSignedLogRoot(ctx context.Context) (*trillian.SignedLogRoot, error)
+	// GetSignedLogRoot returns the SignedLogRoot at revision, or a NotFound
+	// error if there is none.
+	GetSignedLogRoot(ctx context.Context, revision int64) (*trillian.SignedLogRoot, error)
+	// GetSignedLogRootByHash returns the earliest SignedLogRoot with rootHash,
+	// or a NotFound error if there is none.
+	GetSignedLogRootByHash(ctx context.Context, rootHash []byte) (*trillian.SignedLogRoot, error)
+	// GetLogRootSignatures returns the witness signatures stored for the root
+	// at revision, in ascending order of the hash of their public key.
+	GetLogRootSignatures(ctx context.Context, revision int64) ([]*trillian.LogRootSignature, error)
 }
 
 // LogTreeTX is the transactional interface for reading/updating a Log.
@@ -75,6 +84,10 @@ type LogTreeTX interface {
 
 	// StoreSignedLogRoot stores a freshly created SignedLogRoot.
 	StoreSignedLogRoot(ctx context.Context, root *trillian.SignedLogRoot) error
+	// StoreLogRootSignature stores the witness signature sig over the root at
+	// revision, replacing any signature with the same public key. The caller
+	// verifies the signature.
+	StoreLogRootSignature(ctx context.Context, revision int64, sig *trillian.LogRootSignature) error
 
 	// QueueLeaves enqueues leaves for later integration into the tree.
 	// If error is nil, the returned slice of leaves will be the same size as the
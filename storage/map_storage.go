@@ -16,10 +16,38 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/trillian"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// ErrNoLeafHashIndex is returned when looking up the leaves of a map which does
+// not maintain a leaf hash index by leaf hash.
+var ErrNoLeafHashIndex = status.Error(codes.FailedPrecondition, "map has no leaf hash index")
+
+// MapLeafVersion is a version of a map leaf, as written at a revision.
+type MapLeafVersion struct {
+	Revision int64
+	Leaf     *trillian.MapLeaf
+}
+
+// MapRootFilter selects map roots by revision and by publication time. The
+// zero value of each field leaves the corresponding bound open.
+type MapRootFilter struct {
+	// StartRevision is the first revision to include.
+	StartRevision int64
+	// EndRevision, if positive, is the first revision to exclude.
+	EndRevision int64
+	// StartTime excludes the roots published before it.
+	StartTime time.Time
+	// EndTime excludes the roots published at or after it.
+	EndTime time.Time
+	// Descending lists the roots from the newest revision downwards.
+	Descending bool
+}
+
 // ReadOnlyMapTX provides a read-only view into log data.
 // A ReadOnlyMapTX, unlike ReadOnlyMapTreeTX, is not tied to a particular tree.
 type ReadOnlyMapTX interface {
@@ -44,6 +72,19 @@ type ReadOnlyMapTreeTX interface {
 	GetSignedMapRoot(ctx context.Context, revision int64) (*trillian.SignedMapRoot, error)
 	// LatestSignedMapRoot returns the most recently created SignedMapRoot.
 	LatestSignedMapRoot(ctx context.Context) (*trillian.SignedMapRoot, error)
+	// EarliestRevisionSince returns the earliest revision whose root was
+	// published at or after ts, or the latest revision plus one if there is no
+	// such root.
+	EarliestRevisionSince(ctx context.Context, ts time.Time) (int64, error)
+	// ListSignedMapRoots returns up to limit roots which match filter, in
+	// ascending revision order, or descending if filter.Descending is set.
+	ListSignedMapRoots(ctx context.Context, filter MapRootFilter, limit int) ([]*trillian.SignedMapRoot, error)
+	// GetIdempotencyToken returns the revision written by the transaction
+	// which stored token, and whether there was one.
+	GetIdempotencyToken(ctx context.Context, token []byte) (int64, bool, error)
+	// GetMapRootSignatures returns the witness signatures stored for the root
+	// at revision, in ascending order of the hash of their public key.
+	GetMapRootSignatures(ctx context.Context, revision int64) ([]*trillian.MapRootSignature, error)
 
 	// Get retrieves the values associated with the keyHashes, if any, at the
 	// specified revision.
@@ -52,6 +93,21 @@ type ReadOnlyMapTreeTX interface {
 	// exist.  i.e. requesting a set of unknown keys would result in a
 	// zero-length array being returned.
 	Get(ctx context.Context, revision int64, keyHashes [][]byte) ([]*trillian.MapLeaf, error)
+	// List returns up to limit leaves which exist at the specified revision,
+	// in ascending index order, starting with the first index greater than
+	// after. An empty after starts from the beginning of the map.
+	// Fewer than limit leaves are returned only if there are no more leaves.
+	List(ctx context.Context, revision int64, after []byte, limit int) ([]*trillian.MapLeaf, error)
+	// ListLeafVersions returns all the stored versions of up to limit leaves,
+	// in ascending index order and then in ascending revision order, starting
+	// with the first index greater than after. An empty after starts from the
+	// beginning of the map.
+	ListLeafVersions(ctx context.Context, after []byte, limit int) ([]MapLeafVersion, error)
+	// GetIndexesByLeafHash returns the indexes of the leaves which have a
+	// non-empty value with leafHash at the specified revision, in ascending
+	// order. It returns ErrNoLeafHashIndex if the map does not maintain a leaf
+	// hash index, which is configured in its storage settings.
+	GetIndexesByLeafHash(ctx context.Context, revision int64, leafHash []byte) ([][]byte, error)
 }
 
 // MapTreeTX is the transactional interface for reading/modifying a Map.
@@ -65,6 +121,25 @@ type MapTreeTX interface {
 
 	// StoreSignedMapRoot stores root.
 	StoreSignedMapRoot(ctx context.Context, root *trillian.SignedMapRoot) error
+	// StoreIdempotencyToken associates token with the write revision of the
+	// transaction, so that retries of the same write can be detected. The
+	// token is deleted along with the revision by DeleteRevisionsBefore.
+	StoreIdempotencyToken(ctx context.Context, token []byte) error
+	// StoreMapRootSignature stores the witness signature sig over the root at
+	// revision, replacing any signature with the same public key. The caller
+	// verifies the signature. Signatures are deleted along with the revision
+	// by DeleteRevisionsBefore.
+	StoreMapRootSignature(ctx context.Context, revision int64, sig *trillian.MapRootSignature) error
+	// DeleteRevisionsBefore deletes the roots of all revisions older than
+	// revision, and any leaf or subtree data that is not needed to read the
+	// map at revision or later. Storage may delete the leaf and subtree data
+	// in batches after the transaction commits, in which case data left by a
+	// failed deletion is deleted by the next call.
+	DeleteRevisionsBefore(ctx context.Context, revision int64) error
+	// SetLeafHash replaces the LeafHash stored for the version of the leaf at
+	// index which was written at revision. It is only intended for repairing
+	// hashes computed by a faulty hasher.
+	SetLeafHash(ctx context.Context, revision int64, index, leafHash []byte) error
 	// Set sets key to leaf
 	// TODO(mhutchinson): Remove the keyHash parameter or document why it is redundantly passed in
 	// (it is also inside the MapLeaf)
@@ -85,6 +160,9 @@ type ReadOnlyMapStorage interface {
 // MapTXFunc is the func signature for passing into ReadWriteTransaction.
 type MapTXFunc func(context.Context, MapTreeTX) error
 
+// MultiMapTXFunc is the func signature for passing into ReadWriteMultiTransaction.
+type MultiMapTXFunc func(context.Context, []MapTreeTX) error
+
 // MapStorage should be implemented by concrete storage mechanisms which want to support Maps
 type MapStorage interface {
 	ReadOnlyMapStorage
@@ -94,4 +172,58 @@ type MapStorage interface {
 	// If f fails and returns an error, the storage implementation may optionally
 	// retry with a new transaction, and f MUST NOT keep state across calls.
 	ReadWriteTransaction(ctx context.Context, tree *trillian.Tree, f MapTXFunc) error
+
+	// ReadWriteMultiTransaction starts a single RW transaction spanning all of
+	// trees, and calls f with one MapTreeTX per tree, in the same order.
+	// Either all of the writes made through the transactions are committed, or
+	// none are. The same retry semantics as ReadWriteTransaction apply.
+	ReadWriteMultiTransaction(ctx context.Context, trees []*trillian.Tree, f MultiMapTXFunc) error
+}
+
+// QueuedMapWrite is a write to a map which is queued to be merged into a later
+// revision of the map.
+type QueuedMapWrite struct {
+	// Leaves are the leaves to set, with their LeafHash.
+	Leaves []*trillian.MapLeaf
+	// Metadata is the metadata of the map root which merges the write.
+	Metadata []byte
+	// QueueTimestamp is the time at which the write was queued.
+	QueueTimestamp time.Time
+}
+
+// MapWriteQueuer is implemented by MapStorage which supports queued writes.
+type MapWriteQueuer interface {
+	// QueueMapWrite durably queues w to be merged into tree.
+	QueueMapWrite(ctx context.Context, tree *trillian.Tree, w *QueuedMapWrite) error
+}
+
+// MapWriteDequeuer is implemented by the MapTreeTX of MapStorage which supports
+// queued writes.
+type MapWriteDequeuer interface {
+	// DequeueMapWrites removes up to limit of the oldest queued writes of the
+	// tree, and returns them in the order in which they were queued. They are
+	// only removed if the transaction commits.
+	DequeueMapWrites(ctx context.Context, limit int) ([]*QueuedMapWrite, error)
+}
+
+// MapRevisionLease is a reservation of a write revision of a map.
+type MapRevisionLease struct {
+	// Revision is the reserved revision.
+	Revision int64
+	// Token identifies the holder of the lease, who may write at Revision.
+	Token []byte
+	// Expiry is the time at which the lease expires.
+	Expiry time.Time
+}
+
+// MapRevisionReserver is implemented by MapStorage which supports reserving
+// write revisions.
+type MapRevisionReserver interface {
+	// ReserveMapRevision atomically reserves the lowest revision of tree which
+	// is neither written nor reserved by a lease which is unexpired at now,
+	// for the holder of token until expiry, and returns it.
+	ReserveMapRevision(ctx context.Context, tree *trillian.Tree, token []byte, now, expiry time.Time) (int64, error)
+	// GetMapRevisionLease returns the lease on revision of tree which is
+	// unexpired at now, or nil if there is none.
+	GetMapRevisionLease(ctx context.Context, tree *trillian.Tree, revision int64, now time.Time) (*MapRevisionLease, error)
 }
@@ -25,6 +25,8 @@ import (
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/trillian"
 	"github.com/google/trillian/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // NewAdminStorage returns a storage.AdminStorage implementation backed by
@@ -145,7 +147,7 @@ func (t *adminTX) CreateTree(ctx context.Context, tr *trillian.Tree) (*trillian.
 		return nil, err
 	}
 
-	id, err := storage.NewTreeID()
+	id, err := storage.TreeIDForCreation(tr)
 	if err != nil {
 		return nil, err
 	}
@@ -165,6 +167,9 @@ func (t *adminTX) CreateTree(ctx context.Context, tr *trillian.Tree) (*trillian.
 
 	t.ms.mu.Lock()
 	defer t.ms.mu.Unlock()
+	if _, ok := t.ms.trees[id]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "tree %d already exists", id)
+	}
 	t.ms.trees[id] = newTree(meta)
 
 	glog.V(1).Infof("trees: %v", t.ms.trees)
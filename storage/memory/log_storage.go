@@ -15,12 +15,15 @@
 package memory
 
 import (
+	"bytes"
 	"container/list"
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -79,6 +82,18 @@ func sthKey(treeID int64, timestamp uint64) btree.Item {
 	return &kv{k: fmt.Sprintf("/%d/sth/%020d", treeID, timestamp)}
 }
 
+// sthSigKey formats a key for use in a tree's BTree store.
+// The associated Item value will be the witness signature with the given
+// public key hash over the STH with the given revision. The keys of all the
+// signatures over an STH start with sthSigPrefix.
+func sthSigKey(treeID int64, revision int64, keyHash []byte) btree.Item {
+	return &kv{k: fmt.Sprintf("%s%x", sthSigPrefix(treeID, revision), keyHash)}
+}
+
+func sthSigPrefix(treeID int64, revision int64) string {
+	return fmt.Sprintf("/%d/sthsig/%020d/", treeID, revision)
+}
+
 // getActiveLogIDs returns the IDs of all logs that are currently in a state
 // that requires sequencing (e.g. ACTIVE, DRAINING).
 func getActiveLogIDs(trees map[int64]*tree) []int64 {
@@ -198,12 +213,22 @@ func (m *memoryLogStorage) ReadWriteTransaction(ctx context.Context, tree *trill
 }
 
 func (m *memoryLogStorage) AddSequencedLeaves(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, timestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
-	return nil, status.Errorf(codes.Unimplemented, "AddSequencedLeaves is not implemented")
+	var ret []*trillian.QueuedLogLeaf
+	err := m.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		var err error
+		ret, err = tx.AddSequencedLeaves(ctx, leaves, timestamp)
+		return err
+	})
+	return ret, err
 }
 
 func (m *memoryLogStorage) SnapshotForTree(ctx context.Context, tree *trillian.Tree) (storage.ReadOnlyLogTreeTX, error) {
 	tx, err := m.beginInternal(ctx, tree, true /* readonly */)
 	if err != nil {
+		if tx != nil {
+			// Release the lock on the tree taken by uninitialised trees.
+			tx.Close()
+		}
 		return nil, err
 	}
 	return tx.(storage.ReadOnlyLogTreeTX), err
@@ -294,7 +319,27 @@ func (t *logTreeTX) QueueLeaves(ctx context.Context, leaves []*trillian.LogLeaf,
 }
 
 func (t *logTreeTX) AddSequencedLeaves(ctx context.Context, leaves []*trillian.LogLeaf, timestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
-	return nil, status.Errorf(codes.Unimplemented, "AddSequencedLeaves is not implemented")
+	res := make([]*trillian.QueuedLogLeaf, len(leaves))
+	ok := status.New(codes.OK, "OK").Proto()
+	m := t.tx.Get(hashToSeqKey(t.treeID)).(*kv).v.(map[string][]int64)
+	for i, leaf := range leaves {
+		if got, want := len(leaf.LeafIdentityHash), t.hashSizeBytes; got != want {
+			return nil, status.Errorf(codes.FailedPrecondition, "leaves[%d] has incorrect hash size %d, want %d", i, got, want)
+		}
+		k := seqLeafKey(t.treeID, leaf.LeafIndex)
+		if existing := t.tx.Get(k); existing != nil {
+			res[i] = &trillian.QueuedLogLeaf{
+				Leaf:   existing.(*kv).v.(*trillian.LogLeaf),
+				Status: status.New(codes.FailedPrecondition, "conflicting LeafIndex").Proto(),
+			}
+			continue
+		}
+		k.(*kv).v = leaf
+		t.tx.ReplaceOrInsert(k)
+		m[string(leaf.MerkleLeafHash)] = append(m[string(leaf.MerkleLeafHash)], leaf.LeafIndex)
+		res[i] = &trillian.QueuedLogLeaf{Status: ok}
+	}
+	return res, nil
 }
 
 func (t *logTreeTX) GetSequencedLeafCount(ctx context.Context) (int64, error) {
@@ -378,6 +423,73 @@ func (t *logTreeTX) StoreSignedLogRoot(ctx context.Context, slr *trillian.Signed
 	return nil
 }
 
+func (t *logTreeTX) GetSignedLogRoot(ctx context.Context, revision int64) (*trillian.SignedLogRoot, error) {
+	found, err := t.findSignedLogRoot(func(root *types.LogRootV1) bool {
+		return int64(root.Revision) == revision
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, status.Errorf(codes.NotFound, "log root %d not found", revision)
+	}
+	return found, nil
+}
+
+func (t *logTreeTX) GetSignedLogRootByHash(ctx context.Context, rootHash []byte) (*trillian.SignedLogRoot, error) {
+	found, err := t.findSignedLogRoot(func(root *types.LogRootV1) bool {
+		return bytes.Equal(root.RootHash, rootHash)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, status.Errorf(codes.NotFound, "log root with hash %x not found", rootHash)
+	}
+	return found, nil
+}
+
+// findSignedLogRoot returns the earliest STH which matches, or nil if there is
+// none. STHs are keyed by timestamp, so this looks at all of them.
+func (t *logTreeTX) findSignedLogRoot(match func(*types.LogRootV1) bool) (*trillian.SignedLogRoot, error) {
+	var found *trillian.SignedLogRoot
+	var err error
+	t.tx.AscendRange(sthKey(t.treeID, 0), sthKey(t.treeID, math.MaxUint64), func(i btree.Item) bool {
+		slr := i.(*kv).v.(*trillian.SignedLogRoot)
+		var root types.LogRootV1
+		if err = root.UnmarshalBinary(slr.LogRoot); err != nil {
+			return false
+		}
+		if match(&root) {
+			found = slr
+			return false
+		}
+		return true
+	})
+	return found, err
+}
+
+func (t *logTreeTX) GetLogRootSignatures(ctx context.Context, revision int64) ([]*trillian.LogRootSignature, error) {
+	var sigs []*trillian.LogRootSignature
+	prefix := sthSigPrefix(t.treeID, revision)
+	t.tx.AscendGreaterOrEqual(&kv{k: prefix}, func(i btree.Item) bool {
+		if !strings.HasPrefix(i.(*kv).k, prefix) {
+			return false
+		}
+		sigs = append(sigs, i.(*kv).v.(*trillian.LogRootSignature))
+		return true
+	})
+	return sigs, nil
+}
+
+func (t *logTreeTX) StoreLogRootSignature(ctx context.Context, revision int64, sig *trillian.LogRootSignature) error {
+	keyHash := sha256.Sum256(sig.GetPublicKey().GetDer())
+	k := sthSigKey(t.treeID, revision, keyHash[:])
+	k.(*kv).v = sig
+	t.tx.ReplaceOrInsert(k)
+	return nil
+}
+
 func (t *logTreeTX) UpdateSequencedLeaves(ctx context.Context, leaves []*trillian.LogLeaf) error {
 	countByMerkleHash := make(map[string]int)
 	for _, leaf := range leaves {
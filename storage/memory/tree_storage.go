@@ -25,9 +25,12 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/google/btree"
 	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cache"
 	"github.com/google/trillian/storage/storagepb"
 	stree "github.com/google/trillian/storage/tree"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const degree = 8
@@ -264,6 +267,7 @@ func (t *treeTX) Commit(ctx context.Context) error {
 		}
 	}
 	t.closed = true
+	t.subtreeCache.Release()
 	// update the shared view of the tree post TX:
 	t.tree.store = t.tx
 	return nil
@@ -273,6 +277,7 @@ func (t *treeTX) Rollback() error {
 	defer t.unlock()
 
 	t.closed = true
+	t.subtreeCache.Release()
 	return nil
 }
 
@@ -290,3 +295,15 @@ func (t *treeTX) Close() error {
 func (t *treeTX) IsOpen() bool {
 	return !t.closed
 }
+
+// GetRecoveryMarker implements storage.RecoveryMarkerReader. Recovery markers
+// are not supported by in-memory storage.
+func (t *treeTX) GetRecoveryMarker(ctx context.Context, revision int64) (*storage.RecoveryMarker, error) {
+	return nil, status.Error(codes.Unimplemented, "recovery markers are not supported")
+}
+
+// LatestRecoveryMarker implements storage.RecoveryMarkerReader. Recovery
+// markers are not supported by in-memory storage.
+func (t *treeTX) LatestRecoveryMarker(ctx context.Context) (*storage.RecoveryMarker, error) {
+	return nil, status.Error(codes.Unimplemented, "recovery markers are not supported")
+}
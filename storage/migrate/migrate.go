@@ -0,0 +1,186 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package migrate upgrades and downgrades the schemas of the SQL storage
+// systems in place. The version of a schema is recorded in a single-row table
+// of its database, which the servers check at startup.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrUnversioned is returned for databases that don't record the version of
+// their schema, e.g. because they were created before schemas were versioned.
+// Such databases have to be baselined before they can be migrated.
+var ErrUnversioned = errors.New("migrate: the schema version isn't recorded")
+
+// Step changes a schema from the previous version to Version, and back.
+type Step struct {
+	Version     int
+	Description string
+	// Up holds the statements changing the schema to Version, and Down those
+	// reverting it to the previous version.
+	Up, Down []string
+}
+
+// Schema is the history of the schema of a storage system.
+type Schema struct {
+	// Table is the name of the table recording the schema version. It has a
+	// single Version column, and a single row.
+	Table string
+	// ExistsSQL counts the tables named Table in the database, i.e. returns 0
+	// for unversioned databases.
+	ExistsSQL string
+	// Steps are the migration steps, in order, whose versions count up from 1.
+	// Step 1 is the schema of the last release before versioning was
+	// introduced, which databases created before then are baselined at, so it
+	// has no statements.
+	Steps []Step
+}
+
+// Latest returns the version of the schema created from scratch, i.e. the
+// version of its last step.
+func (s Schema) Latest() int {
+	if len(s.Steps) == 0 {
+		return 0
+	}
+	return s.Steps[len(s.Steps)-1].Version
+}
+
+// Validate returns an error if the steps of s don't count up from 1.
+func (s Schema) Validate() error {
+	for i, step := range s.Steps {
+		if step.Version != i+1 {
+			return fmt.Errorf("migrate: step %d has version %d, want %d", i, step.Version, i+1)
+		}
+	}
+	if len(s.Steps) == 0 {
+		return errors.New("migrate: no steps")
+	}
+	return nil
+}
+
+// Version returns the schema version recorded in db, or ErrUnversioned.
+func (s Schema) Version(ctx context.Context, db *sql.DB) (int, error) {
+	var tables int
+	if err := db.QueryRowContext(ctx, s.ExistsSQL).Scan(&tables); err != nil {
+		return 0, fmt.Errorf("migrate: failed to look for table %s: %v", s.Table, err)
+	}
+	if tables == 0 {
+		return 0, ErrUnversioned
+	}
+	var version int
+	switch err := db.QueryRowContext(ctx, "SELECT Version FROM "+s.Table).Scan(&version); {
+	case err == sql.ErrNoRows:
+		return 0, ErrUnversioned
+	case err != nil:
+		return 0, fmt.Errorf("migrate: failed to read schema version: %v", err)
+	}
+	return version, nil
+}
+
+// Check returns an error unless the schema of db is at the latest version.
+// Unversioned databases return ErrUnversioned.
+func (s Schema) Check(ctx context.Context, db *sql.DB) error {
+	version, err := s.Version(ctx, db)
+	if err != nil {
+		return err
+	}
+	switch latest := s.Latest(); {
+	case version < latest:
+		return fmt.Errorf("migrate: schema version %d is older than %d, upgrade it with trillian_migrate", version, latest)
+	case version > latest:
+		return fmt.Errorf("migrate: schema version %d is newer than %d, which is the latest known to this binary", version, latest)
+	}
+	return nil
+}
+
+// Baseline records version as the schema version of an unversioned database,
+// whose schema must already match that version. It creates the version table
+// if need be.
+func (s Schema) Baseline(ctx context.Context, db *sql.DB, version int) error {
+	if version < 1 || version > s.Latest() {
+		return fmt.Errorf("migrate: can't baseline at unknown version %d", version)
+	}
+	switch _, err := s.Version(ctx, db); err {
+	case ErrUnversioned:
+	case nil:
+		return errors.New("migrate: the schema version is already recorded")
+	default:
+		return err
+	}
+	stmts := []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s(Version INTEGER NOT NULL)", s.Table)}
+	return s.apply(ctx, db, stmts, version)
+}
+
+// Up applies the steps which upgrade the schema of db to version target.
+func (s Schema) Up(ctx context.Context, db *sql.DB, target int) error {
+	version, err := s.Version(ctx, db)
+	if err != nil {
+		return err
+	}
+	if target < version || target > s.Latest() {
+		return fmt.Errorf("migrate: can't upgrade from version %d to %d", version, target)
+	}
+	for _, step := range s.Steps[version:target] {
+		if err := s.apply(ctx, db, step.Up, step.Version); err != nil {
+			return fmt.Errorf("migrate: failed to upgrade to version %d (%s): %v", step.Version, step.Description, err)
+		}
+	}
+	return nil
+}
+
+// Down reverts the steps which downgrade the schema of db to version target,
+// which can't be lower than 1.
+func (s Schema) Down(ctx context.Context, db *sql.DB, target int) error {
+	version, err := s.Version(ctx, db)
+	if err != nil {
+		return err
+	}
+	if target < 1 || target > version || version > s.Latest() {
+		return fmt.Errorf("migrate: can't downgrade from version %d to %d", version, target)
+	}
+	for i := version - 1; i >= target; i-- {
+		step := s.Steps[i]
+		if err := s.apply(ctx, db, step.Down, step.Version-1); err != nil {
+			return fmt.Errorf("migrate: failed to downgrade from version %d (%s): %v", step.Version, step.Description, err)
+		}
+	}
+	return nil
+}
+
+// apply executes stmts and records version in a transaction. Some databases,
+// e.g. MySQL, commit DDL statements implicitly, so a failed step may have to
+// be finished by hand.
+func (s Schema) apply(ctx context.Context, db *sql.DB, stmts []string, version int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	record := []string{
+		"DELETE FROM " + s.Table,
+		fmt.Sprintf("INSERT INTO %s(Version) VALUES(%d)", s.Table, version),
+	}
+	for _, stmt := range append(append([]string(nil), stmts...), record...) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
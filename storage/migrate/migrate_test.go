@@ -0,0 +1,182 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3" // Load the SQLite driver.
+)
+
+var testSchema = Schema{
+	Table:     "SchemaVersion",
+	ExistsSQL: "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'SchemaVersion'",
+	Steps: []Step{
+		{Version: 1, Description: "baseline"},
+		{
+			Version:     2,
+			Description: "add Foo",
+			Up:          []string{"CREATE TABLE Foo(Id INTEGER NOT NULL)"},
+			Down:        []string{"DROP TABLE Foo"},
+		},
+		{
+			Version:     3,
+			Description: "index Foo",
+			Up:          []string{"CREATE INDEX FooIdx ON Foo(Id)"},
+			Down:        []string{"DROP INDEX FooIdx"},
+		},
+	},
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open(): %v", err)
+	}
+	// Each connection to an in-memory database has its own database.
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func checkVersion(ctx context.Context, t *testing.T, s Schema, db *sql.DB, want int) {
+	t.Helper()
+	if got, err := s.Version(ctx, db); err != nil || got != want {
+		t.Errorf("Version()=%d, %v, want %d, nil", got, err, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := testSchema.Validate(); err != nil {
+		t.Errorf("Validate(): %v", err)
+	}
+	for _, s := range []Schema{
+		{},
+		{Steps: []Step{{Version: 2}}},
+		{Steps: []Step{{Version: 1}, {Version: 3}}},
+	} {
+		if err := s.Validate(); err == nil {
+			t.Errorf("Validate(%+v): nil, want err", s.Steps)
+		}
+	}
+}
+
+func TestUnversioned(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	defer db.Close()
+
+	if _, err := testSchema.Version(ctx, db); err != ErrUnversioned {
+		t.Errorf("Version(): %v, want %v", err, ErrUnversioned)
+	}
+	if err := testSchema.Check(ctx, db); err != ErrUnversioned {
+		t.Errorf("Check(): %v, want %v", err, ErrUnversioned)
+	}
+	if err := testSchema.Up(ctx, db, 3); err != ErrUnversioned {
+		t.Errorf("Up(): %v, want %v", err, ErrUnversioned)
+	}
+	if err := testSchema.Baseline(ctx, db, 4); err == nil {
+		t.Error("Baseline(4): nil, want err")
+	}
+	if err := testSchema.Baseline(ctx, db, 1); err != nil {
+		t.Fatalf("Baseline(1): %v", err)
+	}
+	checkVersion(ctx, t, testSchema, db, 1)
+	if err := testSchema.Baseline(ctx, db, 1); err == nil {
+		t.Error("Baseline(1) on a versioned database: nil, want err")
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	defer db.Close()
+	if err := testSchema.Baseline(ctx, db, 1); err != nil {
+		t.Fatalf("Baseline(1): %v", err)
+	}
+
+	if err := testSchema.Up(ctx, db, 2); err != nil {
+		t.Fatalf("Up(2): %v", err)
+	}
+	checkVersion(ctx, t, testSchema, db, 2)
+	if err := testSchema.Check(ctx, db); err == nil {
+		t.Error("Check() at version 2: nil, want err")
+	}
+	if err := testSchema.Up(ctx, db, 3); err != nil {
+		t.Fatalf("Up(3): %v", err)
+	}
+	checkVersion(ctx, t, testSchema, db, 3)
+	if err := testSchema.Check(ctx, db); err != nil {
+		t.Errorf("Check() at version 3: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO Foo(Id) VALUES(1)"); err != nil {
+		t.Errorf("INSERT INTO Foo: %v", err)
+	}
+
+	for _, target := range []int{2, 4} {
+		if err := testSchema.Up(ctx, db, target); err == nil {
+			t.Errorf("Up(%d) from version 3: nil, want err", target)
+		}
+	}
+	for _, target := range []int{0, 4} {
+		if err := testSchema.Down(ctx, db, target); err == nil {
+			t.Errorf("Down(%d) from version 3: nil, want err", target)
+		}
+	}
+
+	if err := testSchema.Down(ctx, db, 1); err != nil {
+		t.Fatalf("Down(1): %v", err)
+	}
+	checkVersion(ctx, t, testSchema, db, 1)
+	if _, err := db.ExecContext(ctx, "INSERT INTO Foo(Id) VALUES(1)"); err == nil {
+		t.Error("INSERT INTO Foo after Down(1): nil, want err")
+	}
+}
+
+func TestFailedStep(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	defer db.Close()
+	if err := testSchema.Baseline(ctx, db, 1); err != nil {
+		t.Fatalf("Baseline(1): %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "CREATE INDEX FooIdx ON SchemaVersion(Version)"); err != nil {
+		t.Fatalf("CREATE INDEX: %v", err)
+	}
+
+	// Step 3 fails, as its index already exists, but step 2 stays applied.
+	if err := testSchema.Up(ctx, db, 3); err == nil {
+		t.Fatal("Up(3): nil, want err")
+	}
+	checkVersion(ctx, t, testSchema, db, 2)
+}
+
+func TestNewerVersion(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	defer db.Close()
+	if err := testSchema.Baseline(ctx, db, 3); err != nil {
+		t.Fatalf("Baseline(3): %v", err)
+	}
+	old := Schema{Table: testSchema.Table, ExistsSQL: testSchema.ExistsSQL, Steps: testSchema.Steps[:2]}
+	if err := old.Check(ctx, db); err == nil {
+		t.Error("Check() of a newer schema: nil, want err")
+	}
+	if err := old.Down(ctx, db, 1); err == nil {
+		t.Error("Down() of a newer schema: nil, want err")
+	}
+}
@@ -514,6 +514,21 @@ func (mr *MockLogTreeTXMockRecorder) GetLeavesByRange(arg0, arg1, arg2 interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeavesByRange", reflect.TypeOf((*MockLogTreeTX)(nil).GetLeavesByRange), arg0, arg1, arg2)
 }
 
+// GetLogRootSignatures mocks base method
+func (m *MockLogTreeTX) GetLogRootSignatures(arg0 context.Context, arg1 int64) ([]*trillian.LogRootSignature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLogRootSignatures", arg0, arg1)
+	ret0, _ := ret[0].([]*trillian.LogRootSignature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLogRootSignatures indicates an expected call of GetLogRootSignatures
+func (mr *MockLogTreeTXMockRecorder) GetLogRootSignatures(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLogRootSignatures", reflect.TypeOf((*MockLogTreeTX)(nil).GetLogRootSignatures), arg0, arg1)
+}
+
 // GetMerkleNodes mocks base method
 func (m *MockLogTreeTX) GetMerkleNodes(arg0 context.Context, arg1 int64, arg2 []tree.NodeID) ([]tree.Node, error) {
 	m.ctrl.T.Helper()
@@ -529,6 +544,21 @@ func (mr *MockLogTreeTXMockRecorder) GetMerkleNodes(arg0, arg1, arg2 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMerkleNodes", reflect.TypeOf((*MockLogTreeTX)(nil).GetMerkleNodes), arg0, arg1, arg2)
 }
 
+// GetRecoveryMarker mocks base method
+func (m *MockLogTreeTX) GetRecoveryMarker(arg0 context.Context, arg1 int64) (*RecoveryMarker, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRecoveryMarker", arg0, arg1)
+	ret0, _ := ret[0].(*RecoveryMarker)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRecoveryMarker indicates an expected call of GetRecoveryMarker
+func (mr *MockLogTreeTXMockRecorder) GetRecoveryMarker(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRecoveryMarker", reflect.TypeOf((*MockLogTreeTX)(nil).GetRecoveryMarker), arg0, arg1)
+}
+
 // GetSequencedLeafCount mocks base method
 func (m *MockLogTreeTX) GetSequencedLeafCount(arg0 context.Context) (int64, error) {
 	m.ctrl.T.Helper()
@@ -544,6 +574,36 @@ func (mr *MockLogTreeTXMockRecorder) GetSequencedLeafCount(arg0 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSequencedLeafCount", reflect.TypeOf((*MockLogTreeTX)(nil).GetSequencedLeafCount), arg0)
 }
 
+// GetSignedLogRoot mocks base method
+func (m *MockLogTreeTX) GetSignedLogRoot(arg0 context.Context, arg1 int64) (*trillian.SignedLogRoot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSignedLogRoot", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.SignedLogRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSignedLogRoot indicates an expected call of GetSignedLogRoot
+func (mr *MockLogTreeTXMockRecorder) GetSignedLogRoot(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSignedLogRoot", reflect.TypeOf((*MockLogTreeTX)(nil).GetSignedLogRoot), arg0, arg1)
+}
+
+// GetSignedLogRootByHash mocks base method
+func (m *MockLogTreeTX) GetSignedLogRootByHash(arg0 context.Context, arg1 []byte) (*trillian.SignedLogRoot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSignedLogRootByHash", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.SignedLogRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSignedLogRootByHash indicates an expected call of GetSignedLogRootByHash
+func (mr *MockLogTreeTXMockRecorder) GetSignedLogRootByHash(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSignedLogRootByHash", reflect.TypeOf((*MockLogTreeTX)(nil).GetSignedLogRootByHash), arg0, arg1)
+}
+
 // IsOpen mocks base method
 func (m *MockLogTreeTX) IsOpen() bool {
 	m.ctrl.T.Helper()
@@ -558,6 +618,21 @@ func (mr *MockLogTreeTXMockRecorder) IsOpen() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsOpen", reflect.TypeOf((*MockLogTreeTX)(nil).IsOpen))
 }
 
+// LatestRecoveryMarker mocks base method
+func (m *MockLogTreeTX) LatestRecoveryMarker(arg0 context.Context) (*RecoveryMarker, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LatestRecoveryMarker", arg0)
+	ret0, _ := ret[0].(*RecoveryMarker)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LatestRecoveryMarker indicates an expected call of LatestRecoveryMarker
+func (mr *MockLogTreeTXMockRecorder) LatestRecoveryMarker(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestRecoveryMarker", reflect.TypeOf((*MockLogTreeTX)(nil).LatestRecoveryMarker), arg0)
+}
+
 // LatestSignedLogRoot mocks base method
 func (m *MockLogTreeTX) LatestSignedLogRoot(arg0 context.Context) (*trillian.SignedLogRoot, error) {
 	m.ctrl.T.Helper()
@@ -631,6 +706,20 @@ func (mr *MockLogTreeTXMockRecorder) SetMerkleNodes(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMerkleNodes", reflect.TypeOf((*MockLogTreeTX)(nil).SetMerkleNodes), arg0, arg1)
 }
 
+// StoreLogRootSignature mocks base method
+func (m *MockLogTreeTX) StoreLogRootSignature(arg0 context.Context, arg1 int64, arg2 *trillian.LogRootSignature) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreLogRootSignature", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreLogRootSignature indicates an expected call of StoreLogRootSignature
+func (mr *MockLogTreeTXMockRecorder) StoreLogRootSignature(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreLogRootSignature", reflect.TypeOf((*MockLogTreeTX)(nil).StoreLogRootSignature), arg0, arg1, arg2)
+}
+
 // StoreSignedLogRoot mocks base method
 func (m *MockLogTreeTX) StoreSignedLogRoot(arg0 context.Context, arg1 *trillian.SignedLogRoot) error {
 	m.ctrl.T.Helper()
@@ -711,6 +800,20 @@ func (mr *MockMapStorageMockRecorder) CheckDatabaseAccessible(arg0 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckDatabaseAccessible", reflect.TypeOf((*MockMapStorage)(nil).CheckDatabaseAccessible), arg0)
 }
 
+// ReadWriteMultiTransaction mocks base method
+func (m *MockMapStorage) ReadWriteMultiTransaction(arg0 context.Context, arg1 []*trillian.Tree, arg2 MultiMapTXFunc) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadWriteMultiTransaction", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReadWriteMultiTransaction indicates an expected call of ReadWriteMultiTransaction
+func (mr *MockMapStorageMockRecorder) ReadWriteMultiTransaction(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadWriteMultiTransaction", reflect.TypeOf((*MockMapStorage)(nil).ReadWriteMultiTransaction), arg0, arg1, arg2)
+}
+
 // ReadWriteTransaction mocks base method
 func (m *MockMapStorage) ReadWriteTransaction(arg0 context.Context, arg1 *trillian.Tree, arg2 MapTXFunc) error {
 	m.ctrl.T.Helper()
@@ -791,6 +894,35 @@ func (mr *MockMapTreeTXMockRecorder) Commit(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockMapTreeTX)(nil).Commit), arg0)
 }
 
+// DeleteRevisionsBefore mocks base method
+func (m *MockMapTreeTX) DeleteRevisionsBefore(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRevisionsBefore", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRevisionsBefore indicates an expected call of DeleteRevisionsBefore
+func (mr *MockMapTreeTXMockRecorder) DeleteRevisionsBefore(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRevisionsBefore", reflect.TypeOf((*MockMapTreeTX)(nil).DeleteRevisionsBefore), arg0, arg1)
+}
+
+// EarliestRevisionSince mocks base method
+func (m *MockMapTreeTX) EarliestRevisionSince(arg0 context.Context, arg1 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EarliestRevisionSince", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EarliestRevisionSince indicates an expected call of EarliestRevisionSince
+func (mr *MockMapTreeTXMockRecorder) EarliestRevisionSince(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EarliestRevisionSince", reflect.TypeOf((*MockMapTreeTX)(nil).EarliestRevisionSince), arg0, arg1)
+}
+
 // Get mocks base method
 func (m *MockMapTreeTX) Get(arg0 context.Context, arg1 int64, arg2 [][]byte) ([]*trillian.MapLeaf, error) {
 	m.ctrl.T.Helper()
@@ -806,6 +938,52 @@ func (mr *MockMapTreeTXMockRecorder) Get(arg0, arg1, arg2 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockMapTreeTX)(nil).Get), arg0, arg1, arg2)
 }
 
+// GetIdempotencyToken mocks base method
+func (m *MockMapTreeTX) GetIdempotencyToken(arg0 context.Context, arg1 []byte) (int64, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyToken", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetIdempotencyToken indicates an expected call of GetIdempotencyToken
+func (mr *MockMapTreeTXMockRecorder) GetIdempotencyToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyToken", reflect.TypeOf((*MockMapTreeTX)(nil).GetIdempotencyToken), arg0, arg1)
+}
+
+// GetIndexesByLeafHash mocks base method
+func (m *MockMapTreeTX) GetIndexesByLeafHash(arg0 context.Context, arg1 int64, arg2 []byte) ([][]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIndexesByLeafHash", arg0, arg1, arg2)
+	ret0, _ := ret[0].([][]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIndexesByLeafHash indicates an expected call of GetIndexesByLeafHash
+func (mr *MockMapTreeTXMockRecorder) GetIndexesByLeafHash(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIndexesByLeafHash", reflect.TypeOf((*MockMapTreeTX)(nil).GetIndexesByLeafHash), arg0, arg1, arg2)
+}
+
+// GetMapRootSignatures mocks base method
+func (m *MockMapTreeTX) GetMapRootSignatures(arg0 context.Context, arg1 int64) ([]*trillian.MapRootSignature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMapRootSignatures", arg0, arg1)
+	ret0, _ := ret[0].([]*trillian.MapRootSignature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMapRootSignatures indicates an expected call of GetMapRootSignatures
+func (mr *MockMapTreeTXMockRecorder) GetMapRootSignatures(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMapRootSignatures", reflect.TypeOf((*MockMapTreeTX)(nil).GetMapRootSignatures), arg0, arg1)
+}
+
 // GetMerkleNodes mocks base method
 func (m *MockMapTreeTX) GetMerkleNodes(arg0 context.Context, arg1 int64, arg2 []tree.NodeID) ([]tree.Node, error) {
 	m.ctrl.T.Helper()
@@ -821,6 +999,21 @@ func (mr *MockMapTreeTXMockRecorder) GetMerkleNodes(arg0, arg1, arg2 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMerkleNodes", reflect.TypeOf((*MockMapTreeTX)(nil).GetMerkleNodes), arg0, arg1, arg2)
 }
 
+// GetRecoveryMarker mocks base method
+func (m *MockMapTreeTX) GetRecoveryMarker(arg0 context.Context, arg1 int64) (*RecoveryMarker, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRecoveryMarker", arg0, arg1)
+	ret0, _ := ret[0].(*RecoveryMarker)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRecoveryMarker indicates an expected call of GetRecoveryMarker
+func (mr *MockMapTreeTXMockRecorder) GetRecoveryMarker(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRecoveryMarker", reflect.TypeOf((*MockMapTreeTX)(nil).GetRecoveryMarker), arg0, arg1)
+}
+
 // GetSignedMapRoot mocks base method
 func (m *MockMapTreeTX) GetSignedMapRoot(arg0 context.Context, arg1 int64) (*trillian.SignedMapRoot, error) {
 	m.ctrl.T.Helper()
@@ -850,6 +1043,21 @@ func (mr *MockMapTreeTXMockRecorder) IsOpen() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsOpen", reflect.TypeOf((*MockMapTreeTX)(nil).IsOpen))
 }
 
+// LatestRecoveryMarker mocks base method
+func (m *MockMapTreeTX) LatestRecoveryMarker(arg0 context.Context) (*RecoveryMarker, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LatestRecoveryMarker", arg0)
+	ret0, _ := ret[0].(*RecoveryMarker)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LatestRecoveryMarker indicates an expected call of LatestRecoveryMarker
+func (mr *MockMapTreeTXMockRecorder) LatestRecoveryMarker(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestRecoveryMarker", reflect.TypeOf((*MockMapTreeTX)(nil).LatestRecoveryMarker), arg0)
+}
+
 // LatestSignedMapRoot mocks base method
 func (m *MockMapTreeTX) LatestSignedMapRoot(arg0 context.Context) (*trillian.SignedMapRoot, error) {
 	m.ctrl.T.Helper()
@@ -865,6 +1073,51 @@ func (mr *MockMapTreeTXMockRecorder) LatestSignedMapRoot(arg0 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestSignedMapRoot", reflect.TypeOf((*MockMapTreeTX)(nil).LatestSignedMapRoot), arg0)
 }
 
+// List mocks base method
+func (m *MockMapTreeTX) List(arg0 context.Context, arg1 int64, arg2 []byte, arg3 int) ([]*trillian.MapLeaf, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]*trillian.MapLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List
+func (mr *MockMapTreeTXMockRecorder) List(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockMapTreeTX)(nil).List), arg0, arg1, arg2, arg3)
+}
+
+// ListLeafVersions mocks base method
+func (m *MockMapTreeTX) ListLeafVersions(arg0 context.Context, arg1 []byte, arg2 int) ([]MapLeafVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLeafVersions", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]MapLeafVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListLeafVersions indicates an expected call of ListLeafVersions
+func (mr *MockMapTreeTXMockRecorder) ListLeafVersions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLeafVersions", reflect.TypeOf((*MockMapTreeTX)(nil).ListLeafVersions), arg0, arg1, arg2)
+}
+
+// ListSignedMapRoots mocks base method
+func (m *MockMapTreeTX) ListSignedMapRoots(arg0 context.Context, arg1 MapRootFilter, arg2 int) ([]*trillian.SignedMapRoot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSignedMapRoots", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]*trillian.SignedMapRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSignedMapRoots indicates an expected call of ListSignedMapRoots
+func (mr *MockMapTreeTXMockRecorder) ListSignedMapRoots(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSignedMapRoots", reflect.TypeOf((*MockMapTreeTX)(nil).ListSignedMapRoots), arg0, arg1, arg2)
+}
+
 // ReadRevision mocks base method
 func (m *MockMapTreeTX) ReadRevision(arg0 context.Context) (int64, error) {
 	m.ctrl.T.Helper()
@@ -908,6 +1161,20 @@ func (mr *MockMapTreeTXMockRecorder) Set(arg0, arg1, arg2 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockMapTreeTX)(nil).Set), arg0, arg1, arg2)
 }
 
+// SetLeafHash mocks base method
+func (m *MockMapTreeTX) SetLeafHash(arg0 context.Context, arg1 int64, arg2, arg3 []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLeafHash", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLeafHash indicates an expected call of SetLeafHash
+func (mr *MockMapTreeTXMockRecorder) SetLeafHash(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLeafHash", reflect.TypeOf((*MockMapTreeTX)(nil).SetLeafHash), arg0, arg1, arg2, arg3)
+}
+
 // SetMerkleNodes mocks base method
 func (m *MockMapTreeTX) SetMerkleNodes(arg0 context.Context, arg1 []tree.Node) error {
 	m.ctrl.T.Helper()
@@ -922,6 +1189,34 @@ func (mr *MockMapTreeTXMockRecorder) SetMerkleNodes(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMerkleNodes", reflect.TypeOf((*MockMapTreeTX)(nil).SetMerkleNodes), arg0, arg1)
 }
 
+// StoreIdempotencyToken mocks base method
+func (m *MockMapTreeTX) StoreIdempotencyToken(arg0 context.Context, arg1 []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreIdempotencyToken", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreIdempotencyToken indicates an expected call of StoreIdempotencyToken
+func (mr *MockMapTreeTXMockRecorder) StoreIdempotencyToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreIdempotencyToken", reflect.TypeOf((*MockMapTreeTX)(nil).StoreIdempotencyToken), arg0, arg1)
+}
+
+// StoreMapRootSignature mocks base method
+func (m *MockMapTreeTX) StoreMapRootSignature(arg0 context.Context, arg1 int64, arg2 *trillian.MapRootSignature) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreMapRootSignature", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreMapRootSignature indicates an expected call of StoreMapRootSignature
+func (mr *MockMapTreeTXMockRecorder) StoreMapRootSignature(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreMapRootSignature", reflect.TypeOf((*MockMapTreeTX)(nil).StoreMapRootSignature), arg0, arg1, arg2)
+}
+
 // StoreSignedMapRoot mocks base method
 func (m *MockMapTreeTX) StoreSignedMapRoot(arg0 context.Context, arg1 *trillian.SignedMapRoot) error {
 	m.ctrl.T.Helper()
@@ -1251,6 +1546,21 @@ func (mr *MockReadOnlyLogTreeTXMockRecorder) GetLeavesByRange(arg0, arg1, arg2 i
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeavesByRange", reflect.TypeOf((*MockReadOnlyLogTreeTX)(nil).GetLeavesByRange), arg0, arg1, arg2)
 }
 
+// GetLogRootSignatures mocks base method
+func (m *MockReadOnlyLogTreeTX) GetLogRootSignatures(arg0 context.Context, arg1 int64) ([]*trillian.LogRootSignature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLogRootSignatures", arg0, arg1)
+	ret0, _ := ret[0].([]*trillian.LogRootSignature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLogRootSignatures indicates an expected call of GetLogRootSignatures
+func (mr *MockReadOnlyLogTreeTXMockRecorder) GetLogRootSignatures(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLogRootSignatures", reflect.TypeOf((*MockReadOnlyLogTreeTX)(nil).GetLogRootSignatures), arg0, arg1)
+}
+
 // GetMerkleNodes mocks base method
 func (m *MockReadOnlyLogTreeTX) GetMerkleNodes(arg0 context.Context, arg1 int64, arg2 []tree.NodeID) ([]tree.Node, error) {
 	m.ctrl.T.Helper()
@@ -1266,6 +1576,21 @@ func (mr *MockReadOnlyLogTreeTXMockRecorder) GetMerkleNodes(arg0, arg1, arg2 int
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMerkleNodes", reflect.TypeOf((*MockReadOnlyLogTreeTX)(nil).GetMerkleNodes), arg0, arg1, arg2)
 }
 
+// GetRecoveryMarker mocks base method
+func (m *MockReadOnlyLogTreeTX) GetRecoveryMarker(arg0 context.Context, arg1 int64) (*RecoveryMarker, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRecoveryMarker", arg0, arg1)
+	ret0, _ := ret[0].(*RecoveryMarker)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRecoveryMarker indicates an expected call of GetRecoveryMarker
+func (mr *MockReadOnlyLogTreeTXMockRecorder) GetRecoveryMarker(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRecoveryMarker", reflect.TypeOf((*MockReadOnlyLogTreeTX)(nil).GetRecoveryMarker), arg0, arg1)
+}
+
 // GetSequencedLeafCount mocks base method
 func (m *MockReadOnlyLogTreeTX) GetSequencedLeafCount(arg0 context.Context) (int64, error) {
 	m.ctrl.T.Helper()
@@ -1281,6 +1606,36 @@ func (mr *MockReadOnlyLogTreeTXMockRecorder) GetSequencedLeafCount(arg0 interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSequencedLeafCount", reflect.TypeOf((*MockReadOnlyLogTreeTX)(nil).GetSequencedLeafCount), arg0)
 }
 
+// GetSignedLogRoot mocks base method
+func (m *MockReadOnlyLogTreeTX) GetSignedLogRoot(arg0 context.Context, arg1 int64) (*trillian.SignedLogRoot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSignedLogRoot", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.SignedLogRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSignedLogRoot indicates an expected call of GetSignedLogRoot
+func (mr *MockReadOnlyLogTreeTXMockRecorder) GetSignedLogRoot(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSignedLogRoot", reflect.TypeOf((*MockReadOnlyLogTreeTX)(nil).GetSignedLogRoot), arg0, arg1)
+}
+
+// GetSignedLogRootByHash mocks base method
+func (m *MockReadOnlyLogTreeTX) GetSignedLogRootByHash(arg0 context.Context, arg1 []byte) (*trillian.SignedLogRoot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSignedLogRootByHash", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.SignedLogRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSignedLogRootByHash indicates an expected call of GetSignedLogRootByHash
+func (mr *MockReadOnlyLogTreeTXMockRecorder) GetSignedLogRootByHash(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSignedLogRootByHash", reflect.TypeOf((*MockReadOnlyLogTreeTX)(nil).GetSignedLogRootByHash), arg0, arg1)
+}
+
 // IsOpen mocks base method
 func (m *MockReadOnlyLogTreeTX) IsOpen() bool {
 	m.ctrl.T.Helper()
@@ -1295,6 +1650,21 @@ func (mr *MockReadOnlyLogTreeTXMockRecorder) IsOpen() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsOpen", reflect.TypeOf((*MockReadOnlyLogTreeTX)(nil).IsOpen))
 }
 
+// LatestRecoveryMarker mocks base method
+func (m *MockReadOnlyLogTreeTX) LatestRecoveryMarker(arg0 context.Context) (*RecoveryMarker, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LatestRecoveryMarker", arg0)
+	ret0, _ := ret[0].(*RecoveryMarker)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LatestRecoveryMarker indicates an expected call of LatestRecoveryMarker
+func (mr *MockReadOnlyLogTreeTXMockRecorder) LatestRecoveryMarker(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestRecoveryMarker", reflect.TypeOf((*MockReadOnlyLogTreeTX)(nil).LatestRecoveryMarker), arg0)
+}
+
 // LatestSignedLogRoot mocks base method
 func (m *MockReadOnlyLogTreeTX) LatestSignedLogRoot(arg0 context.Context) (*trillian.SignedLogRoot, error) {
 	m.ctrl.T.Helper()
@@ -1390,6 +1760,21 @@ func (mr *MockReadOnlyMapTreeTXMockRecorder) Commit(arg0 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockReadOnlyMapTreeTX)(nil).Commit), arg0)
 }
 
+// EarliestRevisionSince mocks base method
+func (m *MockReadOnlyMapTreeTX) EarliestRevisionSince(arg0 context.Context, arg1 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EarliestRevisionSince", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EarliestRevisionSince indicates an expected call of EarliestRevisionSince
+func (mr *MockReadOnlyMapTreeTXMockRecorder) EarliestRevisionSince(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EarliestRevisionSince", reflect.TypeOf((*MockReadOnlyMapTreeTX)(nil).EarliestRevisionSince), arg0, arg1)
+}
+
 // Get mocks base method
 func (m *MockReadOnlyMapTreeTX) Get(arg0 context.Context, arg1 int64, arg2 [][]byte) ([]*trillian.MapLeaf, error) {
 	m.ctrl.T.Helper()
@@ -1405,6 +1790,52 @@ func (mr *MockReadOnlyMapTreeTXMockRecorder) Get(arg0, arg1, arg2 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockReadOnlyMapTreeTX)(nil).Get), arg0, arg1, arg2)
 }
 
+// GetIdempotencyToken mocks base method
+func (m *MockReadOnlyMapTreeTX) GetIdempotencyToken(arg0 context.Context, arg1 []byte) (int64, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyToken", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetIdempotencyToken indicates an expected call of GetIdempotencyToken
+func (mr *MockReadOnlyMapTreeTXMockRecorder) GetIdempotencyToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyToken", reflect.TypeOf((*MockReadOnlyMapTreeTX)(nil).GetIdempotencyToken), arg0, arg1)
+}
+
+// GetIndexesByLeafHash mocks base method
+func (m *MockReadOnlyMapTreeTX) GetIndexesByLeafHash(arg0 context.Context, arg1 int64, arg2 []byte) ([][]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIndexesByLeafHash", arg0, arg1, arg2)
+	ret0, _ := ret[0].([][]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIndexesByLeafHash indicates an expected call of GetIndexesByLeafHash
+func (mr *MockReadOnlyMapTreeTXMockRecorder) GetIndexesByLeafHash(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIndexesByLeafHash", reflect.TypeOf((*MockReadOnlyMapTreeTX)(nil).GetIndexesByLeafHash), arg0, arg1, arg2)
+}
+
+// GetMapRootSignatures mocks base method
+func (m *MockReadOnlyMapTreeTX) GetMapRootSignatures(arg0 context.Context, arg1 int64) ([]*trillian.MapRootSignature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMapRootSignatures", arg0, arg1)
+	ret0, _ := ret[0].([]*trillian.MapRootSignature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMapRootSignatures indicates an expected call of GetMapRootSignatures
+func (mr *MockReadOnlyMapTreeTXMockRecorder) GetMapRootSignatures(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMapRootSignatures", reflect.TypeOf((*MockReadOnlyMapTreeTX)(nil).GetMapRootSignatures), arg0, arg1)
+}
+
 // GetMerkleNodes mocks base method
 func (m *MockReadOnlyMapTreeTX) GetMerkleNodes(arg0 context.Context, arg1 int64, arg2 []tree.NodeID) ([]tree.Node, error) {
 	m.ctrl.T.Helper()
@@ -1420,6 +1851,21 @@ func (mr *MockReadOnlyMapTreeTXMockRecorder) GetMerkleNodes(arg0, arg1, arg2 int
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMerkleNodes", reflect.TypeOf((*MockReadOnlyMapTreeTX)(nil).GetMerkleNodes), arg0, arg1, arg2)
 }
 
+// GetRecoveryMarker mocks base method
+func (m *MockReadOnlyMapTreeTX) GetRecoveryMarker(arg0 context.Context, arg1 int64) (*RecoveryMarker, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRecoveryMarker", arg0, arg1)
+	ret0, _ := ret[0].(*RecoveryMarker)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRecoveryMarker indicates an expected call of GetRecoveryMarker
+func (mr *MockReadOnlyMapTreeTXMockRecorder) GetRecoveryMarker(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRecoveryMarker", reflect.TypeOf((*MockReadOnlyMapTreeTX)(nil).GetRecoveryMarker), arg0, arg1)
+}
+
 // GetSignedMapRoot mocks base method
 func (m *MockReadOnlyMapTreeTX) GetSignedMapRoot(arg0 context.Context, arg1 int64) (*trillian.SignedMapRoot, error) {
 	m.ctrl.T.Helper()
@@ -1449,6 +1895,21 @@ func (mr *MockReadOnlyMapTreeTXMockRecorder) IsOpen() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsOpen", reflect.TypeOf((*MockReadOnlyMapTreeTX)(nil).IsOpen))
 }
 
+// LatestRecoveryMarker mocks base method
+func (m *MockReadOnlyMapTreeTX) LatestRecoveryMarker(arg0 context.Context) (*RecoveryMarker, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LatestRecoveryMarker", arg0)
+	ret0, _ := ret[0].(*RecoveryMarker)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LatestRecoveryMarker indicates an expected call of LatestRecoveryMarker
+func (mr *MockReadOnlyMapTreeTXMockRecorder) LatestRecoveryMarker(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestRecoveryMarker", reflect.TypeOf((*MockReadOnlyMapTreeTX)(nil).LatestRecoveryMarker), arg0)
+}
+
 // LatestSignedMapRoot mocks base method
 func (m *MockReadOnlyMapTreeTX) LatestSignedMapRoot(arg0 context.Context) (*trillian.SignedMapRoot, error) {
 	m.ctrl.T.Helper()
@@ -1464,6 +1925,51 @@ func (mr *MockReadOnlyMapTreeTXMockRecorder) LatestSignedMapRoot(arg0 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestSignedMapRoot", reflect.TypeOf((*MockReadOnlyMapTreeTX)(nil).LatestSignedMapRoot), arg0)
 }
 
+// List mocks base method
+func (m *MockReadOnlyMapTreeTX) List(arg0 context.Context, arg1 int64, arg2 []byte, arg3 int) ([]*trillian.MapLeaf, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]*trillian.MapLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List
+func (mr *MockReadOnlyMapTreeTXMockRecorder) List(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockReadOnlyMapTreeTX)(nil).List), arg0, arg1, arg2, arg3)
+}
+
+// ListLeafVersions mocks base method
+func (m *MockReadOnlyMapTreeTX) ListLeafVersions(arg0 context.Context, arg1 []byte, arg2 int) ([]MapLeafVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLeafVersions", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]MapLeafVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListLeafVersions indicates an expected call of ListLeafVersions
+func (mr *MockReadOnlyMapTreeTXMockRecorder) ListLeafVersions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLeafVersions", reflect.TypeOf((*MockReadOnlyMapTreeTX)(nil).ListLeafVersions), arg0, arg1, arg2)
+}
+
+// ListSignedMapRoots mocks base method
+func (m *MockReadOnlyMapTreeTX) ListSignedMapRoots(arg0 context.Context, arg1 MapRootFilter, arg2 int) ([]*trillian.SignedMapRoot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSignedMapRoots", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]*trillian.SignedMapRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSignedMapRoots indicates an expected call of ListSignedMapRoots
+func (mr *MockReadOnlyMapTreeTXMockRecorder) ListSignedMapRoots(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSignedMapRoots", reflect.TypeOf((*MockReadOnlyMapTreeTX)(nil).ListSignedMapRoots), arg0, arg1, arg2)
+}
+
 // ReadRevision mocks base method
 func (m *MockReadOnlyMapTreeTX) ReadRevision(arg0 context.Context) (int64, error) {
 	m.ctrl.T.Helper()
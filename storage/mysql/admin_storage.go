@@ -24,8 +24,11 @@ import (
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -54,13 +57,28 @@ const (
 			PublicKey,
 			MaxRootDurationMillis,
 			Deleted,
-			DeleteTimeMillis
+			DeleteTimeMillis,
+			RetainRevisions,
+			RetainDurationMillis,
+			StorageSettings,
+			MapIndexBits,
+			DuplicateLeafPolicy,
+			AdditionalKeys,
+			SignatureThreshold,
+			Labels,
+			DeleteRetentionMillis,
+			KeyRotation,
+			ReadTokensPerSecond,
+			WriteTokensPerSecond,
+			Owner
 		FROM Trees`
 	selectNonDeletedTrees = selectTrees + nonDeletedWhere
 	selectTreeByID        = selectTrees + " WHERE TreeId = ?"
 
 	updateTreeSQL = `UPDATE Trees
-		SET TreeState = ?, TreeType = ?, DisplayName = ?, Description = ?, UpdateTimeMillis = ?, MaxRootDurationMillis = ?, PrivateKey = ?
+		SET TreeState = ?, TreeType = ?, DisplayName = ?, Description = ?, UpdateTimeMillis = ?, MaxRootDurationMillis = ?, PrivateKey = ?,
+			PublicKey = ?, RetainRevisions = ?, RetainDurationMillis = ?, DuplicateLeafPolicy = ?, Labels = ?,
+			DeleteRetentionMillis = ?, KeyRotation = ?, ReadTokensPerSecond = ?, WriteTokensPerSecond = ?, Owner = ?
 		WHERE TreeId = ?`
 )
 
@@ -158,7 +176,7 @@ func (t *adminTX) GetTree(ctx context.Context, treeID int64) (*trillian.Tree, er
 	defer stmt.Close()
 
 	// GetTree is an entry point for most RPCs, let's provide somewhat nicer error messages.
-	tree, err := storage.ReadTree(stmt.QueryRowContext(ctx, treeID))
+	tree, err := readTree(stmt.QueryRowContext(ctx, treeID))
 	switch {
 	case err == sql.ErrNoRows:
 		// ErrNoRows doesn't provide useful information, so we don't forward it.
@@ -220,7 +238,7 @@ func (t *adminTX) ListTrees(ctx context.Context, includeDeleted bool) ([]*trilli
 	defer rows.Close()
 	trees := []*trillian.Tree{}
 	for rows.Next() {
-		tree, err := storage.ReadTree(rows)
+		tree, err := readTree(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -237,10 +255,18 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 		return nil, err
 	}
 
-	id, err := storage.NewTreeID()
+	id, err := storage.TreeIDForCreation(tree)
 	if err != nil {
 		return nil, err
 	}
+	if tree.TreeId != 0 {
+		if _, err := t.GetTree(ctx, id); status.Code(err) != codes.NotFound {
+			if err == nil {
+				err = status.Errorf(codes.AlreadyExists, "tree %d already exists", id)
+			}
+			return nil, err
+		}
+	}
 
 	// Use the time truncated-to-millis throughout, as that's what's stored.
 	nowMillis := storage.ToMillisSinceEpoch(time.Now())
@@ -260,6 +286,28 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 	if err != nil {
 		return nil, fmt.Errorf("could not parse MaxRootDuration: %v", err)
 	}
+	retainRevisions, retainDuration, err := retentionColumns(newTree.RevisionRetentionPolicy)
+	if err != nil {
+		return nil, err
+	}
+	var storageSettings []byte
+	if newTree.StorageSettings != nil {
+		if storageSettings, err = proto.Marshal(newTree.StorageSettings); err != nil {
+			return nil, fmt.Errorf("could not marshal StorageSettings: %v", err)
+		}
+	}
+	additionalKeys, err := additionalKeysColumn(newTree)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := labelsColumn(newTree)
+	if err != nil {
+		return nil, err
+	}
+	deleteRetention, err := deleteRetentionColumn(newTree)
+	if err != nil {
+		return nil, err
+	}
 
 	insertTreeStmt, err := t.tx.PrepareContext(
 		ctx,
@@ -276,8 +324,20 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 			UpdateTimeMillis,
 			PrivateKey,
 			PublicKey,
-			MaxRootDurationMillis)
-		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+			MaxRootDurationMillis,
+			RetainRevisions,
+			RetainDurationMillis,
+			StorageSettings,
+			MapIndexBits,
+			DuplicateLeafPolicy,
+			AdditionalKeys,
+			SignatureThreshold,
+			Labels,
+			DeleteRetentionMillis,
+			ReadTokensPerSecond,
+			WriteTokensPerSecond,
+			Owner)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return nil, err
 	}
@@ -303,6 +363,18 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 		privateKey,
 		newTree.PublicKey.GetDer(),
 		rootDuration/time.Millisecond,
+		retainRevisions,
+		retainDuration/time.Millisecond,
+		storageSettings,
+		newTree.MapIndexBits,
+		newTree.DuplicateLeafPolicy,
+		additionalKeys,
+		newTree.SignatureThreshold,
+		labels,
+		deleteRetention/time.Millisecond,
+		newTree.Quota.GetReadTokensPerSecond(),
+		newTree.Quota.GetWriteTokensPerSecond(),
+		newTree.Owner,
 	)
 	if err != nil {
 		return nil, err
@@ -357,6 +429,9 @@ func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(
 	if err := validateStorageSettings(tree); err != nil {
 		return nil, err
 	}
+	if !proto.Equal(beforeUpdate.StorageSettings, tree.StorageSettings) {
+		return nil, status.Error(codes.InvalidArgument, "readonly field changed: storage_settings")
+	}
 
 	// TODO(pavelkalinnikov): When switching TreeType from PREORDERED_LOG to LOG,
 	// ensure all entries in SequencedLeafData are integrated.
@@ -372,11 +447,27 @@ func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(
 	if err != nil {
 		return nil, fmt.Errorf("could not parse MaxRootDuration: %v", err)
 	}
+	retainRevisions, retainDuration, err := retentionColumns(tree.RevisionRetentionPolicy)
+	if err != nil {
+		return nil, err
+	}
 
 	privateKey, err := proto.Marshal(tree.PrivateKey)
 	if err != nil {
 		return nil, fmt.Errorf("could not marshal PrivateKey: %v", err)
 	}
+	labels, err := labelsColumn(tree)
+	if err != nil {
+		return nil, err
+	}
+	deleteRetention, err := deleteRetentionColumn(tree)
+	if err != nil {
+		return nil, err
+	}
+	keyRotation, err := keyRotationColumn(tree)
+	if err != nil {
+		return nil, err
+	}
 
 	stmt, err := t.tx.PrepareContext(ctx, updateTreeSQL)
 	if err != nil {
@@ -393,6 +484,16 @@ func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(
 		nowMillis,
 		rootDuration/time.Millisecond,
 		privateKey,
+		tree.PublicKey.GetDer(),
+		retainRevisions,
+		retainDuration/time.Millisecond,
+		tree.DuplicateLeafPolicy,
+		labels,
+		deleteRetention/time.Millisecond,
+		keyRotation,
+		tree.Quota.GetReadTokensPerSecond(),
+		tree.Quota.GetWriteTokensPerSecond(),
+		tree.Owner,
 		tree.TreeId); err != nil {
 		return nil, err
 	}
@@ -454,9 +555,179 @@ func validateDeleted(ctx context.Context, tx *sql.Tx, treeID int64, wantDeleted
 	return nil
 }
 
+// validateStorageSettings checks that tree has no storage_settings, other than
+// the MapStorageSettings of maps.
 func validateStorageSettings(tree *trillian.Tree) error {
-	if tree.StorageSettings != nil {
+	if tree.StorageSettings == nil {
+		return nil
+	}
+	if tree.TreeType != trillian.TreeType_MAP {
 		return fmt.Errorf("storage_settings not supported, but got %v", tree.StorageSettings)
 	}
-	return nil
+	_, err := mapStorageSettings(tree)
+	return err
+}
+
+// mapStorageSettings returns the MapStorageSettings held in the
+// storage_settings of tree, which are empty if it has none.
+func mapStorageSettings(tree *trillian.Tree) (*storagepb.MapStorageSettings, error) {
+	settings := &storagepb.MapStorageSettings{}
+	if tree.StorageSettings == nil {
+		return settings, nil
+	}
+	if err := ptypes.UnmarshalAny(tree.StorageSettings, settings); err != nil {
+		return nil, fmt.Errorf("storage_settings not supported, but got %v: %v", tree.StorageSettings, err)
+	}
+	return settings, nil
+}
+
+// extraRow reads the revision retention, storage settings, map index bits,
+// duplicate leaf policy, additional key, label, delete retention, key rotation,
+// quota and owner columns, which are selected after the ones read by
+// storage.ReadTree.
+type extraRow struct {
+	storage.Row
+	retainRevisions, retainDurationMillis int64
+	storageSettings                       []byte
+	mapIndexBits                          int32
+	duplicateLeafPolicy                   int32
+	additionalKeys                        []byte
+	signatureThreshold                    int32
+	labels                                []byte
+	deleteRetentionMillis                 int64
+	keyRotation                           []byte
+	readTokensPerSecond                   int64
+	writeTokensPerSecond                  int64
+	owner                                 string
+}
+
+func (r *extraRow) Scan(dest ...interface{}) error {
+	return r.Row.Scan(append(dest, &r.retainRevisions, &r.retainDurationMillis, &r.storageSettings, &r.mapIndexBits, &r.duplicateLeafPolicy, &r.additionalKeys, &r.signatureThreshold, &r.labels, &r.deleteRetentionMillis, &r.keyRotation, &r.readTokensPerSecond, &r.writeTokensPerSecond, &r.owner)...)
+}
+
+// readTree takes a row selected by selectTrees and returns a tree.
+func readTree(row storage.Row) (*trillian.Tree, error) {
+	r := &extraRow{Row: row}
+	tree, err := storage.ReadTree(r)
+	if err != nil {
+		return nil, err
+	}
+	tree.MapIndexBits = r.mapIndexBits
+	tree.DuplicateLeafPolicy = trillian.DuplicateLeafPolicy(r.duplicateLeafPolicy)
+	tree.SignatureThreshold = r.signatureThreshold
+	if len(r.additionalKeys) > 0 {
+		var keys storagepb.AdditionalKeys
+		if err := proto.Unmarshal(r.additionalKeys, &keys); err != nil {
+			return nil, fmt.Errorf("could not unmarshal AdditionalKeys: %v", err)
+		}
+		tree.AdditionalPrivateKeys = keys.PrivateKeys
+		for _, der := range keys.PublicKeyDers {
+			tree.AdditionalPublicKeys = append(tree.AdditionalPublicKeys, &keyspb.PublicKey{Der: der})
+		}
+	}
+	if len(r.labels) > 0 {
+		var labels storagepb.TreeLabels
+		if err := proto.Unmarshal(r.labels, &labels); err != nil {
+			return nil, fmt.Errorf("could not unmarshal Labels: %v", err)
+		}
+		tree.Labels = labels.Labels
+	}
+	if r.deleteRetentionMillis != 0 {
+		tree.DeleteRetention = ptypes.DurationProto(time.Duration(r.deleteRetentionMillis) * time.Millisecond)
+	}
+	if len(r.keyRotation) > 0 {
+		tree.KeyRotation = &trillian.KeyRotation{}
+		if err := proto.Unmarshal(r.keyRotation, tree.KeyRotation); err != nil {
+			return nil, fmt.Errorf("could not unmarshal KeyRotation: %v", err)
+		}
+	}
+	if r.readTokensPerSecond != 0 || r.writeTokensPerSecond != 0 {
+		tree.Quota = &trillian.TreeQuota{ReadTokensPerSecond: r.readTokensPerSecond, WriteTokensPerSecond: r.writeTokensPerSecond}
+	}
+	tree.Owner = r.owner
+	if len(r.storageSettings) > 0 {
+		tree.StorageSettings = &any.Any{}
+		if err := proto.Unmarshal(r.storageSettings, tree.StorageSettings); err != nil {
+			return nil, fmt.Errorf("could not unmarshal StorageSettings: %v", err)
+		}
+	}
+	if r.retainRevisions != 0 || r.retainDurationMillis != 0 {
+		tree.RevisionRetentionPolicy = &trillian.RevisionRetentionPolicy{KeepRevisions: r.retainRevisions}
+		if r.retainDurationMillis != 0 {
+			tree.RevisionRetentionPolicy.KeepDuration = ptypes.DurationProto(time.Duration(r.retainDurationMillis) * time.Millisecond)
+		}
+	}
+	return tree, nil
+}
+
+// additionalKeysColumn returns the value stored in the AdditionalKeys column
+// for the tree, which is nil if it has no additional keys.
+func additionalKeysColumn(tree *trillian.Tree) ([]byte, error) {
+	if len(tree.AdditionalPrivateKeys) == 0 {
+		return nil, nil
+	}
+	keys := &storagepb.AdditionalKeys{PrivateKeys: tree.AdditionalPrivateKeys}
+	for _, key := range tree.AdditionalPublicKeys {
+		keys.PublicKeyDers = append(keys.PublicKeyDers, key.GetDer())
+	}
+	b, err := proto.Marshal(keys)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal AdditionalKeys: %v", err)
+	}
+	return b, nil
+}
+
+// labelsColumn returns the value stored in the Labels column for the tree,
+// which is nil if it has no labels.
+func labelsColumn(tree *trillian.Tree) ([]byte, error) {
+	if len(tree.Labels) == 0 {
+		return nil, nil
+	}
+	b, err := proto.Marshal(&storagepb.TreeLabels{Labels: tree.Labels})
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal Labels: %v", err)
+	}
+	return b, nil
+}
+
+// deleteRetentionColumn returns the delete retention of the tree, which is
+// zero if it uses the default.
+func deleteRetentionColumn(tree *trillian.Tree) (time.Duration, error) {
+	if tree.DeleteRetention == nil {
+		return 0, nil
+	}
+	d, err := ptypes.Duration(tree.DeleteRetention)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse DeleteRetention: %v", err)
+	}
+	return d, nil
+}
+
+// keyRotationColumn returns the value stored in the KeyRotation column for the
+// tree, which is nil if it isn't rotating its key.
+func keyRotationColumn(tree *trillian.Tree) ([]byte, error) {
+	if tree.KeyRotation == nil {
+		return nil, nil
+	}
+	b, err := proto.Marshal(tree.KeyRotation)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal KeyRotation: %v", err)
+	}
+	return b, nil
+}
+
+// retentionColumns returns the values stored in the revision retention columns
+// for the given policy, which is nil if all revisions are kept.
+func retentionColumns(policy *trillian.RevisionRetentionPolicy) (int64, time.Duration, error) {
+	if policy == nil {
+		return 0, 0, nil
+	}
+	var keepDuration time.Duration
+	if policy.KeepDuration != nil {
+		var err error
+		if keepDuration, err = ptypes.Duration(policy.KeepDuration); err != nil {
+			return 0, 0, fmt.Errorf("could not parse KeepDuration: %v", err)
+		}
+	}
+	return policy.KeepRevisions, keepDuration, nil
 }
@@ -18,13 +18,17 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
 	"github.com/google/trillian/storage/testonly"
 )
 
@@ -190,6 +194,286 @@ func TestAdminTX_StorageSettingsNotSupported(t *testing.T) {
 	}
 }
 
+func TestAdminTX_MapStorageSettings(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	settings, err := ptypes.MarshalAny(&storagepb.MapStorageSettings{LeafCompression: storagepb.LeafCompression_SNAPPY})
+	if err != nil {
+		t.Fatalf("Error marshaling proto: %v", err)
+	}
+	tree := proto.Clone(testonly.MapTree).(*trillian.Tree)
+	tree.StorageSettings = settings
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if !proto.Equal(got.StorageSettings, settings) {
+		t.Errorf("GetTree().StorageSettings = %v, want %v", got.StorageSettings, settings)
+	}
+
+	other, err := ptypes.MarshalAny(&storagepb.MapStorageSettings{})
+	if err != nil {
+		t.Fatalf("Error marshaling proto: %v", err)
+	}
+	if _, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) { tree.StorageSettings = other }); err == nil {
+		t.Error("UpdateTree() changed storage_settings, want err")
+	}
+	if _, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) { tree.DisplayName = "renamed" }); err != nil {
+		t.Errorf("UpdateTree() returned err = %v", err)
+	}
+}
+
+func TestAdminTX_MapIndexBits(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.MapTree).(*trillian.Tree)
+	tree.MapIndexBits = 160
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if got.MapIndexBits != tree.MapIndexBits {
+		t.Errorf("GetTree().MapIndexBits = %v, want %v", got.MapIndexBits, tree.MapIndexBits)
+	}
+}
+
+func TestAdminTX_DuplicateLeafPolicy(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.DuplicateLeafPolicy = trillian.DuplicateLeafPolicy_DEDUPLICATE
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if got.DuplicateLeafPolicy != tree.DuplicateLeafPolicy {
+		t.Errorf("GetTree().DuplicateLeafPolicy = %v, want %v", got.DuplicateLeafPolicy, tree.DuplicateLeafPolicy)
+	}
+}
+
+func TestAdminTX_AdditionalKeys(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.AdditionalPrivateKeys = []*any.Any{tree.PrivateKey}
+	tree.AdditionalPublicKeys = []*keyspb.PublicKey{tree.PublicKey}
+	tree.SignatureThreshold = 1
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if len(got.AdditionalPrivateKeys) != 1 || !proto.Equal(got.AdditionalPrivateKeys[0], tree.PrivateKey) {
+		t.Errorf("GetTree().AdditionalPrivateKeys = %v, want %v", got.AdditionalPrivateKeys, tree.AdditionalPrivateKeys)
+	}
+	if len(got.AdditionalPublicKeys) != 1 || !proto.Equal(got.AdditionalPublicKeys[0], tree.PublicKey) {
+		t.Errorf("GetTree().AdditionalPublicKeys = %v, want %v", got.AdditionalPublicKeys, tree.AdditionalPublicKeys)
+	}
+	if got.SignatureThreshold != tree.SignatureThreshold {
+		t.Errorf("GetTree().SignatureThreshold = %v, want %v", got.SignatureThreshold, tree.SignatureThreshold)
+	}
+}
+
+func TestAdminTX_Labels(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.Labels = map[string]string{"env": "prod"}
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if !reflect.DeepEqual(got.Labels, tree.Labels) {
+		t.Errorf("GetTree().Labels = %v, want %v", got.Labels, tree.Labels)
+	}
+
+	wantLabels := map[string]string{"owner": "ct"}
+	updated, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) {
+		tree.Labels = wantLabels
+	})
+	if err != nil {
+		t.Fatalf("UpdateTree() returned err = %v", err)
+	}
+	if !reflect.DeepEqual(updated.Labels, wantLabels) {
+		t.Errorf("UpdateTree().Labels = %v, want %v", updated.Labels, wantLabels)
+	}
+	if got, err = storage.GetTree(ctx, s, created.TreeId); err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if !reflect.DeepEqual(got.Labels, wantLabels) {
+		t.Errorf("GetTree().Labels = %v, want %v", got.Labels, wantLabels)
+	}
+}
+
+func TestAdminTX_DeleteRetention(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.DeleteRetention = ptypes.DurationProto(48 * time.Hour)
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	if !proto.Equal(created.DeleteRetention, tree.DeleteRetention) {
+		t.Errorf("CreateTree().DeleteRetention = %v, want %v", created.DeleteRetention, tree.DeleteRetention)
+	}
+
+	updated, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) {
+		tree.DeleteRetention = nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateTree() returned err = %v", err)
+	}
+	if updated.DeleteRetention != nil {
+		t.Errorf("UpdateTree().DeleteRetention = %v, want nil", updated.DeleteRetention)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if got.DeleteRetention != nil {
+		t.Errorf("GetTree().DeleteRetention = %v, want nil", got.DeleteRetention)
+	}
+}
+
+func TestAdminTX_Quota(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.Quota = &trillian.TreeQuota{ReadTokensPerSecond: 100}
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	if !proto.Equal(created.Quota, tree.Quota) {
+		t.Errorf("CreateTree().Quota = %v, want %v", created.Quota, tree.Quota)
+	}
+
+	want := &trillian.TreeQuota{WriteTokensPerSecond: 10}
+	if _, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) {
+		tree.Quota = want
+	}); err != nil {
+		t.Fatalf("UpdateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if !proto.Equal(got.Quota, want) {
+		t.Errorf("GetTree().Quota = %v, want %v", got.Quota, want)
+	}
+}
+
+func TestAdminTX_Owner(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.Owner = "tenant-1"
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	if created.Owner != tree.Owner {
+		t.Errorf("CreateTree().Owner = %q, want %q", created.Owner, tree.Owner)
+	}
+
+	const want = "tenant-2"
+	if _, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) {
+		tree.Owner = want
+	}); err != nil {
+		t.Fatalf("UpdateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if got.Owner != want {
+		t.Errorf("GetTree().Owner = %q, want %q", got.Owner, want)
+	}
+}
+
+func TestAdminTX_KeyRotation(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	created, err := storage.CreateTree(ctx, s, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	rotation := &trillian.KeyRotation{
+		PrivateKey: testonly.MapTree.PrivateKey,
+		PublicKey:  testonly.MapTree.PublicKey,
+		RetireTime: ptypes.TimestampNow(),
+	}
+	if _, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) {
+		tree.KeyRotation = rotation
+	}); err != nil {
+		t.Fatalf("UpdateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if !proto.Equal(got.KeyRotation, rotation) {
+		t.Errorf("GetTree().KeyRotation = %v, want %v", got.KeyRotation, rotation)
+	}
+
+	// Retire the old key.
+	if _, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) {
+		tree.PrivateKey = tree.KeyRotation.PrivateKey
+		tree.PublicKey = tree.KeyRotation.PublicKey
+		tree.KeyRotation = nil
+	}); err != nil {
+		t.Fatalf("UpdateTree() returned err = %v", err)
+	}
+	got, err = storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if got.KeyRotation != nil {
+		t.Errorf("GetTree().KeyRotation = %v, want nil", got.KeyRotation)
+	}
+	if !proto.Equal(got.PublicKey, rotation.PublicKey) {
+		t.Errorf("GetTree().PublicKey = %v, want %v", got.PublicKey, rotation.PublicKey)
+	}
+}
+
 func TestAdminTX_HardDeleteTree(t *testing.T) {
 	cleanTestDB(DB)
 	s := NewAdminStorage(DB)
@@ -4,10 +4,18 @@ DROP TABLE IF EXISTS Unsequenced;
 DROP TABLE IF EXISTS Subtree;
 DROP TABLE IF EXISTS SequencedLeafData;
 DROP TABLE IF EXISTS TreeHead;
+DROP TABLE IF EXISTS RecoveryMarker;
+DROP TABLE IF EXISTS LogRootSignature;
 DROP TABLE IF EXISTS LeafData;
+DROP TABLE IF EXISTS MapIdempotencyToken;
+DROP TABLE IF EXISTS MapRootSignature;
+DROP TABLE IF EXISTS MapWriteQueue;
+DROP TABLE IF EXISTS MapRevisionLease;
+DROP TABLE IF EXISTS MapLeafHash;
 DROP TABLE IF EXISTS MapLeaf;
 DROP TABLE IF EXISTS MapHead;
 DROP TABLE IF EXISTS TreeControl;
 DROP TABLE IF EXISTS MapHead;
 DROP TABLE IF EXISTS MapLeaf;
 DROP TABLE IF EXISTS Trees;
+DROP TABLE IF EXISTS SchemaVersion;
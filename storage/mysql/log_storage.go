@@ -17,6 +17,7 @@ package mysql
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -29,6 +30,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/merkle/hashers"
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
@@ -51,9 +53,18 @@ const (
 		  AND (Deleted IS NULL OR Deleted = 'false')`
 
 	selectSequencedLeafCountSQL  = "SELECT COUNT(*) FROM SequencedLeafData WHERE TreeId=?"
-	selectLatestSignedLogRootSQL = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature
+	selectLatestSignedLogRootSQL = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,AdditionalSignatures
 			FROM TreeHead WHERE TreeId=?
 			ORDER BY TreeHeadTimestamp DESC LIMIT 1`
+	selectSignedLogRootSQL = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,AdditionalSignatures
+			FROM TreeHead WHERE TreeId=? AND TreeRevision=?`
+	selectSignedLogRootByHashSQL = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,AdditionalSignatures
+			FROM TreeHead WHERE TreeId=? AND RootHash=?
+			ORDER BY TreeRevision LIMIT 1`
+	insertLogRootSignatureSQL = `INSERT INTO LogRootSignature(TreeId, TreeRevision, KeyHash, PublicKey, Signature)
+		 VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE Signature=VALUES(Signature)`
+	selectLogRootSignaturesSQL = `SELECT PublicKey, Signature FROM LogRootSignature
+		 WHERE TreeId=? AND TreeRevision=? ORDER BY KeyHash`
 
 	selectLeavesByRangeSQL = `SELECT s.MerkleLeafHash,l.LeafIdentityHash,l.LeafValue,s.SequenceNumber,l.ExtraData,l.QueueTimestampNanos,s.IntegrateTimestampNanos
 			FROM LeafData l,SequencedLeafData s
@@ -134,41 +145,37 @@ type mySQLLogStorage struct {
 	*mySQLTreeStorage
 	admin         storage.AdminStorage
 	metricFactory monitoring.MetricFactory
+	// replica is the storage on opts.Replica, if set.
+	replica *mySQLLogStorage
 }
 
 // NewLogStorage creates a storage.LogStorage instance for the specified MySQL URL.
 // It assumes storage.AdminStorage is backed by the same MySQL database as well.
 func NewLogStorage(db *sql.DB, mf monitoring.MetricFactory) storage.LogStorage {
+	return NewLogStorageWithOpts(db, mf, TreeStorageOptions{})
+}
+
+// NewLogStorageWithOpts creates a storage.LogStorage instance for the specified
+// MySQL URL, using options.
+func NewLogStorageWithOpts(db *sql.DB, mf monitoring.MetricFactory, opts TreeStorageOptions) storage.LogStorage {
 	if mf == nil {
 		mf = monitoring.InertMetricFactory{}
 	}
-	return &mySQLLogStorage{
+	ls := &mySQLLogStorage{
 		admin:            NewAdminStorage(db),
-		mySQLTreeStorage: newTreeStorage(db),
+		mySQLTreeStorage: newTreeStorage(db, opts),
 		metricFactory:    mf,
 	}
+	if opts.Replica != nil {
+		ls.replica = NewLogStorageWithOpts(opts.Replica, mf, replicaOpts(opts)).(*mySQLLogStorage)
+	}
+	return ls
 }
 
 func (m *mySQLLogStorage) CheckDatabaseAccessible(ctx context.Context) error {
 	return m.db.PingContext(ctx)
 }
 
-func (m *mySQLLogStorage) getLeavesByIndexStmt(ctx context.Context, num int) (*sql.Stmt, error) {
-	return m.getStmt(ctx, selectLeavesByIndexSQL, num, "?", "?")
-}
-
-func (m *mySQLLogStorage) getLeavesByMerkleHashStmt(ctx context.Context, num int, orderBySequence bool) (*sql.Stmt, error) {
-	if orderBySequence {
-		return m.getStmt(ctx, selectLeavesByMerkleHashOrderedBySequenceSQL, num, "?", "?")
-	}
-
-	return m.getStmt(ctx, selectLeavesByMerkleHashSQL, num, "?", "?")
-}
-
-func (m *mySQLLogStorage) getLeavesByLeafIdentityHashStmt(ctx context.Context, num int) (*sql.Stmt, error) {
-	return m.getStmt(ctx, selectLeavesByLeafIdentityHashSQL, num, "?", "?")
-}
-
 // readOnlyLogTX implements storage.ReadOnlyLogTX
 type readOnlyLogTX struct {
 	ls *mySQLLogStorage
@@ -179,6 +186,9 @@ type readOnlyLogTX struct {
 }
 
 func (m *mySQLLogStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
+	if m.replica != nil {
+		return m.replica.Snapshot(ctx)
+	}
 	tx, err := m.db.BeginTx(ctx, nil /* opts */)
 	if err != nil {
 		glog.Warningf("Could not start ReadOnlyLogTX: %s", err)
@@ -216,7 +226,7 @@ func (t *readOnlyLogTX) GetActiveLogIDs(ctx context.Context) ([]int64, error) {
 	// Include logs that are DRAINING in the active list as we're still
 	// integrating leaves into them.
 	rows, err := t.tx.QueryContext(
-		ctx, selectNonDeletedTreeIDByTypeAndStateSQL,
+		ctx, t.ls.tag(ctx, 0, selectNonDeletedTreeIDByTypeAndStateSQL),
 		trillian.TreeType_LOG.String(), trillian.TreeType_PREORDERED_LOG.String(),
 		trillian.TreeState_ACTIVE.String(), trillian.TreeState_DRAINING.String())
 	if err != nil {
@@ -304,6 +314,18 @@ func (m *mySQLLogStorage) AddSequencedLeaves(ctx context.Context, tree *trillian
 }
 
 func (m *mySQLLogStorage) SnapshotForTree(ctx context.Context, tree *trillian.Tree) (storage.ReadOnlyLogTreeTX, error) {
+	if m.replica != nil {
+		tx, err := m.replica.beginInternal(ctx, tree)
+		if err == nil && m.freshOnReplica(tx.(*logTreeTX).root.TimestampNanos) {
+			return tx, nil
+		}
+		if tx != nil {
+			tx.Close()
+		}
+		if err != nil && err != storage.ErrTreeNeedsInit {
+			return nil, err
+		}
+	}
 	tx, err := m.beginInternal(ctx, tree)
 	if err != nil && err != storage.ErrTreeNeedsInit {
 		return nil, err
@@ -380,7 +402,7 @@ func (t *logTreeTX) DequeueLeaves(ctx context.Context, limit int, cutoffTime tim
 	}
 
 	start := time.Now()
-	stx, err := t.tx.PrepareContext(ctx, selectQueuedLeavesSQL)
+	stx, err := t.tx.PrepareContext(ctx, t.tag(ctx, selectQueuedLeavesSQL))
 	if err != nil {
 		glog.Warningf("Failed to prepare dequeue select: %s", err)
 		return nil, err
@@ -480,7 +502,7 @@ func (t *logTreeTX) QueueLeaves(ctx context.Context, leaves []*trillian.LogLeaf,
 		if err != nil {
 			return nil, fmt.Errorf("got invalid queue timestamp: %v", err)
 		}
-		_, err = t.tx.ExecContext(ctx, insertLeafDataSQL, t.treeID, leaf.LeafIdentityHash, leaf.LeafValue, leaf.ExtraData, qTimestamp.UnixNano())
+		_, err = t.tx.ExecContext(ctx, t.tag(ctx, insertLeafDataSQL), t.treeID, leaf.LeafIdentityHash, leaf.LeafValue, leaf.ExtraData, qTimestamp.UnixNano())
 		insertDuration := time.Since(leafStart)
 		observe(queueInsertLeafLatency, insertDuration, label)
 		if isDuplicateErr(err) {
@@ -508,7 +530,7 @@ func (t *logTreeTX) QueueLeaves(ctx context.Context, leaves []*trillian.LogLeaf,
 		args = append(args, queueArgs(t.treeID, leaf.LeafIdentityHash, queueTimestamp)...)
 		_, err = t.tx.ExecContext(
 			ctx,
-			insertUnsequencedEntrySQL,
+			t.tag(ctx, insertUnsequencedEntrySQL),
 			args...,
 		)
 		if err != nil {
@@ -605,7 +627,7 @@ func (t *logTreeTX) AddSequencedLeaves(ctx context.Context, leaves []*trillian.L
 		res[i] = &trillian.QueuedLogLeaf{Status: ok}
 
 		// TODO(pavelkalinnikov): Measure latencies.
-		_, err := t.tx.ExecContext(ctx, insertLeafDataSQL,
+		_, err := t.tx.ExecContext(ctx, t.tag(ctx, insertLeafDataSQL),
 			t.treeID, leaf.LeafIdentityHash, leaf.LeafValue, leaf.ExtraData, timestamp.UnixNano())
 		// TODO(pavelkalinnikov): Detach PREORDERED_LOG integration latency metric.
 
@@ -619,7 +641,7 @@ func (t *logTreeTX) AddSequencedLeaves(ctx context.Context, leaves []*trillian.L
 			return nil, err
 		}
 
-		_, err = t.tx.ExecContext(ctx, insertSequencedLeafSQL+valuesPlaceholder5,
+		_, err = t.tx.ExecContext(ctx, t.tag(ctx, insertSequencedLeafSQL+valuesPlaceholder5),
 			t.treeID, leaf.LeafIdentityHash, leaf.MerkleLeafHash, leaf.LeafIndex, 0)
 		// TODO(pavelkalinnikov): Update IntegrateTimestamp on integrating the leaf.
 
@@ -651,7 +673,7 @@ func (t *logTreeTX) GetSequencedLeafCount(ctx context.Context) (int64, error) {
 
 	var sequencedLeafCount int64
 
-	err := t.tx.QueryRowContext(ctx, selectSequencedLeafCountSQL, t.treeID).Scan(&sequencedLeafCount)
+	err := t.tx.QueryRowContext(ctx, t.tag(ctx, selectSequencedLeafCountSQL), t.treeID).Scan(&sequencedLeafCount)
 	if err != nil {
 		glog.Warningf("Error getting sequenced leaf count: %s", err)
 	}
@@ -671,11 +693,10 @@ func (t *logTreeTX) GetLeavesByIndex(ctx context.Context, leaves []int64) ([]*tr
 			}
 		}
 	}
-	tmpl, err := t.ls.getLeavesByIndexStmt(ctx, len(leaves))
+	stx, err := t.stmt(ctx, selectLeavesByIndexSQL, len(leaves), "?", "?")
 	if err != nil {
 		return nil, err
 	}
-	stx := t.tx.StmtContext(ctx, tmpl)
 	defer stx.Close()
 
 	var args []interface{}
@@ -752,7 +773,7 @@ func (t *logTreeTX) getLeavesByRangeInternal(ctx context.Context, start, count i
 	// TODO(pavelkalinnikov): Further clip `count` to a safe upper bound like 64k.
 
 	args := []interface{}{start, start + count, t.treeID}
-	rows, err := t.tx.QueryContext(ctx, selectLeavesByRangeSQL, args...)
+	rows, err := t.tx.QueryContext(ctx, t.tag(ctx, selectLeavesByRangeSQL), args...)
 	if err != nil {
 		glog.Warningf("Failed to get leaves by range: %s", err)
 		return nil, err
@@ -799,23 +820,18 @@ func (t *logTreeTX) GetLeavesByHash(ctx context.Context, leafHashes [][]byte, or
 	t.treeTX.mu.Lock()
 	defer t.treeTX.mu.Unlock()
 
-	tmpl, err := t.ls.getLeavesByMerkleHashStmt(ctx, len(leafHashes), orderBySequence)
-	if err != nil {
-		return nil, err
+	query := selectLeavesByMerkleHashSQL
+	if orderBySequence {
+		query = selectLeavesByMerkleHashOrderedBySequenceSQL
 	}
-
-	return t.getLeavesByHashInternal(ctx, leafHashes, tmpl, "merkle")
+	return t.getLeavesByHashInternal(ctx, leafHashes, query, "merkle")
 }
 
 // getLeafDataByIdentityHash retrieves leaf data by LeafIdentityHash, returned
 // as a slice of LogLeaf objects for convenience.  However, note that the
 // returned LogLeaf objects will not have a valid MerkleLeafHash, LeafIndex, or IntegrateTimestamp.
 func (t *logTreeTX) getLeafDataByIdentityHash(ctx context.Context, leafHashes [][]byte) ([]*trillian.LogLeaf, error) {
-	tmpl, err := t.ls.getLeavesByLeafIdentityHashStmt(ctx, len(leafHashes))
-	if err != nil {
-		return nil, err
-	}
-	return t.getLeavesByHashInternal(ctx, leafHashes, tmpl, "leaf-identity")
+	return t.getLeavesByHashInternal(ctx, leafHashes, selectLeavesByLeafIdentityHashSQL, "leaf-identity")
 }
 
 func (t *logTreeTX) LatestSignedLogRoot(ctx context.Context) (*trillian.SignedLogRoot, error) {
@@ -832,16 +848,22 @@ func (t *logTreeTX) LatestSignedLogRoot(ctx context.Context) (*trillian.SignedLo
 // fetchLatestRoot reads the latest SignedLogRoot from the DB and returns it.
 func (t *logTreeTX) fetchLatestRoot(ctx context.Context) (*trillian.SignedLogRoot, error) {
 	var timestamp, treeSize, treeRevision int64
-	var rootHash, rootSignatureBytes []byte
+	var rootHash, rootSignatureBytes, additionalSignatures []byte
 	if err := t.tx.QueryRowContext(
-		ctx, selectLatestSignedLogRootSQL, t.treeID).Scan(
-		&timestamp, &treeSize, &rootHash, &treeRevision, &rootSignatureBytes,
+		ctx, t.tag(ctx, selectLatestSignedLogRootSQL), t.treeID).Scan(
+		&timestamp, &treeSize, &rootHash, &treeRevision, &rootSignatureBytes, &additionalSignatures,
 	); err == sql.ErrNoRows {
 		// It's possible there are no roots for this tree yet
 		return nil, storage.ErrTreeNeedsInit
 	}
 
-	// Put logRoot back together. Fortunately LogRoot has a deterministic serialization.
+	return t.signedLogRoot(timestamp, treeSize, rootHash, treeRevision, rootSignatureBytes, additionalSignatures)
+}
+
+// signedLogRoot puts a SignedLogRoot back together from the columns of its
+// TreeHead row.
+func (t *logTreeTX) signedLogRoot(timestamp, treeSize int64, rootHash []byte, treeRevision int64, rootSignatureBytes, additionalSignatures []byte) (*trillian.SignedLogRoot, error) {
+	// Fortunately LogRoot has a deterministic serialization.
 	logRoot, err := (&types.LogRootV1{
 		RootHash:       rootHash,
 		TimestampNanos: uint64(timestamp),
@@ -851,14 +873,88 @@ func (t *logTreeTX) fetchLatestRoot(ctx context.Context) (*trillian.SignedLogRoo
 	if err != nil {
 		return nil, err
 	}
+	sigs, err := unmarshalRootSignatures(additionalSignatures)
+	if err != nil {
+		return nil, err
+	}
 
 	return &trillian.SignedLogRoot{
-		KeyHint:          types.SerializeKeyHint(t.treeID),
-		LogRoot:          logRoot,
-		LogRootSignature: rootSignatureBytes,
+		KeyHint:              types.SerializeKeyHint(t.treeID),
+		LogRoot:              logRoot,
+		LogRootSignature:     rootSignatureBytes,
+		AdditionalSignatures: sigs,
 	}, nil
 }
 
+func (t *logTreeTX) GetSignedLogRoot(ctx context.Context, revision int64) (*trillian.SignedLogRoot, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
+	var timestamp, treeSize, treeRevision int64
+	var rootHash, rootSignatureBytes, additionalSignatures []byte
+	err := t.tx.QueryRowContext(ctx, t.tag(ctx, selectSignedLogRootSQL), t.treeID, revision).Scan(
+		&timestamp, &treeSize, &rootHash, &treeRevision, &rootSignatureBytes, &additionalSignatures)
+	if err == sql.ErrNoRows {
+		return nil, status.Errorf(codes.NotFound, "log root %d not found", revision)
+	} else if err != nil {
+		glog.Warningf("Failed to read log root: %s", err)
+		return nil, err
+	}
+	return t.signedLogRoot(timestamp, treeSize, rootHash, treeRevision, rootSignatureBytes, additionalSignatures)
+}
+
+func (t *logTreeTX) GetSignedLogRootByHash(ctx context.Context, rootHash []byte) (*trillian.SignedLogRoot, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
+	var timestamp, treeSize, treeRevision int64
+	var rootHashBytes, rootSignatureBytes, additionalSignatures []byte
+	err := t.tx.QueryRowContext(ctx, t.tag(ctx, selectSignedLogRootByHashSQL), t.treeID, rootHash).Scan(
+		&timestamp, &treeSize, &rootHashBytes, &treeRevision, &rootSignatureBytes, &additionalSignatures)
+	if err == sql.ErrNoRows {
+		return nil, status.Errorf(codes.NotFound, "log root with hash %x not found", rootHash)
+	} else if err != nil {
+		glog.Warningf("Failed to read log root: %s", err)
+		return nil, err
+	}
+	return t.signedLogRoot(timestamp, treeSize, rootHashBytes, treeRevision, rootSignatureBytes, additionalSignatures)
+}
+
+func (t *logTreeTX) GetLogRootSignatures(ctx context.Context, revision int64) ([]*trillian.LogRootSignature, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
+	rows, err := t.tx.QueryContext(ctx, t.tag(ctx, selectLogRootSignaturesSQL), t.treeID, revision)
+	if err != nil {
+		glog.Warningf("Failed to read log root signatures: %s", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sigs []*trillian.LogRootSignature
+	for rows.Next() {
+		var der, sig []byte
+		if err := rows.Scan(&der, &sig); err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, &trillian.LogRootSignature{PublicKey: &keyspb.PublicKey{Der: der}, Signature: sig})
+	}
+	return sigs, rows.Err()
+}
+
+func (t *logTreeTX) StoreLogRootSignature(ctx context.Context, revision int64, sig *trillian.LogRootSignature) error {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
+	der := sig.GetPublicKey().GetDer()
+	keyHash := sha256.Sum256(der)
+	if _, err := t.tx.ExecContext(ctx, t.tag(ctx, insertLogRootSignatureSQL), t.treeID, revision, keyHash[:], der, sig.GetSignature()); err != nil {
+		glog.Warningf("Failed to store log root signature: %s", err)
+		return err
+	}
+	return nil
+}
+
 func (t *logTreeTX) StoreSignedLogRoot(ctx context.Context, root *trillian.SignedLogRoot) error {
 	t.treeTX.mu.Lock()
 	defer t.treeTX.mu.Unlock()
@@ -871,25 +967,36 @@ func (t *logTreeTX) StoreSignedLogRoot(ctx context.Context, root *trillian.Signe
 	if len(logRoot.Metadata) != 0 {
 		return fmt.Errorf("unimplemented: mysql storage does not support log root metadata")
 	}
+	additionalSignatures, err := marshalRootSignatures(root.AdditionalSignatures)
+	if err != nil {
+		return err
+	}
 
 	res, err := t.tx.ExecContext(
 		ctx,
-		insertTreeHeadSQL,
+		t.tag(ctx, insertTreeHeadSQL),
 		t.treeID,
 		logRoot.TimestampNanos,
 		logRoot.TreeSize,
 		logRoot.RootHash,
 		logRoot.Revision,
-		root.LogRootSignature)
+		root.LogRootSignature,
+		additionalSignatures)
 	if err != nil {
 		glog.Warningf("Failed to store signed root: %s", err)
 	}
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		return err
+	}
 
-	return checkResultOkAndRowCountIs(res, err, 1)
+	return t.storeRecoveryMarker(ctx, int64(logRoot.Revision))
 }
 
-func (t *logTreeTX) getLeavesByHashInternal(ctx context.Context, leafHashes [][]byte, tmpl *sql.Stmt, desc string) ([]*trillian.LogLeaf, error) {
-	stx := t.tx.StmtContext(ctx, tmpl)
+func (t *logTreeTX) getLeavesByHashInternal(ctx context.Context, leafHashes [][]byte, query, desc string) ([]*trillian.LogLeaf, error) {
+	stx, err := t.stmt(ctx, query, len(leafHashes), "?", "?")
+	if err != nil {
+		return nil, err
+	}
 	defer stx.Close()
 
 	var args []interface{}
@@ -963,9 +1070,14 @@ func (l byLeafIdentityHashWithPosition) Less(i, j int) bool {
 }
 
 func isDuplicateErr(err error) bool {
+	return isMySQLErr(err, errNumDuplicate)
+}
+
+// isMySQLErr returns whether err is a MySQL error with the given number.
+func isMySQLErr(err error, number uint16) bool {
 	switch err := err.(type) {
 	case *mysql.MySQLError:
-		return err.Number == errNumDuplicate
+		return err.Number == number
 	default:
 		return false
 	}
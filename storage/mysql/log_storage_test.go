@@ -23,12 +23,15 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/testonly"
 	"github.com/google/trillian/types"
@@ -42,7 +45,7 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 )
 
-var allTables = []string{"Unsequenced", "TreeHead", "SequencedLeafData", "LeafData", "Subtree", "TreeControl", "Trees", "MapLeaf", "MapHead"}
+var allTables = []string{"Unsequenced", "TreeHead", "SequencedLeafData", "LeafData", "Subtree", "TreeControl", "Trees", "MapLeaf", "MapLeafHash", "MapHead", "MapIdempotencyToken", "MapWriteQueue", "MapRevisionLease", "RecoveryMarker"}
 
 // Must be 32 bytes to match sha256 length if it was a real hash
 var dummyHash = []byte("hashxxxxhashxxxxhashxxxxhashxxxx")
@@ -1111,6 +1114,90 @@ func TestLatestSignedLogRoot(t *testing.T) {
 	}
 }
 
+func TestLogRecoveryMarker(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree := mustCreateTree(ctx, t, as, testonly.LogTree)
+	s := NewLogStorage(DB, nil)
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		if _, err := tx.LatestRecoveryMarker(ctx); status.Code(err) != codes.NotFound {
+			t.Errorf("LatestRecoveryMarker() before any root: %v, want code %v", err, codes.NotFound)
+		}
+		return nil
+	})
+
+	signer := tcrypto.NewSigner(tree.TreeId, ttestonly.NewSignerWithFixedSig(nil, []byte("notempty")), crypto.SHA256)
+	root, err := signer.SignLogRoot(&types.LogRootV1{
+		TimestampNanos: 98765,
+		TreeSize:       16,
+		Revision:       5,
+		RootHash:       []byte(dummyHash),
+	})
+	if err != nil {
+		t.Fatalf("SignLogRoot(): %v", err)
+	}
+	// The position of the marker is read after the root's transaction
+	// commits, so it's past the position while the root is written.
+	var before string
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		if err := tx.StoreSignedLogRoot(ctx, root); err != nil {
+			return err
+		}
+		pos, err := newTreeStorage(DB, TreeStorageOptions{}).binlogPosition(ctx)
+		if err != nil {
+			t.Logf("binlogPosition(): %v", err)
+		}
+		before = pos
+		return nil
+	})
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		latest, err := tx.LatestRecoveryMarker(ctx)
+		if err != nil {
+			t.Fatalf("LatestRecoveryMarker(): %v", err)
+		}
+		if before != "" && !binlogPositionAfter(t, latest.Position, before) {
+			t.Errorf("LatestRecoveryMarker().Position=%q, want after %q", latest.Position, before)
+		}
+		want := &storage.RecoveryMarker{TreeID: tree.TreeId, Revision: 5, Position: latest.Position}
+		if !reflect.DeepEqual(latest, want) {
+			t.Errorf("LatestRecoveryMarker()=%+v, want %+v", latest, want)
+		}
+		got, err := tx.GetRecoveryMarker(ctx, 5)
+		if err != nil {
+			t.Fatalf("GetRecoveryMarker(5): %v", err)
+		}
+		if !reflect.DeepEqual(got, latest) {
+			t.Errorf("GetRecoveryMarker(5)=%+v, want %+v", got, latest)
+		}
+		return nil
+	})
+}
+
+// binlogPositionAfter returns whether the binary log position a is after b.
+func binlogPositionAfter(t *testing.T, a, b string) bool {
+	t.Helper()
+	parse := func(p string) (string, int64) {
+		i := strings.LastIndex(p, ":")
+		if i < 0 {
+			t.Fatalf("invalid binary log position %q", p)
+		}
+		pos, err := strconv.ParseInt(p[i+1:], 10, 64)
+		if err != nil {
+			t.Fatalf("invalid binary log position %q: %v", p, err)
+		}
+		return p[:i], pos
+	}
+	aFile, aPos := parse(a)
+	bFile, bPos := parse(b)
+	if aFile != bFile {
+		return aFile > bFile
+	}
+	return aPos > bPos
+}
+
 func TestDuplicateSignedLogRoot(t *testing.T) {
 	ctx := context.Background()
 	cleanTestDB(DB)
@@ -1191,6 +1278,129 @@ func TestLogRootUpdate(t *testing.T) {
 	})
 }
 
+func TestLogRootSignatures(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree := mustCreateTree(ctx, t, as, testonly.LogTree)
+	s := NewLogStorage(DB, nil)
+
+	signer := tcrypto.NewSigner(tree.TreeId, ttestonly.NewSignerWithFixedSig(nil, []byte("notempty")), crypto.SHA256)
+	root, err := signer.SignLogRoot(&types.LogRootV1{
+		TimestampNanos: 98765,
+		TreeSize:       16,
+		Revision:       5,
+		RootHash:       []byte(dummyHash),
+	})
+	if err != nil {
+		t.Fatalf("SignLogRoot(): %v", err)
+	}
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		return tx.StoreSignedLogRoot(ctx, root)
+	})
+
+	sig := func(key, sig string) *trillian.LogRootSignature {
+		return &trillian.LogRootSignature{PublicKey: &keyspb.PublicKey{Der: []byte(key)}, Signature: []byte(sig)}
+	}
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		for _, sig := range []*trillian.LogRootSignature{
+			sig("key1", "old"),
+			sig("key2", "sig2"),
+			sig("key1", "sig1"), // Replaces the first signature.
+		} {
+			if err := tx.StoreLogRootSignature(ctx, 5, sig); err != nil {
+				t.Fatalf("StoreLogRootSignature(%v): %v", sig, err)
+			}
+		}
+		return nil
+	})
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		got, err := tx.GetSignedLogRoot(ctx, 5)
+		if err != nil {
+			t.Fatalf("GetSignedLogRoot(5): %v", err)
+		}
+		if !proto.Equal(got, root) {
+			t.Errorf("GetSignedLogRoot(5)=%v, want %v", got, root)
+		}
+		if _, err := tx.GetSignedLogRoot(ctx, 6); status.Code(err) != codes.NotFound {
+			t.Errorf("GetSignedLogRoot(6): %v, want code %v", err, codes.NotFound)
+		}
+
+		// Signatures are ordered by the hash of their key.
+		want := []*trillian.LogRootSignature{sig("key1", "sig1"), sig("key2", "sig2")}
+		sort.Slice(want, func(i, j int) bool {
+			hi, hj := sha256.Sum256(want[i].PublicKey.Der), sha256.Sum256(want[j].PublicKey.Der)
+			return bytes.Compare(hi[:], hj[:]) < 0
+		})
+		sigs, err := tx.GetLogRootSignatures(ctx, 5)
+		if err != nil {
+			t.Fatalf("GetLogRootSignatures(5): %v", err)
+		}
+		if len(sigs) != len(want) {
+			t.Fatalf("GetLogRootSignatures(5): %d signatures, want %d", len(sigs), len(want))
+		}
+		for i := range sigs {
+			if !proto.Equal(sigs[i], want[i]) {
+				t.Errorf("GetLogRootSignatures(5)[%d]=%v, want %v", i, sigs[i], want[i])
+			}
+		}
+		if sigs, err := tx.GetLogRootSignatures(ctx, 6); err != nil || len(sigs) != 0 {
+			t.Errorf("GetLogRootSignatures(6)=%v, %v, want none", sigs, err)
+		}
+		return nil
+	})
+}
+
+func TestGetSignedLogRootByHash(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree := mustCreateTree(ctx, t, as, testonly.LogTree)
+	s := NewLogStorage(DB, nil)
+
+	signer := tcrypto.NewSigner(tree.TreeId, ttestonly.NewSignerWithFixedSig(nil, []byte("notempty")), crypto.SHA256)
+	var roots []*trillian.SignedLogRoot
+	// The second root re-signs the first tree state.
+	for i, hash := range [][]byte{dummyHash, dummyHash, dummyHash2} {
+		root, err := signer.SignLogRoot(&types.LogRootV1{
+			TimestampNanos: uint64(98765 + i),
+			TreeSize:       16,
+			Revision:       uint64(5 + i),
+			RootHash:       hash,
+		})
+		if err != nil {
+			t.Fatalf("SignLogRoot(): %v", err)
+		}
+		runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+			return tx.StoreSignedLogRoot(ctx, root)
+		})
+		roots = append(roots, root)
+	}
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		for _, test := range []struct {
+			hash []byte
+			want *trillian.SignedLogRoot
+		}{
+			{hash: dummyHash, want: roots[0]},
+			{hash: dummyHash2, want: roots[2]},
+		} {
+			got, err := tx.GetSignedLogRootByHash(ctx, test.hash)
+			if err != nil {
+				t.Fatalf("GetSignedLogRootByHash(%x): %v", test.hash, err)
+			}
+			if !proto.Equal(got, test.want) {
+				t.Errorf("GetSignedLogRootByHash(%x)=%v, want %v", test.hash, got, test.want)
+			}
+		}
+		if _, err := tx.GetSignedLogRootByHash(ctx, dummyHash3); status.Code(err) != codes.NotFound {
+			t.Errorf("GetSignedLogRootByHash(%x): %v, want code %v", dummyHash3, err, codes.NotFound)
+		}
+		return nil
+	})
+}
+
 func TestGetActiveLogIDs(t *testing.T) {
 	ctx := context.Background()
 
@@ -1350,6 +1560,55 @@ func TestGetSequencedLeafCount(t *testing.T) {
 	})
 }
 
+func TestSnapshotForTreeOnReplica(t *testing.T) {
+	ctx := context.Background()
+	cleanTestDB(DB)
+	replica, done := openTestDBOrDie()
+	defer done(ctx)
+
+	// The replica lags behind: its latest root of the tree is from 1970.
+	tree := mustCreateTree(ctx, t, NewAdminStorage(DB), testonly.LogTree)
+	mustCreateTree(ctx, t, NewAdminStorage(replica), tree)
+	mustSignAndStoreLogRoot(ctx, t, NewLogStorage(replica, nil), tree, 1)
+	signer := tcrypto.NewSigner(0, ttestonly.NewSignerWithFixedSig(nil, []byte("notnil")), crypto.SHA256)
+	root, err := signer.SignLogRoot(&types.LogRootV1{TreeSize: 2, RootHash: []byte{0}, TimestampNanos: uint64(time.Now().UnixNano())})
+	if err != nil {
+		t.Fatalf("SignLogRoot(): %v", err)
+	}
+	runLogTX(NewLogStorage(DB, nil), tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		return tx.StoreSignedLogRoot(ctx, root)
+	})
+
+	for _, tc := range []struct {
+		desc         string
+		maxStaleness time.Duration
+		wantSize     int64
+	}{
+		{desc: "unlimited", wantSize: 1},
+		{desc: "stale", maxStaleness: time.Hour, wantSize: 2},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := NewLogStorageWithOpts(DB, nil, TreeStorageOptions{Replica: replica, MaxReplicaStaleness: tc.maxStaleness})
+			tx, err := s.SnapshotForTree(ctx, tree)
+			if err != nil {
+				t.Fatalf("SnapshotForTree(): %v", err)
+			}
+			defer tx.Close()
+			slr, err := tx.LatestSignedLogRoot(ctx)
+			if err != nil {
+				t.Fatalf("LatestSignedLogRoot(): %v", err)
+			}
+			var got types.LogRootV1
+			if err := got.UnmarshalBinary(slr.LogRoot); err != nil {
+				t.Fatalf("UnmarshalBinary(): %v", err)
+			}
+			if int64(got.TreeSize) != tc.wantSize {
+				t.Errorf("LatestSignedLogRoot().TreeSize = %d, want %d", got.TreeSize, tc.wantSize)
+			}
+		})
+	}
+}
+
 func TestSortByLeafIdentityHash(t *testing.T) {
 	l := make([]*trillian.LogLeaf, 30)
 	for i := range l {
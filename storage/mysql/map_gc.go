@@ -0,0 +1,238 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring"
+)
+
+const (
+	// selectVersionsAfterSQL returns, in key order, a page of the versions at
+	// or before a revision of the rows of a table keyed by tree, a key column
+	// and a revision column, following a given key and revision.
+	selectVersionsAfterSQL = `SELECT %[2]s, %[3]s FROM %[1]s
+		WHERE TreeId=? AND %[3]s<=? AND (%[2]s, %[3]s)>(?, ?)
+		ORDER BY %[2]s, %[3]s LIMIT ?`
+	deleteVersionSQL = `DELETE FROM %[1]s WHERE TreeId=? AND %[2]s=? AND %[3]s=?`
+	// selectMapLeafHashesAfterSQL returns, in key order, a page of the leaf
+	// hashes at or before a revision following a given key, and whether their
+	// leaf versions have been deleted.
+	selectMapLeafHashesAfterSQL = `SELECT h.LeafHash, h.KeyHash, h.MapRevision, l.KeyHash IS NULL
+		FROM MapLeafHash h
+		LEFT JOIN MapLeaf l
+		ON h.TreeId=l.TreeId
+		AND h.KeyHash=l.KeyHash
+		AND h.MapRevision=l.MapRevision
+		WHERE h.TreeId=? AND h.MapRevision<=? AND (h.LeafHash, h.KeyHash, h.MapRevision)>(?, ?, ?)
+		ORDER BY h.LeafHash, h.KeyHash, h.MapRevision LIMIT ?`
+	deleteOrphanMapLeafHashSQL = `DELETE FROM MapLeafHash WHERE TreeId=? AND LeafHash=? AND KeyHash=? AND MapRevision=?`
+	// deleteMapIdempotencyTokensBeforeSQL deletes a batch of the tokens of the
+	// revisions deleted by deleteMapHeadsBeforeSQL.
+	deleteMapIdempotencyTokensBeforeSQL = `DELETE FROM MapIdempotencyToken WHERE TreeId=? AND MapRevision<? LIMIT ?`
+
+	mapIDLabel = "mapid"
+)
+
+// gcBatchSize is the number of rows read by each page of the deletion of
+// superseded map data, whose deletions are committed together. It keeps the
+// locks held by each transaction short-lived. It's a variable for tests.
+var gcBatchSize = 1000
+
+var (
+	gcMetricsOnce    sync.Once
+	gcFailureCounter monitoring.Counter
+)
+
+// InitMapMetrics initializes the metrics of map storage using mf to create
+// them. May be called multiple times. If so, the first call is the one that
+// counts.
+func InitMapMetrics(mf monitoring.MetricFactory) {
+	gcMetricsOnce.Do(func() {
+		gcFailureCounter = mf.NewCounter("mysql_map_gc_failures", "Number of failed deletions of map data superseded at a deleted revision, which are retried by the next deletion", mapIDLabel)
+	})
+}
+
+// collectGarbage deletes the data superseded at the revisions passed to
+// DeleteRevisionsBefore by the committed transactions txs. The transactions
+// have committed, so failures are logged and counted rather than returned,
+// and the rest of the data is deleted by the next call.
+func (m *mySQLMapStorage) collectGarbage(ctx context.Context, txs ...*mapTreeTX) {
+	for _, tx := range txs {
+		if tx.gcRevision <= 0 {
+			continue
+		}
+		if err := m.deleteSuperseded(ctx, tx.treeID, tx.gcRevision, tx.leafHashIndex); err != nil {
+			glog.Errorf("Failed to delete data of map %d superseded at revision %d: %v", tx.treeID, tx.gcRevision, err)
+			if gcFailureCounter != nil {
+				gcFailureCounter.Inc(strconv.FormatInt(tx.treeID, 10))
+			}
+		}
+	}
+}
+
+// deleteSuperseded deletes the versions of the leaves and subtrees of a map
+// which are superseded at revision, the leaf hashes of the deleted leaf
+// versions if the map has a leaf hash index, and the idempotency tokens of the
+// revisions before revision, in batches of separate transactions.
+func (m *mySQLMapStorage) deleteSuperseded(ctx context.Context, treeID, revision int64, leafHashIndex bool) error {
+	if err := m.deleteSupersededVersions(ctx, treeID, revision, "MapLeaf", "KeyHash", "MapRevision"); err != nil {
+		return err
+	}
+	if err := m.deleteSupersededVersions(ctx, treeID, revision, "Subtree", "SubtreeId", "SubtreeRevision"); err != nil {
+		return err
+	}
+	if leafHashIndex {
+		if err := m.deleteOrphanLeafHashes(ctx, treeID, revision); err != nil {
+			return err
+		}
+	}
+	for {
+		res, err := m.db.ExecContext(ctx, m.tag(ctx, treeID, deleteMapIdempotencyTokensBeforeSQL), treeID, revision, gcBatchSize)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n < int64(gcBatchSize) {
+			return nil
+		}
+	}
+}
+
+// deleteSupersededVersions deletes the rows of table at or before revision
+// which are not the most recent version of their key. The table must be keyed
+// by tree ID, keyCol and revCol. The rows are read in pages of gcBatchSize in
+// key order, and the deletions of each page are committed before the next one
+// is read.
+func (m *mySQLMapStorage) deleteSupersededVersions(ctx context.Context, treeID, revision int64, table, keyCol, revCol string) error {
+	query := m.tag(ctx, treeID, fmt.Sprintf(selectVersionsAfterSQL, table, keyCol, revCol))
+	del := m.tag(ctx, treeID, fmt.Sprintf(deleteVersionSQL, table, keyCol, revCol))
+
+	prev, prevRev := []byte{}, int64(-1)
+	for {
+		// Versions are ordered oldest first, so each version is superseded if
+		// the next one has the same key.
+		var superseded [][]interface{}
+		rows := 0
+		err := forEachRow(ctx, m.db, query, []interface{}{treeID, revision, prev, prevRev, gcBatchSize}, func(r *sql.Rows) error {
+			var key []byte
+			var rev int64
+			if err := r.Scan(&key, &rev); err != nil {
+				return err
+			}
+			if prevRev >= 0 && bytes.Equal(prev, key) {
+				superseded = append(superseded, []interface{}{treeID, prev, prevRev})
+			}
+			prev, prevRev = key, rev
+			rows++
+			return nil
+		})
+		if err != nil {
+			glog.Errorf("Failed to read superseded %s rows: %v", table, err)
+			return err
+		}
+		if err := m.deleteRows(ctx, del, superseded); err != nil {
+			glog.Errorf("Failed to delete superseded %s rows: %v", table, err)
+			return err
+		}
+		if rows < gcBatchSize {
+			return nil
+		}
+	}
+}
+
+// deleteOrphanLeafHashes deletes the leaf hashes at or before revision whose
+// leaf versions have been deleted, in pages like deleteSupersededVersions.
+func (m *mySQLMapStorage) deleteOrphanLeafHashes(ctx context.Context, treeID, revision int64) error {
+	query := m.tag(ctx, treeID, selectMapLeafHashesAfterSQL)
+	del := m.tag(ctx, treeID, deleteOrphanMapLeafHashSQL)
+
+	prevHash, prevKey, prevRev := []byte{}, []byte{}, int64(-1)
+	for {
+		var orphans [][]interface{}
+		rows := 0
+		err := forEachRow(ctx, m.db, query, []interface{}{treeID, revision, prevHash, prevKey, prevRev, gcBatchSize}, func(r *sql.Rows) error {
+			var orphan bool
+			if err := r.Scan(&prevHash, &prevKey, &prevRev, &orphan); err != nil {
+				return err
+			}
+			if orphan {
+				orphans = append(orphans, []interface{}{treeID, prevHash, prevKey, prevRev})
+			}
+			rows++
+			return nil
+		})
+		if err != nil {
+			glog.Errorf("Failed to read orphan MapLeafHash rows: %v", err)
+			return err
+		}
+		if err := m.deleteRows(ctx, del, orphans); err != nil {
+			glog.Errorf("Failed to delete orphan MapLeafHash rows: %v", err)
+			return err
+		}
+		if rows < gcBatchSize {
+			return nil
+		}
+	}
+}
+
+// forEachRow runs query with args, and calls fn with each row of its result.
+func forEachRow(ctx context.Context, db *sql.DB, query string, args []interface{}, fn func(*sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// deleteRows runs del with each of args in one transaction.
+func (m *mySQLMapStorage) deleteRows(ctx context.Context, del string, args [][]interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+	tx, err := m.db.BeginTx(ctx, nil /* opts */)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, del)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, a := range args {
+		if _, err := stmt.ExecContext(ctx, a...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
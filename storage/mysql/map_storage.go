@@ -15,13 +15,19 @@
 package mysql
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/google/trillian"
-	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cache"
 	"github.com/google/trillian/types"
@@ -31,14 +37,90 @@ import (
 )
 
 const (
-	insertMapHeadSQL = `INSERT INTO MapHead(TreeId, MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData)
-	VALUES(?, ?, ?, ?, ?, ?)`
-	selectLatestSignedMapRootSQL = `SELECT MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData
+	// lockTreeSQL locks the row of a tree, so that multi-map transactions
+	// serialize on the trees they share.
+	lockTreeSQL      = `SELECT TreeId FROM Trees WHERE TreeId=? FOR UPDATE`
+	insertMapHeadSQL = `INSERT INTO MapHead(TreeId, MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData, AdditionalSignatures)
+	VALUES(?, ?, ?, ?, ?, ?, ?)`
+	selectLatestSignedMapRootSQL = `SELECT MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData, AdditionalSignatures
 		 FROM MapHead WHERE TreeId=?
 		 ORDER BY MapHeadTimestamp DESC LIMIT 1`
-	selectGetSignedMapRootSQL = `SELECT MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData
+	selectGetSignedMapRootSQL = `SELECT MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData, AdditionalSignatures
 		 FROM MapHead WHERE TreeId=? AND MapRevision=?`
-	insertMapLeafSQL = `INSERT INTO MapLeaf(TreeId, KeyHash, MapRevision, LeafValue) VALUES (?, ?, ?, ?)`
+	// selectEarliestRevisionSinceSQL returns the earliest revision published at
+	// or after a timestamp, or the latest revision plus one if there is none.
+	selectEarliestRevisionSinceSQL = `SELECT COALESCE(MIN(CASE WHEN MapHeadTimestamp >= ? THEN MapRevision END), MAX(MapRevision)+1)
+		 FROM MapHead WHERE TreeId=?`
+	// selectMapHeadsInRangeSQL returns the roots within a range of revisions
+	// and a range of timestamps. The ORDER BY direction is appended by
+	// ListSignedMapRoots.
+	selectMapHeadsInRangeSQL = `SELECT MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData, AdditionalSignatures
+		 FROM MapHead WHERE TreeId=?
+		 AND MapRevision>=? AND MapRevision<?
+		 AND MapHeadTimestamp>=? AND MapHeadTimestamp<?
+		 ORDER BY MapRevision `
+	deleteMapHeadsBeforeSQL      = `DELETE FROM MapHead WHERE TreeId=? AND MapRevision<?`
+	insertMapIdempotencyTokenSQL = `INSERT INTO MapIdempotencyToken(TreeId, Token, MapRevision) VALUES (?, ?, ?)`
+	selectMapIdempotencyTokenSQL = `SELECT MapRevision FROM MapIdempotencyToken WHERE TreeId=? AND Token=?`
+	// deleteMapRootSignaturesBeforeSQL deletes the witness signatures of the
+	// revisions deleted by deleteMapHeadsBeforeSQL.
+	deleteMapRootSignaturesBeforeSQL = `DELETE FROM MapRootSignature WHERE TreeId=? AND MapRevision<?`
+	insertMapRootSignatureSQL        = `INSERT INTO MapRootSignature(TreeId, MapRevision, KeyHash, PublicKey, Signature)
+		 VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE Signature=VALUES(Signature)`
+	selectMapRootSignaturesSQL = `SELECT PublicKey, Signature FROM MapRootSignature
+		 WHERE TreeId=? AND MapRevision=? ORDER BY KeyHash`
+	insertMapLeafSQL        = `INSERT INTO MapLeaf(TreeId, KeyHash, MapRevision, LeafValue) VALUES (?, ?, ?, ?)`
+	selectMapLeafVersionSQL = `SELECT LeafValue FROM MapLeaf WHERE TreeId=? AND KeyHash=? AND MapRevision=?`
+	updateMapLeafVersionSQL = `UPDATE MapLeaf SET LeafValue=? WHERE TreeId=? AND KeyHash=? AND MapRevision=?`
+	insertMapLeafHashSQL    = `INSERT INTO MapLeafHash(TreeId, LeafHash, KeyHash, MapRevision) VALUES (?, ?, ?, ?)`
+	deleteMapLeafHashSQL    = `DELETE FROM MapLeafHash WHERE TreeId=? AND KeyHash=? AND MapRevision=?`
+	// selectIndexesByLeafHashSQL returns the indexes whose latest version at a
+	// revision has a given leaf hash.
+	selectIndexesByLeafHashSQL = `
+ SELECT h.KeyHash
+ FROM MapLeafHash h
+ WHERE h.TreeId = ? AND h.LeafHash = ? AND h.MapRevision <= ?
+ AND h.MapRevision =
+ (
+	SELECT MAX(l.MapRevision)
+	FROM MapLeaf l
+	WHERE l.TreeId = h.TreeId AND l.KeyHash = h.KeyHash AND l.MapRevision <= ?
+ )
+ ORDER BY h.KeyHash`
+	// selectMapLeafVersionsAfterSQL returns all the versions of the first LIMIT
+	// indexes following a given index.
+	selectMapLeafVersionsAfterSQL = `
+ SELECT t1.KeyHash, t1.MapRevision, t1.LeafValue
+ FROM MapLeaf t1
+ INNER JOIN
+ (
+	SELECT DISTINCT TreeId, KeyHash
+	FROM MapLeaf t0
+	WHERE t0.TreeId = ? AND t0.KeyHash > ?
+	ORDER BY t0.KeyHash
+	LIMIT ?
+ ) t2
+ ON t1.TreeId=t2.TreeId
+ AND t1.KeyHash=t2.KeyHash
+ ORDER BY t1.KeyHash, t1.MapRevision`
+	// selectMapLeavesAfterSQL returns the latest value of each leaf at a
+	// revision, for the first LIMIT indexes following a given index.
+	selectMapLeavesAfterSQL = `
+ SELECT t1.KeyHash, t1.LeafValue
+ FROM MapLeaf t1
+ INNER JOIN
+ (
+	SELECT TreeId, KeyHash, MAX(MapRevision) as maxrev
+	FROM MapLeaf t0
+	WHERE t0.TreeId = ? AND t0.KeyHash > ? AND t0.MapRevision <= ?
+	GROUP BY t0.TreeId, t0.KeyHash
+	ORDER BY t0.KeyHash
+	LIMIT ?
+ ) t2
+ ON t1.TreeId=t2.TreeId
+ AND t1.KeyHash=t2.KeyHash
+ AND t1.MapRevision=t2.maxrev
+ ORDER BY t1.KeyHash`
 )
 
 var defaultMapStrata = []int{8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 176}
@@ -46,15 +128,27 @@ var defaultMapStrata = []int{8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 176}
 type mySQLMapStorage struct {
 	*mySQLTreeStorage
 	admin storage.AdminStorage
+	// replica is the storage on opts.Replica, if set.
+	replica *mySQLMapStorage
 }
 
 // NewMapStorage creates a storage.MapStorage instance for the specified MySQL URL.
 // It assumes storage.AdminStorage is backed by the same MySQL database as well.
 func NewMapStorage(db *sql.DB) storage.MapStorage {
-	return &mySQLMapStorage{
+	return NewMapStorageWithOpts(db, TreeStorageOptions{})
+}
+
+// NewMapStorageWithOpts creates a storage.MapStorage instance for the specified
+// MySQL URL, using options.
+func NewMapStorageWithOpts(db *sql.DB, opts TreeStorageOptions) storage.MapStorage {
+	ms := &mySQLMapStorage{
 		admin:            NewAdminStorage(db),
-		mySQLTreeStorage: newTreeStorage(db),
+		mySQLTreeStorage: newTreeStorage(db, opts),
+	}
+	if opts.Replica != nil {
+		ms.replica = NewMapStorageWithOpts(opts.Replica, replicaOpts(opts)).(*mySQLMapStorage)
 	}
+	return ms
 }
 
 func (m *mySQLMapStorage) CheckDatabaseAccessible(ctx context.Context) error {
@@ -62,6 +156,18 @@ func (m *mySQLMapStorage) CheckDatabaseAccessible(ctx context.Context) error {
 }
 
 func (m *mySQLMapStorage) begin(ctx context.Context, tree *trillian.Tree, readonly bool) (storage.MapTreeTX, error) {
+	mtx, err := m.beginWith(ctx, tree, readonly, func(hashSizeBytes int, stCache *cache.SubtreeCache) (treeTX, error) {
+		return m.beginTreeTx(ctx, tree, hashSizeBytes, stCache)
+	})
+	if mtx == nil {
+		return nil, err
+	}
+	return mtx, err
+}
+
+// beginWith returns a mapTreeTX for tree, using newTX to create the underlying
+// treeTX.
+func (m *mySQLMapStorage) beginWith(ctx context.Context, tree *trillian.Tree, readonly bool, newTX func(int, *cache.SubtreeCache) (treeTX, error)) (*mapTreeTX, error) {
 	// TODO: Find a stronger way to ensure that tree has been pulled from storage.
 	// This is a cheap safety-belt check to help us use this API consistently.
 	if tree.UpdateTime == nil {
@@ -70,20 +176,25 @@ func (m *mySQLMapStorage) begin(ctx context.Context, tree *trillian.Tree, readon
 	if got, want := tree.TreeType, trillian.TreeType_MAP; got != want {
 		return nil, fmt.Errorf("begin(tree.TreeType: %v), want %v", got, want)
 	}
-	hasher, err := hashers.NewMapHasher(tree.HashStrategy)
+	hasher, err := m.opts.MapHashers.NewMapHasherForTree(tree)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := mapStorageSettings(tree)
 	if err != nil {
 		return nil, err
 	}
 
-	stCache := cache.NewMapSubtreeCache(defaultMapStrata, tree.TreeId, hasher)
-	ttx, err := m.beginTreeTx(ctx, tree, hasher.Size(), stCache)
+	stCache := cache.NewMapSubtreeCache(cache.MapStrata(defaultMapStrata, hasher.BitLen()), tree.TreeId, hasher)
+	ttx, err := newTX(hasher.Size(), stCache)
 	if err != nil {
 		return nil, err
 	}
 	mtx := &mapTreeTX{
-		treeTX:       ttx,
-		ms:           m,
-		readRevision: -1,
+		treeTX:        ttx,
+		ms:            m,
+		readRevision:  -1,
+		leafHashIndex: settings.LeafHashIndex,
 	}
 
 	if readonly {
@@ -113,9 +224,45 @@ func (m *mySQLMapStorage) begin(ctx context.Context, tree *trillian.Tree, readon
 }
 
 func (m *mySQLMapStorage) SnapshotForTree(ctx context.Context, tree *trillian.Tree) (storage.ReadOnlyMapTreeTX, error) {
+	if m.replica != nil {
+		tx, err := m.replica.snapshotIfFresh(ctx, tree, m.freshOnReplica)
+		if tx != nil || err != nil {
+			return tx, err
+		}
+	}
 	return m.begin(ctx, tree, true /* readonly */)
 }
 
+// snapshotIfFresh returns a read-only transaction for tree if fresh accepts
+// the timestamp of its latest root, and nil if not, or if it has no root.
+func (m *mySQLMapStorage) snapshotIfFresh(ctx context.Context, tree *trillian.Tree, fresh func(uint64) bool) (storage.ReadOnlyMapTreeTX, error) {
+	tx, err := m.begin(ctx, tree, true /* readonly */)
+	if err != nil {
+		return nil, err
+	}
+	root, err := tx.LatestSignedMapRoot(ctx)
+	if err == storage.ErrTreeNeedsInit {
+		tx.Close()
+		return nil, nil
+	} else if err != nil {
+		tx.Close()
+		return nil, err
+	}
+	var mr types.MapRootV1
+	if err := mr.UnmarshalBinary(root.MapRoot); err != nil {
+		tx.Close()
+		return nil, err
+	}
+	if !fresh(mr.TimestampNanos) {
+		tx.Close()
+		return nil, nil
+	}
+	// Leave the revision to read for the caller to choose, as in any other
+	// read-only transaction.
+	tx.(*mapTreeTX).readRevision = -1
+	return tx, nil
+}
+
 func (m *mySQLMapStorage) ReadWriteTransaction(ctx context.Context, tree *trillian.Tree, f storage.MapTXFunc) error {
 	tx, err := m.begin(ctx, tree, false /* readonly */)
 	if tx != nil {
@@ -127,13 +274,86 @@ func (m *mySQLMapStorage) ReadWriteTransaction(ctx context.Context, tree *trilli
 	if err := f(ctx, tx); err != nil {
 		return err
 	}
-	return tx.Commit(ctx)
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	m.collectGarbage(ctx, tx.(*mapTreeTX))
+	return nil
+}
+
+func (m *mySQLMapStorage) ReadWriteMultiTransaction(ctx context.Context, trees []*trillian.Tree, f storage.MultiMapTXFunc) error {
+	t, err := m.db.BeginTx(ctx, nil /* opts */)
+	if err != nil {
+		glog.Warningf("Could not start multi-map TX: %s", err)
+		return err
+	}
+	// All the mapTreeTXs run their queries in t, so they share a mutex, and
+	// are committed or rolled back together.
+	mu := &sync.Mutex{}
+	mtxs := make([]*mapTreeTX, 0, len(trees))
+	committed := false
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, mtx := range mtxs {
+			mtx.closed = true
+		}
+		if !committed {
+			if err := t.Rollback(); err != nil {
+				glog.Warningf("Multi-map TX rollback error: %v", err)
+			}
+		}
+	}()
+
+	if err := lockTrees(ctx, t, trees); err != nil {
+		return err
+	}
+
+	txs := make([]storage.MapTreeTX, 0, len(trees))
+	for _, tree := range trees {
+		tree := tree
+		mtx, err := m.beginWith(ctx, tree, false /* readonly */, func(hashSizeBytes int, stCache *cache.SubtreeCache) (treeTX, error) {
+			return m.newTreeTX(t, mu, tree, hashSizeBytes, stCache), nil
+		})
+		if err != nil && err != storage.ErrTreeNeedsInit {
+			return err
+		}
+		mtxs = append(mtxs, mtx)
+		txs = append(txs, mtx)
+	}
+	if err := f(ctx, txs); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, mtx := range mtxs {
+		if err := mtx.flushSubtrees(ctx); err != nil {
+			return err
+		}
+	}
+	committed = true
+	if err := t.Commit(); err != nil {
+		glog.Warningf("Multi-map TX commit error: %s", err)
+		return err
+	}
+	for _, mtx := range mtxs {
+		mtx.recordRecoveryMarker(ctx)
+	}
+	m.collectGarbage(ctx, mtxs...)
+	return nil
 }
 
 type mapTreeTX struct {
 	treeTX
 	ms           *mySQLMapStorage
 	readRevision int64
+	// leafHashIndex is set if the leaf hashes of the map are stored in the
+	// MapLeafHash table.
+	leafHashIndex bool
+	// gcRevision is the revision passed to DeleteRevisionsBefore, at which the
+	// superseded data is deleted once the transaction commits, or 0.
+	gcRevision int64
 }
 
 func (m *mapTreeTX) ReadRevision(ctx context.Context) (int64, error) {
@@ -166,13 +386,20 @@ func (m *mapTreeTX) Set(ctx context.Context, keyHash []byte, value *trillian.Map
 		return nil
 	}
 
-	stmt, err := m.tx.PrepareContext(ctx, insertMapLeafSQL)
+	stmt, err := m.tx.PrepareContext(ctx, m.tag(ctx, insertMapLeafSQL))
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, m.treeID, keyHash, m.writeRevision, flatValue)
+	if _, err = stmt.ExecContext(ctx, m.treeID, keyHash, m.writeRevision, flatValue); err != nil {
+		return err
+	}
+	// Deleted leaves can't be looked up by hash.
+	if !m.leafHashIndex || len(value.LeafValue) == 0 {
+		return nil
+	}
+	_, err = m.tx.ExecContext(ctx, m.tag(ctx, insertMapLeafHashSQL), m.treeID, value.LeafHash, keyHash, m.writeRevision)
 	return err
 }
 
@@ -202,11 +429,10 @@ func (m *mapTreeTX) Get(ctx context.Context, revision int64, indexes [][]byte) (
  AND t1.KeyHash=t2.KeyHash
  AND t1.MapRevision=t2.maxrev`
 
-	stmt, err := m.ms.getStmt(ctx, selectMapLeafSQL, len(indexes), "?", "?")
+	stx, err := m.stmt(ctx, selectMapLeafSQL, len(indexes), "?", "?")
 	if err != nil {
 		return nil, err
 	}
-	stx := m.tx.StmtContext(ctx, stmt)
 	defer stx.Close()
 
 	args := make([]interface{}, 0, len(indexes)+2)
@@ -240,6 +466,153 @@ func (m *mapTreeTX) Get(ctx context.Context, revision int64, indexes [][]byte) (
 	return ret, nil
 }
 
+// List returns up to limit map leaves which exist at revision, ordered by
+// index, starting with the first index greater than after.
+func (m *mapTreeTX) List(ctx context.Context, revision int64, after []byte, limit int) ([]*trillian.MapLeaf, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	if limit <= 0 {
+		return []*trillian.MapLeaf{}, nil
+	}
+	if after == nil {
+		after = []byte{}
+	}
+
+	stmt, err := m.tx.PrepareContext(ctx, m.tag(ctx, selectMapLeavesAfterSQL))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, m.treeID, after, revision, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]*trillian.MapLeaf, 0, limit)
+	for rows.Next() {
+		var mapKeyHash, flatData []byte
+		if err := rows.Scan(&mapKeyHash, &flatData); err != nil {
+			return nil, err
+		}
+		mapLeaf, err := unmarshalMapLeaf(flatData, mapKeyHash)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, mapLeaf)
+	}
+	return ret, rows.Err()
+}
+
+// ListLeafVersions returns all the versions of up to limit map leaves, ordered
+// by index and revision, starting with the first index greater than after.
+func (m *mapTreeTX) ListLeafVersions(ctx context.Context, after []byte, limit int) ([]storage.MapLeafVersion, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	if limit <= 0 {
+		return []storage.MapLeafVersion{}, nil
+	}
+	if after == nil {
+		after = []byte{}
+	}
+
+	stmt, err := m.tx.PrepareContext(ctx, m.tag(ctx, selectMapLeafVersionsAfterSQL))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, m.treeID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]storage.MapLeafVersion, 0, limit)
+	for rows.Next() {
+		var mapKeyHash, flatData []byte
+		var revision int64
+		if err := rows.Scan(&mapKeyHash, &revision, &flatData); err != nil {
+			return nil, err
+		}
+		mapLeaf, err := unmarshalMapLeaf(flatData, mapKeyHash)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, storage.MapLeafVersion{Revision: revision, Leaf: mapLeaf})
+	}
+	return ret, rows.Err()
+}
+
+func (m *mapTreeTX) SetLeafHash(ctx context.Context, revision int64, index, leafHash []byte) error {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	// Leaves are stored as marshalled MapLeaf protos, so the hash can only be
+	// replaced by rewriting the whole leaf.
+	var flatData []byte
+	if err := m.tx.QueryRowContext(ctx, m.tag(ctx, selectMapLeafVersionSQL), m.treeID, index, revision).Scan(&flatData); err != nil {
+		return err
+	}
+	mapLeaf, err := unmarshalMapLeaf(flatData, index)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(mapLeaf.LeafHash, leafHash) {
+		// MySQL does not count rows updated to their current value as affected.
+		return nil
+	}
+	mapLeaf.LeafHash = leafHash
+	if flatData, err = proto.Marshal(mapLeaf); err != nil {
+		return err
+	}
+	res, err := m.tx.ExecContext(ctx, m.tag(ctx, updateMapLeafVersionSQL), flatData, m.treeID, index, revision)
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		return err
+	}
+	if !m.leafHashIndex {
+		return nil
+	}
+	// The version may have had no hash to index, so it is indexed afresh.
+	if _, err := m.tx.ExecContext(ctx, m.tag(ctx, deleteMapLeafHashSQL), m.treeID, index, revision); err != nil {
+		return err
+	}
+	if len(mapLeaf.LeafValue) == 0 {
+		return nil
+	}
+	_, err = m.tx.ExecContext(ctx, m.tag(ctx, insertMapLeafHashSQL), m.treeID, leafHash, index, revision)
+	return err
+}
+
+// GetIndexesByLeafHash returns the indexes of the leaves whose value at
+// revision has leafHash, ordered by index.
+func (m *mapTreeTX) GetIndexesByLeafHash(ctx context.Context, revision int64, leafHash []byte) ([][]byte, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	if !m.leafHashIndex {
+		return nil, storage.ErrNoLeafHashIndex
+	}
+	rows, err := m.tx.QueryContext(ctx, m.tag(ctx, selectIndexesByLeafHashSQL), m.treeID, leafHash, revision, revision)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes [][]byte
+	for rows.Next() {
+		var index []byte
+		if err := rows.Scan(&index); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, rows.Err()
+}
+
 func unmarshalMapLeaf(marshaledLeaf, mapKeyHash []byte) (*trillian.MapLeaf, error) {
 	if len(marshaledLeaf) == 0 {
 		return nil, errors.New("len(marshaledLeaf): 0 want > 0")
@@ -258,16 +631,16 @@ func (m *mapTreeTX) GetSignedMapRoot(ctx context.Context, revision int64) (*tril
 
 	var timestamp, mapRevision int64
 	var rootHash, rootSignatureBytes []byte
-	var mapperMetaBytes []byte
+	var mapperMetaBytes, additionalSignatures []byte
 
-	stmt, err := m.tx.PrepareContext(ctx, selectGetSignedMapRootSQL)
+	stmt, err := m.tx.PrepareContext(ctx, m.tag(ctx, selectGetSignedMapRootSQL))
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
 	err = stmt.QueryRowContext(ctx, m.treeID, revision).Scan(
-		&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes)
+		&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes, &additionalSignatures)
 	if err != nil {
 		if revision == 0 {
 			return nil, storage.ErrTreeNeedsInit
@@ -275,7 +648,7 @@ func (m *mapTreeTX) GetSignedMapRoot(ctx context.Context, revision int64) (*tril
 		return nil, err
 	}
 	m.readRevision = mapRevision
-	return m.signedMapRoot(timestamp, mapRevision, rootHash, rootSignatureBytes, mapperMetaBytes)
+	return m.signedMapRoot(timestamp, mapRevision, rootHash, rootSignatureBytes, mapperMetaBytes, additionalSignatures)
 }
 
 func (m *mapTreeTX) LatestSignedMapRoot(ctx context.Context) (*trillian.SignedMapRoot, error) {
@@ -284,16 +657,16 @@ func (m *mapTreeTX) LatestSignedMapRoot(ctx context.Context) (*trillian.SignedMa
 
 	var timestamp, mapRevision int64
 	var rootHash, rootSignatureBytes []byte
-	var mapperMetaBytes []byte
+	var mapperMetaBytes, additionalSignatures []byte
 
-	stmt, err := m.tx.PrepareContext(ctx, selectLatestSignedMapRootSQL)
+	stmt, err := m.tx.PrepareContext(ctx, m.tag(ctx, selectLatestSignedMapRootSQL))
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
 	err = stmt.QueryRowContext(ctx, m.treeID).Scan(
-		&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes)
+		&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes, &additionalSignatures)
 
 	// It's possible there are no roots for this tree yet
 	if err == sql.ErrNoRows {
@@ -302,10 +675,166 @@ func (m *mapTreeTX) LatestSignedMapRoot(ctx context.Context) (*trillian.SignedMa
 		return nil, err
 	}
 	m.readRevision = mapRevision
-	return m.signedMapRoot(timestamp, mapRevision, rootHash, rootSignatureBytes, mapperMetaBytes)
+	return m.signedMapRoot(timestamp, mapRevision, rootHash, rootSignatureBytes, mapperMetaBytes, additionalSignatures)
+}
+
+func (m *mapTreeTX) EarliestRevisionSince(ctx context.Context, ts time.Time) (int64, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	stmt, err := m.tx.PrepareContext(ctx, m.tag(ctx, selectEarliestRevisionSinceSQL))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var rev sql.NullInt64
+	if err := stmt.QueryRowContext(ctx, ts.UnixNano(), m.treeID).Scan(&rev); err != nil {
+		return 0, err
+	}
+	// The aggregate is NULL only if there are no roots for this tree yet.
+	if !rev.Valid {
+		return 0, storage.ErrTreeNeedsInit
+	}
+	return rev.Int64, nil
+}
+
+// ListSignedMapRoots returns up to limit roots which match filter, ordered by
+// revision.
+func (m *mapTreeTX) ListSignedMapRoots(ctx context.Context, filter storage.MapRootFilter, limit int) ([]*trillian.SignedMapRoot, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	if limit <= 0 {
+		return []*trillian.SignedMapRoot{}, nil
+	}
+	query := selectMapHeadsInRangeSQL + "ASC LIMIT ?"
+	if filter.Descending {
+		query = selectMapHeadsInRangeSQL + "DESC LIMIT ?"
+	}
+	// Open bounds are replaced by the extremes of the column values.
+	endRev, startTS, endTS := filter.EndRevision, int64(0), int64(math.MaxInt64)
+	if endRev <= 0 {
+		endRev = math.MaxInt64
+	}
+	if !filter.StartTime.IsZero() {
+		startTS = filter.StartTime.UnixNano()
+	}
+	if !filter.EndTime.IsZero() {
+		endTS = filter.EndTime.UnixNano()
+	}
+
+	stmt, err := m.tx.PrepareContext(ctx, m.tag(ctx, query))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, m.treeID, filter.StartRevision, endRev, startTS, endTS, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]*trillian.SignedMapRoot, 0, limit)
+	for rows.Next() {
+		var timestamp, mapRevision int64
+		var rootHash, rootSignatureBytes, mapperMetaBytes, additionalSignatures []byte
+		if err := rows.Scan(&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes, &additionalSignatures); err != nil {
+			return nil, err
+		}
+		root, err := m.signedMapRoot(timestamp, mapRevision, rootHash, rootSignatureBytes, mapperMetaBytes, additionalSignatures)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, root)
+	}
+	return ret, rows.Err()
+}
+
+func (m *mapTreeTX) GetIdempotencyToken(ctx context.Context, token []byte) (int64, bool, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	var rev int64
+	err := m.tx.QueryRowContext(ctx, m.tag(ctx, selectMapIdempotencyTokenSQL), m.treeID, token).Scan(&rev)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	} else if err != nil {
+		glog.Warningf("Failed to read idempotency token: %s", err)
+		return 0, false, err
+	}
+	return rev, true, nil
+}
+
+func (m *mapTreeTX) StoreIdempotencyToken(ctx context.Context, token []byte) error {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	if _, err := m.tx.ExecContext(ctx, m.tag(ctx, insertMapIdempotencyTokenSQL), m.treeID, token, m.writeRevision); err != nil {
+		glog.Warningf("Failed to store idempotency token: %s", err)
+		return err
+	}
+	return nil
+}
+
+func (m *mapTreeTX) GetMapRootSignatures(ctx context.Context, revision int64) ([]*trillian.MapRootSignature, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	rows, err := m.tx.QueryContext(ctx, m.tag(ctx, selectMapRootSignaturesSQL), m.treeID, revision)
+	if err != nil {
+		glog.Warningf("Failed to read map root signatures: %s", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sigs []*trillian.MapRootSignature
+	for rows.Next() {
+		var der, sig []byte
+		if err := rows.Scan(&der, &sig); err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, &trillian.MapRootSignature{PublicKey: &keyspb.PublicKey{Der: der}, Signature: sig})
+	}
+	return sigs, rows.Err()
 }
 
-func (m *mapTreeTX) signedMapRoot(timestamp, mapRevision int64, rootHash, rootSignature, mapperMeta []byte) (*trillian.SignedMapRoot, error) {
+func (m *mapTreeTX) StoreMapRootSignature(ctx context.Context, revision int64, sig *trillian.MapRootSignature) error {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	der := sig.GetPublicKey().GetDer()
+	keyHash := sha256.Sum256(der)
+	if _, err := m.tx.ExecContext(ctx, m.tag(ctx, insertMapRootSignatureSQL), m.treeID, revision, keyHash[:], der, sig.GetSignature()); err != nil {
+		glog.Warningf("Failed to store map root signature: %s", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteRevisionsBefore deletes the roots of all revisions older than
+// revision, with their signatures and recovery markers. Once the transaction
+// commits, the leaf and subtree versions superseded at revision, their leaf
+// hashes and the idempotency tokens of the deleted revisions are deleted too,
+// in batches of separate transactions, so that the first deletion of a large
+// map doesn't hold locks on it for long. If that fails, the rest is deleted by
+// the next call.
+func (m *mapTreeTX) DeleteRevisionsBefore(ctx context.Context, revision int64) error {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	for _, query := range []string{deleteMapHeadsBeforeSQL, deleteMapRootSignaturesBeforeSQL, deleteRecoveryMarkersBeforeSQL} {
+		if _, err := m.tx.ExecContext(ctx, m.tag(ctx, query), m.treeID, revision); err != nil {
+			glog.Warningf("Failed to delete revisions before %d: %s", revision, err)
+			return err
+		}
+	}
+	m.gcRevision = revision
+	return nil
+}
+
+func (m *mapTreeTX) signedMapRoot(timestamp, mapRevision int64, rootHash, rootSignature, mapperMeta, additionalSignatures []byte) (*trillian.SignedMapRoot, error) {
 	mapRoot, err := (&types.MapRootV1{
 		RootHash:       rootHash,
 		TimestampNanos: uint64(timestamp),
@@ -315,10 +844,15 @@ func (m *mapTreeTX) signedMapRoot(timestamp, mapRevision int64, rootHash, rootSi
 	if err != nil {
 		return nil, err
 	}
+	sigs, err := unmarshalRootSignatures(additionalSignatures)
+	if err != nil {
+		return nil, err
+	}
 
 	return &trillian.SignedMapRoot{
-		MapRoot:   mapRoot,
-		Signature: rootSignature,
+		MapRoot:              mapRoot,
+		Signature:            rootSignature,
+		AdditionalSignatures: sigs,
 	}, nil
 }
 
@@ -330,19 +864,49 @@ func (m *mapTreeTX) StoreSignedMapRoot(ctx context.Context, root *trillian.Signe
 	if err := r.UnmarshalBinary(root.MapRoot); err != nil {
 		return err
 	}
+	additionalSignatures, err := marshalRootSignatures(root.AdditionalSignatures)
+	if err != nil {
+		return err
+	}
 
-	stmt, err := m.tx.PrepareContext(ctx, insertMapHeadSQL)
+	stmt, err := m.tx.PrepareContext(ctx, m.tag(ctx, insertMapHeadSQL))
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
 	// TODO(al): store transactionLogHead too
-	res, err := stmt.ExecContext(ctx, m.treeID, r.TimestampNanos, r.RootHash, r.Revision, root.Signature, r.Metadata)
+	res, err := stmt.ExecContext(ctx, m.treeID, r.TimestampNanos, r.RootHash, r.Revision, root.Signature, r.Metadata, additionalSignatures)
 
 	if err != nil {
 		glog.Warningf("Failed to store signed map root: %s", err)
 	}
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		return err
+	}
+
+	return m.storeRecoveryMarker(ctx, int64(r.Revision))
+}
 
-	return checkResultOkAndRowCountIs(res, err, 1)
+// lockTrees locks the rows of trees in t. The rows are locked in increasing
+// order of tree ID whatever the order of trees, so that two transactions
+// spanning the same trees can't deadlock.
+func lockTrees(ctx context.Context, t *sql.Tx, trees []*trillian.Tree) error {
+	ids := make([]int64, 0, len(trees))
+	seen := make(map[int64]bool)
+	for _, tree := range trees {
+		if !seen[tree.TreeId] {
+			seen[tree.TreeId] = true
+			ids = append(ids, tree.TreeId)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		var got int64
+		if err := t.QueryRowContext(ctx, lockTreeSQL, id).Scan(&got); err != nil {
+			glog.Warningf("Could not lock tree %d: %s", id, err)
+			return err
+		}
+	}
+	return nil
 }
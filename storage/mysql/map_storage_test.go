@@ -21,12 +21,15 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/google/trillian"
 	"github.com/google/trillian/examples/ct/ctmapper/ctmapperpb"
 	"github.com/google/trillian/integration/storagetest"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
 	"github.com/google/trillian/storage/testdb"
 	"github.com/google/trillian/testonly"
 	"github.com/google/trillian/types"
@@ -129,6 +132,76 @@ func TestMapReadWriteTransaction(t *testing.T) {
 	}
 }
 
+func TestMapReadWriteMultiTransactionLockOrder(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	trees := []*trillian.Tree{
+		createInitializedMapForTests(ctx, t, s, as),
+		createInitializedMapForTests(ctx, t, s, as),
+	}
+	reversed := []*trillian.Tree{trees[1], trees[0]}
+
+	// Concurrent transactions over the same maps in different orders must
+	// serialize rather than deadlock.
+	const writes = 5
+	errs := make(chan error, 2*writes)
+	for _, order := range [][]*trillian.Tree{trees, reversed} {
+		go func(order []*trillian.Tree) {
+			for i := 0; i < writes; i++ {
+				errs <- s.ReadWriteMultiTransaction(ctx, order, func(ctx context.Context, txs []storage.MapTreeTX) error {
+					for _, tx := range txs {
+						rev, err := tx.WriteRevision(ctx)
+						if err != nil {
+							return err
+						}
+						root, err := fixedSigner.SignMapRoot(&types.MapRootV1{
+							RootHash:       []byte("rootHash"),
+							TimestampNanos: uint64(time.Unix(rev, 0).UnixNano()),
+							Revision:       uint64(rev),
+						})
+						if err != nil {
+							return err
+						}
+						if err := tx.StoreSignedMapRoot(ctx, root); err != nil {
+							return err
+						}
+						time.Sleep(10 * time.Millisecond)
+					}
+					return nil
+				})
+			}
+		}(order)
+	}
+	for i := 0; i < 2*writes; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("ReadWriteMultiTransaction(): %v", err)
+		}
+	}
+
+	for _, tree := range trees {
+		tx, err := s.SnapshotForTree(ctx, tree)
+		if err != nil {
+			t.Fatalf("SnapshotForTree(): %v", err)
+		}
+		root, err := tx.LatestSignedMapRoot(ctx)
+		if err != nil {
+			t.Fatalf("LatestSignedMapRoot(): %v", err)
+		}
+		tx.Close()
+		var mapRoot types.MapRootV1
+		if err := mapRoot.UnmarshalBinary(root.MapRoot); err != nil {
+			t.Fatalf("UnmarshalBinary(): %v", err)
+		}
+		if got, want := mapRoot.Revision, uint64(2*writes); got != want {
+			t.Errorf("map %d: Revision = %d, want %d", tree.TreeId, got, want)
+		}
+	}
+}
+
 func TestMapRootUpdate(t *testing.T) {
 	testdb.SkipIfNoMySQL(t)
 
@@ -534,6 +607,288 @@ func TestReadOnlyMapTX_Rollback(t *testing.T) {
 	}
 }
 
+func TestMapSnapshotForTreeOnReplica(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+	ctx := context.Background()
+
+	cleanTestDB(DB)
+	replica, done := openTestDBOrDie()
+	defer done(ctx)
+
+	// The replica lags behind: it only has the revision 0 root, from 1970.
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, NewAdminStorage(DB))
+	createInitializedMapFromTemplate(ctx, t, NewMapStorage(replica), NewAdminStorage(replica), tree)
+	root := MustSignMapRoot(t, &types.MapRootV1{
+		TimestampNanos: uint64(time.Now().UnixNano()),
+		Revision:       1,
+		RootHash:       []byte(dummyHash),
+	})
+	runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+		return tx.StoreSignedMapRoot(ctx, root)
+	})
+
+	for _, tc := range []struct {
+		desc         string
+		maxStaleness time.Duration
+		wantRev      uint64
+	}{
+		{desc: "unlimited", wantRev: 0},
+		{desc: "stale", maxStaleness: time.Hour, wantRev: 1},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := NewMapStorageWithOpts(DB, TreeStorageOptions{Replica: replica, MaxReplicaStaleness: tc.maxStaleness})
+			tx, err := s.SnapshotForTree(ctx, tree)
+			if err != nil {
+				t.Fatalf("SnapshotForTree(): %v", err)
+			}
+			defer tx.Close()
+			smr, err := tx.LatestSignedMapRoot(ctx)
+			if err != nil {
+				t.Fatalf("LatestSignedMapRoot(): %v", err)
+			}
+			var got types.MapRootV1
+			if err := got.UnmarshalBinary(smr.MapRoot); err != nil {
+				t.Fatalf("UnmarshalBinary(): %v", err)
+			}
+			if got.Revision != tc.wantRev {
+				t.Errorf("LatestSignedMapRoot().Revision = %d, want %d", got.Revision, tc.wantRev)
+			}
+		})
+	}
+}
+
+func TestMapWriteQueue(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, as)
+
+	queued := make([]*storage.QueuedMapWrite, 3)
+	for i := range queued {
+		queued[i] = &storage.QueuedMapWrite{
+			Leaves:         []*trillian.MapLeaf{{Index: keyHash, LeafValue: []byte{byte(i)}}},
+			Metadata:       []byte{byte(i)},
+			QueueTimestamp: time.Unix(0, int64(i)),
+		}
+		if err := s.(storage.MapWriteQueuer).QueueMapWrite(ctx, tree, queued[i]); err != nil {
+			t.Fatalf("QueueMapWrite(): %v", err)
+		}
+	}
+
+	dequeue := func(limit int) []*storage.QueuedMapWrite {
+		t.Helper()
+		var writes []*storage.QueuedMapWrite
+		runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+			var err error
+			writes, err = tx.(storage.MapWriteDequeuer).DequeueMapWrites(ctx, limit)
+			return err
+		})
+		return writes
+	}
+	for _, want := range [][]*storage.QueuedMapWrite{queued[:2], queued[2:], nil} {
+		got := dequeue(2)
+		if len(got) != len(want) {
+			t.Fatalf("DequeueMapWrites(): got %d writes, want %d", len(got), len(want))
+		}
+		for i := range got {
+			if !proto.Equal(got[i].Leaves[0], want[i].Leaves[0]) || !bytes.Equal(got[i].Metadata, want[i].Metadata) || !got[i].QueueTimestamp.Equal(want[i].QueueTimestamp) {
+				t.Errorf("DequeueMapWrites()[%d]=%+v, want %+v", i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestMapRevisionLease(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, as)
+	r := s.(storage.MapRevisionReserver)
+
+	now := time.Unix(100, 0)
+	reserve := func(token string, expiry time.Time) int64 {
+		t.Helper()
+		rev, err := r.ReserveMapRevision(ctx, tree, []byte(token), now, expiry)
+		if err != nil {
+			t.Fatalf("ReserveMapRevision(%q): %v", token, err)
+		}
+		return rev
+	}
+	// Each reservation gets the revision after the last one, until the
+	// earliest expires.
+	if got, want := reserve("a", now.Add(time.Second)), int64(1); got != want {
+		t.Errorf("ReserveMapRevision(a)=%v, want %v", got, want)
+	}
+	if got, want := reserve("b", now.Add(time.Minute)), int64(2); got != want {
+		t.Errorf("ReserveMapRevision(b)=%v, want %v", got, want)
+	}
+
+	lease, err := r.GetMapRevisionLease(ctx, tree, 1, now)
+	if err != nil {
+		t.Fatalf("GetMapRevisionLease(): %v", err)
+	}
+	if lease == nil || string(lease.Token) != "a" || !lease.Expiry.Equal(now.Add(time.Second)) {
+		t.Errorf("GetMapRevisionLease(1)=%+v, want lease of a", lease)
+	}
+
+	now = now.Add(2 * time.Second)
+	if lease, err := r.GetMapRevisionLease(ctx, tree, 1, now); err != nil || lease != nil {
+		t.Errorf("GetMapRevisionLease(1) after expiry=%+v, %v, want nil", lease, err)
+	}
+	if got, want := reserve("c", now.Add(time.Minute)), int64(3); got != want {
+		t.Errorf("ReserveMapRevision(c)=%v, want %v", got, want)
+	}
+}
+
+func TestMapGetIndexesByLeafHash(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+
+	unindexed := createInitializedMapForTests(ctx, t, s, as)
+	runMapTX(ctx, s, unindexed, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+		if _, err := tx.GetIndexesByLeafHash(ctx, 0, []byte("hash")); err != storage.ErrNoLeafHashIndex {
+			t.Errorf("GetIndexesByLeafHash() = %v, want %v", err, storage.ErrNoLeafHashIndex)
+		}
+		return nil
+	})
+
+	settings, err := ptypes.MarshalAny(&storagepb.MapStorageSettings{LeafHashIndex: true})
+	if err != nil {
+		t.Fatalf("MarshalAny(): %v", err)
+	}
+	template := proto.Clone(storageto.MapTree).(*trillian.Tree)
+	template.StorageSettings = settings
+	tree := createInitializedMapFromTemplate(ctx, t, s, as, template)
+
+	key1, key2 := []byte("key1"), []byte("key2")
+	hash1, hash2 := []byte("hash1"), []byte("hash2")
+	for rev, leaves := range [][]*trillian.MapLeaf{
+		1: {{Index: key1, LeafHash: hash1, LeafValue: []byte("a")}, {Index: key2, LeafHash: hash1, LeafValue: []byte("a")}},
+		2: {{Index: key1, LeafHash: hash2, LeafValue: []byte("b")}, {Index: key2}},
+	} {
+		rev, leaves := rev, leaves
+		if len(leaves) == 0 {
+			continue
+		}
+		runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+			tx.(*mapTreeTX).treeTX.writeRevision = int64(rev)
+			for _, l := range leaves {
+				if err := tx.Set(ctx, l.Index, l); err != nil {
+					t.Fatalf("Set(%s): %v", l.Index, err)
+				}
+			}
+			return nil
+		})
+	}
+
+	check := func(desc string) {
+		t.Helper()
+		for _, test := range []struct {
+			revision int64
+			hash     []byte
+			want     [][]byte
+		}{
+			{revision: 1, hash: hash1, want: [][]byte{key1, key2}},
+			{revision: 2, hash: hash1},
+			{revision: 2, hash: hash2, want: [][]byte{key1}},
+		} {
+			runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+				got, err := tx.GetIndexesByLeafHash(ctx, test.revision, test.hash)
+				if err != nil {
+					t.Fatalf("%s: GetIndexesByLeafHash(%d, %s): %v", desc, test.revision, test.hash, err)
+				}
+				if diff := pretty.Compare(got, test.want); diff != "" {
+					t.Errorf("%s: GetIndexesByLeafHash(%d, %s) diff:\n%s", desc, test.revision, test.hash, diff)
+				}
+				return nil
+			})
+		}
+	}
+	check("before SetLeafHash")
+
+	// Recomputing a leaf hash moves the leaf to the new hash.
+	runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+		return tx.SetLeafHash(ctx, 2, key1, []byte("hash3"))
+	})
+	runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+		got, err := tx.GetIndexesByLeafHash(ctx, 2, []byte("hash3"))
+		if err != nil {
+			t.Fatalf("GetIndexesByLeafHash(): %v", err)
+		}
+		if diff := pretty.Compare(got, [][]byte{key1}); diff != "" {
+			t.Errorf("GetIndexesByLeafHash() after SetLeafHash diff:\n%s", diff)
+		}
+		return tx.SetLeafHash(ctx, 2, key1, hash2)
+	})
+
+	runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+		return tx.DeleteRevisionsBefore(ctx, 2)
+	})
+	var count int
+	if err := DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM MapLeafHash WHERE TreeId=?", tree.TreeId).Scan(&count); err != nil {
+		t.Fatalf("QueryRow(): %v", err)
+	}
+	if got, want := count, 1; got != want {
+		t.Errorf("DeleteRevisionsBefore() left %d leaf hashes, want %d", got, want)
+	}
+}
+
+func TestMapDeleteRevisionsBeforeInBatches(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+
+	defer func(size int) { gcBatchSize = size }(gcBatchSize)
+	gcBatchSize = 2
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, as)
+
+	// Three keys have a version at each of revisions 1 to 4, so each key's
+	// versions span pages.
+	for rev := int64(1); rev <= 4; rev++ {
+		runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+			tx.(*mapTreeTX).treeTX.writeRevision = rev
+			for _, key := range []string{"key1", "key2", "key3"} {
+				if err := tx.Set(ctx, []byte(key), &trillian.MapLeaf{Index: []byte(key), LeafValue: []byte(fmt.Sprint(rev))}); err != nil {
+					t.Fatalf("Set(%s): %v", key, err)
+				}
+			}
+			return nil
+		})
+	}
+
+	runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+		return tx.DeleteRevisionsBefore(ctx, 3)
+	})
+	// The versions at revisions 1 and 2 are superseded at revision 3.
+	var count int
+	if err := DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM MapLeaf WHERE TreeId=? AND MapRevision<3", tree.TreeId).Scan(&count); err != nil {
+		t.Fatalf("QueryRow(): %v", err)
+	}
+	if count != 0 {
+		t.Errorf("DeleteRevisionsBefore() left %d superseded leaf versions, want 0", count)
+	}
+	if err := DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM MapLeaf WHERE TreeId=?", tree.TreeId).Scan(&count); err != nil {
+		t.Fatalf("QueryRow(): %v", err)
+	}
+	if got, want := count, 6; got != want {
+		t.Errorf("DeleteRevisionsBefore() left %d leaf versions, want %d", got, want)
+	}
+}
+
 func runMapTX(ctx context.Context, s storage.MapStorage, tree *trillian.Tree, t *testing.T, f storage.MapTXFunc) {
 	if err := s.ReadWriteTransaction(ctx, tree, f); err != nil {
 		t.Fatalf("Failed to begin map tx: %v", err)
@@ -542,7 +897,14 @@ func runMapTX(ctx context.Context, s storage.MapStorage, tree *trillian.Tree, t
 
 func createInitializedMapForTests(ctx context.Context, t *testing.T, s storage.MapStorage, as storage.AdminStorage) *trillian.Tree {
 	t.Helper()
-	tree := mustCreateTree(ctx, t, as, storageto.MapTree)
+	return createInitializedMapFromTemplate(ctx, t, s, as, storageto.MapTree)
+}
+
+// createInitializedMapFromTemplate creates a map like template, and stores
+// its revision 0 root.
+func createInitializedMapFromTemplate(ctx context.Context, t *testing.T, s storage.MapStorage, as storage.AdminStorage, template *trillian.Tree) *trillian.Tree {
+	t.Helper()
+	tree := mustCreateTree(ctx, t, as, template)
 
 	signer := tcrypto.NewSigner(tree.TreeId, testonly.NewSignerWithFixedSig(nil, []byte("sig")), crypto.SHA256)
 	err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
@@ -0,0 +1,103 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+)
+
+const (
+	insertMapWriteSQL = `INSERT INTO MapWriteQueue(TreeId, QueueTimestampNanos, Leaves, Metadata)
+		 VALUES(?, ?, ?, ?)`
+	// selectMapWritesSQL locks the rows it returns, so that concurrent merges
+	// of the same map wait for each other rather than merging a write twice.
+	selectMapWritesSQL = `SELECT QueueId, QueueTimestampNanos, Leaves, Metadata
+		 FROM MapWriteQueue WHERE TreeId=?
+		 ORDER BY QueueId LIMIT ? FOR UPDATE`
+	deleteMapWritesSQL = `DELETE FROM MapWriteQueue WHERE TreeId=? AND QueueId IN (` + placeholderSQL + `)`
+)
+
+// QueueMapWrite implements storage.MapWriteQueuer.
+func (m *mySQLMapStorage) QueueMapWrite(ctx context.Context, tree *trillian.Tree, w *storage.QueuedMapWrite) error {
+	leaves, err := proto.Marshal(&trillian.MapLeaves{Leaves: w.Leaves})
+	if err != nil {
+		return err
+	}
+	if _, err := m.db.ExecContext(ctx, m.tag(ctx, tree.TreeId, insertMapWriteSQL), tree.TreeId, w.QueueTimestamp.UnixNano(), leaves, w.Metadata); err != nil {
+		glog.Warningf("Failed to queue map write: %s", err)
+		return err
+	}
+	return nil
+}
+
+// DequeueMapWrites implements storage.MapWriteDequeuer.
+func (m *mapTreeTX) DequeueMapWrites(ctx context.Context, limit int) ([]*storage.QueuedMapWrite, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	writes, ids, err := m.readMapWrites(ctx, limit)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	stx, err := m.stmt(ctx, deleteMapWritesSQL, len(ids), "?", "?")
+	if err != nil {
+		return nil, err
+	}
+	defer stx.Close()
+	args := append([]interface{}{m.treeID}, ids...)
+	if _, err := stx.ExecContext(ctx, args...); err != nil {
+		glog.Warningf("Failed to delete queued map writes: %s", err)
+		return nil, err
+	}
+	return writes, nil
+}
+
+// readMapWrites returns up to limit of the oldest queued writes, and their IDs.
+func (m *mapTreeTX) readMapWrites(ctx context.Context, limit int) ([]*storage.QueuedMapWrite, []interface{}, error) {
+	rows, err := m.tx.QueryContext(ctx, m.tag(ctx, selectMapWritesSQL), m.treeID, limit)
+	if err != nil {
+		glog.Warningf("Failed to read queued map writes: %s", err)
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var writes []*storage.QueuedMapWrite
+	var ids []interface{}
+	for rows.Next() {
+		var id, ts int64
+		var leavesBytes, metadata []byte
+		if err := rows.Scan(&id, &ts, &leavesBytes, &metadata); err != nil {
+			return nil, nil, err
+		}
+		var leaves trillian.MapLeaves
+		if err := proto.Unmarshal(leavesBytes, &leaves); err != nil {
+			return nil, nil, err
+		}
+		writes = append(writes, &storage.QueuedMapWrite{
+			Leaves:         leaves.Leaves,
+			Metadata:       metadata,
+			QueueTimestamp: time.Unix(0, ts),
+		})
+		ids = append(ids, id)
+	}
+	return writes, ids, rows.Err()
+}
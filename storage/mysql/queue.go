@@ -93,7 +93,7 @@ func (t *logTreeTX) UpdateSequencedLeaves(ctx context.Context, leaves []*trillia
 		}
 		_, err = t.tx.ExecContext(
 			ctx,
-			insertSequencedLeafSQL+valuesPlaceholder5,
+			t.tag(ctx, insertSequencedLeafSQL+valuesPlaceholder5),
 			t.treeID,
 			leaf.LeafIdentityHash,
 			leaf.MerkleLeafHash,
@@ -114,7 +114,7 @@ func (t *logTreeTX) removeSequencedLeaves(ctx context.Context, leaves []dequeued
 	// Don't need to re-sort because the query ordered by leaf hash. If that changes because
 	// the query is expensive then the sort will need to be done here. See comment in
 	// QueueLeaves.
-	stx, err := t.tx.PrepareContext(ctx, deleteUnsequencedSQL)
+	stx, err := t.tx.PrepareContext(ctx, t.tag(ctx, deleteUnsequencedSQL))
 	if err != nil {
 		glog.Warningf("Failed to prep delete statement for sequenced work: %v", err)
 		return err
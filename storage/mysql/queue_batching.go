@@ -103,29 +103,25 @@ func (t *logTreeTX) UpdateSequencedLeaves(ctx context.Context, leaves []*trillia
 		querySuffix = append(querySuffix, valuesPlaceholder5)
 		args = append(args, t.treeID, leaf.LeafIdentityHash, leaf.MerkleLeafHash, leaf.LeafIndex, iTimestamp.UnixNano())
 	}
-	result, err := t.tx.ExecContext(ctx, insertSequencedLeafSQL+strings.Join(querySuffix, ","), args...)
+	result, err := t.tx.ExecContext(ctx, t.tag(ctx, insertSequencedLeafSQL+strings.Join(querySuffix, ",")), args...)
 	if err != nil {
 		glog.Warningf("Failed to update sequenced leaves: %s", err)
 	}
 	return checkResultOkAndRowCountIs(result, err, int64(len(leaves)))
 }
 
-func (m *mySQLLogStorage) getDeleteUnsequencedStmt(ctx context.Context, num int) (*sql.Stmt, error) {
-	return m.getStmt(ctx, deleteUnsequencedSQL, num, "?", "?")
-}
-
 // removeSequencedLeaves removes the passed in leaves slice (which may be
 // modified as part of the operation).
 func (t *logTreeTX) removeSequencedLeaves(ctx context.Context, queueIDs []dequeuedLeaf) error {
 	// Don't need to re-sort because the query ordered by leaf hash. If that changes because
 	// the query is expensive then the sort will need to be done here. See comment in
 	// QueueLeaves.
-	tmpl, err := t.ls.getDeleteUnsequencedStmt(ctx, len(queueIDs))
+	stx, err := t.stmt(ctx, deleteUnsequencedSQL, len(queueIDs), "?", "?")
 	if err != nil {
 		glog.Warningf("Failed to get delete statement for sequenced work: %s", err)
 		return err
 	}
-	stx := t.tx.StmtContext(ctx, tmpl)
+	defer stx.Close()
 	args := make([]interface{}, len(queueIDs))
 	for i, q := range queueIDs {
 		args[i] = []byte(q)
@@ -0,0 +1,181 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	insertRecoveryMarkerSQL = `INSERT INTO RecoveryMarker(TreeId, Revision, Position) VALUES(?, ?, '')`
+	updateRecoveryMarkerSQL = `UPDATE RecoveryMarker SET Position=? WHERE TreeId=? AND Revision=?`
+	selectRecoveryMarkerSQL = `SELECT Revision, Position FROM RecoveryMarker
+		 WHERE TreeId=? AND Revision=?`
+	selectLatestRecoveryMarkerSQL = `SELECT Revision, Position FROM RecoveryMarker
+		 WHERE TreeId=? ORDER BY Revision DESC LIMIT 1`
+	deleteRecoveryMarkersBeforeSQL = `DELETE FROM RecoveryMarker WHERE TreeId=? AND Revision<?`
+
+	errNumParse        = 1064
+	errNumAccessDenied = 1227
+)
+
+// binlogStatusSQLs are the statements which return the binary log position,
+// in the order they are tried: MySQL 8.4 removed SHOW MASTER STATUS in favour
+// of SHOW BINARY LOG STATUS, which MySQL before 8.2 and MariaDB don't support.
+var binlogStatusSQLs = []string{`SHOW BINARY LOG STATUS`, `SHOW MASTER STATUS`}
+
+// binlogStatus caches how to read the binary log position of a database.
+type binlogStatus struct {
+	mu sync.Mutex
+	// query is the statement of binlogStatusSQLs the database supports, or
+	// "" until one has succeeded.
+	query string
+	// unavailable is set once the position is known not to be readable,
+	// because binary logging is disabled or the user lacks the privileges.
+	unavailable bool
+}
+
+// storeRecoveryMarker stores a recovery marker, with an empty position, for
+// the root at revision, so that the marker and the root are committed
+// together. The position is set by recordRecoveryMarker once the transaction
+// has committed. The caller must hold t.mu.
+func (t *treeTX) storeRecoveryMarker(ctx context.Context, revision int64) error {
+	if _, err := t.tx.ExecContext(ctx, t.tag(ctx, insertRecoveryMarkerSQL), t.treeID, revision); err != nil {
+		glog.Warningf("Failed to store recovery marker: %s", err)
+		return err
+	}
+	t.markerPending, t.markerRevision = true, revision
+	return nil
+}
+
+// recordRecoveryMarker sets the position of the recovery marker stored by t,
+// which must have committed, to the current binary log position. That is at
+// or after the commit, so a database restored up to the position contains the
+// root. The marker keeps an empty position if binary logging is disabled, the
+// database user lacks the privileges needed to read it, or it can't be set.
+func (t *treeTX) recordRecoveryMarker(ctx context.Context) {
+	if !t.markerPending {
+		return
+	}
+	t.markerPending = false
+	position, err := t.ts.binlogPosition(ctx)
+	if err != nil {
+		glog.V(1).Infof("Failed to read binary log position: %v", err)
+		return
+	}
+	if position == "" {
+		return
+	}
+	if _, err := t.ts.db.ExecContext(ctx, t.tag(ctx, updateRecoveryMarkerSQL), position, t.treeID, t.markerRevision); err != nil {
+		glog.Warningf("Failed to record recovery marker position: %s", err)
+	}
+}
+
+// binlogPosition returns the current binary log position as "file:position",
+// or "" if it is unavailable. Once the position is known to be unavailable it
+// is not queried again, so that root writes don't pay for it.
+func (m *mySQLTreeStorage) binlogPosition(ctx context.Context) (string, error) {
+	m.binlog.mu.Lock()
+	query, unavailable := m.binlog.query, m.binlog.unavailable
+	m.binlog.mu.Unlock()
+	if unavailable {
+		return "", nil
+	}
+	queries := binlogStatusSQLs
+	if query != "" {
+		queries = []string{query}
+	}
+	for _, query := range queries {
+		position, err := queryBinlogPosition(ctx, m.db, query)
+		if isMySQLErr(err, errNumParse) {
+			continue
+		}
+		m.binlog.mu.Lock()
+		defer m.binlog.mu.Unlock()
+		if err != nil {
+			m.binlog.unavailable = isMySQLErr(err, errNumAccessDenied)
+			return "", err
+		}
+		m.binlog.query = query
+		m.binlog.unavailable = position == ""
+		return position, nil
+	}
+	return "", fmt.Errorf("none of %q is supported", binlogStatusSQLs)
+}
+
+// queryBinlogPosition returns the binary log position returned by query, or
+// "" if binary logging is disabled.
+func queryBinlogPosition(ctx context.Context, db *sql.DB, query string) (string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", rows.Err()
+	}
+	// The set of columns differs between MySQL and MariaDB versions, but the
+	// first two are always the file and position.
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	if len(cols) < 2 {
+		return "", fmt.Errorf("unexpected %s columns: %v", query, cols)
+	}
+	vals := make([]sql.RawBytes, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%s", vals[0], vals[1]), nil
+}
+
+// GetRecoveryMarker implements storage.RecoveryMarkerReader.
+func (t *treeTX) GetRecoveryMarker(ctx context.Context, revision int64) (*storage.RecoveryMarker, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.readRecoveryMarker(ctx, selectRecoveryMarkerSQL, t.treeID, revision)
+}
+
+// LatestRecoveryMarker implements storage.RecoveryMarkerReader.
+func (t *treeTX) LatestRecoveryMarker(ctx context.Context) (*storage.RecoveryMarker, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.readRecoveryMarker(ctx, selectLatestRecoveryMarkerSQL, t.treeID)
+}
+
+func (t *treeTX) readRecoveryMarker(ctx context.Context, query string, args ...interface{}) (*storage.RecoveryMarker, error) {
+	m := &storage.RecoveryMarker{TreeID: t.treeID}
+	err := t.tx.QueryRowContext(ctx, t.tag(ctx, query), args...).Scan(&m.Revision, &m.Position)
+	if err == sql.ErrNoRows {
+		return nil, status.Errorf(codes.NotFound, "no recovery marker for tree %d", t.treeID)
+	} else if err != nil {
+		glog.Warningf("Failed to read recovery marker: %s", err)
+		return nil, err
+	}
+	return m, nil
+}
@@ -8,7 +8,7 @@
 -- render the data in the tree unusable or inconsistent.
 CREATE TABLE IF NOT EXISTS Trees(
   TreeId                BIGINT NOT NULL,
-  TreeState             ENUM('ACTIVE', 'FROZEN', 'DRAINING') NOT NULL,
+  TreeState             ENUM('ACTIVE', 'FROZEN', 'DRAINING', 'QUARANTINED', 'PAUSED') NOT NULL,
   TreeType              ENUM('LOG', 'MAP', 'PREORDERED_LOG') NOT NULL,
   HashStrategy          ENUM('RFC6962_SHA256', 'TEST_MAP_HASHER', 'OBJECT_RFC6962_SHA256', 'CONIKS_SHA512_256', 'CONIKS_SHA256') NOT NULL,
   HashAlgorithm         ENUM('SHA256') NOT NULL,
@@ -22,6 +22,31 @@ CREATE TABLE IF NOT EXISTS Trees(
   PublicKey             MEDIUMBLOB NOT NULL,
   Deleted               BOOLEAN,
   DeleteTimeMillis      BIGINT,
+  -- Revision retention policy of maps. Zero values mean no limit.
+  RetainRevisions       BIGINT NOT NULL DEFAULT 0,
+  RetainDurationMillis  BIGINT NOT NULL DEFAULT 0,
+  -- Marshalled google.protobuf.Any holding the storage_settings of the tree.
+  StorageSettings       MEDIUMBLOB,
+  -- Number of bits of the indices of a map. Zero means the hash length.
+  MapIndexBits          INT NOT NULL DEFAULT 0,
+  -- Number of the trillian.DuplicateLeafPolicy of a log.
+  DuplicateLeafPolicy   INT NOT NULL DEFAULT 0,
+  -- Marshalled storagepb.AdditionalKeys holding the keys which also sign the
+  -- roots of the tree, if any.
+  AdditionalKeys        MEDIUMBLOB,
+  -- Number of keys whose signatures roots need. Zero means all of them.
+  SignatureThreshold    INT NOT NULL DEFAULT 0,
+  -- Marshalled storagepb.TreeLabels holding the labels of the tree, if any.
+  Labels                MEDIUMBLOB,
+  -- Period the tree is kept after being soft-deleted. Zero means the default.
+  DeleteRetentionMillis BIGINT NOT NULL DEFAULT 0,
+  -- Marshalled trillian.KeyRotation of the tree, if it is rotating its key.
+  KeyRotation           MEDIUMBLOB,
+  -- Rates of the trillian.TreeQuota of the tree. Zero means unlimited.
+  ReadTokensPerSecond   BIGINT NOT NULL DEFAULT 0,
+  WriteTokensPerSecond  BIGINT NOT NULL DEFAULT 0,
+  -- Principal which owns the tree, or empty if it has no owner.
+  Owner                 VARCHAR(255) NOT NULL DEFAULT '',
   PRIMARY KEY(TreeId)
 );
 
@@ -56,6 +81,8 @@ CREATE TABLE IF NOT EXISTS TreeHead(
   RootHash             VARBINARY(255) NOT NULL,
   RootSignature        VARBINARY(1024) NOT NULL,
   TreeRevision         BIGINT,
+  -- Marshalled storagepb.RootSignatures by the additional keys of the tree.
+  AdditionalSignatures MEDIUMBLOB,
   PRIMARY KEY(TreeId, TreeHeadTimestamp),
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
 );
@@ -63,6 +90,32 @@ CREATE TABLE IF NOT EXISTS TreeHead(
 CREATE UNIQUE INDEX TreeHeadRevisionIdx
   ON TreeHead(TreeId, TreeRevision);
 
+-- Used to check that root hashes were published by a log.
+CREATE INDEX TreeHeadRootHashIdx
+  ON TreeHead(TreeId, RootHash);
+
+-- Signatures of third-party witnesses over log roots, at most one per public
+-- key and revision. KeyHash is the SHA-256 hash of PublicKey.
+CREATE TABLE IF NOT EXISTS LogRootSignature(
+  TreeId               BIGINT NOT NULL,
+  TreeRevision         BIGINT NOT NULL,
+  KeyHash              VARBINARY(32) NOT NULL,
+  PublicKey            BLOB NOT NULL,
+  Signature            BLOB NOT NULL,
+  PRIMARY KEY(TreeId, TreeRevision, KeyHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- A recovery marker is stored in the same transaction as each log or map root,
+-- recording the binary log position at which the root was published.
+CREATE TABLE IF NOT EXISTS RecoveryMarker(
+  TreeId               BIGINT NOT NULL,
+  Revision             BIGINT NOT NULL,
+  Position             VARCHAR(255) NOT NULL,
+  PRIMARY KEY(TreeId, Revision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
 -- ---------------------------------------------
 -- Log specific stuff here
 -- ---------------------------------------------
@@ -153,6 +206,17 @@ CREATE TABLE IF NOT EXISTS MapLeaf(
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
 );
 
+-- Lookup from leaf hashes to the versions of the map leaves which have them.
+-- Only written for maps with leaf_hash_index set in their storage_settings.
+CREATE TABLE IF NOT EXISTS MapLeafHash(
+  TreeId                BIGINT NOT NULL,
+  LeafHash              VARBINARY(255) NOT NULL,
+  KeyHash               VARBINARY(255) NOT NULL,
+  MapRevision           BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, LeafHash, KeyHash, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
 
 CREATE TABLE IF NOT EXISTS MapHead(
   TreeId               BIGINT NOT NULL,
@@ -161,9 +225,67 @@ CREATE TABLE IF NOT EXISTS MapHead(
   MapRevision          BIGINT,
   RootSignature        VARBINARY(1024) NOT NULL,
   MapperData           MEDIUMBLOB,
+  -- Marshalled storagepb.RootSignatures by the additional keys of the tree.
+  AdditionalSignatures MEDIUMBLOB,
   PRIMARY KEY(TreeId, MapHeadTimestamp),
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
 );
 
 CREATE UNIQUE INDEX MapHeadRevisionIdx
   ON MapHead(TreeId, MapRevision);
+
+-- Idempotency tokens of map writes, so that retried writes can be detected.
+CREATE TABLE IF NOT EXISTS MapIdempotencyToken(
+  TreeId               BIGINT NOT NULL,
+  Token                VARBINARY(255) NOT NULL,
+  MapRevision          BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, Token),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- Signatures of third-party witnesses over map roots, at most one per public
+-- key and revision. KeyHash is the SHA-256 hash of PublicKey.
+CREATE TABLE IF NOT EXISTS MapRootSignature(
+  TreeId               BIGINT NOT NULL,
+  MapRevision          BIGINT NOT NULL,
+  KeyHash              VARBINARY(32) NOT NULL,
+  PublicKey            BLOB NOT NULL,
+  Signature            BLOB NOT NULL,
+  PRIMARY KEY(TreeId, MapRevision, KeyHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- Map writes queued by WriteLeaves, until the map merger folds them into a
+-- new revision of the map.
+CREATE TABLE IF NOT EXISTS MapWriteQueue(
+  TreeId               BIGINT NOT NULL,
+  QueueId              BIGINT NOT NULL AUTO_INCREMENT,
+  QueueTimestampNanos  BIGINT NOT NULL,
+  -- Leaves holds a serialized trillian.MapLeaves message.
+  Leaves               LONGBLOB NOT NULL,
+  Metadata             MEDIUMBLOB,
+  PRIMARY KEY(QueueId),
+  INDEX(TreeId, QueueId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- Write revisions of maps reserved by ReserveRevision, until their lease
+-- expires or the revision is written.
+CREATE TABLE IF NOT EXISTS MapRevisionLease(
+  TreeId               BIGINT NOT NULL,
+  Revision             BIGINT NOT NULL,
+  Token                VARBINARY(255) NOT NULL,
+  ExpiryNanos          BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, Revision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- The version of this schema, which trillian_migrate upgrades. Databases
+-- created before schemas were versioned lack this table, and have to be
+-- baselined with trillian_migrate.
+CREATE TABLE IF NOT EXISTS SchemaVersion(
+  Version              INTEGER NOT NULL
+);
+
+INSERT INTO SchemaVersion(Version)
+  SELECT 2 FROM DUAL WHERE NOT EXISTS (SELECT * FROM SchemaVersion);
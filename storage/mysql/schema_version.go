@@ -0,0 +1,137 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import "github.com/google/trillian/storage/migrate"
+
+// Schema is the history of the MySQL schema. New databases are created at
+// its latest version by schema/storage.sql, which has to be kept in step.
+var Schema = migrate.Schema{
+	Table:     "SchemaVersion",
+	ExistsSQL: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'SchemaVersion'",
+	Steps: []migrate.Step{
+		{Version: 1, Description: "baseline"},
+		{
+			Version:     2,
+			Description: "tree settings, root signatures, recovery markers and map write tables",
+			Up: []string{
+				`ALTER TABLE Trees
+  MODIFY TreeState ENUM('ACTIVE', 'FROZEN', 'DRAINING', 'QUARANTINED', 'PAUSED') NOT NULL,
+  ADD COLUMN RetainRevisions BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN RetainDurationMillis BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN StorageSettings MEDIUMBLOB,
+  ADD COLUMN MapIndexBits INT NOT NULL DEFAULT 0,
+  ADD COLUMN DuplicateLeafPolicy INT NOT NULL DEFAULT 0,
+  ADD COLUMN AdditionalKeys MEDIUMBLOB,
+  ADD COLUMN SignatureThreshold INT NOT NULL DEFAULT 0,
+  ADD COLUMN Labels MEDIUMBLOB,
+  ADD COLUMN DeleteRetentionMillis BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN KeyRotation MEDIUMBLOB,
+  ADD COLUMN ReadTokensPerSecond BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN WriteTokensPerSecond BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN Owner VARCHAR(255) NOT NULL DEFAULT ''`,
+				`ALTER TABLE TreeHead ADD COLUMN AdditionalSignatures MEDIUMBLOB`,
+				`CREATE INDEX TreeHeadRootHashIdx ON TreeHead(TreeId, RootHash)`,
+				`CREATE TABLE LogRootSignature(
+  TreeId               BIGINT NOT NULL,
+  TreeRevision         BIGINT NOT NULL,
+  KeyHash              VARBINARY(32) NOT NULL,
+  PublicKey            BLOB NOT NULL,
+  Signature            BLOB NOT NULL,
+  PRIMARY KEY(TreeId, TreeRevision, KeyHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+				`CREATE TABLE RecoveryMarker(
+  TreeId               BIGINT NOT NULL,
+  Revision             BIGINT NOT NULL,
+  Position             VARCHAR(255) NOT NULL,
+  PRIMARY KEY(TreeId, Revision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+				`CREATE TABLE MapLeafHash(
+  TreeId                BIGINT NOT NULL,
+  LeafHash              VARBINARY(255) NOT NULL,
+  KeyHash               VARBINARY(255) NOT NULL,
+  MapRevision           BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, LeafHash, KeyHash, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+				`ALTER TABLE MapHead ADD COLUMN AdditionalSignatures MEDIUMBLOB`,
+				`CREATE TABLE MapIdempotencyToken(
+  TreeId               BIGINT NOT NULL,
+  Token                VARBINARY(255) NOT NULL,
+  MapRevision          BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, Token),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+				`CREATE TABLE MapRootSignature(
+  TreeId               BIGINT NOT NULL,
+  MapRevision          BIGINT NOT NULL,
+  KeyHash              VARBINARY(32) NOT NULL,
+  PublicKey            BLOB NOT NULL,
+  Signature            BLOB NOT NULL,
+  PRIMARY KEY(TreeId, MapRevision, KeyHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+				`CREATE TABLE MapWriteQueue(
+  TreeId               BIGINT NOT NULL,
+  QueueId              BIGINT NOT NULL AUTO_INCREMENT,
+  QueueTimestampNanos  BIGINT NOT NULL,
+  Leaves               LONGBLOB NOT NULL,
+  Metadata             MEDIUMBLOB,
+  PRIMARY KEY(QueueId),
+  INDEX(TreeId, QueueId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+				`CREATE TABLE MapRevisionLease(
+  TreeId               BIGINT NOT NULL,
+  Revision             BIGINT NOT NULL,
+  Token                VARBINARY(255) NOT NULL,
+  ExpiryNanos          BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, Revision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+			},
+			// Downgrading fails if any tree is QUARANTINED or PAUSED.
+			Down: []string{
+				`DROP TABLE MapRevisionLease`,
+				`DROP TABLE MapWriteQueue`,
+				`DROP TABLE MapRootSignature`,
+				`DROP TABLE MapIdempotencyToken`,
+				`ALTER TABLE MapHead DROP COLUMN AdditionalSignatures`,
+				`DROP TABLE MapLeafHash`,
+				`DROP TABLE RecoveryMarker`,
+				`DROP TABLE LogRootSignature`,
+				`DROP INDEX TreeHeadRootHashIdx ON TreeHead`,
+				`ALTER TABLE TreeHead DROP COLUMN AdditionalSignatures`,
+				`ALTER TABLE Trees
+  DROP COLUMN Owner,
+  DROP COLUMN WriteTokensPerSecond,
+  DROP COLUMN ReadTokensPerSecond,
+  DROP COLUMN KeyRotation,
+  DROP COLUMN DeleteRetentionMillis,
+  DROP COLUMN Labels,
+  DROP COLUMN SignatureThreshold,
+  DROP COLUMN AdditionalKeys,
+  DROP COLUMN DuplicateLeafPolicy,
+  DROP COLUMN MapIndexBits,
+  DROP COLUMN StorageSettings,
+  DROP COLUMN RetainDurationMillis,
+  DROP COLUMN RetainRevisions,
+  MODIFY TreeState ENUM('ACTIVE', 'FROZEN', 'DRAINING') NOT NULL`,
+			},
+		},
+	},
+}
@@ -30,6 +30,8 @@ import (
 	"github.com/google/trillian/merkle/compact"
 	"github.com/google/trillian/merkle/rfc6962"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/querytag"
+	"github.com/google/trillian/storage/storagepb"
 	"github.com/google/trillian/storage/testdb"
 	stree "github.com/google/trillian/storage/tree"
 	"github.com/google/trillian/testonly"
@@ -98,6 +100,95 @@ func TestNodeRoundTrip(t *testing.T) {
 	}
 }
 
+func TestNodeRoundTripSubtreeCodecs(t *testing.T) {
+	nodes := createSomeNodes(256)
+	nodeIDs := make([]stree.NodeID, len(nodes))
+	for i := range nodes {
+		nodeIDs[i] = nodes[i].NodeID
+	}
+
+	for _, write := range SubtreeCodecs {
+		for _, read := range SubtreeCodecs {
+			t.Run(fmt.Sprintf("%v-%v", write, read), func(t *testing.T) {
+				ctx := context.Background()
+				cleanTestDB(DB)
+				as := NewAdminStorage(DB)
+				tree := mustCreateTree(ctx, t, as, storageto.LogTree)
+
+				const writeRev = int64(100)
+				ws := NewLogStorageWithOpts(DB, nil, TreeStorageOptions{SubtreeCodec: write})
+				runLogTX(ws, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+					forceWriteRevision(writeRev, tx)
+					if _, err := tx.GetMerkleNodes(ctx, writeRev-1, nodeIDs); err != nil {
+						t.Fatalf("Failed to read nodes: %s", err)
+					}
+					if err := tx.SetMerkleNodes(ctx, nodes); err != nil {
+						t.Fatalf("Failed to store nodes: %s", err)
+					}
+					return nil
+				})
+
+				// Subtrees are read regardless of the codec of the reader.
+				rs := NewLogStorageWithOpts(DB, nil, TreeStorageOptions{SubtreeCodec: read})
+				runLogTX(rs, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+					readNodes, err := tx.GetMerkleNodes(ctx, writeRev, nodeIDs)
+					if err != nil {
+						t.Fatalf("Failed to retrieve nodes: %s", err)
+					}
+					if err := nodesAreEqual(readNodes, nodes); err != nil {
+						t.Fatalf("Read back different nodes from the ones stored: %s", err)
+					}
+					return nil
+				})
+			})
+		}
+	}
+}
+
+func TestQueryTags(t *testing.T) {
+	nodes := createSomeNodes(256)
+	nodeIDs := make([]stree.NodeID, len(nodes))
+	for i := range nodes {
+		nodeIDs[i] = nodes[i].NodeID
+	}
+
+	ctx := querytag.WithRPC(context.Background(), "*/ DROP TABLE Trees; /*")
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree := mustCreateTree(ctx, t, as, storageto.LogTree)
+	s := NewLogStorageWithOpts(DB, nil, TreeStorageOptions{QueryTags: true})
+
+	const writeRev = int64(100)
+	if err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		forceWriteRevision(writeRev, tx)
+		if _, err := tx.GetMerkleNodes(ctx, writeRev-1, nodeIDs); err != nil {
+			t.Fatalf("Failed to read nodes: %s", err)
+		}
+		return tx.SetMerkleNodes(ctx, nodes)
+	}); err != nil {
+		t.Fatalf("Failed to store nodes: %s", err)
+	}
+	if err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.LogTreeTX) error {
+		readNodes, err := tx.GetMerkleNodes(ctx, writeRev, nodeIDs)
+		if err != nil {
+			return err
+		}
+		return nodesAreEqual(readNodes, nodes)
+	}); err != nil {
+		t.Fatalf("Failed to read back nodes with query tags: %s", err)
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+	if err := Schema.Validate(); err != nil {
+		t.Fatalf("Validate(): %v", err)
+	}
+	if err := Schema.Check(ctx, DB); err != nil {
+		t.Errorf("Check(): %v", err)
+	}
+}
+
 // This test ensures that node writes cross subtree boundaries so this edge case in the subtree
 // cache gets exercised. Any tree size > 256 will do this.
 func TestLogNodeRoundTripMultiSubtree(t *testing.T) {
@@ -153,6 +244,73 @@ func TestLogNodeRoundTripMultiSubtree(t *testing.T) {
 	}
 }
 
+func TestStoreSubtreesInBatches(t *testing.T) {
+	const count = 7
+	subtrees := make([]*storagepb.SubtreeProto, count)
+	ids := make([]stree.NodeID, count)
+	for i := range subtrees {
+		h := sha256.Sum256([]byte{byte(i)})
+		subtrees[i] = &storagepb.SubtreeProto{
+			Prefix: []byte{byte(i)},
+			Depth:  8,
+			Leaves: map[string][]byte{"CAA=": h[:]},
+		}
+		ids[i] = stree.NewNodeIDFromHash([]byte{byte(i)})
+	}
+	rewritten := make([]*storagepb.SubtreeProto, 2)
+	for i := range rewritten {
+		h := sha256.Sum256([]byte{byte(i), 1})
+		rewritten[i] = &storagepb.SubtreeProto{
+			Prefix: []byte{byte(i)},
+			Depth:  8,
+			Leaves: map[string][]byte{"CAA=": h[:]},
+		}
+	}
+
+	for _, batchSize := range []int{0, 1, 3, count} {
+		t.Run(fmt.Sprintf("batch-%d", batchSize), func(t *testing.T) {
+			ctx := context.Background()
+			cleanTestDB(DB)
+			tree := mustCreateTree(ctx, t, NewAdminStorage(DB), storageto.LogTree)
+			s := NewLogStorageWithOpts(DB, nil, TreeStorageOptions{SubtreeWriteBatchSize: batchSize})
+
+			const writeRev = int64(100)
+			runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+				forceWriteRevision(writeRev, tx)
+				ttx := &tx.(*logTreeTX).treeTX
+				if err := ttx.storeSubtrees(ctx, subtrees); err != nil {
+					t.Fatalf("storeSubtrees(): %v", err)
+				}
+				// Writing subtrees again at the same revision replaces them.
+				if err := ttx.storeSubtrees(ctx, rewritten); err != nil {
+					t.Fatalf("storeSubtrees() again: %v", err)
+				}
+				return nil
+			})
+
+			runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+				got, err := tx.(*logTreeTX).treeTX.getSubtrees(ctx, writeRev, ids)
+				if err != nil {
+					t.Fatalf("getSubtrees(): %v", err)
+				}
+				if len(got) != count {
+					t.Errorf("getSubtrees(): %d subtrees, want %d", len(got), count)
+				}
+				for _, st := range got {
+					want := subtrees[st.Prefix[0]]
+					if int(st.Prefix[0]) < len(rewritten) {
+						want = rewritten[st.Prefix[0]]
+					}
+					if got, want := st.Leaves["CAA="], want.Leaves["CAA="]; !bytes.Equal(got, want) {
+						t.Errorf("subtree %x: leaf hash %x, want %x", st.Prefix, got, want)
+					}
+				}
+				return nil
+			})
+		})
+	}
+}
+
 func forceWriteRevision(rev int64, tx storage.TreeTX) {
 	mtx, ok := tx.(*logTreeTX)
 	if !ok {
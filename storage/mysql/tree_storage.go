@@ -23,20 +23,25 @@ import (
 	"runtime/debug"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/hashers"
 	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/storage/querytag"
+	"github.com/google/trillian/storage/sqlpool"
 	"github.com/google/trillian/storage/storagepb"
+	"github.com/google/trillian/storage/subtreecodec"
 	"github.com/google/trillian/storage/tree"
 )
 
 // These statements are fixed
 const (
-	insertSubtreeMultiSQL = `INSERT INTO Subtree(TreeId, SubtreeId, Nodes, SubtreeRevision) ` + placeholderSQL
-	insertTreeHeadSQL     = `INSERT INTO TreeHead(TreeId,TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature)
-		 VALUES(?,?,?,?,?,?)`
+	insertSubtreeMultiSQL = `INSERT INTO Subtree(TreeId, SubtreeId, Nodes, SubtreeRevision) ` + placeholderSQL + ` ON DUPLICATE KEY UPDATE Nodes=VALUES(Nodes)`
+	insertTreeHeadSQL     = `INSERT INTO TreeHead(TreeId,TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,AdditionalSignatures)
+		 VALUES(?,?,?,?,?,?,?)`
 
 	selectSubtreeSQL = `
  SELECT x.SubtreeId, x.MaxRevision, Subtree.Nodes
@@ -55,17 +60,64 @@ const (
 	placeholderSQL = "<placeholder>"
 )
 
+// SubtreeCodecs are the subtree codecs supported by the MySQL storage.
+var SubtreeCodecs = []subtreecodec.Codec{subtreecodec.Proto, subtreecodec.Packed, subtreecodec.Deflate, subtreecodec.Zstd}
+
+// TreeStorageOptions holds the options of the log and map storage.
+type TreeStorageOptions struct {
+	// SubtreeCodec is the codec with which subtrees are written. Subtrees are
+	// read with whichever codec they were written with, so it can be changed
+	// at any time.
+	SubtreeCodec subtreecodec.Codec
+
+	// QueryTags appends the querytag tags of the context of each query to its
+	// text, so that queries can be attributed to requests in slow query logs.
+	// Statements are then prepared in each transaction rather than cached.
+	QueryTags bool
+
+	// MapHashers provides the hashers of map hash strategies, in addition to
+	// the ones registered with hashers.RegisterMapHasher. It is only used by
+	// map storage.
+	MapHashers hashers.MapHasherRegistry
+
+	// Replica, if set, is a read replica of the database, on which Snapshot
+	// and SnapshotForTree run. Read-write transactions always run on the
+	// primary database.
+	Replica *sql.DB
+
+	// MaxReplicaStaleness, if positive, is how old the latest root of a tree
+	// on Replica may be for SnapshotForTree to use it. Snapshots of trees
+	// whose latest root on Replica is older, or which have no root there yet,
+	// run on the primary database. This relies on trees getting new roots
+	// more often than MaxReplicaStaleness, e.g. with the log signer's
+	// --max_root_duration, as otherwise their snapshots always run on the
+	// primary.
+	MaxReplicaStaleness time.Duration
+
+	// StatementCacheSize, if positive, is the maximum number of prepared
+	// statements cached by the storage, on each of the primary database and
+	// Replica. Least recently used statements are closed to make room for
+	// new ones. The statements are cached without limit otherwise.
+	StatementCacheSize int
+
+	// SubtreeWriteBatchSize, if positive, is the maximum number of subtrees
+	// written by each statement when a transaction commits, rather than
+	// defaultSubtreeWriteBatchSize.
+	SubtreeWriteBatchSize int
+}
+
+// defaultSubtreeWriteBatchSize is how many subtrees are written by each
+// statement by default, which keeps the statements of transactions writing
+// many subtrees well within the placeholder and max_allowed_packet limits.
+const defaultSubtreeWriteBatchSize = 1000
+
 // mySQLTreeStorage is shared between the mySQLLog- and (forthcoming) mySQLMap-
 // Storage implementations, and contains functionality which is common to both,
 type mySQLTreeStorage struct {
-	db *sql.DB
-
-	// Must hold the mutex before manipulating the statement map. Sharing a lock because
-	// it only needs to be held while the statements are built, not while they execute and
-	// this will be a short time. These maps are from the number of placeholder '?'
-	// in the query to the statement that should be used.
-	statementMutex sync.Mutex
-	statements     map[string]map[int]*sql.Stmt
+	db     *sql.DB
+	opts   TreeStorageOptions
+	stmts  *sqlpool.StmtCache
+	binlog binlogStatus
 }
 
 // OpenDB opens a database connection for all MySQL-based storage implementations.
@@ -85,11 +137,27 @@ func OpenDB(dbURL string) (*sql.DB, error) {
 	return db, nil
 }
 
-func newTreeStorage(db *sql.DB) *mySQLTreeStorage {
+func newTreeStorage(db *sql.DB, opts TreeStorageOptions) *mySQLTreeStorage {
 	return &mySQLTreeStorage{
-		db:         db,
-		statements: make(map[string]map[int]*sql.Stmt),
+		db:    db,
+		opts:  opts,
+		stmts: sqlpool.NewStmtCache(db, opts.StatementCacheSize),
+	}
+}
+
+// replicaOpts returns the options of the storage on opts.Replica.
+func replicaOpts(opts TreeStorageOptions) TreeStorageOptions {
+	opts.Replica, opts.MaxReplicaStaleness = nil, 0
+	return opts
+}
+
+// freshOnReplica returns whether a snapshot on the replica whose latest root
+// has the given timestamp is recent enough to be used.
+func (m *mySQLTreeStorage) freshOnReplica(rootTimestampNanos uint64) bool {
+	if m.opts.MaxReplicaStaleness <= 0 {
+		return true
 	}
+	return time.Since(time.Unix(0, int64(rootTimestampNanos))) <= m.opts.MaxReplicaStaleness
 }
 
 // expandPlaceholderSQL expands an sql statement by adding a specified number of '?'
@@ -106,40 +174,26 @@ func expandPlaceholderSQL(sql string, num int, first, rest string) string {
 
 // getStmt creates and caches sql.Stmt structs based on the passed in statement
 // and number of bound arguments.
-// TODO(al,martin): consider pulling this all out as a separate unit for reuse
-// elsewhere.
 func (m *mySQLTreeStorage) getStmt(ctx context.Context, statement string, num int, first, rest string) (*sql.Stmt, error) {
-	m.statementMutex.Lock()
-	defer m.statementMutex.Unlock()
-
-	if m.statements[statement] != nil {
-		if m.statements[statement][num] != nil {
-			// TODO(al,martin): we'll possibly need to expire Stmts from the cache,
-			// e.g. when DB connections break etc.
-			return m.statements[statement][num], nil
-		}
-	} else {
-		m.statements[statement] = make(map[int]*sql.Stmt)
-	}
-
-	s, err := m.db.PrepareContext(ctx, expandPlaceholderSQL(statement, num, first, rest))
-
+	s, err := m.stmts.Prepare(ctx, expandPlaceholderSQL(statement, num, first, rest))
 	if err != nil {
 		glog.Warningf("Failed to prepare statement %d: %s", num, err)
 		return nil, err
 	}
-
-	m.statements[statement][num] = s
-
 	return s, nil
 }
 
-func (m *mySQLTreeStorage) getSubtreeStmt(ctx context.Context, num int) (*sql.Stmt, error) {
-	return m.getStmt(ctx, selectSubtreeSQL, num, "?", "?")
-}
-
-func (m *mySQLTreeStorage) setSubtreeStmt(ctx context.Context, num int) (*sql.Stmt, error) {
-	return m.getStmt(ctx, insertSubtreeMultiSQL, num, "VALUES(?, ?, ?, ?)", "(?, ?, ?, ?)")
+// tag returns query with the tags of ctx appended if QueryTags is set, using
+// treeID if ctx has no tree ID.
+func (m *mySQLTreeStorage) tag(ctx context.Context, treeID int64, query string) string {
+	if !m.opts.QueryTags {
+		return query
+	}
+	tags := querytag.FromContext(ctx)
+	if tags.TreeID == 0 {
+		tags.TreeID = treeID
+	}
+	return tags.Append(query)
 }
 
 func (m *mySQLTreeStorage) beginTreeTx(ctx context.Context, tree *trillian.Tree, hashSizeBytes int, subtreeCache *cache.SubtreeCache) (treeTX, error) {
@@ -148,16 +202,22 @@ func (m *mySQLTreeStorage) beginTreeTx(ctx context.Context, tree *trillian.Tree,
 		glog.Warningf("Could not start tree TX: %s", err)
 		return treeTX{}, err
 	}
+	return m.newTreeTX(t, &sync.Mutex{}, tree, hashSizeBytes, subtreeCache), nil
+}
+
+// newTreeTX returns a treeTX for tree which runs its queries in t. All the
+// treeTXs sharing t must also share mu.
+func (m *mySQLTreeStorage) newTreeTX(t *sql.Tx, mu *sync.Mutex, tree *trillian.Tree, hashSizeBytes int, subtreeCache *cache.SubtreeCache) treeTX {
 	return treeTX{
 		tx:            t,
-		mu:            &sync.Mutex{},
+		mu:            mu,
 		ts:            m,
 		treeID:        tree.TreeId,
 		treeType:      tree.TreeType,
 		hashSizeBytes: hashSizeBytes,
 		subtreeCache:  subtreeCache,
 		writeRevision: -1,
-	}, nil
+	}
 }
 
 type treeTX struct {
@@ -171,6 +231,29 @@ type treeTX struct {
 	hashSizeBytes int
 	subtreeCache  *cache.SubtreeCache
 	writeRevision int64
+	// markerPending is set if a recovery marker was stored for the root at
+	// markerRevision, and its position must be recorded after commit.
+	markerPending  bool
+	markerRevision int64
+}
+
+// tag returns query with the tags of ctx appended if QueryTags is set.
+func (t *treeTX) tag(ctx context.Context, query string) string {
+	return t.ts.tag(ctx, t.treeID, query)
+}
+
+// stmt returns a statement for the expanded SQL, for use in t.tx, which the
+// caller must close. The statements are cached unless QueryTags is set, as
+// the tags change the text of each query.
+func (t *treeTX) stmt(ctx context.Context, statement string, num int, first, rest string) (*sql.Stmt, error) {
+	if t.ts.opts.QueryTags {
+		return t.tx.PrepareContext(ctx, t.tag(ctx, expandPlaceholderSQL(statement, num, first, rest)))
+	}
+	tmpl, err := t.ts.getStmt(ctx, statement, num, first, rest)
+	if err != nil {
+		return nil, err
+	}
+	return t.tx.StmtContext(ctx, tmpl), nil
 }
 
 func (t *treeTX) getSubtree(ctx context.Context, treeRevision int64, nodeID tree.NodeID) (*storagepb.SubtreeProto, error) {
@@ -194,11 +277,10 @@ func (t *treeTX) getSubtrees(ctx context.Context, treeRevision int64, nodeIDs []
 		return nil, nil
 	}
 
-	tmpl, err := t.ts.getSubtreeStmt(ctx, len(nodeIDs))
+	stx, err := t.stmt(ctx, selectSubtreeSQL, len(nodeIDs), "?", "?")
 	if err != nil {
 		return nil, err
 	}
-	stx := t.tx.StmtContext(ctx, tmpl)
 	defer stx.Close()
 
 	args := make([]interface{}, 0, len(nodeIDs)+3)
@@ -241,7 +323,7 @@ func (t *treeTX) getSubtrees(ctx context.Context, treeRevision int64, nodeIDs []
 			return nil, err
 		}
 		var subtree storagepb.SubtreeProto
-		if err := proto.Unmarshal(nodesRaw, &subtree); err != nil {
+		if _, err := subtreecodec.Unmarshal(nodesRaw, &subtree); err != nil {
 			glog.Warningf("Failed to unmarshal SubtreeProto: %s", err)
 			return nil, err
 		}
@@ -287,16 +369,35 @@ func (t *treeTX) storeSubtrees(ctx context.Context, subtrees []*storagepb.Subtre
 		return nil
 	}
 
-	// TODO(al): probably need to be able to batch this in the case where we have
-	// a really large number of subtrees to store.
-	args := make([]interface{}, 0, len(subtrees))
+	batchSize := t.ts.opts.SubtreeWriteBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultSubtreeWriteBatchSize
+	}
+	// All batches but the last have the same size, so share a statement.
+	for len(subtrees) > 0 {
+		n := batchSize
+		if n > len(subtrees) {
+			n = len(subtrees)
+		}
+		if err := t.storeSubtreeBatch(ctx, subtrees[:n]); err != nil {
+			return err
+		}
+		subtrees = subtrees[n:]
+	}
+	return nil
+}
+
+// storeSubtreeBatch writes subtrees with a single statement, replacing any
+// already written at the same revision.
+func (t *treeTX) storeSubtreeBatch(ctx context.Context, subtrees []*storagepb.SubtreeProto) error {
+	args := make([]interface{}, 0, 4*len(subtrees))
 
 	for _, s := range subtrees {
 		s := s
 		if s.Prefix == nil {
 			panic(fmt.Errorf("nil prefix on %v", s))
 		}
-		subtreeBytes, err := proto.Marshal(s)
+		subtreeBytes, err := subtreecodec.Marshal(s, t.ts.opts.SubtreeCodec)
 		if err != nil {
 			return err
 		}
@@ -306,11 +407,10 @@ func (t *treeTX) storeSubtrees(ctx context.Context, subtrees []*storagepb.Subtre
 		args = append(args, t.writeRevision)
 	}
 
-	tmpl, err := t.ts.setSubtreeStmt(ctx, len(subtrees))
+	stx, err := t.stmt(ctx, insertSubtreeMultiSQL, len(subtrees), "VALUES(?, ?, ?, ?)", "(?, ?, ?, ?)")
 	if err != nil {
 		return err
 	}
-	stx := t.tx.StmtContext(ctx, tmpl)
 	defer stx.Close()
 
 	r, err := stx.ExecContext(ctx, args...)
@@ -343,6 +443,31 @@ func checkResultOkAndRowCountIs(res sql.Result, err error, count int64) error {
 	return nil
 }
 
+// marshalRootSignatures returns the value stored in the AdditionalSignatures
+// column of a root, which is nil if it has no additional signatures.
+func marshalRootSignatures(sigs [][]byte) ([]byte, error) {
+	if len(sigs) == 0 {
+		return nil, nil
+	}
+	b, err := proto.Marshal(&storagepb.RootSignatures{Signatures: sigs})
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal RootSignatures: %v", err)
+	}
+	return b, nil
+}
+
+// unmarshalRootSignatures parses the AdditionalSignatures column of a root.
+func unmarshalRootSignatures(b []byte) ([][]byte, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	var sigs storagepb.RootSignatures
+	if err := proto.Unmarshal(b, &sigs); err != nil {
+		return nil, fmt.Errorf("could not unmarshal RootSignatures: %v", err)
+	}
+	return sigs.Signatures, nil
+}
+
 // getSubtreesAtRev returns a GetSubtreesFunc which reads at the passed in rev.
 func (t *treeTX) getSubtreesAtRev(ctx context.Context, rev int64) cache.GetSubtreesFunc {
 	return func(ids []tree.NodeID) ([]*storagepb.SubtreeProto, error) {
@@ -378,6 +503,22 @@ func (t *treeTX) Commit(ctx context.Context) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	if err := t.flushSubtrees(ctx); err != nil {
+		return err
+	}
+	t.closed = true
+	t.subtreeCache.Release()
+	if err := t.tx.Commit(); err != nil {
+		glog.Warningf("TX commit error: %s, stack:\n%s", err, string(debug.Stack()))
+		return err
+	}
+	t.recordRecoveryMarker(ctx)
+	return nil
+}
+
+// flushSubtrees writes the subtrees modified by a read-write transaction. The
+// caller must hold t.mu.
+func (t *treeTX) flushSubtrees(ctx context.Context) error {
 	if t.writeRevision > -1 {
 		if err := t.subtreeCache.Flush(ctx, func(ctx context.Context, st []*storagepb.SubtreeProto) error {
 			return t.storeSubtrees(ctx, st)
@@ -386,11 +527,6 @@ func (t *treeTX) Commit(ctx context.Context) error {
 			return err
 		}
 	}
-	t.closed = true
-	if err := t.tx.Commit(); err != nil {
-		glog.Warningf("TX commit error: %s, stack:\n%s", err, string(debug.Stack()))
-		return err
-	}
 	return nil
 }
 
@@ -403,6 +539,7 @@ func (t *treeTX) Rollback() error {
 
 func (t *treeTX) rollbackInternal() error {
 	t.closed = true
+	t.subtreeCache.Release()
 	if err := t.tx.Rollback(); err != nil {
 		glog.Warningf("TX rollback error: %s, stack:\n%s", err, string(debug.Stack()))
 		return err
@@ -24,8 +24,11 @@ import (
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -49,7 +52,20 @@ const (
 		public_key,
 		max_root_duration_millis,
 		deleted,
-		delete_time_millis
+		delete_time_millis,
+		retain_revisions,
+		retain_duration_millis,
+		storage_settings,
+		map_index_bits,
+		duplicate_leaf_policy,
+		additional_keys,
+		signature_threshold,
+		labels,
+		delete_retention_millis,
+		key_rotation,
+		read_tokens_per_second,
+		write_tokens_per_second,
+		owner
 	FROM trees`
 
 	nonDeletedWhere       = " WHERE deleted = false"
@@ -73,8 +89,20 @@ const (
 		update_time_millis,
 		private_key,
 		public_key,
-		max_root_duration_millis)
-	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+		max_root_duration_millis,
+		retain_revisions,
+		retain_duration_millis,
+		storage_settings,
+		map_index_bits,
+		duplicate_leaf_policy,
+		additional_keys,
+		signature_threshold,
+		labels,
+		delete_retention_millis,
+		read_tokens_per_second,
+		write_tokens_per_second,
+		owner)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25)`
 
 	insertTreeControlSQL = `INSERT INTO tree_control(
 		tree_id,
@@ -83,9 +111,11 @@ const (
 		sequence_interval_seconds)
 	VALUES($1, $2, $3, $4)`
 
-	updateTreeSQL = `UPDATE trees SET tree_state = $1, tree_type = $2, display_name = $3, 
-		description = $4, update_time_millis = $5, max_root_duration_millis = $6, private_key = $7
-		WHERE tree_id = $8`
+	updateTreeSQL = `UPDATE trees SET tree_state = $1, tree_type = $2, display_name = $3,
+		description = $4, update_time_millis = $5, max_root_duration_millis = $6, private_key = $7,
+		public_key = $8, retain_revisions = $9, retain_duration_millis = $10, duplicate_leaf_policy = $11, labels = $12,
+		delete_retention_millis = $13, key_rotation = $14, read_tokens_per_second = $15, write_tokens_per_second = $16, owner = $17
+		WHERE tree_id = $18`
 
 	softDeleteSQL = "UPDATE trees SET deleted = $1, delete_time_millis = $2 WHERE tree_id = $3"
 
@@ -98,23 +128,32 @@ const (
 
 // NewAdminStorage returns a storage.AdminStorage implementation
 func NewAdminStorage(db *sql.DB) storage.AdminStorage {
-	return &pgAdminStorage{db}
+	return NewAdminStorageWithOpts(db, TreeStorageOptions{})
+}
+
+// NewAdminStorageWithOpts returns a storage.AdminStorage implementation for
+// the Dialect of opts. The other options are unused.
+func NewAdminStorageWithOpts(db *sql.DB, opts TreeStorageOptions) storage.AdminStorage {
+	return &pgAdminStorage{db: db, dialect: opts.Dialect}
 }
 
 type pgAdminStorage struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect Dialect
 }
 
 func (s *pgAdminStorage) ReadWriteTransaction(ctx context.Context, f storage.AdminTXFunc) error {
-	tx, err := s.beginInternal(ctx)
-	if err != nil {
-		return err
-	}
-	defer tx.Close()
-	if err := f(ctx, tx); err != nil {
-		return err
-	}
-	return tx.Commit()
+	return s.dialect.retryTX(ctx, func() error {
+		tx, err := s.beginInternal(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Close()
+		if err := f(ctx, tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
 }
 
 func (s *pgAdminStorage) CheckDatabaseAccessible(ctx context.Context) error {
@@ -189,7 +228,7 @@ func (t *adminTX) GetTree(ctx context.Context, treeID int64) (*trillian.Tree, er
 	defer stmt.Close()
 
 	// GetTree is an entry point for most RPCs, let's provide somewhat nicer error messages.
-	tree, err := storage.ReadTree(stmt.QueryRowContext(ctx, treeID))
+	tree, err := readTree(stmt.QueryRowContext(ctx, treeID))
 	switch {
 	case err == sql.ErrNoRows:
 		// ErrNoRows doesn't provide useful information, so we don't forward it.
@@ -222,7 +261,7 @@ func (t *adminTX) ListTrees(ctx context.Context, includeDeleted bool) ([]*trilli
 
 	trees := []*trillian.Tree{}
 	for rows.Next() {
-		tree, err := storage.ReadTree(rows)
+		tree, err := readTree(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -276,10 +315,18 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 		return nil, err
 	}
 
-	id, err := storage.NewTreeID()
+	id, err := storage.TreeIDForCreation(tree)
 	if err != nil {
 		return nil, err
 	}
+	if tree.TreeId != 0 {
+		if _, err := t.GetTree(ctx, id); status.Code(err) != codes.NotFound {
+			if err == nil {
+				err = status.Errorf(codes.AlreadyExists, "tree %d already exists", id)
+			}
+			return nil, err
+		}
+	}
 
 	// Use the time truncated-to-millis throughout, as that's what's stored.
 	nowMillis := storage.ToMillisSinceEpoch(time.Now())
@@ -299,6 +346,28 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 	if err != nil {
 		return nil, fmt.Errorf("could not parse MaxRootDuration: %v", err)
 	}
+	retainRevisions, retainDuration, err := retentionColumns(newTree.RevisionRetentionPolicy)
+	if err != nil {
+		return nil, err
+	}
+	var storageSettings []byte
+	if newTree.StorageSettings != nil {
+		if storageSettings, err = proto.Marshal(newTree.StorageSettings); err != nil {
+			return nil, fmt.Errorf("could not marshal StorageSettings: %v", err)
+		}
+	}
+	additionalKeys, err := additionalKeysColumn(newTree)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := labelsColumn(newTree)
+	if err != nil {
+		return nil, err
+	}
+	deleteRetention, err := deleteRetentionColumn(newTree)
+	if err != nil {
+		return nil, err
+	}
 
 	insertTreeStmt, err := t.tx.PrepareContext(ctx, insertSQL)
 	if err != nil {
@@ -326,6 +395,18 @@ func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillia
 		privateKey,
 		newTree.PublicKey.GetDer(),
 		rootDuration/time.Millisecond,
+		retainRevisions,
+		retainDuration/time.Millisecond,
+		storageSettings,
+		newTree.MapIndexBits,
+		newTree.DuplicateLeafPolicy,
+		additionalKeys,
+		newTree.SignatureThreshold,
+		labels,
+		deleteRetention/time.Millisecond,
+		newTree.Quota.GetReadTokensPerSecond(),
+		newTree.Quota.GetWriteTokensPerSecond(),
+		newTree.Owner,
 	)
 	if err != nil {
 		return nil, err
@@ -364,6 +445,9 @@ func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(
 	if err := validateStorageSettings(tree); err != nil {
 		return nil, err
 	}
+	if !proto.Equal(beforeUpdate.StorageSettings, tree.StorageSettings) {
+		return nil, status.Error(codes.InvalidArgument, "readonly field changed: storage_settings")
+	}
 
 	// Use the time truncated-to-millis throughout, as that's what's stored.
 	nowMillis := storage.ToMillisSinceEpoch(time.Now())
@@ -376,11 +460,27 @@ func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(
 	if err != nil {
 		return nil, fmt.Errorf("could not parse MaxRootDuration: %v", err)
 	}
+	retainRevisions, retainDuration, err := retentionColumns(tree.RevisionRetentionPolicy)
+	if err != nil {
+		return nil, err
+	}
 
 	privateKey, err := proto.Marshal(tree.PrivateKey)
 	if err != nil {
 		return nil, fmt.Errorf("could not marshal PrivateKey: %v", err)
 	}
+	labels, err := labelsColumn(tree)
+	if err != nil {
+		return nil, err
+	}
+	deleteRetention, err := deleteRetentionColumn(tree)
+	if err != nil {
+		return nil, err
+	}
+	keyRotation, err := keyRotationColumn(tree)
+	if err != nil {
+		return nil, err
+	}
 
 	stmt, err := t.tx.PrepareContext(ctx, updateTreeSQL)
 	if err != nil {
@@ -397,6 +497,16 @@ func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(
 		nowMillis,
 		rootDuration/time.Millisecond,
 		privateKey,
+		tree.PublicKey.GetDer(),
+		retainRevisions,
+		retainDuration/time.Millisecond,
+		tree.DuplicateLeafPolicy,
+		labels,
+		deleteRetention/time.Millisecond,
+		keyRotation,
+		tree.Quota.GetReadTokensPerSecond(),
+		tree.Quota.GetWriteTokensPerSecond(),
+		tree.Owner,
 		tree.TreeId); err != nil {
 		return nil, err
 	}
@@ -459,9 +569,179 @@ func validateDeleted(ctx context.Context, tx *sql.Tx, treeID int64, wantDeleted
 	return nil
 }
 
+// validateStorageSettings checks that tree has no storage_settings, other than
+// the MapStorageSettings of maps.
 func validateStorageSettings(tree *trillian.Tree) error {
-	if tree.StorageSettings != nil {
+	if tree.StorageSettings == nil {
+		return nil
+	}
+	if tree.TreeType != trillian.TreeType_MAP {
 		return fmt.Errorf("storage_settings not supported, but got %v", tree.StorageSettings)
 	}
-	return nil
+	_, err := mapStorageSettings(tree)
+	return err
+}
+
+// mapStorageSettings returns the MapStorageSettings held in the
+// storage_settings of tree, which are empty if it has none.
+func mapStorageSettings(tree *trillian.Tree) (*storagepb.MapStorageSettings, error) {
+	settings := &storagepb.MapStorageSettings{}
+	if tree.StorageSettings == nil {
+		return settings, nil
+	}
+	if err := ptypes.UnmarshalAny(tree.StorageSettings, settings); err != nil {
+		return nil, fmt.Errorf("storage_settings not supported, but got %v: %v", tree.StorageSettings, err)
+	}
+	return settings, nil
+}
+
+// extraRow reads the revision retention, storage settings, map index bits,
+// duplicate leaf policy, additional key, label, delete retention, key rotation,
+// quota and owner columns, which are selected after the ones read by
+// storage.ReadTree.
+type extraRow struct {
+	storage.Row
+	retainRevisions, retainDurationMillis int64
+	storageSettings                       []byte
+	mapIndexBits                          int32
+	duplicateLeafPolicy                   int32
+	additionalKeys                        []byte
+	signatureThreshold                    int32
+	labels                                []byte
+	deleteRetentionMillis                 int64
+	keyRotation                           []byte
+	readTokensPerSecond                   int64
+	writeTokensPerSecond                  int64
+	owner                                 string
+}
+
+func (r *extraRow) Scan(dest ...interface{}) error {
+	return r.Row.Scan(append(dest, &r.retainRevisions, &r.retainDurationMillis, &r.storageSettings, &r.mapIndexBits, &r.duplicateLeafPolicy, &r.additionalKeys, &r.signatureThreshold, &r.labels, &r.deleteRetentionMillis, &r.keyRotation, &r.readTokensPerSecond, &r.writeTokensPerSecond, &r.owner)...)
+}
+
+// readTree takes a row selected by selectTrees and returns a tree.
+func readTree(row storage.Row) (*trillian.Tree, error) {
+	r := &extraRow{Row: row}
+	tree, err := storage.ReadTree(r)
+	if err != nil {
+		return nil, err
+	}
+	tree.MapIndexBits = r.mapIndexBits
+	tree.DuplicateLeafPolicy = trillian.DuplicateLeafPolicy(r.duplicateLeafPolicy)
+	tree.SignatureThreshold = r.signatureThreshold
+	if len(r.additionalKeys) > 0 {
+		var keys storagepb.AdditionalKeys
+		if err := proto.Unmarshal(r.additionalKeys, &keys); err != nil {
+			return nil, fmt.Errorf("could not unmarshal AdditionalKeys: %v", err)
+		}
+		tree.AdditionalPrivateKeys = keys.PrivateKeys
+		for _, der := range keys.PublicKeyDers {
+			tree.AdditionalPublicKeys = append(tree.AdditionalPublicKeys, &keyspb.PublicKey{Der: der})
+		}
+	}
+	if len(r.labels) > 0 {
+		var labels storagepb.TreeLabels
+		if err := proto.Unmarshal(r.labels, &labels); err != nil {
+			return nil, fmt.Errorf("could not unmarshal Labels: %v", err)
+		}
+		tree.Labels = labels.Labels
+	}
+	if r.deleteRetentionMillis != 0 {
+		tree.DeleteRetention = ptypes.DurationProto(time.Duration(r.deleteRetentionMillis) * time.Millisecond)
+	}
+	if len(r.keyRotation) > 0 {
+		tree.KeyRotation = &trillian.KeyRotation{}
+		if err := proto.Unmarshal(r.keyRotation, tree.KeyRotation); err != nil {
+			return nil, fmt.Errorf("could not unmarshal KeyRotation: %v", err)
+		}
+	}
+	if r.readTokensPerSecond != 0 || r.writeTokensPerSecond != 0 {
+		tree.Quota = &trillian.TreeQuota{ReadTokensPerSecond: r.readTokensPerSecond, WriteTokensPerSecond: r.writeTokensPerSecond}
+	}
+	tree.Owner = r.owner
+	if len(r.storageSettings) > 0 {
+		tree.StorageSettings = &any.Any{}
+		if err := proto.Unmarshal(r.storageSettings, tree.StorageSettings); err != nil {
+			return nil, fmt.Errorf("could not unmarshal StorageSettings: %v", err)
+		}
+	}
+	if r.retainRevisions != 0 || r.retainDurationMillis != 0 {
+		tree.RevisionRetentionPolicy = &trillian.RevisionRetentionPolicy{KeepRevisions: r.retainRevisions}
+		if r.retainDurationMillis != 0 {
+			tree.RevisionRetentionPolicy.KeepDuration = ptypes.DurationProto(time.Duration(r.retainDurationMillis) * time.Millisecond)
+		}
+	}
+	return tree, nil
+}
+
+// additionalKeysColumn returns the value stored in the additional_keys column
+// for the tree, which is nil if it has no additional keys.
+func additionalKeysColumn(tree *trillian.Tree) ([]byte, error) {
+	if len(tree.AdditionalPrivateKeys) == 0 {
+		return nil, nil
+	}
+	keys := &storagepb.AdditionalKeys{PrivateKeys: tree.AdditionalPrivateKeys}
+	for _, key := range tree.AdditionalPublicKeys {
+		keys.PublicKeyDers = append(keys.PublicKeyDers, key.GetDer())
+	}
+	b, err := proto.Marshal(keys)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal AdditionalKeys: %v", err)
+	}
+	return b, nil
+}
+
+// labelsColumn returns the value stored in the labels column for the tree,
+// which is nil if it has no labels.
+func labelsColumn(tree *trillian.Tree) ([]byte, error) {
+	if len(tree.Labels) == 0 {
+		return nil, nil
+	}
+	b, err := proto.Marshal(&storagepb.TreeLabels{Labels: tree.Labels})
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal Labels: %v", err)
+	}
+	return b, nil
+}
+
+// deleteRetentionColumn returns the delete retention of the tree, which is
+// zero if it uses the default.
+func deleteRetentionColumn(tree *trillian.Tree) (time.Duration, error) {
+	if tree.DeleteRetention == nil {
+		return 0, nil
+	}
+	d, err := ptypes.Duration(tree.DeleteRetention)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse DeleteRetention: %v", err)
+	}
+	return d, nil
+}
+
+// keyRotationColumn returns the value stored in the key_rotation column for the
+// tree, which is nil if it isn't rotating its key.
+func keyRotationColumn(tree *trillian.Tree) ([]byte, error) {
+	if tree.KeyRotation == nil {
+		return nil, nil
+	}
+	b, err := proto.Marshal(tree.KeyRotation)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal KeyRotation: %v", err)
+	}
+	return b, nil
+}
+
+// retentionColumns returns the values stored in the revision retention columns
+// for the given policy, which is nil if all revisions are kept.
+func retentionColumns(policy *trillian.RevisionRetentionPolicy) (int64, time.Duration, error) {
+	if policy == nil {
+		return 0, 0, nil
+	}
+	var keepDuration time.Duration
+	if policy.KeepDuration != nil {
+		var err error
+		if keepDuration, err = ptypes.Duration(policy.KeepDuration); err != nil {
+			return 0, 0, fmt.Errorf("could not parse KeepDuration: %v", err)
+		}
+	}
+	return policy.KeepRevisions, keepDuration, nil
 }
@@ -19,16 +19,22 @@ import (
 	"database/sql"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/google/trillian"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
 	"github.com/google/trillian/storage/testonly"
 )
 
-var allTables = []string{"unsequenced", "tree_head", "sequenced_leaf_data", "leaf_data", "subtree", "tree_control", "trees"}
+var allTables = []string{
+	"unsequenced", "tree_head", "log_root_signature", "recovery_marker", "sequenced_leaf_data", "leaf_data",
+	"map_leaf", "map_leaf_hash", "map_head", "map_idempotency_token", "map_root_signature", "map_write_queue", "map_revision_lease",
+	"subtree", "tree_control", "trees",
+}
 var db *sql.DB
 
 const selectTreeControlByID = "SELECT signing_enabled, sequencing_enabled, sequence_interval_seconds FROM tree_control WHERE tree_id = $1"
@@ -193,6 +199,73 @@ func TestAdminTX_StorageSettingsNotSupported(t *testing.T) {
 	}
 }
 
+func TestAdminTX_MapStorageSettings(t *testing.T) {
+	cleanTestDB(db, t)
+	s := NewAdminStorage(db)
+	ctx := context.Background()
+
+	settings, err := ptypes.MarshalAny(&storagepb.MapStorageSettings{LeafHashIndex: true})
+	if err != nil {
+		t.Fatalf("Error marshaling proto: %v", err)
+	}
+	tree := proto.Clone(testonly.MapTree).(*trillian.Tree)
+	tree.StorageSettings = settings
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if !proto.Equal(got.StorageSettings, settings) {
+		t.Errorf("GetTree().StorageSettings = %v, want %v", got.StorageSettings, settings)
+	}
+
+	other, err := ptypes.MarshalAny(&storagepb.MapStorageSettings{})
+	if err != nil {
+		t.Fatalf("Error marshaling proto: %v", err)
+	}
+	if _, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) { tree.StorageSettings = other }); err == nil {
+		t.Error("UpdateTree() changed storage_settings, want err")
+	}
+	if _, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) { tree.DisplayName = "renamed" }); err != nil {
+		t.Errorf("UpdateTree() returned err = %v", err)
+	}
+}
+
+func TestAdminTX_ExtraColumns(t *testing.T) {
+	cleanTestDB(db, t)
+	s := NewAdminStorage(db)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.Labels = map[string]string{"env": "test"}
+	tree.Quota = &trillian.TreeQuota{ReadTokensPerSecond: 100}
+	tree.Owner = "tenant-1"
+	tree.DeleteRetention = ptypes.DurationProto(time.Hour)
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+
+	updated, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) {
+		tree.Labels = map[string]string{"env": "prod"}
+		tree.Quota = &trillian.TreeQuota{WriteTokensPerSecond: 10}
+		tree.Owner = "tenant-2"
+	})
+	if err != nil {
+		t.Fatalf("UpdateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if !proto.Equal(got, updated) {
+		t.Errorf("GetTree() = %v, want %v", got, updated)
+	}
+}
+
 func cleanTestDB(db *sql.DB, t *testing.T) {
 	t.Helper()
 	for _, table := range allTables {
@@ -0,0 +1,154 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/client/backoff"
+	"github.com/lib/pq"
+)
+
+// Dialect is a SQL dialect of the databases which speak the PostgreSQL
+// protocol.
+type Dialect int
+
+const (
+	// PostgreSQL is the dialect of PostgreSQL.
+	PostgreSQL Dialect = iota
+	// CockroachDB is the dialect of CockroachDB 22.2 or later, whose schema is
+	// schema/cockroachdb.sql. Transactions aborted by serialization conflicts
+	// are retried, and read-only transactions can read stale data with AS OF
+	// SYSTEM TIME.
+	CockroachDB
+)
+
+// String returns the name of d.
+func (d Dialect) String() string {
+	switch d {
+	case PostgreSQL:
+		return "PostgreSQL"
+	case CockroachDB:
+		return "CockroachDB"
+	}
+	return fmt.Sprintf("Dialect(%d)", int(d))
+}
+
+const (
+	// maxTXAttempts is the number of times a CockroachDB transaction is run
+	// before the serialization conflict which aborted it is returned.
+	maxTXAttempts = 10
+	// serializationFailure is the SQLSTATE of the errors CockroachDB returns
+	// for transactions which must be retried.
+	serializationFailure = "40001"
+
+	// CockroachDB doesn't support PL/pgSQL functions which trap errors, so
+	// duplicates are skipped with ON CONFLICT, returning whether the row was
+	// inserted like the functions of the PostgreSQL schema do.
+	crdbInsertLeafDataSQL = `WITH ins AS (
+		 INSERT INTO leaf_data(tree_id,leaf_identity_hash,leaf_value,extra_data,queue_timestamp_nanos)
+		 VALUES($1,$2,$3,$4,$5) ON CONFLICT DO NOTHING RETURNING 1)
+		 SELECT EXISTS(SELECT 1 FROM ins)`
+	crdbInsertSequencedLeafSQL = `WITH ins AS (
+		 INSERT INTO sequenced_leaf_data(tree_id,sequence_number,leaf_identity_hash,merkle_leaf_hash,integrate_timestamp_nanos)
+		 VALUES($1,$2,$3,$4,$5) ON CONFLICT DO NOTHING RETURNING 1)
+		 SELECT EXISTS(SELECT 1 FROM ins)`
+	// crdbLockMapRevisionLeasesSQL locks the row of the tree in place of the
+	// advisory locks CockroachDB doesn't support.
+	crdbLockMapRevisionLeasesSQL = `SELECT tree_id FROM trees WHERE tree_id=$1 FOR UPDATE`
+	// crdbInsertRecoveryMarkerSQL records the commit timestamp of the
+	// transaction as the position of the marker, so restoring a backup AS OF
+	// SYSTEM TIME the position includes the root.
+	crdbInsertRecoveryMarkerSQL = `INSERT INTO recovery_marker(tree_id, revision, position)
+		 VALUES($1, $2, cluster_logical_timestamp()::STRING)`
+)
+
+// crdbStatements maps the statements which CockroachDB doesn't support to
+// their replacements.
+var crdbStatements = map[string]string{
+	insertLeafDataSQL:         crdbInsertLeafDataSQL,
+	insertSequencedLeafSQL:    crdbInsertSequencedLeafSQL,
+	insertUnsequencedEntrySQL: crdbInsertUnsequencedEntrySQL,
+	lockMapRevisionLeasesSQL:  crdbLockMapRevisionLeasesSQL,
+	insertRecoveryMarkerSQL:   crdbInsertRecoveryMarkerSQL,
+}
+
+// statement returns the statement to run in place of query in dialect d.
+func (d Dialect) statement(query string) string {
+	if d == CockroachDB {
+		if s, ok := crdbStatements[query]; ok {
+			return s
+		}
+	}
+	return query
+}
+
+// retryTX calls f, which runs a whole transaction, until it succeeds or fails
+// with an error which can't be retried. CockroachDB leaves it to clients to
+// retry the transactions aborted by serialization conflicts; transactions of
+// other dialects are never retried.
+func (d Dialect) retryTX(ctx context.Context, f func() error) error {
+	if d != CockroachDB {
+		return f()
+	}
+	b := backoff.Backoff{Min: 10 * time.Millisecond, Max: time.Second, Factor: 2, Jitter: true}
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt == maxTXAttempts || !isSerializationFailure(err) {
+			return err
+		}
+		glog.V(1).Infof("Retrying transaction after attempt %d: %v", attempt, err)
+		select {
+		case <-time.After(b.Duration()):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// isSerializationFailure returns whether err aborted a transaction which can be
+// retried.
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == serializationFailure
+	}
+	// Some errors are wrapped without their type.
+	return strings.Contains(err.Error(), "restart transaction")
+}
+
+// beginTx starts a transaction. Read-only transactions of CockroachDB read the
+// database as of staleness ago, if it's positive, so that they don't contend
+// with writes.
+func (d Dialect) beginTx(ctx context.Context, db *sql.DB, readonly bool, staleness time.Duration) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, nil /* opts */)
+	if err != nil {
+		return nil, err
+	}
+	if d == CockroachDB && readonly && staleness > 0 {
+		stmt := fmt.Sprintf("SET TRANSACTION AS OF SYSTEM TIME '-%dms'", staleness/time.Millisecond)
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return tx, nil
+}
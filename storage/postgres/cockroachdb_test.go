@@ -0,0 +1,75 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestDialectStatement(t *testing.T) {
+	for _, test := range []struct {
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{dialect: PostgreSQL, query: insertLeafDataSQL, want: insertLeafDataSQL},
+		{dialect: PostgreSQL, query: insertRecoveryMarkerSQL, want: insertRecoveryMarkerSQL},
+		{dialect: CockroachDB, query: insertLeafDataSQL, want: crdbInsertLeafDataSQL},
+		{dialect: CockroachDB, query: insertRecoveryMarkerSQL, want: crdbInsertRecoveryMarkerSQL},
+		{dialect: CockroachDB, query: selectMapRevisionLeaseSQL, want: selectMapRevisionLeaseSQL},
+	} {
+		if got := test.dialect.statement(test.query); got != test.want {
+			t.Errorf("%v.statement(%q) = %q, want %q", test.dialect, test.query, got, test.want)
+		}
+	}
+}
+
+func TestDialectRetryTX(t *testing.T) {
+	ctx := context.Background()
+	conflict := &pq.Error{Code: serializationFailure, Message: "restart transaction"}
+	for _, test := range []struct {
+		desc         string
+		dialect      Dialect
+		errs         []error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{desc: "success", dialect: CockroachDB, errs: []error{nil}, wantAttempts: 1},
+		{desc: "retried", dialect: CockroachDB, errs: []error{conflict, conflict, nil}, wantAttempts: 3},
+		{desc: "wrapped", dialect: CockroachDB, errs: []error{fmt.Errorf("dupecheck failed: %v", conflict), nil}, wantAttempts: 2},
+		{desc: "otherError", dialect: CockroachDB, errs: []error{errors.New("bad")}, wantAttempts: 1, wantErr: true},
+		{desc: "postgres", dialect: PostgreSQL, errs: []error{conflict}, wantAttempts: 1, wantErr: true},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			attempts := 0
+			err := test.dialect.retryTX(ctx, func() error {
+				err := test.errs[attempts]
+				attempts++
+				return err
+			})
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("retryTX(): %v, wantErr %v", err, test.wantErr)
+			}
+			if attempts != test.wantAttempts {
+				t.Errorf("retryTX() made %d attempts, want %d", attempts, test.wantAttempts)
+			}
+		})
+	}
+}
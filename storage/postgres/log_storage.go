@@ -17,8 +17,8 @@ package postgres
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"sort"
@@ -29,6 +29,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/merkle/hashers"
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage"
@@ -42,15 +43,27 @@ const (
 	valuesPlaceholder5     = "($1,$2,$3,$4,$5)"
 	insertLeafDataSQL      = "select insert_leaf_data_ignore_duplicates($1,$2,$3,$4,$5)"
 	insertSequencedLeafSQL = "select insert_sequenced_leaf_data_ignore_duplicates($1,$2,$3,$4,$5)"
+	// insertSequencedLeavesSQL is followed by the values of the leaves.
+	insertSequencedLeavesSQL = "INSERT INTO sequenced_leaf_data(tree_id,leaf_identity_hash,merkle_leaf_hash,sequence_number,integrate_timestamp_nanos) VALUES"
 
 	selectNonDeletedTreeIDByTypeAndStateSQL = `
                 SELECT tree_id FROM trees WHERE tree_type in ($1,$2) AND tree_state in ($3,$4) AND (deleted IS NULL OR deleted = false)`
 
 	selectSequencedLeafCountSQL   = "SELECT COUNT(*) FROM sequenced_leaf_data WHERE tree_id=$1"
 	selectUnsequencedLeafCountSQL = "SELECT tree_id, COUNT(1) FROM unsequenced GROUP BY tree_id"
-	//selectLatestSignedLogRootSQL  = `SELECT tree_head_timestamp,tree_size,root_hash,tree_revision,root_signature
-	//              FROM tree_head WHERE tree_id=$1
-	//              ORDER BY tree_head_timestamp DESC LIMIT 1`
+	selectLatestSignedLogRootSQL  = `SELECT tree_head_timestamp,tree_size,root_hash,tree_revision,root_signature,additional_signatures
+                        FROM tree_head WHERE tree_id=$1
+                        ORDER BY tree_revision DESC LIMIT 1`
+	selectSignedLogRootSQL = `SELECT tree_head_timestamp,tree_size,root_hash,tree_revision,root_signature,additional_signatures
+                        FROM tree_head WHERE tree_id=$1 AND tree_revision=$2`
+	selectSignedLogRootByHashSQL = `SELECT tree_head_timestamp,tree_size,root_hash,tree_revision,root_signature,additional_signatures
+                        FROM tree_head WHERE tree_id=$1 AND root_hash=$2
+                        ORDER BY tree_revision LIMIT 1`
+	insertLogRootSignatureSQL = `INSERT INTO log_root_signature(tree_id, tree_revision, key_hash, public_key, signature)
+                        VALUES ($1, $2, $3, $4, $5)
+                        ON CONFLICT (tree_id, tree_revision, key_hash) DO UPDATE SET signature = EXCLUDED.signature`
+	selectLogRootSignaturesSQL = `SELECT public_key, signature FROM log_root_signature
+                        WHERE tree_id=$1 AND tree_revision=$2 ORDER BY key_hash`
 
 	selectLeavesByRangeSQL = `SELECT s.merkle_leaf_hash,l.leaf_identity_hash,l.leaf_value,s.sequence_number,l.extra_data,l.queue_timestamp_nanos,s.integrate_timestamp_nanos
                         FROM leaf_data l,sequenced_leaf_data s
@@ -135,12 +148,18 @@ type postgresLogStorage struct {
 // NewLogStorage creates a storage.LogStorage instance for the specified PostgreSQL URL.
 // It assumes storage.AdminStorage is backed by the same PostgreSQL database as well.
 func NewLogStorage(db *sql.DB, mf monitoring.MetricFactory) storage.LogStorage {
+	return NewLogStorageWithOpts(db, mf, TreeStorageOptions{})
+}
+
+// NewLogStorageWithOpts creates a storage.LogStorage instance for the
+// specified PostgreSQL URL, using options.
+func NewLogStorageWithOpts(db *sql.DB, mf monitoring.MetricFactory, opts TreeStorageOptions) storage.LogStorage {
 	if mf == nil {
 		mf = monitoring.InertMetricFactory{}
 	}
 	return &postgresLogStorage{
-		admin:         NewAdminStorage(db),
-		pgTreeStorage: newTreeStorage(db),
+		admin:         NewAdminStorageWithOpts(db, opts),
+		pgTreeStorage: newTreeStorage(db, opts),
 		metricFactory: mf,
 	}
 }
@@ -208,7 +227,7 @@ type readOnlyLogTX struct {
 }
 
 func (m *postgresLogStorage) Snapshot(ctx context.Context) (storage.ReadOnlyLogTX, error) {
-	tx, err := m.db.BeginTx(ctx, nil /* opts */)
+	tx, err := m.opts.Dialect.beginTx(ctx, m.db, true /* readonly */, m.opts.SnapshotStaleness)
 	if err != nil {
 		glog.Warningf("Could not start ReadOnlyLogTX: %s", err)
 		return nil, err
@@ -254,7 +273,7 @@ func (t *readOnlyLogTX) GetActiveLogIDs(ctx context.Context) ([]int64, error) {
 	return ids, rows.Err()
 }
 
-func (m *postgresLogStorage) beginInternal(ctx context.Context, tree *trillian.Tree) (storage.LogTreeTX, error) {
+func (m *postgresLogStorage) beginInternal(ctx context.Context, tree *trillian.Tree, readonly bool) (storage.LogTreeTX, error) {
 	once.Do(func() {
 		createMetrics(m.metricFactory)
 	})
@@ -264,7 +283,7 @@ func (m *postgresLogStorage) beginInternal(ctx context.Context, tree *trillian.T
 	}
 
 	stCache := cache.NewLogSubtreeCache(defaultLogStrata, hasher)
-	ttx, err := m.beginTreeTx(ctx, tree, hasher.Size(), stCache)
+	ttx, err := m.beginTreeTx(ctx, tree, hasher.Size(), stCache, readonly)
 	if err != nil && err != storage.ErrTreeNeedsInit {
 		return nil, err
 	}
@@ -290,34 +309,40 @@ func (m *postgresLogStorage) beginInternal(ctx context.Context, tree *trillian.T
 }
 
 func (m *postgresLogStorage) ReadWriteTransaction(ctx context.Context, tree *trillian.Tree, f storage.LogTXFunc) error {
-	tx, err := m.beginInternal(ctx, tree)
-	if err != nil && err != storage.ErrTreeNeedsInit {
-		return err
-	}
-	defer tx.Close()
-	if err := f(ctx, tx); err != nil {
-		return err
-	}
-	return tx.Commit(ctx)
+	return m.opts.Dialect.retryTX(ctx, func() error {
+		tx, err := m.beginInternal(ctx, tree, false /* readonly */)
+		if err != nil && err != storage.ErrTreeNeedsInit {
+			return err
+		}
+		defer tx.Close()
+		if err := f(ctx, tx); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	})
 }
 
 func (m *postgresLogStorage) AddSequencedLeaves(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, timestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
-	tx, err := m.beginInternal(ctx, tree)
-	if err != nil {
-		return nil, err
-	}
-	res, err := tx.AddSequencedLeaves(ctx, leaves, timestamp)
+	var res []*trillian.QueuedLogLeaf
+	err := m.opts.Dialect.retryTX(ctx, func() error {
+		tx, err := m.beginInternal(ctx, tree, false /* readonly */)
+		if err != nil {
+			return err
+		}
+		defer tx.Close()
+		if res, err = tx.AddSequencedLeaves(ctx, leaves, timestamp); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	})
 	if err != nil {
 		return nil, err
 	}
-	if err := tx.Commit(ctx); err != nil {
-		return nil, err
-	}
 	return res, nil
 }
 
 func (m *postgresLogStorage) SnapshotForTree(ctx context.Context, tree *trillian.Tree) (storage.ReadOnlyLogTreeTX, error) {
-	tx, err := m.beginInternal(ctx, tree)
+	tx, err := m.beginInternal(ctx, tree, true /* readonly */)
 	if err != nil && err != storage.ErrTreeNeedsInit {
 		return nil, err
 	}
@@ -325,19 +350,22 @@ func (m *postgresLogStorage) SnapshotForTree(ctx context.Context, tree *trillian
 }
 
 func (m *postgresLogStorage) QueueLeaves(ctx context.Context, tree *trillian.Tree, leaves []*trillian.LogLeaf, queueTimestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
-	tx, err := m.beginInternal(ctx, tree)
-	if err != nil {
-		return nil, err
-	}
-	existing, err := tx.QueueLeaves(ctx, leaves, queueTimestamp)
+	var existing []*trillian.LogLeaf
+	err := m.opts.Dialect.retryTX(ctx, func() error {
+		tx, err := m.beginInternal(ctx, tree, false /* readonly */)
+		if err != nil {
+			return err
+		}
+		defer tx.Close()
+		if existing, err = tx.QueueLeaves(ctx, leaves, queueTimestamp); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return nil, err
-	}
-
 	ret := make([]*trillian.QueuedLogLeaf, len(leaves))
 	for i, e := range existing {
 		if e != nil {
@@ -360,10 +388,16 @@ type logTreeTX struct {
 }
 
 func (t *logTreeTX) ReadRevision(ctx context.Context) (int64, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
 	return int64(t.root.Revision), nil
 }
 
 func (t *logTreeTX) WriteRevision(ctx context.Context) (int64, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
 	if t.treeTX.writeRevision < 0 {
 		return t.treeTX.writeRevision, errors.New("logTreeTX write revision not populated")
 	}
@@ -371,10 +405,13 @@ func (t *logTreeTX) WriteRevision(ctx context.Context) (int64, error) {
 }
 
 func (t *logTreeTX) DequeueLeaves(ctx context.Context, limit int, cutoffTime time.Time) ([]*trillian.LogLeaf, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
 	if t.treeType == trillian.TreeType_PREORDERED_LOG {
 		// TODO(pavelkalinnikov): Optimize this by fetching only the required
 		// fields of LogLeaf. We can avoid joining with LeafData table here.
-		return t.GetLeavesByRange(ctx, int64(t.root.TreeSize), int64(limit))
+		return t.getLeavesByRangeInternal(ctx, int64(t.root.TreeSize), int64(limit))
 	}
 
 	start := time.Now()
@@ -449,6 +486,9 @@ func sortLeavesForInsert(leaves []*trillian.LogLeaf) []leafAndPosition {
 }
 
 func (t *logTreeTX) QueueLeaves(ctx context.Context, leaves []*trillian.LogLeaf, queueTimestamp time.Time) ([]*trillian.LogLeaf, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
 	// Don't accept batches if any of the leaves are invalid.
 	for _, leaf := range leaves {
 		if len(leaf.LeafIdentityHash) != t.hashSizeBytes {
@@ -475,7 +515,7 @@ func (t *logTreeTX) QueueLeaves(ctx context.Context, leaves []*trillian.LogLeaf,
 		if err != nil {
 			return nil, fmt.Errorf("got invalid queue timestamp: %v", err)
 		}
-		dupCheckRow, err := t.tx.QueryContext(ctx, insertLeafDataSQL, t.treeID, leaf.LeafIdentityHash, leaf.LeafValue, leaf.ExtraData, qTimestamp.UnixNano())
+		dupCheckRow, err := t.tx.QueryContext(ctx, t.ts.opts.Dialect.statement(insertLeafDataSQL), t.treeID, leaf.LeafIdentityHash, leaf.LeafValue, leaf.ExtraData, qTimestamp.UnixNano())
 		if err != nil {
 			return nil, fmt.Errorf("dupecheck failed: %v", err)
 		}
@@ -514,7 +554,7 @@ func (t *logTreeTX) QueueLeaves(ctx context.Context, leaves []*trillian.LogLeaf,
 		args = append(args, queueArgs(t.treeID, leaf.LeafIdentityHash, queueTimestamp)...)
 		_, err = t.tx.ExecContext(
 			ctx,
-			insertUnsequencedEntrySQL,
+			t.ts.opts.Dialect.statement(insertUnsequencedEntrySQL),
 			args...,
 		)
 		if err != nil {
@@ -572,6 +612,9 @@ func (t *logTreeTX) QueueLeaves(ctx context.Context, leaves []*trillian.LogLeaf,
 }
 
 func (t *logTreeTX) AddSequencedLeaves(ctx context.Context, leaves []*trillian.LogLeaf, timestamp time.Time) ([]*trillian.QueuedLogLeaf, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
 	res := make([]*trillian.QueuedLogLeaf, len(leaves))
 	ok := status.New(codes.OK, "OK").Proto()
 
@@ -608,7 +651,7 @@ func (t *logTreeTX) AddSequencedLeaves(ctx context.Context, leaves []*trillian.L
 		res[i] = &trillian.QueuedLogLeaf{Status: ok}
 
 		// TODO(pavelkalinnikov): Measure latencies.
-		_, err := t.tx.ExecContext(ctx, insertLeafDataSQL,
+		_, err := t.tx.ExecContext(ctx, t.ts.opts.Dialect.statement(insertLeafDataSQL),
 			t.treeID, leaf.LeafIdentityHash, leaf.LeafValue, leaf.ExtraData, timestamp.UnixNano())
 		// TODO(pavelkalinnikov): Detach PREORDERED_LOG integration latency metric.
 		if err != nil {
@@ -616,7 +659,7 @@ func (t *logTreeTX) AddSequencedLeaves(ctx context.Context, leaves []*trillian.L
 			return nil, err
 		}
 
-		dupCheckRow, err := t.tx.QueryContext(ctx, insertSequencedLeafSQL,
+		dupCheckRow, err := t.tx.QueryContext(ctx, t.ts.opts.Dialect.statement(insertSequencedLeafSQL),
 			t.treeID, leaf.LeafIndex, leaf.LeafIdentityHash, leaf.MerkleLeafHash, 0)
 		// TODO(pavelkalinnikov): Update IntegrateTimestamp on integrating the leaf.
 		resultData := true
@@ -650,6 +693,9 @@ func (t *logTreeTX) AddSequencedLeaves(ctx context.Context, leaves []*trillian.L
 }
 
 func (t *logTreeTX) GetSequencedLeafCount(ctx context.Context) (int64, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
 	var sequencedLeafCount int64
 
 	err := t.tx.QueryRowContext(ctx, selectSequencedLeafCountSQL, t.treeID).Scan(&sequencedLeafCount)
@@ -661,6 +707,9 @@ func (t *logTreeTX) GetSequencedLeafCount(ctx context.Context) (int64, error) {
 }
 
 func (t *logTreeTX) GetLeavesByIndex(ctx context.Context, leaves []int64) ([]*trillian.LogLeaf, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
 	if t.treeType == trillian.TreeType_LOG {
 		treeSize := int64(t.root.TreeSize)
 		for _, leaf := range leaves {
@@ -725,6 +774,12 @@ func (t *logTreeTX) GetLeavesByIndex(ctx context.Context, leaves []int64) ([]*tr
 }
 
 func (t *logTreeTX) GetLeavesByRange(ctx context.Context, start, count int64) ([]*trillian.LogLeaf, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+	return t.getLeavesByRangeInternal(ctx, start, count)
+}
+
+func (t *logTreeTX) getLeavesByRangeInternal(ctx context.Context, start, count int64) ([]*trillian.LogLeaf, error) {
 	if count <= 0 {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid count %d, want > 0", count)
 	}
@@ -791,6 +846,9 @@ func (t *logTreeTX) GetLeavesByRange(ctx context.Context, start, count int64) ([
 }
 
 func (t *logTreeTX) GetLeavesByHash(ctx context.Context, leafHashes [][]byte, orderBySequence bool) ([]*trillian.LogLeaf, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
 	tmpl, err := t.ls.getLeavesByMerkleHashStmt(ctx, len(leafHashes), orderBySequence)
 	if err != nil {
 		return nil, err
@@ -811,33 +869,126 @@ func (t *logTreeTX) getLeafDataByIdentityHash(ctx context.Context, leafHashes []
 }
 
 func (t *logTreeTX) LatestSignedLogRoot(ctx context.Context) (*trillian.SignedLogRoot, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
 	return t.slr, nil
 }
 
 // fetchLatestRoot reads the latest SignedLogRoot from the DB and returns it.
 func (t *logTreeTX) fetchLatestRoot(ctx context.Context) (*trillian.SignedLogRoot, error) {
-	//	var timestamp, treeSize, treeRevision int64
-	var rootSignatureBytes []byte
-	var jsonObj []byte
-
-	t.tx.QueryRowContext(
-		ctx,
-		"select current_tree_data,root_signature from trees where tree_id = $1",
-		t.treeID).Scan(&jsonObj, &rootSignatureBytes)
-	if jsonObj == nil { //this fixes the createtree workflow
+	var timestamp, treeSize, treeRevision int64
+	var rootHash, rootSignatureBytes, additionalSignatures []byte
+	err := t.tx.QueryRowContext(ctx, selectLatestSignedLogRootSQL, t.treeID).Scan(
+		&timestamp, &treeSize, &rootHash, &treeRevision, &rootSignatureBytes, &additionalSignatures)
+	if err == sql.ErrNoRows {
+		// It's possible there are no roots for this tree yet.
 		return nil, storage.ErrTreeNeedsInit
+	} else if err != nil {
+		glog.Warningf("Failed to read latest log root: %s", err)
+		return nil, err
 	}
-	var logRoot types.LogRootV1
-	json.Unmarshal(jsonObj, &logRoot)
-	newRoot, _ := logRoot.MarshalBinary()
+	return t.signedLogRoot(timestamp, treeSize, rootHash, treeRevision, rootSignatureBytes, additionalSignatures)
+}
+
+// signedLogRoot puts a SignedLogRoot back together from the columns of its
+// tree_head row.
+func (t *logTreeTX) signedLogRoot(timestamp, treeSize int64, rootHash []byte, treeRevision int64, rootSignatureBytes, additionalSignatures []byte) (*trillian.SignedLogRoot, error) {
+	logRoot, err := (&types.LogRootV1{
+		RootHash:       rootHash,
+		TimestampNanos: uint64(timestamp),
+		Revision:       uint64(treeRevision),
+		TreeSize:       uint64(treeSize),
+	}).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	sigs, err := unmarshalRootSignatures(additionalSignatures)
+	if err != nil {
+		return nil, err
+	}
+
 	return &trillian.SignedLogRoot{
-		KeyHint:          types.SerializeKeyHint(t.treeID),
-		LogRoot:          newRoot,
-		LogRootSignature: rootSignatureBytes,
+		KeyHint:              types.SerializeKeyHint(t.treeID),
+		LogRoot:              logRoot,
+		LogRootSignature:     rootSignatureBytes,
+		AdditionalSignatures: sigs,
 	}, nil
 }
 
+func (t *logTreeTX) GetSignedLogRoot(ctx context.Context, revision int64) (*trillian.SignedLogRoot, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
+	var timestamp, treeSize, treeRevision int64
+	var rootHash, rootSignatureBytes, additionalSignatures []byte
+	err := t.tx.QueryRowContext(ctx, selectSignedLogRootSQL, t.treeID, revision).Scan(
+		&timestamp, &treeSize, &rootHash, &treeRevision, &rootSignatureBytes, &additionalSignatures)
+	if err == sql.ErrNoRows {
+		return nil, status.Errorf(codes.NotFound, "log root %d not found", revision)
+	} else if err != nil {
+		glog.Warningf("Failed to read log root: %s", err)
+		return nil, err
+	}
+	return t.signedLogRoot(timestamp, treeSize, rootHash, treeRevision, rootSignatureBytes, additionalSignatures)
+}
+
+func (t *logTreeTX) GetSignedLogRootByHash(ctx context.Context, rootHash []byte) (*trillian.SignedLogRoot, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
+	var timestamp, treeSize, treeRevision int64
+	var rootHashBytes, rootSignatureBytes, additionalSignatures []byte
+	err := t.tx.QueryRowContext(ctx, selectSignedLogRootByHashSQL, t.treeID, rootHash).Scan(
+		&timestamp, &treeSize, &rootHashBytes, &treeRevision, &rootSignatureBytes, &additionalSignatures)
+	if err == sql.ErrNoRows {
+		return nil, status.Errorf(codes.NotFound, "log root with hash %x not found", rootHash)
+	} else if err != nil {
+		glog.Warningf("Failed to read log root: %s", err)
+		return nil, err
+	}
+	return t.signedLogRoot(timestamp, treeSize, rootHashBytes, treeRevision, rootSignatureBytes, additionalSignatures)
+}
+
+func (t *logTreeTX) GetLogRootSignatures(ctx context.Context, revision int64) ([]*trillian.LogRootSignature, error) {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
+	rows, err := t.tx.QueryContext(ctx, selectLogRootSignaturesSQL, t.treeID, revision)
+	if err != nil {
+		glog.Warningf("Failed to read log root signatures: %s", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sigs []*trillian.LogRootSignature
+	for rows.Next() {
+		var der, sig []byte
+		if err := rows.Scan(&der, &sig); err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, &trillian.LogRootSignature{PublicKey: &keyspb.PublicKey{Der: der}, Signature: sig})
+	}
+	return sigs, rows.Err()
+}
+
+func (t *logTreeTX) StoreLogRootSignature(ctx context.Context, revision int64, sig *trillian.LogRootSignature) error {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
+	der := sig.GetPublicKey().GetDer()
+	keyHash := sha256.Sum256(der)
+	if _, err := t.tx.ExecContext(ctx, insertLogRootSignatureSQL, t.treeID, revision, keyHash[:], der, sig.GetSignature()); err != nil {
+		glog.Warningf("Failed to store log root signature: %s", err)
+		return err
+	}
+	return nil
+}
+
 func (t *logTreeTX) StoreSignedLogRoot(ctx context.Context, root *trillian.SignedLogRoot) error {
+	t.treeTX.mu.Lock()
+	defer t.treeTX.mu.Unlock()
+
 	var logRoot types.LogRootV1
 	if err := logRoot.UnmarshalBinary(root.LogRoot); err != nil {
 		glog.Warningf("Failed to parse log root: %x %v", root.LogRoot, err)
@@ -845,16 +996,12 @@ func (t *logTreeTX) StoreSignedLogRoot(ctx context.Context, root *trillian.Signe
 	}
 	if len(logRoot.Metadata) != 0 {
 		return fmt.Errorf("unimplemented: postgres storage does not support log root metadata")
-
 	}
-	//get a json copy of the tree_head
-	data, _ := json.Marshal(logRoot)
-	t.tx.ExecContext(
-		ctx,
-		"update trees set current_tree_data = $1,root_signature = $2 where tree_id = $3",
-		data,
-		root.LogRootSignature,
-		t.treeID)
+	additionalSignatures, err := marshalRootSignatures(root.AdditionalSignatures)
+	if err != nil {
+		return err
+	}
+
 	res, err := t.tx.ExecContext(
 		ctx,
 		insertTreeHeadSQL,
@@ -863,12 +1010,16 @@ func (t *logTreeTX) StoreSignedLogRoot(ctx context.Context, root *trillian.Signe
 		logRoot.TreeSize,
 		logRoot.RootHash,
 		logRoot.Revision,
-		root.LogRootSignature)
+		root.LogRootSignature,
+		additionalSignatures)
 	if err != nil {
 		glog.Warningf("Failed to store signed root: %s", err)
 	}
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		return err
+	}
 
-	return checkResultOkAndRowCountIs(res, err, 1)
+	return t.storeRecoveryMarker(ctx, int64(logRoot.Revision))
 }
 
 func (t *logTreeTX) getLeavesByHashInternal(ctx context.Context, leafHashes [][]byte, tmpl *sql.Stmt, desc string) ([]*trillian.LogLeaf, error) {
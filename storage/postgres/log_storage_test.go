@@ -29,6 +29,7 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/testonly"
 	"github.com/google/trillian/types"
@@ -1085,6 +1086,171 @@ func TestLogRootUpdate(t *testing.T) {
 	})
 }
 
+func TestLogRecoveryMarker(t *testing.T) {
+	cleanTestDB(db, t)
+	tree := createTreeOrPanic(db, testonly.LogTree)
+	s := NewLogStorage(db, nil)
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		if _, err := tx.LatestRecoveryMarker(ctx); status.Code(err) != codes.NotFound {
+			t.Errorf("LatestRecoveryMarker() before any root: %v, want code %v", err, codes.NotFound)
+		}
+		return nil
+	})
+
+	signer := tcrypto.NewSigner(tree.TreeId, ttestonly.NewSignerWithFixedSig(nil, []byte("notempty")), crypto.SHA256)
+	root, err := signer.SignLogRoot(&types.LogRootV1{
+		TimestampNanos: 98765,
+		TreeSize:       16,
+		Revision:       5,
+		RootHash:       []byte(dummyHash),
+	})
+	if err != nil {
+		t.Fatalf("SignLogRoot(): %v", err)
+	}
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		return tx.StoreSignedLogRoot(ctx, root)
+	})
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		latest, err := tx.LatestRecoveryMarker(ctx)
+		if err != nil {
+			t.Fatalf("LatestRecoveryMarker(): %v", err)
+		}
+		want := &storage.RecoveryMarker{TreeID: tree.TreeId, Revision: 5, Position: latest.Position}
+		if !reflect.DeepEqual(latest, want) {
+			t.Errorf("LatestRecoveryMarker()=%+v, want %+v", latest, want)
+		}
+		got, err := tx.GetRecoveryMarker(ctx, 5)
+		if err != nil {
+			t.Fatalf("GetRecoveryMarker(5): %v", err)
+		}
+		if !reflect.DeepEqual(got, latest) {
+			t.Errorf("GetRecoveryMarker(5)=%+v, want %+v", got, latest)
+		}
+		return nil
+	})
+}
+
+func TestLogRootSignatures(t *testing.T) {
+	cleanTestDB(db, t)
+	tree := createTreeOrPanic(db, testonly.LogTree)
+	s := NewLogStorage(db, nil)
+
+	signer := tcrypto.NewSigner(tree.TreeId, ttestonly.NewSignerWithFixedSig(nil, []byte("notempty")), crypto.SHA256)
+	root, err := signer.SignLogRoot(&types.LogRootV1{
+		TimestampNanos: 98765,
+		TreeSize:       16,
+		Revision:       5,
+		RootHash:       []byte(dummyHash),
+	})
+	if err != nil {
+		t.Fatalf("SignLogRoot(): %v", err)
+	}
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		return tx.StoreSignedLogRoot(ctx, root)
+	})
+
+	sig := func(key, sig string) *trillian.LogRootSignature {
+		return &trillian.LogRootSignature{PublicKey: &keyspb.PublicKey{Der: []byte(key)}, Signature: []byte(sig)}
+	}
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		for _, sig := range []*trillian.LogRootSignature{
+			sig("key1", "old"),
+			sig("key2", "sig2"),
+			sig("key1", "sig1"), // Replaces the first signature.
+		} {
+			if err := tx.StoreLogRootSignature(ctx, 5, sig); err != nil {
+				t.Fatalf("StoreLogRootSignature(%v): %v", sig, err)
+			}
+		}
+		return nil
+	})
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		got, err := tx.GetSignedLogRoot(ctx, 5)
+		if err != nil {
+			t.Fatalf("GetSignedLogRoot(5): %v", err)
+		}
+		if !proto.Equal(got, root) {
+			t.Errorf("GetSignedLogRoot(5)=%v, want %v", got, root)
+		}
+		if _, err := tx.GetSignedLogRoot(ctx, 6); status.Code(err) != codes.NotFound {
+			t.Errorf("GetSignedLogRoot(6): %v, want code %v", err, codes.NotFound)
+		}
+
+		// Signatures are ordered by the hash of their key.
+		want := []*trillian.LogRootSignature{sig("key1", "sig1"), sig("key2", "sig2")}
+		sort.Slice(want, func(i, j int) bool {
+			hi, hj := sha256.Sum256(want[i].PublicKey.Der), sha256.Sum256(want[j].PublicKey.Der)
+			return bytes.Compare(hi[:], hj[:]) < 0
+		})
+		sigs, err := tx.GetLogRootSignatures(ctx, 5)
+		if err != nil {
+			t.Fatalf("GetLogRootSignatures(5): %v", err)
+		}
+		if len(sigs) != len(want) {
+			t.Fatalf("GetLogRootSignatures(5): %d signatures, want %d", len(sigs), len(want))
+		}
+		for i := range sigs {
+			if !proto.Equal(sigs[i], want[i]) {
+				t.Errorf("GetLogRootSignatures(5)[%d]=%v, want %v", i, sigs[i], want[i])
+			}
+		}
+		if sigs, err := tx.GetLogRootSignatures(ctx, 6); err != nil || len(sigs) != 0 {
+			t.Errorf("GetLogRootSignatures(6)=%v, %v, want none", sigs, err)
+		}
+		return nil
+	})
+}
+
+func TestGetSignedLogRootByHash(t *testing.T) {
+	cleanTestDB(db, t)
+	tree := createTreeOrPanic(db, testonly.LogTree)
+	s := NewLogStorage(db, nil)
+
+	signer := tcrypto.NewSigner(tree.TreeId, ttestonly.NewSignerWithFixedSig(nil, []byte("notempty")), crypto.SHA256)
+	var roots []*trillian.SignedLogRoot
+	// The second root re-signs the first tree state.
+	for i, hash := range [][]byte{dummyHash, dummyHash, dummyHash2} {
+		root, err := signer.SignLogRoot(&types.LogRootV1{
+			TimestampNanos: uint64(98765 + i),
+			TreeSize:       16,
+			Revision:       uint64(5 + i),
+			RootHash:       hash,
+		})
+		if err != nil {
+			t.Fatalf("SignLogRoot(): %v", err)
+		}
+		runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+			return tx.StoreSignedLogRoot(ctx, root)
+		})
+		roots = append(roots, root)
+	}
+
+	runLogTX(s, tree, t, func(ctx context.Context, tx storage.LogTreeTX) error {
+		for _, test := range []struct {
+			hash []byte
+			want *trillian.SignedLogRoot
+		}{
+			{hash: dummyHash, want: roots[0]},
+			{hash: dummyHash2, want: roots[2]},
+		} {
+			got, err := tx.GetSignedLogRootByHash(ctx, test.hash)
+			if err != nil {
+				t.Fatalf("GetSignedLogRootByHash(%x): %v", test.hash, err)
+			}
+			if !proto.Equal(got, test.want) {
+				t.Errorf("GetSignedLogRootByHash(%x)=%v, want %v", test.hash, got, test.want)
+			}
+		}
+		if _, err := tx.GetSignedLogRootByHash(ctx, dummyHash3); status.Code(err) != codes.NotFound {
+			t.Errorf("GetSignedLogRootByHash(%x): %v, want code %v", dummyHash3, err, codes.NotFound)
+		}
+		return nil
+	})
+}
+
 func TestGetActiveLogIDs(t *testing.T) {
 	ctx := context.Background()
 
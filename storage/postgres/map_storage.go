@@ -0,0 +1,858 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/types"
+)
+
+const (
+	// lockTreeSQL locks the row of a tree, so that multi-map transactions
+	// serialize on the trees they share.
+	lockTreeSQL      = `SELECT tree_id FROM trees WHERE tree_id=$1 FOR UPDATE`
+	insertMapHeadSQL = `INSERT INTO map_head(tree_id, map_head_timestamp, root_hash, map_revision, root_signature, mapper_data, additional_signatures)
+	VALUES($1, $2, $3, $4, $5, $6, $7)`
+	selectLatestSignedMapRootSQL = `SELECT map_head_timestamp, root_hash, map_revision, root_signature, mapper_data, additional_signatures
+		 FROM map_head WHERE tree_id=$1
+		 ORDER BY map_head_timestamp DESC LIMIT 1`
+	selectGetSignedMapRootSQL = `SELECT map_head_timestamp, root_hash, map_revision, root_signature, mapper_data, additional_signatures
+		 FROM map_head WHERE tree_id=$1 AND map_revision=$2`
+	// selectEarliestRevisionSinceSQL returns the earliest revision published at
+	// or after a timestamp, or the latest revision plus one if there is none.
+	selectEarliestRevisionSinceSQL = `SELECT COALESCE(MIN(CASE WHEN map_head_timestamp >= $1 THEN map_revision END), MAX(map_revision)+1)
+		 FROM map_head WHERE tree_id=$2`
+	// selectMapHeadsInRangeSQL returns the roots within a range of revisions
+	// and a range of timestamps. The ORDER BY direction and LIMIT are appended
+	// by ListSignedMapRoots.
+	selectMapHeadsInRangeSQL = `SELECT map_head_timestamp, root_hash, map_revision, root_signature, mapper_data, additional_signatures
+		 FROM map_head WHERE tree_id=$1
+		 AND map_revision>=$2 AND map_revision<$3
+		 AND map_head_timestamp>=$4 AND map_head_timestamp<$5
+		 ORDER BY map_revision `
+	deleteMapHeadsBeforeSQL = `DELETE FROM map_head WHERE tree_id=$1 AND map_revision<$2`
+	// deleteMapIdempotencyTokensBeforeSQL deletes the tokens of the revisions
+	// deleted by deleteMapHeadsBeforeSQL.
+	deleteMapIdempotencyTokensBeforeSQL = `DELETE FROM map_idempotency_token WHERE tree_id=$1 AND map_revision<$2`
+	insertMapIdempotencyTokenSQL        = `INSERT INTO map_idempotency_token(tree_id, token, map_revision) VALUES ($1, $2, $3)`
+	selectMapIdempotencyTokenSQL        = `SELECT map_revision FROM map_idempotency_token WHERE tree_id=$1 AND token=$2`
+	// deleteMapRootSignaturesBeforeSQL deletes the witness signatures of the
+	// revisions deleted by deleteMapHeadsBeforeSQL.
+	deleteMapRootSignaturesBeforeSQL = `DELETE FROM map_root_signature WHERE tree_id=$1 AND map_revision<$2`
+	insertMapRootSignatureSQL        = `INSERT INTO map_root_signature(tree_id, map_revision, key_hash, public_key, signature)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (tree_id, map_revision, key_hash) DO UPDATE SET signature = EXCLUDED.signature`
+	selectMapRootSignaturesSQL = `SELECT public_key, signature FROM map_root_signature
+		 WHERE tree_id=$1 AND map_revision=$2 ORDER BY key_hash`
+	// deleteMapLeavesBeforeSQL deletes the versions of each leaf which are
+	// superseded by a later version at or before a revision.
+	deleteMapLeavesBeforeSQL = `
+ DELETE FROM map_leaf t1
+ USING
+ (
+	SELECT tree_id, key_hash, MAX(map_revision) as maxrev
+	FROM map_leaf t0
+	WHERE t0.tree_id = $1 AND t0.map_revision <= $2
+	GROUP BY t0.tree_id, t0.key_hash
+ ) t2
+ WHERE t1.tree_id=t2.tree_id
+ AND t1.key_hash=t2.key_hash
+ AND t1.map_revision<t2.maxrev`
+	// deleteSubtreesBeforeSQL is the equivalent of deleteMapLeavesBeforeSQL
+	// for subtrees.
+	deleteSubtreesBeforeSQL = `
+ DELETE FROM subtree t1
+ USING
+ (
+	SELECT tree_id, subtree_id, MAX(subtree_revision) as maxrev
+	FROM subtree t0
+	WHERE t0.tree_id = $1 AND t0.subtree_revision <= $2
+	GROUP BY t0.tree_id, t0.subtree_id
+ ) t2
+ WHERE t1.tree_id=t2.tree_id
+ AND t1.subtree_id=t2.subtree_id
+ AND t1.subtree_revision<t2.maxrev`
+	insertMapLeafSQL        = `INSERT INTO map_leaf(tree_id, key_hash, map_revision, leaf_value) VALUES ($1, $2, $3, $4)`
+	selectMapLeafVersionSQL = `SELECT leaf_value FROM map_leaf WHERE tree_id=$1 AND key_hash=$2 AND map_revision=$3`
+	updateMapLeafVersionSQL = `UPDATE map_leaf SET leaf_value=$1 WHERE tree_id=$2 AND key_hash=$3 AND map_revision=$4`
+	insertMapLeafHashSQL    = `INSERT INTO map_leaf_hash(tree_id, leaf_hash, key_hash, map_revision) VALUES ($1, $2, $3, $4)`
+	deleteMapLeafHashSQL    = `DELETE FROM map_leaf_hash WHERE tree_id=$1 AND key_hash=$2 AND map_revision=$3`
+	// selectMapLeafSQL returns the latest value of each of the requested
+	// leaves at a revision.
+	selectMapLeafSQL = `
+ SELECT t1.key_hash, t1.leaf_value
+ FROM map_leaf t1
+ INNER JOIN
+ (
+	SELECT tree_id, key_hash, MAX(map_revision) as maxrev
+	FROM map_leaf t0
+	WHERE t0.key_hash IN (` + placeholderSQL + `) AND
+	      t0.tree_id = <param> AND t0.map_revision <= <param>
+	GROUP BY t0.tree_id, t0.key_hash
+ ) t2
+ ON t1.tree_id=t2.tree_id
+ AND t1.key_hash=t2.key_hash
+ AND t1.map_revision=t2.maxrev`
+	// selectIndexesByLeafHashSQL returns the indexes whose latest version at a
+	// revision has a given leaf hash.
+	selectIndexesByLeafHashSQL = `
+ SELECT h.key_hash
+ FROM map_leaf_hash h
+ WHERE h.tree_id = $1 AND h.leaf_hash = $2 AND h.map_revision <= $3
+ AND h.map_revision =
+ (
+	SELECT MAX(l.map_revision)
+	FROM map_leaf l
+	WHERE l.tree_id = h.tree_id AND l.key_hash = h.key_hash AND l.map_revision <= $3
+ )
+ ORDER BY h.key_hash`
+	// deleteMapLeafHashesBeforeSQL deletes the leaf hashes of the leaf versions
+	// deleted by deleteMapLeavesBeforeSQL.
+	deleteMapLeafHashesBeforeSQL = `
+ DELETE FROM map_leaf_hash h
+ WHERE h.tree_id = $1 AND h.map_revision <= $2
+ AND NOT EXISTS
+ (
+	SELECT 1 FROM map_leaf l
+	WHERE l.tree_id=h.tree_id
+	AND l.key_hash=h.key_hash
+	AND l.map_revision=h.map_revision
+ )`
+	// selectMapLeafVersionsAfterSQL returns all the versions of the first LIMIT
+	// indexes following a given index.
+	selectMapLeafVersionsAfterSQL = `
+ SELECT t1.key_hash, t1.map_revision, t1.leaf_value
+ FROM map_leaf t1
+ INNER JOIN
+ (
+	SELECT DISTINCT tree_id, key_hash
+	FROM map_leaf t0
+	WHERE t0.tree_id = $1 AND t0.key_hash > $2
+	ORDER BY t0.key_hash
+	LIMIT $3
+ ) t2
+ ON t1.tree_id=t2.tree_id
+ AND t1.key_hash=t2.key_hash
+ ORDER BY t1.key_hash, t1.map_revision`
+	// selectMapLeavesAfterSQL returns the latest value of each leaf at a
+	// revision, for the first LIMIT indexes following a given index.
+	selectMapLeavesAfterSQL = `
+ SELECT t1.key_hash, t1.leaf_value
+ FROM map_leaf t1
+ INNER JOIN
+ (
+	SELECT tree_id, key_hash, MAX(map_revision) as maxrev
+	FROM map_leaf t0
+	WHERE t0.tree_id = $1 AND t0.key_hash > $2 AND t0.map_revision <= $3
+	GROUP BY t0.tree_id, t0.key_hash
+	ORDER BY t0.key_hash
+	LIMIT $4
+ ) t2
+ ON t1.tree_id=t2.tree_id
+ AND t1.key_hash=t2.key_hash
+ AND t1.map_revision=t2.maxrev
+ ORDER BY t1.key_hash`
+)
+
+var defaultMapStrata = []int{8, 8, 8, 8, 8, 8, 8, 8, 8, 8, 176}
+
+type pgMapStorage struct {
+	*pgTreeStorage
+	admin storage.AdminStorage
+}
+
+// NewMapStorage creates a storage.MapStorage instance for the specified PostgreSQL URL.
+// It assumes storage.AdminStorage is backed by the same PostgreSQL database as well.
+func NewMapStorage(db *sql.DB) storage.MapStorage {
+	return NewMapStorageWithOpts(db, TreeStorageOptions{})
+}
+
+// NewMapStorageWithOpts creates a storage.MapStorage instance for the specified
+// PostgreSQL URL, using options.
+func NewMapStorageWithOpts(db *sql.DB, opts TreeStorageOptions) storage.MapStorage {
+	return &pgMapStorage{
+		admin:         NewAdminStorageWithOpts(db, opts),
+		pgTreeStorage: newTreeStorage(db, opts),
+	}
+}
+
+func (m *pgMapStorage) CheckDatabaseAccessible(ctx context.Context) error {
+	return m.db.PingContext(ctx)
+}
+
+func (m *pgMapStorage) getMapLeafStmt(ctx context.Context, num int) (*sql.Stmt, error) {
+	stmt := &statementSkeleton{
+		sql:               selectMapLeafSQL,
+		firstInsertion:    "%s",
+		firstPlaceholders: 1,
+		restInsertion:     "%s",
+		restPlaceholders:  1,
+		num:               num,
+	}
+	return m.getStmt(ctx, stmt)
+}
+
+func (m *pgMapStorage) begin(ctx context.Context, tree *trillian.Tree, readonly bool) (storage.MapTreeTX, error) {
+	mtx, err := m.beginWith(ctx, tree, readonly, func(hashSizeBytes int, stCache *cache.SubtreeCache) (treeTX, error) {
+		return m.beginTreeTx(ctx, tree, hashSizeBytes, stCache, readonly)
+	})
+	if mtx == nil {
+		return nil, err
+	}
+	return mtx, err
+}
+
+// beginWith returns a mapTreeTX for tree, using newTX to create the underlying
+// treeTX.
+func (m *pgMapStorage) beginWith(ctx context.Context, tree *trillian.Tree, readonly bool, newTX func(int, *cache.SubtreeCache) (treeTX, error)) (*mapTreeTX, error) {
+	// TODO: Find a stronger way to ensure that tree has been pulled from storage.
+	// This is a cheap safety-belt check to help us use this API consistently.
+	if tree.UpdateTime == nil {
+		return nil, fmt.Errorf("tree.UpdateTime: %v. tree must be pulled from storage", tree.UpdateTime)
+	}
+	if got, want := tree.TreeType, trillian.TreeType_MAP; got != want {
+		return nil, fmt.Errorf("begin(tree.TreeType: %v), want %v", got, want)
+	}
+	hasher, err := m.opts.MapHashers.NewMapHasherForTree(tree)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := mapStorageSettings(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	stCache := cache.NewMapSubtreeCache(cache.MapStrata(defaultMapStrata, hasher.BitLen()), tree.TreeId, hasher)
+	ttx, err := newTX(hasher.Size(), stCache)
+	if err != nil {
+		return nil, err
+	}
+	mtx := &mapTreeTX{
+		treeTX:        ttx,
+		ms:            m,
+		readRevision:  -1,
+		leafHashIndex: settings.LeafHashIndex,
+	}
+
+	if readonly {
+		// readRevision will be set later, by the first
+		// GetSignedMapRoot/LatestSignedMapRoot operation.
+		return mtx, nil
+	}
+
+	// A read-write transaction needs to know the current revision
+	// so it can write at revision+1.
+	root, err := mtx.LatestSignedMapRoot(ctx)
+	if err != nil && err != storage.ErrTreeNeedsInit {
+		return nil, err
+	}
+	if err == storage.ErrTreeNeedsInit {
+		return mtx, err
+	}
+
+	var mr types.MapRootV1
+	if err := mr.UnmarshalBinary(root.MapRoot); err != nil {
+		return nil, err
+	}
+
+	mtx.readRevision = int64(mr.Revision)
+	mtx.treeTX.writeRevision = int64(mr.Revision) + 1
+	return mtx, nil
+}
+
+func (m *pgMapStorage) SnapshotForTree(ctx context.Context, tree *trillian.Tree) (storage.ReadOnlyMapTreeTX, error) {
+	return m.begin(ctx, tree, true /* readonly */)
+}
+
+func (m *pgMapStorage) ReadWriteTransaction(ctx context.Context, tree *trillian.Tree, f storage.MapTXFunc) error {
+	return m.opts.Dialect.retryTX(ctx, func() error {
+		tx, err := m.begin(ctx, tree, false /* readonly */)
+		if tx != nil {
+			defer tx.Close()
+		}
+		if err != nil && err != storage.ErrTreeNeedsInit {
+			return err
+		}
+		if err := f(ctx, tx); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	})
+}
+
+func (m *pgMapStorage) ReadWriteMultiTransaction(ctx context.Context, trees []*trillian.Tree, f storage.MultiMapTXFunc) error {
+	return m.opts.Dialect.retryTX(ctx, func() error {
+		return m.readWriteMultiTransaction(ctx, trees, f)
+	})
+}
+
+func (m *pgMapStorage) readWriteMultiTransaction(ctx context.Context, trees []*trillian.Tree, f storage.MultiMapTXFunc) error {
+	t, err := m.db.BeginTx(ctx, nil /* opts */)
+	if err != nil {
+		glog.Warningf("Could not start multi-map TX: %s", err)
+		return err
+	}
+	// All the mapTreeTXs run their queries in t, so they share a mutex, and
+	// are committed or rolled back together.
+	mu := &sync.Mutex{}
+	mtxs := make([]*mapTreeTX, 0, len(trees))
+	committed := false
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, mtx := range mtxs {
+			mtx.closed = true
+		}
+		if !committed {
+			if err := t.Rollback(); err != nil {
+				glog.Warningf("Multi-map TX rollback error: %v", err)
+			}
+		}
+	}()
+
+	if err := lockTrees(ctx, t, trees); err != nil {
+		return err
+	}
+
+	txs := make([]storage.MapTreeTX, 0, len(trees))
+	for _, tree := range trees {
+		tree := tree
+		mtx, err := m.beginWith(ctx, tree, false /* readonly */, func(hashSizeBytes int, stCache *cache.SubtreeCache) (treeTX, error) {
+			return m.newTreeTX(t, mu, tree, hashSizeBytes, stCache), nil
+		})
+		if err != nil && err != storage.ErrTreeNeedsInit {
+			return err
+		}
+		mtxs = append(mtxs, mtx)
+		txs = append(txs, mtx)
+	}
+	if err := f(ctx, txs); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, mtx := range mtxs {
+		if err := mtx.flushSubtrees(ctx); err != nil {
+			return err
+		}
+	}
+	committed = true
+	if err := t.Commit(); err != nil {
+		glog.Warningf("Multi-map TX commit error: %s", err)
+		return err
+	}
+	return nil
+}
+
+type mapTreeTX struct {
+	treeTX
+	ms           *pgMapStorage
+	readRevision int64
+	// leafHashIndex is set if the leaf hashes of the map are stored in the
+	// map_leaf_hash table.
+	leafHashIndex bool
+}
+
+func (m *mapTreeTX) ReadRevision(ctx context.Context) (int64, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	return int64(m.readRevision), nil
+}
+
+func (m *mapTreeTX) WriteRevision(ctx context.Context) (int64, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	if m.treeTX.writeRevision < 0 {
+		return m.treeTX.writeRevision, errors.New("mapTreeTX write revision not populated")
+	}
+	return m.treeTX.writeRevision, nil
+}
+
+func (m *mapTreeTX) Set(ctx context.Context, keyHash []byte, value *trillian.MapLeaf) error {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	flatValue, err := proto.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if _, err := m.tx.ExecContext(ctx, insertMapLeafSQL, m.treeID, keyHash, m.writeRevision, flatValue); err != nil {
+		return err
+	}
+	// Deleted leaves can't be looked up by hash.
+	if !m.leafHashIndex || len(value.LeafValue) == 0 {
+		return nil
+	}
+	_, err = m.tx.ExecContext(ctx, insertMapLeafHashSQL, m.treeID, value.LeafHash, keyHash, m.writeRevision)
+	return err
+}
+
+// Get returns a list of map leaves indicated by indexes.
+// If an index is not found, no corresponding entry is returned.
+// Each MapLeaf.Index is overwritten with the index the leaf was found at.
+func (m *mapTreeTX) Get(ctx context.Context, revision int64, indexes [][]byte) ([]*trillian.MapLeaf, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	// If no indexes are requested, return an empty set.
+	if len(indexes) == 0 {
+		return []*trillian.MapLeaf{}, nil
+	}
+	tmpl, err := m.ms.getMapLeafStmt(ctx, len(indexes))
+	if err != nil {
+		return nil, err
+	}
+	stx := m.tx.StmtContext(ctx, tmpl)
+	defer stx.Close()
+
+	args := make([]interface{}, 0, len(indexes)+2)
+	for _, index := range indexes {
+		args = append(args, index)
+	}
+	args = append(args, m.treeID)
+	args = append(args, revision)
+
+	rows, err := stx.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]*trillian.MapLeaf, 0, len(indexes))
+	for rows.Next() {
+		var mapKeyHash, flatData []byte
+		if err := rows.Scan(&mapKeyHash, &flatData); err != nil {
+			return nil, err
+		}
+		mapLeaf, err := unmarshalMapLeaf(flatData, mapKeyHash)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, mapLeaf)
+	}
+	return ret, rows.Err()
+}
+
+// List returns up to limit map leaves which exist at revision, ordered by
+// index, starting with the first index greater than after.
+func (m *mapTreeTX) List(ctx context.Context, revision int64, after []byte, limit int) ([]*trillian.MapLeaf, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	if limit <= 0 {
+		return []*trillian.MapLeaf{}, nil
+	}
+	if after == nil {
+		after = []byte{}
+	}
+
+	rows, err := m.tx.QueryContext(ctx, selectMapLeavesAfterSQL, m.treeID, after, revision, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]*trillian.MapLeaf, 0, limit)
+	for rows.Next() {
+		var mapKeyHash, flatData []byte
+		if err := rows.Scan(&mapKeyHash, &flatData); err != nil {
+			return nil, err
+		}
+		mapLeaf, err := unmarshalMapLeaf(flatData, mapKeyHash)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, mapLeaf)
+	}
+	return ret, rows.Err()
+}
+
+// ListLeafVersions returns all the versions of up to limit map leaves, ordered
+// by index and revision, starting with the first index greater than after.
+func (m *mapTreeTX) ListLeafVersions(ctx context.Context, after []byte, limit int) ([]storage.MapLeafVersion, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	if limit <= 0 {
+		return []storage.MapLeafVersion{}, nil
+	}
+	if after == nil {
+		after = []byte{}
+	}
+
+	rows, err := m.tx.QueryContext(ctx, selectMapLeafVersionsAfterSQL, m.treeID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]storage.MapLeafVersion, 0, limit)
+	for rows.Next() {
+		var mapKeyHash, flatData []byte
+		var revision int64
+		if err := rows.Scan(&mapKeyHash, &revision, &flatData); err != nil {
+			return nil, err
+		}
+		mapLeaf, err := unmarshalMapLeaf(flatData, mapKeyHash)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, storage.MapLeafVersion{Revision: revision, Leaf: mapLeaf})
+	}
+	return ret, rows.Err()
+}
+
+func (m *mapTreeTX) SetLeafHash(ctx context.Context, revision int64, index, leafHash []byte) error {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	// Leaves are stored as marshalled MapLeaf protos, so the hash can only be
+	// replaced by rewriting the whole leaf.
+	var flatData []byte
+	if err := m.tx.QueryRowContext(ctx, selectMapLeafVersionSQL, m.treeID, index, revision).Scan(&flatData); err != nil {
+		return err
+	}
+	mapLeaf, err := unmarshalMapLeaf(flatData, index)
+	if err != nil {
+		return err
+	}
+	mapLeaf.LeafHash = leafHash
+	if flatData, err = proto.Marshal(mapLeaf); err != nil {
+		return err
+	}
+	res, err := m.tx.ExecContext(ctx, updateMapLeafVersionSQL, flatData, m.treeID, index, revision)
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		return err
+	}
+	if !m.leafHashIndex {
+		return nil
+	}
+	// The version may have had no hash to index, so it is indexed afresh.
+	if _, err := m.tx.ExecContext(ctx, deleteMapLeafHashSQL, m.treeID, index, revision); err != nil {
+		return err
+	}
+	if len(mapLeaf.LeafValue) == 0 {
+		return nil
+	}
+	_, err = m.tx.ExecContext(ctx, insertMapLeafHashSQL, m.treeID, leafHash, index, revision)
+	return err
+}
+
+// GetIndexesByLeafHash returns the indexes of the leaves whose value at
+// revision has leafHash, ordered by index.
+func (m *mapTreeTX) GetIndexesByLeafHash(ctx context.Context, revision int64, leafHash []byte) ([][]byte, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	if !m.leafHashIndex {
+		return nil, storage.ErrNoLeafHashIndex
+	}
+	rows, err := m.tx.QueryContext(ctx, selectIndexesByLeafHashSQL, m.treeID, leafHash, revision)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes [][]byte
+	for rows.Next() {
+		var index []byte
+		if err := rows.Scan(&index); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, rows.Err()
+}
+
+func unmarshalMapLeaf(marshaledLeaf, mapKeyHash []byte) (*trillian.MapLeaf, error) {
+	if len(marshaledLeaf) == 0 {
+		return nil, errors.New("len(marshaledLeaf): 0 want > 0")
+	}
+	var mapLeaf trillian.MapLeaf
+	if err := proto.Unmarshal(marshaledLeaf, &mapLeaf); err != nil {
+		return nil, err
+	}
+	mapLeaf.Index = mapKeyHash
+	return &mapLeaf, nil
+}
+
+func (m *mapTreeTX) GetSignedMapRoot(ctx context.Context, revision int64) (*trillian.SignedMapRoot, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	var timestamp, mapRevision int64
+	var rootHash, rootSignatureBytes []byte
+	var mapperMetaBytes, additionalSignatures []byte
+
+	err := m.tx.QueryRowContext(ctx, selectGetSignedMapRootSQL, m.treeID, revision).Scan(
+		&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes, &additionalSignatures)
+	if err != nil {
+		if revision == 0 {
+			return nil, storage.ErrTreeNeedsInit
+		}
+		return nil, err
+	}
+	m.readRevision = mapRevision
+	return m.signedMapRoot(timestamp, mapRevision, rootHash, rootSignatureBytes, mapperMetaBytes, additionalSignatures)
+}
+
+func (m *mapTreeTX) LatestSignedMapRoot(ctx context.Context) (*trillian.SignedMapRoot, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	var timestamp, mapRevision int64
+	var rootHash, rootSignatureBytes []byte
+	var mapperMetaBytes, additionalSignatures []byte
+
+	err := m.tx.QueryRowContext(ctx, selectLatestSignedMapRootSQL, m.treeID).Scan(
+		&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes, &additionalSignatures)
+
+	// It's possible there are no roots for this tree yet
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrTreeNeedsInit
+	} else if err != nil {
+		return nil, err
+	}
+	m.readRevision = mapRevision
+	return m.signedMapRoot(timestamp, mapRevision, rootHash, rootSignatureBytes, mapperMetaBytes, additionalSignatures)
+}
+
+func (m *mapTreeTX) EarliestRevisionSince(ctx context.Context, ts time.Time) (int64, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	var rev sql.NullInt64
+	if err := m.tx.QueryRowContext(ctx, selectEarliestRevisionSinceSQL, ts.UnixNano(), m.treeID).Scan(&rev); err != nil {
+		return 0, err
+	}
+	// The aggregate is NULL only if there are no roots for this tree yet.
+	if !rev.Valid {
+		return 0, storage.ErrTreeNeedsInit
+	}
+	return rev.Int64, nil
+}
+
+// ListSignedMapRoots returns up to limit roots which match filter, ordered by
+// revision.
+func (m *mapTreeTX) ListSignedMapRoots(ctx context.Context, filter storage.MapRootFilter, limit int) ([]*trillian.SignedMapRoot, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	if limit <= 0 {
+		return []*trillian.SignedMapRoot{}, nil
+	}
+	query := selectMapHeadsInRangeSQL + "ASC LIMIT $6"
+	if filter.Descending {
+		query = selectMapHeadsInRangeSQL + "DESC LIMIT $6"
+	}
+	// Open bounds are replaced by the extremes of the column values.
+	endRev, startTS, endTS := filter.EndRevision, int64(0), int64(math.MaxInt64)
+	if endRev <= 0 {
+		endRev = math.MaxInt64
+	}
+	if !filter.StartTime.IsZero() {
+		startTS = filter.StartTime.UnixNano()
+	}
+	if !filter.EndTime.IsZero() {
+		endTS = filter.EndTime.UnixNano()
+	}
+
+	rows, err := m.tx.QueryContext(ctx, query, m.treeID, filter.StartRevision, endRev, startTS, endTS, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]*trillian.SignedMapRoot, 0, limit)
+	for rows.Next() {
+		var timestamp, mapRevision int64
+		var rootHash, rootSignatureBytes, mapperMetaBytes, additionalSignatures []byte
+		if err := rows.Scan(&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes, &additionalSignatures); err != nil {
+			return nil, err
+		}
+		root, err := m.signedMapRoot(timestamp, mapRevision, rootHash, rootSignatureBytes, mapperMetaBytes, additionalSignatures)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, root)
+	}
+	return ret, rows.Err()
+}
+
+func (m *mapTreeTX) GetIdempotencyToken(ctx context.Context, token []byte) (int64, bool, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	var rev int64
+	err := m.tx.QueryRowContext(ctx, selectMapIdempotencyTokenSQL, m.treeID, token).Scan(&rev)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	} else if err != nil {
+		glog.Warningf("Failed to read idempotency token: %s", err)
+		return 0, false, err
+	}
+	return rev, true, nil
+}
+
+func (m *mapTreeTX) StoreIdempotencyToken(ctx context.Context, token []byte) error {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	if _, err := m.tx.ExecContext(ctx, insertMapIdempotencyTokenSQL, m.treeID, token, m.writeRevision); err != nil {
+		glog.Warningf("Failed to store idempotency token: %s", err)
+		return err
+	}
+	return nil
+}
+
+func (m *mapTreeTX) GetMapRootSignatures(ctx context.Context, revision int64) ([]*trillian.MapRootSignature, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	rows, err := m.tx.QueryContext(ctx, selectMapRootSignaturesSQL, m.treeID, revision)
+	if err != nil {
+		glog.Warningf("Failed to read map root signatures: %s", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sigs []*trillian.MapRootSignature
+	for rows.Next() {
+		var der, sig []byte
+		if err := rows.Scan(&der, &sig); err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, &trillian.MapRootSignature{PublicKey: &keyspb.PublicKey{Der: der}, Signature: sig})
+	}
+	return sigs, rows.Err()
+}
+
+func (m *mapTreeTX) StoreMapRootSignature(ctx context.Context, revision int64, sig *trillian.MapRootSignature) error {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	der := sig.GetPublicKey().GetDer()
+	keyHash := sha256.Sum256(der)
+	if _, err := m.tx.ExecContext(ctx, insertMapRootSignatureSQL, m.treeID, revision, keyHash[:], der, sig.GetSignature()); err != nil {
+		glog.Warningf("Failed to store map root signature: %s", err)
+		return err
+	}
+	return nil
+}
+
+func (m *mapTreeTX) DeleteRevisionsBefore(ctx context.Context, revision int64) error {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	queries := []string{deleteMapHeadsBeforeSQL, deleteMapIdempotencyTokensBeforeSQL, deleteMapRootSignaturesBeforeSQL, deleteRecoveryMarkersBeforeSQL, deleteMapLeavesBeforeSQL, deleteSubtreesBeforeSQL}
+	if m.leafHashIndex {
+		queries = append(queries, deleteMapLeafHashesBeforeSQL)
+	}
+	for _, query := range queries {
+		if _, err := m.tx.ExecContext(ctx, query, m.treeID, revision); err != nil {
+			glog.Warningf("Failed to delete revisions before %d: %s", revision, err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mapTreeTX) signedMapRoot(timestamp, mapRevision int64, rootHash, rootSignature, mapperMeta, additionalSignatures []byte) (*trillian.SignedMapRoot, error) {
+	mapRoot, err := (&types.MapRootV1{
+		RootHash:       rootHash,
+		TimestampNanos: uint64(timestamp),
+		Revision:       uint64(mapRevision),
+		Metadata:       mapperMeta,
+	}).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	sigs, err := unmarshalRootSignatures(additionalSignatures)
+	if err != nil {
+		return nil, err
+	}
+
+	return &trillian.SignedMapRoot{
+		MapRoot:              mapRoot,
+		Signature:            rootSignature,
+		AdditionalSignatures: sigs,
+	}, nil
+}
+
+func (m *mapTreeTX) StoreSignedMapRoot(ctx context.Context, root *trillian.SignedMapRoot) error {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	var r types.MapRootV1
+	if err := r.UnmarshalBinary(root.MapRoot); err != nil {
+		return err
+	}
+	additionalSignatures, err := marshalRootSignatures(root.AdditionalSignatures)
+	if err != nil {
+		return err
+	}
+
+	// TODO(al): store transactionLogHead too
+	res, err := m.tx.ExecContext(ctx, insertMapHeadSQL, m.treeID, r.TimestampNanos, r.RootHash, r.Revision, root.Signature, r.Metadata, additionalSignatures)
+	if err != nil {
+		glog.Warningf("Failed to store signed map root: %s", err)
+	}
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		return err
+	}
+
+	return m.storeRecoveryMarker(ctx, int64(r.Revision))
+}
+
+// lockTrees locks the rows of trees in t. The rows are locked in increasing
+// order of tree ID whatever the order of trees, so that two transactions
+// spanning the same trees can't deadlock.
+func lockTrees(ctx context.Context, t *sql.Tx, trees []*trillian.Tree) error {
+	ids := make([]int64, 0, len(trees))
+	seen := make(map[int64]bool)
+	for _, tree := range trees {
+		if !seen[tree.TreeId] {
+			seen[tree.TreeId] = true
+			ids = append(ids, tree.TreeId)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		var got int64
+		if err := t.QueryRowContext(ctx, lockTreeSQL, id).Scan(&got); err != nil {
+			glog.Warningf("Could not lock tree %d: %s", id, err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/integration/storagetest"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/types"
+
+	tcrypto "github.com/google/trillian/crypto"
+	storageto "github.com/google/trillian/storage/testonly"
+)
+
+var keyHash = []byte("A Key Hash")
+
+func TestMapIntegration(t *testing.T) {
+	storageFactory := func(context.Context, *testing.T) (storage.MapStorage, storage.AdminStorage) {
+		cleanTestDB(db, t)
+		return NewMapStorage(db), NewAdminStorage(db)
+	}
+
+	storagetest.RunMapStorageTests(t, storageFactory)
+}
+
+func TestMapWriteQueue(t *testing.T) {
+	cleanTestDB(db, t)
+	ctx := context.Background()
+	s := NewMapStorage(db)
+	tree := createInitializedMapForTests(ctx, t, s)
+
+	queued := make([]*storage.QueuedMapWrite, 3)
+	for i := range queued {
+		queued[i] = &storage.QueuedMapWrite{
+			Leaves:         []*trillian.MapLeaf{{Index: keyHash, LeafValue: []byte{byte(i)}}},
+			Metadata:       []byte{byte(i)},
+			QueueTimestamp: time.Unix(0, int64(i)),
+		}
+		if err := s.(storage.MapWriteQueuer).QueueMapWrite(ctx, tree, queued[i]); err != nil {
+			t.Fatalf("QueueMapWrite(): %v", err)
+		}
+	}
+
+	dequeue := func(limit int) []*storage.QueuedMapWrite {
+		t.Helper()
+		var writes []*storage.QueuedMapWrite
+		if err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+			var err error
+			writes, err = tx.(storage.MapWriteDequeuer).DequeueMapWrites(ctx, limit)
+			return err
+		}); err != nil {
+			t.Fatalf("DequeueMapWrites(): %v", err)
+		}
+		return writes
+	}
+	for _, want := range [][]*storage.QueuedMapWrite{queued[:2], queued[2:], nil} {
+		got := dequeue(2)
+		if len(got) != len(want) {
+			t.Fatalf("DequeueMapWrites(): got %d writes, want %d", len(got), len(want))
+		}
+		for i := range got {
+			if !proto.Equal(got[i].Leaves[0], want[i].Leaves[0]) || !bytes.Equal(got[i].Metadata, want[i].Metadata) || !got[i].QueueTimestamp.Equal(want[i].QueueTimestamp) {
+				t.Errorf("DequeueMapWrites()[%d]=%+v, want %+v", i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestMapRevisionLease(t *testing.T) {
+	cleanTestDB(db, t)
+	ctx := context.Background()
+	s := NewMapStorage(db)
+	tree := createInitializedMapForTests(ctx, t, s)
+	r := s.(storage.MapRevisionReserver)
+
+	now := time.Unix(100, 0)
+	reserve := func(token string, expiry time.Time) int64 {
+		t.Helper()
+		rev, err := r.ReserveMapRevision(ctx, tree, []byte(token), now, expiry)
+		if err != nil {
+			t.Fatalf("ReserveMapRevision(%q): %v", token, err)
+		}
+		return rev
+	}
+	// Each reservation gets the revision after the last one, until the
+	// earliest expires.
+	if got, want := reserve("a", now.Add(time.Second)), int64(1); got != want {
+		t.Errorf("ReserveMapRevision(a)=%v, want %v", got, want)
+	}
+	if got, want := reserve("b", now.Add(time.Minute)), int64(2); got != want {
+		t.Errorf("ReserveMapRevision(b)=%v, want %v", got, want)
+	}
+
+	lease, err := r.GetMapRevisionLease(ctx, tree, 1, now)
+	if err != nil {
+		t.Fatalf("GetMapRevisionLease(): %v", err)
+	}
+	if lease == nil || string(lease.Token) != "a" || !lease.Expiry.Equal(now.Add(time.Second)) {
+		t.Errorf("GetMapRevisionLease(1)=%+v, want lease of a", lease)
+	}
+
+	now = now.Add(2 * time.Second)
+	if lease, err := r.GetMapRevisionLease(ctx, tree, 1, now); err != nil || lease != nil {
+		t.Errorf("GetMapRevisionLease(1) after expiry=%+v, %v, want nil", lease, err)
+	}
+	if got, want := reserve("c", now.Add(time.Minute)), int64(3); got != want {
+		t.Errorf("ReserveMapRevision(c)=%v, want %v", got, want)
+	}
+}
+
+// createInitializedMapForTests creates a map and stores its revision 0 root.
+func createInitializedMapForTests(ctx context.Context, t *testing.T, s storage.MapStorage) *trillian.Tree {
+	t.Helper()
+	tree, err := createTree(db, storageto.MapTree)
+	if err != nil {
+		t.Fatalf("createTree(): %v", err)
+	}
+
+	signer := tcrypto.NewSigner(tree.TreeId, testonly.NewSignerWithFixedSig(nil, []byte("sig")), crypto.SHA256)
+	if err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		initialRoot, err := signer.SignMapRoot(&types.MapRootV1{RootHash: []byte("rootHash")})
+		if err != nil {
+			return err
+		}
+		return tx.StoreSignedMapRoot(ctx, initialRoot)
+	}); err != nil {
+		t.Fatalf("ReadWriteTransaction(): %v", err)
+	}
+	return tree
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+)
+
+const (
+	insertMapWriteSQL = `INSERT INTO map_write_queue(tree_id, queue_timestamp_nanos, leaves, metadata)
+		 VALUES($1, $2, $3, $4)`
+	// selectMapWritesSQL locks the rows it returns, so that concurrent merges
+	// of the same map wait for each other rather than merging a write twice.
+	selectMapWritesSQL = `SELECT queue_id, queue_timestamp_nanos, leaves, metadata
+		 FROM map_write_queue WHERE tree_id=$1
+		 ORDER BY queue_id LIMIT $2 FOR UPDATE`
+	deleteMapWritesSQL = `DELETE FROM map_write_queue WHERE queue_id IN (` + placeholderSQL + `) AND tree_id=<param>`
+)
+
+func (m *pgMapStorage) getDeleteMapWritesStmt(ctx context.Context, num int) (*sql.Stmt, error) {
+	stmt := &statementSkeleton{
+		sql:               deleteMapWritesSQL,
+		firstInsertion:    "%s",
+		firstPlaceholders: 1,
+		restInsertion:     "%s",
+		restPlaceholders:  1,
+		num:               num,
+	}
+	return m.getStmt(ctx, stmt)
+}
+
+func (m *pgMapStorage) QueueMapWrite(ctx context.Context, tree *trillian.Tree, w *storage.QueuedMapWrite) error {
+	leaves, err := proto.Marshal(&trillian.MapLeaves{Leaves: w.Leaves})
+	if err != nil {
+		return err
+	}
+	if _, err := m.db.ExecContext(ctx, insertMapWriteSQL, tree.TreeId, w.QueueTimestamp.UnixNano(), leaves, w.Metadata); err != nil {
+		glog.Warningf("Failed to queue map write: %s", err)
+		return err
+	}
+	return nil
+}
+
+func (m *mapTreeTX) DequeueMapWrites(ctx context.Context, limit int) ([]*storage.QueuedMapWrite, error) {
+	m.treeTX.mu.Lock()
+	defer m.treeTX.mu.Unlock()
+
+	writes, ids, err := m.readMapWrites(ctx, limit)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	tmpl, err := m.ms.getDeleteMapWritesStmt(ctx, len(ids))
+	if err != nil {
+		return nil, err
+	}
+	stx := m.tx.StmtContext(ctx, tmpl)
+	defer stx.Close()
+	args := append(ids, m.treeID)
+	if _, err := stx.ExecContext(ctx, args...); err != nil {
+		glog.Warningf("Failed to delete queued map writes: %s", err)
+		return nil, err
+	}
+	return writes, nil
+}
+
+func (m *mapTreeTX) readMapWrites(ctx context.Context, limit int) ([]*storage.QueuedMapWrite, []interface{}, error) {
+	rows, err := m.tx.QueryContext(ctx, selectMapWritesSQL, m.treeID, limit)
+	if err != nil {
+		glog.Warningf("Failed to read queued map writes: %s", err)
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var writes []*storage.QueuedMapWrite
+	var ids []interface{}
+	for rows.Next() {
+		var id, ts int64
+		var leavesBytes, metadata []byte
+		if err := rows.Scan(&id, &ts, &leavesBytes, &metadata); err != nil {
+			return nil, nil, err
+		}
+		var leaves trillian.MapLeaves
+		if err := proto.Unmarshal(leavesBytes, &leaves); err != nil {
+			return nil, nil, err
+		}
+		writes = append(writes, &storage.QueuedMapWrite{
+			Leaves:         leaves.Leaves,
+			Metadata:       metadata,
+			QueueTimestamp: time.Unix(0, ts),
+		})
+		ids = append(ids, id)
+	}
+	return writes, ids, rows.Err()
+}
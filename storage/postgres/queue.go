@@ -38,6 +38,11 @@ const (
                         ORDER BY queue_timestamp_nanos,leaf_identity_hash ASC LIMIT $3`
 	insertUnsequencedEntrySQL = "select insert_leaf_data_ignore_duplicates($1,$2,$3,$4)"
 	deleteUnsequencedSQL      = "DELETE FROM unsequenced WHERE tree_id = $1 and bucket=0 and queue_timestamp_nanos = $2 and leaf_identity_hash=$3"
+
+	// crdbInsertUnsequencedEntrySQL replaces insertUnsequencedEntrySQL for
+	// CockroachDB, which doesn't support the function.
+	crdbInsertUnsequencedEntrySQL = `INSERT INTO unsequenced(tree_id,bucket,leaf_identity_hash,merkle_leaf_hash,queue_timestamp_nanos)
+		 VALUES($1,0,$2,$3,$4) ON CONFLICT DO NOTHING`
 )
 
 type dequeuedLeaf struct {
@@ -92,7 +97,7 @@ func (t *logTreeTX) UpdateSequencedLeaves(ctx context.Context, leaves []*trillia
 		}
 		_, err = t.tx.ExecContext(
 			ctx,
-			insertSequencedLeafSQL+valuesPlaceholder5,
+			insertSequencedLeavesSQL+valuesPlaceholder5,
 			t.treeID,
 			leaf.LeafIdentityHash,
 			leaf.MerkleLeafHash,
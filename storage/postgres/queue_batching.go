@@ -40,6 +40,8 @@ const (
                         ORDER BY queue_timestamp_nanos,leaf_identity_hash ASC LIMIT $3`
 	insertUnsequencedEntrySQL = `INSERT INTO unsequenced(tree_id,Bucket,leaf_identity_hash,merkle_leaf_hash,queue_timestamp_nanos,queue_id) VALUES($1,0,$2,$3,$4,$5)`
 	deleteUnsequencedSQL      = "DELETE FROM unsequenced WHERE queue_id IN (<placeholder>)"
+	// CockroachDB supports insertUnsequencedEntrySQL as it is.
+	crdbInsertUnsequencedEntrySQL = insertUnsequencedEntrySQL
 )
 
 type dequeuedLeaf []byte
@@ -100,10 +102,11 @@ func (t *logTreeTX) UpdateSequencedLeaves(ctx context.Context, leaves []*trillia
 		if err != nil {
 			return fmt.Errorf("got invalid integrate timestamp: %v", err)
 		}
-		querySuffix = append(querySuffix, valuesPlaceholder5)
+		n := len(args)
+		querySuffix = append(querySuffix, fmt.Sprintf("($%d,$%d,$%d,$%d,$%d)", n+1, n+2, n+3, n+4, n+5))
 		args = append(args, t.treeID, leaf.LeafIdentityHash, leaf.MerkleLeafHash, leaf.LeafIndex, iTimestamp.UnixNano())
 	}
-	result, err := t.tx.ExecContext(ctx, insertSequencedLeafSQL+strings.Join(querySuffix, ","), args...)
+	result, err := t.tx.ExecContext(ctx, insertSequencedLeavesSQL+strings.Join(querySuffix, ","), args...)
 	if err != nil {
 		glog.Warningf("Failed to update sequenced leaves: %s", err)
 	}
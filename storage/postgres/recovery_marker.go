@@ -0,0 +1,77 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// insertRecoveryMarkerSQL records the current write-ahead log location
+	// as the position of the marker.
+	insertRecoveryMarkerSQL = `INSERT INTO recovery_marker(tree_id, revision, position)
+		 VALUES($1, $2, pg_current_wal_lsn()::text)`
+	selectRecoveryMarkerSQL = `SELECT revision, position FROM recovery_marker
+		 WHERE tree_id=$1 AND revision=$2`
+	selectLatestRecoveryMarkerSQL = `SELECT revision, position FROM recovery_marker
+		 WHERE tree_id=$1 ORDER BY revision DESC LIMIT 1`
+	deleteRecoveryMarkersBeforeSQL = `DELETE FROM recovery_marker WHERE tree_id=$1 AND revision<$2`
+)
+
+// storeRecoveryMarker records the current write-ahead log location as the
+// recovery marker of the root at revision. The caller must hold t.mu.
+//
+// The location is read before the transaction commits, so recovering the
+// database up to that location, e.g. with recovery_target_lsn, does not
+// include the root. CockroachDB records the commit timestamp instead.
+func (t *treeTX) storeRecoveryMarker(ctx context.Context, revision int64) error {
+	if _, err := t.tx.ExecContext(ctx, t.ts.opts.Dialect.statement(insertRecoveryMarkerSQL), t.treeID, revision); err != nil {
+		glog.Warningf("Failed to store recovery marker: %s", err)
+		return err
+	}
+	return nil
+}
+
+// GetRecoveryMarker implements storage.RecoveryMarkerReader.
+func (t *treeTX) GetRecoveryMarker(ctx context.Context, revision int64) (*storage.RecoveryMarker, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.readRecoveryMarker(ctx, selectRecoveryMarkerSQL, t.treeID, revision)
+}
+
+// LatestRecoveryMarker implements storage.RecoveryMarkerReader.
+func (t *treeTX) LatestRecoveryMarker(ctx context.Context) (*storage.RecoveryMarker, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.readRecoveryMarker(ctx, selectLatestRecoveryMarkerSQL, t.treeID)
+}
+
+func (t *treeTX) readRecoveryMarker(ctx context.Context, query string, args ...interface{}) (*storage.RecoveryMarker, error) {
+	m := &storage.RecoveryMarker{TreeID: t.treeID}
+	err := t.tx.QueryRowContext(ctx, query, args...).Scan(&m.Revision, &m.Position)
+	if err == sql.ErrNoRows {
+		return nil, status.Errorf(codes.NotFound, "no recovery marker for tree %d", t.treeID)
+	} else if err != nil {
+		glog.Warningf("Failed to read recovery marker: %s", err)
+		return nil, err
+	}
+	return m, nil
+}
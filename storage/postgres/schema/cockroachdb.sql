@@ -0,0 +1,295 @@
+-- CockroachDB impl of storage, for the CockroachDB dialect of the Postgres
+-- storage. It's the Postgres schema without the functions CockroachDB doesn't
+-- support, which the dialect does without.
+-- ---------------------------------------------
+-- Tree stuff here
+-- ---------------------------------------------
+
+-- Tree Enums
+CREATE TYPE E_TREE_STATE AS ENUM('ACTIVE', 'FROZEN', 'DRAINING', 'QUARANTINED', 'PAUSED');--end
+CREATE TYPE E_TREE_TYPE AS ENUM('LOG', 'MAP', 'PREORDERED_LOG');--end
+CREATE TYPE E_HASH_STRATEGY AS ENUM('RFC6962_SHA256', 'TEST_MAP_HASHER', 'OBJECT_RFC6962_SHA256', 'CONIKS_SHA512_256', 'CONIKS_SHA256');--end
+CREATE TYPE E_HASH_ALGORITHM AS ENUM('SHA256');--end
+CREATE TYPE E_SIGNATURE_ALGORITHM AS ENUM('ECDSA', 'RSA');--end
+
+-- Tree parameters should not be changed after creation. Doing so can
+-- render the data in the tree unusable or inconsistent.
+CREATE TABLE IF NOT EXISTS trees (
+  tree_id                  BIGINT NOT NULL,
+  tree_state               E_TREE_STATE NOT NULL,
+  tree_type                E_TREE_TYPE NOT NULL,
+  hash_strategy            E_HASH_STRATEGY NOT NULL,
+  hash_algorithm           E_HASH_ALGORITHM NOT NULL,
+  signature_algorithm      E_SIGNATURE_ALGORITHM NOT NULL,
+  display_name             VARCHAR(20),
+  description              VARCHAR(200),
+  create_time_millis       BIGINT NOT NULL,
+  update_time_millis       BIGINT NOT NULL,
+  max_root_duration_millis BIGINT NOT NULL,
+  private_key              BYTEA NOT NULL,
+  public_key               BYTEA NOT NULL,
+  deleted                  BOOLEAN NOT NULL DEFAULT FALSE,
+  delete_time_millis       BIGINT,
+  -- Revision retention policy of maps. Zero values mean no limit.
+  retain_revisions         BIGINT NOT NULL DEFAULT 0,
+  retain_duration_millis   BIGINT NOT NULL DEFAULT 0,
+  -- Marshalled google.protobuf.Any holding the storage_settings of the tree.
+  storage_settings         BYTEA,
+  -- Number of bits of the indices of a map. Zero means the hash length.
+  map_index_bits           INTEGER NOT NULL DEFAULT 0,
+  -- Number of the trillian.DuplicateLeafPolicy of a log.
+  duplicate_leaf_policy    INTEGER NOT NULL DEFAULT 0,
+  -- Marshalled storagepb.AdditionalKeys holding the keys which also sign the
+  -- roots of the tree, if any.
+  additional_keys          BYTEA,
+  -- Number of keys whose signatures roots need. Zero means all of them.
+  signature_threshold      INTEGER NOT NULL DEFAULT 0,
+  -- Marshalled storagepb.TreeLabels holding the labels of the tree, if any.
+  labels                   BYTEA,
+  -- Period the tree is kept after being soft-deleted. Zero means the default.
+  delete_retention_millis  BIGINT NOT NULL DEFAULT 0,
+  -- Marshalled trillian.KeyRotation of the tree, if it is rotating its key.
+  key_rotation             BYTEA,
+  -- Rates of the trillian.TreeQuota of the tree. Zero means unlimited.
+  read_tokens_per_second   BIGINT NOT NULL DEFAULT 0,
+  write_tokens_per_second  BIGINT NOT NULL DEFAULT 0,
+  -- Principal which owns the tree, or empty if it has no owner.
+  owner                    VARCHAR(255) NOT NULL DEFAULT '',
+  PRIMARY KEY(tree_id)
+);--end
+
+-- This table contains tree parameters that can be changed at runtime such as for
+-- administrative purposes.
+CREATE TABLE IF NOT EXISTS tree_control(
+  tree_id                   BIGINT NOT NULL,
+  signing_enabled           BOOLEAN NOT NULL,
+  sequencing_enabled        BOOLEAN NOT NULL,
+  sequence_interval_seconds INTEGER NOT NULL,
+  PRIMARY KEY(tree_id),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+CREATE TABLE IF NOT EXISTS subtree(
+  tree_id               BIGINT NOT NULL,
+  subtree_id            BYTEA NOT NULL,
+  nodes                 BYTEA NOT NULL,
+  subtree_revision      INTEGER NOT NULL,
+  PRIMARY KEY(tree_id, subtree_id, subtree_revision),
+  FOREIGN KEY(tree_id) REFERENCES Trees(tree_id) ON DELETE CASCADE
+);--end
+
+-- The TreeRevisionIdx is used to enforce that there is only one STH at any
+-- tree revision
+CREATE TABLE IF NOT EXISTS tree_head(
+  tree_id                BIGINT NOT NULL,
+  tree_head_timestamp    BIGINT,
+  tree_size              BIGINT,
+  root_hash              BYTEA NOT NULL,
+  root_signature         BYTEA NOT NULL,
+  tree_revision          BIGINT,
+  -- Marshalled storagepb.RootSignatures by the additional keys of the tree.
+  additional_signatures  BYTEA,
+  PRIMARY KEY(tree_id, tree_revision),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+-- TODO(vishal) benchmark this to see if it's a suitable replacement for not
+-- having a DESC scan on the primary key
+CREATE UNIQUE INDEX TreeHeadRevisionIdx ON tree_head(tree_id, tree_revision DESC);--end
+
+-- Used to check that root hashes were published by a log.
+CREATE INDEX TreeHeadRootHashIdx ON tree_head(tree_id, root_hash);--end
+
+-- Signatures of third-party witnesses over log roots, at most one per public
+-- key and revision. key_hash is the SHA-256 hash of public_key.
+CREATE TABLE IF NOT EXISTS log_root_signature(
+  tree_id                BIGINT NOT NULL,
+  tree_revision          BIGINT NOT NULL,
+  key_hash               BYTEA NOT NULL,
+  public_key             BYTEA NOT NULL,
+  signature              BYTEA NOT NULL,
+  PRIMARY KEY(tree_id, tree_revision, key_hash),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+-- A recovery marker is stored in the same transaction as each log or map root,
+-- recording the write-ahead log location at which the root was published.
+CREATE TABLE IF NOT EXISTS recovery_marker(
+  tree_id                BIGINT NOT NULL,
+  revision               BIGINT NOT NULL,
+  position               VARCHAR(255) NOT NULL,
+  PRIMARY KEY(tree_id, revision),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+-- ---------------------------------------------
+-- Log specific stuff here
+-- ---------------------------------------------
+
+-- Creating index at same time as table allows some storage engines to better
+-- optimize physical storage layout. Most engines allow multiple nulls in a
+-- unique index but some may not.
+
+-- A leaf that has not been sequenced has a row in this table. If duplicate leaves
+-- are allowed they will all reference this row.
+CREATE TABLE IF NOT EXISTS leaf_data(
+  tree_id               BIGINT NOT NULL,
+  -- This is a personality specific hash of some subset of the leaf data.
+  -- It's only purpose is to allow Trillian to identify duplicate entries in
+  -- the context of the personality.
+  leaf_identity_hash     BYTEA NOT NULL,
+  -- This is the data stored in the leaf for example in CT it contains a DER encoded
+  -- X.509 certificate but is application dependent
+  leaf_value            BYTEA NOT NULL,
+  -- This is extra data that the application can associate with the leaf should it wish to.
+  -- This data is not included in signing and hashing.
+  extra_data            BYTEA,
+  -- The timestamp from when this leaf data was first queued for inclusion.
+  queue_timestamp_nanos  BIGINT NOT NULL,
+  PRIMARY KEY(tree_id, leaf_identity_hash),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+-- When a leaf is sequenced a row is added to this table. If logs allow duplicates then
+-- multiple rows will exist with different sequence numbers. The signed timestamp
+-- will be communicated via the unsequenced table as this might need to be unique, depending
+-- on the log parameters and we can't insert into this table until we have the sequence number
+-- which is not available at the time we queue the entry. We need both hashes because the
+-- LeafData table is keyed by the raw data hash.
+CREATE TABLE IF NOT EXISTS sequenced_leaf_data(
+  tree_id                   BIGINT NOT NULL,
+  sequence_number           BIGINT NOT NULL,
+  -- This is a personality specific has of some subset of the leaf data.
+  -- It's only purpose is to allow Trillian to identify duplicate entries in
+  -- the context of the personality.
+  leaf_identity_hash        BYTEA NOT NULL,
+  -- This is a MerkleLeafHash as defined by the treehasher that the log uses. For example for
+  -- CT this hash will include the leaf prefix byte as well as the leaf data.
+  merkle_leaf_hash          BYTEA NOT NULL,
+  integrate_timestamp_nanos BIGINT NOT NULL,
+  PRIMARY KEY(tree_id, sequence_number),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE,
+  FOREIGN KEY(tree_id, leaf_identity_hash) REFERENCES leaf_data(tree_id, leaf_identity_hash) ON DELETE CASCADE
+);--end
+
+CREATE INDEX SequencedLeafMerkleIdx ON sequenced_leaf_data(tree_id, merkle_leaf_hash);--end
+
+CREATE TABLE IF NOT EXISTS unsequenced(
+  tree_id               BIGINT NOT NULL,
+  -- The bucket field is to allow the use of time based ring bucketed schemes if desired. If
+  -- unused this should be set to zero for all entries.
+  bucket                INTEGER NOT NULL,
+  -- This is a personality specific hash of some subset of the leaf data.
+  -- It's only purpose is to allow Trillian to identify duplicate entries in
+  -- the context of the personality.
+  leaf_identity_hash    BYTEA NOT NULL,
+  -- This is a MerkleLeafHash as defined by the treehasher that the log uses. For example for
+  -- CT this hash will include the leaf prefix byte as well as the leaf data.
+  merkle_leaf_hash      BYTEA NOT NULL,
+  queue_timestamp_nanos BIGINT NOT NULL,
+  -- This is a SHA256 hash of the TreeID, LeafIdentityHash and QueueTimestampNanos. It is used
+  -- for batched deletes from the table when trillian_log_server and trillian_log_signer are
+  -- built with the batched_queue tag.
+  queue_id              BYTEA DEFAULT NULL UNIQUE,
+  PRIMARY KEY (tree_id, bucket, queue_timestamp_nanos, leaf_identity_hash)
+);--end
+
+-- ---------------------------------------------
+-- Map specific stuff here
+-- ---------------------------------------------
+
+CREATE TABLE IF NOT EXISTS map_leaf(
+  tree_id                BIGINT NOT NULL,
+  key_hash               BYTEA NOT NULL,
+  map_revision           BIGINT NOT NULL,
+  leaf_value             BYTEA NOT NULL,
+  PRIMARY KEY(tree_id, key_hash, map_revision),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+-- Lookup from leaf hashes to the versions of the map leaves which have them.
+-- Only written for maps with leaf_hash_index set in their storage_settings.
+CREATE TABLE IF NOT EXISTS map_leaf_hash(
+  tree_id                BIGINT NOT NULL,
+  leaf_hash              BYTEA NOT NULL,
+  key_hash               BYTEA NOT NULL,
+  map_revision           BIGINT NOT NULL,
+  PRIMARY KEY(tree_id, leaf_hash, key_hash, map_revision),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+CREATE TABLE IF NOT EXISTS map_head(
+  tree_id                BIGINT NOT NULL,
+  map_head_timestamp     BIGINT,
+  root_hash              BYTEA NOT NULL,
+  map_revision           BIGINT,
+  root_signature         BYTEA NOT NULL,
+  mapper_data            BYTEA,
+  -- Marshalled storagepb.RootSignatures by the additional keys of the tree.
+  additional_signatures  BYTEA,
+  PRIMARY KEY(tree_id, map_head_timestamp),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+CREATE UNIQUE INDEX MapHeadRevisionIdx ON map_head(tree_id, map_revision);--end
+
+-- Idempotency tokens of map writes, so that retried writes can be detected.
+CREATE TABLE IF NOT EXISTS map_idempotency_token(
+  tree_id                BIGINT NOT NULL,
+  token                  BYTEA NOT NULL,
+  map_revision           BIGINT NOT NULL,
+  PRIMARY KEY(tree_id, token),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+-- Signatures of third-party witnesses over map roots, at most one per public
+-- key and revision. key_hash is the SHA-256 hash of public_key.
+CREATE TABLE IF NOT EXISTS map_root_signature(
+  tree_id                BIGINT NOT NULL,
+  map_revision           BIGINT NOT NULL,
+  key_hash               BYTEA NOT NULL,
+  public_key             BYTEA NOT NULL,
+  signature              BYTEA NOT NULL,
+  PRIMARY KEY(tree_id, map_revision, key_hash),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+-- Map writes queued by WriteLeaves, until the map merger folds them into a
+-- new revision of the map.
+-- queue_id comes from a sequence, as the SERIAL columns of CockroachDB are
+-- unique but not ordered.
+CREATE SEQUENCE IF NOT EXISTS map_write_queue_id;--end
+
+CREATE TABLE IF NOT EXISTS map_write_queue(
+  tree_id                BIGINT NOT NULL,
+  queue_id               BIGINT NOT NULL DEFAULT nextval('map_write_queue_id'),
+  queue_timestamp_nanos  BIGINT NOT NULL,
+  -- leaves holds a serialized trillian.MapLeaves message.
+  leaves                 BYTEA NOT NULL,
+  metadata               BYTEA,
+  PRIMARY KEY(queue_id),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+CREATE INDEX MapWriteQueueTreeIdx ON map_write_queue(tree_id, queue_id);--end
+
+-- Write revisions of maps reserved by ReserveRevision, until their lease
+-- expires or the revision is written.
+CREATE TABLE IF NOT EXISTS map_revision_lease(
+  tree_id                BIGINT NOT NULL,
+  revision               BIGINT NOT NULL,
+  token                  BYTEA NOT NULL,
+  expiry_nanos           BIGINT NOT NULL,
+  PRIMARY KEY(tree_id, revision),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+-- The version of this schema, which trillian_migrate upgrades. Databases
+-- created before schemas were versioned lack this table, and have to be
+-- baselined with trillian_migrate.
+CREATE TABLE IF NOT EXISTS schema_version(
+  version                INTEGER NOT NULL
+);--end
+
+INSERT INTO schema_version(version)
+  SELECT 2 WHERE NOT EXISTS (SELECT * FROM schema_version);--end
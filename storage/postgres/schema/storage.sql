@@ -4,7 +4,7 @@
 -- ---------------------------------------------
 
 -- Tree Enums
-CREATE TYPE E_TREE_STATE AS ENUM('ACTIVE', 'FROZEN', 'DRAINING');--end
+CREATE TYPE E_TREE_STATE AS ENUM('ACTIVE', 'FROZEN', 'DRAINING', 'QUARANTINED', 'PAUSED');--end
 CREATE TYPE E_TREE_TYPE AS ENUM('LOG', 'MAP', 'PREORDERED_LOG');--end
 CREATE TYPE E_HASH_STRATEGY AS ENUM('RFC6962_SHA256', 'TEST_MAP_HASHER', 'OBJECT_RFC6962_SHA256', 'CONIKS_SHA512_256', 'CONIKS_SHA256');--end
 CREATE TYPE E_HASH_ALGORITHM AS ENUM('SHA256');--end
@@ -28,8 +28,31 @@ CREATE TABLE IF NOT EXISTS trees (
   public_key               BYTEA NOT NULL,
   deleted                  BOOLEAN NOT NULL DEFAULT FALSE,
   delete_time_millis       BIGINT,
-  current_tree_data	   json,
-  root_signature	   BYTEA,
+  -- Revision retention policy of maps. Zero values mean no limit.
+  retain_revisions         BIGINT NOT NULL DEFAULT 0,
+  retain_duration_millis   BIGINT NOT NULL DEFAULT 0,
+  -- Marshalled google.protobuf.Any holding the storage_settings of the tree.
+  storage_settings         BYTEA,
+  -- Number of bits of the indices of a map. Zero means the hash length.
+  map_index_bits           INTEGER NOT NULL DEFAULT 0,
+  -- Number of the trillian.DuplicateLeafPolicy of a log.
+  duplicate_leaf_policy    INTEGER NOT NULL DEFAULT 0,
+  -- Marshalled storagepb.AdditionalKeys holding the keys which also sign the
+  -- roots of the tree, if any.
+  additional_keys          BYTEA,
+  -- Number of keys whose signatures roots need. Zero means all of them.
+  signature_threshold      INTEGER NOT NULL DEFAULT 0,
+  -- Marshalled storagepb.TreeLabels holding the labels of the tree, if any.
+  labels                   BYTEA,
+  -- Period the tree is kept after being soft-deleted. Zero means the default.
+  delete_retention_millis  BIGINT NOT NULL DEFAULT 0,
+  -- Marshalled trillian.KeyRotation of the tree, if it is rotating its key.
+  key_rotation             BYTEA,
+  -- Rates of the trillian.TreeQuota of the tree. Zero means unlimited.
+  read_tokens_per_second   BIGINT NOT NULL DEFAULT 0,
+  write_tokens_per_second  BIGINT NOT NULL DEFAULT 0,
+  -- Principal which owns the tree, or empty if it has no owner.
+  owner                    VARCHAR(255) NOT NULL DEFAULT '',
   PRIMARY KEY(tree_id)
 );--end
 
@@ -62,6 +85,8 @@ CREATE TABLE IF NOT EXISTS tree_head(
   root_hash              BYTEA NOT NULL,
   root_signature         BYTEA NOT NULL,
   tree_revision          BIGINT,
+  -- Marshalled storagepb.RootSignatures by the additional keys of the tree.
+  additional_signatures  BYTEA,
   PRIMARY KEY(tree_id, tree_revision),
   FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
 );--end
@@ -70,6 +95,31 @@ CREATE TABLE IF NOT EXISTS tree_head(
 -- having a DESC scan on the primary key
 CREATE UNIQUE INDEX TreeHeadRevisionIdx ON tree_head(tree_id, tree_revision DESC);--end
 
+-- Used to check that root hashes were published by a log.
+CREATE INDEX TreeHeadRootHashIdx ON tree_head(tree_id, root_hash);--end
+
+-- Signatures of third-party witnesses over log roots, at most one per public
+-- key and revision. key_hash is the SHA-256 hash of public_key.
+CREATE TABLE IF NOT EXISTS log_root_signature(
+  tree_id                BIGINT NOT NULL,
+  tree_revision          BIGINT NOT NULL,
+  key_hash               BYTEA NOT NULL,
+  public_key             BYTEA NOT NULL,
+  signature              BYTEA NOT NULL,
+  PRIMARY KEY(tree_id, tree_revision, key_hash),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+-- A recovery marker is stored in the same transaction as each log or map root,
+-- recording the write-ahead log location at which the root was published.
+CREATE TABLE IF NOT EXISTS recovery_marker(
+  tree_id                BIGINT NOT NULL,
+  revision               BIGINT NOT NULL,
+  position               VARCHAR(255) NOT NULL,
+  PRIMARY KEY(tree_id, revision),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
 -- ---------------------------------------------
 -- Log specific stuff here
 -- ---------------------------------------------
@@ -188,3 +238,99 @@ AS $function$
                 raise notice '% %', SQLERRM, SQLSTATE;
     end;
 $function$;--end
+
+-- ---------------------------------------------
+-- Map specific stuff here
+-- ---------------------------------------------
+
+CREATE TABLE IF NOT EXISTS map_leaf(
+  tree_id                BIGINT NOT NULL,
+  key_hash               BYTEA NOT NULL,
+  map_revision           BIGINT NOT NULL,
+  leaf_value             BYTEA NOT NULL,
+  PRIMARY KEY(tree_id, key_hash, map_revision),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+-- Lookup from leaf hashes to the versions of the map leaves which have them.
+-- Only written for maps with leaf_hash_index set in their storage_settings.
+CREATE TABLE IF NOT EXISTS map_leaf_hash(
+  tree_id                BIGINT NOT NULL,
+  leaf_hash              BYTEA NOT NULL,
+  key_hash               BYTEA NOT NULL,
+  map_revision           BIGINT NOT NULL,
+  PRIMARY KEY(tree_id, leaf_hash, key_hash, map_revision),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+CREATE TABLE IF NOT EXISTS map_head(
+  tree_id                BIGINT NOT NULL,
+  map_head_timestamp     BIGINT,
+  root_hash              BYTEA NOT NULL,
+  map_revision           BIGINT,
+  root_signature         BYTEA NOT NULL,
+  mapper_data            BYTEA,
+  -- Marshalled storagepb.RootSignatures by the additional keys of the tree.
+  additional_signatures  BYTEA,
+  PRIMARY KEY(tree_id, map_head_timestamp),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+CREATE UNIQUE INDEX MapHeadRevisionIdx ON map_head(tree_id, map_revision);--end
+
+-- Idempotency tokens of map writes, so that retried writes can be detected.
+CREATE TABLE IF NOT EXISTS map_idempotency_token(
+  tree_id                BIGINT NOT NULL,
+  token                  BYTEA NOT NULL,
+  map_revision           BIGINT NOT NULL,
+  PRIMARY KEY(tree_id, token),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+-- Signatures of third-party witnesses over map roots, at most one per public
+-- key and revision. key_hash is the SHA-256 hash of public_key.
+CREATE TABLE IF NOT EXISTS map_root_signature(
+  tree_id                BIGINT NOT NULL,
+  map_revision           BIGINT NOT NULL,
+  key_hash               BYTEA NOT NULL,
+  public_key             BYTEA NOT NULL,
+  signature              BYTEA NOT NULL,
+  PRIMARY KEY(tree_id, map_revision, key_hash),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+-- Map writes queued by WriteLeaves, until the map merger folds them into a
+-- new revision of the map.
+CREATE TABLE IF NOT EXISTS map_write_queue(
+  tree_id                BIGINT NOT NULL,
+  queue_id               BIGSERIAL NOT NULL,
+  queue_timestamp_nanos  BIGINT NOT NULL,
+  -- leaves holds a serialized trillian.MapLeaves message.
+  leaves                 BYTEA NOT NULL,
+  metadata               BYTEA,
+  PRIMARY KEY(queue_id),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+CREATE INDEX MapWriteQueueTreeIdx ON map_write_queue(tree_id, queue_id);--end
+
+-- Write revisions of maps reserved by ReserveRevision, until their lease
+-- expires or the revision is written.
+CREATE TABLE IF NOT EXISTS map_revision_lease(
+  tree_id                BIGINT NOT NULL,
+  revision               BIGINT NOT NULL,
+  token                  BYTEA NOT NULL,
+  expiry_nanos           BIGINT NOT NULL,
+  PRIMARY KEY(tree_id, revision),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+);--end
+
+-- The version of this schema, which trillian_migrate upgrades. Databases
+-- created before schemas were versioned lack this table, and have to be
+-- baselined with trillian_migrate.
+CREATE TABLE IF NOT EXISTS schema_version(
+  version                INTEGER NOT NULL
+);--end
+
+INSERT INTO schema_version(version)
+  SELECT 2 WHERE NOT EXISTS (SELECT * FROM schema_version);--end
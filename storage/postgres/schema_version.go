@@ -0,0 +1,171 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import "github.com/google/trillian/storage/migrate"
+
+// Schema is the history of the PostgreSQL and CockroachDB schemas. New
+// databases are created at its latest version by schema/storage.sql and
+// schema/cockroachdb.sql, which have to be kept in step. CockroachDB storage
+// was added at version 2, so its databases never run the earlier steps.
+var Schema = migrate.Schema{
+	Table:     "schema_version",
+	ExistsSQL: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_version'",
+	Steps: []migrate.Step{
+		{Version: 1, Description: "baseline"},
+		{
+			Version:     2,
+			Description: "tree settings, root signatures, recovery markers and map tables",
+			// Values can't be added to an enum in a transaction before
+			// PostgreSQL 12, so the tree_state column changes type instead.
+			Up: []string{
+				`CREATE TYPE E_TREE_STATE_V2 AS ENUM('ACTIVE', 'FROZEN', 'DRAINING', 'QUARANTINED', 'PAUSED')`,
+				`ALTER TABLE trees ALTER COLUMN tree_state TYPE E_TREE_STATE_V2 USING tree_state::text::E_TREE_STATE_V2`,
+				`DROP TYPE E_TREE_STATE`,
+				`ALTER TYPE E_TREE_STATE_V2 RENAME TO E_TREE_STATE`,
+				`ALTER TABLE trees
+  DROP COLUMN current_tree_data,
+  DROP COLUMN root_signature,
+  ADD COLUMN retain_revisions BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN retain_duration_millis BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN storage_settings BYTEA,
+  ADD COLUMN map_index_bits INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN duplicate_leaf_policy INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN additional_keys BYTEA,
+  ADD COLUMN signature_threshold INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN labels BYTEA,
+  ADD COLUMN delete_retention_millis BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN key_rotation BYTEA,
+  ADD COLUMN read_tokens_per_second BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN write_tokens_per_second BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN owner VARCHAR(255) NOT NULL DEFAULT ''`,
+				`ALTER TABLE tree_head ADD COLUMN additional_signatures BYTEA`,
+				`CREATE INDEX TreeHeadRootHashIdx ON tree_head(tree_id, root_hash)`,
+				`CREATE TABLE log_root_signature(
+  tree_id                BIGINT NOT NULL,
+  tree_revision          BIGINT NOT NULL,
+  key_hash               BYTEA NOT NULL,
+  public_key             BYTEA NOT NULL,
+  signature              BYTEA NOT NULL,
+  PRIMARY KEY(tree_id, tree_revision, key_hash),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+)`,
+				`CREATE TABLE recovery_marker(
+  tree_id                BIGINT NOT NULL,
+  revision               BIGINT NOT NULL,
+  position               VARCHAR(255) NOT NULL,
+  PRIMARY KEY(tree_id, revision),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+)`,
+				`CREATE TABLE map_leaf(
+  tree_id                BIGINT NOT NULL,
+  key_hash               BYTEA NOT NULL,
+  map_revision           BIGINT NOT NULL,
+  leaf_value             BYTEA NOT NULL,
+  PRIMARY KEY(tree_id, key_hash, map_revision),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+)`,
+				`CREATE TABLE map_leaf_hash(
+  tree_id                BIGINT NOT NULL,
+  leaf_hash              BYTEA NOT NULL,
+  key_hash               BYTEA NOT NULL,
+  map_revision           BIGINT NOT NULL,
+  PRIMARY KEY(tree_id, leaf_hash, key_hash, map_revision),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+)`,
+				`CREATE TABLE map_head(
+  tree_id                BIGINT NOT NULL,
+  map_head_timestamp     BIGINT,
+  root_hash              BYTEA NOT NULL,
+  map_revision           BIGINT,
+  root_signature         BYTEA NOT NULL,
+  mapper_data            BYTEA,
+  additional_signatures  BYTEA,
+  PRIMARY KEY(tree_id, map_head_timestamp),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+)`,
+				`CREATE UNIQUE INDEX MapHeadRevisionIdx ON map_head(tree_id, map_revision)`,
+				`CREATE TABLE map_idempotency_token(
+  tree_id                BIGINT NOT NULL,
+  token                  BYTEA NOT NULL,
+  map_revision           BIGINT NOT NULL,
+  PRIMARY KEY(tree_id, token),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+)`,
+				`CREATE TABLE map_root_signature(
+  tree_id                BIGINT NOT NULL,
+  map_revision           BIGINT NOT NULL,
+  key_hash               BYTEA NOT NULL,
+  public_key             BYTEA NOT NULL,
+  signature              BYTEA NOT NULL,
+  PRIMARY KEY(tree_id, map_revision, key_hash),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+)`,
+				`CREATE TABLE map_write_queue(
+  tree_id                BIGINT NOT NULL,
+  queue_id               BIGSERIAL NOT NULL,
+  queue_timestamp_nanos  BIGINT NOT NULL,
+  leaves                 BYTEA NOT NULL,
+  metadata               BYTEA,
+  PRIMARY KEY(queue_id),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+)`,
+				`CREATE INDEX MapWriteQueueTreeIdx ON map_write_queue(tree_id, queue_id)`,
+				`CREATE TABLE map_revision_lease(
+  tree_id                BIGINT NOT NULL,
+  revision               BIGINT NOT NULL,
+  token                  BYTEA NOT NULL,
+  expiry_nanos           BIGINT NOT NULL,
+  PRIMARY KEY(tree_id, revision),
+  FOREIGN KEY(tree_id) REFERENCES trees(tree_id) ON DELETE CASCADE
+)`,
+			},
+			// Downgrading fails if any tree is QUARANTINED or PAUSED.
+			Down: []string{
+				`DROP TABLE map_revision_lease`,
+				`DROP TABLE map_write_queue`,
+				`DROP TABLE map_root_signature`,
+				`DROP TABLE map_idempotency_token`,
+				`DROP TABLE map_head`,
+				`DROP TABLE map_leaf_hash`,
+				`DROP TABLE map_leaf`,
+				`DROP TABLE recovery_marker`,
+				`DROP TABLE log_root_signature`,
+				`DROP INDEX TreeHeadRootHashIdx`,
+				`ALTER TABLE tree_head DROP COLUMN additional_signatures`,
+				`ALTER TABLE trees
+  DROP COLUMN owner,
+  DROP COLUMN write_tokens_per_second,
+  DROP COLUMN read_tokens_per_second,
+  DROP COLUMN key_rotation,
+  DROP COLUMN delete_retention_millis,
+  DROP COLUMN labels,
+  DROP COLUMN signature_threshold,
+  DROP COLUMN additional_keys,
+  DROP COLUMN duplicate_leaf_policy,
+  DROP COLUMN map_index_bits,
+  DROP COLUMN storage_settings,
+  DROP COLUMN retain_duration_millis,
+  DROP COLUMN retain_revisions,
+  ADD COLUMN current_tree_data json,
+  ADD COLUMN root_signature BYTEA`,
+				`CREATE TYPE E_TREE_STATE_V1 AS ENUM('ACTIVE', 'FROZEN', 'DRAINING')`,
+				`ALTER TABLE trees ALTER COLUMN tree_state TYPE E_TREE_STATE_V1 USING tree_state::text::E_TREE_STATE_V1`,
+				`DROP TYPE E_TREE_STATE`,
+				`ALTER TYPE E_TREE_STATE_V1 RENAME TO E_TREE_STATE`,
+			},
+		},
+	},
+}
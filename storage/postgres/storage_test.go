@@ -96,6 +96,16 @@ func createSomeNodes() []stree.Node {
 	return r
 }
 
+func TestSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+	if err := Schema.Validate(); err != nil {
+		t.Fatalf("Validate(): %v", err)
+	}
+	if err := Schema.Check(ctx, db); err != nil {
+		t.Errorf("Check(): %v", err)
+	}
+}
+
 func nodesAreEqual(lhs []stree.Node, rhs []stree.Node) error {
 	if ls, rs := len(lhs), len(rhs); ls != rs {
 		return fmt.Errorf("different number of nodes, %d vs %d", ls, rs)
@@ -23,12 +23,16 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/hashers"
 	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/storage/sqlpool"
 	"github.com/google/trillian/storage/storagepb"
+	"github.com/google/trillian/storage/subtreecodec"
 	"github.com/google/trillian/storage/tree"
 )
 
@@ -51,20 +55,46 @@ const (
 		ON subtree.subtree_id = x.subtree_id
 		AND subtree.subtree_revision = x.max_revision
 		AND subtree.tree_id = <param>`
-	insertTreeHeadSQL = `INSERT INTO tree_head(tree_id,tree_head_timestamp,tree_size,root_hash,tree_revision,root_signature)
-                 VALUES($1,$2,$3,$4,$5,$6)`
+	insertTreeHeadSQL = `INSERT INTO tree_head(tree_id,tree_head_timestamp,tree_size,root_hash,tree_revision,root_signature,additional_signatures)
+                 VALUES($1,$2,$3,$4,$5,$6,$7)`
 )
 
-// pgTreeStorage contains the pgLogStorage implementation.
+// SubtreeCodecs are the subtree codecs supported by the PostgreSQL storage.
+var SubtreeCodecs = []subtreecodec.Codec{subtreecodec.Proto, subtreecodec.Packed, subtreecodec.Deflate, subtreecodec.Zstd}
+
+// TreeStorageOptions holds the options of the log and map storage.
+type TreeStorageOptions struct {
+	// SubtreeCodec is the codec with which subtrees are written. Subtrees are
+	// read with whichever codec they were written with, so it can be changed
+	// at any time.
+	SubtreeCodec subtreecodec.Codec
+
+	// MapHashers provides the hashers of map hash strategies, in addition to
+	// the ones registered with hashers.RegisterMapHasher. It is only used by
+	// map storage.
+	MapHashers hashers.MapHasherRegistry
+
+	// Dialect is the SQL dialect of the database, PostgreSQL unless set.
+	Dialect Dialect
+
+	// SnapshotStaleness, if positive, is how long ago the snapshots of trees
+	// read the database. It is only supported by CockroachDB, where stale
+	// reads don't contend with writes, at the cost of missing recent writes.
+	SnapshotStaleness time.Duration
+
+	// StatementCacheSize, if positive, is the maximum number of prepared
+	// statements cached by the storage. Least recently used statements are
+	// closed to make room for new ones. The statements are cached without
+	// limit otherwise.
+	StatementCacheSize int
+}
+
+// pgTreeStorage contains the functionality shared by the pgLogStorage and
+// pgMapStorage implementations.
 type pgTreeStorage struct {
-	db *sql.DB
-
-	// Must hold the mutex before manipulating the statement map. Sharing a lock because
-	// it only needs to be held while the statements are built, not while they execute and
-	// this will be a short time. These maps are from the number of placeholder '$#'
-	// in the query to the statement that should be used.
-	statementMutex sync.Mutex
-	statements     map[string]map[int]*sql.Stmt
+	db    *sql.DB
+	opts  TreeStorageOptions
+	stmts *sqlpool.StmtCache
 }
 
 // OpenDB opens a database connection for all PG-based storage implementations.
@@ -79,10 +109,11 @@ func OpenDB(connStr string) (*sql.DB, error) {
 	return db, nil
 }
 
-func newTreeStorage(db *sql.DB) *pgTreeStorage {
+func newTreeStorage(db *sql.DB, opts TreeStorageOptions) *pgTreeStorage {
 	return &pgTreeStorage{
-		db:         db,
-		statements: make(map[string]map[int]*sql.Stmt),
+		db:    db,
+		opts:  opts,
+		stmts: sqlpool.NewStmtCache(db, opts.StatementCacheSize),
 	}
 }
 
@@ -132,17 +163,6 @@ func expandPlaceholderSQL(skeleton *statementSkeleton) (string, error) {
 // getStmt creates and caches sql.Stmt structs based on the passed in statement
 // and number of bound arguments.
 func (p *pgTreeStorage) getStmt(ctx context.Context, skeleton *statementSkeleton) (*sql.Stmt, error) {
-	p.statementMutex.Lock()
-	defer p.statementMutex.Unlock()
-
-	if p.statements[skeleton.sql] != nil {
-		if p.statements[skeleton.sql][skeleton.num] != nil {
-			return p.statements[skeleton.sql][skeleton.num], nil
-		}
-	} else {
-		p.statements[skeleton.sql] = make(map[int]*sql.Stmt)
-	}
-
 	statement, err := expandPlaceholderSQL(skeleton)
 
 	counter := skeleton.restPlaceholders*skeleton.num + 1
@@ -155,15 +175,13 @@ func (p *pgTreeStorage) getStmt(ctx context.Context, skeleton *statementSkeleton
 		glog.Warningf("Failed to expand placeholder sql: %v", skeleton)
 		return nil, err
 	}
-	s, err := p.db.PrepareContext(ctx, statement)
+	s, err := p.stmts.Prepare(ctx, statement)
 
 	if err != nil {
 		glog.Warningf("Failed to prepare statement %d: %s", skeleton.num, err)
 		return nil, err
 	}
 
-	p.statements[skeleton.sql][skeleton.num] = s
-
 	return s, nil
 }
 
@@ -191,24 +209,33 @@ func (p *pgTreeStorage) setSubtreeStmt(ctx context.Context, num int) (*sql.Stmt,
 	return p.getStmt(ctx, skeleton)
 }
 
-func (p *pgTreeStorage) beginTreeTx(ctx context.Context, tree *trillian.Tree, hashSizeBytes int, subtreeCache *cache.SubtreeCache) (treeTX, error) {
-	t, err := p.db.BeginTx(ctx, nil /* opts */)
+func (p *pgTreeStorage) beginTreeTx(ctx context.Context, tree *trillian.Tree, hashSizeBytes int, subtreeCache *cache.SubtreeCache, readonly bool) (treeTX, error) {
+	t, err := p.opts.Dialect.beginTx(ctx, p.db, readonly, p.opts.SnapshotStaleness)
 	if err != nil {
 		glog.Warningf("Could not start tree TX: %s", err)
 		return treeTX{}, err
 	}
+	return p.newTreeTX(t, &sync.Mutex{}, tree, hashSizeBytes, subtreeCache), nil
+}
+
+// newTreeTX returns a treeTX for tree which runs its queries in t. All the
+// treeTXs sharing t must also share mu.
+func (p *pgTreeStorage) newTreeTX(t *sql.Tx, mu *sync.Mutex, tree *trillian.Tree, hashSizeBytes int, subtreeCache *cache.SubtreeCache) treeTX {
 	return treeTX{
 		tx:            t,
+		mu:            mu,
 		ts:            p,
 		treeID:        tree.TreeId,
 		treeType:      tree.TreeType,
 		hashSizeBytes: hashSizeBytes,
 		subtreeCache:  subtreeCache,
 		writeRevision: -1,
-	}, nil
+	}
 }
 
 type treeTX struct {
+	// mu ensures that tx can only be used for one query/exec at a time.
+	mu            *sync.Mutex
 	closed        bool
 	tx            *sql.Tx
 	ts            *pgTreeStorage
@@ -286,7 +313,7 @@ func (t *treeTX) getSubtrees(ctx context.Context, treeRevision int64, nodeIDs []
 			glog.Warningf("Failed to scan merkle subtree: %s", err)
 			return nil, err
 		}
-		if err := proto.Unmarshal(nodesRaw, &subtree); err != nil {
+		if _, err := subtreecodec.Unmarshal(nodesRaw, &subtree); err != nil {
 			glog.Warningf("Failed to unmarshal SubtreeProto: %s", err)
 			return nil, err
 		}
@@ -339,7 +366,7 @@ func (t *treeTX) storeSubtrees(ctx context.Context, subtrees []*storagepb.Subtre
 		if st.Prefix == nil {
 			panic(fmt.Errorf("nil prefix on %v", st))
 		}
-		subtreeBytes, err := proto.Marshal(st)
+		subtreeBytes, err := subtreecodec.Marshal(st, t.ts.opts.SubtreeCodec)
 		if err != nil {
 			return err
 		}
@@ -365,6 +392,24 @@ func (t *treeTX) storeSubtrees(ctx context.Context, subtrees []*storagepb.Subtre
 }
 
 func (t *treeTX) Commit(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.flushSubtrees(ctx); err != nil {
+		return err
+	}
+	t.closed = true
+	t.subtreeCache.Release()
+	if err := t.tx.Commit(); err != nil {
+		glog.Warningf("TX commit error: %s, stack:\n%s", err, string(debug.Stack()))
+		return err
+	}
+	return nil
+}
+
+// flushSubtrees writes the subtrees modified by a read-write transaction. The
+// caller must hold t.mu.
+func (t *treeTX) flushSubtrees(ctx context.Context) error {
 	if t.writeRevision > -1 {
 		if err := t.subtreeCache.Flush(ctx, func(ctx context.Context, st []*storagepb.SubtreeProto) error {
 			return t.storeSubtrees(ctx, st)
@@ -373,16 +418,19 @@ func (t *treeTX) Commit(ctx context.Context) error {
 			return err
 		}
 	}
-	t.closed = true
-	if err := t.tx.Commit(); err != nil {
-		glog.Warningf("TX commit error: %s, stack:\n%s", err, string(debug.Stack()))
-		return err
-	}
 	return nil
 }
 
 func (t *treeTX) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.rollbackInternal()
+}
+
+func (t *treeTX) rollbackInternal() error {
 	t.closed = true
+	t.subtreeCache.Release()
 	if err := t.tx.Rollback(); err != nil {
 		glog.Warningf("TX rollback error: %s, stack:\n%s", err, string(debug.Stack()))
 		return err
@@ -391,8 +439,11 @@ func (t *treeTX) Rollback() error {
 }
 
 func (t *treeTX) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	if !t.closed {
-		err := t.Rollback()
+		err := t.rollbackInternal()
 		if err != nil {
 			glog.Warningf("Rollback error on Close(): %v", err)
 		}
@@ -402,10 +453,16 @@ func (t *treeTX) Close() error {
 }
 
 func (t *treeTX) GetMerkleNodes(ctx context.Context, treeRevision int64, nodeIDs []tree.NodeID) ([]tree.Node, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	return t.subtreeCache.GetNodes(nodeIDs, t.getSubtreesAtRev(ctx, treeRevision))
 }
 
 func (t *treeTX) SetMerkleNodes(ctx context.Context, nodes []tree.Node) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	for _, n := range nodes {
 		err := t.subtreeCache.SetNodeHash(n.NodeID, n.Hash,
 			func(nID tree.NodeID) (*storagepb.SubtreeProto, error) {
@@ -419,6 +476,9 @@ func (t *treeTX) SetMerkleNodes(ctx context.Context, nodes []tree.Node) error {
 }
 
 func (t *treeTX) IsOpen() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	return !t.closed
 }
 
@@ -450,6 +510,31 @@ func checkResultOkAndRowCountIs(res sql.Result, err error, count int64) error {
 	return nil
 }
 
+// marshalRootSignatures returns the value stored in the additional_signatures
+// column of a root, which is nil if it has no additional signatures.
+func marshalRootSignatures(sigs [][]byte) ([]byte, error) {
+	if len(sigs) == 0 {
+		return nil, nil
+	}
+	b, err := proto.Marshal(&storagepb.RootSignatures{Signatures: sigs})
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal RootSignatures: %v", err)
+	}
+	return b, nil
+}
+
+// unmarshalRootSignatures parses the additional_signatures column of a root.
+func unmarshalRootSignatures(b []byte) ([][]byte, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	var sigs storagepb.RootSignatures
+	if err := proto.Unmarshal(b, &sigs); err != nil {
+		return nil, fmt.Errorf("could not unmarshal RootSignatures: %v", err)
+	}
+	return sigs.Signatures, nil
+}
+
 // subtreeKey returns a non-nil []byte suitable for use as a primary key column
 // for the subtree rooted at the passed-in node ID. Returns an error if the ID
 // is not aligned to bytes.
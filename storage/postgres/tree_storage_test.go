@@ -28,7 +28,7 @@ type expandTestcase struct {
 // TODO(vishal): remove this once the rest of the storage code is complete.
 func TestInitializes(t *testing.T) {
 	_ = &statementSkeleton{}
-	arbitraryStorage := newTreeStorage(nil)
+	arbitraryStorage := newTreeStorage(nil, TreeStorageOptions{})
 	_ = arbitraryStorage.getSubtreeStmt
 	_ = arbitraryStorage.beginTreeTx
 	treeTx := &treeTX{}
@@ -0,0 +1,88 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google/trillian/monitoring"
+)
+
+// Provider builds the storage of a storage system, which the Trillian servers
+// select by the name it is registered with.
+type Provider interface {
+	// LogStorage creates and returns a LogStorage implementation.
+	LogStorage() LogStorage
+	// MapStorage creates and returns a MapStorage implementation.
+	MapStorage() MapStorage
+	// AdminStorage creates and returns a AdminStorage implementation.
+	AdminStorage() AdminStorage
+
+	// Close closes the underlying storage.
+	Close() error
+}
+
+// NewProviderFunc is the signature of a function which can be registered to
+// provide instances of storage providers.
+type NewProviderFunc func(monitoring.MetricFactory) (Provider, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]NewProviderFunc)
+)
+
+// RegisterProvider registers newProvider under name, which the server binaries
+// then accept as their --storage_system. Storage systems outside this
+// repository typically call it from the init function of their package, and
+// are linked into a binary with a blank import, e.g. in a file guarded by a
+// build tag, so that no code in the binary has to know about them.
+func RegisterProvider(name string, newProvider NewProviderFunc) error {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if _, exists := providers[name]; exists {
+		return fmt.Errorf("storage provider %v already registered", name)
+	}
+	providers[name] = newProvider
+	return nil
+}
+
+// NewProvider returns a new Provider of the storage system registered under
+// name.
+func NewProvider(name string, mf monitoring.MetricFactory) (Provider, error) {
+	providersMu.RLock()
+	newProvider := providers[name]
+	providersMu.RUnlock()
+
+	if newProvider == nil {
+		return nil, fmt.Errorf("no such storage provider %v, want one of %v", name, Providers())
+	}
+	return newProvider(mf)
+}
+
+// Providers returns the sorted names of the registered storage systems.
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	names := []string{}
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/trillian/monitoring"
+)
+
+type fakeProvider struct{}
+
+func (p *fakeProvider) LogStorage() LogStorage     { return nil }
+func (p *fakeProvider) MapStorage() MapStorage     { return nil }
+func (p *fakeProvider) AdminStorage() AdminStorage { return nil }
+func (p *fakeProvider) Close() error               { return nil }
+
+func TestRegisterProvider(t *testing.T) {
+	var gotMF monitoring.MetricFactory
+	newFake := func(mf monitoring.MetricFactory) (Provider, error) {
+		gotMF = mf
+		return &fakeProvider{}, nil
+	}
+	if err := RegisterProvider("fake", newFake); err != nil {
+		t.Fatalf("RegisterProvider(fake) = %v, want nil", err)
+	}
+	if err := RegisterProvider("fake", newFake); err == nil {
+		t.Error("RegisterProvider(fake) twice = nil, want error")
+	}
+
+	mf := monitoring.InertMetricFactory{}
+	if _, err := NewProvider("fake", mf); err != nil {
+		t.Fatalf("NewProvider(fake) = %v, want nil", err)
+	}
+	if gotMF != mf {
+		t.Errorf("NewProviderFunc got MetricFactory %v, want %v", gotMF, mf)
+	}
+	if _, err := NewProvider("unknown", mf); err == nil {
+		t.Error("NewProvider(unknown) = nil, want error")
+	}
+}
+
+func TestProviders(t *testing.T) {
+	for _, name := range []string{"b", "a"} {
+		if err := RegisterProvider(name, func(monitoring.MetricFactory) (Provider, error) {
+			return &fakeProvider{}, nil
+		}); err != nil {
+			t.Fatalf("RegisterProvider(%v) = %v", name, err)
+		}
+	}
+	names := Providers()
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("Providers() = %v, want sorted names", names)
+	}
+	for _, want := range []string{"a", "b"} {
+		if i := sort.SearchStrings(names, want); i == len(names) || names[i] != want {
+			t.Errorf("Providers() = %v, want it to include %v", names, want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package querytag attributes storage queries to the requests which caused
+// them.
+//
+// Servers add tags identifying the RPC, tree and revision of a request to its
+// context, and storage implementations append them to the text of each SQL
+// query as a comment, which appears in slow query logs and query statistics.
+// Comments follow the sqlcommenter format, e.g.
+//
+//	SELECT ... /*revision='5',rpc='%2Ftrillian.TrillianMap%2FGetLeaves',tree_id='123'*/
+package querytag
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Tags identify the source of storage queries.
+type Tags struct {
+	// TreeID is the ID of the tree accessed, or 0 if unknown.
+	TreeID int64
+	// RPC is the full name of the RPC method served, or empty if unknown.
+	RPC string
+	// Revision is the tree revision accessed, or negative if unknown.
+	Revision int64
+}
+
+type tagsKey struct{}
+
+// FromContext returns the tags of ctx.
+func FromContext(ctx context.Context) Tags {
+	if tags, ok := ctx.Value(tagsKey{}).(Tags); ok {
+		return tags
+	}
+	return Tags{Revision: -1}
+}
+
+// NewContext returns a copy of ctx with the given tags.
+func NewContext(ctx context.Context, tags Tags) context.Context {
+	return context.WithValue(ctx, tagsKey{}, tags)
+}
+
+// WithTreeID returns a copy of ctx which is tagged with the tree ID.
+func WithTreeID(ctx context.Context, treeID int64) context.Context {
+	tags := FromContext(ctx)
+	tags.TreeID = treeID
+	return NewContext(ctx, tags)
+}
+
+// WithRPC returns a copy of ctx which is tagged with the RPC method.
+func WithRPC(ctx context.Context, rpc string) context.Context {
+	tags := FromContext(ctx)
+	tags.RPC = rpc
+	return NewContext(ctx, tags)
+}
+
+// WithRevision returns a copy of ctx which is tagged with the revision.
+func WithRevision(ctx context.Context, revision int64) context.Context {
+	tags := FromContext(ctx)
+	tags.Revision = revision
+	return NewContext(ctx, tags)
+}
+
+// Comment returns the tags as a SQL comment, or an empty string if no tags
+// are known.
+func (t Tags) Comment() string {
+	// Keys are sorted, as sqlcommenter requires.
+	var kv []string
+	if t.Revision >= 0 {
+		kv = append(kv, fmt.Sprintf("revision='%d'", t.Revision))
+	}
+	if t.RPC != "" {
+		// Escaping also ensures the value cannot end the comment.
+		kv = append(kv, fmt.Sprintf("rpc='%s'", url.QueryEscape(t.RPC)))
+	}
+	if t.TreeID != 0 {
+		kv = append(kv, fmt.Sprintf("tree_id='%d'", t.TreeID))
+	}
+	if len(kv) == 0 {
+		return ""
+	}
+	return "/*" + strings.Join(kv, ",") + "*/"
+}
+
+// Append returns query with the tags appended as a comment.
+func (t Tags) Append(query string) string {
+	comment := t.Comment()
+	if comment == "" {
+		return query
+	}
+	return query + " " + comment
+}
@@ -0,0 +1,47 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package querytag
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAppend(t *testing.T) {
+	const query = "SELECT 1"
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		desc string
+		ctx  context.Context
+		want string
+	}{
+		{desc: "no tags", ctx: ctx, want: query},
+		{desc: "tree", ctx: WithTreeID(ctx, 123), want: query + " /*tree_id='123'*/"},
+		{desc: "revision 0", ctx: WithRevision(ctx, 0), want: query + " /*revision='0'*/"},
+		{
+			desc: "all",
+			ctx:  WithRevision(WithRPC(WithTreeID(ctx, 123), "/trillian.TrillianMap/GetLeaves"), 5),
+			want: query + " /*revision='5',rpc='%2Ftrillian.TrillianMap%2FGetLeaves',tree_id='123'*/",
+		},
+		{desc: "escaped", ctx: WithRPC(ctx, "*/ DROP TABLE Trees; /*"), want: query + " /*rpc='%2A%2F+DROP+TABLE+Trees%3B+%2F%2A'*/"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := FromContext(test.ctx).Append(query); got != test.want {
+				t.Errorf("Append()=%q, want %q", got, test.want)
+			}
+		})
+	}
+}
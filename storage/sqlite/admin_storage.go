@@ -0,0 +1,724 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultSequenceIntervalSeconds = 60
+
+	nonDeletedWhere = " WHERE (Deleted IS NULL OR Deleted = FALSE)"
+
+	selectTreeIDs           = "SELECT TreeId FROM Trees"
+	selectNonDeletedTreeIDs = selectTreeIDs + nonDeletedWhere
+
+	selectTrees = `
+		SELECT
+			TreeId,
+			TreeState,
+			TreeType,
+			HashStrategy,
+			HashAlgorithm,
+			SignatureAlgorithm,
+			DisplayName,
+			Description,
+			CreateTimeMillis,
+			UpdateTimeMillis,
+			PrivateKey,
+			PublicKey,
+			MaxRootDurationMillis,
+			Deleted,
+			DeleteTimeMillis,
+			RetainRevisions,
+			RetainDurationMillis,
+			StorageSettings,
+			MapIndexBits,
+			DuplicateLeafPolicy,
+			AdditionalKeys,
+			SignatureThreshold,
+			Labels,
+			DeleteRetentionMillis,
+			KeyRotation,
+			ReadTokensPerSecond,
+			WriteTokensPerSecond,
+			Owner
+		FROM Trees`
+	selectNonDeletedTrees = selectTrees + nonDeletedWhere
+	selectTreeByID        = selectTrees + " WHERE TreeId = ?"
+
+	updateTreeSQL = `UPDATE Trees
+		SET TreeState = ?, TreeType = ?, DisplayName = ?, Description = ?, UpdateTimeMillis = ?, MaxRootDurationMillis = ?, PrivateKey = ?,
+			PublicKey = ?, RetainRevisions = ?, RetainDurationMillis = ?, DuplicateLeafPolicy = ?, Labels = ?,
+			DeleteRetentionMillis = ?, KeyRotation = ?, ReadTokensPerSecond = ?, WriteTokensPerSecond = ?, Owner = ?
+		WHERE TreeId = ?`
+)
+
+// NewAdminStorage returns a SQLite storage.AdminStorage implementation backed by DB.
+func NewAdminStorage(db *sql.DB) storage.AdminStorage {
+	return &sqliteAdminStorage{db}
+}
+
+// sqliteAdminStorage implements storage.AdminStorage
+type sqliteAdminStorage struct {
+	db *sql.DB
+}
+
+func (s *sqliteAdminStorage) Snapshot(ctx context.Context) (storage.ReadOnlyAdminTX, error) {
+	return s.beginInternal(ctx)
+}
+
+func (s *sqliteAdminStorage) beginInternal(ctx context.Context) (storage.AdminTX, error) {
+	tx, err := s.db.BeginTx(ctx, nil /* opts */)
+	if err != nil {
+		return nil, err
+	}
+	return &adminTX{tx: tx}, nil
+}
+
+func (s *sqliteAdminStorage) ReadWriteTransaction(ctx context.Context, f storage.AdminTXFunc) error {
+	tx, err := s.beginInternal(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if err := f(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteAdminStorage) CheckDatabaseAccessible(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+type adminTX struct {
+	tx *sql.Tx
+
+	// mu guards *direct* reads/writes on closed, which happen only on
+	// Commit/Rollback/IsClosed/Close methods.
+	// We don't check closed on *all* methods (apart from the ones above),
+	// as we trust tx to keep tabs on its state (and consequently fail to do
+	// queries after closed).
+	mu     sync.RWMutex
+	closed bool
+}
+
+func (t *adminTX) Commit() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	return t.tx.Commit()
+}
+
+func (t *adminTX) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	return t.tx.Rollback()
+}
+
+func (t *adminTX) IsClosed() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.closed
+}
+
+func (t *adminTX) Close() error {
+	// Acquire and release read lock manually, without defer, as if the txn
+	// is not closed Rollback() will attempt to acquire the rw lock.
+	t.mu.RLock()
+	closed := t.closed
+	t.mu.RUnlock()
+	if !closed {
+		err := t.Rollback()
+		if err != nil {
+			glog.Warningf("Rollback error on Close(): %v", err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (t *adminTX) GetTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
+	stmt, err := t.tx.PrepareContext(ctx, selectTreeByID)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	// GetTree is an entry point for most RPCs, let's provide somewhat nicer error messages.
+	tree, err := readTree(stmt.QueryRowContext(ctx, treeID))
+	switch {
+	case err == sql.ErrNoRows:
+		// ErrNoRows doesn't provide useful information, so we don't forward it.
+		return nil, status.Errorf(codes.NotFound, "tree %v not found", treeID)
+	case err != nil:
+		return nil, fmt.Errorf("error reading tree %v: %v", treeID, err)
+	}
+	return tree, nil
+}
+
+func (t *adminTX) ListTreeIDs(ctx context.Context, includeDeleted bool) ([]int64, error) {
+	var query string
+	if includeDeleted {
+		query = selectTreeIDs
+	} else {
+		query = selectNonDeletedTreeIDs
+	}
+
+	stmt, err := t.tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	treeIDs := []int64{}
+	var treeID int64
+	for rows.Next() {
+		if err := rows.Scan(&treeID); err != nil {
+			return nil, err
+		}
+		treeIDs = append(treeIDs, treeID)
+	}
+	return treeIDs, nil
+}
+
+func (t *adminTX) ListTrees(ctx context.Context, includeDeleted bool) ([]*trillian.Tree, error) {
+	var query string
+	if includeDeleted {
+		query = selectTrees
+	} else {
+		query = selectNonDeletedTrees
+	}
+
+	stmt, err := t.tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	trees := []*trillian.Tree{}
+	for rows.Next() {
+		tree, err := readTree(rows)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, tree)
+	}
+	return trees, nil
+}
+
+func (t *adminTX) CreateTree(ctx context.Context, tree *trillian.Tree) (*trillian.Tree, error) {
+	if err := storage.ValidateTreeForCreation(ctx, tree); err != nil {
+		return nil, err
+	}
+	if err := validateStorageSettings(tree); err != nil {
+		return nil, err
+	}
+
+	id, err := storage.TreeIDForCreation(tree)
+	if err != nil {
+		return nil, err
+	}
+	if tree.TreeId != 0 {
+		if _, err := t.GetTree(ctx, id); status.Code(err) != codes.NotFound {
+			if err == nil {
+				err = status.Errorf(codes.AlreadyExists, "tree %d already exists", id)
+			}
+			return nil, err
+		}
+	}
+
+	// Use the time truncated-to-millis throughout, as that's what's stored.
+	nowMillis := storage.ToMillisSinceEpoch(time.Now())
+	now := storage.FromMillisSinceEpoch(nowMillis)
+
+	newTree := proto.Clone(tree).(*trillian.Tree)
+	newTree.TreeId = id
+	newTree.CreateTime, err = ptypes.TimestampProto(now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build create time: %v", err)
+	}
+	newTree.UpdateTime, err = ptypes.TimestampProto(now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build update time: %v", err)
+	}
+	rootDuration, err := ptypes.Duration(newTree.MaxRootDuration)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse MaxRootDuration: %v", err)
+	}
+	retainRevisions, retainDuration, err := retentionColumns(newTree.RevisionRetentionPolicy)
+	if err != nil {
+		return nil, err
+	}
+	var storageSettings []byte
+	if newTree.StorageSettings != nil {
+		if storageSettings, err = proto.Marshal(newTree.StorageSettings); err != nil {
+			return nil, fmt.Errorf("could not marshal StorageSettings: %v", err)
+		}
+	}
+	additionalKeys, err := additionalKeysColumn(newTree)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := labelsColumn(newTree)
+	if err != nil {
+		return nil, err
+	}
+	deleteRetention, err := deleteRetentionColumn(newTree)
+	if err != nil {
+		return nil, err
+	}
+
+	insertTreeStmt, err := t.tx.PrepareContext(
+		ctx,
+		`INSERT INTO Trees(
+			TreeId,
+			TreeState,
+			TreeType,
+			HashStrategy,
+			HashAlgorithm,
+			SignatureAlgorithm,
+			DisplayName,
+			Description,
+			CreateTimeMillis,
+			UpdateTimeMillis,
+			PrivateKey,
+			PublicKey,
+			MaxRootDurationMillis,
+			RetainRevisions,
+			RetainDurationMillis,
+			StorageSettings,
+			MapIndexBits,
+			DuplicateLeafPolicy,
+			AdditionalKeys,
+			SignatureThreshold,
+			Labels,
+			DeleteRetentionMillis,
+			ReadTokensPerSecond,
+			WriteTokensPerSecond,
+			Owner)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return nil, err
+	}
+	defer insertTreeStmt.Close()
+
+	privateKey, err := proto.Marshal(newTree.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal PrivateKey: %v", err)
+	}
+
+	_, err = insertTreeStmt.ExecContext(
+		ctx,
+		newTree.TreeId,
+		newTree.TreeState.String(),
+		newTree.TreeType.String(),
+		newTree.HashStrategy.String(),
+		newTree.HashAlgorithm.String(),
+		newTree.SignatureAlgorithm.String(),
+		newTree.DisplayName,
+		newTree.Description,
+		nowMillis,
+		nowMillis,
+		privateKey,
+		newTree.PublicKey.GetDer(),
+		rootDuration/time.Millisecond,
+		retainRevisions,
+		retainDuration/time.Millisecond,
+		storageSettings,
+		newTree.MapIndexBits,
+		newTree.DuplicateLeafPolicy,
+		additionalKeys,
+		newTree.SignatureThreshold,
+		labels,
+		deleteRetention/time.Millisecond,
+		newTree.Quota.GetReadTokensPerSecond(),
+		newTree.Quota.GetWriteTokensPerSecond(),
+		newTree.Owner,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	insertControlStmt, err := t.tx.PrepareContext(
+		ctx,
+		`INSERT INTO TreeControl(
+			TreeId,
+			SigningEnabled,
+			SequencingEnabled,
+			SequenceIntervalSeconds)
+		VALUES(?, ?, ?, ?)`)
+	if err != nil {
+		return nil, err
+	}
+	defer insertControlStmt.Close()
+	_, err = insertControlStmt.ExecContext(
+		ctx,
+		newTree.TreeId,
+		true, /* SigningEnabled */
+		true, /* SequencingEnabled */
+		defaultSequenceIntervalSeconds,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTree, nil
+}
+
+func (t *adminTX) UpdateTree(ctx context.Context, treeID int64, updateFunc func(*trillian.Tree)) (*trillian.Tree, error) {
+	tree, err := t.GetTree(ctx, treeID)
+	if err != nil {
+		return nil, err
+	}
+
+	beforeUpdate := proto.Clone(tree).(*trillian.Tree)
+	updateFunc(tree)
+	if err := storage.ValidateTreeForUpdate(ctx, beforeUpdate, tree); err != nil {
+		return nil, err
+	}
+	if err := validateStorageSettings(tree); err != nil {
+		return nil, err
+	}
+	if !proto.Equal(beforeUpdate.StorageSettings, tree.StorageSettings) {
+		return nil, status.Error(codes.InvalidArgument, "readonly field changed: storage_settings")
+	}
+
+	// TODO(pavelkalinnikov): When switching TreeType from PREORDERED_LOG to LOG,
+	// ensure all entries in SequencedLeafData are integrated.
+
+	// Use the time truncated-to-millis throughout, as that's what's stored.
+	nowMillis := storage.ToMillisSinceEpoch(time.Now())
+	now := storage.FromMillisSinceEpoch(nowMillis)
+	tree.UpdateTime, err = ptypes.TimestampProto(now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build update time: %v", err)
+	}
+	rootDuration, err := ptypes.Duration(tree.MaxRootDuration)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse MaxRootDuration: %v", err)
+	}
+	retainRevisions, retainDuration, err := retentionColumns(tree.RevisionRetentionPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := proto.Marshal(tree.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal PrivateKey: %v", err)
+	}
+	labels, err := labelsColumn(tree)
+	if err != nil {
+		return nil, err
+	}
+	deleteRetention, err := deleteRetentionColumn(tree)
+	if err != nil {
+		return nil, err
+	}
+	keyRotation, err := keyRotationColumn(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := t.tx.PrepareContext(ctx, updateTreeSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	if _, err = stmt.ExecContext(
+		ctx,
+		tree.TreeState.String(),
+		tree.TreeType.String(),
+		tree.DisplayName,
+		tree.Description,
+		nowMillis,
+		rootDuration/time.Millisecond,
+		privateKey,
+		tree.PublicKey.GetDer(),
+		retainRevisions,
+		retainDuration/time.Millisecond,
+		tree.DuplicateLeafPolicy,
+		labels,
+		deleteRetention/time.Millisecond,
+		keyRotation,
+		tree.Quota.GetReadTokensPerSecond(),
+		tree.Quota.GetWriteTokensPerSecond(),
+		tree.Owner,
+		tree.TreeId); err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+func (t *adminTX) SoftDeleteTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
+	return t.updateDeleted(ctx, treeID, true /* deleted */, storage.ToMillisSinceEpoch(time.Now()) /* deleteTimeMillis */)
+}
+
+func (t *adminTX) UndeleteTree(ctx context.Context, treeID int64) (*trillian.Tree, error) {
+	return t.updateDeleted(ctx, treeID, false /* deleted */, nil /* deleteTimeMillis */)
+}
+
+// updateDeleted updates the Deleted and DeleteTimeMillis fields of the specified tree.
+// deleteTimeMillis must be either an int64 (in millis since epoch) or nil.
+func (t *adminTX) updateDeleted(ctx context.Context, treeID int64, deleted bool, deleteTimeMillis interface{}) (*trillian.Tree, error) {
+	if err := validateDeleted(ctx, t.tx, treeID, !deleted); err != nil {
+		return nil, err
+	}
+	if _, err := t.tx.ExecContext(
+		ctx,
+		"UPDATE Trees SET Deleted = ?, DeleteTimeMillis = ? WHERE TreeId = ?",
+		deleted, deleteTimeMillis, treeID); err != nil {
+		return nil, err
+	}
+	return t.GetTree(ctx, treeID)
+}
+
+func (t *adminTX) HardDeleteTree(ctx context.Context, treeID int64) error {
+	if err := validateDeleted(ctx, t.tx, treeID, true /* wantDeleted */); err != nil {
+		return err
+	}
+
+	// TreeControl didn't have "ON DELETE CASCADE" on previous versions, so let's hit it explicitly
+	if _, err := t.tx.ExecContext(ctx, "DELETE FROM TreeControl WHERE TreeId = ?", treeID); err != nil {
+		return err
+	}
+	_, err := t.tx.ExecContext(ctx, "DELETE FROM Trees WHERE TreeId = ?", treeID)
+	return err
+}
+
+func validateDeleted(ctx context.Context, tx *sql.Tx, treeID int64, wantDeleted bool) error {
+	var nullDeleted sql.NullBool
+	switch err := tx.QueryRowContext(ctx, "SELECT Deleted FROM Trees WHERE TreeId = ?", treeID).Scan(&nullDeleted); {
+	case err == sql.ErrNoRows:
+		return status.Errorf(codes.NotFound, "tree %v not found", treeID)
+	case err != nil:
+		return err
+	}
+
+	switch deleted := nullDeleted.Valid && nullDeleted.Bool; {
+	case wantDeleted && !deleted:
+		return status.Errorf(codes.FailedPrecondition, "tree %v is not soft deleted", treeID)
+	case !wantDeleted && deleted:
+		return status.Errorf(codes.FailedPrecondition, "tree %v already soft deleted", treeID)
+	}
+	return nil
+}
+
+// validateStorageSettings checks that tree has no storage_settings, other than
+// the MapStorageSettings of maps.
+func validateStorageSettings(tree *trillian.Tree) error {
+	if tree.StorageSettings == nil {
+		return nil
+	}
+	if tree.TreeType != trillian.TreeType_MAP {
+		return fmt.Errorf("storage_settings not supported, but got %v", tree.StorageSettings)
+	}
+	_, err := mapStorageSettings(tree)
+	return err
+}
+
+// mapStorageSettings returns the MapStorageSettings held in the
+// storage_settings of tree, which are empty if it has none.
+func mapStorageSettings(tree *trillian.Tree) (*storagepb.MapStorageSettings, error) {
+	settings := &storagepb.MapStorageSettings{}
+	if tree.StorageSettings == nil {
+		return settings, nil
+	}
+	if err := ptypes.UnmarshalAny(tree.StorageSettings, settings); err != nil {
+		return nil, fmt.Errorf("storage_settings not supported, but got %v: %v", tree.StorageSettings, err)
+	}
+	return settings, nil
+}
+
+// extraRow reads the revision retention, storage settings, map index bits,
+// duplicate leaf policy, additional key, label, delete retention, key rotation,
+// quota and owner columns, which are selected after the ones read by
+// storage.ReadTree.
+type extraRow struct {
+	storage.Row
+	retainRevisions, retainDurationMillis int64
+	storageSettings                       []byte
+	mapIndexBits                          int32
+	duplicateLeafPolicy                   int32
+	additionalKeys                        []byte
+	signatureThreshold                    int32
+	labels                                []byte
+	deleteRetentionMillis                 int64
+	keyRotation                           []byte
+	readTokensPerSecond                   int64
+	writeTokensPerSecond                  int64
+	owner                                 string
+}
+
+func (r *extraRow) Scan(dest ...interface{}) error {
+	return r.Row.Scan(append(dest, &r.retainRevisions, &r.retainDurationMillis, &r.storageSettings, &r.mapIndexBits, &r.duplicateLeafPolicy, &r.additionalKeys, &r.signatureThreshold, &r.labels, &r.deleteRetentionMillis, &r.keyRotation, &r.readTokensPerSecond, &r.writeTokensPerSecond, &r.owner)...)
+}
+
+// readTree takes a row selected by selectTrees and returns a tree.
+func readTree(row storage.Row) (*trillian.Tree, error) {
+	r := &extraRow{Row: row}
+	tree, err := storage.ReadTree(r)
+	if err != nil {
+		return nil, err
+	}
+	tree.MapIndexBits = r.mapIndexBits
+	tree.DuplicateLeafPolicy = trillian.DuplicateLeafPolicy(r.duplicateLeafPolicy)
+	tree.SignatureThreshold = r.signatureThreshold
+	if len(r.additionalKeys) > 0 {
+		var keys storagepb.AdditionalKeys
+		if err := proto.Unmarshal(r.additionalKeys, &keys); err != nil {
+			return nil, fmt.Errorf("could not unmarshal AdditionalKeys: %v", err)
+		}
+		tree.AdditionalPrivateKeys = keys.PrivateKeys
+		for _, der := range keys.PublicKeyDers {
+			tree.AdditionalPublicKeys = append(tree.AdditionalPublicKeys, &keyspb.PublicKey{Der: der})
+		}
+	}
+	if len(r.labels) > 0 {
+		var labels storagepb.TreeLabels
+		if err := proto.Unmarshal(r.labels, &labels); err != nil {
+			return nil, fmt.Errorf("could not unmarshal Labels: %v", err)
+		}
+		tree.Labels = labels.Labels
+	}
+	if r.deleteRetentionMillis != 0 {
+		tree.DeleteRetention = ptypes.DurationProto(time.Duration(r.deleteRetentionMillis) * time.Millisecond)
+	}
+	if len(r.keyRotation) > 0 {
+		tree.KeyRotation = &trillian.KeyRotation{}
+		if err := proto.Unmarshal(r.keyRotation, tree.KeyRotation); err != nil {
+			return nil, fmt.Errorf("could not unmarshal KeyRotation: %v", err)
+		}
+	}
+	if r.readTokensPerSecond != 0 || r.writeTokensPerSecond != 0 {
+		tree.Quota = &trillian.TreeQuota{ReadTokensPerSecond: r.readTokensPerSecond, WriteTokensPerSecond: r.writeTokensPerSecond}
+	}
+	tree.Owner = r.owner
+	if len(r.storageSettings) > 0 {
+		tree.StorageSettings = &any.Any{}
+		if err := proto.Unmarshal(r.storageSettings, tree.StorageSettings); err != nil {
+			return nil, fmt.Errorf("could not unmarshal StorageSettings: %v", err)
+		}
+	}
+	if r.retainRevisions != 0 || r.retainDurationMillis != 0 {
+		tree.RevisionRetentionPolicy = &trillian.RevisionRetentionPolicy{KeepRevisions: r.retainRevisions}
+		if r.retainDurationMillis != 0 {
+			tree.RevisionRetentionPolicy.KeepDuration = ptypes.DurationProto(time.Duration(r.retainDurationMillis) * time.Millisecond)
+		}
+	}
+	return tree, nil
+}
+
+// additionalKeysColumn returns the value stored in the AdditionalKeys column
+// for the tree, which is nil if it has no additional keys.
+func additionalKeysColumn(tree *trillian.Tree) ([]byte, error) {
+	if len(tree.AdditionalPrivateKeys) == 0 {
+		return nil, nil
+	}
+	keys := &storagepb.AdditionalKeys{PrivateKeys: tree.AdditionalPrivateKeys}
+	for _, key := range tree.AdditionalPublicKeys {
+		keys.PublicKeyDers = append(keys.PublicKeyDers, key.GetDer())
+	}
+	b, err := proto.Marshal(keys)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal AdditionalKeys: %v", err)
+	}
+	return b, nil
+}
+
+// labelsColumn returns the value stored in the Labels column for the tree,
+// which is nil if it has no labels.
+func labelsColumn(tree *trillian.Tree) ([]byte, error) {
+	if len(tree.Labels) == 0 {
+		return nil, nil
+	}
+	b, err := proto.Marshal(&storagepb.TreeLabels{Labels: tree.Labels})
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal Labels: %v", err)
+	}
+	return b, nil
+}
+
+// deleteRetentionColumn returns the delete retention of the tree, which is
+// zero if it uses the default.
+func deleteRetentionColumn(tree *trillian.Tree) (time.Duration, error) {
+	if tree.DeleteRetention == nil {
+		return 0, nil
+	}
+	d, err := ptypes.Duration(tree.DeleteRetention)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse DeleteRetention: %v", err)
+	}
+	return d, nil
+}
+
+// keyRotationColumn returns the value stored in the KeyRotation column for the
+// tree, which is nil if it isn't rotating its key.
+func keyRotationColumn(tree *trillian.Tree) ([]byte, error) {
+	if tree.KeyRotation == nil {
+		return nil, nil
+	}
+	b, err := proto.Marshal(tree.KeyRotation)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal KeyRotation: %v", err)
+	}
+	return b, nil
+}
+
+// retentionColumns returns the values stored in the revision retention columns
+// for the given policy, which is nil if all revisions are kept.
+func retentionColumns(policy *trillian.RevisionRetentionPolicy) (int64, time.Duration, error) {
+	if policy == nil {
+		return 0, 0, nil
+	}
+	var keepDuration time.Duration
+	if policy.KeepDuration != nil {
+		var err error
+		if keepDuration, err = ptypes.Duration(policy.KeepDuration); err != nil {
+			return 0, 0, fmt.Errorf("could not parse KeepDuration: %v", err)
+		}
+	}
+	return policy.KeepRevisions, keepDuration, nil
+}
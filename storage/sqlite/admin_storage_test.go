@@ -0,0 +1,570 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto/keyspb"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
+	"github.com/google/trillian/storage/testonly"
+)
+
+const selectTreeControlByID = "SELECT SigningEnabled, SequencingEnabled, SequenceIntervalSeconds FROM TreeControl WHERE TreeId = ?"
+
+func TestSQLiteAdminStorage(t *testing.T) {
+	tester := &testonly.AdminStorageTester{NewAdminStorage: func() storage.AdminStorage {
+		cleanTestDB(DB)
+		return NewAdminStorage(DB)
+	}}
+	tester.RunAllTests(t)
+}
+
+func TestAdminTX_CreateTree_InitializesStorageStructures(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree, err := storage.CreateTree(ctx, s, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree() failed: %v", err)
+	}
+
+	// Check if TreeControl is correctly written.
+	var signingEnabled, sequencingEnabled bool
+	var sequenceIntervalSeconds int
+	if err := DB.QueryRowContext(ctx, selectTreeControlByID, tree.TreeId).Scan(&signingEnabled, &sequencingEnabled, &sequenceIntervalSeconds); err != nil {
+		t.Fatalf("Failed to read TreeControl: %v", err)
+	}
+	// We don't mind about specific values, defaults change, but let's check
+	// that important numbers are not zeroed.
+	if sequenceIntervalSeconds <= 0 {
+		t.Errorf("sequenceIntervalSeconds = %v, want > 0", sequenceIntervalSeconds)
+	}
+}
+
+func TestCreateTreeInvalidStates(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	states := []trillian.TreeState{trillian.TreeState_DRAINING, trillian.TreeState_FROZEN}
+
+	for _, state := range states {
+		inTree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+		inTree.TreeState = state
+		if _, err := storage.CreateTree(ctx, s, inTree); err == nil {
+			t.Errorf("CreateTree() state: %v got: nil want: err", state)
+		}
+	}
+}
+
+func TestAdminTX_TreeWithNulls(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	// Setup: create a tree and set all nullable columns to null.
+	// Some columns have to be manually updated, as it's not possible to set
+	// some proto fields to nil.
+	tree, err := storage.CreateTree(ctx, s, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree() failed: %v", err)
+	}
+	treeID := tree.TreeId
+
+	if err := setNulls(ctx, DB, treeID); err != nil {
+		t.Fatalf("setNulls() = %v, want = nil", err)
+	}
+
+	tests := []struct {
+		desc string
+		fn   storage.AdminTXFunc
+	}{
+		{
+			desc: "GetTree",
+			fn: func(ctx context.Context, tx storage.AdminTX) error {
+				_, err := tx.GetTree(ctx, treeID)
+				return err
+			},
+		},
+		{
+			// ListTreeIDs *shouldn't* care about other columns, but let's test it just
+			// in case.
+			desc: "ListTreeIDs",
+			fn: func(ctx context.Context, tx storage.AdminTX) error {
+				ids, err := tx.ListTreeIDs(ctx, false /* includeDeleted */)
+				if err != nil {
+					return err
+				}
+				for _, id := range ids {
+					if id == treeID {
+						return nil
+					}
+				}
+				return fmt.Errorf("ID not found: %v", treeID)
+			},
+		},
+		{
+			desc: "ListTrees",
+			fn: func(ctx context.Context, tx storage.AdminTX) error {
+				trees, err := tx.ListTrees(ctx, false /* includeDeleted */)
+				if err != nil {
+					return err
+				}
+				for _, tree := range trees {
+					if tree.TreeId == treeID {
+						return nil
+					}
+				}
+				return fmt.Errorf("ID not found: %v", treeID)
+			},
+		},
+	}
+	for _, test := range tests {
+		if err := s.ReadWriteTransaction(ctx, test.fn); err != nil {
+			t.Errorf("%v: err = %v, want = nil", test.desc, err)
+		}
+	}
+}
+
+func TestAdminTX_StorageSettingsNotSupported(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	settings, err := ptypes.MarshalAny(&keyspb.PEMKeyFile{})
+	if err != nil {
+		t.Fatalf("Error marshaling proto: %v", err)
+	}
+
+	tests := []struct {
+		desc string
+		// fn attempts to either create or update a tree with a non-nil, valid Any proto
+		// on Tree.StorageSettings. It's expected to return an error.
+		fn func(storage.AdminStorage) error
+	}{
+		{
+			desc: "CreateTree",
+			fn: func(s storage.AdminStorage) error {
+				tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+				tree.StorageSettings = settings
+				_, err := storage.CreateTree(ctx, s, tree)
+				return err
+			},
+		},
+		{
+			desc: "UpdateTree",
+			fn: func(s storage.AdminStorage) error {
+				tree, err := storage.CreateTree(ctx, s, testonly.LogTree)
+				if err != nil {
+					t.Fatalf("CreateTree() failed with err = %v", err)
+				}
+				_, err = storage.UpdateTree(ctx, s, tree.TreeId, func(tree *trillian.Tree) { tree.StorageSettings = settings })
+				return err
+			},
+		},
+	}
+	for _, test := range tests {
+		if err := test.fn(s); err == nil {
+			t.Errorf("%v: err = nil, want non-nil", test.desc)
+		}
+	}
+}
+
+func TestAdminTX_MapStorageSettings(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	settings, err := ptypes.MarshalAny(&storagepb.MapStorageSettings{LeafCompression: storagepb.LeafCompression_SNAPPY})
+	if err != nil {
+		t.Fatalf("Error marshaling proto: %v", err)
+	}
+	tree := proto.Clone(testonly.MapTree).(*trillian.Tree)
+	tree.StorageSettings = settings
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if !proto.Equal(got.StorageSettings, settings) {
+		t.Errorf("GetTree().StorageSettings = %v, want %v", got.StorageSettings, settings)
+	}
+
+	other, err := ptypes.MarshalAny(&storagepb.MapStorageSettings{})
+	if err != nil {
+		t.Fatalf("Error marshaling proto: %v", err)
+	}
+	if _, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) { tree.StorageSettings = other }); err == nil {
+		t.Error("UpdateTree() changed storage_settings, want err")
+	}
+	if _, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) { tree.DisplayName = "renamed" }); err != nil {
+		t.Errorf("UpdateTree() returned err = %v", err)
+	}
+}
+
+func TestAdminTX_MapIndexBits(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.MapTree).(*trillian.Tree)
+	tree.MapIndexBits = 160
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if got.MapIndexBits != tree.MapIndexBits {
+		t.Errorf("GetTree().MapIndexBits = %v, want %v", got.MapIndexBits, tree.MapIndexBits)
+	}
+}
+
+func TestAdminTX_DuplicateLeafPolicy(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.DuplicateLeafPolicy = trillian.DuplicateLeafPolicy_DEDUPLICATE
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if got.DuplicateLeafPolicy != tree.DuplicateLeafPolicy {
+		t.Errorf("GetTree().DuplicateLeafPolicy = %v, want %v", got.DuplicateLeafPolicy, tree.DuplicateLeafPolicy)
+	}
+}
+
+func TestAdminTX_AdditionalKeys(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.AdditionalPrivateKeys = []*any.Any{tree.PrivateKey}
+	tree.AdditionalPublicKeys = []*keyspb.PublicKey{tree.PublicKey}
+	tree.SignatureThreshold = 1
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if len(got.AdditionalPrivateKeys) != 1 || !proto.Equal(got.AdditionalPrivateKeys[0], tree.PrivateKey) {
+		t.Errorf("GetTree().AdditionalPrivateKeys = %v, want %v", got.AdditionalPrivateKeys, tree.AdditionalPrivateKeys)
+	}
+	if len(got.AdditionalPublicKeys) != 1 || !proto.Equal(got.AdditionalPublicKeys[0], tree.PublicKey) {
+		t.Errorf("GetTree().AdditionalPublicKeys = %v, want %v", got.AdditionalPublicKeys, tree.AdditionalPublicKeys)
+	}
+	if got.SignatureThreshold != tree.SignatureThreshold {
+		t.Errorf("GetTree().SignatureThreshold = %v, want %v", got.SignatureThreshold, tree.SignatureThreshold)
+	}
+}
+
+func TestAdminTX_Labels(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.Labels = map[string]string{"env": "prod"}
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if !reflect.DeepEqual(got.Labels, tree.Labels) {
+		t.Errorf("GetTree().Labels = %v, want %v", got.Labels, tree.Labels)
+	}
+
+	wantLabels := map[string]string{"owner": "ct"}
+	updated, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) {
+		tree.Labels = wantLabels
+	})
+	if err != nil {
+		t.Fatalf("UpdateTree() returned err = %v", err)
+	}
+	if !reflect.DeepEqual(updated.Labels, wantLabels) {
+		t.Errorf("UpdateTree().Labels = %v, want %v", updated.Labels, wantLabels)
+	}
+	if got, err = storage.GetTree(ctx, s, created.TreeId); err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if !reflect.DeepEqual(got.Labels, wantLabels) {
+		t.Errorf("GetTree().Labels = %v, want %v", got.Labels, wantLabels)
+	}
+}
+
+func TestAdminTX_DeleteRetention(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.DeleteRetention = ptypes.DurationProto(48 * time.Hour)
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	if !proto.Equal(created.DeleteRetention, tree.DeleteRetention) {
+		t.Errorf("CreateTree().DeleteRetention = %v, want %v", created.DeleteRetention, tree.DeleteRetention)
+	}
+
+	updated, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) {
+		tree.DeleteRetention = nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateTree() returned err = %v", err)
+	}
+	if updated.DeleteRetention != nil {
+		t.Errorf("UpdateTree().DeleteRetention = %v, want nil", updated.DeleteRetention)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if got.DeleteRetention != nil {
+		t.Errorf("GetTree().DeleteRetention = %v, want nil", got.DeleteRetention)
+	}
+}
+
+func TestAdminTX_Quota(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.Quota = &trillian.TreeQuota{ReadTokensPerSecond: 100}
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	if !proto.Equal(created.Quota, tree.Quota) {
+		t.Errorf("CreateTree().Quota = %v, want %v", created.Quota, tree.Quota)
+	}
+
+	want := &trillian.TreeQuota{WriteTokensPerSecond: 10}
+	if _, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) {
+		tree.Quota = want
+	}); err != nil {
+		t.Fatalf("UpdateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if !proto.Equal(got.Quota, want) {
+		t.Errorf("GetTree().Quota = %v, want %v", got.Quota, want)
+	}
+}
+
+func TestAdminTX_Owner(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.Owner = "tenant-1"
+	created, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	if created.Owner != tree.Owner {
+		t.Errorf("CreateTree().Owner = %q, want %q", created.Owner, tree.Owner)
+	}
+
+	const want = "tenant-2"
+	if _, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) {
+		tree.Owner = want
+	}); err != nil {
+		t.Fatalf("UpdateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if got.Owner != want {
+		t.Errorf("GetTree().Owner = %q, want %q", got.Owner, want)
+	}
+}
+
+func TestAdminTX_KeyRotation(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	created, err := storage.CreateTree(ctx, s, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+	rotation := &trillian.KeyRotation{
+		PrivateKey: testonly.MapTree.PrivateKey,
+		PublicKey:  testonly.MapTree.PublicKey,
+		RetireTime: ptypes.TimestampNow(),
+	}
+	if _, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) {
+		tree.KeyRotation = rotation
+	}); err != nil {
+		t.Fatalf("UpdateTree() returned err = %v", err)
+	}
+	got, err := storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if !proto.Equal(got.KeyRotation, rotation) {
+		t.Errorf("GetTree().KeyRotation = %v, want %v", got.KeyRotation, rotation)
+	}
+
+	// Retire the old key.
+	if _, err := storage.UpdateTree(ctx, s, created.TreeId, func(tree *trillian.Tree) {
+		tree.PrivateKey = tree.KeyRotation.PrivateKey
+		tree.PublicKey = tree.KeyRotation.PublicKey
+		tree.KeyRotation = nil
+	}); err != nil {
+		t.Fatalf("UpdateTree() returned err = %v", err)
+	}
+	got, err = storage.GetTree(ctx, s, created.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if got.KeyRotation != nil {
+		t.Errorf("GetTree().KeyRotation = %v, want nil", got.KeyRotation)
+	}
+	if !proto.Equal(got.PublicKey, rotation.PublicKey) {
+		t.Errorf("GetTree().PublicKey = %v, want %v", got.PublicKey, rotation.PublicKey)
+	}
+}
+
+func TestAdminTX_HardDeleteTree(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+
+	tree, err := storage.CreateTree(ctx, s, testonly.LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree() returned err = %v", err)
+	}
+
+	if err := s.ReadWriteTransaction(ctx, func(ctx context.Context, tx storage.AdminTX) error {
+		if _, err := tx.SoftDeleteTree(ctx, tree.TreeId); err != nil {
+			return err
+		}
+		return tx.HardDeleteTree(ctx, tree.TreeId)
+	}); err != nil {
+		t.Fatalf("ReadWriteTransaction() returned err = %v", err)
+	}
+
+	// Unlike the HardDelete tests on AdminStorageTester, here we have the chance to poke inside the
+	// database and check that the rows are gone, so let's do just that.
+	// If there's no record on Trees, then there can be no record in any of the dependent tables.
+	var name string
+	if err := DB.QueryRowContext(ctx, "SELECT DisplayName FROM Trees WHERE TreeId = ?", tree.TreeId).Scan(&name); err != sql.ErrNoRows {
+		t.Errorf("QueryRowContext() returned err = %v, want = %v", err, sql.ErrNoRows)
+	}
+}
+
+func TestCheckDatabaseAccessible_Fails(t *testing.T) {
+	ctx := context.Background()
+
+	// Pass in a closed database to provoke a failure.
+	db, done := openTestDBOrDie()
+	cleanTestDB(db)
+	s := NewAdminStorage(db)
+	done(ctx)
+
+	if err := s.CheckDatabaseAccessible(ctx); err == nil {
+		t.Error("TestCheckDatabaseAccessible_Fails got: nil, want: err")
+	}
+}
+
+func TestCheckDatabaseAccessible_OK(t *testing.T) {
+	cleanTestDB(DB)
+	s := NewAdminStorage(DB)
+	ctx := context.Background()
+	if err := s.CheckDatabaseAccessible(ctx); err != nil {
+		t.Errorf("TestCheckDatabaseAccessible_OK got: %v, want: nil", err)
+	}
+}
+
+func TestOpenDB_ExistingFile(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "sqlite")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "trillian.db")
+
+	db, err := OpenDB(file)
+	if err != nil {
+		t.Fatalf("OpenDB() returned err = %v", err)
+	}
+	tree := mustCreateTree(ctx, t, NewAdminStorage(db), testonly.LogTree)
+	db.Close()
+
+	// Reopening the file keeps its tables and trees.
+	db, err = OpenDB(file)
+	if err != nil {
+		t.Fatalf("OpenDB() of existing file returned err = %v", err)
+	}
+	defer db.Close()
+	got, err := storage.GetTree(ctx, NewAdminStorage(db), tree.TreeId)
+	if err != nil {
+		t.Fatalf("GetTree() returned err = %v", err)
+	}
+	if !proto.Equal(got, tree) {
+		t.Errorf("GetTree() = %v, want %v", got, tree)
+	}
+}
+
+func setNulls(ctx context.Context, db *sql.DB, treeID int64) error {
+	stmt, err := db.PrepareContext(ctx, "UPDATE Trees SET DisplayName = NULL, Description = NULL WHERE TreeId = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, treeID)
+	return err
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+)
+
+const (
+	selectLatestMapRevisionSQL = `SELECT MAX(MapRevision) FROM MapHead WHERE TreeId=?`
+	// deleteDeadMapRevisionLeasesSQL deletes the leases which have expired, or
+	// whose revision has been written.
+	deleteDeadMapRevisionLeasesSQL = `DELETE FROM MapRevisionLease WHERE TreeId=? AND (Revision<=? OR ExpiryNanos<=?)`
+	// Concurrent reservations can't reserve the same revision, as their
+	// transactions hold the database lock from when they begin.
+	selectLastMapRevisionLeaseSQL = `SELECT MAX(Revision) FROM MapRevisionLease WHERE TreeId=?`
+	insertMapRevisionLeaseSQL     = `INSERT INTO MapRevisionLease(TreeId, Revision, Token, ExpiryNanos) VALUES (?, ?, ?, ?)`
+	selectMapRevisionLeaseSQL     = `SELECT Token, ExpiryNanos FROM MapRevisionLease WHERE TreeId=? AND Revision=? AND ExpiryNanos>?`
+)
+
+// ReserveMapRevision implements storage.MapRevisionReserver.
+func (m *sqliteMapStorage) ReserveMapRevision(ctx context.Context, tree *trillian.Tree, token []byte, now, expiry time.Time) (int64, error) {
+	tx, err := m.db.BeginTx(ctx, nil /* opts */)
+	if err != nil {
+		glog.Warningf("Could not start revision reservation TX: %s", err)
+		return 0, err
+	}
+	defer tx.Rollback() // Does nothing once committed.
+
+	var latest sql.NullInt64
+	if err := tx.QueryRowContext(ctx, m.tag(ctx, tree.TreeId, selectLatestMapRevisionSQL), tree.TreeId).Scan(&latest); err != nil {
+		return 0, err
+	}
+	if !latest.Valid {
+		return 0, storage.ErrTreeNeedsInit
+	}
+	if _, err := tx.ExecContext(ctx, m.tag(ctx, tree.TreeId, deleteDeadMapRevisionLeasesSQL), tree.TreeId, latest.Int64, now.UnixNano()); err != nil {
+		glog.Warningf("Failed to delete dead revision leases: %s", err)
+		return 0, err
+	}
+	var last sql.NullInt64
+	if err := tx.QueryRowContext(ctx, m.tag(ctx, tree.TreeId, selectLastMapRevisionLeaseSQL), tree.TreeId).Scan(&last); err != nil {
+		return 0, err
+	}
+
+	rev := latest.Int64 + 1
+	if last.Valid && last.Int64 >= rev {
+		rev = last.Int64 + 1
+	}
+	if _, err := tx.ExecContext(ctx, m.tag(ctx, tree.TreeId, insertMapRevisionLeaseSQL), tree.TreeId, rev, token, expiry.UnixNano()); err != nil {
+		glog.Warningf("Failed to insert revision lease: %s", err)
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return rev, nil
+}
+
+// GetMapRevisionLease implements storage.MapRevisionReserver.
+func (m *sqliteMapStorage) GetMapRevisionLease(ctx context.Context, tree *trillian.Tree, revision int64, now time.Time) (*storage.MapRevisionLease, error) {
+	var token []byte
+	var expiry int64
+	err := m.db.QueryRowContext(ctx, m.tag(ctx, tree.TreeId, selectMapRevisionLeaseSQL), tree.TreeId, revision, now.UnixNano()).Scan(&token, &expiry)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &storage.MapRevisionLease{Revision: revision, Token: token, Expiry: time.Unix(0, expiry)}, nil
+}
@@ -0,0 +1,752 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/trillian"
+	"github.com/google/trillian/examples/ct/ctmapper/ctmapperpb"
+	"github.com/google/trillian/integration/storagetest"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/storagepb"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/types"
+	"github.com/kylelemons/godebug/pretty"
+
+	tcrypto "github.com/google/trillian/crypto"
+	storageto "github.com/google/trillian/storage/testonly"
+)
+
+var fixedSigner = tcrypto.NewSigner(0, testonly.NewSignerWithFixedSig(nil, []byte("notempty")), crypto.SHA256)
+
+func TestMapIntegration(t *testing.T) {
+	ctx := context.Background()
+	db, cleanup := openTestDBOrDie()
+	defer db.Close()
+	defer cleanup(ctx)
+
+	storageFactory := func(context.Context, *testing.T) (storage.MapStorage, storage.AdminStorage) {
+		cleanTestDB(db)
+		return NewMapStorage(db), NewAdminStorage(db)
+	}
+
+	storagetest.RunMapStorageTests(t, storageFactory)
+}
+
+func MustSignMapRoot(t *testing.T, root *types.MapRootV1) *trillian.SignedMapRoot {
+	t.Helper()
+	r, err := fixedSigner.SignMapRoot(root)
+	if err != nil {
+		t.Fatalf("SignMapRoot(): %v", err)
+	}
+	return r
+}
+
+func TestMapReadWriteTransaction(t *testing.T) {
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	activeMap := createInitializedMapForTests(ctx, t, s, as)
+
+	tests := []struct {
+		desc        string
+		tree        *trillian.Tree
+		wantRev     int64
+		wantTXRev   int64
+		wantErr     bool
+		wantRootErr string
+	}{
+		{
+			desc:        "unknownBegin",
+			tree:        mapTree(-1),
+			wantRev:     0,
+			wantTXRev:   -1,
+			wantErr:     true,
+			wantRootErr: "tree must be pulled from storage",
+		},
+		{
+			desc:      "activeMapBegin",
+			tree:      activeMap,
+			wantRev:   0,
+			wantTXRev: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := s.ReadWriteTransaction(ctx, test.tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+				root, err := tx.LatestSignedMapRoot(ctx)
+				if err != nil {
+					if !strings.Contains(err.Error(), test.wantRootErr) {
+						t.Errorf("LatestSignedMapRoot() returned err = %v", err)
+					}
+					return nil
+				}
+				if len(test.wantRootErr) != 0 {
+					t.Fatalf("LatestSignedMapRoot() returned err = %v, want: nil", err)
+				}
+				var mapRoot types.MapRootV1
+				if err := mapRoot.UnmarshalBinary(root.MapRoot); err != nil {
+					t.Fatalf("UmarshalBinary(): %v", err)
+				}
+				gotRev, _ := tx.WriteRevision(ctx)
+				if gotRev != test.wantTXRev {
+					t.Errorf("WriteRevision() = %v, want = %v", gotRev, test.wantTXRev)
+				}
+				if got, want := int64(mapRoot.Revision), test.wantRev; got != want {
+					t.Errorf("TreeRevision() = %v, want = %v", got, want)
+				}
+				return nil
+			})
+			if hasErr := err != nil; hasErr != test.wantErr {
+				t.Fatalf("err = %q, wantErr = %v", err, test.wantErr)
+			} else if hasErr {
+				return
+			}
+		})
+	}
+}
+
+func TestMapRootUpdate(t *testing.T) {
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, as)
+
+	populatedMetadata := testonly.MustMarshalAnyNoT(&ctmapperpb.MapperMetadata{HighestFullyCompletedSeq: 1})
+
+	for _, tc := range []struct {
+		desc         string
+		root         *trillian.SignedMapRoot
+		wantMetadata []byte
+	}{
+		{
+			desc: "Initial root",
+			root: MustSignMapRoot(t, &types.MapRootV1{
+				TimestampNanos: 98765,
+				Revision:       5,
+				RootHash:       []byte(dummyHash),
+			}),
+		},
+		{
+			desc: "Root update",
+			root: MustSignMapRoot(t, &types.MapRootV1{
+				TimestampNanos: 98766,
+				Revision:       6,
+				RootHash:       []byte(dummyHash),
+			}),
+		},
+		{
+			desc: "Root with default (empty) MapperMetadata",
+			root: MustSignMapRoot(t, &types.MapRootV1{
+				TimestampNanos: 98768,
+				Revision:       7,
+				RootHash:       []byte(dummyHash),
+			}),
+		},
+		{
+			desc: "Root with non-default (populated) MapperMetadata",
+			root: MustSignMapRoot(t, &types.MapRootV1{
+				TimestampNanos: 98769,
+				Revision:       8,
+				RootHash:       []byte(dummyHash),
+				Metadata:       populatedMetadata,
+			}),
+			wantMetadata: populatedMetadata,
+		},
+	} {
+		func() {
+			runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+				if err := tx.StoreSignedMapRoot(ctx, tc.root); err != nil {
+					t.Fatalf("%v: Failed to store signed map root: %v", tc.desc, err)
+				}
+				return nil
+			})
+		}()
+
+		func() {
+			runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+				smr, err := tx.LatestSignedMapRoot(ctx)
+				if err != nil {
+					t.Fatalf("%v: Failed to read back new map root: %v", tc.desc, err)
+				}
+
+				var root types.MapRootV1
+				if err := root.UnmarshalBinary(smr.MapRoot); err != nil {
+					t.Fatalf("%v: UnmarshalBinary(): %v", tc.desc, err)
+				}
+
+				if got, want := root.Metadata, tc.wantMetadata; !bytes.Equal(got, want) {
+					t.Errorf("%v: LatestSignedMapRoot() diff(-got, +want) \n%v", tc.desc, pretty.Compare(got, want))
+				}
+				return nil
+			})
+		}()
+	}
+}
+
+var keyHash = []byte([]byte("A Key Hash"))
+var mapLeaf = &trillian.MapLeaf{
+	Index:     keyHash,
+	LeafHash:  []byte("A Hash"),
+	LeafValue: []byte("A Value"),
+	ExtraData: []byte("Some Extra Data"),
+}
+
+func TestMapSetGetRoundTrip(t *testing.T) {
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, as)
+
+	readRev := int64(1)
+	{
+		runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+			if err := tx.Set(ctx, keyHash, mapLeaf); err != nil {
+				t.Fatalf("Failed to set %v to %v: %v", keyHash, mapLeaf, err)
+			}
+			return nil
+		})
+	}
+
+	{
+		runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+			readValues, err := tx.Get(ctx, readRev, [][]byte{keyHash})
+			if err != nil {
+				t.Fatalf("Failed to get %v:  %v", keyHash, err)
+			}
+			if got, want := len(readValues), 1; got != want {
+				t.Fatalf("Got %d values, expected %d", got, want)
+			}
+			if got, want := readValues[0], mapLeaf; !proto.Equal(got, want) {
+				t.Fatalf("Read back %v, but expected %v", got, want)
+			}
+			return nil
+		})
+	}
+}
+
+func TestMapSetSameKeyInSameRevisionFails(t *testing.T) {
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, as)
+
+	{
+		runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+			if err := tx.Set(ctx, keyHash, mapLeaf); err != nil {
+				t.Fatalf("Failed to set %v to %v: %v", keyHash, mapLeaf, err)
+			}
+			return nil
+		})
+	}
+
+	{
+		runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+			if err := tx.Set(ctx, keyHash, mapLeaf); err == nil {
+				t.Fatalf("Unexpectedly succeeded in setting %v to %v", keyHash, mapLeaf)
+			}
+			return nil
+		})
+	}
+}
+
+func TestMapGet0Results(t *testing.T) {
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, as)
+
+	for _, tc := range []struct {
+		index [][]byte
+	}{
+		{index: nil}, //empty list.
+		{index: [][]byte{[]byte("This doesn't exist.")}},
+	} {
+		t.Run(fmt.Sprintf("tx.Get(%s)", tc.index), func(t *testing.T) {
+			runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+				readValues, err := tx.Get(ctx, 1, tc.index)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, want := len(readValues), 0; got != want {
+					t.Fatalf("len = %d, want %d", got, want)
+				}
+				return nil
+			})
+		})
+	}
+}
+
+func TestMapSetGetMultipleRevisions(t *testing.T) {
+
+	// Write two roots for a map and make sure the one with the newest timestamp supersedes
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, as)
+
+	tests := []struct {
+		rev  int64
+		leaf *trillian.MapLeaf
+	}{
+		{0, &trillian.MapLeaf{Index: keyHash, LeafHash: []byte{0}, LeafValue: []byte{0}, ExtraData: []byte{0}}},
+		{1, &trillian.MapLeaf{Index: keyHash, LeafHash: []byte{1}, LeafValue: []byte{1}, ExtraData: []byte{1}}},
+		{2, &trillian.MapLeaf{Index: keyHash, LeafHash: []byte{2}, LeafValue: []byte{2}, ExtraData: []byte{2}}},
+		{3, &trillian.MapLeaf{Index: keyHash, LeafHash: []byte{3}, LeafValue: []byte{3}, ExtraData: []byte{3}}},
+	}
+
+	for _, tc := range tests {
+		func() {
+			// Write the current test case.
+			runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+				mapTX := tx.(*mapTreeTX)
+				mapTX.treeTX.writeRevision = tc.rev
+				if err := tx.Set(ctx, keyHash, tc.leaf); err != nil {
+					t.Fatalf("Failed to set %v to %v: %v", keyHash, tc.leaf, err)
+				}
+				return nil
+			})
+
+			// Read at a point in time in the future. Expect to get the latest value.
+			// Read at each point in the past. Expect to get that exact point in history.
+			for i := int64(0); i < int64(len(tests)); i++ {
+				func() {
+					expectRev := i
+					if expectRev > tc.rev {
+						expectRev = tc.rev // For future revisions, expect the current value.
+					}
+
+					runMapTX(ctx, s, tree, t, func(ctx context.Context, tx2 storage.MapTreeTX) error {
+						readValues, err := tx2.Get(ctx, i, [][]byte{keyHash})
+						if err != nil {
+							t.Fatalf("At i %d failed to get %v:  %v", i, keyHash, err)
+						}
+						if got, want := len(readValues), 1; got != want {
+							t.Fatalf("At i %d got %d values, expected %d", i, got, want)
+						}
+						if got, want := readValues[0], tests[expectRev].leaf; !proto.Equal(got, want) {
+							t.Fatalf("At i %d read back %v, but expected %v", i, got, want)
+						}
+						return nil
+					})
+				}()
+			}
+		}()
+	}
+}
+
+func TestGetSignedMapRootNotExist(t *testing.T) {
+
+	ctx := context.Background()
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	tree := mustCreateTree(ctx, t, as, storageto.MapTree) // Uninitialized: no revision 0 MapRoot exists.
+	s := NewMapStorage(DB)
+
+	err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		_, err := tx.GetSignedMapRoot(ctx, 0)
+		if got, want := err, storage.ErrTreeNeedsInit; got != want {
+			t.Fatalf("GetSignedMapRoot: %v, want %v", got, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadWriteTransaction: %v", err)
+	}
+}
+
+func TestLatestSignedMapRootNoneWritten(t *testing.T) {
+	// TODO(phad): I'm considering removing this test, because for an Map that has been
+	// initialized there should always be the revision 0 SMR written to the DB, and
+	// without initialization the error path is identical to that tested in the func
+	// TestGetSignedMapRootNotExist above.
+	t.Skip("TODO: remove this as it can no longer occur.")
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, as)
+
+	runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+		root, err := tx.LatestSignedMapRoot(ctx)
+		if err != nil {
+			t.Fatalf("Failed to read an empty map root: %v", err)
+		}
+		if len(root.MapRoot) != 0 || root.Signature != nil {
+			t.Fatalf("Read a root with contents when it should be empty: %v", root)
+		}
+		return nil
+	})
+}
+
+func TestGetSignedMapRoot(t *testing.T) {
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, as)
+
+	revision := int64(5)
+	root := MustSignMapRoot(t, &types.MapRootV1{
+		TimestampNanos: 98765,
+		Revision:       uint64(revision),
+		RootHash:       []byte(dummyHash),
+	})
+	runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+		if err := tx.StoreSignedMapRoot(ctx, root); err != nil {
+			t.Fatalf("Failed to store signed root: %v", err)
+		}
+		return nil
+	})
+
+	{
+		runMapTX(ctx, s, tree, t, func(ctx context.Context, tx2 storage.MapTreeTX) error {
+			root2, err := tx2.GetSignedMapRoot(ctx, revision)
+			if err != nil {
+				t.Fatalf("Failed to get back new map root: %v", err)
+			}
+			if !proto.Equal(root, root2) {
+				t.Fatalf("Getting root round trip failed: <%#v> and: <%#v>", root, root2)
+			}
+			return nil
+		})
+	}
+}
+
+func TestLatestSignedMapRoot(t *testing.T) {
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, as)
+
+	root := MustSignMapRoot(t, &types.MapRootV1{
+		TimestampNanos: 98765,
+		Revision:       5,
+		RootHash:       []byte(dummyHash),
+	})
+	runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+		if err := tx.StoreSignedMapRoot(ctx, root); err != nil {
+			t.Fatalf("Failed to store signed root: %v", err)
+		}
+		return nil
+	})
+
+	{
+		runMapTX(ctx, s, tree, t, func(ctx context.Context, tx2 storage.MapTreeTX) error {
+			root2, err := tx2.LatestSignedMapRoot(ctx)
+			if err != nil {
+				t.Fatalf("Failed to read back new map root: %v", err)
+			}
+			if !proto.Equal(root, root2) {
+				t.Fatalf("Root round trip failed: <%#v> and: <%#v>", root, root2)
+			}
+			return nil
+		})
+	}
+}
+
+func TestDuplicateSignedMapRoot(t *testing.T) {
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, as)
+
+	runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+		root := MustSignMapRoot(t, &types.MapRootV1{
+			TimestampNanos: 98765,
+			Revision:       5,
+			RootHash:       []byte(dummyHash),
+		})
+		if err := tx.StoreSignedMapRoot(ctx, root); err != nil {
+			t.Fatalf("Failed to store signed map root: %v", err)
+		}
+		// Shouldn't be able to do it again
+		if err := tx.StoreSignedMapRoot(ctx, root); err == nil {
+			t.Fatal("Allowed duplicate signed map root")
+		}
+		return nil
+	})
+}
+
+func TestReadOnlyMapTX_Rollback(t *testing.T) {
+	ctx := context.Background()
+
+	cleanTestDB(DB)
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	activeMap := createInitializedMapForTests(ctx, t, s, as)
+	tx, err := s.SnapshotForTree(ctx, activeMap)
+	if err != nil {
+		t.Fatalf("Snapshot() = (_, %v), want = (_, nil)", err)
+	}
+	defer tx.Close()
+	// It's a bit hard to have a more meaningful test. This should suffice.
+	if err := tx.Rollback(); err != nil {
+		t.Errorf("Rollback() = (_, %v), want = (_, nil)", err)
+	}
+}
+
+func TestMapWriteQueue(t *testing.T) {
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, as)
+
+	queued := make([]*storage.QueuedMapWrite, 3)
+	for i := range queued {
+		queued[i] = &storage.QueuedMapWrite{
+			Leaves:         []*trillian.MapLeaf{{Index: keyHash, LeafValue: []byte{byte(i)}}},
+			Metadata:       []byte{byte(i)},
+			QueueTimestamp: time.Unix(0, int64(i)),
+		}
+		if err := s.(storage.MapWriteQueuer).QueueMapWrite(ctx, tree, queued[i]); err != nil {
+			t.Fatalf("QueueMapWrite(): %v", err)
+		}
+	}
+
+	dequeue := func(limit int) []*storage.QueuedMapWrite {
+		t.Helper()
+		var writes []*storage.QueuedMapWrite
+		runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+			var err error
+			writes, err = tx.(storage.MapWriteDequeuer).DequeueMapWrites(ctx, limit)
+			return err
+		})
+		return writes
+	}
+	for _, want := range [][]*storage.QueuedMapWrite{queued[:2], queued[2:], nil} {
+		got := dequeue(2)
+		if len(got) != len(want) {
+			t.Fatalf("DequeueMapWrites(): got %d writes, want %d", len(got), len(want))
+		}
+		for i := range got {
+			if !proto.Equal(got[i].Leaves[0], want[i].Leaves[0]) || !bytes.Equal(got[i].Metadata, want[i].Metadata) || !got[i].QueueTimestamp.Equal(want[i].QueueTimestamp) {
+				t.Errorf("DequeueMapWrites()[%d]=%+v, want %+v", i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestMapRevisionLease(t *testing.T) {
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+	tree := createInitializedMapForTests(ctx, t, s, as)
+	r := s.(storage.MapRevisionReserver)
+
+	now := time.Unix(100, 0)
+	reserve := func(token string, expiry time.Time) int64 {
+		t.Helper()
+		rev, err := r.ReserveMapRevision(ctx, tree, []byte(token), now, expiry)
+		if err != nil {
+			t.Fatalf("ReserveMapRevision(%q): %v", token, err)
+		}
+		return rev
+	}
+	// Each reservation gets the revision after the last one, until the
+	// earliest expires.
+	if got, want := reserve("a", now.Add(time.Second)), int64(1); got != want {
+		t.Errorf("ReserveMapRevision(a)=%v, want %v", got, want)
+	}
+	if got, want := reserve("b", now.Add(time.Minute)), int64(2); got != want {
+		t.Errorf("ReserveMapRevision(b)=%v, want %v", got, want)
+	}
+
+	lease, err := r.GetMapRevisionLease(ctx, tree, 1, now)
+	if err != nil {
+		t.Fatalf("GetMapRevisionLease(): %v", err)
+	}
+	if lease == nil || string(lease.Token) != "a" || !lease.Expiry.Equal(now.Add(time.Second)) {
+		t.Errorf("GetMapRevisionLease(1)=%+v, want lease of a", lease)
+	}
+
+	now = now.Add(2 * time.Second)
+	if lease, err := r.GetMapRevisionLease(ctx, tree, 1, now); err != nil || lease != nil {
+		t.Errorf("GetMapRevisionLease(1) after expiry=%+v, %v, want nil", lease, err)
+	}
+	if got, want := reserve("c", now.Add(time.Minute)), int64(3); got != want {
+		t.Errorf("ReserveMapRevision(c)=%v, want %v", got, want)
+	}
+}
+
+func TestMapGetIndexesByLeafHash(t *testing.T) {
+
+	cleanTestDB(DB)
+	ctx := context.Background()
+	as := NewAdminStorage(DB)
+	s := NewMapStorage(DB)
+
+	unindexed := createInitializedMapForTests(ctx, t, s, as)
+	runMapTX(ctx, s, unindexed, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+		if _, err := tx.GetIndexesByLeafHash(ctx, 0, []byte("hash")); err != storage.ErrNoLeafHashIndex {
+			t.Errorf("GetIndexesByLeafHash() = %v, want %v", err, storage.ErrNoLeafHashIndex)
+		}
+		return nil
+	})
+
+	settings, err := ptypes.MarshalAny(&storagepb.MapStorageSettings{LeafHashIndex: true})
+	if err != nil {
+		t.Fatalf("MarshalAny(): %v", err)
+	}
+	template := proto.Clone(storageto.MapTree).(*trillian.Tree)
+	template.StorageSettings = settings
+	tree := createInitializedMapFromTemplate(ctx, t, s, as, template)
+
+	key1, key2 := []byte("key1"), []byte("key2")
+	hash1, hash2 := []byte("hash1"), []byte("hash2")
+	for rev, leaves := range [][]*trillian.MapLeaf{
+		1: {{Index: key1, LeafHash: hash1, LeafValue: []byte("a")}, {Index: key2, LeafHash: hash1, LeafValue: []byte("a")}},
+		2: {{Index: key1, LeafHash: hash2, LeafValue: []byte("b")}, {Index: key2}},
+	} {
+		rev, leaves := rev, leaves
+		if len(leaves) == 0 {
+			continue
+		}
+		runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+			tx.(*mapTreeTX).treeTX.writeRevision = int64(rev)
+			for _, l := range leaves {
+				if err := tx.Set(ctx, l.Index, l); err != nil {
+					t.Fatalf("Set(%s): %v", l.Index, err)
+				}
+			}
+			return nil
+		})
+	}
+
+	check := func(desc string) {
+		t.Helper()
+		for _, test := range []struct {
+			revision int64
+			hash     []byte
+			want     [][]byte
+		}{
+			{revision: 1, hash: hash1, want: [][]byte{key1, key2}},
+			{revision: 2, hash: hash1},
+			{revision: 2, hash: hash2, want: [][]byte{key1}},
+		} {
+			runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+				got, err := tx.GetIndexesByLeafHash(ctx, test.revision, test.hash)
+				if err != nil {
+					t.Fatalf("%s: GetIndexesByLeafHash(%d, %s): %v", desc, test.revision, test.hash, err)
+				}
+				if diff := pretty.Compare(got, test.want); diff != "" {
+					t.Errorf("%s: GetIndexesByLeafHash(%d, %s) diff:\n%s", desc, test.revision, test.hash, diff)
+				}
+				return nil
+			})
+		}
+	}
+	check("before SetLeafHash")
+
+	// Recomputing a leaf hash moves the leaf to the new hash.
+	runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+		return tx.SetLeafHash(ctx, 2, key1, []byte("hash3"))
+	})
+	runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+		got, err := tx.GetIndexesByLeafHash(ctx, 2, []byte("hash3"))
+		if err != nil {
+			t.Fatalf("GetIndexesByLeafHash(): %v", err)
+		}
+		if diff := pretty.Compare(got, [][]byte{key1}); diff != "" {
+			t.Errorf("GetIndexesByLeafHash() after SetLeafHash diff:\n%s", diff)
+		}
+		return tx.SetLeafHash(ctx, 2, key1, hash2)
+	})
+
+	runMapTX(ctx, s, tree, t, func(ctx context.Context, tx storage.MapTreeTX) error {
+		return tx.DeleteRevisionsBefore(ctx, 2)
+	})
+	var count int
+	if err := DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM MapLeafHash WHERE TreeId=?", tree.TreeId).Scan(&count); err != nil {
+		t.Fatalf("QueryRow(): %v", err)
+	}
+	if got, want := count, 1; got != want {
+		t.Errorf("DeleteRevisionsBefore() left %d leaf hashes, want %d", got, want)
+	}
+}
+
+func runMapTX(ctx context.Context, s storage.MapStorage, tree *trillian.Tree, t *testing.T, f storage.MapTXFunc) {
+	if err := s.ReadWriteTransaction(ctx, tree, f); err != nil {
+		t.Fatalf("Failed to begin map tx: %v", err)
+	}
+}
+
+func createInitializedMapForTests(ctx context.Context, t *testing.T, s storage.MapStorage, as storage.AdminStorage) *trillian.Tree {
+	t.Helper()
+	return createInitializedMapFromTemplate(ctx, t, s, as, storageto.MapTree)
+}
+
+// createInitializedMapFromTemplate creates a map like template, and stores
+// its revision 0 root.
+func createInitializedMapFromTemplate(ctx context.Context, t *testing.T, s storage.MapStorage, as storage.AdminStorage, template *trillian.Tree) *trillian.Tree {
+	t.Helper()
+	tree := mustCreateTree(ctx, t, as, template)
+
+	signer := tcrypto.NewSigner(tree.TreeId, testonly.NewSignerWithFixedSig(nil, []byte("sig")), crypto.SHA256)
+	err := s.ReadWriteTransaction(ctx, tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+		initialRoot, _ := signer.SignMapRoot(&types.MapRootV1{
+			RootHash: []byte("rootHash"),
+			Revision: 0,
+		})
+
+		if err := tx.StoreSignedMapRoot(ctx, initialRoot); err != nil {
+			t.Fatalf("Failed to StoreSignedMapRoot: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadWriteTransaction() = %v", err)
+	}
+
+	return tree
+}
+
+func mapTree(mapID int64) *trillian.Tree {
+	return &trillian.Tree{
+		TreeId:       mapID,
+		TreeType:     trillian.TreeType_MAP,
+		HashStrategy: trillian.HashStrategy_TEST_MAP_HASHER,
+	}
+}
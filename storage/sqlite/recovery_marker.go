@@ -0,0 +1,74 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	insertRecoveryMarkerSQL = `INSERT INTO RecoveryMarker(TreeId, Revision, Position) VALUES(?, ?, '')`
+	selectRecoveryMarkerSQL = `SELECT Revision, Position FROM RecoveryMarker
+		 WHERE TreeId=? AND Revision=?`
+	selectLatestRecoveryMarkerSQL = `SELECT Revision, Position FROM RecoveryMarker
+		 WHERE TreeId=? ORDER BY Revision DESC LIMIT 1`
+	deleteRecoveryMarkersBeforeSQL = `DELETE FROM RecoveryMarker WHERE TreeId=? AND Revision<?`
+)
+
+// storeRecoveryMarker records the recovery marker of the root at revision. The
+// caller must hold t.mu.
+//
+// SQLite has no log of changes to restore the database from, so the position
+// of the marker is always empty. A restored copy of the database file holds
+// the markers of the roots it contains.
+func (t *treeTX) storeRecoveryMarker(ctx context.Context, revision int64) error {
+	if _, err := t.tx.ExecContext(ctx, t.tag(ctx, insertRecoveryMarkerSQL), t.treeID, revision); err != nil {
+		glog.Warningf("Failed to store recovery marker: %s", err)
+		return err
+	}
+	return nil
+}
+
+// GetRecoveryMarker implements storage.RecoveryMarkerReader.
+func (t *treeTX) GetRecoveryMarker(ctx context.Context, revision int64) (*storage.RecoveryMarker, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.readRecoveryMarker(ctx, selectRecoveryMarkerSQL, t.treeID, revision)
+}
+
+// LatestRecoveryMarker implements storage.RecoveryMarkerReader.
+func (t *treeTX) LatestRecoveryMarker(ctx context.Context) (*storage.RecoveryMarker, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.readRecoveryMarker(ctx, selectLatestRecoveryMarkerSQL, t.treeID)
+}
+
+func (t *treeTX) readRecoveryMarker(ctx context.Context, query string, args ...interface{}) (*storage.RecoveryMarker, error) {
+	m := &storage.RecoveryMarker{TreeID: t.treeID}
+	err := t.tx.QueryRowContext(ctx, t.tag(ctx, query), args...).Scan(&m.Revision, &m.Position)
+	if err == sql.ErrNoRows {
+		return nil, status.Errorf(codes.NotFound, "no recovery marker for tree %d", t.treeID)
+	} else if err != nil {
+		glog.Warningf("Failed to read recovery marker: %s", err)
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,274 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+// schemaSQL creates the tables of the SQLite storage, unless they already
+// exist. It's the MySQL schema in SQLite's dialect: ENUM columns are checked
+// text, binary columns are BLOBs, and the batched queue is not supported.
+const schemaSQL = `
+-- Tree parameters should not be changed after creation. Doing so can
+-- render the data in the tree unusable or inconsistent.
+CREATE TABLE IF NOT EXISTS Trees(
+  TreeId                BIGINT NOT NULL,
+  TreeState             VARCHAR(20) NOT NULL CHECK(TreeState IN('ACTIVE', 'FROZEN', 'DRAINING', 'QUARANTINED', 'PAUSED')),
+  TreeType              VARCHAR(20) NOT NULL CHECK(TreeType IN('LOG', 'MAP', 'PREORDERED_LOG')),
+  HashStrategy          VARCHAR(30) NOT NULL CHECK(HashStrategy IN('RFC6962_SHA256', 'TEST_MAP_HASHER', 'OBJECT_RFC6962_SHA256', 'CONIKS_SHA512_256', 'CONIKS_SHA256')),
+  HashAlgorithm         VARCHAR(10) NOT NULL CHECK(HashAlgorithm IN('SHA256')),
+  SignatureAlgorithm    VARCHAR(10) NOT NULL CHECK(SignatureAlgorithm IN('ECDSA', 'RSA')),
+  DisplayName           VARCHAR(20),
+  Description           VARCHAR(200),
+  CreateTimeMillis      BIGINT NOT NULL,
+  UpdateTimeMillis      BIGINT NOT NULL,
+  MaxRootDurationMillis BIGINT NOT NULL,
+  PrivateKey            BLOB NOT NULL,
+  PublicKey             BLOB NOT NULL,
+  Deleted               BOOLEAN,
+  DeleteTimeMillis      BIGINT,
+  -- Revision retention policy of maps. Zero values mean no limit.
+  RetainRevisions       BIGINT NOT NULL DEFAULT 0,
+  RetainDurationMillis  BIGINT NOT NULL DEFAULT 0,
+  -- Marshalled google.protobuf.Any holding the storage_settings of the tree.
+  StorageSettings       BLOB,
+  -- Number of bits of the indices of a map. Zero means the hash length.
+  MapIndexBits          INT NOT NULL DEFAULT 0,
+  -- Number of the trillian.DuplicateLeafPolicy of a log.
+  DuplicateLeafPolicy   INT NOT NULL DEFAULT 0,
+  -- Marshalled storagepb.AdditionalKeys holding the keys which also sign the
+  -- roots of the tree, if any.
+  AdditionalKeys        BLOB,
+  -- Number of keys whose signatures roots need. Zero means all of them.
+  SignatureThreshold    INT NOT NULL DEFAULT 0,
+  -- Marshalled storagepb.TreeLabels holding the labels of the tree, if any.
+  Labels                BLOB,
+  -- Period the tree is kept after being soft-deleted. Zero means the default.
+  DeleteRetentionMillis BIGINT NOT NULL DEFAULT 0,
+  -- Marshalled trillian.KeyRotation of the tree, if it is rotating its key.
+  KeyRotation           BLOB,
+  -- Rates of the trillian.TreeQuota of the tree. Zero means unlimited.
+  ReadTokensPerSecond   BIGINT NOT NULL DEFAULT 0,
+  WriteTokensPerSecond  BIGINT NOT NULL DEFAULT 0,
+  -- Principal which owns the tree, or empty if it has no owner.
+  Owner                 VARCHAR(255) NOT NULL DEFAULT '',
+  PRIMARY KEY(TreeId)
+);
+
+-- This table contains tree parameters that can be changed at runtime such as for
+-- administrative purposes.
+CREATE TABLE IF NOT EXISTS TreeControl(
+  TreeId                  BIGINT NOT NULL,
+  SigningEnabled          BOOLEAN NOT NULL,
+  SequencingEnabled       BOOLEAN NOT NULL,
+  SequenceIntervalSeconds INTEGER NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS Subtree(
+  TreeId               BIGINT NOT NULL,
+  SubtreeId            BLOB NOT NULL,
+  Nodes                BLOB NOT NULL,
+  SubtreeRevision      INTEGER NOT NULL,
+  PRIMARY KEY(TreeId, SubtreeId, SubtreeRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- The TreeRevisionIdx is used to enforce that there is only one STH at any
+-- tree revision
+CREATE TABLE IF NOT EXISTS TreeHead(
+  TreeId               BIGINT NOT NULL,
+  TreeHeadTimestamp    BIGINT,
+  TreeSize             BIGINT,
+  RootHash             BLOB NOT NULL,
+  RootSignature        BLOB NOT NULL,
+  TreeRevision         BIGINT,
+  -- Marshalled storagepb.RootSignatures by the additional keys of the tree.
+  AdditionalSignatures BLOB,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS TreeHeadRevisionIdx
+  ON TreeHead(TreeId, TreeRevision);
+
+-- Used to check that root hashes were published by a log.
+CREATE INDEX IF NOT EXISTS TreeHeadRootHashIdx
+  ON TreeHead(TreeId, RootHash);
+
+-- Signatures of third-party witnesses over log roots, at most one per public
+-- key and revision. KeyHash is the SHA-256 hash of PublicKey.
+CREATE TABLE IF NOT EXISTS LogRootSignature(
+  TreeId               BIGINT NOT NULL,
+  TreeRevision         BIGINT NOT NULL,
+  KeyHash              BLOB NOT NULL,
+  PublicKey            BLOB NOT NULL,
+  Signature            BLOB NOT NULL,
+  PRIMARY KEY(TreeId, TreeRevision, KeyHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- A recovery marker is stored in the same transaction as each log or map root.
+CREATE TABLE IF NOT EXISTS RecoveryMarker(
+  TreeId               BIGINT NOT NULL,
+  Revision             BIGINT NOT NULL,
+  Position             VARCHAR(255) NOT NULL,
+  PRIMARY KEY(TreeId, Revision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- A leaf that has not been sequenced has a row in this table. If duplicate leaves
+-- are allowed they will all reference this row.
+CREATE TABLE IF NOT EXISTS LeafData(
+  TreeId               BIGINT NOT NULL,
+  -- This is a personality specific hash of some subset of the leaf data.
+  -- Its only purpose is to allow Trillian to identify duplicate entries in
+  -- the context of the personality.
+  LeafIdentityHash     BLOB NOT NULL,
+  -- This is the data stored in the leaf for example in CT it contains a DER encoded
+  -- X.509 certificate but is application dependent
+  LeafValue            BLOB NOT NULL,
+  -- This is extra data that the application can associate with the leaf should it wish to.
+  -- This data is not included in signing and hashing.
+  ExtraData            BLOB,
+  -- The timestamp from when this leaf data was first queued for inclusion.
+  QueueTimestampNanos  BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, LeafIdentityHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- When a leaf is sequenced a row is added to this table. If logs allow duplicates then
+-- multiple rows will exist with different sequence numbers.
+CREATE TABLE IF NOT EXISTS SequencedLeafData(
+  TreeId               BIGINT NOT NULL,
+  SequenceNumber       BIGINT NOT NULL,
+  -- This is a personality specific hash of some subset of the leaf data.
+  LeafIdentityHash     BLOB NOT NULL,
+  -- This is a MerkleLeafHash as defined by the treehasher that the log uses. For example for
+  -- CT this hash will include the leaf prefix byte as well as the leaf data.
+  MerkleLeafHash       BLOB NOT NULL,
+  IntegrateTimestampNanos BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, SequenceNumber),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE,
+  FOREIGN KEY(TreeId, LeafIdentityHash) REFERENCES LeafData(TreeId, LeafIdentityHash) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS SequencedLeafMerkleIdx
+  ON SequencedLeafData(TreeId, MerkleLeafHash);
+
+CREATE TABLE IF NOT EXISTS Unsequenced(
+  TreeId               BIGINT NOT NULL,
+  -- The bucket field is to allow the use of time based ring bucketed schemes if desired. If
+  -- unused this should be set to zero for all entries.
+  Bucket               INTEGER NOT NULL,
+  -- This is a personality specific hash of some subset of the leaf data.
+  LeafIdentityHash     BLOB NOT NULL,
+  -- This is a MerkleLeafHash as defined by the treehasher that the log uses.
+  MerkleLeafHash       BLOB NOT NULL,
+  QueueTimestampNanos  BIGINT NOT NULL,
+  PRIMARY KEY (TreeId, Bucket, QueueTimestampNanos, LeafIdentityHash)
+);
+
+CREATE TABLE IF NOT EXISTS MapLeaf(
+  TreeId                BIGINT NOT NULL,
+  KeyHash               BLOB NOT NULL,
+  MapRevision           BIGINT NOT NULL,
+  LeafValue             BLOB NOT NULL,
+  PRIMARY KEY(TreeId, KeyHash, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- Lookup from leaf hashes to the versions of the map leaves which have them.
+-- Only written for maps with leaf_hash_index set in their storage_settings.
+CREATE TABLE IF NOT EXISTS MapLeafHash(
+  TreeId                BIGINT NOT NULL,
+  LeafHash              BLOB NOT NULL,
+  KeyHash               BLOB NOT NULL,
+  MapRevision           BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, LeafHash, KeyHash, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS MapHead(
+  TreeId               BIGINT NOT NULL,
+  MapHeadTimestamp     BIGINT,
+  RootHash             BLOB NOT NULL,
+  MapRevision          BIGINT,
+  RootSignature        BLOB NOT NULL,
+  MapperData           BLOB,
+  -- Marshalled storagepb.RootSignatures by the additional keys of the tree.
+  AdditionalSignatures BLOB,
+  PRIMARY KEY(TreeId, MapHeadTimestamp),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS MapHeadRevisionIdx
+  ON MapHead(TreeId, MapRevision);
+
+-- Idempotency tokens of map writes, so that retried writes can be detected.
+CREATE TABLE IF NOT EXISTS MapIdempotencyToken(
+  TreeId               BIGINT NOT NULL,
+  Token                BLOB NOT NULL,
+  MapRevision          BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, Token),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- Signatures of third-party witnesses over map roots, at most one per public
+-- key and revision. KeyHash is the SHA-256 hash of PublicKey.
+CREATE TABLE IF NOT EXISTS MapRootSignature(
+  TreeId               BIGINT NOT NULL,
+  MapRevision          BIGINT NOT NULL,
+  KeyHash              BLOB NOT NULL,
+  PublicKey            BLOB NOT NULL,
+  Signature            BLOB NOT NULL,
+  PRIMARY KEY(TreeId, MapRevision, KeyHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- Map writes queued by WriteLeaves, until the map merger folds them into a
+-- new revision of the map.
+CREATE TABLE IF NOT EXISTS MapWriteQueue(
+  QueueId              INTEGER PRIMARY KEY AUTOINCREMENT,
+  TreeId               BIGINT NOT NULL,
+  QueueTimestampNanos  BIGINT NOT NULL,
+  -- Leaves holds a serialized trillian.MapLeaves message.
+  Leaves               BLOB NOT NULL,
+  Metadata             BLOB,
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS MapWriteQueueTreeIdx
+  ON MapWriteQueue(TreeId, QueueId);
+
+-- Write revisions of maps reserved by ReserveRevision, until their lease
+-- expires or the revision is written.
+CREATE TABLE IF NOT EXISTS MapRevisionLease(
+  TreeId               BIGINT NOT NULL,
+  Revision             BIGINT NOT NULL,
+  Token                BLOB NOT NULL,
+  ExpiryNanos          BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, Revision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- The version of this schema, which trillian_migrate upgrades. Databases
+-- created before schemas were versioned lack this table, and are baselined
+-- when they are opened.
+CREATE TABLE IF NOT EXISTS SchemaVersion(
+  Version              INTEGER NOT NULL
+);
+
+INSERT INTO SchemaVersion(Version)
+  SELECT 2 WHERE NOT EXISTS (SELECT * FROM SchemaVersion);
+`
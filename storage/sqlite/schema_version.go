@@ -0,0 +1,233 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"fmt"
+
+	"github.com/google/trillian/storage/migrate"
+)
+
+// Schema is the history of the SQLite schema. New databases are created at
+// its latest version by schemaSQL, which has to be kept in step. SQLite
+// storage was added at version 2, and version 1 is the MySQL schema it was
+// ported from, so that versions match across storage systems.
+//
+// SQLite can't change the constraints of a column or drop it in place, so
+// some steps rebuild tables. Rebuilding Trees would delete the rows of the
+// tables which reference it if foreign keys were enforced, so such steps fail
+// unless they are.
+var Schema = migrate.Schema{
+	Table:     "SchemaVersion",
+	ExistsSQL: "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'SchemaVersion'",
+	Steps: []migrate.Step{
+		{Version: 1, Description: "baseline"},
+		{
+			Version:     2,
+			Description: "tree settings, root signatures, recovery markers and map write tables",
+			Up: withForeignKeysOff(
+				rebuild("Trees", treesV2, treesV1Columns),
+				[]string{
+					`ALTER TABLE TreeHead ADD COLUMN AdditionalSignatures BLOB`,
+					`CREATE INDEX TreeHeadRootHashIdx ON TreeHead(TreeId, RootHash)`,
+					`CREATE TABLE LogRootSignature(
+  TreeId               BIGINT NOT NULL,
+  TreeRevision         BIGINT NOT NULL,
+  KeyHash              BLOB NOT NULL,
+  PublicKey            BLOB NOT NULL,
+  Signature            BLOB NOT NULL,
+  PRIMARY KEY(TreeId, TreeRevision, KeyHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+					`CREATE TABLE RecoveryMarker(
+  TreeId               BIGINT NOT NULL,
+  Revision             BIGINT NOT NULL,
+  Position             VARCHAR(255) NOT NULL,
+  PRIMARY KEY(TreeId, Revision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+					`CREATE TABLE MapLeafHash(
+  TreeId                BIGINT NOT NULL,
+  LeafHash              BLOB NOT NULL,
+  KeyHash               BLOB NOT NULL,
+  MapRevision           BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, LeafHash, KeyHash, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+					`ALTER TABLE MapHead ADD COLUMN AdditionalSignatures BLOB`,
+					`CREATE TABLE MapIdempotencyToken(
+  TreeId               BIGINT NOT NULL,
+  Token                BLOB NOT NULL,
+  MapRevision          BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, Token),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+					`CREATE TABLE MapRootSignature(
+  TreeId               BIGINT NOT NULL,
+  MapRevision          BIGINT NOT NULL,
+  KeyHash              BLOB NOT NULL,
+  PublicKey            BLOB NOT NULL,
+  Signature            BLOB NOT NULL,
+  PRIMARY KEY(TreeId, MapRevision, KeyHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+					`CREATE TABLE MapWriteQueue(
+  QueueId              INTEGER PRIMARY KEY AUTOINCREMENT,
+  TreeId               BIGINT NOT NULL,
+  QueueTimestampNanos  BIGINT NOT NULL,
+  Leaves               BLOB NOT NULL,
+  Metadata             BLOB,
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+					`CREATE INDEX MapWriteQueueTreeIdx ON MapWriteQueue(TreeId, QueueId)`,
+					`CREATE TABLE MapRevisionLease(
+  TreeId               BIGINT NOT NULL,
+  Revision             BIGINT NOT NULL,
+  Token                BLOB NOT NULL,
+  ExpiryNanos          BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, Revision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`,
+				}),
+			// Downgrading fails if any tree is QUARANTINED or PAUSED.
+			Down: withForeignKeysOff(
+				[]string{
+					`DROP TABLE MapRevisionLease`,
+					`DROP TABLE MapWriteQueue`,
+					`DROP TABLE MapRootSignature`,
+					`DROP TABLE MapIdempotencyToken`,
+				},
+				rebuild("MapHead", mapHeadV1, mapHeadV1Columns),
+				[]string{
+					`CREATE UNIQUE INDEX MapHeadRevisionIdx ON MapHead(TreeId, MapRevision)`,
+					`DROP TABLE MapLeafHash`,
+					`DROP TABLE RecoveryMarker`,
+					`DROP TABLE LogRootSignature`,
+				},
+				rebuild("TreeHead", treeHeadV1, treeHeadV1Columns),
+				[]string{
+					`CREATE UNIQUE INDEX TreeHeadRevisionIdx ON TreeHead(TreeId, TreeRevision)`,
+				},
+				rebuild("Trees", treesV1, treesV1Columns)),
+		},
+	},
+}
+
+// withForeignKeysOff returns stmts, preceded by a check that foreign keys are
+// not enforced, which fails the step if they are.
+func withForeignKeysOff(stmts ...[]string) []string {
+	ret := []string{
+		`CREATE TEMP TABLE ForeignKeysOff(Enabled INTEGER NOT NULL CHECK(Enabled = 0))`,
+		`INSERT INTO ForeignKeysOff SELECT foreign_keys FROM pragma_foreign_keys`,
+	}
+	for _, s := range stmts {
+		ret = append(ret, s...)
+	}
+	return append(ret, `DROP TABLE ForeignKeysOff`)
+}
+
+// rebuild returns the statements which replace table with a table created by
+// the create statement, which takes the table name as a format argument, and
+// copy the listed columns. The indices of table are dropped.
+func rebuild(table, create, columns string) []string {
+	tmp := table + "Rebuilt"
+	return []string{
+		fmt.Sprintf(create, tmp),
+		fmt.Sprintf("INSERT INTO %s(%s) SELECT %s FROM %s", tmp, columns, columns, table),
+		"DROP TABLE " + table,
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", tmp, table),
+	}
+}
+
+const treesV1Columns = `TreeId, TreeState, TreeType, HashStrategy, HashAlgorithm, SignatureAlgorithm,
+  DisplayName, Description, CreateTimeMillis, UpdateTimeMillis, MaxRootDurationMillis,
+  PrivateKey, PublicKey, Deleted, DeleteTimeMillis`
+
+const treesV1 = `CREATE TABLE %s(
+  TreeId                BIGINT NOT NULL,
+  TreeState             VARCHAR(20) NOT NULL CHECK(TreeState IN('ACTIVE', 'FROZEN', 'DRAINING')),
+  TreeType              VARCHAR(20) NOT NULL CHECK(TreeType IN('LOG', 'MAP', 'PREORDERED_LOG')),
+  HashStrategy          VARCHAR(30) NOT NULL CHECK(HashStrategy IN('RFC6962_SHA256', 'TEST_MAP_HASHER', 'OBJECT_RFC6962_SHA256', 'CONIKS_SHA512_256', 'CONIKS_SHA256')),
+  HashAlgorithm         VARCHAR(10) NOT NULL CHECK(HashAlgorithm IN('SHA256')),
+  SignatureAlgorithm    VARCHAR(10) NOT NULL CHECK(SignatureAlgorithm IN('ECDSA', 'RSA')),
+  DisplayName           VARCHAR(20),
+  Description           VARCHAR(200),
+  CreateTimeMillis      BIGINT NOT NULL,
+  UpdateTimeMillis      BIGINT NOT NULL,
+  MaxRootDurationMillis BIGINT NOT NULL,
+  PrivateKey            BLOB NOT NULL,
+  PublicKey             BLOB NOT NULL,
+  Deleted               BOOLEAN,
+  DeleteTimeMillis      BIGINT,
+  PRIMARY KEY(TreeId)
+)`
+
+const treesV2 = `CREATE TABLE %s(
+  TreeId                BIGINT NOT NULL,
+  TreeState             VARCHAR(20) NOT NULL CHECK(TreeState IN('ACTIVE', 'FROZEN', 'DRAINING', 'QUARANTINED', 'PAUSED')),
+  TreeType              VARCHAR(20) NOT NULL CHECK(TreeType IN('LOG', 'MAP', 'PREORDERED_LOG')),
+  HashStrategy          VARCHAR(30) NOT NULL CHECK(HashStrategy IN('RFC6962_SHA256', 'TEST_MAP_HASHER', 'OBJECT_RFC6962_SHA256', 'CONIKS_SHA512_256', 'CONIKS_SHA256')),
+  HashAlgorithm         VARCHAR(10) NOT NULL CHECK(HashAlgorithm IN('SHA256')),
+  SignatureAlgorithm    VARCHAR(10) NOT NULL CHECK(SignatureAlgorithm IN('ECDSA', 'RSA')),
+  DisplayName           VARCHAR(20),
+  Description           VARCHAR(200),
+  CreateTimeMillis      BIGINT NOT NULL,
+  UpdateTimeMillis      BIGINT NOT NULL,
+  MaxRootDurationMillis BIGINT NOT NULL,
+  PrivateKey            BLOB NOT NULL,
+  PublicKey             BLOB NOT NULL,
+  Deleted               BOOLEAN,
+  DeleteTimeMillis      BIGINT,
+  RetainRevisions       BIGINT NOT NULL DEFAULT 0,
+  RetainDurationMillis  BIGINT NOT NULL DEFAULT 0,
+  StorageSettings       BLOB,
+  MapIndexBits          INT NOT NULL DEFAULT 0,
+  DuplicateLeafPolicy   INT NOT NULL DEFAULT 0,
+  AdditionalKeys        BLOB,
+  SignatureThreshold    INT NOT NULL DEFAULT 0,
+  Labels                BLOB,
+  DeleteRetentionMillis BIGINT NOT NULL DEFAULT 0,
+  KeyRotation           BLOB,
+  ReadTokensPerSecond   BIGINT NOT NULL DEFAULT 0,
+  WriteTokensPerSecond  BIGINT NOT NULL DEFAULT 0,
+  Owner                 VARCHAR(255) NOT NULL DEFAULT '',
+  PRIMARY KEY(TreeId)
+)`
+
+const treeHeadV1Columns = `TreeId, TreeHeadTimestamp, TreeSize, RootHash, RootSignature, TreeRevision`
+
+const treeHeadV1 = `CREATE TABLE %s(
+  TreeId               BIGINT NOT NULL,
+  TreeHeadTimestamp    BIGINT,
+  TreeSize             BIGINT,
+  RootHash             BLOB NOT NULL,
+  RootSignature        BLOB NOT NULL,
+  TreeRevision         BIGINT,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`
+
+const mapHeadV1Columns = `TreeId, MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData`
+
+const mapHeadV1 = `CREATE TABLE %s(
+  TreeId               BIGINT NOT NULL,
+  MapHeadTimestamp     BIGINT,
+  RootHash             BLOB NOT NULL,
+  MapRevision          BIGINT,
+  RootSignature        BLOB NOT NULL,
+  MapperData           BLOB,
+  PRIMARY KEY(TreeId, MapHeadTimestamp),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+)`
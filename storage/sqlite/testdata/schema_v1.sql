@@ -0,0 +1,134 @@
+-- SQLite version of the version 1 schema, which was the MySQL schema before
+-- schemas were versioned, for testing migrations from it.
+
+-- Tree parameters should not be changed after creation. Doing so can
+-- render the data in the tree unusable or inconsistent.
+CREATE TABLE IF NOT EXISTS Trees(
+  TreeId                BIGINT NOT NULL,
+  TreeState             VARCHAR(20) NOT NULL CHECK(TreeState IN('ACTIVE', 'FROZEN', 'DRAINING')),
+  TreeType              VARCHAR(20) NOT NULL CHECK(TreeType IN('LOG', 'MAP', 'PREORDERED_LOG')),
+  HashStrategy          VARCHAR(30) NOT NULL CHECK(HashStrategy IN('RFC6962_SHA256', 'TEST_MAP_HASHER', 'OBJECT_RFC6962_SHA256', 'CONIKS_SHA512_256', 'CONIKS_SHA256')),
+  HashAlgorithm         VARCHAR(10) NOT NULL CHECK(HashAlgorithm IN('SHA256')),
+  SignatureAlgorithm    VARCHAR(10) NOT NULL CHECK(SignatureAlgorithm IN('ECDSA', 'RSA')),
+  DisplayName           VARCHAR(20),
+  Description           VARCHAR(200),
+  CreateTimeMillis      BIGINT NOT NULL,
+  UpdateTimeMillis      BIGINT NOT NULL,
+  MaxRootDurationMillis BIGINT NOT NULL,
+  PrivateKey            BLOB NOT NULL,
+  PublicKey             BLOB NOT NULL,
+  Deleted               BOOLEAN,
+  DeleteTimeMillis      BIGINT,
+  PRIMARY KEY(TreeId)
+);
+
+-- This table contains tree parameters that can be changed at runtime such as for
+-- administrative purposes.
+CREATE TABLE IF NOT EXISTS TreeControl(
+  TreeId                  BIGINT NOT NULL,
+  SigningEnabled          BOOLEAN NOT NULL,
+  SequencingEnabled       BOOLEAN NOT NULL,
+  SequenceIntervalSeconds INTEGER NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS Subtree(
+  TreeId               BIGINT NOT NULL,
+  SubtreeId            BLOB NOT NULL,
+  Nodes                BLOB NOT NULL,
+  SubtreeRevision      INTEGER NOT NULL,
+  PRIMARY KEY(TreeId, SubtreeId, SubtreeRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- The TreeRevisionIdx is used to enforce that there is only one STH at any
+-- tree revision
+CREATE TABLE IF NOT EXISTS TreeHead(
+  TreeId               BIGINT NOT NULL,
+  TreeHeadTimestamp    BIGINT,
+  TreeSize             BIGINT,
+  RootHash             BLOB NOT NULL,
+  RootSignature        BLOB NOT NULL,
+  TreeRevision         BIGINT,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS TreeHeadRevisionIdx
+  ON TreeHead(TreeId, TreeRevision);
+
+-- A leaf that has not been sequenced has a row in this table. If duplicate leaves
+-- are allowed they will all reference this row.
+CREATE TABLE IF NOT EXISTS LeafData(
+  TreeId               BIGINT NOT NULL,
+  -- This is a personality specific hash of some subset of the leaf data.
+  -- Its only purpose is to allow Trillian to identify duplicate entries in
+  -- the context of the personality.
+  LeafIdentityHash     BLOB NOT NULL,
+  -- This is the data stored in the leaf for example in CT it contains a DER encoded
+  -- X.509 certificate but is application dependent
+  LeafValue            BLOB NOT NULL,
+  -- This is extra data that the application can associate with the leaf should it wish to.
+  -- This data is not included in signing and hashing.
+  ExtraData            BLOB,
+  -- The timestamp from when this leaf data was first queued for inclusion.
+  QueueTimestampNanos  BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, LeafIdentityHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- When a leaf is sequenced a row is added to this table. If logs allow duplicates then
+-- multiple rows will exist with different sequence numbers.
+CREATE TABLE IF NOT EXISTS SequencedLeafData(
+  TreeId               BIGINT NOT NULL,
+  SequenceNumber       BIGINT NOT NULL,
+  -- This is a personality specific hash of some subset of the leaf data.
+  LeafIdentityHash     BLOB NOT NULL,
+  -- This is a MerkleLeafHash as defined by the treehasher that the log uses. For example for
+  -- CT this hash will include the leaf prefix byte as well as the leaf data.
+  MerkleLeafHash       BLOB NOT NULL,
+  IntegrateTimestampNanos BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, SequenceNumber),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE,
+  FOREIGN KEY(TreeId, LeafIdentityHash) REFERENCES LeafData(TreeId, LeafIdentityHash) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS SequencedLeafMerkleIdx
+  ON SequencedLeafData(TreeId, MerkleLeafHash);
+
+CREATE TABLE IF NOT EXISTS Unsequenced(
+  TreeId               BIGINT NOT NULL,
+  -- The bucket field is to allow the use of time based ring bucketed schemes if desired. If
+  -- unused this should be set to zero for all entries.
+  Bucket               INTEGER NOT NULL,
+  -- This is a personality specific hash of some subset of the leaf data.
+  LeafIdentityHash     BLOB NOT NULL,
+  -- This is a MerkleLeafHash as defined by the treehasher that the log uses.
+  MerkleLeafHash       BLOB NOT NULL,
+  QueueTimestampNanos  BIGINT NOT NULL,
+  PRIMARY KEY (TreeId, Bucket, QueueTimestampNanos, LeafIdentityHash)
+);
+
+CREATE TABLE IF NOT EXISTS MapLeaf(
+  TreeId                BIGINT NOT NULL,
+  KeyHash               BLOB NOT NULL,
+  MapRevision           BIGINT NOT NULL,
+  LeafValue             BLOB NOT NULL,
+  PRIMARY KEY(TreeId, KeyHash, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS MapHead(
+  TreeId               BIGINT NOT NULL,
+  MapHeadTimestamp     BIGINT,
+  RootHash             BLOB NOT NULL,
+  MapRevision          BIGINT,
+  RootSignature        BLOB NOT NULL,
+  MapperData           BLOB,
+  PRIMARY KEY(TreeId, MapHeadTimestamp),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS MapHeadRevisionIdx
+  ON MapHead(TreeId, MapRevision);
@@ -0,0 +1,532 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqlite provides a SQLite-based storage layer implementation, which
+// keeps all the trees of a single-node deployment in one local file.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/storage/cache"
+	"github.com/google/trillian/storage/querytag"
+	"github.com/google/trillian/storage/sqlpool"
+	"github.com/google/trillian/storage/storagepb"
+	"github.com/google/trillian/storage/subtreecodec"
+	"github.com/google/trillian/storage/tree"
+)
+
+// These statements are fixed
+const (
+	insertSubtreeMultiSQL = `INSERT INTO Subtree(TreeId, SubtreeId, Nodes, SubtreeRevision) ` + placeholderSQL
+	insertTreeHeadSQL     = `INSERT INTO TreeHead(TreeId,TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,AdditionalSignatures)
+		 VALUES(?,?,?,?,?,?,?)`
+
+	selectSubtreeSQL = `
+ SELECT x.SubtreeId, x.MaxRevision, Subtree.Nodes
+ FROM (
+ 	SELECT n.TreeId, n.SubtreeId, max(n.SubtreeRevision) AS MaxRevision
+	FROM Subtree n
+	WHERE n.SubtreeId IN (` + placeholderSQL + `) AND
+	 n.TreeId = ? AND n.SubtreeRevision <= ?
+	GROUP BY n.TreeId, n.SubtreeId
+ ) AS x
+ INNER JOIN Subtree 
+ ON Subtree.SubtreeId = x.SubtreeId 
+ AND Subtree.SubtreeRevision = x.MaxRevision 
+ AND Subtree.TreeId = x.TreeId
+ AND Subtree.TreeId = ?`
+	placeholderSQL = "<placeholder>"
+
+	// busyTimeout is how long transactions wait for the database lock.
+	busyTimeout = 30 * time.Second
+)
+
+// SubtreeCodecs are the subtree codecs supported by the SQLite storage.
+var SubtreeCodecs = []subtreecodec.Codec{subtreecodec.Proto, subtreecodec.Packed, subtreecodec.Deflate, subtreecodec.Zstd}
+
+// TreeStorageOptions holds the options of the log and map storage.
+type TreeStorageOptions struct {
+	// SubtreeCodec is the codec with which subtrees are written. Subtrees are
+	// read with whichever codec they were written with, so it can be changed
+	// at any time.
+	SubtreeCodec subtreecodec.Codec
+
+	// QueryTags appends the querytag tags of the context of each query to its
+	// text, so that queries can be attributed to requests in slow query logs.
+	// Statements are then prepared in each transaction rather than cached.
+	QueryTags bool
+
+	// MapHashers provides the hashers of map hash strategies, in addition to
+	// the ones registered with hashers.RegisterMapHasher. It is only used by
+	// map storage.
+	MapHashers hashers.MapHasherRegistry
+
+	// StatementCacheSize, if positive, is the maximum number of prepared
+	// statements cached by the storage. Least recently used statements are
+	// closed to make room for new ones. The statements are cached without
+	// limit otherwise.
+	StatementCacheSize int
+}
+
+// sqliteTreeStorage is shared between the sqliteLog- and sqliteMapStorage
+// implementations, and contains functionality which is common to both.
+type sqliteTreeStorage struct {
+	db    *sql.DB
+	opts  TreeStorageOptions
+	stmts *sqlpool.StmtCache
+}
+
+// OpenDB opens the SQLite database in file, creating it and its tables if they
+// don't exist, for all SQLite-based storage implementations.
+//
+// Transactions take the database lock when they begin, so that concurrent
+// transactions wait for each other for up to busyTimeout rather than fail
+// when they first write.
+func OpenDB(file string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_txlock=immediate&_busy_timeout=%d&_journal_mode=WAL&_foreign_keys=on", file, busyTimeout/time.Millisecond)
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		glog.Warningf("Could not open SQLite database %q: %s", file, err)
+		return nil, err
+	}
+	if _, err := db.ExecContext(context.TODO(), schemaSQL); err != nil {
+		glog.Warningf("Failed to create tables in SQLite database %q: %s", file, err)
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func newTreeStorage(db *sql.DB, opts TreeStorageOptions) *sqliteTreeStorage {
+	return &sqliteTreeStorage{
+		db:    db,
+		opts:  opts,
+		stmts: sqlpool.NewStmtCache(db, opts.StatementCacheSize),
+	}
+}
+
+// expandPlaceholderSQL expands an sql statement by adding a specified number of '?'
+// placeholder slots. At most one placeholder will be expanded.
+func expandPlaceholderSQL(sql string, num int, first, rest string) string {
+	if num <= 0 {
+		panic(fmt.Errorf("trying to expand SQL placeholder with <= 0 parameters: %s", sql))
+	}
+
+	parameters := first + strings.Repeat(","+rest, num-1)
+
+	return strings.Replace(sql, placeholderSQL, parameters, 1)
+}
+
+// getStmt creates and caches sql.Stmt structs based on the passed in statement
+// and number of bound arguments.
+func (m *sqliteTreeStorage) getStmt(ctx context.Context, statement string, num int, first, rest string) (*sql.Stmt, error) {
+	s, err := m.stmts.Prepare(ctx, expandPlaceholderSQL(statement, num, first, rest))
+	if err != nil {
+		glog.Warningf("Failed to prepare statement %d: %s", num, err)
+		return nil, err
+	}
+	return s, nil
+}
+
+// tag returns query with the tags of ctx appended if QueryTags is set, using
+// treeID if ctx has no tree ID.
+func (m *sqliteTreeStorage) tag(ctx context.Context, treeID int64, query string) string {
+	if !m.opts.QueryTags {
+		return query
+	}
+	tags := querytag.FromContext(ctx)
+	if tags.TreeID == 0 {
+		tags.TreeID = treeID
+	}
+	return tags.Append(query)
+}
+
+func (m *sqliteTreeStorage) beginTreeTx(ctx context.Context, tree *trillian.Tree, hashSizeBytes int, subtreeCache *cache.SubtreeCache) (treeTX, error) {
+	t, err := m.db.BeginTx(ctx, nil /* opts */)
+	if err != nil {
+		glog.Warningf("Could not start tree TX: %s", err)
+		return treeTX{}, err
+	}
+	return m.newTreeTX(t, &sync.Mutex{}, tree, hashSizeBytes, subtreeCache), nil
+}
+
+// newTreeTX returns a treeTX for tree which runs its queries in t. All the
+// treeTXs sharing t must also share mu.
+func (m *sqliteTreeStorage) newTreeTX(t *sql.Tx, mu *sync.Mutex, tree *trillian.Tree, hashSizeBytes int, subtreeCache *cache.SubtreeCache) treeTX {
+	return treeTX{
+		tx:            t,
+		mu:            mu,
+		ts:            m,
+		treeID:        tree.TreeId,
+		treeType:      tree.TreeType,
+		hashSizeBytes: hashSizeBytes,
+		subtreeCache:  subtreeCache,
+		writeRevision: -1,
+	}
+}
+
+type treeTX struct {
+	// mu ensures that tx can only be used for one query/exec at a time.
+	mu            *sync.Mutex
+	closed        bool
+	tx            *sql.Tx
+	ts            *sqliteTreeStorage
+	treeID        int64
+	treeType      trillian.TreeType
+	hashSizeBytes int
+	subtreeCache  *cache.SubtreeCache
+	writeRevision int64
+}
+
+// tag returns query with the tags of ctx appended if QueryTags is set.
+func (t *treeTX) tag(ctx context.Context, query string) string {
+	return t.ts.tag(ctx, t.treeID, query)
+}
+
+// stmt returns a statement for the expanded SQL, for use in t.tx, which the
+// caller must close. The statements are cached unless QueryTags is set, as
+// the tags change the text of each query.
+func (t *treeTX) stmt(ctx context.Context, statement string, num int, first, rest string) (*sql.Stmt, error) {
+	if t.ts.opts.QueryTags {
+		return t.tx.PrepareContext(ctx, t.tag(ctx, expandPlaceholderSQL(statement, num, first, rest)))
+	}
+	tmpl, err := t.ts.getStmt(ctx, statement, num, first, rest)
+	if err != nil {
+		return nil, err
+	}
+	return t.tx.StmtContext(ctx, tmpl), nil
+}
+
+func (t *treeTX) getSubtree(ctx context.Context, treeRevision int64, nodeID tree.NodeID) (*storagepb.SubtreeProto, error) {
+	s, err := t.getSubtrees(ctx, treeRevision, []tree.NodeID{nodeID})
+	if err != nil {
+		return nil, err
+	}
+	switch len(s) {
+	case 0:
+		return nil, nil
+	case 1:
+		return s[0], nil
+	default:
+		return nil, fmt.Errorf("got %d subtrees, but expected 1", len(s))
+	}
+}
+
+func (t *treeTX) getSubtrees(ctx context.Context, treeRevision int64, nodeIDs []tree.NodeID) ([]*storagepb.SubtreeProto, error) {
+	glog.V(4).Infof("getSubtrees(")
+	if len(nodeIDs) == 0 {
+		return nil, nil
+	}
+
+	stx, err := t.stmt(ctx, selectSubtreeSQL, len(nodeIDs), "?", "?")
+	if err != nil {
+		return nil, err
+	}
+	defer stx.Close()
+
+	args := make([]interface{}, 0, len(nodeIDs)+3)
+
+	// populate args with nodeIDs
+	for _, nodeID := range nodeIDs {
+		nodeIDBytes, err := subtreeKey(nodeID)
+		if err != nil {
+			return nil, err
+		}
+		glog.V(4).Infof("  nodeID: %x", nodeIDBytes)
+		args = append(args, nodeIDBytes)
+	}
+
+	args = append(args, t.treeID)
+	args = append(args, treeRevision)
+	args = append(args, t.treeID)
+
+	rows, err := stx.QueryContext(ctx, args...)
+	if err != nil {
+		glog.Warningf("Failed to get merkle subtrees: %s", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Err() != nil {
+		// Nothing from the DB
+		glog.Warningf("Nothing from DB: %s", rows.Err())
+		return nil, rows.Err()
+	}
+
+	ret := make([]*storagepb.SubtreeProto, 0, len(nodeIDs))
+
+	for rows.Next() {
+		var subtreeIDBytes []byte
+		var subtreeRev int64
+		var nodesRaw []byte
+		if err := rows.Scan(&subtreeIDBytes, &subtreeRev, &nodesRaw); err != nil {
+			glog.Warningf("Failed to scan merkle subtree: %s", err)
+			return nil, err
+		}
+		var subtree storagepb.SubtreeProto
+		if _, err := subtreecodec.Unmarshal(nodesRaw, &subtree); err != nil {
+			glog.Warningf("Failed to unmarshal SubtreeProto: %s", err)
+			return nil, err
+		}
+		if subtree.Prefix == nil {
+			subtree.Prefix = []byte{}
+		}
+		ret = append(ret, &subtree)
+
+		if glog.V(4) {
+			glog.Infof("  subtree: NID: %x, prefix: %x, depth: %d",
+				subtreeIDBytes, subtree.Prefix, subtree.Depth)
+			for k, v := range subtree.Leaves {
+				b, err := base64.StdEncoding.DecodeString(k)
+				if err != nil {
+					glog.Errorf("base64.DecodeString(%v): %v", k, err)
+				}
+				glog.Infof("     %x: %x", b, v)
+			}
+		}
+	}
+
+	// The InternalNodes cache is possibly nil here, but the SubtreeCache (which called
+	// this method) will re-populate it.
+	return ret, nil
+}
+
+func (t *treeTX) storeSubtrees(ctx context.Context, subtrees []*storagepb.SubtreeProto) error {
+	if glog.V(4) {
+		glog.Infof("storeSubtrees(")
+		for _, s := range subtrees {
+			glog.Infof("  prefix: %x, depth: %d", s.Prefix, s.Depth)
+			for k, v := range s.Leaves {
+				b, err := base64.StdEncoding.DecodeString(k)
+				if err != nil {
+					glog.Errorf("base64.DecodeString(%v): %v", k, err)
+				}
+				glog.Infof("     %x: %x", b, v)
+			}
+		}
+	}
+	if len(subtrees) == 0 {
+		glog.Warning("attempted to store 0 subtrees...")
+		return nil
+	}
+
+	// TODO(al): probably need to be able to batch this in the case where we have
+	// a really large number of subtrees to store.
+	args := make([]interface{}, 0, len(subtrees))
+
+	for _, s := range subtrees {
+		s := s
+		if s.Prefix == nil {
+			panic(fmt.Errorf("nil prefix on %v", s))
+		}
+		subtreeBytes, err := subtreecodec.Marshal(s, t.ts.opts.SubtreeCodec)
+		if err != nil {
+			return err
+		}
+		args = append(args, t.treeID)
+		args = append(args, s.Prefix)
+		args = append(args, subtreeBytes)
+		args = append(args, t.writeRevision)
+	}
+
+	stx, err := t.stmt(ctx, insertSubtreeMultiSQL, len(subtrees), "VALUES(?, ?, ?, ?)", "(?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stx.Close()
+
+	r, err := stx.ExecContext(ctx, args...)
+	if err != nil {
+		glog.Warningf("Failed to set merkle subtrees: %s", err)
+		return err
+	}
+	_, _ = r.RowsAffected()
+	return nil
+}
+
+func checkResultOkAndRowCountIs(res sql.Result, err error, count int64) error {
+	// The Exec() might have just failed
+	if err != nil {
+		return err
+	}
+
+	// Otherwise we have to look at the result of the operation
+	rowsAffected, rowsError := res.RowsAffected()
+
+	if rowsError != nil {
+		return rowsError
+	}
+
+	if rowsAffected != count {
+		return fmt.Errorf("expected %d row(s) to be affected but saw: %d", count,
+			rowsAffected)
+	}
+
+	return nil
+}
+
+// marshalRootSignatures returns the value stored in the AdditionalSignatures
+// column of a root, which is nil if it has no additional signatures.
+func marshalRootSignatures(sigs [][]byte) ([]byte, error) {
+	if len(sigs) == 0 {
+		return nil, nil
+	}
+	b, err := proto.Marshal(&storagepb.RootSignatures{Signatures: sigs})
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal RootSignatures: %v", err)
+	}
+	return b, nil
+}
+
+// unmarshalRootSignatures parses the AdditionalSignatures column of a root.
+func unmarshalRootSignatures(b []byte) ([][]byte, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	var sigs storagepb.RootSignatures
+	if err := proto.Unmarshal(b, &sigs); err != nil {
+		return nil, fmt.Errorf("could not unmarshal RootSignatures: %v", err)
+	}
+	return sigs.Signatures, nil
+}
+
+// getSubtreesAtRev returns a GetSubtreesFunc which reads at the passed in rev.
+func (t *treeTX) getSubtreesAtRev(ctx context.Context, rev int64) cache.GetSubtreesFunc {
+	return func(ids []tree.NodeID) ([]*storagepb.SubtreeProto, error) {
+		return t.getSubtrees(ctx, rev, ids)
+	}
+}
+
+// GetMerkleNodes returns the requests nodes at (or below) the passed in treeRevision.
+func (t *treeTX) GetMerkleNodes(ctx context.Context, treeRevision int64, nodeIDs []tree.NodeID) ([]tree.Node, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.subtreeCache.GetNodes(nodeIDs, t.getSubtreesAtRev(ctx, treeRevision))
+}
+
+func (t *treeTX) SetMerkleNodes(ctx context.Context, nodes []tree.Node) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, n := range nodes {
+		err := t.subtreeCache.SetNodeHash(n.NodeID, n.Hash,
+			func(nID tree.NodeID) (*storagepb.SubtreeProto, error) {
+				return t.getSubtree(ctx, t.writeRevision, nID)
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *treeTX) Commit(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.flushSubtrees(ctx); err != nil {
+		return err
+	}
+	t.closed = true
+	t.subtreeCache.Release()
+	if err := t.tx.Commit(); err != nil {
+		glog.Warningf("TX commit error: %s, stack:\n%s", err, string(debug.Stack()))
+		return err
+	}
+	return nil
+}
+
+// flushSubtrees writes the subtrees modified by a read-write transaction. The
+// caller must hold t.mu.
+func (t *treeTX) flushSubtrees(ctx context.Context) error {
+	if t.writeRevision > -1 {
+		if err := t.subtreeCache.Flush(ctx, func(ctx context.Context, st []*storagepb.SubtreeProto) error {
+			return t.storeSubtrees(ctx, st)
+		}); err != nil {
+			glog.Warningf("TX commit flush error: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *treeTX) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.rollbackInternal()
+}
+
+func (t *treeTX) rollbackInternal() error {
+	t.closed = true
+	t.subtreeCache.Release()
+	if err := t.tx.Rollback(); err != nil {
+		glog.Warningf("TX rollback error: %s, stack:\n%s", err, string(debug.Stack()))
+		return err
+	}
+	return nil
+}
+
+func (t *treeTX) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.closed {
+		err := t.rollbackInternal()
+		if err != nil {
+			glog.Warningf("Rollback error on Close(): %v", err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (t *treeTX) IsOpen() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return !t.closed
+}
+
+// subtreeKey returns a non-nil []byte suitable for use as a primary key column
+// for the subtree rooted at the passed-in node ID. Returns an error if the ID
+// is not aligned to bytes.
+//
+// TODO(pavelkalinnikov): This function is duplicated in multiple storage
+// implementations. We should create a common "tree layout" type in the
+// top-level storage package and reuse it for ID/strata validation.
+func subtreeKey(id tree.NodeID) ([]byte, error) {
+	// TODO(pavelkalinnikov): Extend this check to verify strata boundaries.
+	if id.PrefixLenBits%8 != 0 {
+		return nil, fmt.Errorf("invalid subtree ID - not multiple of 8: %d", id.PrefixLenBits)
+	}
+	// The returned slice must not be nil, as it would correspond to NULL in SQL.
+	if bytes := id.Path; bytes != nil {
+		return bytes[:id.PrefixLenBits/8], nil
+	}
+	return []byte{}, nil
+}
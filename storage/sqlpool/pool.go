@@ -0,0 +1,125 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqlpool tunes and monitors the connection pools and prepared
+// statement caches of the SQL storage implementations.
+package sqlpool
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/google/trillian/monitoring"
+)
+
+const databaseLabel = "database"
+
+var (
+	once                sync.Once
+	maxOpenConnsGauge   monitoring.Gauge
+	openConnsGauge      monitoring.Gauge
+	inUseConnsGauge     monitoring.Gauge
+	idleConnsGauge      monitoring.Gauge
+	waitCounter         monitoring.Counter
+	waitDurationCounter monitoring.Counter
+	maxIdleClosed       monitoring.Counter
+	maxLifetimeClosed   monitoring.Counter
+)
+
+func createMetrics(mf monitoring.MetricFactory) {
+	maxOpenConnsGauge = mf.NewGauge("sql_pool_max_open_connections", "Maximum number of open connections to the database, 0 if unlimited", databaseLabel)
+	openConnsGauge = mf.NewGauge("sql_pool_open_connections", "Number of open connections to the database, in use or idle", databaseLabel)
+	inUseConnsGauge = mf.NewGauge("sql_pool_in_use_connections", "Number of connections to the database in use", databaseLabel)
+	idleConnsGauge = mf.NewGauge("sql_pool_idle_connections", "Number of idle connections to the database", databaseLabel)
+	waitCounter = mf.NewCounter("sql_pool_waits", "Number of times a connection to the database was waited for", databaseLabel)
+	waitDurationCounter = mf.NewCounter("sql_pool_wait_seconds", "Total time waited for connections to the database in seconds", databaseLabel)
+	maxIdleClosed = mf.NewCounter("sql_pool_max_idle_closed", "Number of connections to the database closed because of the maximum idle connections", databaseLabel)
+	maxLifetimeClosed = mf.NewCounter("sql_pool_max_lifetime_closed", "Number of connections to the database closed because of the maximum connection lifetime", databaseLabel)
+}
+
+// Options configures the connection pool of a database. The zero value
+// leaves the defaults of database/sql in place.
+type Options struct {
+	// MaxOpenConns, if positive, is the maximum number of open connections.
+	MaxOpenConns int
+
+	// MaxIdleConns, if positive, is the maximum number of idle connections
+	// kept in the pool. If negative, idle connections are closed.
+	MaxIdleConns int
+
+	// ConnMaxLifetime, if positive, is how long connections are reused for.
+	ConnMaxLifetime time.Duration
+}
+
+// Apply configures the connection pool of db.
+func (o Options) Apply(db *sql.DB) {
+	if o.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(o.MaxOpenConns)
+	}
+	if o.MaxIdleConns != 0 {
+		// SetMaxIdleConns keeps no idle connections if passed a negative value.
+		db.SetMaxIdleConns(o.MaxIdleConns)
+	}
+	if o.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(o.ConnMaxLifetime)
+	}
+}
+
+// ExportMetrics exports the utilization of the connection pool of db to mf
+// every interval, labelled with name, until the returned function is called.
+func ExportMetrics(mf monitoring.MetricFactory, name string, db *sql.DB, interval time.Duration) (stop func()) {
+	if mf == nil {
+		mf = monitoring.InertMetricFactory{}
+	}
+	once.Do(func() { createMetrics(mf) })
+
+	prev := record(name, sql.DBStats{}, db.Stats())
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				prev = record(name, prev, db.Stats())
+			}
+		}
+	}()
+	var stopOnce sync.Once
+	return func() {
+		stopOnce.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// record exports the statistics cur of the pool called name, and returns it.
+// The counters are advanced by the difference from the previous statistics.
+func record(name string, prev, cur sql.DBStats) sql.DBStats {
+	maxOpenConnsGauge.Set(float64(cur.MaxOpenConnections), name)
+	openConnsGauge.Set(float64(cur.OpenConnections), name)
+	inUseConnsGauge.Set(float64(cur.InUse), name)
+	idleConnsGauge.Set(float64(cur.Idle), name)
+	waitCounter.Add(float64(cur.WaitCount-prev.WaitCount), name)
+	waitDurationCounter.Add((cur.WaitDuration - prev.WaitDuration).Seconds(), name)
+	maxIdleClosed.Add(float64(cur.MaxIdleClosed-prev.MaxIdleClosed), name)
+	maxLifetimeClosed.Add(float64(cur.MaxLifetimeClosed-prev.MaxLifetimeClosed), name)
+	return cur
+}
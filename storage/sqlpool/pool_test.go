@@ -0,0 +1,97 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlpool
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/monitoring"
+)
+
+func TestOptionsApply(t *testing.T) {
+	for _, tc := range []struct {
+		desc        string
+		opts        Options
+		wantMaxOpen int
+	}{
+		{desc: "defaults", wantMaxOpen: 0},
+		{desc: "limited", opts: Options{MaxOpenConns: 3, MaxIdleConns: 1, ConnMaxLifetime: time.Minute}, wantMaxOpen: 3},
+		{desc: "no-idle", opts: Options{MaxOpenConns: 2, MaxIdleConns: -1}, wantMaxOpen: 2},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			db := openTestDB(t)
+			defer db.Close()
+			// Undo the limit set by openTestDB.
+			db.SetMaxOpenConns(0)
+
+			tc.opts.Apply(db)
+			if got, want := db.Stats().MaxOpenConnections, tc.wantMaxOpen; got != want {
+				t.Errorf("MaxOpenConnections: %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestExportMetrics(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Conn(): %v", err)
+	}
+	defer conn.Close()
+
+	// The metrics are exported once before ExportMetrics returns.
+	stop := ExportMetrics(monitoring.InertMetricFactory{}, "export", db, time.Hour)
+	stop()
+	for _, m := range []struct {
+		name  string
+		gauge monitoring.Gauge
+		want  float64
+	}{
+		{name: "sql_pool_max_open_connections", gauge: maxOpenConnsGauge, want: 1},
+		{name: "sql_pool_open_connections", gauge: openConnsGauge, want: 1},
+		{name: "sql_pool_in_use_connections", gauge: inUseConnsGauge, want: 1},
+		{name: "sql_pool_idle_connections", gauge: idleConnsGauge, want: 0},
+	} {
+		if got := m.gauge.Value("export"); got != m.want {
+			t.Errorf("%s: %v, want %v", m.name, got, m.want)
+		}
+	}
+	// Stopping again is a no-op.
+	stop()
+}
+
+func TestRecord(t *testing.T) {
+	once.Do(func() { createMetrics(monitoring.InertMetricFactory{}) })
+	before := waitCounter.Value("record")
+	beforeSecs := waitDurationCounter.Value("record")
+
+	prev := record("record", sql.DBStats{}, sql.DBStats{WaitCount: 2, WaitDuration: time.Second})
+	record("record", prev, sql.DBStats{WaitCount: 5, WaitDuration: 4 * time.Second, InUse: 3})
+
+	if got, want := waitCounter.Value("record")-before, 5.0; got != want {
+		t.Errorf("sql_pool_waits increased by %v, want %v", got, want)
+	}
+	if got, want := waitDurationCounter.Value("record")-beforeSecs, 4.0; got != want {
+		t.Errorf("sql_pool_wait_seconds increased by %v, want %v", got, want)
+	}
+	if got, want := inUseConnsGauge.Value("record"), 3.0; got != want {
+		t.Errorf("sql_pool_in_use_connections: %v, want %v", got, want)
+	}
+}
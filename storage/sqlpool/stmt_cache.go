@@ -0,0 +1,86 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlpool
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// StmtCache caches the prepared statements of a database by their text.
+// The statements are prepared on the database, so transactions should use
+// them through sql.Tx.StmtContext.
+type StmtCache struct {
+	db   *sql.DB
+	size int
+
+	// mu must be held while the statements are looked up and prepared, which
+	// is a short time compared to their use.
+	mu    sync.Mutex
+	lru   *list.List // Of *cachedStmt, most recently used first.
+	stmts map[string]*list.Element
+}
+
+type cachedStmt struct {
+	query string
+	stmt  *sql.Stmt
+}
+
+// NewStmtCache returns a cache of the statements of db. If size is positive,
+// the cache holds at most size statements, and closes the least recently
+// used statement to make room for a new one. Otherwise it is unbounded.
+func NewStmtCache(db *sql.DB, size int) *StmtCache {
+	return &StmtCache{
+		db:    db,
+		size:  size,
+		lru:   list.New(),
+		stmts: make(map[string]*list.Element),
+	}
+}
+
+// Prepare returns the cached statement for query, preparing it if needed.
+// The statement must not be closed by the caller.
+func (c *StmtCache) Prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.stmts[query]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*cachedStmt).stmt, nil
+	}
+
+	s, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if c.size > 0 && c.lru.Len() >= c.size {
+		// The statements of transactions derived from the evicted statement
+		// keep it open until they are closed.
+		oldest := c.lru.Remove(c.lru.Back()).(*cachedStmt)
+		delete(c.stmts, oldest.query)
+		oldest.stmt.Close()
+	}
+	c.stmts[query] = c.lru.PushFront(&cachedStmt{query: query, stmt: s})
+	return s, nil
+}
+
+// Len returns the number of cached statements.
+func (c *StmtCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlpool
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	// Load SQLite driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open(): %v", err)
+	}
+	// Each connection to :memory: has its own database.
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func TestStmtCache(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		desc    string
+		size    int
+		queries []string
+		// wantPrepared says whether each query is newly prepared.
+		wantPrepared []bool
+		wantLen      int
+	}{
+		{
+			desc:         "unbounded",
+			queries:      []string{"SELECT 1", "SELECT 2", "SELECT 3", "SELECT 1"},
+			wantPrepared: []bool{true, true, true, false},
+			wantLen:      3,
+		},
+		{
+			desc:         "hits",
+			size:         2,
+			queries:      []string{"SELECT 1", "SELECT 2", "SELECT 1", "SELECT 2"},
+			wantPrepared: []bool{true, true, false, false},
+			wantLen:      2,
+		},
+		{
+			desc:         "evicts-least-recently-used",
+			size:         2,
+			queries:      []string{"SELECT 1", "SELECT 2", "SELECT 1", "SELECT 3", "SELECT 1", "SELECT 2"},
+			wantPrepared: []bool{true, true, false, true, false, true},
+			wantLen:      2,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			db := openTestDB(t)
+			defer db.Close()
+			c := NewStmtCache(db, tc.size)
+
+			seen := make(map[*sql.Stmt]bool)
+			for i, q := range tc.queries {
+				s, err := c.Prepare(ctx, q)
+				if err != nil {
+					t.Fatalf("Prepare(%q): %v", q, err)
+				}
+				if got, want := !seen[s], tc.wantPrepared[i]; got != want {
+					t.Errorf("Prepare(%q) #%d prepared: %v, want %v", q, i, got, want)
+				}
+				seen[s] = true
+				tx, err := db.BeginTx(ctx, nil)
+				if err != nil {
+					t.Fatalf("BeginTx(): %v", err)
+				}
+				var n int
+				if err := tx.StmtContext(ctx, s).QueryRowContext(ctx).Scan(&n); err != nil {
+					t.Errorf("%q: %v", q, err)
+				}
+				if err := tx.Commit(); err != nil {
+					t.Fatalf("Commit(): %v", err)
+				}
+			}
+			if got, want := c.Len(), tc.wantLen; got != want {
+				t.Errorf("Len(): %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestStmtCacheError(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	c := NewStmtCache(db, 1)
+
+	if _, err := c.Prepare(context.Background(), "NOT SQL"); err == nil {
+		t.Error("Prepare(NOT SQL): nil, want err")
+	}
+	if got := c.Len(); got != 0 {
+		t.Errorf("Len(): %d, want 0", got)
+	}
+}
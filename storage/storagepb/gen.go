@@ -14,4 +14,4 @@
 
 package storagepb
 
-//go:generate protoc -I=. -I=$GOPATH/src/ --go_out=plugins=grpc:. storage.proto
+//go:generate protoc -I=../.. --go_out=paths=source_relative:../.. storage/storagepb/storage.proto
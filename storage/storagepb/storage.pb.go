@@ -1,11 +1,12 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
-// source: storage.proto
+// source: storage/storagepb/storage.proto
 
 package storagepb
 
 import (
 	fmt "fmt"
 	proto "github.com/golang/protobuf/proto"
+	any "github.com/golang/protobuf/ptypes/any"
 	math "math"
 )
 
@@ -20,6 +21,37 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+// LeafCompression is the algorithm which compresses the values of the leaves
+// of a map at rest.
+type LeafCompression int32
+
+const (
+	// Leaf values are stored as written.
+	LeafCompression_NO_COMPRESSION LeafCompression = 0
+	LeafCompression_SNAPPY         LeafCompression = 1
+	LeafCompression_ZSTD           LeafCompression = 2
+)
+
+var LeafCompression_name = map[int32]string{
+	0: "NO_COMPRESSION",
+	1: "SNAPPY",
+	2: "ZSTD",
+}
+
+var LeafCompression_value = map[string]int32{
+	"NO_COMPRESSION": 0,
+	"SNAPPY":         1,
+	"ZSTD":           2,
+}
+
+func (x LeafCompression) String() string {
+	return proto.EnumName(LeafCompression_name, int32(x))
+}
+
+func (LeafCompression) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_22a67192205f4493, []int{0}
+}
+
 // NodeIDProto is the serialized form of NodeID. It's used only for persistence
 // in storage. As this is long-term we prefer not to use a Go specific format.
 type NodeIDProto struct {
@@ -34,7 +66,7 @@ func (m *NodeIDProto) Reset()         { *m = NodeIDProto{} }
 func (m *NodeIDProto) String() string { return proto.CompactTextString(m) }
 func (*NodeIDProto) ProtoMessage()    {}
 func (*NodeIDProto) Descriptor() ([]byte, []int) {
-	return fileDescriptor_0d2c4ccf1453ffdb, []int{0}
+	return fileDescriptor_22a67192205f4493, []int{0}
 }
 
 func (m *NodeIDProto) XXX_Unmarshal(b []byte) error {
@@ -95,7 +127,7 @@ func (m *SubtreeProto) Reset()         { *m = SubtreeProto{} }
 func (m *SubtreeProto) String() string { return proto.CompactTextString(m) }
 func (*SubtreeProto) ProtoMessage()    {}
 func (*SubtreeProto) Descriptor() ([]byte, []int) {
-	return fileDescriptor_0d2c4ccf1453ffdb, []int{1}
+	return fileDescriptor_22a67192205f4493, []int{1}
 }
 
 func (m *SubtreeProto) XXX_Unmarshal(b []byte) error {
@@ -158,35 +190,243 @@ func (m *SubtreeProto) GetInternalNodeCount() uint32 {
 	return 0
 }
 
+// MapStorageSettings holds the storage_settings of maps, for storage
+// implementations which have no settings of their own.
+type MapStorageSettings struct {
+	// Compression of the leaf values. Readonly after tree creation.
+	LeafCompression LeafCompression `protobuf:"varint,1,opt,name=leaf_compression,json=leafCompression,proto3,enum=storagepb.LeafCompression" json:"leaf_compression,omitempty"`
+	// If set, storage maintains a lookup from leaf hashes to the indexes of the
+	// leaves, which GetLeafByHash needs. Readonly after tree creation.
+	LeafHashIndex        bool     `protobuf:"varint,2,opt,name=leaf_hash_index,json=leafHashIndex,proto3" json:"leaf_hash_index,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MapStorageSettings) Reset()         { *m = MapStorageSettings{} }
+func (m *MapStorageSettings) String() string { return proto.CompactTextString(m) }
+func (*MapStorageSettings) ProtoMessage()    {}
+func (*MapStorageSettings) Descriptor() ([]byte, []int) {
+	return fileDescriptor_22a67192205f4493, []int{2}
+}
+
+func (m *MapStorageSettings) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MapStorageSettings.Unmarshal(m, b)
+}
+func (m *MapStorageSettings) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MapStorageSettings.Marshal(b, m, deterministic)
+}
+func (m *MapStorageSettings) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MapStorageSettings.Merge(m, src)
+}
+func (m *MapStorageSettings) XXX_Size() int {
+	return xxx_messageInfo_MapStorageSettings.Size(m)
+}
+func (m *MapStorageSettings) XXX_DiscardUnknown() {
+	xxx_messageInfo_MapStorageSettings.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MapStorageSettings proto.InternalMessageInfo
+
+func (m *MapStorageSettings) GetLeafCompression() LeafCompression {
+	if m != nil {
+		return m.LeafCompression
+	}
+	return LeafCompression_NO_COMPRESSION
+}
+
+func (m *MapStorageSettings) GetLeafHashIndex() bool {
+	if m != nil {
+		return m.LeafHashIndex
+	}
+	return false
+}
+
+// AdditionalKeys holds the additional_private_keys and additional_public_keys
+// of a tree, for storage implementations which keep them in a single column.
+type AdditionalKeys struct {
+	PrivateKeys []*any.Any `protobuf:"bytes,1,rep,name=private_keys,json=privateKeys,proto3" json:"private_keys,omitempty"`
+	// DER-encoded PKIX forms of the public keys, in the same order.
+	PublicKeyDers        [][]byte `protobuf:"bytes,2,rep,name=public_key_ders,json=publicKeyDers,proto3" json:"public_key_ders,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AdditionalKeys) Reset()         { *m = AdditionalKeys{} }
+func (m *AdditionalKeys) String() string { return proto.CompactTextString(m) }
+func (*AdditionalKeys) ProtoMessage()    {}
+func (*AdditionalKeys) Descriptor() ([]byte, []int) {
+	return fileDescriptor_22a67192205f4493, []int{3}
+}
+
+func (m *AdditionalKeys) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AdditionalKeys.Unmarshal(m, b)
+}
+func (m *AdditionalKeys) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AdditionalKeys.Marshal(b, m, deterministic)
+}
+func (m *AdditionalKeys) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AdditionalKeys.Merge(m, src)
+}
+func (m *AdditionalKeys) XXX_Size() int {
+	return xxx_messageInfo_AdditionalKeys.Size(m)
+}
+func (m *AdditionalKeys) XXX_DiscardUnknown() {
+	xxx_messageInfo_AdditionalKeys.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AdditionalKeys proto.InternalMessageInfo
+
+func (m *AdditionalKeys) GetPrivateKeys() []*any.Any {
+	if m != nil {
+		return m.PrivateKeys
+	}
+	return nil
+}
+
+func (m *AdditionalKeys) GetPublicKeyDers() [][]byte {
+	if m != nil {
+		return m.PublicKeyDers
+	}
+	return nil
+}
+
+// RootSignatures holds the additional_signatures of a log or map root, for
+// storage implementations which keep them in a single column.
+type RootSignatures struct {
+	Signatures           [][]byte `protobuf:"bytes,1,rep,name=signatures,proto3" json:"signatures,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RootSignatures) Reset()         { *m = RootSignatures{} }
+func (m *RootSignatures) String() string { return proto.CompactTextString(m) }
+func (*RootSignatures) ProtoMessage()    {}
+func (*RootSignatures) Descriptor() ([]byte, []int) {
+	return fileDescriptor_22a67192205f4493, []int{4}
+}
+
+func (m *RootSignatures) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RootSignatures.Unmarshal(m, b)
+}
+func (m *RootSignatures) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RootSignatures.Marshal(b, m, deterministic)
+}
+func (m *RootSignatures) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RootSignatures.Merge(m, src)
+}
+func (m *RootSignatures) XXX_Size() int {
+	return xxx_messageInfo_RootSignatures.Size(m)
+}
+func (m *RootSignatures) XXX_DiscardUnknown() {
+	xxx_messageInfo_RootSignatures.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RootSignatures proto.InternalMessageInfo
+
+func (m *RootSignatures) GetSignatures() [][]byte {
+	if m != nil {
+		return m.Signatures
+	}
+	return nil
+}
+
+// TreeLabels holds the labels of a tree, for storage implementations which
+// keep them in a single column.
+type TreeLabels struct {
+	Labels               map[string]string `protobuf:"bytes,1,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *TreeLabels) Reset()         { *m = TreeLabels{} }
+func (m *TreeLabels) String() string { return proto.CompactTextString(m) }
+func (*TreeLabels) ProtoMessage()    {}
+func (*TreeLabels) Descriptor() ([]byte, []int) {
+	return fileDescriptor_22a67192205f4493, []int{5}
+}
+
+func (m *TreeLabels) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TreeLabels.Unmarshal(m, b)
+}
+func (m *TreeLabels) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TreeLabels.Marshal(b, m, deterministic)
+}
+func (m *TreeLabels) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TreeLabels.Merge(m, src)
+}
+func (m *TreeLabels) XXX_Size() int {
+	return xxx_messageInfo_TreeLabels.Size(m)
+}
+func (m *TreeLabels) XXX_DiscardUnknown() {
+	xxx_messageInfo_TreeLabels.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TreeLabels proto.InternalMessageInfo
+
+func (m *TreeLabels) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
 func init() {
+	proto.RegisterEnum("storagepb.LeafCompression", LeafCompression_name, LeafCompression_value)
 	proto.RegisterType((*NodeIDProto)(nil), "storagepb.NodeIDProto")
 	proto.RegisterType((*SubtreeProto)(nil), "storagepb.SubtreeProto")
 	proto.RegisterMapType((map[string][]byte)(nil), "storagepb.SubtreeProto.InternalNodesEntry")
 	proto.RegisterMapType((map[string][]byte)(nil), "storagepb.SubtreeProto.LeavesEntry")
+	proto.RegisterType((*MapStorageSettings)(nil), "storagepb.MapStorageSettings")
+	proto.RegisterType((*AdditionalKeys)(nil), "storagepb.AdditionalKeys")
+	proto.RegisterType((*RootSignatures)(nil), "storagepb.RootSignatures")
+	proto.RegisterType((*TreeLabels)(nil), "storagepb.TreeLabels")
+	proto.RegisterMapType((map[string]string)(nil), "storagepb.TreeLabels.LabelsEntry")
 }
 
-func init() { proto.RegisterFile("storage.proto", fileDescriptor_0d2c4ccf1453ffdb) }
-
-var fileDescriptor_0d2c4ccf1453ffdb = []byte{
-	// 308 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x91, 0x4d, 0x4b, 0xc3, 0x40,
-	0x10, 0x86, 0x49, 0xd3, 0x06, 0x3b, 0x6d, 0xfc, 0x58, 0x45, 0x96, 0x7a, 0x09, 0x15, 0x24, 0x78,
-	0xc8, 0x41, 0x2f, 0x7e, 0x5c, 0xc4, 0x0f, 0xb0, 0x50, 0x44, 0xd7, 0x1f, 0x10, 0x36, 0x66, 0x34,
-	0xc1, 0xb0, 0x1b, 0x76, 0x37, 0xc5, 0xfe, 0x11, 0x7f, 0xaf, 0x64, 0xb3, 0x48, 0x44, 0x3c, 0x78,
-	0x9b, 0x77, 0xe6, 0x9d, 0x27, 0x93, 0x77, 0x21, 0xd4, 0x46, 0x2a, 0xfe, 0x86, 0x49, 0xad, 0xa4,
-	0x91, 0x64, 0xec, 0x64, 0x9d, 0xcd, 0x17, 0x30, 0x79, 0x90, 0x39, 0x2e, 0x6e, 0x1f, 0xed, 0x84,
-	0xc0, 0xb0, 0xe6, 0xa6, 0xa0, 0x5e, 0xe4, 0xc5, 0x53, 0x66, 0x6b, 0x72, 0x04, 0x5b, 0xb5, 0xc2,
-	0xd7, 0xf2, 0x23, 0xad, 0x50, 0xa4, 0x59, 0x69, 0x34, 0x1d, 0x44, 0x5e, 0x3c, 0x62, 0x61, 0xd7,
-	0x5e, 0xa2, 0xb8, 0x2e, 0x8d, 0x9e, 0x7f, 0xfa, 0x30, 0x7d, 0x6e, 0x32, 0xa3, 0x10, 0x3b, 0xd8,
-	0x3e, 0x04, 0x9d, 0xc3, 0xe1, 0x9c, 0x22, 0x7b, 0x30, 0xca, 0xb1, 0x36, 0x85, 0xc3, 0x74, 0x82,
-	0x1c, 0xc0, 0x58, 0x49, 0x69, 0xd2, 0x82, 0xeb, 0x82, 0xfa, 0x76, 0x61, 0xa3, 0x6d, 0xdc, 0x73,
-	0x5d, 0x90, 0x4b, 0x08, 0x2a, 0xe4, 0x2b, 0xd4, 0x74, 0x18, 0xf9, 0xf1, 0xe4, 0xe4, 0x30, 0xf9,
-	0xfe, 0x85, 0xa4, 0xff, 0xcd, 0x64, 0x69, 0x5d, 0x77, 0xc2, 0xa8, 0x35, 0x73, 0x2b, 0xe4, 0x09,
-	0x36, 0x4b, 0x61, 0x50, 0x09, 0x5e, 0xa5, 0x42, 0xe6, 0xa8, 0xe9, 0xc8, 0x42, 0x8e, 0xff, 0x82,
-	0x2c, 0x9c, 0xbb, 0x4d, 0xc6, 0xb1, 0xc2, 0xb2, 0xdf, 0x23, 0x09, 0xec, 0xfe, 0x40, 0xa6, 0x2f,
-	0xb2, 0x11, 0x86, 0x06, 0x91, 0x17, 0x87, 0x6c, 0xa7, 0xef, 0xbd, 0x69, 0x07, 0xb3, 0x73, 0x98,
-	0xf4, 0x2e, 0x23, 0xdb, 0xe0, 0xbf, 0xe3, 0xda, 0xc6, 0x32, 0x66, 0x6d, 0xd9, 0x66, 0xb2, 0xe2,
-	0x55, 0x83, 0x36, 0x93, 0x29, 0xeb, 0xc4, 0xc5, 0xe0, 0xcc, 0x9b, 0x5d, 0x01, 0xf9, 0x7d, 0xcf,
-	0x7f, 0x08, 0x59, 0x60, 0x5f, 0xfd, 0xf4, 0x2b, 0x00, 0x00, 0xff, 0xff, 0xf4, 0xae, 0x38, 0x20,
-	0x06, 0x02, 0x00, 0x00,
+func init() { proto.RegisterFile("storage/storagepb/storage.proto", fileDescriptor_22a67192205f4493) }
+
+var fileDescriptor_22a67192205f4493 = []byte{
+	// 601 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0x4d, 0x6f, 0xd3, 0x40,
+	0x10, 0xc5, 0x4d, 0x1b, 0x35, 0x93, 0x8f, 0x86, 0xa5, 0x42, 0x21, 0x48, 0x10, 0x82, 0x84, 0xa2,
+	0x0a, 0x39, 0xa8, 0x1c, 0xa0, 0xf4, 0x42, 0xbf, 0x24, 0xa2, 0xa6, 0x69, 0xb0, 0x7b, 0xa1, 0x17,
+	0x6b, 0x1d, 0x4f, 0x92, 0x55, 0xdd, 0x5d, 0xb3, 0xbb, 0xae, 0xea, 0x2b, 0xfc, 0x07, 0x7e, 0x2f,
+	0xda, 0xb5, 0xdb, 0xba, 0x54, 0x08, 0x71, 0xf2, 0xcc, 0xdb, 0x37, 0x6f, 0x67, 0xdf, 0x4c, 0x02,
+	0x2f, 0x95, 0x16, 0x92, 0x2e, 0x70, 0x58, 0x7c, 0x93, 0xf0, 0x26, 0x72, 0x13, 0x29, 0xb4, 0x20,
+	0xb5, 0xdb, 0x83, 0xee, 0xb3, 0x85, 0x10, 0x8b, 0x18, 0x87, 0xf6, 0x20, 0x4c, 0xe7, 0x43, 0xca,
+	0xb3, 0x9c, 0xd5, 0x1f, 0x41, 0x7d, 0x22, 0x22, 0x1c, 0x1d, 0x4e, 0x6d, 0x11, 0x81, 0xd5, 0x84,
+	0xea, 0x65, 0xc7, 0xe9, 0x39, 0x83, 0x86, 0x67, 0x63, 0xf2, 0x06, 0x36, 0x12, 0x89, 0x73, 0x76,
+	0x1d, 0xc4, 0xc8, 0x83, 0x90, 0x69, 0xd5, 0x59, 0xe9, 0x39, 0x83, 0x35, 0xaf, 0x99, 0xc3, 0x63,
+	0xe4, 0xfb, 0x4c, 0xab, 0xfe, 0xaf, 0x0a, 0x34, 0xfc, 0x34, 0xd4, 0x12, 0x31, 0x17, 0x7b, 0x0a,
+	0xd5, 0x9c, 0x51, 0xc8, 0x15, 0x19, 0xd9, 0x84, 0xb5, 0x08, 0x13, 0xbd, 0x2c, 0x64, 0xf2, 0x84,
+	0x3c, 0x87, 0x9a, 0x14, 0x42, 0x07, 0x4b, 0xaa, 0x96, 0x9d, 0x8a, 0x2d, 0x58, 0x37, 0xc0, 0x17,
+	0xaa, 0x96, 0x64, 0x17, 0xaa, 0x31, 0xd2, 0x2b, 0x54, 0x9d, 0xd5, 0x5e, 0x65, 0x50, 0xdf, 0x7e,
+	0xed, 0xde, 0xbe, 0xce, 0x2d, 0xdf, 0xe9, 0x8e, 0x2d, 0xeb, 0x88, 0x6b, 0x99, 0x79, 0x45, 0x09,
+	0xf9, 0x0a, 0x2d, 0xc6, 0x35, 0x4a, 0x4e, 0xe3, 0x80, 0x8b, 0x08, 0x55, 0x67, 0xcd, 0x8a, 0x6c,
+	0xfd, 0x4d, 0x64, 0x54, 0xb0, 0x8d, 0x33, 0x85, 0x56, 0x93, 0x95, 0x31, 0xe2, 0xc2, 0x93, 0x7b,
+	0x92, 0xc1, 0x4c, 0xa4, 0x5c, 0x77, 0xaa, 0x3d, 0x67, 0xd0, 0xf4, 0x1e, 0x97, 0xb9, 0x07, 0xe6,
+	0xa0, 0xbb, 0x03, 0xf5, 0x52, 0x67, 0xa4, 0x0d, 0x95, 0x0b, 0xcc, 0xac, 0x2d, 0x35, 0xcf, 0x84,
+	0xc6, 0x93, 0x2b, 0x1a, 0xa7, 0x68, 0x3d, 0x69, 0x78, 0x79, 0xf2, 0x69, 0xe5, 0xa3, 0xd3, 0xfd,
+	0x0c, 0xe4, 0x61, 0x3f, 0xff, 0xa3, 0xd0, 0xff, 0xe9, 0x00, 0x39, 0xa1, 0x89, 0x9f, 0x3f, 0xd6,
+	0x47, 0xad, 0x19, 0x5f, 0x28, 0x72, 0x04, 0xed, 0x18, 0xe9, 0x3c, 0x98, 0x89, 0xcb, 0x44, 0xa2,
+	0x52, 0x4c, 0x70, 0xab, 0xd7, 0xda, 0xee, 0x96, 0x8c, 0x19, 0x23, 0x9d, 0x1f, 0xdc, 0x31, 0xbc,
+	0x8d, 0xf8, 0x3e, 0x60, 0xd6, 0xc3, 0xca, 0x98, 0xb9, 0x05, 0x8c, 0x47, 0x78, 0x6d, 0x3b, 0x58,
+	0xf7, 0x9a, 0x06, 0x36, 0xd3, 0x1b, 0x19, 0xb0, 0xff, 0x1d, 0x5a, 0x7b, 0x51, 0xc4, 0x34, 0x13,
+	0x9c, 0xc6, 0xc7, 0x98, 0x29, 0xf2, 0x01, 0x1a, 0x89, 0x64, 0x57, 0x54, 0x63, 0x70, 0x81, 0x99,
+	0xea, 0x38, 0x76, 0x2a, 0x9b, 0x6e, 0xbe, 0xad, 0xee, 0xcd, 0xb6, 0xba, 0x7b, 0x3c, 0xf3, 0xea,
+	0x05, 0xd3, 0x16, 0x9a, 0x8d, 0x4c, 0xc3, 0x98, 0xcd, 0x4c, 0x5d, 0x10, 0xa1, 0x34, 0x1b, 0x59,
+	0x19, 0x34, 0xbc, 0x66, 0x0e, 0x1f, 0x63, 0x76, 0x88, 0x52, 0xf5, 0xdf, 0x41, 0xcb, 0x13, 0x42,
+	0xfb, 0x6c, 0xc1, 0xa9, 0x4e, 0x25, 0x2a, 0xf2, 0x02, 0x40, 0xdd, 0x66, 0xf6, 0xc2, 0x86, 0x57,
+	0x42, 0xfa, 0x3f, 0x1c, 0x80, 0x33, 0x89, 0x38, 0xa6, 0x21, 0xc6, 0x8a, 0xec, 0x40, 0x35, 0xb6,
+	0x51, 0xd1, 0xdb, 0xab, 0x92, 0x31, 0x77, 0x34, 0x37, 0xff, 0xdc, 0x2c, 0x9d, 0x4d, 0xec, 0xc4,
+	0xef, 0xe0, 0x7f, 0xcd, 0xab, 0x56, 0x9a, 0xd7, 0xd6, 0x2e, 0x6c, 0xfc, 0xe1, 0x3a, 0x21, 0xd0,
+	0x9a, 0x9c, 0x06, 0x07, 0xa7, 0x27, 0x53, 0xef, 0xc8, 0xf7, 0x47, 0xa7, 0x93, 0xf6, 0x23, 0x02,
+	0x50, 0xf5, 0x27, 0x7b, 0xd3, 0xe9, 0xb7, 0xb6, 0x43, 0xd6, 0x61, 0xf5, 0xdc, 0x3f, 0x3b, 0x6c,
+	0xaf, 0xec, 0xbb, 0xe7, 0x6f, 0x17, 0x4c, 0x2f, 0xd3, 0xd0, 0x9d, 0x89, 0xcb, 0x61, 0xf1, 0xc3,
+	0xd7, 0x92, 0xc5, 0x31, 0xa3, 0x7c, 0xf8, 0xe0, 0x4f, 0x23, 0xac, 0x5a, 0x9b, 0xdf, 0xff, 0x1e,
+	0x00, 0x1c, 0x26, 0xa2, 0xd9, 0x50, 0x04, 0x00, 0x00,
 }
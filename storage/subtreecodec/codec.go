@@ -0,0 +1,335 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package subtreecodec serializes the subtrees stored by the storage backends.
+//
+// Each serialized subtree records the codec it was written with, so backends
+// can read subtrees written with any codec, and switching codecs takes effect
+// as subtrees are rewritten. Subtrees written with the Proto codec are plain
+// SubtreeProto messages, which is also how subtrees were stored before codecs
+// were introduced. Those written with other codecs start with a zero byte,
+// which never starts an encoded SubtreeProto, followed by the codec.
+//
+// The Zstd codec uses a pure Go Zstandard implementation by default, which
+// RegisterCompressor can replace.
+package subtreecodec
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian/storage/storagepb"
+	"github.com/google/trillian/storage/zstd"
+)
+
+// Codec identifies an encoding of subtrees. Its value is stored with each
+// subtree, so existing values must not change.
+type Codec byte
+
+const (
+	// Proto encodes subtrees as plain SubtreeProto messages.
+	Proto Codec = 0
+	// Packed stores the nodes of each subtree ordered by suffix, with each
+	// suffix delta-encoded against the previous one.
+	Packed Codec = 1
+	// Deflate compresses the Packed encoding with DEFLATE.
+	Deflate Codec = 2
+	// Zstd compresses the Packed encoding with Zstandard, using the
+	// Compressor registered for it.
+	Zstd Codec = 3
+)
+
+// header is the first byte of subtrees not encoded with the Proto codec.
+const header = 0
+
+var names = map[Codec]string{Proto: "proto", Packed: "packed", Deflate: "deflate", Zstd: "zstd"}
+
+// Compressor compresses the Packed encoding of subtrees for a codec.
+type Compressor interface {
+	// Compress appends the compressed src to dst.
+	Compress(dst, src []byte) ([]byte, error)
+	// Decompress returns the decompressed src.
+	Decompress(src []byte) ([]byte, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	// compressors holds the registered compressors, by codec.
+	compressors = map[Codec]Compressor{Zstd: zstdCompressor{}}
+)
+
+// RegisterCompressor replaces the compressor of codec c, which must be one of
+// the codecs, like Zstd, which take a compressor, e.g. with one using a faster
+// Zstandard implementation. It is typically called from the init function of
+// the package providing comp, which must produce data the default compressor
+// can read, and vice versa.
+func RegisterCompressor(c Codec, comp Compressor) error {
+	if c != Zstd {
+		return fmt.Errorf("subtree codec %v doesn't take a compressor", c)
+	}
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c] = comp
+	return nil
+}
+
+// zstdCompressor is the default compressor of the Zstd codec.
+type zstdCompressor struct{}
+
+func (zstdCompressor) Compress(dst, src []byte) ([]byte, error) { return zstd.Compress(dst, src) }
+func (zstdCompressor) Decompress(src []byte) ([]byte, error)    { return zstd.Decompress(src) }
+
+// compressor returns the compressor registered for c.
+func compressor(c Codec) (Compressor, error) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	comp, ok := compressors[c]
+	if !ok {
+		return nil, fmt.Errorf("subtree codec %v has no registered compressor", c)
+	}
+	return comp, nil
+}
+
+// String returns the name of c, as accepted by Parse.
+func (c Codec) String() string {
+	if name, ok := names[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Codec(%d)", byte(c))
+}
+
+// Parse returns the codec with the given name.
+func Parse(name string) (Codec, error) {
+	for c, n := range names {
+		if n == name {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown subtree codec %q, want one of proto, packed, deflate or zstd", name)
+}
+
+// Check returns an error if c is not one of the supported codecs, which are
+// the ones a storage backend is able to read and write, or has no registered
+// compressor.
+func Check(c Codec, supported []Codec) error {
+	for _, s := range supported {
+		if s == c {
+			if c == Zstd {
+				_, err := compressor(c)
+				return err
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("subtree codec %v is not supported by this storage system, want one of %v", c, supported)
+}
+
+// Marshal serializes st with codec c.
+func Marshal(st *storagepb.SubtreeProto, c Codec) ([]byte, error) {
+	switch c {
+	case Proto:
+		return proto.Marshal(st)
+	case Packed:
+		return pack([]byte{header, byte(Packed)}, st), nil
+	case Deflate:
+		return deflate(pack(nil, st))
+	case Zstd:
+		comp, err := compressor(c)
+		if err != nil {
+			return nil, err
+		}
+		return comp.Compress([]byte{header, byte(c)}, pack(nil, st))
+	default:
+		return nil, fmt.Errorf("unknown subtree codec %v", c)
+	}
+}
+
+// Unmarshal parses a subtree serialized by Marshal with any codec into st,
+// and returns the codec.
+func Unmarshal(data []byte, st *storagepb.SubtreeProto) (Codec, error) {
+	if len(data) == 0 || data[0] != header {
+		return Proto, proto.Unmarshal(data, st)
+	}
+	if len(data) < 2 {
+		return 0, errors.New("subtreecodec: truncated header")
+	}
+	c := Codec(data[1])
+	switch c {
+	case Packed:
+		return c, unpack(data[2:], st)
+	case Deflate:
+		packed, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(data[2:])))
+		if err != nil {
+			return 0, fmt.Errorf("subtreecodec: %v", err)
+		}
+		return c, unpack(packed, st)
+	case Zstd:
+		comp, err := compressor(c)
+		if err != nil {
+			return 0, fmt.Errorf("subtreecodec: %v", err)
+		}
+		packed, err := comp.Decompress(data[2:])
+		if err != nil {
+			return 0, fmt.Errorf("subtreecodec: %v", err)
+		}
+		return c, unpack(packed, st)
+	default:
+		return 0, fmt.Errorf("subtreecodec: unknown codec %v", c)
+	}
+}
+
+// writers holds reusable DEFLATE writers, which are expensive to create.
+var writers = sync.Pool{New: func() interface{} {
+	w, err := flate.NewWriter(nil, flate.DefaultCompression)
+	if err != nil {
+		panic(err) // Only happens for invalid levels.
+	}
+	return w
+}}
+
+func deflate(packed []byte) ([]byte, error) {
+	buf := bytes.NewBuffer([]byte{header, byte(Deflate)})
+	w := writers.Get().(*flate.Writer)
+	defer writers.Put(w)
+	w.Reset(buf)
+	if _, err := w.Write(packed); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pack appends the Packed encoding of st to b.
+func pack(b []byte, st *storagepb.SubtreeProto) []byte {
+	b = appendBytes(b, st.Prefix)
+	b = appendUvarint(b, uint64(uint32(st.Depth)))
+	b = appendBytes(b, st.RootHash)
+	b = appendUvarint(b, uint64(st.InternalNodeCount))
+	b = appendNodes(b, st.Leaves)
+	return appendNodes(b, st.InternalNodes)
+}
+
+// appendNodes appends the nodes to b in suffix order, with each suffix stored
+// as the length of its common prefix with the previous suffix and the rest.
+func appendNodes(b []byte, nodes map[string][]byte) []byte {
+	keys := make([]string, 0, len(nodes))
+	for k := range nodes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b = appendUvarint(b, uint64(len(keys)))
+	prev := ""
+	for _, k := range keys {
+		shared := 0
+		for shared < len(k) && shared < len(prev) && k[shared] == prev[shared] {
+			shared++
+		}
+		b = appendUvarint(b, uint64(shared))
+		b = appendBytes(b, []byte(k[shared:]))
+		b = appendBytes(b, nodes[k])
+		prev = k
+	}
+	return b
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendBytes(b, v []byte) []byte {
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// reader decodes the Packed encoding, and records the first error.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errors.New("subtreecodec: invalid varint")
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *reader) bytes() []byte {
+	n := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.data)) {
+		r.err = errors.New("subtreecodec: truncated data")
+		return nil
+	}
+	v := r.data[:n:n]
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *reader) nodes() map[string][]byte {
+	count := r.uvarint()
+	if count == 0 || r.err != nil {
+		return nil
+	}
+	if count > uint64(len(r.data)) {
+		r.err = errors.New("subtreecodec: truncated data")
+		return nil
+	}
+	nodes := make(map[string][]byte, count)
+	prev := ""
+	for i := uint64(0); i < count && r.err == nil; i++ {
+		shared := r.uvarint()
+		if shared > uint64(len(prev)) {
+			r.err = fmt.Errorf("subtreecodec: shared prefix %d longer than previous suffix", shared)
+			return nil
+		}
+		k := prev[:shared] + string(r.bytes())
+		nodes[k] = r.bytes()
+		prev = k
+	}
+	return nodes
+}
+
+func unpack(data []byte, st *storagepb.SubtreeProto) error {
+	r := &reader{data: data}
+	st.Prefix = r.bytes()
+	st.Depth = int32(r.uvarint())
+	st.RootHash = r.bytes()
+	st.InternalNodeCount = uint32(r.uvarint())
+	st.Leaves = r.nodes()
+	st.InternalNodes = r.nodes()
+	if r.err == nil && len(r.data) != 0 {
+		r.err = fmt.Errorf("subtreecodec: %d trailing bytes", len(r.data))
+	}
+	return r.err
+}
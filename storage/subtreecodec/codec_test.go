@@ -0,0 +1,199 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subtreecodec
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian/storage/storagepb"
+)
+
+// subtree returns a subtree with n leaves, in the format used by the map
+// subtree cache.
+func subtree(n int) *storagepb.SubtreeProto {
+	st := &storagepb.SubtreeProto{
+		Prefix:   []byte{1, 2},
+		Depth:    8,
+		RootHash: []byte("root"),
+		Leaves:   make(map[string][]byte),
+	}
+	for i := 0; i < n; i++ {
+		h := sha256.Sum256([]byte{byte(i)})
+		st.Leaves[base64.StdEncoding.EncodeToString([]byte{8, byte(i)})] = h[:]
+	}
+	return st
+}
+
+// xorCompressor stands in for another Zstandard implementation, and inverts
+// bits. Decompress fails for empty input.
+type xorCompressor struct{}
+
+func (xorCompressor) Compress(dst, src []byte) ([]byte, error) {
+	for _, b := range src {
+		dst = append(dst, ^b)
+	}
+	return dst, nil
+}
+
+func (xorCompressor) Decompress(src []byte) ([]byte, error) {
+	if len(src) == 0 {
+		return nil, errors.New("empty")
+	}
+	return xorCompressor{}.Compress(nil, src)
+}
+
+// withXorZstd registers xorCompressor for the Zstd codec, and returns a
+// function which restores the default compressor.
+func withXorZstd(t *testing.T) func() {
+	t.Helper()
+	if err := RegisterCompressor(Zstd, xorCompressor{}); err != nil {
+		t.Fatalf("RegisterCompressor(): %v", err)
+	}
+	return func() {
+		if err := RegisterCompressor(Zstd, zstdCompressor{}); err != nil {
+			t.Fatalf("RegisterCompressor(): %v", err)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	withInternal := subtree(3)
+	withInternal.InternalNodes = map[string][]byte{"AQA=": []byte("internal")}
+	withInternal.InternalNodeCount = 1
+
+	for _, st := range []*storagepb.SubtreeProto{{}, subtree(1), subtree(256), withInternal} {
+		for _, c := range []Codec{Proto, Packed, Deflate, Zstd} {
+			t.Run(fmt.Sprintf("%v/%d", c, len(st.Leaves)), func(t *testing.T) {
+				data, err := Marshal(st, c)
+				if err != nil {
+					t.Fatalf("Marshal(): %v", err)
+				}
+				var got storagepb.SubtreeProto
+				gotCodec, err := Unmarshal(data, &got)
+				if err != nil {
+					t.Fatalf("Unmarshal(): %v", err)
+				}
+				if gotCodec != c {
+					t.Errorf("Unmarshal(): codec %v, want %v", gotCodec, c)
+				}
+				if !proto.Equal(&got, st) {
+					t.Errorf("Unmarshal(): got %v, want %v", &got, st)
+				}
+			})
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	st := subtree(256)
+	protoData, err := Marshal(st, Proto)
+	if err != nil {
+		t.Fatalf("Marshal(Proto): %v", err)
+	}
+	for _, c := range []Codec{Packed, Deflate, Zstd} {
+		data, err := Marshal(st, c)
+		if err != nil {
+			t.Fatalf("Marshal(%v): %v", c, err)
+		}
+		if got, max := len(data), len(protoData); got >= max {
+			t.Errorf("Marshal(%v): got %d bytes, want < %d", c, got, max)
+		}
+	}
+}
+
+func TestUnmarshal_Invalid(t *testing.T) {
+	packed, err := Marshal(subtree(2), Packed)
+	if err != nil {
+		t.Fatalf("Marshal(): %v", err)
+	}
+	for _, test := range []struct {
+		desc string
+		data []byte
+	}{
+		{desc: "no codec", data: []byte{header}},
+		{desc: "unknown codec", data: []byte{header, 99}},
+		{desc: "truncated", data: packed[:len(packed)-1]},
+		{desc: "trailing bytes", data: append(packed[:len(packed):len(packed)], 0)},
+		{desc: "not deflated", data: []byte{header, byte(Deflate), 1, 2, 3}},
+		{desc: "not zstd compressed", data: []byte{header, byte(Zstd), 1, 2, 3}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var st storagepb.SubtreeProto
+			if _, err := Unmarshal(test.data, &st); err == nil {
+				t.Error("Unmarshal() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestUnmarshal_Zstd(t *testing.T) {
+	defer withXorZstd(t)()
+	var st storagepb.SubtreeProto
+	if _, err := Unmarshal([]byte{header, byte(Zstd)}, &st); err == nil {
+		t.Error("Unmarshal() succeeded, want decompression error")
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, c := range []Codec{Proto, Packed, Deflate, Zstd} {
+		if got, err := Parse(c.String()); err != nil || got != c {
+			t.Errorf("Parse(%q)=%v, %v, want %v", c.String(), got, err, c)
+		}
+	}
+	if _, err := Parse("lz4"); err == nil {
+		t.Error("Parse(lz4) succeeded, want error")
+	}
+	if err := Check(Deflate, []Codec{Proto}); err == nil {
+		t.Error("Check(Deflate, [Proto]) succeeded, want error")
+	}
+}
+
+func TestRegisterCompressor(t *testing.T) {
+	if err := Check(Zstd, []Codec{Zstd}); err != nil {
+		t.Errorf("Check(Zstd) with the default compressor: %v", err)
+	}
+	if err := RegisterCompressor(Deflate, xorCompressor{}); err == nil {
+		t.Error("RegisterCompressor(Deflate) succeeded, want error")
+	}
+
+	defer withXorZstd(t)()
+	st := subtree(1)
+	data, err := Marshal(st, Zstd)
+	if err != nil {
+		t.Fatalf("Marshal(): %v", err)
+	}
+	if want, err := (xorCompressor{}).Compress([]byte{header, byte(Zstd)}, pack(nil, st)); err != nil || !bytes.Equal(data, want) {
+		t.Errorf("Marshal() = %x, want %x from the registered compressor", data, want)
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	st := subtree(256)
+	for _, c := range []Codec{Proto, Packed, Deflate, Zstd} {
+		b.Run(c.String(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := Marshal(st, c); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -139,6 +139,7 @@ type AdminStorageTester struct {
 // RunAllTests runs all AdminStorage tests.
 func (tester *AdminStorageTester) RunAllTests(t *testing.T) {
 	t.Run("TestCreateTree", tester.TestCreateTree)
+	t.Run("TestCreateTreeWithID", tester.TestCreateTreeWithID)
 	t.Run("TestUpdateTree", tester.TestUpdateTree)
 	t.Run("TestListTrees", tester.TestListTrees)
 	t.Run("TestSoftDeleteTree", tester.TestSoftDeleteTree)
@@ -242,6 +243,39 @@ func (tester *AdminStorageTester) TestCreateTree(t *testing.T) {
 	}
 }
 
+// TestCreateTreeWithID tests the creation of Trees which keep their ID.
+func (tester *AdminStorageTester) TestCreateTreeWithID(t *testing.T) {
+	ctx := context.Background()
+	s := tester.NewAdminStorage()
+
+	created, err := storage.CreateTree(ctx, s, LogTree)
+	if err != nil {
+		t.Fatalf("CreateTree() = (_, %v), want = (_, nil)", err)
+	}
+
+	tree := proto.Clone(MapTree).(*trillian.Tree)
+	tree.TreeId = created.TreeId + 1
+	imported, err := storage.CreateTree(ctx, s, tree)
+	if err != nil {
+		t.Fatalf("CreateTree(%d) = (_, %v), want = (_, nil)", tree.TreeId, err)
+	}
+	if got, want := imported.TreeId, tree.TreeId; got != want {
+		t.Errorf("CreateTree() created tree %d, want %d", got, want)
+	}
+	if err := assertStoredTree(ctx, s, imported); err != nil {
+		t.Error(err)
+	}
+
+	tree.TreeId = created.TreeId
+	if _, err := storage.CreateTree(ctx, s, tree); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("CreateTree(%d) = (_, %v), want = (_, code %v)", tree.TreeId, err, codes.AlreadyExists)
+	}
+	tree.TreeId = -1
+	if _, err := storage.CreateTree(ctx, s, tree); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CreateTree(%d) = (_, %v), want = (_, code %v)", tree.TreeId, err, codes.InvalidArgument)
+	}
+}
+
 // TestUpdateTree tests AdminStorage Tree updates.
 func (tester *AdminStorageTester) TestUpdateTree(t *testing.T) {
 	ctx := context.Background()
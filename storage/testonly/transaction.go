@@ -17,6 +17,7 @@ package testonly
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/trillian"
@@ -119,6 +120,9 @@ type FakeMapStorage struct {
 	TX          storage.MapTreeTX
 	ReadOnlyTX  storage.ReadOnlyMapTreeTX
 	SnapshotErr error
+	// MultiTX holds the transactions passed to ReadWriteMultiTransaction, one
+	// per tree.
+	MultiTX []storage.MapTreeTX
 }
 
 // Snapshot implements MapStorage.Snapshot
@@ -136,6 +140,25 @@ func (f *FakeMapStorage) ReadWriteTransaction(ctx context.Context, tree *trillia
 	return RunOnMapTX(f.TX)(ctx, tree.TreeId, fn)
 }
 
+// ReadWriteMultiTransaction implements MapStorage.ReadWriteMultiTransaction
+func (f *FakeMapStorage) ReadWriteMultiTransaction(ctx context.Context, trees []*trillian.Tree, fn storage.MultiMapTXFunc) error {
+	if len(trees) != len(f.MultiTX) {
+		return fmt.Errorf("got %d trees, want %d", len(trees), len(f.MultiTX))
+	}
+	for _, tx := range f.MultiTX {
+		defer tx.Close()
+	}
+	if err := fn(ctx, f.MultiTX); err != nil {
+		return err
+	}
+	for _, tx := range f.MultiTX {
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // CheckDatabaseAccessible implements MapStorage.CheckDatabaseAccessible
 func (f *FakeMapStorage) CheckDatabaseAccessible(ctx context.Context) error {
 	return nil
@@ -0,0 +1,157 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/hashers"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/compression"
+)
+
+// counts summarizes the leaf versions examined by a backfiller.
+type counts struct {
+	// Versions is the number of stored leaf versions examined.
+	Versions int
+	// Missing is the number of versions which had no leaf hash.
+	Missing int
+	// Mismatched is the number of versions whose leaf hash differed from the
+	// recomputed one.
+	Mismatched int
+	// Repaired is the number of versions whose leaf hash was overwritten.
+	Repaired int
+}
+
+func (c *counts) add(o counts) {
+	c.Versions += o.Versions
+	c.Missing += o.Missing
+	c.Mismatched += o.Mismatched
+	c.Repaired += o.Repaired
+}
+
+func (c counts) String() string {
+	return fmt.Sprintf("versions=%d missing=%d mismatched=%d repaired=%d", c.Versions, c.Missing, c.Mismatched, c.Repaired)
+}
+
+// backfiller recomputes the leaf hashes of every stored version of every leaf
+// of a map, and compares them with the stored ones. In repair mode, missing or
+// incorrect hashes are overwritten.
+type backfiller struct {
+	ms        storage.MapStorage
+	tree      *trillian.Tree
+	hasher    hashers.MapHasher
+	codec     compression.Codec
+	batchSize int
+	repair    bool
+}
+
+func newBackfiller(ms storage.MapStorage, tree *trillian.Tree, batchSize int, repair bool) (*backfiller, error) {
+	if tree.TreeType != trillian.TreeType_MAP {
+		return nil, fmt.Errorf("tree %d is a %v, want a MAP", tree.TreeId, tree.TreeType)
+	}
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+	hasher, err := hashers.NewMapHasherForTree(tree)
+	if err != nil {
+		return nil, err
+	}
+	// Leaf hashes are computed over the uncompressed values.
+	codec, err := compression.ForTree(tree)
+	if err != nil {
+		return nil, err
+	}
+	return &backfiller{ms: ms, tree: tree, hasher: hasher, codec: codec, batchSize: batchSize, repair: repair}, nil
+}
+
+// run processes all the leaves of the map, one batch per transaction.
+func (b *backfiller) run(ctx context.Context) (counts, error) {
+	var total counts
+	var after []byte
+	for {
+		c, last, err := b.batch(ctx, after)
+		if err != nil {
+			return total, fmt.Errorf("failed to process leaves after %x: %v", after, err)
+		}
+		total.add(c)
+		if last == nil {
+			return total, nil
+		}
+		glog.V(1).Infof("%d: processed leaves up to %x: %v", b.tree.TreeId, last, total)
+		after = last
+	}
+}
+
+// batch processes the versions of up to batchSize leaves following after. It
+// returns the index of the last leaf processed, or nil if there were no leaves
+// left.
+func (b *backfiller) batch(ctx context.Context, after []byte) (counts, []byte, error) {
+	var c counts
+	var last []byte
+	process := func(ctx context.Context, tx storage.ReadOnlyMapTreeTX, set func(storage.MapLeafVersion, []byte) error) error {
+		// Transactions may be retried, so start from scratch.
+		c, last = counts{}, nil
+		versions, err := tx.ListLeafVersions(ctx, after, b.batchSize)
+		if err != nil {
+			return err
+		}
+		for _, v := range versions {
+			c.Versions++
+			last = v.Leaf.Index
+			want := b.hasher.HashLeaf(b.tree.TreeId, v.Leaf.Index, v.Leaf.LeafValue)
+			if bytes.Equal(v.Leaf.LeafHash, want) {
+				continue
+			}
+			if len(v.Leaf.LeafHash) == 0 {
+				c.Missing++
+			} else {
+				c.Mismatched++
+			}
+			glog.V(2).Infof("%d: leaf %x at revision %d has hash %x, want %x", b.tree.TreeId, v.Leaf.Index, v.Revision, v.Leaf.LeafHash, want)
+			if set == nil {
+				continue
+			}
+			if err := set(v, want); err != nil {
+				return err
+			}
+			c.Repaired++
+		}
+		return nil
+	}
+
+	if b.repair {
+		err := b.ms.ReadWriteTransaction(ctx, b.tree, func(ctx context.Context, tx storage.MapTreeTX) error {
+			return process(ctx, compression.WrapTX(tx, b.codec), func(v storage.MapLeafVersion, hash []byte) error {
+				return tx.SetLeafHash(ctx, v.Revision, v.Leaf.Index, hash)
+			})
+		})
+		return c, last, err
+	}
+
+	tx, err := b.ms.SnapshotForTree(ctx, b.tree)
+	if err != nil {
+		return counts{}, nil, err
+	}
+	defer tx.Close()
+	if err := process(ctx, compression.WrapReadOnlyTX(tx, b.codec), nil); err != nil {
+		return counts{}, nil, err
+	}
+	return c, last, tx.Commit(ctx)
+}
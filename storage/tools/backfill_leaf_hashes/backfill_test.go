@@ -0,0 +1,125 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/maphasher"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/testonly"
+)
+
+func TestBackfiller(t *testing.T) {
+	tree := &trillian.Tree{TreeId: 1, TreeType: trillian.TreeType_MAP, HashStrategy: trillian.HashStrategy_TEST_MAP_HASHER}
+	hasher := maphasher.Default
+	index := func(i byte) []byte { return []byte{i} }
+	version := func(i byte, rev int64, hash []byte) storage.MapLeafVersion {
+		return storage.MapLeafVersion{Revision: rev, Leaf: &trillian.MapLeaf{Index: index(i), LeafValue: []byte{byte(rev)}, LeafHash: hash}}
+	}
+	good := func(i byte, rev int64) storage.MapLeafVersion {
+		return version(i, rev, hasher.HashLeaf(tree.TreeId, index(i), []byte{byte(rev)}))
+	}
+	// Two batches of two leaves: leaf 1 has a version with a missing hash,
+	// and leaf 2 one with an incorrect hash.
+	batch1 := []storage.MapLeafVersion{good(0, 1), version(1, 1, nil), good(1, 2)}
+	batch2 := []storage.MapLeafVersion{version(2, 3, []byte("bad")), good(3, 3)}
+	want := counts{Versions: 5, Missing: 1, Mismatched: 1}
+
+	t.Run("verify", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		var txs []storage.ReadOnlyMapTreeTX
+		for _, resp := range []struct {
+			after    []byte
+			versions []storage.MapLeafVersion
+		}{
+			{after: nil, versions: batch1},
+			{after: index(1), versions: batch2},
+			{after: index(3), versions: nil},
+		} {
+			tx := storage.NewMockReadOnlyMapTreeTX(ctrl)
+			tx.EXPECT().ListLeafVersions(gomock.Any(), resp.after, 2).Return(resp.versions, nil)
+			tx.EXPECT().Commit(gomock.Any()).Return(nil)
+			tx.EXPECT().Close().Return(nil)
+			txs = append(txs, tx)
+		}
+		ms := &snapshotSequence{txs: txs}
+
+		b, err := newBackfiller(ms, tree, 2, false)
+		if err != nil {
+			t.Fatalf("newBackfiller(): %v", err)
+		}
+		got, err := b.run(context.Background())
+		if err != nil {
+			t.Fatalf("run(): %v", err)
+		}
+		if got != want {
+			t.Errorf("run()=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("repair", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		tx := storage.NewMockMapTreeTX(ctrl)
+		gomock.InOrder(
+			tx.EXPECT().ListLeafVersions(gomock.Any(), nil, 2).Return(batch1, nil),
+			tx.EXPECT().SetLeafHash(gomock.Any(), int64(1), index(1), good(1, 1).Leaf.LeafHash).Return(nil),
+			tx.EXPECT().ListLeafVersions(gomock.Any(), index(1), 2).Return(batch2, nil),
+			tx.EXPECT().SetLeafHash(gomock.Any(), int64(3), index(2), good(2, 3).Leaf.LeafHash).Return(nil),
+			tx.EXPECT().ListLeafVersions(gomock.Any(), index(3), 2).Return(nil, nil),
+		)
+		tx.EXPECT().Commit(gomock.Any()).Return(nil).Times(3)
+		tx.EXPECT().Close().Return(nil).Times(3)
+
+		b, err := newBackfiller(&testonly.FakeMapStorage{TX: tx}, tree, 2, true)
+		if err != nil {
+			t.Fatalf("newBackfiller(): %v", err)
+		}
+		got, err := b.run(context.Background())
+		if err != nil {
+			t.Fatalf("run(): %v", err)
+		}
+		if want := (counts{Versions: 5, Missing: 1, Mismatched: 1, Repaired: 2}); got != want {
+			t.Errorf("run()=%v, want %v", got, want)
+		}
+	})
+}
+
+func TestNewBackfillerRejectsLogs(t *testing.T) {
+	tree := &trillian.Tree{TreeId: 1, TreeType: trillian.TreeType_LOG, HashStrategy: trillian.HashStrategy_RFC6962_SHA256}
+	if _, err := newBackfiller(&testonly.FakeMapStorage{}, tree, 10, false); err == nil {
+		t.Error("newBackfiller() for a log succeeded, want error")
+	}
+}
+
+// snapshotSequence is a MapStorage which returns a different read-only
+// transaction for each snapshot.
+type snapshotSequence struct {
+	testonly.FakeMapStorage
+	txs []storage.ReadOnlyMapTreeTX
+}
+
+func (s *snapshotSequence) SnapshotForTree(ctx context.Context, _ *trillian.Tree) (storage.ReadOnlyMapTreeTX, error) {
+	tx := s.txs[0]
+	s.txs = s.txs[1:]
+	return tx, nil
+}
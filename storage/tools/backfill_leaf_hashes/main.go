@@ -0,0 +1,80 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The backfill_leaf_hashes program recomputes the leaf hashes of every stored
+// version of every leaf of a map, using the map's hash strategy, and checks
+// them against the stored leaf hashes. It is intended for use after fixing a
+// hasher bug, or after importing data written by a faulty personality.
+//
+// It accesses storage directly, so it takes the same storage flags as the map
+// server. By default stored data is not modified; with -repair, missing and
+// incorrect leaf hashes are overwritten. Note that only the stored leaf hashes
+// are repaired: the Merkle tree of the map is not recomputed.
+//
+// Verify the leaf hashes of a map stored in MySQL:
+// backfill_leaf_hashes -map_id 1234 -mysql_uri 'test:zaphod@tcp(127.0.0.1:3306)/test'
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/monitoring"
+	"github.com/google/trillian/server"
+	"github.com/google/trillian/storage"
+
+	// Load hashers
+	_ "github.com/google/trillian/merkle/coniks"
+	_ "github.com/google/trillian/merkle/maphasher"
+)
+
+var (
+	mapID     = flag.Int64("map_id", 0, "ID of the map whose leaf hashes are checked")
+	repair    = flag.Bool("repair", false, "If true, missing and incorrect leaf hashes are overwritten. Otherwise they are only reported")
+	batchSize = flag.Int("batch_size", 1000, "Number of leaves whose versions are processed in each transaction")
+)
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+	ctx := context.Background()
+
+	if *mapID == 0 {
+		glog.Exit("The -map_id flag must be set")
+	}
+
+	sp, err := server.NewStorageProviderFromFlags(monitoring.InertMetricFactory{})
+	if err != nil {
+		glog.Exitf("Failed to get storage provider: %v", err)
+	}
+	defer sp.Close()
+
+	tree, err := storage.GetTree(ctx, sp.AdminStorage(), *mapID)
+	if err != nil {
+		glog.Exitf("Failed to get map %d: %v", *mapID, err)
+	}
+	b, err := newBackfiller(sp.MapStorage(), tree, *batchSize, *repair)
+	if err != nil {
+		glog.Exitf("Failed to check map %d: %v", *mapID, err)
+	}
+	c, err := b.run(ctx)
+	glog.Infof("Map %d: %v", *mapID, c)
+	if err != nil {
+		glog.Exitf("Failed to check map %d: %v", *mapID, err)
+	}
+	if c.Missing+c.Mismatched > c.Repaired {
+		glog.Exitf("Map %d has %d missing and %d incorrect leaf hashes; run with -repair to fix them", *mapID, c.Missing, c.Mismatched)
+	}
+}
@@ -18,6 +18,10 @@ import (
 	"crypto/rand"
 	"math"
 	"math/big"
+
+	"github.com/google/trillian"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // NewTreeID generates a random, positive, non-zero tree ID.
@@ -28,3 +32,15 @@ func NewTreeID() (int64, error) {
 	}
 	return id.Int64() + 1, nil
 }
+
+// TreeIDForCreation returns the ID to create tree with: its TreeId if set,
+// e.g. to import a tree under its original ID, or else a new random one.
+func TreeIDForCreation(tree *trillian.Tree) (int64, error) {
+	switch {
+	case tree.TreeId < 0:
+		return 0, status.Errorf(codes.InvalidArgument, "invalid tree_id: %d", tree.TreeId)
+	case tree.TreeId > 0:
+		return tree.TreeId, nil
+	}
+	return NewTreeID()
+}
@@ -16,6 +16,10 @@ package storage
 
 import (
 	"testing"
+
+	"github.com/google/trillian"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestNewTreeID(t *testing.T) {
@@ -35,3 +39,30 @@ func TestNewTreeID(t *testing.T) {
 		}
 	}
 }
+
+func TestTreeIDForCreation(t *testing.T) {
+	for _, test := range []struct {
+		desc     string
+		treeID   int64
+		wantID   int64
+		wantCode codes.Code
+	}{
+		{desc: "new"},
+		{desc: "kept", treeID: 12345, wantID: 12345},
+		{desc: "negative", treeID: -1, wantCode: codes.InvalidArgument},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			id, err := TreeIDForCreation(&trillian.Tree{TreeId: test.treeID})
+			if got := status.Code(err); got != test.wantCode {
+				t.Fatalf("TreeIDForCreation() = (_, %v), want code %v", err, test.wantCode)
+			}
+			switch {
+			case err != nil:
+			case test.wantID != 0 && id != test.wantID:
+				t.Errorf("TreeIDForCreation() = %v, want %v", id, test.wantID)
+			case id <= 0:
+				t.Errorf("TreeIDForCreation() = %v, want > 0", id)
+			}
+		})
+	}
+}
@@ -27,8 +27,11 @@ var ErrTreeNeedsInit = status.Error(codes.FailedPrecondition, "tree needs initia
 
 // ReadOnlyTreeTX represents a read-only transaction on a TreeStorage.
 // A ReadOnlyTreeTX can only modify the tree specified in its creation.
+// Its reads may be made concurrently, but not after it is committed, rolled
+// back or closed.
 type ReadOnlyTreeTX interface {
 	NodeReader
+	RecoveryMarkerReader
 
 	// ReadRevision returns the tree revision that was current at the time this
 	// transaction was started.
@@ -69,6 +72,31 @@ type TreeWriter interface {
 	WriteRevision(ctx context.Context) (int64, error)
 }
 
+// RecoveryMarker identifies the point in a storage backend's recovery stream,
+// such as a MySQL binary log position or a Spanner commit timestamp, at which a
+// signed tree root was published. Markers are stored in the same transaction as
+// the root they refer to, so a database restored from a backup or to a point
+// in time contains a root if and only if it contains its marker.
+type RecoveryMarker struct {
+	TreeID int64
+	// Revision is the revision of the root the marker was stored with.
+	Revision int64
+	// Position is the backend-specific recovery position. A database restored
+	// up to Position contains the root. It may be empty if the backend could
+	// not determine it.
+	Position string
+}
+
+// RecoveryMarkerReader provides access to the recovery markers of a tree.
+type RecoveryMarkerReader interface {
+	// GetRecoveryMarker returns the marker stored with the root at revision,
+	// or a NotFound error if there is none.
+	GetRecoveryMarker(ctx context.Context, revision int64) (*RecoveryMarker, error)
+	// LatestRecoveryMarker returns the marker of the most recent root, or a
+	// NotFound error if no marker has been stored.
+	LatestRecoveryMarker(ctx context.Context) (*RecoveryMarker, error)
+}
+
 // DatabaseChecker performs connectivity checks on the database.
 type DatabaseChecker interface {
 	// CheckDatabaseAccessible returns nil if the database is accessible, error otherwise.
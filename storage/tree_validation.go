@@ -17,12 +17,16 @@ package storage
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto/keys"
 	"github.com/google/trillian/crypto/keys/der"
+	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/crypto/sigpb"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -54,11 +58,30 @@ func ValidateTreeForCreation(ctx context.Context, tree *trillian.Tree) error {
 		return status.Errorf(codes.InvalidArgument, "invalid deleted: %v", tree.Deleted)
 	case tree.DeleteTime != nil:
 		return status.Errorf(codes.InvalidArgument, "invalid delete_time: %+v (must be nil)", tree.DeleteTime)
+	case tree.KeyRotation != nil:
+		return status.Error(codes.InvalidArgument, "invalid key_rotation: must be nil")
+	case tree.MapIndexBits != 0 && tree.TreeType != trillian.TreeType_MAP:
+		return status.Errorf(codes.InvalidArgument, "map_index_bits not supported for tree_type: %s", tree.TreeType)
+	case tree.MapIndexBits < 0 || tree.MapIndexBits%8 != 0:
+		return status.Errorf(codes.InvalidArgument, "invalid map_index_bits: %v (must be a multiple of 8)", tree.MapIndexBits)
 	}
 
 	return validateMutableTreeFields(ctx, tree)
 }
 
+// publicKeysEqual returns whether a and b hold the same public keys.
+func publicKeysEqual(a, b []*keyspb.PublicKey) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 // validateTreeTypeUpdate returns nil iff oldTree.TreeType can be updated to
 // newTree.TreeType. The tree type is changeable only if the Tree is and
 // remains in the FROZEN state.
@@ -105,16 +128,33 @@ func ValidateTreeForUpdate(ctx context.Context, storedTree, newTree *trillian.Tr
 		return status.Error(codes.InvalidArgument, "readonly field changed: create_time")
 	case !proto.Equal(storedTree.UpdateTime, newTree.UpdateTime):
 		return status.Error(codes.InvalidArgument, "readonly field changed: update_time")
-	case !proto.Equal(storedTree.PublicKey, newTree.PublicKey):
+	case !proto.Equal(storedTree.PublicKey, newTree.PublicKey) && !isKeyRetirement(storedTree, newTree):
 		return status.Error(codes.InvalidArgument, "readonly field changed: public_key")
 	case storedTree.Deleted != newTree.Deleted:
 		return status.Error(codes.InvalidArgument, "readonly field changed: deleted")
 	case !proto.Equal(storedTree.DeleteTime, newTree.DeleteTime):
 		return status.Error(codes.InvalidArgument, "readonly field changed: delete_time")
+	case storedTree.MapIndexBits != newTree.MapIndexBits:
+		return status.Error(codes.InvalidArgument, "readonly field changed: map_index_bits")
+	case !publicKeysEqual(storedTree.AdditionalPublicKeys, newTree.AdditionalPublicKeys):
+		return status.Error(codes.InvalidArgument, "readonly field changed: additional_public_keys")
+	case storedTree.SignatureThreshold != newTree.SignatureThreshold:
+		return status.Error(codes.InvalidArgument, "readonly field changed: signature_threshold")
+	case !proto.Equal(storedTree.KeyRotation, newTree.KeyRotation) && storedTree.KeyRotation != nil && !isKeyRetirement(storedTree, newTree):
+		// Rotations may be started, and then only completed.
+		return status.Error(codes.InvalidArgument, "readonly field changed: key_rotation")
 	}
 	return validateMutableTreeFields(ctx, newTree)
 }
 
+// isKeyRetirement returns whether newTree completes the key rotation of
+// storedTree, by replacing its keys with the new ones.
+func isKeyRetirement(storedTree, newTree *trillian.Tree) bool {
+	rotation := storedTree.KeyRotation
+	return rotation != nil && newTree.KeyRotation == nil &&
+		proto.Equal(newTree.PrivateKey, rotation.PrivateKey) && proto.Equal(newTree.PublicKey, rotation.PublicKey)
+}
+
 func validateMutableTreeFields(ctx context.Context, tree *trillian.Tree) error {
 	if tree.TreeState == trillian.TreeState_UNKNOWN_TREE_STATE {
 		return status.Errorf(codes.InvalidArgument, "invalid tree_state: %v", tree.TreeState)
@@ -124,6 +164,28 @@ func validateMutableTreeFields(ctx context.Context, tree *trillian.Tree) error {
 	} else if duration < 0 {
 		return status.Errorf(codes.InvalidArgument, "max_root_duration negative: %v", tree.MaxRootDuration)
 	}
+	if tree.DeleteRetention != nil {
+		if duration, err := ptypes.Duration(tree.DeleteRetention); err != nil {
+			return status.Errorf(codes.InvalidArgument, "delete_retention malformed: %v", tree.DeleteRetention)
+		} else if duration < 0 {
+			return status.Errorf(codes.InvalidArgument, "delete_retention negative: %v", tree.DeleteRetention)
+		}
+	}
+	if q := tree.Quota; q.GetReadTokensPerSecond() < 0 || q.GetWriteTokensPerSecond() < 0 {
+		return status.Errorf(codes.InvalidArgument, "quota negative: %v", q)
+	}
+	if len(tree.Owner) > maxOwnerSize {
+		return status.Errorf(codes.InvalidArgument, "owner too long: got %d bytes, max %d", len(tree.Owner), maxOwnerSize)
+	}
+	if err := validateRevisionRetentionPolicy(tree); err != nil {
+		return err
+	}
+	if err := validateDuplicateLeafPolicy(tree); err != nil {
+		return err
+	}
+	if err := validateLabels(tree.Labels); err != nil {
+		return err
+	}
 
 	// Implementations may vary, so let's assume storage_settings is mutable.
 	// Other than checking that it's a valid Any there isn't much to do at this layer, though.
@@ -134,23 +196,134 @@ func validateMutableTreeFields(ctx context.Context, tree *trillian.Tree) error {
 		}
 	}
 
+	if err := validateKeyPair(ctx, tree.PrivateKey, tree.PublicKey, "private_key", "public_key"); err != nil {
+		return err
+	}
+	if err := validateKeyRotation(ctx, tree.KeyRotation); err != nil {
+		return err
+	}
+	return validateAdditionalKeys(ctx, tree)
+}
+
+// validateKeyRotation returns nil iff rotation is nil, or has a matching key
+// pair and a retire_time.
+func validateKeyRotation(ctx context.Context, rotation *trillian.KeyRotation) error {
+	if rotation == nil {
+		return nil
+	}
+	if _, err := ptypes.Timestamp(rotation.RetireTime); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid key_rotation.retire_time: %v", err)
+	}
+	return validateKeyPair(ctx, rotation.PrivateKey, rotation.PublicKey, "key_rotation.private_key", "key_rotation.public_key")
+}
+
+// validateKeyPair returns nil iff the private key can be obtained and matches
+// the public key.
+func validateKeyPair(ctx context.Context, key *any.Any, publicKey *keyspb.PublicKey, privateField, publicField string) error {
 	var privateKeyProto ptypes.DynamicAny
-	if err := ptypes.UnmarshalAny(tree.PrivateKey, &privateKeyProto); err != nil {
-		return status.Errorf(codes.InvalidArgument, "invalid private_key: %v", err)
+	if err := ptypes.UnmarshalAny(key, &privateKeyProto); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid %s: %v", privateField, err)
 	}
 
-	// Check that the private key can be obtained and matches the public key.
 	privateKey, err := keys.NewSigner(ctx, privateKeyProto.Message)
 	if err != nil {
-		return status.Errorf(codes.InvalidArgument, "invalid private_key: %v", err)
+		return status.Errorf(codes.InvalidArgument, "invalid %s: %v", privateField, err)
 	}
 	publicKeyDER, err := der.MarshalPublicKey(privateKey.Public())
 	if err != nil {
-		return status.Errorf(codes.InvalidArgument, "invalid private_key: %v", err)
+		return status.Errorf(codes.InvalidArgument, "invalid %s: %v", privateField, err)
+	}
+	if !bytes.Equal(publicKeyDER, publicKey.GetDer()) {
+		return status.Errorf(codes.InvalidArgument, "%s and %s are not a matching pair", privateField, publicField)
+	}
+	return nil
+}
+
+// validateAdditionalKeys returns nil iff the additional keys of the tree are
+// matching pairs, and its signature_threshold can be met.
+func validateAdditionalKeys(ctx context.Context, tree *trillian.Tree) error {
+	if got, want := len(tree.AdditionalPublicKeys), len(tree.AdditionalPrivateKeys); got != want {
+		return status.Errorf(codes.InvalidArgument, "got %d additional_public_keys, want %d", got, want)
 	}
-	if !bytes.Equal(publicKeyDER, tree.PublicKey.GetDer()) {
-		return status.Errorf(codes.InvalidArgument, "private_key and public_key are not a matching pair")
+	for i, key := range tree.AdditionalPrivateKeys {
+		privateField := fmt.Sprintf("additional_private_keys[%d]", i)
+		publicField := fmt.Sprintf("additional_public_keys[%d]", i)
+		if err := validateKeyPair(ctx, key, tree.AdditionalPublicKeys[i], privateField, publicField); err != nil {
+			return err
+		}
+	}
+	if n := int32(len(tree.AdditionalPrivateKeys)) + 1; tree.SignatureThreshold < 0 || tree.SignatureThreshold > n {
+		return status.Errorf(codes.InvalidArgument, "invalid signature_threshold: %v (must be at most the %d keys)", tree.SignatureThreshold, n)
+	}
+	return nil
+}
+
+// validateDuplicateLeafPolicy returns nil iff the tree has no
+// duplicate_leaf_policy, or it is a log with a known one.
+func validateDuplicateLeafPolicy(tree *trillian.Tree) error {
+	policy := tree.DuplicateLeafPolicy
+	if policy == trillian.DuplicateLeafPolicy_DUPLICATE_LEAF_POLICY_UNSPECIFIED {
+		return nil
 	}
+	if tree.TreeType != trillian.TreeType_LOG {
+		return status.Errorf(codes.InvalidArgument, "duplicate_leaf_policy not supported for tree_type: %s", tree.TreeType)
+	}
+	if _, ok := trillian.DuplicateLeafPolicy_name[int32(policy)]; !ok {
+		return status.Errorf(codes.InvalidArgument, "invalid duplicate_leaf_policy: %v", policy)
+	}
+	return nil
+}
+
+// Limits on the sizes of the labels of a tree.
+const (
+	maxLabelKeySize   = 63
+	maxLabelValueSize = 255
+)
+
+// maxOwnerSize is the limit on the size of the owner of a tree.
+const maxOwnerSize = 255
 
+// validateLabels returns nil iff the label keys are non-empty, and the labels
+// are within the size limits.
+func validateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if k == "" {
+			return status.Errorf(codes.InvalidArgument, "labels: empty key")
+		}
+		if len(k) > maxLabelKeySize {
+			return status.Errorf(codes.InvalidArgument, "labels: key %q too long: got %d bytes, max %d", k, len(k), maxLabelKeySize)
+		}
+		if len(v) > maxLabelValueSize {
+			return status.Errorf(codes.InvalidArgument, "labels: value of %q too long: got %d bytes, max %d", k, len(v), maxLabelValueSize)
+		}
+	}
+	return nil
+}
+
+// validateRevisionRetentionPolicy returns nil iff the tree has no
+// revision_retention_policy, or it is a map with a well-formed one.
+func validateRevisionRetentionPolicy(tree *trillian.Tree) error {
+	policy := tree.RevisionRetentionPolicy
+	if policy == nil {
+		return nil
+	}
+	if tree.TreeType != trillian.TreeType_MAP {
+		return status.Errorf(codes.InvalidArgument, "revision_retention_policy not supported for tree_type: %s", tree.TreeType)
+	}
+	if policy.KeepRevisions < 0 {
+		return status.Errorf(codes.InvalidArgument, "revision_retention_policy.keep_revisions negative: %v", policy.KeepRevisions)
+	}
+	var keepDuration time.Duration
+	if policy.KeepDuration != nil {
+		var err error
+		if keepDuration, err = ptypes.Duration(policy.KeepDuration); err != nil {
+			return status.Errorf(codes.InvalidArgument, "revision_retention_policy.keep_duration malformed: %v", policy.KeepDuration)
+		} else if keepDuration < 0 {
+			return status.Errorf(codes.InvalidArgument, "revision_retention_policy.keep_duration negative: %v", policy.KeepDuration)
+		}
+	}
+	if policy.KeepRevisions == 0 && keepDuration == 0 {
+		return status.Error(codes.InvalidArgument, "revision_retention_policy requires keep_revisions or keep_duration")
+	}
 	return nil
 }
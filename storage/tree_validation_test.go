@@ -16,6 +16,7 @@ package storage
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -118,6 +119,104 @@ func TestValidateTreeForCreation(t *testing.T) {
 	deleteTimeTree := newTree()
 	deleteTimeTree.DeleteTime = ptypes.TimestampNow()
 
+	validRetention := newTree()
+	validRetention.TreeType = trillian.TreeType_MAP
+	validRetention.RevisionRetentionPolicy = &trillian.RevisionRetentionPolicy{
+		KeepRevisions: 10,
+		KeepDuration:  ptypes.DurationProto(time.Hour),
+	}
+
+	logRetention := newTree()
+	logRetention.RevisionRetentionPolicy = &trillian.RevisionRetentionPolicy{KeepRevisions: 10}
+
+	emptyRetention := newTree()
+	emptyRetention.TreeType = trillian.TreeType_MAP
+	emptyRetention.RevisionRetentionPolicy = &trillian.RevisionRetentionPolicy{}
+
+	negativeKeepRevisions := newTree()
+	negativeKeepRevisions.TreeType = trillian.TreeType_MAP
+	negativeKeepRevisions.RevisionRetentionPolicy = &trillian.RevisionRetentionPolicy{KeepRevisions: -1}
+
+	negativeKeepDuration := newTree()
+	negativeKeepDuration.TreeType = trillian.TreeType_MAP
+	negativeKeepDuration.RevisionRetentionPolicy = &trillian.RevisionRetentionPolicy{
+		KeepRevisions: 10,
+		KeepDuration:  ptypes.DurationProto(-time.Second),
+	}
+
+	validIndexBits := newTree()
+	validIndexBits.TreeType = trillian.TreeType_MAP
+	validIndexBits.MapIndexBits = 160
+
+	logIndexBits := newTree()
+	logIndexBits.MapIndexBits = 160
+
+	indexBitsNotBytes := newTree()
+	indexBitsNotBytes.TreeType = trillian.TreeType_MAP
+	indexBitsNotBytes.MapIndexBits = 100
+
+	negativeIndexBits := newTree()
+	negativeIndexBits.TreeType = trillian.TreeType_MAP
+	negativeIndexBits.MapIndexBits = -8
+
+	dedupLog := newTree()
+	dedupLog.DuplicateLeafPolicy = trillian.DuplicateLeafPolicy_DEDUPLICATE
+
+	dedupPreordered := newTree()
+	dedupPreordered.TreeType = trillian.TreeType_PREORDERED_LOG
+	dedupPreordered.DuplicateLeafPolicy = trillian.DuplicateLeafPolicy_ALLOW_DUPLICATES
+
+	unknownDupPolicy := newTree()
+	unknownDupPolicy.DuplicateLeafPolicy = trillian.DuplicateLeafPolicy(99)
+
+	additionalKeys := newTree()
+	additionalKeys.AdditionalPrivateKeys = []*any.Any{additionalKeys.PrivateKey}
+	additionalKeys.AdditionalPublicKeys = []*keyspb.PublicKey{additionalKeys.PublicKey}
+	additionalKeys.SignatureThreshold = 2
+
+	missingAdditionalPublicKey := newTree()
+	missingAdditionalPublicKey.AdditionalPrivateKeys = []*any.Any{missingAdditionalPublicKey.PrivateKey}
+
+	mismatchedAdditionalKeys := newTree()
+	mismatchedAdditionalKeys.AdditionalPrivateKeys = []*any.Any{mismatchedAdditionalKeys.PrivateKey}
+	mismatchedAdditionalKeys.AdditionalPublicKeys = []*keyspb.PublicKey{{Der: ktestonly.MustMarshalPublicPEMToDER(testonly.DemoPublicKey)}}
+
+	thresholdTooHigh := newTree()
+	thresholdTooHigh.SignatureThreshold = 2
+
+	labels := newTree()
+	labels.Labels = map[string]string{"env": "prod", "owner": ""}
+
+	emptyLabelKey := newTree()
+	emptyLabelKey.Labels = map[string]string{"": "prod"}
+
+	longLabelKey := newTree()
+	longLabelKey.Labels = map[string]string{strings.Repeat("k", maxLabelKeySize+1): "prod"}
+
+	deleteRetention := newTree()
+	deleteRetention.DeleteRetention = ptypes.DurationProto(24 * time.Hour)
+
+	keyRotation := newTree()
+	keyRotation.KeyRotation = newKeyRotation()
+
+	negativeDeleteRetention := newTree()
+	negativeDeleteRetention.DeleteRetention = ptypes.DurationProto(-1 * time.Second)
+
+	quota := newTree()
+	quota.Quota = &trillian.TreeQuota{ReadTokensPerSecond: 100}
+
+	negativeQuota := newTree()
+	negativeQuota.Quota = &trillian.TreeQuota{WriteTokensPerSecond: -1}
+
+	owner := newTree()
+	owner.Owner = "tenant-1"
+
+	longOwner := newTree()
+	longOwner.Owner = strings.Repeat("o", maxOwnerSize+1)
+
+	longLabelValue := newTree()
+	longLabelValue.Labels = map[string]string{"env": strings.Repeat("v", maxLabelValueSize+1)}
+
 	tests := []struct {
 		desc    string
 		tree    *trillian.Tree
@@ -220,6 +319,133 @@ func TestValidateTreeForCreation(t *testing.T) {
 			tree:    deleteTimeTree,
 			wantErr: true,
 		},
+		{
+			desc: "validRetention",
+			tree: validRetention,
+		},
+		{
+			desc:    "logRetention",
+			tree:    logRetention,
+			wantErr: true,
+		},
+		{
+			desc:    "emptyRetention",
+			tree:    emptyRetention,
+			wantErr: true,
+		},
+		{
+			desc:    "negativeKeepRevisions",
+			tree:    negativeKeepRevisions,
+			wantErr: true,
+		},
+		{
+			desc:    "negativeKeepDuration",
+			tree:    negativeKeepDuration,
+			wantErr: true,
+		},
+		{
+			desc: "validIndexBits",
+			tree: validIndexBits,
+		},
+		{
+			desc:    "logIndexBits",
+			tree:    logIndexBits,
+			wantErr: true,
+		},
+		{
+			desc:    "indexBitsNotBytes",
+			tree:    indexBitsNotBytes,
+			wantErr: true,
+		},
+		{
+			desc:    "negativeIndexBits",
+			tree:    negativeIndexBits,
+			wantErr: true,
+		},
+		{
+			desc: "dedupLog",
+			tree: dedupLog,
+		},
+		{
+			desc:    "dedupPreordered",
+			tree:    dedupPreordered,
+			wantErr: true,
+		},
+		{
+			desc:    "unknownDupPolicy",
+			tree:    unknownDupPolicy,
+			wantErr: true,
+		},
+		{
+			desc: "additionalKeys",
+			tree: additionalKeys,
+		},
+		{
+			desc:    "missingAdditionalPublicKey",
+			tree:    missingAdditionalPublicKey,
+			wantErr: true,
+		},
+		{
+			desc:    "mismatchedAdditionalKeys",
+			tree:    mismatchedAdditionalKeys,
+			wantErr: true,
+		},
+		{
+			desc:    "thresholdTooHigh",
+			tree:    thresholdTooHigh,
+			wantErr: true,
+		},
+		{
+			desc: "labels",
+			tree: labels,
+		},
+		{
+			desc:    "emptyLabelKey",
+			tree:    emptyLabelKey,
+			wantErr: true,
+		},
+		{
+			desc:    "longLabelKey",
+			tree:    longLabelKey,
+			wantErr: true,
+		},
+		{
+			desc:    "longLabelValue",
+			tree:    longLabelValue,
+			wantErr: true,
+		},
+		{
+			desc: "deleteRetention",
+			tree: deleteRetention,
+		},
+		{
+			desc:    "negativeDeleteRetention",
+			tree:    negativeDeleteRetention,
+			wantErr: true,
+		},
+		{
+			desc:    "keyRotation",
+			tree:    keyRotation,
+			wantErr: true,
+		},
+		{
+			desc: "quota",
+			tree: quota,
+		},
+		{
+			desc:    "negativeQuota",
+			tree:    negativeQuota,
+			wantErr: true,
+		},
+		{
+			desc: "owner",
+			tree: owner,
+		},
+		{
+			desc:    "longOwner",
+			tree:    longOwner,
+			wantErr: true,
+		},
 	}
 	for _, test := range tests {
 		err := ValidateTreeForCreation(ctx, test.tree)
@@ -285,6 +511,20 @@ func TestValidateTreeForUpdate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			desc:     "validRetention",
+			treeType: trillian.TreeType_MAP,
+			updatefn: func(tree *trillian.Tree) {
+				tree.RevisionRetentionPolicy = &trillian.RevisionRetentionPolicy{KeepDuration: ptypes.DurationProto(time.Hour)}
+			},
+		},
+		{
+			desc: "logRetention",
+			updatefn: func(tree *trillian.Tree) {
+				tree.RevisionRetentionPolicy = &trillian.RevisionRetentionPolicy{KeepRevisions: 10}
+			},
+			wantErr: true,
+		},
 		{
 			desc: "differentPrivateKeyProtoButSameKeyMaterial",
 			updatefn: func(tree *trillian.Tree) {
@@ -343,6 +583,28 @@ func TestValidateTreeForUpdate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			desc: "AdditionalPublicKeys",
+			updatefn: func(tree *trillian.Tree) {
+				tree.AdditionalPublicKeys = []*keyspb.PublicKey{tree.PublicKey}
+			},
+			wantErr: true,
+		},
+		{
+			desc: "SignatureThreshold",
+			updatefn: func(tree *trillian.Tree) {
+				tree.SignatureThreshold = 1
+			},
+			wantErr: true,
+		},
+		{
+			desc:     "MapIndexBits",
+			treeType: trillian.TreeType_MAP,
+			updatefn: func(tree *trillian.Tree) {
+				tree.MapIndexBits = 160
+			},
+			wantErr: true,
+		},
 		{
 			desc:     "TreeTypeFromPreorderedLogToLog",
 			treeType: trillian.TreeType_PREORDERED_LOG,
@@ -437,6 +699,101 @@ func TestValidateTreeForUpdate(t *testing.T) {
 	}
 }
 
+func TestValidateTreeForUpdate_KeyRotation(t *testing.T) {
+	ctx := context.Background()
+
+	rotating := newTree()
+	rotating.KeyRotation = newKeyRotation()
+
+	tests := []struct {
+		desc     string
+		baseTree *trillian.Tree
+		updatefn func(*trillian.Tree)
+		wantErr  bool
+	}{
+		{
+			desc:     "start",
+			baseTree: newTree(),
+			updatefn: func(tree *trillian.Tree) { tree.KeyRotation = newKeyRotation() },
+		},
+		{
+			desc:     "startMismatchedKeys",
+			baseTree: newTree(),
+			updatefn: func(tree *trillian.Tree) {
+				tree.KeyRotation = newKeyRotation()
+				tree.KeyRotation.PublicKey = tree.PublicKey
+			},
+			wantErr: true,
+		},
+		{
+			desc:     "startNoRetireTime",
+			baseTree: newTree(),
+			updatefn: func(tree *trillian.Tree) {
+				tree.KeyRotation = newKeyRotation()
+				tree.KeyRotation.RetireTime = nil
+			},
+			wantErr: true,
+		},
+		{
+			desc:     "retire",
+			baseTree: rotating,
+			updatefn: func(tree *trillian.Tree) {
+				tree.PrivateKey = tree.KeyRotation.PrivateKey
+				tree.PublicKey = tree.KeyRotation.PublicKey
+				tree.KeyRotation = nil
+			},
+		},
+		{
+			desc:     "cancel",
+			baseTree: rotating,
+			updatefn: func(tree *trillian.Tree) { tree.KeyRotation = nil },
+			wantErr:  true,
+		},
+		{
+			desc:     "change",
+			baseTree: rotating,
+			updatefn: func(tree *trillian.Tree) { tree.KeyRotation.RetireTime = ptypes.TimestampNow() },
+			wantErr:  true,
+		},
+		{
+			desc:     "PublicKey",
+			baseTree: newTree(),
+			updatefn: func(tree *trillian.Tree) {
+				tree.PrivateKey = newKeyRotation().PrivateKey
+				tree.PublicKey = newKeyRotation().PublicKey
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		tree := proto.Clone(test.baseTree).(*trillian.Tree)
+		test.updatefn(tree)
+
+		err := ValidateTreeForUpdate(ctx, test.baseTree, tree)
+		switch hasErr := err != nil; {
+		case hasErr != test.wantErr:
+			t.Errorf("%v: ValidateTreeForUpdate() = %v, wantErr = %v", test.desc, err, test.wantErr)
+		case hasErr && status.Code(err) != codes.InvalidArgument:
+			t.Errorf("%v: ValidateTreeForUpdate() = %v, wantCode = %d", test.desc, err, codes.InvalidArgument)
+		}
+	}
+}
+
+// newKeyRotation returns a valid rotation of the key of newTree for tests.
+func newKeyRotation() *trillian.KeyRotation {
+	privateKey, err := ptypes.MarshalAny(&keyspb.PrivateKey{
+		Der: ktestonly.MustMarshalPrivatePEMToDER(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &trillian.KeyRotation{
+		PrivateKey: privateKey,
+		PublicKey:  &keyspb.PublicKey{Der: ktestonly.MustMarshalPublicPEMToDER(testonly.DemoPublicKey)},
+		RetireTime: ptypes.TimestampNow(),
+	}
+}
+
 // newTree returns a valid log tree for tests.
 func newTree() *trillian.Tree {
 	privateKey, err := ptypes.MarshalAny(&keyspb.PEMKeyFile{
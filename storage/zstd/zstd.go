@@ -0,0 +1,60 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package zstd compresses data with Zstandard, for the storage codecs which
+// use it: the Zstd subtree codec and ZSTD map leaf compression.
+package zstd
+
+import (
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	once    sync.Once
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	initErr error
+)
+
+// coders returns the shared encoder and decoder, which are safe for
+// concurrent use by EncodeAll and DecodeAll, creating them on first use.
+func coders() (*zstd.Encoder, *zstd.Decoder, error) {
+	once.Do(func() {
+		if encoder, initErr = zstd.NewWriter(nil); initErr != nil {
+			return
+		}
+		decoder, initErr = zstd.NewReader(nil)
+	})
+	return encoder, decoder, initErr
+}
+
+// Compress appends the compressed src to dst.
+func Compress(dst, src []byte) ([]byte, error) {
+	enc, _, err := coders()
+	if err != nil {
+		return nil, err
+	}
+	return enc.EncodeAll(src, dst), nil
+}
+
+// Decompress returns the decompressed src.
+func Decompress(src []byte) ([]byte, error) {
+	_, dec, err := coders()
+	if err != nil {
+		return nil, err
+	}
+	return dec.DecodeAll(src, nil)
+}
@@ -0,0 +1,42 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zstd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, value := range [][]byte{{}, []byte("v"), bytes.Repeat([]byte("value"), 100)} {
+		data, err := Compress([]byte("prefix"), value)
+		if err != nil {
+			t.Fatalf("Compress(): %v", err)
+		}
+		if !bytes.HasPrefix(data, []byte("prefix")) {
+			t.Errorf("Compress() = %x, want it to append to dst", data)
+		}
+		got, err := Decompress(data[len("prefix"):])
+		if err != nil {
+			t.Fatalf("Decompress(): %v", err)
+		}
+		if !bytes.Equal(got, value) {
+			t.Errorf("Decompress() = %x, want %x", got, value)
+		}
+	}
+	if _, err := Decompress([]byte("not zstd")); err == nil {
+		t.Error("Decompress(garbage) = nil error, want error")
+	}
+}
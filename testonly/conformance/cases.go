@@ -0,0 +1,387 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/hashers"
+)
+
+// mapTreeID is the tree ID of the generated maps.
+const mapTreeID = 12345
+
+// MapShape describes the leaves which are set in a generated map.
+type MapShape struct {
+	Name string
+	// Leaves is the number of leaves which are set.
+	Leaves int
+	// PrefixBits is the number of leading bits shared by the indexes of all
+	// the leaves, and of the absent leaf whose proof is checked. Shared
+	// prefixes make proofs hold more non-empty hashes.
+	PrefixBits int
+}
+
+// Matrix selects the cases to generate.
+type Matrix struct {
+	LogHashStrategies []trillian.HashStrategy
+	MapHashStrategies []trillian.HashStrategy
+	// LogSizes are the sizes of the generated logs.
+	LogSizes []int64
+	// MapShapes are the shapes of the generated maps.
+	MapShapes []MapShape
+	// Seed seeds the choice of map indexes.
+	Seed int64
+}
+
+// DefaultMatrix covers the hash strategies of this repository, logs with
+// both complete and ragged right edges, and maps from empty to clustered.
+var DefaultMatrix = Matrix{
+	LogHashStrategies: []trillian.HashStrategy{trillian.HashStrategy_RFC6962_SHA256},
+	MapHashStrategies: []trillian.HashStrategy{
+		trillian.HashStrategy_TEST_MAP_HASHER,
+		trillian.HashStrategy_CONIKS_SHA512_256,
+		trillian.HashStrategy_CONIKS_SHA256,
+	},
+	LogSizes: []int64{1, 2, 3, 4, 5, 7, 8, 9, 16, 17, 100},
+	MapShapes: []MapShape{
+		{Name: "empty"},
+		{Name: "single", Leaves: 1},
+		{Name: "sparse", Leaves: 16},
+		{Name: "clustered", Leaves: 8, PrefixBits: 240},
+		{Name: "siblings", Leaves: 3, PrefixBits: 254},
+	},
+	Seed: 1,
+}
+
+// Cases returns the cases of the matrix.
+func (m Matrix) Cases() ([]*Case, error) {
+	var cases []*Case
+	for _, s := range m.LogHashStrategies {
+		h, err := hashers.NewLogHasher(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, size := range m.LogSizes {
+			cases = append(cases, logCases(h, s.String(), size)...)
+		}
+	}
+	rnd := rand.New(rand.NewSource(m.Seed))
+	for _, s := range m.MapHashStrategies {
+		h, err := hashers.NewMapHasher(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, shape := range m.MapShapes {
+			c, err := mapCases(h, s.String(), shape, rnd)
+			if err != nil {
+				return nil, err
+			}
+			cases = append(cases, c...)
+		}
+	}
+	return cases, nil
+}
+
+// logCases returns the cases of a log of the given size.
+func logCases(h hashers.LogHasher, strategy string, size int64) []*Case {
+	tree := merkle.NewInMemoryMerkleTree(h)
+	for i := int64(0); i < size; i++ {
+		tree.AddLeaf(logLeafData(i))
+	}
+	root := tree.RootAtSnapshot(size).Hash()
+
+	var cases []*Case
+	for _, i := range distinct(0, size/2, size-1) {
+		cases = append(cases, withTampered(&Case{
+			Name:         fmt.Sprintf("%s/%s/size=%d/leaf=%d", LogInclusion, strategy, size, i),
+			ProofType:    LogInclusion,
+			HashStrategy: strategy,
+			TreeSize:     size,
+			LeafIndex:    i,
+			LeafData:     logLeafData(i),
+			Root:         root,
+			Proof:        rawProof(tree.PathToRootAtSnapshot(i+1, size)),
+			Valid:        true,
+		})...)
+	}
+	// Any tree is consistent with the empty tree, whatever the roots, so
+	// there is nothing to tamper with at size 0.
+	for _, first := range distinct(1, size/2, size-1, size) {
+		if first < 1 {
+			continue
+		}
+		cases = append(cases, withTampered(&Case{
+			Name:          fmt.Sprintf("%s/%s/size=%d/first=%d", LogConsistency, strategy, size, first),
+			ProofType:     LogConsistency,
+			HashStrategy:  strategy,
+			TreeSize:      size,
+			FirstTreeSize: first,
+			FirstRoot:     tree.RootAtSnapshot(first).Hash(),
+			Root:          root,
+			Proof:         rawProof(tree.SnapshotConsistency(first, size)),
+			Valid:         true,
+		})...)
+	}
+	return cases
+}
+
+func logLeafData(i int64) []byte {
+	return []byte(fmt.Sprintf("leaf %d", i))
+}
+
+// distinct returns the distinct values of v, in order.
+func distinct(v ...int64) []int64 {
+	var ret []int64
+	seen := make(map[int64]bool)
+	for _, i := range v {
+		if !seen[i] {
+			seen[i] = true
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
+func rawProof(path []merkle.TreeEntryDescriptor) [][]byte {
+	proof := make([][]byte, 0, len(path))
+	for _, d := range path {
+		proof = append(proof, d.Value.Hash())
+	}
+	return proof
+}
+
+// mapCases returns the cases of a map of the given shape.
+func mapCases(h hashers.MapHasher, strategy string, shape MapShape, rnd *rand.Rand) ([]*Case, error) {
+	bitLen := h.BitLen()
+	if shape.PrefixBits < 0 || shape.PrefixBits >= bitLen {
+		return nil, fmt.Errorf("map shape %s: prefix of %d bits, want fewer than %d", shape.Name, shape.PrefixBits, bitLen)
+	}
+	// One more index than the leaves is needed, for the absent leaf.
+	if free := uint(bitLen - shape.PrefixBits); free < 63 && int64(shape.Leaves) >= int64(1)<<free {
+		return nil, fmt.Errorf("map shape %s: %d leaves do not fit after a prefix of %d bits", shape.Name, shape.Leaves, shape.PrefixBits)
+	}
+
+	prefix := randomBytes(rnd, hashers.IndexSize(h))
+	t := &sparseTree{h: h, leaves: make(map[string][]byte)}
+	values := make(map[string][]byte)
+	for len(values) <= shape.Leaves {
+		index := randomBytes(rnd, hashers.IndexSize(h))
+		copyBits(index, prefix, shape.PrefixBits)
+		values[string(index)] = []byte(fmt.Sprintf("value %x", index))
+	}
+	indexes := make([][]byte, 0, len(values))
+	for k := range values {
+		indexes = append(indexes, []byte(k))
+	}
+	sort.Slice(indexes, func(i, j int) bool { return bytes.Compare(indexes[i], indexes[j]) < 0 })
+	// The first index is left unset, so that its proof shows it is absent.
+	absent := indexes[0]
+	for _, index := range indexes[1:] {
+		t.leaves[string(index)] = h.HashLeaf(mapTreeID, index, values[string(index)])
+	}
+	root := t.root()
+
+	proved := append([][]byte{absent}, indexes[1:]...)
+	if len(proved) > 3 {
+		proved = proved[:3]
+	}
+	var cases []*Case
+	for _, index := range proved {
+		var value []byte
+		if !bytes.Equal(index, absent) {
+			value = values[string(index)]
+		}
+		cases = append(cases, withTampered(&Case{
+			Name:         fmt.Sprintf("%s/%s/%s/index=%x", MapInclusion, strategy, shape.Name, index),
+			ProofType:    MapInclusion,
+			HashStrategy: strategy,
+			TreeID:       mapTreeID,
+			Index:        index,
+			LeafValue:    value,
+			Root:         root,
+			Proof:        t.proof(index),
+			Valid:        true,
+		})...)
+	}
+	return cases, nil
+}
+
+func randomBytes(rnd *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	rnd.Read(b)
+	return b
+}
+
+// copyBits copies the first n bits of src to dst.
+func copyBits(dst, src []byte, n int) {
+	for i := 0; i < n; i++ {
+		setBit(dst, i, bit(src, i))
+	}
+}
+
+// bit returns whether the bit of index at depth, counted from the most
+// significant bit, is set.
+func bit(index []byte, depth int) bool {
+	return index[depth/8]&(0x80>>uint(depth%8)) != 0
+}
+
+func setBit(index []byte, depth int, set bool) {
+	mask := byte(0x80 >> uint(depth%8))
+	if set {
+		index[depth/8] |= mask
+	} else {
+		index[depth/8] &^= mask
+	}
+}
+
+// sparseTree computes the hashes of a sparse Merkle tree held in memory. It
+// is independent of the map storage and its caches, to serve as a reference.
+type sparseTree struct {
+	h hashers.MapHasher
+	// leaves holds the leaf hashes of the set leaves, keyed by index.
+	leaves map[string][]byte
+}
+
+// root returns the root hash of the tree.
+func (t *sparseTree) root() []byte {
+	path := make([]byte, hashers.IndexSize(t.h))
+	if hash := t.hash(path, 0, t.indexes()); hash != nil {
+		return hash
+	}
+	return t.h.HashEmpty(mapTreeID, path, t.h.BitLen())
+}
+
+// proof returns the inclusion proof of index, ordered from the leaf to the
+// root, with nil hashes for empty subtrees.
+func (t *sparseTree) proof(index []byte) [][]byte {
+	bitLen := t.h.BitLen()
+	proof := make([][]byte, bitLen)
+	indexes := t.indexes()
+	for depth := 0; depth < bitLen; depth++ {
+		var same, other [][]byte
+		for _, i := range indexes {
+			if bit(i, depth) == bit(index, depth) {
+				same = append(same, i)
+			} else {
+				other = append(other, i)
+			}
+		}
+		sibling := t.path(index, depth)
+		setBit(sibling, depth, !bit(index, depth))
+		proof[bitLen-depth-1] = t.hash(sibling, depth+1, other)
+		indexes = same
+	}
+	return proof
+}
+
+func (t *sparseTree) indexes() [][]byte {
+	indexes := make([][]byte, 0, len(t.leaves))
+	for k := range t.leaves {
+		indexes = append(indexes, []byte(k))
+	}
+	return indexes
+}
+
+// path returns the first depth bits of index, padded with zeros.
+func (t *sparseTree) path(index []byte, depth int) []byte {
+	path := make([]byte, len(index))
+	copyBits(path, index, depth)
+	return path
+}
+
+// hash returns the hash of the subtree at depth whose leftmost index is path,
+// and which holds the leaves at indexes, or nil if it is empty.
+func (t *sparseTree) hash(path []byte, depth int, indexes [][]byte) []byte {
+	if len(indexes) == 0 {
+		return nil
+	}
+	bitLen := t.h.BitLen()
+	if depth == bitLen {
+		return t.leaves[string(indexes[0])]
+	}
+	var left, right [][]byte
+	for _, i := range indexes {
+		if bit(i, depth) {
+			right = append(right, i)
+		} else {
+			left = append(left, i)
+		}
+	}
+	leftPath := t.path(path, depth+1)
+	rightPath := t.path(path, depth+1)
+	setBit(rightPath, depth, true)
+	height := bitLen - depth - 1
+	l := t.hash(leftPath, depth+1, left)
+	if l == nil {
+		l = t.h.HashEmpty(mapTreeID, leftPath, height)
+	}
+	r := t.hash(rightPath, depth+1, right)
+	if r == nil {
+		r = t.h.HashEmpty(mapTreeID, rightPath, height)
+	}
+	return t.h.HashChildren(l, r)
+}
+
+// withTampered returns c, followed by copies of c which are tampered with in
+// ways which must make verifiers reject them.
+func withTampered(c *Case) []*Case {
+	cases := []*Case{c}
+	tampered := func(name string, f func(t *Case)) {
+		t := *c
+		t.Name = c.Name + "/" + name
+		t.Valid = false
+		t.Proof = append([][]byte(nil), c.Proof...)
+		f(&t)
+		cases = append(cases, &t)
+	}
+
+	tampered("wrong_root", func(t *Case) { t.Root = flipped(c.Root) })
+	if c.ProofType == LogConsistency {
+		tampered("wrong_first_root", func(t *Case) { t.FirstRoot = flipped(c.FirstRoot) })
+	} else {
+		tampered("wrong_leaf", func(t *Case) {
+			if c.ProofType == LogInclusion {
+				t.LeafData = append(append([]byte(nil), c.LeafData...), 'x')
+			} else {
+				t.LeafValue = append(append([]byte(nil), c.LeafValue...), 'x')
+			}
+		})
+	}
+	for i, hash := range c.Proof {
+		if len(hash) > 0 {
+			i := i
+			tampered("flipped_proof", func(t *Case) { t.Proof[i] = flipped(c.Proof[i]) })
+			break
+		}
+	}
+	if len(c.Proof) > 0 {
+		tampered("truncated_proof", func(t *Case) { t.Proof = t.Proof[:len(t.Proof)-1] })
+	}
+	tampered("extended_proof", func(t *Case) { t.Proof = append(t.Proof, make([]byte, len(c.Root))) })
+	return cases
+}
+
+// flipped returns a copy of b with its last bit flipped.
+func flipped(b []byte) []byte {
+	f := append([]byte(nil), b...)
+	f[len(f)-1] ^= 1
+	return f
+}
@@ -0,0 +1,185 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conformance checks that proof verifiers, including ones outside
+// this repository, agree on which Trillian proofs are valid.
+//
+// A Matrix generates cases for each combination of hash strategy, tree shape
+// and proof type. Each valid proof is accompanied by tampered copies, which
+// must be rejected. Check runs the cases against a set of verifiers, and
+// reports each case on which a verifier diverges from the expected verdict.
+package conformance
+
+import (
+	"fmt"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/merkle/hashers"
+)
+
+// ProofType is the type of proof in a Case.
+type ProofType string
+
+// Proof types.
+const (
+	LogInclusion   ProofType = "LOG_INCLUSION"
+	LogConsistency ProofType = "LOG_CONSISTENCY"
+	MapInclusion   ProofType = "MAP_INCLUSION"
+)
+
+// Case is a proof, and the data it is checked against, which a verifier must
+// either accept or reject. Cases are sent to external verifiers as JSON, in
+// which byte strings are base64 encoded, and empty ones may be null.
+type Case struct {
+	// Name identifies the case in reports.
+	Name      string    `json:"name"`
+	ProofType ProofType `json:"proof_type"`
+	// HashStrategy is the name of the trillian.HashStrategy of the tree.
+	HashStrategy string `json:"hash_strategy"`
+	// TreeID is the ID of the tree, which some map hashers include in hashes.
+	TreeID int64 `json:"tree_id,omitempty"`
+
+	// TreeSize is the size of the tree which Root is the root of, for log
+	// proofs.
+	TreeSize int64 `json:"tree_size,omitempty"`
+	// LeafIndex is the index of the leaf of a log inclusion proof, and
+	// LeafData is its data, which the verifier must hash.
+	LeafIndex int64  `json:"leaf_index,omitempty"`
+	LeafData  []byte `json:"leaf_data,omitempty"`
+	// FirstTreeSize and FirstRoot are the size and root of the earlier tree
+	// of a log consistency proof.
+	FirstTreeSize int64  `json:"first_tree_size,omitempty"`
+	FirstRoot     []byte `json:"first_root,omitempty"`
+	// Index is the index of the leaf of a map inclusion proof, and LeafValue
+	// is its value, which is empty if the proof shows the leaf is not set.
+	Index     []byte `json:"index,omitempty"`
+	LeafValue []byte `json:"leaf_value,omitempty"`
+
+	// Root is the root hash which the proof must lead to.
+	Root []byte `json:"root"`
+	// Proof holds the hashes of the proof, in the order of the Trillian API.
+	// Empty hashes of map inclusion proofs stand for empty subtrees.
+	Proof [][]byte `json:"proof"`
+
+	// Valid is whether the proof must be accepted. It is not sent to
+	// external verifiers.
+	Valid bool `json:"-"`
+}
+
+// Verdict is the decision of a verifier on a Case.
+type Verdict struct {
+	// Valid is whether the verifier accepted the proof.
+	Valid bool `json:"valid"`
+	// Reason optionally explains why the proof was rejected.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Verifier decides on cases.
+type Verifier interface {
+	// Verify returns the verdict on c. An error means no verdict could be
+	// reached, e.g. because an external verifier failed, and is not a
+	// rejection of the proof.
+	Verify(c *Case) (Verdict, error)
+}
+
+// NamedVerifier is a Verifier with a name to identify it in reports.
+type NamedVerifier struct {
+	Name string
+	Verifier
+}
+
+// Divergence is a case on which a verifier did not reach the expected verdict.
+type Divergence struct {
+	Verifier string
+	Case     *Case
+	Got      Verdict
+}
+
+func (d Divergence) String() string {
+	if d.Case.Valid {
+		return fmt.Sprintf("%s: rejected valid case %s: %s", d.Verifier, d.Case.Name, d.Got.Reason)
+	}
+	return fmt.Sprintf("%s: accepted invalid case %s", d.Verifier, d.Case.Name)
+}
+
+// Check runs every case against every verifier, and returns the cases on
+// which verifiers diverged from the expected verdicts.
+func Check(cases []*Case, verifiers []NamedVerifier) ([]Divergence, error) {
+	var divs []Divergence
+	for _, v := range verifiers {
+		for _, c := range cases {
+			got, err := v.Verify(c)
+			if err != nil {
+				return nil, fmt.Errorf("%s: case %s: %v", v.Name, c.Name, err)
+			}
+			if got.Valid != c.Valid {
+				divs = append(divs, Divergence{Verifier: v.Name, Case: c, Got: got})
+			}
+		}
+	}
+	return divs, nil
+}
+
+// InTree is a Verifier which uses the verifiers of the merkle package, with
+// the hashers registered in the hashers package.
+type InTree struct{}
+
+// Verify implements Verifier.
+func (InTree) Verify(c *Case) (Verdict, error) {
+	strategy, err := parseHashStrategy(c.HashStrategy)
+	if err != nil {
+		return Verdict{}, err
+	}
+	switch c.ProofType {
+	case LogInclusion, LogConsistency:
+		h, err := hashers.NewLogHasher(strategy)
+		if err != nil {
+			return Verdict{}, err
+		}
+		v := merkle.NewLogVerifier(h)
+		if c.ProofType == LogInclusion {
+			err = v.VerifyInclusionProof(c.LeafIndex, c.TreeSize, c.Proof, c.Root, h.HashLeaf(c.LeafData))
+		} else {
+			err = v.VerifyConsistencyProof(c.FirstTreeSize, c.TreeSize, c.FirstRoot, c.Root, c.Proof)
+		}
+		return verdict(err), nil
+	case MapInclusion:
+		h, err := hashers.NewMapHasher(strategy)
+		if err != nil {
+			return Verdict{}, err
+		}
+		leaf := &trillian.MapLeaf{Index: c.Index, LeafValue: c.LeafValue}
+		return verdict(merkle.VerifyMapInclusionProof(c.TreeID, leaf, c.Root, c.Proof, h)), nil
+	default:
+		return Verdict{}, fmt.Errorf("unknown proof type %q", c.ProofType)
+	}
+}
+
+// verdict returns the verdict for the result of an in-tree verifier.
+func verdict(err error) Verdict {
+	if err != nil {
+		return Verdict{Reason: err.Error()}
+	}
+	return Verdict{Valid: true}
+}
+
+// parseHashStrategy returns the hash strategy with the given name.
+func parseHashStrategy(name string) (trillian.HashStrategy, error) {
+	v, ok := trillian.HashStrategy_value[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown hash strategy %q", name)
+	}
+	return trillian.HashStrategy(v), nil
+}
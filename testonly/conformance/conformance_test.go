@@ -0,0 +1,92 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"io"
+	"testing"
+
+	_ "github.com/google/trillian/merkle/coniks"    // register CONIKS_SHA512_256 and CONIKS_SHA256
+	_ "github.com/google/trillian/merkle/maphasher" // register TEST_MAP_HASHER
+	_ "github.com/google/trillian/merkle/rfc6962"   // register RFC6962_SHA256
+)
+
+// acceptAll is a broken Verifier which accepts every proof.
+type acceptAll struct{}
+
+func (acceptAll) Verify(c *Case) (Verdict, error) {
+	return Verdict{Valid: true}, nil
+}
+
+// serve returns a Stream to v, served in the background until the returned
+// closer is closed.
+func serve(v Verifier) (*Stream, io.Closer) {
+	caseR, caseW := io.Pipe()
+	verdictR, verdictW := io.Pipe()
+	go func() {
+		err := Serve(caseR, verdictW, v)
+		verdictW.CloseWithError(err)
+	}()
+	return NewStream(caseW, verdictR), caseW
+}
+
+func TestCheck(t *testing.T) {
+	cases, err := DefaultMatrix.Cases()
+	if err != nil {
+		t.Fatalf("Cases(): %v", err)
+	}
+	valid := 0
+	types := make(map[ProofType]bool)
+	for _, c := range cases {
+		if c.Valid {
+			valid++
+		}
+		types[c.ProofType] = true
+	}
+	if valid == 0 || valid == len(cases) {
+		t.Fatalf("Cases(): %d of %d cases valid, want some valid and some invalid", valid, len(cases))
+	}
+	if got, want := len(types), 3; got != want {
+		t.Errorf("Cases(): %d proof types, want %d", got, want)
+	}
+
+	served, closer := serve(InTree{})
+	defer closer.Close()
+	broken, closer := serve(acceptAll{})
+	defer closer.Close()
+
+	for _, test := range []struct {
+		desc     string
+		verifier Verifier
+		wantDivs int
+	}{
+		{desc: "in-tree", verifier: InTree{}},
+		{desc: "served in-tree", verifier: served},
+		{desc: "broken", verifier: broken, wantDivs: len(cases) - valid},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			divs, err := Check(cases, []NamedVerifier{{Name: test.desc, Verifier: test.verifier}})
+			if err != nil {
+				t.Fatalf("Check(): %v", err)
+			}
+			if got, want := len(divs), test.wantDivs; got != want {
+				for _, d := range divs {
+					t.Log(d)
+				}
+				t.Errorf("Check(): %d divergences, want %d", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// Stream is a Verifier which sends each case to a verifier over a stream, as
+// a line of JSON, and reads back its Verdict as a line of JSON. Serve
+// implements the other end of the protocol.
+type Stream struct {
+	enc *json.Encoder
+	dec *json.Decoder
+}
+
+// NewStream returns a Stream which sends cases to w and reads verdicts from r.
+func NewStream(w io.Writer, r io.Reader) *Stream {
+	return &Stream{enc: json.NewEncoder(w), dec: json.NewDecoder(r)}
+}
+
+// Verify implements Verifier.
+func (s *Stream) Verify(c *Case) (Verdict, error) {
+	if err := s.enc.Encode(c); err != nil {
+		return Verdict{}, fmt.Errorf("failed to send case: %v", err)
+	}
+	var v Verdict
+	if err := s.dec.Decode(&v); err != nil {
+		return Verdict{}, fmt.Errorf("failed to read verdict: %v", err)
+	}
+	return v, nil
+}
+
+// Serve reads cases from r until it ends, and writes the verdict of v on each
+// to w, as the other end of a Stream. It allows a verifier to be tested by
+// wrapping it in a binary which serves it on its standard input and output.
+func Serve(r io.Reader, w io.Writer, v Verifier) error {
+	dec := json.NewDecoder(r)
+	enc := json.NewEncoder(w)
+	for {
+		var c Case
+		if err := dec.Decode(&c); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		verdict, err := v.Verify(&c)
+		if err != nil {
+			return fmt.Errorf("case %s: %v", c.Name, err)
+		}
+		if err := enc.Encode(verdict); err != nil {
+			return err
+		}
+	}
+}
+
+// External is a Stream to an external verifier binary, which reads cases from
+// its standard input and writes verdicts to its standard output.
+type External struct {
+	*Stream
+	cmd   *exec.Cmd
+	stdin io.Closer
+}
+
+// StartExternal starts the verifier binary at path with args. The binary's
+// standard error is passed through, for its diagnostics.
+func StartExternal(path string, args ...string) (*External, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %v", path, err)
+	}
+	return &External{Stream: NewStream(stdin, stdout), cmd: cmd, stdin: stdin}, nil
+}
+
+// Close ends the standard input of the binary, and waits for it to exit.
+func (e *External) Close() error {
+	if err := e.stdin.Close(); err != nil {
+		return err
+	}
+	return e.cmd.Wait()
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// proofconformance checks that the in-tree proof verifiers, and external
+// verifier binaries, agree on a matrix of valid and tampered proofs.
+//
+// External verifiers read one JSON encoded conformance.Case per line from
+// their standard input, and write one JSON encoded conformance.Verdict per
+// line to their standard output, in the same order. With --serve, this binary
+// is such a verifier itself, backed by the in-tree verifiers.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/testonly/conformance"
+
+	_ "github.com/google/trillian/merkle/coniks"    // register CONIKS_SHA512_256 and CONIKS_SHA256
+	_ "github.com/google/trillian/merkle/maphasher" // register TEST_MAP_HASHER
+	_ "github.com/google/trillian/merkle/rfc6962"   // register RFC6962_SHA256
+)
+
+var (
+	verifiers         = flag.String("verifiers", "", "Comma-separated list of external verifier commands, each a binary path optionally followed by space-separated arguments")
+	logHashStrategies = flag.String("log_hash_strategies", "RFC6962_SHA256", "Comma-separated list of log hash strategies to test")
+	mapHashStrategies = flag.String("map_hash_strategies", "TEST_MAP_HASHER,CONIKS_SHA512_256,CONIKS_SHA256", "Comma-separated list of map hash strategies to test")
+	logSizes          = flag.String("log_sizes", "1,2,3,4,5,7,8,9,16,17,100", "Comma-separated list of log sizes to test")
+	seed              = flag.Int64("seed", 1, "Seed for the choice of map indexes")
+	serve             = flag.Bool("serve", false, "Serve the in-tree verifiers on stdin and stdout, as an external verifier, instead of checking")
+)
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	if *serve {
+		if err := conformance.Serve(os.Stdin, os.Stdout, conformance.InTree{}); err != nil {
+			glog.Exitf("Serve(): %v", err)
+		}
+		return
+	}
+
+	m := conformance.DefaultMatrix
+	m.Seed = *seed
+	var err error
+	if m.LogHashStrategies, err = parseHashStrategies(*logHashStrategies); err != nil {
+		glog.Exitf("Invalid --log_hash_strategies: %v", err)
+	}
+	if m.MapHashStrategies, err = parseHashStrategies(*mapHashStrategies); err != nil {
+		glog.Exitf("Invalid --map_hash_strategies: %v", err)
+	}
+	if m.LogSizes, err = parseSizes(*logSizes); err != nil {
+		glog.Exitf("Invalid --log_sizes: %v", err)
+	}
+	cases, err := m.Cases()
+	if err != nil {
+		glog.Exitf("Failed to generate cases: %v", err)
+	}
+
+	vs := []conformance.NamedVerifier{{Name: "in-tree", Verifier: conformance.InTree{}}}
+	for _, cmd := range split(*verifiers) {
+		args := strings.Fields(cmd)
+		e, err := conformance.StartExternal(args[0], args[1:]...)
+		if err != nil {
+			glog.Exitf("Failed to start verifier: %v", err)
+		}
+		defer e.Close()
+		vs = append(vs, conformance.NamedVerifier{Name: cmd, Verifier: e})
+	}
+
+	divs, err := conformance.Check(cases, vs)
+	if err != nil {
+		glog.Exitf("Check(): %v", err)
+	}
+	for _, d := range divs {
+		fmt.Println(d)
+	}
+	fmt.Printf("%d cases, %d verifiers, %d divergences\n", len(cases), len(vs), len(divs))
+	if len(divs) > 0 {
+		glog.Flush()
+		os.Exit(1)
+	}
+}
+
+// split returns the non-empty elements of the comma-separated list s.
+func split(s string) []string {
+	var ret []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+func parseHashStrategies(s string) ([]trillian.HashStrategy, error) {
+	var ret []trillian.HashStrategy
+	for _, name := range split(s) {
+		v, ok := trillian.HashStrategy_value[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown hash strategy %q", name)
+		}
+		ret = append(ret, trillian.HashStrategy(v))
+	}
+	return ret, nil
+}
+
+func parseSizes(s string) ([]int64, error) {
+	var ret []int64
+	for _, e := range split(s) {
+		size, err := strconv.ParseInt(e, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size < 1 {
+			return nil, fmt.Errorf("log size %d, want > 0", size)
+		}
+		ret = append(ret, size)
+	}
+	return ret, nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package datagen generates map indices and leaf values with the skewed
+// distributions seen in production maps, such as hot keys, clustered index
+// prefixes and occasional large values, for load tests and benchmarks.
+//
+// Uniformly random indices spread writes evenly over the tree, which hides
+// the subtree cache and hot spot behaviour of real workloads.
+package datagen
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+)
+
+// KeyDistribution chooses the indices of map leaves.
+type KeyDistribution interface {
+	// Index returns a new index, using r as the source of randomness, so that
+	// the same sequence of indices can be generated again from the same seed.
+	Index(r *rand.Rand) []byte
+}
+
+// Uniform chooses indices of Size bytes uniformly at random.
+type Uniform struct {
+	Size int
+}
+
+// Index implements KeyDistribution.
+func (u Uniform) Index(r *rand.Rand) []byte {
+	index := make([]byte, u.Size)
+	r.Read(index)
+	return index
+}
+
+// Zipf chooses among Keys indices of Size bytes following a Zipf
+// distribution, so that a few hot keys are chosen most of the time. The
+// probability of the k-th most popular key is proportional to 1/(k+1)^S.
+// The hot keys are spread over the map, as if their indices were hashed.
+type Zipf struct {
+	Size int
+	Keys uint64
+	S    float64
+}
+
+// NewZipf returns a Zipf distribution over keys indices of size bytes, with
+// exponent s, which must be greater than 1.
+func NewZipf(size int, keys uint64, s float64) (*Zipf, error) {
+	if keys == 0 {
+		return nil, fmt.Errorf("datagen: Zipf needs at least one key")
+	}
+	if s <= 1 {
+		return nil, fmt.Errorf("datagen: Zipf exponent must be > 1, got %v", s)
+	}
+	return &Zipf{Size: size, Keys: keys, S: s}, nil
+}
+
+// Index implements KeyDistribution.
+func (z *Zipf) Index(r *rand.Rand) []byte {
+	k := rand.NewZipf(r, z.S, 1, z.Keys-1).Uint64()
+	return expand(z.Size, "zipf", k)
+}
+
+// Clustered chooses indices of Size bytes which start with one of Clusters
+// fixed prefixes of PrefixBits bits, chosen uniformly, followed by random
+// bits. Such indices share the subtrees below their prefix, so writes
+// concentrate on a few paths of the tree.
+type Clustered struct {
+	Size       int
+	Clusters   int
+	PrefixBits int
+}
+
+// NewClustered returns a Clustered distribution of indices of size bytes in
+// clusters groups, each sharing a prefix of prefixBits bits.
+func NewClustered(size, clusters, prefixBits int) (*Clustered, error) {
+	if clusters <= 0 {
+		return nil, fmt.Errorf("datagen: need at least one cluster, got %d", clusters)
+	}
+	if prefixBits <= 0 || prefixBits > size*8 {
+		return nil, fmt.Errorf("datagen: prefix of %d bits does not fit in %d byte indices", prefixBits, size)
+	}
+	return &Clustered{Size: size, Clusters: clusters, PrefixBits: prefixBits}, nil
+}
+
+// Index implements KeyDistribution.
+func (c *Clustered) Index(r *rand.Rand) []byte {
+	prefix := expand(c.Size, "cluster", uint64(r.Intn(c.Clusters)))
+	index := make([]byte, c.Size)
+	r.Read(index)
+	full, partial := c.PrefixBits/8, uint(c.PrefixBits%8)
+	copy(index, prefix[:full])
+	if partial > 0 {
+		mask := byte(0xff << (8 - partial))
+		index[full] = prefix[full]&mask | index[full]&^mask
+	}
+	return index
+}
+
+// expand deterministically derives size pseudo-random bytes from label and n.
+func expand(size int, label string, n uint64) []byte {
+	out := make([]byte, 0, size+sha256.Size)
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+	for block := byte(0); len(out) < size; block++ {
+		h := sha256.New()
+		h.Write([]byte(label))
+		h.Write(buf[:])
+		h.Write([]byte{block})
+		out = h.Sum(out)
+	}
+	return out[:size]
+}
+
+// ValueDistribution chooses the sizes of leaf values.
+type ValueDistribution interface {
+	// Size returns the size of a new value, using r as the source of
+	// randomness.
+	Size(r *rand.Rand) int
+}
+
+// FixedSize makes all values the same size.
+type FixedSize int
+
+// Size implements ValueDistribution.
+func (f FixedSize) Size(*rand.Rand) int {
+	return int(f)
+}
+
+// LargeValues makes most values SmallSize bytes long, and one in LargeChance
+// values LargeSize bytes long, like maps which hold the occasional large
+// document.
+type LargeValues struct {
+	SmallSize   int
+	LargeSize   int
+	LargeChance int
+}
+
+// Size implements ValueDistribution.
+func (l LargeValues) Size(r *rand.Rand) int {
+	if l.LargeChance > 0 && r.Intn(l.LargeChance) == 0 {
+		return l.LargeSize
+	}
+	return l.SmallSize
+}
+
+// Value returns a value with a size chosen by d, filled with random bytes.
+func Value(d ValueDistribution, r *rand.Rand) []byte {
+	value := make([]byte, d.Size(r))
+	r.Read(value)
+	return value
+}
+
+// Indices returns n indices chosen by d.
+func Indices(d KeyDistribution, r *rand.Rand, n int) [][]byte {
+	indices := make([][]byte, n)
+	for i := range indices {
+		indices[i] = d.Index(r)
+	}
+	return indices
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datagen
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestDeterministic(t *testing.T) {
+	zipf, err := NewZipf(32, 1000, 1.1)
+	if err != nil {
+		t.Fatalf("NewZipf(): %v", err)
+	}
+	clustered, err := NewClustered(32, 4, 12)
+	if err != nil {
+		t.Fatalf("NewClustered(): %v", err)
+	}
+	for _, d := range []KeyDistribution{Uniform{Size: 32}, zipf, clustered} {
+		a := Indices(d, rand.New(rand.NewSource(1)), 100)
+		b := Indices(d, rand.New(rand.NewSource(1)), 100)
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%T: indices from the same seed differ", d)
+		}
+		for _, index := range a {
+			if got, want := len(index), 32; got != want {
+				t.Fatalf("%T: len(index)=%d, want %d", d, got, want)
+			}
+		}
+	}
+}
+
+func TestZipf(t *testing.T) {
+	for _, test := range []struct {
+		keys uint64
+		s    float64
+	}{
+		{keys: 0, s: 1.1},
+		{keys: 10, s: 1},
+	} {
+		if _, err := NewZipf(32, test.keys, test.s); err == nil {
+			t.Errorf("NewZipf(32, %d, %v) succeeded, want error", test.keys, test.s)
+		}
+	}
+
+	const keys, draws = 10000, 10000
+	z, err := NewZipf(32, keys, 1.5)
+	if err != nil {
+		t.Fatalf("NewZipf(): %v", err)
+	}
+	counts := make(map[string]int)
+	for _, index := range Indices(z, rand.New(rand.NewSource(1)), draws) {
+		counts[string(index)]++
+	}
+	// With a uniform distribution there would be about 6300 distinct keys,
+	// each chosen about once.
+	if got, max := len(counts), draws/4; got > max {
+		t.Errorf("%d distinct keys in %d draws, want at most %d", got, draws, max)
+	}
+	hottest := 0
+	for _, c := range counts {
+		if c > hottest {
+			hottest = c
+		}
+	}
+	if min := draws / 4; hottest < min {
+		t.Errorf("hottest key chosen %d times, want at least %d", hottest, min)
+	}
+}
+
+func TestClustered(t *testing.T) {
+	for _, test := range []struct {
+		clusters, prefixBits int
+	}{
+		{clusters: 0, prefixBits: 8},
+		{clusters: 1, prefixBits: 0},
+		{clusters: 1, prefixBits: 257},
+	} {
+		if _, err := NewClustered(32, test.clusters, test.prefixBits); err == nil {
+			t.Errorf("NewClustered(32, %d, %d) succeeded, want error", test.clusters, test.prefixBits)
+		}
+	}
+
+	c, err := NewClustered(32, 3, 12)
+	if err != nil {
+		t.Fatalf("NewClustered(): %v", err)
+	}
+	prefixes := make(map[[2]byte]bool)
+	for _, index := range Indices(c, rand.New(rand.NewSource(1)), 1000) {
+		prefixes[[2]byte{index[0], index[1] & 0xf0}] = true
+	}
+	if got, want := len(prefixes), 3; got != want {
+		t.Errorf("indices have %d distinct 12-bit prefixes, want %d", got, want)
+	}
+}
+
+func TestLargeValues(t *testing.T) {
+	d := LargeValues{SmallSize: 10, LargeSize: 1000, LargeChance: 10}
+	r := rand.New(rand.NewSource(1))
+	large := 0
+	for i := 0; i < 1000; i++ {
+		switch v := Value(d, r); len(v) {
+		case 10:
+		case 1000:
+			large++
+		default:
+			t.Fatalf("Value() returned %d bytes, want 10 or 1000", len(v))
+		}
+	}
+	if large < 50 || large > 150 {
+		t.Errorf("%d of 1000 values are large, want about 100", large)
+	}
+	if v := Value(FixedSize(5), r); len(v) != 5 || bytes.Equal(v, make([]byte, 5)) {
+		t.Errorf("Value(FixedSize(5))=%x, want 5 random bytes", v)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package boltstore provides a testonly.MapContentsStore backed by a BoltDB
+// file, so that the hammer can track the expected contents of large maps
+// without holding them in memory, and resume after a restart.
+package boltstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/testonly"
+
+	bolt "github.com/coreos/bbolt"
+)
+
+var (
+	// revisionsBucket maps each stored revision to the number of keys set
+	// at or before it.
+	revisionsBucket = []byte("revisions")
+	// keysBucket maps the sequence number of each key, in the order they
+	// were first set, to the key.
+	keysBucket = []byte("keys")
+	// valuesBucket maps each key followed by a revision to the value the key
+	// was set to at that revision.
+	valuesBucket = []byte("values")
+)
+
+// Store holds the contents of a single map at all the revisions it is updated
+// to. Each value is stored once, at the revision it is set.
+type Store struct {
+	db *bolt.DB
+}
+
+var _ testonly.MapContentsStore = &Store{}
+
+// Open opens the store in the file at path, creating it if needed.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %q: %v", path, err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{revisionsBucket, keysBucket, valuesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create buckets in %q: %v", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Revisions returns up to n of the most recent revisions stored, most recent
+// first.
+func (s *Store) Revisions(n int) ([]int64, error) {
+	var revs []int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(revisionsBucket).Cursor()
+		for k, _ := c.Last(); k != nil && len(revs) < n; k, _ = c.Prev() {
+			revs = append(revs, decode(k))
+		}
+		return nil
+	})
+	return revs, err
+}
+
+// Update stores the contents of the map at revision rev as the contents at
+// the latest revision updated with the values of leaves.
+func (s *Store) Update(rev int64, leaves []*trillian.MapLeaf) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		revisions, keys, values := tx.Bucket(revisionsBucket), tx.Bucket(keysBucket), tx.Bucket(valuesBucket)
+		var count int64
+		if k, v := revisions.Cursor().Last(); k != nil {
+			if latest := decode(k); rev <= latest {
+				return fmt.Errorf("revision %d not after stored revision %d", rev, latest)
+			}
+			count = decode(v)
+		}
+		for _, leaf := range leaves {
+			if _, ok := get(values, rev, leaf.Index); !ok {
+				if err := keys.Put(encode(count), leaf.Index); err != nil {
+					return err
+				}
+				count++
+			}
+			if err := values.Put(valueKey(leaf.Index, rev), leaf.LeafValue); err != nil {
+				return err
+			}
+		}
+		return revisions.Put(encode(rev), encode(count))
+	})
+}
+
+// Get returns the value of the leaf at index at revision rev, and whether it
+// had been set by then.
+func (s *Store) Get(rev int64, index []byte) ([]byte, bool, error) {
+	var value []byte
+	var ok bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value, ok = get(tx.Bucket(valuesBucket), rev, index)
+		return nil
+	})
+	return value, ok, err
+}
+
+// KeyCount returns the number of leaves set at or before revision rev.
+func (s *Store) KeyCount(rev int64) (int, error) {
+	var count int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		count = keyCount(tx.Bucket(revisionsBucket), rev)
+		return nil
+	})
+	return count, err
+}
+
+// Key returns the index of the i-th leaf to be set.
+func (s *Store) Key(i int) ([]byte, error) {
+	var index []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		k := tx.Bucket(keysBucket).Get(encode(int64(i)))
+		if k == nil {
+			return fmt.Errorf("no key #%d", i)
+		}
+		index = append([]byte(nil), k...)
+		return nil
+	})
+	return index, err
+}
+
+// ForEach calls f with the index and value of each leaf set at or before
+// revision rev, in the order they were first set.
+func (s *Store) ForEach(rev int64, f func(index, value []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		keys, values := tx.Bucket(keysBucket), tx.Bucket(valuesBucket)
+		count := keyCount(tx.Bucket(revisionsBucket), rev)
+		c := keys.Cursor()
+		for k, index := c.First(); k != nil && decode(k) < int64(count); k, index = c.Next() {
+			value, ok := get(values, rev, index)
+			if !ok {
+				return fmt.Errorf("key #%d %x has no value at revision %d", decode(k), index, rev)
+			}
+			if err := f(append([]byte(nil), index...), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// get returns a copy of the value of the leaf at index at revision rev, and
+// whether it had been set by then.
+func get(values *bolt.Bucket, rev int64, index []byte) ([]byte, bool) {
+	c := values.Cursor()
+	k, v := c.Seek(valueKey(index, rev+1))
+	if k == nil {
+		k, v = c.Last()
+	} else {
+		k, v = c.Prev()
+	}
+	if len(k) != len(index)+8 || !bytes.HasPrefix(k, index) {
+		return nil, false
+	}
+	return append([]byte(nil), v...), true
+}
+
+// keyCount returns the number of leaves set at or before revision rev.
+func keyCount(revisions *bolt.Bucket, rev int64) int {
+	c := revisions.Cursor()
+	k, v := c.Seek(encode(rev + 1))
+	if k == nil {
+		k, v = c.Last()
+	} else {
+		k, v = c.Prev()
+	}
+	if k == nil {
+		return 0
+	}
+	return int(decode(v))
+}
+
+// valueKey returns the key of the value of the leaf at index set at revision
+// rev, which sorts by index and then revision.
+func valueKey(index []byte, rev int64) []byte {
+	return append(append([]byte(nil), index...), encode(rev)...)
+}
+
+func encode(n int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(n))
+	return b
+}
+
+func decode(b []byte) int64 {
+	return int64(binary.BigEndian.Uint64(b))
+}
@@ -0,0 +1,186 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltstore
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle/maphasher"
+	"github.com/google/trillian/testonly"
+)
+
+func openTemp(t *testing.T) (*Store, string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "boltstore")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	path := filepath.Join(dir, "contents.db")
+	s, err := Open(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Open(): %v", err)
+	}
+	return s, path, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func leaf(key, value string) *trillian.MapLeaf {
+	return &trillian.MapLeaf{Index: testonly.TransparentHash(key), LeafValue: []byte(value)}
+}
+
+func TestStore(t *testing.T) {
+	s, _, cleanup := openTemp(t)
+	defer cleanup()
+
+	for _, u := range []struct {
+		rev    int64
+		leaves []*trillian.MapLeaf
+	}{
+		{rev: 1, leaves: []*trillian.MapLeaf{leaf("a", "a1"), leaf("b", "b1")}},
+		{rev: 3, leaves: []*trillian.MapLeaf{leaf("a", "a3"), leaf("c", "c3")}},
+		{rev: 4, leaves: []*trillian.MapLeaf{leaf("b", "")}},
+	} {
+		if err := s.Update(u.rev, u.leaves); err != nil {
+			t.Fatalf("Update(%d): %v", u.rev, err)
+		}
+	}
+	if err := s.Update(4, nil); err == nil {
+		t.Error("Update(4) again: nil, want err")
+	}
+
+	if got, err := s.Revisions(2); err != nil || !reflect.DeepEqual(got, []int64{4, 3}) {
+		t.Errorf("Revisions(2): %v, %v, want [4 3]", got, err)
+	}
+	for _, tc := range []struct {
+		rev       int64
+		key       string
+		want      string
+		wantOK    bool
+		wantCount int
+	}{
+		{rev: 0, key: "a", wantCount: 0},
+		{rev: 1, key: "a", want: "a1", wantOK: true, wantCount: 2},
+		{rev: 2, key: "a", want: "a1", wantOK: true, wantCount: 2},
+		{rev: 3, key: "a", want: "a3", wantOK: true, wantCount: 3},
+		{rev: 2, key: "c", wantCount: 2},
+		{rev: 3, key: "b", want: "b1", wantOK: true, wantCount: 3},
+		{rev: 4, key: "b", want: "", wantOK: true, wantCount: 3},
+		{rev: 9, key: "d", wantCount: 3},
+	} {
+		value, ok, err := s.Get(tc.rev, testonly.TransparentHash(tc.key))
+		if err != nil || string(value) != tc.want || ok != tc.wantOK {
+			t.Errorf("Get(%d, %q): %q, %t, %v, want %q, %t", tc.rev, tc.key, value, ok, err, tc.want, tc.wantOK)
+		}
+		if got, err := s.KeyCount(tc.rev); err != nil || got != tc.wantCount {
+			t.Errorf("KeyCount(%d): %d, %v, want %d", tc.rev, got, err, tc.wantCount)
+		}
+	}
+	for i, key := range []string{"a", "b", "c"} {
+		if got, err := s.Key(i); err != nil || !bytes.Equal(got, testonly.TransparentHash(key)) {
+			t.Errorf("Key(%d): %x, %v, want %x", i, got, err, testonly.TransparentHash(key))
+		}
+	}
+
+	var got []string
+	if err := s.ForEach(3, func(index, value []byte) error {
+		got = append(got, string(value))
+		return nil
+	}); err != nil {
+		t.Fatalf("ForEach(): %v", err)
+	}
+	if want := []string{"a3", "b1", "c3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ForEach(3): %v, want %v", got, want)
+	}
+}
+
+// TestVersionedMapContents checks that contents backed by a Store match those
+// held in memory, including after the store is reopened.
+func TestVersionedMapContents(t *testing.T) {
+	s, path, cleanup := openTemp(t)
+	defer cleanup()
+	stored, err := testonly.NewVersionedMapContents(s)
+	if err != nil {
+		t.Fatalf("NewVersionedMapContents(): %v", err)
+	}
+	var inMemory testonly.VersionedMapContents
+
+	prng := rand.New(rand.NewSource(42))
+	for rev := uint64(1); rev <= 20; rev++ {
+		var leaves []*trillian.MapLeaf
+		for i := 0; i < 3; i++ {
+			leaves = append(leaves, leaf(fmt.Sprintf("key-%d-%d", rev, i), fmt.Sprintf("value-%d", rev)))
+		}
+		if last := inMemory.LastCopy(); !last.Empty() {
+			// Update an existing key, or delete it on even revisions.
+			var value []byte
+			if rev%2 == 1 {
+				value = []byte(fmt.Sprintf("updated-%d", rev))
+			}
+			leaves = append(leaves, &trillian.MapLeaf{Index: last.PickKey(prng), LeafValue: value})
+		}
+		for _, vmc := range []*testonly.VersionedMapContents{stored, &inMemory} {
+			if _, err := vmc.UpdateContentsWith(rev, leaves); err != nil {
+				t.Fatalf("UpdateContentsWith(%d): %v", rev, err)
+			}
+		}
+	}
+
+	s.Close()
+	if s, err = Open(path); err != nil {
+		t.Fatalf("Open() again: %v", err)
+	}
+	reopened, err := testonly.NewVersionedMapContents(s)
+	if err != nil {
+		t.Fatalf("NewVersionedMapContents() again: %v", err)
+	}
+
+	hasher := maphasher.Default
+	for i := 0; i < 10; i++ {
+		want := inMemory.PrevCopy(i)
+		got := reopened.PrevCopy(i)
+		if got == nil || got.Rev != want.Rev {
+			t.Fatalf("PrevCopy(%d): %v, want revision %d", i, got, want.Rev)
+		}
+		wantRoot, err := want.RootHash(1, hasher)
+		if err != nil {
+			t.Fatalf("RootHash(): %v", err)
+		}
+		gotRoot, err := got.RootHash(1, hasher)
+		if err != nil {
+			t.Fatalf("stored RootHash(): %v", err)
+		}
+		if !bytes.Equal(gotRoot, wantRoot) {
+			t.Errorf("revision %d: stored RootHash() %x, want %x", got.Rev, gotRoot, wantRoot)
+		}
+		leaves := []*trillian.MapLeaf{leaf(fmt.Sprintf("key-%d-0", got.Rev), fmt.Sprintf("value-%d", got.Rev))}
+		if err := got.CheckContents(leaves, 0); err != nil {
+			t.Errorf("revision %d: stored CheckContents(): %v", got.Rev, err)
+		}
+		if err := got.CheckContents([]*trillian.MapLeaf{leaf(fmt.Sprintf("key-%d-0", got.Rev), "wrong")}, 0); err == nil {
+			t.Errorf("revision %d: stored CheckContents() of wrong value: nil, want err", got.Rev)
+		}
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/google/trillian/client"
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/testonly/datagen"
 )
 
 const (
@@ -50,6 +51,7 @@ var (
 	rsps        monitoring.Counter   // mapid, ep => value
 	rspLatency  monitoring.Histogram // mapid, ep => distribution-of-values
 	invalidReqs monitoring.Counter   // mapid, ep => value
+	skewResults monitoring.Counter   // mapid, bucket, result => value
 )
 
 // setupMetrics initializes all the exported metrics.
@@ -59,6 +61,7 @@ func setupMetrics(mf monitoring.MetricFactory) {
 	rsps = mf.NewCounter("rsps", "Number of responses received for valid requests", "mapid", "ep")
 	rspLatency = mf.NewHistogram("rsp_latency", "Latency of responses received for valid requests in seconds", "mapid", "ep")
 	invalidReqs = mf.NewCounter("invalid_reqs", "Number of deliberately-invalid requests sent", "mapid", "ep")
+	skewResults = mf.NewCounter("skew_results", "Number of results of requests sent with a simulated client clock skew", "mapid", "bucket", "result")
 }
 
 // errSkip indicates that a test operation should be skipped.
@@ -154,12 +157,29 @@ type MapConfig struct {
 	// KeepFailedTree indicates whether ephemeral trees should be left intact
 	// after a failed hammer run.
 	KeepFailedTree bool
+	// ClockSkews holds the client clock offsets to simulate; each skewed
+	// operation uses one of them at random.
+	ClockSkews []time.Duration
+	// SkewChance gives the odds of performing a skewed operation, as the N in
+	// 1-in-N (0 for never).
+	SkewChance int
+	// Keys chooses the indices of created leaves. If nil, each created leaf
+	// has a new sequential key.
+	Keys datagen.KeyDistribution
+	// Values chooses the sizes of leaf values. If nil, all values are
+	// LeafSize bytes long.
+	Values datagen.ValueDistribution
+	// Contents, if set, stores the expected contents of the map instead of
+	// copies in memory. If it already holds contents, e.g. from before a
+	// restart, the run resumes from them, and the map must be at their
+	// latest revision.
+	Contents testonly.MapContentsStore
 }
 
 // String conforms with Stringer for MapConfig.
 func (c MapConfig) String() string {
-	return fmt.Sprintf("mapID:%d biases:{%v} #operations:%d emit every:%v retryErrors? %t",
-		c.MapID, c.EPBias, c.Operations, c.EmitInterval, c.RetryErrors)
+	return fmt.Sprintf("mapID:%d biases:{%v} #operations:%d emit every:%v retryErrors? %t skews:%v (1-in-%d)",
+		c.MapID, c.EPBias, c.Operations, c.EmitInterval, c.RetryErrors, c.ClockSkews, c.SkewChance)
 }
 
 // HitMap performs load/stress operations according to given config.
@@ -271,6 +291,9 @@ type mapWorker struct {
 
 	retryErrors       bool
 	operationDeadline time.Duration
+
+	clockSkews []time.Duration
+	skewChance int
 }
 
 func newWorker(cfg *MapConfig, prng *rand.Rand) *mapWorker {
@@ -281,6 +304,8 @@ func newWorker(cfg *MapConfig, prng *rand.Rand) *mapWorker {
 		bias:              cfg.EPBias,
 		retryErrors:       cfg.RetryErrors,
 		operationDeadline: cfg.OperationDeadline,
+		clockSkews:        cfg.ClockSkews,
+		skewChance:        cfg.SkewChance,
 	}
 }
 
@@ -335,33 +360,68 @@ func newHammerState(ctx context.Context, cfg *MapConfig) (*hammerState, error) {
 		cfg.OperationDeadline = 60 * time.Second
 	}
 
-	var prevContents testonly.VersionedMapContents
+	prevContents := &testonly.VersionedMapContents{}
+	var keyIdx int
+	if cfg.Contents != nil {
+		if prevContents, err = resumeContents(ctx, mc, cfg.Contents); err != nil {
+			return nil, err
+		}
+		// Carry on with new sequential keys.
+		if last := prevContents.LastCopy(); last != nil {
+			if keyIdx, err = cfg.Contents.KeyCount(last.Rev); err != nil {
+				return nil, err
+			}
+		}
+	}
 	var smrs smrStash
 	validReadOps := validReadOps{
 		mc:           mc,
 		extraSize:    cfg.ExtraSize,
 		minLeaves:    cfg.MinLeaves,
 		maxLeaves:    cfg.MaxLeaves,
-		prevContents: &prevContents,
+		prevContents: prevContents,
 		smrs:         &smrs,
 	}
 	invalidReadOps := invalidReadOps{
 		mapID:        cfg.MapID,
 		client:       cfg.Client,
-		prevContents: &prevContents,
+		prevContents: prevContents,
 		smrs:         &smrs,
 	}
 
 	return &hammerState{
 		cfg:            cfg,
 		start:          time.Now(),
-		prevContents:   &prevContents,
+		prevContents:   prevContents,
 		smrs:           &smrs,
 		validReadOps:   &validReadOps,
 		invalidReadOps: &invalidReadOps,
+		keyIdx:         keyIdx,
 	}, nil
 }
 
+// resumeContents returns the expected contents of the map held in store, after
+// checking that the map is at their latest revision.
+func resumeContents(ctx context.Context, mc *client.MapClient, store testonly.MapContentsStore) (*testonly.VersionedMapContents, error) {
+	contents, err := testonly.NewVersionedMapContents(store)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored map contents: %v", err)
+	}
+	last := contents.LastCopy()
+	if last == nil {
+		return contents, nil
+	}
+	root, err := mc.GetAndVerifyLatestMapRoot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest map root: %v", err)
+	}
+	if got, want := int64(root.Revision), last.Rev; got != want {
+		return nil, fmt.Errorf("map at revision %d, but stored contents at revision %d", got, want)
+	}
+	glog.Infof("%d: resuming from stored contents at revision %d", mc.MapID, last.Rev)
+	return contents, nil
+}
+
 // TODO(mhutchinson): Remove hammerState from here - it allows access to global info
 // which makes reasoning about the behaviour difficult.
 func (w *mapWorker) performOperations(ctx context.Context, done <-chan struct{}, s *hammerState) (uint64, error) {
@@ -407,11 +467,18 @@ func (s *hammerState) nextKey() string {
 	return fmt.Sprintf("key-%08d", s.keyIdx)
 }
 
-func (s *hammerState) nextValue() []byte {
+func (s *hammerState) nextValue(prng *rand.Rand) []byte {
+	size := int(s.cfg.LeafSize)
+	if s.cfg.Values != nil {
+		// Values must still be long enough to hold their unique sequence number.
+		if size = s.cfg.Values.Size(prng); size < minValueLen {
+			size = minValueLen
+		}
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.valueIdx++
-	result := make([]byte, s.cfg.LeafSize)
+	result := make([]byte, size)
 	copy(result, fmt.Sprintf(valueFormat, s.valueIdx))
 	return result
 }
@@ -439,6 +506,7 @@ func (s *hammerState) String() string {
 	if smr := s.smrs.previousSMR(0); smr != nil {
 		latestRev = int64(smr.Revision)
 	}
+	details += s.skewString()
 	return fmt.Sprintf("%d: lastSMR.rev=%d ops: total=%d (%f ops/sec) invalid=%d errs=%v%s", s.cfg.MapID, latestRev, totalReqs, float64(totalReqs)/interval.Seconds(), totalInvalidReqs, totalErrs, details)
 }
 
@@ -448,6 +516,12 @@ func pickIntInRange(min, max int, prng *rand.Rand) int {
 }
 
 func (w *mapWorker) retryOneOp(ctx context.Context, s *hammerState) (err error) {
+	if w.skewed() {
+		skew := pickSkew(w.clockSkews, w.prng)
+		glog.V(3).Infof("%d: perform skewed operation (skew=%v)", w.mapID, skew)
+		return s.skewedOp(ctx, skew)
+	}
+
 	ep := w.bias.choose(w.prng)
 	if w.bias.invalid(ep, w.prng) {
 		glog.V(3).Infof("%d: perform invalid %s operation", w.mapID, ep)
@@ -571,14 +645,26 @@ leafloop:
 		}
 		switch choice {
 		case CreateLeaf:
-			key := s.nextKey()
-			value := s.nextValue()
+			var index []byte
+			if s.cfg.Keys != nil {
+				index = s.cfg.Keys.Index(prng)
+				// Skewed distributions may choose the same index again, which
+				// is not allowed in the same request.
+				for _, leaf := range leaves {
+					if bytes.Equal(leaf.Index, index) {
+						continue leafloop
+					}
+				}
+			} else {
+				index = testonly.TransparentHash(s.nextKey())
+			}
+			value := s.nextValue(prng)
 			leaves = append(leaves, &trillian.MapLeaf{
-				Index:     testonly.TransparentHash(key),
+				Index:     index,
 				LeafValue: value,
 				ExtraData: testonly.ExtraDataForValue(value, s.cfg.ExtraSize),
 			})
-			glog.V(3).Infof("%d: %v: data[%q]=%q", s.cfg.MapID, choice, key, string(value))
+			glog.V(3).Infof("%d: %v: data[%q]=%q", s.cfg.MapID, choice, dehash(index), string(value))
 		case UpdateLeaf, DeleteLeaf:
 			key := contents.PickKey(prng)
 			// Not allowed to have the same key more than once in the same request
@@ -591,7 +677,7 @@ leafloop:
 			}
 			var value, extra []byte
 			if choice == UpdateLeaf {
-				value = s.nextValue()
+				value = s.nextValue(prng)
 				extra = testonly.ExtraDataForValue(value, s.cfg.ExtraSize)
 			}
 			leaves = append(leaves, &trillian.MapLeaf{Index: key, LeafValue: value, ExtraData: extra})
@@ -24,6 +24,7 @@ import (
 
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/storage/testdb"
+	"github.com/google/trillian/testonly/datagen"
 	"github.com/google/trillian/testonly/integration"
 
 	_ "github.com/google/trillian/merkle/coniks"    // register CONIKS_SHA512_256
@@ -128,6 +129,50 @@ func TestInProcessMapHammer(t *testing.T) {
 		MaxLeaves:   150,
 		Operations:  *operations,
 		NumCheckers: 1,
+		ClockSkews:  []time.Duration{-time.Hour, -time.Second, 0, time.Second, time.Hour},
+		SkewChance:  10,
+	}
+	if err := HitMap(ctx, cfg); err != nil {
+		t.Fatalf("hammer failure: %v", err)
+	}
+}
+
+func TestInProcessMapHammerSkewedKeys(t *testing.T) {
+	testdb.SkipIfNoMySQL(t)
+	ctx := context.Background()
+	env, err := integration.NewMapEnv(ctx, *singleTX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+
+	keys, err := datagen.NewZipf(32, 1000, 1.2)
+	if err != nil {
+		t.Fatalf("NewZipf(): %v", err)
+	}
+	seed := time.Now().UTC().UnixNano() & 0xFFFFFFFF
+	cfg := MapConfig{
+		MapID:         0, // ephemeral tree
+		Client:        env.Map,
+		Write:         env.Write,
+		Admin:         env.Admin,
+		MetricFactory: monitoring.InertMetricFactory{},
+		RandSource:    rand.NewSource(seed),
+		EPBias: MapBias{
+			Bias: map[MapEntrypointName]int{
+				GetLeavesName: 10,
+				SetLeavesName: 10,
+				GetSMRName:    10,
+			},
+		},
+		LeafSize:    100,
+		ExtraSize:   100,
+		MinLeaves:   10,
+		MaxLeaves:   50,
+		Operations:  *operations,
+		NumCheckers: 1,
+		Keys:        keys,
+		Values:      datagen.LargeValues{SmallSize: 100, LargeSize: 10000, LargeChance: 20},
 	}
 	if err := HitMap(ctx, cfg); err != nil {
 		t.Fatalf("hammer failure: %v", err)
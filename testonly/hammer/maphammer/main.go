@@ -26,6 +26,7 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -33,10 +34,13 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/google/trillian"
+	"github.com/google/trillian/client/rpcflags"
 	"github.com/google/trillian/client/timeout"
 	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/monitoring/prometheus"
+	"github.com/google/trillian/testonly/datagen"
 	"github.com/google/trillian/testonly/hammer"
+	"github.com/google/trillian/testonly/hammer/boltstore"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
 
@@ -45,9 +49,14 @@ import (
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 )
 
+// indexSize is the size in bytes of the map indices chosen by key
+// distributions, which matches the SHA-256 based map hashers.
+const indexSize = 32
+
 var (
 	mapIDs          = flag.String("map_ids", "", "Comma-separated list of map IDs to test; ephemeral tree used if empty")
 	rpcServer       = flag.String("rpc_server", "", "Server address:port")
+	writeServer     = flag.String("write_server", "", "Address of the gRPC TrillianMapWrite server (host:port); if empty, --rpc_server is used")
 	adminServer     = flag.String("admin_server", "", "Address of the gRPC Trillian Admin Server (host:port)")
 	metricsEndpoint = flag.String("metrics_endpoint", "", "Endpoint for serving metrics; if left empty, metrics will not be exposed")
 	outLog          = flag.String("log_to", "", "File to record operations in")
@@ -62,6 +71,18 @@ var (
 	opDeadline      = flag.Duration("op_deadline", 60*time.Second, "How long to wait for operation success")
 	emitInterval    = flag.Duration("emit_interval", 0, "How often to output the Hammer state")
 	keepFailedTree  = flag.Bool("keep_failed_tree", false, "Whether to preserve ephemeral trees on failed run")
+	clockSkews      = flag.String("clock_skews", "-1h,-10s,0s,10s,1h", "Comma-separated list of client clock skews to simulate in skewed operations")
+	skewChance      = flag.Int("skew_chance", 0, "Chance of performing an operation with a skewed client clock, as the N in 1-in-N (0 for never)")
+
+	keyDistribution   = flag.String("key_distribution", "sequential", "Distribution of the indices of created leaves: sequential, uniform, zipf or clustered")
+	zipfKeys          = flag.Uint64("zipf_keys", 100000, "Number of distinct indices chosen by the zipf key distribution")
+	zipfExponent      = flag.Float64("zipf_exponent", 1.1, "Exponent of the zipf key distribution; higher values make the hot keys hotter")
+	clusters          = flag.Int("clusters", 16, "Number of index prefixes shared by the keys of the clustered key distribution")
+	clusterPrefixBits = flag.Int("cluster_prefix_bits", 16, "Length in bits of the index prefixes of the clustered key distribution")
+	largeLeafSize     = flag.Uint("large_leaf_size", 0, "Size of occasional large leaf values (0 for none)")
+	largeLeafChance   = flag.Int("large_leaf_chance", 100, "Chance of a leaf value being large, as the N in 1-in-N")
+
+	contentsDir = flag.String("contents_dir", "", "Directory in which to store the expected contents of each map, <map ID>.db, so that long runs need not hold them in memory and can resume after a restart; if empty, they are held in memory")
 )
 var (
 	getLeavesBias    = flag.Int("get_leaves", 20, "Bias for get-leaves operations")
@@ -98,6 +119,9 @@ func main() {
 	defer glog.Flush()
 
 	if *mapIDs == "" {
+		if *contentsDir != "" {
+			glog.Exit("--contents_dir requires --map_ids")
+		}
 		glog.Info("No mapIDs provided so using a transient tree")
 		*mapIDs = "0"
 	}
@@ -123,6 +147,26 @@ func main() {
 		},
 	}
 
+	var skews []time.Duration
+	if *clockSkews != "" {
+		for _, s := range strings.Split(*clockSkews, ",") {
+			skew, err := time.ParseDuration(s)
+			if err != nil {
+				glog.Exitf("Invalid clock skew %q: %v", s, err)
+			}
+			skews = append(skews, skew)
+		}
+	}
+
+	keys, err := keyDistributionFromFlags()
+	if err != nil {
+		glog.Exitf("Invalid key distribution: %v", err)
+	}
+	var values datagen.ValueDistribution
+	if *largeLeafSize > 0 {
+		values = datagen.LargeValues{SmallSize: int(*leafSize), LargeSize: int(*largeLeafSize), LargeChance: *largeLeafChance}
+	}
+
 	var mf monitoring.MetricFactory
 	if *metricsEndpoint != "" {
 		mf = prometheus.MetricFactory{}
@@ -169,18 +213,24 @@ func main() {
 		if err != nil || mapid < 0 {
 			glog.Exitf("Invalid map ID %q", m)
 		}
-		c, err := grpc.Dial(*rpcServer, dialOpts...)
+		c, err := grpc.Dial(rpcflags.DialTarget(*rpcServer), dialOpts...)
 		if err != nil {
 			glog.Exitf("Failed to create map client conn: %v", err)
 		}
-		ac, err := grpc.Dial(*adminServer, dialOpts...)
+		ac, err := grpc.Dial(rpcflags.DialTarget(*adminServer), dialOpts...)
 		if err != nil {
 			glog.Exitf("Failed to create admin client conn: %v", err)
 		}
+		wc := c
+		if *writeServer != "" {
+			if wc, err = grpc.Dial(rpcflags.DialTarget(*writeServer), dialOpts...); err != nil {
+				glog.Exitf("Failed to create map write client conn: %v", err)
+			}
+		}
 		cfg := hammer.MapConfig{
 			MapID:             mapid,
 			Client:            trillian.NewTrillianMapClient(c),
-			Write:             trillian.NewTrillianMapWriteClient(c),
+			Write:             trillian.NewTrillianMapWriteClient(wc),
 			Admin:             trillian.NewTrillianAdminClient(ac),
 			MetricFactory:     mf,
 			RandSource:        randSrc,
@@ -195,6 +245,18 @@ func main() {
 			RetryErrors:       *retryErrors,
 			OperationDeadline: *opDeadline,
 			KeepFailedTree:    *keepFailedTree,
+			ClockSkews:        skews,
+			SkewChance:        *skewChance,
+			Keys:              keys,
+			Values:            values,
+		}
+		if *contentsDir != "" {
+			store, err := boltstore.Open(filepath.Join(*contentsDir, fmt.Sprintf("%d.db", mapid)))
+			if err != nil {
+				glog.Exitf("Failed to open map contents store: %v", err)
+			}
+			defer store.Close()
+			cfg.Contents = store
 		}
 		fmt.Printf("%v\n\n", cfg)
 		wg.Add(1)
@@ -220,3 +282,20 @@ func main() {
 	}
 	glog.Info("  no errors; done")
 }
+
+// keyDistributionFromFlags returns the distribution of the indices of created
+// leaves selected by the flags, or nil for sequential keys.
+func keyDistributionFromFlags() (datagen.KeyDistribution, error) {
+	switch *keyDistribution {
+	case "sequential":
+		return nil, nil
+	case "uniform":
+		return datagen.Uniform{Size: indexSize}, nil
+	case "zipf":
+		return datagen.NewZipf(indexSize, *zipfKeys, *zipfExponent)
+	case "clustered":
+		return datagen.NewClustered(indexSize, *clusters, *clusterPrefixBits)
+	default:
+		return nil, fmt.Errorf("unknown key distribution %q", *keyDistribution)
+	}
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/google/trillian"
+	"github.com/google/trillian/client/rpcflags"
 	"github.com/google/trillian/testonly/hammer"
 	"google.golang.org/grpc"
 )
@@ -51,7 +52,7 @@ func main() {
 	var cl trillian.TrillianMapClient
 	var write trillian.TrillianMapWriteClient
 	if *rpcServer != "" {
-		c, err := grpc.Dial(*rpcServer, grpc.WithInsecure())
+		c, err := grpc.Dial(rpcflags.DialTarget(*rpcServer), grpc.WithInsecure())
 		if err != nil {
 			glog.Exitf("Failed to create map client conn: %v", err)
 		}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian/types"
@@ -73,3 +74,40 @@ func (s *smrStash) previousSMR(which int) *types.MapRootV1 {
 	defer s.mu.RUnlock()
 	return s.smr[which]
 }
+
+// revisionAt returns the most recent stashed revision whose root was published
+// no later than t. It returns false if no stashed root is that old.
+func (s *smrStash) revisionAt(t time.Time) (int64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ts := uint64(t.UnixNano())
+	for _, smr := range s.smr {
+		if smr == nil {
+			break
+		}
+		if smr.TimestampNanos <= ts {
+			return int64(smr.Revision), true
+		}
+	}
+	return 0, false
+}
+
+// revisionRate returns the observed rate at which new revisions are published,
+// in revisions per second, based on the stashed roots. It returns zero if there
+// are not enough roots to tell.
+func (s *smrStash) revisionRate() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	newest, oldest := s.smr[0], s.smr[0]
+	for _, smr := range s.smr {
+		if smr == nil {
+			break
+		}
+		oldest = smr
+	}
+	if newest == nil || newest.TimestampNanos <= oldest.TimestampNanos {
+		return 0
+	}
+	elapsed := time.Duration(newest.TimestampNanos - oldest.TimestampNanos)
+	return float64(newest.Revision-oldest.Revision) / elapsed.Seconds()
+}
@@ -0,0 +1,222 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hammer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/testonly"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// nearSkew is the boundary between near and far clock skew buckets.
+const nearSkew = time.Minute
+
+// SkewBucket identifies a range of simulated client clock skew, as exposed in
+// statistics/logging.
+type SkewBucket string
+
+// Constants for skew buckets, from a client clock far behind the server to one
+// far ahead of it.
+const (
+	SkewBehindFar = SkewBucket("BehindFar")
+	SkewBehind    = SkewBucket("Behind")
+	SkewNone      = SkewBucket("None")
+	SkewAhead     = SkewBucket("Ahead")
+	SkewAheadFar  = SkewBucket("AheadFar")
+)
+
+var skewBuckets = []SkewBucket{SkewBehindFar, SkewBehind, SkewNone, SkewAhead, SkewAheadFar}
+
+// skewBucket returns the bucket that a clock skew falls into.
+func skewBucket(skew time.Duration) SkewBucket {
+	switch {
+	case skew <= -nearSkew:
+		return SkewBehindFar
+	case skew < 0:
+		return SkewBehind
+	case skew == 0:
+		return SkewNone
+	case skew < nearSkew:
+		return SkewAhead
+	default:
+		return SkewAheadFar
+	}
+}
+
+// Results of skewed operations, as exposed in statistics.
+const (
+	// skewAccepted means the server accepted a request which was valid
+	// despite the skew.
+	skewAccepted = "accepted"
+	// skewRejected means the server rejected a request which was invalid
+	// because of the skew.
+	skewRejected = "rejected"
+	// skewFutureRoot means the latest root looked like it came from the future
+	// to the skewed client.
+	skewFutureRoot = "future_root"
+)
+
+// skewedView is the view of the map held by a client with a skewed clock.
+type skewedView struct {
+	skew   time.Duration
+	bucket SkewBucket
+	// now is the current time according to the client.
+	now time.Time
+	// rev is the latest revision of the map according to the client. A client
+	// whose clock is behind believes that the revision current at its time is
+	// the latest; a client whose clock is ahead expects revisions that have
+	// not been published yet.
+	rev int64
+}
+
+// newSkewedView builds the view of a client whose clock is offset by skew,
+// given the latest revision known to the hammer.
+func newSkewedView(skew time.Duration, latest int64, smrs *smrStash) skewedView {
+	v := skewedView{skew: skew, bucket: skewBucket(skew), now: time.Now().Add(skew), rev: latest}
+	switch {
+	case skew < 0:
+		if rev, ok := smrs.revisionAt(v.now); ok && rev < latest {
+			v.rev = rev
+		}
+	case skew > 0:
+		ahead := int64(math.Ceil(skew.Seconds() * smrs.revisionRate()))
+		if ahead < 1 {
+			ahead = 1
+		}
+		v.rev = latest + ahead
+	}
+	return v
+}
+
+// skewed randomly chooses whether an operation should be performed by a client
+// with a skewed clock.
+func (w *mapWorker) skewed() bool {
+	if w.skewChance <= 0 || len(w.clockSkews) == 0 {
+		return false
+	}
+	return w.prng.Intn(w.skewChance) == 0
+}
+
+// skewedOp performs a sequence of requests as a client with a randomly chosen
+// clock skew would, and checks that the server accepts or rejects each of them
+// as appropriate. Results are recorded per skew bucket.
+func (s *hammerState) skewedOp(ctx context.Context, skew time.Duration) error {
+	contents := s.prevContents.LastCopy()
+	if contents == nil {
+		glog.V(3).Infof("%d: skipping skewed operation as no data yet", s.cfg.MapID)
+		return nil
+	}
+	v := newSkewedView(skew, contents.Rev, s.smrs)
+	glog.V(2).Infof("%d: skewed client (skew=%v, bucket=%s) believes rev=%d, actual rev=%d", s.cfg.MapID, skew, v.bucket, v.rev, contents.Rev)
+
+	if err := s.skewedGetSMRRev(ctx, v, contents.Rev); err != nil {
+		return err
+	}
+	if v.rev != contents.Rev {
+		if err := s.skewedWrite(ctx, v); err != nil {
+			return err
+		}
+	}
+	return s.skewedGetSMR(ctx, v)
+}
+
+// skewedGetSMRRev requests the root at the revision the client believes to be
+// the latest, which only exists if the client's clock is not ahead.
+func (s *hammerState) skewedGetSMRRev(ctx context.Context, v skewedView, latest int64) error {
+	req := trillian.GetSignedMapRootByRevisionRequest{MapId: s.cfg.MapID, Revision: v.rev}
+	rsp, err := s.cfg.Client.GetSignedMapRootByRevision(ctx, &req)
+	if v.rev > latest {
+		if err == nil {
+			return fmt.Errorf("unexpected success: get-smr-rev(skew=%v: @%d): %+v", v.skew, v.rev, rsp.MapRoot)
+		}
+		s.recordSkew(v, skewRejected)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get-smr-rev(skew=%v: @%d): %v", v.skew, v.rev, err)
+	}
+	s.recordSkew(v, skewAccepted)
+	return nil
+}
+
+// skewedWrite writes a leaf asserting the revision after the one the client
+// believes to be the latest. As the client's view is wrong, the write must be
+// rejected.
+func (s *hammerState) skewedWrite(ctx context.Context, v skewedView) error {
+	value := []byte("value-for-skewed-req")
+	req := trillian.WriteMapLeavesRequest{
+		MapId:          s.cfg.MapID,
+		Leaves:         []*trillian.MapLeaf{{Index: testonly.TransparentHash("skewed-key"), LeafValue: value}},
+		ExpectRevision: v.rev + 1,
+	}
+	rsp, err := s.cfg.Write.WriteLeaves(ctx, &req)
+	if err == nil {
+		// The map now contains a leaf the hammer does not know about, so the
+		// run cannot continue.
+		return fmt.Errorf("unexpected success: set-leaves(skew=%v: rev=%d): %+v", v.skew, req.ExpectRevision, rsp.Revision)
+	}
+	if got, want := status.Code(err), codes.FailedPrecondition; got != want {
+		return fmt.Errorf("set-leaves(skew=%v: rev=%d): got code %v, want %v: %v", v.skew, req.ExpectRevision, got, want, err)
+	}
+	s.recordSkew(v, skewRejected)
+	return nil
+}
+
+// skewedGetSMR fetches the latest root and records whether it appears to have
+// been published in the future according to the client's clock.
+func (s *hammerState) skewedGetSMR(ctx context.Context, v skewedView) error {
+	root, err := s.validReadOps.mc.GetAndVerifyLatestMapRoot(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get-smr(skew=%v): %v", v.skew, err)
+	}
+	if ts := time.Unix(0, int64(root.TimestampNanos)); ts.After(v.now) {
+		glog.V(2).Infof("%d: skewed client (skew=%v) got SMR(time=%q, rev=%d) from %v in its future", s.cfg.MapID, v.skew, ts, root.Revision, ts.Sub(v.now))
+		s.recordSkew(v, skewFutureRoot)
+		return nil
+	}
+	s.recordSkew(v, skewAccepted)
+	return nil
+}
+
+func (s *hammerState) recordSkew(v skewedView, result string) {
+	skewResults.Inc(s.label(), string(v.bucket), result)
+}
+
+// pickSkew randomly chooses one of the configured clock skews.
+func pickSkew(skews []time.Duration, prng *rand.Rand) time.Duration {
+	return skews[prng.Intn(len(skews))]
+}
+
+// skewString summarizes the results of skewed operations for each bucket.
+func (s *hammerState) skewString() string {
+	details := ""
+	for _, b := range skewBuckets {
+		accepted := int(skewResults.Value(s.label(), string(b), skewAccepted))
+		rejected := int(skewResults.Value(s.label(), string(b), skewRejected))
+		future := int(skewResults.Value(s.label(), string(b), skewFutureRoot))
+		if accepted+rejected+future > 0 {
+			details += fmt.Sprintf(" skew[%s]=%d/%d/%d", b, accepted, rejected, future)
+		}
+	}
+	return details
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hammer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/trillian/types"
+)
+
+func TestSkewBucket(t *testing.T) {
+	for _, test := range []struct {
+		skew time.Duration
+		want SkewBucket
+	}{
+		{skew: -time.Hour, want: SkewBehindFar},
+		{skew: -time.Minute, want: SkewBehindFar},
+		{skew: -time.Second, want: SkewBehind},
+		{skew: 0, want: SkewNone},
+		{skew: time.Second, want: SkewAhead},
+		{skew: time.Minute, want: SkewAheadFar},
+		{skew: time.Hour, want: SkewAheadFar},
+	} {
+		if got := skewBucket(test.skew); got != test.want {
+			t.Errorf("skewBucket(%v)=%v, want %v", test.skew, got, test.want)
+		}
+	}
+}
+
+func TestNewSkewedView(t *testing.T) {
+	// Revisions 1 to 10 were published one per second, the last one just now.
+	now := time.Now()
+	var stash smrStash
+	for rev := 1; rev <= 10; rev++ {
+		ts := now.Add(time.Duration(rev-10) * time.Second)
+		if err := stash.pushSMR(types.MapRootV1{Revision: uint64(rev), TimestampNanos: uint64(ts.UnixNano())}); err != nil {
+			t.Fatalf("pushSMR(%d): %v", rev, err)
+		}
+	}
+
+	for _, test := range []struct {
+		desc    string
+		skew    time.Duration
+		latest  int64
+		wantRev int64
+	}{
+		{desc: "no skew", skew: 0, latest: 10, wantRev: 10},
+		{desc: "behind", skew: -3500 * time.Millisecond, latest: 10, wantRev: 6},
+		{desc: "behind all roots", skew: -time.Hour, latest: 10, wantRev: 10},
+		{desc: "behind unseen roots", skew: -time.Second, latest: 12, wantRev: 9},
+		{desc: "ahead", skew: 3500 * time.Millisecond, latest: 10, wantRev: 14},
+		{desc: "slightly ahead", skew: time.Millisecond, latest: 10, wantRev: 11},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			v := newSkewedView(test.skew, test.latest, &stash)
+			if got, want := v.rev, test.wantRev; got != want {
+				t.Errorf("newSkewedView(%v).rev=%d, want %d", test.skew, got, want)
+			}
+			if got, want := v.bucket, skewBucket(test.skew); got != want {
+				t.Errorf("newSkewedView(%v).bucket=%v, want %v", test.skew, got, want)
+			}
+		})
+	}
+}
+
+func TestSmrStash_RevisionRate(t *testing.T) {
+	var stash smrStash
+	if got := stash.revisionRate(); got != 0 {
+		t.Errorf("revisionRate()=%v for empty stash, want 0", got)
+	}
+	start := time.Unix(1000, 0)
+	for _, r := range []types.MapRootV1{
+		{Revision: 1, TimestampNanos: uint64(start.UnixNano())},
+		{Revision: 5, TimestampNanos: uint64(start.Add(2 * time.Second).UnixNano())},
+	} {
+		if err := stash.pushSMR(r); err != nil {
+			t.Fatalf("pushSMR(%d): %v", r.Revision, err)
+		}
+	}
+	if got, want := stash.revisionRate(), 2.0; got != want {
+		t.Errorf("revisionRate()=%v, want %v", got, want)
+	}
+}
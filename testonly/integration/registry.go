@@ -16,11 +16,25 @@ package integration
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
+	"cloud.google.com/go/bigtable/bttest"
 	"github.com/google/trillian/extension"
+	"github.com/google/trillian/quota"
 	"github.com/google/trillian/quota/mysqlqm"
+	"github.com/google/trillian/quota/postgresqm"
+	"github.com/google/trillian/storage/bigtable"
 	"github.com/google/trillian/storage/mysql"
+	"github.com/google/trillian/storage/postgres"
+	"github.com/google/trillian/storage/sqlite"
 	"github.com/google/trillian/storage/testdb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+
+	bt "cloud.google.com/go/bigtable"
+	pgtestdb "github.com/google/trillian/storage/postgres/testdb"
 )
 
 // NewRegistryForTests returns an extension.Registry for integration tests.
@@ -39,3 +53,93 @@ func NewRegistryForTests(ctx context.Context) (extension.Registry, func(context.
 		QuotaManager: &mysqlqm.QuotaManager{DB: db, MaxUnsequencedRows: mysqlqm.DefaultMaxUnsequenced},
 	}, done, nil
 }
+
+// NewPostgresRegistryForTests is like NewRegistryForTests, but returns a
+// registry backed by a new PostgreSQL database.
+func NewPostgresRegistryForTests(ctx context.Context) (extension.Registry, func(context.Context), error) {
+	db, done, err := pgtestdb.NewTrillianDB(ctx)
+	if err != nil {
+		return extension.Registry{}, nil, err
+	}
+
+	return extension.Registry{
+		AdminStorage: postgres.NewAdminStorage(db),
+		LogStorage:   postgres.NewLogStorage(db, nil),
+		MapStorage:   postgres.NewMapStorage(db),
+		QuotaManager: &postgresqm.QuotaManager{DB: db, MaxUnsequencedRows: postgresqm.DefaultMaxUnsequenced},
+	}, done, nil
+}
+
+// NewSQLiteRegistryForTests is like NewRegistryForTests, but returns a
+// registry backed by a new SQLite database in a temporary directory, which
+// needs no database server. The SQLite storage has no quota manager, so quota
+// is not enforced.
+func NewSQLiteRegistryForTests(ctx context.Context) (extension.Registry, func(context.Context), error) {
+	dir, err := ioutil.TempDir("", "trillian")
+	if err != nil {
+		return extension.Registry{}, nil, err
+	}
+	db, err := sqlite.OpenDB(filepath.Join(dir, "trillian.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return extension.Registry{}, nil, err
+	}
+	done := func(context.Context) {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+
+	return extension.Registry{
+		AdminStorage: sqlite.NewAdminStorage(db),
+		LogStorage:   sqlite.NewLogStorage(db, nil),
+		MapStorage:   sqlite.NewMapStorage(db),
+		QuotaManager: quota.Noop(),
+	}, done, nil
+}
+
+// NewBigtableRegistryForTests is like NewRegistryForTests, but returns a
+// registry backed by a table on a new in-memory Bigtable server. The Bigtable
+// storage has no quota manager, so quota is not enforced.
+func NewBigtableRegistryForTests(ctx context.Context) (extension.Registry, func(context.Context), error) {
+	srv, err := bttest.NewServer("localhost:0")
+	if err != nil {
+		return extension.Registry{}, nil, err
+	}
+	conn, err := grpc.Dial(srv.Addr, grpc.WithInsecure())
+	if err != nil {
+		srv.Close()
+		return extension.Registry{}, nil, err
+	}
+	done := func(context.Context) {
+		conn.Close()
+		srv.Close()
+	}
+	admin, err := bt.NewAdminClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		done(ctx)
+		return extension.Registry{}, nil, err
+	}
+	if err := bigtable.CreateTable(ctx, admin, "trillian"); err != nil {
+		admin.Close()
+		done(ctx)
+		return extension.Registry{}, nil, err
+	}
+	client, err := bt.NewClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		admin.Close()
+		done(ctx)
+		return extension.Registry{}, nil, err
+	}
+	tbl := client.Open("trillian")
+
+	return extension.Registry{
+		AdminStorage: bigtable.NewAdminStorage(tbl),
+		LogStorage:   bigtable.NewLogStorage(tbl, bigtable.TreeStorageOptions{}),
+		MapStorage:   bigtable.NewMapStorage(tbl, bigtable.TreeStorageOptions{}),
+		QuotaManager: quota.Noop(),
+	}, func(ctx context.Context) {
+		client.Close()
+		admin.Close()
+		done(ctx)
+	}, nil
+}
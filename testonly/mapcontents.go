@@ -37,15 +37,43 @@ func (e ErrInvariant) Error() string {
 	return fmt.Sprintf("Invariant check failed: %v", e.msg)
 }
 
-// MapContents is a complete copy of the map's contents at a particular revision.
+// MapContentsStore persists the contents of a map at all the revisions it is
+// updated to, so that VersionedMapContents need not hold copies of them in
+// memory, and can be reopened after a restart.
+type MapContentsStore interface {
+	// Revisions returns up to n of the most recent revisions stored, most
+	// recent first.
+	Revisions(n int) ([]int64, error)
+	// Update stores the contents of the map at revision rev, which must be
+	// greater than the stored revisions, as the contents at the latest one
+	// updated with the values of leaves.
+	Update(rev int64, leaves []*trillian.MapLeaf) error
+	// Get returns the value of the leaf at index at revision rev, and
+	// whether it had been set by then.
+	Get(rev int64, index []byte) ([]byte, bool, error)
+	// KeyCount returns the number of leaves set at or before revision rev.
+	KeyCount(rev int64) (int, error)
+	// Key returns the index of the i-th leaf to be set, counting from 0.
+	Key(i int) ([]byte, error)
+	// ForEach calls f with the index and value of each leaf set at or
+	// before revision rev.
+	ForEach(rev int64, f func(index, value []byte) error) error
+}
+
+// MapContents is a complete copy of the map's contents at a particular
+// revision, or a view of them in a MapContentsStore.
 type MapContents struct {
-	Rev  int64
-	data map[mapKey]string
+	Rev   int64
+	data  map[mapKey]string
+	store MapContentsStore
 }
 
 type mapKey [sha256.Size]byte
 
 func (m *MapContents) String() string {
+	if m.store != nil {
+		return fmt.Sprintf("rev: %d data: stored", m.Rev)
+	}
 	var buf bytes.Buffer
 	buf.WriteString(fmt.Sprintf("rev: %d data:", m.Rev))
 	for k, v := range m.data {
@@ -59,7 +87,19 @@ func (m *MapContents) Empty() bool {
 	if m == nil {
 		return true
 	}
-	return len(m.data) == 0
+	return m.keyCount() == 0
+}
+
+// keyCount returns the number of keys in the contents.
+func (m *MapContents) keyCount() int {
+	if m.store == nil {
+		return len(m.data)
+	}
+	n, err := m.store.KeyCount(m.Rev)
+	if err != nil {
+		panic(fmt.Sprintf("failed to read stored map contents: %v", err))
+	}
+	return n
 }
 
 // PickKey randomly selects a key that already exists in a given copy of the
@@ -69,6 +109,15 @@ func (m *MapContents) PickKey(prng *rand.Rand) []byte {
 		panic("internal error: can't pick a key, map data is empty!")
 	}
 
+	if m.store != nil {
+		// Stored keys are in the order they were first set, which is also
+		// reproducible.
+		key, err := m.store.Key(prng.Intn(m.keyCount()))
+		if err != nil {
+			panic(fmt.Sprintf("failed to read stored map contents: %v", err))
+		}
+		return key
+	}
 	choice := prng.Intn(len(m.data))
 	// Need sorted keys for reproduceability.
 	keys := make([]mapKey, 0)
@@ -87,7 +136,10 @@ func (m *MapContents) CheckContents(leaves []*trillian.MapLeaf, extraSize uint)
 	for _, leaf := range leaves {
 		var key mapKey
 		copy(key[:], leaf.Index)
-		value, ok := m.data[key]
+		value, ok, err := m.get(key)
+		if err != nil {
+			return err
+		}
 		if ok {
 			if string(leaf.LeafValue) != value {
 				return fmt.Errorf("got leaf[%v].LeafValue=%q, want %q", key, leaf.LeafValue, value)
@@ -104,8 +156,35 @@ func (m *MapContents) CheckContents(leaves []*trillian.MapLeaf, extraSize uint)
 	return nil
 }
 
+// get returns the value of the leaf at key, and whether it has been set.
+func (m *MapContents) get(key mapKey) (string, bool, error) {
+	if m.store == nil {
+		value, ok := m.data[key]
+		return value, ok, nil
+	}
+	value, ok, err := m.store.Get(m.Rev, key[:])
+	return string(value), ok, err
+}
+
+// forEach calls f with each key and value of the contents.
+func (m *MapContents) forEach(f func(key mapKey, value string)) error {
+	if m.store == nil {
+		for k, v := range m.data {
+			f(k, v)
+		}
+		return nil
+	}
+	return m.store.ForEach(m.Rev, func(index, value []byte) error {
+		var k mapKey
+		copy(k[:], index)
+		f(k, string(value))
+		return nil
+	})
+}
+
 // UpdatedWith returns a new MapContents object that has been updated to include the
-// given leaves and revision.  A nil receiver object is allowed.
+// given leaves and revision.  A nil receiver object is allowed, but not one
+// viewing a MapContentsStore.
 func (m *MapContents) UpdatedWith(rev uint64, leaves []*trillian.MapLeaf) *MapContents {
 	// Start from previous map contents
 	result := MapContents{Rev: int64(rev), data: make(map[mapKey]string)}
@@ -166,17 +245,19 @@ func extendByABit(in bitString, bitValue int) bitString {
 // Merkle tree with the given leaf contents.
 func (m *MapContents) RootHash(treeID int64, hasher hashers.MapHasher) ([]byte, error) {
 	// Watch out for completely empty trees
-	if m == nil || len(m.data) == 0 {
+	if m.Empty() {
 		return hasher.HashEmpty(treeID, []byte{}, hasher.BitLen()), nil
 	}
 	// First do the leaves, as they're special.
 	curBitLen := hasher.BitLen()
 	curHeight := 0
 	curHashes := make(map[bitString][]byte)
-	for k, v := range m.data {
+	if err := m.forEach(func(k mapKey, v string) {
 		prefixKey := bitString{bitLen: curBitLen}
 		copy(prefixKey.data[:], k[:])
 		curHashes[prefixKey] = hasher.HashLeaf(treeID, k[:], []byte(v))
+	}); err != nil {
+		return nil, err
 	}
 
 	for curBitLen > 0 {
@@ -232,13 +313,30 @@ func (m *MapContents) RootHash(treeID int64, hasher hashers.MapHasher) ([]byte,
 const copyCount = 10
 
 // VersionedMapContents holds a collection of copies of a Map's
-// contents across different revisions.
+// contents across different revisions. The zero value holds the copies in
+// memory.
 type VersionedMapContents struct {
 	mu sync.RWMutex
 
 	// contents holds copies of the map at different revisions,
 	// from later to earlier (so [0] is the most recent).
 	contents [copyCount]*MapContents
+	// store, if set, holds the contents, of which contents are only views.
+	store MapContentsStore
+}
+
+// NewVersionedMapContents returns a VersionedMapContents backed by store,
+// holding the most recent revisions already in it.
+func NewVersionedMapContents(store MapContentsStore) (*VersionedMapContents, error) {
+	revs, err := store.Revisions(copyCount)
+	if err != nil {
+		return nil, err
+	}
+	p := &VersionedMapContents{store: store}
+	for i, rev := range revs {
+		p.contents[i] = &MapContents{Rev: rev, store: store}
+	}
+	return p, nil
 }
 
 // Empty indicates whether the most recent map contents are empty.
@@ -306,11 +404,20 @@ func (p *VersionedMapContents) UpdateContentsWith(rev uint64, leaves []*trillian
 		return nil, ErrInvariant{fmt.Sprintf("got rev %d, want >%d when trying to update hammer state with new contents", rev, p.contents[0].Rev)}
 	}
 
+	if p.store != nil {
+		if err := p.store.Update(int64(rev), leaves); err != nil {
+			return nil, err
+		}
+	}
 	// Shuffle earlier contents along.
 	for i := copyCount - 1; i > 0; i-- {
 		p.contents[i] = p.contents[i-1]
 	}
-	p.contents[0] = p.contents[1].UpdatedWith(rev, leaves)
+	if p.store != nil {
+		p.contents[0] = &MapContents{Rev: int64(rev), store: p.store}
+	} else {
+		p.contents[0] = p.contents[1].UpdatedWith(rev, leaves)
+	}
 
 	if glog.V(3) {
 		p.dumpLockedContents()
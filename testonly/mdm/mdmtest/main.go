@@ -26,6 +26,7 @@ import (
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/trillian"
 	"github.com/google/trillian/client"
+	"github.com/google/trillian/client/rpcflags"
 	"github.com/google/trillian/crypto/keyspb"
 	"github.com/google/trillian/crypto/sigpb"
 	"github.com/google/trillian/monitoring"
@@ -78,7 +79,7 @@ func innerMain(ctx context.Context) error {
 	}
 
 	dialOpts := []grpc.DialOption{grpc.WithInsecure()}
-	c, err := grpc.Dial(*rpcServer, dialOpts...)
+	c, err := grpc.Dial(rpcflags.DialTarget(*rpcServer), dialOpts...)
 	if err != nil {
 		glog.Exitf("Failed to create log client conn: %v", err)
 	}
@@ -86,7 +87,7 @@ func innerMain(ctx context.Context) error {
 
 	ac := c
 	if len(*adminServer) > 0 {
-		ac, err = grpc.Dial(*adminServer, dialOpts...)
+		ac, err = grpc.Dial(rpcflags.DialTarget(*adminServer), dialOpts...)
 		if err != nil {
 			glog.Exitf("Failed to create admin client conn: %v", err)
 		}
@@ -49,6 +49,21 @@ func (mr *MockTrillianAdminServerMockRecorder) CreateTree(arg0, arg1 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTree", reflect.TypeOf((*MockTrillianAdminServer)(nil).CreateTree), arg0, arg1)
 }
 
+// CreateTreesFromTemplate mocks base method
+func (m *MockTrillianAdminServer) CreateTreesFromTemplate(arg0 context.Context, arg1 *trillian.CreateTreesFromTemplateRequest) (*trillian.CreateTreesFromTemplateResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTreesFromTemplate", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.CreateTreesFromTemplateResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTreesFromTemplate indicates an expected call of CreateTreesFromTemplate
+func (mr *MockTrillianAdminServerMockRecorder) CreateTreesFromTemplate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTreesFromTemplate", reflect.TypeOf((*MockTrillianAdminServer)(nil).CreateTreesFromTemplate), arg0, arg1)
+}
+
 // DeleteTree mocks base method
 func (m *MockTrillianAdminServer) DeleteTree(arg0 context.Context, arg1 *trillian.DeleteTreeRequest) (*trillian.Tree, error) {
 	m.ctrl.T.Helper()
@@ -64,6 +79,21 @@ func (mr *MockTrillianAdminServerMockRecorder) DeleteTree(arg0, arg1 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTree", reflect.TypeOf((*MockTrillianAdminServer)(nil).DeleteTree), arg0, arg1)
 }
 
+// ExportTrees mocks base method
+func (m *MockTrillianAdminServer) ExportTrees(arg0 context.Context, arg1 *trillian.ExportTreesRequest) (*trillian.ExportTreesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportTrees", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.ExportTreesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExportTrees indicates an expected call of ExportTrees
+func (mr *MockTrillianAdminServerMockRecorder) ExportTrees(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportTrees", reflect.TypeOf((*MockTrillianAdminServer)(nil).ExportTrees), arg0, arg1)
+}
+
 // GetTree mocks base method
 func (m *MockTrillianAdminServer) GetTree(arg0 context.Context, arg1 *trillian.GetTreeRequest) (*trillian.Tree, error) {
 	m.ctrl.T.Helper()
@@ -79,6 +109,36 @@ func (mr *MockTrillianAdminServerMockRecorder) GetTree(arg0, arg1 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTree", reflect.TypeOf((*MockTrillianAdminServer)(nil).GetTree), arg0, arg1)
 }
 
+// ImportTrees mocks base method
+func (m *MockTrillianAdminServer) ImportTrees(arg0 context.Context, arg1 *trillian.ImportTreesRequest) (*trillian.ImportTreesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportTrees", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.ImportTreesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportTrees indicates an expected call of ImportTrees
+func (mr *MockTrillianAdminServerMockRecorder) ImportTrees(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportTrees", reflect.TypeOf((*MockTrillianAdminServer)(nil).ImportTrees), arg0, arg1)
+}
+
+// LiftQuarantine mocks base method
+func (m *MockTrillianAdminServer) LiftQuarantine(arg0 context.Context, arg1 *trillian.LiftQuarantineRequest) (*trillian.Tree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LiftQuarantine", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LiftQuarantine indicates an expected call of LiftQuarantine
+func (mr *MockTrillianAdminServerMockRecorder) LiftQuarantine(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LiftQuarantine", reflect.TypeOf((*MockTrillianAdminServer)(nil).LiftQuarantine), arg0, arg1)
+}
+
 // ListTrees mocks base method
 func (m *MockTrillianAdminServer) ListTrees(arg0 context.Context, arg1 *trillian.ListTreesRequest) (*trillian.ListTreesResponse, error) {
 	m.ctrl.T.Helper()
@@ -94,6 +154,81 @@ func (mr *MockTrillianAdminServerMockRecorder) ListTrees(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTrees", reflect.TypeOf((*MockTrillianAdminServer)(nil).ListTrees), arg0, arg1)
 }
 
+// PauseSequencing mocks base method
+func (m *MockTrillianAdminServer) PauseSequencing(arg0 context.Context, arg1 *trillian.PauseSequencingRequest) (*trillian.Tree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PauseSequencing", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PauseSequencing indicates an expected call of PauseSequencing
+func (mr *MockTrillianAdminServerMockRecorder) PauseSequencing(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PauseSequencing", reflect.TypeOf((*MockTrillianAdminServer)(nil).PauseSequencing), arg0, arg1)
+}
+
+// PurgeTree mocks base method
+func (m *MockTrillianAdminServer) PurgeTree(arg0 context.Context, arg1 *trillian.PurgeTreeRequest) (*trillian.Tree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeTree", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeTree indicates an expected call of PurgeTree
+func (mr *MockTrillianAdminServerMockRecorder) PurgeTree(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeTree", reflect.TypeOf((*MockTrillianAdminServer)(nil).PurgeTree), arg0, arg1)
+}
+
+// ResignLatestRoot mocks base method
+func (m *MockTrillianAdminServer) ResignLatestRoot(arg0 context.Context, arg1 *trillian.ResignLatestRootRequest) (*trillian.ResignLatestRootResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResignLatestRoot", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.ResignLatestRootResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResignLatestRoot indicates an expected call of ResignLatestRoot
+func (mr *MockTrillianAdminServerMockRecorder) ResignLatestRoot(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResignLatestRoot", reflect.TypeOf((*MockTrillianAdminServer)(nil).ResignLatestRoot), arg0, arg1)
+}
+
+// ResumeSequencing mocks base method
+func (m *MockTrillianAdminServer) ResumeSequencing(arg0 context.Context, arg1 *trillian.ResumeSequencingRequest) (*trillian.Tree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeSequencing", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResumeSequencing indicates an expected call of ResumeSequencing
+func (mr *MockTrillianAdminServerMockRecorder) ResumeSequencing(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeSequencing", reflect.TypeOf((*MockTrillianAdminServer)(nil).ResumeSequencing), arg0, arg1)
+}
+
+// RotateTreeKey mocks base method
+func (m *MockTrillianAdminServer) RotateTreeKey(arg0 context.Context, arg1 *trillian.RotateTreeKeyRequest) (*trillian.Tree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateTreeKey", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateTreeKey indicates an expected call of RotateTreeKey
+func (mr *MockTrillianAdminServerMockRecorder) RotateTreeKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateTreeKey", reflect.TypeOf((*MockTrillianAdminServer)(nil).RotateTreeKey), arg0, arg1)
+}
+
 // UndeleteTree mocks base method
 func (m *MockTrillianAdminServer) UndeleteTree(arg0 context.Context, arg1 *trillian.UndeleteTreeRequest) (*trillian.Tree, error) {
 	m.ctrl.T.Helper()
@@ -34,6 +34,21 @@ func (m *MockTrillianLogServer) EXPECT() *MockTrillianLogServerMockRecorder {
 	return m.recorder
 }
 
+// AddLogRootSignature mocks base method
+func (m *MockTrillianLogServer) AddLogRootSignature(arg0 context.Context, arg1 *trillian.AddLogRootSignatureRequest) (*trillian.AddLogRootSignatureResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddLogRootSignature", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.AddLogRootSignatureResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddLogRootSignature indicates an expected call of AddLogRootSignature
+func (mr *MockTrillianLogServerMockRecorder) AddLogRootSignature(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddLogRootSignature", reflect.TypeOf((*MockTrillianLogServer)(nil).AddLogRootSignature), arg0, arg1)
+}
+
 // AddSequencedLeaf mocks base method
 func (m *MockTrillianLogServer) AddSequencedLeaf(arg0 context.Context, arg1 *trillian.AddSequencedLeafRequest) (*trillian.AddSequencedLeafResponse, error) {
 	m.ctrl.T.Helper()
@@ -64,6 +79,35 @@ func (mr *MockTrillianLogServerMockRecorder) AddSequencedLeaves(arg0, arg1 inter
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSequencedLeaves", reflect.TypeOf((*MockTrillianLogServer)(nil).AddSequencedLeaves), arg0, arg1)
 }
 
+// AddSequencedLeavesStream mocks base method
+func (m *MockTrillianLogServer) AddSequencedLeavesStream(arg0 trillian.TrillianLog_AddSequencedLeavesStreamServer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddSequencedLeavesStream", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddSequencedLeavesStream indicates an expected call of AddSequencedLeavesStream
+func (mr *MockTrillianLogServerMockRecorder) AddSequencedLeavesStream(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSequencedLeavesStream", reflect.TypeOf((*MockTrillianLogServer)(nil).AddSequencedLeavesStream), arg0)
+}
+
+// GetCheckpoint mocks base method
+func (m *MockTrillianLogServer) GetCheckpoint(arg0 context.Context, arg1 *trillian.GetCheckpointRequest) (*trillian.GetCheckpointResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCheckpoint", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetCheckpointResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCheckpoint indicates an expected call of GetCheckpoint
+func (mr *MockTrillianLogServerMockRecorder) GetCheckpoint(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCheckpoint", reflect.TypeOf((*MockTrillianLogServer)(nil).GetCheckpoint), arg0, arg1)
+}
+
 // GetConsistencyProof mocks base method
 func (m *MockTrillianLogServer) GetConsistencyProof(arg0 context.Context, arg1 *trillian.GetConsistencyProofRequest) (*trillian.GetConsistencyProofResponse, error) {
 	m.ctrl.T.Helper()
@@ -79,6 +123,21 @@ func (mr *MockTrillianLogServerMockRecorder) GetConsistencyProof(arg0, arg1 inte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConsistencyProof", reflect.TypeOf((*MockTrillianLogServer)(nil).GetConsistencyProof), arg0, arg1)
 }
 
+// GetConsistencyProofByRootHash mocks base method
+func (m *MockTrillianLogServer) GetConsistencyProofByRootHash(arg0 context.Context, arg1 *trillian.GetConsistencyProofByRootHashRequest) (*trillian.GetConsistencyProofByRootHashResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConsistencyProofByRootHash", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetConsistencyProofByRootHashResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetConsistencyProofByRootHash indicates an expected call of GetConsistencyProofByRootHash
+func (mr *MockTrillianLogServerMockRecorder) GetConsistencyProofByRootHash(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConsistencyProofByRootHash", reflect.TypeOf((*MockTrillianLogServer)(nil).GetConsistencyProofByRootHash), arg0, arg1)
+}
+
 // GetEntryAndProof mocks base method
 func (m *MockTrillianLogServer) GetEntryAndProof(arg0 context.Context, arg1 *trillian.GetEntryAndProofRequest) (*trillian.GetEntryAndProofResponse, error) {
 	m.ctrl.T.Helper()
@@ -124,6 +183,21 @@ func (mr *MockTrillianLogServerMockRecorder) GetInclusionProofByHash(arg0, arg1
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInclusionProofByHash", reflect.TypeOf((*MockTrillianLogServer)(nil).GetInclusionProofByHash), arg0, arg1)
 }
 
+// GetInclusionProofByHashBatch mocks base method
+func (m *MockTrillianLogServer) GetInclusionProofByHashBatch(arg0 context.Context, arg1 *trillian.GetInclusionProofByHashBatchRequest) (*trillian.GetInclusionProofByHashBatchResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInclusionProofByHashBatch", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetInclusionProofByHashBatchResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInclusionProofByHashBatch indicates an expected call of GetInclusionProofByHashBatch
+func (mr *MockTrillianLogServerMockRecorder) GetInclusionProofByHashBatch(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInclusionProofByHashBatch", reflect.TypeOf((*MockTrillianLogServer)(nil).GetInclusionProofByHashBatch), arg0, arg1)
+}
+
 // GetLatestSignedLogRoot mocks base method
 func (m *MockTrillianLogServer) GetLatestSignedLogRoot(arg0 context.Context, arg1 *trillian.GetLatestSignedLogRootRequest) (*trillian.GetLatestSignedLogRootResponse, error) {
 	m.ctrl.T.Helper()
@@ -184,6 +258,35 @@ func (mr *MockTrillianLogServerMockRecorder) GetLeavesByRange(arg0, arg1 interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeavesByRange", reflect.TypeOf((*MockTrillianLogServer)(nil).GetLeavesByRange), arg0, arg1)
 }
 
+// GetLeavesByRangeStream mocks base method
+func (m *MockTrillianLogServer) GetLeavesByRangeStream(arg0 *trillian.GetLeavesByRangeStreamRequest, arg1 trillian.TrillianLog_GetLeavesByRangeStreamServer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLeavesByRangeStream", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetLeavesByRangeStream indicates an expected call of GetLeavesByRangeStream
+func (mr *MockTrillianLogServerMockRecorder) GetLeavesByRangeStream(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeavesByRangeStream", reflect.TypeOf((*MockTrillianLogServer)(nil).GetLeavesByRangeStream), arg0, arg1)
+}
+
+// GetLogRootSignatures mocks base method
+func (m *MockTrillianLogServer) GetLogRootSignatures(arg0 context.Context, arg1 *trillian.GetLogRootSignaturesRequest) (*trillian.GetLogRootSignaturesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLogRootSignatures", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetLogRootSignaturesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLogRootSignatures indicates an expected call of GetLogRootSignatures
+func (mr *MockTrillianLogServerMockRecorder) GetLogRootSignatures(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLogRootSignatures", reflect.TypeOf((*MockTrillianLogServer)(nil).GetLogRootSignatures), arg0, arg1)
+}
+
 // GetSequencedLeafCount mocks base method
 func (m *MockTrillianLogServer) GetSequencedLeafCount(arg0 context.Context, arg1 *trillian.GetSequencedLeafCountRequest) (*trillian.GetSequencedLeafCountResponse, error) {
 	m.ctrl.T.Helper()
@@ -34,6 +34,65 @@ func (m *MockTrillianMapServer) EXPECT() *MockTrillianMapServerMockRecorder {
 	return m.recorder
 }
 
+// AddMapRootSignature mocks base method
+func (m *MockTrillianMapServer) AddMapRootSignature(arg0 context.Context, arg1 *trillian.AddMapRootSignatureRequest) (*trillian.AddMapRootSignatureResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMapRootSignature", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.AddMapRootSignatureResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddMapRootSignature indicates an expected call of AddMapRootSignature
+func (mr *MockTrillianMapServerMockRecorder) AddMapRootSignature(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMapRootSignature", reflect.TypeOf((*MockTrillianMapServer)(nil).AddMapRootSignature), arg0, arg1)
+}
+
+// ExportMap mocks base method
+func (m *MockTrillianMapServer) ExportMap(arg0 *trillian.ExportMapRequest, arg1 trillian.TrillianMap_ExportMapServer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportMap", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportMap indicates an expected call of ExportMap
+func (mr *MockTrillianMapServerMockRecorder) ExportMap(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportMap", reflect.TypeOf((*MockTrillianMapServer)(nil).ExportMap), arg0, arg1)
+}
+
+// GetCheckpoint mocks base method
+func (m *MockTrillianMapServer) GetCheckpoint(arg0 context.Context, arg1 *trillian.GetMapCheckpointRequest) (*trillian.GetMapCheckpointResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCheckpoint", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetMapCheckpointResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCheckpoint indicates an expected call of GetCheckpoint
+func (mr *MockTrillianMapServerMockRecorder) GetCheckpoint(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCheckpoint", reflect.TypeOf((*MockTrillianMapServer)(nil).GetCheckpoint), arg0, arg1)
+}
+
+// GetConsistencyProof mocks base method
+func (m *MockTrillianMapServer) GetConsistencyProof(arg0 context.Context, arg1 *trillian.GetMapConsistencyProofRequest) (*trillian.GetMapConsistencyProofResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConsistencyProof", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetMapConsistencyProofResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetConsistencyProof indicates an expected call of GetConsistencyProof
+func (mr *MockTrillianMapServerMockRecorder) GetConsistencyProof(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConsistencyProof", reflect.TypeOf((*MockTrillianMapServer)(nil).GetConsistencyProof), arg0, arg1)
+}
+
 // GetLastInRangeByRevision mocks base method
 func (m *MockTrillianMapServer) GetLastInRangeByRevision(arg0 context.Context, arg1 *trillian.GetLastInRangeByRevisionRequest) (*trillian.MapLeaf, error) {
 	m.ctrl.T.Helper()
@@ -64,6 +123,21 @@ func (mr *MockTrillianMapServerMockRecorder) GetLeaf(arg0, arg1 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeaf", reflect.TypeOf((*MockTrillianMapServer)(nil).GetLeaf), arg0, arg1)
 }
 
+// GetLeafByHash mocks base method
+func (m *MockTrillianMapServer) GetLeafByHash(arg0 context.Context, arg1 *trillian.GetMapLeafByHashRequest) (*trillian.GetMapLeavesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLeafByHash", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetMapLeavesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLeafByHash indicates an expected call of GetLeafByHash
+func (mr *MockTrillianMapServerMockRecorder) GetLeafByHash(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeafByHash", reflect.TypeOf((*MockTrillianMapServer)(nil).GetLeafByHash), arg0, arg1)
+}
+
 // GetLeafByRevision mocks base method
 func (m *MockTrillianMapServer) GetLeafByRevision(arg0 context.Context, arg1 *trillian.GetMapLeafByRevisionRequest) (*trillian.GetMapLeafResponse, error) {
 	m.ctrl.T.Helper()
@@ -79,6 +153,21 @@ func (mr *MockTrillianMapServerMockRecorder) GetLeafByRevision(arg0, arg1 interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeafByRevision", reflect.TypeOf((*MockTrillianMapServer)(nil).GetLeafByRevision), arg0, arg1)
 }
 
+// GetLeafHistory mocks base method
+func (m *MockTrillianMapServer) GetLeafHistory(arg0 context.Context, arg1 *trillian.GetMapLeafHistoryRequest) (*trillian.GetMapLeafHistoryResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLeafHistory", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetMapLeafHistoryResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLeafHistory indicates an expected call of GetLeafHistory
+func (mr *MockTrillianMapServerMockRecorder) GetLeafHistory(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeafHistory", reflect.TypeOf((*MockTrillianMapServer)(nil).GetLeafHistory), arg0, arg1)
+}
+
 // GetLeaves mocks base method
 func (m *MockTrillianMapServer) GetLeaves(arg0 context.Context, arg1 *trillian.GetMapLeavesRequest) (*trillian.GetMapLeavesResponse, error) {
 	m.ctrl.T.Helper()
@@ -94,6 +183,21 @@ func (mr *MockTrillianMapServerMockRecorder) GetLeaves(arg0, arg1 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeaves", reflect.TypeOf((*MockTrillianMapServer)(nil).GetLeaves), arg0, arg1)
 }
 
+// GetLeavesByIndexPrefix mocks base method
+func (m *MockTrillianMapServer) GetLeavesByIndexPrefix(arg0 context.Context, arg1 *trillian.GetMapLeavesByIndexPrefixRequest) (*trillian.GetMapLeavesByIndexPrefixResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLeavesByIndexPrefix", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetMapLeavesByIndexPrefixResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLeavesByIndexPrefix indicates an expected call of GetLeavesByIndexPrefix
+func (mr *MockTrillianMapServerMockRecorder) GetLeavesByIndexPrefix(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeavesByIndexPrefix", reflect.TypeOf((*MockTrillianMapServer)(nil).GetLeavesByIndexPrefix), arg0, arg1)
+}
+
 // GetLeavesByRevision mocks base method
 func (m *MockTrillianMapServer) GetLeavesByRevision(arg0 context.Context, arg1 *trillian.GetMapLeavesByRevisionRequest) (*trillian.GetMapLeavesResponse, error) {
 	m.ctrl.T.Helper()
@@ -124,6 +228,21 @@ func (mr *MockTrillianMapServerMockRecorder) GetLeavesByRevisionNoProof(arg0, ar
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeavesByRevisionNoProof", reflect.TypeOf((*MockTrillianMapServer)(nil).GetLeavesByRevisionNoProof), arg0, arg1)
 }
 
+// GetMapRootSignatures mocks base method
+func (m *MockTrillianMapServer) GetMapRootSignatures(arg0 context.Context, arg1 *trillian.GetMapRootSignaturesRequest) (*trillian.GetMapRootSignaturesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMapRootSignatures", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.GetMapRootSignaturesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMapRootSignatures indicates an expected call of GetMapRootSignatures
+func (mr *MockTrillianMapServerMockRecorder) GetMapRootSignatures(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMapRootSignatures", reflect.TypeOf((*MockTrillianMapServer)(nil).GetMapRootSignatures), arg0, arg1)
+}
+
 // GetSignedMapRoot mocks base method
 func (m *MockTrillianMapServer) GetSignedMapRoot(arg0 context.Context, arg1 *trillian.GetSignedMapRootRequest) (*trillian.GetSignedMapRootResponse, error) {
 	m.ctrl.T.Helper()
@@ -154,6 +273,21 @@ func (mr *MockTrillianMapServerMockRecorder) GetSignedMapRootByRevision(arg0, ar
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSignedMapRootByRevision", reflect.TypeOf((*MockTrillianMapServer)(nil).GetSignedMapRootByRevision), arg0, arg1)
 }
 
+// ImportMapRevision mocks base method
+func (m *MockTrillianMapServer) ImportMapRevision(arg0 context.Context, arg1 *trillian.ImportMapRevisionRequest) (*trillian.ImportMapRevisionResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportMapRevision", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.ImportMapRevisionResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportMapRevision indicates an expected call of ImportMapRevision
+func (mr *MockTrillianMapServerMockRecorder) ImportMapRevision(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportMapRevision", reflect.TypeOf((*MockTrillianMapServer)(nil).ImportMapRevision), arg0, arg1)
+}
+
 // InitMap mocks base method
 func (m *MockTrillianMapServer) InitMap(arg0 context.Context, arg1 *trillian.InitMapRequest) (*trillian.InitMapResponse, error) {
 	m.ctrl.T.Helper()
@@ -169,6 +303,35 @@ func (mr *MockTrillianMapServerMockRecorder) InitMap(arg0, arg1 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InitMap", reflect.TypeOf((*MockTrillianMapServer)(nil).InitMap), arg0, arg1)
 }
 
+// ListLeavesByRevision mocks base method
+func (m *MockTrillianMapServer) ListLeavesByRevision(arg0 *trillian.ListMapLeavesByRevisionRequest, arg1 trillian.TrillianMap_ListLeavesByRevisionServer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLeavesByRevision", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListLeavesByRevision indicates an expected call of ListLeavesByRevision
+func (mr *MockTrillianMapServerMockRecorder) ListLeavesByRevision(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLeavesByRevision", reflect.TypeOf((*MockTrillianMapServer)(nil).ListLeavesByRevision), arg0, arg1)
+}
+
+// ListSignedMapRoots mocks base method
+func (m *MockTrillianMapServer) ListSignedMapRoots(arg0 context.Context, arg1 *trillian.ListSignedMapRootsRequest) (*trillian.ListSignedMapRootsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSignedMapRoots", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.ListSignedMapRootsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSignedMapRoots indicates an expected call of ListSignedMapRoots
+func (mr *MockTrillianMapServerMockRecorder) ListSignedMapRoots(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSignedMapRoots", reflect.TypeOf((*MockTrillianMapServer)(nil).ListSignedMapRoots), arg0, arg1)
+}
+
 // SetLeaves mocks base method
 func (m *MockTrillianMapServer) SetLeaves(arg0 context.Context, arg1 *trillian.SetMapLeavesRequest) (*trillian.SetMapLeavesResponse, error) {
 	m.ctrl.T.Helper()
@@ -183,3 +346,32 @@ func (mr *MockTrillianMapServerMockRecorder) SetLeaves(arg0, arg1 interface{}) *
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLeaves", reflect.TypeOf((*MockTrillianMapServer)(nil).SetLeaves), arg0, arg1)
 }
+
+// SetMultiMapLeaves mocks base method
+func (m *MockTrillianMapServer) SetMultiMapLeaves(arg0 context.Context, arg1 *trillian.SetMultiMapLeavesRequest) (*trillian.SetMultiMapLeavesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMultiMapLeaves", arg0, arg1)
+	ret0, _ := ret[0].(*trillian.SetMultiMapLeavesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetMultiMapLeaves indicates an expected call of SetMultiMapLeaves
+func (mr *MockTrillianMapServerMockRecorder) SetMultiMapLeaves(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMultiMapLeaves", reflect.TypeOf((*MockTrillianMapServer)(nil).SetMultiMapLeaves), arg0, arg1)
+}
+
+// WatchSignedMapRoots mocks base method
+func (m *MockTrillianMapServer) WatchSignedMapRoots(arg0 *trillian.WatchSignedMapRootsRequest, arg1 trillian.TrillianMap_WatchSignedMapRootsServer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchSignedMapRoots", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WatchSignedMapRoots indicates an expected call of WatchSignedMapRoots
+func (mr *MockTrillianMapServerMockRecorder) WatchSignedMapRoots(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchSignedMapRoots", reflect.TypeOf((*MockTrillianMapServer)(nil).WatchSignedMapRoots), arg0, arg1)
+}
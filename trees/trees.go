@@ -22,6 +22,7 @@ import (
 	"fmt"
 
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto/keys"
 	"github.com/google/trillian/crypto/sigpb"
@@ -56,6 +57,8 @@ var rules = map[OpType]accessRule{
 			trillian.TreeState_ACTIVE:             true,
 			trillian.TreeState_DRAINING:           true,
 			trillian.TreeState_FROZEN:             true,
+			trillian.TreeState_QUARANTINED:        true,
+			trillian.TreeState_PAUSED:             true,
 		},
 		okTypes: map[trillian.TreeType]bool{
 			trillian.TreeType_LOG:            true,
@@ -71,6 +74,8 @@ var rules = map[OpType]accessRule{
 			trillian.TreeState_ACTIVE:             true,
 			trillian.TreeState_DRAINING:           true,
 			trillian.TreeState_FROZEN:             true,
+			trillian.TreeState_QUARANTINED:        true,
+			trillian.TreeState_PAUSED:             true,
 		},
 		okTypes: map[trillian.TreeType]bool{
 			trillian.TreeType_LOG:            true,
@@ -81,10 +86,12 @@ var rules = map[OpType]accessRule{
 	QueueLog: {
 		okStates: map[trillian.TreeState]bool{
 			trillian.TreeState_ACTIVE: true,
+			trillian.TreeState_PAUSED: true,
 		},
 		rejectCodes: map[trillian.TreeState]codes.Code{
-			trillian.TreeState_DRAINING: codes.PermissionDenied,
-			trillian.TreeState_FROZEN:   codes.PermissionDenied,
+			trillian.TreeState_DRAINING:    codes.PermissionDenied,
+			trillian.TreeState_FROZEN:      codes.PermissionDenied,
+			trillian.TreeState_QUARANTINED: codes.PermissionDenied,
 		},
 		okTypes: map[trillian.TreeType]bool{
 			trillian.TreeType_LOG:            true,
@@ -101,7 +108,9 @@ var rules = map[OpType]accessRule{
 			trillian.TreeType_PREORDERED_LOG: true,
 		},
 		rejectCodes: map[trillian.TreeState]codes.Code{
-			trillian.TreeState_FROZEN: codes.PermissionDenied,
+			trillian.TreeState_FROZEN:      codes.PermissionDenied,
+			trillian.TreeState_QUARANTINED: codes.PermissionDenied,
+			trillian.TreeState_PAUSED:      codes.PermissionDenied,
 		},
 	},
 	UpdateMap: {
@@ -111,6 +120,9 @@ var rules = map[OpType]accessRule{
 		okTypes: map[trillian.TreeType]bool{
 			trillian.TreeType_MAP: true,
 		},
+		rejectCodes: map[trillian.TreeState]codes.Code{
+			trillian.TreeState_QUARANTINED: codes.PermissionDenied,
+		},
 	},
 }
 
@@ -156,7 +168,7 @@ func validate(o GetOpts, tree *trillian.Tree) error {
 // The tree will be validated according to GetOpts before returned. Tree state is also considered
 // (for example, deleted tree will return NotFound errors).
 func GetTree(ctx context.Context, s storage.AdminStorage, treeID int64, opts GetOpts) (*trillian.Tree, error) {
-	ctx, spanEnd := spanFor(ctx, "GetTree")
+	ctx, spanEnd := spanFor(ctx, "GetTree", monitoring.Int64Attribute(monitoring.TreeIDKey, treeID))
 	defer spanEnd()
 	tree, ok := FromContext(ctx)
 	if !ok {
@@ -204,9 +216,34 @@ func Signer(ctx context.Context, tree *trillian.Tree) (*tcrypto.Signer, error) {
 		return nil, err
 	}
 
+	signer, err := keySigner(ctx, tree, tree.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("tree.PrivateKey: %v", err)
+	}
+	s := tcrypto.NewSigner(tree.GetTreeId(), signer, hash)
+
+	for i, key := range tree.AdditionalPrivateKeys {
+		cosigner, err := keySigner(ctx, tree, key)
+		if err != nil {
+			return nil, fmt.Errorf("tree.AdditionalPrivateKeys[%d]: %v", i, err)
+		}
+		s.Cosigners = append(s.Cosigners, cosigner)
+	}
+	s.Threshold = int(tree.SignatureThreshold)
+
+	if rotation := tree.KeyRotation; rotation != nil {
+		if s.NextSigner, err = keySigner(ctx, tree, rotation.PrivateKey); err != nil {
+			return nil, fmt.Errorf("tree.KeyRotation.PrivateKey: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// keySigner returns a signer for one of the private keys of the tree.
+func keySigner(ctx context.Context, tree *trillian.Tree, key *any.Any) (crypto.Signer, error) {
 	var keyProto ptypes.DynamicAny
-	if err := ptypes.UnmarshalAny(tree.PrivateKey, &keyProto); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal tree.PrivateKey: %v", err)
+	if err := ptypes.UnmarshalAny(key, &keyProto); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal private key: %v", err)
 	}
 
 	signer, err := keys.NewSigner(ctx, keyProto.Message)
@@ -217,10 +254,9 @@ func Signer(ctx context.Context, tree *trillian.Tree) (*tcrypto.Signer, error) {
 	if tcrypto.SignatureAlgorithm(signer.Public()) != tree.SignatureAlgorithm {
 		return nil, fmt.Errorf("%s signature not supported by signer of type %T", tree.SignatureAlgorithm, signer)
 	}
-
-	return tcrypto.NewSigner(tree.GetTreeId(), signer, hash), nil
+	return signer, nil
 }
 
-func spanFor(ctx context.Context, name string) (context.Context, func()) {
-	return monitoring.StartSpan(ctx, fmt.Sprintf("%s.%s", traceSpanRoot, name))
+func spanFor(ctx context.Context, name string, attrs ...monitoring.Attribute) (context.Context, func()) {
+	return monitoring.StartSpan(ctx, fmt.Sprintf("%s.%s", traceSpanRoot, name), attrs...)
 }
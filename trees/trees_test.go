@@ -28,6 +28,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto/keys"
 	"github.com/google/trillian/crypto/sigpb"
@@ -78,6 +79,18 @@ func TestGetTree(t *testing.T) {
 	drainingTree.TreeId = 3
 	drainingTree.TreeState = trillian.TreeState_DRAINING
 
+	quarantinedTree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	quarantinedTree.TreeId = 3
+	quarantinedTree.TreeState = trillian.TreeState_QUARANTINED
+
+	quarantinedMap := proto.Clone(testonly.MapTree).(*trillian.Tree)
+	quarantinedMap.TreeId = 4
+	quarantinedMap.TreeState = trillian.TreeState_QUARANTINED
+
+	pausedTree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	pausedTree.TreeId = 5
+	pausedTree.TreeState = trillian.TreeState_PAUSED
+
 	softDeletedTree := proto.Clone(testonly.LogTree).(*trillian.Tree)
 	softDeletedTree.Deleted = true
 	softDeletedTree.DeleteTime = ptypes.TimestampNow()
@@ -247,6 +260,66 @@ func TestGetTree(t *testing.T) {
 			wantErr:     true,
 			code:        codes.PermissionDenied,
 		},
+		{
+			desc:        "adminQuarantined",
+			treeID:      quarantinedTree.TreeId,
+			opts:        NewGetOpts(Admin, trillian.TreeType_LOG),
+			storageTree: quarantinedTree,
+			wantTree:    quarantinedTree,
+		},
+		{
+			desc:        "queryQuarantined",
+			treeID:      quarantinedTree.TreeId,
+			opts:        NewGetOpts(Query, trillian.TreeType_LOG),
+			storageTree: quarantinedTree,
+			wantTree:    quarantinedTree,
+		},
+		{
+			desc:        "sequenceQuarantined",
+			treeID:      quarantinedTree.TreeId,
+			opts:        NewGetOpts(SequenceLog, trillian.TreeType_LOG),
+			storageTree: quarantinedTree,
+			wantErr:     true,
+			code:        codes.PermissionDenied,
+		},
+		{
+			desc:        "queueQuarantined",
+			treeID:      quarantinedTree.TreeId,
+			opts:        NewGetOpts(QueueLog, trillian.TreeType_LOG),
+			storageTree: quarantinedTree,
+			wantErr:     true,
+			code:        codes.PermissionDenied,
+		},
+		{
+			desc:        "updateQuarantinedMap",
+			treeID:      quarantinedMap.TreeId,
+			opts:        NewGetOpts(UpdateMap, trillian.TreeType_MAP),
+			storageTree: quarantinedMap,
+			wantErr:     true,
+			code:        codes.PermissionDenied,
+		},
+		{
+			desc:        "queryPaused",
+			treeID:      pausedTree.TreeId,
+			opts:        NewGetOpts(Query, trillian.TreeType_LOG),
+			storageTree: pausedTree,
+			wantTree:    pausedTree,
+		},
+		{
+			desc:        "queuePaused",
+			treeID:      pausedTree.TreeId,
+			opts:        NewGetOpts(QueueLog, trillian.TreeType_LOG),
+			storageTree: pausedTree,
+			wantTree:    pausedTree,
+		},
+		{
+			desc:        "sequencePaused",
+			treeID:      pausedTree.TreeId,
+			opts:        NewGetOpts(SequenceLog, trillian.TreeType_LOG),
+			storageTree: pausedTree,
+			wantErr:     true,
+			code:        codes.PermissionDenied,
+		},
 		{
 			desc:        "softDeleted",
 			treeID:      softDeletedTree.TreeId,
@@ -448,3 +521,66 @@ func TestSigner(t *testing.T) {
 		})
 	}
 }
+
+func TestSigner_AdditionalKeys(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating test ECDSA key: %v", err)
+	}
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.AdditionalPrivateKeys = []*any.Any{tree.PrivateKey, tree.PrivateKey}
+	tree.SignatureThreshold = 2
+
+	var keyProto ptypes.DynamicAny
+	if err := ptypes.UnmarshalAny(tree.PrivateKey, &keyProto); err != nil {
+		t.Fatalf("failed to unmarshal tree.PrivateKey: %v", err)
+	}
+	keys.RegisterHandler(keyProto.Message, func(ctx context.Context, _ proto.Message) (crypto.Signer, error) {
+		return ecdsaKey, nil
+	})
+	defer keys.UnregisterHandler(keyProto.Message)
+
+	signer, err := Signer(context.Background(), tree)
+	if err != nil {
+		t.Fatalf("Signer() = (_, %v), want nil error", err)
+	}
+	if got, want := len(signer.Cosigners), 2; got != want {
+		t.Errorf("Signer() has %d cosigners, want %d", got, want)
+	}
+	if got, want := signer.Threshold, 2; got != want {
+		t.Errorf("Signer().Threshold = %d, want %d", got, want)
+	}
+}
+
+func TestSigner_KeyRotation(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating test ECDSA key: %v", err)
+	}
+
+	tree := proto.Clone(testonly.LogTree).(*trillian.Tree)
+	tree.KeyRotation = &trillian.KeyRotation{PrivateKey: tree.PrivateKey}
+
+	var keyProto ptypes.DynamicAny
+	if err := ptypes.UnmarshalAny(tree.PrivateKey, &keyProto); err != nil {
+		t.Fatalf("failed to unmarshal tree.PrivateKey: %v", err)
+	}
+	keys.RegisterHandler(keyProto.Message, func(ctx context.Context, _ proto.Message) (crypto.Signer, error) {
+		return ecdsaKey, nil
+	})
+	defer keys.UnregisterHandler(keyProto.Message)
+
+	signer, err := Signer(context.Background(), tree)
+	if err != nil {
+		t.Fatalf("Signer() = (_, %v), want nil error", err)
+	}
+	if signer.NextSigner != ecdsaKey {
+		t.Errorf("Signer().NextSigner = %v, want the key of the rotation", signer.NextSigner)
+	}
+
+	tree.KeyRotation.PrivateKey = nil
+	if _, err := Signer(context.Background(), tree); err == nil {
+		t.Error("Signer() with an invalid rotation key returned nil error")
+	}
+}
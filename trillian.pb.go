@@ -150,6 +150,16 @@ const (
 	// A tree that is draining will continue to integrate queued entries.
 	// No new entries should be accepted.
 	TreeState_DRAINING TreeState = 5
+	// A quarantined tree failed an integrity check, e.g. by a scrubber, and is
+	// only able to respond to read requests, like a frozen tree. Queued entries
+	// are not integrated. A tree leaves this state only through the
+	// TrillianAdmin.LiftQuarantine RPC.
+	TreeState_QUARANTINED TreeState = 6
+	// A paused log is able to respond to both read and write requests, but
+	// queued entries are not integrated until it is resumed. Logs are paused and
+	// resumed through the TrillianAdmin.PauseSequencing and ResumeSequencing RPCs,
+	// e.g. to freeze integration during an incident.
+	TreeState_PAUSED TreeState = 7
 )
 
 var TreeState_name = map[int32]string{
@@ -159,6 +169,8 @@ var TreeState_name = map[int32]string{
 	3: "DEPRECATED_SOFT_DELETED",
 	4: "DEPRECATED_HARD_DELETED",
 	5: "DRAINING",
+	6: "QUARANTINED",
+	7: "PAUSED",
 }
 
 var TreeState_value = map[string]int32{
@@ -168,6 +180,8 @@ var TreeState_value = map[string]int32{
 	"DEPRECATED_SOFT_DELETED": 3,
 	"DEPRECATED_HARD_DELETED": 4,
 	"DRAINING":                5,
+	"QUARANTINED":             6,
+	"PAUSED":                  7,
 }
 
 func (x TreeState) String() string {
@@ -216,6 +230,50 @@ func (TreeType) EnumDescriptor() ([]byte, []int) {
 	return fileDescriptor_364603a4e17a2a56, []int{4}
 }
 
+// DuplicateLeafPolicy says how a log handles queued leaves which duplicate a
+// leaf already in it, i.e. which have the same leaf_identity_hash. It is
+// applied by the log server, so it behaves the same with all storage
+// implementations.
+type DuplicateLeafPolicy int32
+
+const (
+	// Same as REJECT_DUPLICATES.
+	DuplicateLeafPolicy_DUPLICATE_LEAF_POLICY_UNSPECIFIED DuplicateLeafPolicy = 0
+	// Duplicates are not queued, and their QueuedLogLeaf has an ALREADY_EXISTS
+	// status and the original leaf.
+	DuplicateLeafPolicy_REJECT_DUPLICATES DuplicateLeafPolicy = 1
+	// Duplicates are not queued, and their QueuedLogLeaf has an OK status and
+	// the original leaf, including its leaf_index and integrate_timestamp if it
+	// is already sequenced.
+	DuplicateLeafPolicy_DEDUPLICATE DuplicateLeafPolicy = 2
+	// Duplicates are queued and sequenced like new leaves, so the log can hold
+	// the same leaf more than once. The leaf_identity_hash of queued leaves is
+	// replaced by a unique one.
+	DuplicateLeafPolicy_ALLOW_DUPLICATES DuplicateLeafPolicy = 3
+)
+
+var DuplicateLeafPolicy_name = map[int32]string{
+	0: "DUPLICATE_LEAF_POLICY_UNSPECIFIED",
+	1: "REJECT_DUPLICATES",
+	2: "DEDUPLICATE",
+	3: "ALLOW_DUPLICATES",
+}
+
+var DuplicateLeafPolicy_value = map[string]int32{
+	"DUPLICATE_LEAF_POLICY_UNSPECIFIED": 0,
+	"REJECT_DUPLICATES":                 1,
+	"DEDUPLICATE":                       2,
+	"ALLOW_DUPLICATES":                  3,
+}
+
+func (x DuplicateLeafPolicy) String() string {
+	return proto.EnumName(DuplicateLeafPolicy_name, int32(x))
+}
+
+func (DuplicateLeafPolicy) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_364603a4e17a2a56, []int{5}
+}
+
 // Represents a tree, which may be either a verifiable log or map.
 // Readonly attributes are assigned at tree creation, after which they may not
 // be modified.
@@ -229,7 +287,9 @@ type Tree struct {
 	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId,proto3" json:"tree_id,omitempty"`
 	// State of the tree.
 	// Trees are ACTIVE after creation. At any point the tree may transition
-	// between ACTIVE, DRAINING and FROZEN states.
+	// between ACTIVE, DRAINING and FROZEN states, or into the QUARANTINED
+	// state. A QUARANTINED tree may only leave it through LiftQuarantine. Logs
+	// may also move between the ACTIVE and PAUSED states.
 	TreeState TreeState `protobuf:"varint,2,opt,name=tree_state,json=treeState,proto3,enum=trillian.TreeState" json:"tree_state,omitempty"`
 	// Type of the tree.
 	// Readonly after Tree creation. Exception: Can be switched from
@@ -281,10 +341,67 @@ type Tree struct {
 	Deleted bool `protobuf:"varint,19,opt,name=deleted,proto3" json:"deleted,omitempty"`
 	// Time of tree deletion, if any.
 	// Readonly.
-	DeleteTime           *timestamp.Timestamp `protobuf:"bytes,20,opt,name=delete_time,json=deleteTime,proto3" json:"delete_time,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
-	XXX_unrecognized     []byte               `json:"-"`
-	XXX_sizecache        int32                `json:"-"`
+	DeleteTime *timestamp.Timestamp `protobuf:"bytes,20,opt,name=delete_time,json=deleteTime,proto3" json:"delete_time,omitempty"`
+	// Policy for garbage collecting old revisions of the tree.
+	// Only valid for MAP trees. If unset, all revisions are kept.
+	// Optional.
+	RevisionRetentionPolicy *RevisionRetentionPolicy `protobuf:"bytes,21,opt,name=revision_retention_policy,json=revisionRetentionPolicy,proto3" json:"revision_retention_policy,omitempty"`
+	// Number of bits of the indices of the leaves of a map, which is also the
+	// height of the map. It must be a multiple of 8, and at most the bit length
+	// of the hash_strategy. Smaller indices make proofs shorter, and the map
+	// store fewer nodes. If zero, indices are as long as the hashes.
+	// Only valid for MAP trees. Readonly.
+	MapIndexBits int32 `protobuf:"varint,22,opt,name=map_index_bits,json=mapIndexBits,proto3" json:"map_index_bits,omitempty"`
+	// How the log handles queued leaves with the same leaf_identity_hash as a
+	// leaf already in it. Only valid for LOG trees.
+	// Optional.
+	DuplicateLeafPolicy DuplicateLeafPolicy `protobuf:"varint,23,opt,name=duplicate_leaf_policy,json=duplicateLeafPolicy,proto3,enum=trillian.DuplicateLeafPolicy" json:"duplicate_leaf_policy,omitempty"`
+	// Identifies additional private keys which sign tree heads along with
+	// private_key, e.g. to split the custody of the identity of a log between
+	// several parties. Their signatures are in the additional_signatures of
+	// signed roots. They must use the signature_algorithm of the tree.
+	// Private keys are write-only: they're never returned by RPCs.
+	// Readonly after tree creation.
+	AdditionalPrivateKeys []*any.Any `protobuf:"bytes,24,rep,name=additional_private_keys,json=additionalPrivateKeys,proto3" json:"additional_private_keys,omitempty"`
+	// The public keys which verify the signatures of additional_private_keys,
+	// in the same order.
+	// Readonly.
+	AdditionalPublicKeys []*keyspb.PublicKey `protobuf:"bytes,25,rep,name=additional_public_keys,json=additionalPublicKeys,proto3" json:"additional_public_keys,omitempty"`
+	// Number of valid signatures, by private_key and additional_private_keys,
+	// which signed roots must carry. The signature of private_key is always
+	// required, whatever the threshold: signers tolerate failures of the
+	// additional keys only, whose signatures are left empty. Zero means all of
+	// the keys.
+	// Readonly after tree creation.
+	SignatureThreshold int32 `protobuf:"varint,26,opt,name=signature_threshold,json=signatureThreshold,proto3" json:"signature_threshold,omitempty"`
+	// Labels tag the tree for operators' tooling, e.g. with the customer,
+	// environment or billing code it belongs to. Keys must be non-empty and at
+	// most 63 bytes long, and values at most 255 bytes long. ListTrees can
+	// filter trees by their labels.
+	// Optional.
+	Labels map[string]string `protobuf:"bytes,27,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Minimum period the tree remains soft-deleted, and may be undeleted,
+	// before the deleted tree GC permanently deletes it. If unset, the
+	// retention configured on the server applies.
+	// Optional.
+	DeleteRetention *duration.Duration `protobuf:"bytes,28,opt,name=delete_retention,json=deleteRetention,proto3" json:"delete_retention,omitempty"`
+	// The rotation of private_key to a new key in progress, if any. It can only
+	// be started by RotateTreeKey.
+	// Readonly.
+	KeyRotation *KeyRotation `protobuf:"bytes,29,opt,name=key_rotation,json=keyRotation,proto3" json:"key_rotation,omitempty"`
+	// Rates at which requests to the tree may spend quota tokens, which
+	// throttle the tree on top of the quotas configured on the server. If
+	// unset, only the latter apply.
+	// Optional.
+	Quota *TreeQuota `protobuf:"bytes,30,opt,name=quota,proto3" json:"quota,omitempty"`
+	// Principal which owns the tree, e.g. the name of a tenant. Servers which
+	// enforce tree ownership only serve requests for the tree from its owner
+	// and from superusers. Only superusers may change it.
+	// Optional.
+	Owner                string   `protobuf:"bytes,31,opt,name=owner,proto3" json:"owner,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *Tree) Reset()         { *m = Tree{} }
@@ -424,6 +541,255 @@ func (m *Tree) GetDeleteTime() *timestamp.Timestamp {
 	return nil
 }
 
+func (m *Tree) GetRevisionRetentionPolicy() *RevisionRetentionPolicy {
+	if m != nil {
+		return m.RevisionRetentionPolicy
+	}
+	return nil
+}
+
+func (m *Tree) GetMapIndexBits() int32 {
+	if m != nil {
+		return m.MapIndexBits
+	}
+	return 0
+}
+
+func (m *Tree) GetDuplicateLeafPolicy() DuplicateLeafPolicy {
+	if m != nil {
+		return m.DuplicateLeafPolicy
+	}
+	return DuplicateLeafPolicy_DUPLICATE_LEAF_POLICY_UNSPECIFIED
+}
+
+func (m *Tree) GetAdditionalPrivateKeys() []*any.Any {
+	if m != nil {
+		return m.AdditionalPrivateKeys
+	}
+	return nil
+}
+
+func (m *Tree) GetAdditionalPublicKeys() []*keyspb.PublicKey {
+	if m != nil {
+		return m.AdditionalPublicKeys
+	}
+	return nil
+}
+
+func (m *Tree) GetSignatureThreshold() int32 {
+	if m != nil {
+		return m.SignatureThreshold
+	}
+	return 0
+}
+
+func (m *Tree) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *Tree) GetDeleteRetention() *duration.Duration {
+	if m != nil {
+		return m.DeleteRetention
+	}
+	return nil
+}
+
+func (m *Tree) GetKeyRotation() *KeyRotation {
+	if m != nil {
+		return m.KeyRotation
+	}
+	return nil
+}
+
+func (m *Tree) GetQuota() *TreeQuota {
+	if m != nil {
+		return m.Quota
+	}
+	return nil
+}
+
+func (m *Tree) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+// TreeQuota holds the rates at which requests to a tree may spend quota
+// tokens. Each request spends the tokens it is charged by the server, e.g. one
+// per read request and one per leaf queued to a log. Zero rates are unlimited.
+type TreeQuota struct {
+	// Tokens per second spent by non-modifying requests.
+	ReadTokensPerSecond int64 `protobuf:"varint,1,opt,name=read_tokens_per_second,json=readTokensPerSecond,proto3" json:"read_tokens_per_second,omitempty"`
+	// Tokens per second spent by modifying requests.
+	WriteTokensPerSecond int64    `protobuf:"varint,2,opt,name=write_tokens_per_second,json=writeTokensPerSecond,proto3" json:"write_tokens_per_second,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TreeQuota) Reset()         { *m = TreeQuota{} }
+func (m *TreeQuota) String() string { return proto.CompactTextString(m) }
+func (*TreeQuota) ProtoMessage()    {}
+func (*TreeQuota) Descriptor() ([]byte, []int) {
+	return fileDescriptor_364603a4e17a2a56, []int{1}
+}
+
+func (m *TreeQuota) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TreeQuota.Unmarshal(m, b)
+}
+func (m *TreeQuota) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TreeQuota.Marshal(b, m, deterministic)
+}
+func (m *TreeQuota) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TreeQuota.Merge(m, src)
+}
+func (m *TreeQuota) XXX_Size() int {
+	return xxx_messageInfo_TreeQuota.Size(m)
+}
+func (m *TreeQuota) XXX_DiscardUnknown() {
+	xxx_messageInfo_TreeQuota.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TreeQuota proto.InternalMessageInfo
+
+func (m *TreeQuota) GetReadTokensPerSecond() int64 {
+	if m != nil {
+		return m.ReadTokensPerSecond
+	}
+	return 0
+}
+
+func (m *TreeQuota) GetWriteTokensPerSecond() int64 {
+	if m != nil {
+		return m.WriteTokensPerSecond
+	}
+	return 0
+}
+
+// KeyRotation describes the rotation of the private_key of a tree to a new
+// key. Until retire_time, roots are signed by both keys: the signature of the
+// new key is appended to their additional_signatures, after those of the
+// additional_private_keys. After retire_time, the new key replaces
+// private_key and public_key, and the old key is no longer used.
+type KeyRotation struct {
+	// Identifies the new private key. Private keys are write-only: they're
+	// never returned by RPCs.
+	PrivateKey *any.Any `protobuf:"bytes,1,opt,name=private_key,json=privateKey,proto3" json:"private_key,omitempty"`
+	// The public key which verifies the signatures of private_key.
+	PublicKey *keyspb.PublicKey `protobuf:"bytes,2,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	// Time after which the new key replaces the old one.
+	RetireTime           *timestamp.Timestamp `protobuf:"bytes,3,opt,name=retire_time,json=retireTime,proto3" json:"retire_time,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *KeyRotation) Reset()         { *m = KeyRotation{} }
+func (m *KeyRotation) String() string { return proto.CompactTextString(m) }
+func (*KeyRotation) ProtoMessage()    {}
+func (*KeyRotation) Descriptor() ([]byte, []int) {
+	return fileDescriptor_364603a4e17a2a56, []int{2}
+}
+
+func (m *KeyRotation) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_KeyRotation.Unmarshal(m, b)
+}
+func (m *KeyRotation) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_KeyRotation.Marshal(b, m, deterministic)
+}
+func (m *KeyRotation) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_KeyRotation.Merge(m, src)
+}
+func (m *KeyRotation) XXX_Size() int {
+	return xxx_messageInfo_KeyRotation.Size(m)
+}
+func (m *KeyRotation) XXX_DiscardUnknown() {
+	xxx_messageInfo_KeyRotation.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_KeyRotation proto.InternalMessageInfo
+
+func (m *KeyRotation) GetPrivateKey() *any.Any {
+	if m != nil {
+		return m.PrivateKey
+	}
+	return nil
+}
+
+func (m *KeyRotation) GetPublicKey() *keyspb.PublicKey {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *KeyRotation) GetRetireTime() *timestamp.Timestamp {
+	if m != nil {
+		return m.RetireTime
+	}
+	return nil
+}
+
+// RevisionRetentionPolicy describes which revisions of a map are kept.
+// A revision is kept if it is one of the keep_revisions most recent ones, or if
+// a later revision was published less than keep_duration ago. Other revisions
+// may be garbage collected, after which they can no longer be read. The latest
+// revision is always kept.
+type RevisionRetentionPolicy struct {
+	// Number of most recent revisions to keep. If zero, revisions are only kept
+	// according to keep_duration.
+	KeepRevisions int64 `protobuf:"varint,1,opt,name=keep_revisions,json=keepRevisions,proto3" json:"keep_revisions,omitempty"`
+	// Period for which revisions remain readable after they are superseded. If
+	// unset or zero, revisions are only kept according to keep_revisions.
+	KeepDuration         *duration.Duration `protobuf:"bytes,2,opt,name=keep_duration,json=keepDuration,proto3" json:"keep_duration,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *RevisionRetentionPolicy) Reset()         { *m = RevisionRetentionPolicy{} }
+func (m *RevisionRetentionPolicy) String() string { return proto.CompactTextString(m) }
+func (*RevisionRetentionPolicy) ProtoMessage()    {}
+func (*RevisionRetentionPolicy) Descriptor() ([]byte, []int) {
+	return fileDescriptor_364603a4e17a2a56, []int{3}
+}
+
+func (m *RevisionRetentionPolicy) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevisionRetentionPolicy.Unmarshal(m, b)
+}
+func (m *RevisionRetentionPolicy) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevisionRetentionPolicy.Marshal(b, m, deterministic)
+}
+func (m *RevisionRetentionPolicy) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevisionRetentionPolicy.Merge(m, src)
+}
+func (m *RevisionRetentionPolicy) XXX_Size() int {
+	return xxx_messageInfo_RevisionRetentionPolicy.Size(m)
+}
+func (m *RevisionRetentionPolicy) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevisionRetentionPolicy.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevisionRetentionPolicy proto.InternalMessageInfo
+
+func (m *RevisionRetentionPolicy) GetKeepRevisions() int64 {
+	if m != nil {
+		return m.KeepRevisions
+	}
+	return 0
+}
+
+func (m *RevisionRetentionPolicy) GetKeepDuration() *duration.Duration {
+	if m != nil {
+		return m.KeepDuration
+	}
+	return nil
+}
+
 type SignedEntryTimestamp struct {
 	TimestampNanos       int64                  `protobuf:"varint,1,opt,name=timestamp_nanos,json=timestampNanos,proto3" json:"timestamp_nanos,omitempty"`
 	LogId                int64                  `protobuf:"varint,2,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
@@ -437,7 +803,7 @@ func (m *SignedEntryTimestamp) Reset()         { *m = SignedEntryTimestamp{} }
 func (m *SignedEntryTimestamp) String() string { return proto.CompactTextString(m) }
 func (*SignedEntryTimestamp) ProtoMessage()    {}
 func (*SignedEntryTimestamp) Descriptor() ([]byte, []int) {
-	return fileDescriptor_364603a4e17a2a56, []int{1}
+	return fileDescriptor_364603a4e17a2a56, []int{4}
 }
 
 func (m *SignedEntryTimestamp) XXX_Unmarshal(b []byte) error {
@@ -525,7 +891,12 @@ type SignedLogRoot struct {
 	// (with all integers encoded big-endian).
 	LogRoot []byte `protobuf:"bytes,8,opt,name=log_root,json=logRoot,proto3" json:"log_root,omitempty"`
 	// log_root_signature is the raw signature over log_root.
-	LogRootSignature     []byte   `protobuf:"bytes,9,opt,name=log_root_signature,json=logRootSignature,proto3" json:"log_root_signature,omitempty"`
+	LogRootSignature []byte `protobuf:"bytes,9,opt,name=log_root_signature,json=logRootSignature,proto3" json:"log_root_signature,omitempty"`
+	// additional_signatures are the raw signatures over log_root by the
+	// additional_private_keys of the tree, in the same order. The signatures of
+	// keys which failed to sign are empty. During a key rotation, they end with
+	// the signature of the new key.
+	AdditionalSignatures [][]byte `protobuf:"bytes,10,rep,name=additional_signatures,json=additionalSignatures,proto3" json:"additional_signatures,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -535,7 +906,7 @@ func (m *SignedLogRoot) Reset()         { *m = SignedLogRoot{} }
 func (m *SignedLogRoot) String() string { return proto.CompactTextString(m) }
 func (*SignedLogRoot) ProtoMessage()    {}
 func (*SignedLogRoot) Descriptor() ([]byte, []int) {
-	return fileDescriptor_364603a4e17a2a56, []int{2}
+	return fileDescriptor_364603a4e17a2a56, []int{5}
 }
 
 func (m *SignedLogRoot) XXX_Unmarshal(b []byte) error {
@@ -577,6 +948,13 @@ func (m *SignedLogRoot) GetLogRootSignature() []byte {
 	return nil
 }
 
+func (m *SignedLogRoot) GetAdditionalSignatures() [][]byte {
+	if m != nil {
+		return m.AdditionalSignatures
+	}
+	return nil
+}
+
 // SignedMapRoot represents a commitment by a Map to a particular tree.
 type SignedMapRoot struct {
 	// map_root holds the TLS-serialization of the following structure (described
@@ -597,7 +975,12 @@ type SignedMapRoot struct {
 	// } MapRoot;
 	MapRoot []byte `protobuf:"bytes,9,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
 	// Signature is the raw signature over MapRoot.
-	Signature            []byte   `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	Signature []byte `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	// additional_signatures are the raw signatures over MapRoot by the
+	// additional_private_keys of the tree, in the same order. The signatures of
+	// keys which failed to sign are empty. During a key rotation, they end with
+	// the signature of the new key.
+	AdditionalSignatures [][]byte `protobuf:"bytes,10,rep,name=additional_signatures,json=additionalSignatures,proto3" json:"additional_signatures,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -607,7 +990,7 @@ func (m *SignedMapRoot) Reset()         { *m = SignedMapRoot{} }
 func (m *SignedMapRoot) String() string { return proto.CompactTextString(m) }
 func (*SignedMapRoot) ProtoMessage()    {}
 func (*SignedMapRoot) Descriptor() ([]byte, []int) {
-	return fileDescriptor_364603a4e17a2a56, []int{3}
+	return fileDescriptor_364603a4e17a2a56, []int{6}
 }
 
 func (m *SignedMapRoot) XXX_Unmarshal(b []byte) error {
@@ -642,13 +1025,25 @@ func (m *SignedMapRoot) GetSignature() []byte {
 	return nil
 }
 
+func (m *SignedMapRoot) GetAdditionalSignatures() [][]byte {
+	if m != nil {
+		return m.AdditionalSignatures
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("trillian.LogRootFormat", LogRootFormat_name, LogRootFormat_value)
 	proto.RegisterEnum("trillian.MapRootFormat", MapRootFormat_name, MapRootFormat_value)
 	proto.RegisterEnum("trillian.HashStrategy", HashStrategy_name, HashStrategy_value)
 	proto.RegisterEnum("trillian.TreeState", TreeState_name, TreeState_value)
 	proto.RegisterEnum("trillian.TreeType", TreeType_name, TreeType_value)
+	proto.RegisterEnum("trillian.DuplicateLeafPolicy", DuplicateLeafPolicy_name, DuplicateLeafPolicy_value)
 	proto.RegisterType((*Tree)(nil), "trillian.Tree")
+	proto.RegisterMapType((map[string]string)(nil), "trillian.Tree.LabelsEntry")
+	proto.RegisterType((*TreeQuota)(nil), "trillian.TreeQuota")
+	proto.RegisterType((*KeyRotation)(nil), "trillian.KeyRotation")
+	proto.RegisterType((*RevisionRetentionPolicy)(nil), "trillian.RevisionRetentionPolicy")
 	proto.RegisterType((*SignedEntryTimestamp)(nil), "trillian.SignedEntryTimestamp")
 	proto.RegisterType((*SignedLogRoot)(nil), "trillian.SignedLogRoot")
 	proto.RegisterType((*SignedMapRoot)(nil), "trillian.SignedMapRoot")
@@ -657,71 +1052,105 @@ func init() {
 func init() { proto.RegisterFile("trillian.proto", fileDescriptor_364603a4e17a2a56) }
 
 var fileDescriptor_364603a4e17a2a56 = []byte{
-	// 1052 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x55, 0x5b, 0x6f, 0xe2, 0x46,
-	0x14, 0x5e, 0x83, 0x01, 0x33, 0x5c, 0x32, 0x99, 0xec, 0xc5, 0xa1, 0x55, 0x97, 0x46, 0x95, 0x4a,
-	0xa3, 0x8a, 0x74, 0x69, 0x13, 0xa9, 0xda, 0x87, 0xca, 0x09, 0x4e, 0x80, 0x24, 0x80, 0xc6, 0xee,
-	0x56, 0x9b, 0x97, 0x91, 0x81, 0xa9, 0xb1, 0xe2, 0x9b, 0xec, 0xa1, 0x5a, 0xff, 0x84, 0xaa, 0x7d,
-	0xdf, 0xbf, 0x5b, 0xcd, 0xf8, 0x42, 0x42, 0x76, 0xbb, 0x2f, 0xc9, 0x9c, 0xf3, 0x5d, 0xce, 0x19,
-	0xcf, 0x19, 0x06, 0xb4, 0x59, 0xe4, 0xb8, 0xae, 0x63, 0xf9, 0xfd, 0x30, 0x0a, 0x58, 0x80, 0x94,
-	0x3c, 0xee, 0x74, 0x96, 0x51, 0x12, 0xb2, 0xe0, 0xe4, 0x9e, 0x26, 0x71, 0xb8, 0xc8, 0xfe, 0xa5,
-	0xac, 0x8e, 0x9a, 0x61, 0xb1, 0x63, 0x87, 0x8b, 0xf4, 0x6f, 0x86, 0x1c, 0xda, 0x41, 0x60, 0xbb,
-	0xf4, 0x44, 0x44, 0x8b, 0xcd, 0x9f, 0x27, 0x96, 0x9f, 0x64, 0xd0, 0x37, 0xbb, 0xd0, 0x6a, 0x13,
-	0x59, 0xcc, 0x09, 0xb2, 0xd2, 0x9d, 0xd7, 0xbb, 0x38, 0x73, 0x3c, 0x1a, 0x33, 0xcb, 0x0b, 0x53,
-	0xc2, 0xd1, 0xdf, 0x35, 0x20, 0x9b, 0x11, 0xa5, 0xe8, 0x15, 0xa8, 0xb1, 0x88, 0x52, 0xe2, 0xac,
-	0x54, 0xa9, 0x2b, 0xf5, 0xca, 0xb8, 0xca, 0xc3, 0xf1, 0x0a, 0x0d, 0x00, 0x10, 0x40, 0xcc, 0x2c,
-	0x46, 0xd5, 0x52, 0x57, 0xea, 0xb5, 0x07, 0x07, 0xfd, 0x62, 0x8b, 0x5c, 0x6c, 0x70, 0x08, 0xd7,
-	0x59, 0xbe, 0x44, 0x27, 0x40, 0x04, 0x84, 0x25, 0x21, 0x55, 0xcb, 0x42, 0x82, 0x1e, 0x4b, 0xcc,
-	0x24, 0xa4, 0x58, 0x61, 0xd9, 0x0a, 0xbd, 0x05, 0xad, 0xb5, 0x15, 0xaf, 0x49, 0xcc, 0x22, 0x8b,
-	0x51, 0x3b, 0x51, 0x65, 0x21, 0x7a, 0xb9, 0x15, 0x8d, 0xac, 0x78, 0x6d, 0x64, 0x28, 0x6e, 0xae,
-	0x1f, 0x44, 0xe8, 0x1a, 0xb4, 0x85, 0xd8, 0x72, 0xed, 0x20, 0x72, 0xd8, 0xda, 0x53, 0x2b, 0x42,
-	0xfd, 0x5d, 0x3f, 0xfd, 0x8a, 0x43, 0xc7, 0x76, 0x98, 0xe5, 0xba, 0x89, 0xe1, 0xd8, 0x3e, 0x5d,
-	0x09, 0x2b, 0x2d, 0xe7, 0x62, 0x51, 0xb8, 0x08, 0xd1, 0x1d, 0x38, 0x88, 0x1d, 0xdb, 0xb7, 0xd8,
-	0x26, 0xa2, 0x0f, 0x1c, 0xab, 0xc2, 0xf1, 0x87, 0xcf, 0x38, 0x1a, 0xb9, 0x62, 0x6b, 0x8b, 0xe2,
-	0x27, 0x39, 0xf4, 0x2d, 0x68, 0xae, 0x9c, 0x38, 0x74, 0xad, 0x84, 0xf8, 0x96, 0x47, 0x55, 0xa5,
-	0x2b, 0xf5, 0xea, 0xb8, 0x91, 0xe5, 0xa6, 0x96, 0x47, 0x51, 0x17, 0x34, 0x56, 0x34, 0x5e, 0x46,
-	0x4e, 0xc8, 0x4f, 0x51, 0xad, 0x67, 0x8c, 0x6d, 0x0a, 0x9d, 0x82, 0x46, 0x18, 0x39, 0x7f, 0x59,
-	0x8c, 0x92, 0x7b, 0x9a, 0xa8, 0xcd, 0xae, 0xd4, 0x6b, 0x0c, 0x9e, 0xf7, 0xd3, 0x83, 0xee, 0xe7,
-	0x07, 0xdd, 0xd7, 0xfc, 0x04, 0x83, 0x8c, 0x78, 0x4d, 0x13, 0xf4, 0x1b, 0x80, 0x31, 0x0b, 0x22,
-	0xcb, 0xa6, 0x24, 0xa6, 0x8c, 0x39, 0xbe, 0x1d, 0xab, 0xad, 0xff, 0xd1, 0xee, 0x65, 0x6c, 0x23,
-	0x23, 0xa3, 0x9f, 0x00, 0x08, 0x37, 0x0b, 0xd7, 0x59, 0x8a, 0xb2, 0x6d, 0x21, 0xdd, 0xef, 0x67,
-	0x23, 0x3c, 0x17, 0xc8, 0x35, 0x4d, 0x70, 0x3d, 0xcc, 0x97, 0x48, 0x07, 0xfb, 0x9e, 0xf5, 0x81,
-	0x44, 0x41, 0xc0, 0x48, 0x3e, 0x97, 0xea, 0x9e, 0x10, 0x1e, 0x3e, 0xa9, 0x39, 0xcc, 0x08, 0x78,
-	0xcf, 0xb3, 0x3e, 0xe0, 0x20, 0x60, 0x79, 0x02, 0xbd, 0x05, 0x8d, 0x65, 0x44, 0xf9, 0x7e, 0xf9,
-	0xf0, 0xaa, 0x50, 0x18, 0x74, 0x9e, 0x18, 0x98, 0xf9, 0x64, 0x63, 0x90, 0xd2, 0x79, 0x82, 0x8b,
-	0x37, 0xe1, 0xaa, 0x10, 0xef, 0x7f, 0x59, 0x9c, 0xd2, 0x85, 0x58, 0x05, 0xb5, 0x15, 0x75, 0x29,
-	0xa3, 0x2b, 0xf5, 0xa0, 0x2b, 0xf5, 0x14, 0x9c, 0x87, 0xdc, 0x36, 0x5d, 0xa6, 0xb6, 0xcf, 0xbf,
-	0x6c, 0x9b, 0xd2, 0x79, 0x62, 0x22, 0x2b, 0x08, 0x1e, 0x4c, 0x64, 0xa5, 0x06, 0x95, 0x89, 0xac,
-	0x00, 0xd8, 0x98, 0xc8, 0x4a, 0x03, 0x36, 0x8f, 0xfe, 0x95, 0xc0, 0xf3, 0x74, 0xa0, 0x74, 0x9f,
-	0x45, 0x49, 0x21, 0x46, 0xdf, 0x83, 0xbd, 0xe2, 0xde, 0x12, 0xdf, 0xf2, 0x83, 0x38, 0xbb, 0xa3,
-	0xed, 0x22, 0x3d, 0xe5, 0x59, 0xf4, 0x02, 0x54, 0xdd, 0xc0, 0xe6, 0x77, 0xb8, 0x24, 0xf0, 0x8a,
-	0x1b, 0xd8, 0xe3, 0x15, 0xfa, 0x05, 0xd4, 0x8b, 0x69, 0x14, 0xd7, 0xb1, 0x31, 0x78, 0xf9, 0xe9,
-	0x49, 0xc6, 0x5b, 0xe2, 0xd1, 0x47, 0x09, 0xb4, 0xd2, 0xec, 0x4d, 0x60, 0xf3, 0x13, 0x41, 0x87,
-	0x40, 0xb9, 0xa7, 0x09, 0x59, 0x3b, 0x3e, 0x53, 0x6b, 0x5d, 0xa9, 0xd7, 0xc4, 0xb5, 0x7b, 0x9a,
-	0x8c, 0x1c, 0x5f, 0x40, 0xbc, 0x32, 0x3f, 0x6b, 0x31, 0xd6, 0x4d, 0x5c, 0x73, 0x33, 0xd5, 0x8f,
-	0x00, 0xe5, 0x10, 0xd9, 0xb6, 0x51, 0x17, 0x24, 0x98, 0x91, 0x8a, 0x0b, 0x34, 0x91, 0x15, 0x09,
-	0x96, 0x26, 0xb2, 0x52, 0x82, 0xe5, 0x89, 0xac, 0x94, 0xa1, 0x3c, 0x91, 0x15, 0x19, 0x56, 0x26,
-	0xb2, 0x52, 0x81, 0xd5, 0x89, 0xac, 0x54, 0x61, 0xed, 0x28, 0xca, 0x1b, 0xbb, 0xb5, 0xc2, 0xbc,
-	0x31, 0xcf, 0x0a, 0xd3, 0xea, 0xa9, 0x71, 0xcd, 0xcb, 0xa0, 0xaf, 0x1f, 0xee, 0x5d, 0x16, 0xd8,
-	0x36, 0xf1, 0xc9, 0x6a, 0x45, 0x9d, 0xe2, 0x88, 0x14, 0x58, 0x3f, 0x1e, 0x82, 0x56, 0xf6, 0x19,
-	0x2e, 0x83, 0xc8, 0xb3, 0x18, 0xfa, 0x0a, 0xbc, 0xba, 0x99, 0x5d, 0x11, 0x3c, 0x9b, 0x99, 0xe4,
-	0x72, 0x86, 0x6f, 0x35, 0x93, 0xfc, 0x3e, 0xbd, 0x9e, 0xce, 0xfe, 0x98, 0xc2, 0x67, 0xe8, 0x25,
-	0x40, 0xbb, 0xe0, 0xbb, 0x37, 0x50, 0xe2, 0x2e, 0x59, 0xcf, 0x5b, 0x97, 0x5b, 0x6d, 0xfe, 0x79,
-	0x97, 0x5d, 0x50, 0xb8, 0x7c, 0x94, 0x40, 0xf3, 0xe1, 0xef, 0x21, 0x3a, 0x04, 0x2f, 0x32, 0x15,
-	0x19, 0x69, 0xc6, 0x88, 0x18, 0x26, 0xd6, 0x4c, 0xfd, 0xea, 0x3d, 0x7c, 0x86, 0x10, 0x68, 0xe3,
-	0xcb, 0x8b, 0xb3, 0x5f, 0xcf, 0x06, 0xc4, 0x18, 0x69, 0x83, 0xd3, 0x33, 0x28, 0xa1, 0x03, 0xb0,
-	0x67, 0xea, 0x86, 0x49, 0xb8, 0x39, 0xe7, 0xeb, 0x18, 0x96, 0xb8, 0xc7, 0xec, 0x7c, 0xa2, 0x5f,
-	0x98, 0x64, 0x87, 0x5f, 0x46, 0x2f, 0xc0, 0xfe, 0xc5, 0x6c, 0x3a, 0xbe, 0x36, 0x78, 0xea, 0xf4,
-	0xcd, 0x80, 0xf0, 0xb4, 0x8c, 0xf6, 0x41, 0x6b, 0x9b, 0xe6, 0xa9, 0xca, 0xf1, 0x3f, 0x12, 0xa8,
-	0x17, 0x2f, 0x02, 0xef, 0x3f, 0x6f, 0xcb, 0xc4, 0xba, 0x4e, 0x0c, 0x53, 0x33, 0x75, 0xf8, 0x0c,
-	0x01, 0x50, 0xd5, 0x2e, 0xcc, 0xf1, 0x3b, 0x1d, 0x4a, 0x7c, 0x7d, 0x89, 0x67, 0x77, 0xfa, 0x14,
-	0x96, 0xd0, 0x6b, 0xf0, 0x6a, 0xa8, 0xcf, 0xb1, 0x7e, 0xa1, 0x99, 0xfa, 0x90, 0x18, 0xb3, 0x4b,
-	0x93, 0x0c, 0xf5, 0x1b, 0xdd, 0xd4, 0x87, 0xb0, 0xdc, 0x29, 0x29, 0xd2, 0x0e, 0x61, 0xa4, 0xe1,
-	0x61, 0x41, 0x90, 0x05, 0xa1, 0x09, 0x94, 0x21, 0xd6, 0xc6, 0xd3, 0xf1, 0xf4, 0x0a, 0x56, 0x8e,
-	0xaf, 0x80, 0x92, 0xbf, 0x35, 0x7c, 0x0f, 0x8f, 0x7a, 0x31, 0xdf, 0xcf, 0x79, 0x2b, 0x35, 0x50,
-	0xbe, 0x99, 0x5d, 0x41, 0x89, 0x2f, 0x6e, 0xb5, 0x39, 0x2c, 0xf1, 0x0f, 0x36, 0xc7, 0xfa, 0x0c,
-	0x0f, 0x75, 0xac, 0x0f, 0x09, 0x07, 0xcb, 0xe7, 0x23, 0x70, 0xb8, 0x0c, 0xbc, 0xfc, 0x7a, 0x3f,
-	0x7e, 0xde, 0xcf, 0x5b, 0x66, 0x16, 0xcf, 0x79, 0x38, 0x97, 0xee, 0x3a, 0xb6, 0xc3, 0xd6, 0x9b,
-	0x45, 0x7f, 0x19, 0x78, 0x27, 0xd9, 0xfb, 0x9b, 0x4b, 0x16, 0x55, 0xa1, 0xf9, 0xf9, 0xbf, 0x00,
-	0x00, 0x00, 0xff, 0xff, 0x7f, 0x1e, 0xf7, 0xb2, 0x24, 0x08, 0x00, 0x00,
+	// 1598 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x57, 0xdd, 0x72, 0xdb, 0xb8,
+	0x15, 0x0e, 0xf5, 0x4b, 0x1d, 0xc9, 0x36, 0x02, 0xff, 0xd1, 0xda, 0x9f, 0x28, 0x9e, 0xdd, 0xa9,
+	0x37, 0xd3, 0x91, 0xbb, 0x4e, 0x93, 0xd9, 0x6d, 0x66, 0xda, 0x61, 0x4c, 0xda, 0x96, 0xac, 0x48,
+	0x0a, 0x44, 0xef, 0x4e, 0x32, 0xd3, 0xc1, 0xd0, 0x22, 0x22, 0x71, 0x4c, 0x91, 0x2c, 0x09, 0x25,
+	0x51, 0xaf, 0xfa, 0x00, 0xbd, 0xef, 0x23, 0xb4, 0xf7, 0x7d, 0x8d, 0xbe, 0x40, 0xdf, 0xa6, 0x03,
+	0xf0, 0x47, 0xb2, 0x9c, 0xd4, 0x7b, 0xb1, 0x37, 0x36, 0xce, 0xf9, 0xbe, 0xef, 0xe0, 0x00, 0x3c,
+	0x38, 0x80, 0x60, 0x93, 0x47, 0xae, 0xe7, 0xb9, 0xb6, 0xdf, 0x0e, 0xa3, 0x80, 0x07, 0x58, 0xcd,
+	0xec, 0x66, 0x73, 0x1c, 0x2d, 0x42, 0x1e, 0x1c, 0xdf, 0xb0, 0x45, 0x1c, 0x5e, 0xa7, 0xff, 0x12,
+	0x56, 0x53, 0x4b, 0xb1, 0xd8, 0x9d, 0x84, 0xd7, 0xc9, 0xdf, 0x14, 0x39, 0x98, 0x04, 0xc1, 0xc4,
+	0x63, 0xc7, 0xd2, 0xba, 0x9e, 0xbf, 0x3b, 0xb6, 0xfd, 0x45, 0x0a, 0x7d, 0xbd, 0x0e, 0x39, 0xf3,
+	0xc8, 0xe6, 0x6e, 0x90, 0x4e, 0xdd, 0x7c, 0xb4, 0x8e, 0x73, 0x77, 0xc6, 0x62, 0x6e, 0xcf, 0xc2,
+	0x84, 0x70, 0xf8, 0xdf, 0x06, 0x94, 0xac, 0x88, 0x31, 0xbc, 0x0f, 0x55, 0x1e, 0x31, 0x46, 0x5d,
+	0x47, 0x53, 0x5a, 0xca, 0x51, 0x91, 0x54, 0x84, 0xd9, 0x71, 0xf0, 0x09, 0x80, 0x04, 0x62, 0x6e,
+	0x73, 0xa6, 0x15, 0x5a, 0xca, 0xd1, 0xe6, 0xc9, 0x76, 0x3b, 0x5f, 0xa2, 0x10, 0x8f, 0x04, 0x44,
+	0x6a, 0x3c, 0x1b, 0xe2, 0x63, 0x90, 0x06, 0xe5, 0x8b, 0x90, 0x69, 0x45, 0x29, 0xc1, 0xb7, 0x25,
+	0xd6, 0x22, 0x64, 0x44, 0xe5, 0xe9, 0x08, 0xbf, 0x80, 0x8d, 0xa9, 0x1d, 0x4f, 0x69, 0xcc, 0x23,
+	0x9b, 0xb3, 0xc9, 0x42, 0x2b, 0x49, 0xd1, 0xde, 0x52, 0x74, 0x61, 0xc7, 0xd3, 0x51, 0x8a, 0x92,
+	0xc6, 0x74, 0xc5, 0xc2, 0x97, 0xb0, 0x29, 0xc5, 0xb6, 0x37, 0x09, 0x22, 0x97, 0x4f, 0x67, 0x5a,
+	0x59, 0xaa, 0xbf, 0x69, 0x27, 0xbb, 0x68, 0xb8, 0x13, 0x97, 0xdb, 0x9e, 0xb7, 0x18, 0xb9, 0x13,
+	0x9f, 0x39, 0x32, 0x94, 0x9e, 0x71, 0xc9, 0xc6, 0x74, 0xd5, 0xc4, 0x6f, 0x61, 0x3b, 0x76, 0x27,
+	0xbe, 0xcd, 0xe7, 0x11, 0x5b, 0x89, 0x58, 0x91, 0x11, 0xbf, 0xfb, 0x4c, 0xc4, 0x51, 0xa6, 0x58,
+	0x86, 0xc5, 0xf1, 0x1d, 0x1f, 0x7e, 0x0c, 0x0d, 0xc7, 0x8d, 0x43, 0xcf, 0x5e, 0x50, 0xdf, 0x9e,
+	0x31, 0x4d, 0x6d, 0x29, 0x47, 0x35, 0x52, 0x4f, 0x7d, 0x7d, 0x7b, 0xc6, 0x70, 0x0b, 0xea, 0x0e,
+	0x8b, 0xc7, 0x91, 0x1b, 0x8a, 0xaf, 0xa8, 0xd5, 0x52, 0xc6, 0xd2, 0x85, 0x9f, 0x41, 0x3d, 0x8c,
+	0xdc, 0xf7, 0x36, 0x67, 0xf4, 0x86, 0x2d, 0xb4, 0x46, 0x4b, 0x39, 0xaa, 0x9f, 0xec, 0xb4, 0x93,
+	0x0f, 0xdd, 0xce, 0x3e, 0x74, 0x5b, 0xf7, 0x17, 0x04, 0x52, 0xe2, 0x25, 0x5b, 0xe0, 0x3f, 0x01,
+	0x8a, 0x79, 0x10, 0xd9, 0x13, 0x46, 0x63, 0xc6, 0xb9, 0xeb, 0x4f, 0x62, 0x6d, 0xe3, 0xff, 0x68,
+	0xb7, 0x52, 0xf6, 0x28, 0x25, 0xe3, 0xdf, 0x01, 0x84, 0xf3, 0x6b, 0xcf, 0x1d, 0xcb, 0x69, 0x37,
+	0xa5, 0xf4, 0x61, 0x3b, 0x2d, 0xe1, 0xa1, 0x44, 0x2e, 0xd9, 0x82, 0xd4, 0xc2, 0x6c, 0x88, 0x4d,
+	0x78, 0x38, 0xb3, 0x3f, 0xd2, 0x28, 0x08, 0x38, 0xcd, 0xea, 0x52, 0xdb, 0x92, 0xc2, 0x83, 0x3b,
+	0x73, 0x1a, 0x29, 0x81, 0x6c, 0xcd, 0xec, 0x8f, 0x24, 0x08, 0x78, 0xe6, 0xc0, 0x2f, 0xa0, 0x3e,
+	0x8e, 0x98, 0x58, 0xaf, 0x28, 0x5e, 0x0d, 0xc9, 0x00, 0xcd, 0x3b, 0x01, 0xac, 0xac, 0xb2, 0x09,
+	0x24, 0x74, 0xe1, 0x10, 0xe2, 0x79, 0xe8, 0xe4, 0xe2, 0x87, 0xf7, 0x8b, 0x13, 0xba, 0x14, 0x6b,
+	0x50, 0x75, 0x98, 0xc7, 0x38, 0x73, 0xb4, 0xed, 0x96, 0x72, 0xa4, 0x92, 0xcc, 0x14, 0x61, 0x93,
+	0x61, 0x12, 0x76, 0xe7, 0xfe, 0xb0, 0x09, 0x5d, 0x86, 0xfd, 0x33, 0x1c, 0x44, 0xec, 0xbd, 0x1b,
+	0xbb, 0x81, 0x4f, 0x23, 0xc6, 0x99, 0x2f, 0x96, 0x49, 0xc3, 0xc0, 0x73, 0xc7, 0x0b, 0x6d, 0x57,
+	0x86, 0x7a, 0xbc, 0x2c, 0x7c, 0x92, 0x52, 0x49, 0xc6, 0x1c, 0x4a, 0x22, 0xd9, 0x8f, 0x3e, 0x0d,
+	0xe0, 0x6f, 0x60, 0x73, 0x66, 0x87, 0xd4, 0xf5, 0x1d, 0xf6, 0x91, 0x5e, 0xbb, 0x3c, 0xd6, 0xf6,
+	0x5a, 0xca, 0x51, 0x99, 0x34, 0x66, 0x76, 0xd8, 0x11, 0xce, 0x97, 0x2e, 0x8f, 0xf1, 0x6b, 0xd8,
+	0x75, 0xe6, 0xa1, 0xe7, 0x8e, 0xc5, 0xde, 0x78, 0xcc, 0x7e, 0x97, 0x25, 0xb0, 0x2f, 0x2b, 0xfd,
+	0xab, 0x65, 0x02, 0x46, 0x46, 0xeb, 0x31, 0xfb, 0x5d, 0x3a, 0xf9, 0xb6, 0x73, 0xd7, 0x89, 0x7b,
+	0xb0, 0x6f, 0x3b, 0x8e, 0x2b, 0x52, 0xb1, 0x3d, 0xba, 0x52, 0xa4, 0xb1, 0xa6, 0xb5, 0x8a, 0x9f,
+	0xad, 0xb4, 0xdd, 0xa5, 0x68, 0x98, 0xd7, 0x6b, 0x8c, 0xcf, 0x61, 0x6f, 0x35, 0x5a, 0x5e, 0x7a,
+	0xb1, 0x76, 0xd0, 0x2a, 0x7e, 0xba, 0xf6, 0x76, 0x56, 0x22, 0x65, 0xce, 0x18, 0x1f, 0xaf, 0x9e,
+	0x68, 0x3e, 0x8d, 0x58, 0x3c, 0x0d, 0x3c, 0x47, 0x6b, 0xca, 0x4d, 0x59, 0x1e, 0x53, 0x2b, 0x43,
+	0xf0, 0x09, 0x54, 0x3c, 0xfb, 0x9a, 0x79, 0xb1, 0xf6, 0x85, 0x9c, 0xa9, 0x79, 0xbb, 0x75, 0xb5,
+	0x7b, 0x12, 0x34, 0x7d, 0x1e, 0x2d, 0x48, 0xca, 0xc4, 0x06, 0xa0, 0xb4, 0x20, 0xf2, 0x2f, 0xaa,
+	0x7d, 0x79, 0x6f, 0xa9, 0x27, 0x92, 0xfc, 0x03, 0xe2, 0x1f, 0xa0, 0x71, 0xc3, 0x16, 0x34, 0x0a,
+	0x78, 0x72, 0x58, 0xbe, 0x92, 0x11, 0x76, 0x97, 0xf3, 0x8b, 0x55, 0xa6, 0x20, 0xa9, 0xdf, 0x2c,
+	0x0d, 0xfc, 0x1d, 0x94, 0xff, 0x32, 0x0f, 0xb8, 0xad, 0x7d, 0x2d, 0x25, 0x6b, 0x0d, 0xfa, 0xb5,
+	0x80, 0x48, 0xc2, 0xc0, 0x3b, 0x50, 0x0e, 0x3e, 0xf8, 0x2c, 0xd2, 0x1e, 0xc9, 0xe6, 0x92, 0x18,
+	0xcd, 0x1f, 0xa1, 0xbe, 0xb2, 0x2e, 0x8c, 0xa0, 0x28, 0x8e, 0xb9, 0x22, 0x29, 0x62, 0x28, 0x64,
+	0xef, 0x6d, 0x6f, 0x9e, 0x5c, 0x01, 0x35, 0x92, 0x18, 0x7f, 0x28, 0xfc, 0xa0, 0x74, 0x4b, 0x2a,
+	0x46, 0xdb, 0xdd, 0x92, 0x5a, 0x45, 0x6a, 0xb7, 0xa4, 0x02, 0xaa, 0x77, 0x4b, 0x6a, 0x1d, 0x35,
+	0x0e, 0x3f, 0x40, 0x2d, 0x9f, 0x1c, 0x3f, 0x85, 0xbd, 0x88, 0xd9, 0x0e, 0xe5, 0xc1, 0x0d, 0xf3,
+	0x63, 0x1a, 0xb2, 0x88, 0xc6, 0x6c, 0x1c, 0xf8, 0xd9, 0x75, 0xb3, 0x2d, 0x50, 0x4b, 0x82, 0x43,
+	0x16, 0x8d, 0x24, 0x84, 0x9f, 0xc1, 0xfe, 0x87, 0xc8, 0x15, 0xa7, 0xec, 0x8e, 0xaa, 0x20, 0x55,
+	0x3b, 0x12, 0x5e, 0x93, 0x1d, 0xfe, 0x5b, 0x81, 0xfa, 0xca, 0x4e, 0xad, 0xb7, 0x4c, 0xe5, 0x17,
+	0xb6, 0xcc, 0xdb, 0x1d, 0xaf, 0xf0, 0x0b, 0x3a, 0xde, 0x0b, 0xa8, 0x47, 0x8c, 0xbb, 0x51, 0xda,
+	0x16, 0x8a, 0xf7, 0xb7, 0x85, 0x84, 0x2e, 0x1c, 0x87, 0x7f, 0x53, 0x60, 0xff, 0x33, 0x87, 0x1d,
+	0x7f, 0x0b, 0x9b, 0x37, 0x8c, 0x85, 0x34, 0x3b, 0xf3, 0x71, 0xba, 0x6b, 0x1b, 0xc2, 0x9b, 0x89,
+	0x62, 0xfc, 0x47, 0x90, 0x8e, 0x65, 0xb7, 0x2d, 0xdc, 0x57, 0x82, 0x0d, 0xc1, 0xcf, 0xac, 0xc3,
+	0xbf, 0x2b, 0xb0, 0x93, 0x5c, 0x69, 0xb2, 0x0a, 0xf2, 0x3c, 0xf1, 0x6f, 0x60, 0x2b, 0x7f, 0x39,
+	0x50, 0xdf, 0xf6, 0x83, 0x2c, 0x81, 0xcd, 0xdc, 0xdd, 0x17, 0x5e, 0xbc, 0x0b, 0x15, 0x2f, 0x98,
+	0x88, 0x57, 0x44, 0xf2, 0x81, 0xca, 0x5e, 0x30, 0xe9, 0x38, 0xf8, 0xf7, 0x50, 0xcb, 0x0f, 0x5a,
+	0xba, 0x2d, 0x7b, 0x9f, 0xbe, 0x4b, 0xc9, 0x92, 0x78, 0xf8, 0x1f, 0x05, 0x36, 0x12, 0x6f, 0x2f,
+	0x98, 0x88, 0x3b, 0x01, 0x1f, 0x80, 0x2a, 0x0e, 0xc8, 0xd4, 0xf5, 0xb9, 0x56, 0x6d, 0x29, 0x47,
+	0x0d, 0x52, 0xbd, 0x61, 0x8b, 0x0b, 0xd7, 0x97, 0x90, 0x98, 0x59, 0xdc, 0x36, 0xf2, 0x62, 0x6d,
+	0x90, 0xaa, 0x97, 0xaa, 0x7e, 0x0b, 0x38, 0x83, 0xe8, 0x32, 0x8d, 0x9a, 0x24, 0xa1, 0x94, 0x94,
+	0x5f, 0xe1, 0xf8, 0x29, 0xac, 0x74, 0xa4, 0x25, 0x3f, 0xd6, 0xa0, 0x55, 0x3c, 0x6a, 0xac, 0x36,
+	0x99, 0x5c, 0x13, 0x77, 0x4b, 0xaa, 0x82, 0x0a, 0xdd, 0x92, 0x5a, 0x40, 0xc5, 0x6e, 0x49, 0x2d,
+	0xa2, 0x52, 0xb7, 0xa4, 0x96, 0x50, 0xb9, 0x5b, 0x52, 0xcb, 0xa8, 0xd2, 0x2d, 0xa9, 0x15, 0x54,
+	0x3d, 0xfc, 0x67, 0xbe, 0x9c, 0x57, 0x76, 0x98, 0x2d, 0x47, 0xb4, 0x6a, 0x99, 0x73, 0x92, 0x4e,
+	0x75, 0x96, 0x42, 0x5f, 0xae, 0xee, 0x58, 0x49, 0x62, 0xb5, 0xf8, 0xd7, 0xcf, 0x31, 0xcf, 0x2e,
+	0x3f, 0xbf, 0x2a, 0xaa, 0x3d, 0x31, 0x60, 0x23, 0xdd, 0xf1, 0xb3, 0x20, 0x9a, 0xd9, 0x1c, 0x7f,
+	0x01, 0xfb, 0xbd, 0xc1, 0x39, 0x25, 0x83, 0x81, 0x45, 0xcf, 0x06, 0xe4, 0x95, 0x6e, 0xd1, 0xab,
+	0xfe, 0x65, 0x7f, 0xf0, 0x73, 0x1f, 0x3d, 0xc0, 0x7b, 0x80, 0xd7, 0xc1, 0x9f, 0xbe, 0x47, 0x8a,
+	0x88, 0x92, 0x2e, 0x74, 0x19, 0xe5, 0x95, 0x3e, 0xfc, 0x7c, 0x94, 0x75, 0x50, 0x46, 0xf9, 0x87,
+	0x02, 0x8d, 0xd5, 0xc7, 0x1f, 0x3e, 0x80, 0xdd, 0x54, 0x45, 0x2f, 0xf4, 0xd1, 0x05, 0x1d, 0x59,
+	0x44, 0xb7, 0xcc, 0xf3, 0x37, 0xe8, 0x01, 0xc6, 0xb0, 0x49, 0xce, 0x4e, 0x9f, 0xff, 0xf8, 0xfc,
+	0x84, 0x8e, 0x2e, 0xf4, 0x93, 0x67, 0xcf, 0x91, 0x82, 0xb7, 0x61, 0xcb, 0x32, 0x47, 0x16, 0x15,
+	0xc1, 0x05, 0xdf, 0x24, 0xa8, 0x20, 0x62, 0x0c, 0x5e, 0x76, 0xcd, 0x53, 0x8b, 0xae, 0xf1, 0x8b,
+	0x78, 0x17, 0x1e, 0x9e, 0x0e, 0xfa, 0x9d, 0xcb, 0x91, 0x70, 0x3d, 0xfb, 0xfe, 0x84, 0x0a, 0x77,
+	0x09, 0x3f, 0x84, 0x8d, 0xa5, 0x5b, 0xb8, 0xca, 0x4f, 0xfe, 0xa5, 0x24, 0x0d, 0x2e, 0x79, 0xf3,
+	0xee, 0x01, 0xce, 0xd2, 0xb2, 0x88, 0x69, 0xd2, 0x91, 0xa5, 0x5b, 0x26, 0x7a, 0x80, 0x01, 0x2a,
+	0xfa, 0xa9, 0xd5, 0xf9, 0xc9, 0x44, 0x8a, 0x18, 0x9f, 0x91, 0xc1, 0x5b, 0xb3, 0x8f, 0x0a, 0xf8,
+	0x11, 0xec, 0x1b, 0xe6, 0x90, 0x98, 0xa7, 0xba, 0x65, 0x1a, 0x74, 0x34, 0x38, 0xb3, 0xa8, 0x61,
+	0xf6, 0x4c, 0xcb, 0x34, 0x50, 0xb1, 0x59, 0x50, 0x95, 0x35, 0xc2, 0x85, 0x4e, 0x8c, 0x9c, 0x50,
+	0x92, 0x84, 0x06, 0xa8, 0x06, 0xd1, 0x3b, 0xfd, 0x4e, 0xff, 0x1c, 0x95, 0xf1, 0x16, 0xd4, 0x5f,
+	0x5f, 0xe9, 0x44, 0xef, 0x5b, 0x9d, 0xbe, 0x69, 0xa0, 0x8a, 0x98, 0x6c, 0xa8, 0x5f, 0x8d, 0x4c,
+	0x03, 0x55, 0x9f, 0x9c, 0x83, 0x9a, 0xbd, 0xba, 0xc5, 0x02, 0x6f, 0x25, 0x6a, 0xbd, 0x19, 0x8a,
+	0x3c, 0xab, 0x50, 0xec, 0x0d, 0xce, 0x91, 0x22, 0x06, 0xaf, 0xf4, 0x21, 0x2a, 0x88, 0xdd, 0x1c,
+	0x12, 0x73, 0x40, 0x0c, 0x93, 0x98, 0x06, 0x15, 0x60, 0xf1, 0xc9, 0x5f, 0x61, 0xfb, 0x13, 0xef,
+	0x01, 0xfc, 0x2d, 0x3c, 0x36, 0xae, 0x86, 0xbd, 0x8e, 0xc8, 0x95, 0xf6, 0x4c, 0xfd, 0x8c, 0x0e,
+	0x07, 0xbd, 0xce, 0xe9, 0x1b, 0x7a, 0xd5, 0x1f, 0x0d, 0xcd, 0xd3, 0xce, 0x59, 0xc7, 0x34, 0xd0,
+	0x03, 0x31, 0x35, 0x31, 0xe5, 0xb6, 0xe7, 0xec, 0x11, 0x52, 0x44, 0xea, 0x86, 0x99, 0x7b, 0x50,
+	0x01, 0xef, 0x00, 0xd2, 0x7b, 0xbd, 0xc1, 0xcf, 0xab, 0xb4, 0xe2, 0xcb, 0x0b, 0x38, 0x18, 0x07,
+	0xb3, 0xac, 0x97, 0xdd, 0xfe, 0x91, 0xf5, 0x72, 0xc3, 0x4a, 0xed, 0xa1, 0x30, 0x87, 0xca, 0xdb,
+	0xe6, 0xc4, 0xe5, 0xd3, 0xf9, 0x75, 0x7b, 0x1c, 0xcc, 0x8e, 0xd3, 0x5f, 0x41, 0x99, 0xe4, 0xba,
+	0x22, 0x35, 0x4f, 0xff, 0x37, 0x00, 0xb0, 0x9b, 0xd1, 0x73, 0xaa, 0x0d, 0x00, 0x00,
 }
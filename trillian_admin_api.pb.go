@@ -7,6 +7,9 @@ import (
 	context "context"
 	fmt "fmt"
 	proto "github.com/golang/protobuf/proto"
+	any "github.com/golang/protobuf/ptypes/any"
+	duration "github.com/golang/protobuf/ptypes/duration"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
 	keyspb "github.com/google/trillian/crypto/keyspb"
 	_ "google.golang.org/genproto/googleapis/api/annotations"
 	field_mask "google.golang.org/genproto/protobuf/field_mask"
@@ -28,10 +31,33 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 // ListTrees request.
-// No filters or pagination options are provided.
+// Trees are listed in ascending tree ID order. The filters are combined, so
+// only trees matching all of them are returned.
 type ListTreesRequest struct {
 	// If true, deleted trees are included in the response.
-	ShowDeleted          bool     `protobuf:"varint,1,opt,name=show_deleted,json=showDeleted,proto3" json:"show_deleted,omitempty"`
+	ShowDeleted bool `protobuf:"varint,1,opt,name=show_deleted,json=showDeleted,proto3" json:"show_deleted,omitempty"`
+	// If not empty, only trees of these types are returned.
+	TreeTypes []TreeType `protobuf:"varint,2,rep,packed,name=tree_types,json=treeTypes,proto3,enum=trillian.TreeType" json:"tree_types,omitempty"`
+	// If not empty, only trees in these states are returned.
+	TreeStates []TreeState `protobuf:"varint,3,rep,packed,name=tree_states,json=treeStates,proto3,enum=trillian.TreeState" json:"tree_states,omitempty"`
+	// If not empty, only trees whose display name starts with this prefix are
+	// returned.
+	DisplayNamePrefix string `protobuf:"bytes,4,opt,name=display_name_prefix,json=displayNamePrefix,proto3" json:"display_name_prefix,omitempty"`
+	// If set, only trees created at or after this time are returned.
+	CreateTimeStart *timestamp.Timestamp `protobuf:"bytes,5,opt,name=create_time_start,json=createTimeStart,proto3" json:"create_time_start,omitempty"`
+	// If set, only trees created before this time are returned.
+	CreateTimeEnd *timestamp.Timestamp `protobuf:"bytes,6,opt,name=create_time_end,json=createTimeEnd,proto3" json:"create_time_end,omitempty"`
+	// If not empty, only trees which have all of these labels, with the same
+	// values, are returned.
+	Labels map[string]string `protobuf:"bytes,9,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// page_size is the maximum number of trees in the response. If zero, all
+	// matching trees are returned. Values larger than the server's limit are
+	// capped to that limit.
+	PageSize int32 `protobuf:"varint,7,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// page_token, if set, must be a next_page_token returned by an earlier
+	// ListTrees call with the same filters. The listing resumes with the tree
+	// following that token.
+	PageToken            string   `protobuf:"bytes,8,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -69,12 +95,69 @@ func (m *ListTreesRequest) GetShowDeleted() bool {
 	return false
 }
 
+func (m *ListTreesRequest) GetTreeTypes() []TreeType {
+	if m != nil {
+		return m.TreeTypes
+	}
+	return nil
+}
+
+func (m *ListTreesRequest) GetTreeStates() []TreeState {
+	if m != nil {
+		return m.TreeStates
+	}
+	return nil
+}
+
+func (m *ListTreesRequest) GetDisplayNamePrefix() string {
+	if m != nil {
+		return m.DisplayNamePrefix
+	}
+	return ""
+}
+
+func (m *ListTreesRequest) GetCreateTimeStart() *timestamp.Timestamp {
+	if m != nil {
+		return m.CreateTimeStart
+	}
+	return nil
+}
+
+func (m *ListTreesRequest) GetCreateTimeEnd() *timestamp.Timestamp {
+	if m != nil {
+		return m.CreateTimeEnd
+	}
+	return nil
+}
+
+func (m *ListTreesRequest) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *ListTreesRequest) GetPageSize() int32 {
+	if m != nil {
+		return m.PageSize
+	}
+	return 0
+}
+
+func (m *ListTreesRequest) GetPageToken() string {
+	if m != nil {
+		return m.PageToken
+	}
+	return ""
+}
+
 // ListTrees response.
-// No pagination is provided, all trees the requester has access to are
-// returned.
 type ListTreesResponse struct {
 	// Trees matching the list request filters.
-	Tree                 []*Tree  `protobuf:"bytes,1,rep,name=tree,proto3" json:"tree,omitempty"`
+	Tree []*Tree `protobuf:"bytes,1,rep,name=tree,proto3" json:"tree,omitempty"`
+	// next_page_token can be used to resume the listing after the last tree in
+	// this response. It is empty when there are no more trees.
+	NextPageToken        string   `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -112,6 +195,13 @@ func (m *ListTreesResponse) GetTree() []*Tree {
 	return nil
 }
 
+func (m *ListTreesResponse) GetNextPageToken() string {
+	if m != nil {
+		return m.NextPageToken
+	}
+	return ""
+}
+
 // GetTree request.
 type GetTreeRequest struct {
 	// ID of the tree to retrieve.
@@ -204,6 +294,108 @@ func (m *CreateTreeRequest) GetKeySpec() *keyspb.Specification {
 	return nil
 }
 
+// CreateTreesFromTemplate request.
+type CreateTreesFromTemplateRequest struct {
+	// Tree which all the created trees are copies of, as the tree of a
+	// CreateTreeRequest. Its private_key, if any, is shared by all of them.
+	Template *Tree `protobuf:"bytes,1,opt,name=template,proto3" json:"template,omitempty"`
+	// Describes the private key to generate for each tree, if the template has
+	// no private_key. Every tree gets a key of its own.
+	KeySpec *keyspb.Specification `protobuf:"bytes,2,opt,name=key_spec,json=keySpec,proto3" json:"key_spec,omitempty"`
+	// Number of trees to create, at most 1000.
+	Count                int32    `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateTreesFromTemplateRequest) Reset()         { *m = CreateTreesFromTemplateRequest{} }
+func (m *CreateTreesFromTemplateRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateTreesFromTemplateRequest) ProtoMessage()    {}
+func (*CreateTreesFromTemplateRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aac35e28a5dd9ee3, []int{4}
+}
+
+func (m *CreateTreesFromTemplateRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateTreesFromTemplateRequest.Unmarshal(m, b)
+}
+func (m *CreateTreesFromTemplateRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateTreesFromTemplateRequest.Marshal(b, m, deterministic)
+}
+func (m *CreateTreesFromTemplateRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateTreesFromTemplateRequest.Merge(m, src)
+}
+func (m *CreateTreesFromTemplateRequest) XXX_Size() int {
+	return xxx_messageInfo_CreateTreesFromTemplateRequest.Size(m)
+}
+func (m *CreateTreesFromTemplateRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateTreesFromTemplateRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateTreesFromTemplateRequest proto.InternalMessageInfo
+
+func (m *CreateTreesFromTemplateRequest) GetTemplate() *Tree {
+	if m != nil {
+		return m.Template
+	}
+	return nil
+}
+
+func (m *CreateTreesFromTemplateRequest) GetKeySpec() *keyspb.Specification {
+	if m != nil {
+		return m.KeySpec
+	}
+	return nil
+}
+
+func (m *CreateTreesFromTemplateRequest) GetCount() int32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+// CreateTreesFromTemplate response.
+type CreateTreesFromTemplateResponse struct {
+	// The created trees.
+	Tree                 []*Tree  `protobuf:"bytes,1,rep,name=tree,proto3" json:"tree,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateTreesFromTemplateResponse) Reset()         { *m = CreateTreesFromTemplateResponse{} }
+func (m *CreateTreesFromTemplateResponse) String() string { return proto.CompactTextString(m) }
+func (*CreateTreesFromTemplateResponse) ProtoMessage()    {}
+func (*CreateTreesFromTemplateResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aac35e28a5dd9ee3, []int{5}
+}
+
+func (m *CreateTreesFromTemplateResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateTreesFromTemplateResponse.Unmarshal(m, b)
+}
+func (m *CreateTreesFromTemplateResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateTreesFromTemplateResponse.Marshal(b, m, deterministic)
+}
+func (m *CreateTreesFromTemplateResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateTreesFromTemplateResponse.Merge(m, src)
+}
+func (m *CreateTreesFromTemplateResponse) XXX_Size() int {
+	return xxx_messageInfo_CreateTreesFromTemplateResponse.Size(m)
+}
+func (m *CreateTreesFromTemplateResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateTreesFromTemplateResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateTreesFromTemplateResponse proto.InternalMessageInfo
+
+func (m *CreateTreesFromTemplateResponse) GetTree() []*Tree {
+	if m != nil {
+		return m.Tree
+	}
+	return nil
+}
+
 // UpdateTree request.
 type UpdateTreeRequest struct {
 	// Tree to be updated.
@@ -220,7 +412,7 @@ func (m *UpdateTreeRequest) Reset()         { *m = UpdateTreeRequest{} }
 func (m *UpdateTreeRequest) String() string { return proto.CompactTextString(m) }
 func (*UpdateTreeRequest) ProtoMessage()    {}
 func (*UpdateTreeRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_aac35e28a5dd9ee3, []int{4}
+	return fileDescriptor_aac35e28a5dd9ee3, []int{6}
 }
 
 func (m *UpdateTreeRequest) XXX_Unmarshal(b []byte) error {
@@ -268,124 +460,709 @@ func (m *DeleteTreeRequest) Reset()         { *m = DeleteTreeRequest{} }
 func (m *DeleteTreeRequest) String() string { return proto.CompactTextString(m) }
 func (*DeleteTreeRequest) ProtoMessage()    {}
 func (*DeleteTreeRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_aac35e28a5dd9ee3, []int{5}
+	return fileDescriptor_aac35e28a5dd9ee3, []int{7}
+}
+
+func (m *DeleteTreeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteTreeRequest.Unmarshal(m, b)
+}
+func (m *DeleteTreeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteTreeRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteTreeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteTreeRequest.Merge(m, src)
+}
+func (m *DeleteTreeRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteTreeRequest.Size(m)
+}
+func (m *DeleteTreeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteTreeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteTreeRequest proto.InternalMessageInfo
+
+func (m *DeleteTreeRequest) GetTreeId() int64 {
+	if m != nil {
+		return m.TreeId
+	}
+	return 0
+}
+
+// UndeleteTree request.
+type UndeleteTreeRequest struct {
+	// ID of the tree to undelete.
+	TreeId               int64    `protobuf:"varint,1,opt,name=tree_id,json=treeId,proto3" json:"tree_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UndeleteTreeRequest) Reset()         { *m = UndeleteTreeRequest{} }
+func (m *UndeleteTreeRequest) String() string { return proto.CompactTextString(m) }
+func (*UndeleteTreeRequest) ProtoMessage()    {}
+func (*UndeleteTreeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aac35e28a5dd9ee3, []int{8}
+}
+
+func (m *UndeleteTreeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UndeleteTreeRequest.Unmarshal(m, b)
+}
+func (m *UndeleteTreeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UndeleteTreeRequest.Marshal(b, m, deterministic)
+}
+func (m *UndeleteTreeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UndeleteTreeRequest.Merge(m, src)
+}
+func (m *UndeleteTreeRequest) XXX_Size() int {
+	return xxx_messageInfo_UndeleteTreeRequest.Size(m)
+}
+func (m *UndeleteTreeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_UndeleteTreeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UndeleteTreeRequest proto.InternalMessageInfo
+
+func (m *UndeleteTreeRequest) GetTreeId() int64 {
+	if m != nil {
+		return m.TreeId
+	}
+	return 0
+}
+
+// PurgeTree request.
+type PurgeTreeRequest struct {
+	// ID of the soft-deleted tree to purge.
+	TreeId               int64    `protobuf:"varint,1,opt,name=tree_id,json=treeId,proto3" json:"tree_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PurgeTreeRequest) Reset()         { *m = PurgeTreeRequest{} }
+func (m *PurgeTreeRequest) String() string { return proto.CompactTextString(m) }
+func (*PurgeTreeRequest) ProtoMessage()    {}
+func (*PurgeTreeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aac35e28a5dd9ee3, []int{9}
+}
+
+func (m *PurgeTreeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PurgeTreeRequest.Unmarshal(m, b)
+}
+func (m *PurgeTreeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PurgeTreeRequest.Marshal(b, m, deterministic)
+}
+func (m *PurgeTreeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PurgeTreeRequest.Merge(m, src)
+}
+func (m *PurgeTreeRequest) XXX_Size() int {
+	return xxx_messageInfo_PurgeTreeRequest.Size(m)
+}
+func (m *PurgeTreeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_PurgeTreeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PurgeTreeRequest proto.InternalMessageInfo
+
+func (m *PurgeTreeRequest) GetTreeId() int64 {
+	if m != nil {
+		return m.TreeId
+	}
+	return 0
+}
+
+// RotateTreeKey request.
+type RotateTreeKeyRequest struct {
+	// ID of the tree whose key to rotate.
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId,proto3" json:"tree_id,omitempty"`
+	// Identifies the new private key. Mutually exclusive with key_spec.
+	PrivateKey *any.Any `protobuf:"bytes,2,opt,name=private_key,json=privateKey,proto3" json:"private_key,omitempty"`
+	// Describes the new private key to generate. Mutually exclusive with
+	// private_key. If neither is set, a key with the default parameters of the
+	// signature_algorithm of the tree is generated.
+	KeySpec *keyspb.Specification `protobuf:"bytes,3,opt,name=key_spec,json=keySpec,proto3" json:"key_spec,omitempty"`
+	// Period during which roots are signed by both the old and the new key,
+	// before the old key is retired. Defaults to 24 hours if unset.
+	Overlap              *duration.Duration `protobuf:"bytes,4,opt,name=overlap,proto3" json:"overlap,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *RotateTreeKeyRequest) Reset()         { *m = RotateTreeKeyRequest{} }
+func (m *RotateTreeKeyRequest) String() string { return proto.CompactTextString(m) }
+func (*RotateTreeKeyRequest) ProtoMessage()    {}
+func (*RotateTreeKeyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aac35e28a5dd9ee3, []int{10}
+}
+
+func (m *RotateTreeKeyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RotateTreeKeyRequest.Unmarshal(m, b)
+}
+func (m *RotateTreeKeyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RotateTreeKeyRequest.Marshal(b, m, deterministic)
+}
+func (m *RotateTreeKeyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RotateTreeKeyRequest.Merge(m, src)
+}
+func (m *RotateTreeKeyRequest) XXX_Size() int {
+	return xxx_messageInfo_RotateTreeKeyRequest.Size(m)
+}
+func (m *RotateTreeKeyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RotateTreeKeyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RotateTreeKeyRequest proto.InternalMessageInfo
+
+func (m *RotateTreeKeyRequest) GetTreeId() int64 {
+	if m != nil {
+		return m.TreeId
+	}
+	return 0
+}
+
+func (m *RotateTreeKeyRequest) GetPrivateKey() *any.Any {
+	if m != nil {
+		return m.PrivateKey
+	}
+	return nil
+}
+
+func (m *RotateTreeKeyRequest) GetKeySpec() *keyspb.Specification {
+	if m != nil {
+		return m.KeySpec
+	}
+	return nil
+}
+
+func (m *RotateTreeKeyRequest) GetOverlap() *duration.Duration {
+	if m != nil {
+		return m.Overlap
+	}
+	return nil
+}
+
+// LiftQuarantine request.
+type LiftQuarantineRequest struct {
+	// ID of the quarantined tree.
+	TreeId int64 `protobuf:"varint,1,opt,name=tree_id,json=treeId,proto3" json:"tree_id,omitempty"`
+	// State to move the tree to: ACTIVE, DRAINING or FROZEN. Defaults to
+	// ACTIVE if unset.
+	TreeState            TreeState `protobuf:"varint,2,opt,name=tree_state,json=treeState,proto3,enum=trillian.TreeState" json:"tree_state,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *LiftQuarantineRequest) Reset()         { *m = LiftQuarantineRequest{} }
+func (m *LiftQuarantineRequest) String() string { return proto.CompactTextString(m) }
+func (*LiftQuarantineRequest) ProtoMessage()    {}
+func (*LiftQuarantineRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aac35e28a5dd9ee3, []int{11}
+}
+
+func (m *LiftQuarantineRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LiftQuarantineRequest.Unmarshal(m, b)
+}
+func (m *LiftQuarantineRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LiftQuarantineRequest.Marshal(b, m, deterministic)
+}
+func (m *LiftQuarantineRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LiftQuarantineRequest.Merge(m, src)
+}
+func (m *LiftQuarantineRequest) XXX_Size() int {
+	return xxx_messageInfo_LiftQuarantineRequest.Size(m)
+}
+func (m *LiftQuarantineRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LiftQuarantineRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LiftQuarantineRequest proto.InternalMessageInfo
+
+func (m *LiftQuarantineRequest) GetTreeId() int64 {
+	if m != nil {
+		return m.TreeId
+	}
+	return 0
+}
+
+func (m *LiftQuarantineRequest) GetTreeState() TreeState {
+	if m != nil {
+		return m.TreeState
+	}
+	return TreeState_UNKNOWN_TREE_STATE
+}
+
+// PauseSequencing request.
+type PauseSequencingRequest struct {
+	// ID of the log to pause.
+	TreeId               int64    `protobuf:"varint,1,opt,name=tree_id,json=treeId,proto3" json:"tree_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PauseSequencingRequest) Reset()         { *m = PauseSequencingRequest{} }
+func (m *PauseSequencingRequest) String() string { return proto.CompactTextString(m) }
+func (*PauseSequencingRequest) ProtoMessage()    {}
+func (*PauseSequencingRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aac35e28a5dd9ee3, []int{12}
+}
+
+func (m *PauseSequencingRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PauseSequencingRequest.Unmarshal(m, b)
+}
+func (m *PauseSequencingRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PauseSequencingRequest.Marshal(b, m, deterministic)
+}
+func (m *PauseSequencingRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PauseSequencingRequest.Merge(m, src)
+}
+func (m *PauseSequencingRequest) XXX_Size() int {
+	return xxx_messageInfo_PauseSequencingRequest.Size(m)
+}
+func (m *PauseSequencingRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_PauseSequencingRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PauseSequencingRequest proto.InternalMessageInfo
+
+func (m *PauseSequencingRequest) GetTreeId() int64 {
+	if m != nil {
+		return m.TreeId
+	}
+	return 0
+}
+
+// ResumeSequencing request.
+type ResumeSequencingRequest struct {
+	// ID of the paused log.
+	TreeId               int64    `protobuf:"varint,1,opt,name=tree_id,json=treeId,proto3" json:"tree_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResumeSequencingRequest) Reset()         { *m = ResumeSequencingRequest{} }
+func (m *ResumeSequencingRequest) String() string { return proto.CompactTextString(m) }
+func (*ResumeSequencingRequest) ProtoMessage()    {}
+func (*ResumeSequencingRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aac35e28a5dd9ee3, []int{13}
+}
+
+func (m *ResumeSequencingRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResumeSequencingRequest.Unmarshal(m, b)
+}
+func (m *ResumeSequencingRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResumeSequencingRequest.Marshal(b, m, deterministic)
+}
+func (m *ResumeSequencingRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResumeSequencingRequest.Merge(m, src)
+}
+func (m *ResumeSequencingRequest) XXX_Size() int {
+	return xxx_messageInfo_ResumeSequencingRequest.Size(m)
+}
+func (m *ResumeSequencingRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResumeSequencingRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResumeSequencingRequest proto.InternalMessageInfo
+
+func (m *ResumeSequencingRequest) GetTreeId() int64 {
+	if m != nil {
+		return m.TreeId
+	}
+	return 0
+}
+
+// ResignLatestRoot request.
+type ResignLatestRootRequest struct {
+	// ID of the tree whose latest root is re-signed.
+	TreeId               int64    `protobuf:"varint,1,opt,name=tree_id,json=treeId,proto3" json:"tree_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResignLatestRootRequest) Reset()         { *m = ResignLatestRootRequest{} }
+func (m *ResignLatestRootRequest) String() string { return proto.CompactTextString(m) }
+func (*ResignLatestRootRequest) ProtoMessage()    {}
+func (*ResignLatestRootRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aac35e28a5dd9ee3, []int{14}
+}
+
+func (m *ResignLatestRootRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResignLatestRootRequest.Unmarshal(m, b)
+}
+func (m *ResignLatestRootRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResignLatestRootRequest.Marshal(b, m, deterministic)
+}
+func (m *ResignLatestRootRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResignLatestRootRequest.Merge(m, src)
+}
+func (m *ResignLatestRootRequest) XXX_Size() int {
+	return xxx_messageInfo_ResignLatestRootRequest.Size(m)
+}
+func (m *ResignLatestRootRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResignLatestRootRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResignLatestRootRequest proto.InternalMessageInfo
+
+func (m *ResignLatestRootRequest) GetTreeId() int64 {
+	if m != nil {
+		return m.TreeId
+	}
+	return 0
+}
+
+// ResignLatestRoot response.
+type ResignLatestRootResponse struct {
+	// The new root of a log, set if the tree is a log.
+	SignedLogRoot *SignedLogRoot `protobuf:"bytes,1,opt,name=signed_log_root,json=signedLogRoot,proto3" json:"signed_log_root,omitempty"`
+	// The new root of a map, set if the tree is a map.
+	SignedMapRoot        *SignedMapRoot `protobuf:"bytes,2,opt,name=signed_map_root,json=signedMapRoot,proto3" json:"signed_map_root,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *ResignLatestRootResponse) Reset()         { *m = ResignLatestRootResponse{} }
+func (m *ResignLatestRootResponse) String() string { return proto.CompactTextString(m) }
+func (*ResignLatestRootResponse) ProtoMessage()    {}
+func (*ResignLatestRootResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aac35e28a5dd9ee3, []int{15}
+}
+
+func (m *ResignLatestRootResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResignLatestRootResponse.Unmarshal(m, b)
+}
+func (m *ResignLatestRootResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResignLatestRootResponse.Marshal(b, m, deterministic)
+}
+func (m *ResignLatestRootResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResignLatestRootResponse.Merge(m, src)
+}
+func (m *ResignLatestRootResponse) XXX_Size() int {
+	return xxx_messageInfo_ResignLatestRootResponse.Size(m)
+}
+func (m *ResignLatestRootResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResignLatestRootResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResignLatestRootResponse proto.InternalMessageInfo
+
+func (m *ResignLatestRootResponse) GetSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.SignedLogRoot
+	}
+	return nil
+}
+
+func (m *ResignLatestRootResponse) GetSignedMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.SignedMapRoot
+	}
+	return nil
+}
+
+// ExportTrees request.
+type ExportTreesRequest struct {
+	// IDs of the trees to export. If empty, all trees which are not deleted are
+	// exported.
+	TreeId               []int64  `protobuf:"varint,1,rep,packed,name=tree_id,json=treeId,proto3" json:"tree_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ExportTreesRequest) Reset()         { *m = ExportTreesRequest{} }
+func (m *ExportTreesRequest) String() string { return proto.CompactTextString(m) }
+func (*ExportTreesRequest) ProtoMessage()    {}
+func (*ExportTreesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aac35e28a5dd9ee3, []int{16}
+}
+
+func (m *ExportTreesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExportTreesRequest.Unmarshal(m, b)
+}
+func (m *ExportTreesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExportTreesRequest.Marshal(b, m, deterministic)
+}
+func (m *ExportTreesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExportTreesRequest.Merge(m, src)
+}
+func (m *ExportTreesRequest) XXX_Size() int {
+	return xxx_messageInfo_ExportTreesRequest.Size(m)
+}
+func (m *ExportTreesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExportTreesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ExportTreesRequest proto.InternalMessageInfo
+
+func (m *ExportTreesRequest) GetTreeId() []int64 {
+	if m != nil {
+		return m.TreeId
+	}
+	return nil
+}
+
+// ExportTrees response.
+type ExportTreesResponse struct {
+	// The exported trees. A private_key is only kept if it refers to a key held
+	// outside of Trillian, a PEMKeyFile or a PKCS11Config, and then without its
+	// password or PIN. Trees whose private key is held by Trillian have none.
+	Tree                 []*Tree  `protobuf:"bytes,1,rep,name=tree,proto3" json:"tree,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ExportTreesResponse) Reset()         { *m = ExportTreesResponse{} }
+func (m *ExportTreesResponse) String() string { return proto.CompactTextString(m) }
+func (*ExportTreesResponse) ProtoMessage()    {}
+func (*ExportTreesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aac35e28a5dd9ee3, []int{17}
+}
+
+func (m *ExportTreesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExportTreesResponse.Unmarshal(m, b)
+}
+func (m *ExportTreesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExportTreesResponse.Marshal(b, m, deterministic)
+}
+func (m *ExportTreesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExportTreesResponse.Merge(m, src)
+}
+func (m *ExportTreesResponse) XXX_Size() int {
+	return xxx_messageInfo_ExportTreesResponse.Size(m)
+}
+func (m *ExportTreesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExportTreesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ExportTreesResponse proto.InternalMessageInfo
+
+func (m *ExportTreesResponse) GetTree() []*Tree {
+	if m != nil {
+		return m.Tree
+	}
+	return nil
+}
+
+// ImportTrees request.
+type ImportTreesRequest struct {
+	// Trees to create, usually from an ExportTreesResponse. Each is created as
+	// by CreateTree, in the ACTIVE state.
+	Tree []*Tree `protobuf:"bytes,1,rep,name=tree,proto3" json:"tree,omitempty"`
+	// Describes the private key to generate for each tree without a
+	// private_key. If unset, a key with the default parameters of the
+	// signature_algorithm of the tree is generated. The public_key of such
+	// trees is replaced by the generated one.
+	KeySpec *keyspb.Specification `protobuf:"bytes,2,opt,name=key_spec,json=keySpec,proto3" json:"key_spec,omitempty"`
+	// If true, each tree is created with its tree_id, which must not be used by
+	// any other tree, rather than a new one. Maps must keep their ID to be
+	// imported with ImportMapRevision, as their hashes depend on it.
+	KeepTreeIds          bool     `protobuf:"varint,3,opt,name=keep_tree_ids,json=keepTreeIds,proto3" json:"keep_tree_ids,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ImportTreesRequest) Reset()         { *m = ImportTreesRequest{} }
+func (m *ImportTreesRequest) String() string { return proto.CompactTextString(m) }
+func (*ImportTreesRequest) ProtoMessage()    {}
+func (*ImportTreesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aac35e28a5dd9ee3, []int{18}
 }
 
-func (m *DeleteTreeRequest) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_DeleteTreeRequest.Unmarshal(m, b)
+func (m *ImportTreesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportTreesRequest.Unmarshal(m, b)
 }
-func (m *DeleteTreeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_DeleteTreeRequest.Marshal(b, m, deterministic)
+func (m *ImportTreesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportTreesRequest.Marshal(b, m, deterministic)
 }
-func (m *DeleteTreeRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_DeleteTreeRequest.Merge(m, src)
+func (m *ImportTreesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportTreesRequest.Merge(m, src)
 }
-func (m *DeleteTreeRequest) XXX_Size() int {
-	return xxx_messageInfo_DeleteTreeRequest.Size(m)
+func (m *ImportTreesRequest) XXX_Size() int {
+	return xxx_messageInfo_ImportTreesRequest.Size(m)
 }
-func (m *DeleteTreeRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_DeleteTreeRequest.DiscardUnknown(m)
+func (m *ImportTreesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportTreesRequest.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_DeleteTreeRequest proto.InternalMessageInfo
+var xxx_messageInfo_ImportTreesRequest proto.InternalMessageInfo
 
-func (m *DeleteTreeRequest) GetTreeId() int64 {
+func (m *ImportTreesRequest) GetTree() []*Tree {
 	if m != nil {
-		return m.TreeId
+		return m.Tree
 	}
-	return 0
+	return nil
 }
 
-// UndeleteTree request.
-type UndeleteTreeRequest struct {
-	// ID of the tree to undelete.
-	TreeId               int64    `protobuf:"varint,1,opt,name=tree_id,json=treeId,proto3" json:"tree_id,omitempty"`
+func (m *ImportTreesRequest) GetKeySpec() *keyspb.Specification {
+	if m != nil {
+		return m.KeySpec
+	}
+	return nil
+}
+
+func (m *ImportTreesRequest) GetKeepTreeIds() bool {
+	if m != nil {
+		return m.KeepTreeIds
+	}
+	return false
+}
+
+// ImportTrees response.
+type ImportTreesResponse struct {
+	// The created trees, in the order of the request.
+	Tree                 []*Tree  `protobuf:"bytes,1,rep,name=tree,proto3" json:"tree,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *UndeleteTreeRequest) Reset()         { *m = UndeleteTreeRequest{} }
-func (m *UndeleteTreeRequest) String() string { return proto.CompactTextString(m) }
-func (*UndeleteTreeRequest) ProtoMessage()    {}
-func (*UndeleteTreeRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_aac35e28a5dd9ee3, []int{6}
+func (m *ImportTreesResponse) Reset()         { *m = ImportTreesResponse{} }
+func (m *ImportTreesResponse) String() string { return proto.CompactTextString(m) }
+func (*ImportTreesResponse) ProtoMessage()    {}
+func (*ImportTreesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_aac35e28a5dd9ee3, []int{19}
 }
 
-func (m *UndeleteTreeRequest) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_UndeleteTreeRequest.Unmarshal(m, b)
+func (m *ImportTreesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportTreesResponse.Unmarshal(m, b)
 }
-func (m *UndeleteTreeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_UndeleteTreeRequest.Marshal(b, m, deterministic)
+func (m *ImportTreesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportTreesResponse.Marshal(b, m, deterministic)
 }
-func (m *UndeleteTreeRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_UndeleteTreeRequest.Merge(m, src)
+func (m *ImportTreesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportTreesResponse.Merge(m, src)
 }
-func (m *UndeleteTreeRequest) XXX_Size() int {
-	return xxx_messageInfo_UndeleteTreeRequest.Size(m)
+func (m *ImportTreesResponse) XXX_Size() int {
+	return xxx_messageInfo_ImportTreesResponse.Size(m)
 }
-func (m *UndeleteTreeRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_UndeleteTreeRequest.DiscardUnknown(m)
+func (m *ImportTreesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportTreesResponse.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_UndeleteTreeRequest proto.InternalMessageInfo
+var xxx_messageInfo_ImportTreesResponse proto.InternalMessageInfo
 
-func (m *UndeleteTreeRequest) GetTreeId() int64 {
+func (m *ImportTreesResponse) GetTree() []*Tree {
 	if m != nil {
-		return m.TreeId
+		return m.Tree
 	}
-	return 0
+	return nil
 }
 
 func init() {
 	proto.RegisterType((*ListTreesRequest)(nil), "trillian.ListTreesRequest")
+	proto.RegisterMapType((map[string]string)(nil), "trillian.ListTreesRequest.LabelsEntry")
 	proto.RegisterType((*ListTreesResponse)(nil), "trillian.ListTreesResponse")
 	proto.RegisterType((*GetTreeRequest)(nil), "trillian.GetTreeRequest")
 	proto.RegisterType((*CreateTreeRequest)(nil), "trillian.CreateTreeRequest")
+	proto.RegisterType((*CreateTreesFromTemplateRequest)(nil), "trillian.CreateTreesFromTemplateRequest")
+	proto.RegisterType((*CreateTreesFromTemplateResponse)(nil), "trillian.CreateTreesFromTemplateResponse")
 	proto.RegisterType((*UpdateTreeRequest)(nil), "trillian.UpdateTreeRequest")
 	proto.RegisterType((*DeleteTreeRequest)(nil), "trillian.DeleteTreeRequest")
 	proto.RegisterType((*UndeleteTreeRequest)(nil), "trillian.UndeleteTreeRequest")
+	proto.RegisterType((*PurgeTreeRequest)(nil), "trillian.PurgeTreeRequest")
+	proto.RegisterType((*RotateTreeKeyRequest)(nil), "trillian.RotateTreeKeyRequest")
+	proto.RegisterType((*LiftQuarantineRequest)(nil), "trillian.LiftQuarantineRequest")
+	proto.RegisterType((*PauseSequencingRequest)(nil), "trillian.PauseSequencingRequest")
+	proto.RegisterType((*ResumeSequencingRequest)(nil), "trillian.ResumeSequencingRequest")
+	proto.RegisterType((*ResignLatestRootRequest)(nil), "trillian.ResignLatestRootRequest")
+	proto.RegisterType((*ResignLatestRootResponse)(nil), "trillian.ResignLatestRootResponse")
+	proto.RegisterType((*ExportTreesRequest)(nil), "trillian.ExportTreesRequest")
+	proto.RegisterType((*ExportTreesResponse)(nil), "trillian.ExportTreesResponse")
+	proto.RegisterType((*ImportTreesRequest)(nil), "trillian.ImportTreesRequest")
+	proto.RegisterType((*ImportTreesResponse)(nil), "trillian.ImportTreesResponse")
 }
 
 func init() { proto.RegisterFile("trillian_admin_api.proto", fileDescriptor_aac35e28a5dd9ee3) }
 
 var fileDescriptor_aac35e28a5dd9ee3 = []byte{
-	// 548 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x54, 0xe1, 0x6a, 0x13, 0x4d,
-	0x14, 0xfd, 0xb6, 0x2d, 0x6d, 0xbe, 0x9b, 0x1a, 0xcc, 0x94, 0x62, 0xba, 0x56, 0x8c, 0xa3, 0x42,
-	0x8d, 0xb2, 0x6b, 0x23, 0x22, 0x54, 0xfc, 0xd1, 0x2a, 0x15, 0x41, 0x21, 0xac, 0x29, 0x82, 0x20,
-	0xcb, 0x66, 0xf7, 0x26, 0x1d, 0x93, 0xec, 0xac, 0x3b, 0x13, 0x25, 0x88, 0x7f, 0x7c, 0x05, 0x5f,
-	0xc6, 0xf7, 0xf0, 0x15, 0x7c, 0x10, 0x99, 0xd9, 0xd9, 0xee, 0xa6, 0xdb, 0x48, 0xf1, 0xd7, 0xee,
-	0xdc, 0x73, 0xef, 0x3d, 0x77, 0xce, 0xb9, 0x0c, 0xb4, 0x64, 0xca, 0x26, 0x13, 0x16, 0xc4, 0x7e,
-	0x10, 0x4d, 0x59, 0xec, 0x07, 0x09, 0x73, 0x92, 0x94, 0x4b, 0x4e, 0x6a, 0x39, 0x62, 0x37, 0xf2,
-	0xbf, 0x0c, 0xb1, 0xed, 0x30, 0x9d, 0x27, 0x92, 0xbb, 0x63, 0x9c, 0x8b, 0x64, 0x60, 0x3e, 0x06,
-	0xdb, 0x1d, 0x71, 0x3e, 0x9a, 0xa0, 0x1b, 0x24, 0xcc, 0x0d, 0xe2, 0x98, 0xcb, 0x40, 0x32, 0x1e,
-	0x0b, 0x83, 0xb6, 0x0d, 0xaa, 0x4f, 0x83, 0xd9, 0xd0, 0x1d, 0x32, 0x9c, 0x44, 0xfe, 0x34, 0x10,
-	0xe3, 0x2c, 0x83, 0x3e, 0x86, 0xab, 0xaf, 0x99, 0x90, 0xfd, 0x14, 0x51, 0x78, 0xf8, 0x69, 0x86,
-	0x42, 0x92, 0x5b, 0xb0, 0x29, 0x4e, 0xf9, 0x17, 0x3f, 0xc2, 0x09, 0x4a, 0x8c, 0x5a, 0x56, 0xdb,
-	0xda, 0xab, 0x79, 0x75, 0x15, 0x7b, 0x91, 0x85, 0xe8, 0x13, 0x68, 0x96, 0xca, 0x44, 0xc2, 0x63,
-	0x81, 0x84, 0xc2, 0x9a, 0x4c, 0x11, 0x5b, 0x56, 0x7b, 0x75, 0xaf, 0xde, 0x6d, 0x38, 0x67, 0xd7,
-	0x50, 0x69, 0x9e, 0xc6, 0xe8, 0x3d, 0x68, 0xbc, 0x44, 0x5d, 0x97, 0xb3, 0x5d, 0x83, 0x0d, 0x85,
-	0xf8, 0x2c, 0x23, 0x5a, 0xf5, 0xd6, 0xd5, 0xf1, 0x55, 0x44, 0x19, 0x34, 0x9f, 0xa7, 0x18, 0x48,
-	0x2c, 0x67, 0x17, 0x1c, 0xd6, 0x32, 0x0e, 0xf2, 0x10, 0x6a, 0x63, 0x9c, 0xfb, 0x22, 0xc1, 0xb0,
-	0xb5, 0xa2, 0xf3, 0xb6, 0x1d, 0x23, 0xda, 0xdb, 0x04, 0x43, 0x36, 0x64, 0xa1, 0x56, 0xc9, 0xdb,
-	0x18, 0xe3, 0x5c, 0x45, 0xa8, 0x84, 0xe6, 0x49, 0x12, 0xfd, 0x03, 0xd5, 0x53, 0xa8, 0xcf, 0x74,
-	0xa1, 0xd6, 0xd4, 0xb0, 0xd9, 0x4e, 0x26, 0xbb, 0x93, 0xcb, 0xee, 0x1c, 0x2b, 0xd9, 0xdf, 0x04,
-	0x62, 0xec, 0x41, 0x96, 0xae, 0xfe, 0xe9, 0x03, 0x68, 0x66, 0x7a, 0x5e, 0x4a, 0x0e, 0x07, 0xb6,
-	0x4e, 0xe2, 0xe8, 0xd2, 0xf9, 0xdd, 0x9f, 0x6b, 0x70, 0xa5, 0x6f, 0x46, 0x3e, 0x54, 0xbb, 0x46,
-	0x8e, 0xe1, 0xff, 0x33, 0xd3, 0x88, 0x5d, 0xdc, 0xe7, 0xfc, 0x02, 0xd8, 0xd7, 0x2f, 0xc4, 0x32,
-	0x97, 0xe9, 0x7f, 0xe4, 0x1d, 0x6c, 0x18, 0x0f, 0x49, 0xab, 0xc8, 0x5c, 0xb4, 0xd5, 0x3e, 0xa7,
-	0x17, 0xa5, 0xdf, 0x7f, 0xfd, 0xfe, 0xb1, 0xb2, 0x4b, 0x6c, 0xf7, 0xf3, 0xfe, 0x00, 0x65, 0xb0,
-	0xef, 0xaa, 0x39, 0x85, 0xfb, 0xd5, 0x4c, 0xff, 0xac, 0xf3, 0x8d, 0xf4, 0x01, 0x0a, 0xc7, 0x49,
-	0x69, 0x8a, 0xca, 0x1e, 0x54, 0xda, 0xef, 0xe8, 0xf6, 0x5b, 0xb4, 0xb1, 0xd8, 0xfe, 0xc0, 0xea,
-	0x10, 0x04, 0x28, 0xcc, 0x2d, 0x77, 0xad, 0x58, 0x5e, 0xe9, 0xda, 0xd1, 0x5d, 0xef, 0x74, 0x6f,
-	0x5e, 0x34, 0xb4, 0x53, 0x4c, 0xae, 0x68, 0x3e, 0x00, 0x14, 0x6e, 0x96, 0x69, 0x2a, 0x1e, 0x2f,
-	0xd3, 0xa6, 0xf3, 0x37, 0x6d, 0x3e, 0xc2, 0x66, 0xd9, 0x7e, 0x72, 0xa3, 0x74, 0x8f, 0xea, 0x5a,
-	0x54, 0x28, 0xee, 0x6b, 0x8a, 0xbb, 0x9d, 0xdb, 0xcb, 0x29, 0x0e, 0x66, 0xa6, 0xcf, 0x51, 0x0f,
-	0x76, 0x42, 0x3e, 0xcd, 0xb7, 0x78, 0xf1, 0x35, 0x3a, 0xda, 0x5e, 0x58, 0xaa, 0xc3, 0x84, 0xf5,
-	0x54, 0xb8, 0x67, 0xbd, 0xb7, 0x47, 0x4c, 0x9e, 0xce, 0x06, 0x4e, 0xc8, 0xa7, 0xae, 0x79, 0x77,
-	0xf2, 0xd2, 0xc1, 0xba, 0xae, 0x7d, 0xf4, 0x27, 0x00, 0x00, 0xff, 0xff, 0x58, 0xc1, 0xa5, 0x85,
-	0xff, 0x04, 0x00, 0x00,
+	// 1259 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x57, 0xdd, 0x72, 0xdb, 0x44,
+	0x14, 0xae, 0xe2, 0x36, 0xb1, 0x8f, 0x9b, 0xbf, 0x4d, 0x4b, 0x14, 0xf5, 0x27, 0xae, 0x80, 0x8e,
+	0x9b, 0x82, 0x4d, 0x5c, 0x98, 0xa1, 0x65, 0x80, 0x49, 0xdb, 0xa4, 0x93, 0x69, 0xca, 0x18, 0xc5,
+	0x1d, 0x66, 0x60, 0x18, 0xcd, 0xc6, 0x3a, 0x71, 0x85, 0x2d, 0x69, 0xd1, 0xae, 0x43, 0x55, 0x86,
+	0x1b, 0x1e, 0x80, 0x19, 0x06, 0x2e, 0x79, 0x25, 0xae, 0x78, 0x05, 0x9e, 0x81, 0x6b, 0x66, 0x57,
+	0xb2, 0x25, 0xdb, 0x72, 0xec, 0xf4, 0x2a, 0xda, 0xf3, 0xf3, 0x9d, 0xfd, 0xce, 0xee, 0x7e, 0x27,
+	0x06, 0x5d, 0x84, 0x6e, 0xaf, 0xe7, 0x52, 0xdf, 0xa6, 0x8e, 0xe7, 0xfa, 0x36, 0x65, 0x6e, 0x8d,
+	0x85, 0x81, 0x08, 0x48, 0x71, 0xe0, 0x31, 0x56, 0x06, 0x5f, 0xb1, 0xc7, 0x30, 0xda, 0x61, 0xc4,
+	0x44, 0x50, 0xef, 0x62, 0xc4, 0xd9, 0x49, 0xf2, 0x27, 0xf1, 0xdd, 0xec, 0x04, 0x41, 0xa7, 0x87,
+	0x75, 0xca, 0xdc, 0x3a, 0xf5, 0xfd, 0x40, 0x50, 0xe1, 0x06, 0x3e, 0x4f, 0xbc, 0x5b, 0x89, 0x57,
+	0xad, 0x4e, 0xfa, 0xa7, 0x75, 0xea, 0x47, 0x89, 0xeb, 0xf6, 0xb8, 0xcb, 0xe9, 0x87, 0x2a, 0x37,
+	0xf1, 0x57, 0xc6, 0xfd, 0xa7, 0x2e, 0xf6, 0x1c, 0xdb, 0xa3, 0xbc, 0x9b, 0x44, 0x6c, 0x8f, 0x47,
+	0x08, 0xd7, 0x43, 0x2e, 0xa8, 0xc7, 0xe2, 0x00, 0xf3, 0xf7, 0xcb, 0xb0, 0x76, 0xe4, 0x72, 0xd1,
+	0x0a, 0x11, 0xb9, 0x85, 0x3f, 0xf6, 0x91, 0x0b, 0x72, 0x07, 0xae, 0xf2, 0x57, 0xc1, 0x4f, 0xb6,
+	0x83, 0x3d, 0x14, 0xe8, 0xe8, 0x5a, 0x45, 0xab, 0x16, 0xad, 0xb2, 0xb4, 0x3d, 0x8d, 0x4d, 0x64,
+	0x17, 0x40, 0x84, 0x88, 0xb6, 0x88, 0x18, 0x72, 0x7d, 0xa1, 0x52, 0xa8, 0xae, 0x34, 0x48, 0x6d,
+	0xd8, 0x14, 0x09, 0xd7, 0x8a, 0x18, 0x5a, 0x25, 0x91, 0x7c, 0x71, 0xf2, 0x31, 0x94, 0x55, 0x0a,
+	0x17, 0x54, 0x20, 0xd7, 0x0b, 0x2a, 0x67, 0x63, 0x34, 0xe7, 0x58, 0xfa, 0x2c, 0x10, 0x83, 0x4f,
+	0x4e, 0x6a, 0xb0, 0xe1, 0xb8, 0x9c, 0xf5, 0x68, 0x64, 0xfb, 0xd4, 0x43, 0x9b, 0x85, 0x78, 0xea,
+	0xbe, 0xd6, 0x2f, 0x57, 0xb4, 0x6a, 0xc9, 0x5a, 0x4f, 0x5c, 0x5f, 0x51, 0x0f, 0x9b, 0xca, 0x41,
+	0x0e, 0x60, 0xbd, 0x1d, 0x22, 0x15, 0x68, 0x4b, 0xaa, 0xb2, 0x58, 0x28, 0xf4, 0x2b, 0x15, 0xad,
+	0x5a, 0x6e, 0x18, 0xb5, 0xb8, 0x1b, 0xb5, 0x41, 0x37, 0x6a, 0xad, 0x41, 0x37, 0xac, 0xd5, 0x38,
+	0x49, 0x1a, 0x8e, 0x65, 0x0a, 0x79, 0x0c, 0xab, 0x59, 0x1c, 0xf4, 0x1d, 0x7d, 0x71, 0x26, 0xca,
+	0x72, 0x8a, 0xb2, 0xef, 0x3b, 0xe4, 0x0b, 0x58, 0xec, 0xd1, 0x13, 0xec, 0x71, 0xbd, 0x54, 0x29,
+	0x54, 0xcb, 0x8d, 0xbb, 0x29, 0xd9, 0xf1, 0x9e, 0xd7, 0x8e, 0x54, 0xe0, 0xbe, 0x2f, 0xc2, 0xc8,
+	0x4a, 0xb2, 0xc8, 0x0d, 0x28, 0x31, 0xda, 0x41, 0x9b, 0xbb, 0x6f, 0x50, 0x5f, 0xaa, 0x68, 0xd5,
+	0x2b, 0x56, 0x51, 0x1a, 0x8e, 0xdd, 0x37, 0x48, 0x6e, 0x01, 0x28, 0xa7, 0x08, 0xba, 0xe8, 0xeb,
+	0x45, 0xd5, 0x0f, 0x15, 0xde, 0x92, 0x06, 0xe3, 0x21, 0x94, 0x33, 0x90, 0x64, 0x0d, 0x0a, 0x5d,
+	0x8c, 0xd4, 0x49, 0x96, 0x2c, 0xf9, 0x49, 0xae, 0xc1, 0x95, 0x33, 0xda, 0xeb, 0xa3, 0xbe, 0xa0,
+	0x6c, 0xf1, 0xe2, 0xd1, 0xc2, 0xa7, 0x9a, 0x69, 0xc3, 0x7a, 0x66, 0x7b, 0x9c, 0x05, 0x3e, 0x47,
+	0x62, 0xc2, 0x65, 0x11, 0x22, 0xea, 0x9a, 0x62, 0xb2, 0x32, 0x7a, 0x6c, 0x96, 0xf2, 0x91, 0xbb,
+	0xb0, 0xea, 0xe3, 0x6b, 0x61, 0x67, 0xf6, 0x15, 0x83, 0x2f, 0x4b, 0x73, 0x73, 0xb0, 0x37, 0xf3,
+	0x1e, 0xac, 0x3c, 0x43, 0x85, 0x3f, 0xb8, 0x71, 0x9b, 0xb0, 0xa4, 0xee, 0x86, 0x1b, 0x5f, 0xb6,
+	0x82, 0xb5, 0x28, 0x97, 0x87, 0x8e, 0xe9, 0xc2, 0xfa, 0x93, 0xb8, 0xa7, 0x99, 0xe8, 0x74, 0x2f,
+	0xda, 0xd4, 0xbd, 0x7c, 0x04, 0xc5, 0x2e, 0x46, 0x36, 0x67, 0xd8, 0x56, 0x9b, 0x28, 0x37, 0xae,
+	0xd7, 0x92, 0x57, 0x79, 0xcc, 0xb0, 0xed, 0x9e, 0xba, 0x6d, 0xf5, 0x94, 0xac, 0xa5, 0x2e, 0x46,
+	0xd2, 0x62, 0xfe, 0xa9, 0xc1, 0xed, 0xb4, 0x16, 0x3f, 0x08, 0x03, 0xaf, 0x85, 0x1e, 0xeb, 0x51,
+	0x31, 0x2c, 0xbc, 0x03, 0x45, 0x91, 0x98, 0xa6, 0x14, 0x1f, 0xfa, 0x2f, 0xbe, 0x01, 0x79, 0x22,
+	0xed, 0xa0, 0xef, 0x0b, 0xbd, 0xa0, 0x8e, 0x3a, 0x5e, 0x98, 0xfb, 0xb0, 0x3d, 0x75, 0x57, 0xf3,
+	0x9f, 0x8d, 0x29, 0x60, 0xfd, 0x25, 0x73, 0xde, 0xa2, 0x91, 0x9f, 0x41, 0xb9, 0xaf, 0x12, 0x95,
+	0xae, 0xe8, 0x0b, 0x53, 0x1e, 0xc1, 0x81, 0x94, 0x9e, 0x17, 0x94, 0x77, 0x2d, 0x88, 0xc3, 0xe5,
+	0xb7, 0xf9, 0x01, 0xac, 0xc7, 0x8a, 0x31, 0xd7, 0x61, 0xd7, 0x60, 0xe3, 0xa5, 0xef, 0xcc, 0x1f,
+	0x7f, 0x1f, 0xd6, 0x9a, 0xfd, 0xb0, 0x33, 0x5f, 0xf0, 0xdf, 0x1a, 0x5c, 0xb3, 0xa4, 0xf6, 0xaa,
+	0xf0, 0xe7, 0x18, 0xcd, 0xca, 0x20, 0x9f, 0x40, 0x99, 0x85, 0xee, 0x99, 0xa4, 0x2e, 0xdf, 0x4e,
+	0xcc, 0xfc, 0xda, 0x04, 0xf3, 0x3d, 0x3f, 0xb2, 0x20, 0x09, 0x7c, 0x8e, 0xd1, 0xc8, 0xc1, 0x17,
+	0xe6, 0x3a, 0xf8, 0x07, 0xb0, 0x14, 0x9c, 0x61, 0xd8, 0xa3, 0x4c, 0xe9, 0x5a, 0xb9, 0xb1, 0x35,
+	0x51, 0xe4, 0x69, 0xa2, 0xfc, 0xd6, 0x20, 0xd2, 0x74, 0xe0, 0xfa, 0x91, 0x7b, 0x2a, 0xbe, 0xee,
+	0xd3, 0x90, 0xfa, 0xc2, 0xf5, 0x67, 0x76, 0x80, 0x34, 0x00, 0x52, 0x01, 0x56, 0x74, 0xa6, 0xe8,
+	0x6f, 0x69, 0xa8, 0xbf, 0xe6, 0x2e, 0xbc, 0xd3, 0xa4, 0x7d, 0x8e, 0xc7, 0x12, 0xdc, 0x6f, 0xbb,
+	0x7e, 0x67, 0x66, 0xa3, 0x1b, 0xb0, 0x69, 0x21, 0xef, 0x7b, 0x17, 0xcf, 0x71, 0x3b, 0xfe, 0x91,
+	0x14, 0x7d, 0x61, 0x05, 0x81, 0x98, 0x99, 0xf3, 0x97, 0x06, 0xfa, 0x64, 0x52, 0xf2, 0x24, 0xbe,
+	0x84, 0x55, 0xe9, 0x41, 0xc7, 0xee, 0x05, 0x1d, 0x3b, 0x0c, 0x02, 0x91, 0x5c, 0xf2, 0xcd, 0x94,
+	0xf0, 0xb1, 0x0a, 0x38, 0x0a, 0x3a, 0x2a, 0x73, 0x99, 0x67, 0x97, 0x19, 0x00, 0x8f, 0xb2, 0x18,
+	0x60, 0x21, 0x1f, 0xe0, 0x05, 0x65, 0x59, 0x80, 0x64, 0x69, 0x7e, 0x08, 0x64, 0xff, 0x35, 0x0b,
+	0xc2, 0xd1, 0xd1, 0x3a, 0xc2, 0xa6, 0x90, 0x61, 0xf3, 0x10, 0x36, 0x46, 0xc2, 0x2f, 0xf0, 0xb4,
+	0x7f, 0xd3, 0x80, 0x1c, 0x7a, 0x13, 0xa5, 0xe6, 0x51, 0xec, 0x8b, 0x8b, 0x94, 0x09, 0xcb, 0x5d,
+	0x44, 0x66, 0x27, 0x2c, 0xb8, 0xba, 0xe2, 0x45, 0xab, 0x2c, 0x8d, 0x2d, 0x45, 0x85, 0x4b, 0x2e,
+	0x87, 0xde, 0x5b, 0x71, 0x69, 0xfc, 0x57, 0x82, 0xe5, 0x56, 0x62, 0xdf, 0x93, 0xff, 0x7d, 0x91,
+	0x03, 0x28, 0x0d, 0xa7, 0x11, 0x31, 0xa6, 0x4f, 0x50, 0xe3, 0x46, 0xae, 0x2f, 0xae, 0x6d, 0x5e,
+	0x22, 0xdf, 0xc0, 0x52, 0x32, 0x74, 0x88, 0x9e, 0x46, 0x8e, 0xce, 0x21, 0x63, 0x6c, 0x53, 0xa6,
+	0xf9, 0xeb, 0x3f, 0xff, 0xfe, 0xb1, 0x70, 0x93, 0x18, 0xf5, 0xb3, 0xdd, 0x13, 0x14, 0x74, 0xb7,
+	0x2e, 0x77, 0xc9, 0xeb, 0x3f, 0x27, 0xf4, 0x3f, 0xdf, 0xf9, 0x85, 0xb4, 0x00, 0x52, 0x81, 0x26,
+	0x99, 0x5d, 0x4c, 0x0c, 0xae, 0x09, 0xf8, 0x2d, 0x05, 0xbf, 0x61, 0xae, 0x8c, 0xc2, 0x3f, 0xd2,
+	0x76, 0x08, 0x83, 0xcd, 0x29, 0xb2, 0x4f, 0xaa, 0x79, 0x25, 0xf2, 0xe6, 0x95, 0x71, 0x6f, 0x8e,
+	0xc8, 0x61, 0x83, 0x10, 0x20, 0x9d, 0x10, 0x59, 0x1e, 0x13, 0x73, 0x63, 0x82, 0xc7, 0x8e, 0xe2,
+	0xf1, 0x5e, 0x63, 0x3b, 0xaf, 0x4d, 0xb5, 0xb4, 0x57, 0x92, 0xd8, 0xf7, 0x00, 0xe9, 0x48, 0xc8,
+	0x96, 0x99, 0x18, 0x14, 0xd3, 0x4e, 0x63, 0xe7, 0xbc, 0xd3, 0xf8, 0x01, 0xae, 0x66, 0x67, 0x08,
+	0xb9, 0x95, 0xe1, 0xe1, 0x3b, 0x33, 0x4b, 0xdc, 0x57, 0x25, 0xde, 0xdf, 0x79, 0x77, 0x7a, 0x89,
+	0x47, 0xfd, 0x04, 0x87, 0x3c, 0x84, 0xd2, 0x70, 0xfe, 0x64, 0xaf, 0xe6, 0xf8, 0x50, 0x9a, 0xa8,
+	0x72, 0x89, 0xec, 0xc1, 0xf2, 0xc8, 0x30, 0x22, 0xb7, 0xd3, 0x90, 0xbc, 0x29, 0x95, 0x03, 0xf1,
+	0x04, 0x56, 0x46, 0x07, 0x00, 0xd9, 0xce, 0xbe, 0x80, 0x9c, 0xd1, 0x90, 0x03, 0xb2, 0x0f, 0xab,
+	0x63, 0xfa, 0x4e, 0x2a, 0x19, 0x22, 0xb9, 0xd2, 0x9f, 0x03, 0xf3, 0x0c, 0xd6, 0xc6, 0x35, 0x9f,
+	0xdc, 0xc9, 0x30, 0xca, 0x9f, 0x07, 0x39, 0x40, 0xdf, 0xc1, 0xda, 0xb8, 0xa6, 0x8f, 0x01, 0xe5,
+	0x0d, 0x09, 0xc3, 0x3c, 0x2f, 0x64, 0x78, 0xc3, 0x8f, 0xa0, 0x9c, 0xd1, 0x58, 0x72, 0x33, 0x4d,
+	0x9a, 0x54, 0x6a, 0xe3, 0xd6, 0x14, 0x6f, 0x16, 0xed, 0xd0, 0xcb, 0x45, 0x3b, 0xf4, 0xce, 0x43,
+	0xcb, 0x91, 0x46, 0xf3, 0xd2, 0xe3, 0x26, 0x6c, 0xb5, 0x03, 0x6f, 0x30, 0xf7, 0x47, 0x7f, 0x5d,
+	0x3e, 0xbe, 0x3e, 0x22, 0x89, 0x7b, 0xcc, 0x6d, 0x4a, 0x73, 0x53, 0xfb, 0xd6, 0xe8, 0xb8, 0xe2,
+	0x55, 0xff, 0xa4, 0xd6, 0x0e, 0xbc, 0x7a, 0xf2, 0x53, 0x6f, 0x90, 0x7a, 0xb2, 0xa8, 0x72, 0x1f,
+	0xfc, 0x3f, 0x00, 0x80, 0x11, 0xde, 0x65, 0xcf, 0x0e, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -409,6 +1186,9 @@ type TrillianAdminClient interface {
 	// e.g.: tree_id, create_time and update_time.
 	// Returns the created tree, with all system-generated fields assigned.
 	CreateTree(ctx context.Context, in *CreateTreeRequest, opts ...grpc.CallOption) (*Tree, error)
+	// Creates a batch of trees which share the settings of a template, in a
+	// single transaction: either all of them are created, or none is.
+	CreateTreesFromTemplate(ctx context.Context, in *CreateTreesFromTemplateRequest, opts ...grpc.CallOption) (*CreateTreesFromTemplateResponse, error)
 	// Updates a tree.
 	// See Tree for details. Readonly fields cannot be updated.
 	UpdateTree(ctx context.Context, in *UpdateTreeRequest, opts ...grpc.CallOption) (*Tree, error)
@@ -420,6 +1200,35 @@ type TrillianAdminClient interface {
 	// A soft-deleted tree may be undeleted for a certain period, after which
 	// it'll be permanently deleted.
 	UndeleteTree(ctx context.Context, in *UndeleteTreeRequest, opts ...grpc.CallOption) (*Tree, error)
+	// Permanently deletes a soft-deleted tree without waiting for its
+	// delete_retention to pass. The tree can't be undeleted afterwards.
+	PurgeTree(ctx context.Context, in *PurgeTreeRequest, opts ...grpc.CallOption) (*Tree, error)
+	// Starts the rotation of the private key of a tree to a new key. Roots are
+	// signed by both keys for the overlap of the request, after which the old
+	// key is retired. Fails if a rotation is already in progress.
+	RotateTreeKey(ctx context.Context, in *RotateTreeKeyRequest, opts ...grpc.CallOption) (*Tree, error)
+	// Lifts the quarantine of a tree, which was placed in the QUARANTINED state
+	// after failing an integrity check. Quarantined trees can't leave that
+	// state through UpdateTree.
+	LiftQuarantine(ctx context.Context, in *LiftQuarantineRequest, opts ...grpc.CallOption) (*Tree, error)
+	// Pauses the sequencing of an ACTIVE log by moving it to the PAUSED state,
+	// in which it keeps serving reads and queuing leaves, but no leaves are
+	// integrated. Pausing a PAUSED log has no effect.
+	PauseSequencing(ctx context.Context, in *PauseSequencingRequest, opts ...grpc.CallOption) (*Tree, error)
+	// Resumes the sequencing of a PAUSED log by moving it back to the ACTIVE
+	// state. Resuming an ACTIVE log has no effect.
+	ResumeSequencing(ctx context.Context, in *ResumeSequencingRequest, opts ...grpc.CallOption) (*Tree, error)
+	// Re-signs the latest root of a log or map with the current key and
+	// timestamp, at a new revision with the same contents. This refreshes a
+	// root which went stale, e.g. while sequencing was paused, or gets a root
+	// signed by a new key after RotateTreeKey, without adding leaves.
+	ResignLatestRoot(ctx context.Context, in *ResignLatestRootRequest, opts ...grpc.CallOption) (*ResignLatestRootResponse, error)
+	// Exports the definitions of trees, without their private key material, so
+	// that they can be re-created in another environment with ImportTrees.
+	ExportTrees(ctx context.Context, in *ExportTreesRequest, opts ...grpc.CallOption) (*ExportTreesResponse, error)
+	// Creates trees from their definitions, usually exported by ExportTrees.
+	// Trees are created in order; if one fails, the ones before it remain.
+	ImportTrees(ctx context.Context, in *ImportTreesRequest, opts ...grpc.CallOption) (*ImportTreesResponse, error)
 }
 
 type trillianAdminClient struct {
@@ -457,6 +1266,15 @@ func (c *trillianAdminClient) CreateTree(ctx context.Context, in *CreateTreeRequ
 	return out, nil
 }
 
+func (c *trillianAdminClient) CreateTreesFromTemplate(ctx context.Context, in *CreateTreesFromTemplateRequest, opts ...grpc.CallOption) (*CreateTreesFromTemplateResponse, error) {
+	out := new(CreateTreesFromTemplateResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianAdmin/CreateTreesFromTemplate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *trillianAdminClient) UpdateTree(ctx context.Context, in *UpdateTreeRequest, opts ...grpc.CallOption) (*Tree, error) {
 	out := new(Tree)
 	err := c.cc.Invoke(ctx, "/trillian.TrillianAdmin/UpdateTree", in, out, opts...)
@@ -484,6 +1302,78 @@ func (c *trillianAdminClient) UndeleteTree(ctx context.Context, in *UndeleteTree
 	return out, nil
 }
 
+func (c *trillianAdminClient) PurgeTree(ctx context.Context, in *PurgeTreeRequest, opts ...grpc.CallOption) (*Tree, error) {
+	out := new(Tree)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianAdmin/PurgeTree", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianAdminClient) RotateTreeKey(ctx context.Context, in *RotateTreeKeyRequest, opts ...grpc.CallOption) (*Tree, error) {
+	out := new(Tree)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianAdmin/RotateTreeKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianAdminClient) LiftQuarantine(ctx context.Context, in *LiftQuarantineRequest, opts ...grpc.CallOption) (*Tree, error) {
+	out := new(Tree)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianAdmin/LiftQuarantine", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianAdminClient) PauseSequencing(ctx context.Context, in *PauseSequencingRequest, opts ...grpc.CallOption) (*Tree, error) {
+	out := new(Tree)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianAdmin/PauseSequencing", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianAdminClient) ResumeSequencing(ctx context.Context, in *ResumeSequencingRequest, opts ...grpc.CallOption) (*Tree, error) {
+	out := new(Tree)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianAdmin/ResumeSequencing", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianAdminClient) ResignLatestRoot(ctx context.Context, in *ResignLatestRootRequest, opts ...grpc.CallOption) (*ResignLatestRootResponse, error) {
+	out := new(ResignLatestRootResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianAdmin/ResignLatestRoot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianAdminClient) ExportTrees(ctx context.Context, in *ExportTreesRequest, opts ...grpc.CallOption) (*ExportTreesResponse, error) {
+	out := new(ExportTreesResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianAdmin/ExportTrees", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianAdminClient) ImportTrees(ctx context.Context, in *ImportTreesRequest, opts ...grpc.CallOption) (*ImportTreesResponse, error) {
+	out := new(ImportTreesResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianAdmin/ImportTrees", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TrillianAdminServer is the server API for TrillianAdmin service.
 type TrillianAdminServer interface {
 	// Lists all trees the requester has access to.
@@ -495,6 +1385,9 @@ type TrillianAdminServer interface {
 	// e.g.: tree_id, create_time and update_time.
 	// Returns the created tree, with all system-generated fields assigned.
 	CreateTree(context.Context, *CreateTreeRequest) (*Tree, error)
+	// Creates a batch of trees which share the settings of a template, in a
+	// single transaction: either all of them are created, or none is.
+	CreateTreesFromTemplate(context.Context, *CreateTreesFromTemplateRequest) (*CreateTreesFromTemplateResponse, error)
 	// Updates a tree.
 	// See Tree for details. Readonly fields cannot be updated.
 	UpdateTree(context.Context, *UpdateTreeRequest) (*Tree, error)
@@ -506,6 +1399,35 @@ type TrillianAdminServer interface {
 	// A soft-deleted tree may be undeleted for a certain period, after which
 	// it'll be permanently deleted.
 	UndeleteTree(context.Context, *UndeleteTreeRequest) (*Tree, error)
+	// Permanently deletes a soft-deleted tree without waiting for its
+	// delete_retention to pass. The tree can't be undeleted afterwards.
+	PurgeTree(context.Context, *PurgeTreeRequest) (*Tree, error)
+	// Starts the rotation of the private key of a tree to a new key. Roots are
+	// signed by both keys for the overlap of the request, after which the old
+	// key is retired. Fails if a rotation is already in progress.
+	RotateTreeKey(context.Context, *RotateTreeKeyRequest) (*Tree, error)
+	// Lifts the quarantine of a tree, which was placed in the QUARANTINED state
+	// after failing an integrity check. Quarantined trees can't leave that
+	// state through UpdateTree.
+	LiftQuarantine(context.Context, *LiftQuarantineRequest) (*Tree, error)
+	// Pauses the sequencing of an ACTIVE log by moving it to the PAUSED state,
+	// in which it keeps serving reads and queuing leaves, but no leaves are
+	// integrated. Pausing a PAUSED log has no effect.
+	PauseSequencing(context.Context, *PauseSequencingRequest) (*Tree, error)
+	// Resumes the sequencing of a PAUSED log by moving it back to the ACTIVE
+	// state. Resuming an ACTIVE log has no effect.
+	ResumeSequencing(context.Context, *ResumeSequencingRequest) (*Tree, error)
+	// Re-signs the latest root of a log or map with the current key and
+	// timestamp, at a new revision with the same contents. This refreshes a
+	// root which went stale, e.g. while sequencing was paused, or gets a root
+	// signed by a new key after RotateTreeKey, without adding leaves.
+	ResignLatestRoot(context.Context, *ResignLatestRootRequest) (*ResignLatestRootResponse, error)
+	// Exports the definitions of trees, without their private key material, so
+	// that they can be re-created in another environment with ImportTrees.
+	ExportTrees(context.Context, *ExportTreesRequest) (*ExportTreesResponse, error)
+	// Creates trees from their definitions, usually exported by ExportTrees.
+	// Trees are created in order; if one fails, the ones before it remain.
+	ImportTrees(context.Context, *ImportTreesRequest) (*ImportTreesResponse, error)
 }
 
 // UnimplementedTrillianAdminServer can be embedded to have forward compatible implementations.
@@ -521,6 +1443,9 @@ func (*UnimplementedTrillianAdminServer) GetTree(ctx context.Context, req *GetTr
 func (*UnimplementedTrillianAdminServer) CreateTree(ctx context.Context, req *CreateTreeRequest) (*Tree, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateTree not implemented")
 }
+func (*UnimplementedTrillianAdminServer) CreateTreesFromTemplate(ctx context.Context, req *CreateTreesFromTemplateRequest) (*CreateTreesFromTemplateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateTreesFromTemplate not implemented")
+}
 func (*UnimplementedTrillianAdminServer) UpdateTree(ctx context.Context, req *UpdateTreeRequest) (*Tree, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateTree not implemented")
 }
@@ -530,6 +1455,30 @@ func (*UnimplementedTrillianAdminServer) DeleteTree(ctx context.Context, req *De
 func (*UnimplementedTrillianAdminServer) UndeleteTree(ctx context.Context, req *UndeleteTreeRequest) (*Tree, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UndeleteTree not implemented")
 }
+func (*UnimplementedTrillianAdminServer) PurgeTree(ctx context.Context, req *PurgeTreeRequest) (*Tree, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PurgeTree not implemented")
+}
+func (*UnimplementedTrillianAdminServer) RotateTreeKey(ctx context.Context, req *RotateTreeKeyRequest) (*Tree, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RotateTreeKey not implemented")
+}
+func (*UnimplementedTrillianAdminServer) LiftQuarantine(ctx context.Context, req *LiftQuarantineRequest) (*Tree, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LiftQuarantine not implemented")
+}
+func (*UnimplementedTrillianAdminServer) PauseSequencing(ctx context.Context, req *PauseSequencingRequest) (*Tree, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PauseSequencing not implemented")
+}
+func (*UnimplementedTrillianAdminServer) ResumeSequencing(ctx context.Context, req *ResumeSequencingRequest) (*Tree, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResumeSequencing not implemented")
+}
+func (*UnimplementedTrillianAdminServer) ResignLatestRoot(ctx context.Context, req *ResignLatestRootRequest) (*ResignLatestRootResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResignLatestRoot not implemented")
+}
+func (*UnimplementedTrillianAdminServer) ExportTrees(ctx context.Context, req *ExportTreesRequest) (*ExportTreesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportTrees not implemented")
+}
+func (*UnimplementedTrillianAdminServer) ImportTrees(ctx context.Context, req *ImportTreesRequest) (*ImportTreesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportTrees not implemented")
+}
 
 func RegisterTrillianAdminServer(s *grpc.Server, srv TrillianAdminServer) {
 	s.RegisterService(&_TrillianAdmin_serviceDesc, srv)
@@ -589,6 +1538,24 @@ func _TrillianAdmin_CreateTree_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianAdmin_CreateTreesFromTemplate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTreesFromTemplateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).CreateTreesFromTemplate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/CreateTreesFromTemplate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).CreateTreesFromTemplate(ctx, req.(*CreateTreesFromTemplateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _TrillianAdmin_UpdateTree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UpdateTreeRequest)
 	if err := dec(in); err != nil {
@@ -643,6 +1610,150 @@ func _TrillianAdmin_UndeleteTree_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianAdmin_PurgeTree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PurgeTreeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).PurgeTree(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/PurgeTree",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).PurgeTree(ctx, req.(*PurgeTreeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianAdmin_RotateTreeKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateTreeKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).RotateTreeKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/RotateTreeKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).RotateTreeKey(ctx, req.(*RotateTreeKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianAdmin_LiftQuarantine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LiftQuarantineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).LiftQuarantine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/LiftQuarantine",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).LiftQuarantine(ctx, req.(*LiftQuarantineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianAdmin_PauseSequencing_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PauseSequencingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).PauseSequencing(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/PauseSequencing",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).PauseSequencing(ctx, req.(*PauseSequencingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianAdmin_ResumeSequencing_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResumeSequencingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).ResumeSequencing(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/ResumeSequencing",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).ResumeSequencing(ctx, req.(*ResumeSequencingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianAdmin_ResignLatestRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResignLatestRootRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).ResignLatestRoot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/ResignLatestRoot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).ResignLatestRoot(ctx, req.(*ResignLatestRootRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianAdmin_ExportTrees_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportTreesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).ExportTrees(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/ExportTrees",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).ExportTrees(ctx, req.(*ExportTreesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianAdmin_ImportTrees_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportTreesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianAdminServer).ImportTrees(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianAdmin/ImportTrees",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianAdminServer).ImportTrees(ctx, req.(*ImportTreesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _TrillianAdmin_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "trillian.TrillianAdmin",
 	HandlerType: (*TrillianAdminServer)(nil),
@@ -659,6 +1770,10 @@ var _TrillianAdmin_serviceDesc = grpc.ServiceDesc{
 			MethodName: "CreateTree",
 			Handler:    _TrillianAdmin_CreateTree_Handler,
 		},
+		{
+			MethodName: "CreateTreesFromTemplate",
+			Handler:    _TrillianAdmin_CreateTreesFromTemplate_Handler,
+		},
 		{
 			MethodName: "UpdateTree",
 			Handler:    _TrillianAdmin_UpdateTree_Handler,
@@ -671,6 +1786,38 @@ var _TrillianAdmin_serviceDesc = grpc.ServiceDesc{
 			MethodName: "UndeleteTree",
 			Handler:    _TrillianAdmin_UndeleteTree_Handler,
 		},
+		{
+			MethodName: "PurgeTree",
+			Handler:    _TrillianAdmin_PurgeTree_Handler,
+		},
+		{
+			MethodName: "RotateTreeKey",
+			Handler:    _TrillianAdmin_RotateTreeKey_Handler,
+		},
+		{
+			MethodName: "LiftQuarantine",
+			Handler:    _TrillianAdmin_LiftQuarantine_Handler,
+		},
+		{
+			MethodName: "PauseSequencing",
+			Handler:    _TrillianAdmin_PauseSequencing_Handler,
+		},
+		{
+			MethodName: "ResumeSequencing",
+			Handler:    _TrillianAdmin_ResumeSequencing_Handler,
+		},
+		{
+			MethodName: "ResignLatestRoot",
+			Handler:    _TrillianAdmin_ResignLatestRoot_Handler,
+		},
+		{
+			MethodName: "ExportTrees",
+			Handler:    _TrillianAdmin_ExportTrees_Handler,
+		},
+		{
+			MethodName: "ImportTrees",
+			Handler:    _TrillianAdmin_ImportTrees_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "trillian_admin_api.proto",
@@ -8,6 +8,7 @@ import (
 	fmt "fmt"
 	proto "github.com/golang/protobuf/proto"
 	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	keyspb "github.com/google/trillian/crypto/keyspb"
 	_ "google.golang.org/genproto/googleapis/api/annotations"
 	status "google.golang.org/genproto/googleapis/rpc/status"
 	grpc "google.golang.org/grpc"
@@ -514,6 +515,181 @@ func (m *GetInclusionProofByHashResponse) GetSignedLogRoot() *SignedLogRoot {
 	return nil
 }
 
+type GetInclusionProofByHashBatchRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	// The Merkle tree hashes of the leaf entries to prove the inclusion of.
+	LeafHash             [][]byte  `protobuf:"bytes,2,rep,name=leaf_hash,json=leafHash,proto3" json:"leaf_hash,omitempty"`
+	TreeSize             int64     `protobuf:"varint,3,opt,name=tree_size,json=treeSize,proto3" json:"tree_size,omitempty"`
+	OrderBySequence      bool      `protobuf:"varint,4,opt,name=order_by_sequence,json=orderBySequence,proto3" json:"order_by_sequence,omitempty"`
+	ChargeTo             *ChargeTo `protobuf:"bytes,5,opt,name=charge_to,json=chargeTo,proto3" json:"charge_to,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *GetInclusionProofByHashBatchRequest) Reset()         { *m = GetInclusionProofByHashBatchRequest{} }
+func (m *GetInclusionProofByHashBatchRequest) String() string { return proto.CompactTextString(m) }
+func (*GetInclusionProofByHashBatchRequest) ProtoMessage()    {}
+func (*GetInclusionProofByHashBatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{9}
+}
+
+func (m *GetInclusionProofByHashBatchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetInclusionProofByHashBatchRequest.Unmarshal(m, b)
+}
+func (m *GetInclusionProofByHashBatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetInclusionProofByHashBatchRequest.Marshal(b, m, deterministic)
+}
+func (m *GetInclusionProofByHashBatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetInclusionProofByHashBatchRequest.Merge(m, src)
+}
+func (m *GetInclusionProofByHashBatchRequest) XXX_Size() int {
+	return xxx_messageInfo_GetInclusionProofByHashBatchRequest.Size(m)
+}
+func (m *GetInclusionProofByHashBatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetInclusionProofByHashBatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetInclusionProofByHashBatchRequest proto.InternalMessageInfo
+
+func (m *GetInclusionProofByHashBatchRequest) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
+}
+
+func (m *GetInclusionProofByHashBatchRequest) GetLeafHash() [][]byte {
+	if m != nil {
+		return m.LeafHash
+	}
+	return nil
+}
+
+func (m *GetInclusionProofByHashBatchRequest) GetTreeSize() int64 {
+	if m != nil {
+		return m.TreeSize
+	}
+	return 0
+}
+
+func (m *GetInclusionProofByHashBatchRequest) GetOrderBySequence() bool {
+	if m != nil {
+		return m.OrderBySequence
+	}
+	return false
+}
+
+func (m *GetInclusionProofByHashBatchRequest) GetChargeTo() *ChargeTo {
+	if m != nil {
+		return m.ChargeTo
+	}
+	return nil
+}
+
+type GetInclusionProofByHashBatchResponse struct {
+	// The proofs for each requested leaf hash, in the order of the request.
+	Proofs               []*GetInclusionProofByHashBatchResponse_LeafHashProofs `protobuf:"bytes,1,rep,name=proofs,proto3" json:"proofs,omitempty"`
+	SignedLogRoot        *SignedLogRoot                                         `protobuf:"bytes,2,opt,name=signed_log_root,json=signedLogRoot,proto3" json:"signed_log_root,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                                               `json:"-"`
+	XXX_unrecognized     []byte                                                 `json:"-"`
+	XXX_sizecache        int32                                                  `json:"-"`
+}
+
+func (m *GetInclusionProofByHashBatchResponse) Reset()         { *m = GetInclusionProofByHashBatchResponse{} }
+func (m *GetInclusionProofByHashBatchResponse) String() string { return proto.CompactTextString(m) }
+func (*GetInclusionProofByHashBatchResponse) ProtoMessage()    {}
+func (*GetInclusionProofByHashBatchResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{10}
+}
+
+func (m *GetInclusionProofByHashBatchResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetInclusionProofByHashBatchResponse.Unmarshal(m, b)
+}
+func (m *GetInclusionProofByHashBatchResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetInclusionProofByHashBatchResponse.Marshal(b, m, deterministic)
+}
+func (m *GetInclusionProofByHashBatchResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetInclusionProofByHashBatchResponse.Merge(m, src)
+}
+func (m *GetInclusionProofByHashBatchResponse) XXX_Size() int {
+	return xxx_messageInfo_GetInclusionProofByHashBatchResponse.Size(m)
+}
+func (m *GetInclusionProofByHashBatchResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetInclusionProofByHashBatchResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetInclusionProofByHashBatchResponse proto.InternalMessageInfo
+
+func (m *GetInclusionProofByHashBatchResponse) GetProofs() []*GetInclusionProofByHashBatchResponse_LeafHashProofs {
+	if m != nil {
+		return m.Proofs
+	}
+	return nil
+}
+
+func (m *GetInclusionProofByHashBatchResponse) GetSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.SignedLogRoot
+	}
+	return nil
+}
+
+// LeafHashProofs holds the inclusion proofs of the leaves with one Merkle
+// leaf hash.
+type GetInclusionProofByHashBatchResponse_LeafHashProofs struct {
+	LeafHash []byte `protobuf:"bytes,1,opt,name=leaf_hash,json=leafHash,proto3" json:"leaf_hash,omitempty"`
+	// As in GetInclusionProofByHashResponse, there can be several proofs, or
+	// none if no leaf with the hash is within the requested tree size.
+	Proof                []*Proof `protobuf:"bytes,2,rep,name=proof,proto3" json:"proof,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetInclusionProofByHashBatchResponse_LeafHashProofs) Reset() {
+	*m = GetInclusionProofByHashBatchResponse_LeafHashProofs{}
+}
+func (m *GetInclusionProofByHashBatchResponse_LeafHashProofs) String() string {
+	return proto.CompactTextString(m)
+}
+func (*GetInclusionProofByHashBatchResponse_LeafHashProofs) ProtoMessage() {}
+func (*GetInclusionProofByHashBatchResponse_LeafHashProofs) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{10, 0}
+}
+
+func (m *GetInclusionProofByHashBatchResponse_LeafHashProofs) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetInclusionProofByHashBatchResponse_LeafHashProofs.Unmarshal(m, b)
+}
+func (m *GetInclusionProofByHashBatchResponse_LeafHashProofs) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetInclusionProofByHashBatchResponse_LeafHashProofs.Marshal(b, m, deterministic)
+}
+func (m *GetInclusionProofByHashBatchResponse_LeafHashProofs) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetInclusionProofByHashBatchResponse_LeafHashProofs.Merge(m, src)
+}
+func (m *GetInclusionProofByHashBatchResponse_LeafHashProofs) XXX_Size() int {
+	return xxx_messageInfo_GetInclusionProofByHashBatchResponse_LeafHashProofs.Size(m)
+}
+func (m *GetInclusionProofByHashBatchResponse_LeafHashProofs) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetInclusionProofByHashBatchResponse_LeafHashProofs.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetInclusionProofByHashBatchResponse_LeafHashProofs proto.InternalMessageInfo
+
+func (m *GetInclusionProofByHashBatchResponse_LeafHashProofs) GetLeafHash() []byte {
+	if m != nil {
+		return m.LeafHash
+	}
+	return nil
+}
+
+func (m *GetInclusionProofByHashBatchResponse_LeafHashProofs) GetProof() []*Proof {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
+}
+
 type GetConsistencyProofRequest struct {
 	LogId                int64     `protobuf:"varint,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
 	FirstTreeSize        int64     `protobuf:"varint,2,opt,name=first_tree_size,json=firstTreeSize,proto3" json:"first_tree_size,omitempty"`
@@ -528,7 +704,7 @@ func (m *GetConsistencyProofRequest) Reset()         { *m = GetConsistencyProofR
 func (m *GetConsistencyProofRequest) String() string { return proto.CompactTextString(m) }
 func (*GetConsistencyProofRequest) ProtoMessage()    {}
 func (*GetConsistencyProofRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{9}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{11}
 }
 
 func (m *GetConsistencyProofRequest) XXX_Unmarshal(b []byte) error {
@@ -595,7 +771,7 @@ func (m *GetConsistencyProofResponse) Reset()         { *m = GetConsistencyProof
 func (m *GetConsistencyProofResponse) String() string { return proto.CompactTextString(m) }
 func (*GetConsistencyProofResponse) ProtoMessage()    {}
 func (*GetConsistencyProofResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{10}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{12}
 }
 
 func (m *GetConsistencyProofResponse) XXX_Unmarshal(b []byte) error {
@@ -630,6 +806,127 @@ func (m *GetConsistencyProofResponse) GetSignedLogRoot() *SignedLogRoot {
 	return nil
 }
 
+type GetConsistencyProofByRootHashRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	// The root hashes of the published roots to prove consistency between. The
+	// tree size of the first root must not be greater than that of the second.
+	FirstRootHash        []byte    `protobuf:"bytes,2,opt,name=first_root_hash,json=firstRootHash,proto3" json:"first_root_hash,omitempty"`
+	SecondRootHash       []byte    `protobuf:"bytes,3,opt,name=second_root_hash,json=secondRootHash,proto3" json:"second_root_hash,omitempty"`
+	ChargeTo             *ChargeTo `protobuf:"bytes,4,opt,name=charge_to,json=chargeTo,proto3" json:"charge_to,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *GetConsistencyProofByRootHashRequest) Reset()         { *m = GetConsistencyProofByRootHashRequest{} }
+func (m *GetConsistencyProofByRootHashRequest) String() string { return proto.CompactTextString(m) }
+func (*GetConsistencyProofByRootHashRequest) ProtoMessage()    {}
+func (*GetConsistencyProofByRootHashRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{13}
+}
+
+func (m *GetConsistencyProofByRootHashRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetConsistencyProofByRootHashRequest.Unmarshal(m, b)
+}
+func (m *GetConsistencyProofByRootHashRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetConsistencyProofByRootHashRequest.Marshal(b, m, deterministic)
+}
+func (m *GetConsistencyProofByRootHashRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetConsistencyProofByRootHashRequest.Merge(m, src)
+}
+func (m *GetConsistencyProofByRootHashRequest) XXX_Size() int {
+	return xxx_messageInfo_GetConsistencyProofByRootHashRequest.Size(m)
+}
+func (m *GetConsistencyProofByRootHashRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetConsistencyProofByRootHashRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetConsistencyProofByRootHashRequest proto.InternalMessageInfo
+
+func (m *GetConsistencyProofByRootHashRequest) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
+}
+
+func (m *GetConsistencyProofByRootHashRequest) GetFirstRootHash() []byte {
+	if m != nil {
+		return m.FirstRootHash
+	}
+	return nil
+}
+
+func (m *GetConsistencyProofByRootHashRequest) GetSecondRootHash() []byte {
+	if m != nil {
+		return m.SecondRootHash
+	}
+	return nil
+}
+
+func (m *GetConsistencyProofByRootHashRequest) GetChargeTo() *ChargeTo {
+	if m != nil {
+		return m.ChargeTo
+	}
+	return nil
+}
+
+type GetConsistencyProofByRootHashResponse struct {
+	Proof *Proof `protobuf:"bytes,1,opt,name=proof,proto3" json:"proof,omitempty"`
+	// The earliest published roots with the requested root hashes.
+	FirstSignedLogRoot   *SignedLogRoot `protobuf:"bytes,2,opt,name=first_signed_log_root,json=firstSignedLogRoot,proto3" json:"first_signed_log_root,omitempty"`
+	SecondSignedLogRoot  *SignedLogRoot `protobuf:"bytes,3,opt,name=second_signed_log_root,json=secondSignedLogRoot,proto3" json:"second_signed_log_root,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *GetConsistencyProofByRootHashResponse) Reset()         { *m = GetConsistencyProofByRootHashResponse{} }
+func (m *GetConsistencyProofByRootHashResponse) String() string { return proto.CompactTextString(m) }
+func (*GetConsistencyProofByRootHashResponse) ProtoMessage()    {}
+func (*GetConsistencyProofByRootHashResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{14}
+}
+
+func (m *GetConsistencyProofByRootHashResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetConsistencyProofByRootHashResponse.Unmarshal(m, b)
+}
+func (m *GetConsistencyProofByRootHashResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetConsistencyProofByRootHashResponse.Marshal(b, m, deterministic)
+}
+func (m *GetConsistencyProofByRootHashResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetConsistencyProofByRootHashResponse.Merge(m, src)
+}
+func (m *GetConsistencyProofByRootHashResponse) XXX_Size() int {
+	return xxx_messageInfo_GetConsistencyProofByRootHashResponse.Size(m)
+}
+func (m *GetConsistencyProofByRootHashResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetConsistencyProofByRootHashResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetConsistencyProofByRootHashResponse proto.InternalMessageInfo
+
+func (m *GetConsistencyProofByRootHashResponse) GetProof() *Proof {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
+}
+
+func (m *GetConsistencyProofByRootHashResponse) GetFirstSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.FirstSignedLogRoot
+	}
+	return nil
+}
+
+func (m *GetConsistencyProofByRootHashResponse) GetSecondSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.SecondSignedLogRoot
+	}
+	return nil
+}
+
 type GetLatestSignedLogRootRequest struct {
 	LogId    int64     `protobuf:"varint,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
 	ChargeTo *ChargeTo `protobuf:"bytes,2,opt,name=charge_to,json=chargeTo,proto3" json:"charge_to,omitempty"`
@@ -645,7 +942,7 @@ func (m *GetLatestSignedLogRootRequest) Reset()         { *m = GetLatestSignedLo
 func (m *GetLatestSignedLogRootRequest) String() string { return proto.CompactTextString(m) }
 func (*GetLatestSignedLogRootRequest) ProtoMessage()    {}
 func (*GetLatestSignedLogRootRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{11}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{15}
 }
 
 func (m *GetLatestSignedLogRootRequest) XXX_Unmarshal(b []byte) error {
@@ -677,62 +974,411 @@ func (m *GetLatestSignedLogRootRequest) GetChargeTo() *ChargeTo {
 	if m != nil {
 		return m.ChargeTo
 	}
-	return nil
+	return nil
+}
+
+func (m *GetLatestSignedLogRootRequest) GetFirstTreeSize() int64 {
+	if m != nil {
+		return m.FirstTreeSize
+	}
+	return 0
+}
+
+type GetLatestSignedLogRootResponse struct {
+	SignedLogRoot *SignedLogRoot `protobuf:"bytes,2,opt,name=signed_log_root,json=signedLogRoot,proto3" json:"signed_log_root,omitempty"`
+	// proof is filled in with a consistency proof if first_tree_size in
+	// GetLatestSignedLogRootRequest is non-zero (and within the tree size
+	// available at the server).
+	Proof                *Proof   `protobuf:"bytes,3,opt,name=proof,proto3" json:"proof,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetLatestSignedLogRootResponse) Reset()         { *m = GetLatestSignedLogRootResponse{} }
+func (m *GetLatestSignedLogRootResponse) String() string { return proto.CompactTextString(m) }
+func (*GetLatestSignedLogRootResponse) ProtoMessage()    {}
+func (*GetLatestSignedLogRootResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{16}
+}
+
+func (m *GetLatestSignedLogRootResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetLatestSignedLogRootResponse.Unmarshal(m, b)
+}
+func (m *GetLatestSignedLogRootResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetLatestSignedLogRootResponse.Marshal(b, m, deterministic)
+}
+func (m *GetLatestSignedLogRootResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetLatestSignedLogRootResponse.Merge(m, src)
+}
+func (m *GetLatestSignedLogRootResponse) XXX_Size() int {
+	return xxx_messageInfo_GetLatestSignedLogRootResponse.Size(m)
+}
+func (m *GetLatestSignedLogRootResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetLatestSignedLogRootResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetLatestSignedLogRootResponse proto.InternalMessageInfo
+
+func (m *GetLatestSignedLogRootResponse) GetSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.SignedLogRoot
+	}
+	return nil
+}
+
+func (m *GetLatestSignedLogRootResponse) GetProof() *Proof {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
+}
+
+// LogRootSignature is a signature by a third-party witness over a signed log
+// root, endorsing it.
+type LogRootSignature struct {
+	// The public key of the witness, which the signature verifies with.
+	PublicKey *keyspb.PublicKey `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	// The signature over the log_root bytes of the SignedLogRoot, made as by
+	// the Trillian signers, i.e. over their SHA-256 hash.
+	Signature            []byte   `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LogRootSignature) Reset()         { *m = LogRootSignature{} }
+func (m *LogRootSignature) String() string { return proto.CompactTextString(m) }
+func (*LogRootSignature) ProtoMessage()    {}
+func (*LogRootSignature) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{17}
+}
+
+func (m *LogRootSignature) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LogRootSignature.Unmarshal(m, b)
+}
+func (m *LogRootSignature) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LogRootSignature.Marshal(b, m, deterministic)
+}
+func (m *LogRootSignature) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LogRootSignature.Merge(m, src)
+}
+func (m *LogRootSignature) XXX_Size() int {
+	return xxx_messageInfo_LogRootSignature.Size(m)
+}
+func (m *LogRootSignature) XXX_DiscardUnknown() {
+	xxx_messageInfo_LogRootSignature.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LogRootSignature proto.InternalMessageInfo
+
+func (m *LogRootSignature) GetPublicKey() *keyspb.PublicKey {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *LogRootSignature) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type AddLogRootSignatureRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	// The revision of the root which is signed, as in its LogRootV1.
+	Revision             int64             `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	Signature            *LogRootSignature `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+	ChargeTo             *ChargeTo         `protobuf:"bytes,4,opt,name=charge_to,json=chargeTo,proto3" json:"charge_to,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *AddLogRootSignatureRequest) Reset()         { *m = AddLogRootSignatureRequest{} }
+func (m *AddLogRootSignatureRequest) String() string { return proto.CompactTextString(m) }
+func (*AddLogRootSignatureRequest) ProtoMessage()    {}
+func (*AddLogRootSignatureRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{18}
+}
+
+func (m *AddLogRootSignatureRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddLogRootSignatureRequest.Unmarshal(m, b)
+}
+func (m *AddLogRootSignatureRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddLogRootSignatureRequest.Marshal(b, m, deterministic)
+}
+func (m *AddLogRootSignatureRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddLogRootSignatureRequest.Merge(m, src)
+}
+func (m *AddLogRootSignatureRequest) XXX_Size() int {
+	return xxx_messageInfo_AddLogRootSignatureRequest.Size(m)
+}
+func (m *AddLogRootSignatureRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddLogRootSignatureRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddLogRootSignatureRequest proto.InternalMessageInfo
+
+func (m *AddLogRootSignatureRequest) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
+}
+
+func (m *AddLogRootSignatureRequest) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+func (m *AddLogRootSignatureRequest) GetSignature() *LogRootSignature {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+func (m *AddLogRootSignatureRequest) GetChargeTo() *ChargeTo {
+	if m != nil {
+		return m.ChargeTo
+	}
+	return nil
+}
+
+type AddLogRootSignatureResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddLogRootSignatureResponse) Reset()         { *m = AddLogRootSignatureResponse{} }
+func (m *AddLogRootSignatureResponse) String() string { return proto.CompactTextString(m) }
+func (*AddLogRootSignatureResponse) ProtoMessage()    {}
+func (*AddLogRootSignatureResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{19}
+}
+
+func (m *AddLogRootSignatureResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddLogRootSignatureResponse.Unmarshal(m, b)
+}
+func (m *AddLogRootSignatureResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddLogRootSignatureResponse.Marshal(b, m, deterministic)
+}
+func (m *AddLogRootSignatureResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddLogRootSignatureResponse.Merge(m, src)
+}
+func (m *AddLogRootSignatureResponse) XXX_Size() int {
+	return xxx_messageInfo_AddLogRootSignatureResponse.Size(m)
+}
+func (m *AddLogRootSignatureResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddLogRootSignatureResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddLogRootSignatureResponse proto.InternalMessageInfo
+
+type GetLogRootSignaturesRequest struct {
+	LogId                int64     `protobuf:"varint,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	Revision             int64     `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	ChargeTo             *ChargeTo `protobuf:"bytes,3,opt,name=charge_to,json=chargeTo,proto3" json:"charge_to,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *GetLogRootSignaturesRequest) Reset()         { *m = GetLogRootSignaturesRequest{} }
+func (m *GetLogRootSignaturesRequest) String() string { return proto.CompactTextString(m) }
+func (*GetLogRootSignaturesRequest) ProtoMessage()    {}
+func (*GetLogRootSignaturesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{20}
+}
+
+func (m *GetLogRootSignaturesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetLogRootSignaturesRequest.Unmarshal(m, b)
+}
+func (m *GetLogRootSignaturesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetLogRootSignaturesRequest.Marshal(b, m, deterministic)
+}
+func (m *GetLogRootSignaturesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetLogRootSignaturesRequest.Merge(m, src)
+}
+func (m *GetLogRootSignaturesRequest) XXX_Size() int {
+	return xxx_messageInfo_GetLogRootSignaturesRequest.Size(m)
+}
+func (m *GetLogRootSignaturesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetLogRootSignaturesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetLogRootSignaturesRequest proto.InternalMessageInfo
+
+func (m *GetLogRootSignaturesRequest) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
+}
+
+func (m *GetLogRootSignaturesRequest) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+func (m *GetLogRootSignaturesRequest) GetChargeTo() *ChargeTo {
+	if m != nil {
+		return m.ChargeTo
+	}
+	return nil
+}
+
+type GetLogRootSignaturesResponse struct {
+	// The root of the log at the requested revision.
+	SignedLogRoot *SignedLogRoot `protobuf:"bytes,1,opt,name=signed_log_root,json=signedLogRoot,proto3" json:"signed_log_root,omitempty"`
+	// The witness signatures over signed_log_root, at most one per public key.
+	Signatures           []*LogRootSignature `protobuf:"bytes,2,rep,name=signatures,proto3" json:"signatures,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *GetLogRootSignaturesResponse) Reset()         { *m = GetLogRootSignaturesResponse{} }
+func (m *GetLogRootSignaturesResponse) String() string { return proto.CompactTextString(m) }
+func (*GetLogRootSignaturesResponse) ProtoMessage()    {}
+func (*GetLogRootSignaturesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{21}
+}
+
+func (m *GetLogRootSignaturesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetLogRootSignaturesResponse.Unmarshal(m, b)
+}
+func (m *GetLogRootSignaturesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetLogRootSignaturesResponse.Marshal(b, m, deterministic)
+}
+func (m *GetLogRootSignaturesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetLogRootSignaturesResponse.Merge(m, src)
+}
+func (m *GetLogRootSignaturesResponse) XXX_Size() int {
+	return xxx_messageInfo_GetLogRootSignaturesResponse.Size(m)
+}
+func (m *GetLogRootSignaturesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetLogRootSignaturesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetLogRootSignaturesResponse proto.InternalMessageInfo
+
+func (m *GetLogRootSignaturesResponse) GetSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.SignedLogRoot
+	}
+	return nil
+}
+
+func (m *GetLogRootSignaturesResponse) GetSignatures() []*LogRootSignature {
+	if m != nil {
+		return m.Signatures
+	}
+	return nil
+}
+
+type GetCheckpointRequest struct {
+	LogId                int64     `protobuf:"varint,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	ChargeTo             *ChargeTo `protobuf:"bytes,2,opt,name=charge_to,json=chargeTo,proto3" json:"charge_to,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *GetCheckpointRequest) Reset()         { *m = GetCheckpointRequest{} }
+func (m *GetCheckpointRequest) String() string { return proto.CompactTextString(m) }
+func (*GetCheckpointRequest) ProtoMessage()    {}
+func (*GetCheckpointRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{22}
+}
+
+func (m *GetCheckpointRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetCheckpointRequest.Unmarshal(m, b)
+}
+func (m *GetCheckpointRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetCheckpointRequest.Marshal(b, m, deterministic)
+}
+func (m *GetCheckpointRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetCheckpointRequest.Merge(m, src)
+}
+func (m *GetCheckpointRequest) XXX_Size() int {
+	return xxx_messageInfo_GetCheckpointRequest.Size(m)
+}
+func (m *GetCheckpointRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetCheckpointRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetCheckpointRequest proto.InternalMessageInfo
+
+func (m *GetCheckpointRequest) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
 }
 
-func (m *GetLatestSignedLogRootRequest) GetFirstTreeSize() int64 {
+func (m *GetCheckpointRequest) GetChargeTo() *ChargeTo {
 	if m != nil {
-		return m.FirstTreeSize
+		return m.ChargeTo
 	}
-	return 0
+	return nil
 }
 
-type GetLatestSignedLogRootResponse struct {
-	SignedLogRoot *SignedLogRoot `protobuf:"bytes,2,opt,name=signed_log_root,json=signedLogRoot,proto3" json:"signed_log_root,omitempty"`
-	// proof is filled in with a consistency proof if first_tree_size in
-	// GetLatestSignedLogRootRequest is non-zero (and within the tree size
-	// available at the server).
-	Proof                *Proof   `protobuf:"bytes,3,opt,name=proof,proto3" json:"proof,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+type GetCheckpointResponse struct {
+	// The signed note of the checkpoint. Its origin line is the server's
+	// checkpoint origin prefix, followed by "/" and the log ID, and it's signed
+	// under the same name.
+	Checkpoint []byte `protobuf:"bytes,1,opt,name=checkpoint,proto3" json:"checkpoint,omitempty"`
+	// The root which the checkpoint represents.
+	SignedLogRoot        *SignedLogRoot `protobuf:"bytes,2,opt,name=signed_log_root,json=signedLogRoot,proto3" json:"signed_log_root,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
 }
 
-func (m *GetLatestSignedLogRootResponse) Reset()         { *m = GetLatestSignedLogRootResponse{} }
-func (m *GetLatestSignedLogRootResponse) String() string { return proto.CompactTextString(m) }
-func (*GetLatestSignedLogRootResponse) ProtoMessage()    {}
-func (*GetLatestSignedLogRootResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{12}
+func (m *GetCheckpointResponse) Reset()         { *m = GetCheckpointResponse{} }
+func (m *GetCheckpointResponse) String() string { return proto.CompactTextString(m) }
+func (*GetCheckpointResponse) ProtoMessage()    {}
+func (*GetCheckpointResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{23}
 }
 
-func (m *GetLatestSignedLogRootResponse) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_GetLatestSignedLogRootResponse.Unmarshal(m, b)
+func (m *GetCheckpointResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetCheckpointResponse.Unmarshal(m, b)
 }
-func (m *GetLatestSignedLogRootResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_GetLatestSignedLogRootResponse.Marshal(b, m, deterministic)
+func (m *GetCheckpointResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetCheckpointResponse.Marshal(b, m, deterministic)
 }
-func (m *GetLatestSignedLogRootResponse) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_GetLatestSignedLogRootResponse.Merge(m, src)
+func (m *GetCheckpointResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetCheckpointResponse.Merge(m, src)
 }
-func (m *GetLatestSignedLogRootResponse) XXX_Size() int {
-	return xxx_messageInfo_GetLatestSignedLogRootResponse.Size(m)
+func (m *GetCheckpointResponse) XXX_Size() int {
+	return xxx_messageInfo_GetCheckpointResponse.Size(m)
 }
-func (m *GetLatestSignedLogRootResponse) XXX_DiscardUnknown() {
-	xxx_messageInfo_GetLatestSignedLogRootResponse.DiscardUnknown(m)
+func (m *GetCheckpointResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetCheckpointResponse.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_GetLatestSignedLogRootResponse proto.InternalMessageInfo
+var xxx_messageInfo_GetCheckpointResponse proto.InternalMessageInfo
 
-func (m *GetLatestSignedLogRootResponse) GetSignedLogRoot() *SignedLogRoot {
+func (m *GetCheckpointResponse) GetCheckpoint() []byte {
 	if m != nil {
-		return m.SignedLogRoot
+		return m.Checkpoint
 	}
 	return nil
 }
 
-func (m *GetLatestSignedLogRootResponse) GetProof() *Proof {
+func (m *GetCheckpointResponse) GetSignedLogRoot() *SignedLogRoot {
 	if m != nil {
-		return m.Proof
+		return m.SignedLogRoot
 	}
 	return nil
 }
@@ -753,7 +1399,7 @@ func (m *GetSequencedLeafCountRequest) Reset()         { *m = GetSequencedLeafCo
 func (m *GetSequencedLeafCountRequest) String() string { return proto.CompactTextString(m) }
 func (*GetSequencedLeafCountRequest) ProtoMessage()    {}
 func (*GetSequencedLeafCountRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{13}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{24}
 }
 
 func (m *GetSequencedLeafCountRequest) XXX_Unmarshal(b []byte) error {
@@ -799,7 +1445,7 @@ func (m *GetSequencedLeafCountResponse) Reset()         { *m = GetSequencedLeafC
 func (m *GetSequencedLeafCountResponse) String() string { return proto.CompactTextString(m) }
 func (*GetSequencedLeafCountResponse) ProtoMessage()    {}
 func (*GetSequencedLeafCountResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{14}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{25}
 }
 
 func (m *GetSequencedLeafCountResponse) XXX_Unmarshal(b []byte) error {
@@ -841,7 +1487,7 @@ func (m *GetEntryAndProofRequest) Reset()         { *m = GetEntryAndProofRequest
 func (m *GetEntryAndProofRequest) String() string { return proto.CompactTextString(m) }
 func (*GetEntryAndProofRequest) ProtoMessage()    {}
 func (*GetEntryAndProofRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{15}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{26}
 }
 
 func (m *GetEntryAndProofRequest) XXX_Unmarshal(b []byte) error {
@@ -903,7 +1549,7 @@ func (m *GetEntryAndProofResponse) Reset()         { *m = GetEntryAndProofRespon
 func (m *GetEntryAndProofResponse) String() string { return proto.CompactTextString(m) }
 func (*GetEntryAndProofResponse) ProtoMessage()    {}
 func (*GetEntryAndProofResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{16}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{27}
 }
 
 func (m *GetEntryAndProofResponse) XXX_Unmarshal(b []byte) error {
@@ -957,7 +1603,7 @@ func (m *InitLogRequest) Reset()         { *m = InitLogRequest{} }
 func (m *InitLogRequest) String() string { return proto.CompactTextString(m) }
 func (*InitLogRequest) ProtoMessage()    {}
 func (*InitLogRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{17}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{28}
 }
 
 func (m *InitLogRequest) XXX_Unmarshal(b []byte) error {
@@ -1003,7 +1649,7 @@ func (m *InitLogResponse) Reset()         { *m = InitLogResponse{} }
 func (m *InitLogResponse) String() string { return proto.CompactTextString(m) }
 func (*InitLogResponse) ProtoMessage()    {}
 func (*InitLogResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{18}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{29}
 }
 
 func (m *InitLogResponse) XXX_Unmarshal(b []byte) error {
@@ -1044,7 +1690,7 @@ func (m *QueueLeavesRequest) Reset()         { *m = QueueLeavesRequest{} }
 func (m *QueueLeavesRequest) String() string { return proto.CompactTextString(m) }
 func (*QueueLeavesRequest) ProtoMessage()    {}
 func (*QueueLeavesRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{19}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{30}
 }
 
 func (m *QueueLeavesRequest) XXX_Unmarshal(b []byte) error {
@@ -1088,17 +1734,23 @@ func (m *QueueLeavesRequest) GetChargeTo() *ChargeTo {
 
 type QueueLeavesResponse struct {
 	// Same number and order as in the corresponding request.
-	QueuedLeaves         []*QueuedLogLeaf `protobuf:"bytes,2,rep,name=queued_leaves,json=queuedLeaves,proto3" json:"queued_leaves,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
-	XXX_unrecognized     []byte           `json:"-"`
-	XXX_sizecache        int32            `json:"-"`
+	QueuedLeaves []*QueuedLogLeaf `protobuf:"bytes,2,rep,name=queued_leaves,json=queuedLeaves,proto3" json:"queued_leaves,omitempty"`
+	// Set if the log is running out of the quota for queuing leaves, e.g.
+	// because of a backlog of leaves waiting to be sequenced. Its code is
+	// `google.rpc.OK`, and its details include a `google.rpc.RetryInfo` with how
+	// long submitters should wait before queuing more leaves, so that they can
+	// back off before their requests fail with `RESOURCE_EXHAUSTED`.
+	Backpressure         *status.Status `protobuf:"bytes,3,opt,name=backpressure,proto3" json:"backpressure,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
 }
 
 func (m *QueueLeavesResponse) Reset()         { *m = QueueLeavesResponse{} }
 func (m *QueueLeavesResponse) String() string { return proto.CompactTextString(m) }
 func (*QueueLeavesResponse) ProtoMessage()    {}
 func (*QueueLeavesResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{20}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{31}
 }
 
 func (m *QueueLeavesResponse) XXX_Unmarshal(b []byte) error {
@@ -1126,6 +1778,13 @@ func (m *QueueLeavesResponse) GetQueuedLeaves() []*QueuedLogLeaf {
 	return nil
 }
 
+func (m *QueueLeavesResponse) GetBackpressure() *status.Status {
+	if m != nil {
+		return m.Backpressure
+	}
+	return nil
+}
+
 type AddSequencedLeavesRequest struct {
 	LogId                int64      `protobuf:"varint,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
 	Leaves               []*LogLeaf `protobuf:"bytes,2,rep,name=leaves,proto3" json:"leaves,omitempty"`
@@ -1139,7 +1798,7 @@ func (m *AddSequencedLeavesRequest) Reset()         { *m = AddSequencedLeavesReq
 func (m *AddSequencedLeavesRequest) String() string { return proto.CompactTextString(m) }
 func (*AddSequencedLeavesRequest) ProtoMessage()    {}
 func (*AddSequencedLeavesRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{21}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{32}
 }
 
 func (m *AddSequencedLeavesRequest) XXX_Unmarshal(b []byte) error {
@@ -1193,7 +1852,7 @@ func (m *AddSequencedLeavesResponse) Reset()         { *m = AddSequencedLeavesRe
 func (m *AddSequencedLeavesResponse) String() string { return proto.CompactTextString(m) }
 func (*AddSequencedLeavesResponse) ProtoMessage()    {}
 func (*AddSequencedLeavesResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{22}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{33}
 }
 
 func (m *AddSequencedLeavesResponse) XXX_Unmarshal(b []byte) error {
@@ -1221,6 +1880,127 @@ func (m *AddSequencedLeavesResponse) GetResults() []*QueuedLogLeaf {
 	return nil
 }
 
+type AddSequencedLeavesStreamRequest struct {
+	// The log to import into. Must be the same in every request of the stream.
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	// The next chunk of leaves. Their indices must continue from the last leaf
+	// of the previous request of the stream.
+	Leaves []*LogLeaf `protobuf:"bytes,2,rep,name=leaves,proto3" json:"leaves,omitempty"`
+	// If set in the first request of the stream, the response is only sent
+	// once the log has a root which includes all the leaves of the stream. The
+	// client's deadline bounds the wait.
+	WaitForIntegration   bool     `protobuf:"varint,3,opt,name=wait_for_integration,json=waitForIntegration,proto3" json:"wait_for_integration,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddSequencedLeavesStreamRequest) Reset()         { *m = AddSequencedLeavesStreamRequest{} }
+func (m *AddSequencedLeavesStreamRequest) String() string { return proto.CompactTextString(m) }
+func (*AddSequencedLeavesStreamRequest) ProtoMessage()    {}
+func (*AddSequencedLeavesStreamRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{34}
+}
+
+func (m *AddSequencedLeavesStreamRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddSequencedLeavesStreamRequest.Unmarshal(m, b)
+}
+func (m *AddSequencedLeavesStreamRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddSequencedLeavesStreamRequest.Marshal(b, m, deterministic)
+}
+func (m *AddSequencedLeavesStreamRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddSequencedLeavesStreamRequest.Merge(m, src)
+}
+func (m *AddSequencedLeavesStreamRequest) XXX_Size() int {
+	return xxx_messageInfo_AddSequencedLeavesStreamRequest.Size(m)
+}
+func (m *AddSequencedLeavesStreamRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddSequencedLeavesStreamRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddSequencedLeavesStreamRequest proto.InternalMessageInfo
+
+func (m *AddSequencedLeavesStreamRequest) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
+}
+
+func (m *AddSequencedLeavesStreamRequest) GetLeaves() []*LogLeaf {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+func (m *AddSequencedLeavesStreamRequest) GetWaitForIntegration() bool {
+	if m != nil {
+		return m.WaitForIntegration
+	}
+	return false
+}
+
+type AddSequencedLeavesStreamResponse struct {
+	// The number of leaves received in the stream.
+	LeafCount int64 `protobuf:"varint,1,opt,name=leaf_count,json=leafCount,proto3" json:"leaf_count,omitempty"`
+	// The number of those leaves which were not added because a leaf already
+	// exists at their index, e.g. when an interrupted import is retried.
+	DuplicateCount int64 `protobuf:"varint,2,opt,name=duplicate_count,json=duplicateCount,proto3" json:"duplicate_count,omitempty"`
+	// The root which includes all the leaves of the stream, if the first
+	// request set wait_for_integration.
+	SignedLogRoot        *SignedLogRoot `protobuf:"bytes,3,opt,name=signed_log_root,json=signedLogRoot,proto3" json:"signed_log_root,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *AddSequencedLeavesStreamResponse) Reset()         { *m = AddSequencedLeavesStreamResponse{} }
+func (m *AddSequencedLeavesStreamResponse) String() string { return proto.CompactTextString(m) }
+func (*AddSequencedLeavesStreamResponse) ProtoMessage()    {}
+func (*AddSequencedLeavesStreamResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{35}
+}
+
+func (m *AddSequencedLeavesStreamResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddSequencedLeavesStreamResponse.Unmarshal(m, b)
+}
+func (m *AddSequencedLeavesStreamResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddSequencedLeavesStreamResponse.Marshal(b, m, deterministic)
+}
+func (m *AddSequencedLeavesStreamResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddSequencedLeavesStreamResponse.Merge(m, src)
+}
+func (m *AddSequencedLeavesStreamResponse) XXX_Size() int {
+	return xxx_messageInfo_AddSequencedLeavesStreamResponse.Size(m)
+}
+func (m *AddSequencedLeavesStreamResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddSequencedLeavesStreamResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddSequencedLeavesStreamResponse proto.InternalMessageInfo
+
+func (m *AddSequencedLeavesStreamResponse) GetLeafCount() int64 {
+	if m != nil {
+		return m.LeafCount
+	}
+	return 0
+}
+
+func (m *AddSequencedLeavesStreamResponse) GetDuplicateCount() int64 {
+	if m != nil {
+		return m.DuplicateCount
+	}
+	return 0
+}
+
+func (m *AddSequencedLeavesStreamResponse) GetSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.SignedLogRoot
+	}
+	return nil
+}
+
 type GetLeavesByIndexRequest struct {
 	LogId                int64     `protobuf:"varint,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
 	LeafIndex            []int64   `protobuf:"varint,2,rep,packed,name=leaf_index,json=leafIndex,proto3" json:"leaf_index,omitempty"`
@@ -1234,7 +2014,7 @@ func (m *GetLeavesByIndexRequest) Reset()         { *m = GetLeavesByIndexRequest
 func (m *GetLeavesByIndexRequest) String() string { return proto.CompactTextString(m) }
 func (*GetLeavesByIndexRequest) ProtoMessage()    {}
 func (*GetLeavesByIndexRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{23}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{36}
 }
 
 func (m *GetLeavesByIndexRequest) XXX_Unmarshal(b []byte) error {
@@ -1289,7 +2069,7 @@ func (m *GetLeavesByIndexResponse) Reset()         { *m = GetLeavesByIndexRespon
 func (m *GetLeavesByIndexResponse) String() string { return proto.CompactTextString(m) }
 func (*GetLeavesByIndexResponse) ProtoMessage()    {}
 func (*GetLeavesByIndexResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{24}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{37}
 }
 
 func (m *GetLeavesByIndexResponse) XXX_Unmarshal(b []byte) error {
@@ -1321,123 +2101,285 @@ func (m *GetLeavesByIndexResponse) GetSignedLogRoot() *SignedLogRoot {
 	if m != nil {
 		return m.SignedLogRoot
 	}
-	return nil
+	return nil
+}
+
+type GetLeavesByRangeRequest struct {
+	LogId      int64     `protobuf:"varint,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	StartIndex int64     `protobuf:"varint,2,opt,name=start_index,json=startIndex,proto3" json:"start_index,omitempty"`
+	Count      int64     `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	ChargeTo   *ChargeTo `protobuf:"bytes,4,opt,name=charge_to,json=chargeTo,proto3" json:"charge_to,omitempty"`
+	// If positive, the range is read from the tree at this size: leaves at or
+	// beyond it are not returned, even if the log has grown since. Clients
+	// paging through a range pin every page to the same size, so that
+	// concurrent sequencing cannot change the leaves they see. If the latest
+	// root of the log is smaller, no leaves are returned.
+	TreeSize             int64    `protobuf:"varint,5,opt,name=tree_size,json=treeSize,proto3" json:"tree_size,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetLeavesByRangeRequest) Reset()         { *m = GetLeavesByRangeRequest{} }
+func (m *GetLeavesByRangeRequest) String() string { return proto.CompactTextString(m) }
+func (*GetLeavesByRangeRequest) ProtoMessage()    {}
+func (*GetLeavesByRangeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{38}
+}
+
+func (m *GetLeavesByRangeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetLeavesByRangeRequest.Unmarshal(m, b)
+}
+func (m *GetLeavesByRangeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetLeavesByRangeRequest.Marshal(b, m, deterministic)
+}
+func (m *GetLeavesByRangeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetLeavesByRangeRequest.Merge(m, src)
+}
+func (m *GetLeavesByRangeRequest) XXX_Size() int {
+	return xxx_messageInfo_GetLeavesByRangeRequest.Size(m)
+}
+func (m *GetLeavesByRangeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetLeavesByRangeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetLeavesByRangeRequest proto.InternalMessageInfo
+
+func (m *GetLeavesByRangeRequest) GetLogId() int64 {
+	if m != nil {
+		return m.LogId
+	}
+	return 0
+}
+
+func (m *GetLeavesByRangeRequest) GetStartIndex() int64 {
+	if m != nil {
+		return m.StartIndex
+	}
+	return 0
+}
+
+func (m *GetLeavesByRangeRequest) GetCount() int64 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+func (m *GetLeavesByRangeRequest) GetChargeTo() *ChargeTo {
+	if m != nil {
+		return m.ChargeTo
+	}
+	return nil
+}
+
+func (m *GetLeavesByRangeRequest) GetTreeSize() int64 {
+	if m != nil {
+		return m.TreeSize
+	}
+	return 0
+}
+
+type GetLeavesByRangeResponse struct {
+	// Returned log leaves starting from the `start_index` of the request, in
+	// order. There may be fewer than `request.count` leaves returned, if the
+	// requested range extended beyond the size of the tree or if the server opted
+	// to return fewer leaves than requested.
+	Leaves        []*LogLeaf     `protobuf:"bytes,1,rep,name=leaves,proto3" json:"leaves,omitempty"`
+	SignedLogRoot *SignedLogRoot `protobuf:"bytes,2,opt,name=signed_log_root,json=signedLogRoot,proto3" json:"signed_log_root,omitempty"`
+	// The tree size the leaves were read at: the `tree_size` of the request if
+	// set, otherwise the size of `signed_log_root`. No leaf at or beyond it is
+	// returned. Zero if the request's `tree_size` is beyond the latest root.
+	TreeSize             int64    `protobuf:"varint,3,opt,name=tree_size,json=treeSize,proto3" json:"tree_size,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetLeavesByRangeResponse) Reset()         { *m = GetLeavesByRangeResponse{} }
+func (m *GetLeavesByRangeResponse) String() string { return proto.CompactTextString(m) }
+func (*GetLeavesByRangeResponse) ProtoMessage()    {}
+func (*GetLeavesByRangeResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{39}
+}
+
+func (m *GetLeavesByRangeResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetLeavesByRangeResponse.Unmarshal(m, b)
+}
+func (m *GetLeavesByRangeResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetLeavesByRangeResponse.Marshal(b, m, deterministic)
+}
+func (m *GetLeavesByRangeResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetLeavesByRangeResponse.Merge(m, src)
+}
+func (m *GetLeavesByRangeResponse) XXX_Size() int {
+	return xxx_messageInfo_GetLeavesByRangeResponse.Size(m)
+}
+func (m *GetLeavesByRangeResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetLeavesByRangeResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetLeavesByRangeResponse proto.InternalMessageInfo
+
+func (m *GetLeavesByRangeResponse) GetLeaves() []*LogLeaf {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+func (m *GetLeavesByRangeResponse) GetSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.SignedLogRoot
+	}
+	return nil
+}
+
+func (m *GetLeavesByRangeResponse) GetTreeSize() int64 {
+	if m != nil {
+		return m.TreeSize
+	}
+	return 0
 }
 
-type GetLeavesByRangeRequest struct {
-	LogId                int64     `protobuf:"varint,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
-	StartIndex           int64     `protobuf:"varint,2,opt,name=start_index,json=startIndex,proto3" json:"start_index,omitempty"`
-	Count                int64     `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
-	ChargeTo             *ChargeTo `protobuf:"bytes,4,opt,name=charge_to,json=chargeTo,proto3" json:"charge_to,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
-	XXX_unrecognized     []byte    `json:"-"`
-	XXX_sizecache        int32     `json:"-"`
+type GetLeavesByRangeStreamRequest struct {
+	LogId      int64 `protobuf:"varint,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	StartIndex int64 `protobuf:"varint,2,opt,name=start_index,json=startIndex,proto3" json:"start_index,omitempty"`
+	// The number of leaves to stream. If zero, all the leaves from start_index
+	// up to the tree size are streamed.
+	Count int64 `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	// If positive, the range is read from the tree at this size, as in
+	// GetLeavesByRangeRequest.
+	TreeSize int64 `protobuf:"varint,4,opt,name=tree_size,json=treeSize,proto3" json:"tree_size,omitempty"`
+	// chunk_size is the maximum number of leaves in each streamed response.
+	// If zero, a server-chosen default is used. Values larger than the server's
+	// limit are capped to that limit.
+	ChunkSize            int32    `protobuf:"varint,5,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *GetLeavesByRangeRequest) Reset()         { *m = GetLeavesByRangeRequest{} }
-func (m *GetLeavesByRangeRequest) String() string { return proto.CompactTextString(m) }
-func (*GetLeavesByRangeRequest) ProtoMessage()    {}
-func (*GetLeavesByRangeRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{25}
+func (m *GetLeavesByRangeStreamRequest) Reset()         { *m = GetLeavesByRangeStreamRequest{} }
+func (m *GetLeavesByRangeStreamRequest) String() string { return proto.CompactTextString(m) }
+func (*GetLeavesByRangeStreamRequest) ProtoMessage()    {}
+func (*GetLeavesByRangeStreamRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{40}
 }
 
-func (m *GetLeavesByRangeRequest) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_GetLeavesByRangeRequest.Unmarshal(m, b)
+func (m *GetLeavesByRangeStreamRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetLeavesByRangeStreamRequest.Unmarshal(m, b)
 }
-func (m *GetLeavesByRangeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_GetLeavesByRangeRequest.Marshal(b, m, deterministic)
+func (m *GetLeavesByRangeStreamRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetLeavesByRangeStreamRequest.Marshal(b, m, deterministic)
 }
-func (m *GetLeavesByRangeRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_GetLeavesByRangeRequest.Merge(m, src)
+func (m *GetLeavesByRangeStreamRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetLeavesByRangeStreamRequest.Merge(m, src)
 }
-func (m *GetLeavesByRangeRequest) XXX_Size() int {
-	return xxx_messageInfo_GetLeavesByRangeRequest.Size(m)
+func (m *GetLeavesByRangeStreamRequest) XXX_Size() int {
+	return xxx_messageInfo_GetLeavesByRangeStreamRequest.Size(m)
 }
-func (m *GetLeavesByRangeRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_GetLeavesByRangeRequest.DiscardUnknown(m)
+func (m *GetLeavesByRangeStreamRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetLeavesByRangeStreamRequest.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_GetLeavesByRangeRequest proto.InternalMessageInfo
+var xxx_messageInfo_GetLeavesByRangeStreamRequest proto.InternalMessageInfo
 
-func (m *GetLeavesByRangeRequest) GetLogId() int64 {
+func (m *GetLeavesByRangeStreamRequest) GetLogId() int64 {
 	if m != nil {
 		return m.LogId
 	}
 	return 0
 }
 
-func (m *GetLeavesByRangeRequest) GetStartIndex() int64 {
+func (m *GetLeavesByRangeStreamRequest) GetStartIndex() int64 {
 	if m != nil {
 		return m.StartIndex
 	}
 	return 0
 }
 
-func (m *GetLeavesByRangeRequest) GetCount() int64 {
+func (m *GetLeavesByRangeStreamRequest) GetCount() int64 {
 	if m != nil {
 		return m.Count
 	}
 	return 0
 }
 
-func (m *GetLeavesByRangeRequest) GetChargeTo() *ChargeTo {
+func (m *GetLeavesByRangeStreamRequest) GetTreeSize() int64 {
 	if m != nil {
-		return m.ChargeTo
+		return m.TreeSize
 	}
-	return nil
+	return 0
 }
 
-type GetLeavesByRangeResponse struct {
-	// Returned log leaves starting from the `start_index` of the request, in
-	// order. There may be fewer than `request.count` leaves returned, if the
-	// requested range extended beyond the size of the tree or if the server opted
-	// to return fewer leaves than requested.
-	Leaves               []*LogLeaf     `protobuf:"bytes,1,rep,name=leaves,proto3" json:"leaves,omitempty"`
-	SignedLogRoot        *SignedLogRoot `protobuf:"bytes,2,opt,name=signed_log_root,json=signedLogRoot,proto3" json:"signed_log_root,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
-	XXX_unrecognized     []byte         `json:"-"`
-	XXX_sizecache        int32          `json:"-"`
+func (m *GetLeavesByRangeStreamRequest) GetChunkSize() int32 {
+	if m != nil {
+		return m.ChunkSize
+	}
+	return 0
 }
 
-func (m *GetLeavesByRangeResponse) Reset()         { *m = GetLeavesByRangeResponse{} }
-func (m *GetLeavesByRangeResponse) String() string { return proto.CompactTextString(m) }
-func (*GetLeavesByRangeResponse) ProtoMessage()    {}
-func (*GetLeavesByRangeResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{26}
+type GetLeavesByRangeStreamResponse struct {
+	// The next chunk of leaves of the range, in order.
+	Leaves []*LogLeaf `protobuf:"bytes,1,rep,name=leaves,proto3" json:"leaves,omitempty"`
+	// The latest root of the log when the stream started. It is only set in the
+	// first response of each stream.
+	SignedLogRoot *SignedLogRoot `protobuf:"bytes,2,opt,name=signed_log_root,json=signedLogRoot,proto3" json:"signed_log_root,omitempty"`
+	// The tree size the leaves are read at, as in GetLeavesByRangeResponse. It
+	// is only set in the first response of each stream.
+	TreeSize             int64    `protobuf:"varint,3,opt,name=tree_size,json=treeSize,proto3" json:"tree_size,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *GetLeavesByRangeResponse) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_GetLeavesByRangeResponse.Unmarshal(m, b)
+func (m *GetLeavesByRangeStreamResponse) Reset()         { *m = GetLeavesByRangeStreamResponse{} }
+func (m *GetLeavesByRangeStreamResponse) String() string { return proto.CompactTextString(m) }
+func (*GetLeavesByRangeStreamResponse) ProtoMessage()    {}
+func (*GetLeavesByRangeStreamResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ad20a6a54aa5af3, []int{41}
 }
-func (m *GetLeavesByRangeResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_GetLeavesByRangeResponse.Marshal(b, m, deterministic)
+
+func (m *GetLeavesByRangeStreamResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetLeavesByRangeStreamResponse.Unmarshal(m, b)
 }
-func (m *GetLeavesByRangeResponse) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_GetLeavesByRangeResponse.Merge(m, src)
+func (m *GetLeavesByRangeStreamResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetLeavesByRangeStreamResponse.Marshal(b, m, deterministic)
 }
-func (m *GetLeavesByRangeResponse) XXX_Size() int {
-	return xxx_messageInfo_GetLeavesByRangeResponse.Size(m)
+func (m *GetLeavesByRangeStreamResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetLeavesByRangeStreamResponse.Merge(m, src)
 }
-func (m *GetLeavesByRangeResponse) XXX_DiscardUnknown() {
-	xxx_messageInfo_GetLeavesByRangeResponse.DiscardUnknown(m)
+func (m *GetLeavesByRangeStreamResponse) XXX_Size() int {
+	return xxx_messageInfo_GetLeavesByRangeStreamResponse.Size(m)
+}
+func (m *GetLeavesByRangeStreamResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetLeavesByRangeStreamResponse.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_GetLeavesByRangeResponse proto.InternalMessageInfo
+var xxx_messageInfo_GetLeavesByRangeStreamResponse proto.InternalMessageInfo
 
-func (m *GetLeavesByRangeResponse) GetLeaves() []*LogLeaf {
+func (m *GetLeavesByRangeStreamResponse) GetLeaves() []*LogLeaf {
 	if m != nil {
 		return m.Leaves
 	}
 	return nil
 }
 
-func (m *GetLeavesByRangeResponse) GetSignedLogRoot() *SignedLogRoot {
+func (m *GetLeavesByRangeStreamResponse) GetSignedLogRoot() *SignedLogRoot {
 	if m != nil {
 		return m.SignedLogRoot
 	}
 	return nil
 }
 
+func (m *GetLeavesByRangeStreamResponse) GetTreeSize() int64 {
+	if m != nil {
+		return m.TreeSize
+	}
+	return 0
+}
+
 type GetLeavesByHashRequest struct {
 	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
 	// The Merkle leaf hash of the leaf to be retrieved.
@@ -1455,7 +2397,7 @@ func (m *GetLeavesByHashRequest) Reset()         { *m = GetLeavesByHashRequest{}
 func (m *GetLeavesByHashRequest) String() string { return proto.CompactTextString(m) }
 func (*GetLeavesByHashRequest) ProtoMessage()    {}
 func (*GetLeavesByHashRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{27}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{42}
 }
 
 func (m *GetLeavesByHashRequest) XXX_Unmarshal(b []byte) error {
@@ -1516,7 +2458,7 @@ func (m *GetLeavesByHashResponse) Reset()         { *m = GetLeavesByHashResponse
 func (m *GetLeavesByHashResponse) String() string { return proto.CompactTextString(m) }
 func (*GetLeavesByHashResponse) ProtoMessage()    {}
 func (*GetLeavesByHashResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{28}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{43}
 }
 
 func (m *GetLeavesByHashResponse) XXX_Unmarshal(b []byte) error {
@@ -1555,15 +2497,18 @@ func (m *GetLeavesByHashResponse) GetSignedLogRoot() *SignedLogRoot {
 // TODO(pavelkalinnikov): Consider renaming it to AddLogLeafResult or the like.
 type QueuedLogLeaf struct {
 	// The leaf as it was stored by Trillian. Empty unless `status.code` is:
-	//  - `google.rpc.OK`: the `leaf` data is the same as in the request.
+	//  - `google.rpc.OK`: the `leaf` data is the same as in the request, or
+	//    the `leaf` is the one already in the log if it's a duplicate and the
+	//    log's `duplicate_leaf_policy` is `DEDUPLICATE`.
 	//  - `google.rpc.ALREADY_EXISTS` or 'google.rpc.FAILED_PRECONDITION`: the
 	//    `leaf` is the conflicting one already in the log.
 	Leaf *LogLeaf `protobuf:"bytes,1,opt,name=leaf,proto3" json:"leaf,omitempty"`
 	// The status of adding the leaf.
-	//  - `google.rpc.OK`: successfully added.
+	//  - `google.rpc.OK`: successfully added, or deduplicated.
 	//  - `google.rpc.ALREADY_EXISTS`: the leaf is a duplicate of an already
 	//    existing one. Either `leaf_identity_hash` is the same in the `LOG`
-	//    mode, or `leaf_index` in the `PREORDERED_LOG`.
+	//    mode, or `leaf_index` in the `PREORDERED_LOG`. See the log's
+	//    `duplicate_leaf_policy`.
 	//  - `google.rpc.FAILED_PRECONDITION`: A conflicting entry is already
 	//    present in the log, e.g., same `leaf_index` but different `leaf_data`.
 	Status               *status.Status `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
@@ -1576,7 +2521,7 @@ func (m *QueuedLogLeaf) Reset()         { *m = QueuedLogLeaf{} }
 func (m *QueuedLogLeaf) String() string { return proto.CompactTextString(m) }
 func (*QueuedLogLeaf) ProtoMessage()    {}
 func (*QueuedLogLeaf) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{29}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{44}
 }
 
 func (m *QueuedLogLeaf) XXX_Unmarshal(b []byte) error {
@@ -1685,7 +2630,7 @@ func (m *LogLeaf) Reset()         { *m = LogLeaf{} }
 func (m *LogLeaf) String() string { return proto.CompactTextString(m) }
 func (*LogLeaf) ProtoMessage()    {}
 func (*LogLeaf) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{30}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{45}
 }
 
 func (m *LogLeaf) XXX_Unmarshal(b []byte) error {
@@ -1772,7 +2717,7 @@ func (m *Proof) Reset()         { *m = Proof{} }
 func (m *Proof) String() string { return proto.CompactTextString(m) }
 func (*Proof) ProtoMessage()    {}
 func (*Proof) Descriptor() ([]byte, []int) {
-	return fileDescriptor_5ad20a6a54aa5af3, []int{31}
+	return fileDescriptor_5ad20a6a54aa5af3, []int{46}
 }
 
 func (m *Proof) XXX_Unmarshal(b []byte) error {
@@ -1817,10 +2762,22 @@ func init() {
 	proto.RegisterType((*GetInclusionProofResponse)(nil), "trillian.GetInclusionProofResponse")
 	proto.RegisterType((*GetInclusionProofByHashRequest)(nil), "trillian.GetInclusionProofByHashRequest")
 	proto.RegisterType((*GetInclusionProofByHashResponse)(nil), "trillian.GetInclusionProofByHashResponse")
+	proto.RegisterType((*GetInclusionProofByHashBatchRequest)(nil), "trillian.GetInclusionProofByHashBatchRequest")
+	proto.RegisterType((*GetInclusionProofByHashBatchResponse)(nil), "trillian.GetInclusionProofByHashBatchResponse")
+	proto.RegisterType((*GetInclusionProofByHashBatchResponse_LeafHashProofs)(nil), "trillian.GetInclusionProofByHashBatchResponse.LeafHashProofs")
 	proto.RegisterType((*GetConsistencyProofRequest)(nil), "trillian.GetConsistencyProofRequest")
 	proto.RegisterType((*GetConsistencyProofResponse)(nil), "trillian.GetConsistencyProofResponse")
+	proto.RegisterType((*GetConsistencyProofByRootHashRequest)(nil), "trillian.GetConsistencyProofByRootHashRequest")
+	proto.RegisterType((*GetConsistencyProofByRootHashResponse)(nil), "trillian.GetConsistencyProofByRootHashResponse")
 	proto.RegisterType((*GetLatestSignedLogRootRequest)(nil), "trillian.GetLatestSignedLogRootRequest")
 	proto.RegisterType((*GetLatestSignedLogRootResponse)(nil), "trillian.GetLatestSignedLogRootResponse")
+	proto.RegisterType((*LogRootSignature)(nil), "trillian.LogRootSignature")
+	proto.RegisterType((*AddLogRootSignatureRequest)(nil), "trillian.AddLogRootSignatureRequest")
+	proto.RegisterType((*AddLogRootSignatureResponse)(nil), "trillian.AddLogRootSignatureResponse")
+	proto.RegisterType((*GetLogRootSignaturesRequest)(nil), "trillian.GetLogRootSignaturesRequest")
+	proto.RegisterType((*GetLogRootSignaturesResponse)(nil), "trillian.GetLogRootSignaturesResponse")
+	proto.RegisterType((*GetCheckpointRequest)(nil), "trillian.GetCheckpointRequest")
+	proto.RegisterType((*GetCheckpointResponse)(nil), "trillian.GetCheckpointResponse")
 	proto.RegisterType((*GetSequencedLeafCountRequest)(nil), "trillian.GetSequencedLeafCountRequest")
 	proto.RegisterType((*GetSequencedLeafCountResponse)(nil), "trillian.GetSequencedLeafCountResponse")
 	proto.RegisterType((*GetEntryAndProofRequest)(nil), "trillian.GetEntryAndProofRequest")
@@ -1831,10 +2788,14 @@ func init() {
 	proto.RegisterType((*QueueLeavesResponse)(nil), "trillian.QueueLeavesResponse")
 	proto.RegisterType((*AddSequencedLeavesRequest)(nil), "trillian.AddSequencedLeavesRequest")
 	proto.RegisterType((*AddSequencedLeavesResponse)(nil), "trillian.AddSequencedLeavesResponse")
+	proto.RegisterType((*AddSequencedLeavesStreamRequest)(nil), "trillian.AddSequencedLeavesStreamRequest")
+	proto.RegisterType((*AddSequencedLeavesStreamResponse)(nil), "trillian.AddSequencedLeavesStreamResponse")
 	proto.RegisterType((*GetLeavesByIndexRequest)(nil), "trillian.GetLeavesByIndexRequest")
 	proto.RegisterType((*GetLeavesByIndexResponse)(nil), "trillian.GetLeavesByIndexResponse")
 	proto.RegisterType((*GetLeavesByRangeRequest)(nil), "trillian.GetLeavesByRangeRequest")
 	proto.RegisterType((*GetLeavesByRangeResponse)(nil), "trillian.GetLeavesByRangeResponse")
+	proto.RegisterType((*GetLeavesByRangeStreamRequest)(nil), "trillian.GetLeavesByRangeStreamRequest")
+	proto.RegisterType((*GetLeavesByRangeStreamResponse)(nil), "trillian.GetLeavesByRangeStreamResponse")
 	proto.RegisterType((*GetLeavesByHashRequest)(nil), "trillian.GetLeavesByHashRequest")
 	proto.RegisterType((*GetLeavesByHashResponse)(nil), "trillian.GetLeavesByHashResponse")
 	proto.RegisterType((*QueuedLogLeaf)(nil), "trillian.QueuedLogLeaf")
@@ -1845,104 +2806,141 @@ func init() {
 func init() { proto.RegisterFile("trillian_log_api.proto", fileDescriptor_5ad20a6a54aa5af3) }
 
 var fileDescriptor_5ad20a6a54aa5af3 = []byte{
-	// 1540 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xcc, 0x58, 0x5b, 0x6f, 0xdc, 0x44,
-	0x14, 0xae, 0xe3, 0x5c, 0x4f, 0x9a, 0x6c, 0x32, 0xa1, 0xcd, 0xc6, 0x69, 0xda, 0xd4, 0x69, 0xda,
-	0x6d, 0x28, 0x31, 0x29, 0x42, 0xa0, 0xa8, 0x02, 0x35, 0x29, 0x0a, 0xa1, 0x0b, 0x14, 0x27, 0x42,
-	0x15, 0x3c, 0x58, 0x5e, 0xef, 0xc4, 0xb1, 0xd8, 0x78, 0xb6, 0xf6, 0x6c, 0xd4, 0x6d, 0x55, 0x89,
-	0x8b, 0xca, 0xe5, 0x01, 0x78, 0x80, 0x87, 0xbe, 0x70, 0x79, 0x43, 0xfc, 0x01, 0x7e, 0x06, 0x42,
-	0xe2, 0x2f, 0xf0, 0xc0, 0xcf, 0x40, 0x9e, 0x19, 0xaf, 0x2f, 0x6b, 0x7b, 0x77, 0x4b, 0x5b, 0x78,
-	0x5b, 0x9f, 0x39, 0x73, 0xce, 0x77, 0xbe, 0x99, 0x73, 0xe6, 0x9c, 0x85, 0xd3, 0xd4, 0x73, 0x1a,
-	0x0d, 0xc7, 0x74, 0x8d, 0x06, 0xb1, 0x0d, 0xb3, 0xe9, 0xac, 0x37, 0x3d, 0x42, 0x09, 0x1a, 0x0f,
-	0xe5, 0xca, 0x19, 0x9b, 0x10, 0xbb, 0x81, 0x35, 0xb3, 0xe9, 0x68, 0xa6, 0xeb, 0x12, 0x6a, 0x52,
-	0x87, 0xb8, 0x3e, 0xd7, 0x53, 0xce, 0x89, 0x55, 0xf6, 0x55, 0x6b, 0x1d, 0x68, 0xd4, 0x39, 0xc2,
-	0x3e, 0x35, 0x8f, 0x9a, 0x42, 0x61, 0x5e, 0x28, 0x78, 0x4d, 0x4b, 0xf3, 0xa9, 0x49, 0x5b, 0xe1,
-	0xce, 0xe9, 0xd0, 0x03, 0xff, 0x56, 0xcf, 0xc2, 0xf8, 0xf6, 0xa1, 0xe9, 0xd9, 0x78, 0x9f, 0x20,
-	0x04, 0xc3, 0x2d, 0x1f, 0x7b, 0x65, 0x69, 0x59, 0xae, 0x4c, 0xe8, 0xec, 0xb7, 0xfa, 0x89, 0x04,
-	0x33, 0xef, 0xb5, 0x70, 0x0b, 0x57, 0xb1, 0x79, 0xa0, 0xe3, 0x3b, 0x2d, 0xec, 0x53, 0x74, 0x0a,
-	0x46, 0x03, 0xdc, 0x4e, 0xbd, 0x2c, 0x2d, 0x4b, 0x15, 0x59, 0x1f, 0x69, 0x10, 0x7b, 0xb7, 0x8e,
-	0x56, 0x61, 0xb8, 0x81, 0xcd, 0x83, 0xf2, 0xd0, 0xb2, 0x54, 0x99, 0xbc, 0x3a, 0xbb, 0xde, 0x71,
-	0x55, 0x25, 0x36, 0xdb, 0xce, 0x96, 0x91, 0x06, 0x13, 0x16, 0x73, 0x69, 0x50, 0x52, 0x96, 0x99,
-	0x2e, 0x8a, 0x74, 0x43, 0x34, 0xfa, 0xb8, 0x25, 0x7e, 0xa9, 0x6f, 0xc3, 0x6c, 0x0c, 0x82, 0xdf,
-	0x24, 0xae, 0x8f, 0xd1, 0xab, 0x30, 0x79, 0x27, 0x10, 0xd6, 0x8d, 0x98, 0xcf, 0xf9, 0xc8, 0x0e,
-	0xdb, 0x51, 0x0f, 0x3d, 0x03, 0xd7, 0x0d, 0x7e, 0xab, 0x5f, 0x4a, 0x30, 0x7f, 0xbd, 0x5e, 0xdf,
-	0x0b, 0x82, 0x71, 0x2d, 0x2e, 0xfc, 0x8f, 0x22, 0xbb, 0x09, 0xe5, 0x6e, 0x24, 0x22, 0x40, 0x0d,
-	0x46, 0x3d, 0xec, 0xb7, 0x1a, 0xb4, 0x57, 0x6c, 0x42, 0x4d, 0xfd, 0x51, 0x82, 0xf2, 0x0e, 0xa6,
-	0xbb, 0xae, 0xd5, 0x68, 0xf9, 0x0e, 0x71, 0x6f, 0x79, 0x84, 0xf4, 0x0a, 0x6c, 0x09, 0x20, 0x40,
-	0x6e, 0x38, 0x6e, 0x1d, 0xdf, 0x65, 0x8e, 0x64, 0x7d, 0x22, 0x90, 0xec, 0x06, 0x02, 0xb4, 0x08,
-	0x13, 0xd4, 0xc3, 0xd8, 0xf0, 0x9d, 0x7b, 0x98, 0x05, 0x24, 0xeb, 0xe3, 0x81, 0x60, 0xcf, 0xb9,
-	0x87, 0x93, 0xd1, 0x0e, 0xf7, 0x11, 0xed, 0x67, 0x12, 0x2c, 0x64, 0x00, 0x14, 0xf1, 0xae, 0xc2,
-	0x48, 0x33, 0x10, 0x88, 0x70, 0x4b, 0x91, 0x29, 0xae, 0xc7, 0x57, 0xd1, 0xeb, 0x50, 0xf2, 0x1d,
-	0xdb, 0x0d, 0xce, 0x9d, 0xd8, 0x86, 0x47, 0x08, 0x15, 0x4c, 0xc7, 0xf8, 0xd9, 0x63, 0x0a, 0x55,
-	0x62, 0xeb, 0x84, 0x50, 0x7d, 0xca, 0x8f, 0x7f, 0xaa, 0xbf, 0x4b, 0x70, 0xb6, 0x0b, 0xc5, 0x56,
-	0xfb, 0x4d, 0xd3, 0x3f, 0xec, 0x41, 0xd6, 0x22, 0x30, 0x6a, 0x8c, 0x43, 0xd3, 0x3f, 0x64, 0x28,
-	0x4f, 0xea, 0xe3, 0x81, 0x20, 0xd8, 0x5a, 0x4c, 0xd5, 0x1a, 0xcc, 0x12, 0xaf, 0x8e, 0x3d, 0xa3,
-	0xd6, 0x36, 0x7c, 0x71, 0xda, 0x8c, 0xb2, 0x71, 0xbd, 0xc4, 0x16, 0xb6, 0xda, 0xe1, 0x25, 0x48,
-	0xd2, 0x3a, 0xd2, 0x07, 0xad, 0x5f, 0x49, 0x70, 0x2e, 0x37, 0xa0, 0x6e, 0x72, 0xe5, 0xa7, 0x49,
-	0xee, 0x6f, 0x12, 0x28, 0x3b, 0x98, 0x6e, 0x13, 0xd7, 0x77, 0x7c, 0x8a, 0x5d, 0xab, 0xdd, 0xcf,
-	0x2d, 0xbc, 0x08, 0xa5, 0x03, 0xc7, 0xf3, 0xa9, 0x11, 0x31, 0xc8, 0xaf, 0xe2, 0x14, 0x13, 0xef,
-	0x87, 0x34, 0x56, 0x60, 0xc6, 0xc7, 0x16, 0x71, 0xeb, 0x46, 0x9a, 0xea, 0x69, 0x2e, 0xdf, 0x7f,
-	0xec, 0xbb, 0xf9, 0x50, 0x82, 0xc5, 0x4c, 0xe0, 0xcf, 0xf8, 0x76, 0x7e, 0x2b, 0xc1, 0xd2, 0x0e,
-	0xa6, 0x55, 0x93, 0x62, 0x9f, 0x26, 0x35, 0x8b, 0x39, 0x4c, 0x44, 0x3c, 0xd4, 0x3b, 0xe2, 0x2c,
-	0xd2, 0xe5, 0x0c, 0xd2, 0x83, 0x72, 0x79, 0x36, 0x0f, 0x91, 0x20, 0x27, 0x23, 0xea, 0xa1, 0x41,
-	0xa2, 0x8e, 0xd8, 0x95, 0x8b, 0xd8, 0x55, 0x0f, 0xe0, 0xcc, 0x0e, 0xa6, 0x89, 0x72, 0xb9, 0x4d,
-	0x5a, 0xee, 0x93, 0xa6, 0x46, 0x7d, 0x8d, 0x9d, 0x41, 0x96, 0x1f, 0x11, 0x70, 0x58, 0x36, 0xad,
-	0x40, 0x1a, 0x2f, 0x9b, 0x4c, 0x4d, 0xfd, 0x41, 0x82, 0xf9, 0x1d, 0x4c, 0xdf, 0x70, 0xa9, 0xd7,
-	0xbe, 0xee, 0xd6, 0xff, 0x77, 0x85, 0xf8, 0x57, 0xfe, 0x52, 0xa4, 0xf0, 0x0d, 0x76, 0xd3, 0xc3,
-	0x27, 0x51, 0x2e, 0x7e, 0x12, 0x33, 0xae, 0xc6, 0xf0, 0x40, 0x09, 0x71, 0x1b, 0xa6, 0x77, 0x5d,
-	0x87, 0x06, 0x9f, 0x4f, 0xf8, 0x94, 0x6f, 0x40, 0xa9, 0x63, 0x59, 0xc4, 0xbe, 0x01, 0x63, 0x96,
-	0x87, 0x4d, 0x8a, 0xb9, 0xed, 0x02, 0x94, 0xa1, 0x9e, 0xfa, 0x85, 0x04, 0x28, 0xec, 0x4e, 0x8e,
-	0xb1, 0xdf, 0x03, 0xe4, 0x65, 0x18, 0x6d, 0x30, 0x3d, 0x51, 0x88, 0x33, 0x78, 0x13, 0x0a, 0x83,
-	0x37, 0x13, 0x7b, 0x30, 0x97, 0x00, 0x22, 0x62, 0xba, 0x06, 0x53, 0x51, 0xa3, 0x14, 0x79, 0xce,
-	0x6d, 0x27, 0x4e, 0x76, 0x5a, 0xa5, 0x63, 0xec, 0xab, 0xdf, 0x48, 0xb0, 0x90, 0x6a, 0x51, 0x9e,
-	0x5e, 0x94, 0xfd, 0xdc, 0xdd, 0x77, 0x41, 0xc9, 0xc2, 0x13, 0x1d, 0x20, 0xef, 0x86, 0x7a, 0x86,
-	0x19, 0xea, 0xa9, 0x1f, 0xf3, 0x64, 0xe5, 0x86, 0xb6, 0xda, 0x2c, 0xdf, 0x06, 0x4c, 0x56, 0x39,
-	0x99, 0xac, 0x03, 0xbf, 0xe0, 0x9f, 0xf3, 0x7c, 0x4c, 0x41, 0x10, 0x21, 0x0d, 0x40, 0xe6, 0xbf,
-	0x7e, 0x7d, 0x1e, 0x25, 0xb9, 0xd0, 0x4d, 0xd7, 0xc6, 0x3d, 0xb8, 0x38, 0x07, 0x93, 0x3e, 0x35,
-	0x3d, 0x9a, 0xa8, 0x5c, 0xc0, 0x44, 0x9c, 0x8d, 0xe7, 0x60, 0x84, 0x97, 0x49, 0x5e, 0xb6, 0xf8,
-	0xc7, 0xe0, 0xe7, 0x9e, 0xe2, 0x48, 0x40, 0xeb, 0xe2, 0x48, 0x7a, 0x0c, 0x8e, 0x06, 0x7a, 0xab,
-	0x82, 0xe2, 0x79, 0x3a, 0x06, 0x64, 0xf0, 0xbe, 0x51, 0x4e, 0xf4, 0x8d, 0x99, 0xad, 0xa1, 0xfc,
-	0x84, 0x5a, 0xc3, 0x87, 0xc9, 0xf3, 0x4c, 0xb4, 0x84, 0xcf, 0xf2, 0x5e, 0xd5, 0x60, 0x2a, 0x91,
-	0x7d, 0x9d, 0xd7, 0x43, 0x2a, 0x7e, 0x3d, 0xd6, 0x60, 0x94, 0x4f, 0xaf, 0x9d, 0x82, 0xce, 0xe7,
-	0xda, 0x75, 0xaf, 0x69, 0xad, 0xef, 0xb1, 0x15, 0x5d, 0x68, 0xa8, 0x7f, 0x0c, 0xc1, 0x58, 0x68,
-	0xbe, 0x02, 0x33, 0x47, 0xd8, 0xfb, 0xa8, 0x81, 0x8d, 0x88, 0x78, 0x89, 0x35, 0xec, 0xd3, 0x5c,
-	0x5e, 0x0d, 0xe9, 0x0f, 0x53, 0xf9, 0xd8, 0x6c, 0xb4, 0xb0, 0x68, 0xea, 0xd9, 0x69, 0xbd, 0x1f,
-	0x08, 0x82, 0x65, 0x7c, 0x97, 0x7a, 0xa6, 0x51, 0x37, 0xa9, 0xc9, 0x82, 0x3e, 0xa9, 0x4f, 0x30,
-	0xc9, 0x0d, 0x93, 0x9a, 0xa9, 0x42, 0x30, 0x9c, 0x7e, 0xb5, 0xaf, 0x00, 0xe2, 0xcb, 0x75, 0xec,
-	0x52, 0x87, 0xb6, 0x39, 0x90, 0x11, 0x66, 0x65, 0x86, 0xa9, 0x89, 0x05, 0x06, 0x65, 0x1b, 0x4a,
-	0xac, 0xf4, 0x1a, 0x9d, 0x61, 0xbe, 0x3c, 0xca, 0xa2, 0x56, 0xc2, 0xa8, 0xc3, 0x71, 0x7f, 0x7d,
-	0x3f, 0xd4, 0xd0, 0xa7, 0xd9, 0x96, 0xce, 0x37, 0xba, 0x09, 0x73, 0x8e, 0x4b, 0xb1, 0xed, 0x99,
-	0x34, 0x6e, 0x68, 0xac, 0xa7, 0x21, 0xd4, 0xd9, 0xd6, 0x91, 0xa9, 0x37, 0x60, 0x84, 0xbd, 0xf9,
-	0xa9, 0x38, 0xa5, 0x74, 0x9c, 0xa7, 0x61, 0x34, 0x88, 0x0c, 0xfb, 0x65, 0x99, 0xdd, 0x6e, 0xf1,
-	0xf5, 0xd6, 0xf0, 0xf8, 0xd0, 0x8c, 0x7c, 0xf5, 0xef, 0x29, 0x98, 0xdc, 0x17, 0xe7, 0x5b, 0x25,
-	0x36, 0x72, 0x61, 0xa2, 0x33, 0xce, 0x23, 0x25, 0x55, 0x9f, 0x63, 0xc3, 0xb8, 0xb2, 0x98, 0xb9,
-	0xc6, 0xaf, 0xaf, 0x5a, 0xf9, 0xf4, 0xcf, 0xbf, 0xbe, 0x1b, 0x52, 0xd5, 0x25, 0xed, 0x78, 0xa3,
-	0x86, 0xa9, 0xb9, 0xa1, 0x35, 0x88, 0xed, 0x6b, 0xf7, 0x79, 0x02, 0x3e, 0xd0, 0xf8, 0xd5, 0xdd,
-	0x94, 0xd6, 0xd0, 0xd7, 0x12, 0xcc, 0xa4, 0xa7, 0x6c, 0x74, 0x3e, 0xb2, 0x9d, 0xf3, 0x5f, 0x80,
-	0xa2, 0x16, 0xa9, 0x08, 0x14, 0x57, 0x19, 0x8a, 0x2b, 0xea, 0xa5, 0x62, 0x14, 0x61, 0x62, 0xd7,
-	0x03, 0x3c, 0x3f, 0x4b, 0x30, 0xdb, 0x35, 0xaf, 0xa1, 0x98, 0xb7, 0xbc, 0x21, 0x5e, 0x59, 0x29,
-	0xd4, 0x11, 0x90, 0xb6, 0x18, 0xa4, 0x6b, 0x68, 0xb3, 0x10, 0x92, 0x76, 0x3f, 0x3a, 0xd0, 0x07,
-	0x9b, 0x4e, 0x68, 0xca, 0xe0, 0xcd, 0xdd, 0x2f, 0xbc, 0x6e, 0x64, 0x8d, 0x94, 0xa8, 0x52, 0x00,
-	0x22, 0x51, 0x0e, 0x95, 0xcb, 0x7d, 0x68, 0x0a, 0xd0, 0xaf, 0x30, 0xd0, 0x1b, 0x48, 0x2b, 0xe6,
-	0x31, 0xc2, 0x59, 0xe3, 0xc9, 0x84, 0xbe, 0x97, 0x60, 0x2e, 0x63, 0x6e, 0x43, 0x17, 0x12, 0xbe,
-	0x73, 0xe6, 0x51, 0x65, 0xb5, 0x87, 0x96, 0x40, 0xf7, 0x22, 0x43, 0xb7, 0x86, 0x2a, 0xd9, 0xe8,
-	0x36, 0xad, 0x68, 0xa3, 0x20, 0xf0, 0x91, 0x78, 0x24, 0xba, 0x87, 0x26, 0x74, 0x29, 0xe1, 0x33,
-	0x7f, 0xd0, 0x53, 0x2a, 0xbd, 0x15, 0x05, 0xbe, 0xe7, 0x19, 0xbe, 0x55, 0xb4, 0x92, 0xc3, 0x5e,
-	0x50, 0xb1, 0xfd, 0xcd, 0x06, 0xb3, 0x80, 0x7e, 0x92, 0xe0, 0x54, 0xe6, 0x74, 0x83, 0x2e, 0x26,
-	0x1c, 0xe6, 0x8e, 0x59, 0xca, 0xa5, 0x9e, 0x7a, 0x02, 0xd7, 0xcb, 0x0c, 0x97, 0x86, 0x5e, 0xe8,
-	0x33, 0x3b, 0xf8, 0x3c, 0xc5, 0x12, 0x36, 0x3d, 0x9e, 0xc4, 0x13, 0x36, 0x67, 0xb4, 0x52, 0xd4,
-	0x22, 0x95, 0x64, 0xc2, 0xa2, 0xb5, 0xfe, 0xb3, 0x03, 0x59, 0x30, 0x26, 0x06, 0x05, 0x54, 0x8e,
-	0x5c, 0x24, 0xa7, 0x12, 0x65, 0x21, 0x63, 0x45, 0xf8, 0x5c, 0x61, 0x3e, 0x97, 0xd4, 0xc5, 0x9c,
-	0xeb, 0xe3, 0xb8, 0x0e, 0x45, 0x55, 0x98, 0x8c, 0x75, 0xef, 0xe8, 0x4c, 0x77, 0xed, 0x8b, 0xfa,
-	0x6e, 0x65, 0x29, 0x67, 0x55, 0x38, 0x3c, 0x81, 0x4c, 0x40, 0xdd, 0x5d, 0x32, 0x5a, 0xc9, 0xad,
-	0x68, 0x31, 0xdb, 0x17, 0x8a, 0x95, 0x3a, 0x2e, 0x3e, 0x64, 0x87, 0x94, 0xe8, 0x59, 0x53, 0x87,
-	0x94, 0xd5, 0x52, 0xa7, 0x0e, 0x29, 0xb3, 0xe5, 0xed, 0x32, 0xce, 0x9a, 0xbd, 0x1c, 0xe3, 0xf1,
-	0x1e, 0x35, 0xc7, 0x78, 0xa2, 0x57, 0x54, 0x4f, 0xa0, 0xdb, 0x50, 0x4a, 0x35, 0x45, 0x68, 0x39,
-	0x73, 0x63, 0xbc, 0x98, 0x9d, 0x2f, 0xd0, 0x08, 0x2d, 0x6f, 0xbd, 0x03, 0x0b, 0x16, 0x39, 0x0a,
-	0x5f, 0xd9, 0xe4, 0x5f, 0xed, 0x5b, 0x73, 0xb1, 0x47, 0xf0, 0x7a, 0xd3, 0xb9, 0x15, 0x08, 0x6f,
-	0x49, 0x1f, 0x28, 0xb6, 0x43, 0x0f, 0x5b, 0xb5, 0x75, 0x8b, 0x1c, 0x69, 0xe2, 0x5f, 0xfb, 0x70,
-	0x63, 0x6d, 0x94, 0xed, 0x7c, 0xe9, 0x9f, 0x00, 0x00, 0x00, 0xff, 0xff, 0xe5, 0xa4, 0xd4, 0xe5,
-	0x30, 0x18, 0x00, 0x00,
+	// 2132 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xcc, 0x5a, 0x5b, 0x6f, 0x1c, 0x49,
+	0x15, 0xde, 0xf2, 0xd8, 0x8e, 0x7d, 0x1c, 0xdf, 0xca, 0x89, 0x33, 0x69, 0x7b, 0x6c, 0x6f, 0x3b,
+	0x8e, 0x27, 0x66, 0x77, 0x26, 0x09, 0x02, 0x56, 0xd6, 0x02, 0x8a, 0x13, 0x08, 0xde, 0x18, 0x08,
+	0x63, 0x83, 0x56, 0x20, 0xd1, 0xea, 0xe9, 0x2e, 0x8f, 0x5b, 0x1e, 0x77, 0xcf, 0x76, 0xd7, 0x84,
+	0xcc, 0xae, 0x56, 0xb0, 0x8b, 0x16, 0x16, 0x21, 0xe0, 0x81, 0x45, 0x42, 0x42, 0x5c, 0x24, 0x1e,
+	0x10, 0xe2, 0x19, 0x1e, 0x78, 0xe2, 0x17, 0x20, 0xa4, 0xfd, 0x0b, 0x3c, 0xf0, 0x17, 0x78, 0x43,
+	0x5d, 0x55, 0x7d, 0xa9, 0x9e, 0xbe, 0x4c, 0xc7, 0xde, 0x90, 0x27, 0x4f, 0x57, 0x9d, 0x3a, 0xe7,
+	0x3b, 0x5f, 0x55, 0x9d, 0xaa, 0x73, 0xca, 0xb0, 0x4c, 0x5d, 0xab, 0xdb, 0xb5, 0x74, 0x5b, 0xeb,
+	0x3a, 0x1d, 0x4d, 0xef, 0x59, 0x8d, 0x9e, 0xeb, 0x50, 0x07, 0x4f, 0x05, 0xed, 0x8a, 0x62, 0xb8,
+	0x83, 0x1e, 0x75, 0x9a, 0xa7, 0x64, 0xe0, 0xf5, 0xda, 0xe2, 0x0f, 0x97, 0x52, 0x56, 0x3b, 0x8e,
+	0xd3, 0xe9, 0x92, 0xa6, 0xde, 0xb3, 0x9a, 0xba, 0x6d, 0x3b, 0x54, 0xa7, 0x96, 0x63, 0x7b, 0xa2,
+	0x77, 0x5d, 0xf4, 0xb2, 0xaf, 0x76, 0xff, 0xb8, 0x49, 0xad, 0x33, 0xe2, 0x51, 0xfd, 0xac, 0x27,
+	0x04, 0xae, 0x09, 0x01, 0xb7, 0x67, 0x34, 0x3d, 0xaa, 0xd3, 0x7e, 0x30, 0x72, 0x2e, 0xb0, 0xce,
+	0xbf, 0xd5, 0x35, 0x98, 0xba, 0x7f, 0xa2, 0xbb, 0x1d, 0x72, 0xe4, 0x60, 0x0c, 0xe3, 0x7d, 0x8f,
+	0xb8, 0x55, 0xb4, 0x51, 0xa9, 0x4f, 0xb7, 0xd8, 0x6f, 0xf5, 0x3d, 0x04, 0x0b, 0xdf, 0xe8, 0x93,
+	0x3e, 0x39, 0x20, 0xfa, 0x71, 0x8b, 0xbc, 0xd5, 0x27, 0x1e, 0xc5, 0x57, 0x61, 0xd2, 0xf7, 0xc9,
+	0x32, 0xab, 0x68, 0x03, 0xd5, 0x2b, 0xad, 0x89, 0xae, 0xd3, 0xd9, 0x37, 0xf1, 0x16, 0x8c, 0x77,
+	0x89, 0x7e, 0x5c, 0x1d, 0xdb, 0x40, 0xf5, 0x99, 0xbb, 0x8b, 0x8d, 0xd0, 0xd4, 0x81, 0xd3, 0x61,
+	0xc3, 0x59, 0x37, 0x6e, 0xc2, 0xb4, 0xc1, 0x4c, 0x6a, 0xd4, 0xa9, 0x56, 0x98, 0x2c, 0x8e, 0x64,
+	0x03, 0x34, 0xad, 0x29, 0x43, 0xfc, 0x52, 0xbf, 0x0a, 0x8b, 0x31, 0x08, 0x5e, 0xcf, 0xb1, 0x3d,
+	0x82, 0x5f, 0x83, 0x99, 0xb7, 0xfc, 0x46, 0x53, 0x8b, 0xd9, 0xbc, 0x16, 0xe9, 0x61, 0x23, 0xcc,
+	0xc0, 0x32, 0x70, 0x59, 0xff, 0xb7, 0xfa, 0x21, 0x82, 0x6b, 0xf7, 0x4c, 0xf3, 0xd0, 0x77, 0xc6,
+	0x36, 0x88, 0xf9, 0x7f, 0xf4, 0xec, 0x11, 0x54, 0x87, 0x91, 0x08, 0x07, 0x9b, 0x30, 0xe9, 0x12,
+	0xaf, 0xdf, 0xa5, 0x45, 0xbe, 0x09, 0x31, 0xf5, 0x77, 0x08, 0xaa, 0x0f, 0x09, 0xdd, 0xb7, 0x8d,
+	0x6e, 0xdf, 0xb3, 0x1c, 0xfb, 0xb1, 0xeb, 0x38, 0x45, 0x8e, 0xd5, 0x00, 0x7c, 0xe4, 0x9a, 0x65,
+	0x9b, 0xe4, 0x29, 0x33, 0x54, 0x69, 0x4d, 0xfb, 0x2d, 0xfb, 0x7e, 0x03, 0x5e, 0x81, 0x69, 0xea,
+	0x12, 0xa2, 0x79, 0xd6, 0xdb, 0x84, 0x39, 0x54, 0x69, 0x4d, 0xf9, 0x0d, 0x87, 0xd6, 0xdb, 0x44,
+	0xf6, 0x76, 0x7c, 0x04, 0x6f, 0x7f, 0x88, 0xe0, 0x7a, 0x0a, 0x40, 0xe1, 0xef, 0x16, 0x4c, 0xf4,
+	0xfc, 0x06, 0xe1, 0xee, 0x7c, 0xa4, 0x8a, 0xcb, 0xf1, 0x5e, 0xfc, 0x45, 0x98, 0xf7, 0xac, 0x8e,
+	0xed, 0xcf, 0xbb, 0xd3, 0xd1, 0x5c, 0xc7, 0xa1, 0xd5, 0x4a, 0x92, 0x9f, 0x43, 0x26, 0x70, 0xe0,
+	0x74, 0x5a, 0x8e, 0x43, 0x5b, 0xb3, 0x5e, 0xfc, 0x53, 0xfd, 0x27, 0x82, 0xb5, 0x21, 0x14, 0x7b,
+	0x83, 0xaf, 0xe8, 0xde, 0x49, 0x01, 0x59, 0x2b, 0xc0, 0xa8, 0xd1, 0x4e, 0x74, 0xef, 0x84, 0xa1,
+	0xbc, 0xdc, 0x9a, 0xf2, 0x1b, 0xfc, 0xa1, 0xf9, 0x54, 0xed, 0xc0, 0xa2, 0xe3, 0x9a, 0xc4, 0xd5,
+	0xda, 0x03, 0xcd, 0x13, 0xb3, 0xcd, 0x28, 0x9b, 0x6a, 0xcd, 0xb3, 0x8e, 0xbd, 0x41, 0xb0, 0x08,
+	0x64, 0x5a, 0x27, 0x46, 0xa0, 0xf5, 0x27, 0x08, 0xd6, 0x33, 0x1d, 0x1a, 0x26, 0xb7, 0xf2, 0x49,
+	0x92, 0xfb, 0x31, 0x82, 0xcd, 0x0c, 0x2c, 0x7b, 0x3a, 0x35, 0x4a, 0x32, 0x5c, 0x79, 0x41, 0x18,
+	0xfe, 0x68, 0x0c, 0x6e, 0xe4, 0x7b, 0x25, 0x68, 0xfe, 0x26, 0x4c, 0x32, 0x22, 0x3d, 0x16, 0x43,
+	0x67, 0xee, 0x7e, 0x3e, 0x52, 0x3b, 0xca, 0xf8, 0xc6, 0x81, 0xf0, 0x95, 0x09, 0x78, 0x2d, 0xa1,
+	0x2c, 0x6d, 0x5a, 0xc6, 0xca, 0x4c, 0x8b, 0x72, 0x04, 0x73, 0xb2, 0x6a, 0x99, 0x69, 0x94, 0x58,
+	0xcb, 0xa3, 0xad, 0x16, 0xf5, 0x6f, 0x08, 0x94, 0x87, 0x84, 0xde, 0x77, 0x6c, 0xcf, 0xf2, 0x28,
+	0xb1, 0x8d, 0xc1, 0x28, 0x21, 0xe7, 0x26, 0xcc, 0x1f, 0x5b, 0xae, 0x47, 0xb5, 0x68, 0x32, 0x79,
+	0xdc, 0x99, 0x65, 0xcd, 0x47, 0xc1, 0x8c, 0xd6, 0x61, 0xc1, 0x23, 0x86, 0x63, 0x9b, 0x5a, 0x72,
+	0xd6, 0xe7, 0x78, 0xfb, 0xd1, 0x33, 0x07, 0xa2, 0x0f, 0x10, 0xac, 0xa4, 0x02, 0x7f, 0xce, 0xa1,
+	0xe8, 0x1f, 0x08, 0x6e, 0xa4, 0xe0, 0xd8, 0x1b, 0xf8, 0xbd, 0x23, 0x04, 0xa4, 0x90, 0x4a, 0xdf,
+	0x76, 0x3c, 0x2c, 0x71, 0x2a, 0x03, 0x2d, 0x31, 0x2a, 0x23, 0xc1, 0x0a, 0x13, 0x14, 0x54, 0x86,
+	0x92, 0xa5, 0xa9, 0xfc, 0x0f, 0x82, 0xad, 0x02, 0x17, 0x92, 0xa4, 0xa2, 0x5c, 0x52, 0xdf, 0x80,
+	0xab, 0xdc, 0xa7, 0x92, 0x2b, 0x1e, 0xb3, 0x51, 0x52, 0x1b, 0x3e, 0x80, 0x65, 0xe1, 0x77, 0xc9,
+	0x79, 0x5a, 0xe2, 0xc3, 0xa4, 0x46, 0xf5, 0x17, 0x08, 0x6a, 0x0f, 0x09, 0x3d, 0xd0, 0x29, 0x49,
+	0x18, 0x2a, 0x98, 0x26, 0x89, 0xd4, 0xb1, 0x62, 0x52, 0xd3, 0xb6, 0x48, 0x25, 0x65, 0x8b, 0xa8,
+	0x1f, 0xf2, 0xa3, 0x2c, 0x15, 0x91, 0x60, 0xfd, 0xbc, 0xa1, 0x23, 0x9a, 0xb6, 0x4a, 0xde, 0xb4,
+	0xa9, 0x6d, 0x58, 0x10, 0x23, 0x7c, 0x6d, 0x3a, 0xed, 0xbb, 0x04, 0xdf, 0x06, 0xe8, 0xf5, 0xdb,
+	0x5d, 0xcb, 0xd0, 0x4e, 0xc9, 0x40, 0x4c, 0xfb, 0x62, 0x43, 0x5c, 0x73, 0x1f, 0xb3, 0x9e, 0x47,
+	0x64, 0xd0, 0x9a, 0xee, 0x05, 0x3f, 0xf1, 0x2a, 0x4c, 0x7b, 0xc1, 0x70, 0xb1, 0x94, 0xa3, 0x06,
+	0xf5, 0xef, 0x08, 0x94, 0x7b, 0xa6, 0x99, 0xb4, 0x53, 0xc0, 0xbe, 0x02, 0x53, 0x2e, 0x79, 0x62,
+	0xf9, 0x71, 0x57, 0x04, 0x9a, 0xf0, 0x1b, 0xbf, 0x16, 0xb7, 0xc7, 0x1d, 0x54, 0xa4, 0xcb, 0x9d,
+	0x6c, 0x28, 0x12, 0x2e, 0xbf, 0x51, 0x6a, 0xb0, 0x92, 0x8a, 0x9d, 0xcf, 0x93, 0xfa, 0x1e, 0x0f,
+	0x49, 0xc9, 0x7e, 0xef, 0x1c, 0xce, 0x95, 0xbe, 0x8d, 0xfe, 0x06, 0xc1, 0x6a, 0x3a, 0x86, 0xec,
+	0xc5, 0x84, 0x4a, 0x2d, 0xa6, 0x5d, 0x80, 0x90, 0x42, 0x4f, 0x9c, 0x2e, 0x79, 0x84, 0xc7, 0xa4,
+	0xd5, 0xef, 0xc2, 0x15, 0x3f, 0xd0, 0x9c, 0x10, 0xe3, 0xb4, 0xe7, 0x58, 0xf6, 0x45, 0x6f, 0x3a,
+	0xf5, 0x29, 0x5c, 0x4d, 0xe8, 0x17, 0x5e, 0xaf, 0x01, 0x18, 0x61, 0xab, 0x38, 0x2b, 0x63, 0x2d,
+	0xe7, 0xde, 0x62, 0xea, 0x31, 0xa3, 0x5d, 0xca, 0x02, 0xee, 0x3b, 0xfd, 0x8b, 0xf7, 0xf0, 0x0b,
+	0x50, 0xcb, 0xb0, 0x23, 0x3c, 0x0d, 0xb2, 0x01, 0xc3, 0x6f, 0x8d, 0x67, 0x03, 0x4c, 0x4c, 0xfd,
+	0x2d, 0x82, 0x6b, 0x0f, 0x09, 0xfd, 0x92, 0x4d, 0xdd, 0xc1, 0x3d, 0xdb, 0x7c, 0xe1, 0xf2, 0x8b,
+	0x3f, 0xf3, 0x04, 0x28, 0x81, 0xaf, 0xdc, 0x99, 0x1e, 0x64, 0x7a, 0x95, 0xfc, 0x4c, 0x2f, 0x65,
+	0xce, 0xc7, 0x4b, 0xcd, 0xf9, 0x9b, 0x30, 0xb7, 0x6f, 0x5b, 0x6c, 0xaf, 0x5d, 0xf0, 0x2c, 0x3f,
+	0x80, 0xf9, 0x50, 0xb3, 0xf0, 0xfd, 0x0e, 0x5c, 0x32, 0x5c, 0xa2, 0x53, 0x62, 0x16, 0xed, 0xd7,
+	0x40, 0x4e, 0xfd, 0x31, 0x02, 0x1c, 0x24, 0xdd, 0x4f, 0x0a, 0xc3, 0xd0, 0x2d, 0x98, 0xec, 0x32,
+	0x39, 0xb1, 0xa7, 0x53, 0x78, 0x13, 0x02, 0xe5, 0xa3, 0xd2, 0x4f, 0x11, 0x2c, 0x49, 0x48, 0x84,
+	0x53, 0xaf, 0xc3, 0x6c, 0x54, 0x00, 0x88, 0x4c, 0x67, 0xa6, 0xc9, 0x97, 0xc3, 0x12, 0x80, 0x0f,
+	0xe3, 0xb3, 0x70, 0xb9, 0xad, 0x1b, 0xa7, 0x3d, 0x97, 0x78, 0x5e, 0x14, 0xfc, 0x71, 0x83, 0xd7,
+	0x4d, 0x1a, 0x6e, 0xcf, 0x68, 0x1c, 0xb2, 0xba, 0x49, 0x4b, 0x92, 0x53, 0x7f, 0x8e, 0xe0, 0x7a,
+	0x22, 0x65, 0xff, 0xe4, 0xe8, 0x19, 0x65, 0xd1, 0x7f, 0x1d, 0x94, 0x34, 0x3c, 0xd1, 0xcc, 0xf3,
+	0xea, 0x40, 0x21, 0x3d, 0x81, 0x9c, 0xfa, 0x2b, 0x04, 0xeb, 0xc3, 0x1a, 0x0f, 0xa9, 0x4b, 0xf4,
+	0xb3, 0x8b, 0xf3, 0xf3, 0x36, 0x5c, 0xf9, 0x9e, 0x6e, 0x51, 0xed, 0xd8, 0x71, 0x35, 0xcb, 0xa6,
+	0xa4, 0xe3, 0xb2, 0x02, 0x17, 0x9b, 0x87, 0xa9, 0x16, 0xf6, 0xfb, 0xbe, 0xec, 0xb8, 0xfb, 0x51,
+	0x8f, 0xfa, 0x17, 0x04, 0x1b, 0xd9, 0xb8, 0x52, 0x23, 0x18, 0x4a, 0x44, 0x30, 0xbc, 0x0d, 0xf3,
+	0x66, 0xbf, 0xd7, 0xb5, 0x0c, 0x9d, 0x12, 0x29, 0xca, 0xcd, 0x85, 0xcd, 0x5c, 0xf0, 0xdc, 0x57,
+	0xfb, 0x1f, 0xf0, 0x58, 0xc9, 0x41, 0xee, 0x0d, 0x58, 0xb8, 0x2b, 0x19, 0x2b, 0x2b, 0x72, 0xac,
+	0x2c, 0x9d, 0xb5, 0xfe, 0x88, 0x87, 0xc3, 0x04, 0x04, 0x41, 0x54, 0x89, 0xa9, 0x3a, 0x37, 0x17,
+	0x7f, 0x95, 0xb9, 0x68, 0xe9, 0x76, 0xa7, 0xe8, 0xd2, 0xb6, 0x0e, 0x33, 0x1e, 0xd5, 0x5d, 0x2a,
+	0x1d, 0x1c, 0xc0, 0x9a, 0x38, 0x1b, 0x57, 0x60, 0x82, 0xcf, 0x1f, 0x3f, 0x35, 0xf8, 0x47, 0xe9,
+	0xdd, 0x23, 0x1f, 0x40, 0x13, 0xf2, 0x01, 0xa4, 0xfe, 0x51, 0x26, 0x50, 0xe0, 0x1e, 0x22, 0x10,
+	0x3d, 0x03, 0x81, 0xe5, 0xee, 0xe0, 0x79, 0xc7, 0xa4, 0x7f, 0xea, 0xd5, 0x92, 0x28, 0x47, 0xda,
+	0xad, 0xcf, 0xc8, 0xb1, 0x04, 0x66, 0x3c, 0x71, 0x66, 0xd7, 0xfc, 0xbb, 0x52, 0xdf, 0x3e, 0x8d,
+	0x08, 0x9d, 0x68, 0x4d, 0xb3, 0x96, 0x00, 0xeb, 0x5a, 0x16, 0xd6, 0x17, 0x90, 0xd7, 0xe5, 0x18,
+	0xd6, 0xf2, 0xf5, 0x41, 0xb9, 0x7a, 0x95, 0x5a, 0xa0, 0xaa, 0x5c, 0x50, 0x81, 0xea, 0x03, 0x79,
+	0x87, 0x49, 0x79, 0xf7, 0xf3, 0xdc, 0xe9, 0x6d, 0x98, 0x95, 0x4e, 0x95, 0xf0, 0x3a, 0x85, 0xf2,
+	0xaf, 0x53, 0x3b, 0x30, 0xc9, 0x5f, 0x29, 0xaa, 0x63, 0x99, 0xe7, 0xb0, 0x90, 0x50, 0xff, 0x35,
+	0x06, 0x97, 0x02, 0xf5, 0x75, 0x58, 0x38, 0x23, 0xee, 0x69, 0x97, 0x68, 0xc9, 0x62, 0xd6, 0x1c,
+	0x6f, 0x0f, 0xaa, 0x5e, 0x61, 0x70, 0x7d, 0xa2, 0x77, 0xfb, 0x61, 0x6a, 0xe9, 0xb7, 0x7c, 0xcb,
+	0x6f, 0xf0, 0xbb, 0xc9, 0x53, 0xea, 0xea, 0x9a, 0xa9, 0x53, 0x5d, 0xd4, 0x46, 0xa6, 0x59, 0xcb,
+	0x03, 0x9d, 0xea, 0x89, 0xd0, 0x3c, 0x9e, 0xbc, 0xc6, 0xbe, 0x02, 0x98, 0x77, 0x9b, 0xc4, 0xa6,
+	0x16, 0x1d, 0x70, 0x20, 0x13, 0x4c, 0xcb, 0x02, 0x13, 0x13, 0x1d, 0x0c, 0xca, 0x7d, 0x98, 0x67,
+	0x57, 0x11, 0x2d, 0x7c, 0xb4, 0xa9, 0x4e, 0x8a, 0xd4, 0x53, 0x78, 0x1d, 0x3c, 0xeb, 0x34, 0x8e,
+	0x02, 0x89, 0xd6, 0x1c, 0x1b, 0x12, 0x7e, 0xe3, 0x47, 0xb0, 0x14, 0x1c, 0x9b, 0x71, 0x45, 0x97,
+	0x0a, 0x15, 0xe1, 0x70, 0x58, 0xd8, 0xa6, 0x3e, 0x80, 0x09, 0x76, 0x09, 0x4e, 0xf8, 0x89, 0x92,
+	0x7e, 0x2e, 0xc3, 0xa4, 0xef, 0x19, 0xf1, 0xaa, 0x15, 0xb6, 0xba, 0xc5, 0xd7, 0x1b, 0xe3, 0x53,
+	0x63, 0x0b, 0x95, 0xbb, 0xff, 0x5d, 0x82, 0x99, 0x23, 0x31, 0xbf, 0x07, 0x4e, 0x07, 0xdb, 0x30,
+	0x1d, 0x3e, 0xdb, 0x60, 0x25, 0x71, 0xef, 0x88, 0x3d, 0xba, 0x28, 0x2b, 0xa9, 0x7d, 0x22, 0x31,
+	0xae, 0xbf, 0xff, 0xf1, 0xbf, 0x7f, 0x39, 0xa6, 0xaa, 0xb5, 0xe6, 0x93, 0x3b, 0x6d, 0x42, 0xf5,
+	0x3b, 0xcd, 0xae, 0xd3, 0xf1, 0x9a, 0xef, 0xf0, 0x0d, 0xf8, 0x6e, 0x93, 0x2f, 0xdd, 0x5d, 0xb4,
+	0x83, 0x7f, 0x86, 0x60, 0x21, 0xf9, 0x9a, 0x82, 0x5f, 0x8e, 0x74, 0x67, 0xbc, 0xf9, 0x28, 0x6a,
+	0x9e, 0x88, 0x40, 0x71, 0x97, 0xa1, 0x78, 0x45, 0xdd, 0xce, 0x47, 0x11, 0x6c, 0x6c, 0xd3, 0xc7,
+	0xf3, 0x07, 0x04, 0x8b, 0x43, 0x55, 0x5f, 0xac, 0xe6, 0x94, 0x84, 0x03, 0x44, 0x9b, 0xb9, 0x32,
+	0x02, 0xd2, 0x1e, 0x83, 0xf4, 0x3a, 0xde, 0xcd, 0x85, 0xd4, 0x7c, 0x27, 0x9a, 0xd0, 0x77, 0x77,
+	0xad, 0x40, 0x95, 0xc6, 0xb3, 0x9d, 0x3f, 0xf1, 0xb8, 0x91, 0x56, 0x98, 0xc6, 0xf5, 0xc2, 0xda,
+	0x75, 0x00, 0xf7, 0xd6, 0x08, 0x92, 0x02, 0xf4, 0xe7, 0x18, 0xe8, 0x3b, 0xb8, 0x99, 0xcf, 0x63,
+	0x84, 0xb3, 0xcd, 0x37, 0x13, 0xfe, 0x08, 0xc1, 0x52, 0x4a, 0x9d, 0x11, 0xdf, 0x90, 0x6c, 0x67,
+	0x94, 0xa2, 0x95, 0xad, 0x02, 0x29, 0x81, 0xee, 0x36, 0x43, 0xb7, 0x83, 0xeb, 0xe9, 0xe8, 0x76,
+	0x8d, 0x68, 0xa0, 0x20, 0xf0, 0xd7, 0xe2, 0x90, 0x18, 0xae, 0xc0, 0xe1, 0x6d, 0xc9, 0x66, 0x76,
+	0xd5, 0x50, 0xa9, 0x17, 0x0b, 0x0a, 0x7c, 0x9f, 0x62, 0xf8, 0xb6, 0xf0, 0x66, 0x06, 0x7b, 0x7e,
+	0xc4, 0xf6, 0x76, 0xbb, 0x4c, 0x03, 0xfe, 0x3d, 0x62, 0x05, 0x8d, 0xe1, 0x74, 0x1f, 0xdf, 0x94,
+	0x0c, 0x66, 0xd6, 0x1d, 0x94, 0xed, 0x42, 0x39, 0x81, 0xeb, 0x33, 0x0c, 0x57, 0x13, 0xbf, 0x3a,
+	0xe2, 0xee, 0xe0, 0x57, 0x6f, 0xb6, 0x61, 0x93, 0xf9, 0x7a, 0x7c, 0xc3, 0x66, 0xd4, 0x1a, 0x14,
+	0x35, 0x4f, 0x44, 0xde, 0xb0, 0x78, 0x67, 0xf4, 0xdd, 0x81, 0x0d, 0xb8, 0x24, 0x32, 0x67, 0x5c,
+	0x8d, 0x4c, 0xc8, 0x69, 0xba, 0x72, 0x3d, 0xa5, 0x47, 0xd8, 0xdc, 0x64, 0x36, 0x6b, 0xea, 0x4a,
+	0xc6, 0xf2, 0xb1, 0x6c, 0xcb, 0xaf, 0x49, 0xcf, 0xc4, 0xb2, 0x59, 0xbc, 0x3a, 0x1c, 0xfb, 0xa2,
+	0x7c, 0x52, 0xa9, 0x65, 0xf4, 0x0a, 0x83, 0x2f, 0x61, 0x1d, 0xf0, 0x70, 0x4e, 0x84, 0x37, 0x33,
+	0x23, 0x5a, 0x4c, 0xf7, 0x8d, 0x7c, 0xa1, 0xd0, 0x44, 0x1f, 0xaa, 0x59, 0x69, 0x17, 0xbe, 0x95,
+	0xa7, 0x43, 0xba, 0x84, 0x2a, 0x3b, 0xa3, 0x88, 0x06, 0x46, 0xeb, 0x08, 0x7f, 0x87, 0xad, 0x0d,
+	0x29, 0x79, 0x49, 0xac, 0x8d, 0xb4, 0xdc, 0x4a, 0x51, 0xf3, 0x44, 0x42, 0x9f, 0x64, 0xe5, 0xec,
+	0x1a, 0x9a, 0xa1, 0x3c, 0x9e, 0xac, 0x28, 0x6a, 0x9e, 0x48, 0xa8, 0xdc, 0x81, 0xe5, 0x64, 0xaf,
+	0xa0, 0x6b, 0x3b, 0x7b, 0xbc, 0x4c, 0x56, 0xbd, 0x58, 0x30, 0x30, 0x77, 0x1b, 0xe1, 0x37, 0x61,
+	0x3e, 0x71, 0xf9, 0xc3, 0x1b, 0xa9, 0x0a, 0xe2, 0x41, 0xfb, 0xe5, 0x1c, 0x89, 0xd0, 0x95, 0xef,
+	0xc3, 0x6a, 0x46, 0x44, 0x67, 0xef, 0x96, 0xf8, 0xd5, 0x51, 0xdf, 0x37, 0xb9, 0xcd, 0x46, 0xb9,
+	0xe7, 0x50, 0xf5, 0x25, 0x6c, 0xc2, 0x52, 0x4a, 0xd5, 0x1c, 0xcb, 0x6b, 0x37, 0xe3, 0x41, 0x40,
+	0xd9, 0x2a, 0x90, 0x0a, 0xad, 0x74, 0x58, 0x69, 0x39, 0x29, 0xe0, 0x61, 0xf9, 0xd8, 0xc8, 0xaa,
+	0xcd, 0x2b, 0x37, 0x8b, 0xc4, 0x42, 0x43, 0xef, 0xf3, 0x5c, 0x2d, 0xfb, 0xb5, 0x0c, 0x37, 0x72,
+	0x4f, 0xaa, 0xa1, 0x97, 0x41, 0xa5, 0x39, 0xb2, 0x7c, 0x08, 0xa2, 0x05, 0xb3, 0x52, 0xa1, 0x1b,
+	0xaf, 0xc9, 0x3a, 0x92, 0x15, 0x76, 0x65, 0x3d, 0xb3, 0x3f, 0xd0, 0xb9, 0xf7, 0x35, 0xb8, 0x6e,
+	0x38, 0x67, 0xc1, 0xb5, 0x53, 0xfe, 0x1f, 0xa3, 0xbd, 0xa5, 0xd8, 0xad, 0xf0, 0x5e, 0xcf, 0x7a,
+	0xec, 0x37, 0x3e, 0x46, 0xdf, 0x56, 0x3a, 0x16, 0x3d, 0xe9, 0xb7, 0x1b, 0x86, 0x73, 0xd6, 0xe4,
+	0x03, 0x9b, 0xc1, 0xc0, 0xf6, 0x24, 0x1b, 0xf9, 0xe9, 0xff, 0x0d, 0x00, 0x57, 0x53, 0x1e, 0x84,
+	0x45, 0x25, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -2012,15 +3010,52 @@ type TrillianLogClient interface {
 	// AddSequencedLeaves adds a batch of leaves with assigned sequence numbers
 	// to a pre-ordered log.  The indices of the provided leaves must be contiguous.
 	AddSequencedLeaves(ctx context.Context, in *AddSequencedLeavesRequest, opts ...grpc.CallOption) (*AddSequencedLeavesResponse, error)
+	// AddSequencedLeavesStream imports leaves with assigned sequence numbers
+	// into a pre-ordered log from a stream of chunks, e.g. to migrate an
+	// existing append-only log. The indices of the leaves must be contiguous
+	// across the whole stream. The response is sent once the client closes the
+	// stream and, if requested, once the leaves have been integrated.
+	AddSequencedLeavesStream(ctx context.Context, opts ...grpc.CallOption) (TrillianLog_AddSequencedLeavesStreamClient, error)
 	// GetLeavesByIndex returns a batch of leaves whose leaf indices are provided
 	// in the request.
 	GetLeavesByIndex(ctx context.Context, in *GetLeavesByIndexRequest, opts ...grpc.CallOption) (*GetLeavesByIndexResponse, error)
 	// GetLeavesByRange returns a batch of leaves whose leaf indices are in a
 	// sequential range.
 	GetLeavesByRange(ctx context.Context, in *GetLeavesByRangeRequest, opts ...grpc.CallOption) (*GetLeavesByRangeResponse, error)
+	// GetLeavesByRangeStream streams the leaves whose leaf indices are in a
+	// sequential range, in order, in chunks of up to chunk_size leaves. All the
+	// leaves are read at the same tree size, so that mirrors and auditors can
+	// read a large range without paging through it.
+	GetLeavesByRangeStream(ctx context.Context, in *GetLeavesByRangeStreamRequest, opts ...grpc.CallOption) (TrillianLog_GetLeavesByRangeStreamClient, error)
 	// GetLeavesByHash returns a batch of leaves which are identified by their
 	// Merkle leaf hash values.
 	GetLeavesByHash(ctx context.Context, in *GetLeavesByHashRequest, opts ...grpc.CallOption) (*GetLeavesByHashResponse, error)
+	// GetInclusionProofByHashBatch returns inclusion proofs for the leaves with
+	// any of the given Merkle hashes, in a particular tree. All the proofs are
+	// read from one storage snapshot, and relate to the same log root.
+	//
+	// Hashes without a leaf in the requested tree size have no proofs, rather
+	// than failing the request.
+	GetInclusionProofByHashBatch(ctx context.Context, in *GetInclusionProofByHashBatchRequest, opts ...grpc.CallOption) (*GetInclusionProofByHashBatchResponse, error)
+	// AddLogRootSignature stores the signature of a witness over the log root
+	// at a revision, once it has been verified with the witness's public key. A
+	// later signature with the same key replaces it. The log doesn't vouch for
+	// the witnesses: clients decide which keys they trust.
+	AddLogRootSignature(ctx context.Context, in *AddLogRootSignatureRequest, opts ...grpc.CallOption) (*AddLogRootSignatureResponse, error)
+	// GetLogRootSignatures returns the log root at a revision, along with the
+	// witness signatures stored for it.
+	GetLogRootSignatures(ctx context.Context, in *GetLogRootSignaturesRequest, opts ...grpc.CallOption) (*GetLogRootSignaturesResponse, error)
+	// GetConsistencyProofByRootHash returns a consistency proof between two
+	// roots of the log identified by their root hashes. Unlike
+	// GetConsistencyProof, it fails with NOT_FOUND unless both hashes are of
+	// roots the log has published, so that monitors can't be given proofs
+	// between tree states the log never committed to.
+	GetConsistencyProofByRootHash(ctx context.Context, in *GetConsistencyProofByRootHashRequest, opts ...grpc.CallOption) (*GetConsistencyProofByRootHashResponse, error)
+	// GetCheckpoint returns the latest root of the log as a checkpoint: a note
+	// in the signed note format, signed by the log's key, as consumed by
+	// witnesses and other checkpoint-based tools. It returns Unimplemented
+	// unless the server is configured with a checkpoint origin prefix.
+	GetCheckpoint(ctx context.Context, in *GetCheckpointRequest, opts ...grpc.CallOption) (*GetCheckpointResponse, error)
 }
 
 type trillianLogClient struct {
@@ -2130,6 +3165,40 @@ func (c *trillianLogClient) AddSequencedLeaves(ctx context.Context, in *AddSeque
 	return out, nil
 }
 
+func (c *trillianLogClient) AddSequencedLeavesStream(ctx context.Context, opts ...grpc.CallOption) (TrillianLog_AddSequencedLeavesStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TrillianLog_serviceDesc.Streams[0], "/trillian.TrillianLog/AddSequencedLeavesStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trillianLogAddSequencedLeavesStreamClient{stream}
+	return x, nil
+}
+
+type TrillianLog_AddSequencedLeavesStreamClient interface {
+	Send(*AddSequencedLeavesStreamRequest) error
+	CloseAndRecv() (*AddSequencedLeavesStreamResponse, error)
+	grpc.ClientStream
+}
+
+type trillianLogAddSequencedLeavesStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *trillianLogAddSequencedLeavesStreamClient) Send(m *AddSequencedLeavesStreamRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *trillianLogAddSequencedLeavesStreamClient) CloseAndRecv() (*AddSequencedLeavesStreamResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(AddSequencedLeavesStreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *trillianLogClient) GetLeavesByIndex(ctx context.Context, in *GetLeavesByIndexRequest, opts ...grpc.CallOption) (*GetLeavesByIndexResponse, error) {
 	out := new(GetLeavesByIndexResponse)
 	err := c.cc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByIndex", in, out, opts...)
@@ -2148,6 +3217,38 @@ func (c *trillianLogClient) GetLeavesByRange(ctx context.Context, in *GetLeavesB
 	return out, nil
 }
 
+func (c *trillianLogClient) GetLeavesByRangeStream(ctx context.Context, in *GetLeavesByRangeStreamRequest, opts ...grpc.CallOption) (TrillianLog_GetLeavesByRangeStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TrillianLog_serviceDesc.Streams[1], "/trillian.TrillianLog/GetLeavesByRangeStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trillianLogGetLeavesByRangeStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TrillianLog_GetLeavesByRangeStreamClient interface {
+	Recv() (*GetLeavesByRangeStreamResponse, error)
+	grpc.ClientStream
+}
+
+type trillianLogGetLeavesByRangeStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *trillianLogGetLeavesByRangeStreamClient) Recv() (*GetLeavesByRangeStreamResponse, error) {
+	m := new(GetLeavesByRangeStreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *trillianLogClient) GetLeavesByHash(ctx context.Context, in *GetLeavesByHashRequest, opts ...grpc.CallOption) (*GetLeavesByHashResponse, error) {
 	out := new(GetLeavesByHashResponse)
 	err := c.cc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByHash", in, out, opts...)
@@ -2157,6 +3258,51 @@ func (c *trillianLogClient) GetLeavesByHash(ctx context.Context, in *GetLeavesBy
 	return out, nil
 }
 
+func (c *trillianLogClient) GetInclusionProofByHashBatch(ctx context.Context, in *GetInclusionProofByHashBatchRequest, opts ...grpc.CallOption) (*GetInclusionProofByHashBatchResponse, error) {
+	out := new(GetInclusionProofByHashBatchResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianLog/GetInclusionProofByHashBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) AddLogRootSignature(ctx context.Context, in *AddLogRootSignatureRequest, opts ...grpc.CallOption) (*AddLogRootSignatureResponse, error) {
+	out := new(AddLogRootSignatureResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianLog/AddLogRootSignature", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetLogRootSignatures(ctx context.Context, in *GetLogRootSignaturesRequest, opts ...grpc.CallOption) (*GetLogRootSignaturesResponse, error) {
+	out := new(GetLogRootSignaturesResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianLog/GetLogRootSignatures", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetConsistencyProofByRootHash(ctx context.Context, in *GetConsistencyProofByRootHashRequest, opts ...grpc.CallOption) (*GetConsistencyProofByRootHashResponse, error) {
+	out := new(GetConsistencyProofByRootHashResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianLog/GetConsistencyProofByRootHash", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetCheckpoint(ctx context.Context, in *GetCheckpointRequest, opts ...grpc.CallOption) (*GetCheckpointResponse, error) {
+	out := new(GetCheckpointResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianLog/GetCheckpoint", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TrillianLogServer is the server API for TrillianLog service.
 type TrillianLogServer interface {
 	// QueueLeaf adds a single leaf to the queue of pending leaves for a normal
@@ -2214,15 +3360,52 @@ type TrillianLogServer interface {
 	// AddSequencedLeaves adds a batch of leaves with assigned sequence numbers
 	// to a pre-ordered log.  The indices of the provided leaves must be contiguous.
 	AddSequencedLeaves(context.Context, *AddSequencedLeavesRequest) (*AddSequencedLeavesResponse, error)
+	// AddSequencedLeavesStream imports leaves with assigned sequence numbers
+	// into a pre-ordered log from a stream of chunks, e.g. to migrate an
+	// existing append-only log. The indices of the leaves must be contiguous
+	// across the whole stream. The response is sent once the client closes the
+	// stream and, if requested, once the leaves have been integrated.
+	AddSequencedLeavesStream(TrillianLog_AddSequencedLeavesStreamServer) error
 	// GetLeavesByIndex returns a batch of leaves whose leaf indices are provided
 	// in the request.
 	GetLeavesByIndex(context.Context, *GetLeavesByIndexRequest) (*GetLeavesByIndexResponse, error)
 	// GetLeavesByRange returns a batch of leaves whose leaf indices are in a
 	// sequential range.
 	GetLeavesByRange(context.Context, *GetLeavesByRangeRequest) (*GetLeavesByRangeResponse, error)
+	// GetLeavesByRangeStream streams the leaves whose leaf indices are in a
+	// sequential range, in order, in chunks of up to chunk_size leaves. All the
+	// leaves are read at the same tree size, so that mirrors and auditors can
+	// read a large range without paging through it.
+	GetLeavesByRangeStream(*GetLeavesByRangeStreamRequest, TrillianLog_GetLeavesByRangeStreamServer) error
 	// GetLeavesByHash returns a batch of leaves which are identified by their
 	// Merkle leaf hash values.
 	GetLeavesByHash(context.Context, *GetLeavesByHashRequest) (*GetLeavesByHashResponse, error)
+	// GetInclusionProofByHashBatch returns inclusion proofs for the leaves with
+	// any of the given Merkle hashes, in a particular tree. All the proofs are
+	// read from one storage snapshot, and relate to the same log root.
+	//
+	// Hashes without a leaf in the requested tree size have no proofs, rather
+	// than failing the request.
+	GetInclusionProofByHashBatch(context.Context, *GetInclusionProofByHashBatchRequest) (*GetInclusionProofByHashBatchResponse, error)
+	// AddLogRootSignature stores the signature of a witness over the log root
+	// at a revision, once it has been verified with the witness's public key. A
+	// later signature with the same key replaces it. The log doesn't vouch for
+	// the witnesses: clients decide which keys they trust.
+	AddLogRootSignature(context.Context, *AddLogRootSignatureRequest) (*AddLogRootSignatureResponse, error)
+	// GetLogRootSignatures returns the log root at a revision, along with the
+	// witness signatures stored for it.
+	GetLogRootSignatures(context.Context, *GetLogRootSignaturesRequest) (*GetLogRootSignaturesResponse, error)
+	// GetConsistencyProofByRootHash returns a consistency proof between two
+	// roots of the log identified by their root hashes. Unlike
+	// GetConsistencyProof, it fails with NOT_FOUND unless both hashes are of
+	// roots the log has published, so that monitors can't be given proofs
+	// between tree states the log never committed to.
+	GetConsistencyProofByRootHash(context.Context, *GetConsistencyProofByRootHashRequest) (*GetConsistencyProofByRootHashResponse, error)
+	// GetCheckpoint returns the latest root of the log as a checkpoint: a note
+	// in the signed note format, signed by the log's key, as consumed by
+	// witnesses and other checkpoint-based tools. It returns Unimplemented
+	// unless the server is configured with a checkpoint origin prefix.
+	GetCheckpoint(context.Context, *GetCheckpointRequest) (*GetCheckpointResponse, error)
 }
 
 // UnimplementedTrillianLogServer can be embedded to have forward compatible implementations.
@@ -2262,15 +3445,36 @@ func (*UnimplementedTrillianLogServer) QueueLeaves(ctx context.Context, req *Que
 func (*UnimplementedTrillianLogServer) AddSequencedLeaves(ctx context.Context, req *AddSequencedLeavesRequest) (*AddSequencedLeavesResponse, error) {
 	return nil, status1.Errorf(codes.Unimplemented, "method AddSequencedLeaves not implemented")
 }
+func (*UnimplementedTrillianLogServer) AddSequencedLeavesStream(srv TrillianLog_AddSequencedLeavesStreamServer) error {
+	return status1.Errorf(codes.Unimplemented, "method AddSequencedLeavesStream not implemented")
+}
 func (*UnimplementedTrillianLogServer) GetLeavesByIndex(ctx context.Context, req *GetLeavesByIndexRequest) (*GetLeavesByIndexResponse, error) {
 	return nil, status1.Errorf(codes.Unimplemented, "method GetLeavesByIndex not implemented")
 }
 func (*UnimplementedTrillianLogServer) GetLeavesByRange(ctx context.Context, req *GetLeavesByRangeRequest) (*GetLeavesByRangeResponse, error) {
 	return nil, status1.Errorf(codes.Unimplemented, "method GetLeavesByRange not implemented")
 }
+func (*UnimplementedTrillianLogServer) GetLeavesByRangeStream(req *GetLeavesByRangeStreamRequest, srv TrillianLog_GetLeavesByRangeStreamServer) error {
+	return status1.Errorf(codes.Unimplemented, "method GetLeavesByRangeStream not implemented")
+}
 func (*UnimplementedTrillianLogServer) GetLeavesByHash(ctx context.Context, req *GetLeavesByHashRequest) (*GetLeavesByHashResponse, error) {
 	return nil, status1.Errorf(codes.Unimplemented, "method GetLeavesByHash not implemented")
 }
+func (*UnimplementedTrillianLogServer) GetInclusionProofByHashBatch(ctx context.Context, req *GetInclusionProofByHashBatchRequest) (*GetInclusionProofByHashBatchResponse, error) {
+	return nil, status1.Errorf(codes.Unimplemented, "method GetInclusionProofByHashBatch not implemented")
+}
+func (*UnimplementedTrillianLogServer) AddLogRootSignature(ctx context.Context, req *AddLogRootSignatureRequest) (*AddLogRootSignatureResponse, error) {
+	return nil, status1.Errorf(codes.Unimplemented, "method AddLogRootSignature not implemented")
+}
+func (*UnimplementedTrillianLogServer) GetLogRootSignatures(ctx context.Context, req *GetLogRootSignaturesRequest) (*GetLogRootSignaturesResponse, error) {
+	return nil, status1.Errorf(codes.Unimplemented, "method GetLogRootSignatures not implemented")
+}
+func (*UnimplementedTrillianLogServer) GetConsistencyProofByRootHash(ctx context.Context, req *GetConsistencyProofByRootHashRequest) (*GetConsistencyProofByRootHashResponse, error) {
+	return nil, status1.Errorf(codes.Unimplemented, "method GetConsistencyProofByRootHash not implemented")
+}
+func (*UnimplementedTrillianLogServer) GetCheckpoint(ctx context.Context, req *GetCheckpointRequest) (*GetCheckpointResponse, error) {
+	return nil, status1.Errorf(codes.Unimplemented, "method GetCheckpoint not implemented")
+}
 
 func RegisterTrillianLogServer(s *grpc.Server, srv TrillianLogServer) {
 	s.RegisterService(&_TrillianLog_serviceDesc, srv)
@@ -2474,6 +3678,32 @@ func _TrillianLog_AddSequencedLeaves_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianLog_AddSequencedLeavesStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TrillianLogServer).AddSequencedLeavesStream(&trillianLogAddSequencedLeavesStreamServer{stream})
+}
+
+type TrillianLog_AddSequencedLeavesStreamServer interface {
+	SendAndClose(*AddSequencedLeavesStreamResponse) error
+	Recv() (*AddSequencedLeavesStreamRequest, error)
+	grpc.ServerStream
+}
+
+type trillianLogAddSequencedLeavesStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *trillianLogAddSequencedLeavesStreamServer) SendAndClose(m *AddSequencedLeavesStreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *trillianLogAddSequencedLeavesStreamServer) Recv() (*AddSequencedLeavesStreamRequest, error) {
+	m := new(AddSequencedLeavesStreamRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _TrillianLog_GetLeavesByIndex_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetLeavesByIndexRequest)
 	if err := dec(in); err != nil {
@@ -2510,6 +3740,27 @@ func _TrillianLog_GetLeavesByRange_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianLog_GetLeavesByRangeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetLeavesByRangeStreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrillianLogServer).GetLeavesByRangeStream(m, &trillianLogGetLeavesByRangeStreamServer{stream})
+}
+
+type TrillianLog_GetLeavesByRangeStreamServer interface {
+	Send(*GetLeavesByRangeStreamResponse) error
+	grpc.ServerStream
+}
+
+type trillianLogGetLeavesByRangeStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *trillianLogGetLeavesByRangeStreamServer) Send(m *GetLeavesByRangeStreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _TrillianLog_GetLeavesByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetLeavesByHashRequest)
 	if err := dec(in); err != nil {
@@ -2528,6 +3779,96 @@ func _TrillianLog_GetLeavesByHash_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianLog_GetInclusionProofByHashBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInclusionProofByHashBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetInclusionProofByHashBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetInclusionProofByHashBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetInclusionProofByHashBatch(ctx, req.(*GetInclusionProofByHashBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_AddLogRootSignature_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddLogRootSignatureRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).AddLogRootSignature(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/AddLogRootSignature",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).AddLogRootSignature(ctx, req.(*AddLogRootSignatureRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetLogRootSignatures_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLogRootSignaturesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetLogRootSignatures(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetLogRootSignatures",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetLogRootSignatures(ctx, req.(*GetLogRootSignaturesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetConsistencyProofByRootHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConsistencyProofByRootHashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetConsistencyProofByRootHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetConsistencyProofByRootHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetConsistencyProofByRootHash(ctx, req.(*GetConsistencyProofByRootHashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetCheckpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCheckpointRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetCheckpoint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetCheckpoint",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetCheckpoint(ctx, req.(*GetCheckpointRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _TrillianLog_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "trillian.TrillianLog",
 	HandlerType: (*TrillianLogServer)(nil),
@@ -2588,7 +3929,38 @@ var _TrillianLog_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetLeavesByHash",
 			Handler:    _TrillianLog_GetLeavesByHash_Handler,
 		},
+		{
+			MethodName: "GetInclusionProofByHashBatch",
+			Handler:    _TrillianLog_GetInclusionProofByHashBatch_Handler,
+		},
+		{
+			MethodName: "AddLogRootSignature",
+			Handler:    _TrillianLog_AddLogRootSignature_Handler,
+		},
+		{
+			MethodName: "GetLogRootSignatures",
+			Handler:    _TrillianLog_GetLogRootSignatures_Handler,
+		},
+		{
+			MethodName: "GetConsistencyProofByRootHash",
+			Handler:    _TrillianLog_GetConsistencyProofByRootHash_Handler,
+		},
+		{
+			MethodName: "GetCheckpoint",
+			Handler:    _TrillianLog_GetCheckpoint_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "AddSequencedLeavesStream",
+			Handler:       _TrillianLog_AddSequencedLeavesStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GetLeavesByRangeStream",
+			Handler:       _TrillianLog_GetLeavesByRangeStream_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "trillian_log_api.proto",
 }
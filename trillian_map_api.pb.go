@@ -7,6 +7,7 @@ import (
 	context "context"
 	fmt "fmt"
 	proto "github.com/golang/protobuf/proto"
+	keyspb "github.com/google/trillian/crypto/keyspb"
 	_ "google.golang.org/genproto/googleapis/api/annotations"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
@@ -106,10 +107,14 @@ func (m *MapLeaf) GetExtraData() []byte {
 }
 
 type MapLeaves struct {
-	Leaves               []*MapLeaf `protobuf:"bytes,1,rep,name=leaves,proto3" json:"leaves,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
-	XXX_unrecognized     []byte     `json:"-"`
-	XXX_sizecache        int32      `json:"-"`
+	Leaves []*MapLeaf `protobuf:"bytes,1,rep,name=leaves,proto3" json:"leaves,omitempty"`
+	// The root of the revision which the leaves were read from, if requested
+	// with GetMapLeavesByRevisionRequest.include_map_root. Clients exporting
+	// leaves without proofs can later check a sample of them against it.
+	MapRoot              *SignedMapRoot `protobuf:"bytes,2,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
 }
 
 func (m *MapLeaves) Reset()         { *m = MapLeaves{} }
@@ -144,6 +149,13 @@ func (m *MapLeaves) GetLeaves() []*MapLeaf {
 	return nil
 }
 
+func (m *MapLeaves) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
+}
+
 type MapLeafInclusion struct {
 	Leaf *MapLeaf `protobuf:"bytes,1,opt,name=leaf,proto3" json:"leaf,omitempty"`
 	// inclusion holds the inclusion proof for this leaf in the map root. It
@@ -199,8 +211,18 @@ func (m *MapLeafInclusion) GetInclusion() [][]byte {
 }
 
 type GetMapLeavesRequest struct {
-	MapId                int64    `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
-	Index                [][]byte `protobuf:"bytes,2,rep,name=index,proto3" json:"index,omitempty"`
+	MapId int64    `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	Index [][]byte `protobuf:"bytes,2,rep,name=index,proto3" json:"index,omitempty"`
+	// max_response_bytes, if positive, bounds the size of the response. If the
+	// inclusion proofs of all the remaining indices do not fit, the response
+	// holds those of the first few of them, at least one, and a
+	// next_page_token.
+	MaxResponseBytes int32 `protobuf:"varint,4,opt,name=max_response_bytes,json=maxResponseBytes,proto3" json:"max_response_bytes,omitempty"`
+	// page_token, if set, must be a next_page_token returned by an earlier
+	// GetLeaves call with the same map_id and index. The response continues
+	// with the indices following those already returned, at the revision of
+	// the first response.
+	PageToken            string   `protobuf:"bytes,5,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -245,6 +267,20 @@ func (m *GetMapLeavesRequest) GetIndex() [][]byte {
 	return nil
 }
 
+func (m *GetMapLeavesRequest) GetMaxResponseBytes() int32 {
+	if m != nil {
+		return m.MaxResponseBytes
+	}
+	return 0
+}
+
+func (m *GetMapLeavesRequest) GetPageToken() string {
+	if m != nil {
+		return m.PageToken
+	}
+	return ""
+}
+
 type GetMapLeafRequest struct {
 	MapId                int64    `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
 	Index                []byte   `protobuf:"bytes,2,opt,name=index,proto3" json:"index,omitempty"`
@@ -354,7 +390,11 @@ type GetMapLeavesByRevisionRequest struct {
 	// index(es) to query.  It is an error to request the same index more than once.
 	Index [][]byte `protobuf:"bytes,2,rep,name=index,proto3" json:"index,omitempty"`
 	// revision >= 0.
-	Revision             int64    `protobuf:"varint,3,opt,name=revision,proto3" json:"revision,omitempty"`
+	Revision int64 `protobuf:"varint,3,opt,name=revision,proto3" json:"revision,omitempty"`
+	// If true, TrillianMapWrite.GetLeavesByRevision, which returns no inclusion
+	// proofs, also returns the root of the revision, which must have been
+	// published. TrillianMap.GetLeavesByRevision always returns the root.
+	IncludeMapRoot       bool     `protobuf:"varint,4,opt,name=include_map_root,json=includeMapRoot,proto3" json:"include_map_root,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -406,6 +446,13 @@ func (m *GetMapLeavesByRevisionRequest) GetRevision() int64 {
 	return 0
 }
 
+func (m *GetMapLeavesByRevisionRequest) GetIncludeMapRoot() bool {
+	if m != nil {
+		return m.IncludeMapRoot
+	}
+	return false
+}
+
 type GetMapLeafResponse struct {
 	MapLeafInclusion     *MapLeafInclusion `protobuf:"bytes,1,opt,name=map_leaf_inclusion,json=mapLeafInclusion,proto3" json:"map_leaf_inclusion,omitempty"`
 	MapRoot              *SignedMapRoot    `protobuf:"bytes,2,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
@@ -454,11 +501,15 @@ func (m *GetMapLeafResponse) GetMapRoot() *SignedMapRoot {
 }
 
 type GetMapLeavesResponse struct {
-	MapLeafInclusion     []*MapLeafInclusion `protobuf:"bytes,2,rep,name=map_leaf_inclusion,json=mapLeafInclusion,proto3" json:"map_leaf_inclusion,omitempty"`
-	MapRoot              *SignedMapRoot      `protobuf:"bytes,3,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
-	XXX_unrecognized     []byte              `json:"-"`
-	XXX_sizecache        int32               `json:"-"`
+	MapLeafInclusion []*MapLeafInclusion `protobuf:"bytes,2,rep,name=map_leaf_inclusion,json=mapLeafInclusion,proto3" json:"map_leaf_inclusion,omitempty"`
+	MapRoot          *SignedMapRoot      `protobuf:"bytes,3,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
+	// next_page_token is set if map_leaf_inclusion holds only some of the
+	// remaining indices of the request. It can be passed as the page_token of
+	// another request to fetch the others at the same revision.
+	NextPageToken        string   `protobuf:"bytes,4,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *GetMapLeavesResponse) Reset()         { *m = GetMapLeavesResponse{} }
@@ -500,416 +551,2409 @@ func (m *GetMapLeavesResponse) GetMapRoot() *SignedMapRoot {
 	return nil
 }
 
-// GetLastInRangeByRevisionRequest specifies a range in the map at a revision.
-// The range is defined as the entire subtree below a particular point in the
-// Merkle tree. Another way of saying this is that the range matches all leaves
-// that share a common prefix of `prefix_bits` with `prefix`.
-type GetLastInRangeByRevisionRequest struct {
-	MapId    int64  `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
-	Revision int64  `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
-	Prefix   []byte `protobuf:"bytes,3,opt,name=prefix,proto3" json:"prefix,omitempty"`
-	// prefix_bits is the number of bits to include, starting from the left, or
-	// most significant bit (MSB).
-	PrefixBits           int32    `protobuf:"varint,4,opt,name=prefix_bits,json=prefixBits,proto3" json:"prefix_bits,omitempty"`
+func (m *GetMapLeavesResponse) GetNextPageToken() string {
+	if m != nil {
+		return m.NextPageToken
+	}
+	return ""
+}
+
+// GetMapLeafHistoryRequest asks for the values of a single leaf at each of a
+// range of consecutive revisions.
+type GetMapLeafHistoryRequest struct {
+	MapId int64  `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	Index []byte `protobuf:"bytes,2,opt,name=index,proto3" json:"index,omitempty"`
+	// start_revision >= 0 is the first revision to return.
+	StartRevision int64 `protobuf:"varint,3,opt,name=start_revision,json=startRevision,proto3" json:"start_revision,omitempty"`
+	// count is the maximum number of revisions to return. If zero, a
+	// server-chosen default is used. Values larger than the server's limit are
+	// capped to that limit.
+	Count                int32    `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *GetLastInRangeByRevisionRequest) Reset()         { *m = GetLastInRangeByRevisionRequest{} }
-func (m *GetLastInRangeByRevisionRequest) String() string { return proto.CompactTextString(m) }
-func (*GetLastInRangeByRevisionRequest) ProtoMessage()    {}
-func (*GetLastInRangeByRevisionRequest) Descriptor() ([]byte, []int) {
+func (m *GetMapLeafHistoryRequest) Reset()         { *m = GetMapLeafHistoryRequest{} }
+func (m *GetMapLeafHistoryRequest) String() string { return proto.CompactTextString(m) }
+func (*GetMapLeafHistoryRequest) ProtoMessage()    {}
+func (*GetMapLeafHistoryRequest) Descriptor() ([]byte, []int) {
 	return fileDescriptor_28d34dfba22a7ce2, []int{9}
 }
 
-func (m *GetLastInRangeByRevisionRequest) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_GetLastInRangeByRevisionRequest.Unmarshal(m, b)
+func (m *GetMapLeafHistoryRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMapLeafHistoryRequest.Unmarshal(m, b)
 }
-func (m *GetLastInRangeByRevisionRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_GetLastInRangeByRevisionRequest.Marshal(b, m, deterministic)
+func (m *GetMapLeafHistoryRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMapLeafHistoryRequest.Marshal(b, m, deterministic)
 }
-func (m *GetLastInRangeByRevisionRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_GetLastInRangeByRevisionRequest.Merge(m, src)
+func (m *GetMapLeafHistoryRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMapLeafHistoryRequest.Merge(m, src)
 }
-func (m *GetLastInRangeByRevisionRequest) XXX_Size() int {
-	return xxx_messageInfo_GetLastInRangeByRevisionRequest.Size(m)
+func (m *GetMapLeafHistoryRequest) XXX_Size() int {
+	return xxx_messageInfo_GetMapLeafHistoryRequest.Size(m)
 }
-func (m *GetLastInRangeByRevisionRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_GetLastInRangeByRevisionRequest.DiscardUnknown(m)
+func (m *GetMapLeafHistoryRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMapLeafHistoryRequest.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_GetLastInRangeByRevisionRequest proto.InternalMessageInfo
+var xxx_messageInfo_GetMapLeafHistoryRequest proto.InternalMessageInfo
 
-func (m *GetLastInRangeByRevisionRequest) GetMapId() int64 {
+func (m *GetMapLeafHistoryRequest) GetMapId() int64 {
 	if m != nil {
 		return m.MapId
 	}
 	return 0
 }
 
-func (m *GetLastInRangeByRevisionRequest) GetRevision() int64 {
+func (m *GetMapLeafHistoryRequest) GetIndex() []byte {
 	if m != nil {
-		return m.Revision
+		return m.Index
 	}
-	return 0
+	return nil
 }
 
-func (m *GetLastInRangeByRevisionRequest) GetPrefix() []byte {
+func (m *GetMapLeafHistoryRequest) GetStartRevision() int64 {
 	if m != nil {
-		return m.Prefix
+		return m.StartRevision
 	}
-	return nil
+	return 0
 }
 
-func (m *GetLastInRangeByRevisionRequest) GetPrefixBits() int32 {
+func (m *GetMapLeafHistoryRequest) GetCount() int32 {
 	if m != nil {
-		return m.PrefixBits
+		return m.Count
 	}
 	return 0
 }
 
-type SetMapLeavesRequest struct {
+type GetMapLeafHistoryResponse struct {
+	// leaves holds the leaf and its inclusion proof at each revision from
+	// start_revision onwards, together with the root of that revision, in
+	// ascending revision order. It stops at the latest revision of the map, so
+	// it is empty if start_revision does not exist yet.
+	Leaves               []*GetMapLeafResponse `protobuf:"bytes,1,rep,name=leaves,proto3" json:"leaves,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}              `json:"-"`
+	XXX_unrecognized     []byte                `json:"-"`
+	XXX_sizecache        int32                 `json:"-"`
+}
+
+func (m *GetMapLeafHistoryResponse) Reset()         { *m = GetMapLeafHistoryResponse{} }
+func (m *GetMapLeafHistoryResponse) String() string { return proto.CompactTextString(m) }
+func (*GetMapLeafHistoryResponse) ProtoMessage()    {}
+func (*GetMapLeafHistoryResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{10}
+}
+
+func (m *GetMapLeafHistoryResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMapLeafHistoryResponse.Unmarshal(m, b)
+}
+func (m *GetMapLeafHistoryResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMapLeafHistoryResponse.Marshal(b, m, deterministic)
+}
+func (m *GetMapLeafHistoryResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMapLeafHistoryResponse.Merge(m, src)
+}
+func (m *GetMapLeafHistoryResponse) XXX_Size() int {
+	return xxx_messageInfo_GetMapLeafHistoryResponse.Size(m)
+}
+func (m *GetMapLeafHistoryResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMapLeafHistoryResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetMapLeafHistoryResponse proto.InternalMessageInfo
+
+func (m *GetMapLeafHistoryResponse) GetLeaves() []*GetMapLeafResponse {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+// GetMapLeafByHashRequest asks for the leaves of a map which have a leaf hash.
+type GetMapLeafByHashRequest struct {
 	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
-	// The leaves being set must have unique Index values within the request.
-	Leaves   []*MapLeaf `protobuf:"bytes,2,rep,name=leaves,proto3" json:"leaves,omitempty"`
-	Metadata []byte     `protobuf:"bytes,5,opt,name=metadata,proto3" json:"metadata,omitempty"`
-	// The map revision to associate the leaves with. The request will fail if
-	// this revision already exists, does not match the current write revision, or
-	// is negative. If revision = 0 then the leaves will be written to the current
-	// write revision.
-	Revision             int64    `protobuf:"varint,6,opt,name=revision,proto3" json:"revision,omitempty"`
+	// leaf_hash is the MapLeaf.leaf_hash to look up.
+	LeafHash []byte `protobuf:"bytes,2,opt,name=leaf_hash,json=leafHash,proto3" json:"leaf_hash,omitempty"`
+	// revision is the revision to look up leaf_hash at. If zero, the latest
+	// revision is used, as revision 0 of a map holds no leaves.
+	Revision             int64    `protobuf:"varint,3,opt,name=revision,proto3" json:"revision,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *SetMapLeavesRequest) Reset()         { *m = SetMapLeavesRequest{} }
-func (m *SetMapLeavesRequest) String() string { return proto.CompactTextString(m) }
-func (*SetMapLeavesRequest) ProtoMessage()    {}
-func (*SetMapLeavesRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_28d34dfba22a7ce2, []int{10}
+func (m *GetMapLeafByHashRequest) Reset()         { *m = GetMapLeafByHashRequest{} }
+func (m *GetMapLeafByHashRequest) String() string { return proto.CompactTextString(m) }
+func (*GetMapLeafByHashRequest) ProtoMessage()    {}
+func (*GetMapLeafByHashRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{11}
 }
 
-func (m *SetMapLeavesRequest) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_SetMapLeavesRequest.Unmarshal(m, b)
+func (m *GetMapLeafByHashRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMapLeafByHashRequest.Unmarshal(m, b)
 }
-func (m *SetMapLeavesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_SetMapLeavesRequest.Marshal(b, m, deterministic)
+func (m *GetMapLeafByHashRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMapLeafByHashRequest.Marshal(b, m, deterministic)
 }
-func (m *SetMapLeavesRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_SetMapLeavesRequest.Merge(m, src)
+func (m *GetMapLeafByHashRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMapLeafByHashRequest.Merge(m, src)
 }
-func (m *SetMapLeavesRequest) XXX_Size() int {
-	return xxx_messageInfo_SetMapLeavesRequest.Size(m)
+func (m *GetMapLeafByHashRequest) XXX_Size() int {
+	return xxx_messageInfo_GetMapLeafByHashRequest.Size(m)
 }
-func (m *SetMapLeavesRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_SetMapLeavesRequest.DiscardUnknown(m)
+func (m *GetMapLeafByHashRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMapLeafByHashRequest.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_SetMapLeavesRequest proto.InternalMessageInfo
+var xxx_messageInfo_GetMapLeafByHashRequest proto.InternalMessageInfo
 
-func (m *SetMapLeavesRequest) GetMapId() int64 {
+func (m *GetMapLeafByHashRequest) GetMapId() int64 {
 	if m != nil {
 		return m.MapId
 	}
 	return 0
 }
 
-func (m *SetMapLeavesRequest) GetLeaves() []*MapLeaf {
-	if m != nil {
-		return m.Leaves
-	}
-	return nil
-}
-
-func (m *SetMapLeavesRequest) GetMetadata() []byte {
+func (m *GetMapLeafByHashRequest) GetLeafHash() []byte {
 	if m != nil {
-		return m.Metadata
+		return m.LeafHash
 	}
 	return nil
 }
 
-func (m *SetMapLeavesRequest) GetRevision() int64 {
+func (m *GetMapLeafByHashRequest) GetRevision() int64 {
 	if m != nil {
 		return m.Revision
 	}
 	return 0
 }
 
-type SetMapLeavesResponse struct {
-	MapRoot              *SignedMapRoot `protobuf:"bytes,2,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
-	XXX_unrecognized     []byte         `json:"-"`
-	XXX_sizecache        int32          `json:"-"`
+// GetMapConsistencyProofRequest asks for a proof that second_revision of a map
+// differs from first_revision only at the given indices.
+type GetMapConsistencyProofRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	// first_revision >= 0.
+	FirstRevision int64 `protobuf:"varint,2,opt,name=first_revision,json=firstRevision,proto3" json:"first_revision,omitempty"`
+	// second_revision >= first_revision.
+	SecondRevision int64 `protobuf:"varint,3,opt,name=second_revision,json=secondRevision,proto3" json:"second_revision,omitempty"`
+	// index holds the indices of the leaves claimed to have changed between the
+	// two revisions.
+	Index                [][]byte `protobuf:"bytes,4,rep,name=index,proto3" json:"index,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *SetMapLeavesResponse) Reset()         { *m = SetMapLeavesResponse{} }
-func (m *SetMapLeavesResponse) String() string { return proto.CompactTextString(m) }
-func (*SetMapLeavesResponse) ProtoMessage()    {}
-func (*SetMapLeavesResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_28d34dfba22a7ce2, []int{11}
+func (m *GetMapConsistencyProofRequest) Reset()         { *m = GetMapConsistencyProofRequest{} }
+func (m *GetMapConsistencyProofRequest) String() string { return proto.CompactTextString(m) }
+func (*GetMapConsistencyProofRequest) ProtoMessage()    {}
+func (*GetMapConsistencyProofRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{12}
 }
 
-func (m *SetMapLeavesResponse) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_SetMapLeavesResponse.Unmarshal(m, b)
+func (m *GetMapConsistencyProofRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMapConsistencyProofRequest.Unmarshal(m, b)
 }
-func (m *SetMapLeavesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_SetMapLeavesResponse.Marshal(b, m, deterministic)
+func (m *GetMapConsistencyProofRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMapConsistencyProofRequest.Marshal(b, m, deterministic)
 }
-func (m *SetMapLeavesResponse) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_SetMapLeavesResponse.Merge(m, src)
+func (m *GetMapConsistencyProofRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMapConsistencyProofRequest.Merge(m, src)
 }
-func (m *SetMapLeavesResponse) XXX_Size() int {
-	return xxx_messageInfo_SetMapLeavesResponse.Size(m)
+func (m *GetMapConsistencyProofRequest) XXX_Size() int {
+	return xxx_messageInfo_GetMapConsistencyProofRequest.Size(m)
 }
-func (m *SetMapLeavesResponse) XXX_DiscardUnknown() {
-	xxx_messageInfo_SetMapLeavesResponse.DiscardUnknown(m)
+func (m *GetMapConsistencyProofRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMapConsistencyProofRequest.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_SetMapLeavesResponse proto.InternalMessageInfo
+var xxx_messageInfo_GetMapConsistencyProofRequest proto.InternalMessageInfo
 
-func (m *SetMapLeavesResponse) GetMapRoot() *SignedMapRoot {
+func (m *GetMapConsistencyProofRequest) GetMapId() int64 {
 	if m != nil {
-		return m.MapRoot
+		return m.MapId
+	}
+	return 0
+}
+
+func (m *GetMapConsistencyProofRequest) GetFirstRevision() int64 {
+	if m != nil {
+		return m.FirstRevision
+	}
+	return 0
+}
+
+func (m *GetMapConsistencyProofRequest) GetSecondRevision() int64 {
+	if m != nil {
+		return m.SecondRevision
+	}
+	return 0
+}
+
+func (m *GetMapConsistencyProofRequest) GetIndex() [][]byte {
+	if m != nil {
+		return m.Index
 	}
 	return nil
 }
 
-type WriteMapLeavesRequest struct {
-	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
-	// The leaves being set must have unique Index values within the request.
-	Leaves []*MapLeaf `protobuf:"bytes,2,rep,name=leaves,proto3" json:"leaves,omitempty"`
-	// Metadata that the Map should associate with the new Map root after
-	// incorporating the leaf changes.  The metadata will be reflected in the
-	// Map Root published for this revision.
-	// Map personalities should use metadata to persist any state needed later
-	// to continue mapping from an external data source.
-	Metadata []byte `protobuf:"bytes,3,opt,name=metadata,proto3" json:"metadata,omitempty"`
-	// The map revision to associate the leaves with. The request will fail if
-	// this revision already exists, does not match the current write revision, or
-	// is negative. If revision = 0 then the leaves will be written to the current
-	// write revision.
-	ExpectRevision       int64    `protobuf:"varint,4,opt,name=expect_revision,json=expectRevision,proto3" json:"expect_revision,omitempty"`
+type GetMapConsistencyProofResponse struct {
+	FirstMapRoot  *SignedMapRoot `protobuf:"bytes,1,opt,name=first_map_root,json=firstMapRoot,proto3" json:"first_map_root,omitempty"`
+	SecondMapRoot *SignedMapRoot `protobuf:"bytes,2,opt,name=second_map_root,json=secondMapRoot,proto3" json:"second_map_root,omitempty"`
+	// first_leaves and second_leaves hold the leaves at each requested index at
+	// the two revisions, in the order of the request. Indices with no leaf at a
+	// revision have a leaf with only its index set.
+	FirstLeaves  []*MapLeaf `protobuf:"bytes,3,rep,name=first_leaves,json=firstLeaves,proto3" json:"first_leaves,omitempty"`
+	SecondLeaves []*MapLeaf `protobuf:"bytes,4,rep,name=second_leaves,json=secondLeaves,proto3" json:"second_leaves,omitempty"`
+	// proof holds the hashes of the largest subtrees which contain none of the
+	// requested indices, at second_revision, in ascending index order. Empty
+	// subtrees have an empty hash. If the leaves outside of the requested
+	// indices did not change, the same hashes also make up first_revision, and
+	// both roots can be computed from them and the leaves.
+	Proof                [][]byte `protobuf:"bytes,5,rep,name=proof,proto3" json:"proof,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *WriteMapLeavesRequest) Reset()         { *m = WriteMapLeavesRequest{} }
-func (m *WriteMapLeavesRequest) String() string { return proto.CompactTextString(m) }
-func (*WriteMapLeavesRequest) ProtoMessage()    {}
-func (*WriteMapLeavesRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_28d34dfba22a7ce2, []int{12}
+func (m *GetMapConsistencyProofResponse) Reset()         { *m = GetMapConsistencyProofResponse{} }
+func (m *GetMapConsistencyProofResponse) String() string { return proto.CompactTextString(m) }
+func (*GetMapConsistencyProofResponse) ProtoMessage()    {}
+func (*GetMapConsistencyProofResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{13}
 }
 
-func (m *WriteMapLeavesRequest) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_WriteMapLeavesRequest.Unmarshal(m, b)
+func (m *GetMapConsistencyProofResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMapConsistencyProofResponse.Unmarshal(m, b)
 }
-func (m *WriteMapLeavesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_WriteMapLeavesRequest.Marshal(b, m, deterministic)
+func (m *GetMapConsistencyProofResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMapConsistencyProofResponse.Marshal(b, m, deterministic)
 }
-func (m *WriteMapLeavesRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_WriteMapLeavesRequest.Merge(m, src)
+func (m *GetMapConsistencyProofResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMapConsistencyProofResponse.Merge(m, src)
 }
-func (m *WriteMapLeavesRequest) XXX_Size() int {
-	return xxx_messageInfo_WriteMapLeavesRequest.Size(m)
+func (m *GetMapConsistencyProofResponse) XXX_Size() int {
+	return xxx_messageInfo_GetMapConsistencyProofResponse.Size(m)
 }
-func (m *WriteMapLeavesRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_WriteMapLeavesRequest.DiscardUnknown(m)
+func (m *GetMapConsistencyProofResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMapConsistencyProofResponse.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_WriteMapLeavesRequest proto.InternalMessageInfo
+var xxx_messageInfo_GetMapConsistencyProofResponse proto.InternalMessageInfo
 
-func (m *WriteMapLeavesRequest) GetMapId() int64 {
+func (m *GetMapConsistencyProofResponse) GetFirstMapRoot() *SignedMapRoot {
 	if m != nil {
-		return m.MapId
+		return m.FirstMapRoot
 	}
-	return 0
+	return nil
 }
 
-func (m *WriteMapLeavesRequest) GetLeaves() []*MapLeaf {
+func (m *GetMapConsistencyProofResponse) GetSecondMapRoot() *SignedMapRoot {
 	if m != nil {
-		return m.Leaves
+		return m.SecondMapRoot
 	}
 	return nil
 }
 
-func (m *WriteMapLeavesRequest) GetMetadata() []byte {
+func (m *GetMapConsistencyProofResponse) GetFirstLeaves() []*MapLeaf {
 	if m != nil {
-		return m.Metadata
+		return m.FirstLeaves
 	}
 	return nil
 }
 
-func (m *WriteMapLeavesRequest) GetExpectRevision() int64 {
+func (m *GetMapConsistencyProofResponse) GetSecondLeaves() []*MapLeaf {
 	if m != nil {
-		return m.ExpectRevision
+		return m.SecondLeaves
 	}
-	return 0
+	return nil
 }
 
-type WriteMapLeavesResponse struct {
-	// The map revision that the leaves will be published at.
-	// This may be accompanied by a proof that the write request has been included
-	// in an input log in the future.
-	Revision             int64    `protobuf:"varint,1,opt,name=revision,proto3" json:"revision,omitempty"`
+func (m *GetMapConsistencyProofResponse) GetProof() [][]byte {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
+}
+
+// GetMapLeavesByIndexPrefixRequest asks for all the leaves of a map whose
+// indices start with a prefix, e.g. those of a shard of the index space.
+type GetMapLeavesByIndexPrefixRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	// The prefix of the indices of the leaves to return. It must be non-empty
+	// and shorter than an index.
+	IndexPrefix []byte `protobuf:"bytes,2,opt,name=index_prefix,json=indexPrefix,proto3" json:"index_prefix,omitempty"`
+	// revision >= 0.
+	Revision             int64    `protobuf:"varint,3,opt,name=revision,proto3" json:"revision,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *WriteMapLeavesResponse) Reset()         { *m = WriteMapLeavesResponse{} }
-func (m *WriteMapLeavesResponse) String() string { return proto.CompactTextString(m) }
-func (*WriteMapLeavesResponse) ProtoMessage()    {}
-func (*WriteMapLeavesResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_28d34dfba22a7ce2, []int{13}
+func (m *GetMapLeavesByIndexPrefixRequest) Reset()         { *m = GetMapLeavesByIndexPrefixRequest{} }
+func (m *GetMapLeavesByIndexPrefixRequest) String() string { return proto.CompactTextString(m) }
+func (*GetMapLeavesByIndexPrefixRequest) ProtoMessage()    {}
+func (*GetMapLeavesByIndexPrefixRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{14}
 }
 
-func (m *WriteMapLeavesResponse) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_WriteMapLeavesResponse.Unmarshal(m, b)
+func (m *GetMapLeavesByIndexPrefixRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMapLeavesByIndexPrefixRequest.Unmarshal(m, b)
 }
-func (m *WriteMapLeavesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_WriteMapLeavesResponse.Marshal(b, m, deterministic)
+func (m *GetMapLeavesByIndexPrefixRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMapLeavesByIndexPrefixRequest.Marshal(b, m, deterministic)
 }
-func (m *WriteMapLeavesResponse) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_WriteMapLeavesResponse.Merge(m, src)
+func (m *GetMapLeavesByIndexPrefixRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMapLeavesByIndexPrefixRequest.Merge(m, src)
 }
-func (m *WriteMapLeavesResponse) XXX_Size() int {
-	return xxx_messageInfo_WriteMapLeavesResponse.Size(m)
+func (m *GetMapLeavesByIndexPrefixRequest) XXX_Size() int {
+	return xxx_messageInfo_GetMapLeavesByIndexPrefixRequest.Size(m)
 }
-func (m *WriteMapLeavesResponse) XXX_DiscardUnknown() {
-	xxx_messageInfo_WriteMapLeavesResponse.DiscardUnknown(m)
+func (m *GetMapLeavesByIndexPrefixRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMapLeavesByIndexPrefixRequest.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_WriteMapLeavesResponse proto.InternalMessageInfo
+var xxx_messageInfo_GetMapLeavesByIndexPrefixRequest proto.InternalMessageInfo
 
-func (m *WriteMapLeavesResponse) GetRevision() int64 {
+func (m *GetMapLeavesByIndexPrefixRequest) GetMapId() int64 {
 	if m != nil {
-		return m.Revision
+		return m.MapId
 	}
 	return 0
 }
 
-type GetSignedMapRootRequest struct {
-	MapId                int64    `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+func (m *GetMapLeavesByIndexPrefixRequest) GetIndexPrefix() []byte {
+	if m != nil {
+		return m.IndexPrefix
+	}
+	return nil
+}
+
+func (m *GetMapLeavesByIndexPrefixRequest) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type GetMapLeavesByIndexPrefixResponse struct {
+	MapRoot *SignedMapRoot `protobuf:"bytes,1,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
+	// leaves holds the leaves stored under index_prefix at revision, in
+	// ascending index order. Leaves which were cleared have an empty
+	// leaf_value, and are included as the proof covers them.
+	Leaves []*MapLeaf `protobuf:"bytes,2,rep,name=leaves,proto3" json:"leaves,omitempty"`
+	// proof holds the hashes of the largest subtrees which contain none of the
+	// indices of leaves, in ascending index order, as in
+	// GetMapConsistencyProofResponse.proof. If there are no leaves, the proof
+	// is for the first index under index_prefix instead. All the subtrees under
+	// index_prefix are empty, which proves that no leaf was omitted.
+	Proof                [][]byte `protobuf:"bytes,3,rep,name=proof,proto3" json:"proof,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetMapLeavesByIndexPrefixResponse) Reset()         { *m = GetMapLeavesByIndexPrefixResponse{} }
+func (m *GetMapLeavesByIndexPrefixResponse) String() string { return proto.CompactTextString(m) }
+func (*GetMapLeavesByIndexPrefixResponse) ProtoMessage()    {}
+func (*GetMapLeavesByIndexPrefixResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{15}
+}
+
+func (m *GetMapLeavesByIndexPrefixResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMapLeavesByIndexPrefixResponse.Unmarshal(m, b)
+}
+func (m *GetMapLeavesByIndexPrefixResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMapLeavesByIndexPrefixResponse.Marshal(b, m, deterministic)
+}
+func (m *GetMapLeavesByIndexPrefixResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMapLeavesByIndexPrefixResponse.Merge(m, src)
+}
+func (m *GetMapLeavesByIndexPrefixResponse) XXX_Size() int {
+	return xxx_messageInfo_GetMapLeavesByIndexPrefixResponse.Size(m)
+}
+func (m *GetMapLeavesByIndexPrefixResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMapLeavesByIndexPrefixResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetMapLeavesByIndexPrefixResponse proto.InternalMessageInfo
+
+func (m *GetMapLeavesByIndexPrefixResponse) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
+}
+
+func (m *GetMapLeavesByIndexPrefixResponse) GetLeaves() []*MapLeaf {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+func (m *GetMapLeavesByIndexPrefixResponse) GetProof() [][]byte {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
+}
+
+// GetLastInRangeByRevisionRequest specifies a range in the map at a revision.
+// The range is defined as the entire subtree below a particular point in the
+// Merkle tree. Another way of saying this is that the range matches all leaves
+// that share a common prefix of `prefix_bits` with `prefix`.
+type GetLastInRangeByRevisionRequest struct {
+	MapId    int64  `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	Revision int64  `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	Prefix   []byte `protobuf:"bytes,3,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	// prefix_bits is the number of bits to include, starting from the left, or
+	// most significant bit (MSB).
+	PrefixBits           int32    `protobuf:"varint,4,opt,name=prefix_bits,json=prefixBits,proto3" json:"prefix_bits,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetLastInRangeByRevisionRequest) Reset()         { *m = GetLastInRangeByRevisionRequest{} }
+func (m *GetLastInRangeByRevisionRequest) String() string { return proto.CompactTextString(m) }
+func (*GetLastInRangeByRevisionRequest) ProtoMessage()    {}
+func (*GetLastInRangeByRevisionRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{16}
+}
+
+func (m *GetLastInRangeByRevisionRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetLastInRangeByRevisionRequest.Unmarshal(m, b)
+}
+func (m *GetLastInRangeByRevisionRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetLastInRangeByRevisionRequest.Marshal(b, m, deterministic)
+}
+func (m *GetLastInRangeByRevisionRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetLastInRangeByRevisionRequest.Merge(m, src)
+}
+func (m *GetLastInRangeByRevisionRequest) XXX_Size() int {
+	return xxx_messageInfo_GetLastInRangeByRevisionRequest.Size(m)
+}
+func (m *GetLastInRangeByRevisionRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetLastInRangeByRevisionRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetLastInRangeByRevisionRequest proto.InternalMessageInfo
+
+func (m *GetLastInRangeByRevisionRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
+	}
+	return 0
+}
+
+func (m *GetLastInRangeByRevisionRequest) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+func (m *GetLastInRangeByRevisionRequest) GetPrefix() []byte {
+	if m != nil {
+		return m.Prefix
+	}
+	return nil
+}
+
+func (m *GetLastInRangeByRevisionRequest) GetPrefixBits() int32 {
+	if m != nil {
+		return m.PrefixBits
+	}
+	return 0
+}
+
+// ListMapLeavesByRevisionRequest asks for all the populated leaves of a map at
+// a given revision, in ascending index order.
+type ListMapLeavesByRevisionRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	// revision >= 0.
+	Revision int64 `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	// page_size is the maximum number of leaves in each streamed response.
+	// If zero, a server-chosen default is used. Values larger than the server's
+	// limit are capped to that limit.
+	PageSize int32 `protobuf:"varint,3,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// page_token, if set, must be a next_page_token returned by an earlier
+	// ListLeavesByRevision call for the same map and revision. The listing
+	// resumes with the first leaf following that token.
+	PageToken            string   `protobuf:"bytes,4,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListMapLeavesByRevisionRequest) Reset()         { *m = ListMapLeavesByRevisionRequest{} }
+func (m *ListMapLeavesByRevisionRequest) String() string { return proto.CompactTextString(m) }
+func (*ListMapLeavesByRevisionRequest) ProtoMessage()    {}
+func (*ListMapLeavesByRevisionRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{17}
+}
+
+func (m *ListMapLeavesByRevisionRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListMapLeavesByRevisionRequest.Unmarshal(m, b)
+}
+func (m *ListMapLeavesByRevisionRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListMapLeavesByRevisionRequest.Marshal(b, m, deterministic)
+}
+func (m *ListMapLeavesByRevisionRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListMapLeavesByRevisionRequest.Merge(m, src)
+}
+func (m *ListMapLeavesByRevisionRequest) XXX_Size() int {
+	return xxx_messageInfo_ListMapLeavesByRevisionRequest.Size(m)
+}
+func (m *ListMapLeavesByRevisionRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListMapLeavesByRevisionRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListMapLeavesByRevisionRequest proto.InternalMessageInfo
+
+func (m *ListMapLeavesByRevisionRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
+	}
+	return 0
+}
+
+func (m *ListMapLeavesByRevisionRequest) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+func (m *ListMapLeavesByRevisionRequest) GetPageSize() int32 {
+	if m != nil {
+		return m.PageSize
+	}
+	return 0
+}
+
+func (m *ListMapLeavesByRevisionRequest) GetPageToken() string {
+	if m != nil {
+		return m.PageToken
+	}
+	return ""
+}
+
+type ListMapLeavesByRevisionResponse struct {
+	// leaves holds the next page of leaves, in ascending index order.
+	Leaves []*MapLeaf `protobuf:"bytes,1,rep,name=leaves,proto3" json:"leaves,omitempty"`
+	// next_page_token can be used to resume the listing after the last leaf in
+	// this response. It is empty when there are no more leaves.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	// map_root is the root of the revision being listed. It is only set in the
+	// first response of each stream.
+	MapRoot              *SignedMapRoot `protobuf:"bytes,3,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *ListMapLeavesByRevisionResponse) Reset()         { *m = ListMapLeavesByRevisionResponse{} }
+func (m *ListMapLeavesByRevisionResponse) String() string { return proto.CompactTextString(m) }
+func (*ListMapLeavesByRevisionResponse) ProtoMessage()    {}
+func (*ListMapLeavesByRevisionResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{18}
+}
+
+func (m *ListMapLeavesByRevisionResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListMapLeavesByRevisionResponse.Unmarshal(m, b)
+}
+func (m *ListMapLeavesByRevisionResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListMapLeavesByRevisionResponse.Marshal(b, m, deterministic)
+}
+func (m *ListMapLeavesByRevisionResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListMapLeavesByRevisionResponse.Merge(m, src)
+}
+func (m *ListMapLeavesByRevisionResponse) XXX_Size() int {
+	return xxx_messageInfo_ListMapLeavesByRevisionResponse.Size(m)
+}
+func (m *ListMapLeavesByRevisionResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListMapLeavesByRevisionResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListMapLeavesByRevisionResponse proto.InternalMessageInfo
+
+func (m *ListMapLeavesByRevisionResponse) GetLeaves() []*MapLeaf {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+func (m *ListMapLeavesByRevisionResponse) GetNextPageToken() string {
+	if m != nil {
+		return m.NextPageToken
+	}
+	return ""
+}
+
+func (m *ListMapLeavesByRevisionResponse) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
+}
+
+type SetMapLeavesRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	// The leaves being set must have unique Index values within the request.
+	Leaves   []*MapLeaf `protobuf:"bytes,2,rep,name=leaves,proto3" json:"leaves,omitempty"`
+	Metadata []byte     `protobuf:"bytes,5,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	// The map revision to associate the leaves with. The request will fail if
+	// this revision already exists, does not match the current write revision, or
+	// is negative. If revision = 0 then the leaves will be written to the current
+	// write revision.
+	Revision int64 `protobuf:"varint,6,opt,name=revision,proto3" json:"revision,omitempty"`
+	// An optional token, at most 255 bytes long, identifying this write. If a
+	// previous write with the same token succeeded, the request is not applied
+	// again, and the root of the revision it created is returned instead.
+	// Clients should set a unique token per logical write to be able to retry
+	// it safely after a network failure. Tokens are retained as long as the
+	// revision they created.
+	IdempotencyToken []byte `protobuf:"bytes,7,opt,name=idempotency_token,json=idempotencyToken,proto3" json:"idempotency_token,omitempty"`
+	// If set, the root which the write would produce is computed and returned,
+	// but nothing is written. Not supported by SetMultiMapLeaves.
+	DryRun bool `protobuf:"varint,8,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	// The lease token returned by ReserveRevision, which allows the leaves to
+	// be written at the reserved revision while the lease lasts. Writes at a
+	// reserved revision without its token fail.
+	RevisionLease        []byte   `protobuf:"bytes,9,opt,name=revision_lease,json=revisionLease,proto3" json:"revision_lease,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetMapLeavesRequest) Reset()         { *m = SetMapLeavesRequest{} }
+func (m *SetMapLeavesRequest) String() string { return proto.CompactTextString(m) }
+func (*SetMapLeavesRequest) ProtoMessage()    {}
+func (*SetMapLeavesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{19}
+}
+
+func (m *SetMapLeavesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetMapLeavesRequest.Unmarshal(m, b)
+}
+func (m *SetMapLeavesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetMapLeavesRequest.Marshal(b, m, deterministic)
+}
+func (m *SetMapLeavesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetMapLeavesRequest.Merge(m, src)
+}
+func (m *SetMapLeavesRequest) XXX_Size() int {
+	return xxx_messageInfo_SetMapLeavesRequest.Size(m)
+}
+func (m *SetMapLeavesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetMapLeavesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetMapLeavesRequest proto.InternalMessageInfo
+
+func (m *SetMapLeavesRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
+	}
+	return 0
+}
+
+func (m *SetMapLeavesRequest) GetLeaves() []*MapLeaf {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+func (m *SetMapLeavesRequest) GetMetadata() []byte {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *SetMapLeavesRequest) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+func (m *SetMapLeavesRequest) GetIdempotencyToken() []byte {
+	if m != nil {
+		return m.IdempotencyToken
+	}
+	return nil
+}
+
+func (m *SetMapLeavesRequest) GetDryRun() bool {
+	if m != nil {
+		return m.DryRun
+	}
+	return false
+}
+
+func (m *SetMapLeavesRequest) GetRevisionLease() []byte {
+	if m != nil {
+		return m.RevisionLease
+	}
+	return nil
+}
+
+type SetMapLeavesResponse struct {
+	// The new root of the map. In dry runs, the root which the write would
+	// have produced, which is not signed.
+	MapRoot              *SignedMapRoot `protobuf:"bytes,2,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *SetMapLeavesResponse) Reset()         { *m = SetMapLeavesResponse{} }
+func (m *SetMapLeavesResponse) String() string { return proto.CompactTextString(m) }
+func (*SetMapLeavesResponse) ProtoMessage()    {}
+func (*SetMapLeavesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{20}
+}
+
+func (m *SetMapLeavesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetMapLeavesResponse.Unmarshal(m, b)
+}
+func (m *SetMapLeavesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetMapLeavesResponse.Marshal(b, m, deterministic)
+}
+func (m *SetMapLeavesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetMapLeavesResponse.Merge(m, src)
+}
+func (m *SetMapLeavesResponse) XXX_Size() int {
+	return xxx_messageInfo_SetMapLeavesResponse.Size(m)
+}
+func (m *SetMapLeavesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetMapLeavesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetMapLeavesResponse proto.InternalMessageInfo
+
+func (m *SetMapLeavesResponse) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
+}
+
+type SetMultiMapLeavesRequest struct {
+	// The updates to apply, at most one per map. All the maps must be stored in
+	// the same storage backend.
+	Requests             []*SetMapLeavesRequest `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}               `json:"-"`
+	XXX_unrecognized     []byte                 `json:"-"`
+	XXX_sizecache        int32                  `json:"-"`
+}
+
+func (m *SetMultiMapLeavesRequest) Reset()         { *m = SetMultiMapLeavesRequest{} }
+func (m *SetMultiMapLeavesRequest) String() string { return proto.CompactTextString(m) }
+func (*SetMultiMapLeavesRequest) ProtoMessage()    {}
+func (*SetMultiMapLeavesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{21}
+}
+
+func (m *SetMultiMapLeavesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetMultiMapLeavesRequest.Unmarshal(m, b)
+}
+func (m *SetMultiMapLeavesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetMultiMapLeavesRequest.Marshal(b, m, deterministic)
+}
+func (m *SetMultiMapLeavesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetMultiMapLeavesRequest.Merge(m, src)
+}
+func (m *SetMultiMapLeavesRequest) XXX_Size() int {
+	return xxx_messageInfo_SetMultiMapLeavesRequest.Size(m)
+}
+func (m *SetMultiMapLeavesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetMultiMapLeavesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetMultiMapLeavesRequest proto.InternalMessageInfo
+
+func (m *SetMultiMapLeavesRequest) GetRequests() []*SetMapLeavesRequest {
+	if m != nil {
+		return m.Requests
+	}
+	return nil
+}
+
+type SetMultiMapLeavesResponse struct {
+	// The new roots of the maps, in the same order as the requests.
+	MapRoots             []*SignedMapRoot `protobuf:"bytes,1,rep,name=map_roots,json=mapRoots,proto3" json:"map_roots,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *SetMultiMapLeavesResponse) Reset()         { *m = SetMultiMapLeavesResponse{} }
+func (m *SetMultiMapLeavesResponse) String() string { return proto.CompactTextString(m) }
+func (*SetMultiMapLeavesResponse) ProtoMessage()    {}
+func (*SetMultiMapLeavesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{22}
+}
+
+func (m *SetMultiMapLeavesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetMultiMapLeavesResponse.Unmarshal(m, b)
+}
+func (m *SetMultiMapLeavesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetMultiMapLeavesResponse.Marshal(b, m, deterministic)
+}
+func (m *SetMultiMapLeavesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetMultiMapLeavesResponse.Merge(m, src)
+}
+func (m *SetMultiMapLeavesResponse) XXX_Size() int {
+	return xxx_messageInfo_SetMultiMapLeavesResponse.Size(m)
+}
+func (m *SetMultiMapLeavesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetMultiMapLeavesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetMultiMapLeavesResponse proto.InternalMessageInfo
+
+func (m *SetMultiMapLeavesResponse) GetMapRoots() []*SignedMapRoot {
+	if m != nil {
+		return m.MapRoots
+	}
+	return nil
+}
+
+type WriteMapLeavesRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	// The leaves being set must have unique Index values within the request.
+	Leaves []*MapLeaf `protobuf:"bytes,2,rep,name=leaves,proto3" json:"leaves,omitempty"`
+	// Metadata that the Map should associate with the new Map root after
+	// incorporating the leaf changes.  The metadata will be reflected in the
+	// Map Root published for this revision.
+	// Map personalities should use metadata to persist any state needed later
+	// to continue mapping from an external data source.
+	Metadata []byte `protobuf:"bytes,3,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	// The map revision to associate the leaves with. The request will fail if
+	// this revision already exists, does not match the current write revision, or
+	// is negative. If revision = 0 then the leaves will be written to the current
+	// write revision.
+	ExpectRevision int64 `protobuf:"varint,4,opt,name=expect_revision,json=expectRevision,proto3" json:"expect_revision,omitempty"`
+	// An optional token, at most 255 bytes long, identifying this write. If a
+	// previous write with the same token succeeded, the request is not applied
+	// again, and the revision it created is returned instead.
+	IdempotencyToken []byte `protobuf:"bytes,5,opt,name=idempotency_token,json=idempotencyToken,proto3" json:"idempotency_token,omitempty"`
+	// If set, the revision and root hash which the write would produce are
+	// returned, but nothing is written. Not supported if the server queues
+	// writes.
+	DryRun bool `protobuf:"varint,6,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	// The lease token returned by ReserveRevision, as in
+	// SetMapLeavesRequest.revision_lease. Not supported if the server queues
+	// writes.
+	RevisionLease        []byte   `protobuf:"bytes,7,opt,name=revision_lease,json=revisionLease,proto3" json:"revision_lease,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WriteMapLeavesRequest) Reset()         { *m = WriteMapLeavesRequest{} }
+func (m *WriteMapLeavesRequest) String() string { return proto.CompactTextString(m) }
+func (*WriteMapLeavesRequest) ProtoMessage()    {}
+func (*WriteMapLeavesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{23}
+}
+
+func (m *WriteMapLeavesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WriteMapLeavesRequest.Unmarshal(m, b)
+}
+func (m *WriteMapLeavesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WriteMapLeavesRequest.Marshal(b, m, deterministic)
+}
+func (m *WriteMapLeavesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WriteMapLeavesRequest.Merge(m, src)
+}
+func (m *WriteMapLeavesRequest) XXX_Size() int {
+	return xxx_messageInfo_WriteMapLeavesRequest.Size(m)
+}
+func (m *WriteMapLeavesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WriteMapLeavesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WriteMapLeavesRequest proto.InternalMessageInfo
+
+func (m *WriteMapLeavesRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
+	}
+	return 0
+}
+
+func (m *WriteMapLeavesRequest) GetLeaves() []*MapLeaf {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+func (m *WriteMapLeavesRequest) GetMetadata() []byte {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *WriteMapLeavesRequest) GetExpectRevision() int64 {
+	if m != nil {
+		return m.ExpectRevision
+	}
+	return 0
+}
+
+func (m *WriteMapLeavesRequest) GetIdempotencyToken() []byte {
+	if m != nil {
+		return m.IdempotencyToken
+	}
+	return nil
+}
+
+func (m *WriteMapLeavesRequest) GetDryRun() bool {
+	if m != nil {
+		return m.DryRun
+	}
+	return false
+}
+
+func (m *WriteMapLeavesRequest) GetRevisionLease() []byte {
+	if m != nil {
+		return m.RevisionLease
+	}
+	return nil
+}
+
+type WriteMapLeavesResponse struct {
+	// The map revision that the leaves will be published at.
+	// This may be accompanied by a proof that the write request has been included
+	// in an input log in the future.
+	// If the server queues writes to be merged later, the leaves have not been
+	// published yet and revision is 0.
+	Revision int64 `protobuf:"varint,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	// The root hash of the map at revision, unset if the write was queued.
+	RootHash             []byte   `protobuf:"bytes,2,opt,name=root_hash,json=rootHash,proto3" json:"root_hash,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WriteMapLeavesResponse) Reset()         { *m = WriteMapLeavesResponse{} }
+func (m *WriteMapLeavesResponse) String() string { return proto.CompactTextString(m) }
+func (*WriteMapLeavesResponse) ProtoMessage()    {}
+func (*WriteMapLeavesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{24}
+}
+
+func (m *WriteMapLeavesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WriteMapLeavesResponse.Unmarshal(m, b)
+}
+func (m *WriteMapLeavesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WriteMapLeavesResponse.Marshal(b, m, deterministic)
+}
+func (m *WriteMapLeavesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WriteMapLeavesResponse.Merge(m, src)
+}
+func (m *WriteMapLeavesResponse) XXX_Size() int {
+	return xxx_messageInfo_WriteMapLeavesResponse.Size(m)
+}
+func (m *WriteMapLeavesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_WriteMapLeavesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WriteMapLeavesResponse proto.InternalMessageInfo
+
+func (m *WriteMapLeavesResponse) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+func (m *WriteMapLeavesResponse) GetRootHash() []byte {
+	if m != nil {
+		return m.RootHash
+	}
+	return nil
+}
+
+// DeleteMapLeafRangeRequest clears the leaves whose indexes start with a
+// prefix, e.g. those of a tenant of a shared map. Each request clears at most
+// as many leaves as the server allows a write to set (its --max_set_leaves
+// flag, or 10000 if the flag is not set), in index order. If the prefix has
+// more leaves, DeleteMapLeafRangeResponse.more is set, and the request must be
+// repeated, each time at a new revision, until it is not.
+type DeleteMapLeafRangeRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	// The prefix of the indexes of the leaves to clear. It must be non-empty and
+	// shorter than an index.
+	IndexPrefix []byte `protobuf:"bytes,2,opt,name=index_prefix,json=indexPrefix,proto3" json:"index_prefix,omitempty"`
+	// Metadata that the Map should associate with the new Map root.
+	Metadata []byte `protobuf:"bytes,3,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	// The map revision to clear the leaves at. If 0, the leaves are cleared at
+	// the current write revision, as with WriteMapLeavesRequest.expect_revision.
+	ExpectRevision       int64    `protobuf:"varint,4,opt,name=expect_revision,json=expectRevision,proto3" json:"expect_revision,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteMapLeafRangeRequest) Reset()         { *m = DeleteMapLeafRangeRequest{} }
+func (m *DeleteMapLeafRangeRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteMapLeafRangeRequest) ProtoMessage()    {}
+func (*DeleteMapLeafRangeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{25}
+}
+
+func (m *DeleteMapLeafRangeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteMapLeafRangeRequest.Unmarshal(m, b)
+}
+func (m *DeleteMapLeafRangeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteMapLeafRangeRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteMapLeafRangeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteMapLeafRangeRequest.Merge(m, src)
+}
+func (m *DeleteMapLeafRangeRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteMapLeafRangeRequest.Size(m)
+}
+func (m *DeleteMapLeafRangeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteMapLeafRangeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteMapLeafRangeRequest proto.InternalMessageInfo
+
+func (m *DeleteMapLeafRangeRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
+	}
+	return 0
+}
+
+func (m *DeleteMapLeafRangeRequest) GetIndexPrefix() []byte {
+	if m != nil {
+		return m.IndexPrefix
+	}
+	return nil
+}
+
+func (m *DeleteMapLeafRangeRequest) GetMetadata() []byte {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *DeleteMapLeafRangeRequest) GetExpectRevision() int64 {
+	if m != nil {
+		return m.ExpectRevision
+	}
+	return 0
+}
+
+type DeleteMapLeafRangeResponse struct {
+	// The map revision which cleared the leaves.
+	Revision int64 `protobuf:"varint,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	// The number of leaves cleared. Each is set to an empty value at revision,
+	// as if it had been deleted by WriteLeaves, so that inclusion proofs of
+	// the range at revision verify against empty values.
+	DeletedCount int64 `protobuf:"varint,2,opt,name=deleted_count,json=deletedCount,proto3" json:"deleted_count,omitempty"`
+	// Whether leaves under the prefix remain, because there were more than a
+	// single request may clear. They are cleared by repeating the request.
+	More                 bool     `protobuf:"varint,3,opt,name=more,proto3" json:"more,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteMapLeafRangeResponse) Reset()         { *m = DeleteMapLeafRangeResponse{} }
+func (m *DeleteMapLeafRangeResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteMapLeafRangeResponse) ProtoMessage()    {}
+func (*DeleteMapLeafRangeResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{26}
+}
+
+func (m *DeleteMapLeafRangeResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteMapLeafRangeResponse.Unmarshal(m, b)
+}
+func (m *DeleteMapLeafRangeResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteMapLeafRangeResponse.Marshal(b, m, deterministic)
+}
+func (m *DeleteMapLeafRangeResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteMapLeafRangeResponse.Merge(m, src)
+}
+func (m *DeleteMapLeafRangeResponse) XXX_Size() int {
+	return xxx_messageInfo_DeleteMapLeafRangeResponse.Size(m)
+}
+func (m *DeleteMapLeafRangeResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteMapLeafRangeResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteMapLeafRangeResponse proto.InternalMessageInfo
+
+func (m *DeleteMapLeafRangeResponse) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+func (m *DeleteMapLeafRangeResponse) GetDeletedCount() int64 {
+	if m != nil {
+		return m.DeletedCount
+	}
+	return 0
+}
+
+func (m *DeleteMapLeafRangeResponse) GetMore() bool {
+	if m != nil {
+		return m.More
+	}
+	return false
+}
+
+// ReserveMapRevisionRequest reserves the next write revision of a map, so that
+// a writer coordinating with others out-of-band can assign it to a batch of
+// leaves before writing them.
+type ReserveMapRevisionRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	// How long the reservation lasts. If 0, a default of one minute is used.
+	// It must not be negative, nor longer than an hour.
+	LeaseDurationNanos   int64    `protobuf:"varint,2,opt,name=lease_duration_nanos,json=leaseDurationNanos,proto3" json:"lease_duration_nanos,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReserveMapRevisionRequest) Reset()         { *m = ReserveMapRevisionRequest{} }
+func (m *ReserveMapRevisionRequest) String() string { return proto.CompactTextString(m) }
+func (*ReserveMapRevisionRequest) ProtoMessage()    {}
+func (*ReserveMapRevisionRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{27}
+}
+
+func (m *ReserveMapRevisionRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReserveMapRevisionRequest.Unmarshal(m, b)
+}
+func (m *ReserveMapRevisionRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReserveMapRevisionRequest.Marshal(b, m, deterministic)
+}
+func (m *ReserveMapRevisionRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReserveMapRevisionRequest.Merge(m, src)
+}
+func (m *ReserveMapRevisionRequest) XXX_Size() int {
+	return xxx_messageInfo_ReserveMapRevisionRequest.Size(m)
+}
+func (m *ReserveMapRevisionRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReserveMapRevisionRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReserveMapRevisionRequest proto.InternalMessageInfo
+
+func (m *ReserveMapRevisionRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
+	}
+	return 0
+}
+
+func (m *ReserveMapRevisionRequest) GetLeaseDurationNanos() int64 {
+	if m != nil {
+		return m.LeaseDurationNanos
+	}
+	return 0
+}
+
+type ReserveMapRevisionResponse struct {
+	// The reserved revision: the lowest revision of the map which is neither
+	// written nor reserved by an unexpired lease.
+	Revision int64 `protobuf:"varint,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	// The token which writes at revision must set as their revision_lease.
+	LeaseToken []byte `protobuf:"bytes,2,opt,name=lease_token,json=leaseToken,proto3" json:"lease_token,omitempty"`
+	// The time at which the lease expires, after which revision can be written
+	// without the token, or reserved again.
+	LeaseExpiryNanos     int64    `protobuf:"varint,3,opt,name=lease_expiry_nanos,json=leaseExpiryNanos,proto3" json:"lease_expiry_nanos,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReserveMapRevisionResponse) Reset()         { *m = ReserveMapRevisionResponse{} }
+func (m *ReserveMapRevisionResponse) String() string { return proto.CompactTextString(m) }
+func (*ReserveMapRevisionResponse) ProtoMessage()    {}
+func (*ReserveMapRevisionResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{28}
+}
+
+func (m *ReserveMapRevisionResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReserveMapRevisionResponse.Unmarshal(m, b)
+}
+func (m *ReserveMapRevisionResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReserveMapRevisionResponse.Marshal(b, m, deterministic)
+}
+func (m *ReserveMapRevisionResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReserveMapRevisionResponse.Merge(m, src)
+}
+func (m *ReserveMapRevisionResponse) XXX_Size() int {
+	return xxx_messageInfo_ReserveMapRevisionResponse.Size(m)
+}
+func (m *ReserveMapRevisionResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReserveMapRevisionResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReserveMapRevisionResponse proto.InternalMessageInfo
+
+func (m *ReserveMapRevisionResponse) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+func (m *ReserveMapRevisionResponse) GetLeaseToken() []byte {
+	if m != nil {
+		return m.LeaseToken
+	}
+	return nil
+}
+
+func (m *ReserveMapRevisionResponse) GetLeaseExpiryNanos() int64 {
+	if m != nil {
+		return m.LeaseExpiryNanos
+	}
+	return 0
+}
+
+// GetMapHotKeysRequest asks for the index prefixes of a map written to most
+// often.
+type GetMapHotKeysRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	// The maximum number of prefixes to return. If 0, the server default of 10
+	// is used.
+	Limit                int32    `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetMapHotKeysRequest) Reset()         { *m = GetMapHotKeysRequest{} }
+func (m *GetMapHotKeysRequest) String() string { return proto.CompactTextString(m) }
+func (*GetMapHotKeysRequest) ProtoMessage()    {}
+func (*GetMapHotKeysRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{29}
+}
+
+func (m *GetMapHotKeysRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMapHotKeysRequest.Unmarshal(m, b)
+}
+func (m *GetMapHotKeysRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMapHotKeysRequest.Marshal(b, m, deterministic)
+}
+func (m *GetMapHotKeysRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMapHotKeysRequest.Merge(m, src)
+}
+func (m *GetMapHotKeysRequest) XXX_Size() int {
+	return xxx_messageInfo_GetMapHotKeysRequest.Size(m)
+}
+func (m *GetMapHotKeysRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMapHotKeysRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetMapHotKeysRequest proto.InternalMessageInfo
+
+func (m *GetMapHotKeysRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
+	}
+	return 0
+}
+
+func (m *GetMapHotKeysRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+// MapPrefixWrites is the number of leaves written under an index prefix.
+type MapPrefixWrites struct {
+	IndexPrefix []byte `protobuf:"bytes,1,opt,name=index_prefix,json=indexPrefix,proto3" json:"index_prefix,omitempty"`
+	// The number of leaves counted under index_prefix, which may exceed the
+	// actual number by up to max_overcount.
+	Writes int64 `protobuf:"varint,2,opt,name=writes,proto3" json:"writes,omitempty"`
+	// Prefixes are only counted while they are among the most written ones, so
+	// when a prefix replaces one which is written less often, it inherits the
+	// count of that prefix as max_overcount.
+	MaxOvercount         int64    `protobuf:"varint,3,opt,name=max_overcount,json=maxOvercount,proto3" json:"max_overcount,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MapPrefixWrites) Reset()         { *m = MapPrefixWrites{} }
+func (m *MapPrefixWrites) String() string { return proto.CompactTextString(m) }
+func (*MapPrefixWrites) ProtoMessage()    {}
+func (*MapPrefixWrites) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{30}
+}
+
+func (m *MapPrefixWrites) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MapPrefixWrites.Unmarshal(m, b)
+}
+func (m *MapPrefixWrites) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MapPrefixWrites.Marshal(b, m, deterministic)
+}
+func (m *MapPrefixWrites) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MapPrefixWrites.Merge(m, src)
+}
+func (m *MapPrefixWrites) XXX_Size() int {
+	return xxx_messageInfo_MapPrefixWrites.Size(m)
+}
+func (m *MapPrefixWrites) XXX_DiscardUnknown() {
+	xxx_messageInfo_MapPrefixWrites.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MapPrefixWrites proto.InternalMessageInfo
+
+func (m *MapPrefixWrites) GetIndexPrefix() []byte {
+	if m != nil {
+		return m.IndexPrefix
+	}
+	return nil
+}
+
+func (m *MapPrefixWrites) GetWrites() int64 {
+	if m != nil {
+		return m.Writes
+	}
+	return 0
+}
+
+func (m *MapPrefixWrites) GetMaxOvercount() int64 {
+	if m != nil {
+		return m.MaxOvercount
+	}
+	return 0
+}
+
+type GetMapHotKeysResponse struct {
+	// The number of leading bytes of the indices which writes are counted by.
+	PrefixBytes int32 `protobuf:"varint,1,opt,name=prefix_bytes,json=prefixBytes,proto3" json:"prefix_bytes,omitempty"`
+	// The number of leaves written to the map since count_start_nanos.
+	TotalWrites int64 `protobuf:"varint,2,opt,name=total_writes,json=totalWrites,proto3" json:"total_writes,omitempty"`
+	// The time at which the server started counting the writes to the map.
+	CountStartNanos int64 `protobuf:"varint,3,opt,name=count_start_nanos,json=countStartNanos,proto3" json:"count_start_nanos,omitempty"`
+	// The prefixes written to most often, in descending order of writes.
+	HotKeys              []*MapPrefixWrites `protobuf:"bytes,4,rep,name=hot_keys,json=hotKeys,proto3" json:"hot_keys,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *GetMapHotKeysResponse) Reset()         { *m = GetMapHotKeysResponse{} }
+func (m *GetMapHotKeysResponse) String() string { return proto.CompactTextString(m) }
+func (*GetMapHotKeysResponse) ProtoMessage()    {}
+func (*GetMapHotKeysResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{31}
+}
+
+func (m *GetMapHotKeysResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMapHotKeysResponse.Unmarshal(m, b)
+}
+func (m *GetMapHotKeysResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMapHotKeysResponse.Marshal(b, m, deterministic)
+}
+func (m *GetMapHotKeysResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMapHotKeysResponse.Merge(m, src)
+}
+func (m *GetMapHotKeysResponse) XXX_Size() int {
+	return xxx_messageInfo_GetMapHotKeysResponse.Size(m)
+}
+func (m *GetMapHotKeysResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMapHotKeysResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetMapHotKeysResponse proto.InternalMessageInfo
+
+func (m *GetMapHotKeysResponse) GetPrefixBytes() int32 {
+	if m != nil {
+		return m.PrefixBytes
+	}
+	return 0
+}
+
+func (m *GetMapHotKeysResponse) GetTotalWrites() int64 {
+	if m != nil {
+		return m.TotalWrites
+	}
+	return 0
+}
+
+func (m *GetMapHotKeysResponse) GetCountStartNanos() int64 {
+	if m != nil {
+		return m.CountStartNanos
+	}
+	return 0
+}
+
+func (m *GetMapHotKeysResponse) GetHotKeys() []*MapPrefixWrites {
+	if m != nil {
+		return m.HotKeys
+	}
+	return nil
+}
+
+type GetSignedMapRootRequest struct {
+	MapId                int64    `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetSignedMapRootRequest) Reset()         { *m = GetSignedMapRootRequest{} }
+func (m *GetSignedMapRootRequest) String() string { return proto.CompactTextString(m) }
+func (*GetSignedMapRootRequest) ProtoMessage()    {}
+func (*GetSignedMapRootRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{32}
+}
+
+func (m *GetSignedMapRootRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetSignedMapRootRequest.Unmarshal(m, b)
+}
+func (m *GetSignedMapRootRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetSignedMapRootRequest.Marshal(b, m, deterministic)
+}
+func (m *GetSignedMapRootRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetSignedMapRootRequest.Merge(m, src)
+}
+func (m *GetSignedMapRootRequest) XXX_Size() int {
+	return xxx_messageInfo_GetSignedMapRootRequest.Size(m)
+}
+func (m *GetSignedMapRootRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetSignedMapRootRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetSignedMapRootRequest proto.InternalMessageInfo
+
+func (m *GetSignedMapRootRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
+	}
+	return 0
+}
+
+type GetSignedMapRootByRevisionRequest struct {
+	MapId                int64    `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	Revision             int64    `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetSignedMapRootByRevisionRequest) Reset()         { *m = GetSignedMapRootByRevisionRequest{} }
+func (m *GetSignedMapRootByRevisionRequest) String() string { return proto.CompactTextString(m) }
+func (*GetSignedMapRootByRevisionRequest) ProtoMessage()    {}
+func (*GetSignedMapRootByRevisionRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{33}
+}
+
+func (m *GetSignedMapRootByRevisionRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetSignedMapRootByRevisionRequest.Unmarshal(m, b)
+}
+func (m *GetSignedMapRootByRevisionRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetSignedMapRootByRevisionRequest.Marshal(b, m, deterministic)
+}
+func (m *GetSignedMapRootByRevisionRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetSignedMapRootByRevisionRequest.Merge(m, src)
+}
+func (m *GetSignedMapRootByRevisionRequest) XXX_Size() int {
+	return xxx_messageInfo_GetSignedMapRootByRevisionRequest.Size(m)
+}
+func (m *GetSignedMapRootByRevisionRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetSignedMapRootByRevisionRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetSignedMapRootByRevisionRequest proto.InternalMessageInfo
+
+func (m *GetSignedMapRootByRevisionRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
+	}
+	return 0
+}
+
+func (m *GetSignedMapRootByRevisionRequest) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type GetSignedMapRootResponse struct {
+	MapRoot              *SignedMapRoot `protobuf:"bytes,2,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *GetSignedMapRootResponse) Reset()         { *m = GetSignedMapRootResponse{} }
+func (m *GetSignedMapRootResponse) String() string { return proto.CompactTextString(m) }
+func (*GetSignedMapRootResponse) ProtoMessage()    {}
+func (*GetSignedMapRootResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{34}
+}
+
+func (m *GetSignedMapRootResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetSignedMapRootResponse.Unmarshal(m, b)
+}
+func (m *GetSignedMapRootResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetSignedMapRootResponse.Marshal(b, m, deterministic)
+}
+func (m *GetSignedMapRootResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetSignedMapRootResponse.Merge(m, src)
+}
+func (m *GetSignedMapRootResponse) XXX_Size() int {
+	return xxx_messageInfo_GetSignedMapRootResponse.Size(m)
+}
+func (m *GetSignedMapRootResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetSignedMapRootResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetSignedMapRootResponse proto.InternalMessageInfo
+
+func (m *GetSignedMapRootResponse) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
+}
+
+type GetMapCheckpointRequest struct {
+	MapId                int64    `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetMapCheckpointRequest) Reset()         { *m = GetMapCheckpointRequest{} }
+func (m *GetMapCheckpointRequest) String() string { return proto.CompactTextString(m) }
+func (*GetMapCheckpointRequest) ProtoMessage()    {}
+func (*GetMapCheckpointRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{35}
+}
+
+func (m *GetMapCheckpointRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMapCheckpointRequest.Unmarshal(m, b)
+}
+func (m *GetMapCheckpointRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMapCheckpointRequest.Marshal(b, m, deterministic)
+}
+func (m *GetMapCheckpointRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMapCheckpointRequest.Merge(m, src)
+}
+func (m *GetMapCheckpointRequest) XXX_Size() int {
+	return xxx_messageInfo_GetMapCheckpointRequest.Size(m)
+}
+func (m *GetMapCheckpointRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMapCheckpointRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetMapCheckpointRequest proto.InternalMessageInfo
+
+func (m *GetMapCheckpointRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
+	}
+	return 0
+}
+
+type GetMapCheckpointResponse struct {
+	// The signed note of the checkpoint, as in GetCheckpointResponse.
+	Checkpoint []byte `protobuf:"bytes,1,opt,name=checkpoint,proto3" json:"checkpoint,omitempty"`
+	// The root which the checkpoint represents.
+	MapRoot              *SignedMapRoot `protobuf:"bytes,2,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *GetMapCheckpointResponse) Reset()         { *m = GetMapCheckpointResponse{} }
+func (m *GetMapCheckpointResponse) String() string { return proto.CompactTextString(m) }
+func (*GetMapCheckpointResponse) ProtoMessage()    {}
+func (*GetMapCheckpointResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{36}
+}
+
+func (m *GetMapCheckpointResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMapCheckpointResponse.Unmarshal(m, b)
+}
+func (m *GetMapCheckpointResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMapCheckpointResponse.Marshal(b, m, deterministic)
+}
+func (m *GetMapCheckpointResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMapCheckpointResponse.Merge(m, src)
+}
+func (m *GetMapCheckpointResponse) XXX_Size() int {
+	return xxx_messageInfo_GetMapCheckpointResponse.Size(m)
+}
+func (m *GetMapCheckpointResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMapCheckpointResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetMapCheckpointResponse proto.InternalMessageInfo
+
+func (m *GetMapCheckpointResponse) GetCheckpoint() []byte {
+	if m != nil {
+		return m.Checkpoint
+	}
+	return nil
+}
+
+func (m *GetMapCheckpointResponse) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
+}
+
+// ListSignedMapRootsRequest asks for the roots of a map which were published
+// within a range of revisions and a range of times. Unset bounds are open.
+type ListSignedMapRootsRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	// start_revision >= 0 is the first revision to return.
+	StartRevision int64 `protobuf:"varint,2,opt,name=start_revision,json=startRevision,proto3" json:"start_revision,omitempty"`
+	// end_revision, if positive, is the first revision not to return.
+	EndRevision int64 `protobuf:"varint,3,opt,name=end_revision,json=endRevision,proto3" json:"end_revision,omitempty"`
+	// start_timestamp_nanos excludes the roots published before it.
+	StartTimestampNanos int64 `protobuf:"varint,4,opt,name=start_timestamp_nanos,json=startTimestampNanos,proto3" json:"start_timestamp_nanos,omitempty"`
+	// end_timestamp_nanos, if positive, excludes the roots published at or
+	// after it.
+	EndTimestampNanos int64 `protobuf:"varint,5,opt,name=end_timestamp_nanos,json=endTimestampNanos,proto3" json:"end_timestamp_nanos,omitempty"`
+	// newest_first returns the roots in descending revision order. Together
+	// with end_timestamp_nanos and a page_size of 1, it finds the latest root
+	// published before a given time.
+	NewestFirst bool `protobuf:"varint,6,opt,name=newest_first,json=newestFirst,proto3" json:"newest_first,omitempty"`
+	// page_size is the maximum number of roots in the response. If zero, a
+	// server-chosen default is used. Values larger than the server's limit are
+	// capped to that limit.
+	PageSize int32 `protobuf:"varint,7,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// page_token, if set, must be a next_page_token returned by an earlier
+	// ListSignedMapRoots call with the same filter. The listing resumes with
+	// the root following that token.
+	PageToken            string   `protobuf:"bytes,8,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *GetSignedMapRootRequest) Reset()         { *m = GetSignedMapRootRequest{} }
-func (m *GetSignedMapRootRequest) String() string { return proto.CompactTextString(m) }
-func (*GetSignedMapRootRequest) ProtoMessage()    {}
-func (*GetSignedMapRootRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_28d34dfba22a7ce2, []int{14}
+func (m *ListSignedMapRootsRequest) Reset()         { *m = ListSignedMapRootsRequest{} }
+func (m *ListSignedMapRootsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListSignedMapRootsRequest) ProtoMessage()    {}
+func (*ListSignedMapRootsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{37}
+}
+
+func (m *ListSignedMapRootsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSignedMapRootsRequest.Unmarshal(m, b)
+}
+func (m *ListSignedMapRootsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSignedMapRootsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListSignedMapRootsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSignedMapRootsRequest.Merge(m, src)
+}
+func (m *ListSignedMapRootsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListSignedMapRootsRequest.Size(m)
+}
+func (m *ListSignedMapRootsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSignedMapRootsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSignedMapRootsRequest proto.InternalMessageInfo
+
+func (m *ListSignedMapRootsRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
+	}
+	return 0
+}
+
+func (m *ListSignedMapRootsRequest) GetStartRevision() int64 {
+	if m != nil {
+		return m.StartRevision
+	}
+	return 0
+}
+
+func (m *ListSignedMapRootsRequest) GetEndRevision() int64 {
+	if m != nil {
+		return m.EndRevision
+	}
+	return 0
+}
+
+func (m *ListSignedMapRootsRequest) GetStartTimestampNanos() int64 {
+	if m != nil {
+		return m.StartTimestampNanos
+	}
+	return 0
+}
+
+func (m *ListSignedMapRootsRequest) GetEndTimestampNanos() int64 {
+	if m != nil {
+		return m.EndTimestampNanos
+	}
+	return 0
+}
+
+func (m *ListSignedMapRootsRequest) GetNewestFirst() bool {
+	if m != nil {
+		return m.NewestFirst
+	}
+	return false
+}
+
+func (m *ListSignedMapRootsRequest) GetPageSize() int32 {
+	if m != nil {
+		return m.PageSize
+	}
+	return 0
+}
+
+func (m *ListSignedMapRootsRequest) GetPageToken() string {
+	if m != nil {
+		return m.PageToken
+	}
+	return ""
+}
+
+type ListSignedMapRootsResponse struct {
+	// map_roots holds the matching roots, in the requested revision order. The
+	// revision, timestamp and metadata of each are in its map_root field.
+	MapRoots []*SignedMapRoot `protobuf:"bytes,1,rep,name=map_roots,json=mapRoots,proto3" json:"map_roots,omitempty"`
+	// next_page_token can be used to resume the listing after the last root in
+	// this response. It is empty when there are no more roots.
+	NextPageToken        string   `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListSignedMapRootsResponse) Reset()         { *m = ListSignedMapRootsResponse{} }
+func (m *ListSignedMapRootsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListSignedMapRootsResponse) ProtoMessage()    {}
+func (*ListSignedMapRootsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{38}
+}
+
+func (m *ListSignedMapRootsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSignedMapRootsResponse.Unmarshal(m, b)
+}
+func (m *ListSignedMapRootsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSignedMapRootsResponse.Marshal(b, m, deterministic)
+}
+func (m *ListSignedMapRootsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSignedMapRootsResponse.Merge(m, src)
+}
+func (m *ListSignedMapRootsResponse) XXX_Size() int {
+	return xxx_messageInfo_ListSignedMapRootsResponse.Size(m)
+}
+func (m *ListSignedMapRootsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSignedMapRootsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSignedMapRootsResponse proto.InternalMessageInfo
+
+func (m *ListSignedMapRootsResponse) GetMapRoots() []*SignedMapRoot {
+	if m != nil {
+		return m.MapRoots
+	}
+	return nil
+}
+
+func (m *ListSignedMapRootsResponse) GetNextPageToken() string {
+	if m != nil {
+		return m.NextPageToken
+	}
+	return ""
+}
+
+type WatchSignedMapRootsRequest struct {
+	MapId                int64    `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchSignedMapRootsRequest) Reset()         { *m = WatchSignedMapRootsRequest{} }
+func (m *WatchSignedMapRootsRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchSignedMapRootsRequest) ProtoMessage()    {}
+func (*WatchSignedMapRootsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{39}
+}
+
+func (m *WatchSignedMapRootsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchSignedMapRootsRequest.Unmarshal(m, b)
+}
+func (m *WatchSignedMapRootsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchSignedMapRootsRequest.Marshal(b, m, deterministic)
+}
+func (m *WatchSignedMapRootsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchSignedMapRootsRequest.Merge(m, src)
+}
+func (m *WatchSignedMapRootsRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchSignedMapRootsRequest.Size(m)
+}
+func (m *WatchSignedMapRootsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchSignedMapRootsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchSignedMapRootsRequest proto.InternalMessageInfo
+
+func (m *WatchSignedMapRootsRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
+	}
+	return 0
+}
+
+type WatchSignedMapRootsResponse struct {
+	MapRoot              *SignedMapRoot `protobuf:"bytes,1,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *WatchSignedMapRootsResponse) Reset()         { *m = WatchSignedMapRootsResponse{} }
+func (m *WatchSignedMapRootsResponse) String() string { return proto.CompactTextString(m) }
+func (*WatchSignedMapRootsResponse) ProtoMessage()    {}
+func (*WatchSignedMapRootsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{40}
+}
+
+func (m *WatchSignedMapRootsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchSignedMapRootsResponse.Unmarshal(m, b)
+}
+func (m *WatchSignedMapRootsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchSignedMapRootsResponse.Marshal(b, m, deterministic)
+}
+func (m *WatchSignedMapRootsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchSignedMapRootsResponse.Merge(m, src)
+}
+func (m *WatchSignedMapRootsResponse) XXX_Size() int {
+	return xxx_messageInfo_WatchSignedMapRootsResponse.Size(m)
+}
+func (m *WatchSignedMapRootsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchSignedMapRootsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchSignedMapRootsResponse proto.InternalMessageInfo
+
+func (m *WatchSignedMapRootsResponse) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
+}
+
+// MapRootSignature is a signature by a third-party witness over a signed map
+// root, endorsing it.
+type MapRootSignature struct {
+	// The public key of the witness, which the signature verifies with.
+	PublicKey *keyspb.PublicKey `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	// The signature over the map_root bytes of the SignedMapRoot, made as by
+	// the Trillian signers, i.e. over their SHA-256 hash.
+	Signature            []byte   `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MapRootSignature) Reset()         { *m = MapRootSignature{} }
+func (m *MapRootSignature) String() string { return proto.CompactTextString(m) }
+func (*MapRootSignature) ProtoMessage()    {}
+func (*MapRootSignature) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{41}
+}
+
+func (m *MapRootSignature) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MapRootSignature.Unmarshal(m, b)
+}
+func (m *MapRootSignature) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MapRootSignature.Marshal(b, m, deterministic)
+}
+func (m *MapRootSignature) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MapRootSignature.Merge(m, src)
+}
+func (m *MapRootSignature) XXX_Size() int {
+	return xxx_messageInfo_MapRootSignature.Size(m)
+}
+func (m *MapRootSignature) XXX_DiscardUnknown() {
+	xxx_messageInfo_MapRootSignature.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MapRootSignature proto.InternalMessageInfo
+
+func (m *MapRootSignature) GetPublicKey() *keyspb.PublicKey {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *MapRootSignature) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type AddMapRootSignatureRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	// The revision of the root which is signed.
+	Revision             int64             `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	Signature            *MapRootSignature `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *AddMapRootSignatureRequest) Reset()         { *m = AddMapRootSignatureRequest{} }
+func (m *AddMapRootSignatureRequest) String() string { return proto.CompactTextString(m) }
+func (*AddMapRootSignatureRequest) ProtoMessage()    {}
+func (*AddMapRootSignatureRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{42}
+}
+
+func (m *AddMapRootSignatureRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddMapRootSignatureRequest.Unmarshal(m, b)
+}
+func (m *AddMapRootSignatureRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddMapRootSignatureRequest.Marshal(b, m, deterministic)
+}
+func (m *AddMapRootSignatureRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddMapRootSignatureRequest.Merge(m, src)
+}
+func (m *AddMapRootSignatureRequest) XXX_Size() int {
+	return xxx_messageInfo_AddMapRootSignatureRequest.Size(m)
+}
+func (m *AddMapRootSignatureRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddMapRootSignatureRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddMapRootSignatureRequest proto.InternalMessageInfo
+
+func (m *AddMapRootSignatureRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
+	}
+	return 0
+}
+
+func (m *AddMapRootSignatureRequest) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+func (m *AddMapRootSignatureRequest) GetSignature() *MapRootSignature {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type AddMapRootSignatureResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddMapRootSignatureResponse) Reset()         { *m = AddMapRootSignatureResponse{} }
+func (m *AddMapRootSignatureResponse) String() string { return proto.CompactTextString(m) }
+func (*AddMapRootSignatureResponse) ProtoMessage()    {}
+func (*AddMapRootSignatureResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{43}
+}
+
+func (m *AddMapRootSignatureResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddMapRootSignatureResponse.Unmarshal(m, b)
+}
+func (m *AddMapRootSignatureResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddMapRootSignatureResponse.Marshal(b, m, deterministic)
+}
+func (m *AddMapRootSignatureResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddMapRootSignatureResponse.Merge(m, src)
+}
+func (m *AddMapRootSignatureResponse) XXX_Size() int {
+	return xxx_messageInfo_AddMapRootSignatureResponse.Size(m)
+}
+func (m *AddMapRootSignatureResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddMapRootSignatureResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddMapRootSignatureResponse proto.InternalMessageInfo
+
+type GetMapRootSignaturesRequest struct {
+	MapId                int64    `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	Revision             int64    `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetMapRootSignaturesRequest) Reset()         { *m = GetMapRootSignaturesRequest{} }
+func (m *GetMapRootSignaturesRequest) String() string { return proto.CompactTextString(m) }
+func (*GetMapRootSignaturesRequest) ProtoMessage()    {}
+func (*GetMapRootSignaturesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{44}
+}
+
+func (m *GetMapRootSignaturesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMapRootSignaturesRequest.Unmarshal(m, b)
+}
+func (m *GetMapRootSignaturesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMapRootSignaturesRequest.Marshal(b, m, deterministic)
+}
+func (m *GetMapRootSignaturesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMapRootSignaturesRequest.Merge(m, src)
+}
+func (m *GetMapRootSignaturesRequest) XXX_Size() int {
+	return xxx_messageInfo_GetMapRootSignaturesRequest.Size(m)
+}
+func (m *GetMapRootSignaturesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMapRootSignaturesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetMapRootSignaturesRequest proto.InternalMessageInfo
+
+func (m *GetMapRootSignaturesRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
+	}
+	return 0
+}
+
+func (m *GetMapRootSignaturesRequest) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+type GetMapRootSignaturesResponse struct {
+	// The root of the map at the requested revision.
+	MapRoot *SignedMapRoot `protobuf:"bytes,1,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
+	// The witness signatures over map_root, at most one per public key.
+	Signatures           []*MapRootSignature `protobuf:"bytes,2,rep,name=signatures,proto3" json:"signatures,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *GetMapRootSignaturesResponse) Reset()         { *m = GetMapRootSignaturesResponse{} }
+func (m *GetMapRootSignaturesResponse) String() string { return proto.CompactTextString(m) }
+func (*GetMapRootSignaturesResponse) ProtoMessage()    {}
+func (*GetMapRootSignaturesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{45}
+}
+
+func (m *GetMapRootSignaturesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMapRootSignaturesResponse.Unmarshal(m, b)
+}
+func (m *GetMapRootSignaturesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMapRootSignaturesResponse.Marshal(b, m, deterministic)
+}
+func (m *GetMapRootSignaturesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMapRootSignaturesResponse.Merge(m, src)
+}
+func (m *GetMapRootSignaturesResponse) XXX_Size() int {
+	return xxx_messageInfo_GetMapRootSignaturesResponse.Size(m)
+}
+func (m *GetMapRootSignaturesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMapRootSignaturesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetMapRootSignaturesResponse proto.InternalMessageInfo
+
+func (m *GetMapRootSignaturesResponse) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
+}
+
+func (m *GetMapRootSignaturesResponse) GetSignatures() []*MapRootSignature {
+	if m != nil {
+		return m.Signatures
+	}
+	return nil
+}
+
+// ExportMapRequest asks for all the revisions of a map up to a given one.
+type ExportMapRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	// revision >= 0 is the last revision to export.
+	Revision int64 `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+	// page_size is the maximum number of roots, or of leaves with all their
+	// versions, in each streamed response. If zero, a server-chosen default is
+	// used. Values larger than the server's limit are capped to that limit.
+	PageSize             int32    `protobuf:"varint,3,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *GetSignedMapRootRequest) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_GetSignedMapRootRequest.Unmarshal(m, b)
+func (m *ExportMapRequest) Reset()         { *m = ExportMapRequest{} }
+func (m *ExportMapRequest) String() string { return proto.CompactTextString(m) }
+func (*ExportMapRequest) ProtoMessage()    {}
+func (*ExportMapRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{46}
 }
-func (m *GetSignedMapRootRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_GetSignedMapRootRequest.Marshal(b, m, deterministic)
+
+func (m *ExportMapRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExportMapRequest.Unmarshal(m, b)
 }
-func (m *GetSignedMapRootRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_GetSignedMapRootRequest.Merge(m, src)
+func (m *ExportMapRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExportMapRequest.Marshal(b, m, deterministic)
 }
-func (m *GetSignedMapRootRequest) XXX_Size() int {
-	return xxx_messageInfo_GetSignedMapRootRequest.Size(m)
+func (m *ExportMapRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExportMapRequest.Merge(m, src)
 }
-func (m *GetSignedMapRootRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_GetSignedMapRootRequest.DiscardUnknown(m)
+func (m *ExportMapRequest) XXX_Size() int {
+	return xxx_messageInfo_ExportMapRequest.Size(m)
+}
+func (m *ExportMapRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExportMapRequest.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_GetSignedMapRootRequest proto.InternalMessageInfo
+var xxx_messageInfo_ExportMapRequest proto.InternalMessageInfo
 
-func (m *GetSignedMapRootRequest) GetMapId() int64 {
+func (m *ExportMapRequest) GetMapId() int64 {
 	if m != nil {
 		return m.MapId
 	}
 	return 0
 }
 
-type GetSignedMapRootByRevisionRequest struct {
-	MapId                int64    `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
-	Revision             int64    `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+func (m *ExportMapRequest) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
+func (m *ExportMapRequest) GetPageSize() int32 {
+	if m != nil {
+		return m.PageSize
+	}
+	return 0
+}
+
+// MapLeafVersion is a version of a map leaf, as written at a revision.
+type MapLeafVersion struct {
+	Revision int64 `protobuf:"varint,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	// leaf is the leaf as written at revision. An empty leaf_value deleted it.
+	Leaf                 *MapLeaf `protobuf:"bytes,2,opt,name=leaf,proto3" json:"leaf,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *GetSignedMapRootByRevisionRequest) Reset()         { *m = GetSignedMapRootByRevisionRequest{} }
-func (m *GetSignedMapRootByRevisionRequest) String() string { return proto.CompactTextString(m) }
-func (*GetSignedMapRootByRevisionRequest) ProtoMessage()    {}
-func (*GetSignedMapRootByRevisionRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_28d34dfba22a7ce2, []int{15}
+func (m *MapLeafVersion) Reset()         { *m = MapLeafVersion{} }
+func (m *MapLeafVersion) String() string { return proto.CompactTextString(m) }
+func (*MapLeafVersion) ProtoMessage()    {}
+func (*MapLeafVersion) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{47}
 }
 
-func (m *GetSignedMapRootByRevisionRequest) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_GetSignedMapRootByRevisionRequest.Unmarshal(m, b)
+func (m *MapLeafVersion) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MapLeafVersion.Unmarshal(m, b)
 }
-func (m *GetSignedMapRootByRevisionRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_GetSignedMapRootByRevisionRequest.Marshal(b, m, deterministic)
+func (m *MapLeafVersion) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MapLeafVersion.Marshal(b, m, deterministic)
 }
-func (m *GetSignedMapRootByRevisionRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_GetSignedMapRootByRevisionRequest.Merge(m, src)
+func (m *MapLeafVersion) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MapLeafVersion.Merge(m, src)
 }
-func (m *GetSignedMapRootByRevisionRequest) XXX_Size() int {
-	return xxx_messageInfo_GetSignedMapRootByRevisionRequest.Size(m)
+func (m *MapLeafVersion) XXX_Size() int {
+	return xxx_messageInfo_MapLeafVersion.Size(m)
 }
-func (m *GetSignedMapRootByRevisionRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_GetSignedMapRootByRevisionRequest.DiscardUnknown(m)
+func (m *MapLeafVersion) XXX_DiscardUnknown() {
+	xxx_messageInfo_MapLeafVersion.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_GetSignedMapRootByRevisionRequest proto.InternalMessageInfo
+var xxx_messageInfo_MapLeafVersion proto.InternalMessageInfo
 
-func (m *GetSignedMapRootByRevisionRequest) GetMapId() int64 {
+func (m *MapLeafVersion) GetRevision() int64 {
 	if m != nil {
-		return m.MapId
+		return m.Revision
 	}
 	return 0
 }
 
-func (m *GetSignedMapRootByRevisionRequest) GetRevision() int64 {
+func (m *MapLeafVersion) GetLeaf() *MapLeaf {
 	if m != nil {
-		return m.Revision
+		return m.Leaf
+	}
+	return nil
+}
+
+// ExportMapResponse is a page of an export of a map. All the roots come
+// first, followed by all the leaf versions.
+type ExportMapResponse struct {
+	// map_roots holds the next page of roots, in ascending revision order
+	// from revision 0.
+	MapRoots []*SignedMapRoot `protobuf:"bytes,1,rep,name=map_roots,json=mapRoots,proto3" json:"map_roots,omitempty"`
+	// leaf_versions holds the next page of leaf versions, in ascending index
+	// order and then in ascending revision order.
+	LeafVersions         []*MapLeafVersion `protobuf:"bytes,2,rep,name=leaf_versions,json=leafVersions,proto3" json:"leaf_versions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *ExportMapResponse) Reset()         { *m = ExportMapResponse{} }
+func (m *ExportMapResponse) String() string { return proto.CompactTextString(m) }
+func (*ExportMapResponse) ProtoMessage()    {}
+func (*ExportMapResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{48}
+}
+
+func (m *ExportMapResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExportMapResponse.Unmarshal(m, b)
+}
+func (m *ExportMapResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExportMapResponse.Marshal(b, m, deterministic)
+}
+func (m *ExportMapResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExportMapResponse.Merge(m, src)
+}
+func (m *ExportMapResponse) XXX_Size() int {
+	return xxx_messageInfo_ExportMapResponse.Size(m)
+}
+func (m *ExportMapResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExportMapResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ExportMapResponse proto.InternalMessageInfo
+
+func (m *ExportMapResponse) GetMapRoots() []*SignedMapRoot {
+	if m != nil {
+		return m.MapRoots
+	}
+	return nil
+}
+
+func (m *ExportMapResponse) GetLeafVersions() []*MapLeafVersion {
+	if m != nil {
+		return m.LeafVersions
+	}
+	return nil
+}
+
+// ImportMapRevisionRequest writes an exported revision to a map.
+type ImportMapRevisionRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId,proto3" json:"map_id,omitempty"`
+	// map_root is the exported root of the revision, which must be the next
+	// revision of the map, or revision 0 if the map is not initialised.
+	MapRoot *SignedMapRoot `protobuf:"bytes,2,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
+	// leaves holds the leaves written at the revision.
+	Leaves               []*MapLeaf `protobuf:"bytes,3,rep,name=leaves,proto3" json:"leaves,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+func (m *ImportMapRevisionRequest) Reset()         { *m = ImportMapRevisionRequest{} }
+func (m *ImportMapRevisionRequest) String() string { return proto.CompactTextString(m) }
+func (*ImportMapRevisionRequest) ProtoMessage()    {}
+func (*ImportMapRevisionRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{49}
+}
+
+func (m *ImportMapRevisionRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportMapRevisionRequest.Unmarshal(m, b)
+}
+func (m *ImportMapRevisionRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportMapRevisionRequest.Marshal(b, m, deterministic)
+}
+func (m *ImportMapRevisionRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportMapRevisionRequest.Merge(m, src)
+}
+func (m *ImportMapRevisionRequest) XXX_Size() int {
+	return xxx_messageInfo_ImportMapRevisionRequest.Size(m)
+}
+func (m *ImportMapRevisionRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportMapRevisionRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImportMapRevisionRequest proto.InternalMessageInfo
+
+func (m *ImportMapRevisionRequest) GetMapId() int64 {
+	if m != nil {
+		return m.MapId
 	}
 	return 0
 }
 
-type GetSignedMapRootResponse struct {
-	MapRoot              *SignedMapRoot `protobuf:"bytes,2,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
+func (m *ImportMapRevisionRequest) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
+}
+
+func (m *ImportMapRevisionRequest) GetLeaves() []*MapLeaf {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+type ImportMapRevisionResponse struct {
+	// map_root is the root of the imported revision, as signed by the map.
+	MapRoot              *SignedMapRoot `protobuf:"bytes,1,opt,name=map_root,json=mapRoot,proto3" json:"map_root,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
 	XXX_unrecognized     []byte         `json:"-"`
 	XXX_sizecache        int32          `json:"-"`
 }
 
-func (m *GetSignedMapRootResponse) Reset()         { *m = GetSignedMapRootResponse{} }
-func (m *GetSignedMapRootResponse) String() string { return proto.CompactTextString(m) }
-func (*GetSignedMapRootResponse) ProtoMessage()    {}
-func (*GetSignedMapRootResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_28d34dfba22a7ce2, []int{16}
+func (m *ImportMapRevisionResponse) Reset()         { *m = ImportMapRevisionResponse{} }
+func (m *ImportMapRevisionResponse) String() string { return proto.CompactTextString(m) }
+func (*ImportMapRevisionResponse) ProtoMessage()    {}
+func (*ImportMapRevisionResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_28d34dfba22a7ce2, []int{50}
 }
 
-func (m *GetSignedMapRootResponse) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_GetSignedMapRootResponse.Unmarshal(m, b)
+func (m *ImportMapRevisionResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportMapRevisionResponse.Unmarshal(m, b)
 }
-func (m *GetSignedMapRootResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_GetSignedMapRootResponse.Marshal(b, m, deterministic)
+func (m *ImportMapRevisionResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportMapRevisionResponse.Marshal(b, m, deterministic)
 }
-func (m *GetSignedMapRootResponse) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_GetSignedMapRootResponse.Merge(m, src)
+func (m *ImportMapRevisionResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportMapRevisionResponse.Merge(m, src)
 }
-func (m *GetSignedMapRootResponse) XXX_Size() int {
-	return xxx_messageInfo_GetSignedMapRootResponse.Size(m)
+func (m *ImportMapRevisionResponse) XXX_Size() int {
+	return xxx_messageInfo_ImportMapRevisionResponse.Size(m)
 }
-func (m *GetSignedMapRootResponse) XXX_DiscardUnknown() {
-	xxx_messageInfo_GetSignedMapRootResponse.DiscardUnknown(m)
+func (m *ImportMapRevisionResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportMapRevisionResponse.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_GetSignedMapRootResponse proto.InternalMessageInfo
+var xxx_messageInfo_ImportMapRevisionResponse proto.InternalMessageInfo
 
-func (m *GetSignedMapRootResponse) GetMapRoot() *SignedMapRoot {
+func (m *ImportMapRevisionResponse) GetMapRoot() *SignedMapRoot {
 	if m != nil {
 		return m.MapRoot
 	}
@@ -927,7 +2971,7 @@ func (m *InitMapRequest) Reset()         { *m = InitMapRequest{} }
 func (m *InitMapRequest) String() string { return proto.CompactTextString(m) }
 func (*InitMapRequest) ProtoMessage()    {}
 func (*InitMapRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_28d34dfba22a7ce2, []int{17}
+	return fileDescriptor_28d34dfba22a7ce2, []int{51}
 }
 
 func (m *InitMapRequest) XXX_Unmarshal(b []byte) error {
@@ -966,7 +3010,7 @@ func (m *InitMapResponse) Reset()         { *m = InitMapResponse{} }
 func (m *InitMapResponse) String() string { return proto.CompactTextString(m) }
 func (*InitMapResponse) ProtoMessage()    {}
 func (*InitMapResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_28d34dfba22a7ce2, []int{18}
+	return fileDescriptor_28d34dfba22a7ce2, []int{52}
 }
 
 func (m *InitMapResponse) XXX_Unmarshal(b []byte) error {
@@ -1004,14 +3048,48 @@ func init() {
 	proto.RegisterType((*GetMapLeavesByRevisionRequest)(nil), "trillian.GetMapLeavesByRevisionRequest")
 	proto.RegisterType((*GetMapLeafResponse)(nil), "trillian.GetMapLeafResponse")
 	proto.RegisterType((*GetMapLeavesResponse)(nil), "trillian.GetMapLeavesResponse")
+	proto.RegisterType((*GetMapLeafHistoryRequest)(nil), "trillian.GetMapLeafHistoryRequest")
+	proto.RegisterType((*GetMapLeafHistoryResponse)(nil), "trillian.GetMapLeafHistoryResponse")
+	proto.RegisterType((*GetMapLeafByHashRequest)(nil), "trillian.GetMapLeafByHashRequest")
+	proto.RegisterType((*GetMapConsistencyProofRequest)(nil), "trillian.GetMapConsistencyProofRequest")
+	proto.RegisterType((*GetMapConsistencyProofResponse)(nil), "trillian.GetMapConsistencyProofResponse")
+	proto.RegisterType((*GetMapLeavesByIndexPrefixRequest)(nil), "trillian.GetMapLeavesByIndexPrefixRequest")
+	proto.RegisterType((*GetMapLeavesByIndexPrefixResponse)(nil), "trillian.GetMapLeavesByIndexPrefixResponse")
 	proto.RegisterType((*GetLastInRangeByRevisionRequest)(nil), "trillian.GetLastInRangeByRevisionRequest")
+	proto.RegisterType((*ListMapLeavesByRevisionRequest)(nil), "trillian.ListMapLeavesByRevisionRequest")
+	proto.RegisterType((*ListMapLeavesByRevisionResponse)(nil), "trillian.ListMapLeavesByRevisionResponse")
 	proto.RegisterType((*SetMapLeavesRequest)(nil), "trillian.SetMapLeavesRequest")
 	proto.RegisterType((*SetMapLeavesResponse)(nil), "trillian.SetMapLeavesResponse")
+	proto.RegisterType((*SetMultiMapLeavesRequest)(nil), "trillian.SetMultiMapLeavesRequest")
+	proto.RegisterType((*SetMultiMapLeavesResponse)(nil), "trillian.SetMultiMapLeavesResponse")
 	proto.RegisterType((*WriteMapLeavesRequest)(nil), "trillian.WriteMapLeavesRequest")
 	proto.RegisterType((*WriteMapLeavesResponse)(nil), "trillian.WriteMapLeavesResponse")
+	proto.RegisterType((*DeleteMapLeafRangeRequest)(nil), "trillian.DeleteMapLeafRangeRequest")
+	proto.RegisterType((*DeleteMapLeafRangeResponse)(nil), "trillian.DeleteMapLeafRangeResponse")
+	proto.RegisterType((*ReserveMapRevisionRequest)(nil), "trillian.ReserveMapRevisionRequest")
+	proto.RegisterType((*ReserveMapRevisionResponse)(nil), "trillian.ReserveMapRevisionResponse")
+	proto.RegisterType((*GetMapHotKeysRequest)(nil), "trillian.GetMapHotKeysRequest")
+	proto.RegisterType((*MapPrefixWrites)(nil), "trillian.MapPrefixWrites")
+	proto.RegisterType((*GetMapHotKeysResponse)(nil), "trillian.GetMapHotKeysResponse")
 	proto.RegisterType((*GetSignedMapRootRequest)(nil), "trillian.GetSignedMapRootRequest")
 	proto.RegisterType((*GetSignedMapRootByRevisionRequest)(nil), "trillian.GetSignedMapRootByRevisionRequest")
 	proto.RegisterType((*GetSignedMapRootResponse)(nil), "trillian.GetSignedMapRootResponse")
+	proto.RegisterType((*GetMapCheckpointRequest)(nil), "trillian.GetMapCheckpointRequest")
+	proto.RegisterType((*GetMapCheckpointResponse)(nil), "trillian.GetMapCheckpointResponse")
+	proto.RegisterType((*ListSignedMapRootsRequest)(nil), "trillian.ListSignedMapRootsRequest")
+	proto.RegisterType((*ListSignedMapRootsResponse)(nil), "trillian.ListSignedMapRootsResponse")
+	proto.RegisterType((*WatchSignedMapRootsRequest)(nil), "trillian.WatchSignedMapRootsRequest")
+	proto.RegisterType((*WatchSignedMapRootsResponse)(nil), "trillian.WatchSignedMapRootsResponse")
+	proto.RegisterType((*MapRootSignature)(nil), "trillian.MapRootSignature")
+	proto.RegisterType((*AddMapRootSignatureRequest)(nil), "trillian.AddMapRootSignatureRequest")
+	proto.RegisterType((*AddMapRootSignatureResponse)(nil), "trillian.AddMapRootSignatureResponse")
+	proto.RegisterType((*GetMapRootSignaturesRequest)(nil), "trillian.GetMapRootSignaturesRequest")
+	proto.RegisterType((*GetMapRootSignaturesResponse)(nil), "trillian.GetMapRootSignaturesResponse")
+	proto.RegisterType((*ExportMapRequest)(nil), "trillian.ExportMapRequest")
+	proto.RegisterType((*MapLeafVersion)(nil), "trillian.MapLeafVersion")
+	proto.RegisterType((*ExportMapResponse)(nil), "trillian.ExportMapResponse")
+	proto.RegisterType((*ImportMapRevisionRequest)(nil), "trillian.ImportMapRevisionRequest")
+	proto.RegisterType((*ImportMapRevisionResponse)(nil), "trillian.ImportMapRevisionResponse")
 	proto.RegisterType((*InitMapRequest)(nil), "trillian.InitMapRequest")
 	proto.RegisterType((*InitMapResponse)(nil), "trillian.InitMapResponse")
 }
@@ -1019,69 +3097,160 @@ func init() {
 func init() { proto.RegisterFile("trillian_map_api.proto", fileDescriptor_28d34dfba22a7ce2) }
 
 var fileDescriptor_28d34dfba22a7ce2 = []byte{
-	// 988 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x57, 0x4f, 0x6f, 0xe3, 0x44,
-	0x14, 0x5f, 0xc7, 0xf9, 0xfb, 0x82, 0xba, 0xd9, 0x69, 0xb7, 0x9b, 0x75, 0x5b, 0xda, 0xf5, 0xaa,
-	0xea, 0x56, 0x2b, 0xc5, 0x34, 0x20, 0x0e, 0x15, 0x42, 0x50, 0x55, 0xf4, 0x8f, 0xda, 0x52, 0x39,
-	0xb0, 0x2b, 0xed, 0xc5, 0x4c, 0x93, 0x49, 0x32, 0x92, 0xe3, 0x31, 0xf6, 0xb4, 0x2a, 0xac, 0xf6,
-	0xc2, 0x01, 0x71, 0xe1, 0x02, 0xdc, 0x90, 0xfa, 0x4d, 0x38, 0xf1, 0x11, 0xf8, 0x0a, 0x7c, 0x10,
-	0x34, 0x33, 0x8e, 0x1d, 0x27, 0x4e, 0x1a, 0xb5, 0xec, 0xcd, 0xf3, 0xfe, 0xff, 0xe6, 0xf7, 0xde,
-	0x9b, 0x04, 0x96, 0x79, 0x40, 0x5d, 0x97, 0x62, 0xcf, 0x19, 0x60, 0xdf, 0xc1, 0x3e, 0x6d, 0xf8,
-	0x01, 0xe3, 0x0c, 0x95, 0x87, 0x72, 0x63, 0x61, 0xf8, 0xa5, 0x34, 0xc6, 0x6a, 0x8f, 0xb1, 0x9e,
-	0x4b, 0x2c, 0xec, 0x53, 0x0b, 0x7b, 0x1e, 0xe3, 0x98, 0x53, 0xe6, 0x85, 0x4a, 0x6b, 0xfe, 0x08,
-	0xa5, 0x53, 0xec, 0x9f, 0x10, 0xdc, 0x45, 0x4b, 0x50, 0xa0, 0x5e, 0x87, 0x5c, 0xd7, 0xb5, 0x0d,
-	0xed, 0xc5, 0x07, 0xb6, 0x3a, 0xa0, 0x15, 0xa8, 0xb8, 0x04, 0x77, 0x9d, 0x3e, 0x0e, 0xfb, 0xf5,
-	0x9c, 0xd4, 0x94, 0x85, 0xe0, 0x10, 0x87, 0x7d, 0xb4, 0x06, 0x20, 0x95, 0x57, 0xd8, 0xbd, 0x24,
-	0x75, 0x5d, 0x6a, 0xa5, 0xf9, 0x2b, 0x21, 0x10, 0x6a, 0x72, 0xcd, 0x03, 0xec, 0x74, 0x30, 0xc7,
-	0xf5, 0xbc, 0x52, 0x4b, 0xc9, 0x3e, 0xe6, 0xd8, 0xfc, 0x14, 0x2a, 0x2a, 0xf7, 0x15, 0x09, 0xd1,
-	0x36, 0x14, 0x5d, 0xf9, 0x55, 0xd7, 0x36, 0xf4, 0x17, 0xd5, 0xe6, 0xa3, 0x46, 0x8c, 0x23, 0x2a,
-	0xd0, 0x8e, 0x0c, 0xcc, 0xd7, 0x50, 0x8b, 0x44, 0x47, 0x5e, 0xdb, 0xbd, 0x0c, 0x29, 0xf3, 0xd0,
-	0x26, 0xe4, 0x45, 0x5e, 0x59, 0x7b, 0xa6, 0xb3, 0x54, 0xa3, 0x55, 0xa8, 0xd0, 0xa1, 0x4f, 0x3d,
-	0xb7, 0xa1, 0x8b, 0x82, 0x62, 0x81, 0x79, 0x08, 0x8b, 0x07, 0x84, 0xc7, 0x35, 0xd9, 0xe4, 0xfb,
-	0x4b, 0x12, 0x72, 0xf4, 0x18, 0x8a, 0xe2, 0xb2, 0x69, 0x47, 0x46, 0xd7, 0xed, 0xc2, 0x00, 0xfb,
-	0x47, 0x9d, 0xe4, 0xbe, 0x54, 0x1c, 0x75, 0x38, 0xce, 0x97, 0xf5, 0x5a, 0xde, 0xfc, 0x02, 0x1e,
-	0xc5, 0x91, 0xba, 0xf3, 0xc7, 0x49, 0xee, 0xdd, 0xec, 0xc2, 0x4a, 0x12, 0x61, 0xef, 0x07, 0x9b,
-	0x5c, 0x51, 0x51, 0xe3, 0x5d, 0x62, 0x21, 0x03, 0xca, 0x41, 0xe4, 0x2f, 0x49, 0xd2, 0xed, 0xf8,
-	0x6c, 0xf6, 0x61, 0x6d, 0x14, 0xf3, 0x5d, 0x32, 0xe9, 0xf3, 0x65, 0xfa, 0x4d, 0x03, 0x34, 0x7a,
-	0x29, 0xa1, 0xcf, 0xbc, 0x90, 0xa0, 0x43, 0x40, 0x22, 0xbe, 0xec, 0xa3, 0x84, 0x1b, 0xc5, 0xa3,
-	0x31, 0xc1, 0x63, 0xcc, 0xb8, 0x5d, 0x1b, 0x8c, 0xf7, 0x40, 0x13, 0xca, 0x22, 0x52, 0xc0, 0x18,
-	0x97, 0xf8, 0xab, 0xcd, 0x27, 0x89, 0x7f, 0x8b, 0xf6, 0x3c, 0xd2, 0x39, 0xc5, 0xbe, 0xcd, 0x18,
-	0xb7, 0x4b, 0x03, 0xf5, 0x61, 0xfe, 0xa1, 0xc1, 0x52, 0x9a, 0xf3, 0x99, 0x65, 0xe5, 0x64, 0x6f,
-	0xde, 0xbd, 0x2c, 0x7d, 0xce, 0xb2, 0x7e, 0xd5, 0x60, 0xfd, 0x80, 0xf0, 0x13, 0x1c, 0xf2, 0x23,
-	0xcf, 0xc6, 0x5e, 0x8f, 0xcc, 0x4d, 0xcc, 0x28, 0x05, 0xb9, 0x34, 0x05, 0x68, 0x19, 0x8a, 0x7e,
-	0x40, 0xba, 0xf4, 0x3a, 0x9a, 0xd5, 0xe8, 0x84, 0xd6, 0xa1, 0xaa, 0xbe, 0x9c, 0x0b, 0xca, 0x43,
-	0x39, 0xa9, 0x05, 0x1b, 0x94, 0x68, 0x8f, 0xf2, 0xd0, 0xfc, 0x53, 0x83, 0xc5, 0xd6, 0xfc, 0xa3,
-	0x91, 0x0c, 0x73, 0xee, 0x96, 0x61, 0x16, 0xe5, 0x0e, 0x08, 0xc7, 0x72, 0x43, 0x14, 0xd4, 0x7a,
-	0x19, 0x9e, 0x53, 0x50, 0x8a, 0x69, 0x28, 0x6a, 0xce, 0x8e, 0xf3, 0xe5, 0x7c, 0xad, 0x60, 0x1e,
-	0xc3, 0x52, 0x2b, 0x8b, 0xc3, 0xbb, 0x34, 0xc4, 0x8d, 0x06, 0x8f, 0x5f, 0x07, 0x94, 0x93, 0xf7,
-	0x8c, 0x55, 0x1f, 0xc3, 0xba, 0x05, 0x0f, 0xc9, 0xb5, 0x4f, 0xda, 0xdc, 0x89, 0x21, 0xe7, 0x65,
-	0x9a, 0x05, 0x25, 0x1e, 0xb2, 0x6f, 0x7e, 0x02, 0xcb, 0xe3, 0xf5, 0x45, 0x70, 0x47, 0xaf, 0x4b,
-	0x1b, 0x1b, 0xbe, 0x8f, 0xe0, 0xc9, 0x01, 0xe1, 0x69, 0xcc, 0x33, 0x71, 0x99, 0xaf, 0xe0, 0xd9,
-	0xb8, 0xc7, 0xff, 0xd1, 0x83, 0xe6, 0x19, 0xd4, 0x27, 0x2b, 0xb9, 0x07, 0x61, 0x5b, 0xb0, 0x70,
-	0xe4, 0x51, 0xc1, 0xfe, 0x2d, 0x80, 0xf6, 0xe1, 0x61, 0x6c, 0x18, 0xe5, 0xdb, 0x81, 0x52, 0x3b,
-	0x20, 0x98, 0x93, 0x4e, 0xb4, 0x70, 0xa6, 0xa7, 0x8b, 0xec, 0x9a, 0x7f, 0x97, 0xa1, 0xfa, 0x4d,
-	0x64, 0x73, 0x8a, 0x7d, 0xf4, 0x15, 0x94, 0xc4, 0xa0, 0x8a, 0xc7, 0x65, 0x25, 0x71, 0x9e, 0x58,
-	0xfe, 0xc6, 0x6a, 0xb6, 0x52, 0x15, 0x62, 0x3e, 0x40, 0x6f, 0xe4, 0x8b, 0x91, 0x5e, 0xf6, 0x68,
-	0x33, 0xcb, 0x69, 0x82, 0x85, 0x5b, 0x63, 0x9f, 0x40, 0x45, 0xc5, 0x16, 0x4d, 0xb8, 0x96, 0x61,
-	0x9c, 0x74, 0xb9, 0xf1, 0xe1, 0x34, 0x75, 0x1c, 0xed, 0x3b, 0xf9, 0x4a, 0x8e, 0x3f, 0x17, 0x68,
-	0x2b, 0xdb, 0x71, 0xb2, 0xda, 0xdb, 0x33, 0x38, 0x60, 0x64, 0x64, 0x38, 0x63, 0xe7, 0x01, 0x63,
-	0xdd, 0xf9, 0x13, 0x2d, 0x8e, 0x4f, 0xa2, 0xf8, 0xf1, 0xa0, 0xff, 0x92, 0xd3, 0xd0, 0x8d, 0x26,
-	0x9b, 0x30, 0x73, 0xbd, 0xa2, 0xed, 0x54, 0xfc, 0x59, 0x2b, 0xd8, 0x98, 0x9c, 0x75, 0x73, 0xff,
-	0xa7, 0x7f, 0xfe, 0xfd, 0x3d, 0xf7, 0x39, 0xfa, 0xcc, 0xba, 0xda, 0xb9, 0x20, 0x1c, 0xef, 0x58,
-	0x03, 0xec, 0x87, 0xd6, 0x5b, 0xd5, 0x91, 0xef, 0x2c, 0xd1, 0xdb, 0xa1, 0xf5, 0x76, 0x38, 0x0e,
-	0xef, 0x2c, 0xb5, 0x1b, 0x76, 0x5d, 0x1c, 0x72, 0x87, 0x7a, 0x4e, 0x20, 0x32, 0xa1, 0xaf, 0xa1,
-	0xd2, 0xca, 0x62, 0xac, 0x35, 0x9b, 0xb1, 0xac, 0x2d, 0xa8, 0x10, 0xff, 0xac, 0x41, 0x6d, 0x7c,
-	0xec, 0xd0, 0xb3, 0x14, 0xd2, 0xac, 0xe5, 0x60, 0x98, 0xb3, 0x4c, 0xa2, 0x04, 0x2f, 0x25, 0xe4,
-	0x4d, 0xf4, 0x7c, 0x16, 0xe4, 0x5d, 0x17, 0x73, 0x31, 0x9c, 0x37, 0x9a, 0x24, 0x77, 0xca, 0x5e,
-	0x41, 0x2f, 0xa7, 0xe7, 0x9b, 0xbc, 0xfe, 0x79, 0x8a, 0xb3, 0x64, 0x71, 0xdb, 0x68, 0x6b, 0x4e,
-	0x3e, 0x50, 0x1b, 0x4a, 0xd1, 0x9a, 0x40, 0xf5, 0x24, 0x7e, 0x7a, 0xc5, 0x18, 0x4f, 0x33, 0x34,
-	0x51, 0xc2, 0xe7, 0x32, 0xe1, 0x9a, 0xb9, 0x92, 0x9d, 0x70, 0x97, 0x7a, 0x94, 0x37, 0xff, 0xd2,
-	0xa0, 0x36, 0xb2, 0x45, 0xe4, 0x42, 0x47, 0xdf, 0xde, 0x73, 0xb0, 0x32, 0xfb, 0xfd, 0x01, 0xb2,
-	0xa1, 0x2a, 0xe3, 0x47, 0xdd, 0xb4, 0x9e, 0x58, 0x65, 0xbe, 0x73, 0xc6, 0xc6, 0x74, 0x83, 0xe1,
-	0x84, 0xee, 0x9d, 0xc1, 0xd3, 0x36, 0x1b, 0x34, 0xd4, 0x5f, 0x8b, 0x46, 0xfa, 0x1f, 0xc7, 0xde,
-	0xe2, 0x08, 0xb2, 0x2f, 0x7d, 0x7a, 0x2e, 0x84, 0xe7, 0xda, 0x1b, 0xa3, 0x47, 0x79, 0xff, 0xf2,
-	0xa2, 0xd1, 0x66, 0x03, 0x2b, 0xfa, 0x4f, 0x32, 0x74, 0xbc, 0x28, 0x4a, 0xcf, 0x8f, 0xff, 0x0b,
-	0x00, 0x00, 0xff, 0xff, 0x84, 0x5c, 0xb9, 0x8c, 0xdf, 0x0c, 0x00, 0x00,
+	// 2437 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x5a, 0x5b, 0x6f, 0x1b, 0xc7,
+	0x15, 0xce, 0xf2, 0x22, 0x91, 0x87, 0xd4, 0x6d, 0x24, 0x3b, 0xd4, 0xca, 0xb2, 0xa4, 0x91, 0x15,
+	0xdb, 0x71, 0x20, 0xda, 0x8a, 0x51, 0xb4, 0x46, 0xd3, 0x36, 0xb6, 0x13, 0x5b, 0x8e, 0x2f, 0xf2,
+	0xca, 0xb1, 0x8b, 0x14, 0xf0, 0x76, 0x45, 0x8e, 0xa4, 0x8d, 0xc9, 0xdd, 0xf5, 0xee, 0x50, 0x21,
+	0x1d, 0xe4, 0xa5, 0x28, 0x5a, 0x17, 0x45, 0x2f, 0x68, 0x51, 0xa0, 0x28, 0x8a, 0x3c, 0xf5, 0xa1,
+	0x3f, 0xa2, 0x40, 0xde, 0xfa, 0xd8, 0x97, 0xfe, 0x85, 0xfe, 0x90, 0x62, 0x2e, 0xbb, 0x1c, 0xee,
+	0x2e, 0x97, 0xb4, 0x98, 0x3c, 0x99, 0x7b, 0xe6, 0xcc, 0x99, 0x33, 0xdf, 0x39, 0x73, 0x6e, 0x16,
+	0x9c, 0xa5, 0xbe, 0xdd, 0x6a, 0xd9, 0x96, 0x63, 0xb6, 0x2d, 0xcf, 0xb4, 0x3c, 0x7b, 0xdb, 0xf3,
+	0x5d, 0xea, 0xa2, 0x52, 0x48, 0xd7, 0xf5, 0x86, 0xdf, 0xf3, 0xa8, 0x5b, 0x7f, 0x41, 0x7a, 0x81,
+	0x77, 0x20, 0xff, 0x11, 0x5c, 0xfa, 0x6c, 0xc8, 0x25, 0xbf, 0xcf, 0x1d, 0xb9, 0xee, 0x51, 0x8b,
+	0xd4, 0x2d, 0xcf, 0xae, 0x5b, 0x8e, 0xe3, 0x52, 0x8b, 0xda, 0xae, 0x13, 0x88, 0x55, 0xfc, 0x0a,
+	0xa6, 0x1f, 0x58, 0xde, 0x7d, 0x62, 0x1d, 0xa2, 0x25, 0x28, 0xda, 0x4e, 0x93, 0x74, 0x6b, 0xda,
+	0xba, 0x76, 0xa9, 0x6a, 0x88, 0x0f, 0xb4, 0x02, 0xe5, 0x16, 0xb1, 0x0e, 0xcd, 0x63, 0x2b, 0x38,
+	0xae, 0xe5, 0xf8, 0x4a, 0x89, 0x11, 0xee, 0x5a, 0xc1, 0x31, 0x5a, 0x05, 0xe0, 0x8b, 0x27, 0x56,
+	0xab, 0x43, 0x6a, 0x79, 0xbe, 0xca, 0xd9, 0x9f, 0x32, 0x02, 0x5b, 0x26, 0x5d, 0xea, 0x5b, 0x66,
+	0xd3, 0xa2, 0x56, 0xad, 0x20, 0x96, 0x39, 0xe5, 0xb6, 0x45, 0x2d, 0xfc, 0x39, 0x94, 0xc5, 0xd9,
+	0x27, 0x24, 0x40, 0x97, 0x61, 0xaa, 0xc5, 0x7f, 0xd5, 0xb4, 0xf5, 0xfc, 0xa5, 0xca, 0xce, 0xc2,
+	0x76, 0x74, 0x0f, 0xa9, 0xa0, 0x21, 0x19, 0xd0, 0x0e, 0x94, 0x18, 0x30, 0xbe, 0xeb, 0x52, 0xae,
+	0x51, 0x65, 0xe7, 0xed, 0x3e, 0xf3, 0xbe, 0x7d, 0xe4, 0x90, 0xe6, 0x03, 0xcb, 0x33, 0x5c, 0x97,
+	0x1a, 0xd3, 0x6d, 0xf1, 0x03, 0x3f, 0x83, 0x79, 0x29, 0x66, 0xd7, 0x69, 0xb4, 0x3a, 0x81, 0xed,
+	0x3a, 0x68, 0x0b, 0x0a, 0x4c, 0x57, 0x7e, 0xdf, 0xd4, 0x03, 0xf9, 0x32, 0x3a, 0x07, 0x65, 0x3b,
+	0xdc, 0x53, 0xcb, 0xad, 0xe7, 0xd9, 0x25, 0x22, 0x02, 0xfe, 0x8b, 0x06, 0x8b, 0x77, 0x08, 0x8d,
+	0x2e, 0x62, 0x90, 0x97, 0x1d, 0x12, 0x50, 0x74, 0x06, 0xa6, 0x98, 0x92, 0x76, 0x93, 0x8b, 0xcf,
+	0x1b, 0xc5, 0xb6, 0xe5, 0xed, 0x36, 0xfb, 0x20, 0x0b, 0x41, 0x12, 0xe4, 0xf7, 0x00, 0xb5, 0xad,
+	0xae, 0xe9, 0x93, 0xc0, 0x73, 0x9d, 0x80, 0x98, 0x07, 0x3d, 0x4a, 0x02, 0x0e, 0x58, 0xd1, 0x98,
+	0x6f, 0x5b, 0x5d, 0x43, 0x2e, 0xdc, 0x64, 0x74, 0x06, 0xab, 0x67, 0x1d, 0x11, 0x93, 0xba, 0x2f,
+	0x88, 0x53, 0x2b, 0xae, 0x6b, 0x97, 0xca, 0x46, 0x99, 0x51, 0x9e, 0x30, 0xc2, 0xbd, 0x42, 0x29,
+	0x3f, 0x5f, 0xc0, 0x3f, 0x81, 0x85, 0x48, 0xad, 0xc3, 0xf1, 0x95, 0xea, 0x5b, 0x1e, 0x1f, 0xc2,
+	0x4a, 0x5f, 0xc2, 0xcd, 0x9e, 0x41, 0x4e, 0x6c, 0x76, 0xe3, 0xd3, 0xc8, 0x42, 0x3a, 0x94, 0x7c,
+	0xb9, 0x9f, 0xbb, 0x49, 0xde, 0x88, 0xbe, 0xf1, 0x9f, 0x34, 0x58, 0x55, 0x11, 0x3c, 0xcd, 0x51,
+	0xf9, 0xb1, 0x8e, 0x42, 0x97, 0x60, 0x9e, 0x5b, 0xae, 0x49, 0xcc, 0xc8, 0x83, 0x18, 0xca, 0x25,
+	0x63, 0x56, 0xd2, 0xa5, 0xe3, 0x30, 0xa5, 0x90, 0x8a, 0x9f, 0xc0, 0x1f, 0xdd, 0x65, 0x86, 0xf2,
+	0x4c, 0xee, 0xf4, 0x7d, 0xa7, 0x10, 0x0e, 0xa4, 0x27, 0x1c, 0x28, 0x72, 0x35, 0x66, 0xc4, 0x98,
+	0xf3, 0x9d, 0xc6, 0x89, 0xff, 0xa5, 0xc1, 0xd2, 0xa0, 0xaf, 0x65, 0xaa, 0x95, 0x5b, 0xcf, 0x4f,
+	0xa4, 0x56, 0x7e, 0x3c, 0xb5, 0xd0, 0x3b, 0x30, 0xe7, 0x90, 0x2e, 0x35, 0x15, 0xa7, 0x2c, 0x70,
+	0xa7, 0x9c, 0x61, 0xe4, 0xbd, 0xd0, 0x31, 0xf1, 0x2f, 0x35, 0xa8, 0xf5, 0x31, 0xbd, 0x6b, 0x07,
+	0xd4, 0xf5, 0x7b, 0xa7, 0x72, 0xa7, 0x2d, 0x98, 0x0d, 0xa8, 0xe5, 0x53, 0x33, 0x66, 0xe9, 0x19,
+	0x4e, 0x0d, 0xdd, 0x87, 0x6d, 0x6e, 0xb8, 0x1d, 0x87, 0xca, 0x97, 0x24, 0x3e, 0xf0, 0x63, 0x58,
+	0x4e, 0xd1, 0x42, 0x22, 0x79, 0x3d, 0x16, 0x86, 0xce, 0xf5, 0x6f, 0x9f, 0x74, 0x87, 0x30, 0x22,
+	0x61, 0x1b, 0xde, 0x56, 0x9f, 0x0a, 0x8b, 0x8d, 0x23, 0xee, 0x95, 0x19, 0x56, 0xb3, 0x5e, 0xcb,
+	0xdf, 0xa3, 0xd7, 0x72, 0xcb, 0x75, 0x02, 0x3b, 0xa0, 0xc4, 0x69, 0xf4, 0xf6, 0x7c, 0xd7, 0x1d,
+	0xf5, 0xc8, 0xb7, 0x60, 0xf6, 0xd0, 0xf6, 0x03, 0x05, 0xb3, 0x9c, 0xc0, 0x8c, 0x53, 0x23, 0xcc,
+	0x2e, 0xc2, 0x5c, 0x40, 0x1a, 0xae, 0xd3, 0x8c, 0x63, 0x3b, 0x2b, 0xc8, 0x2a, 0xb8, 0xc2, 0x32,
+	0x05, 0xe5, 0xf5, 0xe1, 0x7f, 0xe4, 0xe0, 0xfc, 0x30, 0xf5, 0x24, 0xc4, 0x1f, 0x84, 0x8a, 0x44,
+	0x8e, 0xa6, 0x65, 0x3b, 0x5a, 0x95, 0xb3, 0xcb, 0x2f, 0xf4, 0xe3, 0x48, 0xc1, 0x71, 0xdf, 0xcf,
+	0x8c, 0xe0, 0x0f, 0x05, 0x5c, 0x07, 0x21, 0xd0, 0x94, 0x86, 0xce, 0x0f, 0xcb, 0x37, 0x15, 0xce,
+	0x26, 0xf3, 0xd3, 0xf7, 0x40, 0x8a, 0x09, 0xb7, 0x15, 0x86, 0x6d, 0xab, 0x0a, 0x3e, 0xb9, 0x6f,
+	0x09, 0x8a, 0x1e, 0xbb, 0x7e, 0xad, 0x28, 0x60, 0xe2, 0x1f, 0xb8, 0x0b, 0xeb, 0x83, 0x21, 0x6f,
+	0x97, 0xa1, 0xb7, 0xe7, 0x93, 0x43, 0xbb, 0x3b, 0xc2, 0x8e, 0x1b, 0x50, 0xe5, 0x50, 0x9b, 0x1e,
+	0xe7, 0x96, 0xce, 0x53, 0xb1, 0xfb, 0x02, 0x32, 0xfd, 0xe7, 0xaf, 0x1a, 0x6c, 0x64, 0x1c, 0x2d,
+	0x6d, 0xa4, 0x86, 0x01, 0x6d, 0xcc, 0x30, 0xd0, 0xcf, 0xe0, 0xb9, 0x51, 0x19, 0x3c, 0x02, 0x25,
+	0xaf, 0x82, 0xf2, 0x3b, 0x0d, 0xd6, 0xee, 0x10, 0x7a, 0xdf, 0x0a, 0xe8, 0xae, 0x63, 0x58, 0xce,
+	0x11, 0x19, 0x3b, 0x15, 0xa8, 0x37, 0xce, 0xc5, 0x82, 0xfe, 0x59, 0x98, 0x92, 0x50, 0x89, 0x02,
+	0x45, 0x7e, 0xa1, 0x35, 0xa8, 0x88, 0x5f, 0xe6, 0x81, 0x4d, 0xc3, 0x6c, 0x0b, 0x82, 0x74, 0xd3,
+	0xa6, 0x01, 0xfe, 0x83, 0x06, 0xe7, 0xef, 0xdb, 0xc1, 0x29, 0x32, 0x53, 0x96, 0x3a, 0x2b, 0xc0,
+	0x73, 0xb5, 0x19, 0xd8, 0xaf, 0x44, 0xc9, 0x54, 0x34, 0x4a, 0x8c, 0xb0, 0x6f, 0xbf, 0x22, 0xb1,
+	0xd4, 0x5e, 0x88, 0xa5, 0x76, 0xfc, 0x4f, 0x0d, 0xd6, 0x86, 0x6a, 0x24, 0x4d, 0xf7, 0x06, 0x85,
+	0x54, 0x4a, 0xe0, 0xce, 0xa5, 0x04, 0xee, 0xd3, 0x24, 0x05, 0xfc, 0x3a, 0x07, 0x8b, 0xfb, 0xe3,
+	0xd7, 0x45, 0x6f, 0xe0, 0x3c, 0x3a, 0x94, 0xda, 0x84, 0x5a, 0xbc, 0xa6, 0x2c, 0x8a, 0xc8, 0x19,
+	0x7e, 0x0f, 0x00, 0x3f, 0x15, 0x03, 0xfe, 0x0a, 0x2c, 0xd8, 0x4d, 0xd2, 0xf6, 0x5c, 0x1e, 0x93,
+	0xe4, 0x7d, 0xa7, 0xb9, 0x80, 0x79, 0x65, 0x41, 0x5c, 0xf9, 0x6d, 0x98, 0x6e, 0xfa, 0x3d, 0xd3,
+	0xef, 0x38, 0xb5, 0x12, 0x2f, 0x10, 0xa6, 0x9a, 0x7e, 0xcf, 0xe8, 0xb0, 0xa2, 0x71, 0x36, 0x94,
+	0xc8, 0x22, 0x41, 0x40, 0x6a, 0x65, 0x2e, 0x62, 0x26, 0xa4, 0xde, 0x67, 0x44, 0x51, 0x84, 0xdd,
+	0x2b, 0x94, 0x0a, 0xf3, 0x45, 0x7c, 0x0f, 0x96, 0xf6, 0xd3, 0xb2, 0xf6, 0x69, 0x4a, 0x80, 0x4f,
+	0xa1, 0xc6, 0x64, 0x75, 0x5a, 0xd4, 0x4e, 0x40, 0xfb, 0x03, 0x76, 0x79, 0xfe, 0x33, 0xb4, 0xfd,
+	0xaa, 0x22, 0x2f, 0x69, 0x0b, 0x23, 0x62, 0x67, 0x39, 0x31, 0x45, 0x6c, 0x94, 0x13, 0xcb, 0xa1,
+	0x9e, 0xa1, 0xe0, 0xa1, 0x8a, 0x96, 0xa4, 0xa2, 0x01, 0xfe, 0x6d, 0x0e, 0xce, 0x3c, 0xf3, 0x6d,
+	0x4a, 0xbe, 0x63, 0x17, 0xc8, 0xc7, 0x5c, 0xe0, 0x22, 0xcc, 0x91, 0xae, 0x47, 0x1a, 0x4a, 0xa2,
+	0x2b, 0x88, 0x04, 0x26, 0xc8, 0x46, 0xa6, 0x3f, 0x14, 0x47, 0xfb, 0xc3, 0xd4, 0x08, 0x7f, 0x98,
+	0x4e, 0xf1, 0x07, 0xfc, 0x18, 0xce, 0xc6, 0xc1, 0x90, 0xe8, 0xaa, 0x2e, 0xab, 0x25, 0x63, 0x05,
+	0x43, 0x7d, 0xa0, 0x4a, 0x60, 0x04, 0x56, 0x25, 0xe0, 0xbf, 0x69, 0xb0, 0x7c, 0x9b, 0xb4, 0x48,
+	0x28, 0xf4, 0x90, 0x87, 0xcc, 0x6f, 0x25, 0x7b, 0x4c, 0x0c, 0x2e, 0x7e, 0x09, 0x7a, 0x9a, 0x6e,
+	0x63, 0xdc, 0x79, 0x13, 0x66, 0x9a, 0x7c, 0x67, 0xd3, 0x14, 0xc5, 0x9b, 0x08, 0xa0, 0x55, 0x49,
+	0xbc, 0xc5, 0x68, 0x08, 0x41, 0xa1, 0xed, 0xfa, 0x22, 0x7e, 0x96, 0x0c, 0xfe, 0x1b, 0x37, 0x61,
+	0xd9, 0x20, 0x01, 0xf1, 0x4f, 0xd8, 0x99, 0x63, 0x06, 0xea, 0xab, 0xb0, 0xc4, 0x8d, 0x66, 0x36,
+	0x3b, 0x3e, 0xef, 0x8b, 0x4d, 0xc7, 0x72, 0xdc, 0x40, 0x9e, 0x89, 0xf8, 0xda, 0x6d, 0xb9, 0xf4,
+	0x90, 0xad, 0xe0, 0x5f, 0x6b, 0xa0, 0xa7, 0x1d, 0x33, 0xc6, 0xcd, 0xd6, 0xa0, 0x22, 0x0e, 0xeb,
+	0x87, 0xda, 0xaa, 0x01, 0x9c, 0x24, 0x9c, 0xec, 0x3d, 0x10, 0x27, 0x9a, 0xa4, 0xeb, 0xd9, 0x7e,
+	0x4f, 0xea, 0x22, 0x32, 0xf8, 0x3c, 0x5f, 0xf9, 0x88, 0x2f, 0x08, 0x4d, 0x6e, 0x85, 0xcd, 0xc0,
+	0x5d, 0x97, 0x7e, 0x42, 0x7a, 0x63, 0x74, 0x9e, 0x2d, 0xbb, 0x6d, 0x0b, 0x3c, 0x8b, 0x86, 0xf8,
+	0xc0, 0x2f, 0x61, 0xee, 0x81, 0xe5, 0x09, 0xcb, 0x73, 0x07, 0x0d, 0x12, 0x2e, 0xa2, 0x25, 0x5d,
+	0xe4, 0x2c, 0x4c, 0x7d, 0xc1, 0x99, 0x25, 0x50, 0xf2, 0x8b, 0xd9, 0x8e, 0xf5, 0xb1, 0xee, 0x09,
+	0xf1, 0x85, 0xed, 0x84, 0xee, 0xd5, 0xb6, 0xd5, 0x7d, 0x14, 0xd2, 0xf0, 0x37, 0x1a, 0x9c, 0x89,
+	0x29, 0x2e, 0xc1, 0xdb, 0x80, 0x6a, 0x98, 0x91, 0x79, 0x03, 0xac, 0x71, 0x4d, 0x65, 0x96, 0x16,
+	0xbd, 0xef, 0x06, 0x54, 0xa9, 0x4b, 0xad, 0x96, 0x39, 0x70, 0x7e, 0x85, 0xd3, 0xa4, 0xfe, 0xef,
+	0xc2, 0x02, 0x3f, 0xc8, 0x14, 0x2d, 0x82, 0x0a, 0xe2, 0x1c, 0x5f, 0xd8, 0x67, 0x74, 0x8e, 0x21,
+	0xba, 0x0e, 0xa5, 0x63, 0x97, 0x9a, 0x6c, 0x80, 0x22, 0x0b, 0xba, 0xe5, 0x81, 0xa8, 0xa3, 0x02,
+	0x63, 0x4c, 0x1f, 0x0b, 0x7d, 0xf1, 0x55, 0x5e, 0xee, 0x0f, 0x06, 0xbe, 0x4c, 0xf0, 0xf1, 0x53,
+	0xd8, 0x88, 0xef, 0xf8, 0x36, 0x8a, 0x09, 0xfc, 0x10, 0x6a, 0x71, 0xb9, 0x13, 0xa5, 0x97, 0xab,
+	0x61, 0x23, 0x73, 0xeb, 0x98, 0x34, 0x5e, 0x78, 0xae, 0xed, 0x8c, 0xba, 0x99, 0x03, 0xb5, 0xe4,
+	0x0e, 0xa9, 0xc1, 0x79, 0x80, 0x46, 0x44, 0x95, 0x7e, 0xa4, 0x50, 0x4e, 0xa5, 0xe1, 0x37, 0x39,
+	0x58, 0x66, 0x25, 0xd0, 0xc0, 0x72, 0x30, 0xba, 0xf7, 0x89, 0xf5, 0x8b, 0xb9, 0xb4, 0x7e, 0x71,
+	0x03, 0xaa, 0x24, 0xd9, 0xf8, 0x54, 0x88, 0xd2, 0xf5, 0xec, 0xc0, 0x19, 0x21, 0x89, 0xda, 0x6d,
+	0x12, 0x50, 0xab, 0xed, 0x49, 0x07, 0x13, 0x61, 0x70, 0x91, 0x2f, 0x3e, 0x09, 0xd7, 0x84, 0x93,
+	0x6d, 0xc3, 0x22, 0x13, 0x1b, 0xdf, 0x51, 0xe4, 0x3b, 0x16, 0x88, 0xd3, 0x8c, 0xf1, 0x6f, 0x40,
+	0xd5, 0x21, 0x5f, 0x90, 0x80, 0x9a, 0xbc, 0x01, 0x91, 0x09, 0xa7, 0x22, 0x68, 0x1f, 0x33, 0xd2,
+	0x60, 0x11, 0x39, 0x9d, 0x59, 0x44, 0x96, 0xe2, 0x45, 0xe4, 0x2b, 0xd0, 0xd3, 0x00, 0x9c, 0x24,
+	0xd9, 0x8f, 0x5b, 0x49, 0xe2, 0xf7, 0x41, 0x7f, 0x66, 0xd1, 0xc6, 0xf1, 0x9b, 0x58, 0x0f, 0x3f,
+	0x86, 0x95, 0xd4, 0x4d, 0xa7, 0xef, 0x55, 0xf0, 0x01, 0x1f, 0x07, 0xb2, 0x9f, 0x8c, 0xc1, 0xa2,
+	0x1d, 0x9f, 0xa0, 0xab, 0x00, 0x5e, 0xe7, 0xa0, 0x65, 0x37, 0x58, 0x38, 0x88, 0x86, 0x82, 0x72,
+	0xb6, 0xba, 0xc7, 0x57, 0x3e, 0x21, 0x3d, 0xa3, 0xec, 0x85, 0x3f, 0xd9, 0x64, 0x30, 0x08, 0xb7,
+	0xcb, 0x70, 0xde, 0x27, 0xe0, 0xdf, 0x68, 0xa0, 0x7f, 0xd8, 0x6c, 0xc6, 0xcf, 0x99, 0xa0, 0x75,
+	0xf8, 0xbe, 0x7a, 0x5e, 0x3e, 0x65, 0xe8, 0x34, 0x78, 0x90, 0xa2, 0xcb, 0x2a, 0xac, 0xa4, 0xaa,
+	0x22, 0x20, 0xc4, 0x7b, 0xe1, 0xa8, 0x6f, 0x60, 0x39, 0x98, 0x20, 0x30, 0xfd, 0x5e, 0x83, 0x73,
+	0xe9, 0x22, 0x27, 0xe8, 0x30, 0x6f, 0x00, 0x44, 0x57, 0x0a, 0x52, 0xc7, 0x5b, 0x83, 0xd7, 0x53,
+	0xb8, 0x99, 0xc5, 0x3f, 0xea, 0x7a, 0xae, 0xcf, 0x55, 0xfa, 0x6e, 0xba, 0x37, 0xbc, 0x0f, 0xb3,
+	0xb2, 0xdc, 0x79, 0x4a, 0x7c, 0xce, 0x9e, 0x55, 0x0e, 0x84, 0xe3, 0xe7, 0x5c, 0xe6, 0xf8, 0x19,
+	0xbf, 0xd6, 0x60, 0x41, 0xd1, 0x7c, 0xa2, 0x67, 0xfa, 0x01, 0xcc, 0x88, 0x79, 0xbd, 0x50, 0x2f,
+	0xc4, 0xb0, 0x96, 0x38, 0x5b, 0xea, 0x6f, 0x54, 0x5b, 0xfd, 0x8f, 0x00, 0xff, 0x51, 0x83, 0xda,
+	0x6e, 0x3b, 0x52, 0x65, 0xac, 0xec, 0x75, 0x8a, 0x18, 0xaf, 0x74, 0x02, 0xf9, 0x11, 0x9d, 0x00,
+	0x7e, 0x04, 0xcb, 0x29, 0x1a, 0x4d, 0x10, 0x19, 0x2e, 0xc2, 0xec, 0xae, 0x63, 0x8f, 0xf6, 0x12,
+	0x7c, 0x1b, 0xe6, 0x22, 0x46, 0x79, 0xde, 0x35, 0x98, 0x6e, 0xf8, 0xc4, 0xa2, 0xa4, 0x39, 0xf2,
+	0x38, 0xc9, 0xb7, 0xf3, 0x6f, 0x04, 0x95, 0x27, 0x92, 0xe7, 0x81, 0xe5, 0xa1, 0x8f, 0x61, 0x9a,
+	0x8d, 0x40, 0xd8, 0xff, 0x3b, 0xac, 0xa4, 0x8f, 0x1e, 0xb9, 0x52, 0x7a, 0xe6, 0x5c, 0x12, 0xbf,
+	0x85, 0x3e, 0xe3, 0xe3, 0xff, 0xc1, 0xc9, 0x3d, 0xda, 0x4a, 0xdb, 0x94, 0xa8, 0x43, 0x46, 0xca,
+	0xbe, 0x0f, 0x65, 0x21, 0x9b, 0xb5, 0x62, 0xab, 0x29, 0xcc, 0xfd, 0x5e, 0x4f, 0x3f, 0x3f, 0x6c,
+	0x39, 0x92, 0xf6, 0x73, 0xfe, 0xff, 0x27, 0xf1, 0x71, 0x06, 0xba, 0x98, 0xbe, 0x31, 0xa9, 0xed,
+	0xe8, 0x13, 0x7e, 0x06, 0xb3, 0x12, 0x0b, 0x39, 0xed, 0x45, 0x38, 0xed, 0x86, 0x83, 0x03, 0x69,
+	0x7d, 0x33, 0x93, 0x27, 0x12, 0xfe, 0x04, 0x66, 0x22, 0xa0, 0xf9, 0xf0, 0x76, 0x23, 0x1d, 0x64,
+	0x65, 0x26, 0x3c, 0x86, 0xca, 0x9f, 0x73, 0x50, 0xe2, 0x23, 0xd4, 0x24, 0x28, 0x43, 0x66, 0xc0,
+	0xfa, 0xa5, 0xd1, 0x8c, 0xd1, 0x59, 0x01, 0x9c, 0x55, 0x0c, 0xa0, 0x4c, 0x03, 0xd1, 0xbb, 0xc3,
+	0x6c, 0x90, 0x9c, 0x56, 0xea, 0x57, 0xc6, 0xe2, 0x8d, 0x0e, 0x35, 0x41, 0x4f, 0xb1, 0xfa, 0x43,
+	0x77, 0xc8, 0x3d, 0x87, 0x19, 0x7f, 0x31, 0x1e, 0x19, 0x58, 0x4c, 0xc8, 0xbf, 0xce, 0x69, 0xe8,
+	0x6b, 0xf1, 0x9f, 0x0d, 0xa9, 0xc3, 0x44, 0x74, 0x79, 0x40, 0x7e, 0xd6, 0xc0, 0x51, 0x4f, 0xc6,
+	0x1e, 0x7c, 0xfb, 0x17, 0xff, 0xfd, 0xdf, 0x9f, 0x73, 0x3f, 0x42, 0x3f, 0xac, 0x9f, 0x5c, 0x3b,
+	0x20, 0xd4, 0xba, 0x56, 0x6f, 0x5b, 0x5e, 0x50, 0xff, 0x52, 0x44, 0x89, 0xaf, 0xea, 0x3c, 0x28,
+	0xd7, 0xbf, 0x0c, 0x03, 0xfd, 0x57, 0x75, 0x11, 0xab, 0x6e, 0xb4, 0xac, 0x80, 0x9a, 0xb6, 0x63,
+	0xfa, 0xec, 0x24, 0xe4, 0xc2, 0x12, 0x2b, 0xc3, 0x12, 0x8e, 0xaf, 0x98, 0x2e, 0x7b, 0xf8, 0xa8,
+	0x5f, 0x1e, 0x83, 0x33, 0x04, 0xfc, 0xaa, 0x86, 0x1e, 0x41, 0x79, 0x3f, 0xed, 0xd9, 0xee, 0x67,
+	0x3f, 0xdb, 0xb4, 0xd1, 0x95, 0x80, 0xf8, 0x39, 0x2c, 0x24, 0x86, 0x46, 0xea, 0xd3, 0x1a, 0x36,
+	0xa8, 0xd2, 0x37, 0x33, 0x79, 0x22, 0x1f, 0xf9, 0x95, 0x06, 0xf3, 0xf1, 0xee, 0x26, 0xf6, 0xbc,
+	0xd2, 0x7a, 0x30, 0x1d, 0x67, 0xb1, 0x48, 0xe9, 0x57, 0xb8, 0x0d, 0xb7, 0xd0, 0x66, 0x96, 0x0d,
+	0x6f, 0xb4, 0x2c, 0xca, 0x32, 0xc0, 0xd7, 0x1a, 0xe8, 0x71, 0x49, 0x8a, 0xc5, 0xae, 0x0c, 0x3f,
+	0x2f, 0x69, 0xb4, 0x71, 0x94, 0xab, 0x73, 0xe5, 0x2e, 0xa3, 0x8b, 0x63, 0x3a, 0x18, 0xb2, 0x00,
+	0x25, 0x4b, 0x7a, 0xb4, 0x39, 0xe8, 0x1f, 0xa9, 0x35, 0xb7, 0x7e, 0x21, 0x9b, 0x29, 0x32, 0xc6,
+	0x21, 0x2c, 0xa6, 0x14, 0xe1, 0x48, 0xd9, 0x3e, 0xbc, 0xb0, 0xd7, 0xb7, 0x46, 0x70, 0x29, 0x5e,
+	0xda, 0x84, 0xc5, 0x94, 0x4a, 0x55, 0x3d, 0x67, 0x78, 0x4d, 0xad, 0x6f, 0x8d, 0xe0, 0x8a, 0x6e,
+	0x73, 0x14, 0xce, 0x4e, 0x06, 0x18, 0x82, 0x64, 0x86, 0x4c, 0x2d, 0x88, 0xf5, 0x77, 0x46, 0xb1,
+	0x45, 0x07, 0xfd, 0x94, 0xa7, 0x87, 0x7e, 0x6f, 0x9c, 0x4c, 0x0f, 0x89, 0x4e, 0x5b, 0xc7, 0x59,
+	0x2c, 0x91, 0xe4, 0xbb, 0x50, 0x8e, 0xca, 0x42, 0xa4, 0x54, 0xc1, 0xf1, 0x2a, 0x57, 0x5f, 0x49,
+	0x5d, 0x53, 0x20, 0x7f, 0x0e, 0x0b, 0x89, 0x1a, 0x4a, 0x7d, 0xc7, 0xc3, 0x4a, 0x3e, 0x7d, 0x33,
+	0x93, 0x27, 0xd2, 0xb4, 0x01, 0xd3, 0xb2, 0x52, 0x42, 0x4a, 0xa5, 0x39, 0x58, 0x65, 0xe9, 0xcb,
+	0x29, 0x2b, 0x52, 0xc2, 0x26, 0x7f, 0x0e, 0xab, 0x78, 0x25, 0xfd, 0x39, 0xdc, 0xb0, 0x1d, 0x9b,
+	0xee, 0xfc, 0x27, 0x0f, 0xf3, 0x4a, 0x21, 0xc5, 0x47, 0x36, 0xe8, 0xd3, 0x09, 0x6b, 0x8b, 0xd4,
+	0xf4, 0xf2, 0x16, 0x32, 0xa0, 0xc2, 0xe5, 0x0b, 0x02, 0x5a, 0x53, 0xbc, 0x3b, 0x6d, 0xe0, 0xad,
+	0xaf, 0x0f, 0x67, 0x88, 0x40, 0x7a, 0x0e, 0x73, 0x62, 0x60, 0x1a, 0x4d, 0x4b, 0xd5, 0xf7, 0x3b,
+	0x74, 0xce, 0xab, 0x5f, 0xc8, 0x66, 0x52, 0xe5, 0xcb, 0xb1, 0x65, 0x04, 0x83, 0x22, 0x7f, 0xe8,
+	0xe0, 0x54, 0xbf, 0x90, 0xcd, 0x14, 0xc9, 0x7f, 0x04, 0x70, 0x87, 0x50, 0x39, 0xd1, 0x43, 0x89,
+	0x0a, 0x67, 0x70, 0x46, 0xa9, 0xaf, 0x0d, 0x5d, 0x0f, 0x05, 0xde, 0x7c, 0x08, 0xcb, 0x0d, 0xb7,
+	0xbd, 0x2d, 0xfe, 0x7a, 0x69, 0x7b, 0xf0, 0x8f, 0x9a, 0x6e, 0x2e, 0x2a, 0xa6, 0xfe, 0xd0, 0xb3,
+	0xf7, 0x18, 0x71, 0x4f, 0xfb, 0x4c, 0x3f, 0xb2, 0xe9, 0x71, 0xe7, 0x60, 0xbb, 0xe1, 0xb6, 0xeb,
+	0xf2, 0xcf, 0x9e, 0xc2, 0x8d, 0x07, 0x53, 0x7c, 0xe7, 0xfb, 0xff, 0x1f, 0x00, 0x4b, 0x45, 0x26,
+	0x85, 0x5e, 0x25, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -1103,16 +3272,69 @@ type TrillianMapClient interface {
 	GetLeafByRevision(ctx context.Context, in *GetMapLeafByRevisionRequest, opts ...grpc.CallOption) (*GetMapLeafResponse, error)
 	GetLeaves(ctx context.Context, in *GetMapLeavesRequest, opts ...grpc.CallOption) (*GetMapLeavesResponse, error)
 	GetLeavesByRevision(ctx context.Context, in *GetMapLeavesByRevisionRequest, opts ...grpc.CallOption) (*GetMapLeavesResponse, error)
-	// Deprecated: this should only be used be writers, which should migrate
+	// GetLeafHistory returns the value of a leaf and its inclusion proof at each
+	// of a range of revisions, in a single round trip.
+	GetLeafHistory(ctx context.Context, in *GetMapLeafHistoryRequest, opts ...grpc.CallOption) (*GetMapLeafHistoryResponse, error)
+	// GetLeafByHash returns the leaves whose value has a leaf hash at a
+	// revision, with their inclusion proofs, so that verifiers which only hold
+	// a leaf hash can locate and prove the map entry. Usually at most one leaf
+	// matches. It needs the map to maintain a leaf hash index, which is enabled
+	// in the storage settings of the map when it is created.
+	GetLeafByHash(ctx context.Context, in *GetMapLeafByHashRequest, opts ...grpc.CallOption) (*GetMapLeavesResponse, error)
+	// GetConsistencyProof returns a proof that a revision of the map was
+	// derived from an earlier one by changing only a claimed set of leaves, so
+	// that mirrors and auditors need not replay every write in between.
+	GetConsistencyProof(ctx context.Context, in *GetMapConsistencyProofRequest, opts ...grpc.CallOption) (*GetMapConsistencyProofResponse, error)
+	// GetLeavesByIndexPrefix returns all the leaves whose indices start with a
+	// prefix at a revision, with a single proof that they are all of them.
+	GetLeavesByIndexPrefix(ctx context.Context, in *GetMapLeavesByIndexPrefixRequest, opts ...grpc.CallOption) (*GetMapLeavesByIndexPrefixResponse, error)
+	// Deprecated: this should only be used by writers, which should migrate
 	// to TrillianMapWrite#GetLeavesByRevision
 	GetLeavesByRevisionNoProof(ctx context.Context, in *GetMapLeavesByRevisionRequest, opts ...grpc.CallOption) (*MapLeaves, error)
 	// GetLastInRangeByRevision returns the last leaf in a requested range.
 	GetLastInRangeByRevision(ctx context.Context, in *GetLastInRangeByRevisionRequest, opts ...grpc.CallOption) (*MapLeaf, error)
-	// Deprecated: this should only be used be writers, which should migrate
+	// ListLeavesByRevision streams all the populated leaves of the map at the
+	// given revision, in ascending index order. Each response holds up to
+	// page_size leaves and a token that can be used to resume the listing.
+	ListLeavesByRevision(ctx context.Context, in *ListMapLeavesByRevisionRequest, opts ...grpc.CallOption) (TrillianMap_ListLeavesByRevisionClient, error)
+	// Deprecated: this should only be used by writers, which should migrate
 	// to TrillianMapWrite#WriteLeaves
 	SetLeaves(ctx context.Context, in *SetMapLeavesRequest, opts ...grpc.CallOption) (*SetMapLeavesResponse, error)
+	// SetMultiMapLeaves atomically applies leaf updates to several maps in a
+	// single storage transaction. Either every map gets a new revision, or none
+	// of them do.
+	SetMultiMapLeaves(ctx context.Context, in *SetMultiMapLeavesRequest, opts ...grpc.CallOption) (*SetMultiMapLeavesResponse, error)
 	GetSignedMapRoot(ctx context.Context, in *GetSignedMapRootRequest, opts ...grpc.CallOption) (*GetSignedMapRootResponse, error)
 	GetSignedMapRootByRevision(ctx context.Context, in *GetSignedMapRootByRevisionRequest, opts ...grpc.CallOption) (*GetSignedMapRootResponse, error)
+	// ListSignedMapRoots returns the roots of the map which match a range of
+	// revisions and a range of publication times, in pages.
+	ListSignedMapRoots(ctx context.Context, in *ListSignedMapRootsRequest, opts ...grpc.CallOption) (*ListSignedMapRootsResponse, error)
+	// WatchSignedMapRoots streams the latest root of the map, followed by each
+	// newer root as it is published, in revision order. The stream only ends
+	// when the client cancels it or an error occurs.
+	WatchSignedMapRoots(ctx context.Context, in *WatchSignedMapRootsRequest, opts ...grpc.CallOption) (TrillianMap_WatchSignedMapRootsClient, error)
+	// AddMapRootSignature stores the signature of a witness over the root of
+	// the map at a revision, once it has been verified with the witness's
+	// public key. A later signature with the same key replaces it. The map
+	// doesn't vouch for the witnesses: clients decide which keys they trust.
+	AddMapRootSignature(ctx context.Context, in *AddMapRootSignatureRequest, opts ...grpc.CallOption) (*AddMapRootSignatureResponse, error)
+	// GetMapRootSignatures returns the root of the map at a revision, along
+	// with the witness signatures stored for it.
+	GetMapRootSignatures(ctx context.Context, in *GetMapRootSignaturesRequest, opts ...grpc.CallOption) (*GetMapRootSignaturesResponse, error)
+	// GetCheckpoint returns the latest root of the map as a signed checkpoint,
+	// as in TrillianLog.GetCheckpoint. Its size line is the map revision.
+	GetCheckpoint(ctx context.Context, in *GetMapCheckpointRequest, opts ...grpc.CallOption) (*GetMapCheckpointResponse, error)
+	// ExportMap streams the roots of all the revisions of the map up to a given
+	// one, and all the versions of its leaves written at those revisions, read
+	// from a single snapshot. Revisions deleted by retention can't be exported.
+	// Witness signatures are not exported.
+	ExportMap(ctx context.Context, in *ExportMapRequest, opts ...grpc.CallOption) (TrillianMap_ExportMapClient, error)
+	// ImportMapRevision writes the leaves of an exported revision to the map,
+	// and checks that the resulting root hash is the exported one. The root is
+	// then stored with the exported timestamp and metadata, signed by the map.
+	// A map has the root hashes of the exported one only if it has the same
+	// tree ID, as map hashes depend on it.
+	ImportMapRevision(ctx context.Context, in *ImportMapRevisionRequest, opts ...grpc.CallOption) (*ImportMapRevisionResponse, error)
 	InitMap(ctx context.Context, in *InitMapRequest, opts ...grpc.CallOption) (*InitMapResponse, error)
 }
 
@@ -1160,6 +3382,42 @@ func (c *trillianMapClient) GetLeavesByRevision(ctx context.Context, in *GetMapL
 	return out, nil
 }
 
+func (c *trillianMapClient) GetLeafHistory(ctx context.Context, in *GetMapLeafHistoryRequest, opts ...grpc.CallOption) (*GetMapLeafHistoryResponse, error) {
+	out := new(GetMapLeafHistoryResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianMap/GetLeafHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianMapClient) GetLeafByHash(ctx context.Context, in *GetMapLeafByHashRequest, opts ...grpc.CallOption) (*GetMapLeavesResponse, error) {
+	out := new(GetMapLeavesResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianMap/GetLeafByHash", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianMapClient) GetConsistencyProof(ctx context.Context, in *GetMapConsistencyProofRequest, opts ...grpc.CallOption) (*GetMapConsistencyProofResponse, error) {
+	out := new(GetMapConsistencyProofResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianMap/GetConsistencyProof", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianMapClient) GetLeavesByIndexPrefix(ctx context.Context, in *GetMapLeavesByIndexPrefixRequest, opts ...grpc.CallOption) (*GetMapLeavesByIndexPrefixResponse, error) {
+	out := new(GetMapLeavesByIndexPrefixResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianMap/GetLeavesByIndexPrefix", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Deprecated: Do not use.
 func (c *trillianMapClient) GetLeavesByRevisionNoProof(ctx context.Context, in *GetMapLeavesByRevisionRequest, opts ...grpc.CallOption) (*MapLeaves, error) {
 	out := new(MapLeaves)
@@ -1179,6 +3437,38 @@ func (c *trillianMapClient) GetLastInRangeByRevision(ctx context.Context, in *Ge
 	return out, nil
 }
 
+func (c *trillianMapClient) ListLeavesByRevision(ctx context.Context, in *ListMapLeavesByRevisionRequest, opts ...grpc.CallOption) (TrillianMap_ListLeavesByRevisionClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TrillianMap_serviceDesc.Streams[0], "/trillian.TrillianMap/ListLeavesByRevision", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trillianMapListLeavesByRevisionClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TrillianMap_ListLeavesByRevisionClient interface {
+	Recv() (*ListMapLeavesByRevisionResponse, error)
+	grpc.ClientStream
+}
+
+type trillianMapListLeavesByRevisionClient struct {
+	grpc.ClientStream
+}
+
+func (x *trillianMapListLeavesByRevisionClient) Recv() (*ListMapLeavesByRevisionResponse, error) {
+	m := new(ListMapLeavesByRevisionResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Deprecated: Do not use.
 func (c *trillianMapClient) SetLeaves(ctx context.Context, in *SetMapLeavesRequest, opts ...grpc.CallOption) (*SetMapLeavesResponse, error) {
 	out := new(SetMapLeavesResponse)
@@ -1189,6 +3479,15 @@ func (c *trillianMapClient) SetLeaves(ctx context.Context, in *SetMapLeavesReque
 	return out, nil
 }
 
+func (c *trillianMapClient) SetMultiMapLeaves(ctx context.Context, in *SetMultiMapLeavesRequest, opts ...grpc.CallOption) (*SetMultiMapLeavesResponse, error) {
+	out := new(SetMultiMapLeavesResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianMap/SetMultiMapLeaves", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *trillianMapClient) GetSignedMapRoot(ctx context.Context, in *GetSignedMapRootRequest, opts ...grpc.CallOption) (*GetSignedMapRootResponse, error) {
 	out := new(GetSignedMapRootResponse)
 	err := c.cc.Invoke(ctx, "/trillian.TrillianMap/GetSignedMapRoot", in, out, opts...)
@@ -1207,6 +3506,115 @@ func (c *trillianMapClient) GetSignedMapRootByRevision(ctx context.Context, in *
 	return out, nil
 }
 
+func (c *trillianMapClient) ListSignedMapRoots(ctx context.Context, in *ListSignedMapRootsRequest, opts ...grpc.CallOption) (*ListSignedMapRootsResponse, error) {
+	out := new(ListSignedMapRootsResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianMap/ListSignedMapRoots", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianMapClient) WatchSignedMapRoots(ctx context.Context, in *WatchSignedMapRootsRequest, opts ...grpc.CallOption) (TrillianMap_WatchSignedMapRootsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TrillianMap_serviceDesc.Streams[1], "/trillian.TrillianMap/WatchSignedMapRoots", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trillianMapWatchSignedMapRootsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TrillianMap_WatchSignedMapRootsClient interface {
+	Recv() (*WatchSignedMapRootsResponse, error)
+	grpc.ClientStream
+}
+
+type trillianMapWatchSignedMapRootsClient struct {
+	grpc.ClientStream
+}
+
+func (x *trillianMapWatchSignedMapRootsClient) Recv() (*WatchSignedMapRootsResponse, error) {
+	m := new(WatchSignedMapRootsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *trillianMapClient) AddMapRootSignature(ctx context.Context, in *AddMapRootSignatureRequest, opts ...grpc.CallOption) (*AddMapRootSignatureResponse, error) {
+	out := new(AddMapRootSignatureResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianMap/AddMapRootSignature", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianMapClient) GetMapRootSignatures(ctx context.Context, in *GetMapRootSignaturesRequest, opts ...grpc.CallOption) (*GetMapRootSignaturesResponse, error) {
+	out := new(GetMapRootSignaturesResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianMap/GetMapRootSignatures", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianMapClient) GetCheckpoint(ctx context.Context, in *GetMapCheckpointRequest, opts ...grpc.CallOption) (*GetMapCheckpointResponse, error) {
+	out := new(GetMapCheckpointResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianMap/GetCheckpoint", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianMapClient) ExportMap(ctx context.Context, in *ExportMapRequest, opts ...grpc.CallOption) (TrillianMap_ExportMapClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TrillianMap_serviceDesc.Streams[2], "/trillian.TrillianMap/ExportMap", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trillianMapExportMapClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TrillianMap_ExportMapClient interface {
+	Recv() (*ExportMapResponse, error)
+	grpc.ClientStream
+}
+
+type trillianMapExportMapClient struct {
+	grpc.ClientStream
+}
+
+func (x *trillianMapExportMapClient) Recv() (*ExportMapResponse, error) {
+	m := new(ExportMapResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *trillianMapClient) ImportMapRevision(ctx context.Context, in *ImportMapRevisionRequest, opts ...grpc.CallOption) (*ImportMapRevisionResponse, error) {
+	out := new(ImportMapRevisionResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianMap/ImportMapRevision", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *trillianMapClient) InitMap(ctx context.Context, in *InitMapRequest, opts ...grpc.CallOption) (*InitMapResponse, error) {
 	out := new(InitMapResponse)
 	err := c.cc.Invoke(ctx, "/trillian.TrillianMap/InitMap", in, out, opts...)
@@ -1225,16 +3633,69 @@ type TrillianMapServer interface {
 	GetLeafByRevision(context.Context, *GetMapLeafByRevisionRequest) (*GetMapLeafResponse, error)
 	GetLeaves(context.Context, *GetMapLeavesRequest) (*GetMapLeavesResponse, error)
 	GetLeavesByRevision(context.Context, *GetMapLeavesByRevisionRequest) (*GetMapLeavesResponse, error)
-	// Deprecated: this should only be used be writers, which should migrate
+	// GetLeafHistory returns the value of a leaf and its inclusion proof at each
+	// of a range of revisions, in a single round trip.
+	GetLeafHistory(context.Context, *GetMapLeafHistoryRequest) (*GetMapLeafHistoryResponse, error)
+	// GetLeafByHash returns the leaves whose value has a leaf hash at a
+	// revision, with their inclusion proofs, so that verifiers which only hold
+	// a leaf hash can locate and prove the map entry. Usually at most one leaf
+	// matches. It needs the map to maintain a leaf hash index, which is enabled
+	// in the storage settings of the map when it is created.
+	GetLeafByHash(context.Context, *GetMapLeafByHashRequest) (*GetMapLeavesResponse, error)
+	// GetConsistencyProof returns a proof that a revision of the map was
+	// derived from an earlier one by changing only a claimed set of leaves, so
+	// that mirrors and auditors need not replay every write in between.
+	GetConsistencyProof(context.Context, *GetMapConsistencyProofRequest) (*GetMapConsistencyProofResponse, error)
+	// GetLeavesByIndexPrefix returns all the leaves whose indices start with a
+	// prefix at a revision, with a single proof that they are all of them.
+	GetLeavesByIndexPrefix(context.Context, *GetMapLeavesByIndexPrefixRequest) (*GetMapLeavesByIndexPrefixResponse, error)
+	// Deprecated: this should only be used by writers, which should migrate
 	// to TrillianMapWrite#GetLeavesByRevision
 	GetLeavesByRevisionNoProof(context.Context, *GetMapLeavesByRevisionRequest) (*MapLeaves, error)
 	// GetLastInRangeByRevision returns the last leaf in a requested range.
 	GetLastInRangeByRevision(context.Context, *GetLastInRangeByRevisionRequest) (*MapLeaf, error)
-	// Deprecated: this should only be used be writers, which should migrate
+	// ListLeavesByRevision streams all the populated leaves of the map at the
+	// given revision, in ascending index order. Each response holds up to
+	// page_size leaves and a token that can be used to resume the listing.
+	ListLeavesByRevision(*ListMapLeavesByRevisionRequest, TrillianMap_ListLeavesByRevisionServer) error
+	// Deprecated: this should only be used by writers, which should migrate
 	// to TrillianMapWrite#WriteLeaves
 	SetLeaves(context.Context, *SetMapLeavesRequest) (*SetMapLeavesResponse, error)
+	// SetMultiMapLeaves atomically applies leaf updates to several maps in a
+	// single storage transaction. Either every map gets a new revision, or none
+	// of them do.
+	SetMultiMapLeaves(context.Context, *SetMultiMapLeavesRequest) (*SetMultiMapLeavesResponse, error)
 	GetSignedMapRoot(context.Context, *GetSignedMapRootRequest) (*GetSignedMapRootResponse, error)
 	GetSignedMapRootByRevision(context.Context, *GetSignedMapRootByRevisionRequest) (*GetSignedMapRootResponse, error)
+	// ListSignedMapRoots returns the roots of the map which match a range of
+	// revisions and a range of publication times, in pages.
+	ListSignedMapRoots(context.Context, *ListSignedMapRootsRequest) (*ListSignedMapRootsResponse, error)
+	// WatchSignedMapRoots streams the latest root of the map, followed by each
+	// newer root as it is published, in revision order. The stream only ends
+	// when the client cancels it or an error occurs.
+	WatchSignedMapRoots(*WatchSignedMapRootsRequest, TrillianMap_WatchSignedMapRootsServer) error
+	// AddMapRootSignature stores the signature of a witness over the root of
+	// the map at a revision, once it has been verified with the witness's
+	// public key. A later signature with the same key replaces it. The map
+	// doesn't vouch for the witnesses: clients decide which keys they trust.
+	AddMapRootSignature(context.Context, *AddMapRootSignatureRequest) (*AddMapRootSignatureResponse, error)
+	// GetMapRootSignatures returns the root of the map at a revision, along
+	// with the witness signatures stored for it.
+	GetMapRootSignatures(context.Context, *GetMapRootSignaturesRequest) (*GetMapRootSignaturesResponse, error)
+	// GetCheckpoint returns the latest root of the map as a signed checkpoint,
+	// as in TrillianLog.GetCheckpoint. Its size line is the map revision.
+	GetCheckpoint(context.Context, *GetMapCheckpointRequest) (*GetMapCheckpointResponse, error)
+	// ExportMap streams the roots of all the revisions of the map up to a given
+	// one, and all the versions of its leaves written at those revisions, read
+	// from a single snapshot. Revisions deleted by retention can't be exported.
+	// Witness signatures are not exported.
+	ExportMap(*ExportMapRequest, TrillianMap_ExportMapServer) error
+	// ImportMapRevision writes the leaves of an exported revision to the map,
+	// and checks that the resulting root hash is the exported one. The root is
+	// then stored with the exported timestamp and metadata, signed by the map.
+	// A map has the root hashes of the exported one only if it has the same
+	// tree ID, as map hashes depend on it.
+	ImportMapRevision(context.Context, *ImportMapRevisionRequest) (*ImportMapRevisionResponse, error)
 	InitMap(context.Context, *InitMapRequest) (*InitMapResponse, error)
 }
 
@@ -1254,20 +3715,59 @@ func (*UnimplementedTrillianMapServer) GetLeaves(ctx context.Context, req *GetMa
 func (*UnimplementedTrillianMapServer) GetLeavesByRevision(ctx context.Context, req *GetMapLeavesByRevisionRequest) (*GetMapLeavesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetLeavesByRevision not implemented")
 }
+func (*UnimplementedTrillianMapServer) GetLeafHistory(ctx context.Context, req *GetMapLeafHistoryRequest) (*GetMapLeafHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLeafHistory not implemented")
+}
+func (*UnimplementedTrillianMapServer) GetLeafByHash(ctx context.Context, req *GetMapLeafByHashRequest) (*GetMapLeavesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLeafByHash not implemented")
+}
+func (*UnimplementedTrillianMapServer) GetConsistencyProof(ctx context.Context, req *GetMapConsistencyProofRequest) (*GetMapConsistencyProofResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConsistencyProof not implemented")
+}
+func (*UnimplementedTrillianMapServer) GetLeavesByIndexPrefix(ctx context.Context, req *GetMapLeavesByIndexPrefixRequest) (*GetMapLeavesByIndexPrefixResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLeavesByIndexPrefix not implemented")
+}
 func (*UnimplementedTrillianMapServer) GetLeavesByRevisionNoProof(ctx context.Context, req *GetMapLeavesByRevisionRequest) (*MapLeaves, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetLeavesByRevisionNoProof not implemented")
 }
 func (*UnimplementedTrillianMapServer) GetLastInRangeByRevision(ctx context.Context, req *GetLastInRangeByRevisionRequest) (*MapLeaf, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetLastInRangeByRevision not implemented")
 }
+func (*UnimplementedTrillianMapServer) ListLeavesByRevision(req *ListMapLeavesByRevisionRequest, srv TrillianMap_ListLeavesByRevisionServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListLeavesByRevision not implemented")
+}
 func (*UnimplementedTrillianMapServer) SetLeaves(ctx context.Context, req *SetMapLeavesRequest) (*SetMapLeavesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SetLeaves not implemented")
 }
-func (*UnimplementedTrillianMapServer) GetSignedMapRoot(ctx context.Context, req *GetSignedMapRootRequest) (*GetSignedMapRootResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetSignedMapRoot not implemented")
+func (*UnimplementedTrillianMapServer) SetMultiMapLeaves(ctx context.Context, req *SetMultiMapLeavesRequest) (*SetMultiMapLeavesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetMultiMapLeaves not implemented")
+}
+func (*UnimplementedTrillianMapServer) GetSignedMapRoot(ctx context.Context, req *GetSignedMapRootRequest) (*GetSignedMapRootResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSignedMapRoot not implemented")
+}
+func (*UnimplementedTrillianMapServer) GetSignedMapRootByRevision(ctx context.Context, req *GetSignedMapRootByRevisionRequest) (*GetSignedMapRootResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSignedMapRootByRevision not implemented")
+}
+func (*UnimplementedTrillianMapServer) ListSignedMapRoots(ctx context.Context, req *ListSignedMapRootsRequest) (*ListSignedMapRootsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSignedMapRoots not implemented")
+}
+func (*UnimplementedTrillianMapServer) WatchSignedMapRoots(req *WatchSignedMapRootsRequest, srv TrillianMap_WatchSignedMapRootsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchSignedMapRoots not implemented")
+}
+func (*UnimplementedTrillianMapServer) AddMapRootSignature(ctx context.Context, req *AddMapRootSignatureRequest) (*AddMapRootSignatureResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddMapRootSignature not implemented")
+}
+func (*UnimplementedTrillianMapServer) GetMapRootSignatures(ctx context.Context, req *GetMapRootSignaturesRequest) (*GetMapRootSignaturesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMapRootSignatures not implemented")
+}
+func (*UnimplementedTrillianMapServer) GetCheckpoint(ctx context.Context, req *GetMapCheckpointRequest) (*GetMapCheckpointResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCheckpoint not implemented")
 }
-func (*UnimplementedTrillianMapServer) GetSignedMapRootByRevision(ctx context.Context, req *GetSignedMapRootByRevisionRequest) (*GetSignedMapRootResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetSignedMapRootByRevision not implemented")
+func (*UnimplementedTrillianMapServer) ExportMap(req *ExportMapRequest, srv TrillianMap_ExportMapServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportMap not implemented")
+}
+func (*UnimplementedTrillianMapServer) ImportMapRevision(ctx context.Context, req *ImportMapRevisionRequest) (*ImportMapRevisionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportMapRevision not implemented")
 }
 func (*UnimplementedTrillianMapServer) InitMap(ctx context.Context, req *InitMapRequest) (*InitMapResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method InitMap not implemented")
@@ -1349,6 +3849,78 @@ func _TrillianMap_GetLeavesByRevision_Handler(srv interface{}, ctx context.Conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianMap_GetLeafHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMapLeafHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).GetLeafHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/GetLeafHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).GetLeafHistory(ctx, req.(*GetMapLeafHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianMap_GetLeafByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMapLeafByHashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).GetLeafByHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/GetLeafByHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).GetLeafByHash(ctx, req.(*GetMapLeafByHashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianMap_GetConsistencyProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMapConsistencyProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).GetConsistencyProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/GetConsistencyProof",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).GetConsistencyProof(ctx, req.(*GetMapConsistencyProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianMap_GetLeavesByIndexPrefix_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMapLeavesByIndexPrefixRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).GetLeavesByIndexPrefix(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/GetLeavesByIndexPrefix",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).GetLeavesByIndexPrefix(ctx, req.(*GetMapLeavesByIndexPrefixRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _TrillianMap_GetLeavesByRevisionNoProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetMapLeavesByRevisionRequest)
 	if err := dec(in); err != nil {
@@ -1385,6 +3957,27 @@ func _TrillianMap_GetLastInRangeByRevision_Handler(srv interface{}, ctx context.
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianMap_ListLeavesByRevision_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListMapLeavesByRevisionRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrillianMapServer).ListLeavesByRevision(m, &trillianMapListLeavesByRevisionServer{stream})
+}
+
+type TrillianMap_ListLeavesByRevisionServer interface {
+	Send(*ListMapLeavesByRevisionResponse) error
+	grpc.ServerStream
+}
+
+type trillianMapListLeavesByRevisionServer struct {
+	grpc.ServerStream
+}
+
+func (x *trillianMapListLeavesByRevisionServer) Send(m *ListMapLeavesByRevisionResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _TrillianMap_SetLeaves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(SetMapLeavesRequest)
 	if err := dec(in); err != nil {
@@ -1403,6 +3996,24 @@ func _TrillianMap_SetLeaves_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianMap_SetMultiMapLeaves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetMultiMapLeavesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).SetMultiMapLeaves(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/SetMultiMapLeaves",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).SetMultiMapLeaves(ctx, req.(*SetMultiMapLeavesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _TrillianMap_GetSignedMapRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetSignedMapRootRequest)
 	if err := dec(in); err != nil {
@@ -1439,6 +4050,138 @@ func _TrillianMap_GetSignedMapRootByRevision_Handler(srv interface{}, ctx contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianMap_ListSignedMapRoots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSignedMapRootsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).ListSignedMapRoots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/ListSignedMapRoots",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).ListSignedMapRoots(ctx, req.(*ListSignedMapRootsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianMap_WatchSignedMapRoots_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchSignedMapRootsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrillianMapServer).WatchSignedMapRoots(m, &trillianMapWatchSignedMapRootsServer{stream})
+}
+
+type TrillianMap_WatchSignedMapRootsServer interface {
+	Send(*WatchSignedMapRootsResponse) error
+	grpc.ServerStream
+}
+
+type trillianMapWatchSignedMapRootsServer struct {
+	grpc.ServerStream
+}
+
+func (x *trillianMapWatchSignedMapRootsServer) Send(m *WatchSignedMapRootsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TrillianMap_AddMapRootSignature_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddMapRootSignatureRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).AddMapRootSignature(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/AddMapRootSignature",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).AddMapRootSignature(ctx, req.(*AddMapRootSignatureRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianMap_GetMapRootSignatures_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMapRootSignaturesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).GetMapRootSignatures(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/GetMapRootSignatures",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).GetMapRootSignatures(ctx, req.(*GetMapRootSignaturesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianMap_GetCheckpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMapCheckpointRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).GetCheckpoint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/GetCheckpoint",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).GetCheckpoint(ctx, req.(*GetMapCheckpointRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianMap_ExportMap_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportMapRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrillianMapServer).ExportMap(m, &trillianMapExportMapServer{stream})
+}
+
+type TrillianMap_ExportMapServer interface {
+	Send(*ExportMapResponse) error
+	grpc.ServerStream
+}
+
+type trillianMapExportMapServer struct {
+	grpc.ServerStream
+}
+
+func (x *trillianMapExportMapServer) Send(m *ExportMapResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TrillianMap_ImportMapRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportMapRevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).ImportMapRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/ImportMapRevision",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).ImportMapRevision(ctx, req.(*ImportMapRevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _TrillianMap_InitMap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(InitMapRequest)
 	if err := dec(in); err != nil {
@@ -1477,6 +4220,22 @@ var _TrillianMap_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetLeavesByRevision",
 			Handler:    _TrillianMap_GetLeavesByRevision_Handler,
 		},
+		{
+			MethodName: "GetLeafHistory",
+			Handler:    _TrillianMap_GetLeafHistory_Handler,
+		},
+		{
+			MethodName: "GetLeafByHash",
+			Handler:    _TrillianMap_GetLeafByHash_Handler,
+		},
+		{
+			MethodName: "GetConsistencyProof",
+			Handler:    _TrillianMap_GetConsistencyProof_Handler,
+		},
+		{
+			MethodName: "GetLeavesByIndexPrefix",
+			Handler:    _TrillianMap_GetLeavesByIndexPrefix_Handler,
+		},
 		{
 			MethodName: "GetLeavesByRevisionNoProof",
 			Handler:    _TrillianMap_GetLeavesByRevisionNoProof_Handler,
@@ -1489,6 +4248,10 @@ var _TrillianMap_serviceDesc = grpc.ServiceDesc{
 			MethodName: "SetLeaves",
 			Handler:    _TrillianMap_SetLeaves_Handler,
 		},
+		{
+			MethodName: "SetMultiMapLeaves",
+			Handler:    _TrillianMap_SetMultiMapLeaves_Handler,
+		},
 		{
 			MethodName: "GetSignedMapRoot",
 			Handler:    _TrillianMap_GetSignedMapRoot_Handler,
@@ -1497,12 +4260,48 @@ var _TrillianMap_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetSignedMapRootByRevision",
 			Handler:    _TrillianMap_GetSignedMapRootByRevision_Handler,
 		},
+		{
+			MethodName: "ListSignedMapRoots",
+			Handler:    _TrillianMap_ListSignedMapRoots_Handler,
+		},
+		{
+			MethodName: "AddMapRootSignature",
+			Handler:    _TrillianMap_AddMapRootSignature_Handler,
+		},
+		{
+			MethodName: "GetMapRootSignatures",
+			Handler:    _TrillianMap_GetMapRootSignatures_Handler,
+		},
+		{
+			MethodName: "GetCheckpoint",
+			Handler:    _TrillianMap_GetCheckpoint_Handler,
+		},
+		{
+			MethodName: "ImportMapRevision",
+			Handler:    _TrillianMap_ImportMapRevision_Handler,
+		},
 		{
 			MethodName: "InitMap",
 			Handler:    _TrillianMap_InitMap_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListLeavesByRevision",
+			Handler:       _TrillianMap_ListLeavesByRevision_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchSignedMapRoots",
+			Handler:       _TrillianMap_WatchSignedMapRoots_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportMap",
+			Handler:       _TrillianMap_ExportMap_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "trillian_map_api.proto",
 }
 
@@ -1516,6 +4315,23 @@ type TrillianMapWriteClient interface {
 	// WriteLeaves sets the values for the provided leaves, and returns the new map
 	// revision if successful.
 	WriteLeaves(ctx context.Context, in *WriteMapLeavesRequest, opts ...grpc.CallOption) (*WriteMapLeavesResponse, error)
+	// DeleteLeafRange clears the leaves whose indexes start with a prefix in a
+	// single new revision, without the client listing and deleting each of
+	// them. Ranges larger than the server's write limit are cleared by
+	// repeating the request, see DeleteMapLeafRangeRequest.
+	DeleteLeafRange(ctx context.Context, in *DeleteMapLeafRangeRequest, opts ...grpc.CallOption) (*DeleteMapLeafRangeResponse, error)
+	// ReserveRevision atomically reserves the next write revision of a map for
+	// a lease. Writers which pre-assign revisions to batches can then write
+	// each batch at its revision, and retry the write safely while the lease
+	// lasts. Revisions are still written in order, so a revision reserved
+	// after an unwritten one can only be written once that one is.
+	ReserveRevision(ctx context.Context, in *ReserveMapRevisionRequest, opts ...grpc.CallOption) (*ReserveMapRevisionResponse, error)
+	// GetHotKeys is a debug method which returns the index prefixes of a map
+	// that the server has written the most leaves under, e.g. to find the
+	// tenants of a shared map which cause the most subtree churn. Writes are
+	// counted in memory by each server, from when it started, and only if the
+	// server counts writes by prefix.
+	GetHotKeys(ctx context.Context, in *GetMapHotKeysRequest, opts ...grpc.CallOption) (*GetMapHotKeysResponse, error)
 }
 
 type trillianMapWriteClient struct {
@@ -1544,6 +4360,33 @@ func (c *trillianMapWriteClient) WriteLeaves(ctx context.Context, in *WriteMapLe
 	return out, nil
 }
 
+func (c *trillianMapWriteClient) DeleteLeafRange(ctx context.Context, in *DeleteMapLeafRangeRequest, opts ...grpc.CallOption) (*DeleteMapLeafRangeResponse, error) {
+	out := new(DeleteMapLeafRangeResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianMapWrite/DeleteLeafRange", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianMapWriteClient) ReserveRevision(ctx context.Context, in *ReserveMapRevisionRequest, opts ...grpc.CallOption) (*ReserveMapRevisionResponse, error) {
+	out := new(ReserveMapRevisionResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianMapWrite/ReserveRevision", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianMapWriteClient) GetHotKeys(ctx context.Context, in *GetMapHotKeysRequest, opts ...grpc.CallOption) (*GetMapHotKeysResponse, error) {
+	out := new(GetMapHotKeysResponse)
+	err := c.cc.Invoke(ctx, "/trillian.TrillianMapWrite/GetHotKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TrillianMapWriteServer is the server API for TrillianMapWrite service.
 type TrillianMapWriteServer interface {
 	// GetLeavesByRevision returns the requested map leaves without inclusion proofs.
@@ -1552,6 +4395,23 @@ type TrillianMapWriteServer interface {
 	// WriteLeaves sets the values for the provided leaves, and returns the new map
 	// revision if successful.
 	WriteLeaves(context.Context, *WriteMapLeavesRequest) (*WriteMapLeavesResponse, error)
+	// DeleteLeafRange clears the leaves whose indexes start with a prefix in a
+	// single new revision, without the client listing and deleting each of
+	// them. Ranges larger than the server's write limit are cleared by
+	// repeating the request, see DeleteMapLeafRangeRequest.
+	DeleteLeafRange(context.Context, *DeleteMapLeafRangeRequest) (*DeleteMapLeafRangeResponse, error)
+	// ReserveRevision atomically reserves the next write revision of a map for
+	// a lease. Writers which pre-assign revisions to batches can then write
+	// each batch at its revision, and retry the write safely while the lease
+	// lasts. Revisions are still written in order, so a revision reserved
+	// after an unwritten one can only be written once that one is.
+	ReserveRevision(context.Context, *ReserveMapRevisionRequest) (*ReserveMapRevisionResponse, error)
+	// GetHotKeys is a debug method which returns the index prefixes of a map
+	// that the server has written the most leaves under, e.g. to find the
+	// tenants of a shared map which cause the most subtree churn. Writes are
+	// counted in memory by each server, from when it started, and only if the
+	// server counts writes by prefix.
+	GetHotKeys(context.Context, *GetMapHotKeysRequest) (*GetMapHotKeysResponse, error)
 }
 
 // UnimplementedTrillianMapWriteServer can be embedded to have forward compatible implementations.
@@ -1564,6 +4424,15 @@ func (*UnimplementedTrillianMapWriteServer) GetLeavesByRevision(ctx context.Cont
 func (*UnimplementedTrillianMapWriteServer) WriteLeaves(ctx context.Context, req *WriteMapLeavesRequest) (*WriteMapLeavesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method WriteLeaves not implemented")
 }
+func (*UnimplementedTrillianMapWriteServer) DeleteLeafRange(ctx context.Context, req *DeleteMapLeafRangeRequest) (*DeleteMapLeafRangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteLeafRange not implemented")
+}
+func (*UnimplementedTrillianMapWriteServer) ReserveRevision(ctx context.Context, req *ReserveMapRevisionRequest) (*ReserveMapRevisionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReserveRevision not implemented")
+}
+func (*UnimplementedTrillianMapWriteServer) GetHotKeys(ctx context.Context, req *GetMapHotKeysRequest) (*GetMapHotKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHotKeys not implemented")
+}
 
 func RegisterTrillianMapWriteServer(s *grpc.Server, srv TrillianMapWriteServer) {
 	s.RegisterService(&_TrillianMapWrite_serviceDesc, srv)
@@ -1605,6 +4474,60 @@ func _TrillianMapWrite_WriteLeaves_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianMapWrite_DeleteLeafRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteMapLeafRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapWriteServer).DeleteLeafRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMapWrite/DeleteLeafRange",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapWriteServer).DeleteLeafRange(ctx, req.(*DeleteMapLeafRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianMapWrite_ReserveRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveMapRevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapWriteServer).ReserveRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMapWrite/ReserveRevision",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapWriteServer).ReserveRevision(ctx, req.(*ReserveMapRevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianMapWrite_GetHotKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMapHotKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapWriteServer).GetHotKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMapWrite/GetHotKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapWriteServer).GetHotKeys(ctx, req.(*GetMapHotKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _TrillianMapWrite_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "trillian.TrillianMapWrite",
 	HandlerType: (*TrillianMapWriteServer)(nil),
@@ -1617,6 +4540,18 @@ var _TrillianMapWrite_serviceDesc = grpc.ServiceDesc{
 			MethodName: "WriteLeaves",
 			Handler:    _TrillianMapWrite_WriteLeaves_Handler,
 		},
+		{
+			MethodName: "DeleteLeafRange",
+			Handler:    _TrillianMapWrite_DeleteLeafRange_Handler,
+		},
+		{
+			MethodName: "ReserveRevision",
+			Handler:    _TrillianMapWrite_ReserveRevision_Handler,
+		},
+		{
+			MethodName: "GetHotKeys",
+			Handler:    _TrillianMapWrite_GetHotKeys_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "trillian_map_api.proto",
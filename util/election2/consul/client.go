@@ -0,0 +1,175 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// errNotFound is returned for sessions which don't exist, e.g. because their
+// TTL expired, and for keys which don't exist.
+var errNotFound = errors.New("not found")
+
+// Client calls the session and KV endpoints of the Consul HTTP API.
+type Client struct {
+	address string
+	token   string
+	hc      *http.Client
+}
+
+// NewClient returns a Client for the Consul agent at address, e.g.
+// "http://localhost:8500". Requests carry the given ACL token, unless it's
+// empty.
+func NewClient(address, token string, hc *http.Client) *Client {
+	if hc == nil {
+		hc = &http.Client{Timeout: 30 * time.Second}
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	return &Client{
+		address: strings.TrimRight(address, "/"),
+		token:   token,
+		hc:      hc,
+	}
+}
+
+// sessionEntry is the subset of a Consul session used for election.
+type sessionEntry struct {
+	ID       string `json:",omitempty"`
+	Name     string `json:",omitempty"`
+	TTL      string `json:",omitempty"`
+	Behavior string `json:",omitempty"`
+}
+
+// kvPair is the subset of a Consul KV entry used for election.
+type kvPair struct {
+	Key     string
+	Session string `json:",omitempty"`
+}
+
+// createSession creates a session which expires unless renewed within ttl, and
+// whose locks are released when it expires.
+func (c *Client) createSession(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	req := sessionEntry{Name: name, TTL: ttl.String(), Behavior: "release"}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	var resp sessionEntry
+	if err := c.do(ctx, http.MethodPut, "/v1/session/create", nil, body, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// renewSession resets the TTL of the session, or returns errNotFound if it has
+// expired.
+func (c *Client) renewSession(ctx context.Context, id string) error {
+	var resp []sessionEntry
+	if err := c.do(ctx, http.MethodPut, "/v1/session/renew/"+id, nil, nil, &resp); err != nil {
+		return err
+	}
+	if len(resp) == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+// destroySession destroys the session, releasing its locks.
+func (c *Client) destroySession(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPut, "/v1/session/destroy/"+id, nil, nil, nil)
+}
+
+// acquire locks key for the session, storing value in it, and returns whether
+// the session holds the lock.
+func (c *Client) acquire(ctx context.Context, key, session string, value []byte) (bool, error) {
+	var ok bool
+	err := c.do(ctx, http.MethodPut, "/v1/kv/"+key, url.Values{"acquire": {session}}, value, &ok)
+	return ok, err
+}
+
+// release unlocks key, if the session holds the lock.
+func (c *Client) release(ctx context.Context, key, session string) error {
+	var ok bool
+	return c.do(ctx, http.MethodPut, "/v1/kv/"+key, url.Values{"release": {session}}, nil, &ok)
+}
+
+// lockHolder returns the session holding the lock on key, if any.
+func (c *Client) lockHolder(ctx context.Context, key string) (string, error) {
+	var pairs []kvPair
+	if err := c.do(ctx, http.MethodGet, "/v1/kv/"+key, nil, nil, &pairs); err == errNotFound {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	if len(pairs) == 0 {
+		return "", nil
+	}
+	return pairs[0].Session, nil
+}
+
+// do sends a request to the Consul API, and decodes the JSON response into
+// resp unless it's nil. A 404 response returns errNotFound.
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, body []byte, resp interface{}) error {
+	u := c.address + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	hr, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer hr.Body.Close()
+	data, err := ioutil.ReadAll(hr.Body)
+	if err != nil {
+		return err
+	}
+	switch {
+	case hr.StatusCode == http.StatusNotFound:
+		return errNotFound
+	case hr.StatusCode/100 != 2:
+		return fmt.Errorf("%s %s: %s: %s", method, path, hr.Status, bytes.TrimSpace(data))
+	case resp == nil:
+		return nil
+	}
+	if err := json.Unmarshal(data, resp); err != nil {
+		return fmt.Errorf("%s %s: invalid response: %v", method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package consul provides an implementation of master election based on
+// Consul sessions and KV locks.
+//
+// Each resource has a key, and the instance whose session holds the lock on it
+// is the master. Sessions expire unless renewed within SessionTTL, which
+// releases their locks.
+package consul
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/util/clock"
+	"github.com/google/trillian/util/election2"
+)
+
+// Options configures the sessions and locks of the Elections created by a
+// Factory.
+type Options struct {
+	// SessionTTL is how long a session lives after its last renewal. Consul
+	// requires it to be between 10s and 24h.
+	SessionTTL time.Duration
+	// RetryPeriod is the interval between attempts to acquire a lock, and
+	// between renewals of the session and checks of the lock while it's held.
+	// It should be a fraction of SessionTTL.
+	RetryPeriod time.Duration
+	// TimeSource is used to wait between attempts. If nil, the system clock is
+	// used.
+	TimeSource clock.TimeSource
+}
+
+// Election is an implementation of election2.Election based on a Consul lock.
+type Election struct {
+	resourceID string
+	instanceID string
+	key        string
+	client     *Client
+	opts       Options
+
+	mu sync.Mutex
+	// session is the ID of the session used to acquire the lock, or empty if
+	// there is none.
+	session string
+	// done is non-nil while the instance is the master, and is closed when it
+	// stops being the master.
+	done chan struct{}
+	// stopMonitoring stops the goroutine which keeps the session alive and
+	// checks the lock while the instance is the master.
+	stopMonitoring context.CancelFunc
+}
+
+// Await blocks until the instance captures mastership.
+func (e *Election) Await(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.done != nil {
+		return nil
+	}
+	for {
+		acquired, err := e.tryAcquire(ctx)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		} else if err != nil {
+			return err
+		}
+		if acquired {
+			break
+		}
+		if err := clock.SleepSource(ctx, e.opts.RetryPeriod, e.opts.TimeSource); err != nil {
+			return err
+		}
+	}
+
+	mctx, stop := context.WithCancel(context.Background())
+	e.done, e.stopMonitoring = make(chan struct{}), stop
+	go e.monitor(mctx, e.done, e.session)
+	return nil
+}
+
+// tryAcquire renews the session, or creates one if there is none or it has
+// expired, and returns whether the session acquired the lock. Must be called
+// with mu held.
+func (e *Election) tryAcquire(ctx context.Context) (bool, error) {
+	if e.session != "" {
+		if err := e.client.renewSession(ctx, e.session); err == errNotFound {
+			e.session = ""
+		} else if err != nil {
+			return false, fmt.Errorf("failed to renew session: %v", err)
+		}
+	}
+	if e.session == "" {
+		id, err := e.client.createSession(ctx, e.key, e.opts.SessionTTL)
+		if err != nil {
+			return false, fmt.Errorf("failed to create session: %v", err)
+		}
+		e.session = id
+	}
+	acquired, err := e.client.acquire(ctx, e.key, e.session, []byte(e.instanceID))
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lock %s: %v", e.key, err)
+	}
+	return acquired, nil
+}
+
+// monitor renews the session every RetryPeriod, and checks that it still holds
+// the lock, until ctx is cancelled or the instance loses the lock.
+func (e *Election) monitor(ctx context.Context, done chan struct{}, session string) {
+	checked := e.opts.TimeSource.Now()
+	for {
+		if err := clock.SleepSource(ctx, e.opts.RetryPeriod, e.opts.TimeSource); err != nil {
+			return
+		}
+		err := e.client.renewSession(ctx, session)
+		var holder string
+		if err == nil {
+			holder, err = e.client.lockHolder(ctx, e.key)
+		}
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err == nil && holder == session:
+			checked = e.opts.TimeSource.Now()
+			continue
+		case err == nil:
+			glog.Warningf("%s: lock %s is no longer held by this instance", e.resourceID, e.key)
+		case err == errNotFound:
+			glog.Warningf("%s: session expired, lock %s released", e.resourceID, e.key)
+		case clock.SecondsSince(e.opts.TimeSource, checked) < e.opts.SessionTTL.Seconds():
+			glog.Warningf("%s: failed to check lock: %v", e.resourceID, err)
+			continue
+		default:
+			glog.Errorf("%s: session likely expired, last check failed: %v", e.resourceID, err)
+		}
+		e.mu.Lock()
+		e.endMastership(done)
+		e.mu.Unlock()
+		return
+	}
+}
+
+// endMastership cancels the mastership contexts, unless mastership was
+// already ended, or captured again, since done was created. Must be called
+// with mu held.
+func (e *Election) endMastership(done chan struct{}) {
+	if done == nil || e.done != done {
+		return
+	}
+	e.stopMonitoring()
+	close(done)
+	e.done, e.stopMonitoring = nil, nil
+}
+
+// WithMastership returns a "mastership context" which remains active until the
+// instance stops being the master, or the passed in context is canceled.
+func (e *Election) WithMastership(ctx context.Context) (context.Context, error) {
+	cctx, cancel := context.WithCancel(ctx)
+	e.mu.Lock()
+	done := e.done
+	e.mu.Unlock()
+	if done == nil {
+		cancel()
+		return cctx, nil
+	}
+	go func() {
+		select {
+		case <-done:
+			glog.Infof("%s: canceled mastership context", e.resourceID)
+		case <-cctx.Done():
+		}
+		cancel()
+	}()
+	return cctx, nil
+}
+
+// Resign releases mastership for this instance. The instance can be elected
+// again using Await. Idempotent, might be useful to retry if fails.
+func (e *Election) Resign(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.endMastership(e.done)
+	if e.session == "" {
+		return nil
+	}
+	// Release the lock before destroying the session, as Consul delays
+	// acquiring locks released by destroying their session.
+	if err := e.client.release(ctx, e.key, e.session); err != nil && err != errNotFound {
+		return fmt.Errorf("failed to release lock %s: %v", e.key, err)
+	}
+	if err := e.client.destroySession(ctx, e.session); err != nil && err != errNotFound {
+		return fmt.Errorf("failed to destroy session: %v", err)
+	}
+	e.session = ""
+	return nil
+}
+
+// Close resigns and permanently stops participating in election. No other
+// method should be called after Close.
+func (e *Election) Close(ctx context.Context) error {
+	if err := e.Resign(ctx); err != nil {
+		// The session expires after SessionTTL anyway.
+		glog.Errorf("%s: Resign(): %v", e.resourceID, err)
+	}
+	return nil
+}
+
+// Factory creates Election instances.
+type Factory struct {
+	client     *Client
+	instanceID string
+	prefix     string
+	opts       Options
+}
+
+// NewFactory builds an election factory whose Elections lock the key
+// <prefix>/<resourceID>, e.g. the ID of a log.
+func NewFactory(instanceID string, client *Client, prefix string, opts Options) (*Factory, error) {
+	if opts.SessionTTL < 10*time.Second || opts.SessionTTL > 24*time.Hour {
+		return nil, fmt.Errorf("session TTL must be between 10s and 24h, got %v", opts.SessionTTL)
+	}
+	if opts.RetryPeriod <= 0 || opts.RetryPeriod >= opts.SessionTTL {
+		return nil, fmt.Errorf("retry period must be positive and less than the session TTL %v, got %v", opts.SessionTTL, opts.RetryPeriod)
+	}
+	if opts.TimeSource == nil {
+		opts.TimeSource = clock.System
+	}
+	return &Factory{
+		client:     client,
+		instanceID: instanceID,
+		prefix:     strings.Trim(prefix, "/"),
+		opts:       opts,
+	}, nil
+}
+
+// NewElection creates a specific Election instance.
+func (f *Factory) NewElection(ctx context.Context, resourceID string) (election2.Election, error) {
+	el := &Election{
+		resourceID: resourceID,
+		instanceID: f.instanceID,
+		key:        fmt.Sprintf("%s/%s", f.prefix, resourceID),
+		client:     f.client,
+		opts:       f.opts,
+	}
+	glog.Infof("Election created: resource %s, key %s", resourceID, el.key)
+	return el, nil
+}
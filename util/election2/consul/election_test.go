@@ -0,0 +1,228 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util/election2/testonly"
+)
+
+// fakeAgent serves the session and KV lock endpoints of the Consul API.
+// Sessions never expire unless expire is called.
+type fakeAgent struct {
+	mu       sync.Mutex
+	next     int
+	sessions map[string]bool
+	locks    map[string]string // Key to holding session.
+}
+
+func newFakeAgent(t *testing.T) (*fakeAgent, *Client, func()) {
+	t.Helper()
+	f := &fakeAgent{sessions: make(map[string]bool), locks: make(map[string]string)}
+	s := httptest.NewServer(f)
+	return f, NewClient(s.URL, "token", s.Client()), s.Close
+}
+
+func (f *fakeAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Consul-Token") != "token" {
+		http.Error(w, "ACL not found", http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p := r.URL.Path
+	switch {
+	case p == "/v1/session/create":
+		var s sessionEntry
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil || s.Behavior != "release" {
+			http.Error(w, fmt.Sprintf("bad session %+v: %v", s, err), http.StatusBadRequest)
+			return
+		}
+		f.next++
+		id := fmt.Sprintf("session-%d", f.next)
+		f.sessions[id] = true
+		json.NewEncoder(w).Encode(sessionEntry{ID: id})
+	case strings.HasPrefix(p, "/v1/session/renew/"):
+		id := strings.TrimPrefix(p, "/v1/session/renew/")
+		if !f.sessions[id] {
+			http.Error(w, "Session id not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode([]sessionEntry{{ID: id}})
+	case strings.HasPrefix(p, "/v1/session/destroy/"):
+		f.invalidate(strings.TrimPrefix(p, "/v1/session/destroy/"))
+		json.NewEncoder(w).Encode(true)
+	case strings.HasPrefix(p, "/v1/kv/"):
+		f.serveKV(w, r, strings.TrimPrefix(p, "/v1/kv/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeAgent) serveKV(w http.ResponseWriter, r *http.Request, key string) {
+	q := r.URL.Query()
+	switch {
+	case r.Method == http.MethodGet:
+		holder, ok := f.locks[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode([]kvPair{{Key: key, Session: holder}})
+	case q.Get("acquire") != "":
+		id := q.Get("acquire")
+		if !f.sessions[id] {
+			http.Error(w, "invalid session", http.StatusInternalServerError)
+			return
+		}
+		holder := f.locks[key]
+		if holder == "" {
+			f.locks[key] = id
+		}
+		json.NewEncoder(w).Encode(holder == "" || holder == id)
+	case q.Get("release") != "":
+		ok := f.locks[key] == q.Get("release")
+		if ok {
+			f.locks[key] = ""
+		}
+		json.NewEncoder(w).Encode(ok)
+	default:
+		http.Error(w, "unsupported", http.StatusBadRequest)
+	}
+}
+
+// invalidate destroys the session, and releases its locks. Must be called with
+// mu held.
+func (f *fakeAgent) invalidate(id string) {
+	delete(f.sessions, id)
+	for key, holder := range f.locks {
+		if holder == id {
+			f.locks[key] = ""
+		}
+	}
+}
+
+// expire expires the session holding the lock on key.
+func (f *fakeAgent) expire(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.invalidate(f.locks[key])
+}
+
+func (f *fakeAgent) holder(key string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.locks[key]
+}
+
+func newFactory(t *testing.T, instanceID string, client *Client, prefix string) *Factory {
+	t.Helper()
+	f, err := NewFactory(instanceID, client, prefix, Options{
+		SessionTTL:  10 * time.Second,
+		RetryPeriod: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewFactory(): %v", err)
+	}
+	return f
+}
+
+func TestElection(t *testing.T) {
+	_, client, cleanup := newFakeAgent(t)
+	defer cleanup()
+
+	for _, nt := range testonly.Tests {
+		// Create a new Factory for each test for better isolation.
+		fact := newFactory(t, "testID", client, nt.Name+"/resources/")
+		t.Run(nt.Name, func(t *testing.T) {
+			nt.Run(t, fact)
+		})
+	}
+}
+
+func TestElectionTakeover(t *testing.T) {
+	ctx := context.Background()
+	agent, client, cleanup := newFakeAgent(t)
+	defer cleanup()
+
+	e1, err := newFactory(t, "one", client, "res").NewElection(ctx, "10")
+	if err != nil {
+		t.Fatalf("NewElection(one): %v", err)
+	}
+	e2, err := newFactory(t, "two", client, "res").NewElection(ctx, "10")
+	if err != nil {
+		t.Fatalf("NewElection(two): %v", err)
+	}
+	if err := e1.Await(ctx); err != nil {
+		t.Fatalf("Await(one): %v", err)
+	}
+	mctx, err := e1.WithMastership(ctx)
+	if err != nil {
+		t.Fatalf("WithMastership(one): %v", err)
+	}
+	e2ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := e2.Await(e2ctx); err != context.DeadlineExceeded {
+		t.Errorf("Await(two) while locked: %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The session of the first instance expires, e.g. after a network partition.
+	agent.expire("res/10")
+	if err := e2.Await(ctx); err != nil {
+		t.Fatalf("Await(two) after expiry: %v", err)
+	}
+	select {
+	case <-mctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("mastership context not canceled after losing the lock")
+	}
+	if err := e1.Close(ctx); err != nil {
+		t.Errorf("Close(one): %v", err)
+	}
+	s2 := e2.(*Election).session
+	if got := agent.holder("res/10"); got != s2 {
+		t.Errorf("lock holder: %q, want %q", got, s2)
+	}
+	if err := e2.Close(ctx); err != nil {
+		t.Errorf("Close(two): %v", err)
+	}
+	if got := agent.holder("res/10"); got != "" {
+		t.Errorf("lock holder after Close(): %q, want none", got)
+	}
+}
+
+func TestNewFactoryErrors(t *testing.T) {
+	for _, opts := range []Options{
+		{SessionTTL: time.Second, RetryPeriod: 100 * time.Millisecond},
+		{SessionTTL: 25 * time.Hour, RetryPeriod: time.Second},
+		{SessionTTL: 15 * time.Second, RetryPeriod: 0},
+		{SessionTTL: 15 * time.Second, RetryPeriod: 15 * time.Second},
+	} {
+		t.Run(fmt.Sprintf("%v-%v", opts.SessionTTL, opts.RetryPeriod), func(t *testing.T) {
+			if _, err := NewFactory("id", nil, "res", opts); err == nil {
+				t.Error("NewFactory(): nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// The files mounted into every pod with the credentials of its service
+// account.
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount/"
+	tokenFile         = serviceAccountDir + "token"
+	caFile            = serviceAccountDir + "ca.crt"
+	namespaceFile     = serviceAccountDir + "namespace"
+)
+
+var (
+	errNotFound = errors.New("lease not found")
+	errConflict = errors.New("lease modified concurrently")
+)
+
+// lease is the subset of a coordination.k8s.io/v1 Lease used for election.
+type lease struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   objectMeta `json:"metadata"`
+	Spec       leaseSpec  `json:"spec"`
+}
+
+type objectMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string     `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int32      `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          *microTime `json:"acquireTime,omitempty"`
+	RenewTime            *microTime `json:"renewTime,omitempty"`
+	LeaseTransitions     int32      `json:"leaseTransitions,omitempty"`
+}
+
+// microTime is a time in the JSON format of the Kubernetes API, with exactly
+// six fractional digits.
+type microTime struct {
+	time.Time
+}
+
+const microTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+func newMicroTime(t time.Time) *microTime {
+	return &microTime{t.UTC()}
+}
+
+func (t microTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.UTC().Format(microTimeFormat))
+}
+
+func (t *microTime) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Client reads and writes the Leases of one namespace through the Kubernetes
+// API.
+type Client struct {
+	server    string
+	namespace string
+	token     func() (string, error)
+	hc        *http.Client
+}
+
+// NewClient returns a Client for the Leases in namespace, of the API server
+// at serverURL. Requests carry the given bearer token, unless it's empty.
+func NewClient(serverURL, namespace, token string, hc *http.Client) *Client {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{
+		server:    strings.TrimRight(serverURL, "/"),
+		namespace: namespace,
+		token:     func() (string, error) { return token, nil },
+		hc:        hc,
+	}
+}
+
+// NewInClusterClient returns a Client for the Leases in namespace, with the
+// credentials of the service account of the pod it runs in. An empty
+// namespace means the namespace of the pod.
+func NewInClusterClient(namespace string) (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST or KUBERNETES_SERVICE_PORT unset")
+	}
+	if namespace == "" {
+		ns, err := ioutil.ReadFile(namespaceFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the namespace of the pod: %v", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+	caPEM, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cluster CA: %v", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return &Client{
+		server:    "https://" + net.JoinHostPort(host, port),
+		namespace: namespace,
+		// The token is read for every request, as the kubelet rotates it.
+		token: func() (string, error) {
+			token, err := ioutil.ReadFile(tokenFile)
+			return strings.TrimSpace(string(token)), err
+		},
+		hc: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}},
+		},
+	}, nil
+}
+
+func (c *Client) leasesURL() string {
+	return fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", c.server, c.namespace)
+}
+
+// get returns the named Lease, or errNotFound.
+func (c *Client) get(ctx context.Context, name string) (*lease, error) {
+	return c.do(ctx, http.MethodGet, c.leasesURL()+"/"+name, nil)
+}
+
+// create creates l, or returns errConflict if it exists.
+func (c *Client) create(ctx context.Context, l *lease) (*lease, error) {
+	return c.do(ctx, http.MethodPost, c.leasesURL(), l)
+}
+
+// update replaces l, or returns errConflict if it changed since it was read.
+func (c *Client) update(ctx context.Context, l *lease) (*lease, error) {
+	return c.do(ctx, http.MethodPut, c.leasesURL()+"/"+l.Metadata.Name, l)
+}
+
+func (c *Client) do(ctx context.Context, method, url string, l *lease) (*lease, error) {
+	var body []byte
+	if l != nil {
+		l.APIVersion, l.Kind = "coordination.k8s.io/v1", "Lease"
+		l.Metadata.Namespace = c.namespace
+		var err error
+		if body, err = json.Marshal(l); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if l != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	token, err := c.token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errNotFound
+	case resp.StatusCode == http.StatusConflict:
+		return nil, errConflict
+	case resp.StatusCode/100 != 2:
+		return nil, fmt.Errorf("%s %s: %s: %s", method, url, resp.Status, bytes.TrimSpace(data))
+	}
+	var got lease
+	if err := json.Unmarshal(data, &got); err != nil {
+		return nil, fmt.Errorf("%s %s: invalid Lease: %v", method, url, err)
+	}
+	return &got, nil
+}
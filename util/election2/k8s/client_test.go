@@ -0,0 +1,71 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMicroTimeJSON(t *testing.T) {
+	ts := time.Date(2019, 7, 1, 12, 30, 5, 123456789, time.FixedZone("CET", 3600))
+	data, err := json.Marshal(newMicroTime(ts))
+	if err != nil {
+		t.Fatalf("Marshal(): %v", err)
+	}
+	// The API server rejects times without exactly six fractional digits.
+	if got, want := string(data), `"2019-07-01T11:30:05.123456Z"`; got != want {
+		t.Errorf("Marshal(): %s, want %s", got, want)
+	}
+	var got microTime
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal(): %v", err)
+	}
+	if want := ts.Truncate(time.Microsecond); !got.Equal(want) {
+		t.Errorf("Unmarshal(): %v, want %v", got, want)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	_, client, cleanup := newFakeAPIServer(t)
+	defer cleanup()
+
+	if _, err := client.get(ctx, "missing"); err != errNotFound {
+		t.Errorf("get(missing): %v, want %v", err, errNotFound)
+	}
+	l := &lease{Metadata: objectMeta{Name: "lease"}}
+	created, err := client.create(ctx, l)
+	if err != nil {
+		t.Fatalf("create(): %v", err)
+	}
+	if _, err := client.create(ctx, l); err != errConflict {
+		t.Errorf("create() again: %v, want %v", err, errConflict)
+	}
+	if _, err := client.update(ctx, created); err != nil {
+		t.Fatalf("update(): %v", err)
+	}
+	// The first update changed the resourceVersion.
+	if _, err := client.update(ctx, created); err != errConflict {
+		t.Errorf("update() of stale Lease: %v, want %v", err, errConflict)
+	}
+
+	unauthorized := NewClient(client.server, "ns", "", nil)
+	if _, err := unauthorized.get(ctx, "lease"); err == nil || err == errNotFound {
+		t.Errorf("get() without token: %v, want authorization error", err)
+	}
+}
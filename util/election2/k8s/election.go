@@ -0,0 +1,307 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package k8s provides an implementation of master election based on
+// Kubernetes coordination/v1 Leases, for deployments which don't run etcd.
+//
+// Each resource has a Lease, whose holder is the master. The master renews the
+// Lease every RetryPeriod, and other instances take it over if it hasn't been
+// renewed for LeaseDuration.
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/util/clock"
+	"github.com/google/trillian/util/election2"
+)
+
+// Options configures the Leases of the Elections created by a Factory.
+type Options struct {
+	// LeaseDuration is how long a Lease holds after its last renewal.
+	LeaseDuration time.Duration
+	// RetryPeriod is the interval between attempts to acquire a Lease, and
+	// between renewals of a held Lease. It should be a fraction of
+	// LeaseDuration, so that a renewal failing now and then doesn't lose the
+	// Lease.
+	RetryPeriod time.Duration
+	// TimeSource is used to decide whether Leases have expired. If nil, the
+	// system clock is used.
+	TimeSource clock.TimeSource
+}
+
+// Election is an implementation of election2.Election based on a Kubernetes
+// Lease.
+type Election struct {
+	resourceID string
+	instanceID string
+	leaseName  string
+	client     *Client
+	opts       Options
+
+	mu sync.Mutex
+	// done is non-nil while the instance is the master, and is closed when it
+	// stops being the master.
+	done chan struct{}
+	// stopRenewing stops the goroutine renewing the Lease while the instance
+	// is the master.
+	stopRenewing context.CancelFunc
+	// renewing is closed once the goroutine renewing the Lease has returned.
+	renewing chan struct{}
+}
+
+// Await blocks until the instance captures mastership.
+func (e *Election) Await(ctx context.Context) error {
+	e.mu.Lock()
+	master := e.done != nil
+	e.mu.Unlock()
+	if master {
+		return nil
+	}
+	for {
+		acquired, err := e.tryAcquire(ctx)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		} else if err != nil {
+			return err
+		}
+		if acquired {
+			break
+		}
+		if err := clock.SleepSource(ctx, e.opts.RetryPeriod, e.opts.TimeSource); err != nil {
+			return err
+		}
+	}
+
+	rctx, stop := context.WithCancel(context.Background())
+	done, renewing := make(chan struct{}), make(chan struct{})
+	e.mu.Lock()
+	e.done, e.stopRenewing, e.renewing = done, stop, renewing
+	e.mu.Unlock()
+	go e.renew(rctx, done, renewing)
+	return nil
+}
+
+// tryAcquire takes, or renews, the Lease if it's free, expired or held by the
+// instance, and returns whether the instance holds it.
+func (e *Election) tryAcquire(ctx context.Context) (bool, error) {
+	now := e.opts.TimeSource.Now()
+	l, err := e.client.get(ctx, e.leaseName)
+	if err == errNotFound {
+		l = &lease{Metadata: objectMeta{Name: e.leaseName}}
+		e.hold(l, now)
+		if _, err := e.client.create(ctx, l); err == errConflict {
+			return false, nil
+		} else if err != nil {
+			return false, fmt.Errorf("failed to create Lease %s: %v", e.leaseName, err)
+		}
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to read Lease %s: %v", e.leaseName, err)
+	}
+
+	if holder := l.Spec.HolderIdentity; holder != "" && holder != e.instanceID && !expired(l, now) {
+		return false, nil
+	}
+	e.hold(l, now)
+	if _, err := e.client.update(ctx, l); err == errConflict {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to update Lease %s: %v", e.leaseName, err)
+	}
+	return true, nil
+}
+
+// hold makes the instance the holder of l, renewed at now.
+func (e *Election) hold(l *lease, now time.Time) {
+	if l.Spec.HolderIdentity != e.instanceID {
+		l.Spec.HolderIdentity = e.instanceID
+		l.Spec.AcquireTime = newMicroTime(now)
+		l.Spec.LeaseTransitions++
+	}
+	l.Spec.LeaseDurationSeconds = int32(e.opts.LeaseDuration / time.Second)
+	l.Spec.RenewTime = newMicroTime(now)
+}
+
+// expired returns whether l was last renewed longer than its duration ago.
+func expired(l *lease, now time.Time) bool {
+	if l.Spec.RenewTime == nil {
+		return true
+	}
+	d := time.Duration(l.Spec.LeaseDurationSeconds) * time.Second
+	return now.After(l.Spec.RenewTime.Add(d))
+}
+
+// renew renews the Lease every RetryPeriod until ctx is cancelled, or the
+// instance loses the Lease, and then closes renewing.
+func (e *Election) renew(ctx context.Context, done, renewing chan struct{}) {
+	defer close(renewing)
+	renewed := e.opts.TimeSource.Now()
+	for {
+		if err := clock.SleepSource(ctx, e.opts.RetryPeriod, e.opts.TimeSource); err != nil {
+			return
+		}
+		// The renewal isn't cancelled with ctx, as the API server could still
+		// apply an update abandoned by the client after renew has returned.
+		rctx, cancel := context.WithTimeout(context.Background(), e.opts.LeaseDuration)
+		held, err := e.tryAcquire(rctx)
+		cancel()
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err == nil && held:
+			renewed = e.opts.TimeSource.Now()
+			continue
+		case err == nil:
+			glog.Warningf("%s: Lease %s overtaken by another instance", e.resourceID, e.leaseName)
+		case clock.SecondsSince(e.opts.TimeSource, renewed) < e.opts.LeaseDuration.Seconds():
+			glog.Warningf("%s: failed to renew Lease: %v", e.resourceID, err)
+			continue
+		default:
+			glog.Errorf("%s: Lease %s expired, last renewal failed: %v", e.resourceID, e.leaseName, err)
+		}
+		e.endMastership(done)
+		return
+	}
+}
+
+// endMastership cancels the mastership contexts, unless mastership was
+// already ended, or captured again, since done was created. It returns a
+// channel which is closed once the goroutine renewing the Lease has returned,
+// or nil if mastership was not ended by this call.
+func (e *Election) endMastership(done chan struct{}) chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.done != done {
+		return nil
+	}
+	e.stopRenewing()
+	close(done)
+	renewing := e.renewing
+	e.done, e.stopRenewing, e.renewing = nil, nil, nil
+	return renewing
+}
+
+// WithMastership returns a "mastership context" which remains active until the
+// instance stops being the master, or the passed in context is canceled.
+func (e *Election) WithMastership(ctx context.Context) (context.Context, error) {
+	cctx, cancel := context.WithCancel(ctx)
+	e.mu.Lock()
+	done := e.done
+	e.mu.Unlock()
+	if done == nil {
+		cancel()
+		return cctx, nil
+	}
+	go func() {
+		select {
+		case <-done:
+			glog.Infof("%s: canceled mastership context", e.resourceID)
+		case <-cctx.Done():
+		}
+		cancel()
+	}()
+	return cctx, nil
+}
+
+// Resign releases mastership for this instance. The instance can be elected
+// again using Await. Idempotent, might be useful to retry if fails.
+func (e *Election) Resign(ctx context.Context) error {
+	e.mu.Lock()
+	done := e.done
+	e.mu.Unlock()
+	if done != nil {
+		// Wait for the renewal in flight, if any, so that it doesn't race with
+		// the release of the Lease below.
+		if renewing := e.endMastership(done); renewing != nil {
+			select {
+			case <-renewing:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	// Release the Lease so that other instances needn't wait for it to expire.
+	l, err := e.client.get(ctx, e.leaseName)
+	if err == errNotFound {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read Lease %s: %v", e.leaseName, err)
+	}
+	if l.Spec.HolderIdentity != e.instanceID {
+		return nil // Resigning if not master is a no-op.
+	}
+	l.Spec.HolderIdentity = ""
+	l.Spec.RenewTime = nil
+	if _, err := e.client.update(ctx, l); err != nil {
+		return fmt.Errorf("failed to release Lease %s: %v", e.leaseName, err)
+	}
+	return nil
+}
+
+// Close resigns and permanently stops participating in election. No other
+// method should be called after Close.
+func (e *Election) Close(ctx context.Context) error {
+	if err := e.Resign(ctx); err != nil {
+		// The Lease expires after LeaseDuration anyway.
+		glog.Errorf("%s: Resign(): %v", e.resourceID, err)
+	}
+	return nil
+}
+
+// Factory creates Election instances.
+type Factory struct {
+	client     *Client
+	instanceID string
+	prefix     string
+	opts       Options
+}
+
+// NewFactory builds an election factory whose Elections use the Lease named
+// <prefix>-<resourceID>, e.g. the ID of a log.
+func NewFactory(instanceID string, client *Client, prefix string, opts Options) (*Factory, error) {
+	if opts.LeaseDuration < time.Second {
+		return nil, fmt.Errorf("lease duration must be at least 1s, got %v", opts.LeaseDuration)
+	}
+	if opts.RetryPeriod <= 0 || opts.RetryPeriod >= opts.LeaseDuration {
+		return nil, fmt.Errorf("retry period must be positive and less than the lease duration %v, got %v", opts.LeaseDuration, opts.RetryPeriod)
+	}
+	if opts.TimeSource == nil {
+		opts.TimeSource = clock.System
+	}
+	return &Factory{
+		client:     client,
+		instanceID: instanceID,
+		prefix:     prefix,
+		opts:       opts,
+	}, nil
+}
+
+// NewElection creates a specific Election instance.
+func (f *Factory) NewElection(ctx context.Context, resourceID string) (election2.Election, error) {
+	el := &Election{
+		resourceID: resourceID,
+		instanceID: f.instanceID,
+		leaseName:  fmt.Sprintf("%s-%s", f.prefix, resourceID),
+		client:     f.client,
+		opts:       f.opts,
+	}
+	glog.Infof("Election created: resource %s, Lease %s", resourceID, el.leaseName)
+	return el, nil
+}
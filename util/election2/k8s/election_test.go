@@ -0,0 +1,242 @@
+// Copyright 2019 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util/clock"
+	"github.com/google/trillian/util/election2/testonly"
+)
+
+const leasesPath = "/apis/coordination.k8s.io/v1/namespaces/ns/leases"
+
+// fakeAPIServer serves the Lease API of namespace "ns", with optimistic
+// concurrency control as in Kubernetes.
+type fakeAPIServer struct {
+	mu      sync.Mutex
+	leases  map[string]*lease
+	version int
+}
+
+func newFakeAPIServer(t *testing.T) (*fakeAPIServer, *Client, func()) {
+	t.Helper()
+	f := &fakeAPIServer{leases: make(map[string]*lease)}
+	s := httptest.NewServer(f)
+	return f, NewClient(s.URL, "ns", "token", s.Client()), s.Close
+}
+
+func (f *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, leasesPath) {
+		http.NotFound(w, r)
+		return
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, leasesPath), "/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var l lease
+	if r.Method != http.MethodGet {
+		if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	old, exists := f.leases[name]
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		l = *old
+	case http.MethodPost:
+		if _, exists := f.leases[l.Metadata.Name]; exists {
+			http.Error(w, "exists", http.StatusConflict)
+			return
+		}
+		f.store(&l)
+	case http.MethodPut:
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		if l.Metadata.ResourceVersion != old.Metadata.ResourceVersion {
+			http.Error(w, "stale resourceVersion", http.StatusConflict)
+			return
+		}
+		f.store(&l)
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(l)
+}
+
+func (f *fakeAPIServer) store(l *lease) {
+	f.version++
+	l.Metadata.ResourceVersion = strconv.Itoa(f.version)
+	f.leases[l.Metadata.Name] = l
+}
+
+func (f *fakeAPIServer) holder(name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if l, ok := f.leases[name]; ok {
+		return l.Spec.HolderIdentity
+	}
+	return ""
+}
+
+func newFactory(t *testing.T, instanceID string, client *Client, prefix string, ts clock.TimeSource) *Factory {
+	t.Helper()
+	f, err := NewFactory(instanceID, client, prefix, Options{
+		LeaseDuration: 2 * time.Second,
+		RetryPeriod:   10 * time.Millisecond,
+		TimeSource:    ts,
+	})
+	if err != nil {
+		t.Fatalf("NewFactory(): %v", err)
+	}
+	return f
+}
+
+func TestElection(t *testing.T) {
+	_, client, cleanup := newFakeAPIServer(t)
+	defer cleanup()
+
+	for _, nt := range testonly.Tests {
+		// Create a new Factory for each test for better isolation.
+		fact := newFactory(t, "testID", client, nt.Name, nil)
+		t.Run(nt.Name, func(t *testing.T) {
+			nt.Run(t, fact)
+		})
+	}
+}
+
+func TestElectionTakeover(t *testing.T) {
+	ctx := context.Background()
+	api, client, cleanup := newFakeAPIServer(t)
+	defer cleanup()
+	ts := clock.NewFake(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	e1, err := newFactory(t, "one", client, "res", ts).NewElection(ctx, "10")
+	if err != nil {
+		t.Fatalf("NewElection(one): %v", err)
+	}
+	e2, err := newFactory(t, "two", client, "res", ts).NewElection(ctx, "10")
+	if err != nil {
+		t.Fatalf("NewElection(two): %v", err)
+	}
+	if err := e1.Await(ctx); err != nil {
+		t.Fatalf("Await(one): %v", err)
+	}
+	if got, want := api.holder("res-10"), "one"; got != want {
+		t.Errorf("Lease holder: %q, want %q", got, want)
+	}
+
+	// The second instance can't capture a Lease which hasn't expired.
+	if acquired, err := e2.(*Election).tryAcquire(ctx); err != nil || acquired {
+		t.Errorf("tryAcquire(two): %v, %v, want false", acquired, err)
+	}
+	if err := e1.(*Election).Close(ctx); err != nil {
+		t.Fatalf("Close(one): %v", err)
+	}
+
+	// Nor one which was renewed within its duration, even though not released.
+	if err := e1.Await(ctx); err != nil {
+		t.Fatalf("Await(one): %v", err)
+	}
+	e1.(*Election).endMastership(e1.(*Election).done)
+	ts.Set(ts.Now().Add(time.Second))
+	if acquired, err := e2.(*Election).tryAcquire(ctx); err != nil || acquired {
+		t.Errorf("tryAcquire(two) before expiry: %v, %v, want false", acquired, err)
+	}
+	ts.Set(ts.Now().Add(2 * time.Second))
+	if err := e2.Await(ctx); err != nil {
+		t.Fatalf("Await(two) after expiry: %v", err)
+	}
+	if got, want := api.holder("res-10"), "two"; got != want {
+		t.Errorf("Lease holder: %q, want %q", got, want)
+	}
+	if err := e2.Close(ctx); err != nil {
+		t.Fatalf("Close(two): %v", err)
+	}
+	if got := api.holder("res-10"); got != "" {
+		t.Errorf("Lease holder after Close(): %q, want none", got)
+	}
+}
+
+func TestElectionLostLease(t *testing.T) {
+	ctx := context.Background()
+	api, client, cleanup := newFakeAPIServer(t)
+	defer cleanup()
+
+	e, err := newFactory(t, "one", client, "res", nil).NewElection(ctx, "10")
+	if err != nil {
+		t.Fatalf("NewElection(): %v", err)
+	}
+	if err := e.Await(ctx); err != nil {
+		t.Fatalf("Await(): %v", err)
+	}
+	mctx, err := e.WithMastership(ctx)
+	if err != nil {
+		t.Fatalf("WithMastership(): %v", err)
+	}
+
+	// Another instance takes the Lease over, e.g. after a network partition.
+	api.mu.Lock()
+	api.leases["res-10"].Spec.HolderIdentity = "two"
+	api.mu.Unlock()
+
+	select {
+	case <-mctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("mastership context not canceled after losing the Lease")
+	}
+	if err := e.Close(ctx); err != nil {
+		t.Errorf("Close(): %v", err)
+	}
+	if got, want := api.holder("res-10"), "two"; got != want {
+		t.Errorf("Lease holder after Close(): %q, want %q", got, want)
+	}
+}
+
+func TestNewFactoryErrors(t *testing.T) {
+	for _, opts := range []Options{
+		{LeaseDuration: 0, RetryPeriod: time.Second},
+		{LeaseDuration: 15 * time.Second, RetryPeriod: 0},
+		{LeaseDuration: 15 * time.Second, RetryPeriod: 15 * time.Second},
+	} {
+		t.Run(fmt.Sprintf("%v-%v", opts.LeaseDuration, opts.RetryPeriod), func(t *testing.T) {
+			if _, err := NewFactory("id", nil, "res", opts); err == nil {
+				t.Error("NewFactory(): nil, want error")
+			}
+		})
+	}
+}